@@ -207,9 +207,18 @@ func (cmd *Command) Parse(argv []string) (*Invocation, error) {
 // ParseWithParent is like [Command.Parse], but allows to specify
 // the parent [Invocation]. It is used internally for implementing
 // sub-commands.
+//
+// Persistent options of the parent Command (and of its ancestors)
+// are inherited by the Command (see [Option] for details).
 func (cmd *Command) ParseWithParent(parent *Invocation,
 	argv []string) (*Invocation, error) {
-	prs := newParser(cmd, argv)
+
+	var inherited []*Option
+	if parent != nil {
+		inherited = parent.cmd.persistentOptions(parent.inherited)
+	}
+
+	prs := newParser(cmd, argv, inherited)
 
 	return prs.parse(parent)
 }
@@ -217,7 +226,7 @@ func (cmd *Command) ParseWithParent(parent *Invocation,
 // Run parses the command, then calls its handler.
 func (cmd *Command) Run(ctx context.Context, argv []string) error {
 	if len(argv) > 0 && argv[0] == "--bash-completion" {
-		compl := cmd.Complete(argv[1:])
+		compl := cmd.CompleteContext(ctx, argv[1:])
 		for _, c := range compl {
 			s := ""
 			for _, c := range c.String {
@@ -315,8 +324,24 @@ func (cmd *Command) handler(ctx context.Context, inv *Invocation) error {
 //	prompt> hello    ->  ["hello", ""]
 //	  Cursor      ^
 func (cmd *Command) Complete(argv []string) []Completion {
-	prs := newParser(cmd, argv)
-	return prs.complete()
+	return cmd.CompleteContext(context.Background(), argv)
+}
+
+// CompleteContext is like [Command.Complete], but allows to specify
+// the context, passed to the [LiveCompleter] callbacks.
+func (cmd *Command) CompleteContext(ctx context.Context,
+	argv []string) []Completion {
+
+	return cmd.completeWithInherited(ctx, argv, nil)
+}
+
+// completeWithInherited is like [Command.CompleteContext], but
+// also accepts options, inherited from the parent Commands.
+func (cmd *Command) completeWithInherited(ctx context.Context,
+	argv []string, inherited []*Option) []Completion {
+
+	prs := newParser(cmd, argv, inherited)
+	return prs.complete(ctx)
 }
 
 // hasOptions tells if Command has Options
@@ -386,6 +411,58 @@ func (cmd *Command) FindSubCommandCandidates(name string) []*Command {
 	return inexact
 }
 
+// persistentOptions returns options, inherited by the Command's
+// sub-commands: the Command's own persistent options, followed by
+// the options, inherited by the Command itself.
+func (cmd *Command) persistentOptions(inherited []*Option) []*Option {
+	var persistent []*Option
+	for i := range cmd.Options {
+		if cmd.Options[i].Persistent {
+			persistent = append(persistent, &cmd.Options[i])
+		}
+	}
+
+	return append(persistent, cmd.inheritedOptions(inherited)...)
+}
+
+// inheritedOptions returns inherited options, visible to the
+// Command. Options, shadowed by the Command's own options with
+// the same name, are excluded.
+func (cmd *Command) inheritedOptions(inherited []*Option) []*Option {
+	var visible []*Option
+
+	for _, opt := range inherited {
+		shadowed := false
+		for _, name := range opt.names() {
+			if cmd.findOption(name) != nil {
+				shadowed = true
+				break
+			}
+		}
+
+		if !shadowed {
+			visible = append(visible, opt)
+		}
+	}
+
+	return visible
+}
+
+// findOption finds Command's own Option by name.
+// If Option is not found, it returns nil.
+func (cmd *Command) findOption(name string) *Option {
+	for i := range cmd.Options {
+		opt := &cmd.Options[i]
+		for _, n := range opt.names() {
+			if name == n {
+				return opt
+			}
+		}
+	}
+
+	return nil
+}
+
 // names returns Command names, including aliases
 func (cmd *Command) names() []string {
 	names := make([]string, len(cmd.Aliases)+1)
@@ -9,11 +9,16 @@
 package argv
 
 import (
+	"context"
 	"io/fs"
 	"os"
 	"strings"
+	"time"
 )
 
+// LiveCompleterTimeout limits execution time of the [LiveCompleter].
+const LiveCompleterTimeout = 300 * time.Millisecond
+
 // Completer is a callback called for auto-completion
 //
 // Any [Option] or [Parameter] may have its own Completer.
@@ -32,6 +37,44 @@ import (
 //	"Rol" -> []
 type Completer func(string) []Completion
 
+// LiveCompleter is a callback called for auto-completion, that
+// may query the live state, for example, the list of printers,
+// known to the server.
+//
+// It receives the context, derived from the completion invocation,
+// with the [LiveCompleterTimeout] deadline applied, and the value
+// prefix, already typed by user, and returns the list of candidates.
+// Candidates are filtered the same way as by [CompleteStrings],
+// so LiveCompleter may simply return all known values.
+//
+// If LiveCompleter doesn't return in time, its result is ignored,
+// so it cannot hang the shell. On errors, LiveCompleter should
+// simply return nil.
+type LiveCompleter func(ctx context.Context, prefix string) []string
+
+// complete calls the LiveCompleter and converts its output
+// into the slice of [Completion].
+func (lc LiveCompleter) complete(ctx context.Context,
+	prefix string) []Completion {
+
+	ctx, cancel := context.WithTimeout(ctx, LiveCompleterTimeout)
+	defer cancel()
+
+	done := make(chan []string, 1)
+	go func() {
+		done <- lc(ctx, prefix)
+	}()
+
+	var candidates []string
+	select {
+	case candidates = <-done:
+	case <-ctx.Done():
+		return nil
+	}
+
+	return CompleteStrings(candidates)(prefix)
+}
+
 // CompleteStrings returns a [Completer], that performs auto-completion,
 // choosing from a set of supplied strings.
 func CompleteStrings(s []string) Completer {
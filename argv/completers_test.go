@@ -9,11 +9,13 @@
 package argv
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"reflect"
 	"testing"
 	"testing/fstest"
+	"time"
 )
 
 // TestCompleteStrings tests CompleteStrings
@@ -170,3 +172,93 @@ func TestCompleteFs(t *testing.T) {
 		}
 	}
 }
+
+// TestLiveCompleter tests LiveCompleter
+func TestLiveCompleter(t *testing.T) {
+	type ctxKey struct{}
+
+	// Fast completer. Checks that context is derived from
+	// the completion invocation and has the deadline.
+	fast := LiveCompleter(func(ctx context.Context,
+		prefix string) []string {
+		if ctx.Value(ctxKey{}) == nil {
+			t.Errorf("LiveCompleter: context is not inherited")
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("LiveCompleter: deadline is not set")
+		}
+		return []string{"Printer-1", "Printer-2", "Other"}
+	})
+
+	// Slow completer that ignores its context
+	release := make(chan struct{})
+	defer close(release)
+
+	slow := LiveCompleter(func(ctx context.Context,
+		prefix string) []string {
+		<-release
+		return []string{"Printer-1"}
+	})
+
+	cmd := Command{
+		Name: "test",
+		Options: []Option{
+			{
+				Name:         "-p",
+				Validate:     ValidateAny,
+				CompleteLive: fast,
+			},
+			{
+				Name:         "-s",
+				Validate:     ValidateAny,
+				CompleteLive: slow,
+			},
+		},
+		Parameters: []Parameter{
+			{
+				Name:         "printer",
+				CompleteLive: fast,
+			},
+		},
+	}
+
+	err := cmd.Verify()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, true)
+
+	// Option value, filtered by prefix
+	out := cmd.CompleteContext(ctx, []string{"-p", "Pr"})
+	expected := []Completion{{"Printer-", true}}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("option: expected %#v, present %#v", expected, out)
+	}
+
+	// Parameter
+	out = cmd.CompleteContext(ctx, []string{"Printer-"})
+	expected = []Completion{{"Printer-1", false}, {"Printer-2", false}}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("parameter: expected %#v, present %#v", expected, out)
+	}
+
+	// Timeout
+	start := time.Now()
+	out = cmd.CompleteContext(ctx, []string{"-s", ""})
+	elapsed := time.Since(start)
+
+	if len(out) != 0 {
+		t.Errorf("timeout: unexpected output %#v", out)
+	}
+
+	if elapsed > 2*LiveCompleterTimeout {
+		t.Errorf("timeout: completion took %s", elapsed)
+	}
+
+	// Complete and CompleteLive are mutually exclusive
+	cmd.Options[0].Complete = CompleteStrings(nil)
+	if cmd.Verify() == nil {
+		t.Errorf("Complete and CompleteLive: error expected")
+	}
+}
@@ -16,9 +16,13 @@ import (
 
 // DefaultHandler is the default Handler for [Command]
 func DefaultHandler(ctx context.Context, inv *Invocation) error {
-	subcmd, subargv := inv.SubCommand()
-	if subcmd != nil {
-		return subcmd.RunWithParent(ctx, inv, subargv)
+	subinv, err := inv.SubInvocation()
+	if err != nil {
+		return err
+	}
+
+	if subinv != nil {
+		return subinv.cmd.handler(ctx, subinv)
 	}
 
 	argv := append([]string{inv.Cmd().Name}, inv.Argv()...)
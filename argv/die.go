@@ -9,6 +9,7 @@
 package argv
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -22,8 +23,28 @@ var (
 	dieExit             = os.Exit
 )
 
+// ExitStatus is the error that, when returned by the [Handler],
+// causes [Command.Main] to exit with the specified status, without
+// printing any message. It allows commands to report the result
+// (e.g., severity of the found problems) via exit status.
+type ExitStatus int
+
+// Error returns the error message. It implements the error interface.
+func (status ExitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(status))
+}
+
 // die writes message into the os.Stderr and dies.
+//
+// If err is the [ExitStatus], the message is not written
+// and the process exits with the requested status.
 func die(err error) {
+	var status ExitStatus
+	if errors.As(err, &status) {
+		dieExit(int(status))
+		return
+	}
+
 	fmt.Fprintf(dieOutput, "%s\n", err)
 	dieExit(1)
 }
@@ -59,15 +59,21 @@ func HelpHandler(ctx context.Context, inv *Invocation) error {
 	// In the later case, if parent cannot be figured, it is an error. Looks
 	// that somebody is calling HelpCommand directly, not as a part of
 	// sub-commands hierarchy.
+	//
+	// Persistent options, inherited by the target command, are
+	// described in the separate "Global options" section.
 	var cmd *Command
+	var inherited []*Option
 	if inv.IsImmediate() {
 		cmd = inv.Cmd()
+		inherited = inv.inherited
 	} else {
 		parent := inv.Parent()
 		if parent == nil {
 			return errors.New("HelpHandler must be used in sub-command")
 		}
 		cmd = parent.Cmd()
+		inherited = parent.inherited
 	}
 
 	// The 'help' command may have an optional parameter,
@@ -78,11 +84,12 @@ func HelpHandler(ctx context.Context, inv *Invocation) error {
 		if err != nil {
 			return err
 		}
+		inherited = cmd.persistentOptions(inherited)
 		cmd = subcmd
 	}
 
 	// And if it is OK so far, it's a time to generate a help page
-	Help(cmd, HelpOutput)
+	helpWithGlobals(cmd, inherited, HelpOutput)
 
 	return nil
 }
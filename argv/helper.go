@@ -66,9 +66,10 @@ var (
 
 // helper builds help
 type helper struct {
-	cmd *Command  // Target command
-	out io.Writer // Output goes here
-	err error     // Sticky I/O error
+	cmd     *Command  // Target command
+	globals []*Option // Options, inherited from the parent commands
+	out     io.Writer // Output goes here
+	err     error     // Sticky I/O error
 }
 
 // Help generates a help page and writes it into output io.Writer.
@@ -77,7 +78,14 @@ type helper struct {
 // The returned error, if any, is the I/O error from the destination
 // io.Writer.
 func Help(cmd *Command, out io.Writer) error {
+	return helpWithGlobals(cmd, nil, out)
+}
+
+// helpWithGlobals is like Help, but also describes persistent
+// options, inherited from the parent commands.
+func helpWithGlobals(cmd *Command, inherited []*Option, out io.Writer) error {
 	hlp := newHelper(cmd, out)
+	hlp.globals = cmd.inheritedOptions(inherited)
 	hlp.generate()
 	return hlp.err
 }
@@ -111,6 +119,7 @@ func newHelper(cmd *Command, out io.Writer) *helper {
 func (hlp *helper) generate() {
 	hlp.describeUsageLine()
 	hlp.describeOptions()
+	hlp.describeGlobalOptions()
 	hlp.describeParameters()
 	hlp.describeSubCommands()
 	hlp.describeCommandLong()
@@ -122,7 +131,7 @@ func (hlp *helper) describeUsageLine() {
 
 	hlp.printf("usage: %s", cmd.Name)
 
-	if cmd.hasOptions() {
+	if cmd.hasOptions() || len(hlp.globals) != 0 {
 		hlp.printf(" [options]")
 	}
 
@@ -150,44 +159,63 @@ func (hlp *helper) describeOptions() {
 	hlp.puts("Options are:\n")
 
 	for i := range cmd.Options {
-		opt := &cmd.Options[i]
-		names := opt.names()
-		namesHelp := hlpSpcOptionName + strings.Join(opt.names(), ", ")
+		hlp.describeOption(&cmd.Options[i])
+	}
+}
 
-		if opt.HelpArg != "" {
-			if strings.HasPrefix(names[len(names)-1], "--") {
-				namesHelp += "="
-			} else {
-				namesHelp += " "
-			}
+// describeGlobalOptions describes options, inherited from
+// the parent commands
+func (hlp *helper) describeGlobalOptions() {
+	if len(hlp.globals) == 0 {
+		return
+	}
 
-			namesHelp += opt.HelpArg
+	hlp.nl()
+	hlp.puts("Global options:\n")
+
+	for _, opt := range hlp.globals {
+		hlp.describeOption(opt)
+	}
+}
+
+// describeOption describes a single option
+func (hlp *helper) describeOption(opt *Option) {
+	names := opt.names()
+	namesHelp := hlpSpcOptionName + strings.Join(opt.names(), ", ")
+
+	if opt.HelpArg != "" {
+		if strings.HasPrefix(names[len(names)-1], "--") {
+			namesHelp += "="
+		} else {
+			namesHelp += " "
 		}
 
-		hlp.puts(namesHelp)
+		namesHelp += opt.HelpArg
+	}
 
-		help := strings.Split(opt.Help, "\n")
-		if len(help) > 0 {
-			if len(namesHelp)+hlpMinColumnSpace <=
-				hlpOffOptionHelp {
+	hlp.puts(namesHelp)
 
-				if help[0] != "" {
-					hlp.space(hlpOffOptionHelp -
-						len(namesHelp))
-					hlp.puts(help[0])
-				}
-				hlp.nl()
-				help = help[1:]
-			} else {
-				hlp.nl()
+	help := strings.Split(opt.Help, "\n")
+	if len(help) > 0 {
+		if len(namesHelp)+hlpMinColumnSpace <=
+			hlpOffOptionHelp {
+
+			if help[0] != "" {
+				hlp.space(hlpOffOptionHelp -
+					len(namesHelp))
+				hlp.puts(help[0])
 			}
+			hlp.nl()
+			help = help[1:]
+		} else {
+			hlp.nl()
+		}
 
-			for _, line := range help {
-				if line != "" {
-					hlp.puts(hlpSpcOptionHelp + line)
-				}
-				hlp.nl()
+		for _, line := range help {
+			if line != "" {
+				hlp.puts(hlpSpcOptionHelp + line)
 			}
+			hlp.nl()
 		}
 	}
 }
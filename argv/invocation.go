@@ -49,10 +49,19 @@ type Invocation struct {
 	// parameters contains parameters values, indexed by numbers.
 	parameters []string
 
+	// inherited contains persistent options, inherited from
+	// the parent Commands.
+	inherited []*Option
+
 	// subcmd is the Command's SubCommand and subargv is its arguments.
 	subcmd  *Command
 	subargv []string
 
+	// subinv and suberr are the cached results of SubInvocation
+	subinv    *Invocation
+	suberr    error
+	subparsed bool
+
 	// immediate is the first Option's Immediate callback, if any
 	immediate func(context.Context, *Invocation) error
 }
@@ -167,3 +176,29 @@ func (inv *Invocation) ParamGet(n int) (param string) {
 func (inv *Invocation) SubCommand() (*Command, []string) {
 	return inv.subcmd, inv.subargv
 }
+
+// SubInvocation parses the SubCommand's arguments and returns
+// its Invocation. If Command doesn't have SubCommands, this function
+// returns (nil, nil).
+//
+// The sub-command is parsed only once; subsequent calls, including
+// the call from the [DefaultHandler], return the same result.
+//
+// It allows the Command's handler to see effective values of the
+// persistent options before the sub-command is executed, including
+// values specified after the sub-command name:
+//
+//	subinv, err := inv.SubInvocation()
+//	if err == nil && subinv != nil {
+//		debug := subinv.Flag("-d")
+//		...
+//	}
+func (inv *Invocation) SubInvocation() (*Invocation, error) {
+	if inv.subcmd != nil && !inv.subparsed {
+		inv.subinv, inv.suberr = inv.subcmd.ParseWithParent(inv,
+			inv.subargv)
+		inv.subparsed = true
+	}
+
+	return inv.subinv, inv.suberr
+}
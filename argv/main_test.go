@@ -11,6 +11,8 @@ package argv
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -75,3 +77,49 @@ func TestMain(t *testing.T) {
 			expected, received)
 	}
 }
+
+// TestMainExitStatus tests ExitStatus handling by (*Command) Main()
+func TestMainExitStatus(t *testing.T) {
+	buf := &bytes.Buffer{}
+	status := -1
+
+	saveArgs := os.Args
+	saveDieOutput := dieOutput
+	saveDieExit := dieExit
+
+	defer func() {
+		os.Args = saveArgs
+		dieOutput = saveDieOutput
+		dieExit = saveDieExit
+	}()
+
+	dieOutput = buf
+	dieExit = func(s int) { status = s }
+
+	for _, err := range []error{
+		ExitStatus(2),
+		fmt.Errorf("wrapped: %w", ExitStatus(3)),
+	} {
+		cmd := Command{
+			Name: "test",
+			Handler: func(ctx context.Context, inv *Invocation) error {
+				return err
+			},
+		}
+
+		buf.Reset()
+		os.Args = []string{"test"}
+		cmd.Main(nil)
+
+		var expected ExitStatus
+		errors.As(err, &expected)
+
+		if status != int(expected) {
+			t.Errorf("%s: exit status %d", err, status)
+		}
+
+		if buf.Len() != 0 {
+			t.Errorf("%s: unexpected output: %q", err, buf)
+		}
+	}
+}
@@ -86,6 +86,18 @@ type Option struct {
 	// more that once.
 	Singleton bool
 
+	// Persistent flag, if set, makes option inherited by all
+	// sub-commands of the Command that defines it.
+	//
+	// Persistent option is recognized both before and after the
+	// sub-command name, and its values are visible via the
+	// sub-command's Invocation. If option is used at both levels,
+	// the sub-command's values override the parent ones.
+	//
+	// Sub-command may shadow the inherited option by defining its
+	// own option with the same name.
+	Persistent bool
+
 	// Validate callback called to validate parameter.
 	//
 	// Use nil to indicate that this option has no value.
@@ -96,6 +108,13 @@ type Option struct {
 	// See description of the Completer type for details.
 	Complete Completer
 
+	// CompleteLive is the auto-completion callback, that may
+	// query the live state. It is mutually exclusive with the
+	// Complete callback.
+	//
+	// See description of the LiveCompleter type for details.
+	CompleteLive LiveCompleter
+
 	// Immediate, if not nil and option was encountered in
 	// the Command's argv, overrides the Command's handler
 	// and check for missed parameters and options is suppressed
@@ -141,6 +160,11 @@ func (opt *Option) verify() error {
 		}
 	}
 
+	if opt.Complete != nil && opt.CompleteLive != nil {
+		return fmt.Errorf("option %q: both Complete and CompleteLive "+
+			"are set", opt.Name)
+	}
+
 	return nil
 }
 
@@ -193,10 +217,16 @@ func (opt *Option) names() []string {
 }
 
 // complete is the convenience wrapper around Option.Complete
-// callback. It call callback only if one is not nil.
-func (opt *Option) complete(prefix string) (compl []Completion) {
-	if opt.Complete != nil {
+// and Option.CompleteLive callbacks. It calls callback only if
+// one is not nil.
+func (opt *Option) complete(ctx context.Context,
+	prefix string) (compl []Completion) {
+
+	switch {
+	case opt.Complete != nil:
 		compl = opt.Complete(prefix)
+	case opt.CompleteLive != nil:
+		compl = opt.CompleteLive.complete(ctx, prefix)
 	}
 
 	return
@@ -9,6 +9,7 @@
 package argv
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -84,6 +85,13 @@ type Parameter struct {
 	//
 	// See description of the Completer type for details
 	Complete Completer
+
+	// CompleteLive is the auto-completion callback, that may
+	// query the live state. It is mutually exclusive with the
+	// Complete callback.
+	//
+	// See description of the LiveCompleter type for details.
+	CompleteLive LiveCompleter
 }
 
 // verify checks correctness of Parameter definition. It fails if any
@@ -121,6 +129,11 @@ func (param *Parameter) verify() error {
 			c, param.Name)
 	}
 
+	if param.Complete != nil && param.CompleteLive != nil {
+		return fmt.Errorf("parameter %q: both Complete and "+
+			"CompleteLive are set", param.Name)
+	}
+
 	return nil
 }
 
@@ -153,10 +166,16 @@ func (param *Parameter) repeated() bool {
 }
 
 // complete is the convenience wrapper around Parameter.Complete
-// callback. It call callback only if one is not nil.
-func (param *Parameter) complete(prefix string) (compl []Completion) {
-	if param.Complete != nil {
+// and Parameter.CompleteLive callbacks. It calls callback only if
+// one is not nil.
+func (param *Parameter) complete(ctx context.Context,
+	prefix string) (compl []Completion) {
+
+	switch {
+	case param.Complete != nil:
 		compl = param.Complete(prefix)
+	case param.CompleteLive != nil:
+		compl = param.CompleteLive.complete(ctx, prefix)
 	}
 
 	return
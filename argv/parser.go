@@ -9,6 +9,7 @@
 package argv
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"strings"
@@ -30,6 +31,7 @@ import (
 // already seen, using "--opt2" among all its possible aliases.
 type parser struct {
 	inv          *Invocation               // Invocation being parsed
+	inherited    []*Option                 // Inherited persistent options
 	nextarg      int                       // Index of the next argument
 	optConflicts map[string]string         // Conflicting options
 	optRequired  map[string]string         // Required options
@@ -53,19 +55,26 @@ type parserParamVal struct {
 
 // newParser creates a new parser.
 //
+// The inherited parameter contains persistent options, inherited
+// from the parent Commands.
+//
 // It panics, if cmd.Verify() returns an error.
-func newParser(cmd *Command, argv []string) *parser {
+func newParser(cmd *Command, argv []string, inherited []*Option) *parser {
 	err := cmd.Verify()
 	if err != nil {
 		panic(err)
 	}
 
+	inherited = cmd.inheritedOptions(inherited)
+
 	return &parser{
 		inv: &Invocation{
-			cmd:    cmd,
-			argv:   argv,
-			byName: make(map[string][]string),
+			cmd:       cmd,
+			argv:      argv,
+			byName:    make(map[string][]string),
+			inherited: inherited,
 		},
+		inherited:    inherited,
 		optConflicts: make(map[string]string),
 		optRequired:  make(map[string]string),
 		optSeen:      make(map[string]string),
@@ -162,7 +171,7 @@ func (prs *parser) doParse(parent *Invocation) error {
 	}
 
 	// Build prs.inv.byName map
-	prs.buildByName()
+	prs.buildByName(parent)
 
 	// Validate things
 	if err := prs.validateThings(); err != nil {
@@ -331,7 +340,7 @@ func (prs *parser) handleSubCommand(arg string) error {
 }
 
 // buildByName populates prs.inv.byName map
-func (prs *parser) buildByName() {
+func (prs *parser) buildByName(parent *Invocation) {
 	// Save options values
 	for _, optval := range prs.options {
 		opt := optval.opt
@@ -352,6 +361,26 @@ func (prs *parser) buildByName() {
 
 		prs.inv.byName[name] = values
 	}
+
+	// Inherit values of persistent options, not used at this
+	// level. Note, the parent's byName already contains values,
+	// inherited by the parent.
+	if parent == nil {
+		return
+	}
+
+	for _, opt := range prs.inv.cmd.persistentOptions(prs.inherited) {
+		if prs.options[opt] != nil {
+			continue
+		}
+
+		for _, name := range opt.names() {
+			_, found := prs.inv.byName[name]
+			if values, ok := parent.byName[name]; ok && !found {
+				prs.inv.byName[name] = values
+			}
+		}
+	}
 }
 
 // validateThings validates things that can only be verified
@@ -384,7 +413,7 @@ func (prs *parser) validateThings() error {
 }
 
 // complete handles command auto-completion
-func (prs *parser) complete() (compl []Completion) {
+func (prs *parser) complete(ctx context.Context) (compl []Completion) {
 	doneOptions := false
 	paramCount := 0
 
@@ -440,7 +469,7 @@ func (prs *parser) complete() (compl []Completion) {
 				// Complete or skip the value
 				val = prs.next()
 				if prs.done() {
-					return prs.completeOptionValue(opt, val)
+					return prs.completeOptionValue(ctx, opt, val)
 				}
 
 			case prs.done():
@@ -457,8 +486,8 @@ func (prs *parser) complete() (compl []Completion) {
 				if opt != nil {
 					// If option is not unknown, we may
 					// try to complete the value.
-					compl = prs.completeOptionValue(opt,
-						val)
+					compl = prs.completeOptionValue(ctx,
+						opt, val)
 
 					prefix := name
 					if prs.isLongOption(name) {
@@ -481,7 +510,10 @@ func (prs *parser) complete() (compl []Completion) {
 			// complete self
 			if subcmd != nil && !prs.done() {
 				argv := prs.inv.argv[prs.nextarg:]
-				return subcmd.Complete(argv)
+				inherited := prs.inv.cmd.persistentOptions(
+					prs.inherited)
+				return subcmd.completeWithInherited(ctx,
+					argv, inherited)
 			}
 
 			// If we are at the end of argv, complete
@@ -501,19 +533,20 @@ func (prs *parser) complete() (compl []Completion) {
 			if !prs.done() {
 				paramCount++
 			} else {
-				return prs.completeParameter(arg, paramCount)
+				return prs.completeParameter(ctx, arg,
+					paramCount)
 			}
 		}
 	}
 
 	switch {
 	case prs.inv.cmd.hasParameters():
-		compl = prs.completeParameter("", paramCount)
+		compl = prs.completeParameter(ctx, "", paramCount)
 
 	case prs.inv.cmd.hasSubCommands():
 		compl = prs.completeSubCommandName("")
 
-	case prs.inv.cmd.hasOptions():
+	case len(prs.allOptions()) != 0:
 		compl = prs.completeOptionName("")
 	}
 
@@ -523,9 +556,7 @@ func (prs *parser) complete() (compl []Completion) {
 // completeOptionName returns slice of completion candidates for
 // Option name
 func (prs *parser) completeOptionName(arg string) (compl []Completion) {
-	for i := range prs.inv.cmd.Options {
-		opt := &prs.inv.cmd.Options[i]
-
+	for _, opt := range prs.allOptions() {
 		for _, name := range opt.names() {
 			if strings.HasPrefix(name, arg) {
 				c := Completion{name, false}
@@ -542,10 +573,10 @@ func (prs *parser) completeOptionName(arg string) (compl []Completion) {
 }
 
 // completeOption handles auto-completion for options.
-func (prs *parser) completeOptionValue(opt *Option, arg string) (
-	compl []Completion) {
+func (prs *parser) completeOptionValue(ctx context.Context,
+	opt *Option, arg string) (compl []Completion) {
 
-	compl = opt.complete(arg)
+	compl = opt.complete(ctx, arg)
 	compl = prs.completePostProcess(arg, compl)
 
 	return
@@ -553,7 +584,8 @@ func (prs *parser) completeOptionValue(opt *Option, arg string) (
 
 // completeParameter handles auto-completion for positional
 // Parameters. 'n' is the count of preceding Parameters.
-func (prs *parser) completeParameter(arg string, n int) (compl []Completion) {
+func (prs *parser) completeParameter(ctx context.Context,
+	arg string, n int) (compl []Completion) {
 
 	var paramFound *Parameter
 
@@ -566,7 +598,7 @@ func (prs *parser) completeParameter(arg string, n int) (compl []Completion) {
 	}
 
 	if paramFound != nil {
-		compl := paramFound.complete(arg)
+		compl := paramFound.complete(ctx, arg)
 		return prs.completePostProcess(arg, compl)
 	}
 
@@ -682,10 +714,14 @@ func (prs *parser) splitOptVal(arg string) (name, val string, novalue bool) {
 	return
 }
 
-// findOption finds Command's Option by name.
+// findOption finds Command's Option by name, including
+// options, inherited from the parent Commands.
 func (prs *parser) findOption(name string) *Option {
-	for i := range prs.inv.cmd.Options {
-		opt := &prs.inv.cmd.Options[i]
+	if opt := prs.inv.cmd.findOption(name); opt != nil {
+		return opt
+	}
+
+	for _, opt := range prs.inherited {
 		for _, n := range opt.names() {
 			if name == n {
 				return opt
@@ -696,6 +732,17 @@ func (prs *parser) findOption(name string) *Option {
 	return nil
 }
 
+// allOptions returns all Command's Options, followed by options,
+// inherited from the parent Commands.
+func (prs *parser) allOptions() []*Option {
+	opts := make([]*Option, 0, len(prs.inv.cmd.Options)+len(prs.inherited))
+	for i := range prs.inv.cmd.Options {
+		opts = append(opts, &prs.inv.cmd.Options[i])
+	}
+
+	return append(opts, prs.inherited...)
+}
+
 // paramsInfo returns information on a command parameters:
 //
 //	paramsMin - minimal count of parameters
@@ -28,7 +28,7 @@ func TestNewParserPanic(t *testing.T) {
 	}()
 
 	// It must panic, because empty Command is invalid
-	newParser(&Command{}, []string{})
+	newParser(&Command{}, []string{}, nil)
 }
 
 // TestParser tests argv parser
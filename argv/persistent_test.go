@@ -0,0 +1,342 @@
+// MFP  - Miulti-Function Printers and scanners toolkit
+// argv - Argv parsing mini-library
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Persistent options test
+
+package argv
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testCommandPersistent is the Command with persistent options
+var testCommandPersistent = Command{
+	Name: "tool",
+	Options: []Option{
+		{
+			Name:       "-d",
+			Aliases:    []string{"--debug"},
+			Help:       "enable debug output",
+			Persistent: true,
+		},
+		{
+			Name:       "--server",
+			Help:       "server address",
+			HelpArg:    "addr",
+			Validate:   ValidateAny,
+			Persistent: true,
+		},
+		{
+			Name: "--local",
+			Help: "not inherited",
+		},
+		HelpOption,
+	},
+	SubCommands: []Command{
+		{
+			Name: "get",
+			Help: "get the object",
+			Options: []Option{
+				{
+					Name:     "--limit",
+					Help:     "limit",
+					Validate: ValidateAny,
+				},
+				HelpOption,
+			},
+			Parameters: []Parameter{
+				{Name: "[name]"},
+			},
+		},
+		{
+			Name: "shadow",
+			Help: "shadows --server",
+			Options: []Option{
+				{
+					Name:     "--server",
+					Help:     "other meaning",
+					Validate: ValidateAny,
+				},
+			},
+		},
+		{
+			Name: "group",
+			Help: "nested sub-commands",
+			Options: []Option{
+				{
+					Name:       "--group-opt",
+					Help:       "group option",
+					Persistent: true,
+				},
+			},
+			SubCommands: []Command{
+				{Name: "leaf"},
+			},
+		},
+		HelpCommand,
+	},
+}
+
+// testPersistentParse parses argv down to the deepest sub-command
+// and returns its Invocation.
+func testPersistentParse(argv []string) (*Invocation, error) {
+	inv, err := testCommandPersistent.Parse(argv)
+	for err == nil {
+		var sub *Invocation
+		sub, err = inv.SubInvocation()
+		if sub == nil {
+			break
+		}
+		inv = sub
+	}
+
+	return inv, err
+}
+
+// TestPersistentOptions tests persistent options inheritance
+func TestPersistentOptions(t *testing.T) {
+	type testData struct {
+		argv []string            // Input
+		cmd  string              // Expected sub-command name
+		err  string              // Expected error
+		out  map[string][]string // Expected values (subset)
+	}
+
+	tests := []testData{
+		// Before the sub-command name
+		{
+			argv: []string{"-d", "--server", "host", "get", "x"},
+			cmd:  "get",
+			out: map[string][]string{
+				"-d":       {""},
+				"--debug":  {""},
+				"--server": {"host"},
+				"name":     {"x"},
+			},
+		},
+
+		// After the sub-command name
+		{
+			argv: []string{"get", "--debug", "x", "--server=host"},
+			cmd:  "get",
+			out: map[string][]string{
+				"-d":       {""},
+				"--debug":  {""},
+				"--server": {"host"},
+			},
+		},
+
+		// Sub-command values override parent ones
+		{
+			argv: []string{"--server", "a", "get", "--server", "b"},
+			cmd:  "get",
+			out: map[string][]string{
+				"--server": {"b"},
+			},
+		},
+
+		// Operand equals to the sub-command name
+		{
+			argv: []string{"--server", "get", "get", "--server", "get"},
+			cmd:  "get",
+			out: map[string][]string{
+				"--server": {"get"},
+			},
+		},
+
+		// Persistent option operand after the sub-command
+		// is not taken as parameter
+		{
+			argv: []string{"get", "--server", "host"},
+			cmd:  "get",
+			out: map[string][]string{
+				"--server": {"host"},
+				"name":     nil,
+			},
+		},
+
+		// Non-persistent options are not inherited
+		{
+			argv: []string{"get", "--local"},
+			err:  `unknown option: "--local"`,
+		},
+
+		{
+			argv: []string{"--local", "get"},
+			cmd:  "get",
+			out: map[string][]string{
+				"--local": nil,
+			},
+		},
+
+		// Shadowed option
+		{
+			argv: []string{"--server", "a", "shadow", "--server", "b"},
+			cmd:  "shadow",
+			out: map[string][]string{
+				"--server": {"b"},
+				"-d":       nil,
+			},
+		},
+
+		{
+			argv: []string{"--server", "a", "shadow"},
+			cmd:  "shadow",
+			out: map[string][]string{
+				"--server": nil,
+			},
+		},
+
+		// Nested sub-commands
+		{
+			argv: []string{"-d", "group", "leaf", "--group-opt",
+				"--server", "host"},
+			cmd: "leaf",
+			out: map[string][]string{
+				"-d":          {""},
+				"--group-opt": {""},
+				"--server":    {"host"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		inv, err := testPersistentParse(test.argv)
+		argv := strings.Join(test.argv, " ")
+
+		errstr := ""
+		if err != nil {
+			errstr = err.Error()
+		}
+
+		if errstr != test.err {
+			t.Errorf("%s: error mismatch:\n"+
+				"expected: %s\npresent:  %s", argv, test.err, errstr)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if inv.Cmd().Name != test.cmd {
+			t.Errorf("%s: sub-command: expected %q, present %q",
+				argv, test.cmd, inv.Cmd().Name)
+		}
+
+		for name, expected := range test.out {
+			present := inv.Values(name)
+			if !reflect.DeepEqual(present, expected) {
+				t.Errorf("%s: %s: expected %q, present %q",
+					argv, name, expected, present)
+			}
+		}
+	}
+}
+
+// TestPersistentParentHandler tests that the parent Command handler
+// sees the persistent option, specified after the sub-command name,
+// and sub-command is parsed only once.
+func TestPersistentParentHandler(t *testing.T) {
+	var debug bool
+	var seen, child *Invocation
+
+	cmd := testCommandPersistent
+	cmd.Handler = func(ctx context.Context, inv *Invocation) error {
+		sub, err := inv.SubInvocation()
+		if err != nil {
+			return err
+		}
+		debug = sub.Flag("-d")
+		seen = sub
+		return DefaultHandler(ctx, inv)
+	}
+
+	cmd.SubCommands = append([]Command{}, cmd.SubCommands...)
+	cmd.SubCommands[0].Handler = func(ctx context.Context,
+		inv *Invocation) error {
+		child = inv
+		return nil
+	}
+
+	err := cmd.Run(context.Background(), []string{"get", "-d"})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !debug {
+		t.Errorf("-d not visible to the parent handler")
+	}
+
+	if seen != child {
+		t.Errorf("sub-command parsed twice")
+	}
+}
+
+// TestPersistentHelp tests "Global options" section of the help page
+func TestPersistentHelp(t *testing.T) {
+	expected := "" +
+		"usage: get [options] [name]\n" +
+		"\n" +
+		"Options are:\n" +
+		"  --limit               limit\n" +
+		"  -h, --help            print help page\n" +
+		"\n" +
+		"Global options:\n" +
+		"  -d, --debug           enable debug output\n" +
+		"  --server=addr         server address\n" +
+		"\n" +
+		"Parameters are:\n" +
+		"  name\n"
+
+	for _, argv := range [][]string{
+		{"get", "-h"},
+		{"help", "get"},
+		{"-d", "get", "--help"},
+	} {
+		buf := &bytes.Buffer{}
+		save := HelpOutput
+		HelpOutput = buf
+
+		err := testCommandPersistent.Run(context.Background(), argv)
+		HelpOutput = save
+
+		if err != nil {
+			t.Errorf("%s: %s", argv, err)
+			continue
+		}
+
+		if buf.String() != expected {
+			t.Errorf("%s: help mismatch\nexpected:\n%s\npresent:\n%s",
+				argv, expected, buf.String())
+		}
+	}
+
+	// The top-level command has no global options
+	buf := &bytes.Buffer{}
+	Help(&testCommandPersistent, buf)
+	if strings.Contains(buf.String(), "Global options") {
+		t.Errorf("unexpected Global options at the top level")
+	}
+}
+
+// TestPersistentComplete tests completion of inherited options
+func TestPersistentComplete(t *testing.T) {
+	compl := testCommandPersistent.Complete([]string{"get", "--se"})
+	expected := []Completion{{"--server=", true}}
+	if !reflect.DeepEqual(compl, expected) {
+		t.Errorf("expected %v, present %v", expected, compl)
+	}
+
+	compl = testCommandPersistent.Complete([]string{"get", "--lo"})
+	if len(compl) != 0 {
+		t.Errorf("--local must not be completed: %v", compl)
+	}
+}
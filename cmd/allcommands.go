@@ -14,6 +14,7 @@ import (
 	"github.com/OpenPrinting/go-mfp/cmd/mfp-discover/discover"
 	"github.com/OpenPrinting/go-mfp/cmd/mfp-ppd/ppd"
 	"github.com/OpenPrinting/go-mfp/cmd/mfp-proxy/proxy"
+	"github.com/OpenPrinting/go-mfp/internal/env"
 )
 
 // AllCommands is the argv.Command, that includes all other commands
@@ -21,6 +22,8 @@ import (
 var AllCommands = &argv.Command{
 	Name: "mfp",
 	Options: []argv.Option{
+		env.OptDebug,
+		env.OptVerbose,
 		argv.HelpOption,
 	},
 	SubCommands: []argv.Command{
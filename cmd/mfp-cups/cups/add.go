@@ -0,0 +1,132 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "add" command.
+
+package cups
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// cmdAdd defines the "add" sub-command.
+var cmdAdd = argv.Command{
+	Name:    "add",
+	Help:    "Add a new printer",
+	Handler: cmdAddHandler,
+	Options: []argv.Option{
+		{
+			Name:      "--info",
+			Help:      "Printer description",
+			HelpArg:   "text",
+			Singleton: true,
+			Validate:  argv.ValidateAny,
+		},
+		{
+			Name:      "--location",
+			Help:      "Printer location",
+			HelpArg:   "text",
+			Singleton: true,
+			Validate:  argv.ValidateAny,
+		},
+		{
+			Name: "--no-probe",
+			Help: "Don't probe the device before adding",
+		},
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name: "printer",
+			Help: "printer (queue) name",
+		},
+		{
+			Name: "device-uri",
+			Help: "device URI (ipp, ipps, socket or lpd)\n" +
+				"or bare hostname to discover IPP printer",
+			Validate: validateDeviceURI,
+		},
+	},
+}
+
+// cmdAddHandler is the "add" command handler
+func cmdAddHandler(ctx context.Context, inv *argv.Invocation) error {
+	name := inv.ParamGet(0)
+	uri := inv.ParamGet(1)
+
+	// Discover IPP printer on the bare hostname
+	if addIsBareHost(uri) {
+		candidates, err := ipp.DiscoverEndpoint(ctx, nil, uri)
+		if err != nil {
+			return err
+		}
+
+		for _, cand := range candidates {
+			log.Info(ctx, "%s: found IPP printer %q", cand.URL,
+				cand.MakeModel)
+		}
+
+		uri = candidates[0].URL.String()
+		log.Info(ctx, "using device URI: %s", uri)
+	}
+
+	// Probe the device
+	if !inv.Flag("--no-probe") {
+		res, err := cups.ProbeDeviceURI(ctx, uri)
+		if err != nil {
+			return fmt.Errorf("%s: %w (use --no-probe to add anyway)",
+				uri, err)
+		}
+
+		log.Info(ctx, "%s: reachable, latency %s", uri, res.Latency)
+		if res.MakeModel != "" {
+			log.Info(ctx, "%s: make and model: %q", uri, res.MakeModel)
+		}
+		if res.Info != "" {
+			log.Info(ctx, "%s: %s", uri, res.Info)
+		}
+	}
+
+	// Create the queue
+	settings := &ipp.CUPSPrinterSettings{
+		DeviceURI: optional.New(uri),
+	}
+
+	if val, found := inv.Get("--info"); found {
+		settings.PrinterInfo = optional.New(val)
+	}
+
+	if val, found := inv.Get("--location"); found {
+		settings.PrinterLocation = optional.New(val)
+	}
+
+	dest := optCUPSURL(inv)
+	clnt := cups.NewClient(dest, nil)
+	return clnt.CUPSAddModifyPrinter(ctx, name, settings)
+}
+
+// validateDeviceURI validates the device-uri parameter.
+// It accepts device URIs and bare hostnames.
+func validateDeviceURI(uri string) error {
+	if addIsBareHost(uri) {
+		return nil
+	}
+	return cups.ValidateDeviceURI(uri)
+}
+
+// addIsBareHost reports if the device-uri parameter is the
+// bare hostname or address, without scheme.
+func addIsBareHost(uri string) bool {
+	return uri != "" && !strings.Contains(uri, "://")
+}
@@ -0,0 +1,227 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "class" command.
+
+package cups
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/internal/env"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// cmdClass defines the "class" sub-command.
+var cmdClass = argv.Command{
+	Name: "class",
+	Help: "Manage printer classes",
+	Options: []argv.Option{
+		argv.HelpOption,
+	},
+	SubCommands: []argv.Command{
+		cmdClassAdd,
+		cmdClassMembers,
+		cmdClassRemove,
+		argv.HelpCommand,
+	},
+}
+
+// cmdClassAdd defines the "class add" sub-command.
+var cmdClassAdd = argv.Command{
+	Name:    "add",
+	Help:    "Add printers to class. Class is created, if missed",
+	Handler: cmdClassAddHandler,
+	Options: []argv.Option{
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:         "class",
+			Help:         "class name",
+			CompleteLive: completeClassName,
+		},
+		{
+			Name:         "printer",
+			Help:         "printer (queue) name",
+			CompleteLive: completePrinterName,
+		},
+		{
+			Name:         "[printer...]",
+			Help:         "more printers",
+			CompleteLive: completePrinterName,
+		},
+	},
+}
+
+// cmdClassRemove defines the "class remove" sub-command.
+var cmdClassRemove = argv.Command{
+	Name: "remove",
+	Help: "Remove printers from class.\n" +
+		"Without printers, or if no members left, class is deleted",
+	Handler: cmdClassRemoveHandler,
+	Options: []argv.Option{
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:         "class",
+			Help:         "class name",
+			CompleteLive: completeClassName,
+		},
+		{
+			Name:         "[printer...]",
+			Help:         "printers (queues) to remove",
+			CompleteLive: completePrinterName,
+		},
+	},
+}
+
+// cmdClassMembers defines the "class members" sub-command.
+var cmdClassMembers = argv.Command{
+	Name:    "members",
+	Help:    "List class members",
+	Handler: cmdClassMembersHandler,
+	Options: []argv.Option{
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:         "class",
+			Help:         "class name",
+			CompleteLive: completeClassName,
+		},
+	},
+}
+
+// cmdClassAddHandler is the "class add" command handler
+func cmdClassAddHandler(ctx context.Context, inv *argv.Invocation) error {
+	name := inv.ParamGet(0)
+	clnt := cups.NewClient(optCUPSURL(inv), nil)
+
+	class, err := classFind(ctx, clnt, name)
+	if err != nil {
+		return err
+	}
+
+	var members []string
+	if class != nil {
+		members = cups.ClassMembers(class)
+	}
+
+	for i := 1; i < inv.ParamCount(); i++ {
+		members = classMembersAdd(members, inv.ParamGet(i))
+	}
+
+	return clnt.SetClassMembers(ctx, name, members)
+}
+
+// cmdClassRemoveHandler is the "class remove" command handler
+func cmdClassRemoveHandler(ctx context.Context, inv *argv.Invocation) error {
+	name := inv.ParamGet(0)
+	clnt := cups.NewClient(optCUPSURL(inv), nil)
+
+	if inv.ParamCount() == 1 {
+		return clnt.CUPSDeleteClass(ctx, name)
+	}
+
+	class, err := classFind(ctx, clnt, name)
+	switch {
+	case err != nil:
+		return err
+	case class == nil:
+		return fmt.Errorf("%s: class not found", name)
+	}
+
+	members := cups.ClassMembers(class)
+	for i := 1; i < inv.ParamCount(); i++ {
+		member := inv.ParamGet(i)
+		members, err = classMembersRemove(members, member)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	// CUPS doesn't allow empty classes
+	if len(members) == 0 {
+		return clnt.CUPSDeleteClass(ctx, name)
+	}
+
+	return clnt.SetClassMembers(ctx, name, members)
+}
+
+// cmdClassMembersHandler is the "class members" command handler
+func cmdClassMembersHandler(ctx context.Context, inv *argv.Invocation) error {
+	name := inv.ParamGet(0)
+	clnt := cups.NewClient(optCUPSURL(inv), nil)
+
+	class, err := classFind(ctx, clnt, name)
+	switch {
+	case err != nil:
+		return err
+	case class == nil:
+		return fmt.Errorf("%s: class not found", name)
+	}
+
+	pager := env.NewPager()
+	for _, member := range cups.ClassMembers(class) {
+		pager.Printf("%s", member)
+	}
+
+	return pager.Display()
+}
+
+// classFind returns the class attributes by the class name.
+// If class not found, it returns (nil, nil).
+//
+// As in CUPS, class names are case-insensitive.
+func classFind(ctx context.Context, clnt *cups.Client, name string) (
+	*ipp.PrinterAttributes, error) {
+
+	classes, err := clnt.GetClasses(ctx, nil, cups.ClassAttrs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, class := range classes {
+		if strings.EqualFold(optional.Get(class.PrinterName), name) {
+			return class, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// classMembersAdd adds member to the list of class members,
+// if it is not there yet.
+func classMembersAdd(members []string, member string) []string {
+	for _, m := range members {
+		if strings.EqualFold(m, member) {
+			return members
+		}
+	}
+
+	return append(members, member)
+}
+
+// classMembersRemove removes member from the list of class members.
+func classMembersRemove(members []string, member string) (
+	[]string, error) {
+
+	for i, m := range members {
+		if strings.EqualFold(m, member) {
+			return slices.Delete(slices.Clone(members), i, i+1), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s: not a class member", member)
+}
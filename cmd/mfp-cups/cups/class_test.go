@@ -0,0 +1,48 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "class" command test
+
+package cups
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestClassMembersEdit tests classMembersAdd and classMembersRemove
+func TestClassMembersEdit(t *testing.T) {
+	members := []string{"Kyocera"}
+	members = classMembersAdd(members, "kyocera")
+	members = classMembersAdd(members, "Xerox")
+
+	if strings.Join(members, ",") != "Kyocera,Xerox" {
+		t.Errorf("classMembersAdd: unexpected %v", members)
+	}
+
+	removed, err := classMembersRemove(members, "KYOCERA")
+	if err != nil || strings.Join(removed, ",") != "Xerox" {
+		t.Errorf("classMembersRemove: unexpected %v, %v", removed, err)
+	}
+
+	if strings.Join(members, ",") != "Kyocera,Xerox" {
+		t.Errorf("classMembersRemove: input modified: %v", members)
+	}
+
+	_, err = classMembersRemove(members, "HP")
+	if err == nil {
+		t.Errorf("classMembersRemove: error expected for non-member")
+	}
+}
+
+// TestClassCommandVerify tests the "cups" command description,
+// with nested "class" sub-commands
+func TestClassCommandVerify(t *testing.T) {
+	err := Command.Verify()
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+}
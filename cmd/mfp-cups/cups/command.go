@@ -14,6 +14,7 @@ import (
 
 	"github.com/OpenPrinting/go-mfp/argv"
 	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/internal/env"
 	"github.com/OpenPrinting/go-mfp/log"
 	"github.com/OpenPrinting/go-mfp/transport"
 )
@@ -23,30 +24,33 @@ var Command = argv.Command{
 	Name: "cups",
 	Help: "CUPS client",
 	Options: []argv.Option{
-		argv.Option{
-			Name:    "-d",
-			Aliases: []string{"--debug"},
-			Help:    "Enable debug output",
-		},
-		argv.Option{
-			Name:    "-v",
-			Aliases: []string{"--verbose"},
-			Help:    "Enable verbose debug output",
-		},
+		env.OptDebug,
+		env.OptVerbose,
 		argv.Option{
 			Name:    "-u",
 			Aliases: []string{"--cups"},
 			Help: "CUPS server address or URL\n" +
 				fmt.Sprintf("default: %q", cups.DefaultUNIXURL),
-			Validate: transport.ValidateAddr,
+			Validate:   transport.ValidateAddr,
+			Persistent: true,
 		},
 		argv.HelpOption,
 	},
 	SubCommands: []argv.Command{
+		cmdAdd,
+		cmdClass,
+		cmdCompare,
+		cmdConformance,
+		cmdCounters,
 		cmdDefaultPrinter,
 		cmdDetectPrinters,
+		cmdDiagnose,
+		cmdGetDocument,
 		cmdGetPPD,
 		cmdListPrinters,
+		cmdModify,
+		cmdPrint,
+		cmdStatus,
 		argv.HelpCommand,
 	},
 	Handler: cmdCupsHandler,
@@ -55,20 +59,12 @@ var Command = argv.Command{
 // cmdCupsHandler is the top-level handler for the 'cups' command.
 func cmdCupsHandler(ctx context.Context, inv *argv.Invocation) error {
 	// Setup logging
-	_, dbg := inv.Get("-d")
-	_, vrb := inv.Get("-v")
-
-	level := log.LevelInfo
-	if dbg {
-		level = log.LevelDebug
-	}
-	if vrb {
-		level = log.LevelTrace
-	}
+	level := env.LogLevel(inv)
 
 	logger := log.NewLogger(level, log.Console)
 	ctx = log.NewContext(ctx, logger)
 
 	// Execute subcommand
-	return argv.DefaultHandler(ctx, inv)
+	err := argv.DefaultHandler(ctx, inv)
+	return env.ErrorHint(err)
 }
@@ -0,0 +1,234 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "compare" command.
+
+package cups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/internal/env"
+	"github.com/OpenPrinting/go-mfp/modeling/ippread"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/goipp"
+)
+
+// compareExitMissing is the exit status of the "compare" command,
+// when --fail-on-missing is set and printer-b misses some
+// capabilities of printer-a. Status 1 is reserved for the command
+// failure.
+const compareExitMissing = 2
+
+// cmdCompare defines the "compare" sub-command.
+var cmdCompare = argv.Command{
+	Name: "compare",
+	Help: "Compare capabilities of two printers",
+	Description: "" +
+		"Fetches attributes of both printers and reports capabilities\n" +
+		"(supported values, document formats, media, finishings,\n" +
+		"resolutions and so on), present on one side only.\n" +
+		"\n" +
+		"Printer may be specified either as the CUPS queue name or\n" +
+		"as the path to the MFP model file. Argument is considered\n" +
+		"a path, if it contains '/' or has the .py suffix.\n" +
+		"\n" +
+		"Exit status:\n" +
+		"  0 - comparison done\n" +
+		"  1 - comparison failed\n" +
+		"  2 - printer-b misses capabilities of printer-a\n" +
+		"      (only with --fail-on-missing)",
+	Handler: cmdCompareHandler,
+	Options: []argv.Option{
+		{
+			Name: "--fail-on-missing",
+			Help: "Fail, if printer-b misses capabilities of printer-a",
+		},
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:         "printer-a",
+			Help:         "printer (queue) name or model file",
+			CompleteLive: completePrinterName,
+		},
+		{
+			Name:         "printer-b",
+			Help:         "printer (queue) name or model file",
+			CompleteLive: completePrinterName,
+		},
+	},
+}
+
+// cmdCompareHandler is the "compare" command handler
+func cmdCompareHandler(ctx context.Context, inv *argv.Invocation) error {
+	dest := optCUPSURL(inv)
+	clnt := cups.NewClient(dest, nil)
+	clnt.SetDecoderOptions(&ipp.DecoderOptions{KeepTrying: true})
+
+	nameA, nameB := inv.ParamGet(0), inv.ParamGet(1)
+
+	prnA, err := compareLoad(ctx, clnt, nameA)
+	if err != nil {
+		return err
+	}
+
+	prnB, err := compareLoad(ctx, clnt, nameB)
+	if err != nil {
+		return err
+	}
+
+	diffs := ipp.DiffPrinterAttributes(prnA, prnB,
+		ipp.PrinterCapabilityAttribute)
+
+	pager := env.NewPager()
+	compareFormat(pager, nameA, nameB, diffs)
+
+	err = pager.Display()
+	if err != nil {
+		return err
+	}
+
+	if inv.Flag("--fail-on-missing") && compareMissing(diffs) != 0 {
+		return argv.ExitStatus(compareExitMissing)
+	}
+
+	return nil
+}
+
+// compareIsModel reports whether the printer argument of the
+// "compare" command refers to the model file.
+func compareIsModel(name string) bool {
+	return strings.ContainsRune(name, '/') || strings.HasSuffix(name, ".py")
+}
+
+// compareLoad loads attributes of the printer, specified either
+// as the CUPS queue name or as the model file.
+func compareLoad(ctx context.Context, clnt *cups.Client, name string) (
+	*ipp.PrinterAttributes, error) {
+
+	if compareIsModel(name) {
+		prn, err := ippread.Load(name)
+		if err == nil && prn == nil {
+			err = errors.New("model has no IPP printer attributes")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		return prn, nil
+	}
+
+	prn, err := clnt.GetPrinterAttributes(ctx, name, []string{"all"})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return prn, nil
+}
+
+// compareMissing returns count of capabilities, present at the
+// A side and missed at the B side.
+func compareMissing(diffs []ipp.AttributeDiff) int {
+	missing := 0
+	for _, diff := range diffs {
+		switch {
+		case diff.MissedA():
+		case diff.MissedB():
+			missing++
+		default:
+			missing += len(diff.OnlyA)
+		}
+	}
+
+	return missing
+}
+
+// compareFormat writes the side-by-side comparison report.
+//
+// Each value, present on one side only, occupies its own line,
+// in the column of the side where it is present. Attribute, missed
+// at one side, is marked as such.
+func compareFormat(w io.Writer, nameA, nameB string,
+	diffs []ipp.AttributeDiff) {
+
+	fmt.Fprintf(w, "A: %s\n", nameA)
+	fmt.Fprintf(w, "B: %s\n", nameB)
+	fmt.Fprintf(w, "\n")
+
+	if len(diffs) == 0 {
+		fmt.Fprintf(w, "Capabilities are equivalent\n")
+		return
+	}
+
+	// Prepare table rows
+	const missed = "(missing)"
+	type row struct{ name, a, b string }
+	rows := []row{{"ATTRIBUTE", "A", "B"}}
+
+	for _, diff := range diffs {
+		rowsBefore := len(rows)
+
+		for _, v := range diff.OnlyA {
+			rows = append(rows, row{
+				a: compareValue(diff.Name, v)})
+		}
+
+		for _, v := range diff.OnlyB {
+			rows = append(rows, row{
+				b: compareValue(diff.Name, v)})
+		}
+
+		if len(rows) == rowsBefore {
+			rows = append(rows, row{})
+		}
+
+		first := &rows[rowsBefore]
+		first.name = diff.Name
+
+		switch {
+		case diff.MissedA():
+			first.a = missed
+		case diff.MissedB():
+			first.b = missed
+		}
+	}
+
+	// Compute column widths
+	wName, wA := 0, 0
+	for _, r := range rows {
+		wName = max(wName, len(r.name))
+		wA = max(wA, len(r.a))
+	}
+
+	// Format the table
+	for _, r := range rows {
+		line := fmt.Sprintf("%-*s  %-*s  %s", wName, r.name, wA, r.a, r.b)
+		fmt.Fprintf(w, "%s\n", strings.TrimRight(line, " "))
+	}
+
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "%d attributes differ, %d capabilities of A missed in B\n",
+		len(diffs), compareMissing(diffs))
+}
+
+// compareValue formats the attribute value for the report.
+func compareValue(name string, v goipp.TaggedValue) string {
+	if name == "operations-supported" && v.T == goipp.TagEnum {
+		if i, ok := v.V.(goipp.Integer); ok {
+			return goipp.Op(i).String()
+		}
+	}
+
+	return v.V.String()
+}
@@ -0,0 +1,124 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "compare" command test
+
+package cups
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+)
+
+// testCompareUpdate, if set, causes TestCompareReport to rewrite
+// golden files
+var testCompareUpdate = flag.Bool("update-golden", false,
+	"update golden files of the compare test")
+
+// testCompareDir is the directory with the compare test fixtures
+const testCompareDir = "testdata/compare"
+
+// TestCompareReport tests the "compare" command report against
+// the golden files
+func TestCompareReport(t *testing.T) {
+	type testData struct {
+		a, b    string // Fixture model files
+		golden  string // Golden report file
+		missing int    // Expected count of missing capabilities
+	}
+
+	tests := []testData{
+		{
+			a:       "printer-a.py",
+			b:       "printer-b.py",
+			golden:  "report-a-b.txt",
+			missing: 6,
+		},
+		{
+			a:       "printer-b.py",
+			b:       "printer-a.py",
+			golden:  "report-b-a.txt",
+			missing: 6,
+		},
+		{
+			a:       "printer-a.py",
+			b:       "printer-a.py",
+			golden:  "report-a-a.txt",
+			missing: 0,
+		},
+	}
+
+	for _, test := range tests {
+		nameA := filepath.Join(testCompareDir, test.a)
+		nameB := filepath.Join(testCompareDir, test.b)
+
+		prnA, err := compareLoad(context.Background(), nil, nameA)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		prnB, err := compareLoad(context.Background(), nil, nameB)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		diffs := ipp.DiffPrinterAttributes(prnA, prnB,
+			ipp.PrinterCapabilityAttribute)
+
+		if missing := compareMissing(diffs); missing != test.missing {
+			t.Errorf("%s vs %s: missing capabilities: "+
+				"expected %d, present %d",
+				test.a, test.b, test.missing, missing)
+		}
+
+		buf := &bytes.Buffer{}
+		compareFormat(buf, test.a, test.b, diffs)
+
+		file := filepath.Join(testCompareDir, test.golden)
+		if *testCompareUpdate {
+			err = os.WriteFile(file, buf.Bytes(), 0644)
+			if err != nil {
+				t.Errorf("%s", err)
+			}
+			continue
+		}
+
+		expected, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Errorf("%s: output mismatch\nexpected:\n%s\npresent:\n%s",
+				test.golden, expected, buf.Bytes())
+		}
+	}
+}
+
+// TestCompareIsModel tests compareIsModel
+func TestCompareIsModel(t *testing.T) {
+	tests := map[string]bool{
+		"Office":             false,
+		"Office.py":          true,
+		"./Office":           true,
+		"models/Kyocera.py":  true,
+		"Kyocera_M2040dn_py": false,
+	}
+
+	for name, expected := range tests {
+		if present := compareIsModel(name); present != expected {
+			t.Errorf("compareIsModel(%q): expected %v, present %v",
+				name, expected, present)
+		}
+	}
+}
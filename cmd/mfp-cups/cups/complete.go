@@ -0,0 +1,85 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Live completion of printer names and job IDs
+
+package cups
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// completeCUPSURL is the CUPS URL, used by the live completers.
+//
+// Completers don't see other options, so the -u option is not
+// taken into account here.
+var completeCUPSURL = cups.DefaultUNIXURL
+
+// completePrinterName is the argv.LiveCompleter for the printer
+// (queue) names.
+func completePrinterName(ctx context.Context, prefix string) []string {
+	clnt := cups.NewClient(completeCUPSURL, nil)
+
+	printers, err := clnt.CUPSGetPrinters(ctx, nil,
+		[]string{"printer-name"})
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(printers))
+	for _, prn := range printers {
+		if name := optional.Get(prn.PrinterName); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// completeClassName is the argv.LiveCompleter for the printer
+// class names.
+func completeClassName(ctx context.Context, prefix string) []string {
+	clnt := cups.NewClient(completeCUPSURL, nil)
+
+	classes, err := clnt.GetClasses(ctx, nil, []string{"printer-name"})
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(classes))
+	for _, class := range classes {
+		if name := optional.Get(class.PrinterName); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// completeJobID is the argv.LiveCompleter for the job IDs.
+func completeJobID(ctx context.Context, prefix string) []string {
+	clnt := cups.NewClient(completeCUPSURL, nil)
+
+	jobs, err := clnt.GetJobs(ctx, "", ipp.KwWhichJobsAll,
+		[]ipp.KwRequestedAttribute{ipp.KwRequestedAttributeJobID})
+	if err != nil {
+		return nil
+	}
+
+	ids := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		if job.JobID > 0 {
+			ids = append(ids, strconv.Itoa(job.JobID))
+		}
+	}
+
+	return ids
+}
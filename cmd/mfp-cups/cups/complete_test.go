@@ -0,0 +1,125 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Live completion test
+
+package cups
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestCompleteLive tests live completion of printer names and job IDs
+func TestCompleteLive(t *testing.T) {
+	var slow atomic.Bool
+	release := make(chan struct{})
+
+	mux := ipp.NewServeMux(ipp.ServerOptions{})
+	mux.Handle(goipp.OpCupsGetPrinters,
+		func(ctx context.Context, msg *goipp.Message,
+			body io.Reader) (*goipp.Message, io.Reader, error) {
+
+			if slow.Load() {
+				<-release
+			}
+
+			rsp := &ipp.CUPSGetPrintersResponse{
+				ResponseHeader: ipp.ResponseHeader{
+					Version:   msg.Version,
+					RequestID: msg.RequestID,
+					Status:    goipp.StatusOk,
+				},
+			}
+
+			for _, name := range []string{"Kyocera", "Test-1", "Test-2"} {
+				prn := &ipp.PrinterAttributes{}
+				prn.PrinterName = optional.New(name)
+				rsp.Printer = append(rsp.Printer, prn)
+			}
+
+			return rsp.Encode(), nil, nil
+		})
+
+	mux.Handle(goipp.OpGetJobs,
+		func(ctx context.Context, msg *goipp.Message,
+			body io.Reader) (*goipp.Message, io.Reader, error) {
+
+			rsp := &ipp.GetJobsResponse{
+				ResponseHeader: ipp.ResponseHeader{
+					Version:   msg.Version,
+					RequestID: msg.RequestID,
+					Status:    goipp.StatusOk,
+				},
+			}
+
+			for _, id := range []int{7, 12, 15} {
+				var job ipp.JobGroupEntry
+				job.JobID = id
+				rsp.Jobs = append(rsp.Jobs, job)
+			}
+
+			return rsp.Encode(), nil, nil
+		})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	saveURL := completeCUPSURL
+	defer func() { completeCUPSURL = saveURL }()
+	completeCUPSURL = transport.MustParseURL(srv.URL)
+
+	ctx := context.Background()
+
+	// Printer names
+	out := cmdGetDocument.CompleteContext(ctx, []string{"Te"})
+	expected := []argv.Completion{{String: "Test-", NoSpace: true}}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("printer: expected %#v, present %#v", expected, out)
+	}
+
+	// Job IDs
+	out = cmdGetDocument.CompleteContext(ctx, []string{"Test-1", "1"})
+	expected = []argv.Completion{
+		{String: "12"}, {String: "15"}}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("job-id: expected %#v, present %#v", expected, out)
+	}
+
+	// Server too slow
+	slow.Store(true)
+	start := time.Now()
+	out = cmdGetDocument.CompleteContext(ctx, []string{"Te"})
+	elapsed := time.Since(start)
+
+	if len(out) != 0 {
+		t.Errorf("timeout: unexpected output %#v", out)
+	}
+
+	if elapsed > 2*argv.LiveCompleterTimeout {
+		t.Errorf("timeout: completion took %s", elapsed)
+	}
+
+	// Server not available
+	close(release)
+	srv.Close()
+
+	out = cmdGetDocument.CompleteContext(ctx, []string{"Te"})
+	if len(out) != 0 {
+		t.Errorf("no server: unexpected output %#v", out)
+	}
+}
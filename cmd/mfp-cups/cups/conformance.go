@@ -0,0 +1,122 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "conformance" command.
+
+package cups
+
+import (
+	"context"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/cups/conformance"
+	"github.com/OpenPrinting/go-mfp/internal/env"
+	"github.com/OpenPrinting/go-mfp/log/trace"
+)
+
+// conformanceExitStatus is the exit status of the "conformance"
+// command, if some tests failed. Status 1 is reserved for the
+// command failure.
+const conformanceExitStatus = 2
+
+// cmdConformance defines the "conformance" sub-command.
+var cmdConformance = argv.Command{
+	Name: "conformance",
+	Help: "Run IPP conformance self-test against the printer",
+	Description: "" +
+		"Runs the battery of IPP conformance tests directly against\n" +
+		"the printer and reports per-test results and the score.\n" +
+		"\n" +
+		"Note, the test page is submitted with the Print-Job and\n" +
+		"immediately canceled, but fast printer still may print it.\n" +
+		"\n" +
+		"Exit status:\n" +
+		"  0 - all tests passed or skipped\n" +
+		"  1 - tests cannot be executed\n" +
+		"  2 - some tests failed",
+	Handler: cmdConformanceHandler,
+	Options: []argv.Option{
+		argv.Option{
+			Name: "--tls",
+			Help: "perform the optional TLS checks",
+		},
+		argv.Option{
+			Name:      "-t",
+			Aliases:   []string{"--trace"},
+			Help:      "write trace to file.log and file.tar",
+			HelpArg:   "file",
+			Singleton: true,
+			Validate:  argv.ValidateAny,
+			Complete:  argv.CompleteOSPath,
+		},
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name: "printer-uri",
+			Help: "printer URI (ipp://host/path or ipps://host/path)",
+		},
+	},
+}
+
+// cmdConformanceHandler is the "conformance" command handler
+func cmdConformanceHandler(ctx context.Context, inv *argv.Invocation) error {
+	// Setup tracer
+	if traceName, _ := inv.Get("-t"); traceName != "" {
+		tracer, err := trace.NewWriter(ctx, traceName)
+		if err != nil {
+			return err
+		}
+
+		defer tracer.Close()
+		ctx = trace.NewContext(ctx, tracer)
+	}
+
+	// Run tests
+	opts := conformance.Options{
+		TLS: inv.Flag("--tls"),
+	}
+
+	r, err := conformance.Run(ctx, inv.ParamGet(0), opts)
+	if err != nil {
+		return err
+	}
+
+	// Print the report
+	pager := env.NewPager()
+
+	pass, fail, skip := r.Counts()
+
+	pager.Printf("Printer:        %s", r.PrinterURI)
+	if r.MakeModel != "" {
+		pager.Printf("Make and model: %s", r.MakeModel)
+	}
+	pager.Printf("IPP version:    %s", r.Version)
+	pager.Printf("Score:          %d%% (%d passed, %d failed, %d skipped)",
+		r.Score(), pass, fail, skip)
+	pager.Printf("")
+
+	for _, test := range r.Tests {
+		pager.Printf("%s: %s: %s", test.Result, test.Name, test.Title)
+		if test.Message != "" {
+			pager.Printf("  %s", test.Message)
+		}
+		for _, file := range test.Evidence {
+			pager.Printf("  evidence: %s", file)
+		}
+	}
+
+	err = pager.Display()
+	if err != nil {
+		return err
+	}
+
+	if !r.Passed() {
+		return argv.ExitStatus(conformanceExitStatus)
+	}
+
+	return nil
+}
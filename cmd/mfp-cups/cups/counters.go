@@ -0,0 +1,120 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "counters" command.
+
+package cups
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/internal/env"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// cmdCounters defines the "counters" sub-command.
+var cmdCounters = argv.Command{
+	Name:    "counters",
+	Help:    "Get printer usage counters and quotas",
+	Handler: cmdCountersHandler,
+	Options: []argv.Option{
+		{
+			Name: "--csv",
+			Help: "CSV output, for periodic collection",
+		},
+		{
+			Name:     "--no-header",
+			Help:     "Omit CSV header, for appending to existing file",
+			Requires: []string{"--csv"},
+		},
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:         "printer",
+			Help:         "printer (queue) name",
+			CompleteLive: completePrinterName,
+		},
+		{
+			Name:         "[printer...]",
+			Help:         "more printers",
+			CompleteLive: completePrinterName,
+		},
+	},
+}
+
+// cmdCountersHandler is the "counters" command handler
+func cmdCountersHandler(ctx context.Context, inv *argv.Invocation) error {
+	// Perform the queries
+	dest := optCUPSURL(inv)
+	clnt := cups.NewClient(dest, nil)
+
+	counters := make([]cups.Counters, 0, inv.ParamCount())
+	for i := 0; i < inv.ParamCount(); i++ {
+		cnt, err := clnt.GetCounters(ctx, inv.ParamGet(i))
+		if err != nil {
+			return fmt.Errorf("%s: %w", inv.ParamGet(i), err)
+		}
+
+		counters = append(counters, cnt)
+	}
+
+	// Format CSV output. It is intended for scripts, so the
+	// pager is not used here.
+	if inv.Flag("--csv") {
+		w := csv.NewWriter(os.Stdout)
+		if !inv.Flag("--no-header") {
+			w.Write(cups.CountersCSVHeader())
+		}
+
+		for _, cnt := range counters {
+			w.Write(cnt.CSVRecord())
+		}
+
+		w.Flush()
+		return w.Error()
+	}
+
+	// Format human-readable output
+	pager := env.NewPager()
+
+	pager.Printf("CUPS: %s", dest)
+	for _, cnt := range counters {
+		pager.Printf("")
+		pager.Printf("%s:", cnt.Printer)
+		countersFormatInt(pager, "Impressions completed",
+			cnt.ImpressionsCompleted)
+		countersFormatInt(pager, "Pages completed",
+			cnt.PagesCompleted)
+		countersFormatInt(pager, "Up time (seconds)", cnt.UpTime)
+
+		if cnt.JobKOctetsSupported != nil {
+			rng := *cnt.JobKOctetsSupported
+			pager.Printf("  %-24s %d-%d", "Job size (KB):",
+				rng.Lower, rng.Upper)
+		}
+
+		countersFormatInt(pager, "Quota period (seconds)",
+			cnt.JobQuotaPeriod)
+		countersFormatInt(pager, "Quota limit (KB)", cnt.JobKLimit)
+		countersFormatInt(pager, "Quota limit (pages)",
+			cnt.JobPageLimit)
+	}
+
+	return pager.Display()
+}
+
+// countersFormatInt formats the optional counter value, if present.
+func countersFormatInt(pager *env.Pager, name string, val optional.Val[int]) {
+	if val != nil {
+		pager.Printf("  %-24s %d", name+":", *val)
+	}
+}
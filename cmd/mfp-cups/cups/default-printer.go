@@ -10,11 +10,16 @@ package cups
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/OpenPrinting/go-mfp/argv"
 	"github.com/OpenPrinting/go-mfp/cups"
 	"github.com/OpenPrinting/go-mfp/internal/env"
 	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
 )
 
 // cmdDefaultPrinter defines the "default-printer" sub-command
@@ -24,6 +29,10 @@ var cmdDefaultPrinter = argv.Command{
 	Handler: cmdDefaultPrinterHandler,
 	Options: []argv.Option{
 		optAttrs,
+		{
+			Name: "--json",
+			Help: "JSON output of the key printer attributes",
+		},
 		argv.HelpOption,
 	},
 }
@@ -34,6 +43,7 @@ func cmdDefaultPrinterHandler(ctx context.Context, inv *argv.Invocation) error {
 
 	attrList := optAttrsGet(inv)
 	attrList = append(attrList, prnAttrsRequested...)
+	attrList = append(attrList, defaultPrinterJSONAttrs...)
 
 	// Perform the query
 	clnt := cups.NewClient(dest, nil)
@@ -44,11 +54,85 @@ func cmdDefaultPrinterHandler(ctx context.Context, inv *argv.Invocation) error {
 		return err
 	}
 
-	// Format output
+	// Format output. JSON output is intended for scripts, so
+	// the pager is not used here.
+	if inv.Flag("--json") {
+		return defaultPrinterJSON(os.Stdout, prn)
+	}
+
 	pager := env.NewPager()
 
 	pager.Printf("CUPS: %s", dest)
-	prnAttrsFormat(pager, prn)
+	prnAttrsFormat(pager, prn, nil)
 
 	return pager.Display()
 }
+
+// defaultPrinterJSONAttrs lists additional attributes, requested
+// for the JSON output.
+var defaultPrinterJSONAttrs = []string{
+	"printer-info",
+	"printer-is-accepting-jobs",
+	"printer-location",
+	"printer-make-and-model",
+	"printer-state",
+}
+
+// defaultPrinterInfo contains key attributes of the default printer
+// for the JSON output.
+type defaultPrinterInfo struct {
+	Name           string `json:"name"`
+	Instance       string `json:"instance,omitempty"`
+	URI            string `json:"uri"`
+	Class          bool   `json:"class"`
+	DeviceURI      string `json:"device-uri,omitempty"`
+	Info           string `json:"info,omitempty"`
+	Location       string `json:"location,omitempty"`
+	MakeAndModel   string `json:"make-and-model,omitempty"`
+	State          int    `json:"state,omitempty"`
+	AcceptingJobs  *bool  `json:"accepting-jobs,omitempty"`
+	Shared         *bool  `json:"shared,omitempty"`
+	PrinterType    *int   `json:"printer-type,omitempty"`
+	PrinterTypeStr string `json:"printer-type-decoded,omitempty"`
+}
+
+// defaultPrinterJSON writes key attributes of the default printer
+// as JSON.
+//
+// The printer URI is resolved from the printer name and type, so
+// classes get the "/classes/" URI and instance suffix is reported
+// separately.
+func defaultPrinterJSON(w io.Writer, prn *ipp.PrinterAttributes) error {
+	ptype := optional.Get(prn.PrinterType)
+	name := optional.Get(prn.PrinterName)
+
+	u, instance, err := cups.ResolveQueueURI(cups.DefaultLocalhostURL,
+		name, ptype)
+	if err != nil {
+		return err
+	}
+
+	info := defaultPrinterInfo{
+		Name:          strings.TrimSuffix(name, "/"+instance),
+		Instance:      instance,
+		URI:           u.String(),
+		Class:         ptype&ipp.EnPrinterClass != 0,
+		DeviceURI:     prn.DeviceURI,
+		Info:          optional.Get(prn.PrinterInfo),
+		Location:      optional.Get(prn.PrinterLocation),
+		MakeAndModel:  optional.Get(prn.PrinterMakeAndModel),
+		State:         optional.Get(prn.PrinterState),
+		AcceptingJobs: prn.PrinterIsAcceptingJobs,
+		Shared:        prn.PrinterIsShared,
+	}
+
+	if prn.PrinterType != nil {
+		t := int(ptype)
+		info.PrinterType = &t
+		info.PrinterTypeStr = ptype.String()
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(info)
+}
@@ -0,0 +1,100 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "default-printer" command test
+
+package cups
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// TestDefaultPrinterJSON tests JSON output of the "default-printer"
+// command
+func TestDefaultPrinterJSON(t *testing.T) {
+	type testData struct {
+		prn      *ipp.PrinterAttributes
+		expected string
+	}
+
+	tests := []testData{
+		{
+			prn: &ipp.PrinterAttributes{
+				PrinterDescription: ipp.PrinterDescription{
+					PrinterName: optional.New("Test Queue"),
+					PrinterMakeAndModel: optional.New(
+						"Kyocera ECOSYS M2040dn"),
+					PrinterState:           optional.New(3),
+					PrinterIsAcceptingJobs: optional.New(true),
+				},
+			},
+			expected: `{
+  "name": "Test Queue",
+  "uri": "ipp://localhost/printers/Test%20Queue",
+  "class": false,
+  "make-and-model": "Kyocera ECOSYS M2040dn",
+  "state": 3,
+  "accepting-jobs": true
+}
+`,
+		},
+
+		{
+			prn: &ipp.PrinterAttributes{
+				PrinterDescription: ipp.PrinterDescription{
+					PrinterName: optional.New("office/duplex"),
+				},
+			},
+			expected: `{
+  "name": "office",
+  "instance": "duplex",
+  "uri": "ipp://localhost/printers/office",
+  "class": false
+}
+`,
+		},
+	}
+
+	// Class. CUPS-specific attributes are set separately.
+	class := &ipp.PrinterAttributes{
+		PrinterDescription: ipp.PrinterDescription{
+			PrinterName: optional.New("office"),
+		},
+	}
+	class.PrinterType = optional.New(ipp.EnPrinterClass)
+	class.PrinterIsShared = optional.New(false)
+
+	tests = append(tests, testData{
+		prn: class,
+		expected: `{
+  "name": "office",
+  "uri": "ipp://localhost/classes/office",
+  "class": true,
+  "shared": false,
+  "printer-type": 1,
+  "printer-type-decoded": "` + ipp.EnPrinterClass.String() + `"
+}
+`,
+	})
+
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		err := defaultPrinterJSON(buf, test.prn)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+
+		if buf.String() != test.expected {
+			t.Errorf("JSON mismatch:\nexpected:\n%s\npresent:\n%s",
+				test.expected, buf)
+		}
+	}
+}
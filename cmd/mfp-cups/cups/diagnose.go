@@ -0,0 +1,128 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "diagnose" command.
+
+package cups
+
+import (
+	"context"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/internal/env"
+)
+
+// Exit status of the "diagnose" command, by the report severity.
+// Status 1 is reserved for the command failure.
+var diagnoseExitStatus = map[cups.Severity]int{
+	cups.SeverityOK:      0,
+	cups.SeverityInfo:    0,
+	cups.SeverityWarning: 2,
+	cups.SeverityError:   3,
+}
+
+// cmdDiagnose defines the "diagnose" sub-command.
+var cmdDiagnose = argv.Command{
+	Name: "diagnose",
+	Help: "Diagnose the printer queue problems",
+	Description: "" +
+		"Fetches the queue state from CUPS, probes the device\n" +
+		"directly and reports problems with suggested remediations.\n" +
+		"\n" +
+		"Exit status:\n" +
+		"  0 - no problems found\n" +
+		"  1 - diagnostics failed\n" +
+		"  2 - warnings found\n" +
+		"  3 - errors found",
+	Handler: cmdDiagnoseHandler,
+	Options: []argv.Option{
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:         "printer",
+			Help:         "printer (queue) name",
+			CompleteLive: completePrinterName,
+		},
+	},
+}
+
+// cmdDiagnoseHandler is the "diagnose" command handler
+func cmdDiagnoseHandler(ctx context.Context, inv *argv.Invocation) error {
+	dest := optCUPSURL(inv)
+	clnt := cups.NewClient(dest, nil)
+
+	r, err := cups.Diagnose(ctx, clnt, inv.ParamGet(0))
+	if err != nil {
+		return err
+	}
+
+	pager := env.NewPager()
+
+	pager.Printf("CUPS: %s", dest)
+	pager.Printf("")
+	pager.Printf("%s:", r.Printer)
+	pager.Printf("  Device URI:     %s", r.DeviceURI)
+
+	pager.Printf("  Queue state:    %s", cups.StateString(r.QueueState))
+	if r.QueueStateMessage != "" {
+		pager.Printf("  Queue message:  %s", r.QueueStateMessage)
+	}
+	if len(r.QueueStateReasons) != 0 {
+		pager.Printf("  Queue reasons:  %s", r.QueueStateReasons)
+	}
+
+	switch {
+	case r.ProbeErr != nil:
+		pager.Printf("  Device:         unreachable")
+
+	case r.Probe.Reachable:
+		pager.Printf("  Device:         reachable, latency %s",
+			r.Probe.Latency)
+		if r.Probe.MakeModel != "" {
+			pager.Printf("  Make and model: %s", r.Probe.MakeModel)
+		}
+		if r.DeviceState != 0 {
+			pager.Printf("  Device state:   %s",
+				cups.StateString(r.DeviceState))
+		}
+		if r.DeviceStateMessage != "" {
+			pager.Printf("  Device message: %s",
+				r.DeviceStateMessage)
+		}
+		if len(r.DeviceStateReasons) != 0 {
+			pager.Printf("  Device reasons: %s",
+				r.DeviceStateReasons)
+		}
+		if r.TLS.Encrypted || r.TLSErr != nil {
+			tlsInfoFormat(pager, r.TLS, r.TLSErr)
+		}
+	}
+
+	pager.Printf("")
+	if len(r.Findings) == 0 {
+		pager.Printf("No problems found")
+	}
+
+	for _, f := range r.Findings {
+		pager.Printf("%s: %s: %s", f.Severity, f.Code, f.Message)
+		if f.Remediation != "" {
+			pager.Printf("  suggestion: %s", f.Remediation)
+		}
+	}
+
+	err = pager.Display()
+	if err != nil {
+		return err
+	}
+
+	if status := diagnoseExitStatus[r.Severity()]; status != 0 {
+		return argv.ExitStatus(status)
+	}
+
+	return nil
+}
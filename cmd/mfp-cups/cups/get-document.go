@@ -0,0 +1,134 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "get-document" command.
+
+package cups
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/cups"
+)
+
+// cmdGetDocument defines the "get-document" sub-command.
+var cmdGetDocument = argv.Command{
+	Name: "get-document",
+	Help: "Download the spooled job document",
+	Description: "" +
+		"Retrieves the document, the application has actually sent\n" +
+		"to the print queue, using the CUPS-Get-Document request.\n" +
+		"\n" +
+		"CUPS keeps documents of the completed jobs only if\n" +
+		"PreserveJobFiles is enabled in the cupsd.conf.",
+	Handler: cmdGetDocumentHandler,
+	Options: []argv.Option{
+		optDocumentNumber,
+		optDocumentOutput,
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:         "printer",
+			Help:         "printer (queue) name",
+			CompleteLive: completePrinterName,
+		},
+		{
+			Name:         "job-id",
+			Help:         "job ID",
+			Validate:     argv.ValidateIntRange(0, 1, math.MaxInt32),
+			CompleteLive: completeJobID,
+		},
+	},
+}
+
+// optDocumentNumber describes the -n option.
+// It specifies the document number within the job.
+var optDocumentNumber = argv.Option{
+	Name:      "-n",
+	Aliases:   []string{"--document"},
+	Help:      "Document number within the job (default: 1)",
+	HelpArg:   "N",
+	Singleton: true,
+	Validate:  argv.ValidateIntRange(0, 1, math.MaxInt32),
+}
+
+// optDocumentOutput describes the -o option.
+// It specifies the output file.
+var optDocumentOutput = argv.Option{
+	Name:      "-o",
+	Aliases:   []string{"--output"},
+	Help:      "write document to file (use - for stdout)",
+	HelpArg:   "file",
+	Required:  true,
+	Singleton: true,
+	Validate:  argv.ValidateAny,
+	Complete:  argv.CompleteOSPath,
+}
+
+// cmdGetDocumentHandler is the "get-document" command handler
+func cmdGetDocumentHandler(ctx context.Context, inv *argv.Invocation) error {
+	// Parse parameters
+	printer := inv.ParamGet(0)
+	jobID, _ := strconv.Atoi(inv.ParamGet(1))
+
+	docnum := 1
+	if opt, ok := inv.Get("-n"); ok {
+		docnum, _ = strconv.Atoi(opt)
+	}
+
+	file, _ := inv.Get("-o")
+
+	// Perform the query
+	dest := optCUPSURL(inv)
+	clnt := cups.NewClient(dest, nil)
+
+	doc, err := clnt.GetDocument(ctx, printer, jobID, docnum)
+	if err != nil {
+		return err
+	}
+
+	defer doc.Body.Close()
+
+	// Save the document
+	if file == "-" {
+		_, err = io.Copy(os.Stdout, doc.Body)
+		return err
+	}
+
+	fp, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fp, doc.Body)
+	err2 := fp.Close()
+	if err == nil {
+		err = err2
+	}
+
+	if err != nil {
+		os.Remove(file)
+		return err
+	}
+
+	fmt.Printf("Document %d of job %d", doc.Number, jobID)
+	if doc.Name != "" {
+		fmt.Printf(" (%q)", doc.Name)
+	}
+	if doc.Format != "" {
+		fmt.Printf(", %s", doc.Format)
+	}
+	fmt.Printf(" saved to %s\n", file)
+
+	return nil
+}
@@ -0,0 +1,158 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Bulk operations over queues, matched by glob pattern
+
+package cups
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// globYesOption is the option that skips confirmation of the
+// bulk operations.
+var globYesOption = argv.Option{
+	Name:    "-y",
+	Aliases: []string{"--yes"},
+	Help:    "Don't ask for confirmation of bulk operations",
+}
+
+// globBulk performs operations over queues, named by the
+// glob pattern.
+type globBulk struct {
+	clnt *cups.Client // CUPS client
+	yes  bool         // Don't ask for confirmation
+	in   io.Reader    // Confirmation input
+	out  io.Writer    // Confirmation prompt and results output
+}
+
+// newGlobBulk creates a new globBulk for the command invocation.
+func newGlobBulk(inv *argv.Invocation, clnt *cups.Client) *globBulk {
+	_, yes := inv.Get("-y")
+	return &globBulk{
+		clnt: clnt,
+		yes:  yes,
+		in:   os.Stdin,
+		out:  os.Stdout,
+	}
+}
+
+// Do performs the operation on queues, named by the name
+// or by the glob pattern.
+//
+// If name is not a glob pattern (see globIsPattern), the operation
+// is performed on this queue directly. Otherwise, the pattern is
+// expanded against the queue names, known to CUPS, user is asked
+// for confirmation (unless -y is given) and operation is performed
+// on each matched queue. Failures are reported per queue, and the
+// operation continues with the remaining queues.
+func (bulk *globBulk) Do(ctx context.Context, name string,
+	op func(ctx context.Context, name string) error) error {
+
+	if !globIsPattern(name) {
+		return op(ctx, name)
+	}
+
+	// Expand the pattern
+	printers, err := bulk.clnt.CUPSGetPrinters(ctx, nil,
+		[]string{"printer-name"})
+	if err != nil {
+		return err
+	}
+
+	queues := make([]string, 0, len(printers))
+	for _, prn := range printers {
+		if q := optional.Get(prn.PrinterName); q != "" {
+			queues = append(queues, q)
+		}
+	}
+
+	matched, err := globExpand(name, queues)
+	if err != nil {
+		return err
+	}
+
+	if len(matched) == 0 {
+		return fmt.Errorf("%q: no matching queues", name)
+	}
+
+	// Ask for confirmation
+	if !bulk.yes && !bulk.confirm(matched) {
+		return fmt.Errorf("%q: canceled", name)
+	}
+
+	// Do the job
+	failed := 0
+	for _, q := range matched {
+		err := op(ctx, q)
+		if err != nil {
+			fmt.Fprintf(bulk.out, "%s: %s\n", q, err)
+			failed++
+		} else {
+			fmt.Fprintf(bulk.out, "%s: OK\n", q)
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d of %d queues failed", failed, len(matched))
+	}
+
+	return nil
+}
+
+// confirm lists the matched queues and asks user for confirmation.
+func (bulk *globBulk) confirm(queues []string) bool {
+	fmt.Fprintf(bulk.out, "Matched queues:\n")
+	for _, q := range queues {
+		fmt.Fprintf(bulk.out, "  %s\n", q)
+	}
+
+	fmt.Fprintf(bulk.out, "Proceed with %d queues? [y/N] ", len(queues))
+
+	answer, _ := bufio.NewReader(bulk.in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+// globIsPattern reports whether the queue name is the glob pattern.
+func globIsPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// globExpand returns queue names, matching the pattern, sorted.
+//
+// Patterns are matched against the server-side queue names
+// (printer-name), not against the display names (printer-info).
+func globExpand(pattern string, queues []string) ([]string, error) {
+	// Validate pattern upfront, as path.Match may miss
+	// syntax errors when name doesn't match.
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", pattern, err)
+	}
+
+	var matched []string
+	for _, q := range queues {
+		if ok, _ := path.Match(pattern, q); ok {
+			matched = append(matched, q)
+		}
+	}
+
+	sort.Strings(matched)
+	return matched, nil
+}
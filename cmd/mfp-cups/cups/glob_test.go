@@ -0,0 +1,203 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Bulk operations over queues test
+
+package cups
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// globTestQueues is the fixture queue list
+var globTestQueues = []string{
+	"lab-1", "lab-2", "lab-10", "lab", "office", "office-duplex",
+	"Lab-3",
+}
+
+// TestGlobExpand tests globExpand
+func TestGlobExpand(t *testing.T) {
+	type testData struct {
+		pattern  string
+		expected []string
+		err      string
+	}
+
+	tests := []testData{
+		{pattern: "lab-*", expected: []string{"lab-1", "lab-10", "lab-2"}},
+		{pattern: "lab-?", expected: []string{"lab-1", "lab-2"}},
+		{pattern: "[lL]ab-[13]", expected: []string{"Lab-3", "lab-1"}},
+		{pattern: "office*", expected: []string{"office", "office-duplex"}},
+		{pattern: "printer-*", expected: nil},
+		{pattern: "lab-[", err: `"lab-[": syntax error in pattern`},
+	}
+
+	for _, test := range tests {
+		matched, err := globExpand(test.pattern, globTestQueues)
+
+		errstr := ""
+		if err != nil {
+			errstr = err.Error()
+		}
+
+		if errstr != test.err {
+			t.Errorf("%q: error expected %q, present %q",
+				test.pattern, test.err, errstr)
+			continue
+		}
+
+		if !reflect.DeepEqual(matched, test.expected) {
+			t.Errorf("%q: expected %q, present %q",
+				test.pattern, test.expected, matched)
+		}
+	}
+
+	for _, s := range []string{"lab-*", "lab-?", "lab-[12]"} {
+		if !globIsPattern(s) {
+			t.Errorf("%q: must be pattern", s)
+		}
+	}
+
+	if globIsPattern("lab-1") {
+		t.Errorf("%q: must not be pattern", "lab-1")
+	}
+}
+
+// TestGlobBulk tests bulk operation against the fake CUPS server,
+// where one of queues fails.
+func TestGlobBulk(t *testing.T) {
+	var lock sync.Mutex
+	var modified []string
+
+	mux := ipp.NewServeMux(ipp.ServerOptions{})
+	mux.Handle(goipp.OpCupsGetPrinters,
+		func(ctx context.Context, msg *goipp.Message,
+			body io.Reader) (*goipp.Message, io.Reader, error) {
+
+			rsp := &ipp.CUPSGetPrintersResponse{
+				ResponseHeader: ipp.ResponseHeader{
+					Version:   msg.Version,
+					RequestID: msg.RequestID,
+					Status:    goipp.StatusOk,
+				},
+			}
+
+			for _, name := range globTestQueues {
+				prn := &ipp.PrinterAttributes{}
+				prn.PrinterName = optional.New(name)
+				prn.PrinterInfo = optional.New("lab-display-name")
+				rsp.Printer = append(rsp.Printer, prn)
+			}
+
+			return rsp.Encode(), nil, nil
+		})
+
+	mux.Handle(goipp.OpCupsAddModifyPrinter,
+		func(ctx context.Context, msg *goipp.Message,
+			body io.Reader) (*goipp.Message, io.Reader, error) {
+
+			var uri string
+			for _, attr := range msg.Operation {
+				if attr.Name == "printer-uri" {
+					uri = attr.Values[0].V.String()
+				}
+			}
+
+			name := uri[strings.LastIndex(uri, "/")+1:]
+
+			status := goipp.StatusOk
+			if name == "lab-2" {
+				status = goipp.StatusErrorNotPossible
+			}
+
+			lock.Lock()
+			modified = append(modified, name)
+			lock.Unlock()
+
+			rsp := goipp.NewResponse(msg.Version, status, msg.RequestID)
+			return rsp, nil, nil
+		})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	clnt := cups.NewClient(transport.MustParseURL(srv.URL), nil)
+	settings := &ipp.CUPSPrinterSettings{
+		PrinterIsShared: optional.New(false),
+	}
+
+	op := func(ctx context.Context, name string) error {
+		return clnt.CUPSAddModifyPrinter(ctx, name, settings)
+	}
+
+	ctx := context.Background()
+
+	// Declined confirmation
+	out := &bytes.Buffer{}
+	bulk := &globBulk{
+		clnt: clnt,
+		in:   strings.NewReader("n\n"),
+		out:  out,
+	}
+
+	err := bulk.Do(ctx, "lab-*", op)
+	if err == nil || len(modified) != 0 {
+		t.Errorf("declined: expected no changes, present %v (%v)",
+			modified, err)
+	}
+
+	if !strings.Contains(out.String(), "  lab-10\n") {
+		t.Errorf("declined: queues not listed:\n%s", out)
+	}
+
+	// Confirmed; one queue fails, others must proceed.
+	// Display names (printer-info) must not be matched.
+	out.Reset()
+	bulk.in = strings.NewReader("y\n")
+
+	err = bulk.Do(ctx, "lab-*", op)
+	if err == nil || err.Error() != "1 of 3 queues failed" {
+		t.Errorf("confirmed: unexpected error %v", err)
+	}
+
+	expected := []string{"lab-1", "lab-10", "lab-2"}
+	if !reflect.DeepEqual(modified, expected) {
+		t.Errorf("confirmed: expected %q, present %q",
+			expected, modified)
+	}
+
+	if !strings.Contains(out.String(), "lab-1: OK\n") ||
+		!strings.Contains(out.String(), "lab-2: IPP: ") {
+		t.Errorf("confirmed: per-queue results missed:\n%s", out)
+	}
+
+	// No matches
+	bulk.yes = true
+	err = bulk.Do(ctx, "printer-*", op)
+	if err == nil {
+		t.Errorf("no matches: expected error")
+	}
+
+	// Not a pattern: performed directly
+	modified = nil
+	err = bulk.Do(ctx, "office", op)
+	if err != nil || !reflect.DeepEqual(modified, []string{"office"}) {
+		t.Errorf("single: %v (%v)", modified, err)
+	}
+}
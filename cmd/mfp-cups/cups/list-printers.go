@@ -15,6 +15,7 @@ import (
 	"github.com/OpenPrinting/go-mfp/cups"
 	"github.com/OpenPrinting/go-mfp/internal/env"
 	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
 )
 
 // cmdGetPrinters defines the "list-printers" sub-command.
@@ -27,6 +28,11 @@ var cmdListPrinters = argv.Command{
 		optID,
 		optLimit,
 		optLocation,
+		optNear,
+		{
+			Name: "--tls",
+			Help: "Inspect TLS certificates of the devices",
+		},
 		optUser,
 		argv.HelpOption,
 	},
@@ -47,6 +53,11 @@ func cmdListPrintersHandler(ctx context.Context, inv *argv.Invocation) error {
 	attrList := optAttrsGet(inv)
 	attrList = append(attrList, prnAttrsRequested...)
 
+	near := optNearGet(inv)
+	if near != nil {
+		attrList = append(attrList, cups.SearchAttrs...)
+	}
+
 	// Perform the query
 	clnt := cups.NewClient(dest, nil)
 	clnt.SetDecoderOptions(&ipp.DecoderOptions{KeepTrying: true})
@@ -56,13 +67,31 @@ func cmdListPrintersHandler(ctx context.Context, inv *argv.Invocation) error {
 		return err
 	}
 
+	if near != nil {
+		printers = near.Filter(printers)
+	}
+
+	// Query classes, to mark printers that are class members
+	classes, err := clnt.GetClasses(ctx, nil, cups.ClassAttrs)
+	if err != nil {
+		return err
+	}
+
+	membership := cups.ClassMembership(classes)
+
 	// Format output
 	pager := env.NewPager()
 
 	pager.Printf("CUPS: %s", dest)
 	for _, prn := range printers {
 		pager.Printf("")
-		prnAttrsFormat(pager, prn)
+		prnAttrsFormat(pager, prn,
+			membership[optional.Get(prn.PrinterName)])
+
+		if inv.Flag("--tls") {
+			info, err := cups.InspectTLS(ctx, prn.DeviceURI)
+			tlsInfoFormat(pager, info, err)
+		}
 	}
 
 	return pager.Display()
@@ -0,0 +1,173 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "modify" command.
+
+package cups
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// modifyYesNo lists values of the --shared option
+var modifyYesNo = []string{"yes", "no"}
+
+// modifyErrorPolicies lists values of the --error-policy option
+var modifyErrorPolicies = []string{
+	string(ipp.KwPrinterErrorPolicyAbortJob),
+	string(ipp.KwPrinterErrorPolicyRetryCurrentJob),
+	string(ipp.KwPrinterErrorPolicyRetryJob),
+	string(ipp.KwPrinterErrorPolicyStopPrinter),
+}
+
+// modifyOpPolicies lists suggested values of the --op-policy option
+var modifyOpPolicies = []string{
+	string(ipp.KwPrinterOpPolicyDefault),
+	string(ipp.KwPrinterOpPolicyAuthenticated),
+}
+
+// cmdModify defines the "modify" sub-command.
+var cmdModify = argv.Command{
+	Name:    "modify",
+	Help:    "Modify printer settings",
+	Handler: cmdModifyHandler,
+	Options: []argv.Option{
+		{
+			Name:      "--shared",
+			Help:      "Share the printer",
+			HelpArg:   "yes|no",
+			Singleton: true,
+			Validate:  argv.ValidateStrings(modifyYesNo),
+			Complete:  argv.CompleteStrings(modifyYesNo),
+		},
+		{
+			Name:      "--error-policy",
+			Help:      "What to do when the job cannot be sent to printer",
+			HelpArg:   strings.Join(modifyErrorPolicies, "|"),
+			Singleton: true,
+			Validate:  argv.ValidateStrings(modifyErrorPolicies),
+			Complete:  argv.CompleteStrings(modifyErrorPolicies),
+		},
+		{
+			Name:      "--op-policy",
+			Help:      "Operation policy name, defined in cupsd.conf",
+			HelpArg:   "name",
+			Singleton: true,
+			Validate:  argv.ValidateAny,
+			Complete:  argv.CompleteStrings(modifyOpPolicies),
+		},
+		{
+			Name:      "--job-sheets",
+			Help:      "Default banner pages",
+			HelpArg:   "start[,end]",
+			Singleton: true,
+			Validate:  optJobSheetsValidate,
+		},
+		{
+			Name:      "--info",
+			Help:      "Printer description",
+			HelpArg:   "text",
+			Singleton: true,
+			Validate:  argv.ValidateAny,
+		},
+		{
+			Name:      "--location",
+			Help:      "Printer location",
+			HelpArg:   "text",
+			Singleton: true,
+			Validate:  argv.ValidateAny,
+		},
+		globYesOption,
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:         "printer",
+			Help:         "printer (queue) name or glob pattern",
+			CompleteLive: completePrinterName,
+		},
+	},
+}
+
+// optJobSheetsValidate validates the --job-sheets option.
+func optJobSheetsValidate(s string) error {
+	sheets := strings.Split(s, ",")
+	if len(sheets) > 2 {
+		return errors.New("must be start[,end]")
+	}
+
+	for _, sheet := range sheets {
+		if sheet == "" {
+			return errors.New("must be start[,end]")
+		}
+	}
+
+	return nil
+}
+
+// cmdModifyHandler is the "modify" command handler
+func cmdModifyHandler(ctx context.Context, inv *argv.Invocation) error {
+	// Collect settings. Only explicitly specified attributes
+	// are sent, so CUPS leaves others unchanged.
+	name := inv.ParamGet(0)
+	settings := &ipp.CUPSPrinterSettings{}
+	modified := false
+
+	if val, found := inv.Get("--shared"); found {
+		settings.PrinterIsShared = optional.New(val == "yes")
+		modified = true
+	}
+
+	if val, found := inv.Get("--error-policy"); found {
+		settings.PrinterErrorPolicy = optional.New(
+			ipp.KwPrinterErrorPolicy(val))
+		modified = true
+	}
+
+	if val, found := inv.Get("--op-policy"); found {
+		settings.PrinterOpPolicy = optional.New(
+			ipp.KwPrinterOpPolicy(val))
+		modified = true
+	}
+
+	if val, found := inv.Get("--job-sheets"); found {
+		for _, sheet := range strings.Split(val, ",") {
+			settings.JobSheetsDefault = append(settings.JobSheetsDefault,
+				ipp.KwJobSheets(sheet))
+		}
+		modified = true
+	}
+
+	if val, found := inv.Get("--info"); found {
+		settings.PrinterInfo = optional.New(val)
+		modified = true
+	}
+
+	if val, found := inv.Get("--location"); found {
+		settings.PrinterLocation = optional.New(val)
+		modified = true
+	}
+
+	if !modified {
+		return errors.New("nothing to modify")
+	}
+
+	// Perform the query
+	dest := optCUPSURL(inv)
+	clnt := cups.NewClient(dest, nil)
+	bulk := newGlobBulk(inv, clnt)
+
+	return bulk.Do(ctx, name, func(ctx context.Context, name string) error {
+		return clnt.CUPSAddModifyPrinter(ctx, name, settings)
+	})
+}
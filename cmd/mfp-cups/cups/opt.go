@@ -9,6 +9,7 @@
 package cups
 
 import (
+	"fmt"
 	"math"
 	"net/url"
 	"strconv"
@@ -122,6 +123,62 @@ func optLocationGet(inv *argv.Invocation) string {
 	return opt
 }
 
+// optNear describes the --near option.
+// It specifies the location and radius (meters, or kilometers with
+// the "km" suffix) of the printers search.
+var optNear = argv.Option{
+	Name: "--near",
+	Help: "" +
+		`Show only printers within radius ` +
+		`(e.g., "48.2010,16.3695,500" or "48.2,16.37,2km")`,
+	HelpArg: "lat,lon,radius",
+	Validate: func(s string) error {
+		_, err := optNearParse(s)
+		return err
+	},
+}
+
+// optNearGet returns --near option value as cups.PrinterSearch.
+// If option is not set, it returns nil.
+func optNearGet(inv *argv.Invocation) *cups.PrinterSearch {
+	opt, ok := inv.Get("--near")
+	if !ok {
+		return nil
+	}
+
+	search, _ := optNearParse(opt)
+	return search
+}
+
+// optNearParse parses the --near option value.
+func optNearParse(s string) (*cups.PrinterSearch, error) {
+	err := fmt.Errorf("%q: expected lat,lon,radius", s)
+
+	fields := strings.Split(s, ",")
+	if len(fields) != 3 {
+		return nil, err
+	}
+
+	center, e := ipp.ParseGeoLocation("geo:" + fields[0] + "," + fields[1])
+	if e != nil {
+		return nil, err
+	}
+
+	radius, mult := fields[2], 1.0
+	if strings.HasSuffix(radius, "km") {
+		radius, mult = strings.TrimSuffix(radius, "km"), 1000
+	} else {
+		radius = strings.TrimSuffix(radius, "m")
+	}
+
+	r, e := strconv.ParseFloat(radius, 64)
+	if e != nil || r < 0 || math.IsInf(r, 0) || math.IsNaN(r) {
+		return nil, err
+	}
+
+	return &cups.PrinterSearch{Near: &center, Radius: r * mult}, nil
+}
+
 // optSchemesExclude describes the --exclude-schemes=scheme,... option
 // It specifies URL schemes to be excluded
 var optSchemesExclude = argv.Option{
@@ -266,10 +323,13 @@ func optPPDNameGet(inv *argv.Invocation) string {
 
 // optCUPSURL returns CUPS URL (-u/--cups option).
 // If option is not set, it uses default destination.
+//
+// The -u option is persistent, so it may be specified either
+// before or after the sub-command name.
 func optCUPSURL(inv *argv.Invocation) *url.URL {
 	dest := cups.DefaultUNIXURL
 
-	if addr, ok := inv.Parent().Get("-u"); ok {
+	if addr, ok := inv.Get("-u"); ok {
 		dest = transport.MustParseAddr(addr, "ipp://localhost/")
 	}
 
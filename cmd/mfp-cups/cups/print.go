@@ -0,0 +1,190 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "print" command.
+
+package cups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/internal/env"
+	"github.com/OpenPrinting/goipp"
+)
+
+// cmdPrint defines the "print" sub-command.
+var cmdPrint = argv.Command{
+	Name:    "print",
+	Help:    "Print the file",
+	Handler: cmdPrintHandler,
+	Options: []argv.Option{
+		optPrinterURI,
+		optJobOption,
+		optDryRun,
+		optMaxClockSkew,
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:     "file",
+			Help:     "file to print",
+			Complete: argv.CompleteOSPath,
+		},
+	},
+}
+
+// optJobOption describes the -o option.
+// It specifies the job option.
+var optJobOption = argv.Option{
+	Name:    "-o",
+	Aliases: []string{"--option"},
+	Help: "Job option. Either one of:\n" +
+		"  quality=draft|normal|best\n" +
+		"  duplex=off|long|short\n" +
+		"  color=auto|color|mono\n" +
+		"or any IPP Job Template attribute\n" +
+		"(dateTime values are in the RFC 3339 format)",
+	HelpArg:  "name=value",
+	Validate: optJobOptionValidate,
+}
+
+// optDryRun describes the --dry-run option.
+// It requests job validation instead of printing.
+var optDryRun = argv.Option{
+	Name: "--dry-run",
+	Help: "Validate the job (Validate-Job), but don't print",
+}
+
+// optMaxClockSkew describes the --max-clock-skew option.
+// It specifies the tolerated printer clock skew.
+var optMaxClockSkew = argv.Option{
+	Name: "--max-clock-skew",
+	Help: "Warn, if job uses time-based attributes and printer\n" +
+		"clock differs more (default: " +
+		cups.DefaultMaxClockSkew.String() + ")",
+	HelpArg:  "seconds",
+	Validate: argv.ValidateIntRange(0, 1, math.MaxInt32),
+}
+
+// optMaxClockSkewGet returns --max-clock-skew option value,
+// or 0 if option is not set.
+func optMaxClockSkewGet(inv *argv.Invocation) time.Duration {
+	if opt, ok := inv.Get(optMaxClockSkew.Name); ok {
+		v, _ := strconv.Atoi(opt)
+		return time.Duration(v) * time.Second
+	}
+	return 0
+}
+
+// optJobOptionValidate validates the -o option.
+func optJobOptionValidate(s string) error {
+	if name, _, ok := strings.Cut(s, "="); !ok || name == "" {
+		return errors.New("must be name=value")
+	}
+	return nil
+}
+
+// cmdPrintHandler is the "print" command handler
+func cmdPrintHandler(ctx context.Context, inv *argv.Invocation) error {
+	// Validate options
+	printerURI := optPrinterURIGet(inv)
+	if printerURI == "" {
+		return fmt.Errorf("%s option required", optPrinterURI.Name)
+	}
+
+	file := inv.ParamGet(0)
+
+	// Prepare print options
+	var username string
+	if usr, err := user.Current(); err == nil {
+		username = usr.Username
+	}
+
+	opts := cups.PrintOptions{
+		JobName:  filepath.Base(file),
+		UserName: username,
+		Options:  inv.Values(optJobOption.Name),
+
+		MaxClockSkew: optMaxClockSkewGet(inv),
+	}
+
+	dest := optCUPSURL(inv)
+	clnt := cups.NewClient(dest, nil)
+
+	if inv.Flag(optDryRun.Name) {
+		return cmdPrintValidate(ctx, clnt, printerURI, opts)
+	}
+
+	// Open the file
+	fp, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer fp.Close()
+
+	// Print the document
+	jobID, err := clnt.Print(ctx, printerURI, opts, fp)
+	if err != nil {
+		return err
+	}
+
+	// Format output
+	pager := env.NewPager()
+	pager.Printf("Job ID: %d", jobID)
+
+	return pager.Display()
+}
+
+// cmdPrintValidate validates the job with Validate-Job and
+// reports the result.
+func cmdPrintValidate(ctx context.Context, clnt *cups.Client,
+	printerURI string, opts cups.PrintOptions) error {
+
+	res, err := clnt.ValidateJob(ctx, printerURI, opts)
+	if err != nil {
+		return err
+	}
+
+	// Format output
+	pager := env.NewPager()
+	if res.Accepted {
+		pager.Printf("Job accepted: %s", res.Status)
+	} else {
+		pager.Printf("Job rejected: %s", res.Status)
+	}
+
+	if res.StatusMessage != "" && res.StatusMessage != res.Status.String() {
+		pager.Printf("Message: %s", res.StatusMessage)
+	}
+
+	if len(res.UnsupportedAttributes) != 0 {
+		f := goipp.NewFormatter()
+		f.SetIndent(2)
+		f.FmtAttributes(res.UnsupportedAttributes)
+
+		pager.Printf("Unsupported attributes:")
+		f.WriteTo(pager)
+	}
+
+	err = pager.Display()
+	if err == nil && !res.Accepted {
+		err = errors.New("job would be rejected by printer")
+	}
+
+	return err
+}
@@ -11,6 +11,7 @@ package cups
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/OpenPrinting/go-mfp/proto/ipp"
 	"github.com/OpenPrinting/go-mfp/util/optional"
@@ -22,6 +23,7 @@ import (
 // "get-default", "get-printers" and similar.
 var prnAttrsRequested = []string{
 	"device-uri",
+	"member-names",
 	"printer-id",
 	"printer-is-shared",
 	"printer-is-temporary",
@@ -31,7 +33,12 @@ var prnAttrsRequested = []string{
 }
 
 // prnAttrsFormat pretty-prints [ipp.PrinterAttributes]
-func prnAttrsFormat(w io.Writer, prn *ipp.PrinterAttributes) {
+//
+// The memberOf lists classes, the printer is member of (see
+// [cups.ClassMembership]). It may be nil.
+func prnAttrsFormat(w io.Writer, prn *ipp.PrinterAttributes,
+	memberOf []string) {
+
 	fmt.Fprintf(w, "%s:\n", optional.Get(prn.PrinterName))
 
 	fmt.Fprintf(w, "  General information:\n")
@@ -52,6 +59,14 @@ func prnAttrsFormat(w io.Writer, prn *ipp.PrinterAttributes) {
 	if prn.PrinterType != nil {
 		fmt.Fprintf(w, "    Decoded Type: %s\n", *prn.PrinterType)
 	}
+	if len(prn.MemberNames) != 0 {
+		fmt.Fprintf(w, "    Members:      %s\n",
+			strings.Join(prn.MemberNames, ", "))
+	}
+	if len(memberOf) != 0 {
+		fmt.Fprintf(w, "    Member of:    %s\n",
+			strings.Join(memberOf, ", "))
+	}
 	fmt.Fprintf(w, "\n")
 
 	errors := prn.Errors()
@@ -0,0 +1,91 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer information pretty-printer test
+
+package cups
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// TestPrnAttrsFormatMembership tests rendering of the class
+// membership by prnAttrsFormat
+func TestPrnAttrsFormatMembership(t *testing.T) {
+	// Classes, as returned by CUPS-Get-Classes
+	office := &ipp.PrinterAttributes{}
+	office.PrinterName = optional.New("office")
+	office.PrinterType = optional.New(ipp.EnPrinterClass)
+	office.MemberNames = []string{"Kyocera", "Xerox"}
+
+	lab := &ipp.PrinterAttributes{}
+	lab.PrinterName = optional.New("lab")
+	lab.MemberURIs = []string{"ipp://localhost/printers/Kyocera"}
+
+	membership := cups.ClassMembership([]*ipp.PrinterAttributes{
+		office, lab})
+
+	type testData struct {
+		prn      *ipp.PrinterAttributes
+		expected []string // Expected lines
+		missed   []string // Lines that must not be present
+	}
+
+	kyocera := &ipp.PrinterAttributes{}
+	kyocera.PrinterName = optional.New("Kyocera")
+
+	xerox := &ipp.PrinterAttributes{}
+	xerox.PrinterName = optional.New("Xerox")
+
+	hp := &ipp.PrinterAttributes{}
+	hp.PrinterName = optional.New("HP")
+
+	tests := []testData{
+		{
+			prn:      kyocera,
+			expected: []string{"    Member of:    lab, office\n"},
+			missed:   []string{"Members:"},
+		},
+		{
+			prn:      xerox,
+			expected: []string{"    Member of:    office\n"},
+		},
+		{
+			prn:    hp,
+			missed: []string{"Member of:", "Members:"},
+		},
+		{
+			prn:      office,
+			expected: []string{"    Members:      Kyocera, Xerox\n"},
+			missed:   []string{"Member of:"},
+		},
+	}
+
+	for _, test := range tests {
+		name := optional.Get(test.prn.PrinterName)
+		buf := &bytes.Buffer{}
+		prnAttrsFormat(buf, test.prn, membership[name])
+		out := buf.String()
+
+		for _, line := range test.expected {
+			if !strings.Contains(out, line) {
+				t.Errorf("%s: missed %q in:\n%s", name, line, out)
+			}
+		}
+
+		for _, line := range test.missed {
+			if strings.Contains(out, line) {
+				t.Errorf("%s: unexpected %q in:\n%s", name, line, out)
+			}
+		}
+	}
+}
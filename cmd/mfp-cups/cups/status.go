@@ -0,0 +1,136 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "status" command.
+
+package cups
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/internal/env"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// cmdStatus defines the "status" sub-command.
+var cmdStatus = argv.Command{
+	Name:    "status",
+	Help:    "Show printer status",
+	Handler: cmdStatusHandler,
+	Options: []argv.Option{
+		{
+			Name: "--trays",
+			Help: "Show input and output trays",
+		},
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:         "printer",
+			Help:         "printer (queue) name",
+			CompleteLive: completePrinterName,
+		},
+		{
+			Name:         "[printer...]",
+			Help:         "more printers",
+			CompleteLive: completePrinterName,
+		},
+	},
+}
+
+// statusAttrs are attributes, requested by the "status" command
+var statusAttrs = []string{
+	"printer-name",
+	"printer-state",
+	"printer-state-message",
+	"printer-state-reasons",
+}
+
+// statusTraysAttrs are attributes, requested with the --trays option
+var statusTraysAttrs = []string{
+	"media-col-ready",
+	"media-ready",
+	"printer-input-tray",
+	"printer-output-tray",
+}
+
+// cmdStatusHandler is the "status" command handler
+func cmdStatusHandler(ctx context.Context, inv *argv.Invocation) error {
+	trays := inv.Flag("--trays")
+
+	attrs := statusAttrs
+	if trays {
+		attrs = append(attrs, statusTraysAttrs...)
+	}
+
+	// Perform the queries
+	dest := optCUPSURL(inv)
+	clnt := cups.NewClient(dest, nil)
+
+	pager := env.NewPager()
+	pager.Printf("CUPS: %s", dest)
+
+	for i := 0; i < inv.ParamCount(); i++ {
+		name := inv.ParamGet(i)
+		prn, err := clnt.GetPrinterAttributes(ctx, name, attrs)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		pager.Printf("")
+		pager.Printf("%s:", name)
+		statusFormat(pager, prn, trays)
+	}
+
+	return pager.Display()
+}
+
+// statusFormat formats the printer status
+func statusFormat(pager *env.Pager, prn *ipp.PrinterAttributes, trays bool) {
+	reasons := make([]string, len(prn.PrinterStateReasons))
+	for i, reason := range prn.PrinterStateReasons {
+		reasons[i] = string(reason)
+	}
+
+	pager.Printf("  %-16s %s", "State:",
+		cups.StateString(optional.Get(prn.PrinterState)))
+	pager.Printf("  %-16s %s", "Reasons:", strings.Join(reasons, ","))
+
+	if msg := optional.Get(prn.PrinterStateMessage); msg != "" {
+		pager.Printf("  %-16s %s", "Message:", msg)
+	}
+
+	if !trays {
+		return
+	}
+
+	input := prn.InputTrays()
+	output := prn.OutputTrays()
+
+	if len(input) == 0 && len(output) == 0 {
+		pager.Printf("  Trays:           not reported")
+		return
+	}
+
+	if len(input) != 0 {
+		pager.Printf("  Input trays:")
+		for _, tray := range input {
+			pager.Printf("    %s", tray)
+		}
+	}
+
+	if len(output) != 0 {
+		pager.Printf("  Output trays:")
+		for _, tray := range output {
+			pager.Printf("    %s", tray)
+		}
+	}
+}
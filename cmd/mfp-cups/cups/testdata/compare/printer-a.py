@@ -0,0 +1,41 @@
+# This is the generated MFP model file.
+# You probably need to edit it appropriately before use.
+
+# IPP printer attributes:
+ipp.printer = ipp.COLLECTION(
+    operations_supported = [
+        ipp.OP.PRINT_JOB,
+        ipp.OP.VALIDATE_JOB,
+        ipp.OP.CREATE_JOB,
+        ipp.OP.SEND_DOCUMENT,
+        ipp.OP.GET_PRINTER_ATTRIBUTES,
+    ],
+    printer_uri_supported = ipp.URI('ipp://printer-a.local/ipp/print'),
+    printer_make_and_model = ipp.TEXT('Example Office 4000'),
+    document_format_supported = [
+        ipp.MIMETYPE('application/pdf'),
+        ipp.MIMETYPE('image/urf'),
+        ipp.MIMETYPE('application/postscript'),
+    ],
+    media_supported = [
+        ipp.KEYWORD('iso_a4_210x297mm'),
+        ipp.KEYWORD('iso_a3_297x420mm'),
+        ipp.KEYWORD('na_letter_8.5x11in'),
+    ],
+    media_default = ipp.KEYWORD('iso_a4_210x297mm'),
+    finishings_supported = [ipp.ENUM(3), ipp.ENUM(4), ipp.ENUM(28)],
+    printer_resolution_supported = [
+        ipp.RESOLUTION(300, 300, 'dpi'),
+        ipp.RESOLUTION(600, 600, 'dpi'),
+    ],
+    sides_supported = [
+        ipp.KEYWORD('one-sided'),
+        ipp.KEYWORD('two-sided-long-edge'),
+        ipp.KEYWORD('two-sided-short-edge'),
+    ],
+    print_color_mode_supported = [
+        ipp.KEYWORD('monochrome'),
+        ipp.KEYWORD('color'),
+    ],
+    copies_supported = ipp.RANGE(1, 999),
+)
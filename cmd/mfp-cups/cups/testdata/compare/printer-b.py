@@ -0,0 +1,39 @@
+# This is the generated MFP model file.
+# You probably need to edit it appropriately before use.
+
+# IPP printer attributes:
+ipp.printer = ipp.COLLECTION(
+    operations_supported = [
+        ipp.OP.PRINT_JOB,
+        ipp.OP.VALIDATE_JOB,
+        ipp.OP.CREATE_JOB,
+        ipp.OP.SEND_DOCUMENT,
+        ipp.OP.GET_PRINTER_ATTRIBUTES,
+        ipp.OP.IDENTIFY_PRINTER,
+    ],
+    printer_uri_supported = ipp.URI('ipp://printer-b.local/ipp/print'),
+    printer_make_and_model = ipp.TEXT('Example Office 5000'),
+    document_format_supported = [
+        ipp.MIMETYPE('image/urf'),
+        ipp.MIMETYPE('application/pdf'),
+        ipp.MIMETYPE('image/pwg-raster'),
+    ],
+    media_supported = [
+        ipp.KEYWORD('na_letter_8.5x11in'),
+        ipp.KEYWORD('iso_a4_210x297mm'),
+        ipp.KEYWORD('na_legal_8.5x14in'),
+    ],
+    media_default = ipp.KEYWORD('na_letter_8.5x11in'),
+    finishings_supported = [ipp.ENUM(3), ipp.ENUM(4)],
+    printer_resolution_supported = [
+        ipp.RESOLUTION(600, 600, 'dpi'),
+        ipp.RESOLUTION(1200, 1200, 'dpi'),
+    ],
+    sides_supported = [
+        ipp.KEYWORD('one-sided'),
+        ipp.KEYWORD('two-sided-long-edge'),
+        ipp.KEYWORD('two-sided-short-edge'),
+    ],
+    output_bin_supported = ipp.KEYWORD('face-down'),
+    copies_supported = ipp.RANGE(1, 9999),
+)
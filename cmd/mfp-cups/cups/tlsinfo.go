@@ -0,0 +1,50 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "cups" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// TLS certificate details formatting
+
+package cups
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/cups"
+)
+
+// tlsInfoFormat pretty-prints [cups.TLSInfo], as returned by
+// the [cups.InspectTLS], as the "Device TLS" section.
+func tlsInfoFormat(w io.Writer, info cups.TLSInfo, err error) {
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "  Device TLS:     %s\n", err)
+		return
+
+	case !info.Encrypted:
+		fmt.Fprintf(w, "  Device TLS:     none\n")
+		return
+	}
+
+	verified := "yes"
+	if !info.Verified {
+		verified = fmt.Sprintf("no (%s)", info.VerifyErr)
+	}
+
+	fmt.Fprintf(w, "  Device TLS:     %s\n", info.Version)
+	fmt.Fprintf(w, "    Subject:      %s\n", info.Subject)
+	fmt.Fprintf(w, "    Issuer:       %s\n", info.Issuer)
+	if len(info.SANs) != 0 {
+		fmt.Fprintf(w, "    SANs:         %s\n",
+			strings.Join(info.SANs, ", "))
+	}
+	fmt.Fprintf(w, "    Not after:    %s\n",
+		info.NotAfter.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "    Signature:    %s\n", info.SignatureAlgorithm)
+	fmt.Fprintf(w, "    Self-signed:  %v\n", info.SelfSigned)
+	fmt.Fprintf(w, "    Verified:     %s\n", verified)
+}
@@ -0,0 +1,62 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "discover" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Discovery backends selection
+
+package discover
+
+import (
+	"context"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+	"github.com/OpenPrinting/go-mfp/discovery/dnssd"
+	"github.com/OpenPrinting/go-mfp/discovery/usb"
+	"github.com/OpenPrinting/go-mfp/discovery/wsdd"
+)
+
+// protoNames lists values of the --proto option
+var protoNames = []string{"dnssd", "wsd", "usb"}
+
+// backendNew creates discovery backends by the --proto name.
+//
+// It is variable, so tests can substitute fake backends.
+var backendNew = map[string]func(context.Context) (discovery.Backend, error){
+	"dnssd": func(ctx context.Context) (discovery.Backend, error) {
+		return dnssd.NewBackend(ctx, "", 0)
+	},
+	"wsd": wsdd.NewBackend,
+	"usb": usb.NewBackend,
+}
+
+// discoverBackends creates backends for the selected protocols.
+// If protos is empty, all known backends are created.
+//
+// Backends, that cannot be created in the current environment (i.e.,
+// DNS-SD without Avahi daemon), are skipped and reported as
+// [discovery.Degradation] with the empty Fallback.
+func discoverBackends(ctx context.Context, protos []string) (
+	backends []discovery.Backend, skipped []discovery.Degradation) {
+
+	if len(protos) == 0 {
+		protos = protoNames
+	}
+
+	for _, proto := range protos {
+		bk, err := backendNew[proto](ctx)
+		if err != nil {
+			skipped = append(skipped, discovery.Degradation{
+				Backend:    proto,
+				Capability: "discovery",
+				Err:        err,
+			})
+			continue
+		}
+
+		backends = append(backends, bk)
+	}
+
+	return
+}
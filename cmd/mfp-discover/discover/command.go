@@ -10,31 +10,96 @@ package discover
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/OpenPrinting/go-mfp/abstract"
 	"github.com/OpenPrinting/go-mfp/argv"
 	"github.com/OpenPrinting/go-mfp/discovery"
-	"github.com/OpenPrinting/go-mfp/discovery/dnssd"
-	"github.com/OpenPrinting/go-mfp/discovery/wsdd"
+	"github.com/OpenPrinting/go-mfp/discovery/query"
 	"github.com/OpenPrinting/go-mfp/internal/env"
 	"github.com/OpenPrinting/go-mfp/log"
 )
 
+// formatNames lists values of the --format option
+var formatNames = []string{
+	"text", "table", "json", "json-schema",
+	"uris", "cups-commands", "airscan-conf",
+}
+
 // Command is the 'cups' command description
 var Command = argv.Command{
 	Name: "discover",
 	Help: "search for printers and scanners",
 	Options: []argv.Option{
 		argv.Option{
-			Name:    "-d",
-			Aliases: []string{"--debug"},
-			Help:    "Enable debug output",
+			Name:       "-d",
+			Aliases:    []string{"--debug"},
+			Help:       "Enable debug output (-dd for verbose debug)",
+			Persistent: true,
 		},
 		argv.Option{
 			Name:    "-v",
 			Aliases: []string{"--verbose"},
-			Help:    "Enable verbose debug output",
+			Help: "Increase output verbosity:\n" +
+				"-v  - show endpoints and where they come from\n" +
+				"-vv - also show device and units attributes",
+		},
+		argv.Option{
+			Name: "--raw",
+			Help: "Show per-backend records, before merging",
+		},
+		argv.Option{
+			Name: "--format",
+			Help: "Output format (default: text):\n" +
+				"text          - human-readable text\n" +
+				"table         - table, one line per device\n" +
+				"json          - machine-readable JSON\n" +
+				"json-schema   - print JSON Schema of the JSON output\n" +
+				"uris          - list of endpoint URIs\n" +
+				"cups-commands - lpadmin commands to add printers\n" +
+				"airscan-conf  - sane-airscan configuration",
+			HelpArg:   strings.Join(formatNames, "|"),
+			Singleton: true,
+			Validate:  argv.ValidateStrings(formatNames),
+			Complete:  argv.CompleteStrings(formatNames),
+		},
+		argv.Option{
+			Name: "--proto",
+			Help: "Use only the specified discovery protocol\n" +
+				"(may be repeated, default: all)",
+			HelpArg:  strings.Join(protoNames, "|"),
+			Validate: argv.ValidateStrings(protoNames),
+			Complete: argv.CompleteStrings(protoNames),
+		},
+		argv.Option{
+			Name:         "-i",
+			Aliases:      []string{"--interface"},
+			Help:         "Show only devices, reachable via interface",
+			HelpArg:      "name",
+			CompleteLive: completeInterface,
+		},
+		argv.Option{
+			Name:     "--subnet",
+			Help:     "Show only devices within the subnet",
+			HelpArg:  "CIDR",
+			Validate: validateSubnet,
+		},
+		argv.Option{
+			Name: "--timeout",
+			Help: "Discovery timeout; when expired, show what\n" +
+				"is discovered so far",
+			HelpArg:   "seconds",
+			Singleton: true,
+			Validate:  argv.ValidateIntRange(0, 1, math.MaxInt32),
 		},
 		argv.Option{
 			Name:    "-p",
@@ -46,204 +111,260 @@ var Command = argv.Command{
 			Aliases: []string{"--scanners"},
 			Help:    "Search for scanners",
 		},
+		argv.Option{
+			Name:    "-q",
+			Aliases: []string{"--query"},
+			Help: "Filter devices by query, e.g.:\n" +
+				`"make:HP AND (protocol:escl OR protocol:wsd)"`,
+			HelpArg:   "query",
+			Singleton: true,
+			Validate:  validateQuery,
+		},
+		argv.Option{
+			Name:    "-w",
+			Aliases: []string{"--watch"},
+			Help:    "Watch for devices changes until interrupted",
+		},
+		argv.Option{
+			Name: "--explain",
+			Help: "Explain discovery decisions (to stderr)",
+		},
 		argv.HelpOption,
 	},
 	Handler: cmdDiscoverHandler,
 }
 
+// options contains the parsed command options
+type options struct {
+	format    string              // Output format
+	verbosity discovery.Verbosity // Text output verbosity
+	raw       bool                // Raw text output
+	protos    []string            // Discovery protocols, nil for all
+	filter    filter              // Devices filter
+	timeout   time.Duration       // Discovery timeout, 0 if none
+	watch     bool                // Watch mode
+	explain   *explainer          // The --explain output, nil if none
+}
+
 // cmdCupsHandler is the handler for the 'discover' command.
 func cmdDiscoverHandler(ctx context.Context, inv *argv.Invocation) error {
-	// Setup logging
-	_, dbg := inv.Get("-d")
-	_, vrb := inv.Get("-v")
+	// Parse options
+	opts, err := optionsGet(inv)
+	if err != nil {
+		return err
+	}
 
-	dbg = true // FIXME
+	if opts.format == "json-schema" {
+		_, err := os.Stdout.Write(discovery.JSONSchema())
+		return err
+	}
 
-	level := log.LevelInfo
-	if dbg {
-		level = log.LevelDebug
+	if inv.Flag("--explain") {
+		opts.explain = newExplainer(os.Stderr)
 	}
-	if vrb {
+
+	// Setup logging
+	dbg := len(inv.Values("-d"))
+
+	level := log.LevelInfo
+	switch {
+	case dbg > 1:
 		level = log.LevelTrace
+	case dbg > 0:
+		level = log.LevelDebug
 	}
 
 	logger := log.NewLogger(level, log.Console)
 	ctx = log.NewContext(ctx, logger)
 
 	// Prepare discovery.Client
+	backends, skipped := discoverBackends(ctx, opts.protos)
+	for _, d := range skipped {
+		log.Warning(ctx, "%s", d)
+	}
+
+	opts.explain.backends(backends, skipped)
+
+	if len(backends) == 0 {
+		return errors.New("no discovery backends available")
+	}
+
 	clnt := discovery.NewClient(ctx)
+	defer clnt.Close()
+
+	for _, bk := range backends {
+		clnt.AddBackend(bk)
+	}
+
+	// Run discovery. The text output goes via pager, unless
+	// watching, as the pager shows output only on exit.
+	if opts.watch || (opts.format != "text" && opts.format != "table") {
+		return discoverRun(ctx, clnt, opts, os.Stdout)
+	}
+
+	pager := env.NewPager()
+	defer pager.Display()
+
+	return discoverRun(ctx, clnt, opts, pager)
+}
+
+// optionsGet parses the command options.
+func optionsGet(inv *argv.Invocation) (*options, error) {
+	opts := &options{
+		format: "text",
+		raw:    inv.Flag("--raw"),
+		watch:  inv.Flag("-w"),
+	}
 
-	backend, err := dnssd.NewBackend(ctx, "", 0)
+	if format, found := inv.Get("--format"); found {
+		opts.format = format
+	}
+
+	if opts.raw && opts.format != "text" {
+		return nil, fmt.Errorf("--raw is not supported with --format %s",
+			opts.format)
+	}
+
+	if opts.watch && opts.format != "text" {
+		return nil, fmt.Errorf("--watch is not supported with --format %s",
+			opts.format)
+	}
+
+	opts.verbosity = discovery.Verbosity(len(inv.Values("-v")))
+	opts.verbosity = min(opts.verbosity, discovery.VerbosityAttributes)
+
+	for _, proto := range inv.Values("--proto") {
+		if !slices.Contains(opts.protos, proto) {
+			opts.protos = append(opts.protos, proto)
+		}
+	}
+
+	if s, found := inv.Get("--timeout"); found {
+		v, _ := strconv.Atoi(s)
+		opts.timeout = time.Duration(v) * time.Second
+	}
+
+	// Prepare the filter
+	qstr, _ := inv.Get("-q")
+	match, err := query.Compile(qstr)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	defer backend.Close()
-	clnt.AddBackend(backend)
+	opts.filter = filter{
+		match:    match,
+		printers: inv.Flag("-p"),
+		scanners: inv.Flag("-s"),
+	}
+
+	var subnets []netip.Prefix
+	for _, s := range inv.Values("--subnet") {
+		subnet, _ := netip.ParsePrefix(s)
+		subnets = append(subnets, subnet.Masked())
+	}
 
-	backend, err = wsdd.NewBackend(ctx)
+	opts.filter.scope, err = newScope(inv.Values("-i"), subnets)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	clnt.AddBackend(backend)
+	return opts, nil
+}
 
-	// Perform device discovery
-	devices, err := clnt.GetDevices(ctx, discovery.ModeNormal)
-	backend.Close()
+// discoverRun performs the discovery and writes results to out.
+func discoverRun(ctx context.Context, clnt *discovery.Client,
+	opts *options, out io.Writer) error {
 
+	if opts.watch {
+		return discoverWatch(ctx, clnt, opts, out, watchInterval)
+	}
+
+	// Perform device discovery
+	devices, err := discoverGetDevices(ctx, clnt, opts.timeout)
 	if err != nil {
 		return err
 	}
 
+	// Report unavailable discovery capabilities
+	degraded := clnt.Degraded()
+	for _, d := range degraded {
+		log.Warning(ctx, "%s", d)
+	}
+
+	opts.explain.degraded(degraded)
+
+	// Filter devices
+	devices = opts.filter.apply(devices, opts.explain)
+	opts.explain.identities(devices, clnt.Identities())
+
 	// Format output
-	pager := env.NewPager()
-	defer pager.Display()
+	switch opts.format {
+	case "table":
+		return discovery.FormatTable(out, devices)
+	case "json":
+		return discovery.FormatJSON(out, devices)
+	case "uris":
+		return discovery.FormatURIs(out, devices)
+	case "cups-commands":
+		return discovery.FormatCUPSCommands(out, devices)
+	case "airscan-conf":
+		return discovery.FormatAirscanConf(out, devices)
+	}
 
-	if len(devices) == 0 {
-		pager.Printf("No devices found.")
-	}
-
-	for _, dev := range devices {
-		pager.Printf("================================")
-
-		pager.Printf("  MakeModel:        %q", dev.MakeModel)
-		pager.Printf("  Location:         %q", dev.Location)
-		pager.Printf("  DNS-SD name:      %q", dev.DNSSDName)
-		pager.Printf("  DNS-SD UUID:      %q", dev.DNSSDUUID)
-		pager.Printf("  Print Admin URL:  %s", dev.PrintAdminURL)
-		pager.Printf("  Scan Admin URL:   %s", dev.ScanAdminURL)
-		pager.Printf("  Faxout Admin URL: %s", dev.FaxoutAdminURL)
-		pager.Printf("  Icon URL:         %s", dev.IconURL)
-		pager.Printf("  PPD Manufacturer: %q", dev.PPDManufacturer)
-		pager.Printf("  PPD Model:        %q", dev.PPDModel)
-		pager.Printf("  USB Serial:       %q", dev.USBSerial)
-		pager.Printf("  USB HWID:         %q", dev.USBHWID)
-
-		s := []string{}
-		for _, addr := range dev.Addrs {
-			s = append(s, addr.String())
-		}
-		pager.Printf("  IP addresses:     %s", strings.Join(s, ", "))
-		pager.Printf("")
-
-		if len(dev.PrintUnits) != 0 {
-			pager.Printf("  Print units:")
-			for i, un := range dev.PrintUnits {
-				if i != 0 {
-					pager.Printf("")
-				}
-
-				p := un.Params
-
-				pager.Printf("    Type:       %s printer",
-					un.Proto)
-				pager.Printf("    Auth:       %s", p.Auth)
-
-				if p.Paper != discovery.PaperUnknown {
-					pager.Printf("    Paper Size: %s",
-						p.Paper)
-				}
-
-				if p.Media != 0 {
-					pager.Printf("    Media Type: %s",
-						p.Media)
-				}
-
-				pager.Printf("    Flags:      %s", p.Flags())
-
-				pager.Printf("    PSProduct:  %q", p.PSProduct)
-				pager.Printf("    PDL:        %s",
-					strings.Join(p.PDL, ","))
-				pager.Printf("    Priority:   %d", p.Priority)
-
-				pager.Printf("    Endpoints:")
-				for _, ep := range un.Endpoints {
-					pager.Printf("      %s", ep)
-				}
-
-			}
-			pager.Printf("")
-		}
+	return discovery.Format(out, devices, discovery.FormatOptions{
+		Verbosity: opts.verbosity,
+		Raw:       opts.raw,
+	})
+}
 
-		if len(dev.ScanUnits) != 0 {
-			pager.Printf("  Scan units:")
-			for i, un := range dev.ScanUnits {
-				if i != 0 {
-					pager.Printf("")
-				}
-
-				p := un.Params
-				pager.Printf("    Type:       %s scanner",
-					un.Proto)
-				if p.Duplex != nil {
-					pager.Printf("    Duplex:     %v",
-						*p.Duplex)
-				}
-				if p.Sources != 0 {
-					pager.Printf("    Sources:    %s",
-						p.Sources)
-				}
-				if !p.Colors.IsEmpty() {
-					var modes []string
-					if p.Colors.Contains(
-						abstract.ColorModeColor) {
-						modes = append(modes, "color")
-					}
-					if p.Colors.Contains(
-						abstract.ColorModeMono) {
-						modes = append(modes, "mono")
-					}
-					if p.Colors.Contains(
-						abstract.ColorModeBinary) {
-						modes = append(modes, "bin")
-					}
-					pager.Printf("    ColorModes: %s",
-						strings.Join(modes, ","))
-				}
-				if len(p.PDL) != 0 {
-					pager.Printf("    PDL:        %s",
-						strings.Join(p.PDL, ","))
-				}
-
-				pager.Printf("    Endpoints:")
-				for _, ep := range un.Endpoints {
-					pager.Printf("      %s", ep)
-				}
-			}
-			pager.Printf("")
-		}
+// discoverGetDevices returns discovered devices.
+//
+// If timeout is not zero and discovery doesn't complete in time,
+// it returns devices, discovered so far.
+func discoverGetDevices(ctx context.Context, clnt *discovery.Client,
+	timeout time.Duration) ([]discovery.Device, error) {
 
-		if len(dev.FaxoutUnits) != 0 {
-			pager.Printf("  Faxout units:")
-			for i, un := range dev.FaxoutUnits {
-				if i != 0 {
-					pager.Printf("")
-				}
-
-				p := un.Params
-
-				pager.Printf("    Type:       %s fax",
-					un.Proto)
-				pager.Printf("    Auth:       %s", p.Auth)
-				pager.Printf("    Paper Size: %s", p.Paper)
-				pager.Printf("    Media Type: %s", p.Media)
-
-				pager.Printf("    Flags:      %s", p.Flags())
-
-				pager.Printf("    PSProduct:  %q", p.PSProduct)
-				pager.Printf("    PDL:        %s",
-					strings.Join(p.PDL, ","))
-				pager.Printf("    Priority:   %d", p.Priority)
-
-				pager.Printf("    Endpoints:")
-				for _, ep := range un.Endpoints {
-					pager.Printf("      %s", ep)
-				}
-			}
-			pager.Printf("")
-		}
+	if timeout == 0 {
+		return clnt.GetDevices(ctx, discovery.ModeNormal)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	devices, err := clnt.GetDevices(tctx, discovery.ModeNormal)
+	if err == nil || ctx.Err() != nil {
+		return devices, err
+	}
+
+	log.Warning(ctx, "discovery timed out after %s, results may be incomplete",
+		timeout)
+
+	return clnt.GetDevices(ctx, discovery.ModeSnapshot)
+}
+
+// validateQuery validates the --query option
+func validateQuery(s string) error {
+	_, err := query.Compile(s)
+	return err
+}
+
+// validateSubnet validates the --subnet option
+func validateSubnet(s string) error {
+	_, err := netip.ParsePrefix(s)
+	return err
+}
+
+// completeInterface completes the --interface option
+func completeInterface(ctx context.Context, prefix string) []string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, len(ifaces))
+	for i, ifi := range ifaces {
+		names[i] = ifi.Name
 	}
 
-	return nil
+	return names
 }
@@ -0,0 +1,422 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "discover" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "discover" command test
+
+package discover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// testDiscoverUpdate, if set, causes tests to rewrite golden files
+var testDiscoverUpdate = flag.Bool("update-golden", false,
+	"update golden files of the discover command test")
+
+// testDiscoverDir is the directory with the golden files
+const testDiscoverDir = "testdata"
+
+// Fake devices
+var (
+	testKyoceraPrinter = discovery.UnitID{
+		DNSSDName: "Kyocera ECOSYS M2040dn",
+		UUID:      uuid.MustParse("4509a320-00a0-008f-00b6-002507510eca"),
+		Realm:     discovery.RealmDNSSD,
+		SvcType:   discovery.ServicePrinter,
+		SvcProto:  discovery.ServiceIPP,
+	}
+
+	testKyoceraScanner = discovery.UnitID{
+		DNSSDName: "Kyocera ECOSYS M2040dn",
+		UUID:      uuid.MustParse("4509a320-00a0-008f-00b6-002507510eca"),
+		Realm:     discovery.RealmDNSSD,
+		SvcType:   discovery.ServiceScanner,
+		SvcProto:  discovery.ServiceESCL,
+	}
+
+	testCanonScanner = discovery.UnitID{
+		DNSSDName: "Canon MF410 Series",
+		UUID:      uuid.MustParse("6d4ff0ce-6b11-11d8-8020-f48139a1f2c8"),
+		Realm:     discovery.RealmDNSSD,
+		SvcType:   discovery.ServiceScanner,
+		SvcProto:  discovery.ServiceESCL,
+	}
+)
+
+// testBackend is the fake discovery.Backend
+type testBackend struct {
+	name   string                // Backend name
+	events []discovery.Event     // Initial events
+	queue  *discovery.Eventqueue // Event queue, after Start
+	lock   sync.Mutex            // Access lock
+}
+
+// newTestBackend creates the testBackend with two devices:
+// Kyocera MFP and Canon scanner.
+func newTestBackend(name string) *testBackend {
+	return &testBackend{
+		name: name,
+		events: []discovery.Event{
+			&discovery.EventAddUnit{ID: testKyoceraPrinter},
+			&discovery.EventPrinterParameters{
+				ID:        testKyoceraPrinter,
+				MakeModel: "Kyocera ECOSYS M2040dn",
+			},
+			&discovery.EventAddEndpoint{
+				ID:       testKyoceraPrinter,
+				Endpoint: "ipp://192.168.0.5:631/ipp/print",
+			},
+			&discovery.EventAddUnit{ID: testKyoceraScanner},
+			&discovery.EventScannerParameters{
+				ID:        testKyoceraScanner,
+				MakeModel: "Kyocera ECOSYS M2040dn",
+			},
+			&discovery.EventAddEndpoint{
+				ID:       testKyoceraScanner,
+				Endpoint: "http://192.168.0.5:9095/eSCL",
+			},
+			&discovery.EventAddUnit{ID: testCanonScanner},
+			&discovery.EventScannerParameters{
+				ID:        testCanonScanner,
+				MakeModel: "Canon MF410 Series",
+			},
+			&discovery.EventAddEndpoint{
+				ID:       testCanonScanner,
+				Endpoint: "http://10.0.0.7/eSCL",
+			},
+		},
+	}
+}
+
+// Name returns backend name.
+func (bk *testBackend) Name() string {
+	return bk.name
+}
+
+// Start starts Backend operations.
+func (bk *testBackend) Start(q *discovery.Eventqueue) {
+	bk.lock.Lock()
+	bk.queue = q
+	bk.lock.Unlock()
+
+	for _, e := range bk.events {
+		q.Push(e)
+	}
+}
+
+// Close closes the Backend.
+func (bk *testBackend) Close() {
+}
+
+// push pushes the event into the queue of the started backend.
+func (bk *testBackend) push(e discovery.Event) {
+	bk.lock.Lock()
+	bk.queue.Push(e)
+	bk.lock.Unlock()
+}
+
+// testClient creates discovery.Client with the testBackend
+func testClient(t *testing.T, warmUp time.Duration) (
+	*discovery.Client, *testBackend) {
+
+	clnt := discovery.NewClientTm(context.Background(),
+		warmUp, 50*time.Millisecond)
+	t.Cleanup(clnt.Close)
+
+	bk := newTestBackend("test")
+	clnt.AddBackend(bk)
+
+	return clnt, bk
+}
+
+// testOptions parses command options
+func testOptions(t *testing.T, args ...string) *options {
+	inv, err := Command.Parse(args)
+	if err != nil {
+		t.Fatalf("%q: %s", args, err)
+	}
+
+	opts, err := optionsGet(inv)
+	if err != nil {
+		t.Fatalf("%q: %s", args, err)
+	}
+
+	return opts
+}
+
+// testGolden compares output against the golden file
+func testGolden(t *testing.T, name string, out []byte) {
+	path := filepath.Join(testDiscoverDir, name)
+	if *testDiscoverUpdate {
+		err := os.WriteFile(path, out, 0644)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !bytes.Equal(out, expected) {
+		diff := testutils.DiffLineBytes(path, expected, "output", out)
+		t.Errorf("%s: output mismatch:\n%s", name, diff)
+	}
+}
+
+// TestDiscoverFormats tests all output formats against golden files
+func TestDiscoverFormats(t *testing.T) {
+	type testData struct {
+		args   []string // Command arguments
+		golden string   // Golden file
+	}
+
+	tests := []testData{
+		{[]string{}, "text.txt"},
+		{[]string{"--format", "table"}, "table.txt"},
+		{[]string{"--format", "json"}, "devices.json"},
+		{[]string{"--format", "uris"}, "uris.txt"},
+		{[]string{"--format", "cups-commands"}, "cups-commands.txt"},
+		{[]string{"--format", "airscan-conf"}, "airscan.conf"},
+		{[]string{"-s", "--subnet", "10.0.0.0/8"}, "scope.txt"},
+	}
+
+	// LocalID is random, so mask it
+	localID := regexp.MustCompile(`"local_id": "[^"]*"`)
+
+	clnt, _ := testClient(t, 100*time.Millisecond)
+
+	for _, test := range tests {
+		opts := testOptions(t, test.args...)
+
+		buf := &bytes.Buffer{}
+		err := discoverRun(context.Background(), clnt, opts, buf)
+		if err != nil {
+			t.Errorf("%q: %s", test.args, err)
+			continue
+		}
+
+		out := localID.ReplaceAll(buf.Bytes(),
+			[]byte(`"local_id": "LOCAL-ID"`))
+
+		testGolden(t, test.golden, out)
+	}
+}
+
+// TestDiscoverOptions tests options parsing errors
+func TestDiscoverOptions(t *testing.T) {
+	tests := [][]string{
+		{"--raw", "--format", "json"},
+		{"--watch", "--format", "uris"},
+		{"--subnet", "10.0.0.0"},
+		{"--proto", "cups"},
+		{"--timeout", "0"},
+		{"-i", "no-such-interface0"},
+	}
+
+	for _, args := range tests {
+		inv, err := Command.Parse(args)
+		if err == nil {
+			_, err = optionsGet(inv)
+		}
+
+		if err == nil {
+			t.Errorf("%q: error expected", args)
+		}
+	}
+}
+
+// TestDiscoverBackends tests backends selection
+func TestDiscoverBackends(t *testing.T) {
+	saveBackendNew := backendNew
+	defer func() { backendNew = saveBackendNew }()
+
+	errUnavailable := errors.New("daemon not running")
+	backendNew = map[string]func(context.Context) (discovery.Backend, error){
+		"dnssd": func(context.Context) (discovery.Backend, error) {
+			return nil, errUnavailable
+		},
+		"wsd": func(context.Context) (discovery.Backend, error) {
+			return newTestBackend("wsd"), nil
+		},
+		"usb": func(context.Context) (discovery.Backend, error) {
+			return newTestBackend("usb"), nil
+		},
+	}
+
+	backends, skipped := discoverBackends(context.Background(), nil)
+	if len(backends) != 2 {
+		t.Errorf("all protocols: expected 2 backends, present %d",
+			len(backends))
+	}
+
+	if len(skipped) != 1 || skipped[0].Backend != "dnssd" ||
+		!errors.Is(skipped[0].Err, errUnavailable) {
+		t.Errorf("all protocols: unexpected skipped: %v", skipped)
+	}
+
+	opts := testOptions(t, "--proto", "usb", "--proto", "usb")
+	backends, skipped = discoverBackends(context.Background(), opts.protos)
+	if len(backends) != 1 || backends[0].Name() != "usb" || skipped != nil {
+		t.Errorf("--proto usb: unexpected result: %v, %v",
+			backends, skipped)
+	}
+}
+
+// TestDiscoverExplain tests the --explain output
+func TestDiscoverExplain(t *testing.T) {
+	clnt, _ := testClient(t, 100*time.Millisecond)
+
+	opts := testOptions(t, "-q", "make:Kyocera")
+	explain := &bytes.Buffer{}
+	opts.explain = newExplainer(explain)
+
+	err := discoverRun(context.Background(), clnt, opts, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	expected := []string{
+		`explain: "Canon MF410 Series": excluded: doesn't match the query`,
+		`explain: "Kyocera ECOSYS M2040dn": included`,
+		`explain: "Kyocera ECOSYS M2040dn": local-id `,
+	}
+
+	for _, s := range expected {
+		if !strings.Contains(explain.String(), s) {
+			t.Errorf("%q: missed in output:\n%s", s, explain)
+		}
+	}
+}
+
+// TestDiscoverTimeout tests that on timeout devices, discovered
+// so far, are returned
+func TestDiscoverTimeout(t *testing.T) {
+	clnt, _ := testClient(t, time.Minute)
+
+	start := time.Now()
+	devices, err := discoverGetDevices(context.Background(), clnt,
+		200*time.Millisecond)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	if elapsed > 10*time.Second {
+		t.Errorf("timeout not honored: %s", elapsed)
+	}
+
+	if len(devices) != 2 {
+		t.Errorf("expected 2 devices, present %d", len(devices))
+	}
+}
+
+// TestDiscoverScopeMapped tests that devices with IPv4-mapped
+// addresses match the IPv4 subnet scope
+func TestDiscoverScopeMapped(t *testing.T) {
+	sc := &scope{
+		subnets: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	}
+
+	dev := discovery.Device{
+		Addrs: []netip.Addr{netip.MustParseAddr("::ffff:10.1.2.3")},
+	}
+
+	if !sc.contains(dev) {
+		t.Errorf("%s: must be in scope %s", dev.Addrs[0], sc.subnets[0])
+	}
+
+	dev.Addrs = []netip.Addr{netip.MustParseAddr("::ffff:192.168.1.1")}
+	if sc.contains(dev) {
+		t.Errorf("%s: must not be in scope %s", dev.Addrs[0],
+			sc.subnets[0])
+	}
+}
+
+// TestDiscoverWatchEvents tests the --watch event stream rendering
+func TestDiscoverWatchEvents(t *testing.T) {
+	clnt, bk := testClient(t, 100*time.Millisecond)
+	w := newWatcher()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	buf := &bytes.Buffer{}
+
+	poll := func() {
+		devices, err := clnt.GetDevices(context.Background(),
+			discovery.ModeNormal)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		for _, ev := range w.update(devices, now) {
+			buf.WriteString(ev.String() + "\n")
+		}
+
+		now = now.Add(5 * time.Second)
+	}
+
+	// Initial poll: all devices added
+	poll()
+
+	// Nothing changed: no events
+	poll()
+
+	// Canon scanner disappears, Kyocera gets the new endpoint
+	bk.push(&discovery.EventDelUnit{ID: testCanonScanner})
+	bk.push(&discovery.EventAddEndpoint{
+		ID:       testKyoceraPrinter,
+		Endpoint: "ipps://192.168.0.5:443/ipp/print",
+	})
+	time.Sleep(200 * time.Millisecond)
+	poll()
+
+	// All devices disappear
+	for _, ev := range w.update(nil, now) {
+		buf.WriteString(ev.String() + "\n")
+	}
+
+	testGolden(t, "watch.txt", buf.Bytes())
+}
+
+// TestDiscoverWatch tests that watch mode streams events until
+// the context is canceled
+func TestDiscoverWatch(t *testing.T) {
+	clnt, _ := testClient(t, 100*time.Millisecond)
+	opts := testOptions(t, "--watch", "-p")
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		500*time.Millisecond)
+	defer cancel()
+
+	buf := &bytes.Buffer{}
+	err := discoverWatch(ctx, clnt, opts, buf, 50*time.Millisecond)
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 ||
+		!strings.Contains(lines[0], ` added   "Kyocera ECOSYS M2040dn"`) {
+		t.Errorf("unexpected output:\n%s", buf)
+	}
+}
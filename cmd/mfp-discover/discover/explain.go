@@ -0,0 +1,99 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "discover" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The --explain output
+
+package discover
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+)
+
+// explainer writes the --explain output: which backends were used,
+// what was degraded, why each device was included or excluded and
+// the identity audit trail of included devices.
+//
+// All methods are no-op on the nil explainer.
+type explainer struct {
+	out io.Writer
+}
+
+// newExplainer creates a new explainer. If out is nil,
+// it returns nil, which is the valid explainer that does nothing.
+func newExplainer(out io.Writer) *explainer {
+	if out == nil {
+		return nil
+	}
+	return &explainer{out}
+}
+
+// printf writes the formatted line of explanation.
+func (e *explainer) printf(format string, args ...any) {
+	if e != nil {
+		fmt.Fprintf(e.out, "explain: "+format+"\n", args...)
+	}
+}
+
+// backends explains used and skipped backends
+func (e *explainer) backends(used []discovery.Backend,
+	skipped []discovery.Degradation) {
+
+	for _, bk := range used {
+		e.printf("backend %s: used", bk.Name())
+	}
+
+	for _, d := range skipped {
+		e.printf("backend %s: skipped: %s", d.Backend, d.Err)
+	}
+}
+
+// degraded explains degraded capabilities of the backends
+func (e *explainer) degraded(degraded []discovery.Degradation) {
+	for _, d := range degraded {
+		e.printf("degraded: %s", d)
+	}
+}
+
+// identities writes identity audit trail of devices
+func (e *explainer) identities(devices []discovery.Device,
+	idents []discovery.Identity) {
+
+	if e == nil {
+		return
+	}
+
+	for _, dev := range devices {
+		if dev.LocalID == "" {
+			continue
+		}
+
+		for _, ident := range idents {
+			if ident.LocalID != dev.LocalID {
+				continue
+			}
+
+			e.printf("%q: local-id %s, first seen %s",
+				devName(dev), ident.LocalID,
+				ident.FirstSeen.Format(time.DateTime))
+
+			for _, rec := range ident.History {
+				e.printf("%q: %s: uuid %s, addresses %v",
+					devName(dev), rec.Time.Format(time.DateTime),
+					rec.UUID, rec.Addrs)
+			}
+
+			if dev.IdentityConflict {
+				e.printf("%q: identity conflict: other device "+
+					"claims the same MAC address or serial",
+					devName(dev))
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "discover" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Devices filtering: query, device kind and network scope
+
+package discover
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+	"github.com/OpenPrinting/go-mfp/discovery/query"
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// filter selects devices for output
+type filter struct {
+	match    func(query.Device) bool // Compiled --query
+	printers bool                    // -p: devices with print units
+	scanners bool                    // -s: devices with scan units
+	scope    *scope                  // --interface/--subnet, nil if any
+}
+
+// scope is the network scope of discovery.
+//
+// Device is in scope, if any of its addresses belongs to the
+// scope subnets, or it is the link-local address with the zone
+// of the scope interface (by name or by index). Devices without
+// IP addresses (i.e., USB devices) are always in scope.
+type scope struct {
+	zones   []string       // Interface names and indices
+	subnets []netip.Prefix // Subnets
+}
+
+// apply returns devices, that pass the filter.
+// If explain is not nil, reason of each decision is reported there.
+func (f *filter) apply(devices []discovery.Device,
+	explain *explainer) []discovery.Device {
+
+	filtered := make([]discovery.Device, 0, len(devices))
+	for _, dev := range devices {
+		reason := f.reject(dev)
+		if reason != "" {
+			explain.printf("%q: excluded: %s", devName(dev), reason)
+			continue
+		}
+
+		explain.printf("%q: included", devName(dev))
+		filtered = append(filtered, dev)
+	}
+
+	return filtered
+}
+
+// reject returns the reason why device doesn't pass the filter,
+// or "" if it passes.
+func (f *filter) reject(dev discovery.Device) string {
+	if f.printers || f.scanners {
+		ok := (f.printers && len(dev.PrintUnits) != 0) ||
+			(f.scanners && len(dev.ScanUnits) != 0)
+		if !ok {
+			return "not a printer or scanner, as requested"
+		}
+	}
+
+	if f.match != nil && !f.match(query.Device{Device: dev}) {
+		return "doesn't match the query"
+	}
+
+	if f.scope != nil && !f.scope.contains(dev) {
+		return fmt.Sprintf("out of scope (addresses: %s)",
+			devAddrs(dev))
+	}
+
+	return ""
+}
+
+// newScope creates the scope from the interface names and subnets.
+//
+// The interface scope includes subnets of all addresses of the
+// interface.
+func newScope(ifnames []string, subnets []netip.Prefix) (*scope, error) {
+	if len(ifnames) == 0 && len(subnets) == 0 {
+		return nil, nil
+	}
+
+	sc := &scope{subnets: subnets}
+	for _, name := range ifnames {
+		ifi, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("interface %q: %w", name, err)
+		}
+
+		sc.zones = append(sc.zones, ifi.Name, strconv.Itoa(ifi.Index))
+
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("interface %q: %w", name, err)
+		}
+
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+
+			ip, _ := netip.AddrFromSlice(ipnet.IP)
+			bits, _ := ipnet.Mask.Size()
+			sc.subnets = append(sc.subnets,
+				netip.PrefixFrom(transport.NormalizeAddr(ip),
+					bits).Masked())
+		}
+	}
+
+	return sc, nil
+}
+
+// contains reports if device is in scope.
+func (sc *scope) contains(dev discovery.Device) bool {
+	if len(dev.Addrs) == 0 {
+		return true
+	}
+
+	for _, addr := range dev.Addrs {
+		if addr.Zone() != "" && slices.Contains(sc.zones, addr.Zone()) {
+			return true
+		}
+
+		addr = transport.NormalizeAddr(addr.WithZone(""))
+		for _, subnet := range sc.subnets {
+			if subnet.Contains(addr) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// devName returns the device name for messages
+func devName(dev discovery.Device) string {
+	if dev.DNSSDName != "" {
+		return dev.DNSSDName
+	}
+	return dev.MakeModel
+}
+
+// devAddrs returns the device addresses for messages
+func devAddrs(dev discovery.Device) string {
+	if len(dev.Addrs) == 0 {
+		return "none"
+	}
+
+	s := ""
+	for i, addr := range dev.Addrs {
+		if i != 0 {
+			s += ","
+		}
+		s += addr.String()
+	}
+
+	return s
+}
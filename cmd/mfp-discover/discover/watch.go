@@ -0,0 +1,166 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "discover" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The --watch mode
+
+package discover
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+)
+
+// watchInterval is the devices polling interval in the --watch mode
+const watchInterval = time.Second
+
+// watchEventKind is the kind of the watchEvent
+type watchEventKind int
+
+// watchEventKind values:
+const (
+	watchAdded watchEventKind = iota
+	watchRemoved
+	watchChanged
+)
+
+// String returns watchEventKind name
+func (kind watchEventKind) String() string {
+	switch kind {
+	case watchAdded:
+		return "added"
+	case watchRemoved:
+		return "removed"
+	case watchChanged:
+		return "changed"
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(kind))
+}
+
+// watchEvent reports the change in the set of discovered devices.
+type watchEvent struct {
+	time    time.Time      // When change was detected
+	kind    watchEventKind // What happened
+	summary string         // Device summary line
+}
+
+// String formats watchEvent as a line of the --watch output.
+func (ev watchEvent) String() string {
+	return fmt.Sprintf("%s %-7s %s",
+		ev.time.Format(time.TimeOnly), ev.kind, ev.summary)
+}
+
+// watcher tracks the set of discovered devices between polls
+type watcher struct {
+	known map[string]watchState // Known devices, by key
+}
+
+// watchState is the known device state
+type watchState struct {
+	summary     string // Device summary line
+	fingerprint string // All device data, for change detection
+}
+
+// newWatcher creates a new watcher
+func newWatcher() *watcher {
+	return &watcher{known: make(map[string]watchState)}
+}
+
+// update compares devices with the known ones, updates known devices
+// and returns events, sorted by device summary.
+func (w *watcher) update(devices []discovery.Device,
+	now time.Time) []watchEvent {
+
+	var events []watchEvent
+	seen := make(map[string]struct{}, len(devices))
+
+	for _, dev := range devices {
+		key := watchKey(dev)
+		seen[key] = struct{}{}
+
+		st := watchState{
+			summary:     watchFormat(dev, discovery.VerbositySummary),
+			fingerprint: watchFormat(dev, discovery.VerbosityAttributes),
+		}
+
+		old, found := w.known[key]
+		switch {
+		case !found:
+			events = append(events, watchEvent{now, watchAdded, st.summary})
+		case old.fingerprint != st.fingerprint:
+			events = append(events, watchEvent{now, watchChanged, st.summary})
+		}
+
+		w.known[key] = st
+	}
+
+	for key, st := range w.known {
+		if _, found := seen[key]; !found {
+			events = append(events, watchEvent{now, watchRemoved, st.summary})
+			delete(w.known, key)
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].summary < events[j].summary
+	})
+
+	return events
+}
+
+// watchKey returns the key that identifies the device between polls.
+func watchKey(dev discovery.Device) string {
+	if dev.LocalID != "" {
+		return dev.LocalID
+	}
+	return dev.DNSSDName + "\x00" + dev.MakeModel
+}
+
+// watchFormat formats the single device with the specified verbosity.
+func watchFormat(dev discovery.Device, verbosity discovery.Verbosity) string {
+	buf := &bytes.Buffer{}
+	discovery.Format(buf, []discovery.Device{dev},
+		discovery.FormatOptions{Verbosity: verbosity})
+	return strings.TrimSpace(buf.String())
+}
+
+// discoverWatch polls discovery.Client with the specified interval
+// and writes changes in the set of devices, that pass the filter,
+// until ctx is canceled.
+func discoverWatch(ctx context.Context, clnt *discovery.Client,
+	opts *options, out io.Writer, interval time.Duration) error {
+
+	w := newWatcher()
+	for {
+		devices, err := clnt.GetDevices(ctx, discovery.ModeNormal)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		devices = opts.filter.apply(devices, nil)
+		for _, ev := range w.update(devices, time.Now()) {
+			if _, err := fmt.Fprintln(out, ev); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
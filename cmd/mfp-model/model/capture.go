@@ -0,0 +1,163 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "model" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "capture" command.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/discovery"
+	"github.com/OpenPrinting/go-mfp/discovery/dnssd"
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/modeling"
+	"github.com/OpenPrinting/go-mfp/proto/usbhost"
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// cmdCapture defines the "capture" sub-command.
+var cmdCapture = argv.Command{
+	Name:    "capture",
+	Help:    "Capture model from the real device",
+	Handler: cmdCaptureHandler,
+	Options: []argv.Option{
+		argv.Option{
+			Name:      "-D",
+			Aliases:   []string{"--dnssd"},
+			Help:      "DNS-SD name of the device",
+			HelpArg:   "name",
+			Conflicts: []string{"-p", "-s", "-W", "-U"},
+			Singleton: true,
+			Validate:  argv.ValidateAny,
+			Complete:  dnssd.ArgvCompleter,
+		},
+		argv.Option{
+			Name:      "-p",
+			Aliases:   []string{"--printer", "--ipp"},
+			Help:      "IPP printer URL",
+			HelpArg:   "URL",
+			Singleton: true,
+			Validate:  transport.ValidateAddr,
+		},
+		argv.Option{
+			Name:      "-s",
+			Aliases:   []string{"--scanner", "--escl"},
+			Help:      "eSCL scanner URL",
+			HelpArg:   "URL",
+			Singleton: true,
+			Validate:  transport.ValidateAddr,
+		},
+		argv.Option{
+			Name:      "-W",
+			Aliases:   []string{"--wsd"},
+			Help:      "WSD scanner URL",
+			HelpArg:   "URL",
+			Singleton: true,
+			Validate:  transport.ValidateAddr,
+		},
+		argv.Option{
+			Name:      "-U",
+			Aliases:   []string{"--usb"},
+			Help:      "USB device (identified by serial number)",
+			HelpArg:   "serial",
+			Singleton: true,
+			Validate:  argv.ValidateAny,
+			Complete:  usbCompleter,
+		},
+		optOutput,
+		argv.HelpOption,
+	},
+}
+
+// usbCompleter is a the argv.Completer for -U option
+func usbCompleter(s string) []argv.Completion {
+	completions := []argv.Completion{}
+	list, _ := usbhost.ListDevices(false)
+	for _, info := range list {
+		if info.IsPrinter() {
+			serial := info.Desc.ISerialNumber
+			if strings.HasPrefix(serial, s) {
+				completions = append(completions,
+					argv.Completion{String: serial})
+			}
+		}
+	}
+
+	return completions
+}
+
+// cmdCaptureHandler is the "capture" command handler.
+func cmdCaptureHandler(ctx context.Context, inv *argv.Invocation) error {
+	// Check options
+	optDNSSD, haveDNSSD := inv.Get("--dnssd")
+	opt := modeling.CaptureOptions{
+		IPP:  inv.Values("--printer"),
+		ESCL: inv.Values("--scanner"),
+		WSD:  inv.Values("--wsd"),
+	}
+	opt.USB, _ = inv.Get("--usb")
+
+	if !haveDNSSD && opt.IPP == nil && opt.ESCL == nil &&
+		opt.WSD == nil && opt.USB == "" {
+
+		err := errors.New("at least one option required: --dnssd, --printer, --scanner, --wsd or --usb")
+		return err
+	}
+
+	// Gather endpoints
+	if haveDNSSD {
+		dev, err := discoverByName(ctx, optDNSSD)
+		if err != nil {
+			return err
+		}
+
+		for _, unit := range dev.PrintUnits {
+			if unit.Proto == discovery.ServiceIPP {
+				opt.IPP = append(opt.IPP, unit.Endpoints...)
+			}
+		}
+
+		for _, unit := range dev.ScanUnits {
+			switch unit.Proto {
+			case discovery.ServiceESCL:
+				opt.ESCL = append(opt.ESCL, unit.Endpoints...)
+			case discovery.ServiceWSD:
+				opt.WSD = append(opt.WSD, unit.Endpoints...)
+			}
+		}
+
+		// Check that something was discovered.
+		if opt.IPP == nil && opt.ESCL == nil && opt.WSD == nil {
+			err := errors.New("no eSCL/IPP/WSD endpoints discovered")
+			return err
+		}
+	}
+
+	// Capture the model
+	model, err := modeling.Capture(ctx, opt)
+	if err != nil {
+		return err
+	}
+
+	defer model.Close()
+
+	if attrs := model.GetIPPPrinterAttrs(); attrs != nil {
+		if errors := attrs.Errors(); errors != nil {
+			log.Warning(ctx, "ipp: printer attributes decoded with warnings:")
+			for _, err := range errors {
+				log.Warning(ctx, "  %s", err)
+			}
+		}
+	}
+
+	// Save model to file
+	file, _ := inv.Get("-o")
+	return saveModel(model, file)
+}
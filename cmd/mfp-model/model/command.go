@@ -10,18 +10,13 @@ package model
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	"io"
 	"os"
-	"strings"
 
 	"github.com/OpenPrinting/go-mfp/argv"
-	"github.com/OpenPrinting/go-mfp/discovery"
-	"github.com/OpenPrinting/go-mfp/discovery/dnssd"
+	"github.com/OpenPrinting/go-mfp/internal/env"
 	"github.com/OpenPrinting/go-mfp/log"
 	"github.com/OpenPrinting/go-mfp/modeling"
-	"github.com/OpenPrinting/go-mfp/proto/usbhost"
-	"github.com/OpenPrinting/go-mfp/transport"
 )
 
 // DefaultTCPPort is the default TCP port for the MFP simulator
@@ -32,262 +27,78 @@ const description = "" +
 	"This command generates and validates models for the MFP simulator\n" +
 	""
 
+// output is where the sub-commands write their output.
+// Tests may redirect it.
+var output io.Writer = os.Stdout
+
 // Command is the 'model' command description
 var Command = argv.Command{
-	Name:                     "model",
-	Help:                     "Model generator for MFP simulator",
-	Description:              description,
-	NoOptionsAfterParameters: true,
+	Name:        "model",
+	Help:        "Model generator for MFP simulator",
+	Description: description,
 	Options: []argv.Option{
-		argv.Option{
-			Name:      "-D",
-			Aliases:   []string{"--dnssd"},
-			Help:      "DNS-SD name of the device",
-			HelpArg:   "name",
-			Conflicts: []string{"-E", "-I", "-W", "-U"},
-			Singleton: true,
-			Validate:  argv.ValidateAny,
-			Complete:  dnssd.ArgvCompleter,
-		},
-		argv.Option{
-			Name:      "-E",
-			Aliases:   []string{"--escl"},
-			Help:      "eSCL scanner URL",
-			HelpArg:   "URL",
-			Singleton: true,
-			Validate:  transport.ValidateAddr,
-		},
-		argv.Option{
-			Name:      "-I",
-			Aliases:   []string{"--ipp"},
-			Help:      "IPP printer URL",
-			HelpArg:   "URL",
-			Singleton: true,
-			Validate: func(s string) error {
-				err := transport.ValidateAddr(s)
-				return err
-			},
-		},
-		argv.Option{
-			Name:      "-W",
-			Aliases:   []string{"--wsd"},
-			Help:      "WSD scanner URL",
-			HelpArg:   "URL",
-			Singleton: true,
-			Validate: func(s string) error {
-				err := transport.ValidateAddr(s)
-				return err
-			},
-		},
-		argv.Option{
-			Name:      "-U",
-			Aliases:   []string{"--usb"},
-			Help:      "USB device (identified by serial number)",
-			HelpArg:   "serial",
-			Singleton: true,
-			Validate:  argv.ValidateAny,
-			Complete:  usbCompleter,
-		},
-		argv.Option{
-			Name:      "-m",
-			Aliases:   []string{"--model"},
-			Help:      "write model to file (use - for stdout)",
-			HelpArg:   "file",
-			Required:  true,
-			Singleton: true,
-			Validate:  argv.ValidateAny,
-			Complete:  argv.CompleteOSPath,
-		},
-		argv.Option{
-			Name:      "-V",
-			Aliases:   []string{"--validate"},
-			Help:      "validate existent model",
-			Singleton: true,
-			Conflicts: []string{
-				"--dnssd", "--escl", "--ipp", "--wsd",
-			},
-		},
-		argv.Option{
-			Name:    "-d",
-			Aliases: []string{"--debug"},
-			Help:    "Enable debug output",
-		},
-		argv.Option{
-			Name:    "-v",
-			Aliases: []string{"--verbose"},
-			Help:    "Enable verbose debug output",
-		},
+		env.OptDebug,
+		env.OptVerbose,
 		argv.HelpOption,
 	},
+	SubCommands: []argv.Command{
+		cmdCapture,
+		cmdDiff,
+		cmdInstantiate,
+		cmdValidate,
+		argv.HelpCommand,
+	},
 	Handler: cmdModelHandler,
 }
 
-// usbCompleter is a the argv.Completer for -U option
-func usbCompleter(s string) []argv.Completion {
-	completions := []argv.Completion{}
-	list, _ := usbhost.ListDevices(false)
-	for _, info := range list {
-		if info.IsPrinter() {
-			serial := info.Desc.ISerialNumber
-			//println(serial, s)
-			if strings.HasPrefix(serial, s) {
-				completions = append(completions,
-					argv.Completion{String: serial})
-			}
-		}
-	}
-
-	return completions
+// optOutput describes the -o option, common for sub-commands
+// that write model.
+var optOutput = argv.Option{
+	Name:      "-o",
+	Aliases:   []string{"--output"},
+	Help:      "write model to file (use - for stdout)",
+	HelpArg:   "file",
+	Required:  true,
+	Singleton: true,
+	Validate:  argv.ValidateAny,
+	Complete:  argv.CompleteOSPath,
 }
 
 // cmdModelHandler is the top-level handler for the 'model' command.
 func cmdModelHandler(ctx context.Context, inv *argv.Invocation) error {
 	// Setup logging
-	_, dbg := inv.Get("-d")
-	_, vrb := inv.Get("-v")
-
-	level := log.LevelInfo
-	if dbg {
-		level = log.LevelDebug
-	}
-	if vrb {
-		level = log.LevelTrace
-	}
+	level := env.LogLevel(inv)
 
 	logger := log.NewLogger(level, log.Console)
 	ctx = log.NewContext(ctx, logger)
 
-	_ = ctx
-
-	// Check options
-	optDHSSD, haveDNSSD := inv.Get("--dnssd")
-	_, validate := inv.Get("--validate")
-	optESCL := inv.Values("--escl")
-	optIPP := inv.Values("--ipp")
-	optWSD := inv.Values("--wsd")
-	optUSB, haveUSB := inv.Get("--usb")
-
-	if !haveDNSSD && !validate &&
-		optIPP == nil && optESCL == nil && optWSD == nil && !haveUSB {
-
-		err := errors.New("at least one option required: --dnssd, --escl, --ipp, --wsd, --usb or --validate")
-		return err
-	}
-
-	// Handle the --validate option
-	if validate {
-		model, err := modeling.NewModel()
-		if err != nil {
-			return err
-		}
-
-		defer model.Close()
-
-		file, _ := inv.Get("-m")
-		err = model.Load(file)
-		if err != nil {
-			return err
-		}
-
-		caps := model.GetIPPPrinterAttrs()
-		if caps != nil {
-			errors := caps.Errors()
-			if errors != nil {
-				log.Warning(ctx, "ipp: printer attributes decoded with warnings:")
-				for _, err := range errors {
-					log.Warning(ctx, "  %s", err)
-				}
-			}
-		}
-
-		return err
-	}
-
-	// Gather endpoints
-	if haveDNSSD {
-		dev, err := discoverByName(ctx, optDHSSD)
-		if err != nil {
-			return err
-		}
-
-		for _, unit := range dev.PrintUnits {
-			if unit.Proto == discovery.ServiceIPP {
-				optIPP = append(optIPP, unit.Endpoints...)
-			}
-		}
-
-		for _, unit := range dev.ScanUnits {
-			switch unit.Proto {
-			case discovery.ServiceESCL:
-				optESCL = append(optESCL, unit.Endpoints...)
-			case discovery.ServiceWSD:
-				optWSD = append(optWSD, unit.Endpoints...)
-			}
-		}
-
-		// Check that something was discovered.
-		if optIPP == nil && optESCL == nil && optWSD == nil {
-			err := errors.New("no eSCL/IPP/WSD endpoints discovered")
-			return err
-		}
-	}
+	// Execute subcommand
+	return argv.DefaultHandler(ctx, inv)
+}
 
-	// Create a model
+// loadModel creates a new [modeling.Model] and loads it from file.
+//
+// On success, caller must Close the returned Model after use.
+func loadModel(file string) (*modeling.Model, error) {
 	model, err := modeling.NewModel()
 	if err != nil {
-		return err
-	}
-
-	// Query printer and scanner capabilities
-	if optIPP != nil {
-		err = model.DownloadIPPPrinterAttrs(ctx, optIPP)
-		if err != nil {
-			err = fmt.Errorf(
-				"Can't get IPP Printer Attributes: %s", err)
-			return err
-		}
-
-		attrs := model.GetIPPPrinterAttrs()
-		if errors := attrs.Errors(); errors != nil {
-			log.Warning(ctx, "ipp: printer attributes decoded with warnings:")
-			for _, err := range errors {
-				log.Warning(ctx, "  %s", err)
-			}
-		}
-
-	}
-
-	if optESCL != nil {
-		err = model.DownloadESCLScannerCapabilities(ctx, optESCL)
-		if err != nil {
-			err = fmt.Errorf(
-				"Can't get eSCL ScannerCapabilities: %s", err)
-			return err
-		}
+		return nil, err
 	}
 
-	if optWSD != nil {
-		err = model.DownloadWSDScannerCapabilities(ctx, optWSD)
-		if err != nil {
-			err = fmt.Errorf(
-				"Can't get WSD ScannerCapabilities: %s", err)
-			return err
-		}
+	err = model.Load(file)
+	if err != nil {
+		model.Close()
+		return nil, err
 	}
 
-	if haveUSB {
-		err = model.DownloadUSBDeviceDescriptor(ctx, optUSB)
-		if err != nil {
-			err = fmt.Errorf(
-				"Can't get USBDeviceDescripto: %s", err)
-			return err
-		}
-	}
+	return model, nil
+}
 
-	// Save model to file
-	file, _ := inv.Get("-m")
+// saveModel writes model to file. If file is "-", the model is
+// written to the standard output.
+func saveModel(model *modeling.Model, file string) error {
 	if file == "-" {
-		return model.Write(os.Stdout)
+		return model.Write(output)
 	}
 
 	return model.Save(file)
@@ -0,0 +1,223 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "model" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "model" command test
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/abstract"
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/modeling"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// testModelKyocera is the fixture model, used by tests
+var testModelKyocera = filepath.Join("..", "..", "..",
+	"modeling", "examples", "Kyocera-ECOSYS-M2040dn.py")
+
+// testRun runs the "model" command with the specified arguments
+// and returns its output.
+func testRun(t *testing.T, args ...string) (string, error) {
+	saveOutput := output
+	defer func() { output = saveOutput }()
+
+	buf := &bytes.Buffer{}
+	output = buf
+
+	err := Command.Run(context.Background(), args)
+	return buf.String(), err
+}
+
+// TestValidate tests the "validate" command
+func TestValidate(t *testing.T) {
+	// The fixture model must pass validation
+	_, err := testRun(t, "validate", testModelKyocera)
+	if err != nil {
+		t.Errorf("validate %s: %s", testModelKyocera, err)
+	}
+
+	// Empty model must not
+	empty := filepath.Join(t.TempDir(), "empty.py")
+	err = os.WriteFile(empty, []byte("# Empty model\n"), 0644)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	out, err := testRun(t, "validate", empty)
+	if err == nil {
+		t.Errorf("validate %s: error expected", empty)
+	}
+
+	expected := "error: model has no printer or scanner capabilities"
+	if !strings.Contains(out, expected) {
+		t.Errorf("validate %s: output mismatch:\n"+
+			"expected: %q\npresent:  %q", empty, expected, out)
+	}
+}
+
+// TestInstantiateDiff tests the "instantiate" and "diff" commands
+func TestInstantiateDiff(t *testing.T) {
+	dir := t.TempDir()
+	out1 := filepath.Join(dir, "out1.py")
+	out1Again := filepath.Join(dir, "out1-again.py")
+	out2 := filepath.Join(dir, "out2.py")
+
+	for _, args := range [][]string{
+		{"instantiate", testModelKyocera, "--seed", "1", "-o", out1},
+		{"instantiate", testModelKyocera, "--seed", "1", "-o", out1Again},
+		{"instantiate", testModelKyocera, "--seed", "2", "-o", out2},
+	} {
+		_, err := testRun(t, args...)
+		if err != nil {
+			t.Fatalf("%s: %s", strings.Join(args, " "), err)
+		}
+	}
+
+	// Instantiated model must be valid
+	_, err := testRun(t, "validate", out1)
+	if err != nil {
+		t.Errorf("validate %s: %s", out1, err)
+	}
+
+	// Model is equal to itself
+	diff, err := testRun(t, "diff", testModelKyocera, testModelKyocera)
+	if err != nil {
+		t.Errorf("diff: %s", err)
+	} else if diff != "" {
+		t.Errorf("diff: model differs from itself:\n%s", diff)
+	}
+
+	// The same seed must produce the same identity
+	diff, err = testRun(t, "diff", out1, out1Again)
+	if err != nil {
+		t.Errorf("diff: %s", err)
+	} else if diff != "" {
+		t.Errorf("diff: same seed, different models:\n%s", diff)
+	}
+
+	// The different seeds must produce different identity.
+	// Only identity must differ.
+	diff, err = testRun(t, "diff", out1, out2)
+	if err != nil {
+		t.Errorf("diff: %s", err)
+	}
+
+	for _, id := range []string{"SerialNumber", "Uuid", "iSerialNumber"} {
+		if !strings.Contains(diff, "-    "+id+" = ") ||
+			!strings.Contains(diff, "+    "+id+" = ") {
+			t.Errorf("diff: %s change expected:\n%s", id, diff)
+		}
+	}
+
+	for _, line := range strings.Split(diff, "\n") {
+		if !strings.HasPrefix(line, "-") &&
+			!strings.HasPrefix(line, "+") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "---") ||
+			strings.HasPrefix(line, "+++") {
+			continue
+		}
+
+		if !strings.Contains(line, "SerialNumber") &&
+			!strings.Contains(line, "Uuid") &&
+			!strings.Contains(line, "printer_device_id") &&
+			!strings.Contains(line, "urn:uuid:") {
+			t.Errorf("diff: unexpected change: %s", line)
+		}
+	}
+}
+
+// TestCapture tests the "capture" command against the in-process
+// fake printer and scanner.
+func TestCapture(t *testing.T) {
+	// Create fake devices out of the fixture model
+	model, err := modeling.NewModel()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	defer model.Close()
+
+	err = model.Load(testModelKyocera)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	scanner := &abstract.VirtualScanner{
+		ScanCaps: model.GetESCLScanCaps().ToAbstract(),
+		Resolution: abstract.Resolution{
+			XResolution: 600,
+			YResolution: 600,
+		},
+		PlatenImage: testutils.Images.PNG5100x7016,
+	}
+
+	mux := transport.NewPathMux()
+	mux.Add("/eSCL", model.NewESCLServer(scanner))
+	mux.Add("/ipp/print", model.NewIPPServer())
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// Capture the model
+	out := filepath.Join(t.TempDir(), "captured.py")
+	_, err = testRun(t, "capture",
+		"--printer", srv.URL+"/ipp/print",
+		"--scanner", srv.URL+"/eSCL",
+		"-o", out)
+
+	if err != nil {
+		t.Fatalf("capture: %s", err)
+	}
+
+	// Check the captured model
+	_, err = testRun(t, "validate", out)
+	if err != nil {
+		t.Errorf("validate %s: %s", out, err)
+	}
+
+	captured, err := modeling.NewModel()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	defer captured.Close()
+
+	err = captured.Load(out)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if captured.GetIPPPrinterAttrs() == nil {
+		t.Errorf("capture: IPP printer attributes missed")
+	}
+
+	caps := captured.GetESCLScanCaps()
+	if caps == nil {
+		t.Errorf("capture: eSCL scanner capabilities missed")
+	} else if optional.Get(caps.SerialNumber) !=
+		optional.Get(model.GetESCLScanCaps().SerialNumber) {
+		t.Errorf("capture: eSCL SerialNumber mismatch")
+	}
+
+	// No endpoints must be rejected
+	_, err = testRun(t, "capture", "-o", out)
+	if err == nil {
+		t.Errorf("capture without endpoints: error expected")
+	}
+}
@@ -0,0 +1,67 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "model" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "diff" command.
+
+package model
+
+import (
+	"context"
+	"io"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/modeling"
+)
+
+// cmdDiff defines the "diff" sub-command.
+var cmdDiff = argv.Command{
+	Name:    "diff",
+	Help:    "Compare two models",
+	Handler: cmdDiffHandler,
+	Options: []argv.Option{
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:     "old",
+			Help:     "old model file",
+			Complete: argv.CompleteOSPath,
+		},
+		{
+			Name:     "new",
+			Help:     "new model file",
+			Complete: argv.CompleteOSPath,
+		},
+	},
+}
+
+// cmdDiffHandler is the "diff" command handler.
+func cmdDiffHandler(ctx context.Context, inv *argv.Invocation) error {
+	oldFile := inv.ParamGet(0)
+	newFile := inv.ParamGet(1)
+
+	oldModel, err := loadModel(oldFile)
+	if err != nil {
+		return err
+	}
+
+	defer oldModel.Close()
+
+	newModel, err := loadModel(newFile)
+	if err != nil {
+		return err
+	}
+
+	defer newModel.Close()
+
+	diff, err := modeling.Diff(oldFile, oldModel, newFile, newModel)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(output, diff)
+	return err
+}
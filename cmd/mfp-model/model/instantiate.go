@@ -0,0 +1,63 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "model" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "instantiate" command.
+
+package model
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+)
+
+// cmdInstantiate defines the "instantiate" sub-command.
+var cmdInstantiate = argv.Command{
+	Name:    "instantiate",
+	Help:    "Make model instance with the new device identity",
+	Handler: cmdInstantiateHandler,
+	Options: []argv.Option{
+		argv.Option{
+			Name:      "--seed",
+			Help:      "seed for the identity (UUID and serial) generation",
+			HelpArg:   "N",
+			Required:  true,
+			Singleton: true,
+			Validate:  argv.ValidateInt64,
+		},
+		optOutput,
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:     "model",
+			Help:     "model file",
+			Complete: argv.CompleteOSPath,
+		},
+	},
+}
+
+// cmdInstantiateHandler is the "instantiate" command handler.
+func cmdInstantiateHandler(ctx context.Context, inv *argv.Invocation) error {
+	optSeed, _ := inv.Get("--seed")
+	seed, _ := strconv.ParseInt(optSeed, 0, 64)
+
+	model, err := loadModel(inv.ParamGet(0))
+	if err != nil {
+		return err
+	}
+
+	defer model.Close()
+
+	err = model.Instantiate(seed)
+	if err != nil {
+		return err
+	}
+
+	file, _ := inv.Get("-o")
+	return saveModel(model, file)
+}
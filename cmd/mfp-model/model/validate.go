@@ -0,0 +1,57 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "model" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The "validate" command.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/OpenPrinting/go-mfp/argv"
+)
+
+// cmdValidate defines the "validate" sub-command.
+var cmdValidate = argv.Command{
+	Name:    "validate",
+	Help:    "Validate existent model",
+	Handler: cmdValidateHandler,
+	Options: []argv.Option{
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name:     "model",
+			Help:     "model file",
+			Complete: argv.CompleteOSPath,
+		},
+	},
+}
+
+// cmdValidateHandler is the "validate" command handler.
+func cmdValidateHandler(ctx context.Context, inv *argv.Invocation) error {
+	file := inv.ParamGet(0)
+
+	model, err := loadModel(file)
+	if err != nil {
+		return err
+	}
+
+	defer model.Close()
+
+	issues := model.Validate()
+	for _, issue := range issues {
+		fmt.Fprintf(output, "%s: %s\n", file, issue)
+	}
+
+	if issues.HasErrors() {
+		return errors.New("model has errors")
+	}
+
+	return nil
+}
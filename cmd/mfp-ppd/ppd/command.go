@@ -12,6 +12,7 @@ import (
 	"context"
 
 	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/internal/env"
 	"github.com/OpenPrinting/go-mfp/log"
 )
 
@@ -20,16 +21,8 @@ var Command = argv.Command{
 	Name: "ppd",
 	Help: "Utility for PPD files",
 	Options: []argv.Option{
-		argv.Option{
-			Name:    "-d",
-			Aliases: []string{"--debug"},
-			Help:    "Enable debug output",
-		},
-		argv.Option{
-			Name:    "-v",
-			Aliases: []string{"--verbose"},
-			Help:    "Enable verbose debug output",
-		},
+		env.OptDebug,
+		env.OptVerbose,
 		argv.HelpOption,
 	},
 	SubCommands: []argv.Command{
@@ -42,16 +35,7 @@ var Command = argv.Command{
 // cmdPpdHandler is the top-level handler for the 'ppd' command.
 func cmdPpdHandler(ctx context.Context, inv *argv.Invocation) error {
 	// Setup logging
-	_, dbg := inv.Get("-d")
-	_, vrb := inv.Get("-v")
-
-	level := log.LevelInfo
-	if dbg {
-		level = log.LevelDebug
-	}
-	if vrb {
-		level = log.LevelTrace
-	}
+	level := env.LogLevel(inv)
 
 	logger := log.NewLogger(level, log.Console)
 	ctx = log.NewContext(ctx, logger)
@@ -13,7 +13,9 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/OpenPrinting/go-mfp/argv"
 	"github.com/OpenPrinting/go-mfp/internal/assert"
@@ -28,6 +30,17 @@ import (
 // DefaultTCPPort is the default TCP port for the MFP proxy
 const DefaultTCPPort = 50000
 
+// Log file parameters (see log.NewFileBackendFormat and log.SinkSpec)
+const (
+	logFileMaxSize = 16 * 1024 * 1024 // Max size before rotation
+	logFileBackups = 4                // Count of backup files
+	logFileBuffer  = 1024             // Max records queued to file
+)
+
+// DefaultReplayMissStatus is the default HTTP status for requests
+// that don't match any record in the replay mode
+const DefaultReplayMissStatus = http.StatusNotImplemented
+
 // description is printed as a command description text
 const description = "" +
 	"This command runs the IPP/eSCL/WSD proxy\n" +
@@ -37,30 +50,174 @@ const description = "" +
 	"  - to logically bring the device into the different IP address\n" +
 	"    or port\n" +
 	"\n" +
+	"The \"run\" sub-command forwards requests to the target\n" +
+	"devices. The \"replay\" sub-command answers requests\n" +
+	"by replaying the recorded trace, without the devices.\n" +
+	"\n" +
+	"Options, common for all sub-commands, are accepted both\n" +
+	"before and after the sub-command name.\n"
+
+// runDescription is printed as the "run" sub-command description text
+const runDescription = "" +
+	"This command runs the proxy, that forwards requests to\n" +
+	"the target devices.\n" +
+	"\n" +
 	"If optional command is specified, the CUPS_SERVER and the\n" +
 	"SANE_AIRSCAN_DEVICE environment variables will be set properly\n" +
 	"and the command will be executed, The proxy  will exit when\n" +
 	"the command finished.\n" +
 	"\n" +
 	"Without that the proxy  will run until termination signal\n" +
-	"is received.\n"
+	"is received.\n" +
+	"\n" +
+	"On exit, the proxy waits for the in-flight requests to\n" +
+	"complete before closing the trace. Requests that don't\n" +
+	"complete within the grace period are aborted and marked\n" +
+	"in the trace. The count of the in-flight requests is\n" +
+	"available at " + drainStatusPath + ".\n" +
+	"\n" +
+	"The state of the IPP targets is polled periodically, as set\n" +
+	"by the --watch-interval option, and is also reported at\n" +
+	drainStatusPath + ". Zero interval disables polling.\n" +
+	"\n" +
+	"With the --trace-dir option, the trace is written into the\n" +
+	"directory, file per message, with the index.jsonl file that\n" +
+	"lists exchanges and their files. Files appear under their\n" +
+	"final names only when completely written.\n"
+
+// replayDescription is printed as the "replay" sub-command
+// description text
+const replayDescription = "" +
+	"This command runs the proxy, that doesn't contact the\n" +
+	"target devices. Instead, it answers requests by replaying\n" +
+	"responses, recorded with the --trace option of the \"run\"\n" +
+	"sub-command. The trace may be either the .tar file or the\n" +
+	"directory.\n" +
+	"\n" +
+	"Target URLs in mappings must be the same as used during\n" +
+	"recording. Requests that don't match any record are\n" +
+	"rejected with the diagnostic message.\n" +
+	"\n" +
+	"If optional command is specified, it is executed the same\n" +
+	"way, as by the \"run\" sub-command.\n"
 
 // Command is the 'proxy' command description
 var Command = argv.Command{
-	Name:                     "proxy",
-	Help:                     "IPP/eSCL/WSD masquerading proxy",
-	Description:              description,
-	NoOptionsAfterParameters: true,
+	Name:        "proxy",
+	Help:        "IPP/eSCL/WSD masquerading proxy",
+	Description: description,
 	Options: []argv.Option{
 		argv.Option{
 			Name:    "-P",
 			Aliases: []string{"--port"},
 			Help: fmt.Sprintf("TCP port. Default: %d",
 				DefaultTCPPort),
-			HelpArg:   "port",
-			Singleton: true,
-			Validate:  argv.ValidateUint16,
+			HelpArg:    "port",
+			Singleton:  true,
+			Validate:   argv.ValidateUint16,
+			Persistent: true,
 		},
+		argv.Option{
+			Name:       "-E",
+			Aliases:    []string{"--escl"},
+			Help:       "Forward eSCL requests from local path to url",
+			HelpArg:    "path=url",
+			Validate:   validateMapping,
+			Persistent: true,
+		},
+		argv.Option{
+			Name:       "-I",
+			Aliases:    []string{"--ipp"},
+			Help:       "Forward IPP requests from local path to url",
+			HelpArg:    "path=url",
+			Validate:   validateMapping,
+			Persistent: true,
+		},
+		argv.Option{
+			Name:       "-W",
+			Aliases:    []string{"--wsd"},
+			Help:       "Forward WSD requests from local path to url",
+			HelpArg:    "path=url",
+			Validate:   validateMapping,
+			Persistent: true,
+		},
+		argv.Option{
+			Name: "--tls-policy",
+			Help: "TLS policy for all or the specific mapping:\n" +
+				"modern (default), intermediate or legacy-printer",
+			HelpArg:    "[path=]policy",
+			Validate:   validateTLSPolicy,
+			Persistent: true,
+		},
+		argv.Option{
+			Name:       "-t",
+			Aliases:    []string{"--trace"},
+			Help:       "write trace to file.log and file.tar",
+			HelpArg:    "file",
+			Validate:   argv.ValidateAny,
+			Complete:   argv.CompleteOSPath,
+			Persistent: true,
+		},
+		argv.Option{
+			Name: "--trace-dir",
+			Help: "write trace into directory file/ instead of\n" +
+				"file.tar, with the index.jsonl index",
+			Singleton:  true,
+			Requires:   []string{"--trace"},
+			Persistent: true,
+		},
+		argv.Option{
+			Name: "--trace-durable",
+			Help: "sync also document data of the directory trace\n" +
+				"to disk (slow)",
+			Singleton:  true,
+			Requires:   []string{"--trace-dir"},
+			Persistent: true,
+		},
+		argv.Option{
+			Name:       "--log-file",
+			Help:       "write debug log to file",
+			HelpArg:    "file",
+			Singleton:  true,
+			Validate:   argv.ValidateAny,
+			Complete:   argv.CompleteOSPath,
+			Persistent: true,
+		},
+		argv.Option{
+			Name: "--log-relative",
+			Help: "time-stamp logs with time since start (+12.345s),\n" +
+				"instead of wall clock",
+			Singleton:  true,
+			Persistent: true,
+		},
+		argv.Option{
+			Name: "--crash-dir",
+			Help: "on panic, write stacks of all goroutines\n" +
+				"into the crash-*.txt file in this directory",
+			HelpArg:    "dir",
+			Singleton:  true,
+			Validate:   argv.ValidateAny,
+			Complete:   argv.CompleteOSPath,
+			Persistent: true,
+		},
+		env.OptDebug,
+		env.OptVerbose,
+		argv.HelpOption,
+	},
+	SubCommands: []argv.Command{
+		cmdRun,
+		cmdReplay,
+		argv.HelpCommand,
+	},
+}
+
+// cmdRun defines the "run" sub-command.
+var cmdRun = argv.Command{
+	Name:                     "run",
+	Help:                     "Forward requests to the target devices",
+	Description:              runDescription,
+	NoOptionsAfterParameters: true,
+	Options: []argv.Option{
 		argv.Option{
 			Name:      "-U",
 			Aliases:   []string{"--usbip"},
@@ -69,47 +226,63 @@ var Command = argv.Command{
 			Conflicts: []string{"-P"},
 		},
 		argv.Option{
-			Name:     "-E",
-			Aliases:  []string{"--escl"},
-			Help:     "Forward eSCL requests from local path to url",
-			HelpArg:  "path=url",
-			Validate: validateMapping,
+			Name: "--watch-interval",
+			Help: fmt.Sprintf("IPP targets polling interval. Default: %d",
+				DefaultWatchInterval/time.Second),
+			HelpArg:   "seconds",
+			Singleton: true,
+			Validate:  argv.ValidateUintRange(10, 0, 86400),
 		},
 		argv.Option{
-			Name:     "-I",
-			Aliases:  []string{"--ipp"},
-			Help:     "Forward IPP requests from local path to url",
-			HelpArg:  "path=url",
-			Validate: validateMapping,
+			Name:      "--upgrade",
+			Help:      "on SIGUSR2, restart without dropping connections",
+			Singleton: true,
+			Conflicts: []string{"-U"},
 		},
-		argv.Option{
-			Name:     "-W",
-			Aliases:  []string{"--wsd"},
-			Help:     "Forward WSD requests from local path to url",
-			HelpArg:  "path=url",
-			Validate: validateMapping,
+		argv.HelpOption,
+	},
+	Parameters: []argv.Parameter{
+		{
+			Name: "[command]",
+			Help: "command to run under the proxy",
 		},
-		argv.Option{
-			Name:     "-t",
-			Aliases:  []string{"--trace"},
-			Help:     "write trace to file.log and file.tar",
-			HelpArg:  "file",
-			Validate: argv.ValidateAny,
-			Complete: argv.CompleteOSPath,
+		{
+			Name: "[args...]",
+			Help: "the command's arguments",
 		},
+	},
+	Handler: cmdProxyHandler,
+}
+
+// cmdReplay defines the "replay" sub-command.
+var cmdReplay = argv.Command{
+	Name:                     "replay",
+	Help:                     "Replay recorded trace instead of forwarding",
+	Description:              replayDescription,
+	NoOptionsAfterParameters: true,
+	Options: []argv.Option{
 		argv.Option{
-			Name:    "-d",
-			Aliases: []string{"--debug"},
-			Help:    "Enable debug output",
+			Name:     "--replay-match",
+			Help:     "replay requests matching: normal, strict or loose",
+			HelpArg:  "mode",
+			Validate: argv.ValidateStrings(replayMatchNames),
+			Complete: argv.CompleteStrings(replayMatchNames),
 		},
 		argv.Option{
-			Name:    "-v",
-			Aliases: []string{"--verbose"},
-			Help:    "Enable verbose debug output",
+			Name: "--replay-miss-status",
+			Help: fmt.Sprintf("HTTP status for unmatched requests. Default: %d",
+				DefaultReplayMissStatus),
+			HelpArg:  "status",
+			Validate: argv.ValidateUintRange(10, 100, 599),
 		},
 		argv.HelpOption,
 	},
 	Parameters: []argv.Parameter{
+		{
+			Name:     "trace",
+			Help:     "recorded trace (.tar file or directory)",
+			Complete: argv.CompleteOSPath,
+		},
 		{
 			Name: "[command]",
 			Help: "command to run under the proxy",
@@ -122,26 +295,56 @@ var Command = argv.Command{
 	Handler: cmdProxyHandler,
 }
 
-// cmdProxyHandler is the top-level handler for the 'proxy' command.
+// cmdProxyHandler is the handler for the "run" and "replay"
+// sub-commands.
+//
+// Both sub-commands run the proxy server the same way. The
+// "replay" sub-command has the trace parameter, and its mappings
+// are served by replaying the trace.
 func cmdProxyHandler(ctx context.Context, inv *argv.Invocation) error {
 	// Setup logging
-	_, dbg := inv.Get("-d")
-	_, vrb := inv.Get("-v")
+	level := env.LogLevel(inv)
 
-	level := log.LevelInfo
-	if dbg {
-		level = log.LevelDebug
+	console, fileTime := log.Console, log.TimeWall
+	if _, rel := inv.Get("--log-relative"); rel {
+		console = log.NewConsoleBackend(log.TimeRelative)
+		fileTime = log.TimeRelative
 	}
-	if vrb {
-		level = log.LevelTrace
+
+	sinks := []log.SinkSpec{{Level: level, Backend: console}}
+	if logFile, _ := inv.Get("--log-file"); logFile != "" {
+		sinks = append(sinks, log.SinkSpec{
+			Level: log.LevelDebug,
+			Backend: log.NewFileBackendFormat(logFile,
+				logFileMaxSize, logFileBackups, fileTime),
+			Buffer: logFileBuffer,
+		})
 	}
 
-	logger := log.NewLogger(level, log.Console)
+	logger := log.NewLoggerMulti(sinks...)
+	defer logger.Close()
+
 	ctx = log.NewContext(ctx, logger)
 
+	if dir, _ := inv.Get("--crash-dir"); dir != "" {
+		log.SetRecoverOptions(log.RecoverOptions{
+			AllGoroutines: true,
+			CrashDir:      dir,
+		})
+	}
+
 	// Setup trace
 	if traceName, _ := inv.Get("-t"); traceName != "" {
-		tracer, err := trace.NewWriter(ctx, traceName)
+		var tracer *trace.Writer
+		var err error
+
+		if inv.Flag("--trace-dir") {
+			tracer, err = trace.NewDirWriter(ctx, traceName,
+				inv.Flag("--trace-durable"))
+		} else {
+			tracer, err = trace.NewWriter(ctx, traceName)
+		}
+
 		if err != nil {
 			return err
 		}
@@ -190,11 +393,66 @@ func cmdProxyHandler(ctx context.Context, inv *argv.Invocation) error {
 		return err
 	}
 
+	// Apply TLS policies
+	for _, opt := range inv.Values("--tls-policy") {
+		path, policy, err := parseTLSPolicy(opt)
+		assert.NoError(err)
+
+		found := false
+		for i := range mappings {
+			if path == "" || mappings[i].localPath == path {
+				mappings[i].tlsPolicy = policy
+				found = true
+			}
+		}
+
+		if !found {
+			err = fmt.Errorf("--tls-policy: %q: no such mapping",
+				path)
+			return err
+		}
+	}
+
+	// Load replay trace
+	var replay []*replayRecord
+	replayMatch := replayNormal
+	replayMissStatus := DefaultReplayMissStatus
+
+	if replayName, ok := inv.Get("trace"); ok {
+		var err error
+		replay, err = loadReplay(replayName)
+		if err != nil {
+			return err
+		}
+
+		if name, ok := inv.Get("--replay-match"); ok {
+			replayMatch, err = parseReplayMatch(name)
+			assert.NoError(err)
+		}
+
+		if status, ok := inv.Get("--replay-miss-status"); ok {
+			replayMissStatus, err = strconv.Atoi(status)
+			assert.NoError(err)
+		}
+
+		log.Info(ctx, "replaying %d records from %s",
+			len(replay), replayName)
+	}
+
+	watchInterval := DefaultWatchInterval
+	if seconds, ok := inv.Get("--watch-interval"); ok {
+		n, err := strconv.Atoi(seconds)
+		assert.NoError(err)
+		watchInterval = time.Duration(n) * time.Second
+	}
+
 	// Create and populate the PathMux
 	runner := env.Runner{
 		ESCLName: "Virtual MFP Scanner",
 	}
 
+	var watches []*printerWatch
+
 	mux := transport.NewPathMux()
 	for _, m := range mappings {
 		if mux.Contains(m.localPath) {
@@ -205,14 +463,35 @@ func cmdProxyHandler(ctx context.Context, inv *argv.Invocation) error {
 
 		switch m.proto {
 		case protoIPP:
-			proxy := ipp.NewProxy(m.localPath, m.targetURL)
-			mux.Add(m.localPath, proxy)
+			var handler http.Handler
+			if replay != nil {
+				handler = newReplayer(m, replay,
+					replayMatch, replayMissStatus)
+			} else {
+				proxy := ipp.NewProxy(m.localPath, m.targetURL)
+				proxy.SetTransport(m.newTransport())
+				handler = proxy
+
+				if watchInterval > 0 {
+					watches = append(watches,
+						newPrinterWatch(m))
+				}
+			}
+			mux.Add(m.localPath, handler)
 
 			runner.CUPSPort = portnum
 
 		case protoESCL:
-			proxy := escl.NewProxy(m.localPath, m.targetURL)
-			mux.Add(m.localPath, proxy)
+			var handler http.Handler
+			if replay != nil {
+				handler = newReplayer(m, replay,
+					replayMatch, replayMissStatus)
+			} else {
+				proxy := escl.NewProxy(m.localPath, m.targetURL)
+				proxy.SetTransport(m.newTransport())
+				handler = proxy
+			}
+			mux.Add(m.localPath, handler)
 
 			runner.ESCLPort = portnum
 			runner.ESCLPath = m.localPath
@@ -222,19 +501,59 @@ func cmdProxyHandler(ctx context.Context, inv *argv.Invocation) error {
 		}
 	}
 
+	// In-flight requests are tracked, so they can be drained
+	// on exit before the trace is closed.
+	drain := newDrainer(mux)
+	drain.watches = watches
+
+	for _, pw := range watches {
+		go pw.run(ctx, watchInterval)
+	}
+
 	// Create server for incoming connections.
+	upgraded := make(chan struct{})
 	if !inv.Flag("-U") {
-		l, err := newListener(ctx, portnum)
+		inherited, err := transport.InheritListeners()
+		if err != nil {
+			return err
+		}
+
+		l, err := newListener(ctx, portnum, inherited)
+		if err != nil {
+			return err
+		}
+
+		// Requests are not canceled by the termination signal.
+		// Instead, they are given the grace period to complete
+		// (see proxyShutdown).
+		srvr := transport.NewServer(context.WithoutCancel(ctx),
+			nil, drain)
+
+		// If started by the previous instance, wait until
+		// it passes the ownership of the listener
+		err = transport.HandoffReady(ctx)
 		if err != nil {
+			l.Close()
 			return err
 		}
 
-		srvr := transport.NewServer(ctx, nil, mux)
 		log.Info(ctx, "starting MFP proxy at http://localhost:%d",
 			portnum)
 		go srvr.Serve(l)
 
-		defer srvr.Close()
+		defer proxyShutdown(ctx, srvr, drain, DefaultShutdownGrace)
+
+		if inv.Flag("--upgrade") {
+			go func() {
+				err := upgradeWait(ctx, srvr)
+				switch {
+				case err == nil:
+					close(upgraded)
+				case ctx.Err() == nil:
+					log.Error(ctx, "%s", err)
+				}
+			}()
+		}
 	} else {
 		addr := &net.TCPAddr{
 			IP:   net.IPv4(127, 0, 0, 1),
@@ -242,7 +561,7 @@ func cmdProxyHandler(ctx context.Context, inv *argv.Invocation) error {
 		}
 
 		log.Info(ctx, "starting USBIP server at %s", addr)
-		newUsbipServer(ctx, addr, mux)
+		newUsbipServer(ctx, addr, drain)
 	}
 
 	// Run external program if requested
@@ -251,8 +570,12 @@ func cmdProxyHandler(ctx context.Context, inv *argv.Invocation) error {
 		return runner.Run(ctx, command, argv...)
 	}
 
-	// Wait for termination signal
-	<-ctx.Done()
+	// Wait for termination signal or upgrade completion
+	select {
+	case <-ctx.Done():
+	case <-upgraded:
+	}
+
 	log.Info(ctx, "Exiting...")
 
 	return nil
@@ -0,0 +1,103 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "proxy" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Command description test
+
+package proxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestCommand tests parsing of the proxy sub-commands
+func TestCommand(t *testing.T) {
+	err := Command.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %s", err)
+	}
+
+	type testData struct {
+		argv    []string          // Command line
+		subcmd  string            // Expected sub-command
+		options map[string]string // Expected options
+		params  []string          // Expected parameters
+	}
+
+	tests := []testData{
+		{
+			argv: []string{"-I", "/ipp=http://printer/ipp",
+				"run", "-P", "60000", "lpstat", "-t"},
+			subcmd: "run",
+			options: map[string]string{
+				"-I": "/ipp=http://printer/ipp",
+				"-P": "60000",
+			},
+			params: []string{"lpstat", "-t"},
+		},
+
+		{
+			argv: []string{"replay", "-I", "/ipp=http://printer/ipp",
+				"--replay-match", "loose", "trace.tar"},
+			subcmd: "replay",
+			options: map[string]string{
+				"-I":             "/ipp=http://printer/ipp",
+				"--replay-match": "loose",
+			},
+			params: []string{"trace.tar"},
+		},
+
+		{
+			argv:   []string{"replay", "traces/session", "lpstat"},
+			subcmd: "replay",
+			params: []string{"traces/session", "lpstat"},
+		},
+	}
+
+	for _, test := range tests {
+		inv, err := Command.Parse(test.argv)
+		if err == nil {
+			inv, err = inv.SubInvocation()
+		}
+
+		if err != nil {
+			t.Errorf("%q: %s", test.argv, err)
+			continue
+		}
+
+		if name := inv.Cmd().Name; name != test.subcmd {
+			t.Errorf("%q: sub-command expected %q, present %q",
+				test.argv, test.subcmd, name)
+		}
+
+		for opt, expected := range test.options {
+			if val, _ := inv.Get(opt); val != expected {
+				t.Errorf("%q: %s expected %q, present %q",
+					test.argv, opt, expected, val)
+			}
+		}
+
+		var params []string
+		for i := 0; i < inv.ParamCount(); i++ {
+			params = append(params, inv.ParamGet(i))
+		}
+
+		if !reflect.DeepEqual(params, test.params) {
+			t.Errorf("%q: parameters expected %q, present %q",
+				test.argv, test.params, params)
+		}
+	}
+
+	// The trace parameter of the "replay" sub-command is required
+	inv, err := Command.Parse([]string{"replay", "-P", "60000"})
+	if err == nil {
+		_, err = inv.SubInvocation()
+	}
+
+	if err == nil {
+		t.Errorf("replay without trace: error expected")
+	}
+}
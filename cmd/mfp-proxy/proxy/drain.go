@@ -0,0 +1,193 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "proxy" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// In-flight requests tracking and draining
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/log/trace"
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// DefaultShutdownGrace is the default time, given to the in-flight
+// requests to complete on the proxy shutdown. Requests that don't
+// complete in time are aborted.
+const DefaultShutdownGrace = 10 * time.Second
+
+// drainStatusPath is the path of the proxy status endpoint.
+// Requests to this path are not counted as in-flight.
+const drainStatusPath = "/mfp-proxy/status"
+
+// drainAbortedFile is the name of the trace file, written into
+// the directory of the request, aborted on shutdown. Its presence
+// means that other files of the request may be incomplete.
+const drainAbortedFile = "aborted.txt"
+
+// drainer wraps the proxy http.Handler and tracks the in-flight
+// requests, so the proxy shutdown can wait for them to complete.
+type drainer struct {
+	handler  http.Handler             // Underlying handler
+	inflight sync.WaitGroup           // Running handlers
+	seq      atomic.Uint64            // Request sequence numbers
+	served   atomic.Uint64            // Completed requests counter
+	active   map[*http.Request]uint64 // Running requests, by seq
+	lock     sync.Mutex               // Access lock
+	start    time.Time                // Start time, for status
+	watches  []*printerWatch          // IPP targets, for status
+}
+
+// newDrainer creates a new drainer.
+func newDrainer(handler http.Handler) *drainer {
+	return &drainer{
+		handler: handler,
+		active:  make(map[*http.Request]uint64),
+		start:   time.Now(),
+	}
+}
+
+// ServeHTTP handles incoming HTTP requests.
+// It implements [http.Handler] interface.
+func (d *drainer) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
+	if rq.URL.Path == drainStatusPath {
+		d.serveStatus(w, rq)
+		return
+	}
+
+	// Assign the request sequence number here, so we know
+	// the trace directory of the request, if we need to
+	// mark it as aborted.
+	seq := d.seq.Add(1)
+	rq = rq.WithContext(log.WithRequestSeq(rq.Context(), seq))
+
+	d.lock.Lock()
+	d.inflight.Add(1)
+	d.active[rq] = seq
+	d.lock.Unlock()
+
+	defer func() {
+		d.lock.Lock()
+		delete(d.active, rq)
+		d.lock.Unlock()
+
+		d.served.Add(1)
+		d.inflight.Done()
+	}()
+
+	d.handler.ServeHTTP(w, rq)
+}
+
+// InFlight returns count of the in-flight requests.
+func (d *drainer) InFlight() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return len(d.active)
+}
+
+// serveStatus serves the status endpoint.
+func (d *drainer) serveStatus(w http.ResponseWriter, rq *http.Request) {
+	if rq.Method != "GET" && rq.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed",
+			http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(w, "in-flight: %d\n", d.InFlight())
+	fmt.Fprintf(w, "served:    %d\n", d.served.Load())
+	fmt.Fprintf(w, "uptime:    %s\n",
+		time.Since(d.start).Truncate(time.Second))
+
+	for _, pw := range d.watches {
+		fmt.Fprintf(w, "%s\n", pw)
+	}
+}
+
+// wait waits until all in-flight requests are completed.
+func (d *drainer) wait() {
+	d.inflight.Wait()
+}
+
+// markAborted marks all in-flight requests as aborted in
+// the protocol trace, if tracing is enabled, and logs them.
+// It returns count of the aborted requests.
+func (d *drainer) markAborted(ctx context.Context) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for rq, seq := range d.active {
+		name := log.RequestSeqString(seq)
+		msg := fmt.Sprintf("%s %s: aborted on shutdown\n",
+			rq.Method, rq.URL)
+
+		log.Info(ctx, "%s: %s", name, msg[:len(msg)-1])
+
+		if tracer := trace.CtxWriter(rq.Context()); tracer != nil {
+			tracer.Send(name+"/"+drainAbortedFile, []byte(msg))
+		}
+	}
+
+	return len(d.active)
+}
+
+// proxyShutdown gracefully shuts the proxy server down.
+//
+// It stops accepting new connections and waits up to the grace
+// period for the in-flight requests to complete. Then remaining
+// requests are aborted and marked so in the protocol trace.
+//
+// When proxyShutdown returns, all request handlers are returned,
+// so the trace writer can be safely closed. It returns count of
+// the aborted requests.
+func proxyShutdown(ctx context.Context, srvr *transport.Server,
+	d *drainer, grace time.Duration) int {
+
+	gctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	if n := d.InFlight(); n != 0 {
+		log.Info(ctx, "waiting for %d in-flight requests", n)
+	}
+
+	// Shutdown waits for active connections, but not for the
+	// handlers of the hijacked ones, so wait for handlers as well.
+	aborted := 0
+	err := srvr.Shutdown(gctx)
+	if err == nil {
+		done := make(chan struct{})
+		go func() {
+			d.wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-gctx.Done():
+			err = gctx.Err()
+		}
+	}
+
+	if err != nil {
+		aborted = d.markAborted(ctx)
+	}
+
+	// Close aborts remaining connections; then handlers will
+	// return shortly.
+	srvr.Close()
+	d.wait()
+
+	return aborted
+}
@@ -0,0 +1,338 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "proxy" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// In-flight requests draining test
+
+package proxy
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/log/trace"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/goipp"
+)
+
+// testDrainChunk is the size of each of two response body chunks,
+// sent by the slow upstream
+const testDrainChunk = 64 * 1024
+
+// testDrainEnv is the test environment: the slow upstream, the
+// proxy with the tracer and the client request in progress.
+type testDrainEnv struct {
+	name     string            // Trace name
+	srvr     *transport.Server // Proxy server
+	drain    *drainer          // Proxy drainer
+	tracer   *trace.Writer     // Proxy tracer
+	body     []byte            // Expected response body
+	release  chan struct{}     // Close to send the second chunk
+	received chan error        // Client result
+}
+
+// newTestDrainEnv creates the testDrainEnv and starts the request.
+// When it returns, the request is in flight: upstream has sent
+// the first chunk of the response body and waits for release.
+func newTestDrainEnv(t *testing.T) *testDrainEnv {
+	env := &testDrainEnv{
+		name:     filepath.Join(t.TempDir(), "trace"),
+		release:  make(chan struct{}),
+		received: make(chan error, 1),
+	}
+
+	env.body = make([]byte, 2*testDrainChunk)
+	for i := range env.body {
+		env.body[i] = byte(i * 7)
+	}
+
+	// Create slow upstream
+	started := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			io.Copy(io.Discard, rq.Body)
+
+			rsp := goipp.NewResponse(goipp.DefaultVersion,
+				goipp.StatusOk, 1)
+			rsp.Operation.Add(goipp.MakeAttribute(
+				"attributes-charset",
+				goipp.TagCharset, goipp.String("utf-8")))
+			rsp.Operation.Add(goipp.MakeAttribute(
+				"attributes-natural-language",
+				goipp.TagLanguage, goipp.String("en-us")))
+
+			msg, _ := rsp.EncodeBytes()
+
+			w.Header().Set("Content-Type", "application/ipp")
+			w.Write(msg)
+			w.Write(env.body[:testDrainChunk])
+			w.(http.Flusher).Flush()
+			close(started)
+
+			select {
+			case <-env.release:
+				w.Write(env.body[testDrainChunk:])
+			case <-rq.Context().Done():
+			}
+		}))
+
+	t.Cleanup(func() {
+		select {
+		case <-env.release:
+		default:
+			close(env.release)
+		}
+		upstream.Close()
+	})
+
+	// Create proxy
+	m, err := parseMapping(protoIPP,
+		"/ipp/print="+upstream.URL+"/ipp/print")
+	if err != nil {
+		t.Fatalf("parseMapping: %s", err)
+	}
+
+	logger := log.NewLogger(log.LevelError, log.Console)
+	ctx := log.NewContext(context.Background(), logger)
+
+	env.tracer, err = trace.NewWriter(ctx, env.name)
+	if err != nil {
+		t.Fatalf("trace.NewWriter: %s", err)
+	}
+
+	ctx = trace.NewContext(ctx, env.tracer)
+
+	env.drain = newDrainer(ipp.NewProxy(m.localPath, m.targetURL))
+	var u string
+	env.srvr, u = testDrainServe(t, ctx, env.drain)
+
+	// Start the request
+	rq := goipp.NewRequest(goipp.DefaultVersion,
+		goipp.OpGetPrinterAttributes, 1)
+	rq.Operation.Add(goipp.MakeAttribute("attributes-charset",
+		goipp.TagCharset, goipp.String("utf-8")))
+	rq.Operation.Add(goipp.MakeAttribute("attributes-natural-language",
+		goipp.TagLanguage, goipp.String("en-us")))
+	rq.Operation.Add(goipp.MakeAttribute("printer-uri",
+		goipp.TagURI, goipp.String(u)))
+
+	data, _ := rq.EncodeBytes()
+
+	go func() {
+		rsp, err := http.Post(u, "application/ipp",
+			bytes.NewReader(data))
+		if err != nil {
+			env.received <- err
+			return
+		}
+
+		defer rsp.Body.Close()
+
+		var msg goipp.Message
+		err = msg.Decode(rsp.Body)
+		if err != nil {
+			env.received <- err
+			return
+		}
+
+		body, err := io.ReadAll(rsp.Body)
+		if err == nil && !bytes.Equal(body, env.body) {
+			err = io.ErrUnexpectedEOF
+		}
+		env.received <- err
+	}()
+
+	select {
+	case <-started:
+	case err := <-env.received:
+		t.Fatalf("request failed: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("request not started")
+	}
+
+	return env
+}
+
+// testDrainServe starts the proxy server
+func testDrainServe(t *testing.T, ctx context.Context,
+	handler http.Handler) (*transport.Server, string) {
+
+	srvr, u := testReplayServe(t, ctx, "", handler)
+	return srvr, u.String()
+}
+
+// traceFiles returns the trace files, closing the tracer.
+func (env *testDrainEnv) traceFiles(t *testing.T) map[string][]byte {
+	env.tracer.Close()
+
+	fp, err := os.Open(env.name + ".tar")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer fp.Close()
+
+	files := make(map[string][]byte)
+	rd := tar.NewReader(fp)
+	for {
+		hdr, err := rd.Next()
+		if err == io.EOF {
+			return files
+		} else if err != nil {
+			t.Fatalf("trace: %s", err)
+		}
+
+		data, _ := io.ReadAll(rd)
+		files[hdr.Name] = data
+	}
+}
+
+// testDrainRspBody returns the traced response message with body,
+// if any
+func testDrainRspBody(files map[string][]byte) ([]byte, bool) {
+	for name, data := range files {
+		base := path.Base(name)
+		ext := path.Ext(base)
+		if strings.HasPrefix(base, "rsp-") &&
+			ext != ".ipp" && ext != ".http" {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// TestDrainComplete tests that proxy shutdown waits for the
+// in-flight request and the trace is complete.
+func TestDrainComplete(t *testing.T) {
+	env := newTestDrainEnv(t)
+
+	if n := env.drain.InFlight(); n != 1 {
+		t.Errorf("InFlight: expected 1, present %d", n)
+	}
+
+	done := make(chan int)
+	go func() {
+		done <- proxyShutdown(context.Background(), env.srvr,
+			env.drain, 10*time.Second)
+	}()
+
+	// Let Shutdown start, then let upstream to finish
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatalf("proxyShutdown didn't wait for in-flight request")
+	default:
+	}
+
+	close(env.release)
+
+	if aborted := <-done; aborted != 0 {
+		t.Errorf("aborted: expected 0, present %d", aborted)
+	}
+
+	if err := <-env.received; err != nil {
+		t.Errorf("client: %s", err)
+	}
+
+	if n := env.drain.InFlight(); n != 0 {
+		t.Errorf("InFlight after shutdown: %d", n)
+	}
+
+	files := env.traceFiles(t)
+	body, ok := testDrainRspBody(files)
+	switch {
+	case !ok:
+		t.Errorf("trace: response body not found")
+	case !bytes.HasSuffix(body, env.body):
+		t.Errorf("trace: response body truncated: %d bytes",
+			len(body))
+	}
+
+	for name := range files {
+		if path.Base(name) == drainAbortedFile {
+			t.Errorf("trace: unexpected %s", name)
+		}
+	}
+}
+
+// TestDrainAbort tests that request, not completed within the
+// grace period, is aborted and marked so in the trace.
+func TestDrainAbort(t *testing.T) {
+	env := newTestDrainEnv(t)
+
+	const grace = 200 * time.Millisecond
+	start := time.Now()
+	aborted := proxyShutdown(context.Background(), env.srvr,
+		env.drain, grace)
+	elapsed := time.Since(start)
+
+	if aborted != 1 {
+		t.Errorf("aborted: expected 1, present %d", aborted)
+	}
+
+	if elapsed < grace {
+		t.Errorf("aborted before grace period: %s", elapsed)
+	}
+
+	// Client must see the error, not the truncated body
+	select {
+	case err := <-env.received:
+		if err == nil {
+			t.Errorf("client: aborted response received as complete")
+		}
+	case <-time.After(10 * time.Second):
+		t.Errorf("client: response not aborted")
+	}
+
+	files := env.traceFiles(t)
+	found := false
+	for name := range files {
+		if path.Base(name) == drainAbortedFile {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("trace: aborted request not marked")
+	}
+
+	// Aborted request must not be replayed
+	if _, err := loadReplay(env.name); err == nil {
+		t.Errorf("loadReplay: aborted request loaded")
+	}
+}
+
+// TestDrainStatus tests the status endpoint
+func TestDrainStatus(t *testing.T) {
+	drain := newDrainer(http.NotFoundHandler())
+
+	rq := httptest.NewRequest("GET", drainStatusPath, nil)
+	w := httptest.NewRecorder()
+	drain.ServeHTTP(w, rq)
+
+	if w.Code != http.StatusOK ||
+		!strings.Contains(w.Body.String(), "in-flight: 0\n") {
+		t.Errorf("status: %d %q", w.Code, w.Body.String())
+	}
+
+	rq = httptest.NewRequest("POST", drainStatusPath, nil)
+	w = httptest.NewRecorder()
+	drain.ServeHTTP(w, rq)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status POST: %d", w.Code)
+	}
+}
@@ -10,10 +10,11 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"net"
+	"os"
 	"strconv"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/OpenPrinting/go-mfp/log"
@@ -21,24 +22,51 @@ import (
 
 // listener wraps net.Listener for a reason of fine-tuning incoming
 // TCP connections.
+//
+// The listener is closed either by Close or by cancellation of its
+// parent context. Close is idempotent and safe to call concurrently
+// with Accept, with other Close calls and with the context
+// cancellation. When Close returns, the underlying net.Listener
+// is closed and all Accept calls have returned.
 type listener struct {
 	net.Listener                 // Underlying net.Listener
 	ctx          context.Context // For logging and shutdown
 	cancel       func()          // ctx cancel function
-	closed       atomic.Bool     // listener.Close called
-	closeWait    sync.WaitGroup  // Wait for listener.Close completion
+	lock         sync.Mutex      // Protects closed and accepting.Add
+	closed       bool            // Listener is closed
+	accepting    sync.WaitGroup  // Accept calls in progress
+	killed       chan struct{}   // Closed when kill is done
 }
 
-// newListener creates a new listener
-func newListener(ctx context.Context, port int) (net.Listener, error) {
-	// Setup network and address
-	network := "tcp"
-	addr := ":" + strconv.Itoa(port)
+// newListener creates a new listener.
+//
+// If listener for the port is found among the inherited
+// listeners (see transport.InheritListeners), it is used
+// instead of creating a new one.
+func newListener(ctx context.Context, port int,
+	inherited map[string]net.Listener) (net.Listener, error) {
+
+	// Lookup inherited listeners
+	var nl net.Listener
+	for _, l := range inherited {
+		if addr, ok := l.Addr().(*net.TCPAddr); ok && addr.Port == port {
+			nl = l
+			break
+		}
+	}
+
+	// Create net.Listener, if not inherited
+	if nl == nil {
+		network := "tcp"
+		addr := ":" + strconv.Itoa(port)
 
-	// Create net.Listener
-	nl, err := net.Listen(network, addr)
-	if err != nil {
-		return nil, err
+		var err error
+		nl, err = net.Listen(network, addr)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		log.Debug(ctx, "PROXY %s: listener inherited", nl.Addr())
 	}
 
 	// Create cancelable context
@@ -49,9 +77,9 @@ func newListener(ctx context.Context, port int) (net.Listener, error) {
 		Listener: nl,
 		ctx:      ctx,
 		cancel:   cancel,
+		killed:   make(chan struct{}),
 	}
 
-	l.closeWait.Add(1)
 	go l.kill()
 
 	return l, nil
@@ -61,26 +89,41 @@ func newListener(ctx context.Context, port int) (net.Listener, error) {
 func (l *listener) kill() {
 	<-l.ctx.Done()
 
-	l.closed.Store(true)
+	// Once closed is set, no new Accept calls are counted
+	// in l.accepting, so it is safe to Wait for it.
+	l.lock.Lock()
+	l.closed = true
+	l.lock.Unlock()
+
 	l.Listener.Close()
 
-	l.closeWait.Done()
+	close(l.killed)
+}
+
+// isClosed reports whether listener is closed.
+func (l *listener) isClosed() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.closed
 }
 
 // Accept new connection.
 func (l *listener) Accept() (net.Conn, error) {
-	if l.closed.Load() {
+	l.lock.Lock()
+	if l.closed {
+		l.lock.Unlock()
 		return nil, ErrShutdown
 	}
+	l.accepting.Add(1)
+	l.lock.Unlock()
 
-	l.closeWait.Add(1)
-	defer l.closeWait.Done()
+	defer l.accepting.Done()
 
 	for {
 		// Accept new connection
 		conn, err := l.Listener.Accept()
 
-		if l.closed.Load() {
+		if l.isClosed() {
 			if conn != nil {
 				conn.Close()
 			}
@@ -111,9 +154,22 @@ func (l *listener) Accept() (net.Conn, error) {
 	}
 }
 
+// File returns a copy of the underlying socket file.
+// It is required for the listeners handoff.
+func (l *listener) File() (*os.File, error) {
+	if filer, ok := l.Listener.(interface {
+		File() (*os.File, error)
+	}); ok {
+		return filer.File()
+	}
+
+	return nil, errors.New("listener doesn't support File")
+}
+
 // Close closes the listener.
 func (l *listener) Close() error {
 	l.cancel()
-	l.closeWait.Wait()
+	<-l.killed
+	l.accepting.Wait()
 	return nil
 }
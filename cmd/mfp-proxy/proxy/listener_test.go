@@ -0,0 +1,90 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "proxy" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Listener tests
+
+package proxy
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestListenerCloseRace closes the listener concurrently with
+// Accept, with another Close and with the context cancellation.
+func TestListenerCloseRace(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		l, err := newListener(ctx, 0, nil)
+		if err != nil {
+			t.Fatalf("newListener: %s", err)
+		}
+
+		addr := l.Addr().String()
+
+		var wg sync.WaitGroup
+
+		// Accepters
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					c, err := l.Accept()
+					if err != nil {
+						return
+					}
+					c.Close()
+				}
+			}()
+		}
+
+		// Client
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := net.Dial("tcp", addr)
+			if err == nil {
+				c.Close()
+			}
+		}()
+
+		// Double Close and cancel, in the varying order
+		wg.Add(3)
+		go func() {
+			l.Close()
+			wg.Done()
+		}()
+		go func() {
+			time.Sleep(time.Duration(round%3) * time.Millisecond)
+			l.Close()
+			wg.Done()
+		}()
+		go func() {
+			cancel()
+			wg.Done()
+		}()
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("deadlock")
+		}
+
+		if _, err := l.Accept(); err != ErrShutdown {
+			t.Errorf("Accept after Close: %v", err)
+		}
+	}
+}
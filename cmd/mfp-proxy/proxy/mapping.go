@@ -18,10 +18,11 @@ import (
 
 // mapping defines the mapping between local port and destination URL
 type mapping struct {
-	param     string   // original parameter
-	proto     proto    // Proxy protocol
-	localPath string   // Local path
-	targetURL *url.URL // Destination URL
+	param     string              // original parameter
+	proto     proto               // Proxy protocol
+	localPath string              // Local path
+	targetURL *url.URL            // Destination URL
+	tlsPolicy transport.TLSPolicy // TLS policy for the target
 }
 
 // validateMapping mapping validates mapping, defined as the
@@ -66,3 +67,47 @@ func parseMapping(proto proto, param string) (m mapping, err error) {
 
 	return
 }
+
+// newTransport creates the [transport.Transport] for the mapping
+// target, configured according to the mapping TLS policy.
+//
+// Each mapping has its own DNS cache, so targets referenced by
+// host name are not resolved on each connection.
+func (m mapping) newTransport() *transport.Transport {
+	tr := transport.NewTransport(nil)
+	tr.SetTLSPolicy(m.tlsPolicy)
+	tr.SetDNSCache(transport.NewDNSCache(transport.DNSCacheOptions{}))
+	return tr
+}
+
+// validateTLSPolicy validates the --tls-policy option.
+//
+// It can be used as argv.Option.Validate callback.
+func validateTLSPolicy(param string) error {
+	_, _, err := parseTLSPolicy(param)
+	return err
+}
+
+// parseTLSPolicy parses the --tls-policy option of the following
+// form:
+//
+//	[local-path=]policy
+//
+// If local-path is omitted, the policy applies to all mappings.
+func parseTLSPolicy(param string) (
+	localPath string, policy transport.TLSPolicy, err error) {
+
+	name := param
+	if i := strings.IndexByte(param, '='); i >= 0 {
+		localPath = param[:i]
+		name = param[i+1:]
+
+		if localPath == "" {
+			err = fmt.Errorf("parameter must be \"[path=]policy\"")
+			return
+		}
+	}
+
+	policy, err = transport.ParseTLSPolicy(name)
+	return
+}
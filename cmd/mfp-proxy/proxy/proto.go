@@ -8,6 +8,11 @@
 
 package proxy
 
+import (
+	"github.com/OpenPrinting/go-mfp/proto/escl"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+)
+
 // proto identifies proxy protocol
 type proto int
 
@@ -16,3 +21,14 @@ const (
 	protoESCL
 	protoWSD
 )
+
+// methods returns HTTP methods, allowed for the protocol.
+//
+// There is no dedicated WSD proxy yet, and WSD mappings
+// use the same set of methods as eSCL.
+func (p proto) methods() []string {
+	if p == protoIPP {
+		return ipp.ProxyMethods
+	}
+	return escl.ProxyMethods
+}
@@ -0,0 +1,623 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "proxy" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Replay of the recorded traces
+
+package proxy
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/goipp"
+)
+
+// replayMatch defines how replayed requests are matched
+// against the recorded requests.
+type replayMatch int
+
+// replayMatch values:
+const (
+	// replayNormal matches IPP requests by operation and
+	// normalized attributes fingerprint. The attributes order
+	// and the client identification attributes are ignored.
+	replayNormal replayMatch = iota
+
+	// replayStrict requires exact match of all IPP request
+	// attributes, including their order.
+	replayStrict
+
+	// replayLoose matches IPP requests by operation only.
+	replayLoose
+)
+
+// replayMatchNames contains names of replayMatch values,
+// as used in the command line.
+var replayMatchNames = []string{"normal", "strict", "loose"}
+
+// parseReplayMatch parses the replayMatch name.
+func parseReplayMatch(s string) (replayMatch, error) {
+	for i, name := range replayMatchNames {
+		if s == name {
+			return replayMatch(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%q: invalid replay match mode", s)
+}
+
+// replayIgnoredAttrs contains names of IPP attributes, ignored
+// by the replayNormal matching. These attributes identify the
+// client and may naturally change between the recording and
+// the replay sessions.
+var replayIgnoredAttrs = map[string]struct{}{
+	"requesting-user-name": {},
+	"requesting-user-uri":  {},
+}
+
+// replayRecord is the single recorded request/response pair.
+type replayRecord struct {
+	id     string         // Record ID (the trace directory name)
+	method string         // HTTP request method
+	path   string         // HTTP request path
+	ippRq  *goipp.Message // IPP request, nil if not IPP
+	status int            // HTTP response status
+	header http.Header    // HTTP response header
+	ippRsp *goipp.Message // IPP response, nil if not IPP
+	body   []byte         // Response body (after IPP message, if any)
+}
+
+// replayFile is the single file, loaded from the trace.
+type replayFile struct {
+	name string // File name, relative to the trace root
+	data []byte // File content
+}
+
+// loadReplay loads recorded request/response pairs from the trace.
+//
+// The trace can be either the .tar file, written by the trace.Writer,
+// or the directory with its unpacked content. For convenience, the
+// ".tar" suffix may be omitted.
+//
+// Records are returned in the order of recording.
+func loadReplay(name string) ([]*replayRecord, error) {
+	fi, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		if fi2, err2 := os.Stat(name + ".tar"); err2 == nil {
+			name, fi, err = name+".tar", fi2, nil
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var files []replayFile
+	if fi.IsDir() {
+		files, err = loadReplayDir(name)
+	} else {
+		files, err = loadReplayTar(name)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	// Group files by the record ID. Within the trace, each
+	// request/response pair lives in its own directory.
+	byID := make(map[string][]replayFile)
+	for _, file := range files {
+		dir, base := path.Split(path.Clean(file.name))
+		dir = strings.TrimSuffix(dir, "/")
+		if dir == "" || strings.Contains(dir, "/") {
+			continue
+		}
+
+		file.name = base
+		byID[dir] = append(byID[dir], file)
+	}
+
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// Decode records
+	var records []*replayRecord
+	for _, id := range ids {
+		rec, err := newReplayRecord(id, byID[id])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", name, id, err)
+		}
+
+		if rec != nil {
+			records = append(records, rec)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s: no recorded requests found", name)
+	}
+
+	return records, nil
+}
+
+// loadReplayTar loads files from the trace .tar file.
+func loadReplayTar(name string) ([]replayFile, error) {
+	fp, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	var files []replayFile
+	rd := tar.NewReader(fp)
+	for {
+		hdr, err := rd.Next()
+		switch {
+		case err == io.EOF:
+			return files, nil
+		case err != nil:
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := io.ReadAll(rd)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, replayFile{hdr.Name, data})
+	}
+}
+
+// loadReplayDir loads files from the directory with unpacked trace.
+func loadReplayDir(name string) ([]replayFile, error) {
+	var files []replayFile
+
+	err := filepath.WalkDir(name,
+		func(file string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+
+			// Skip temporary files, left by the interrupted
+			// trace.Writer (see trace.NewDirWriter)
+			if strings.HasPrefix(d.Name(), ".") {
+				return nil
+			}
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+
+			rel, _ := filepath.Rel(name, file)
+			files = append(files,
+				replayFile{filepath.ToSlash(rel), data})
+
+			return nil
+		})
+
+	return files, err
+}
+
+// newReplayRecord decodes replayRecord out of the trace files,
+// related to the single request.
+//
+// Files are named "req-Name.ext" and "rsp-Name.ext", where Name
+// is the protocol message name and ext defines the file content.
+//
+// It returns nil, nil if files don't contain the complete
+// request/response pair (for example, if the trace was
+// interrupted or request was aborted on the proxy shutdown).
+func newReplayRecord(id string, files []replayFile) (*replayRecord, error) {
+	rec := &replayRecord{id: id}
+
+	var rqHTTP, rspHTTP, rqIPP, rspIPP, rspBody []byte
+	for _, file := range files {
+		ext := path.Ext(file.name)
+
+		switch {
+		case file.name == drainAbortedFile:
+			return nil, nil
+		case strings.HasPrefix(file.name, "req-") && ext == ".http":
+			rqHTTP = file.data
+		case strings.HasPrefix(file.name, "req-") && ext == ".ipp":
+			rqIPP = file.data
+		case strings.HasPrefix(file.name, "rsp-") && ext == ".http":
+			rspHTTP = file.data
+		case strings.HasPrefix(file.name, "rsp-") && ext == ".ipp":
+			rspIPP = file.data
+		case strings.HasPrefix(file.name, "rsp-"):
+			rspBody = file.data
+		}
+	}
+
+	// The rsp-*.http file is written when response is completely
+	// sent, so without it the exchange is incomplete.
+	if rqHTTP == nil || rspHTTP == nil {
+		return nil, nil
+	}
+
+	// Decode HTTP request
+	rq, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(rqHTTP)))
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request: %w", err)
+	}
+
+	rec.method = rq.Method
+	rec.path = rq.URL.Path
+
+	// Decode HTTP response
+	rsp, err := http.ReadResponse(
+		bufio.NewReader(bytes.NewReader(rspHTTP)), rq)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP response: %w", err)
+	}
+
+	rec.status = rsp.StatusCode
+	rec.header = rsp.Header
+	rsp.Body.Close()
+
+	// Decode IPP messages
+	if rqIPP != nil && rspIPP != nil {
+		rec.ippRq = &goipp.Message{}
+		err = rec.ippRq.DecodeBytes(rqIPP)
+		if err != nil {
+			return nil, fmt.Errorf("IPP request: %w", err)
+		}
+
+		rec.ippRsp = &goipp.Message{}
+		err = rec.ippRsp.DecodeBytes(rspIPP)
+		if err != nil {
+			return nil, fmt.Errorf("IPP response: %w", err)
+		}
+
+		// The response body file contains the whole HTTP
+		// body, starting with the IPP message. Keep only
+		// the data that follows the message.
+		if rspBody != nil {
+			var msg goipp.Message
+			rd := bytes.NewReader(rspBody)
+			err = msg.Decode(rd)
+			if err != nil {
+				return nil, fmt.Errorf("IPP response body: %w",
+					err)
+			}
+
+			rspBody = rspBody[len(rspBody)-rd.Len():]
+		}
+	}
+
+	rec.body = rspBody
+
+	return rec, nil
+}
+
+// replayer answers requests by replaying the recorded responses.
+// It implements the [http.Handler] interface.
+//
+// Requests are matched against the recorded ones by the IPP
+// operation and attributes fingerprint (see [replayMatch]) for
+// IPP, and by the HTTP method and path for everything else.
+//
+// If the same request was recorded multiple times, responses
+// are served sequentially, and the last one is repeated after
+// the recorded sequence is exhausted.
+type replayer struct {
+	localPath  string                     // Local path
+	methods    []string                   // Allowed HTTP methods
+	targetURL  *url.URL                   // Recorded device URL
+	match      replayMatch                // Matching mode
+	missStatus int                        // HTTP status for unmatched
+	byKey      map[string][]*replayRecord // Records by match key
+	next       map[string]int             // Next record by match key
+	lock       sync.Mutex                 // Access lock for next
+}
+
+// newReplayer creates a new replayer.
+//
+// Only records with path under the localPath are used. For the
+// IPP mappings only IPP records are used, and vice versa.
+func newReplayer(m mapping, records []*replayRecord,
+	match replayMatch, missStatus int) *replayer {
+
+	rp := &replayer{
+		localPath:  m.localPath,
+		methods:    m.proto.methods(),
+		targetURL:  m.targetURL,
+		match:      match,
+		missStatus: missStatus,
+		byKey:      make(map[string][]*replayRecord),
+		next:       make(map[string]int),
+	}
+
+	for _, rec := range records {
+		if (rec.ippRq != nil) != (m.proto == protoIPP) ||
+			!replayPathMatch(rec.path, m.localPath) {
+			continue
+		}
+
+		var key string
+		if rec.ippRq != nil {
+			key = rp.ippKey(rec.ippRq)
+		} else {
+			key = rp.httpKey(rec.method, rec.path)
+		}
+
+		rp.byKey[key] = append(rp.byKey[key], rec)
+	}
+
+	return rp
+}
+
+// ServeHTTP handles incoming HTTP requests.
+// It implements [http.Handler] interface.
+func (rp *replayer) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
+	query := transport.NewServerQuery(w, rq)
+	defer query.Finish()
+
+	defer query.RequestBody().Close()
+
+	// Check request method
+	if !slices.Contains(rp.methods, query.RequestMethod()) {
+		query.RejectMethod(rp.methods...)
+		return
+	}
+
+	// Guess our local URL out of request.
+	s := query.RequestScheme() + "://" + query.RequestHost()
+	local, err := transport.ParseURL(s)
+	if err != nil {
+		err = fmt.Errorf("%q: can't parse local URL", s)
+		query.Reject(http.StatusBadRequest, err)
+		return
+	}
+
+	local.Path = rp.localPath
+	urlxlat := transport.NewURLXlat(local, rp.targetURL)
+
+	// Dispatch the request
+	if query.RequestContentType() == "application/ipp" &&
+		query.RequestMethod() == "POST" {
+		rp.serveIPP(query, urlxlat)
+	} else {
+		rp.serveHTTP(query, urlxlat)
+	}
+}
+
+// serveIPP replays the IPP request.
+func (rp *replayer) serveIPP(query *transport.ServerQuery,
+	urlxlat *transport.URLXlat) {
+
+	// Fetch IPP Request message and discard the document data,
+	// if any.
+	var msg goipp.Message
+
+	body := query.RequestBody()
+	ops := goipp.DecoderOptions{EnableWorkarounds: true}
+	err := msg.DecodeEx(body, ops)
+	if err != nil {
+		query.Reject(http.StatusBadRequest, err)
+		return
+	}
+
+	io.Copy(io.Discard, body)
+
+	// Lookup the record
+	key := rp.ippKey(&msg)
+	rec := rp.lookup(key)
+	if rec == nil {
+		rp.miss(query, key, "IPP "+goipp.Op(msg.Code).String())
+		return
+	}
+
+	log.Debug(query.RequestContext(), "replay: IPP %s: record %s",
+		goipp.Op(msg.Code), rec.id)
+
+	// Prepare response
+	rsp := ipp.ProxyTranslateResponse(rec.ippRsp, urlxlat)
+	rsp.RequestID = msg.RequestID
+
+	data, _ := rsp.EncodeBytes()
+	data = append(data, rec.body...)
+
+	rp.send(query, rec, urlxlat, data)
+}
+
+// serveHTTP replays the non-IPP HTTP request.
+func (rp *replayer) serveHTTP(query *transport.ServerQuery,
+	urlxlat *transport.URLXlat) {
+
+	io.Copy(io.Discard, query.RequestBody())
+
+	method := query.RequestMethod()
+	path := query.RequestURL().Path
+
+	key := rp.httpKey(method, path)
+	rec := rp.lookup(key)
+	if rec == nil {
+		rp.miss(query, key, method+" "+path)
+		return
+	}
+
+	log.Debug(query.RequestContext(), "replay: %s %s: record %s",
+		method, path, rec.id)
+
+	rp.send(query, rec, urlxlat, rec.body)
+}
+
+// send sends the recorded response.
+func (rp *replayer) send(query *transport.ServerQuery, rec *replayRecord,
+	urlxlat *transport.URLXlat, data []byte) {
+
+	hdr := rec.header.Clone()
+	transport.HTTPRemoveHopByHopHeaders(hdr)
+	hdr.Del("Content-Length")
+	hdr.Del("Date")
+
+	if location := hdr.Get("Location"); location != "" {
+		if u, err := transport.ParseURL(location); err == nil {
+			hdr.Set("Location", urlxlat.Reverse(u).String())
+		}
+	}
+
+	transport.HTTPCopyHeaders(query.ResponseHeader(), hdr)
+	query.ResponseHeader().Set("Content-Length", strconv.Itoa(len(data)))
+
+	query.WriteHeader(rec.status)
+	query.Write(data)
+}
+
+// miss rejects the request that doesn't match any record.
+//
+// The response body contains diagnostics: the request key and
+// list of recorded keys for the same IPP operation or HTTP path.
+func (rp *replayer) miss(query *transport.ServerQuery, key, what string) {
+	prefix, _, _ := strings.Cut(key, "\n")
+
+	var similar []string
+	for k, recs := range rp.byKey {
+		if p, _, _ := strings.Cut(k, "\n"); p == prefix {
+			for _, rec := range recs {
+				similar = append(similar, rec.id)
+			}
+		}
+	}
+	sort.Strings(similar)
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "replay: %s: no matching record\n", what)
+	fmt.Fprintf(buf, "request key (%s match):\n", replayMatchNames[rp.match])
+	fmt.Fprintf(buf, "  %s\n", strings.ReplaceAll(key, "\n", "\n  "))
+
+	if len(similar) != 0 {
+		fmt.Fprintf(buf, "records with different attributes: %s",
+			strings.Join(similar, " "))
+	} else {
+		fmt.Fprintf(buf, "no records for this request")
+	}
+
+	err := errors.New(buf.String())
+	log.Debug(query.RequestContext(), "%s", err)
+
+	query.Reject(rp.missStatus, err)
+}
+
+// lookup returns the next record for the key or nil if
+// there is no matching record.
+func (rp *replayer) lookup(key string) *replayRecord {
+	recs := rp.byKey[key]
+	if len(recs) == 0 {
+		return nil
+	}
+
+	rp.lock.Lock()
+	n := rp.next[key]
+	rp.next[key] = n + 1
+	rp.lock.Unlock()
+
+	if n >= len(recs) {
+		n = len(recs) - 1
+	}
+
+	return recs[n]
+}
+
+// httpKey returns the match key for the HTTP request.
+func (rp *replayer) httpKey(method, path string) string {
+	return method + " " + path
+}
+
+// ippKey returns the match key for the IPP request.
+//
+// The first line of the key is the IPP operation, the remaining
+// lines, if any, represent the request attributes fingerprint.
+//
+// Values of the URI attributes are reduced to their paths, as
+// the proxy host and port naturally change between the
+// recording and replay sessions.
+func (rp *replayer) ippKey(msg *goipp.Message) string {
+	buf := &bytes.Buffer{}
+	buf.WriteString(goipp.Op(msg.Code).String())
+
+	if rp.match == replayLoose {
+		return buf.String()
+	}
+
+	for _, grp := range msg.AttrGroups() {
+		attrs := grp.Attrs
+		if rp.match == replayNormal {
+			attrs = make(goipp.Attributes, 0, len(grp.Attrs))
+			for _, attr := range grp.Attrs {
+				_, ignore := replayIgnoredAttrs[attr.Name]
+				if !ignore {
+					attrs = append(attrs, attr)
+				}
+			}
+
+			sort.SliceStable(attrs, func(i, j int) bool {
+				return attrs[i].Name < attrs[j].Name
+			})
+		}
+
+		fmt.Fprintf(buf, "\n%s:", grp.Tag)
+		for _, attr := range attrs {
+			fmt.Fprintf(buf, " %s=", attr.Name)
+			for i, v := range attr.Values {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+
+				s := v.V.String()
+				if v.T == goipp.TagURI {
+					if u, err := url.Parse(s); err == nil {
+						s = u.Path
+					}
+				}
+
+				fmt.Fprintf(buf, "%s:%q", v.T, s)
+			}
+		}
+	}
+
+	return buf.String()
+}
+
+// replayPathMatch reports if path is the same as prefix or
+// lives under it.
+func replayPathMatch(path, prefix string) bool {
+	switch {
+	case !strings.HasPrefix(path, prefix):
+		return false
+	case len(path) == len(prefix), strings.HasSuffix(prefix, "/"):
+		return true
+	}
+
+	return path[len(prefix)] == '/'
+}
@@ -0,0 +1,424 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "proxy" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Replay of the recorded traces test
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/log/trace"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// testReplaySession is the recorded or replayed client session
+type testReplaySession struct {
+	printer *ipp.PrinterAttributes
+	job     *ipp.CreateJobResponse
+	jobAttr ipp.JobGroupEntry
+	jobs    []ipp.JobGroupEntry
+}
+
+// testReplayRun runs the client session against the proxy
+func testReplayRun(t *testing.T, u *url.URL) testReplaySession {
+	var s testReplaySession
+	var err error
+
+	ctx := context.Background()
+	clnt := ipp.NewClient(u, nil)
+
+	s.printer, err = clnt.GetPrinterAttributes(ctx, nil, "")
+	if err != nil {
+		t.Fatalf("Get-Printer-Attributes: %s", err)
+	}
+
+	op := ipp.JobCreateOperation{PrinterURI: u.String()}
+	s.job, err = clnt.CreateJob(ctx, op, nil)
+	if err != nil {
+		t.Fatalf("Create-Job: %s", err)
+	}
+
+	s.jobAttr, err = clnt.GetJobAttributes(ctx, s.job.Job.JobID, nil)
+	if err != nil {
+		t.Fatalf("Get-Job-Attributes: %s", err)
+	}
+
+	s.jobs, err = clnt.GetJobs(ctx, ipp.KwWhichJobsAll, false, 0, nil)
+	if err != nil {
+		t.Fatalf("Get-Jobs: %s", err)
+	}
+
+	return s
+}
+
+// testReplayServe starts the HTTP server with the handler on the
+// specified address. If addr is "", the random port is allocated.
+func testReplayServe(t *testing.T, ctx context.Context,
+	addr string, handler http.Handler) (*transport.Server, *url.URL) {
+
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("net.Listen: %s", err)
+	}
+
+	srvr := transport.NewServer(ctx, nil, handler)
+	go srvr.Serve(l)
+
+	u, _ := url.Parse("http://" + l.Addr().String() + "/ipp/print")
+	return srvr, u
+}
+
+// testReplayRecord records the client session against the
+// virtual printer, passed through the proxy. It returns the
+// recorded session, the proxy address and the mapping.
+func testReplayRecord(t *testing.T, name string) (
+	testReplaySession, string, mapping) {
+
+	attrs := &ipp.PrinterAttributes{}
+	attrs.PrinterName = optional.New("replay")
+	attrs.PrinterInfo = optional.New("Replay Test Printer")
+
+	printer := ipp.NewPrinter(attrs, ipp.PrinterOptions{})
+	target := httptest.NewServer(printer)
+	defer target.Close()
+
+	m, err := parseMapping(protoIPP, "/ipp/print="+target.URL+"/ipp/print")
+	if err != nil {
+		t.Fatalf("parseMapping: %s", err)
+	}
+
+	logger := log.NewLogger(log.LevelError, log.Console)
+	ctx := log.NewContext(context.Background(), logger)
+
+	tracer, err := trace.NewWriter(ctx, name)
+	if err != nil {
+		t.Fatalf("trace.NewWriter: %s", err)
+	}
+
+	ctx = trace.NewContext(ctx, tracer)
+
+	proxy := ipp.NewProxy(m.localPath, m.targetURL)
+	srvr, u := testReplayServe(t, ctx, "", proxy)
+
+	session := testReplayRun(t, u)
+
+	// Shutdown waits for handlers to return, so responses
+	// are completely written into the trace
+	srvr.Shutdown(context.Background())
+	tracer.Close()
+
+	return session, u.Host, m
+}
+
+// TestReplay records the IPP session and replays it to a fresh client
+func TestReplay(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "trace")
+	recorded, addr, m := testReplayRecord(t, name)
+
+	records, err := loadReplay(name)
+	if err != nil {
+		t.Fatalf("loadReplay: %s", err)
+	}
+
+	if len(records) != 4 {
+		t.Errorf("loadReplay: expected 4 records, present %d",
+			len(records))
+	}
+
+	for _, match := range []replayMatch{replayNormal, replayStrict,
+		replayLoose} {
+
+		rp := newReplayer(m, records, match, DefaultReplayMissStatus)
+
+		// Replay on the same address, so URLs in responses
+		// must be exactly the same, as recorded.
+		srvr, u := testReplayServe(t, context.Background(), addr, rp)
+		replayed := testReplayRun(t, u)
+		srvr.Close()
+
+		if !reflect.DeepEqual(recorded, replayed) {
+			t.Errorf("%s: replayed session mismatch",
+				replayMatchNames[match])
+		}
+	}
+}
+
+// TestReplayBody tests that replayed IPP responses are
+// byte-to-byte the same, as recorded
+func TestReplayBody(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "trace")
+	_, addr, m := testReplayRecord(t, name)
+
+	records, err := loadReplay(name)
+	if err != nil {
+		t.Fatalf("loadReplay: %s", err)
+	}
+
+	files, err := loadReplayTar(name + ".tar")
+	if err != nil {
+		t.Fatalf("loadReplayTar: %s", err)
+	}
+
+	rp := newReplayer(m, records, replayNormal, DefaultReplayMissStatus)
+	srvr, u := testReplayServe(t, context.Background(), addr, rp)
+	defer srvr.Close()
+
+	// Collect recorded request messages and response bodies
+	rqs := make(map[string][]byte)
+	rsps := make(map[string][]byte)
+	for _, file := range files {
+		dir, base := path.Split(file.name)
+		ext := path.Ext(base)
+
+		switch {
+		case strings.HasPrefix(base, "req-") && ext == ".ipp":
+			rqs[dir] = file.data
+		case strings.HasPrefix(base, "rsp-") &&
+			ext != ".ipp" && ext != ".http":
+			rsps[dir] = file.data
+		}
+	}
+
+	if len(rqs) != len(records) {
+		t.Fatalf("%d IPP requests in trace, expected %d",
+			len(rqs), len(records))
+	}
+
+	// Resend recorded requests and compare responses
+	for dir, rq := range rqs {
+		rsp, err := http.Post(u.String(), "application/ipp",
+			bytes.NewReader(rq))
+		if err != nil {
+			t.Fatalf("%s: %s", dir, err)
+		}
+
+		data, err := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if err != nil {
+			t.Fatalf("%s: %s", dir, err)
+		}
+
+		if !bytes.Equal(data, rsps[dir]) {
+			t.Errorf("%s: response body mismatch:\n"+
+				"expected: %d bytes\npresent:  %d bytes",
+				dir, len(rsps[dir]), len(data))
+		}
+	}
+}
+
+// TestReplayTruncated tests loading of the trace with
+// incomplete exchanges
+func TestReplayTruncated(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "trace")
+	testReplayRecord(t, name)
+
+	files, err := loadReplayTar(name + ".tar")
+	if err != nil {
+		t.Fatalf("loadReplayTar: %s", err)
+	}
+
+	// Unpack the trace, dropping the rsp-*.http file of
+	// the first exchange, as if it was still running, when
+	// the trace was closed.
+	dir := filepath.Join(t.TempDir(), "unpacked")
+	dropped := ""
+	for _, file := range files {
+		seq, base := path.Split(file.name)
+		if strings.HasPrefix(base, "rsp-") &&
+			path.Ext(base) == ".http" &&
+			(dropped == "" || dropped == seq) {
+			dropped = seq
+			continue
+		}
+
+		fullname := filepath.Join(dir, filepath.FromSlash(file.name))
+		os.MkdirAll(filepath.Dir(fullname), 0755)
+		err = os.WriteFile(fullname, file.data, 0644)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	records, err := loadReplay(dir)
+	if err != nil {
+		t.Fatalf("loadReplay: %s", err)
+	}
+
+	if len(records) != 3 {
+		t.Errorf("loadReplay: expected 3 records, present %d",
+			len(records))
+	}
+
+	for _, rec := range records {
+		if rec.id+"/" == dropped {
+			t.Errorf("%s: incomplete record loaded", rec.id)
+		}
+
+		if rec.status == 0 {
+			t.Errorf("%s: HTTP status missed", rec.id)
+		}
+	}
+}
+
+// TestReplayMiss tests handling of requests without matching records
+func TestReplayMiss(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "trace")
+	_, _, m := testReplayRecord(t, name)
+
+	records, err := loadReplay(name + ".tar")
+	if err != nil {
+		t.Fatalf("loadReplay: %s", err)
+	}
+
+	// Request the job that was never recorded
+	tests := []struct {
+		match   replayMatch
+		success bool
+	}{
+		{replayNormal, false},
+		{replayStrict, false},
+		{replayLoose, true},
+	}
+
+	for _, test := range tests {
+		rp := newReplayer(m, records, test.match, 503)
+		srvr, u := testReplayServe(t, context.Background(), "", rp)
+
+		clnt := ipp.NewClient(u, nil)
+		_, err := clnt.GetJobAttributes(context.Background(), 12345, nil)
+		srvr.Close()
+
+		switch {
+		case test.success && err != nil:
+			t.Errorf("%s: unexpected error: %s",
+				replayMatchNames[test.match], err)
+
+		case !test.success && err == nil:
+			t.Errorf("%s: error expected",
+				replayMatchNames[test.match])
+
+		case !test.success && !strings.Contains(err.Error(), "503"):
+			t.Errorf("%s: expected HTTP 503, present %s",
+				replayMatchNames[test.match], err)
+		}
+	}
+}
+
+// TestReplayMethods tests rejection of HTTP methods, not
+// allowed for the mapping protocol
+func TestReplayMethods(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "trace")
+	_, _, m := testReplayRecord(t, name)
+
+	records, err := loadReplay(name + ".tar")
+	if err != nil {
+		t.Fatalf("loadReplay: %s", err)
+	}
+
+	rp := newReplayer(m, records, replayNormal, DefaultReplayMissStatus)
+	srvr, u := testReplayServe(t, context.Background(), "", rp)
+	defer srvr.Close()
+
+	for _, method := range []string{"PUT", "DELETE", "OPTIONS"} {
+		rq, _ := http.NewRequest(method, u.String(), nil)
+		rsp, err := http.DefaultClient.Do(rq)
+		if err != nil {
+			t.Errorf("%s: %s", method, err)
+			continue
+		}
+		rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("%s: expected HTTP 405, present %d",
+				method, rsp.StatusCode)
+		}
+
+		allow := rsp.Header.Get("Allow")
+		if allow != "GET, HEAD, POST" {
+			t.Errorf("%s: Allow mismatch: %q", method, allow)
+		}
+	}
+}
+
+// TestReplayIPPKey tests IPP request fingerprints
+func TestReplayIPPKey(t *testing.T) {
+	rq1 := &ipp.CreateJobRequest{
+		RequestHeader: ipp.DefaultRequestHeader,
+		JobCreateOperation: ipp.JobCreateOperation{
+			PrinterURI:         "ipp://localhost:1111/ipp/print",
+			RequestingUserName: optional.New("alice"),
+			JobName:            optional.New("test"),
+		},
+		JobTemplate: &ipp.JobTemplate{},
+	}
+
+	rq2 := &ipp.CreateJobRequest{
+		RequestHeader: ipp.DefaultRequestHeader,
+		JobCreateOperation: ipp.JobCreateOperation{
+			PrinterURI:         "ipp://localhost:2222/ipp/print",
+			RequestingUserName: optional.New("bob"),
+			JobName:            optional.New("test"),
+		},
+		JobTemplate: &ipp.JobTemplate{},
+	}
+
+	rq3 := &ipp.CreateJobRequest{
+		RequestHeader: ipp.DefaultRequestHeader,
+		JobCreateOperation: ipp.JobCreateOperation{
+			PrinterURI: "ipp://localhost:1111/ipp/print",
+			JobName:    optional.New("other"),
+		},
+		JobTemplate: &ipp.JobTemplate{},
+	}
+
+	tests := []struct {
+		match replayMatch
+		eq12  bool // rq1 and rq2 keys are equal
+		eq13  bool // rq1 and rq3 keys are equal
+	}{
+		{replayNormal, true, false},
+		{replayStrict, false, false},
+		{replayLoose, true, true},
+	}
+
+	for _, test := range tests {
+		rp := &replayer{match: test.match}
+		k1 := rp.ippKey(rq1.Encode())
+		k2 := rp.ippKey(rq2.Encode())
+		k3 := rp.ippKey(rq3.Encode())
+
+		if (k1 == k2) != test.eq12 {
+			t.Errorf("%s: rq1/rq2 keys equality: expected %v",
+				replayMatchNames[test.match], test.eq12)
+		}
+
+		if (k1 == k3) != test.eq13 {
+			t.Errorf("%s: rq1/rq3 keys equality: expected %v",
+				replayMatchNames[test.match], test.eq13)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "proxy" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Zero-downtime upgrade
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// upgradeTimeout limits the whole handoff process, including
+// draining of active connections.
+const upgradeTimeout = 60 * time.Second
+
+// upgradeWait waits for the upgradeSignal, then re-executes the
+// proxy and hands off listening sockets of the srvr to the new
+// instance.
+//
+// It returns nil when handoff is completed, so the caller
+// should exit, or ctx.Err() if ctx is canceled.
+//
+// If handoff fails, error is logged and the proxy continues
+// to serve and wait for the next signal.
+func upgradeWait(ctx context.Context, srvr *transport.Server) error {
+	if upgradeSignal == nil {
+		return errors.New("--upgrade is not supported on this platform")
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, upgradeSignal)
+	defer signal.Stop(sig)
+
+	for {
+		select {
+		case <-sig:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		log.Info(ctx, "upgrade requested")
+
+		err := upgrade(ctx, srvr)
+		if err == nil {
+			log.Info(ctx, "upgrade completed")
+			return nil
+		}
+
+		log.Error(ctx, "upgrade: %s", err)
+	}
+}
+
+// upgrade re-executes the proxy and hands off listening sockets
+// of the srvr to the new instance.
+func upgrade(ctx context.Context, srvr *transport.Server) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	ctx, cancel := context.WithTimeout(ctx, upgradeTimeout)
+	defer cancel()
+
+	return srvr.Handoff(ctx, cmd)
+}
@@ -0,0 +1,17 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "proxy" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Zero-downtime upgrade -- the portable fallback
+
+//go:build !unix
+
+package proxy
+
+import "os"
+
+// upgradeSignal is the signal that requests the upgrade.
+// Upgrade is not supported on this platform.
+var upgradeSignal os.Signal
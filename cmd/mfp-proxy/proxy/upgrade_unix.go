@@ -0,0 +1,19 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "proxy" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Zero-downtime upgrade -- UNIX part
+
+//go:build unix
+
+package proxy
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignal is the signal that requests the upgrade
+var upgradeSignal os.Signal = syscall.SIGUSR2
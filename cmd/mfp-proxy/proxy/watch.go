@@ -0,0 +1,96 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "proxy" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Watching state of the IPP targets
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// DefaultWatchInterval is the default polling interval of the
+// IPP targets state.
+const DefaultWatchInterval = 10 * time.Second
+
+// printerWatch tracks the state of the IPP target printer with
+// the ipp.Watch and reports it at the status endpoint.
+type printerWatch struct {
+	m     mapping                // The mapping
+	attrs *ipp.PrinterAttributes // Last known attributes, nil if none
+	lock  sync.Mutex             // Access lock
+}
+
+// newPrinterWatch creates a new printerWatch for the IPP mapping.
+func newPrinterWatch(m mapping) *printerWatch {
+	return &printerWatch{m: m}
+}
+
+// run runs the printerWatch until ctx is canceled.
+func (pw *printerWatch) run(ctx context.Context, interval time.Duration) {
+	clnt := ipp.NewClient(pw.m.targetURL, pw.m.newTransport())
+	ipp.Watch(ctx, clnt, interval, func(attrs *ipp.PrinterAttributes) {
+		log.Debug(ctx, "IPP: %s: %s", pw.m.targetURL,
+			printerWatchState(attrs))
+		pw.update(attrs)
+	})
+}
+
+// update updates the printerWatch with the new printer attributes.
+func (pw *printerWatch) update(attrs *ipp.PrinterAttributes) {
+	pw.lock.Lock()
+	pw.attrs = attrs
+	pw.lock.Unlock()
+}
+
+// String returns the status line of the printerWatch.
+func (pw *printerWatch) String() string {
+	pw.lock.Lock()
+	attrs := pw.attrs
+	pw.lock.Unlock()
+
+	return fmt.Sprintf("printer %s: %s", pw.m.localPath,
+		printerWatchState(attrs))
+}
+
+// printerWatchState formats printer state from the printer attributes.
+func printerWatchState(attrs *ipp.PrinterAttributes) string {
+	if attrs == nil {
+		return "unknown"
+	}
+
+	var state string
+	switch s := optional.Get(attrs.PrinterState); s {
+	case 3:
+		state = "idle"
+	case 4:
+		state = "processing"
+	case 5:
+		state = "stopped"
+	default:
+		state = strconv.Itoa(s)
+	}
+
+	reasons := make([]string, len(attrs.PrinterStateReasons))
+	for i, reason := range attrs.PrinterStateReasons {
+		reasons[i] = string(reason)
+	}
+
+	if len(reasons) != 0 {
+		state += " (" + strings.Join(reasons, ",") + ")"
+	}
+
+	return state
+}
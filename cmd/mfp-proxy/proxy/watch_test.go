@@ -0,0 +1,108 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "proxy" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Watching state of the IPP targets test
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestPrinterWatch tests reporting of the IPP target state
+// at the status endpoint
+func TestPrinterWatch(t *testing.T) {
+	// Fake printer. The first poll means that the initial
+	// attributes are already received by the printerWatch.
+	polled := make(chan struct{})
+	var once sync.Once
+
+	mux := ipp.NewServeMux(ipp.ServerOptions{})
+	mux.Handle(goipp.OpGetPrinterAttributes,
+		func(ctx context.Context, msg *goipp.Message,
+			body io.Reader) (*goipp.Message, io.Reader, error) {
+
+			var rq ipp.GetPrinterAttributesRequest
+			err := rq.Decode(msg, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if rq.RequestedAttributes != nil {
+				once.Do(func() { close(polled) })
+			}
+
+			prn := &ipp.PrinterAttributes{}
+			prn.PrinterConfigChangeTime = optional.New(1)
+			prn.PrinterState = optional.New(5)
+			prn.PrinterStateReasons = []ipp.KwPrinterStateReasons{
+				"media-jam-error"}
+
+			rsp := &ipp.GetPrinterAttributesResponse{
+				ResponseHeader: ipp.ResponseHeader{
+					Version:   msg.Version,
+					RequestID: msg.RequestID,
+					Status:    goipp.StatusOk,
+				},
+				Printer: prn,
+			}
+
+			return rsp.Encode(), nil, nil
+		})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	m, err := parseMapping(protoIPP, "/ipp/print="+srv.URL)
+	if err != nil {
+		t.Fatalf("parseMapping: %s", err)
+	}
+
+	drain := newDrainer(http.NotFoundHandler())
+	pw := newPrinterWatch(m)
+	drain.watches = []*printerWatch{pw}
+
+	status := func() string {
+		rq := httptest.NewRequest("GET", drainStatusPath, nil)
+		w := httptest.NewRecorder()
+		drain.ServeHTTP(w, rq)
+		return w.Body.String()
+	}
+
+	// Before the first response, state is unknown
+	if s := status(); !strings.Contains(s,
+		"printer /ipp/print: unknown\n") {
+		t.Errorf("initial status: %q", s)
+	}
+
+	// Run the printerWatch
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pw.run(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	<-polled
+	cancel()
+	<-done
+
+	if s := status(); !strings.Contains(s,
+		"printer /ipp/print: stopped (media-jam-error)\n") {
+		t.Errorf("status: %q", s)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"strconv"
 
 	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/internal/env"
 	"github.com/OpenPrinting/go-mfp/log"
 	"github.com/OpenPrinting/go-mfp/log/trace"
 	"github.com/OpenPrinting/go-mfp/modeling"
@@ -24,6 +25,13 @@ import (
 // DefaultTCPPort is the default TCP port for the MFP simulator
 const DefaultTCPPort = 50000
 
+// Log file parameters (see log.NewFileBackendFormat and log.SinkSpec)
+const (
+	logFileMaxSize = 16 * 1024 * 1024 // Max size before rotation
+	logFileBackups = 4                // Count of backup files
+	logFileBuffer  = 1024             // Max records queued to file
+)
+
 // description is printed as a command description text
 const description = "" +
 	"This command runs the MFP simulator\n" +
@@ -76,15 +84,21 @@ var Command = argv.Command{
 			Complete: argv.CompleteOSPath,
 		},
 		argv.Option{
-			Name:    "-d",
-			Aliases: []string{"--debug"},
-			Help:    "Enable debug output",
+			Name:      "--log-file",
+			Help:      "write debug log to file",
+			HelpArg:   "file",
+			Singleton: true,
+			Validate:  argv.ValidateAny,
+			Complete:  argv.CompleteOSPath,
 		},
 		argv.Option{
-			Name:    "-v",
-			Aliases: []string{"--verbose"},
-			Help:    "Enable verbose debug output",
+			Name: "--log-relative",
+			Help: "time-stamp logs with time since start (+12.345s),\n" +
+				"instead of wall clock",
+			Singleton: true,
 		},
+		env.OptDebug,
+		env.OptVerbose,
 		argv.HelpOption,
 	},
 	Parameters: []argv.Parameter{
@@ -103,18 +117,27 @@ var Command = argv.Command{
 // cmdVirtualHandler is the top-level handler for the 'cups' command.
 func cmdVirtualHandler(ctx context.Context, inv *argv.Invocation) error {
 	// Setup logging
-	_, dbg := inv.Get("-d")
-	_, vrb := inv.Get("-v")
+	level := env.LogLevel(inv)
 
-	level := log.LevelInfo
-	if dbg {
-		level = log.LevelDebug
+	console, fileTime := log.Console, log.TimeWall
+	if _, rel := inv.Get("--log-relative"); rel {
+		console = log.NewConsoleBackend(log.TimeRelative)
+		fileTime = log.TimeRelative
 	}
-	if vrb {
-		level = log.LevelTrace
+
+	sinks := []log.SinkSpec{{Level: level, Backend: console}}
+	if logFile, _ := inv.Get("--log-file"); logFile != "" {
+		sinks = append(sinks, log.SinkSpec{
+			Level: log.LevelDebug,
+			Backend: log.NewFileBackendFormat(logFile,
+				logFileMaxSize, logFileBackups, fileTime),
+			Buffer: logFileBuffer,
+		})
 	}
 
-	logger := log.NewLogger(level, log.Console)
+	logger := log.NewLoggerMulti(sinks...)
+	defer logger.Close()
+
 	ctx = log.NewContext(ctx, logger)
 
 	var err error
@@ -30,11 +30,72 @@ func simulate(ctx context.Context, model *modeling.Model,
 	portnum int, usbip bool, argv []string) error {
 
 	// Create the PathMux
+	mux, runner := newMux(model, portnum)
+
+	// Check that we have added at least something
+	if mux.Empty() {
+		return errors.New("model is emoty")
+	}
+
+	if cfg := model.GetDeviceConfig(); cfg != nil && cfg.TLS {
+		log.Warning(ctx, "TLS is not supported by simulator, ignored")
+	}
+
+	// Create server for incoming connections.
+	if !usbip {
+		addr := fmt.Sprintf("localhost:%d", portnum)
+
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+
+		srvr := transport.NewServer(ctx, nil, mux)
+		log.Info(ctx, "starting virtual MFP at http://%s", addr)
+		go srvr.Serve(ln)
+
+		defer srvr.Close()
+	} else {
+		addr := &net.TCPAddr{
+			IP:   net.IPv4(127, 0, 0, 1),
+			Port: 3240,
+		}
+
+		log.Info(ctx, "starting USBIP server at %s", addr)
+		log.Info(ctx, "to connect the USB printer, run the following commands:")
+		log.Info(ctx, "  sudo modprobe vhci-hcd")
+		log.Info(ctx, "  sudo usbip attach -r localhost -b 1-1")
+
+		_, err := newUsbipServer(ctx, addr, mux)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Run external command if specified
+	if len(argv) != 0 {
+		return runner.Run(ctx, argv[0], argv[1:]...)
+	}
+
+	// Wait for termination signal
+	<-ctx.Done()
+	log.Info(ctx, "Exiting...")
+
+	return nil
+}
+
+// newMux creates the [transport.PathMux] with handlers for all
+// protocols, enabled by the model, and the [env.Runner], configured
+// accordingly.
+func newMux(model *modeling.Model,
+	portnum int) (*transport.PathMux, env.Runner) {
+
 	mux := transport.NewPathMux()
 	runner := env.Runner{}
 
 	// Add eSCL handler
-	if esclcaps := model.GetESCLScanCaps(); esclcaps != nil {
+	esclcaps := model.GetESCLScanCaps()
+	if esclcaps != nil && model.ProtocolEnabled(modeling.ProtoESCL) {
 		s := &abstract.VirtualScanner{
 			ScanCaps: esclcaps.ToAbstract(),
 			Resolution: abstract.Resolution{
@@ -49,16 +110,18 @@ func simulate(ctx context.Context, model *modeling.Model,
 			},
 		}
 
+		path := model.ProtocolPath(modeling.ProtoESCL)
 		handler := model.NewESCLServer(s)
-		mux.Add("/eSCL", handler)
+		mux.Add(path, handler)
 
 		runner.ESCLName = "Virtual MFP Scanner"
 		runner.ESCLPort = portnum
-		runner.ESCLPath = "/eSCL"
+		runner.ESCLPath = path
 	}
 
 	// Add WS-Scan handler
-	if wsdcaps := model.GetWSDScanCaps(); wsdcaps != nil {
+	wsdcaps := model.GetWSDScanCaps()
+	if wsdcaps != nil && model.ProtocolEnabled(modeling.ProtoWSD) {
 		s := &abstract.VirtualScanner{
 			ScanCaps: wsdcaps.ToAbstract(),
 			Resolution: abstract.Resolution{
@@ -73,64 +136,22 @@ func simulate(ctx context.Context, model *modeling.Model,
 			},
 		}
 
+		path := model.ProtocolPath(modeling.ProtoWSD)
 		handler := model.NewWSDServer(s)
-		mux.Add("/WSScan", handler)
+		mux.Add(path, handler)
 
 		runner.WSDName = "Virtual MFP Scanner"
 		runner.WSDPort = portnum
-		runner.WSDPath = "/WSScan"
+		runner.WSDPath = path
 	}
 
 	// Add IPP handler
-	if handler := model.NewIPPServer(); handler != nil {
-		mux.Add("/ipp/print", handler)
-		runner.CUPSPort = portnum
-	}
-
-	// Check that we have added at least something
-	if mux.Empty() {
-		return errors.New("model is emoty")
-	}
-
-	// Create server for incoming connections.
-	if !usbip {
-		addr := fmt.Sprintf("localhost:%d", portnum)
-
-		ln, err := net.Listen("tcp", addr)
-		if err != nil {
-			return err
-		}
-
-		srvr := transport.NewServer(ctx, nil, mux)
-		log.Info(ctx, "starting virtual MFP at http://%s", addr)
-		go srvr.Serve(ln)
-
-		defer srvr.Close()
-	} else {
-		addr := &net.TCPAddr{
-			IP:   net.IPv4(127, 0, 0, 1),
-			Port: 3240,
-		}
-
-		log.Info(ctx, "starting USBIP server at %s", addr)
-		log.Info(ctx, "to connect the USB printer, run the following commands:")
-		log.Info(ctx, "  sudo modprobe vhci-hcd")
-		log.Info(ctx, "  sudo usbip attach -r localhost -b 1-1")
-
-		_, err := newUsbipServer(ctx, addr, mux)
-		if err != nil {
-			return err
+	if model.ProtocolEnabled(modeling.ProtoIPP) {
+		if handler := model.NewIPPServer(); handler != nil {
+			mux.Add(model.ProtocolPath(modeling.ProtoIPP), handler)
+			runner.CUPSPort = portnum
 		}
 	}
 
-	// Run external command if specified
-	if len(argv) != 0 {
-		return runner.Run(ctx, argv[0], argv[1:]...)
-	}
-
-	// Wait for termination signal
-	<-ctx.Done()
-	log.Info(ctx, "Exiting...")
-
-	return nil
+	return mux, runner
 }
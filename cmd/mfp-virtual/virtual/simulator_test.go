@@ -0,0 +1,86 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// The "virtual" command
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Virtual MFP simulator test
+
+package virtual
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/internal/assert"
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/modeling"
+	"github.com/OpenPrinting/go-mfp/modeling/defaults"
+	"github.com/OpenPrinting/go-mfp/proto/escl"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestDeviceConfig tests that services are mounted according
+// to the model's device configuration.
+func TestDeviceConfig(t *testing.T) {
+	// Prepare the model with both IPP and eSCL, and IPP disabled
+	var msg goipp.Message
+	err := msg.DecodeBytes(testutils.Kyocera.ECOSYS.M2040dn.
+		IPP.PrinterAttributes)
+	assert.NoError(err)
+
+	pa, err := ipp.DecodePrinterAttributes(msg.Printer, nil)
+	assert.NoError(err)
+
+	model, err := modeling.NewModel()
+	assert.NoError(err)
+	defer model.Close()
+
+	model.SetIPPPrinterAttrs(pa)
+	model.SetESCLScanCaps(escl.FromAbstractScannerCapabilities(
+		escl.DefaultVersion, defaults.ScannerCapabilities()))
+	model.SetDeviceConfig(&modeling.DeviceConfig{
+		Protocols: map[string]bool{modeling.ProtoIPP: false},
+	})
+
+	// Load it via the model file
+	buf := &bytes.Buffer{}
+	err = model.Write(buf)
+	assert.NoError(err)
+
+	model2, err := modeling.NewModel()
+	assert.NoError(err)
+	defer model2.Close()
+
+	err = model2.Read("test", buf)
+	if err != nil {
+		t.Fatalf("Model.Read: %s", err)
+	}
+
+	// Run the server
+	mux, _ := newMux(model2, DefaultTCPPort)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	get := func(path string) int {
+		rsp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %s", path, err)
+		}
+		rsp.Body.Close()
+		return rsp.StatusCode
+	}
+
+	if status := get("/ipp/print"); status != http.StatusNotFound {
+		t.Errorf("IPP: expected %d, present %d",
+			http.StatusNotFound, status)
+	}
+
+	status := get("/eSCL/ScannerCapabilities")
+	if status != http.StatusOK {
+		t.Errorf("eSCL: expected %d, present %d", http.StatusOK, status)
+	}
+}
@@ -1,3 +1,5 @@
+//go:build !nocpython
+
 // MFP - Miulti-Function Printers and scanners toolkit
 // CPython binding.
 //
@@ -181,9 +183,12 @@ func callbackSetError(err error) {
 	except := Except("")
 	msg := ""
 
-	if err2, ok := err.(ErrPython); ok {
+	switch err2 := err.(type) {
+	case ErrPython:
 		except, msg = err2.except, err2.msg
-	} else {
+	case *ExcError:
+		except, msg = err2.Type, err2.Message
+	default:
 		msg = err.Error()
 	}
 
@@ -1,3 +1,5 @@
+//go:build !nocpython
+
 // MFP - Miulti-Function Printers and scanners toolkit
 // CPython binding.
 //
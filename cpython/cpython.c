@@ -1,3 +1,5 @@
+//go:build !nocpython
+
 // MFP - Miulti-Function Printers and scanners toolkit
 // CPython binding.
 //
@@ -127,6 +129,16 @@ static __typeof__(PyUnicode_FromStringAndSize)  *PyUnicode_FromStringAndSize_p;
 // so it needs the special care.
 static PyInterpreterState *(*PyInterpreterState_Get_p)(void);
 
+// Py_GetVersion is used for version probing.
+static const char *(*Py_GetVersion_p)(void);
+
+// Version-dependent symbols. They are loaded optionally, may be NULL
+// and used only when allowed by the version gating, performed on
+// the Go side. Their types are declared here explicitly, because
+// Python.h we are compiled with may not have their declarations.
+static void                                     (*PyEval_InitThreads_p)(void);
+static void                                     *Py_NewInterpreterFromConfig_p;
+
 // Directly exposed functions
 __typeof__(PyUnicode_GetLength)                  *PyUnicode_GetLength_p;
 
@@ -266,6 +278,18 @@ static void *py_load2 (const char *name1, const char *name2) {
     return p;
 }
 
+// py_load_opt loads optional Python symbol by name.
+// If symbol is missed, it returns NULL without setting py_error.
+static void *py_load_opt (const char *name) {
+    void *p = NULL;
+
+    if (py_error == NULL) {
+        p = dlsym(py_libpython3, name);
+    }
+
+    return p;
+}
+
 // py_load loads and dereferences pointer from the libpython3.so.
 static void *py_load_ptr (const char *name) {
     void **pp = py_load(name);
@@ -373,6 +397,10 @@ static void py_load_all (const char *libpython3) {
 
     PyUnicode_GetLength_p = py_load("PyUnicode_GetLength");
 
+    Py_GetVersion_p = py_load("Py_GetVersion");
+    PyEval_InitThreads_p = py_load_opt("PyEval_InitThreads");
+    Py_NewInterpreterFromConfig_p = py_load_opt("Py_NewInterpreterFromConfig");
+
     PyBool_Type_p = py_load("PyBool_Type");
     PyByteArray_Type_p = py_load("PyByteArray_Type");
     PyBytes_Type_p = py_load("PyBytes_Type");
@@ -459,7 +487,7 @@ static void py_load_all (const char *libpython3) {
     PyExc_Warning_p = py_load_ptr("PyExc_Warning");
 }
 
-// py_init initializes Python stuff.
+// py_init loads the Python library and all required symbols.
 // libpython3 must be a full path to the libpython3.XX.so library.
 //
 // It returns NULL on success or an error message in a case of errors.
@@ -468,12 +496,32 @@ static void py_load_all (const char *libpython3) {
 // This function MUST be called by the main Python thread only.
 const char *py_init (const char *libpython3) {
     py_load_all(libpython3);
+    return py_error;
+}
 
-    if (py_error != NULL) {
-        return py_error;
-    }
+// py_version returns the version string of the loaded Python
+// library, as returned by Py_GetVersion.
+//
+// It can be called after successful py_init, but before py_start.
+const char *py_version (void) {
+    return Py_GetVersion_p();
+}
 
+// py_start initializes the Python interpreter.
+//
+// If init_threads is true, PyEval_InitThreads is called, if available.
+//
+// It returns NULL on success or an error message in a case of errors.
+// This function needs to be called only once, after py_init.
+//
+// This function MUST be called by the main Python thread only.
+const char *py_start (bool init_threads) {
     Py_InitializeEx_p(0);
+
+    if (init_threads && PyEval_InitThreads_p != NULL) {
+        PyEval_InitThreads_p();
+    }
+
     py_main_thread = PyEval_SaveThread_p();
 
     return py_error;
@@ -481,17 +529,46 @@ const char *py_init (const char *libpython3) {
 
 // py_new_interp returns a new Python interpreter.
 //
+// If from_config is true, Py_NewInterpreterFromConfig is used,
+// if available.
+//
+// It returns NULL on success or an error message in a case of errors.
+//
 // This function MUST be called by the main Python thread only.
-void py_new_interp (PyThreadState **tstate_p, PyInterpreterState **interp_p) {
+const char *py_new_interp (PyThreadState **tstate_p,
+                           PyInterpreterState **interp_p,
+                           bool from_config) {
+    PyThreadState      *tstate = NULL;
+    PyInterpreterState *interp = NULL;
+    const char         *err = NULL;
+    bool               done = false;
+
     // This attaches the current OS thread to py_main_thread and
     // locks the GIL
     PyEval_RestoreThread_p(py_main_thread);
 
     // This creates an interpreter and the new thread state for it
     // and switches the current OS thread to the newly created
-    // thread state
-    PyThreadState *tstate = Py_NewInterpreter_p();
-    PyInterpreterState *interp = PyInterpreterState_Get_p();
+    // thread state.
+    //
+    // Since Python 3.12, Py_NewInterpreter terminates the process,
+    // if interpreter cannot be created, so we prefer
+    // Py_NewInterpreterFromConfig, which reports errors.
+    if (from_config && Py_NewInterpreterFromConfig_p != NULL) {
+        done = py_shim_new_interp_from_config(
+            Py_NewInterpreterFromConfig_p, &tstate, &err);
+    }
+
+    if (!done) {
+        tstate = Py_NewInterpreter_p();
+        if (tstate == NULL) {
+            err = "Py_NewInterpreter failed";
+        }
+    }
+
+    if (tstate != NULL) {
+        interp = PyInterpreterState_Get_p();
+    }
 
     // Here we switch back to the py_main_thread, which detaches
     // the newly created thread state from the OS thread
@@ -502,6 +579,8 @@ void py_new_interp (PyThreadState **tstate_p, PyInterpreterState **interp_p) {
 
     *tstate_p = tstate;
     *interp_p = interp;
+
+    return err;
 }
 
 // py_interp_close closes the Python interpreter.
@@ -556,6 +635,23 @@ void py_leave (void) {
     py_enter_level --;
 }
 
+// py_yield temporary releases the GIL, so other threads, waiting
+// for the interpreter, may run, and then reacquires it.
+//
+// The calling thread must be attached to the interpreter.
+void py_yield (void) {
+    assert(py_thread_current != NULL);
+
+    PyThreadState *tstate = PyEval_SaveThread_p();
+    PyEval_RestoreThread_p(tstate);
+}
+
+// py_attached reports if the calling thread is attached to
+// the Python interpreter (i.e., holds the GIL).
+bool py_attached (void) {
+    return py_thread_current != NULL;
+}
+
 // py_interp_eval evaluates string as a Python statement or expression.
 // It returns, via the 'res' pointer, the strong reference to the Python
 // value of the executed statement.
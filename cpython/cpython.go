@@ -1,3 +1,5 @@
+//go:build !nocpython
+
 // MFP - Miulti-Function Printers and scanners toolkit
 // CPython binding.
 //
@@ -11,6 +13,7 @@ package cpython
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
@@ -57,6 +60,7 @@ var pyInterpDeleteRequestChan = make(chan pyInterpHandles)
 type pyInterpHandles struct {
 	tstate pyThreadState
 	interp pyInterpState
+	err    error
 }
 
 // pyNewInterp creates a new Python sub-interpreter and returns
@@ -70,12 +74,12 @@ func pyNewInterp() (pyThreadState, pyInterpState, error) {
 	pyInterpNewRequestChan <- rsp
 	response := <-rsp
 
-	return response.tstate, response.interp, nil
+	return response.tstate, response.interp, response.err
 }
 
 // pyInterpDelete releases the Python sub-interpreter
 func pyInterpDelete(tstate pyThreadState, interp pyInterpState) {
-	pyInterpDeleteRequestChan <- pyInterpHandles{tstate, interp}
+	pyInterpDeleteRequestChan <- pyInterpHandles{tstate, interp, nil}
 }
 
 // pyLocateLibPython locates the full path to the libpython3.XX.so library
@@ -118,7 +122,7 @@ func pyInterpThread(initilized *sync.WaitGroup) {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 
-	// Locate and initialize Python library
+	// Locate and load Python library
 	var lib string
 	lib, pyInitError = pyLocateLibPython()
 	if pyInitError == nil {
@@ -129,6 +133,22 @@ func pyInterpThread(initilized *sync.WaitGroup) {
 		pyInitErrorCheck(msg)
 	}
 
+	// Check Python version before we start to use it
+	if pyInitError == nil {
+		s := C.GoString(C.py_version())
+		pyRuntimeVersion, pyInitError = pyParseVersion(s)
+		if pyInitError == nil {
+			pyInitError = pyRuntimeVersion.check()
+		}
+	}
+
+	// Initialize Python
+	if pyInitError == nil {
+		initThreads := pyRuntimeVersion.has(pyFeatInitThreads)
+		msg := C.py_start(C.bool(initThreads))
+		pyInitErrorCheck(msg)
+	}
+
 	// Initialize exceptions
 	if pyInitError == nil {
 		exceptInit()
@@ -142,10 +162,16 @@ func pyInterpThread(initilized *sync.WaitGroup) {
 		for {
 			select {
 			case rq := <-pyInterpNewRequestChan:
-				var tstate pyThreadState
-				var interp pyInterpState
-				C.py_new_interp(&tstate, &interp)
-				rq <- pyInterpHandles{tstate, interp}
+				var handles pyInterpHandles
+				fromConfig := pyRuntimeVersion.has(
+					pyFeatNewInterpFromConfig)
+				msg := C.py_new_interp(&handles.tstate,
+					&handles.interp, C.bool(fromConfig))
+				if msg != nil {
+					handles.err = fmt.Errorf("CPython: %s",
+						C.GoString(msg))
+				}
+				rq <- handles
 
 			case rq := <-pyInterpDeleteRequestChan:
 				C.py_interp_close(rq.tstate, rq.interp)
@@ -17,7 +17,7 @@
 #include <stdio.h>
 #include <stdlib.h>
 
-// py_init initializes Python stuff.
+// py_init loads the Python library and all required symbols.
 // libpython3 must be a full path to the libpython3.XX.so library.
 //
 // It returns NULL on success or an error message in a case of errors.
@@ -26,10 +26,45 @@
 // This function MUST be called by the main Python thread only.
 const char *py_init (const char *libpython3);
 
+// py_version returns the version string of the loaded Python
+// library, as returned by Py_GetVersion.
+//
+// It can be called after successful py_init, but before py_start.
+const char *py_version (void);
+
+// py_start initializes the Python interpreter.
+//
+// If init_threads is true, PyEval_InitThreads is called, if available.
+//
+// It returns NULL on success or an error message in a case of errors.
+// This function needs to be called only once, after py_init.
+//
+// This function MUST be called by the main Python thread only.
+const char *py_start (bool init_threads);
+
 // py_new_interp returns a new Python interpreter.
 //
+// If from_config is true, Py_NewInterpreterFromConfig is used,
+// if available.
+//
+// It returns NULL on success or an error message in a case of errors.
+//
 // This function MUST be called by the main Python thread only.
-void py_new_interp (PyThreadState **tstate_p, PyInterpreterState **interp_p);
+const char *py_new_interp (PyThreadState **tstate_p,
+                           PyInterpreterState **interp_p,
+                           bool from_config);
+
+// py_shim_new_interp_from_config creates a new Python interpreter
+// with the legacy (shared GIL) configuration, using the
+// Py_NewInterpreterFromConfig function, pointed by fn.
+//
+// It returns false, if shim is not compiled in (see the
+// cpython_noshim build tag). Otherwise, it returns true and
+// the error message, if any, via the err_p pointer.
+//
+// This function is implemented in the version-specific C file.
+bool py_shim_new_interp_from_config (void *fn, PyThreadState **tstate_p,
+                                     const char **err_p);
 
 // py_interp_close closes the Python interpreter.
 //
@@ -46,6 +81,14 @@ void py_enter (PyInterpreterState *interp);
 // py_leave detaches the calling thread from the Python interpreter.
 void py_leave (void);
 
+// py_yield temporary releases the GIL, so other threads, waiting
+// for the interpreter, may run, and then reacquires it.
+void py_yield (void);
+
+// py_attached reports if the calling thread is attached to
+// the Python interpreter (i.e., holds the GIL).
+bool py_attached (void);
+
 // py_interp_eval evaluates string as a Python statement or expression.
 // It returns, via the 'res' pointer, the strong reference to the Python
 // value of the executed statement.
@@ -0,0 +1,21 @@
+//go:build cpython_noshim && !nocpython
+
+// MFP - Miulti-Function Printers and scanners toolkit
+// CPython binding.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// CPython glue -- stubs for disabled version-specific shims
+
+#include "cpython.h"
+
+// py_shim_new_interp_from_config is the stub, that always
+// returns false, so Py_NewInterpreter is used instead.
+bool py_shim_new_interp_from_config (void *fn, PyThreadState **tstate_p,
+                                     const char **err_p) {
+    (void) fn;
+    (void) tstate_p;
+    (void) err_p;
+    return false;
+}
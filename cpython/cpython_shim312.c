@@ -0,0 +1,81 @@
+//go:build !cpython_noshim && !nocpython
+
+// MFP - Miulti-Function Printers and scanners toolkit
+// CPython binding.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// CPython glue -- shims for Python 3.12 and up
+//
+// These shims use the non-stable API, so declarations are
+// duplicated here, to not depend on the Python.h version
+// we are compiled with. Build with the cpython_noshim tag
+// to disable them.
+
+#include "cpython.h"
+
+// py_shim_interp_config mirrors PyInterpreterConfig, as defined
+// by Python 3.12 and 3.13.
+typedef struct {
+    int use_main_obmalloc;
+    int allow_fork;
+    int allow_exec;
+    int allow_threads;
+    int allow_daemon_threads;
+    int check_multi_interp_extensions;
+    int gil;
+} py_shim_interp_config;
+
+// PyInterpreterConfig.gil values
+enum {
+    py_shim_gil_default = 0,
+    py_shim_gil_shared  = 1,
+    py_shim_gil_own     = 2
+};
+
+// py_shim_status mirrors PyStatus, as defined by Python 3.8 and up.
+typedef struct {
+    enum {
+        py_shim_status_ok    = 0,
+        py_shim_status_error = 1,
+        py_shim_status_exit  = 2
+    } type;
+    const char *func;
+    const char *err_msg;
+    int exitcode;
+} py_shim_status;
+
+// py_shim_new_interp_from_config creates a new Python interpreter
+// with the legacy (shared GIL) configuration, using the
+// Py_NewInterpreterFromConfig function, pointed by fn.
+bool py_shim_new_interp_from_config (void *fn, PyThreadState **tstate_p,
+                                     const char **err_p) {
+    py_shim_status (*new_interp) (PyThreadState **,
+                                  const py_shim_interp_config *) = fn;
+
+    // This is _PyInterpreterConfig_LEGACY_INIT, the same
+    // configuration as used by Py_NewInterpreter
+    py_shim_interp_config config = {
+        .use_main_obmalloc = 1,
+        .allow_fork = 1,
+        .allow_exec = 1,
+        .allow_threads = 1,
+        .allow_daemon_threads = 1,
+        .check_multi_interp_extensions = 0,
+        .gil = py_shim_gil_shared,
+    };
+
+    *tstate_p = NULL;
+    py_shim_status status = new_interp(tstate_p, &config);
+
+    if (status.type != py_shim_status_ok) {
+        *tstate_p = NULL;
+        *err_p = status.err_msg;
+        if (*err_p == NULL) {
+            *err_p = "Py_NewInterpreterFromConfig failed";
+        }
+    }
+
+    return true;
+}
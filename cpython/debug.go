@@ -0,0 +1,46 @@
+//go:build !nocpython
+
+// MFP - Miulti-Function Printers and scanners toolkit
+// CPython binding.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Debug assertions
+
+package cpython
+
+import (
+	"sync/atomic"
+
+	"github.com/OpenPrinting/go-mfp/internal/assert"
+)
+
+// #include "cpython.h"
+import "C"
+
+// debugAssertions enables debug assertions
+var debugAssertions atomic.Bool
+
+// SetDebugAssertions enables or disables the debug assertion mode.
+//
+// In this mode, every low-level operation with the Python
+// interpreter verifies that the calling thread actually holds
+// the GIL, and panics otherwise. Object methods acquire the GIL
+// by themselves, so these assertions catch internal bugs, like
+// use of the interpreter after the GIL is released.
+//
+// The debug assertion mode costs some performance and intended
+// for testing.
+func SetDebugAssertions(enable bool) {
+	debugAssertions.Store(enable)
+}
+
+// assertAttached panics in the debug assertion mode, if the
+// calling thread doesn't hold the GIL.
+func (gate pyGate) assertAttached() {
+	if debugAssertions.Load() {
+		assert.MustMsg(bool(C.py_attached()),
+			"cpython: interpreter used without GIL")
+	}
+}
@@ -0,0 +1,53 @@
+//go:build !nocpython
+
+// MFP - Miulti-Function Printers and scanners toolkit
+// CPython binding.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Debug assertions tests
+
+package cpython
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/internal/assert"
+)
+
+// TestDebugAssertions tests the debug assertion mode
+func TestDebugAssertions(t *testing.T) {
+	SetDebugAssertions(true)
+	defer SetDebugAssertions(false)
+
+	py, err := NewPython()
+	assert.NoError(err)
+	defer py.Close()
+
+	// Normal operations must work
+	obj := py.Eval("[i*i for i in range(1000)]")
+	assert.NoError(obj.Err())
+
+	items, err := obj.Slice()
+	assert.NoError(err)
+	if len(items) != 1000 {
+		t.Errorf("Slice: expected 1000 items, present %d", len(items))
+	}
+
+	// Deliberately incorrect call: pyGate is used without
+	// acquiring the GIL.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var msg any
+	func() {
+		defer func() { msg = recover() }()
+		pyGate{}.ref(nil)
+	}()
+
+	if msg == nil {
+		t.Errorf("use without GIL: assertion not triggered")
+	}
+}
@@ -6,4 +6,16 @@
 //
 // Package documentation
 
+// Package cpython implements simple Golang to CPython binding.
+//
+// CPython library is loaded at runtime, but building the package
+// requires the Python headers (i.e., python3-dev). On systems where
+// they are not available, build with the nocpython tag:
+//
+//	go build -tags nocpython ./...
+//
+// With this tag, the package provides stub implementation of the
+// same API: [NewPython] returns [ErrNotAvailable], and all [Object]
+// methods return the error Object with this error. Tests that
+// require Python are disabled.
 package cpython
@@ -9,6 +9,7 @@
 package cpython
 
 import (
+	"bytes"
 	"fmt"
 )
 
@@ -39,6 +40,81 @@ func (e ErrPython) Is(target error) bool {
 	return false
 }
 
+// ExcError represents a Python exception, raised by the Python code,
+// with its full details: exception type, message, traceback and
+// the chained exception, if any.
+//
+// ExcError is returned as *ExcError and can be obtained from the
+// returned error using [errors.As].
+type ExcError struct {
+	Type    Except    // Exception type
+	Message string    // Exception message
+	Frames  []Frame   // Traceback, the most recent call last
+	Cause   *ExcError // Chained exception, nil if none
+
+	// Error location, appended to the error message. Normally,
+	// it is the location of the last frame, but may be overridden.
+	file string
+	line int
+}
+
+// Frame represents a single frame of the Python traceback.
+type Frame struct {
+	File     string // Source file name
+	Line     int    // Line number
+	Function string // Function name
+}
+
+// Error returns error message. It implements the [error] interface.
+func (e *ExcError) Error() string {
+	s := string(e.Type) + ": " + e.Message
+	if e.file != "" {
+		s += fmt.Sprintf(" (%s, line %d)", e.file, e.line)
+	}
+	return s
+}
+
+// Is reports if ExcError matches the target error.
+//
+// ExcError matches on the following cases:
+//   - target is [Except] and exception type is the same
+//   - target is [ErrPython] with the same exception type and
+//     error message
+func (e *ExcError) Is(target error) bool {
+	switch target := target.(type) {
+	case Except:
+		return e.Type == target
+	case ErrPython:
+		return e.Error() == target.Error()
+	}
+
+	return false
+}
+
+// MarshalLog formats ExcError for logging. It renders the error
+// line, followed by the indented traceback and chained exceptions.
+//
+// It implements the log.Marshaler interface.
+func (e *ExcError) MarshalLog() []byte {
+	buf := &bytes.Buffer{}
+
+	for exc := e; exc != nil; exc = exc.Cause {
+		if exc != e {
+			buf.WriteString("Caused by: ")
+		}
+
+		buf.WriteString(exc.Error())
+		buf.WriteByte('\n')
+
+		for _, frame := range exc.Frames {
+			fmt.Fprintf(buf, "  File %q, line %d, in %s\n",
+				frame.File, frame.Line, frame.Function)
+		}
+	}
+
+	return buf.Bytes()
+}
+
 // ErrTypeConversion represents Go<->Python type conversion error.
 type ErrTypeConversion struct {
 	from, to string // from/to types that can't be converted
@@ -69,6 +145,16 @@ func (e ErrClosed) Error() string {
 	return "use Python interpreter after Python.Close"
 }
 
+// ErrNotAvailable represents the error that occurs when package
+// is built with the nocpython tag and Python is not available.
+// See [NewPython] for details.
+type ErrNotAvailable struct{}
+
+// Error returns error message. It implements the [error] interface.
+func (e ErrNotAvailable) Error() string {
+	return "Python is not available (built with nocpython tag)"
+}
+
 // ErrInvalidObject represents the error that occurs when [Object]
 // accessed after call to [Object.Invalidate]
 type ErrInvalidObject struct{}
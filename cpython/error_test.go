@@ -1,3 +1,5 @@
+//go:build !nocpython
+
 // MFP - Multi-Function Printers and scanners toolkit
 // CPython binding.
 //
@@ -9,6 +11,7 @@ package cpython
 
 import (
 	"errors"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -16,6 +19,7 @@ import (
 // Compile-time assertions that all error types implement the error interface.
 var (
 	_ error = ErrPython{}
+	_ error = &ExcError{}
 	_ error = ErrTypeConversion{}
 	_ error = ErrOverflow{}
 	_ error = ErrClosed{}
@@ -108,3 +112,141 @@ func TestErrNotFound(t *testing.T) {
 		t.Fatalf("ErrNotFound.Error() returned empty string")
 	}
 }
+
+// TestExcError verifies decoding of Python exceptions, raised by
+// the script, with traceback and chained exceptions.
+func TestExcError(t *testing.T) {
+	py, err := NewPython()
+	if err != nil {
+		t.Fatalf("NewPython: %s", err)
+	}
+	defer py.Close()
+
+	// Note, line numbers matter here
+	const script = "" +
+		"def inner():\n" +
+		"    raise ValueError('inner')\n" +
+		"\n" +
+		"def outer():\n" +
+		"    try:\n" +
+		"        inner()\n" +
+		"    except ValueError as e:\n" +
+		"        raise KeyError('outer') from e\n" +
+		"\n" +
+		"outer()\n"
+
+	err = py.Exec(script, "test.py")
+
+	var exc *ExcError
+	if !errors.As(err, &exc) {
+		t.Fatalf("errors.As: *ExcError expected, present %T", err)
+	}
+
+	if !errors.Is(err, KeyError) {
+		t.Errorf("errors.Is(err, KeyError): false")
+	}
+
+	expected := &ExcError{
+		Type:    KeyError,
+		Message: "'outer'",
+		Frames: []Frame{
+			{"test.py", 10, "<module>"},
+			{"test.py", 8, "outer"},
+		},
+		Cause: &ExcError{
+			Type:    ValueError,
+			Message: "inner",
+			Frames: []Frame{
+				{"test.py", 6, "outer"},
+				{"test.py", 2, "inner"},
+			},
+			file: "test.py",
+			line: 2,
+		},
+	}
+
+	// Location may be overridden by the Exec, so don't
+	// compare it.
+	expected.file, expected.line = exc.file, exc.line
+
+	if !reflect.DeepEqual(exc, expected) {
+		t.Errorf("ExcError mismatch:\n"+
+			"expected:\n%s\npresent:\n%s",
+			expected.MarshalLog(), exc.MarshalLog())
+	}
+
+	// Check MarshalLog output
+	log := string(exc.MarshalLog())
+	for _, s := range []string{
+		"KeyError: 'outer'",
+		`  File "test.py", line 8, in outer`,
+		"Caused by: ValueError: inner",
+		`  File "test.py", line 2, in inner`,
+	} {
+		if !strings.Contains(log, s) {
+			t.Errorf("MarshalLog: missed %q in:\n%s", s, log)
+		}
+	}
+}
+
+// TestExcErrorContext verifies handling of the implicitly chained
+// exceptions and suppression of the exception context.
+func TestExcErrorContext(t *testing.T) {
+	py, err := NewPython()
+	if err != nil {
+		t.Fatalf("NewPython: %s", err)
+	}
+	defer py.Close()
+
+	tests := []struct {
+		script string
+		cause  Except
+	}{
+		{
+			script: "" +
+				"try:\n" +
+				"    {}['missed']\n" +
+				"except KeyError:\n" +
+				"    raise RuntimeError('implicit')\n",
+			cause: KeyError,
+		},
+
+		{
+			script: "" +
+				"try:\n" +
+				"    {}['missed']\n" +
+				"except KeyError:\n" +
+				"    raise RuntimeError('suppressed') from None\n",
+		},
+
+		{
+			script: "raise RuntimeError('alone')\n",
+		},
+	}
+
+	for _, test := range tests {
+		err := py.Exec(test.script, "test.py")
+
+		var exc *ExcError
+		if !errors.As(err, &exc) {
+			t.Errorf("%q: *ExcError expected, present %T",
+				test.script, err)
+			continue
+		}
+
+		if exc.Type != RuntimeError {
+			t.Errorf("%q: Type: expected %s, present %s",
+				test.script, RuntimeError, exc.Type)
+		}
+
+		var cause Except
+		if exc.Cause != nil {
+			cause = exc.Cause.Type
+		}
+
+		if cause != test.cause {
+			t.Errorf("%q: Cause: expected %q, present %q",
+				test.script, test.cause, cause)
+		}
+	}
+}
@@ -54,37 +54,6 @@ func TestExceptConstants(t *testing.T) {
 	}
 }
 
-// TestExceptObjectKnown verifies that object() returns non-nil
-// for all known standard exceptions.
-func TestExceptObjectKnown(t *testing.T) {
-	known := []Except{
-		ArithmeticError, AssertionError, AttributeError,
-		BlockingIOError, EOFError, Exception,
-		FileNotFoundError, ImportError, IndexError,
-		KeyError, MemoryError, NameError,
-		NotImplementedError, OSError, OverflowError,
-		RuntimeError, StopIteration, SyntaxError,
-		SystemError, TypeError, ValueError,
-		ZeroDivisionError, DeprecationWarning,
-		RuntimeWarning, UserWarning, Warning,
-	}
-	for _, ex := range known {
-		if ex.object() == nil {
-			t.Fatalf("Except(%q).object() returned nil", ex)
-		}
-	}
-}
-
-// TestExceptObjectUnknown verifies that object() falls back to
-// SystemError for unknown exception names.
-func TestExceptObjectUnknown(t *testing.T) {
-	unknown := Except("NoSuchException")
-	obj := unknown.object()
-	if obj == nil {
-		t.Fatalf("Except(%q).object() returned nil, want SystemError fallback", unknown)
-	}
-}
-
 // TestExceptError verifies that the Error() method returns the exception
 // name as a string, satisfying the error interface.
 func TestExceptError(t *testing.T) {
@@ -0,0 +1,43 @@
+//go:build !nocpython
+
+// MFP - Multi-Function Printers and scanners toolkit
+// CPython binding.
+//
+// Copyright (C) 2026 and up by Abhishrestha Tiwari
+// See LICENSE for license terms and conditions
+//
+// Tests for Python exceptions mapping to Python objects
+package cpython
+
+import "testing"
+
+// TestExceptObjectKnown verifies that object() returns non-nil
+// for all known standard exceptions.
+func TestExceptObjectKnown(t *testing.T) {
+	known := []Except{
+		ArithmeticError, AssertionError, AttributeError,
+		BlockingIOError, EOFError, Exception,
+		FileNotFoundError, ImportError, IndexError,
+		KeyError, MemoryError, NameError,
+		NotImplementedError, OSError, OverflowError,
+		RuntimeError, StopIteration, SyntaxError,
+		SystemError, TypeError, ValueError,
+		ZeroDivisionError, DeprecationWarning,
+		RuntimeWarning, UserWarning, Warning,
+	}
+	for _, ex := range known {
+		if ex.object() == nil {
+			t.Fatalf("Except(%q).object() returned nil", ex)
+		}
+	}
+}
+
+// TestExceptObjectUnknown verifies that object() falls back to
+// SystemError for unknown exception names.
+func TestExceptObjectUnknown(t *testing.T) {
+	unknown := Except("NoSuchException")
+	obj := unknown.object()
+	if obj == nil {
+		t.Fatalf("Except(%q).object() returned nil, want SystemError fallback", unknown)
+	}
+}
@@ -1,3 +1,5 @@
+//go:build !nocpython
+
 // MFP - Miulti-Function Printers and scanners toolkit
 // CPython binding.
 //
@@ -9,7 +11,6 @@
 package cpython
 
 import (
-	"fmt"
 	"math/big"
 	"runtime"
 	"strings"
@@ -49,6 +50,17 @@ func (gate pyGate) release() {
 	runtime.UnlockOSThread()
 }
 
+// yield temporary releases the GIL and then reacquires it,
+// giving a chance to other threads, waiting for the interpreter.
+//
+// Borrowed references are not protected while the GIL is
+// released, so the caller must own strong references to all
+// Python objects it continues to use after yield returns.
+func (gate pyGate) yield() {
+	gate.assertAttached()
+	C.py_yield()
+}
+
 // lastError returns a last error, nil if none.
 func (gate pyGate) lastError() error {
 	return gate.lastErrorAt("", -1)
@@ -71,76 +83,150 @@ func (gate pyGate) lastErrorAt(file string, line int) error {
 	defer C.py_obj_unref(trace)
 
 	// Decode the error
-	except := SystemError // The default
-	msg := "Unknown Python exception"
-	var needLocation bool
+	exc := gate.decodeExc(etype, evalue, trace, excMaxCauses)
+	if exc.file != "" && file != "" && line >= 0 {
+		exc.file, exc.line = file, line
+	}
+
+	return exc
+}
+
+// excMaxCauses limits the length of the decoded chain of exceptions.
+// Python allows cycles in this chain, so we need some limit.
+const excMaxCauses = 16
+
+// decodeExc decodes Python exception into the [ExcError].
+//
+// The maxCauses parameter limits the depth of decoding of the
+// chained exceptions.
+func (gate pyGate) decodeExc(etype, evalue, trace pyObject,
+	maxCauses int) *ExcError {
+
+	exc := &ExcError{
+		Type:    SystemError, // The default
+		Message: "Unknown Python exception",
+	}
 
 	if etype != nil {
-		var nm string
 		if tmp, _ := gate.getattr(etype, "__name__"); tmp != nil {
 			nm, _ := gate.str(tmp)
 			C.py_obj_unref(tmp)
 			if nm != "" {
-				except = Except(nm)
+				exc.Type = Except(nm)
 			}
 		}
-
-		// Some exception types, like SyntaxError, already
-		// come with the file:line information. Others require
-		// additional effort...
-		switch nm {
-		case "SyntaxError":
-		default:
-			needLocation = true
-		}
 	}
 
 	if evalue != nil {
 		s, _ := gate.str(evalue)
 		if s != "" {
-			msg = s
+			exc.Message = s
 		}
 	}
 
-	if needLocation && trace != nil {
-		var err error
-		if file == "" || line < 0 {
-			file, line, err = gate.lastErrorLocation(trace)
-		}
-		if err == nil {
-			msg += fmt.Sprintf(" (%s, line %d)", file, line)
-		}
+	if trace != nil {
+		exc.Frames = gate.decodeTraceback(trace)
+	}
+
+	// Some exception types, like SyntaxError, already
+	// come with the file:line information. Others require
+	// additional effort...
+	if exc.Type != SyntaxError && len(exc.Frames) != 0 {
+		last := exc.Frames[len(exc.Frames)-1]
+		exc.file, exc.line = last.File, last.Line
+	}
+
+	if evalue != nil && maxCauses > 0 {
+		exc.Cause = gate.decodeExcCause(evalue, maxCauses-1)
 	}
 
-	return ErrPython{except, msg}
+	return exc
 }
 
-// lastErrorLocation extracts file and line information out of the
-// traceback object.
-func (gate pyGate) lastErrorLocation(trace pyObject) (
-	file string, line int, err error) {
+// decodeExcCause decodes the chained exception, if any.
+//
+// The explicitly chained exception (raise ... from ...) comes
+// from the __cause__ attribute. Otherwise, exception raised
+// while handling another exception is implicitly chained via
+// the __context__ attribute, unless __suppress_context__ is set.
+func (gate pyGate) decodeExcCause(evalue pyObject, maxCauses int) *ExcError {
+	cause, err := gate.getattr(evalue, "__cause__")
+	if err != nil {
+		return nil
+	}
 
-	var lineno, frame, code, filename pyObject
+	if gate.isNone(cause) {
+		C.py_obj_unref(cause)
 
-	// follow trace = trace.tb_next, until latest frame is reached.
-	for {
-		var next pyObject
-		next, err = gate.getattr(trace, "tb_next")
+		if tmp, _ := gate.getattr(evalue, "__suppress_context__"); tmp != nil {
+			suppress, _ := gate.str(tmp)
+			C.py_obj_unref(tmp)
+			if suppress == "True" {
+				return nil
+			}
+		}
+
+		cause, err = gate.getattr(evalue, "__context__")
 		if err != nil {
-			return
+			return nil
+		}
+	}
+
+	defer C.py_obj_unref(cause)
+	if gate.isNone(cause) {
+		return nil
+	}
+
+	trace, _ := gate.getattr(cause, "__traceback__")
+	if trace != nil {
+		defer C.py_obj_unref(trace)
+		if gate.isNone(trace) {
+			trace = nil
 		}
-		defer C.py_obj_unref(next)
+	}
+
+	etype := pyObject(unsafe.Pointer(C.py_obj_type(cause)))
+	return gate.decodeExc(etype, cause, trace, maxCauses)
+}
 
-		// Note, we don't have here a convenient access to
-		// the None object to compare, so just run the loop
-		// while trace.tb_next is of the same type as trace.
-		if C.py_obj_type(next) != C.py_obj_type(trace) {
-			break
+// decodeTraceback extracts the traceback frames out of the
+// traceback object.
+//
+// Frames that cannot be decoded are silently skipped.
+func (gate pyGate) decodeTraceback(trace pyObject) []Frame {
+	var frames []Frame
+
+	// Note, we don't have here a convenient access to the None
+	// object to compare, so just run the loop while trace.tb_next
+	// is of the same type as trace.
+	traceType := C.py_obj_type(trace)
+
+	gate.ref(trace)
+	for trace != nil && C.py_obj_type(trace) == traceType {
+		frame, err := gate.decodeTracebackFrame(trace)
+		if err == nil {
+			frames = append(frames, frame)
 		}
 
+		// trace = trace.tb_next
+		next, _ := gate.getattr(trace, "tb_next")
+		C.py_obj_unref(trace)
 		trace = next
 	}
 
+	if trace != nil {
+		C.py_obj_unref(trace)
+	}
+
+	return frames
+}
+
+// decodeTracebackFrame decodes a single traceback frame.
+func (gate pyGate) decodeTracebackFrame(trace pyObject) (
+	frame Frame, err error) {
+
+	var lineno, pyframe, code, filename, name pyObject
+
 	// lineno = trace.tb_lineno
 	lineno, err = gate.getattr(trace, "tb_lineno")
 	if err != nil {
@@ -148,15 +234,15 @@ func (gate pyGate) lastErrorLocation(trace pyObject) (
 	}
 	defer C.py_obj_unref(lineno)
 
-	// frame = trace.tb_frame
-	frame, err = gate.getattr(trace, "tb_frame")
+	// pyframe = trace.tb_frame
+	pyframe, err = gate.getattr(trace, "tb_frame")
 	if err != nil {
 		return
 	}
-	defer C.py_obj_unref(frame)
+	defer C.py_obj_unref(pyframe)
 
-	// code = frame.f_code
-	code, err = gate.getattr(frame, "f_code")
+	// code = pyframe.f_code
+	code, err = gate.getattr(pyframe, "f_code")
 	if err != nil {
 		return
 	}
@@ -169,21 +255,45 @@ func (gate pyGate) lastErrorLocation(trace pyObject) (
 	}
 	defer C.py_obj_unref(filename)
 
-	// Now convert filename and lineno from Python to go
-	file, err = gate.decodeUnicode(filename)
+	// name = code.co_name
+	name, err = gate.getattr(code, "co_name")
+	if err != nil {
+		return
+	}
+	defer C.py_obj_unref(name)
+
+	// Now convert everything from Python to go
+	frame.File, err = gate.decodeUnicode(filename)
 	if err != nil {
 		return
 	}
 
+	frame.Function, err = gate.decodeUnicode(name)
+	if err != nil {
+		return
+	}
+
+	// Since Python 3.12, tb_lineno may be None, if line number
+	// is not known. Keep the frame, but leave Line as zero.
+	if pyRuntimeVersion.has(pyFeatTracebackLinenoNone) &&
+		gate.isNone(lineno) {
+		return
+	}
+
 	n, err := gate.decodeUint64(lineno)
 	if err != nil {
 		return
 	}
 
-	line = int(n)
+	frame.Line = int(n)
 	return
 }
 
+// isNone reports if PyObject is None.
+func (gate pyGate) isNone(pyobj pyObject) bool {
+	return gate.typename(pyobj) == "NoneType"
+}
+
 // objOrLastError returns pyobj, if it is not nil, or gate.lastError()
 func (gate pyGate) objOrLastError(pyobj pyObject) (pyObject, error) {
 	if pyobj != nil {
@@ -195,11 +305,13 @@ func (gate pyGate) objOrLastError(pyobj pyObject) (pyObject, error) {
 
 // ref increments PyObject's reference count.
 func (gate pyGate) ref(pyobj pyObject) {
+	gate.assertAttached()
 	C.py_obj_ref(pyobj)
 }
 
 // unref decrements PyObject's reference count.
 func (gate pyGate) unref(pyobj pyObject) {
+	gate.assertAttached()
 	C.py_obj_unref(pyobj)
 }
 
@@ -305,6 +417,7 @@ func (gate pyGate) delattr(pyobj pyObject, name string) error {
 
 // getattr returns Object attribute with the specified name.
 func (gate pyGate) getattr(pyobj pyObject, name string) (pyObject, error) {
+	gate.assertAttached()
 	cname := C.CString(name)
 	defer C.free(unsafe.Pointer(cname))
 
@@ -356,6 +469,7 @@ func (gate pyGate) delitem(pyobj, key pyObject) error {
 //
 //	pyobj[key]
 func (gate pyGate) getitem(pyobj, key pyObject) (pyObject, error) {
+	gate.assertAttached()
 	var item pyObject
 	if !bool(C.py_obj_getitem(pyobj, key, &item)) {
 		return nil, gate.lastError()
@@ -393,6 +507,7 @@ func (gate pyGate) setitem(pyobj, key, val pyObject) error {
 //
 // It returns strong reference to result on success, nil on an error.
 func (gate pyGate) call(callable, args, kwargs pyObject) (pyObject, error) {
+	gate.assertAttached()
 	return gate.objOrLastError(C.py_obj_call(callable, args, kwargs))
 }
 
@@ -780,6 +895,7 @@ func (gate pyGate) setListItem(list, item pyObject, idx int) error {
 
 // getSeqItem retrieves the item of the sequence the specified position.
 func (gate pyGate) getSeqItem(tuple pyObject, idx int) (pyObject, error) {
+	gate.assertAttached()
 	item := C.py_seq_get(tuple, C.int(idx))
 	return gate.objOrLastError(item)
 }
@@ -809,6 +925,8 @@ func (gate pyGate) setTupleItem(tuple, item pyObject, idx int) error {
 // interpreted as a multi-line Python script, and returned *Object
 // will be nil.
 func (gate pyGate) eval(s, name string, expr bool) (pyObject, error) {
+	gate.assertAttached()
+
 	// Convert expression and filename to the C strings
 	cs := C.CString(s)
 	defer C.free(unsafe.Pointer(cs))
@@ -1,3 +1,5 @@
+//go:build !nocpython
+
 // MFP - Miulti-Function Printers and scanners toolkit
 // CPython binding.
 //
@@ -801,8 +803,16 @@ func objDoNoError[T any](obj *Object, f func(pyGate, pyObject) T) T {
 	return f(gate, pyobj)
 }
 
+// objSliceChunk limits count of items, converted by objSlice
+// without temporary releasing the GIL.
+const objSliceChunk = 256
+
 // objSlice is the helper function that extracts contained objects from the
 // sequence object and converts extracted objects into the []*Object slice.
+//
+// Long sequences are converted in chunks of objSliceChunk items,
+// and the GIL is temporary released between chunks, so conversion
+// doesn't stall other users of the interpreter.
 func objSlice(py *Python, gate pyGate, pyobj pyObject) ([]*Object, error) {
 	// Check that object is sequence
 	if !gate.isSeq(pyobj) {
@@ -815,22 +825,30 @@ func objSlice(py *Python, gate pyGate, pyobj pyObject) ([]*Object, error) {
 		return nil, err
 	}
 
-	// Obtain items
-	pyobjects := make([]pyObject, length)
-	for i := range pyobjects {
-		pyobjects[i], err = gate.getSeqItem(pyobj, i)
+	// Keep the sequence alive while the GIL is released.
+	// Note, the sequence may be modified meanwhile by other
+	// threads, then getSeqItem fails with IndexError.
+	gate.ref(pyobj)
+	defer gate.unref(pyobj)
+
+	// Extract items and convert into []*Object
+	objects := make([]*Object, 0, length)
+	for i := 0; i < length; i++ {
+		if i != 0 && i%objSliceChunk == 0 {
+			py.yield(gate)
+		}
+
+		var item pyObject
+		item, err = gate.getSeqItem(pyobj, i)
 		if err != nil {
-			for j := 0; j < i; j++ {
-				gate.unref(pyobjects[j])
+			for _, obj := range objects {
+				py.delObjID(gate, obj.oid)
+				runtime.SetFinalizer(obj, nil)
 			}
 			return nil, err
 		}
-	}
 
-	// Convert into []*Object
-	objects := make([]*Object, length)
-	for i := range pyobjects {
-		objects[i] = newObjectFromPython(py, gate, pyobjects[i])
+		objects = append(objects, newObjectFromPython(py, gate, item))
 	}
 
 	return objects, nil
@@ -1,3 +1,5 @@
+//go:build !nocpython
+
 // MFP - Multi-Function Printers and scanners toolkit
 // CPython binding.
 //
@@ -373,3 +375,108 @@ func TestNewObject(t *testing.T) {
 		}
 	}
 }
+
+// TestObjectSliceLong tests Object.Slice with sequences, long
+// enough to be converted in multiple chunks.
+func TestObjectSliceLong(t *testing.T) {
+	py, err := NewPython()
+	assert.NoError(err)
+	defer py.Close()
+
+	for _, length := range []int{objSliceChunk - 1, objSliceChunk,
+		objSliceChunk + 1, 10*objSliceChunk + 7} {
+
+		expr := fmt.Sprintf("list(range(%d))", length)
+		items, err := py.Eval(expr).Slice()
+		if err != nil {
+			t.Errorf("%s: Slice: %s", expr, err)
+			continue
+		}
+
+		if len(items) != length {
+			t.Errorf("%s: Slice: expected %d items, present %d",
+				expr, length, len(items))
+			continue
+		}
+
+		for i, item := range items {
+			v, err := item.Int()
+			if err != nil || v != int64(i) {
+				t.Errorf("%s: item %d: %v (%v)", expr, i, v, err)
+				break
+			}
+		}
+	}
+}
+
+// TestObjectSliceClose tests Python.Close while the other
+// goroutine converts long lists with the GIL temporary released.
+func TestObjectSliceClose(t *testing.T) {
+	py, err := NewPython()
+	assert.NoError(err)
+
+	list := py.Eval("list(range(100000))")
+	assert.NoError(list.Err())
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			if i == 1 {
+				close(started)
+			}
+
+			_, err := list.Slice()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	<-started
+	py.Close()
+	<-stopped
+}
+
+// BenchmarkObjectSliceConcurrent measures latency of the short
+// Python.Eval calls while the other goroutine converts long lists.
+//
+// As Object.Slice releases the GIL between chunks, the short calls
+// don't wait for the whole conversion to complete.
+func BenchmarkObjectSliceConcurrent(b *testing.B) {
+	py, err := NewPython()
+	assert.NoError(err)
+	defer py.Close()
+
+	list := py.Eval("list(range(100000))")
+	assert.NoError(list.Err())
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			items, err := list.Slice()
+			assert.NoError(err)
+			for _, item := range items {
+				item.Invalidate()
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		assert.NoError(py.Eval("1 + 1").Err())
+	}
+	b.StopTimer()
+
+	close(done)
+	<-stopped
+}
@@ -1,3 +1,5 @@
+//go:build !nocpython
+
 // MFP - Miulti-Function Printers and scanners toolkit
 // CPython binding.
 //
@@ -38,6 +40,7 @@ func newObjmap() *objmap {
 
 // put adds *C.PyObject to the map and returns assigned objid.
 func (omap *objmap) put(gate pyGate, obj pyObject) objid {
+	gate.assertAttached()
 	oid := omap.next.inc()
 	assert.Must(omap.mapped[oid] == nil)
 	omap.mapped[oid] = obj
@@ -47,11 +50,13 @@ func (omap *objmap) put(gate pyGate, obj pyObject) objid {
 
 // get returns *C.PyObject by objid.
 func (omap *objmap) get(gate pyGate, oid objid) pyObject {
+	gate.assertAttached()
 	return omap.mapped[oid]
 }
 
 // del removes the *C.PyObject from the map and deletes its strong reference.
 func (omap *objmap) del(gate pyGate, oid objid) {
+	gate.assertAttached()
 	obj := omap.mapped[oid]
 	delete(omap.mapped, oid)
 	omap.maplen.Store(int32(len(omap.mapped)))
@@ -60,6 +65,7 @@ func (omap *objmap) del(gate pyGate, oid objid) {
 
 // purge removes all objects from the map.
 func (omap *objmap) purge(gate pyGate) {
+	gate.assertAttached()
 	objects := make([]pyObject, 0, len(omap.mapped))
 
 	for oid, obj := range omap.mapped {
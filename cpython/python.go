@@ -1,3 +1,5 @@
+//go:build !nocpython
+
 // MFP - Miulti-Function Printers and scanners toolkit
 // CPython binding.
 //
@@ -36,6 +38,8 @@ type Python struct {
 	objFalse  *Object       // Cached False Object
 	globals   *Object       // Global dictionary
 	builtins  *Object       // __builtins__ dictionary
+	closing   atomic.Bool   // Python.Close in progress, under GIL
+	yielding  atomic.Int32  // Count of yielded gates, under GIL
 }
 
 // NewPython creates a new Python interpreter.
@@ -101,6 +105,14 @@ func (py *Python) Close() {
 
 	gate := pyGateAcquire(interp)
 
+	// Wait until goroutines, temporary released the GIL with
+	// py.yield(), reacquire it. Once py.closing is set, they
+	// will not release the GIL anymore.
+	py.closing.Store(true)
+	for py.yielding.Load() != 0 {
+		gate.yield()
+	}
+
 	// On ARM64, Py_EndInterpreter hangs in wait_for_thread_shutdown()
 	// because threading._shutdown() blocks on a semaphore that is never
 	// signalled in a subinterpreter context (CPython issues #122517, #87135).
@@ -468,6 +480,30 @@ func (py *Python) Exec(s, filename string) error {
 	return obj.Err()
 }
 
+// GC runs the full Python garbage collection and returns count of
+// unreachable objects found.
+//
+// Python runs its cyclic garbage collector at unpredictable moments,
+// so long-running embedding applications may call GC at the
+// deterministic safe points, when all temporary objects are
+// released, to keep memory usage and latency predictable.
+func (py *Python) GC() (int, error) {
+	gate, err := py.gate()
+	if err != nil {
+		return 0, err
+	}
+	defer gate.release()
+
+	pyobj, err := gate.eval("__import__('gc').collect()", "gc", true)
+	if err != nil {
+		return 0, err
+	}
+	defer gate.unref(pyobj)
+
+	n, err := gate.decodeInt64(pyobj)
+	return int(n), err
+}
+
 // Load loads (imports) string as a Python module with name 'name' as if
 // it was loaded from the file 'file'.
 //
@@ -568,3 +604,19 @@ var pythonInstancesCount atomic.Int32
 func PythonInstancesCount() int {
 	return int(pythonInstancesCount.Load())
 }
+
+// yield is like gate.yield, but doesn't release the GIL while
+// Python.Close is in progress.
+//
+// py.closing is checked and py.yielding is updated under the GIL,
+// so Python.Close, that holds the GIL, waits for all yielded
+// gates to be reacquired before it destroys the interpreter.
+func (py *Python) yield(gate pyGate) {
+	if py.closing.Load() {
+		return
+	}
+
+	py.yielding.Add(1)
+	gate.yield()
+	py.yielding.Add(-1)
+}
@@ -1,3 +1,5 @@
+//go:build !nocpython
+
 // MFP - Miulti-Function Printers and scanners toolkit
 // CPython binding.
 //
@@ -516,3 +518,42 @@ func TestPythonEvalGateError(t *testing.T) {
 		t.Error("eval(1/0): expected ZeroDivisionError, got nil")
 	}
 }
+
+// TestPythonGC tests Python.GC
+func TestPythonGC(t *testing.T) {
+	py, err := NewPython()
+	assert.NoError(err)
+	defer py.Close()
+
+	// Create some unreachable reference cycles
+	err = py.Exec(
+		"for i in range(10):\n"+
+			"    l = []\n"+
+			"    l.append(l)\n"+
+			"del l\n", "")
+	assert.NoError(err)
+
+	n, err := py.GC()
+	assert.NoError(err)
+	if n < 10 {
+		t.Errorf("GC: expected at least 10 objects, present %d", n)
+	}
+
+	// Closed interpreter
+	py.Close()
+	_, err = py.GC()
+	if err == nil {
+		t.Errorf("GC: expected error on closed interpreter")
+	}
+}
+
+// TestPyRuntimeVersion checks version of the loaded CPython library
+func TestPyRuntimeVersion(t *testing.T) {
+	py, err := NewPython()
+	assert.NoError(err)
+	py.Close()
+
+	if err := pyRuntimeVersion.check(); err != nil {
+		t.Errorf("runtime version: %s", err)
+	}
+}
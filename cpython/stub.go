@@ -0,0 +1,380 @@
+//go:build nocpython
+
+// MFP - Miulti-Function Printers and scanners toolkit
+// CPython binding.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Stub implementation for builds without CPython
+
+package cpython
+
+import "math/big"
+
+// Python represents the Python interpreter.
+//
+// This is the stub implementation, used when package is built
+// with the nocpython tag. Python instances are never created.
+type Python struct{}
+
+// NewPython always returns [ErrNotAvailable] in this build.
+func NewPython() (py *Python, err error) {
+	return nil, ErrNotAvailable{}
+}
+
+// PythonInstancesCount always returns 0 in this build.
+func PythonInstancesCount() int {
+	return 0
+}
+
+// SetDebugAssertions does nothing in this build.
+func SetDebugAssertions(enable bool) {
+}
+
+// Close does nothing in this build.
+func (py *Python) Close() {
+}
+
+// Get returns the error Object in this build.
+func (py *Python) Get(name string) *Object {
+	return stubObject()
+}
+
+// GetGlobal returns the error Object in this build.
+func (py *Python) GetGlobal(name string) *Object {
+	return stubObject()
+}
+
+// Set returns [ErrNotAvailable] in this build.
+func (py *Python) Set(name string, val any) error {
+	return ErrNotAvailable{}
+}
+
+// Del returns [ErrNotAvailable] in this build.
+func (py *Python) Del(name string) (bool, error) {
+	return false, ErrNotAvailable{}
+}
+
+// Contains returns [ErrNotAvailable] in this build.
+func (py *Python) Contains(name string) (bool, error) {
+	return false, ErrNotAvailable{}
+}
+
+// ContainsGlobal returns [ErrNotAvailable] in this build.
+func (py *Python) ContainsGlobal(name string) (bool, error) {
+	return false, ErrNotAvailable{}
+}
+
+// None returns the error Object in this build.
+func (py *Python) None() *Object {
+	return stubObject()
+}
+
+// Bool returns the error Object in this build.
+func (py *Python) Bool(v bool) *Object {
+	return stubObject()
+}
+
+// NewObject returns the error Object in this build.
+func (py *Python) NewObject(val any) *Object {
+	return stubObject()
+}
+
+// NewError returns the error Object in this build.
+func (py *Python) NewError(err error) *Object {
+	return stubObject()
+}
+
+// Eval returns the error Object in this build.
+func (py *Python) Eval(s string) *Object {
+	return stubObject()
+}
+
+// Exec returns [ErrNotAvailable] in this build.
+func (py *Python) Exec(s, filename string) error {
+	return ErrNotAvailable{}
+}
+
+// GC returns [ErrNotAvailable] in this build.
+func (py *Python) GC() (int, error) {
+	return 0, ErrNotAvailable{}
+}
+
+// Load returns the error Object in this build.
+func (py *Python) Load(s, name, file string) *Object {
+	return stubObject()
+}
+
+// Object represents a Python value or Python error.
+//
+// In this build, all Objects are error Objects, and all
+// operations with them return [ErrNotAvailable].
+type Object struct{}
+
+// stubObject returns the error Object.
+func stubObject() *Object {
+	return &Object{}
+}
+
+// Invalidate does nothing in this build.
+func (obj *Object) Invalidate() {
+}
+
+// Py returns nil in this build.
+func (obj *Object) Py() *Python {
+	return nil
+}
+
+// Err returns [ErrNotAvailable] in this build.
+func (obj *Object) Err() error {
+	return ErrNotAvailable{}
+}
+
+// NotFound returns false in this build.
+func (obj *Object) NotFound() bool {
+	return false
+}
+
+// Len returns [ErrNotAvailable] in this build.
+func (obj *Object) Len() (int, error) {
+	return 0, ErrNotAvailable{}
+}
+
+// Save returns [ErrNotAvailable] in this build.
+func (obj *Object) Save(name string) error {
+	return ErrNotAvailable{}
+}
+
+// SaveTo returns [ErrNotAvailable] in this build.
+func (obj *Object) SaveTo(dest *Object, name string) error {
+	return ErrNotAvailable{}
+}
+
+// SaveItem returns [ErrNotAvailable] in this build.
+func (obj *Object) SaveItem(dest *Object, key any) error {
+	return ErrNotAvailable{}
+}
+
+// String returns the error message in this build.
+func (obj *Object) String() string {
+	return ErrNotAvailable{}.Error()
+}
+
+// Del returns [ErrNotAvailable] in this build.
+func (obj *Object) Del(key any) (bool, error) {
+	return false, ErrNotAvailable{}
+}
+
+// GetItem returns the error Object in this build.
+func (obj *Object) GetItem(key any) *Object {
+	return obj
+}
+
+// ContainsItem returns [ErrNotAvailable] in this build.
+func (obj *Object) ContainsItem(key any) (bool, error) {
+	return false, ErrNotAvailable{}
+}
+
+// SetItem returns [ErrNotAvailable] in this build.
+func (obj *Object) SetItem(key, val any) error {
+	return ErrNotAvailable{}
+}
+
+// DelAttr returns [ErrNotAvailable] in this build.
+func (obj *Object) DelAttr(name string) (bool, error) {
+	return false, ErrNotAvailable{}
+}
+
+// Get returns the error Object in this build.
+func (obj *Object) Get(name string) *Object {
+	return obj
+}
+
+// HasAttr returns [ErrNotAvailable] in this build.
+func (obj *Object) HasAttr(name string) (bool, error) {
+	return false, ErrNotAvailable{}
+}
+
+// Set returns [ErrNotAvailable] in this build.
+func (obj *Object) Set(name string, val any) error {
+	return ErrNotAvailable{}
+}
+
+// Call returns the error Object in this build.
+func (obj *Object) Call(args ...any) *Object {
+	return obj
+}
+
+// CallKW returns the error Object in this build.
+func (obj *Object) CallKW(kw map[string]any, args ...any) *Object {
+	return obj
+}
+
+// CallKWArgs returns the error Object in this build.
+func (obj *Object) CallKWArgs(kwargs []KWArg, args ...any) *Object {
+	return obj
+}
+
+// Str returns [ErrNotAvailable] in this build.
+func (obj *Object) Str() (string, error) {
+	return "", ErrNotAvailable{}
+}
+
+// Repr returns [ErrNotAvailable] in this build.
+func (obj *Object) Repr() (string, error) {
+	return "", ErrNotAvailable{}
+}
+
+// Bigint returns [ErrNotAvailable] in this build.
+func (obj *Object) Bigint() (*big.Int, error) {
+	return nil, ErrNotAvailable{}
+}
+
+// Bool returns [ErrNotAvailable] in this build.
+func (obj *Object) Bool() (bool, error) {
+	return false, ErrNotAvailable{}
+}
+
+// Bytes returns [ErrNotAvailable] in this build.
+func (obj *Object) Bytes() ([]byte, error) {
+	return nil, ErrNotAvailable{}
+}
+
+// Complex returns [ErrNotAvailable] in this build.
+func (obj *Object) Complex() (complex128, error) {
+	return 0, ErrNotAvailable{}
+}
+
+// Float returns [ErrNotAvailable] in this build.
+func (obj *Object) Float() (float64, error) {
+	return 0, ErrNotAvailable{}
+}
+
+// Int returns [ErrNotAvailable] in this build.
+func (obj *Object) Int() (int64, error) {
+	return 0, ErrNotAvailable{}
+}
+
+// Keys returns [ErrNotAvailable] in this build.
+func (obj *Object) Keys() ([]*Object, error) {
+	return nil, ErrNotAvailable{}
+}
+
+// Slice returns [ErrNotAvailable] in this build.
+func (obj *Object) Slice() ([]*Object, error) {
+	return nil, ErrNotAvailable{}
+}
+
+// Uint returns [ErrNotAvailable] in this build.
+func (obj *Object) Uint() (uint64, error) {
+	return 0, ErrNotAvailable{}
+}
+
+// Unicode returns [ErrNotAvailable] in this build.
+func (obj *Object) Unicode() (string, error) {
+	return "", ErrNotAvailable{}
+}
+
+// TypeName returns "" in this build.
+func (obj *Object) TypeName() string {
+	return ""
+}
+
+// TypeModuleName returns "" in this build.
+func (obj *Object) TypeModuleName() string {
+	return ""
+}
+
+// IsCallable returns false in this build.
+func (obj *Object) IsCallable() bool { return false }
+
+// IsBool returns false in this build.
+func (obj *Object) IsBool() bool { return false }
+
+// IsByteArray returns false in this build.
+func (obj *Object) IsByteArray() bool { return false }
+
+// IsBytes returns false in this build.
+func (obj *Object) IsBytes() bool { return false }
+
+// IsComplex returns false in this build.
+func (obj *Object) IsComplex() bool { return false }
+
+// IsFloat returns false in this build.
+func (obj *Object) IsFloat() bool { return false }
+
+// IsDict returns false in this build.
+func (obj *Object) IsDict() bool { return false }
+
+// IsLong returns false in this build.
+func (obj *Object) IsLong() bool { return false }
+
+// IsNone returns false in this build.
+func (obj *Object) IsNone() bool { return false }
+
+// IsSeq returns false in this build.
+func (obj *Object) IsSeq() bool { return false }
+
+// IsUnicode returns false in this build.
+func (obj *Object) IsUnicode() bool { return false }
+
+// IsType returns false in this build.
+func (obj *Object) IsType() bool { return false }
+
+// IsTrue returns false in this build.
+func (obj *Object) IsTrue() bool { return false }
+
+// IsFalse returns false in this build.
+func (obj *Object) IsFalse() bool { return false }
+
+// IsError returns true in this build.
+func (obj *Object) IsError() bool { return true }
+
+// Add returns the error Object in this build.
+func (obj *Object) Add(val any) *Object { return obj }
+
+// Sub returns the error Object in this build.
+func (obj *Object) Sub(val any) *Object { return obj }
+
+// Mul returns the error Object in this build.
+func (obj *Object) Mul(val any) *Object { return obj }
+
+// TrueDiv returns the error Object in this build.
+func (obj *Object) TrueDiv(val any) *Object { return obj }
+
+// FloorDiv returns the error Object in this build.
+func (obj *Object) FloorDiv(val any) *Object { return obj }
+
+// Mod returns the error Object in this build.
+func (obj *Object) Mod(val any) *Object { return obj }
+
+// Pow returns the error Object in this build.
+func (obj *Object) Pow(val any) *Object { return obj }
+
+// Lt returns the error Object in this build.
+func (obj *Object) Lt(val any) *Object { return obj }
+
+// Gt returns the error Object in this build.
+func (obj *Object) Gt(val any) *Object { return obj }
+
+// Le returns the error Object in this build.
+func (obj *Object) Le(val any) *Object { return obj }
+
+// Ge returns the error Object in this build.
+func (obj *Object) Ge(val any) *Object { return obj }
+
+// Eq returns the error Object in this build.
+func (obj *Object) Eq(val any) *Object { return obj }
+
+// Ne returns the error Object in this build.
+func (obj *Object) Ne(val any) *Object { return obj }
+
+// Neg returns the error Object in this build.
+func (obj *Object) Neg() *Object { return obj }
+
+// Pos returns the error Object in this build.
+func (obj *Object) Pos() *Object { return obj }
+
+// Invert returns the error Object in this build.
+func (obj *Object) Invert() *Object { return obj }
@@ -0,0 +1,45 @@
+//go:build nocpython
+
+// MFP - Miulti-Function Printers and scanners toolkit
+// CPython binding.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Stub implementation test
+
+package cpython
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestStub tests the stub implementation
+func TestStub(t *testing.T) {
+	py, err := NewPython()
+	if py != nil || !errors.Is(err, ErrNotAvailable{}) {
+		t.Fatalf("NewPython: expected %v, present %v",
+			ErrNotAvailable{}, err)
+	}
+
+	// Operations with nil Python must not crash and must
+	// return ErrNotAvailable
+	obj := py.Eval("1").Get("attr").Call(1, 2).Add(3)
+	if !errors.Is(obj.Err(), ErrNotAvailable{}) {
+		t.Errorf("Object.Err: expected %v, present %v",
+			ErrNotAvailable{}, obj.Err())
+	}
+
+	if _, err := obj.Int(); !errors.Is(err, ErrNotAvailable{}) {
+		t.Errorf("Object.Int: expected %v, present %v",
+			ErrNotAvailable{}, err)
+	}
+
+	if err := py.Exec("pass", "test"); !errors.Is(err, ErrNotAvailable{}) {
+		t.Errorf("Python.Exec: expected %v, present %v",
+			ErrNotAvailable{}, err)
+	}
+
+	py.Close()
+}
@@ -0,0 +1,148 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CPython binding.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// CPython version probing
+
+package cpython
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pyVersion represents the CPython version.
+type pyVersion struct {
+	major, minor, micro int
+}
+
+// Range of supported CPython versions. Only major and minor
+// numbers are taken into account.
+var (
+	pyVersionMin = pyVersion{3, 8, 0}
+	pyVersionMax = pyVersion{3, 13, 0}
+)
+
+// pyRuntimeVersion is the version of the loaded CPython library.
+// It is probed at initialization time.
+var pyRuntimeVersion pyVersion
+
+// pyParseVersion parses the CPython version string, as returned
+// by the Py_GetVersion function. The string looks as follows:
+//
+//	3.11.2 (main, Mar 13 2023, 12:18:29) [GCC 12.2.0]
+//
+// Suffixes like "3.13.0rc1" or "3.12.0+" are allowed and ignored.
+func pyParseVersion(s string) (pyVersion, error) {
+	var ver pyVersion
+
+	str := s
+	if i := strings.IndexAny(str, " \t"); i >= 0 {
+		str = str[:i]
+	}
+
+	fields := strings.SplitN(str, ".", 3)
+	if len(fields) < 2 {
+		return ver, fmt.Errorf("CPython: invalid version %q", s)
+	}
+
+	nums := []*int{&ver.major, &ver.minor, &ver.micro}
+	for i, field := range fields {
+		// Strip non-digit suffix, if any
+		end := strings.IndexFunc(field, func(c rune) bool {
+			return c < '0' || c > '9'
+		})
+
+		if end >= 0 {
+			if i < len(fields)-1 {
+				return ver, fmt.Errorf(
+					"CPython: invalid version %q", s)
+			}
+			field = field[:end]
+		}
+
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return ver, fmt.Errorf("CPython: invalid version %q", s)
+		}
+
+		*nums[i] = n
+	}
+
+	return ver, nil
+}
+
+// String returns the version string in the major.minor.micro form.
+func (ver pyVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", ver.major, ver.minor, ver.micro)
+}
+
+// before reports if ver is before the major.minor version.
+func (ver pyVersion) before(major, minor int) bool {
+	return ver.major < major || (ver.major == major && ver.minor < minor)
+}
+
+// check returns error if version is outside of the supported range.
+func (ver pyVersion) check() error {
+	if ver.before(pyVersionMin.major, pyVersionMin.minor) ||
+		!ver.before(pyVersionMax.major, pyVersionMax.minor+1) {
+		return fmt.Errorf(
+			"CPython %s is not supported (supported: %d.%d...%d.%d)",
+			ver, pyVersionMin.major, pyVersionMin.minor,
+			pyVersionMax.major, pyVersionMax.minor)
+	}
+
+	return nil
+}
+
+// pyFeature enumerates the version-dependent CPython behaviors.
+type pyFeature int
+
+// pyFeature values:
+const (
+	// PyEval_InitThreads needs to be called after
+	// Py_InitializeEx. Deprecated in 3.9, removed in 3.13.
+	pyFeatInitThreads pyFeature = iota
+
+	// Traceback tb_lineno may be None, if instruction
+	// has no associated line number.
+	pyFeatTracebackLinenoNone
+
+	// Py_NewInterpreterFromConfig is available, so we can
+	// create sub-interpreters without the risk that failure
+	// terminates the whole process.
+	pyFeatNewInterpFromConfig
+)
+
+// pyFeatureTable defines CPython versions, where each pyFeature
+// is present. Versions are in the major.minor form and the
+// range is [since,until). The zero until means "up to now".
+var pyFeatureTable = map[pyFeature]struct {
+	since, until pyVersion
+}{
+	pyFeatInitThreads:         {pyVersion{3, 8, 0}, pyVersion{3, 9, 0}},
+	pyFeatTracebackLinenoNone: {pyVersion{3, 12, 0}, pyVersion{}},
+	pyFeatNewInterpFromConfig: {pyVersion{3, 12, 0}, pyVersion{}},
+}
+
+// has reports if the pyFeature is present in the version.
+func (ver pyVersion) has(feat pyFeature) bool {
+	ent, ok := pyFeatureTable[feat]
+	if !ok {
+		return false
+	}
+
+	if ver.before(ent.since.major, ent.since.minor) {
+		return false
+	}
+
+	if ent.until != (pyVersion{}) &&
+		!ver.before(ent.until.major, ent.until.minor) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,156 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CPython binding.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// CPython version probing test
+
+package cpython
+
+import (
+	"testing"
+)
+
+// TestPyParseVersion tests pyParseVersion
+func TestPyParseVersion(t *testing.T) {
+	type testData struct {
+		in  string    // Input string
+		ver pyVersion // Expected version
+		err string    // Expected error
+	}
+
+	tests := []testData{
+		{
+			in:  "3.11.2 (main, Mar 13 2023, 12:18:29) [GCC 12.2.0]",
+			ver: pyVersion{3, 11, 2},
+		},
+		{
+			in:  "3.8.10 (default, Nov 22 2023, 10:22:35) \n[GCC 9.4.0]",
+			ver: pyVersion{3, 8, 10},
+		},
+		{
+			in:  "3.13.0rc1 (main, Aug  2 2024, 00:00:00) [Clang 15]",
+			ver: pyVersion{3, 13, 0},
+		},
+		{
+			in:  "3.12.0+ (heads/3.12:1234567, Oct  2 2023, 00:00:00)",
+			ver: pyVersion{3, 12, 0},
+		},
+		{
+			in:  "3.10",
+			ver: pyVersion{3, 10, 0},
+		},
+		{
+			in:  "",
+			err: `CPython: invalid version ""`,
+		},
+		{
+			in:  "3",
+			err: `CPython: invalid version "3"`,
+		},
+		{
+			in:  "3.x.1",
+			err: `CPython: invalid version "3.x.1"`,
+		},
+		{
+			in:  "3.11b.1",
+			err: `CPython: invalid version "3.11b.1"`,
+		},
+	}
+
+	for _, test := range tests {
+		ver, err := pyParseVersion(test.in)
+		errstr := ""
+		if err != nil {
+			errstr = err.Error()
+		}
+
+		switch {
+		case errstr != test.err:
+			t.Errorf("%q: error mismatch:\n"+
+				"expected: %s\npresent:  %s",
+				test.in, test.err, errstr)
+
+		case err == nil && ver != test.ver:
+			t.Errorf("%q: version mismatch:\n"+
+				"expected: %s\npresent:  %s",
+				test.in, test.ver, ver)
+		}
+	}
+}
+
+// TestPyVersionCheck tests pyVersion.check
+func TestPyVersionCheck(t *testing.T) {
+	type testData struct {
+		ver pyVersion // Input version
+		err string    // Expected error
+	}
+
+	tests := []testData{
+		{ver: pyVersion{3, 7, 17},
+			err: "CPython 3.7.17 is not supported (supported: 3.8...3.13)"},
+		{ver: pyVersion{3, 8, 0}},
+		{ver: pyVersion{3, 11, 2}},
+		{ver: pyVersion{3, 13, 5}},
+		{ver: pyVersion{3, 14, 0},
+			err: "CPython 3.14.0 is not supported (supported: 3.8...3.13)"},
+		{ver: pyVersion{2, 7, 18},
+			err: "CPython 2.7.18 is not supported (supported: 3.8...3.13)"},
+	}
+
+	for _, test := range tests {
+		err := test.ver.check()
+		errstr := ""
+		if err != nil {
+			errstr = err.Error()
+		}
+
+		if errstr != test.err {
+			t.Errorf("%s: error mismatch:\n"+
+				"expected: %s\npresent:  %s",
+				test.ver, test.err, errstr)
+		}
+	}
+}
+
+// TestPyVersionFeatures tests version-gated behaviors
+func TestPyVersionFeatures(t *testing.T) {
+	type testData struct {
+		feat pyFeature // The feature
+		ver  pyVersion // The version
+		has  bool      // Expected result
+	}
+
+	tests := []testData{
+		{pyFeatInitThreads, pyVersion{3, 8, 10}, true},
+		{pyFeatInitThreads, pyVersion{3, 9, 0}, false},
+		{pyFeatInitThreads, pyVersion{3, 13, 0}, false},
+
+		{pyFeatTracebackLinenoNone, pyVersion{3, 11, 9}, false},
+		{pyFeatTracebackLinenoNone, pyVersion{3, 12, 0}, true},
+		{pyFeatTracebackLinenoNone, pyVersion{3, 13, 1}, true},
+
+		{pyFeatNewInterpFromConfig, pyVersion{3, 8, 0}, false},
+		{pyFeatNewInterpFromConfig, pyVersion{3, 11, 2}, false},
+		{pyFeatNewInterpFromConfig, pyVersion{3, 12, 1}, true},
+		{pyFeatNewInterpFromConfig, pyVersion{3, 13, 0}, true},
+
+		{pyFeature(-1), pyVersion{3, 12, 0}, false},
+	}
+
+	for _, test := range tests {
+		has := test.ver.has(test.feat)
+		if has != test.has {
+			t.Errorf("feature %d, version %s: expected %v, present %v",
+				test.feat, test.ver, test.has, has)
+		}
+	}
+
+	// Every feature must be in the table
+	for feat := pyFeatInitThreads; feat <= pyFeatNewInterpFromConfig; feat++ {
+		if _, found := pyFeatureTable[feat]; !found {
+			t.Errorf("feature %d: missed in pyFeatureTable", feat)
+		}
+	}
+}
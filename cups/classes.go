@@ -0,0 +1,73 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer classes
+
+package cups
+
+import (
+	"net/url"
+	"path"
+	"sort"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// ClassAttrs lists attributes, required by [ClassMembers] and
+// [ClassMembership]. Request them from [Client.GetClasses].
+var ClassAttrs = []string{
+	"member-names",
+	"member-uris",
+	"printer-name",
+}
+
+// ClassMembers returns names of the class members.
+//
+// Names are taken from the "member-names" attribute. If it is
+// missed, they are recovered from the "member-uris".
+func ClassMembers(class *ipp.PrinterAttributes) []string {
+	if len(class.MemberNames) != 0 {
+		return class.MemberNames
+	}
+
+	members := make([]string, 0, len(class.MemberURIs))
+	for _, uri := range class.MemberURIs {
+		u, err := url.Parse(uri)
+		if err != nil || u.Path == "" {
+			continue
+		}
+
+		if name := path.Base(u.Path); name != "/" && name != "." {
+			members = append(members, name)
+		}
+	}
+
+	return members
+}
+
+// ClassMembership returns classes, the printers are members of,
+// indexed by the printer name. Class names are sorted.
+func ClassMembership(classes []*ipp.PrinterAttributes) map[string][]string {
+	membership := make(map[string][]string)
+
+	for _, class := range classes {
+		name := optional.Get(class.PrinterName)
+		if name == "" {
+			continue
+		}
+
+		for _, member := range ClassMembers(class) {
+			membership[member] = append(membership[member], name)
+		}
+	}
+
+	for _, names := range membership {
+		sort.Strings(names)
+	}
+
+	return membership
+}
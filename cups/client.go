@@ -12,6 +12,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"time"
 
@@ -99,6 +100,74 @@ func (c *Client) CUPSGetPrinters(ctx context.Context,
 	return rsp.Printer, nil
 }
 
+// GetPrinterAttributes returns attributes of the CUPS queue,
+// specified by name.
+//
+// The attrs attribute allows to specify list of requested attributes.
+func (c *Client) GetPrinterAttributes(ctx context.Context, name string,
+	attrs []string) (*ipp.PrinterAttributes, error) {
+
+	uri, err := c.printerURI(name)
+	if err != nil {
+		return nil, err
+	}
+
+	rq := &ipp.GetPrinterAttributesRequest{
+		RequestHeader:       ipp.DefaultRequestHeader,
+		PrinterURI:          uri,
+		RequestedAttributes: attrs,
+	}
+
+	rsp := &ipp.GetPrinterAttributesResponse{}
+	err = c.IPPClient.Do(ctx, rq, rsp)
+	if err == nil && rsp.Status != goipp.StatusOk {
+		err = fmt.Errorf("IPP: %s", rsp.Status)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rsp.Printer, nil
+}
+
+// GetJobs returns jobs of the CUPS queue, specified by name.
+// If name is empty, jobs of all queues are returned.
+//
+// The which argument selects jobs by their state. If empty,
+// CUPS returns not-completed jobs.
+//
+// The attrs attribute allows to specify list of requested attributes.
+func (c *Client) GetJobs(ctx context.Context, name string,
+	which ipp.KwWhichJobs, attrs []ipp.KwRequestedAttribute) (
+	[]ipp.JobGroupEntry, error) {
+
+	uri := DefaultLocalhostURL.String()
+	if name != "" {
+		var err error
+		uri, err = c.printerURI(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rq := &ipp.GetJobsRequest{
+		RequestHeader:       ipp.DefaultRequestHeader,
+		PrinterURI:          uri,
+		WhichJobs:           optional.NotZero(which),
+		RequestedAttributes: attrs,
+	}
+
+	rsp := &ipp.GetJobsResponse{}
+
+	err := c.IPPClient.Do(ctx, rq, rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	return rsp.Jobs, nil
+}
+
 // CUPSGetDevices performs search for available devices and returns
 // found devices.
 //
@@ -147,6 +216,10 @@ func (c *Client) CUPSGetDevices(ctx context.Context,
 //   - nil body, empty seeOtherURI and non-nil err in a case of error.
 //
 // If non-nil body returned, caller MUST close it after use.
+//
+// CUPS-Get-PPD is the IPP operation, sent as HTTP POST, so its
+// response cannot be cached. Use [Client.FetchPPD] to fetch the
+// PPD file of the CUPS queue with caching.
 func (c *Client) CUPSGetPPD(ctx context.Context,
 	printerURI, ppdName string) (
 	body io.ReadCloser, seeOtherURI string, err error) {
@@ -204,3 +277,259 @@ func (c *Client) CUPSGetPPDs(ctx context.Context,
 
 	return nil, fmt.Errorf("IPP: %s", rsp.Status)
 }
+
+// FetchPPD fetches the PPD file of the CUPS queue by the queue name.
+//
+// Unlike [Client.CUPSGetPPD], it uses the HTTP GET request for the
+// "/printers/NAME.ppd" resource, which is served by CUPS for each
+// queue that has the PPD file. PPD files rarely change, so request
+// is performed with caching enabled (see [transport.WithCaching])
+// and uses c.IPPClient.HTTPClient.Cache, if it is set.
+func (c *Client) FetchPPD(ctx context.Context, name string) ([]byte, error) {
+	u, _, err := ResolvePrinterURI(c.IPPClient.URL, name)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path += ".ppd"
+
+	ctx = transport.WithCaching(ctx)
+	rq, err := transport.NewRequest(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := c.IPPClient.HTTPClient.Do(rq)
+	if err != nil {
+		return nil, transport.WrapError(err, u)
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP: %s", rsp.Status)
+	}
+
+	return io.ReadAll(rsp.Body)
+}
+
+// CUPSAddModifyPrinter adds a new printer or modifies the existing
+// one, identified by the queue name.
+//
+// Only attributes, set in the settings, are sent. CUPS leaves other
+// attributes of the existing printer unchanged.
+func (c *Client) CUPSAddModifyPrinter(ctx context.Context,
+	name string, settings *ipp.CUPSPrinterSettings) error {
+
+	uri, err := c.printerURI(name)
+	if err != nil {
+		return err
+	}
+
+	rq := &ipp.CUPSAddModifyPrinterRequest{
+		RequestHeader: ipp.DefaultRequestHeader,
+		PrinterURI:    uri,
+		Printer:       settings,
+	}
+
+	rsp := &ipp.CUPSAddModifyPrinterResponse{}
+
+	err = c.IPPClient.Do(ctx, rq, rsp)
+	if err != nil {
+		return err
+	}
+
+	if rsp.Status != goipp.StatusOk {
+		return fmt.Errorf("IPP: %s", rsp.Status)
+	}
+
+	return nil
+}
+
+// SetShared enables or disables sharing of the printer,
+// identified by the queue name.
+func (c *Client) SetShared(ctx context.Context,
+	name string, shared bool) error {
+
+	settings := &ipp.CUPSPrinterSettings{
+		PrinterIsShared: optional.New(shared),
+	}
+
+	return c.CUPSAddModifyPrinter(ctx, name, settings)
+}
+
+// SetErrorPolicy sets the error policy of the printer,
+// identified by the queue name.
+//
+// Unknown policy values are rejected without contacting the server.
+func (c *Client) SetErrorPolicy(ctx context.Context,
+	name string, policy ipp.KwPrinterErrorPolicy) error {
+
+	if !policy.IsKnown() {
+		return fmt.Errorf("%q: unknown printer-error-policy", policy)
+	}
+
+	settings := &ipp.CUPSPrinterSettings{
+		PrinterErrorPolicy: optional.New(policy),
+	}
+
+	return c.CUPSAddModifyPrinter(ctx, name, settings)
+}
+
+// GetClasses returns attributes of printer classes known
+// to the system.
+//
+// If [GetPrintersSelection] argument is not nil, it allows to
+// specify a subset of classes to be returned. CUPS-Get-Classes
+// doesn't support selection by PrinterID, so it is ignored.
+//
+// The attrs attribute allows to specify list of requested attributes.
+// Class members are returned by the "member-names" and "member-uris"
+// attributes.
+func (c *Client) GetClasses(ctx context.Context,
+	sel *GetPrintersSelection, attrs []string) (
+	[]*ipp.PrinterAttributes, error) {
+
+	if sel == nil {
+		sel = DefaultGetPrintersSelection
+	}
+
+	rq := &ipp.CUPSGetClassesRequest{
+		RequestHeader:       ipp.DefaultRequestHeader,
+		FirstPrinterName:    optional.NotZero(sel.FirstPrinterName),
+		Limit:               optional.NotZero(sel.Limit),
+		PrinterLocation:     optional.NotZero(sel.PrinterLocation),
+		PrinterType:         optional.NotZero(sel.PrinterType),
+		PrinterTypeMask:     optional.NotZero(sel.PrinterTypeMask),
+		RequestedUserName:   optional.NotZero(sel.User),
+		RequestedAttributes: attrs,
+	}
+
+	rsp := &ipp.CUPSGetClassesResponse{}
+
+	err := c.IPPClient.Do(ctx, rq, rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	return rsp.Printer, nil
+}
+
+// CUPSAddModifyClass adds a new printer class or modifies the
+// existing one, identified by the class name.
+//
+// Only attributes, set in the settings, are sent. If MemberURIs
+// is set, it replaces the whole list of the class members.
+// Use [Client.SetClassMembers] to set members by the queue names.
+func (c *Client) CUPSAddModifyClass(ctx context.Context,
+	name string, settings *ipp.CUPSClassSettings) error {
+
+	uri, err := c.classURI(name)
+	if err != nil {
+		return err
+	}
+
+	rq := &ipp.CUPSAddModifyClassRequest{
+		RequestHeader: ipp.DefaultRequestHeader,
+		PrinterURI:    uri,
+		Class:         settings,
+	}
+
+	rsp := &ipp.CUPSAddModifyClassResponse{}
+
+	err = c.IPPClient.Do(ctx, rq, rsp)
+	if err != nil {
+		return err
+	}
+
+	if rsp.Status != goipp.StatusOk {
+		return fmt.Errorf("IPP: %s", rsp.Status)
+	}
+
+	return nil
+}
+
+// SetClassMembers sets members of the printer class, identified
+// by the class name. If class doesn't exist, it is created.
+//
+// Members are specified by their queue names. Instances are the
+// client-side concept and cannot be class members.
+func (c *Client) SetClassMembers(ctx context.Context,
+	name string, members []string) error {
+
+	if len(members) == 0 {
+		return fmt.Errorf("%q: class must have members", name)
+	}
+
+	settings := &ipp.CUPSClassSettings{}
+	for _, member := range members {
+		u, instance, err := ResolvePrinterURI(DefaultLocalhostURL,
+			member)
+		if err == nil && instance != "" {
+			err = fmt.Errorf("%q: instance can't be class member",
+				member)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		settings.MemberURIs = append(settings.MemberURIs, u.String())
+	}
+
+	return c.CUPSAddModifyClass(ctx, name, settings)
+}
+
+// CUPSDeleteClass deletes the printer class, identified by
+// the class name. Class members are not affected.
+func (c *Client) CUPSDeleteClass(ctx context.Context, name string) error {
+	uri, err := c.classURI(name)
+	if err != nil {
+		return err
+	}
+
+	rq := &ipp.CUPSDeleteClassRequest{
+		RequestHeader: ipp.DefaultRequestHeader,
+		PrinterURI:    uri,
+	}
+
+	rsp := &ipp.CUPSDeleteClassResponse{}
+
+	err = c.IPPClient.Do(ctx, rq, rsp)
+	if err != nil {
+		return err
+	}
+
+	if rsp.Status != goipp.StatusOk {
+		return fmt.Errorf("IPP: %s", rsp.Status)
+	}
+
+	return nil
+}
+
+// printerURI returns the printer-uri of the CUPS queue by its name.
+// See [ResolvePrinterURI] for details.
+func (c *Client) printerURI(name string) (string, error) {
+	u, _, err := ResolvePrinterURI(DefaultLocalhostURL, name)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// classURI returns the printer-uri of the CUPS class by its name.
+// See [ResolveQueueURI] for details.
+func (c *Client) classURI(name string) (string, error) {
+	u, instance, err := ResolveQueueURI(DefaultLocalhostURL, name,
+		ipp.EnPrinterClass)
+	if err == nil && instance != "" {
+		err = fmt.Errorf("%q: invalid class name", name)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
@@ -10,9 +10,18 @@ package cups
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"path"
+	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/transport/testutil"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
 )
 
 func TestCUPS(t *testing.T) {
@@ -28,3 +37,329 @@ func TestCUPS(t *testing.T) {
 	_ = rsp
 	//fmt.Printf("%#v", rsp)
 }
+
+// TestFetchPPD tests Client.FetchPPD with caching
+func TestFetchPPD(t *testing.T) {
+	const ppd = "*PPD-Adobe: \"4.3\"\n"
+	var full, notModified int
+
+	prn := testutil.NewFakeIPPPrinter(nil, testutil.Options{})
+	defer prn.Close()
+
+	prn.Handle("/printers/Test Queue.ppd", http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			w.Header().Set("ETag", `"ppd-1"`)
+			if rq.Header.Get("If-None-Match") == `"ppd-1"` {
+				notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+
+			full++
+			io.WriteString(w, ppd)
+		}))
+
+	cache, err := transport.NewCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewCache: %s", err)
+	}
+
+	c := NewClient(transport.MustParseURL(prn.URL), nil)
+	c.IPPClient.HTTPClient.Cache = cache
+
+	for i := 0; i < 2; i++ {
+		data, err := c.FetchPPD(context.Background(), "Test Queue")
+		if err != nil {
+			t.Fatalf("FetchPPD: %s", err)
+		}
+
+		if string(data) != ppd {
+			t.Errorf("FetchPPD: expected %q, present %q", ppd, data)
+		}
+	}
+
+	if full != 1 || notModified != 1 {
+		t.Errorf("FetchPPD: requests (full/304): "+
+			"expected 1/1, present %d/%d", full, notModified)
+	}
+
+	// Instances share the PPD file of the queue
+	_, err = c.FetchPPD(context.Background(), "Test Queue/draft")
+	if err != nil {
+		t.Errorf("FetchPPD: instance: %s", err)
+	}
+
+	_, err = c.FetchPPD(context.Background(), "Missed")
+	if err == nil {
+		t.Errorf("FetchPPD: error expected for missed queue")
+	}
+
+	prn.Log().Expect(t,
+		"GET /printers/Test Queue.ppd",
+		"GET /printers/Test Queue.ppd",
+		"GET /printers/Test Queue.ppd",
+		"GET /printers/Missed.ppd")
+}
+
+// newTestAddModifyServer creates the fake CUPS server, that accepts
+// CUPS-Add-Modify-Printer requests.
+func newTestAddModifyServer() *testutil.FakeIPPPrinter {
+	srv := testutil.NewFakeIPPPrinter(nil, testutil.Options{})
+	srv.RespondFunc(goipp.OpCupsAddModifyPrinter,
+		func(*goipp.Message) *goipp.Message {
+			return testutil.NewIPPResponse(goipp.StatusOk)
+		})
+
+	return srv
+}
+
+// testAddModifyRequests returns CUPS-Add-Modify-Printer requests,
+// received by the fake CUPS server.
+func testAddModifyRequests(t *testing.T,
+	srv *testutil.FakeIPPPrinter) []*ipp.CUPSAddModifyPrinterRequest {
+
+	var requests []*ipp.CUPSAddModifyPrinterRequest
+	for _, entry := range srv.Log().ByOp(goipp.OpCupsAddModifyPrinter) {
+		rq := &ipp.CUPSAddModifyPrinterRequest{}
+		err := rq.Decode(entry.IPP, nil)
+		if err != nil {
+			t.Errorf("IPP request: %s", err)
+			continue
+		}
+
+		requests = append(requests, rq)
+	}
+
+	return requests
+}
+
+// TestSetShared tests Client.SetShared
+func TestSetShared(t *testing.T) {
+	srv := newTestAddModifyServer()
+	defer srv.Close()
+
+	c := NewClient(transport.MustParseURL(srv.URL), nil)
+	err := c.SetShared(context.Background(), "Test Queue", true)
+	if err != nil {
+		t.Fatalf("SetShared: %s", err)
+	}
+
+	requests := testAddModifyRequests(t, srv)
+	if len(requests) != 1 {
+		t.Fatalf("SetShared: 1 request expected, %d present",
+			len(requests))
+	}
+
+	rq := requests[0]
+	expectedURI := "ipp://localhost/printers/Test%20Queue"
+	if rq.PrinterURI != expectedURI {
+		t.Errorf("printer-uri: expected %q, present %q",
+			expectedURI, rq.PrinterURI)
+	}
+
+	// Only printer-is-shared must be sent
+	var names []string
+	for _, attr := range rq.Printer.RawAttrs().All() {
+		names = append(names, attr.Name)
+	}
+
+	expected := []string{"printer-is-shared"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("printer attributes: expected %v, present %v",
+			expected, names)
+	}
+
+	if !optional.Get(rq.Printer.PrinterIsShared) {
+		t.Errorf("printer-is-shared: expected true")
+	}
+}
+
+// TestSetErrorPolicy tests Client.SetErrorPolicy
+func TestSetErrorPolicy(t *testing.T) {
+	srv := newTestAddModifyServer()
+	defer srv.Close()
+
+	c := NewClient(transport.MustParseURL(srv.URL), nil)
+
+	// Unknown policy must be rejected without sending the request
+	err := c.SetErrorPolicy(context.Background(), "Test", "retry-forever")
+	if err == nil {
+		t.Errorf("SetErrorPolicy: error expected for unknown policy")
+	}
+
+	if srv.Log().Len() != 0 {
+		t.Errorf("SetErrorPolicy: request sent for unknown policy")
+	}
+
+	err = c.SetErrorPolicy(context.Background(), "Test",
+		ipp.KwPrinterErrorPolicyStopPrinter)
+	if err != nil {
+		t.Fatalf("SetErrorPolicy: %s", err)
+	}
+
+	requests := testAddModifyRequests(t, srv)
+	if len(requests) != 1 {
+		t.Fatalf("SetErrorPolicy: 1 request expected, %d present",
+			len(requests))
+	}
+
+	settings := requests[0].Printer
+	if len(settings.RawAttrs().All()) != 1 ||
+		optional.Get(settings.PrinterErrorPolicy) !=
+			ipp.KwPrinterErrorPolicyStopPrinter {
+		t.Errorf("SetErrorPolicy: unexpected attributes: %v",
+			settings.RawAttrs().All())
+	}
+}
+
+// newTestClassesServer creates the fake CUPS server, that keeps
+// printer classes, created by CUPS-Add-Modify-Class, and returns
+// them by CUPS-Get-Classes.
+func newTestClassesServer() *testutil.FakeIPPPrinter {
+	srv := testutil.NewFakeIPPPrinter(nil, testutil.Options{})
+
+	var lock sync.Mutex
+	classes := make(map[string][]string)
+
+	srv.RespondFunc(goipp.OpCupsAddModifyClass,
+		func(msg *goipp.Message) *goipp.Message {
+			rq := &ipp.CUPSAddModifyClassRequest{}
+			err := rq.Decode(msg, nil)
+			if err != nil || rq.Class == nil {
+				return testutil.NewIPPResponse(
+					goipp.StatusErrorBadRequest)
+			}
+
+			lock.Lock()
+			classes[rq.PrinterURI] = rq.Class.MemberURIs
+			lock.Unlock()
+
+			return testutil.NewIPPResponse(goipp.StatusOk)
+		})
+
+	srv.RespondFunc(goipp.OpCupsDeleteClass,
+		func(msg *goipp.Message) *goipp.Message {
+			rq := &ipp.CUPSDeleteClassRequest{}
+			rq.Decode(msg, nil)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if _, found := classes[rq.PrinterURI]; !found {
+				return testutil.NewIPPResponse(
+					goipp.StatusErrorNotFound)
+			}
+
+			delete(classes, rq.PrinterURI)
+			return testutil.NewIPPResponse(goipp.StatusOk)
+		})
+
+	srv.RespondFunc(goipp.OpCupsGetClasses,
+		func(*goipp.Message) *goipp.Message {
+			rsp := &ipp.CUPSGetClassesResponse{
+				ResponseHeader: ipp.DefaultResponseHeader,
+			}
+
+			lock.Lock()
+			for uri, members := range classes {
+				u := transport.MustParseURL(uri)
+				class := &ipp.PrinterAttributes{}
+				class.PrinterName = optional.New(path.Base(u.Path))
+				class.MemberURIs = members
+				rsp.Printer = append(rsp.Printer, class)
+			}
+			lock.Unlock()
+
+			return rsp.Encode()
+		})
+
+	return srv
+}
+
+// TestClasses tests creation, query and deletion of printer classes
+func TestClasses(t *testing.T) {
+	srv := newTestClassesServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := NewClient(transport.MustParseURL(srv.URL), nil)
+
+	// Instances cannot be class members
+	err := c.SetClassMembers(ctx, "office", []string{"Kyocera/duplex"})
+	if err == nil {
+		t.Errorf("SetClassMembers: instance must be rejected")
+	}
+
+	if srv.Log().Len() != 0 {
+		t.Errorf("SetClassMembers: request sent for invalid member")
+	}
+
+	// Create the class
+	err = c.SetClassMembers(ctx, "office",
+		[]string{"Kyocera", "Xerox B235"})
+	if err != nil {
+		t.Fatalf("SetClassMembers: %s", err)
+	}
+
+	entries := srv.Log().ByOp(goipp.OpCupsAddModifyClass)
+	if len(entries) != 1 {
+		t.Fatalf("SetClassMembers: 1 request expected, %d present",
+			len(entries))
+	}
+
+	rq := &ipp.CUPSAddModifyClassRequest{}
+	err = rq.Decode(entries[0].IPP, nil)
+	if err != nil {
+		t.Fatalf("IPP request: %s", err)
+	}
+
+	expectedURI := "ipp://localhost/classes/office"
+	if rq.PrinterURI != expectedURI {
+		t.Errorf("printer-uri: expected %q, present %q",
+			expectedURI, rq.PrinterURI)
+	}
+
+	expectedMembers := []string{
+		"ipp://localhost/printers/Kyocera",
+		"ipp://localhost/printers/Xerox%20B235",
+	}
+	if !reflect.DeepEqual(rq.Class.MemberURIs, expectedMembers) {
+		t.Errorf("member-uris: expected %v, present %v",
+			expectedMembers, rq.Class.MemberURIs)
+	}
+
+	// Read it back
+	classes, err := c.GetClasses(ctx, nil, ClassAttrs)
+	if err != nil {
+		t.Fatalf("GetClasses: %s", err)
+	}
+
+	if len(classes) != 1 {
+		t.Fatalf("GetClasses: 1 class expected, %d present",
+			len(classes))
+	}
+
+	members := ClassMembers(classes[0])
+	expected := []string{"Kyocera", "Xerox B235"}
+	if !reflect.DeepEqual(members, expected) {
+		t.Errorf("ClassMembers: expected %v, present %v",
+			expected, members)
+	}
+
+	// Delete the class
+	err = c.CUPSDeleteClass(ctx, "office")
+	if err != nil {
+		t.Fatalf("CUPSDeleteClass: %s", err)
+	}
+
+	err = c.CUPSDeleteClass(ctx, "office")
+	if err == nil {
+		t.Errorf("CUPSDeleteClass: error expected for missed class")
+	}
+
+	classes, err = c.GetClasses(ctx, nil, ClassAttrs)
+	if err != nil || len(classes) != 0 {
+		t.Errorf("GetClasses after delete: %d classes, err=%v",
+			len(classes), err)
+	}
+}
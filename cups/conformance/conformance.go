@@ -0,0 +1,295 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP conformance self-test
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Conformance test runner
+
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/log/trace"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/goipp"
+)
+
+// ExchangeTimeout limits each request/response exchange with
+// the printer, if Context has no deadline.
+const ExchangeTimeout = 10 * time.Second
+
+// Result is the outcome of the single conformance test.
+type Result int
+
+// Result values:
+const (
+	ResultPass Result = iota // Test passed
+	ResultFail               // Test failed
+	ResultSkip               // Test skipped
+)
+
+// String returns the Result name.
+func (res Result) String() string {
+	switch res {
+	case ResultPass:
+		return "pass"
+	case ResultFail:
+		return "fail"
+	case ResultSkip:
+		return "skip"
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(res))
+}
+
+// Names of tests, executed by the [Run], in order of execution:
+const (
+	// Get-Printer-Attributes returns all required attributes
+	TestPrinterAttributes = "printer-attributes"
+
+	// All required operations are supported
+	TestOperations = "operations"
+
+	// All advertised IPP versions are accepted and
+	// unsupported version is properly rejected
+	TestVersion = "version"
+
+	// Request with the non-default natural language is accepted,
+	// response uses utf-8
+	TestCharset = "charset"
+
+	// Validate-Job accepts the canonical job ticket
+	TestValidateJob = "validate-job"
+
+	// Print-Job of the test page, followed by Cancel-Job
+	TestPrintJob = "print-job"
+
+	// Printer advertises TLS (optional, see Options.TLS)
+	TestTLSAdvertised = "tls-advertised"
+
+	// Printer is reachable via ipps:// (optional, see Options.TLS)
+	TestTLSConnect = "tls-connect"
+)
+
+// TestResult is the result of the single conformance test.
+type TestResult struct {
+	Name    string // Test name (TestXXX)
+	Title   string // Human-readable test description
+	Result  Result // Test outcome
+	Message string // Details, failure or skip reason
+
+	// Evidence contains names of the trace files with the
+	// raw IPP messages, exchanged during the test. It is only
+	// filled, if trace is enabled on the Context (see
+	// [trace.NewContext]).
+	Evidence []string
+}
+
+// Report is the result of the [Run].
+type Report struct {
+	PrinterURI string        // Tested printer URI
+	MakeModel  string        // printer-make-and-model, if known
+	Version    goipp.Version // IPP version, used by the tests
+	Tests      []TestResult  // Per-test results, in order of execution
+}
+
+// Counts returns number of passed, failed and skipped tests.
+func (r Report) Counts() (pass, fail, skip int) {
+	for _, t := range r.Tests {
+		switch t.Result {
+		case ResultPass:
+			pass++
+		case ResultFail:
+			fail++
+		case ResultSkip:
+			skip++
+		}
+	}
+	return
+}
+
+// Score returns the conformance score, in percents: the share of
+// passed tests among the executed (not skipped) ones.
+func (r Report) Score() int {
+	pass, fail, _ := r.Counts()
+	if pass+fail == 0 {
+		return 0
+	}
+	return pass * 100 / (pass + fail)
+}
+
+// Passed reports whether no tests were failed.
+func (r Report) Passed() bool {
+	_, fail, _ := r.Counts()
+	return fail == 0
+}
+
+// Options contains the [Run] parameters.
+type Options struct {
+	// TLS enables the optional TLS checks. Otherwise, TLS
+	// tests are skipped.
+	TLS bool
+}
+
+// Run runs the conformance test battery against the IPP printer,
+// specified by its ipp:// or ipps:// URI.
+//
+// Requests, sent by the tests, are real. In particular, the test
+// page is submitted with the Print-Job and immediately canceled;
+// it still may be printed, if printer is fast enough.
+//
+// If trace is enabled on the Context, the exchanged IPP messages
+// are written into the trace, and their names are reported as the
+// TestResult.Evidence.
+//
+// Only invalid printer URI is returned as error. Failures of the
+// printer are reported as the failed tests.
+func Run(ctx context.Context, uri string, opts Options) (Report, error) {
+	u, err := transport.ParseURL(uri)
+	if err == nil && u.Scheme != "ipp" && u.Scheme != "ipps" {
+		err = fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	if err != nil {
+		return Report{}, fmt.Errorf("%q: %w", uri, err)
+	}
+
+	r := &runner{
+		ctx:    ctx,
+		opts:   opts,
+		u:      u,
+		clnt:   runnerClient(u),
+		tracer: trace.CtxWriter(ctx),
+		report: Report{
+			PrinterURI: u.String(),
+			Version:    ipp.FallbackVersion,
+			Tests:      make([]TestResult, 0, len(conformanceTests)),
+		},
+	}
+
+	for i, t := range conformanceTests {
+		r.report.Tests = append(r.report.Tests, TestResult{
+			Name:  t.name,
+			Title: t.title,
+		})
+
+		r.seq = i + 1
+		r.exchanges = 0
+		r.test = &r.report.Tests[i]
+		r.test.Result, r.test.Message = t.run(r)
+	}
+
+	return r.report, nil
+}
+
+// runner runs the conformance tests.
+type runner struct {
+	ctx       context.Context        // Run context
+	opts      Options                // Run options
+	u         *url.URL               // Printer URI
+	clnt      *ipp.Client            // IPP client
+	tracer    *trace.Writer          // Trace writer, nil if none
+	report    Report                 // Report being built
+	printer   *ipp.PrinterAttributes // Printer attributes, nil if none
+	requestID uint32                 // Last used request ID
+	test      *TestResult            // Current test
+	seq       int                    // Current test number, 1-based
+	exchanges int                    // Exchanges count in current test
+}
+
+// runnerClient creates the IPP client for the printer URI.
+func runnerClient(u *url.URL) *ipp.Client {
+	clnt := ipp.NewClient(u, transport.PoolFor(u).Transport())
+	clnt.SetDecoderOptions(&ipp.DecoderOptions{KeepTrying: true})
+	return clnt
+}
+
+// pass returns the successful test outcome.
+func (r *runner) pass(format string, args ...any) (Result, string) {
+	return ResultPass, fmt.Sprintf(format, args...)
+}
+
+// fail returns the failed test outcome.
+func (r *runner) fail(format string, args ...any) (Result, string) {
+	return ResultFail, fmt.Sprintf(format, args...)
+}
+
+// skip returns the skipped test outcome.
+func (r *runner) skip(format string, args ...any) (Result, string) {
+	return ResultSkip, fmt.Sprintf(format, args...)
+}
+
+// do sends the request to the printer and waits for response.
+func (r *runner) do(rq ipp.Request, rsp ipp.Response) error {
+	return r.doWith(r.clnt, rq, rsp)
+}
+
+// doWith sends the request, using the specified client.
+//
+// Request is sent with the explicit IPP version (the Report.Version,
+// if request version is not set) and request ID, so the version
+// is not negotiated and the request, recorded as the evidence, is
+// exactly the same as sent.
+func (r *runner) doWith(clnt *ipp.Client,
+	rq ipp.Request, rsp ipp.Response) error {
+
+	hdr := rq.Header()
+	if hdr.Version == 0 {
+		hdr.Version = r.report.Version
+	}
+
+	r.requestID++
+	hdr.RequestID = r.requestID
+	r.exchanges++
+
+	ctx := r.ctx
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ExchangeTimeout)
+		defer cancel()
+	}
+
+	name := fmt.Sprintf("conformance/%02d-%s/%d", r.seq, r.test.Name,
+		r.exchanges)
+
+	r.evidence(name+"-req-"+rq.GetOp().String()+".ipp", rq.Encode())
+
+	err := clnt.Do(ctx, rq, rsp)
+	if err == nil {
+		msg := rsp.Header().IPPMessage
+		r.evidence(name+"-rsp-"+goipp.Status(msg.Code).String()+".ipp",
+			msg)
+	}
+
+	return err
+}
+
+// evidence writes the IPP message into the trace and adds
+// its name to the current test evidence.
+func (r *runner) evidence(name string, msg *goipp.Message) {
+	if r.tracer == nil {
+		return
+	}
+
+	data, err := msg.EncodeBytes()
+	if err != nil {
+		return
+	}
+
+	r.tracer.Send(name, data)
+	r.test.Evidence = append(r.test.Evidence, name)
+}
+
+// statusOK reports whether IPP status is successful.
+//
+// Note, successful-ok-ignored-or-substituted-attributes and
+// similar are also successful; they occupy range 0x0000-0x00ff.
+func statusOK(status goipp.Status) bool {
+	return status <= 0x00ff
+}
@@ -0,0 +1,387 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP conformance self-test
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Conformance test runner test
+
+package conformance
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/log/trace"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/goipp"
+)
+
+// testPrinter is the virtual IPP printer, served both via
+// ipp:// and ipps://
+type testPrinter struct {
+	plain *httptest.Server // ipp:// server
+	tls   *httptest.Server // ipps:// server
+	ipp   string           // ipp:// printer URI
+	ipps  string           // ipps:// printer URI
+}
+
+// newTestPrinter creates a new testPrinter with attributes of the
+// real printer. The degrade callback, if not nil, may modify the
+// printer attributes and options to inject faults.
+func newTestPrinter(t *testing.T,
+	degrade func(attrs goipp.Attributes, opts *ipp.PrinterOptions,
+		p *testPrinter) goipp.Attributes) *testPrinter {
+
+	p := &testPrinter{
+		plain: httptest.NewUnstartedServer(nil),
+		tls:   httptest.NewUnstartedServer(nil),
+	}
+
+	t.Cleanup(func() {
+		p.plain.Close()
+		p.tls.Close()
+	})
+
+	p.ipp = "ipp://" + p.plain.Listener.Addr().String() + "/ipp/print"
+	p.ipps = "ipps://" + p.tls.Listener.Addr().String() + "/ipp/print"
+
+	// Printer attributes of the real printer, but with
+	// printer-uri-supported, pointing to the test servers
+	msg := testutils.IPPMustParse(
+		testutils.Kyocera.ECOSYS.M2040dn.IPP.PrinterAttributes)
+
+	attrs := testSetAttr(msg.Printer, "printer-uri-supported",
+		goipp.TagURI, p.ipps, p.ipp)
+
+	opts := ipp.PrinterOptions{UseRawPrinterAttributes: true}
+	if degrade != nil {
+		attrs = degrade(attrs, &opts, p)
+	}
+
+	pa, err := ipp.DecodePrinterAttributes(attrs, nil)
+	if err != nil {
+		t.Fatalf("DecodePrinterAttributes: %s", err)
+	}
+
+	p.plain.Config.Handler = ipp.NewPrinter(pa, opts)
+	p.tls.Config.Handler = ipp.NewPrinter(pa, opts)
+
+	p.plain.Start()
+	p.tls.StartTLS()
+
+	return p
+}
+
+// testSetAttr replaces values of the attribute. If no values
+// are specified, attribute is removed.
+func testSetAttr(attrs goipp.Attributes, name string, tag goipp.Tag,
+	values ...string) goipp.Attributes {
+
+	var out goipp.Attributes
+	for _, attr := range attrs {
+		if attr.Name != name {
+			out = append(out, attr)
+			continue
+		}
+
+		if len(values) != 0 {
+			attr = goipp.Attribute{Name: name}
+			for _, v := range values {
+				attr.Values.Add(tag, goipp.String(v))
+			}
+			out = append(out, attr)
+		}
+	}
+
+	return out
+}
+
+// testCheckResults checks results of tests against expected.
+func testCheckResults(t *testing.T, r Report, expected map[string]Result) {
+	if len(r.Tests) != len(conformanceTests) {
+		t.Fatalf("%d tests reported, expected %d",
+			len(r.Tests), len(conformanceTests))
+	}
+
+	for _, test := range r.Tests {
+		if test.Result != expected[test.Name] {
+			t.Errorf("%s: expected %s, present %s: %s",
+				test.Name, expected[test.Name],
+				test.Result, test.Message)
+		}
+
+		if test.Message == "" {
+			t.Errorf("%s: message missed", test.Name)
+		}
+	}
+}
+
+// TestRunVirtualPrinter runs the conformance tests against the
+// properly configured virtual printer
+func TestRunVirtualPrinter(t *testing.T) {
+	p := newTestPrinter(t, nil)
+
+	// Enable trace
+	name := filepath.Join(t.TempDir(), "trace")
+
+	logger := log.NewLogger(log.LevelError, log.Console)
+	ctx := log.NewContext(context.Background(), logger)
+
+	tracer, err := trace.NewWriter(ctx, name)
+	if err != nil {
+		t.Fatalf("trace.NewWriter: %s", err)
+	}
+
+	ctx = trace.NewContext(ctx, tracer)
+
+	// Run tests
+	r, err := Run(ctx, p.ipp, Options{TLS: true})
+	tracer.Close()
+
+	if err != nil {
+		t.Fatalf("Run: %s", err)
+	}
+
+	testCheckResults(t, r, map[string]Result{
+		TestPrinterAttributes: ResultPass,
+		TestOperations:        ResultPass,
+		TestVersion:           ResultPass,
+		TestCharset:           ResultPass,
+		TestValidateJob:       ResultPass,
+		TestPrintJob:          ResultPass,
+		TestTLSAdvertised:     ResultPass,
+		TestTLSConnect:        ResultPass,
+	})
+
+	if !r.Passed() || r.Score() != 100 {
+		t.Errorf("Score: %d%%", r.Score())
+	}
+
+	if r.Version != goipp.MakeVersion(2, 0) {
+		t.Errorf("Version: expected 2.0, present %s", r.Version)
+	}
+
+	if r.MakeModel == "" {
+		t.Errorf("MakeModel missed")
+	}
+
+	// Check evidence. All tests, except these, that only check
+	// attributes, obtained before, talk to the printer.
+	var evidence []string
+	for _, test := range r.Tests {
+		switch test.Name {
+		case TestOperations, TestTLSAdvertised:
+			continue
+		}
+
+		if len(test.Evidence) == 0 {
+			t.Errorf("%s: evidence missed", test.Name)
+		}
+		evidence = append(evidence, test.Evidence...)
+	}
+
+	fp, err := os.Open(name + ".tar")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer fp.Close()
+
+	files := make(map[string]bool)
+	rd := tar.NewReader(fp)
+	for {
+		hdr, err := rd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%s.tar: %s", name, err)
+		}
+		files[hdr.Name] = true
+	}
+
+	for _, file := range evidence {
+		if !files[file] {
+			t.Errorf("%s: missed in the trace", file)
+		}
+	}
+
+	// Without TLS checks, TLS tests are skipped
+	r, err = Run(context.Background(), p.ipp, Options{})
+	if err != nil {
+		t.Fatalf("Run: %s", err)
+	}
+
+	pass, fail, skip := r.Counts()
+	if pass != 6 || fail != 0 || skip != 2 || r.Score() != 100 {
+		t.Errorf("without TLS: %d passed, %d failed, %d skipped",
+			pass, fail, skip)
+	}
+
+	if r.Tests[0].Evidence != nil {
+		t.Errorf("evidence without trace: %v", r.Tests[0].Evidence)
+	}
+}
+
+// TestRunDegradedPrinter runs the conformance tests against
+// the virtual printer with injected faults
+func TestRunDegradedPrinter(t *testing.T) {
+	degrade := func(attrs goipp.Attributes, opts *ipp.PrinterOptions,
+		p *testPrinter) goipp.Attributes {
+
+		// Required attribute missed; it also makes the
+		// media-default unsupported by Validate-Job
+		attrs = testSetAttr(attrs, "media-supported", 0)
+
+		// No suitable format for the test page
+		attrs = testSetAttr(attrs, "document-format-supported",
+			goipp.TagMimeType, "application/octet-stream")
+
+		// TLS is announced, but no ipps:// URI
+		attrs = testSetAttr(attrs, "printer-uri-supported",
+			goipp.TagURI, p.ipp)
+
+		// IPP 2.0 is announced, but not accepted
+		opts.MaxVersion = goipp.MakeVersion(1, 1)
+
+		// attributes-charset is not the first attribute
+		// of responses
+		opts.Hooks.OnIPPResponse = func(query *transport.ServerQuery,
+			msg *goipp.Message) *goipp.Message {
+
+			for _, grp := range msg.Groups {
+				ops := grp.Attrs
+				if grp.Tag == goipp.TagOperationGroup &&
+					len(ops) >= 2 &&
+					ops[0].Name == "attributes-charset" {
+					ops[0], ops[1] = ops[1], ops[0]
+				}
+			}
+			return msg
+		}
+
+		return attrs
+	}
+
+	p := newTestPrinter(t, degrade)
+
+	r, err := Run(context.Background(), p.ipp, Options{TLS: true})
+	if err != nil {
+		t.Fatalf("Run: %s", err)
+	}
+
+	testCheckResults(t, r, map[string]Result{
+		TestPrinterAttributes: ResultFail,
+		TestOperations:        ResultPass,
+		TestVersion:           ResultFail,
+		TestCharset:           ResultFail,
+		TestValidateJob:       ResultFail,
+		TestPrintJob:          ResultSkip,
+		TestTLSAdvertised:     ResultFail,
+		TestTLSConnect:        ResultSkip,
+	})
+
+	if r.Passed() || r.Score() != 16 {
+		t.Errorf("Score: %d%%", r.Score())
+	}
+
+	if r.Version != goipp.MakeVersion(1, 1) {
+		t.Errorf("Version: expected 1.1, present %s", r.Version)
+	}
+
+	// Check failure details
+	messages := map[string]string{
+		TestPrinterAttributes: "media-supported",
+		TestVersion:           "version 2.0",
+		TestCharset:           "attributes-charset",
+		TestValidateJob:       "unsupported: media",
+	}
+
+	for _, test := range r.Tests {
+		if s := messages[test.Name]; !strings.Contains(test.Message, s) {
+			t.Errorf("%s: %q expected in message: %s",
+				test.Name, s, test.Message)
+		}
+	}
+}
+
+// TestRunUnreachable tests Run against unreachable printer
+// and with invalid URIs
+func TestRunUnreachable(t *testing.T) {
+	srv := httptest.NewServer(nil)
+	uri := "ipp://" + srv.Listener.Addr().String() + "/ipp/print"
+	srv.Close()
+
+	r, err := Run(context.Background(), uri, Options{})
+	if err != nil {
+		t.Fatalf("Run: %s", err)
+	}
+
+	pass, fail, skip := r.Counts()
+	if pass != 0 || fail != 3 || skip != 5 || r.Score() != 0 {
+		t.Errorf("%d passed, %d failed, %d skipped",
+			pass, fail, skip)
+	}
+
+	for _, uri := range []string{"", "http://localhost/ipp/print",
+		"ipp:///ipp/print"} {
+		_, err := Run(context.Background(), uri, Options{})
+		if err == nil {
+			t.Errorf("%q: error expected", uri)
+		}
+	}
+}
+
+// TestTestPage tests the test page generation
+func TestTestPage(t *testing.T) {
+	type testData struct {
+		supported []string // document-format-supported
+		format    string   // Expected format
+		magic     string   // Expected data prefix
+	}
+
+	tests := []testData{
+		{
+			supported: []string{"application/pdf", "image/jpeg"},
+			format:    "image/jpeg",
+			magic:     "\xff\xd8\xff",
+		},
+		{
+			supported: []string{"Application/PDF"},
+			format:    "application/pdf",
+			magic:     "%PDF-1.4\n",
+		},
+		{
+			supported: []string{"application/octet-stream"},
+		},
+	}
+
+	for _, test := range tests {
+		format, data := testPage(test.supported)
+		if format != test.format ||
+			!strings.HasPrefix(string(data), test.magic) {
+			t.Errorf("%v: expected %q, present %q (%d bytes)",
+				test.supported, test.format, format, len(data))
+		}
+	}
+
+	// Check PDF xref offsets
+	pdf := string(testPagePDF())
+	for i := 1; i <= 3; i++ {
+		obj := strings.Index(pdf, fmt.Sprintf("\n%d 0 obj\n", i)) + 1
+		entry := fmt.Sprintf("%010d 00000 n \n", obj)
+		if obj == 0 || !strings.Contains(pdf, entry) {
+			t.Errorf("PDF: object %d: bad xref entry", i)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP conformance self-test
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Test page generation
+
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"strings"
+)
+
+// testPageFormats lists document formats of the test page, in
+// order of preference, with the corresponding generators.
+//
+// JPEG is required by the IPP Everywhere, PDF is widely supported.
+var testPageFormats = []struct {
+	format   string        // MIME type
+	generate func() []byte // Generator
+}{
+	{"image/jpeg", testPageJPEG},
+	{"application/pdf", testPagePDF},
+}
+
+// testPage generates the one-page test document in the format,
+// supported by the printer. If none of the test page formats
+// is supported, it returns "" and nil.
+func testPage(supported []string) (format string, data []byte) {
+	for _, f := range testPageFormats {
+		for _, s := range supported {
+			if strings.EqualFold(f.format, s) {
+				return f.format, f.generate()
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// testPageJPEG generates the blank US Letter page at 10 DPI
+// in the JPEG format.
+func testPageJPEG() []byte {
+	img := image.NewGray(image.Rect(0, 0, 85, 110))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.White},
+		image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, nil)
+
+	return buf.Bytes()
+}
+
+// testPagePDF generates the blank US Letter page in the PDF format.
+func testPagePDF() []byte {
+	objs := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] " +
+			"/Resources << >> >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+
+	offsets := make([]int, len(objs))
+	for i, obj := range objs {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n", len(objs)+1)
+	buf.WriteString("0000000000 65535 f \n")
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\n",
+		len(objs)+1)
+	fmt.Fprintf(&buf, "startxref\n%d\n%%%%EOF\n", xref)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,640 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP conformance self-test
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Conformance tests
+
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/OpenPrinting/go-mfp/cups"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// conformanceTests lists the tests, in order of execution.
+// Later tests depend on the printer attributes, obtained by
+// the first one.
+var conformanceTests = []struct {
+	name  string                         // Test name
+	title string                         // Test description
+	run   func(*runner) (Result, string) // Test function
+}{
+	{
+		name:  TestPrinterAttributes,
+		title: "Get-Printer-Attributes completeness",
+		run:   (*runner).testPrinterAttributes,
+	},
+	{
+		name:  TestOperations,
+		title: "Required operations",
+		run:   (*runner).testOperations,
+	},
+	{
+		name:  TestVersion,
+		title: "IPP version negotiation",
+		run:   (*runner).testVersion,
+	},
+	{
+		name:  TestCharset,
+		title: "Charset and natural language handling",
+		run:   (*runner).testCharset,
+	},
+	{
+		name:  TestValidateJob,
+		title: "Validate-Job with the canonical ticket",
+		run:   (*runner).testValidateJob,
+	},
+	{
+		name:  TestPrintJob,
+		title: "Print-Job of the test page and Cancel-Job",
+		run:   (*runner).testPrintJob,
+	},
+	{
+		name:  TestTLSAdvertised,
+		title: "TLS support advertised",
+		run:   (*runner).testTLSAdvertised,
+	},
+	{
+		name:  TestTLSConnect,
+		title: "Get-Printer-Attributes over TLS",
+		run:   (*runner).testTLSConnect,
+	},
+}
+
+// conformanceRequiredAttrs lists the Printer attributes, required
+// by the IPP Everywhere (PWG 5100.14).
+var conformanceRequiredAttrs = []string{
+	"charset-configured",
+	"charset-supported",
+	"color-supported",
+	"compression-supported",
+	"copies-default",
+	"copies-supported",
+	"document-format-default",
+	"document-format-supported",
+	"generated-natural-language-supported",
+	"ipp-features-supported",
+	"ipp-versions-supported",
+	"media-bottom-margin-supported",
+	"media-col-database",
+	"media-col-default",
+	"media-col-ready",
+	"media-col-supported",
+	"media-default",
+	"media-left-margin-supported",
+	"media-ready",
+	"media-right-margin-supported",
+	"media-supported",
+	"media-top-margin-supported",
+	"media-type-supported",
+	"natural-language-configured",
+	"operations-supported",
+	"pdl-override-supported",
+	"print-color-mode-default",
+	"print-color-mode-supported",
+	"print-quality-default",
+	"print-quality-supported",
+	"printer-info",
+	"printer-is-accepting-jobs",
+	"printer-location",
+	"printer-make-and-model",
+	"printer-more-info",
+	"printer-name",
+	"printer-resolution-default",
+	"printer-resolution-supported",
+	"printer-state",
+	"printer-state-reasons",
+	"printer-up-time",
+	"printer-uri-supported",
+	"printer-uuid",
+	"pwg-raster-document-resolution-supported",
+	"pwg-raster-document-type-supported",
+	"queued-job-count",
+	"sides-default",
+	"sides-supported",
+	"uri-authentication-supported",
+	"uri-security-supported",
+}
+
+// conformanceRequiredOps lists the required operations
+// (RFC8011, 5.4.15 and PWG 5100.14).
+var conformanceRequiredOps = []goipp.Op{
+	goipp.OpPrintJob,
+	goipp.OpValidateJob,
+	goipp.OpCreateJob,
+	goipp.OpSendDocument,
+	goipp.OpCancelJob,
+	goipp.OpGetJobAttributes,
+	goipp.OpGetJobs,
+	goipp.OpGetPrinterAttributes,
+}
+
+// Parameters of the test requests:
+const (
+	// Unsupported IPP version for the version negotiation test
+	conformanceBadVersion goipp.Version = 0x0900
+
+	// Non-default natural language for the charset test
+	conformanceLanguage = "de-de"
+
+	// requesting-user-name and job-name of the test jobs
+	conformanceUser    = "mfp-conformance"
+	conformanceJobName = "IPP conformance test page"
+)
+
+// errNoPrinterAttrs is the reason of skipping tests that
+// depend on the printer attributes.
+const errNoPrinterAttrs = "printer attributes not available"
+
+// testPrinterAttributes fetches all printer attributes and
+// checks that required attributes are present.
+func (r *runner) testPrinterAttributes() (Result, string) {
+	rq := &ipp.GetPrinterAttributesRequest{
+		RequestHeader: ipp.DefaultRequestHeader,
+		PrinterURI:    r.report.PrinterURI,
+		RequestedAttributes: []string{
+			ipp.GetPrinterAttributesAll,
+			ipp.GetPrinterAttributesMediaColDatabase,
+		},
+	}
+
+	rsp := &ipp.GetPrinterAttributesResponse{}
+	err := r.do(rq, rsp)
+	switch {
+	case err != nil:
+		return r.fail("%s", err)
+	case !statusOK(rsp.Status):
+		return r.fail("IPP: %s", rsp.Status)
+	case rsp.Printer == nil:
+		return r.fail("no printer attributes returned")
+	}
+
+	r.printer = rsp.Printer
+	r.report.MakeModel = optional.Get(r.printer.PrinterMakeAndModel)
+
+	attrs := rsp.IPPMessage.Printer
+	var missed []string
+	for _, name := range conformanceRequiredAttrs {
+		found := slices.ContainsFunc(attrs, func(attr goipp.Attribute) bool {
+			return attr.Name == name
+		})
+
+		if !found {
+			missed = append(missed, name)
+		}
+	}
+
+	if len(missed) != 0 {
+		return r.fail("%d of %d required attributes missed: %s",
+			len(missed), len(conformanceRequiredAttrs),
+			strings.Join(missed, ", "))
+	}
+
+	return r.pass("%d attributes returned, all %d required present",
+		len(attrs), len(conformanceRequiredAttrs))
+}
+
+// testOperations checks that printer supports required operations.
+func (r *runner) testOperations() (Result, string) {
+	if r.printer == nil {
+		return r.skip(errNoPrinterAttrs)
+	}
+
+	var missed []string
+	for _, op := range conformanceRequiredOps {
+		if !r.printer.IsOperationSupported(op) {
+			missed = append(missed, op.String())
+		}
+	}
+
+	if len(missed) != 0 {
+		return r.fail("operations-supported: missed %s",
+			strings.Join(missed, ", "))
+	}
+
+	return r.pass("all %d required operations supported",
+		len(conformanceRequiredOps))
+}
+
+// testVersion checks that printer accepts requests of all
+// advertised IPP versions (and 1.1, which is always required) and
+// rejects requests of the unsupported version with the
+// server-error-version-not-supported status.
+//
+// The highest working version is used by the subsequent tests.
+func (r *runner) testVersion() (Result, string) {
+	versions := []goipp.Version{ipp.FallbackVersion}
+	if r.printer != nil {
+		versions = append(versions, r.printer.IppVersionsSupported...)
+	}
+
+	slices.Sort(versions)
+	versions = slices.Compact(versions)
+
+	var accepted, problems []string
+	for _, ver := range versions {
+		rsp, err := r.getAttrs(ver, ipp.DefaultNaturalLanguage,
+			"ipp-versions-supported")
+
+		switch {
+		case err != nil:
+			problems = append(problems,
+				fmt.Sprintf("version %s: %s", ver, err))
+		case !statusOK(rsp.Status):
+			problems = append(problems,
+				fmt.Sprintf("version %s: IPP: %s", ver, rsp.Status))
+		default:
+			accepted = append(accepted, ver.String())
+			r.report.Version = ver
+		}
+	}
+
+	// Printer must reject the unsupported version. Its response
+	// contains no printer attributes, so only status is decoded.
+	rq := r.attrsRequest(conformanceBadVersion, ipp.DefaultNaturalLanguage,
+		"ipp-versions-supported")
+	rsp := &statusResponse{}
+	err := r.do(rq, rsp)
+
+	switch {
+	case err != nil:
+		problems = append(problems, fmt.Sprintf("version %s: %s",
+			conformanceBadVersion, err))
+
+	case rsp.Status != goipp.StatusErrorVersionNotSupported:
+		problems = append(problems,
+			fmt.Sprintf("version %s: expected %s, got %s",
+				conformanceBadVersion,
+				goipp.StatusErrorVersionNotSupported,
+				rsp.Status))
+	}
+
+	if len(problems) != 0 {
+		return r.fail("%s", strings.Join(problems, "; "))
+	}
+
+	return r.pass("versions %s accepted, version %s rejected",
+		strings.Join(accepted, ", "), conformanceBadVersion)
+}
+
+// testCharset sends request with the utf-8 charset and the
+// non-default natural language and checks the response
+// charset, natural language and text values.
+func (r *runner) testCharset() (Result, string) {
+	rsp, err := r.getAttrs(0, conformanceLanguage,
+		"printer-name", "printer-info", "printer-location",
+		"printer-make-and-model")
+
+	switch {
+	case err != nil:
+		return r.fail("%s", err)
+	case !statusOK(rsp.Status):
+		return r.fail("natural language %q: IPP: %s",
+			conformanceLanguage, rsp.Status)
+	}
+
+	// RFC8011, 4.1.4: attributes-charset and
+	// attributes-natural-language must be the first two
+	// operation attributes of the response.
+	ops := rsp.IPPMessage.Operation
+	switch {
+	case len(ops) < 2 || ops[0].Name != "attributes-charset":
+		return r.fail("attributes-charset is not the " +
+			"first response attribute")
+	case ops[1].Name != "attributes-natural-language":
+		return r.fail("attributes-natural-language is not the " +
+			"second response attribute")
+	case !strings.EqualFold(rsp.AttributesCharset, ipp.DefaultCharset):
+		return r.fail("response charset %q, expected %q",
+			rsp.AttributesCharset, ipp.DefaultCharset)
+	}
+
+	for _, attr := range rsp.IPPMessage.Printer {
+		for _, v := range attr.Values {
+			var s string
+			switch val := v.V.(type) {
+			case goipp.String:
+				if v.T != goipp.TagText && v.T != goipp.TagName {
+					continue
+				}
+				s = string(val)
+			case goipp.TextWithLang:
+				s = val.Text
+			default:
+				continue
+			}
+
+			if !utf8.ValidString(s) {
+				return r.fail("%s: invalid UTF-8 %q",
+					attr.Name, s)
+			}
+		}
+	}
+
+	return r.pass("natural language %q accepted, response %s/%s",
+		conformanceLanguage, rsp.AttributesCharset,
+		rsp.AttributesNaturalLanguage)
+}
+
+// testValidateJob validates the canonical job ticket.
+func (r *runner) testValidateJob() (Result, string) {
+	if r.printer == nil {
+		return r.skip(errNoPrinterAttrs)
+	}
+
+	format, _ := testPage(r.printer.DocumentFormatSupported)
+
+	rq := &ipp.ValidateJobRequest{
+		RequestHeader:      ipp.DefaultRequestHeader,
+		JobCreateOperation: r.jobCreateOperation(format),
+		JobTemplate:        r.ticket(),
+	}
+
+	rsp := &ipp.ValidateJobResponse{}
+	err := r.do(rq, rsp)
+	switch {
+	case err != nil:
+		return r.fail("%s", err)
+	case !statusOK(rsp.Status):
+		return r.fail("IPP: %s%s", rsp.Status,
+			unsupportedString(rsp.UnsupportedAttributes))
+	}
+
+	return r.pass("ticket accepted: %s", ticketString(rq))
+}
+
+// testPrintJob prints the test page and immediately cancels
+// the job.
+func (r *runner) testPrintJob() (Result, string) {
+	if r.printer == nil {
+		return r.skip(errNoPrinterAttrs)
+	}
+
+	format, page := testPage(r.printer.DocumentFormatSupported)
+	if format == "" {
+		return r.skip("no suitable document format for the " +
+			"test page (image/jpeg or application/pdf)")
+	}
+
+	// Print the test page
+	rq := &ipp.PrintJobRequest{
+		RequestHeader:      ipp.DefaultRequestHeader,
+		JobCreateOperation: r.jobCreateOperation(format),
+		JobTemplate:        r.ticket(),
+	}
+	rq.Body = bytes.NewReader(page)
+
+	rsp := &ipp.PrintJobResponse{}
+	err := r.do(rq, rsp)
+	switch {
+	case err != nil:
+		return r.fail("Print-Job: %s", err)
+	case !statusOK(rsp.Status):
+		return r.fail("Print-Job: IPP: %s%s", rsp.Status,
+			unsupportedString(rsp.UnsupportedAttributes))
+	case rsp.Job == nil || rsp.Job.JobID == 0:
+		return r.fail("Print-Job: job-id missed in response")
+	}
+
+	jobID := rsp.Job.JobID
+
+	// Cancel the job
+	cancelRq := &ipp.CancelJobRequest{
+		RequestHeader: ipp.DefaultRequestHeader,
+		JobCancelOperation: ipp.JobCancelOperation{
+			PrinterURI:         optional.New(r.report.PrinterURI),
+			JobID:              optional.New(jobID),
+			RequestingUserName: optional.New(conformanceUser),
+		},
+	}
+
+	cancelRsp := &ipp.CancelJobResponse{}
+	err = r.do(cancelRq, cancelRsp)
+	switch {
+	case err != nil:
+		return r.fail("Cancel-Job: %s", err)
+
+	case cancelRsp.Status == goipp.StatusErrorNotPossible:
+		// Job is already completed
+		return r.pass("%s job %d submitted, completed before "+
+			"Cancel-Job", format, jobID)
+
+	case !statusOK(cancelRsp.Status):
+		return r.fail("Cancel-Job: IPP: %s", cancelRsp.Status)
+	}
+
+	return r.pass("%s job %d submitted and canceled", format, jobID)
+}
+
+// testTLSAdvertised checks that printer advertises TLS support.
+func (r *runner) testTLSAdvertised() (Result, string) {
+	switch {
+	case !r.opts.TLS:
+		return r.skip("TLS checks not requested")
+	case r.printer == nil:
+		return r.skip(errNoPrinterAttrs)
+	}
+
+	ipps := r.ippsURI()
+	tls := slices.Contains(r.printer.URISecuritySupported,
+		ipp.KwURISecurityTLS)
+
+	switch {
+	case ipps == "" && !tls:
+		return r.fail("neither ipps:// printer-uri-supported " +
+			"nor tls uri-security-supported advertised")
+	case ipps == "":
+		return r.fail("uri-security-supported includes tls, " +
+			"but ipps:// printer-uri-supported missed")
+	case !tls:
+		return r.fail("%s advertised, but uri-security-supported "+
+			"doesn't include tls", ipps)
+	}
+
+	return r.pass("%s advertised", ipps)
+}
+
+// testTLSConnect inspects the printer TLS certificate and
+// fetches printer attributes over TLS.
+func (r *runner) testTLSConnect() (Result, string) {
+	switch {
+	case !r.opts.TLS:
+		return r.skip("TLS checks not requested")
+	case r.printer == nil:
+		return r.skip(errNoPrinterAttrs)
+	}
+
+	ipps := r.ippsURI()
+	if ipps == "" {
+		return r.skip("ipps:// printer URI not advertised")
+	}
+
+	u, err := transport.ParseURL(ipps)
+	if err != nil {
+		return r.fail("%q: %s", ipps, err)
+	}
+
+	info, err := cups.InspectTLS(r.ctx, ipps)
+	switch {
+	case err != nil:
+		return r.fail("%s: %s", ipps, err)
+	case info.Expired(time.Now()):
+		return r.fail("%s: certificate expired on %s", ipps,
+			info.NotAfter.UTC().Format(time.DateOnly))
+	}
+
+	rq := &ipp.GetPrinterAttributesRequest{
+		RequestHeader:       ipp.DefaultRequestHeader,
+		PrinterURI:          u.String(),
+		RequestedAttributes: []string{"printer-state"},
+	}
+
+	rsp := &ipp.GetPrinterAttributesResponse{}
+	err = r.doWith(runnerClient(u), rq, rsp)
+	switch {
+	case err != nil:
+		return r.fail("%s: %s", ipps, err)
+	case !statusOK(rsp.Status):
+		return r.fail("%s: IPP: %s", ipps, rsp.Status)
+	}
+
+	return r.pass("%s: %s, certificate valid until %s", ipps,
+		info.Version, info.NotAfter.UTC().Format(time.DateOnly))
+}
+
+// getAttrs requests printer attributes with the specified IPP
+// version (0 means Report.Version) and natural language.
+func (r *runner) getAttrs(ver goipp.Version, lang string,
+	attrs ...string) (*ipp.GetPrinterAttributesResponse, error) {
+
+	rsp := &ipp.GetPrinterAttributesResponse{}
+	err := r.do(r.attrsRequest(ver, lang, attrs...), rsp)
+	return rsp, err
+}
+
+// attrsRequest returns Get-Printer-Attributes request with the
+// specified IPP version and natural language.
+func (r *runner) attrsRequest(ver goipp.Version, lang string,
+	attrs ...string) *ipp.GetPrinterAttributesRequest {
+
+	rq := &ipp.GetPrinterAttributesRequest{
+		RequestHeader:       ipp.DefaultRequestHeader,
+		PrinterURI:          r.report.PrinterURI,
+		RequestedAttributes: attrs,
+	}
+
+	rq.Version = ver
+	rq.AttributesNaturalLanguage = lang
+
+	return rq
+}
+
+// jobCreateOperation returns operation attributes of the test job.
+func (r *runner) jobCreateOperation(format string) ipp.JobCreateOperation {
+	return ipp.JobCreateOperation{
+		PrinterURI:         r.report.PrinterURI,
+		RequestingUserName: optional.New(conformanceUser),
+		JobName:            optional.New(conformanceJobName),
+		DocumentFormat:     optional.NotZero(format),
+	}
+}
+
+// ticket returns the canonical job ticket: single one-sided copy
+// on the default media with the default color mode.
+func (r *runner) ticket() *ipp.JobTemplate {
+	tmpl := &ipp.JobTemplate{}
+	tmpl.Copies = optional.New(1)
+	tmpl.Sides = optional.New(ipp.KwSidesOneSided)
+	tmpl.Media = r.printer.MediaDefault
+	tmpl.PrintColorMode = r.printer.PrintColorModeDefault
+	return tmpl
+}
+
+// ippsURI returns the ipps:// printer URI, if printer supports TLS.
+//
+// If printer advertises several ipps:// URIs, the URI with the
+// same host as the tested printer URI is preferred.
+func (r *runner) ippsURI() string {
+	if r.u.Scheme == "ipps" {
+		return r.report.PrinterURI
+	}
+
+	var found string
+	for _, s := range r.printer.PrinterURISupported {
+		u, err := transport.ParseURL(s)
+		switch {
+		case err != nil || u.Scheme != "ipps":
+		case u.Hostname() == r.u.Hostname():
+			return s
+		case found == "":
+			found = s
+		}
+	}
+
+	return found
+}
+
+// ticketString formats the job ticket of the request for messages.
+func ticketString(rq *ipp.ValidateJobRequest) string {
+	var attrs []string
+	if format := rq.DocumentFormat; format != nil {
+		attrs = append(attrs, "document-format="+*format)
+	}
+
+	for _, attr := range rq.Encode().Job {
+		attrs = append(attrs, attr.Name+"="+attr.Values.String())
+	}
+
+	return strings.Join(attrs, " ")
+}
+
+// unsupportedString formats unsupported attributes, returned by
+// printer, for the failure messages.
+func unsupportedString(attrs goipp.Attributes) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+
+	names := make([]string, len(attrs))
+	for i, attr := range attrs {
+		names[i] = attr.Name
+	}
+
+	return "; unsupported: " + strings.Join(names, ", ")
+}
+
+// statusResponse is the IPP response, decoded only up to the status.
+//
+// It is used when printer is expected to reject the request, so
+// the operation-specific attributes are missed in the response.
+type statusResponse struct {
+	ipp.ResponseHeader
+}
+
+// Encode encodes statusResponse into goipp.Message.
+func (rsp *statusResponse) Encode() *goipp.Message {
+	return goipp.NewResponse(rsp.Version, rsp.Status, rsp.RequestID)
+}
+
+// Decode decodes statusResponse from goipp.Message.
+func (rsp *statusResponse) Decode(
+	msg *goipp.Message, opt *ipp.DecoderOptions) error {
+
+	rsp.Version = msg.Version
+	rsp.RequestID = msg.RequestID
+	rsp.Status = goipp.Status(msg.Code)
+
+	return nil
+}
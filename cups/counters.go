@@ -0,0 +1,209 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer counters and quotas for accounting
+
+package cups
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// countersAttrs lists attributes, requested by the [Client.GetCounters]
+var countersAttrs = []string{
+	"job-k-limit",
+	"job-k-octets-supported",
+	"job-page-limit",
+	"job-quota-period",
+	"printer-impressions-completed",
+	"printer-name",
+	"printer-pages-completed",
+	"printer-up-time",
+}
+
+// Counters contains the printer lifetime counters and the
+// per-queue quota attributes, used for print accounting.
+//
+// Values are returned as reported by the printer. Some firmwares
+// use 32-bit counters that wrap around; use [DeltaCounters] to
+// get the monotonic interpretation of two samples.
+type Counters struct {
+	Printer string    // Printer (queue) name
+	Time    time.Time // When counters were obtained
+
+	// Printer lifetime counters
+	ImpressionsCompleted optional.Val[int] // printer-impressions-completed
+	PagesCompleted       optional.Val[int] // printer-pages-completed
+	UpTime               optional.Val[int] // printer-up-time, seconds
+
+	// Quotas
+	JobKOctetsSupported optional.Val[goipp.Range] // job-k-octets-supported
+	JobQuotaPeriod      optional.Val[int]         // job-quota-period, seconds
+	JobKLimit           optional.Val[int]         // job-k-limit, KB
+	JobPageLimit        optional.Val[int]         // job-page-limit
+}
+
+// CountersDelta is the difference between two [Counters] samples,
+// returned by the [DeltaCounters].
+//
+// Counters, missed in any of the samples, are nil.
+type CountersDelta struct {
+	Impressions optional.Val[int64] // Completed impressions
+	Pages       optional.Val[int64] // Completed pages
+	UpTime      optional.Val[int64] // Printer up time, seconds
+	Wrapped     bool                // Some counter wrapped around
+	Restarted   bool                // Printer restarted between samples
+}
+
+// GetCounters returns the accounting [Counters] of the printer,
+// identified by the queue name.
+func (c *Client) GetCounters(ctx context.Context,
+	name string) (Counters, error) {
+
+	uri, err := c.printerURI(name)
+	if err != nil {
+		return Counters{}, err
+	}
+
+	rq := &ipp.GetPrinterAttributesRequest{
+		RequestHeader:       ipp.DefaultRequestHeader,
+		PrinterURI:          uri,
+		RequestedAttributes: countersAttrs,
+	}
+
+	rsp := &ipp.GetPrinterAttributesResponse{}
+	err = c.IPPClient.Do(ctx, rq, rsp)
+	if err == nil && rsp.Status != goipp.StatusOk {
+		err = fmt.Errorf("IPP: %s", rsp.Status)
+	}
+
+	if err != nil {
+		return Counters{}, err
+	}
+
+	prn := rsp.Printer
+	cnt := Counters{
+		Printer:              optional.Get(prn.PrinterName),
+		Time:                 time.Now(),
+		ImpressionsCompleted: prn.PrinterImpressionsCompleted,
+		PagesCompleted:       prn.PrinterPagesCompleted,
+		UpTime:               prn.PrinterUpTime,
+		JobKOctetsSupported:  prn.JobKOctetsSupported,
+		JobQuotaPeriod:       prn.JobQuotaPeriod,
+		JobKLimit:            prn.JobKLimit,
+		JobPageLimit:         prn.JobPageLimit,
+	}
+
+	if cnt.Printer == "" {
+		cnt.Printer = name
+	}
+
+	return cnt, nil
+}
+
+// DeltaCounters returns the difference between two samples of
+// [Counters] of the same printer, prev taken before cur.
+//
+// Lifetime counters never decrease. If the counter value has
+// decreased, it is interpreted as the 32-bit counter wrap-around,
+// and the delta is computed modulo 2^32.
+//
+// The printer-up-time is not a lifetime counter: if it has
+// decreased, the printer was restarted, and the delta is the
+// current up time.
+func DeltaCounters(prev, cur Counters) CountersDelta {
+	var delta CountersDelta
+
+	delta.Impressions = countersDelta(prev.ImpressionsCompleted,
+		cur.ImpressionsCompleted, &delta.Wrapped)
+	delta.Pages = countersDelta(prev.PagesCompleted,
+		cur.PagesCompleted, &delta.Wrapped)
+
+	if prev.UpTime != nil && cur.UpTime != nil {
+		up := int64(*cur.UpTime) - int64(*prev.UpTime)
+		if up < 0 {
+			up = int64(*cur.UpTime)
+			delta.Restarted = true
+		}
+		delta.UpTime = optional.New(up)
+	}
+
+	return delta
+}
+
+// countersDelta returns difference between two values of the
+// 32-bit lifetime counter. If counter has wrapped around,
+// *wrapped is set to true.
+func countersDelta(prev, cur optional.Val[int],
+	wrapped *bool) optional.Val[int64] {
+
+	if prev == nil || cur == nil {
+		return nil
+	}
+
+	d := int64(*cur) - int64(*prev)
+	if d < 0 {
+		// Modulo 2^32 arithmetic handles both signed (2^31-1 -> -2^31)
+		// and unsigned (2^32-1 -> 0) wrap-around.
+		d = int64(uint32(*cur) - uint32(*prev))
+		*wrapped = true
+	}
+
+	return optional.New(d)
+}
+
+// CountersCSVHeader returns the CSV header for [Counters.CSVRecord].
+func CountersCSVHeader() []string {
+	return []string{
+		"time",
+		"printer",
+		"impressions-completed",
+		"pages-completed",
+		"up-time",
+		"job-k-octets-supported",
+		"job-quota-period",
+		"job-k-limit",
+		"job-page-limit",
+	}
+}
+
+// CSVRecord formats [Counters] as the CSV record, suitable for
+// the [encoding/csv.Writer]. Missed values are represented by
+// empty fields.
+func (cnt Counters) CSVRecord() []string {
+	kOctets := ""
+	if cnt.JobKOctetsSupported != nil {
+		rng := *cnt.JobKOctetsSupported
+		kOctets = fmt.Sprintf("%d-%d", rng.Lower, rng.Upper)
+	}
+
+	return []string{
+		cnt.Time.UTC().Format(time.RFC3339),
+		cnt.Printer,
+		countersCSVInt(cnt.ImpressionsCompleted),
+		countersCSVInt(cnt.PagesCompleted),
+		countersCSVInt(cnt.UpTime),
+		kOctets,
+		countersCSVInt(cnt.JobQuotaPeriod),
+		countersCSVInt(cnt.JobKLimit),
+		countersCSVInt(cnt.JobPageLimit),
+	}
+}
+
+// countersCSVInt formats optional integer for CSV
+func countersCSVInt(v optional.Val[int]) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
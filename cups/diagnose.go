@@ -0,0 +1,513 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Per-queue health diagnostics
+
+package cups
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/generic"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// diagnoseQueueAttrs lists queue attributes, requested from CUPS
+// by the [Diagnose].
+var diagnoseQueueAttrs = []string{
+	"device-uri",
+	"printer-is-accepting-jobs",
+	"printer-name",
+	"printer-state",
+	"printer-state-message",
+	"printer-state-reasons",
+}
+
+// diagnoseDeviceAttrs lists device attributes, requested from
+// the IPP device by the [Diagnose].
+var diagnoseDeviceAttrs = []string{
+	"printer-make-and-model",
+	"printer-state",
+	"printer-state-message",
+	"printer-state-reasons",
+	"printer-uri-supported",
+	"uri-security-supported",
+}
+
+// printer-state values (RFC8011, 5.4.11)
+const (
+	diagnoseStateIdle       = 3
+	diagnoseStateProcessing = 4
+	diagnoseStateStopped    = 5
+)
+
+// Severity is the severity of the diagnostic [Finding].
+type Severity int
+
+// Severity values, in ascending order:
+const (
+	SeverityOK      Severity = iota // No problems found
+	SeverityInfo                    // Informational, no action required
+	SeverityWarning                 // Possible problem
+	SeverityError                   // Printing doesn't work
+)
+
+// String returns the Severity name.
+func (sev Severity) String() string {
+	switch sev {
+	case SeverityOK:
+		return "ok"
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(sev))
+}
+
+// Finding codes, reported by the [Diagnose]:
+const (
+	// Queue is stopped, but the device looks ready
+	FindingQueueStopped = "queue-stopped"
+
+	// Queue doesn't accept new jobs
+	FindingQueueRejecting = "queue-rejecting"
+
+	// Device reports the error condition
+	FindingDeviceError = "device-error"
+
+	// Device reports the warning condition
+	FindingDeviceWarning = "device-warning"
+
+	// Device is stopped
+	FindingDeviceStopped = "device-stopped"
+
+	// Device cannot be reached
+	FindingDeviceUnreachable = "device-unreachable"
+
+	// Device host name cannot be resolved
+	FindingStaleHostname = "stale-hostname"
+
+	// ipps:// device URI, but device doesn't support TLS
+	FindingTLSNotSupported = "tls-not-supported"
+
+	// ipp:// device URI, but device requires TLS
+	FindingTLSRequired = "tls-required"
+
+	// ipp:// device URI, but device supports TLS
+	FindingTLSAvailable = "tls-available"
+
+	// Device TLS certificate expires soon
+	FindingTLSExpiring = "tls-expiring"
+
+	// Device TLS certificate is expired
+	FindingTLSExpired = "tls-expired"
+
+	// CUPS reports problems, not confirmed by the device
+	FindingStateMismatch = "state-mismatch"
+
+	// Device URI scheme cannot be probed
+	FindingNotProbed = "not-probed"
+)
+
+// Finding is the single problem, found by the [Diagnose].
+type Finding struct {
+	Severity    Severity // Finding severity
+	Code        string   // Machine-readable code (FindingXXX)
+	Message     string   // Human-readable description
+	Remediation string   // Suggested remediation, if any
+}
+
+// Report is the result of the [Diagnose].
+type Report struct {
+	Printer   string // Printer (queue) name
+	DeviceURI string // Queue device URI
+
+	// Queue state, as reported by CUPS
+	QueueState        int                         // printer-state
+	QueueStateReasons []ipp.KwPrinterStateReasons // printer-state-reasons
+	QueueStateMessage string                      // printer-state-message
+	QueueAccepting    bool                        // printer-is-accepting-jobs
+
+	// Device state, as reported by the device itself.
+	// Only available for the ipp:// and ipps:// devices;
+	// DeviceState is 0 if unknown.
+	Probe              ProbeResult                 // Probe result
+	ProbeErr           error                       // Probe error, if any
+	DeviceState        int                         // printer-state
+	DeviceStateReasons []ipp.KwPrinterStateReasons // printer-state-reasons
+	DeviceStateMessage string                      // printer-state-message
+
+	// Device TLS certificate, as returned by the [InspectTLS].
+	// Only available for the reachable ipps:// devices.
+	TLS    TLSInfo // TLS certificate details
+	TLSErr error   // InspectTLS error, if any
+
+	// Findings, in order of discovery
+	Findings []Finding
+}
+
+// Severity returns the highest severity of the Report findings.
+func (r Report) Severity() Severity {
+	sev := SeverityOK
+	for _, f := range r.Findings {
+		sev = generic.Max(sev, f.Severity)
+	}
+	return sev
+}
+
+// add adds the Finding to the Report.
+func (r *Report) add(sev Severity, code, remediation, format string,
+	args ...any) {
+
+	r.Findings = append(r.Findings, Finding{
+		Severity:    sev,
+		Code:        code,
+		Message:     fmt.Sprintf(format, args...),
+		Remediation: remediation,
+	})
+}
+
+// StateString returns the printer-state value name.
+func StateString(state int) string {
+	switch state {
+	case 0:
+		return "unknown"
+	case diagnoseStateIdle:
+		return "idle"
+	case diagnoseStateProcessing:
+		return "processing"
+	case diagnoseStateStopped:
+		return "stopped"
+	}
+
+	return fmt.Sprintf("%d", state)
+}
+
+// Diagnose checks health of the CUPS queue.
+//
+// It fetches the queue attributes from CUPS, probes the queue
+// device directly (see [ProbeDeviceURI]), compares the device's
+// own state with the queue state, reported by CUPS, and checks
+// for common misconfigurations (TLS mismatch between the device
+// URI scheme and device capabilities, stale host name, expiring
+// TLS certificate).
+//
+// Only failure to obtain the queue attributes from CUPS is
+// returned as error. Problems with the device are reported as
+// the Report findings.
+func Diagnose(ctx context.Context, c *Client, printer string) (
+	Report, error) {
+
+	// Fetch the queue attributes
+	uri, err := c.printerURI(printer)
+	if err != nil {
+		return Report{}, err
+	}
+
+	rq := &ipp.GetPrinterAttributesRequest{
+		RequestHeader:       ipp.DefaultRequestHeader,
+		PrinterURI:          uri,
+		RequestedAttributes: diagnoseQueueAttrs,
+	}
+
+	rsp := &ipp.GetPrinterAttributesResponse{}
+	err = c.IPPClient.Do(ctx, rq, rsp)
+	// Note, successful-ok-ignored-or-substituted-attributes and
+	// similar are also successful; they occupy range 0x0000-0x00ff.
+	if err == nil && rsp.Status > 0x00ff {
+		err = fmt.Errorf("IPP: %s", rsp.Status)
+	}
+
+	if err != nil {
+		return Report{}, err
+	}
+
+	prn := rsp.Printer
+	r := Report{
+		Printer:           printer,
+		DeviceURI:         prn.DeviceURI,
+		QueueState:        optional.Get(prn.PrinterState),
+		QueueStateReasons: prn.PrinterStateReasons,
+		QueueStateMessage: optional.Get(prn.PrinterStateMessage),
+		QueueAccepting:    optional.Get(prn.PrinterIsAcceptingJobs),
+	}
+
+	// Check the queue
+	if !r.QueueAccepting && prn.PrinterIsAcceptingJobs != nil {
+		r.add(SeverityWarning, FindingQueueRejecting,
+			fmt.Sprintf("accept jobs: cupsaccept %s", printer),
+			"queue doesn't accept new jobs")
+	}
+
+	// Probe the device
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ProbeTimeout)
+		defer cancel()
+	}
+
+	u, err := probeParseURI(r.DeviceURI)
+	if err != nil {
+		r.add(SeverityInfo, FindingNotProbed, "",
+			"device not probed: %s", err)
+		diagnoseQueueOnly(&r)
+		return r, nil
+	}
+
+	switch u.Scheme {
+	case "ipp", "ipps":
+		diagnoseIPP(ctx, &r, u)
+	default:
+		r.Probe, r.ProbeErr = ProbeDeviceURI(ctx, r.DeviceURI)
+		if r.ProbeErr != nil {
+			diagnoseUnreachable(&r, u)
+		}
+		diagnoseQueueOnly(&r)
+	}
+
+	return r, nil
+}
+
+// diagnoseIPP probes the ipp:// or ipps:// device and compares
+// its state with the queue state.
+func diagnoseIPP(ctx context.Context, r *Report, u *url.URL) {
+	var dev *ipp.PrinterAttributes
+	r.Probe, dev, r.ProbeErr = probeIPPAttrs(ctx, u, diagnoseDeviceAttrs)
+
+	if r.ProbeErr != nil {
+		// Check for TLS mismatch: device may be reachable
+		// with the alternative scheme. It makes no sense
+		// if device is not reachable at the network level.
+		alt := transport.URLClone(u)
+		alt.Scheme = "ipps"
+		if u.Scheme == "ipps" {
+			alt.Scheme = "ipp"
+		}
+
+		err := r.ProbeErr
+		switch transport.ClassifyError(err) {
+		case transport.ErrClassDNSFailure,
+			transport.ErrClassNetworkUnreachable,
+			transport.ErrClassTimeout,
+			transport.ErrClassCanceled:
+		default:
+			_, _, err = probeIPPAttrs(ctx, alt, diagnoseDeviceAttrs)
+		}
+
+		switch {
+		case err == nil && u.Scheme == "ipps":
+			r.add(SeverityError, FindingTLSNotSupported,
+				"change device-uri to "+alt.String(),
+				"device doesn't support TLS, "+
+					"but device-uri uses ipps://")
+
+		case err == nil:
+			r.add(SeverityError, FindingTLSRequired,
+				"change device-uri to "+alt.String(),
+				"device requires TLS, "+
+					"but device-uri uses ipp://")
+
+		default:
+			diagnoseUnreachable(r, u)
+		}
+
+		diagnoseQueueOnly(r)
+		return
+	}
+
+	r.DeviceState = optional.Get(dev.PrinterState)
+	r.DeviceStateReasons = dev.PrinterStateReasons
+	r.DeviceStateMessage = optional.Get(dev.PrinterStateMessage)
+
+	// Check TLS capability
+	if u.Scheme == "ipp" && diagnoseTLSAvailable(dev) {
+		alt := transport.URLClone(u)
+		alt.Scheme = "ipps"
+		r.add(SeverityInfo, FindingTLSAvailable,
+			"consider changing device-uri to "+alt.String(),
+			"device supports TLS, but device-uri uses ipp://")
+	}
+
+	// Check TLS certificate
+	if u.Scheme == "ipps" {
+		diagnoseTLS(ctx, r, time.Now())
+	}
+
+	// Check device state
+	devErrors, devWarnings := diagnoseSplitReasons(r.DeviceStateReasons)
+
+	if len(devErrors) != 0 {
+		r.add(SeverityError, FindingDeviceError,
+			"fix the device problem; then resume the queue, "+
+				"if stopped: cupsenable "+r.Printer,
+			"device reports: %s", diagnoseReasons(devErrors))
+	} else if r.DeviceState == diagnoseStateStopped {
+		r.add(SeverityError, FindingDeviceStopped,
+			"check the device control panel",
+			"device is stopped")
+	}
+
+	if len(devWarnings) != 0 {
+		r.add(SeverityInfo, FindingDeviceWarning, "",
+			"device reports: %s", diagnoseReasons(devWarnings))
+	}
+
+	// Compare with the queue state
+	devOK := len(devErrors) == 0 && r.DeviceState != diagnoseStateStopped
+	if devOK && r.QueueState == diagnoseStateStopped {
+		r.add(SeverityWarning, FindingQueueStopped,
+			"resume the queue: cupsenable "+r.Printer,
+			"queue is stopped (%s), but device is ready",
+			r.queueStopReason())
+	}
+
+	queueErrors, _ := diagnoseSplitReasons(r.QueueStateReasons)
+	var mismatch []ipp.KwPrinterStateReasons
+	for _, reason := range queueErrors {
+		if !diagnoseHasReason(devErrors, reason) {
+			mismatch = append(mismatch, reason)
+		}
+	}
+
+	if len(mismatch) != 0 {
+		r.add(SeverityInfo, FindingStateMismatch, "",
+			"CUPS reports %s, not confirmed by device",
+			diagnoseReasons(mismatch))
+	}
+}
+
+// diagnoseTLS inspects the device TLS certificate and checks
+// its expiration.
+func diagnoseTLS(ctx context.Context, r *Report, now time.Time) {
+	r.TLS, r.TLSErr = InspectTLS(ctx, r.DeviceURI)
+	if r.TLSErr != nil {
+		return
+	}
+
+	notAfter := r.TLS.NotAfter.UTC().Format(time.DateOnly)
+
+	switch {
+	case r.TLS.Expired(now):
+		r.add(SeverityError, FindingTLSExpired,
+			"renew the device certificate",
+			"device TLS certificate expired on %s", notAfter)
+
+	case r.TLS.Expiring(now, TLSExpiryWarning):
+		r.add(SeverityWarning, FindingTLSExpiring,
+			"renew the device certificate",
+			"device TLS certificate expires on %s", notAfter)
+	}
+}
+
+// diagnoseQueueOnly checks the queue state, when the device
+// state is not available.
+func diagnoseQueueOnly(r *Report) {
+	if r.QueueState == diagnoseStateStopped && r.ProbeErr == nil {
+		r.add(SeverityWarning, FindingQueueStopped,
+			"resume the queue: cupsenable "+r.Printer,
+			"queue is stopped: %s", r.queueStopReason())
+	}
+}
+
+// diagnoseUnreachable adds the Finding for the unreachable device.
+func diagnoseUnreachable(r *Report, u *url.URL) {
+	class := transport.ClassifyError(r.ProbeErr)
+	hint := transport.Hint(class, u)
+
+	if class == transport.ErrClassDNSFailure {
+		r.add(SeverityError, FindingStaleHostname,
+			hint+"; update device-uri, if device was renamed",
+			"device host name %q cannot be resolved", u.Hostname())
+		return
+	}
+
+	r.add(SeverityError, FindingDeviceUnreachable, hint,
+		"device is unreachable: %s", r.ProbeErr)
+}
+
+// queueStopReason returns the reason of stopped queue, for
+// the diagnostic messages.
+func (r *Report) queueStopReason() string {
+	if r.QueueStateMessage != "" {
+		return r.QueueStateMessage
+	}
+	if len(r.QueueStateReasons) != 0 {
+		return diagnoseReasons(r.QueueStateReasons)
+	}
+	return "unknown reason"
+}
+
+// diagnoseTLSAvailable reports whether device supports TLS.
+func diagnoseTLSAvailable(dev *ipp.PrinterAttributes) bool {
+	for _, s := range dev.URISecuritySupported {
+		if s == ipp.KwURISecurityTLS {
+			return true
+		}
+	}
+
+	for _, s := range dev.PrinterURISupported {
+		if strings.HasPrefix(strings.ToLower(s), "ipps:") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diagnoseSplitReasons splits printer-state-reasons into
+// errors and warnings.
+//
+// Per RFC8011, 5.4.12, reasons without the severity suffix are
+// errors. The "-report" reasons and the "none" keyword are ignored.
+func diagnoseSplitReasons(reasons []ipp.KwPrinterStateReasons) (
+	errors, warnings []ipp.KwPrinterStateReasons) {
+
+	for _, reason := range reasons {
+		switch reason.Severity() {
+		case "", ipp.KwPrinterStateError:
+			if reason != ipp.KwPrinterStateNone {
+				errors = append(errors, reason)
+			}
+		case ipp.KwPrinterStateWarning:
+			warnings = append(warnings, reason)
+		}
+	}
+
+	return
+}
+
+// diagnoseHasReason reports whether reasons contain the reason,
+// ignoring the severity suffix.
+func diagnoseHasReason(reasons []ipp.KwPrinterStateReasons,
+	reason ipp.KwPrinterStateReasons) bool {
+
+	for _, r := range reasons {
+		if r.Reason() == reason.Reason() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diagnoseReasons formats printer-state-reasons for messages.
+func diagnoseReasons(reasons []ipp.KwPrinterStateReasons) string {
+	s := make([]string, len(reasons))
+	for i, reason := range reasons {
+		s[i] = string(reason)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,260 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Per-queue health diagnostics test
+
+package cups
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// testDiagnoseEnv is the test environment for Diagnose: the fake
+// CUPS and the virtual IPP device. Test modifies their attributes
+// to inject faults.
+type testDiagnoseEnv struct {
+	queue   *ipp.PrinterAttributes // Queue attributes, reported by CUPS
+	device  *ipp.PrinterAttributes // Device attributes
+	cups    *httptest.Server       // Fake CUPS
+	plain   *httptest.Server       // Device, ipp://
+	tls     *httptest.Server       // Device, ipps://
+	clnt    *Client                // CUPS client
+	ipp     string                 // ipp:// URI of the device
+	ipps    string                 // ipps:// URI of the TLS device
+	badipps string                 // ipps:// URI of the plain device
+}
+
+// newTestDiagnoseEnv creates a new testDiagnoseEnv.
+// The queue and device are initially healthy.
+func newTestDiagnoseEnv(t *testing.T) *testDiagnoseEnv {
+	env := &testDiagnoseEnv{
+		queue:  &ipp.PrinterAttributes{},
+		device: &ipp.PrinterAttributes{},
+	}
+
+	env.queue.PrinterName = optional.New("Test")
+	env.queue.PrinterState = optional.New(diagnoseStateIdle)
+	env.queue.PrinterStateReasons = []ipp.KwPrinterStateReasons{"none"}
+	env.queue.PrinterIsAcceptingJobs = optional.New(true)
+
+	env.device.PrinterMakeAndModel = optional.New("Virtual Printer")
+	env.device.PrinterState = optional.New(diagnoseStateIdle)
+	env.device.PrinterStateReasons = []ipp.KwPrinterStateReasons{"none"}
+
+	env.cups = httptest.NewServer(
+		ipp.NewPrinter(env.queue, ipp.PrinterOptions{}))
+	env.plain = httptest.NewServer(
+		ipp.NewPrinter(env.device, ipp.PrinterOptions{}))
+	env.tls = httptest.NewTLSServer(
+		ipp.NewPrinter(env.device, ipp.PrinterOptions{}))
+
+	t.Cleanup(func() {
+		env.cups.Close()
+		env.plain.Close()
+		env.tls.Close()
+	})
+
+	env.clnt = NewClient(transport.MustParseURL(env.cups.URL), nil)
+
+	plainAddr := strings.TrimPrefix(env.plain.URL, "http://")
+	tlsAddr := strings.TrimPrefix(env.tls.URL, "https://")
+
+	env.ipp = "ipp://" + plainAddr + "/ipp/print"
+	env.ipps = "ipps://" + tlsAddr + "/ipp/print"
+	env.badipps = "ipps://" + plainAddr + "/ipp/print"
+
+	env.queue.DeviceURI = env.ipp
+
+	return env
+}
+
+// TestDiagnose tests Diagnose
+func TestDiagnose(t *testing.T) {
+	// Obtain the address, nobody listens on
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := l.Addr().String()
+	l.Close()
+
+	type testData struct {
+		name     string                 // Test name
+		fault    func(*testDiagnoseEnv) // Injects the fault
+		codes    []string               // Expected findings
+		severity Severity               // Expected severity
+	}
+
+	tests := []testData{
+		{
+			name:     "healthy",
+			fault:    func(env *testDiagnoseEnv) {},
+			severity: SeverityOK,
+		},
+
+		{
+			name: "queue stopped, device ready",
+			fault: func(env *testDiagnoseEnv) {
+				env.queue.PrinterState = optional.New(
+					diagnoseStateStopped)
+				env.queue.PrinterStateMessage = optional.New(
+					"Stopped by admin")
+			},
+			codes:    []string{FindingQueueStopped},
+			severity: SeverityWarning,
+		},
+
+		{
+			name: "queue rejecting",
+			fault: func(env *testDiagnoseEnv) {
+				env.queue.PrinterIsAcceptingJobs = optional.New(false)
+			},
+			codes:    []string{FindingQueueRejecting},
+			severity: SeverityWarning,
+		},
+
+		{
+			name: "device error",
+			fault: func(env *testDiagnoseEnv) {
+				env.queue.PrinterState = optional.New(
+					diagnoseStateStopped)
+				env.device.PrinterState = optional.New(
+					diagnoseStateStopped)
+				env.device.PrinterStateReasons =
+					[]ipp.KwPrinterStateReasons{
+						"media-jam-error",
+						"toner-low-warning",
+					}
+			},
+			codes:    []string{FindingDeviceError, FindingDeviceWarning},
+			severity: SeverityError,
+		},
+
+		{
+			name: "device stopped",
+			fault: func(env *testDiagnoseEnv) {
+				env.device.PrinterState = optional.New(
+					diagnoseStateStopped)
+			},
+			codes:    []string{FindingDeviceStopped},
+			severity: SeverityError,
+		},
+
+		{
+			name: "state mismatch",
+			fault: func(env *testDiagnoseEnv) {
+				env.queue.PrinterStateReasons =
+					[]ipp.KwPrinterStateReasons{
+						"cover-open-error",
+					}
+			},
+			codes:    []string{FindingStateMismatch},
+			severity: SeverityInfo,
+		},
+
+		{
+			name: "device unreachable",
+			fault: func(env *testDiagnoseEnv) {
+				env.queue.DeviceURI = "ipp://" + closed + "/ipp/print"
+			},
+			codes:    []string{FindingDeviceUnreachable},
+			severity: SeverityError,
+		},
+
+		{
+			name: "stale hostname",
+			fault: func(env *testDiagnoseEnv) {
+				env.queue.DeviceURI = "socket://missed.invalid"
+			},
+			codes:    []string{FindingStaleHostname},
+			severity: SeverityError,
+		},
+
+		{
+			name: "ipps to device without TLS",
+			fault: func(env *testDiagnoseEnv) {
+				env.queue.DeviceURI = env.badipps
+			},
+			codes:    []string{FindingTLSNotSupported},
+			severity: SeverityError,
+		},
+
+		{
+			name: "ipp to device that requires TLS",
+			fault: func(env *testDiagnoseEnv) {
+				env.queue.DeviceURI = strings.Replace(env.ipps,
+					"ipps:", "ipp:", 1)
+			},
+			codes:    []string{FindingTLSRequired},
+			severity: SeverityError,
+		},
+
+		{
+			name: "TLS available",
+			fault: func(env *testDiagnoseEnv) {
+				env.device.URISecuritySupported =
+					[]ipp.KwURISecurity{"none", "tls"}
+			},
+			codes:    []string{FindingTLSAvailable},
+			severity: SeverityInfo,
+		},
+
+		{
+			name: "ipps to TLS device",
+			fault: func(env *testDiagnoseEnv) {
+				env.queue.DeviceURI = env.ipps
+				env.device.URISecuritySupported =
+					[]ipp.KwURISecurity{"tls"}
+			},
+			severity: SeverityOK,
+		},
+
+		{
+			name: "unsupported scheme",
+			fault: func(env *testDiagnoseEnv) {
+				env.queue.DeviceURI = "usb://HP/LaserJet"
+			},
+			codes:    []string{FindingNotProbed},
+			severity: SeverityInfo,
+		},
+	}
+
+	for _, test := range tests {
+		env := newTestDiagnoseEnv(t)
+		test.fault(env)
+
+		r, err := Diagnose(context.Background(), env.clnt, "Test")
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		codes := []string{}
+		for _, f := range r.Findings {
+			codes = append(codes, f.Code)
+		}
+
+		expected := strings.Join(test.codes, ",")
+		present := strings.Join(codes, ",")
+
+		if expected != present {
+			t.Errorf("%s: findings mismatch:\n"+
+				"expected: %s\npresent:  %s\nreport:   %+v",
+				test.name, expected, present, r.Findings)
+		}
+
+		if r.Severity() != test.severity {
+			t.Errorf("%s: severity mismatch:\n"+
+				"expected: %s\npresent:  %s",
+				test.name, test.severity, r.Severity())
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Job document download
+
+package cups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// Errors, returned by the [Client.GetDocument].
+//
+// They are wrapped with the status-message, returned by CUPS,
+// so use errors.Is to test for them.
+var (
+	// ErrDocumentNotPreserved means that job exists, but its
+	// document file is not kept by CUPS. Typically, it happens
+	// when the job is completed and PreserveJobFiles is disabled.
+	ErrDocumentNotPreserved = errors.New(
+		"job document not preserved (is PreserveJobFiles enabled?)")
+
+	// ErrDocumentNotFound means that job or document doesn't exist.
+	ErrDocumentNotFound = errors.New("job or document not found")
+
+	// ErrDocumentForbidden means that the user is not allowed
+	// to access the document (CUPS-Get-Document is the
+	// administrative operation by default).
+	ErrDocumentForbidden = errors.New("access to job document denied")
+)
+
+// getDocumentNotPreserved is the prefix of the status-message, that
+// CUPS returns, when the job document file cannot be opened.
+const getDocumentNotPreserved = "Unable to open document"
+
+// JobDocument is the job document, returned by the [Client.GetDocument].
+type JobDocument struct {
+	Number int           // Document number, starting from 1
+	Name   string        // Document name, may be empty
+	Format string        // Document format (MIME type), may be empty
+	Body   io.ReadCloser // Document content
+}
+
+// GetDocument retrieves the document of the job, spooled by CUPS,
+// using the CUPS-Get-Document request.
+//
+// The printer is the CUPS queue name, docnum is the document
+// number within the job, starting from 1.
+//
+// On success, caller MUST close the JobDocument.Body after use.
+func (c *Client) GetDocument(ctx context.Context,
+	printer string, jobID, docnum int) (*JobDocument, error) {
+
+	uri, err := c.printerURI(printer)
+	if err != nil {
+		return nil, err
+	}
+
+	rq := &ipp.CUPSGetDocumentRequest{
+		RequestHeader:  ipp.DefaultRequestHeader,
+		PrinterURI:     uri,
+		JobID:          jobID,
+		DocumentNumber: docnum,
+	}
+
+	rsp := &ipp.CUPSGetDocumentResponse{}
+
+	err = c.IPPClient.DoWithBody(ctx, rq, rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.Status != goipp.StatusOk {
+		rsp.Body.Close()
+		return nil, getDocumentError(rsp.Status, rsp.StatusMessage)
+	}
+
+	doc := &JobDocument{
+		Number: docnum,
+		Name:   optional.Get(rsp.DocumentName),
+		Format: optional.Get(rsp.DocumentFormat),
+		Body:   rsp.Body,
+	}
+
+	if rsp.DocumentNumber != nil {
+		doc.Number = *rsp.DocumentNumber
+	}
+
+	return doc, nil
+}
+
+// getDocumentError returns error for the failed CUPS-Get-Document
+// request.
+func getDocumentError(status goipp.Status, msg string) error {
+	var err error
+
+	switch status {
+	case goipp.StatusErrorNotFound:
+		err = ErrDocumentNotFound
+		if strings.HasPrefix(msg, getDocumentNotPreserved) {
+			err = ErrDocumentNotPreserved
+		}
+
+	case goipp.StatusErrorForbidden,
+		goipp.StatusErrorNotAuthenticated,
+		goipp.StatusErrorNotAuthorized:
+		err = ErrDocumentForbidden
+
+	default:
+		err = fmt.Errorf("IPP: %s", status)
+	}
+
+	if msg != "" {
+		err = fmt.Errorf("%w: %s", err, msg)
+	}
+
+	return err
+}
@@ -0,0 +1,145 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Job document download test
+
+package cups
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestGetDocument tests Client.GetDocument
+func TestGetDocument(t *testing.T) {
+	// Large enough to not fit into a single read buffer
+	payload := bytes.Repeat([]byte("%PDF-1.7 test document\n"), 10000)
+
+	// Fake CUPS. Job 1 has 2 documents, job 2 has the documents
+	// purged, job 3 belongs to somebody else.
+	mux := ipp.NewServeMux(ipp.ServerOptions{})
+	mux.Handle(goipp.OpCupsGetDocument,
+		func(ctx context.Context, msg *goipp.Message,
+			body io.Reader) (*goipp.Message, io.Reader, error) {
+
+			var rq ipp.CUPSGetDocumentRequest
+			err := rq.Decode(msg, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if !strings.HasSuffix(rq.PrinterURI, "/printers/Test") {
+				t.Errorf("printer-uri: %q", rq.PrinterURI)
+			}
+
+			rsp := &ipp.CUPSGetDocumentResponse{
+				ResponseHeader: ipp.ResponseHeader{
+					Version:   msg.Version,
+					RequestID: msg.RequestID,
+					Status:    goipp.StatusOk,
+				},
+			}
+
+			switch {
+			case rq.JobID == 1 && rq.DocumentNumber == 2:
+				rsp.DocumentFormat = optional.New("application/pdf")
+				rsp.DocumentName = optional.New("report.pdf")
+				rsp.DocumentNumber = optional.New(2)
+				return rsp.Encode(), bytes.NewReader(payload), nil
+
+			case rq.JobID == 1:
+				rsp.Status = goipp.StatusErrorNotFound
+				rsp.StatusMessage = "Document #3 does not exist " +
+					"in job #1."
+
+			case rq.JobID == 2:
+				rsp.Status = goipp.StatusErrorNotFound
+				rsp.StatusMessage = "Unable to open document " +
+					"#1 in job #2."
+
+			case rq.JobID == 3:
+				rsp.Status = goipp.StatusErrorForbidden
+				rsp.StatusMessage = "Not authorized to access " +
+					"document #1 in job #3 owned by \"root\"."
+
+			default:
+				rsp.Status = goipp.StatusErrorNotFound
+				rsp.StatusMessage = "Job #4 does not exist."
+			}
+
+			return rsp.Encode(), nil, nil
+		})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	clnt := NewClient(transport.MustParseURL(srv.URL), nil)
+	ctx := context.Background()
+
+	// Successful download
+	doc, err := clnt.GetDocument(ctx, "Test", 1, 2)
+	if err != nil {
+		t.Fatalf("GetDocument: %s", err)
+	}
+
+	data, err := io.ReadAll(doc.Body)
+	doc.Body.Close()
+
+	switch {
+	case err != nil:
+		t.Errorf("GetDocument: %s", err)
+	case !bytes.Equal(data, payload):
+		t.Errorf("GetDocument: payload mismatch (%d bytes received)",
+			len(data))
+	}
+
+	if doc.Number != 2 || doc.Name != "report.pdf" ||
+		doc.Format != "application/pdf" {
+		t.Errorf("GetDocument: metadata mismatch: %d %q %q",
+			doc.Number, doc.Name, doc.Format)
+	}
+
+	// Errors
+	type testData struct {
+		job, docnum int    // Request parameters
+		err         error  // Expected error
+		msg         string // Expected text in the error message
+	}
+
+	tests := []testData{
+		{1, 3, ErrDocumentNotFound, "Document #3 does not exist"},
+		{2, 1, ErrDocumentNotPreserved, "Unable to open document"},
+		{3, 1, ErrDocumentForbidden, "Not authorized"},
+		{4, 1, ErrDocumentNotFound, "Job #4 does not exist"},
+	}
+
+	for _, test := range tests {
+		doc, err := clnt.GetDocument(ctx, "Test", test.job, test.docnum)
+		if doc != nil {
+			t.Errorf("job %d document %d: unexpected success",
+				test.job, test.docnum)
+			doc.Body.Close()
+			continue
+		}
+
+		if !errors.Is(err, test.err) ||
+			!strings.Contains(err.Error(), test.msg) {
+			t.Errorf("job %d document %d: error mismatch:\n"+
+				"expected: %s (%s)\npresent:  %v",
+				test.job, test.docnum, test.err, test.msg, err)
+		}
+	}
+}
@@ -0,0 +1,505 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printing and job validation
+
+package cups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/proto/ipp/iana"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// DefaultDocumentFormat is the document format, used by the
+// [Client.Print], if PrintOptions.DocumentFormat is not set.
+const DefaultDocumentFormat = "application/octet-stream"
+
+// DefaultMaxClockSkew is the maximum difference between the local
+// and printer clocks, tolerated by [Client.Print] and [Client.ValidateJob]
+// for jobs with time-based attributes, if PrintOptions.MaxClockSkew
+// is not set.
+const DefaultMaxClockSkew = 5 * time.Minute
+
+// cancelJobTimeout limits Cancel-Job request, sent by the
+// [Client.PrintMany] if it fails to send document.
+const cancelJobTimeout = 10 * time.Second
+
+// ErrMultipleDocumentsUnsupported is returned by the [Client.PrintMany],
+// if printer doesn't support multiple-document jobs.
+var ErrMultipleDocumentsUnsupported = errors.New(
+	"printer doesn't support multiple-document jobs")
+
+// Document is the document, printed by the [Client.PrintMany].
+type Document struct {
+	// Name is the document name. Optional.
+	Name string
+
+	// Format is the MIME type of the document. Optional.
+	// If not set, PrintOptions.DocumentFormat is used.
+	Format string
+
+	// Body is the document content.
+	Body io.Reader
+}
+
+// PrintOptions contains parameters of the print job for
+// [Client.Print] and [Client.ValidateJob].
+type PrintOptions struct {
+	// JobName is the job name. Optional.
+	JobName string
+
+	// UserName is the requesting user name. Optional.
+	UserName string
+
+	// DocumentFormat is the MIME type of the document. Optional.
+	DocumentFormat string
+
+	// Options are the job options, in the name=value form.
+	//
+	// Name is either the user-friendly option name (see
+	// [ResolveUserOption]) or any IPP Job Template attribute.
+	// Values of the 1setOf attributes are comma-separated.
+	// The dateTime values are in the RFC 3339 format.
+	Options []string
+
+	// MaxClockSkew is the maximum tolerated difference between
+	// the local and printer clocks. If job uses time-based attributes
+	// (job-hold-until-time and similar) and the printer clock differs
+	// more, the warning is logged, as the job will be held or retained
+	// not for the time the user expects. Optional.
+	//
+	// If not set, DefaultMaxClockSkew is used.
+	MaxClockSkew time.Duration
+}
+
+// ValidationResult is the result of the [Client.ValidateJob].
+type ValidationResult struct {
+	// Accepted reports if printer would accept the job.
+	Accepted bool
+
+	// Status and StatusMessage, returned by the printer.
+	Status        goipp.Status
+	StatusMessage string
+
+	// UnsupportedAttributes lists the job attributes or values,
+	// not supported by the printer, if any.
+	UnsupportedAttributes goipp.Attributes
+}
+
+// Print prints the document on the printer, specified by the
+// printer URI, and returns the job ID.
+//
+// Job options are resolved against the printer capabilities,
+// exactly as [Client.ValidateJob] does.
+func (c *Client) Print(ctx context.Context, printerURI string,
+	opts PrintOptions, document io.Reader) (int, error) {
+
+	// Create the job
+	op, tmpl, err := c.jobAttrs(ctx, printerURI, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	job, err := c.IPPClient.CreateJob(ctx, op, tmpl)
+	if err == nil && job.Status != goipp.StatusOk {
+		err = fmt.Errorf("IPP: %s", job.Status)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	// Send document
+	doc := Document{Name: opts.JobName, Body: document}
+	err = c.sendDocument(ctx, printerURI, job.Job.JobID, opts, doc, true)
+	if err != nil {
+		return 0, err
+	}
+
+	return job.Job.JobID, nil
+}
+
+// PrintMany prints multiple documents as a single job on the printer,
+// specified by the printer URI, and returns the job ID.
+//
+// The job is created by the Create-Job request, then documents are
+// sent by the sequence of Send-Document requests, and only the last
+// of them has the last-document attribute set.
+//
+// If printer doesn't support multiple-document jobs, and more than
+// one document is requested to print, PrintMany returns
+// [ErrMultipleDocumentsUnsupported] before anything is uploaded.
+//
+// If some of documents cannot be sent, the job is canceled.
+func (c *Client) PrintMany(ctx context.Context, printerURI string,
+	docs []Document, opts PrintOptions) (int, error) {
+
+	if len(docs) == 0 {
+		return 0, errors.New("no documents to print")
+	}
+
+	// Check printer capabilities
+	if len(docs) > 1 {
+		rq := &ipp.GetPrinterAttributesRequest{
+			RequestHeader: ipp.DefaultRequestHeader,
+			PrinterURI:    printerURI,
+			RequestedAttributes: []string{
+				"multiple-document-jobs-supported",
+				"printer-name", // Required; keeps response non-empty
+			},
+		}
+
+		rsp := &ipp.GetPrinterAttributesResponse{}
+		err := c.IPPClient.Do(ctx, rq, rsp)
+		if err == nil && rsp.Status >= goipp.StatusRedirectionOtherSite {
+			err = fmt.Errorf("IPP: %s", rsp.Status)
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		if !optional.Get(rsp.Printer.MultipleDocumentJobsSupported) {
+			return 0, ErrMultipleDocumentsUnsupported
+		}
+	}
+
+	// Create the job
+	op, tmpl, err := c.jobAttrs(ctx, printerURI, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	job, err := c.IPPClient.CreateJob(ctx, op, tmpl)
+	if err == nil && job.Status != goipp.StatusOk {
+		err = fmt.Errorf("IPP: %s", job.Status)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	// Send documents
+	for i, doc := range docs {
+		last := i == len(docs)-1
+		err = c.sendDocument(ctx, printerURI, job.Job.JobID,
+			opts, doc, last)
+
+		if err != nil {
+			c.cancelJob(ctx, printerURI, job.Job.JobID, opts)
+			return 0, fmt.Errorf("document %d: %w", i+1, err)
+		}
+	}
+
+	return job.Job.JobID, nil
+}
+
+// sendDocument sends the document of the job, created by the
+// Create-Job request.
+func (c *Client) sendDocument(ctx context.Context, printerURI string,
+	jobID int, opts PrintOptions, doc Document, last bool) error {
+
+	format := doc.Format
+	if format == "" {
+		format = opts.DocumentFormat
+	}
+	if format == "" {
+		format = DefaultDocumentFormat
+	}
+
+	rq := &ipp.SendDocumentRequest{
+		RequestHeader:      ipp.DefaultRequestHeader,
+		PrinterURI:         optional.New(printerURI),
+		JobID:              optional.New(jobID),
+		RequestingUserName: optional.NotZero(opts.UserName),
+		DocumentFormat:     optional.New(format),
+		DocumentName:       optional.NotZero(doc.Name),
+		LastDocument:       last,
+	}
+
+	rq.Body = doc.Body
+
+	rsp := &ipp.SendDocumentResponse{}
+	err := c.IPPClient.Do(ctx, rq, rsp)
+	if err == nil && rsp.Status != goipp.StatusOk {
+		err = fmt.Errorf("IPP: %s", rsp.Status)
+	}
+
+	return err
+}
+
+// cancelJob cancels the job after failed Send-Document.
+//
+// The job is canceled even if ctx is already canceled, and
+// errors are ignored, as the original error is more important.
+func (c *Client) cancelJob(ctx context.Context, printerURI string,
+	jobID int, opts PrintOptions) {
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx),
+		cancelJobTimeout)
+	defer cancel()
+
+	rq := &ipp.CancelJobRequest{
+		RequestHeader: ipp.DefaultRequestHeader,
+		JobCancelOperation: ipp.JobCancelOperation{
+			PrinterURI:         optional.New(printerURI),
+			JobID:              optional.New(jobID),
+			RequestingUserName: optional.NotZero(opts.UserName),
+		},
+	}
+
+	rsp := &ipp.CancelJobResponse{}
+	c.IPPClient.Do(ctx, rq, rsp)
+}
+
+// ValidateJob sends the Validate-Job request with the same job
+// attributes, as [Client.Print] would send, and reports whether
+// the printer would accept the job.
+//
+// Rejection of the job by the printer is not an error: it is
+// reported by the returned [ValidationResult].
+func (c *Client) ValidateJob(ctx context.Context, printerURI string,
+	opts PrintOptions) (*ValidationResult, error) {
+
+	op, tmpl, err := c.jobAttrs(ctx, printerURI, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rq := &ipp.ValidateJobRequest{
+		RequestHeader:      ipp.DefaultRequestHeader,
+		JobCreateOperation: op,
+		JobTemplate:        tmpl,
+	}
+
+	rsp := &ipp.ValidateJobResponse{}
+	err = c.IPPClient.Do(ctx, rq, rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ValidationResult{
+		Accepted:              rsp.Status < goipp.StatusRedirectionOtherSite,
+		Status:                rsp.Status,
+		StatusMessage:         rsp.StatusMessage,
+		UnsupportedAttributes: rsp.UnsupportedAttributes,
+	}
+
+	return res, nil
+}
+
+// jobAttrs obtains the printer capabilities and builds the
+// Operation and Job Template attributes of the job.
+func (c *Client) jobAttrs(ctx context.Context, printerURI string,
+	opts PrintOptions) (
+	op ipp.JobCreateOperation, tmpl *ipp.JobTemplate, err error) {
+
+	// Obtain printer capabilities
+	rq := &ipp.GetPrinterAttributesRequest{
+		RequestHeader: ipp.DefaultRequestHeader,
+		PrinterURI:    printerURI,
+		RequestedAttributes: []string{
+			"job-template",
+			"printer-current-time",
+		},
+	}
+
+	rsp := &ipp.GetPrinterAttributesResponse{}
+	err = c.IPPClient.Do(ctx, rq, rsp)
+	if err == nil && rsp.Status != goipp.StatusOk {
+		err = fmt.Errorf("IPP: %s", rsp.Status)
+	}
+
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	// Build job template
+	tmpl = &ipp.JobTemplate{}
+	for _, opt := range opts.Options {
+		var attr goipp.Attribute
+		attr, err = jobOption(opt,
+			&rsp.Printer.JobTemplateCapabilities)
+
+		if err == nil {
+			err = ipp.ObjectSetAttr(tmpl, attr)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	if msg := jobClockSkewCheck(rsp.Printer, tmpl, now,
+		opts.MaxClockSkew); msg != "" {
+		log.Warning(ctx, "%s", msg)
+	}
+
+	op = ipp.JobCreateOperation{
+		PrinterURI:         printerURI,
+		RequestingUserName: optional.NotZero(opts.UserName),
+		JobName:            optional.NotZero(opts.JobName),
+		DocumentFormat:     optional.NotZero(opts.DocumentFormat),
+	}
+
+	return
+}
+
+// jobClockSkewCheck checks the printer clock skew, if the job uses
+// time-based attributes. The now parameter is the time when printer
+// attributes were received.
+//
+// It returns the warning message, or "" if everything is OK or
+// printer doesn't report its current time.
+func jobClockSkewCheck(pa *ipp.PrinterAttributes, tmpl *ipp.JobTemplate,
+	now time.Time, maxSkew time.Duration) string {
+
+	var names []string
+	if tmpl.JobHoldUntilTime != nil {
+		names = append(names, "job-hold-until-time")
+	}
+	if tmpl.JobRetainUntilTime != nil {
+		names = append(names, "job-retain-until-time")
+	}
+	if tmpl.JobDelayOutputUntilTime != nil {
+		names = append(names, "job-delay-output-until-time")
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+
+	if maxSkew == 0 {
+		maxSkew = DefaultMaxClockSkew
+	}
+
+	skew, ok := pa.ClockSkew(now)
+	if !ok || (skew <= maxSkew && skew >= -maxSkew) {
+		return ""
+	}
+
+	dir := "ahead of"
+	if skew < 0 {
+		skew = -skew
+		dir = "behind"
+	}
+
+	return fmt.Sprintf("printer clock is %s %s local clock; "+
+		"%s may not work as expected",
+		skew.Round(time.Second), dir, strings.Join(names, ", "))
+}
+
+// jobOption converts the name=value job option into the IPP
+// attribute.
+//
+// The user-friendly options are resolved by [ResolveUserOption],
+// against the printer capabilities. Other options are treated as the
+// raw IPP Job Template attributes.
+func jobOption(opt string,
+	caps *ipp.JobTemplateCapabilities) (goipp.Attribute, error) {
+
+	name, value, ok := strings.Cut(opt, "=")
+	if !ok || name == "" {
+		return goipp.Attribute{},
+			fmt.Errorf("%s: must be name=value", opt)
+	}
+
+	attrName, attrValue, err := ResolveUserOption(name, value, caps)
+	switch {
+	case err == nil:
+		name = attrName
+		value = fmt.Sprint(attrValue)
+
+	case !errors.Is(err, ErrUserOptionUnknown):
+		return goipp.Attribute{}, err
+	}
+
+	return jobRawAttr(name, value)
+}
+
+// jobRawAttr makes the IPP Job Template attribute out of its
+// name and string value.
+//
+// The value syntax is chosen according to the attribute definition.
+// The 1setOf attribute values are comma-separated.
+func jobRawAttr(name, value string) (goipp.Attribute, error) {
+	def := iana.JobTemplate[name]
+	switch {
+	case def == nil:
+		return goipp.Attribute{},
+			fmt.Errorf("%s: unknown job attribute", name)
+	case def.IsCollection():
+		return goipp.Attribute{},
+			fmt.Errorf("%s: collections not supported", name)
+	}
+
+	values := []string{value}
+	if def.SetOf {
+		values = strings.Split(value, ",")
+	}
+
+	attr := goipp.Attribute{Name: name}
+
+NEXT:
+	for _, s := range values {
+		for _, tag := range def.Tags {
+			switch tag {
+			case goipp.TagInteger, goipp.TagEnum:
+				if v, err := strconv.Atoi(s); err == nil {
+					attr.Values.Add(tag, goipp.Integer(v))
+					continue NEXT
+				}
+
+			case goipp.TagBoolean:
+				if v, err := strconv.ParseBool(s); err == nil {
+					attr.Values.Add(tag, goipp.Boolean(v))
+					continue NEXT
+				}
+
+			case goipp.TagDateTime:
+				if v, err := time.Parse(time.RFC3339, s); err == nil {
+					attr.Values.Add(tag, goipp.Time{Time: v})
+					continue NEXT
+				}
+
+			case goipp.TagRange:
+				lo, hi, ok := strings.Cut(s, "-")
+				l, err1 := strconv.Atoi(lo)
+				h, err2 := strconv.Atoi(hi)
+				if ok && err1 == nil && err2 == nil {
+					attr.Values.Add(tag, goipp.Range{
+						Lower: l, Upper: h})
+					continue NEXT
+				}
+
+			case goipp.TagKeyword, goipp.TagName, goipp.TagText,
+				goipp.TagURI, goipp.TagURIScheme,
+				goipp.TagMimeType, goipp.TagLanguage,
+				goipp.TagCharset:
+				attr.Values.Add(tag, goipp.String(s))
+				continue NEXT
+			}
+		}
+
+		return goipp.Attribute{},
+			fmt.Errorf("%s=%s: invalid value", name, value)
+	}
+
+	return attr, nil
+}
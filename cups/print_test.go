@@ -0,0 +1,458 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printing and job validation test
+
+package cups
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/abstract"
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/transport/testutil"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// testPrinter is the virtual IPP printer, that records
+// Job attributes of the received requests.
+type testPrinter struct {
+	*testutil.FakeIPPPrinter
+}
+
+// newTestPrinter creates a new testPrinter
+func newTestPrinter() *testPrinter {
+	attrs := &ipp.PrinterAttributes{}
+	attrs.PrinterName = optional.New("test")
+	attrs.CopiesSupported = optional.New(goipp.Range{Lower: 1, Upper: 99})
+	attrs.MediaSupported = []ipp.KwMedia{"iso_a4_210x297mm",
+		"na_letter_8.5x11in"}
+	attrs.SidesSupported = []ipp.KwSides{ipp.KwSidesOneSided,
+		ipp.KwSidesTwoSidedLongEdge}
+	attrs.DocumentFormatSupported = []string{DefaultDocumentFormat}
+
+	return &testPrinter{testutil.NewFakeIPPPrinter(attrs, testutil.Options{})}
+}
+
+// jobAttrs returns Job attributes of the last received request
+// with the specified operation.
+func (prn *testPrinter) jobAttrs(op goipp.Op) goipp.Attributes {
+	requests := prn.Log().ByOp(op)
+	if len(requests) == 0 {
+		return nil
+	}
+
+	return requests[len(requests)-1].IPP.Job
+}
+
+// ops returns operations of the received requests, in order.
+func (prn *testPrinter) ops() []goipp.Op {
+	var ops []goipp.Op
+	for _, rq := range prn.Log().Requests() {
+		ops = append(ops, rq.Op())
+	}
+
+	return ops
+}
+
+// TestValidateJob tests Client.ValidateJob
+func TestValidateJob(t *testing.T) {
+	prn := newTestPrinter()
+	defer prn.Close()
+
+	ctx := context.Background()
+	c := NewClient(transport.MustParseURL(prn.URL), nil)
+
+	// Accepted ticket
+	opts := PrintOptions{
+		JobName: "test",
+		Options: []string{"copies=2", "duplex=long",
+			"media=iso_a4_210x297mm"},
+	}
+
+	res, err := c.ValidateJob(ctx, prn.URL, opts)
+	if err != nil {
+		t.Fatalf("ValidateJob: %s", err)
+	}
+
+	if !res.Accepted || len(res.UnsupportedAttributes) != 0 {
+		t.Errorf("ValidateJob: job must be accepted, present: %s %v",
+			res.Status, res.UnsupportedAttributes)
+	}
+
+	// Rejected ticket: unsupported value and unsupported attribute
+	opts.Options = []string{"copies=200", "job-priority=50"}
+
+	res, err = c.ValidateJob(ctx, prn.URL, opts)
+	if err != nil {
+		t.Fatalf("ValidateJob: %s", err)
+	}
+
+	if res.Accepted ||
+		res.Status != goipp.StatusErrorAttributesOrValues {
+		t.Errorf("ValidateJob: job must be rejected, present: %s",
+			res.Status)
+	}
+
+	expected := goipp.Attributes{
+		goipp.MakeAttribute("copies",
+			goipp.TagInteger, goipp.Integer(200)),
+		goipp.MakeAttribute("job-priority",
+			goipp.TagUnsupportedValue, goipp.Void{}),
+	}
+
+	if !res.UnsupportedAttributes.Similar(expected) {
+		t.Errorf("ValidateJob: unsupported attributes:\n"+
+			"expected: %v\npresent:  %v",
+			expected, res.UnsupportedAttributes)
+	}
+
+	// Options, rejected client-side, are not sent
+	opts.Options = []string{"duplex=short"}
+	_, err = c.ValidateJob(ctx, prn.URL, opts)
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("ValidateJob: client-side error expected, present %v",
+			err)
+	}
+}
+
+// TestPrintValidateEquivalence tests that Client.Print and
+// Client.ValidateJob send the same Job attributes.
+func TestPrintValidateEquivalence(t *testing.T) {
+	prn := newTestPrinter()
+	defer prn.Close()
+
+	ctx := context.Background()
+	c := NewClient(transport.MustParseURL(prn.URL), nil)
+
+	opts := PrintOptions{
+		JobName:  "test",
+		UserName: "user",
+		Options: []string{"copies=3", "duplex=long",
+			"media=na_letter_8.5x11in", "page-ranges=1-2"},
+	}
+
+	_, err := c.ValidateJob(ctx, prn.URL, opts)
+	if err != nil {
+		t.Fatalf("ValidateJob: %s", err)
+	}
+
+	_, err = c.Print(ctx, prn.URL, opts, strings.NewReader("document"))
+	if err != nil {
+		t.Fatalf("Print: %s", err)
+	}
+
+	validate := prn.jobAttrs(goipp.OpValidateJob)
+	create := prn.jobAttrs(goipp.OpCreateJob)
+
+	if len(validate) == 0 || !validate.Similar(create) {
+		t.Errorf("Job attributes mismatch:\n"+
+			"Validate-Job: %v\nCreate-Job:   %v", validate, create)
+	}
+}
+
+// testPrintBackend is the abstract.Printer that records
+// received documents.
+type testPrintBackend struct {
+	lock sync.Mutex
+	docs []testPrintDocument
+}
+
+// testPrintDocument is the document, received by the testPrintBackend
+type testPrintDocument struct {
+	Name, Format, Body string
+}
+
+// PrintDocument records the received document.
+func (backend *testPrintBackend) PrintDocument(
+	params abstract.PrinterRequest, body io.Reader) error {
+
+	data, err := io.ReadAll(body)
+	backend.lock.Lock()
+	backend.docs = append(backend.docs, testPrintDocument{
+		params.JobName, params.Format, string(data)})
+	backend.lock.Unlock()
+
+	return err
+}
+
+// testJobState returns state of the job at the testPrinter
+func testJobState(t *testing.T, c *Client,
+	prn *testPrinter, jobID int) ipp.EnJobState {
+
+	rq := &ipp.GetJobAttributesRequest{
+		RequestHeader: ipp.DefaultRequestHeader,
+		PrinterURI:    optional.New(prn.URL),
+		JobID:         optional.New(jobID),
+	}
+
+	rsp := &ipp.GetJobAttributesResponse{}
+	err := c.IPPClient.Do(context.Background(), rq, rsp)
+	if err == nil && rsp.Status != goipp.StatusOk {
+		err = fmt.Errorf("IPP: %s", rsp.Status)
+	}
+
+	if err != nil {
+		t.Fatalf("Get-Job-Attributes: %s", err)
+	}
+
+	return rsp.Job.JobState
+}
+
+// testFailedReader is the io.Reader that always fails
+type testFailedReader struct{}
+
+// Read returns an error
+func (testFailedReader) Read([]byte) (int, error) {
+	return 0, errors.New("read error")
+}
+
+// TestPrintMany tests Client.PrintMany
+func TestPrintMany(t *testing.T) {
+	prn := newTestPrinter()
+	defer prn.Close()
+
+	prn.Attrs.MultipleDocumentJobsSupported = optional.New(true)
+	backend := &testPrintBackend{}
+	prn.Printer.SetPrintBackend(backend)
+
+	ctx := context.Background()
+	c := NewClient(transport.MustParseURL(prn.URL), nil)
+
+	opts := PrintOptions{
+		JobName:        "test",
+		DocumentFormat: "text/plain",
+	}
+
+	docs := []Document{
+		{Name: "one", Body: strings.NewReader("1")},
+		{Name: "two", Format: "application/pdf",
+			Body: strings.NewReader("2")},
+		{Name: "three", Body: strings.NewReader("3")},
+	}
+
+	jobID, err := c.PrintMany(ctx, prn.URL, docs, opts)
+	if err != nil {
+		t.Fatalf("PrintMany: %s", err)
+	}
+
+	expected := []testPrintDocument{
+		{"one", "text/plain", "1"},
+		{"two", "application/pdf", "2"},
+		{"three", "text/plain", "3"},
+	}
+
+	if !reflect.DeepEqual(backend.docs, expected) {
+		t.Errorf("PrintMany: documents mismatch:\n"+
+			"expected: %v\npresent:  %v", expected, backend.docs)
+	}
+
+	state := testJobState(t, c, prn, jobID)
+	if state != ipp.EnJobStateCompleted {
+		t.Errorf("PrintMany: job state: expected %v, present %v",
+			ipp.EnJobStateCompleted, state)
+	}
+}
+
+// TestPrintManyFailure tests that Client.PrintMany cancels the
+// job, if some of documents cannot be sent.
+func TestPrintManyFailure(t *testing.T) {
+	prn := newTestPrinter()
+	defer prn.Close()
+
+	prn.Attrs.MultipleDocumentJobsSupported = optional.New(true)
+	backend := &testPrintBackend{}
+	prn.Printer.SetPrintBackend(backend)
+
+	ctx := context.Background()
+	c := NewClient(transport.MustParseURL(prn.URL), nil)
+
+	docs := []Document{
+		{Name: "one", Body: strings.NewReader("1")},
+		{Name: "two", Body: testFailedReader{}},
+		{Name: "three", Body: strings.NewReader("3")},
+	}
+
+	_, err := c.PrintMany(ctx, prn.URL, docs, PrintOptions{})
+	if err == nil {
+		t.Fatalf("PrintMany: error expected")
+	}
+
+	ops := prn.ops()
+
+	if len(ops) == 0 || ops[len(ops)-1] != goipp.OpCancelJob {
+		t.Fatalf("PrintMany: Cancel-Job expected, present %v", ops)
+	}
+
+	if len(backend.docs) != 1 {
+		t.Errorf("PrintMany: %d documents received, expected 1",
+			len(backend.docs))
+	}
+
+	state := testJobState(t, c, prn, 1)
+	if state != ipp.EnJobStateCanceled {
+		t.Errorf("PrintMany: job state: expected %v, present %v",
+			ipp.EnJobStateCanceled, state)
+	}
+}
+
+// TestPrintManyUnsupported tests Client.PrintMany with printer
+// that doesn't support multiple-document jobs.
+func TestPrintManyUnsupported(t *testing.T) {
+	prn := newTestPrinter()
+	defer prn.Close()
+
+	ctx := context.Background()
+	c := NewClient(transport.MustParseURL(prn.URL), nil)
+
+	docs := []Document{
+		{Name: "one", Body: strings.NewReader("1")},
+		{Name: "two", Body: strings.NewReader("2")},
+	}
+
+	_, err := c.PrintMany(ctx, prn.URL, docs, PrintOptions{})
+	if !errors.Is(err, ErrMultipleDocumentsUnsupported) {
+		t.Errorf("PrintMany: expected %q, present %v",
+			ErrMultipleDocumentsUnsupported, err)
+	}
+
+	for _, op := range prn.ops() {
+		if op != goipp.OpGetPrinterAttributes {
+			t.Errorf("PrintMany: unexpected %s request", op)
+		}
+	}
+}
+
+// TestPrintClockSkew tests the printer clock skew warning for
+// jobs with time-based attributes.
+func TestPrintClockSkew(t *testing.T) {
+	prn := newTestPrinter()
+	defer prn.Close()
+
+	prn.Attrs.PrinterCurrentTime = optional.New(time.Now().Add(time.Hour))
+
+	buf := &bytes.Buffer{}
+	lgr := log.NewLogger(log.LevelWarning, log.NewWriterBackend(buf))
+	ctx := log.NewContext(context.Background(), lgr)
+	c := NewClient(transport.MustParseURL(prn.URL), nil)
+
+	// The dateTime value must be sent with its original offset
+	opts := PrintOptions{
+		Options: []string{"job-hold-until-time=2025-06-01T14:00:00+02:00"},
+	}
+
+	_, err := c.ValidateJob(ctx, prn.URL, opts)
+	if err != nil {
+		t.Fatalf("ValidateJob: %s", err)
+	}
+
+	job := prn.jobAttrs(goipp.OpValidateJob)
+
+	var sent string
+	for _, attr := range job {
+		if attr.Name == "job-hold-until-time" {
+			sent = attr.Values.String()
+		}
+	}
+
+	if sent != "2025-06-01T14:00:00+02:00" {
+		t.Errorf("job-hold-until-time: sent as %q", sent)
+	}
+
+	if !strings.Contains(buf.String(),
+		"printer clock is 1h0m0s ahead of local clock") {
+		t.Errorf("clock skew warning expected, log:\n%s", buf)
+	}
+
+	// Larger MaxClockSkew: no warning
+	buf.Reset()
+	opts.MaxClockSkew = 2 * time.Hour
+	c.ValidateJob(ctx, prn.URL, opts)
+
+	if buf.Len() != 0 {
+		t.Errorf("unexpected warning:\n%s", buf)
+	}
+}
+
+// TestJobClockSkewCheck tests jobClockSkewCheck
+func TestJobClockSkewCheck(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	hold := &ipp.JobTemplate{}
+	hold.JobHoldUntilTime = optional.New(now)
+	retain := &ipp.JobTemplate{}
+	retain.JobRetainUntilTime = optional.New(now)
+
+	type testData struct {
+		skew     optional.Val[time.Duration] // nil if missed
+		tmpl     *ipp.JobTemplate
+		max      time.Duration
+		expected string
+	}
+
+	tests := []testData{
+		{
+			// Printer clock is behind
+			skew: optional.New(-10 * time.Minute),
+			tmpl: hold,
+			expected: "printer clock is 10m0s behind local clock; " +
+				"job-hold-until-time may not work as expected",
+		},
+		{
+			// Printer clock is ahead
+			skew: optional.New(10 * time.Minute),
+			tmpl: retain,
+			expected: "printer clock is 10m0s ahead of local clock; " +
+				"job-retain-until-time may not work as expected",
+		},
+		{
+			// Within default threshold
+			skew: optional.New(4 * time.Minute),
+			tmpl: hold,
+		},
+		{
+			// Within explicit threshold
+			skew: optional.New(10 * time.Minute),
+			tmpl: hold,
+			max:  time.Hour,
+		},
+		{
+			// No time-based attributes
+			skew: optional.New(10 * time.Minute),
+			tmpl: &ipp.JobTemplate{},
+		},
+		{
+			// printer-current-time is missed
+			tmpl: hold,
+		},
+	}
+
+	for _, test := range tests {
+		pa := &ipp.PrinterAttributes{}
+		if test.skew != nil {
+			pa.PrinterCurrentTime = optional.New(
+				now.Add(*test.skew))
+		}
+
+		msg := jobClockSkewCheck(pa, test.tmpl, now, test.max)
+		if msg != test.expected {
+			t.Errorf("jobClockSkewCheck:\n"+
+				"expected: %q\n"+
+				"present:  %q", test.expected, msg)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer URI resolution
+
+package cups
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// ResolvePrinterURI converts the CUPS queue name into the printer-uri,
+// relative to the serverURL.
+//
+// The name may contain the instance suffix ("printer/instance").
+// Instances are the client-side concept, unknown to the CUPS server,
+// so the suffix is stripped from the URI and returned separately.
+// Characters, not allowed in the URI path (i.e., spaces), are
+// percent-encoded.
+//
+// The returned URI refers to the "/printers/" resource. CUPS accepts
+// it for classes as well; use [ResolveQueueURI], if the queue type
+// is known, to obtain the canonical "/classes/" URI for classes.
+func ResolvePrinterURI(serverURL *url.URL, name string) (
+	*url.URL, string, error) {
+	return ResolveQueueURI(serverURL, name, ipp.EnPrinterLocal)
+}
+
+// ResolveQueueURI is like [ResolvePrinterURI], but uses the queue
+// type, reported by the "printer-type" attribute, to choose between
+// the "/printers/" and "/classes/" resources.
+func ResolveQueueURI(serverURL *url.URL, name string,
+	ptype ipp.EnPrinterType) (*url.URL, string, error) {
+
+	printer, instance, hasInstance := strings.Cut(name, "/")
+
+	switch {
+	case printer == "":
+		return nil, "", fmt.Errorf("%q: missed printer name", name)
+	case hasInstance && instance == "":
+		return nil, "", fmt.Errorf("%q: missed instance name", name)
+	case strings.Contains(instance, "/"):
+		return nil, "", fmt.Errorf("%q: invalid instance name", name)
+	}
+
+	for _, c := range printer {
+		if c < 0x20 || c == 0x7f {
+			return nil, "", fmt.Errorf("%q: invalid printer name",
+				name)
+		}
+	}
+
+	resource := "/printers/"
+	if ptype&ipp.EnPrinterClass != 0 {
+		resource = "/classes/"
+	}
+
+	u := transport.URLClone(serverURL)
+	u.Path = resource + printer
+	u.RawPath = ""
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	return u, instance, nil
+}
@@ -0,0 +1,146 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer URI resolution test
+
+package cups
+
+import (
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// TestResolvePrinterURI tests ResolvePrinterURI and ResolveQueueURI
+func TestResolvePrinterURI(t *testing.T) {
+	type testData struct {
+		server   string            // Server URL
+		name     string            // Queue name
+		ptype    ipp.EnPrinterType // Queue type
+		uri      string            // Expected URI
+		instance string            // Expected instance
+		err      string            // Expected error
+	}
+
+	tests := []testData{
+		{
+			server: "ipp://localhost/",
+			name:   "Kyocera",
+			uri:    "ipp://localhost/printers/Kyocera",
+		},
+
+		{
+			server:   "ipp://localhost/",
+			name:     "Kyocera/duplex",
+			uri:      "ipp://localhost/printers/Kyocera",
+			instance: "duplex",
+		},
+
+		{
+			server: "http://localhost:631/admin?x=y",
+			name:   "Test Queue",
+			uri:    "http://localhost:631/printers/Test%20Queue",
+		},
+
+		{
+			server:   "ipp://localhost/",
+			name:     "Test Queue/draft mode",
+			uri:      "ipp://localhost/printers/Test%20Queue",
+			instance: "draft mode",
+		},
+
+		{
+			server: "ipp://localhost/",
+			name:   "office",
+			ptype:  ipp.EnPrinterClass | ipp.EnPrinterColor,
+			uri:    "ipp://localhost/classes/office",
+		},
+
+		{
+			server: "ipp://localhost/",
+			name:   "Kyocera",
+			ptype:  ipp.EnPrinterRemote | ipp.EnPrinterDuplex,
+			uri:    "ipp://localhost/printers/Kyocera",
+		},
+
+		{
+			server: "ipp://localhost/",
+			name:   "",
+			err:    `"": missed printer name`,
+		},
+
+		{
+			server: "ipp://localhost/",
+			name:   "/duplex",
+			err:    `"/duplex": missed printer name`,
+		},
+
+		{
+			server: "ipp://localhost/",
+			name:   "Kyocera/",
+			err:    `"Kyocera/": missed instance name`,
+		},
+
+		{
+			server: "ipp://localhost/",
+			name:   "Kyocera/a/b",
+			err:    `"Kyocera/a/b": invalid instance name`,
+		},
+
+		{
+			server: "ipp://localhost/",
+			name:   "Kyo\ncera",
+			err:    `"Kyo\ncera": invalid printer name`,
+		},
+	}
+
+	for _, test := range tests {
+		server := transport.MustParseURL(test.server)
+		u, instance, err := ResolveQueueURI(server, test.name, test.ptype)
+
+		if err != nil {
+			if err.Error() != test.err {
+				t.Errorf("%q: error mismatch:\n"+
+					"expected: %s\npresent:  %s",
+					test.name, test.err, err)
+			}
+			continue
+		}
+
+		if test.err != "" {
+			t.Errorf("%q: error not detected, expected: %s",
+				test.name, test.err)
+			continue
+		}
+
+		if u.String() != test.uri {
+			t.Errorf("%q: URI mismatch:\nexpected: %s\npresent:  %s",
+				test.name, test.uri, u)
+		}
+
+		if instance != test.instance {
+			t.Errorf("%q: instance: expected %q, present %q",
+				test.name, test.instance, instance)
+		}
+
+		// ResolvePrinterURI always uses /printers/
+		if test.ptype&ipp.EnPrinterClass == 0 {
+			u2, _, _ := ResolvePrinterURI(server, test.name)
+			if u2.String() != u.String() {
+				t.Errorf("%q: ResolvePrinterURI: %s",
+					test.name, u2)
+			}
+		}
+	}
+
+	// serverURL must not be modified
+	server := transport.MustParseURL("ipp://localhost/")
+	ResolvePrinterURI(server, "Kyocera")
+	if server.String() != "ipp://localhost/" {
+		t.Errorf("serverURL modified: %s", server)
+	}
+}
@@ -0,0 +1,274 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Device URI probing
+
+package cups
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// Probe parameters:
+const (
+	// ProbeTimeout limits the total time of ProbeDeviceURI,
+	// if Context has no deadline.
+	ProbeTimeout = 10 * time.Second
+
+	// ProbePJLTimeout limits time of waiting for response to the
+	// PJL INFO ID query. Many devices don't answer PJL queries
+	// at all, so this timeout is short.
+	ProbePJLTimeout = 2 * time.Second
+)
+
+// Default ports of the legacy backends
+const (
+	probeSocketPort = "9100" // AppSocket (JetDirect)
+	probeLPDPort    = "515"  // LPD
+)
+
+// probePJLInfoID is the PJL INFO ID query, wrapped into the
+// Universal Exit Language (UEL) commands.
+const probePJLInfoID = "\x1b%-12345X@PJL INFO ID\r\n\x1b%-12345X"
+
+// ProbeResult contains the result of [ProbeDeviceURI].
+type ProbeResult struct {
+	Reachable bool          // Device is reachable
+	MakeModel string        // Device make and model, "" if unknown
+	Info      string        // Status text, returned by device, if any
+	Latency   time.Duration // Time to connect (or to answer, for IPP)
+}
+
+// ProbeDeviceURI probes the CUPS device URI.
+//
+// Supported schemes are:
+//   - ipp and ipps: Get-Printer-Attributes request is sent
+//   - socket: TCP connection is established, and then the
+//     PJL INFO ID query is sent, to obtain the device model
+//   - lpd: the RFC 1179 short queue state is requested
+//
+// Only the failure to reach the device is considered an error.
+// Devices that don't respond to the PJL or LPD queries are
+// reported as reachable, with the unknown model.
+func ProbeDeviceURI(ctx context.Context, uri string) (ProbeResult, error) {
+	u, err := probeParseURI(uri)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ProbeTimeout)
+		defer cancel()
+	}
+
+	switch u.Scheme {
+	case "ipp", "ipps":
+		return probeIPP(ctx, u)
+	case "socket":
+		return probeSocket(ctx, u)
+	}
+
+	return probeLPD(ctx, u)
+}
+
+// ValidateDeviceURI validates the device URI, supported
+// by [ProbeDeviceURI].
+func ValidateDeviceURI(uri string) error {
+	_, err := probeParseURI(uri)
+	return err
+}
+
+// probeParseURI parses and validates the device URI.
+// Returned URL has the lower-case scheme.
+func probeParseURI(uri string) (*url.URL, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	switch u.Scheme {
+	case "ipp", "ipps", "socket":
+	case "lpd":
+		if strings.Trim(u.Path, "/") == "" {
+			return nil, fmt.Errorf("%q: missed LPD queue name", uri)
+		}
+	default:
+		return nil, fmt.Errorf("%q: unsupported scheme %q",
+			uri, u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("%q: missed host", uri)
+	}
+
+	return u, nil
+}
+
+// probeIPP probes the ipp:// and ipps:// device URIs.
+func probeIPP(ctx context.Context, u *url.URL) (ProbeResult, error) {
+	res, _, err := probeIPPAttrs(ctx, u, []string{"printer-make-and-model"})
+	return res, err
+}
+
+// probeIPPAttrs probes the ipp:// and ipps:// device URIs,
+// requesting the specified printer attributes. The printer-make-and-model
+// attribute must be included into the list, to fill the ProbeResult.
+//
+// It returns the received printer attributes, which may be nil.
+func probeIPPAttrs(ctx context.Context, u *url.URL, attrs []string) (
+	ProbeResult, *ipp.PrinterAttributes, error) {
+
+	clnt := ipp.NewClient(u, transport.PoolFor(u).Transport())
+	clnt.SetDecoderOptions(&ipp.DecoderOptions{KeepTrying: true})
+
+	start := time.Now()
+	prn, err := clnt.GetPrinterAttributes(ctx, attrs, "")
+
+	if err != nil {
+		return ProbeResult{}, nil, err
+	}
+
+	res := ProbeResult{
+		Reachable: true,
+		Latency:   time.Since(start),
+	}
+
+	if prn != nil {
+		res.MakeModel = optional.Get(prn.PrinterMakeAndModel)
+	}
+
+	return res, prn, nil
+}
+
+// probeSocket probes the socket:// (AppSocket, JetDirect) device URIs.
+func probeSocket(ctx context.Context, u *url.URL) (ProbeResult, error) {
+	conn, res, err := probeDial(ctx, u, probeSocketPort)
+	if err != nil {
+		return res, err
+	}
+
+	defer conn.Close()
+
+	// Query the device model. Errors are not fatal here.
+	probeSetDeadline(ctx, conn, ProbePJLTimeout)
+
+	_, err = io.WriteString(conn, probePJLInfoID)
+	if err == nil {
+		var reply []byte
+		reply, err = bufio.NewReader(conn).ReadBytes('\f')
+		if err == nil {
+			res.MakeModel = probeParsePJLInfoID(reply)
+		}
+	}
+
+	return res, nil
+}
+
+// probeParsePJLInfoID parses response to the PJL INFO ID query.
+//
+// The response looks as follows:
+//
+//	@PJL INFO ID<CR><LF>
+//	"HP LaserJet 4250"<CR><LF>
+//	<FF>
+func probeParsePJLInfoID(reply []byte) string {
+	lines := bytes.Split(reply, []byte("\n"))
+	echo := false
+
+	for _, line := range lines {
+		s := strings.TrimSpace(string(line))
+		switch {
+		case strings.HasPrefix(s, "@PJL INFO ID"):
+			echo = true
+		case echo && s != "":
+			return strings.Trim(s, `"`)
+		}
+	}
+
+	return ""
+}
+
+// probeLPD probes the lpd:// device URIs.
+func probeLPD(ctx context.Context, u *url.URL) (ProbeResult, error) {
+	queue := strings.Trim(u.Path, "/")
+
+	conn, res, err := probeDial(ctx, u, probeLPDPort)
+	if err != nil {
+		return res, err
+	}
+
+	defer conn.Close()
+
+	// Request the short queue state.
+	probeSetDeadline(ctx, conn, ProbeTimeout)
+
+	_, err = fmt.Fprintf(conn, "\x03%s\n", queue)
+	if err == nil {
+		var line string
+		line, err = bufio.NewReader(conn).ReadString('\n')
+		if err == nil || (err == io.EOF && line != "") {
+			res.Info = strings.TrimSpace(line)
+		}
+	}
+
+	return res, nil
+}
+
+// probeDial connects to the device.
+//
+// The defport is the default port for the URL scheme.
+// On success, ProbeResult is filled with reachability and latency.
+func probeDial(ctx context.Context, u *url.URL, defport string) (
+	net.Conn, ProbeResult, error) {
+
+	port := u.Port()
+	if port == "" {
+		port = defport
+	}
+
+	addr := net.JoinHostPort(u.Hostname(), port)
+
+	var dialer net.Dialer
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, ProbeResult{}, err
+	}
+
+	res := ProbeResult{
+		Reachable: true,
+		Latency:   time.Since(start),
+	}
+
+	return conn, res, nil
+}
+
+// probeSetDeadline sets the connection deadline, according to the
+// timeout, limited by the Context deadline.
+func probeSetDeadline(ctx context.Context, conn net.Conn,
+	timeout time.Duration) {
+
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	conn.SetDeadline(deadline)
+}
@@ -0,0 +1,155 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Device URI probing test
+
+package cups
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testProbeServer runs the fake TCP server, that handles
+// each connection with the provided function.
+//
+// It returns the server address; the server is stopped when
+// the test ends.
+func testProbeServer(t *testing.T, handler func(net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				handler(conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+// TestProbeDeviceURISocket tests probing of socket:// URIs
+func TestProbeDeviceURISocket(t *testing.T) {
+	// Fake PJL responder
+	pjl := testProbeServer(t, func(conn net.Conn) {
+		rd := bufio.NewReader(conn)
+		line, _ := rd.ReadString('\n')
+		if strings.HasSuffix(line, "@PJL INFO ID\r\n") {
+			io.WriteString(conn, "@PJL INFO ID\r\n"+
+				"\"HP LaserJet 4250\"\r\n\f")
+		}
+	})
+
+	res, err := ProbeDeviceURI(context.Background(), "socket://"+pjl)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !res.Reachable || res.MakeModel != "HP LaserJet 4250" {
+		t.Errorf("PJL responder: %+v", res)
+	}
+
+	// Silent device: reachable, but model is unknown
+	silent := testProbeServer(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		200*time.Millisecond)
+	defer cancel()
+
+	res, err = ProbeDeviceURI(ctx, "socket://"+silent)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !res.Reachable || res.MakeModel != "" {
+		t.Errorf("silent device: %+v", res)
+	}
+}
+
+// TestProbeDeviceURILPD tests probing of lpd:// URIs
+func TestProbeDeviceURILPD(t *testing.T) {
+	// Fake LPD daemon
+	commands := make(chan string, 1)
+	lpd := testProbeServer(t, func(conn net.Conn) {
+		command, _ := bufio.NewReader(conn).ReadString('\n')
+		commands <- command
+		io.WriteString(conn, "lp is ready\nno entries\n")
+	})
+
+	res, err := ProbeDeviceURI(context.Background(), "lpd://"+lpd+"/lp")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if command := <-commands; command != "\x03lp\n" {
+		t.Errorf("LPD command: %q", command)
+	}
+
+	if !res.Reachable || res.Info != "lp is ready" {
+		t.Errorf("LPD daemon: %+v", res)
+	}
+}
+
+// TestProbeDeviceURIUnreachable tests probing of unreachable device
+func TestProbeDeviceURIUnreachable(t *testing.T) {
+	// Obtain the surely closed port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	addr := l.Addr().String()
+	l.Close()
+
+	res, err := ProbeDeviceURI(context.Background(), "socket://"+addr)
+	if err == nil || res.Reachable {
+		t.Errorf("unreachable device: %+v, %v", res, err)
+	}
+}
+
+// TestValidateDeviceURI tests ValidateDeviceURI
+func TestValidateDeviceURI(t *testing.T) {
+	tests := []struct {
+		uri string
+		ok  bool
+	}{
+		{"ipp://192.168.0.5/ipp/print", true},
+		{"IPPS://printer.local/ipp/print", true},
+		{"socket://192.168.0.5", true},
+		{"socket://192.168.0.5:9101", true},
+		{"lpd://192.168.0.5/queue", true},
+		{"lpd://192.168.0.5/", false},
+		{"socket:///", false},
+		{"usb://HP/LaserJet", false},
+		{"%", false},
+	}
+
+	for _, test := range tests {
+		err := ValidateDeviceURI(test.uri)
+		if (err == nil) != test.ok {
+			t.Errorf("%q: unexpected result: %v", test.uri, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer search by organization and location
+
+package cups
+
+import (
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+)
+
+// SearchAttrs lists attributes, used by the [PrinterSearch].
+// They need to be requested from the server in order search
+// to work.
+var SearchAttrs = []string{
+	"printer-geo-location",
+	"printer-location",
+	"printer-organization",
+	"printer-organizational-unit",
+}
+
+// PrinterSearch specifies the client-side search criteria for
+// printers, returned by the [Client.CUPSGetPrinters].
+//
+// Zero criteria match all printers. If multiple criteria are
+// specified, all of them must match.
+type PrinterSearch struct {
+	// Organization, if not empty, matches printers with the
+	// equal (case-insensitive) printer-organization or
+	// printer-organizational-unit value.
+	Organization string
+
+	// Near, if not nil, matches printers with the known
+	// printer-geo-location within the Radius, in meters,
+	// from the Near point. Boundary is inclusive.
+	//
+	// Printers with unknown or invalid location never match.
+	// Uncertainty of the printer location is not taken
+	// into account.
+	Near   *ipp.GeoLocation
+	Radius float64
+}
+
+// Match reports whether printer matches the search criteria.
+func (search PrinterSearch) Match(prn *ipp.PrinterAttributes) bool {
+	if search.Organization != "" && !search.matchOrganization(prn) {
+		return false
+	}
+
+	if search.Near != nil {
+		geo, ok := prn.GeoLocation()
+		if !ok || search.Near.Distance(geo) > search.Radius {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchOrganization matches printer against the Organization criteria.
+func (search PrinterSearch) matchOrganization(
+	prn *ipp.PrinterAttributes) bool {
+
+	for _, list := range [][]string{
+		prn.PrinterOrganization, prn.PrinterOrganizationalUnit} {
+		for _, org := range list {
+			if strings.EqualFold(org, search.Organization) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Filter returns printers that match the search criteria,
+// preserving their order.
+func (search PrinterSearch) Filter(
+	printers []*ipp.PrinterAttributes) []*ipp.PrinterAttributes {
+
+	var matched []*ipp.PrinterAttributes
+	for _, prn := range printers {
+		if search.Match(prn) {
+			matched = append(matched, prn)
+		}
+	}
+
+	return matched
+}
@@ -0,0 +1,115 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer search tests
+
+package cups
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// TestPrinterSearch tests PrinterSearch
+func TestPrinterSearch(t *testing.T) {
+	newPrinter := func(name, geo string, org, unit []string) *ipp.PrinterAttributes {
+		prn := &ipp.PrinterAttributes{}
+		prn.PrinterName = optional.New(name)
+		if geo != "" {
+			prn.PrinterGeoLocation = optional.New(geo)
+		}
+		prn.PrinterOrganization = org
+		prn.PrinterOrganizationalUnit = unit
+		return prn
+	}
+
+	// Printers along the equator, 1 degree (~111 km) apart
+	printers := []*ipp.PrinterAttributes{
+		newPrinter("p0", "geo:0,0", []string{"ACME"}, nil),
+		newPrinter("p1", "geo:0,1;u=10", nil, []string{"Lab"}),
+		newPrinter("p2", "geo:0,2,100", []string{"Other"}, nil),
+		newPrinter("unknown", "unknown", []string{"acme"}, nil),
+		newPrinter("missed", "", nil, nil),
+		newPrinter("invalid", "geo:x,y", nil, nil),
+	}
+
+	deg := ipp.GeoEarthRadius * math.Pi / 180
+	center := &ipp.GeoLocation{}
+
+	type testData struct {
+		name     string
+		search   PrinterSearch
+		expected []string
+	}
+
+	tests := []testData{
+		{
+			name: "no criteria",
+			expected: []string{"p0", "p1", "p2", "unknown",
+				"missed", "invalid"},
+		},
+
+		{
+			name:     "organization",
+			search:   PrinterSearch{Organization: "acme"},
+			expected: []string{"p0", "unknown"},
+		},
+
+		{
+			name:     "organizational unit",
+			search:   PrinterSearch{Organization: "LAB"},
+			expected: []string{"p1"},
+		},
+
+		{
+			name:     "radius 0",
+			search:   PrinterSearch{Near: center},
+			expected: []string{"p0"},
+		},
+
+		{
+			name: "radius just below 1 degree",
+			search: PrinterSearch{Near: center,
+				Radius: deg * (1 - 1e-9)},
+			expected: []string{"p0"},
+		},
+
+		{
+			name:     "radius exactly 1 degree",
+			search:   PrinterSearch{Near: center, Radius: deg},
+			expected: []string{"p0", "p1"},
+		},
+
+		{
+			name:     "radius 2 degrees",
+			search:   PrinterSearch{Near: center, Radius: 2 * deg},
+			expected: []string{"p0", "p1", "p2"},
+		},
+
+		{
+			name: "organization and radius",
+			search: PrinterSearch{Organization: "acme",
+				Near: center, Radius: 2 * deg},
+			expected: []string{"p0"},
+		},
+	}
+
+	for _, test := range tests {
+		var names []string
+		for _, prn := range test.search.Filter(printers) {
+			names = append(names, optional.Get(prn.PrinterName))
+		}
+
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%s: expected %q, present %q",
+				test.name, test.expected, names)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// TLS certificate inspection
+
+package cups
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TLSExpiryWarning is the threshold of the certificate expiration,
+// below which [Diagnose] warns about the expiring certificate.
+const TLSExpiryWarning = 30 * 24 * time.Hour
+
+// Default ports of the TLS-protected schemes
+const (
+	tlsIPPSPort  = "631"
+	tlsHTTPSPort = "443"
+)
+
+// TLSInfo contains the device TLS certificate details,
+// returned by the [InspectTLS].
+type TLSInfo struct {
+	Encrypted          bool      // false for plain (ipp://) URIs
+	Version            string    // TLS version (i.e., "TLS 1.3")
+	Subject            string    // Certificate subject
+	Issuer             string    // Certificate issuer
+	SANs               []string  // Subject alternative names
+	NotBefore          time.Time // Validity period start
+	NotAfter           time.Time // Validity period end
+	SignatureAlgorithm string    // Certificate signature algorithm
+	SelfSigned         bool      // Certificate is self-signed
+	Verified           bool      // Chain verified against system roots
+	VerifyErr          error     // Verification error, if not Verified
+}
+
+// InspectTLS performs the TLS handshake with the device and
+// returns details of its certificate.
+//
+// Only the handshake is performed, no IPP or HTTP requests are
+// sent. The certificate is returned even if its chain cannot be
+// verified against the system roots; in this case Verified is
+// false and VerifyErr explains the reason.
+//
+// Supported schemes are ipps and https. For other schemes
+// (ipp, http, socket and so on) the TLSInfo with Encrypted
+// set to false is returned.
+func InspectTLS(ctx context.Context, deviceURI string) (TLSInfo, error) {
+	u, err := url.Parse(deviceURI)
+	if err != nil {
+		return TLSInfo{}, err
+	}
+
+	var port string
+	switch strings.ToLower(u.Scheme) {
+	case "ipps":
+		port = tlsIPPSPort
+	case "https":
+		port = tlsHTTPSPort
+	default:
+		return TLSInfo{}, nil
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return TLSInfo{}, fmt.Errorf("%q: missed host", deviceURI)
+	}
+
+	if u.Port() != "" {
+		port = u.Port()
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ProbeTimeout)
+		defer cancel()
+	}
+
+	// Perform the handshake. Verification is performed
+	// separately, so certificate details are available even
+	// for the certificates that cannot be verified.
+	dialer := &tls.Dialer{
+		Config: &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+		},
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return TLSInfo{}, err
+	}
+
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return TLSInfo{}, errors.New("TLS: no peer certificate")
+	}
+
+	info := tlsInfoFromChain(host, state.PeerCertificates)
+	info.Version = tls.VersionName(state.Version)
+
+	return info, nil
+}
+
+// tlsInfoFromChain makes TLSInfo from the peer certificate chain.
+// The leaf certificate comes first.
+func tlsInfoFromChain(host string, chain []*x509.Certificate) TLSInfo {
+	cert := chain[0]
+
+	info := TLSInfo{
+		Encrypted:          true,
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+	}
+
+	// Note, CheckSignatureFrom can't be used here, because
+	// self-signed device certificates often are not marked as CA.
+	if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		err := cert.CheckSignature(cert.SignatureAlgorithm,
+			cert.RawTBSCertificate, cert.Signature)
+		info.SelfSigned = err == nil
+	}
+
+	info.SANs = append(info.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+
+	opts := x509.VerifyOptions{
+		DNSName:       host,
+		Intermediates: x509.NewCertPool(),
+	}
+
+	for _, c := range chain[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+
+	_, info.VerifyErr = cert.Verify(opts)
+	info.Verified = info.VerifyErr == nil
+
+	return info
+}
+
+// Expired reports whether the certificate is expired at the
+// specified time.
+func (info TLSInfo) Expired(now time.Time) bool {
+	return info.Encrypted && now.After(info.NotAfter)
+}
+
+// Expiring reports whether the certificate is not yet expired
+// at the specified time, but will expire within the threshold.
+func (info TLSInfo) Expiring(now time.Time, threshold time.Duration) bool {
+	return info.Encrypted && !info.Expired(now) &&
+		info.NotAfter.Sub(now) < threshold
+}
+
+// String returns the short summary of the TLSInfo.
+// For the plain connections it returns "none".
+func (info TLSInfo) String() string {
+	if !info.Encrypted {
+		return "none"
+	}
+
+	s := fmt.Sprintf("%s, expires %s", info.Version,
+		info.NotAfter.UTC().Format(time.DateOnly))
+
+	switch {
+	case info.Verified:
+		s += ", verified"
+	case info.SelfSigned:
+		s += ", self-signed"
+	default:
+		s += ", not verified"
+	}
+
+	return s
+}
@@ -0,0 +1,236 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// TLS certificate inspection test
+
+package cups
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+)
+
+// testTLSCert generates the self-signed certificate, valid
+// until notAfter.
+func testTLSCert(t *testing.T, notAfter time.Time) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test Printer"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"printer.test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl,
+		&key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// testTLSServer starts the TLS server with the specified certificate.
+func testTLSServer(t *testing.T, cert tls.Certificate,
+	handler http.Handler) *httptest.Server {
+
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// TestInspectTLS tests InspectTLS
+func TestInspectTLS(t *testing.T) {
+	notAfter := time.Now().Add(10 * 24 * time.Hour).Truncate(time.Second)
+	srv := testTLSServer(t, testTLSCert(t, notAfter), http.NotFoundHandler())
+	addr := strings.TrimPrefix(srv.URL, "https://")
+
+	info, err := InspectTLS(context.Background(),
+		"ipps://"+addr+"/ipp/print")
+	if err != nil {
+		t.Fatalf("InspectTLS: %s", err)
+	}
+
+	if !info.Encrypted {
+		t.Errorf("Encrypted: expected true")
+	}
+
+	if info.Subject != "CN=Test Printer" {
+		t.Errorf("Subject: %q", info.Subject)
+	}
+
+	if info.Issuer != "CN=Test Printer" {
+		t.Errorf("Issuer: %q", info.Issuer)
+	}
+
+	expSANs := []string{"printer.test", "127.0.0.1"}
+	if !reflect.DeepEqual(info.SANs, expSANs) {
+		t.Errorf("SANs: expected %q, present %q", expSANs, info.SANs)
+	}
+
+	if !info.NotAfter.Equal(notAfter) {
+		t.Errorf("NotAfter: expected %s, present %s",
+			notAfter, info.NotAfter)
+	}
+
+	if info.SignatureAlgorithm != "ECDSA-SHA256" {
+		t.Errorf("SignatureAlgorithm: %q", info.SignatureAlgorithm)
+	}
+
+	if !info.SelfSigned {
+		t.Errorf("SelfSigned: expected true")
+	}
+
+	if info.Verified || info.VerifyErr == nil {
+		t.Errorf("Verified: self-signed certificate must not verify")
+	}
+
+	// Plain URIs
+	for _, uri := range []string{"ipp://127.0.0.1/ipp/print",
+		"socket://127.0.0.1"} {
+
+		info, err = InspectTLS(context.Background(), uri)
+		if err != nil {
+			t.Errorf("InspectTLS(%q): %s", uri, err)
+		} else if s := info.String(); s != "none" {
+			t.Errorf("InspectTLS(%q): expected none, present %q",
+				uri, s)
+		}
+	}
+}
+
+// TestTLSInfoExpiry tests TLSInfo.Expired and TLSInfo.Expiring
+func TestTLSInfoExpiry(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	type testData struct {
+		notAfter time.Time // Certificate expiration
+		expired  bool      // Expected Expired
+		expiring bool      // Expected Expiring
+	}
+
+	tests := []testData{
+		{notAfter: now.Add(-day), expired: true},
+		{notAfter: now.Add(day), expiring: true},
+		{notAfter: now.Add(30*day - time.Second), expiring: true},
+		{notAfter: now.Add(30 * day)},
+		{notAfter: now.Add(365 * day)},
+	}
+
+	for _, test := range tests {
+		info := TLSInfo{Encrypted: true, NotAfter: test.notAfter}
+
+		expired := info.Expired(now)
+		expiring := info.Expiring(now, TLSExpiryWarning)
+
+		if expired != test.expired || expiring != test.expiring {
+			t.Errorf("%s: expected expired=%v expiring=%v, "+
+				"present expired=%v expiring=%v",
+				test.notAfter.Sub(now),
+				test.expired, test.expiring, expired, expiring)
+		}
+	}
+
+	// Plain connection never expires
+	if (TLSInfo{}).Expired(now) {
+		t.Errorf("plain connection reported as expired")
+	}
+}
+
+// TestDiagnoseTLSExpiry tests Diagnose with the expiring and
+// expired device certificates.
+func TestDiagnoseTLSExpiry(t *testing.T) {
+	day := 24 * time.Hour
+
+	type testData struct {
+		name     string        // Test name
+		expires  time.Duration // Certificate expiration, from now
+		codes    []string      // Expected findings
+		severity Severity      // Expected severity
+	}
+
+	tests := []testData{
+		{
+			name:     "valid",
+			expires:  365 * day,
+			severity: SeverityOK,
+		},
+		{
+			name:     "expiring",
+			expires:  10 * day,
+			codes:    []string{FindingTLSExpiring},
+			severity: SeverityWarning,
+		},
+		{
+			name:     "expired",
+			expires:  -day,
+			codes:    []string{FindingTLSExpired},
+			severity: SeverityError,
+		},
+	}
+
+	for _, test := range tests {
+		env := newTestDiagnoseEnv(t)
+		cert := testTLSCert(t, time.Now().Add(test.expires))
+		srv := testTLSServer(t, cert,
+			ipp.NewPrinter(env.device, ipp.PrinterOptions{}))
+
+		env.queue.DeviceURI = "ipps://" +
+			strings.TrimPrefix(srv.URL, "https://") + "/ipp/print"
+
+		r, err := Diagnose(context.Background(), env.clnt, "Test")
+		if err != nil {
+			t.Errorf("%s: Diagnose: %s", test.name, err)
+			continue
+		}
+
+		var codes []string
+		for _, f := range r.Findings {
+			codes = append(codes, f.Code)
+		}
+
+		if !reflect.DeepEqual(codes, test.codes) {
+			t.Errorf("%s: findings: expected %q, present %q",
+				test.name, test.codes, codes)
+		}
+
+		if sev := r.Severity(); sev != test.severity {
+			t.Errorf("%s: severity: expected %s, present %s",
+				test.name, test.severity, sev)
+		}
+
+		if !r.TLS.Encrypted || r.TLSErr != nil {
+			t.Errorf("%s: TLS: %s, %v", test.name, r.TLS, r.TLSErr)
+		}
+	}
+}
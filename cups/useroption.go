@@ -0,0 +1,194 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// User-friendly job options
+
+package cups
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+)
+
+// ErrUserOptionUnknown is returned by [ResolveUserOption], if option
+// name doesn't belong to its vocabulary. In this case, caller may
+// fall back to the raw IPP attribute assignment.
+var ErrUserOptionUnknown = errors.New("unknown user option")
+
+// userOption defines the user-friendly option.
+type userOption struct {
+	attr    string            // IPP attribute
+	values  []userOptionValue // Possible values
+	convert func(string) any  // IPP value conversion
+}
+
+// userOptionValue defines the user-friendly option value.
+//
+// The ipp field lists IPP values this value is mapped to, in order
+// of preference; the first one supported by printer is used. This
+// allows to cover printer-specific aliases, like "process-monochrome",
+// which some printers offer instead of the standard "monochrome".
+type userOptionValue struct {
+	names []string // User names, the first one is the canonical
+	ipp   []string // IPP values, in order of preference
+}
+
+// userOptions contains the vocabulary of user-friendly options.
+//
+// Values are listed in the "natural" order, so the closest
+// alternative of any value is its nearest neighbor.
+var userOptions = map[string]*userOption{
+	"quality": {
+		attr: "print-quality",
+		values: []userOptionValue{
+			{[]string{"draft", "low"}, []string{"3"}},
+			{[]string{"normal"}, []string{"4"}},
+			{[]string{"best", "high"}, []string{"5"}},
+		},
+		convert: func(s string) any {
+			q, _ := strconv.Atoi(s)
+			return q
+		},
+	},
+
+	"duplex": {
+		attr: "sides",
+		values: []userOptionValue{
+			{[]string{"off", "none", "simplex"},
+				[]string{string(ipp.KwSidesOneSided)}},
+			{[]string{"long", "on", "long-edge"},
+				[]string{string(ipp.KwSidesTwoSidedLongEdge)}},
+			{[]string{"short", "short-edge"},
+				[]string{string(ipp.KwSidesTwoSidedShortEdge)}},
+		},
+		convert: func(s string) any {
+			return ipp.KwSides(s)
+		},
+	},
+
+	"color": {
+		attr: "print-color-mode",
+		values: []userOptionValue{
+			{[]string{"auto"}, []string{"auto"}},
+			{[]string{"color", "colour"}, []string{"color"}},
+			{[]string{"mono", "monochrome", "gray", "grayscale"},
+				[]string{"monochrome", "auto-monochrome",
+					"process-monochrome"}},
+		},
+		convert: func(s string) any {
+			return s
+		},
+	},
+}
+
+// ResolveUserOption translates the user-friendly job option
+// into the IPP Job Template attribute.
+//
+// The following options are understood:
+//
+//	quality=draft|normal|best -> print-quality
+//	duplex=off|long|short     -> sides
+//	color=auto|color|mono     -> print-color-mode
+//
+// It returns the IPP attribute name and value. Value type matches
+// the type of the corresponding [ipp.JobTemplate] field: int for
+// print-quality, [ipp.KwSides] for sides and string for
+// print-color-mode.
+//
+// If caps is not nil, the value is validated against the printer's
+// "xxx-supported" attributes. If the requested value is not offered
+// by printer, returned error suggests the closest supported
+// alternative. Empty "xxx-supported" list means that printer doesn't
+// report its capabilities, and the value is not validated.
+//
+// If option name is not known, [ErrUserOptionUnknown] is returned.
+func ResolveUserOption(name, value string,
+	caps *ipp.JobTemplateCapabilities) (
+	attrName string, attrValue any, err error) {
+
+	opt := userOptions[strings.ToLower(name)]
+	if opt == nil {
+		return "", nil, fmt.Errorf("%s: %w", name, ErrUserOptionUnknown)
+	}
+
+	// Lookup the value
+	idx := opt.lookup(value)
+	if idx < 0 {
+		return "", nil, fmt.Errorf("%s=%s: invalid value, use one of: %s",
+			name, value, strings.Join(opt.names(), ", "))
+	}
+
+	// Check against printer capabilities
+	var spec ipp.SupportedSpec
+	found := false
+	if caps != nil {
+		pa := &ipp.PrinterAttributes{JobTemplateCapabilities: *caps}
+		spec, found = pa.SupportedValues()[opt.attr]
+	}
+
+	if !found {
+		return opt.attr, opt.convert(opt.values[idx].ipp[0]), nil
+	}
+
+	if v := opt.match(idx, spec); v != "" {
+		return opt.attr, opt.convert(v), nil
+	}
+
+	// Suggest the closest alternative
+	for dist := 1; dist < len(opt.values); dist++ {
+		for _, i := range []int{idx + dist, idx - dist} {
+			if i >= 0 && i < len(opt.values) &&
+				opt.match(i, spec) != "" {
+				return "", nil, fmt.Errorf(
+					"%s=%s: not supported by printer; "+
+						"closest supported: %s",
+					name, value, opt.values[i].names[0])
+			}
+		}
+	}
+
+	return "", nil, fmt.Errorf("%s=%s: not supported by printer",
+		name, value)
+}
+
+// lookup returns index of the value, or -1 if value is not found
+func (opt *userOption) lookup(value string) int {
+	value = strings.ToLower(value)
+	for i, v := range opt.values {
+		for _, name := range v.names {
+			if name == value {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// match returns the first IPP value of the idx-th option value
+// which is supported by printer, or "" if none supported.
+func (opt *userOption) match(idx int, spec ipp.SupportedSpec) string {
+	for _, v := range opt.values[idx].ipp {
+		if ok, _ := spec.Supports(opt.convert(v)); ok {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// names returns canonical names of the option values.
+func (opt *userOption) names() []string {
+	names := make([]string, len(opt.values))
+	for i, v := range opt.values {
+		names[i] = v.names[0]
+	}
+	return names
+}
@@ -0,0 +1,163 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// CUPS Client and Server
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// User-friendly job options test
+
+package cups
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+)
+
+// TestResolveUserOption tests ResolveUserOption
+func TestResolveUserOption(t *testing.T) {
+	// Full-featured color duplex printer
+	full := &ipp.JobTemplateCapabilities{
+		PrintQualitySupported: []int{3, 4, 5},
+		SidesSupported: []ipp.KwSides{
+			ipp.KwSidesOneSided,
+			ipp.KwSidesTwoSidedLongEdge,
+			ipp.KwSidesTwoSidedShortEdge,
+		},
+		PrintColorModeSupported: []string{
+			"auto", "color", "monochrome",
+		},
+	}
+
+	// Monochrome printer without duplex and with vendor-specific
+	// color mode and limited quality
+	simplex := &ipp.JobTemplateCapabilities{
+		PrintQualitySupported: []int{4},
+		SidesSupported: []ipp.KwSides{
+			ipp.KwSidesOneSided,
+		},
+		PrintColorModeSupported: []string{
+			"process-monochrome",
+		},
+	}
+
+	// Printer with long-edge duplex only
+	longEdge := &ipp.JobTemplateCapabilities{
+		PrintQualitySupported: []int{3, 4},
+		SidesSupported: []ipp.KwSides{
+			ipp.KwSidesOneSided,
+			ipp.KwSidesTwoSidedLongEdge,
+		},
+		PrintColorModeSupported: []string{
+			"auto", "auto-monochrome", "color",
+		},
+	}
+
+	// Printer that doesn't report its capabilities
+	unknown := &ipp.JobTemplateCapabilities{}
+
+	tests := []struct {
+		name, value string
+		caps        *ipp.JobTemplateCapabilities
+		attrName    string
+		attrValue   any
+		err         string
+	}{
+		// Full-featured printer
+		{"quality", "draft", full, "print-quality", 3, ""},
+		{"quality", "normal", full, "print-quality", 4, ""},
+		{"quality", "best", full, "print-quality", 5, ""},
+		{"duplex", "off", full, "sides", ipp.KwSidesOneSided, ""},
+		{"duplex", "long", full, "sides", ipp.KwSidesTwoSidedLongEdge, ""},
+		{"duplex", "short", full, "sides", ipp.KwSidesTwoSidedShortEdge, ""},
+		{"color", "auto", full, "print-color-mode", "auto", ""},
+		{"color", "color", full, "print-color-mode", "color", ""},
+		{"color", "mono", full, "print-color-mode", "monochrome", ""},
+
+		// Case and aliases
+		{"Duplex", "ON", full, "sides", ipp.KwSidesTwoSidedLongEdge, ""},
+		{"color", "grayscale", full, "print-color-mode", "monochrome", ""},
+		{"quality", "high", full, "print-quality", 5, ""},
+
+		// Printer without duplex
+		{"duplex", "off", simplex, "sides", ipp.KwSidesOneSided, ""},
+		{"duplex", "long", simplex, "", nil,
+			"duplex=long: not supported by printer; " +
+				"closest supported: off"},
+		{"duplex", "short", simplex, "", nil,
+			"duplex=short: not supported by printer; " +
+				"closest supported: off"},
+		{"quality", "best", simplex, "", nil,
+			"quality=best: not supported by printer; " +
+				"closest supported: normal"},
+		{"quality", "draft", simplex, "", nil,
+			"quality=draft: not supported by printer; " +
+				"closest supported: normal"},
+		{"color", "mono", simplex,
+			"print-color-mode", "process-monochrome", ""},
+		{"color", "color", simplex, "", nil,
+			"color=color: not supported by printer; " +
+				"closest supported: mono"},
+		{"color", "auto", simplex, "", nil,
+			"color=auto: not supported by printer; " +
+				"closest supported: mono"},
+
+		// Printer with long-edge duplex only
+		{"duplex", "short", longEdge, "", nil,
+			"duplex=short: not supported by printer; " +
+				"closest supported: long"},
+		{"quality", "best", longEdge, "", nil,
+			"quality=best: not supported by printer; " +
+				"closest supported: normal"},
+		{"color", "mono", longEdge,
+			"print-color-mode", "auto-monochrome", ""},
+
+		// Capabilities not reported or not known
+		{"duplex", "short", unknown,
+			"sides", ipp.KwSidesTwoSidedShortEdge, ""},
+		{"color", "mono", unknown, "print-color-mode", "monochrome", ""},
+		{"quality", "draft", nil, "print-quality", 3, ""},
+
+		// Invalid values
+		{"quality", "ultra", full, "", nil,
+			"quality=ultra: invalid value, use one of: " +
+				"draft, normal, best"},
+		{"duplex", "", full, "", nil,
+			"duplex=: invalid value, use one of: off, long, short"},
+	}
+
+	for _, test := range tests {
+		attrName, attrValue, err := ResolveUserOption(
+			test.name, test.value, test.caps)
+
+		errstr := ""
+		if err != nil {
+			errstr = err.Error()
+		}
+
+		if errstr != test.err {
+			t.Errorf("%s=%s: error mismatch:\n"+
+				"expected: %q\npresent:  %q",
+				test.name, test.value, test.err, errstr)
+			continue
+		}
+
+		if attrName != test.attrName ||
+			!reflect.DeepEqual(attrValue, test.attrValue) {
+			t.Errorf("%s=%s: result mismatch:\n"+
+				"expected: %s=%#v\npresent:  %s=%#v",
+				test.name, test.value,
+				test.attrName, test.attrValue,
+				attrName, attrValue)
+		}
+	}
+
+	// Unknown option must return ErrUserOptionUnknown
+	_, _, err := ResolveUserOption("media", "a4", full)
+	if !errors.Is(err, ErrUserOptionUnknown) {
+		t.Errorf("media=a4: expected ErrUserOptionUnknown, present %v",
+			err)
+	}
+}
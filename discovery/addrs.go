@@ -13,6 +13,8 @@ import (
 	"net/url"
 	"sort"
 	"strings"
+
+	"github.com/OpenPrinting/go-mfp/transport"
 )
 
 // addrsFromEndpoints extracts addresses from endpoints
@@ -50,17 +52,13 @@ func addrsFromEndpoints(endpoints []string) (addrs []netip.Addr) {
 			continue
 		}
 
-		addr = addr.Unmap() // Just in case
+		addr = transport.NormalizeAddr(addr)
 
-		// Save the address
-		addrs = append(addrs, addr)
+		// Save the address. addrsAdd keeps addresses sorted
+		// and drops duplicates.
+		addrs, _ = addrsAdd(addrs, addr)
 	}
 
-	// Sort extracted addresses
-	sort.Slice(addrs, func(i, j int) bool {
-		return addrs[i].Less(addrs[j])
-	})
-
 	return
 }
 
@@ -37,3 +37,34 @@ type Backend interface {
 	// Close closes the Backend and releases resources it holds.
 	Close()
 }
+
+// FastBackend is the optional interface, implemented by the [Backend]
+// that reports its initial results quickly, without the network
+// round-trips (for example, from the local cache or from the local
+// system).
+//
+// The first wave of [Client.Discover] completes as soon as all
+// fast backends become ready.
+type FastBackend interface {
+	Backend
+
+	// Ready returns a channel that is closed when the Backend
+	// has pushed all its initially known events into the
+	// [Eventqueue].
+	Ready() <-chan struct{}
+}
+
+// DegradedBackend is the optional interface, implemented by the
+// [Backend] that may work with the reduced set of capabilities.
+//
+// For example, in containers and other locked-down environments,
+// joining multicast groups or binding the well-known ports may
+// fail. Instead of failing, Backend falls back to the less capable
+// discovery method and reports it via the Degraded method.
+type DegradedBackend interface {
+	Backend
+
+	// Degraded returns capabilities, currently not available
+	// to the Backend. Nil means that Backend is fully functional.
+	Degraded() []Degradation
+}
@@ -11,6 +11,7 @@ package discovery
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"time"
 )
 
@@ -21,7 +22,10 @@ type cache struct {
 	readyAt           time.Time            // When cache is warmed up and ready
 	entries           map[UnitID]*cacheEnt // Cache entries
 	out               output               // Cached output
+	idents            identityStore        // Devices identities
 	stabilizationTime time.Duration        // Stabilization time for new data
+	quietPeriod       time.Duration        // Quiet period for re-announcements
+	writes            uint64               // Count of cache modifications
 }
 
 // cacheEnt is the cache entry for print/scan/faxout units.
@@ -30,17 +34,41 @@ type cacheEnt struct {
 	hasParams        bool      // Parameters are received
 	stagingEndpoints []string  // Newly discovered endpoints, on quarantine
 	stagingDoneAt    time.Time // End of staging time. Zero if no staging.
+	paramsAt         time.Time // When parameters were last updated
+	absorbed         uint64    // Count of absorbed re-announcements
 }
 
 // newCache creates the new discovery cache
-func newCache(warmUpTime, stabilizationTime time.Duration) *cache {
+func newCache(warmUpTime, stabilizationTime,
+	quietPeriod time.Duration) *cache {
 	return &cache{
 		readyAt:           time.Now().Add(warmUpTime),
 		entries:           make(map[UnitID]*cacheEnt),
 		stabilizationTime: stabilizationTime,
+		quietPeriod:       quietPeriod,
 	}
 }
 
+// Writes returns count of cache modifications. It allows to detect
+// events that were absorbed without touching the cache.
+func (c *cache) Writes() uint64 {
+	return c.writes
+}
+
+// Absorbed returns count of re-announcements absorbed for the unit.
+func (c *cache) Absorbed(id UnitID) uint64 {
+	if ent := c.entries[id]; ent != nil {
+		return ent.absorbed
+	}
+	return 0
+}
+
+// invalidate must be called on each cache modification.
+func (c *cache) invalidate() {
+	c.writes++
+	c.out.Invalidate()
+}
+
 // ReadyAt returns time when cache is ready to be exported, according to
 // the cache state and export Mode
 func (c *cache) ReadyAt(m Mode) time.Time {
@@ -90,7 +118,10 @@ func (c *cache) Export() []Device {
 		}
 	}
 
-	return c.out.Generate(ttl, units)
+	devices := c.out.Generate(ttl, units)
+	c.idents.Assign(devices, time.Now())
+
+	return devices
 }
 
 // Snapshot exports the cached data in the ModeSnapshot mode.
@@ -106,7 +137,21 @@ func (c *cache) Snapshot() []Device {
 	}
 
 	var out output
-	return out.Generate(ttl, units)
+	devices := out.Generate(ttl, units)
+	c.idents.Assign(devices, time.Now())
+
+	return devices
+}
+
+// Identities returns copy of all known device identities.
+func (c *cache) Identities() []Identity {
+	return c.idents.Export()
+}
+
+// SetIdentities replaces all known device identities.
+func (c *cache) SetIdentities(idents []Identity) {
+	c.idents.Import(idents)
+	c.invalidate()
 }
 
 // AddUnit adds new unit. Called when EventAddUnit is received.
@@ -116,7 +161,7 @@ func (c *cache) AddUnit(evnt *EventAddUnit) error {
 	}
 
 	c.entries[evnt.ID] = &cacheEnt{unit: unit{ID: evnt.ID}}
-	c.out.Invalidate()
+	c.invalidate()
 
 	return nil
 }
@@ -128,7 +173,7 @@ func (c *cache) DelUnit(evnt *EventDelUnit) error {
 	}
 
 	delete(c.entries, evnt.ID)
-	c.out.Invalidate()
+	c.invalidate()
 
 	return nil
 }
@@ -144,15 +189,16 @@ func (c *cache) SetPrinterParameters(evnt *EventPrinterParameters) error {
 	params := evnt.Printer
 	params.fixup()
 
-	ent.MakeModel = evnt.MakeModel
-	ent.Location = evnt.Location
-	ent.AdminURL = evnt.AdminURL
-	ent.IconURL = evnt.IconURL
-	ent.PPDManufacturer = evnt.PPDManufacturer
-	ent.PPDModel = evnt.PPDModel
-	ent.Params = params
+	un := ent.unit
+	un.MakeModel = evnt.MakeModel
+	un.Location = evnt.Location
+	un.AdminURL = evnt.AdminURL
+	un.IconURL = evnt.IconURL
+	un.PPDManufacturer = evnt.PPDManufacturer
+	un.PPDModel = evnt.PPDModel
+	un.Params = params
 
-	c.setParametersCommit(ent)
+	c.setParametersCommit(ent, un)
 
 	return nil
 }
@@ -167,13 +213,14 @@ func (c *cache) SetScannerParameters(evnt *EventScannerParameters) error {
 
 	params := evnt.Scanner
 
-	ent.MakeModel = evnt.MakeModel
-	ent.Location = evnt.Location
-	ent.AdminURL = evnt.AdminURL
-	ent.IconURL = evnt.IconURL
-	ent.Params = params
+	un := ent.unit
+	un.MakeModel = evnt.MakeModel
+	un.Location = evnt.Location
+	un.AdminURL = evnt.AdminURL
+	un.IconURL = evnt.IconURL
+	un.Params = params
 
-	c.setParametersCommit(ent)
+	c.setParametersCommit(ent, un)
 
 	return nil
 }
@@ -191,15 +238,16 @@ func (c *cache) SetFaxoutParameters(evnt *EventFaxoutParameters) error {
 	params := evnt.Faxout
 	params.fixup()
 
-	ent.MakeModel = evnt.MakeModel
-	ent.Location = evnt.Location
-	ent.AdminURL = evnt.AdminURL
-	ent.IconURL = evnt.IconURL
-	ent.PPDManufacturer = evnt.PPDManufacturer
-	ent.PPDModel = evnt.PPDModel
-	ent.Params = params
+	un := ent.unit
+	un.MakeModel = evnt.MakeModel
+	un.Location = evnt.Location
+	un.AdminURL = evnt.AdminURL
+	un.IconURL = evnt.IconURL
+	un.PPDManufacturer = evnt.PPDManufacturer
+	un.PPDModel = evnt.PPDModel
+	un.Params = params
 
-	c.setParametersCommit(ent)
+	c.setParametersCommit(ent, un)
 
 	return nil
 }
@@ -222,10 +270,25 @@ func (c *cache) setParametersBegin(id UnitID,
 	return ent, nil
 }
 
-// setParametersCommit finishes operation of setting unit parameters
-func (c *cache) setParametersCommit(ent *cacheEnt) {
+// setParametersCommit finishes operation of setting unit parameters.
+//
+// Some devices re-announce themselves every few seconds. If parameters
+// are identical to the previous ones and the quiet period since the
+// previous update is not expired yet, the update is absorbed without
+// touching the cache. Changed parameters always pass.
+func (c *cache) setParametersCommit(ent *cacheEnt, un unit) {
+	now := time.Now()
+
+	if ent.hasParams && now.Before(ent.paramsAt.Add(c.quietPeriod)) &&
+		reflect.DeepEqual(ent.unit, un) {
+		ent.absorbed++
+		return
+	}
+
+	ent.unit = un
 	ent.hasParams = true
-	c.out.Invalidate()
+	ent.paramsAt = now
+	c.invalidate()
 }
 
 // AddEndpoint adds unit endpoint.
@@ -235,7 +298,7 @@ func (c *cache) AddEndpoint(evnt *EventAddEndpoint) error {
 		return errors.New("unknown UnitID")
 	}
 
-	endpoint := evnt.Endpoint
+	endpoint := endpointNormalize(evnt.Endpoint, evnt.ID.Zone)
 	if endpointsContain(ent.Endpoints, endpoint) ||
 		endpointsContain(ent.stagingEndpoints, endpoint) {
 		return errors.New("endpoint already added")
@@ -244,7 +307,7 @@ func (c *cache) AddEndpoint(evnt *EventAddEndpoint) error {
 	ent.stagingBegin(c.stabilizationTime)
 	ent.stagingEndpoints, _ = endpointsAdd(ent.stagingEndpoints, endpoint)
 
-	c.out.Invalidate()
+	c.invalidate()
 
 	return nil
 }
@@ -256,7 +319,7 @@ func (c *cache) DelEndpoint(evnt *EventDelEndpoint) error {
 		return errors.New("unknown UnitID")
 	}
 
-	endpoint := evnt.Endpoint
+	endpoint := endpointNormalize(evnt.Endpoint, evnt.ID.Zone)
 
 	switch {
 	case endpointsContain(ent.Endpoints, endpoint):
@@ -269,7 +332,7 @@ func (c *cache) DelEndpoint(evnt *EventDelEndpoint) error {
 		return errors.New("unknown endpoint")
 	}
 
-	c.out.Invalidate()
+	c.invalidate()
 
 	return nil
 }
@@ -289,6 +352,7 @@ func (ent *cacheEnt) snapshot() (un unit, ok bool) {
 		un = ent.unit
 		un.Endpoints = endpointsMerge(un.Endpoints,
 			ent.stagingEndpoints)
+		un.Staging = endpointsMerge(nil, ent.stagingEndpoints)
 		if len(un.Endpoints) > 0 {
 			return un, true
 		}
@@ -24,6 +24,9 @@ type Client struct {
 	queue    *Eventqueue
 	backends map[Backend]struct{}
 	cache    *cache
+	updated  chan struct{} // Closed and replaced on cache update
+	progress chan struct{} // Closed and replaced on each handled event
+	handled  uint64        // Count of handled events
 	lock     sync.Mutex
 	done     sync.WaitGroup
 }
@@ -59,7 +62,9 @@ func NewClientTm(ctx context.Context,
 		ctx:      ctx,
 		cancel:   cancel,
 		queue:    NewEventqueue(),
-		cache:    newCache(warmUpTime, stabilizationTime),
+		cache:    newCache(warmUpTime, stabilizationTime, QuietPeriod),
+		updated:  make(chan struct{}),
+		progress: make(chan struct{}),
 		backends: make(map[Backend]struct{}),
 	}
 
@@ -165,6 +170,28 @@ func (clnt *Client) GetDevices(ctx context.Context, m Mode) ([]Device, error) {
 func (clnt *Client) Refresh() {
 }
 
+// Identities returns all device identities, known to the [Client],
+// including identities of devices that already disappeared.
+//
+// Identity history may be used for audit. Identities may be saved
+// and restored later with the [Client.SetIdentities], so devices
+// keep their Device.LocalID across the Client restarts.
+func (clnt *Client) Identities() []Identity {
+	clnt.lock.Lock()
+	defer clnt.lock.Unlock()
+
+	return clnt.cache.Identities()
+}
+
+// SetIdentities replaces all device identities, known to the [Client],
+// i.e., with the previously saved by the [Client.Identities].
+func (clnt *Client) SetIdentities(idents []Identity) {
+	clnt.lock.Lock()
+	defer clnt.lock.Unlock()
+
+	clnt.cache.SetIdentities(idents)
+}
+
 // proc runs the discovery event loop on its separate goroutine.
 func (clnt *Client) proc() {
 	defer clnt.done.Done()
@@ -191,6 +218,8 @@ func (clnt *Client) nextEvent() error {
 	rec.Debug("%s:", evnt.Name())
 	rec.Object(log.LevelDebug, 2, evnt.GetID())
 
+	writes := clnt.cache.Writes()
+
 	switch evnt := evnt.(type) {
 	case *EventAddUnit:
 		err = clnt.cache.AddUnit(evnt)
@@ -210,10 +239,28 @@ func (clnt *Client) nextEvent() error {
 		err = clnt.cache.DelEndpoint(evnt)
 	}
 
-	if err != nil {
+	changed := clnt.cache.Writes() != writes
+
+	switch {
+	case err != nil:
 		// Log backend error and don't propagate it up the stack
 		rec.Error("%s", err)
 		err = nil
+
+	case !changed:
+		// Identical re-announcement, absorbed by the cache
+		rec.Debug("  Absorbed:  %d", clnt.cache.Absorbed(evnt.GetID()))
+	}
+
+	// Wake up waiters for progress
+	clnt.handled++
+	close(clnt.progress)
+	clnt.progress = make(chan struct{})
+
+	// Wake up waiters for updates, if something has changed
+	if changed {
+		close(clnt.updated)
+		clnt.updated = make(chan struct{})
 	}
 
 	return err
@@ -224,19 +271,19 @@ func (clnt *Client) nextEvent() error {
 // This is the testing interface and it is not recommended
 // for the general use
 func (clnt *Client) flush() {
+	pushed := clnt.queue.pushedCount()
+
 	clnt.lock.Lock()
 	defer clnt.lock.Unlock()
 
-	for {
-		switch {
-		case clnt.queue.Count() == 0:
-			return
-		case clnt.ctx.Err() != nil:
-			return
-		}
+	for clnt.handled < pushed && clnt.ctx.Err() == nil {
+		progress := clnt.progress
 
 		clnt.lock.Unlock()
-		time.Sleep(10 * time.Millisecond)
+		select {
+		case <-progress:
+		case <-clnt.ctx.Done():
+		}
 		clnt.lock.Lock()
 	}
 }
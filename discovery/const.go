@@ -21,7 +21,16 @@ const (
 	// Stabilization time after discovery of new data.
 	StabilizationTime = 1 * time.Second
 
+	// Quiet period for re-announcements. Identical unit parameters,
+	// received within this period after the previous update, are
+	// absorbed without touching the cache.
+	QuietPeriod = 30 * time.Second
+
 	// Fast and not so reliable discovery for interactive purposes,
 	// like discovery-based command-line auto completion.
 	FastDiscoveryTime = 2500 * time.Millisecond
+
+	// Deadline for the first wave of results for interactive UIs.
+	// See [Client.Discover] for details.
+	FirstWaveTime = 1 * time.Second
 )
@@ -0,0 +1,66 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Degraded backend capabilities
+
+package discovery
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Degradation describes the discovery capability, not available
+// to the [Backend] (typically, due to the lack of privileges), and
+// the fallback, used instead.
+type Degradation struct {
+	Backend    string // Backend name
+	Capability string // Degraded capability (i.e., "multicast reception")
+	Fallback   string // Fallback used instead, "" if none
+	Err        error  // The cause
+}
+
+// String returns string representation of the Degradation,
+// suitable for logging and status output.
+func (d Degradation) String() string {
+	s := fmt.Sprintf("%s: %s not available", d.Backend, d.Capability)
+	if d.Err != nil {
+		s += fmt.Sprintf(" (%s)", d.Err)
+	}
+
+	if d.Fallback != "" {
+		s += ", using " + d.Fallback
+	} else {
+		s += ", no fallback"
+	}
+
+	return s
+}
+
+// Degraded returns capabilities, degraded by all backends,
+// attached to the [Client] (see [DegradedBackend]). Nil means
+// that all backends are fully functional.
+//
+// Degradations are sorted by backend name.
+func (clnt *Client) Degraded() []Degradation {
+	clnt.lock.Lock()
+	var degraded []Degradation
+	for bk := range clnt.backends {
+		if dbk, ok := bk.(DegradedBackend); ok {
+			degraded = append(degraded, dbk.Degraded()...)
+		}
+	}
+	clnt.lock.Unlock()
+
+	sort.SliceStable(degraded, func(i, j int) bool {
+		if degraded[i].Backend != degraded[j].Backend {
+			return degraded[i].Backend < degraded[j].Backend
+		}
+		return degraded[i].Capability < degraded[j].Capability
+	})
+
+	return degraded
+}
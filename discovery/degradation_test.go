@@ -0,0 +1,75 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Degraded backend capabilities tests
+
+package discovery
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// testDegradedBackend is the MockBackend that implements
+// the DegradedBackend interface.
+type testDegradedBackend struct {
+	*MockBackend
+	degraded []Degradation
+}
+
+// Degraded returns degraded capabilities of the backend.
+func (bk *testDegradedBackend) Degraded() []Degradation {
+	return bk.degraded
+}
+
+// TestClientDegraded tests Client.Degraded
+func TestClientDegraded(t *testing.T) {
+	clnt := NewClient(context.Background())
+	defer clnt.Close()
+
+	// Fully functional backends
+	clnt.AddBackend(NewMockBackend("usb"))
+	clnt.AddBackend(&testDegradedBackend{MockBackend: NewMockBackend("dnssd")})
+
+	if degraded := clnt.Degraded(); degraded != nil {
+		t.Errorf("expected no degradations, present %v", degraded)
+	}
+
+	// Degraded backend
+	eperm := os.NewSyscallError("bind", syscall.EPERM)
+	clnt.AddBackend(&testDegradedBackend{
+		MockBackend: NewMockBackend("wsdd"),
+		degraded: []Degradation{
+			{
+				Backend:    "wsdd",
+				Capability: "multicast reception",
+				Fallback:   "directed probes",
+				Err:        eperm,
+			},
+		},
+	})
+
+	degraded := clnt.Degraded()
+	if len(degraded) != 1 {
+		t.Fatalf("expected 1 degradation, present %d", len(degraded))
+	}
+
+	expected := "wsdd: multicast reception not available " +
+		"(bind: operation not permitted), using directed probes"
+	if s := degraded[0].String(); s != expected {
+		t.Errorf("String:\nexpected: %s\npresent:  %s", expected, s)
+	}
+
+	// Degradation without fallback
+	degraded[0].Fallback = ""
+	expected = "wsdd: multicast reception not available " +
+		"(bind: operation not permitted), no fallback"
+	if s := degraded[0].String(); s != expected {
+		t.Errorf("String:\nexpected: %s\npresent:  %s", expected, s)
+	}
+}
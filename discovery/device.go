@@ -50,6 +50,18 @@ type Device struct {
 	USBSerial string // USB serial number, "" if n/a
 	USBHWID   string // USB hardware ID, "" if n/a
 
+	// MACAddr is reported by some backends.
+	MACAddr string // MAC address, "" if n/a
+
+	// LocalID is the stable local device ID, assigned by
+	// the discovery [Client]. It persists across changes of
+	// the device addresses and UUID. See [Identity] for details.
+	//
+	// IdentityConflict is set when other live device claims
+	// the same MAC address or serial number.
+	LocalID          string // "" if not assigned
+	IdentityConflict bool   // Conflicting strong identifiers
+
 	// Connectivity
 	Addrs []netip.Addr // Device's IP addresses
 
@@ -57,6 +69,13 @@ type Device struct {
 	PrintUnits  []PrintUnit  // Print units
 	ScanUnits   []ScanUnit   // Scan units
 	FaxoutUnits []FaxoutUnit // Faxout units
+
+	// Late is set by [Results] for devices, discovered after
+	// the first wave.
+	Late bool
+
+	ids     []UnitID // IDs of the device units
+	records []unit   // Per-backend units, see FormatOptions.Raw
 }
 
 // device is the internal representation of the Device
@@ -69,7 +88,11 @@ type device struct {
 
 // Export exports device as Device
 func (dev device) Export() Device {
-	out := Device{Addrs: dev.addrs}
+	out := Device{Addrs: dev.addrs, records: dev.units}
+
+	for i := range dev.units {
+		out.ids = append(out.ids, dev.units[i].ID)
+	}
 
 	// Classify units
 	var ippPrinters []*unit
@@ -80,6 +103,7 @@ func (dev device) Export() Device {
 	var ippScanners []*unit
 	var esclSanners []*unit
 	var wsdScanners []*unit
+	var usbScanners []*unit
 	var ippFaxes []*unit
 
 	for i := range dev.units {
@@ -107,6 +131,8 @@ func (dev device) Export() Device {
 				esclSanners = append(esclSanners, un)
 			case ServiceWSD:
 				wsdScanners = append(wsdScanners, un)
+			case ServiceUSB:
+				usbScanners = append(usbScanners, un)
 			}
 
 		case ServiceFaxout:
@@ -130,6 +156,7 @@ func (dev device) Export() Device {
 		ippScanners,
 		esclSanners,
 		wsdScanners,
+		usbScanners,
 	)
 
 	faxoutUnits := ippFaxes
@@ -169,6 +196,7 @@ func (dev device) Export() Device {
 		ippScanners,
 		esclSanners,
 		wsdScanners,
+		usbScanners,
 		ippFaxes,
 	)
 
@@ -242,6 +270,10 @@ func (dev device) Export() Device {
 		if un.ID.USBHWID != "" {
 			out.USBHWID = un.ID.USBHWID
 		}
+
+		if un.ID.MACAddr != "" {
+			out.MACAddr = un.ID.MACAddr
+		}
 	}
 
 	return out
@@ -10,9 +10,15 @@ package discovery
 
 import (
 	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/OpenPrinting/go-mfp/transport"
 	"github.com/OpenPrinting/go-mfp/util/uuid"
 )
 
@@ -269,3 +275,256 @@ func TestClient_Unreachable(t *testing.T) {
 		t.Errorf("Expected 0 devices, got %d", len(devices))
 	}
 }
+
+// TestClient_IPv6Only verifies that synthetic announcements from the
+// IPv6-only network produce usable endpoint URLs: link-local
+// addresses are zone-qualified, zoned and unzoned forms of the same
+// address are merged and endpoints are dialable.
+func TestClient_IPv6Only(t *testing.T) {
+	// Find the loopback interface
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("net.Interfaces: %s", err)
+	}
+
+	lo := ""
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			lo = iface.Name
+			break
+		}
+	}
+
+	if lo == "" {
+		t.Skip("loopback interface not found")
+	}
+
+	// Start the fake scanner on the IPv6 loopback
+	l, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 not available: %s", err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	srv.Listener.Close()
+	srv.Listener = l
+	srv.Start()
+	defer srv.Close()
+
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+
+	// Announce the scanner
+	ctx := context.Background()
+	client := NewClientTm(ctx, 100*time.Millisecond, 100*time.Millisecond)
+	defer client.Close()
+
+	backend := NewMockBackend("mock-backend")
+
+	uid := UnitID{
+		DNSSDName: "IPv6 Scanner",
+		UUID:      uuid.Random(),
+		SvcType:   ServiceScanner,
+		SvcProto:  ServiceESCL,
+		Zone:      lo,
+	}
+
+	backend.AddEvent(&EventAddUnit{ID: uid})
+	backend.AddEvent(&EventScannerParameters{ID: uid})
+
+	for _, endpoint := range []string{
+		"http://[::1]:" + port + "/eSCL",
+		"http://[fe80::1]:" + port + "/eSCL",
+		"http://[fe80::1%25" + lo + "]:" + port + "/eSCL",
+	} {
+		backend.AddEvent(&EventAddEndpoint{ID: uid, Endpoint: endpoint})
+	}
+
+	client.AddBackend(backend)
+	client.flush()
+
+	// Endpoints are published when their staging interval ends,
+	// and ModeWaitIncomplete waits for it.
+	devices, err := client.GetDevices(ctx, ModeWaitIncomplete)
+	if err != nil {
+		t.Fatalf("GetDevices failed: %v", err)
+	}
+
+	if len(devices) != 1 || len(devices[0].ScanUnits) != 1 {
+		t.Fatalf("Expected 1 device with 1 scan unit, got %v", devices)
+	}
+
+	// Check endpoints
+	endpoints := devices[0].ScanUnits[0].Endpoints
+	if len(endpoints) != 2 {
+		t.Fatalf("Expected 2 endpoints, got %q", endpoints)
+	}
+
+	for _, endpoint := range endpoints {
+		u, err := transport.ParseURL(endpoint)
+		if err != nil {
+			t.Errorf("%s: %s", endpoint, err)
+			continue
+		}
+
+		addr, err := netip.ParseAddr(u.Hostname())
+		if err != nil {
+			t.Errorf("%s: not a literal address", endpoint)
+			continue
+		}
+
+		if addr.IsLinkLocalUnicast() {
+			// Link-local address must be zone-qualified
+			if addr.Zone() != lo {
+				t.Errorf("%s: zone %q expected", endpoint, lo)
+			}
+			continue
+		}
+
+		// Loopback endpoint must be dialable
+		rsp, err := http.Get(u.String())
+		if err != nil {
+			t.Errorf("%s: %s", endpoint, err)
+			continue
+		}
+		rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusOK {
+			t.Errorf("%s: HTTP %s", endpoint, rsp.Status)
+		}
+	}
+}
+
+// TestClient_Reannouncements verifies that identical re-announcements
+// from a chatty device are absorbed without touching the cache, while
+// the changed announcement passes.
+func TestClient_Reannouncements(t *testing.T) {
+	ctx := context.Background()
+	client := NewClientTm(ctx, 100*time.Millisecond, 100*time.Millisecond)
+	defer client.Close()
+
+	backend := NewMockBackend("mock-backend")
+
+	uid := UnitID{
+		DNSSDName: "Test Printer",
+		UUID:      uuid.Random(),
+		SvcType:   ServicePrinter,
+		SvcProto:  ServiceIPP,
+	}
+
+	params := &EventPrinterParameters{
+		ID:        uid,
+		MakeModel: "Test Make Model",
+		Printer: PrinterParameters{
+			Queue: "test-queue",
+		},
+	}
+
+	changed := *params
+	changed.Location = "2nd Floor Lab"
+
+	// Device announces itself 100 times identically, then changes
+	backend.AddEvent(&EventAddUnit{ID: uid})
+	backend.AddEvent(&EventAddEndpoint{
+		ID:       uid,
+		Endpoint: "ipp://192.168.1.100/ipp/print",
+	})
+	for i := 0; i < 100; i++ {
+		p := *params
+		backend.AddEvent(&p)
+	}
+	backend.AddEvent(&changed)
+
+	// Count updates, seen by the waiters
+	client.lock.Lock()
+	updated := client.updated
+	client.lock.Unlock()
+
+	updates := 0
+	client.AddBackend(backend)
+
+	for {
+		client.lock.Lock()
+		done := client.handled == client.queue.pushedCount()
+		if updated != client.updated {
+			updated = client.updated
+			updates++
+		}
+		client.lock.Unlock()
+
+		if done {
+			break
+		}
+
+		select {
+		case <-updated:
+		case <-time.After(time.Second):
+			t.Fatalf("Events not handled in time")
+		}
+	}
+
+	client.lock.Lock()
+	writes := client.cache.Writes()
+	absorbed := client.cache.Absorbed(uid)
+	client.lock.Unlock()
+
+	// Expected: one add (EventAddUnit + endpoint + first parameters)
+	// and one update (changed parameters).
+	if writes != 4 {
+		t.Errorf("Expected 4 cache writes, got %d", writes)
+	}
+
+	if updates > 4 {
+		t.Errorf("Expected at most 4 updates, got %d", updates)
+	}
+
+	if absorbed != 99 {
+		t.Errorf("Expected 99 absorbed announcements, got %d", absorbed)
+	}
+
+	devices, err := client.GetDevices(ctx, ModeSnapshot)
+	if err != nil {
+		t.Fatalf("GetDevices failed: %v", err)
+	}
+
+	if len(devices) != 1 {
+		t.Errorf("Expected 1 device, got %d", len(devices))
+	} else if devices[0].Location != changed.Location {
+		t.Errorf("Expected Location %q, got %q",
+			changed.Location, devices[0].Location)
+	}
+}
+
+// TestCache_QuietPeriod verifies that identical parameters pass
+// after the quiet period expiration.
+func TestCache_QuietPeriod(t *testing.T) {
+	c := newCache(0, 0, 50*time.Millisecond)
+
+	uid := UnitID{
+		DNSSDName: "Test Printer",
+		UUID:      uuid.Random(),
+		SvcType:   ServicePrinter,
+		SvcProto:  ServiceIPP,
+	}
+
+	params := &EventPrinterParameters{ID: uid, MakeModel: "Test"}
+
+	c.AddUnit(&EventAddUnit{ID: uid})
+	c.SetPrinterParameters(params)
+	c.SetPrinterParameters(params)
+
+	if c.Writes() != 2 || c.Absorbed(uid) != 1 {
+		t.Errorf("Within quiet period: writes=%d, absorbed=%d",
+			c.Writes(), c.Absorbed(uid))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	c.SetPrinterParameters(params)
+
+	if c.Writes() != 3 || c.Absorbed(uid) != 1 {
+		t.Errorf("After quiet period: writes=%d, absorbed=%d",
+			c.Writes(), c.Absorbed(uid))
+	}
+}
@@ -0,0 +1,408 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Avahi client over D-Bus
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Avahi client
+
+package avahidbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+
+	"github.com/OpenPrinting/go-mfp/internal/dbus"
+)
+
+// Avahi D-Bus names
+const (
+	// ServiceName is the well-known bus name of the avahi-daemon
+	ServiceName = "org.freedesktop.Avahi"
+
+	serverPath    = dbus.ObjectPath("/")
+	serverIface   = "org.freedesktop.Avahi.Server"
+	browserIface  = "org.freedesktop.Avahi.ServiceBrowser"
+	resolverIface = "org.freedesktop.Avahi.ServiceResolver"
+)
+
+// pendingMax is the maximum number of signals, buffered for
+// objects not known yet.
+const pendingMax = 1024
+
+// ErrNotRunning is returned by the [NewClient], if avahi-daemon
+// is not present on the bus.
+var ErrNotRunning = errors.New("Avahi: avahi-daemon is not running")
+
+// Client is the connection to the avahi-daemon
+type Client struct {
+	conn     *dbus.Conn                          // D-Bus connection
+	lock     sync.Mutex                          // Access lock
+	objects  map[dbus.ObjectPath]any             // Browsers and resolvers
+	pending  map[dbus.ObjectPath][]*dbus.Message // Early signals
+	npending int                                 // Total count of pending
+	inflight int                                 // Objects being created
+	events   []any                               // Events ready for Poll
+}
+
+// ServiceBrowserEvent is reported by the [ServiceBrowser]
+type ServiceBrowserEvent struct {
+	Browser      *ServiceBrowser   // Originating browser
+	Event        BrowserEvent      // Event type
+	IfIdx        IfIndex           // Network interface index
+	Proto        Protocol          // Network protocol
+	InstanceName string            // Service instance name
+	SvcType      string            // Service type
+	Domain       string            // Service domain
+	Flags        LookupResultFlags // Result flags
+	Err          error             // For BrowserFailure
+}
+
+// ServiceResolverEvent is reported by the [ServiceResolver]
+type ServiceResolverEvent struct {
+	Resolver     *ServiceResolver  // Originating resolver
+	Event        ResolverEvent     // Event type
+	IfIdx        IfIndex           // Network interface index
+	Proto        Protocol          // Network protocol
+	InstanceName string            // Service instance name
+	SvcType      string            // Service type
+	Domain       string            // Service domain
+	Hostname     string            // Service host name
+	Addr         netip.Addr        // Service address
+	Port         uint16            // Service port
+	TXT          []string          // TXT record
+	Flags        LookupResultFlags // Result flags
+	Err          error             // For ResolverFailure
+}
+
+// ServiceBrowser browses for services of the particular type
+type ServiceBrowser struct {
+	clnt *Client         // The owner
+	path dbus.ObjectPath // Object path
+}
+
+// ServiceResolver resolves the service instance
+type ServiceResolver struct {
+	clnt *Client         // The owner
+	path dbus.ObjectPath // Object path
+}
+
+// NewClient connects to the avahi-daemon via the message bus
+// at the specified address. If addr is "", the system bus is used.
+//
+// It returns [ErrNotRunning] if the daemon is not present on the bus.
+func NewClient(ctx context.Context, addr string) (*Client, error) {
+	if addr == "" {
+		addr = dbus.SystemBusAddress()
+	}
+
+	conn, err := dbus.Dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	owned, err := conn.NameHasOwner(ctx, ServiceName)
+	if err == nil && !owned {
+		err = ErrNotRunning
+	}
+
+	// Subscribe to signals of browsers and resolvers
+	for _, iface := range []string{browserIface, resolverIface} {
+		if err == nil {
+			rule := fmt.Sprintf("type='signal',sender='%s',"+
+				"interface='%s'", ServiceName, iface)
+			err = conn.AddMatch(ctx, rule)
+		}
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	clnt := &Client{
+		conn:    conn,
+		objects: make(map[dbus.ObjectPath]any),
+		pending: make(map[dbus.ObjectPath][]*dbus.Message),
+	}
+
+	return clnt, nil
+}
+
+// Close closes the Client. Avahi automatically frees all
+// browsers and resolvers, owned by the client.
+func (clnt *Client) Close() {
+	clnt.conn.Close()
+}
+
+// Poll returns the next event. Events are [*ServiceBrowserEvent]
+// and [*ServiceResolverEvent].
+//
+// It blocks until event is available, the connection fails or
+// context is canceled. Connection errors are permanent: the Client
+// needs to be closed and created again.
+func (clnt *Client) Poll(ctx context.Context) (any, error) {
+	for {
+		clnt.lock.Lock()
+		if len(clnt.events) > 0 {
+			evnt := clnt.events[0]
+			clnt.events[0] = nil
+			clnt.events = clnt.events[1:]
+			clnt.lock.Unlock()
+			return evnt, nil
+		}
+		clnt.lock.Unlock()
+
+		msg, err := clnt.conn.Signal(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		clnt.lock.Lock()
+		clnt.dispatch(msg)
+		clnt.lock.Unlock()
+	}
+}
+
+// NewServiceBrowser creates a new [ServiceBrowser] for the services
+// of the specified type.
+func (clnt *Client) NewServiceBrowser(ctx context.Context,
+	ifidx IfIndex, proto Protocol, svctype, domain string,
+	flags LookupFlags) (*ServiceBrowser, error) {
+
+	browser := &ServiceBrowser{clnt: clnt}
+	path, err := clnt.newObject(ctx, browser, "ServiceBrowserNew",
+		"iissu",
+		int32(ifidx), int32(proto), svctype, domain, uint32(flags))
+
+	if err != nil {
+		return nil, err
+	}
+
+	browser.path = path
+	return browser, nil
+}
+
+// Close closes the ServiceBrowser
+func (browser *ServiceBrowser) Close() {
+	browser.clnt.freeObject(browser.path, browserIface)
+}
+
+// NewServiceResolver creates a new [ServiceResolver] for the
+// service instance. Address of the aproto protocol is resolved.
+func (clnt *Client) NewServiceResolver(ctx context.Context,
+	ifidx IfIndex, proto Protocol,
+	instance, svctype, domain string,
+	aproto Protocol, flags LookupFlags) (*ServiceResolver, error) {
+
+	resolver := &ServiceResolver{clnt: clnt}
+	path, err := clnt.newObject(ctx, resolver, "ServiceResolverNew",
+		"iisssiu",
+		int32(ifidx), int32(proto), instance, svctype, domain,
+		int32(aproto), uint32(flags))
+
+	if err != nil {
+		return nil, err
+	}
+
+	resolver.path = path
+	return resolver, nil
+}
+
+// Close closes the ServiceResolver
+func (resolver *ServiceResolver) Close() {
+	resolver.clnt.freeObject(resolver.path, resolverIface)
+}
+
+// newObject creates a new browser or resolver with the Avahi
+// server method and registers it in the Client.
+//
+// Avahi may start to emit signals of the new object before
+// method returns. Such signals are kept pending, until object
+// path becomes known.
+func (clnt *Client) newObject(ctx context.Context, obj any,
+	method string, sig dbus.Signature, args ...any) (
+	dbus.ObjectPath, error) {
+
+	clnt.lock.Lock()
+	clnt.inflight++
+	clnt.lock.Unlock()
+
+	reply, err := clnt.conn.Call(ctx, ServiceName, serverPath,
+		serverIface, method, sig, args...)
+
+	var path dbus.ObjectPath
+	if err == nil {
+		var ok bool
+		if len(reply) > 0 {
+			path, ok = reply[0].(dbus.ObjectPath)
+		}
+		if !ok {
+			err = errors.New("invalid reply")
+		}
+	}
+
+	clnt.lock.Lock()
+	defer clnt.lock.Unlock()
+
+	clnt.inflight--
+	if err != nil {
+		return "", fmt.Errorf("Avahi: %s: %w", method, err)
+	}
+
+	clnt.objects[path] = obj
+
+	// Dispatch signals, received before the object path became known
+	early := clnt.pending[path]
+	delete(clnt.pending, path)
+	clnt.npending -= len(early)
+
+	for _, msg := range early {
+		clnt.dispatch(msg)
+	}
+
+	if clnt.inflight == 0 {
+		clnt.pending = make(map[dbus.ObjectPath][]*dbus.Message)
+		clnt.npending = 0
+	}
+
+	return path, nil
+}
+
+// freeObject frees the browser or resolver
+func (clnt *Client) freeObject(path dbus.ObjectPath, iface string) {
+	clnt.lock.Lock()
+	delete(clnt.objects, path)
+	clnt.lock.Unlock()
+
+	clnt.conn.CallNoReply(ServiceName, path, iface, "Free", "")
+}
+
+// dispatch converts the received signal into the event and
+// queues it for Poll. Must be called under the clnt.lock.
+func (clnt *Client) dispatch(msg *dbus.Message) {
+	obj, found := clnt.objects[msg.Path]
+	if !found {
+		// Keep signal pending, if it may belong to the object
+		// being created. Otherwise, it belongs to the already
+		// freed object and can be dropped.
+		if clnt.inflight > 0 && clnt.npending < pendingMax {
+			clnt.pending[msg.Path] = append(
+				clnt.pending[msg.Path], msg)
+			clnt.npending++
+		}
+		return
+	}
+
+	var evnt any
+	switch obj := obj.(type) {
+	case *ServiceBrowser:
+		evnt = obj.decode(msg)
+	case *ServiceResolver:
+		evnt = obj.decode(msg)
+	}
+
+	if evnt != nil {
+		clnt.events = append(clnt.events, evnt)
+	}
+}
+
+// decode decodes the ServiceBrowser signal.
+// It returns nil, if signal is not recognized.
+func (browser *ServiceBrowser) decode(msg *dbus.Message) any {
+	evnt := &ServiceBrowserEvent{Browser: browser}
+
+	switch {
+	case msg.Interface != browserIface:
+		return nil
+
+	case msg.Member == "ItemNew" && msg.Signature == "iisssu",
+		msg.Member == "ItemRemove" && msg.Signature == "iisssu":
+
+		evnt.Event = BrowserNew
+		if msg.Member == "ItemRemove" {
+			evnt.Event = BrowserRemove
+		}
+
+		evnt.IfIdx = IfIndex(msg.Body[0].(int32))
+		evnt.Proto = Protocol(msg.Body[1].(int32))
+		evnt.InstanceName = msg.Body[2].(string)
+		evnt.SvcType = msg.Body[3].(string)
+		evnt.Domain = msg.Body[4].(string)
+		evnt.Flags = LookupResultFlags(msg.Body[5].(uint32))
+
+	case msg.Member == "AllForNow":
+		evnt.Event = BrowserAllForNow
+
+	case msg.Member == "CacheExhausted":
+		evnt.Event = BrowserCacheExhausted
+
+	case msg.Member == "Failure":
+		evnt.Event = BrowserFailure
+		evnt.Err = failureError(msg)
+
+	default:
+		return nil
+	}
+
+	return evnt
+}
+
+// decode decodes the ServiceResolver signal.
+// It returns nil, if signal is not recognized.
+func (resolver *ServiceResolver) decode(msg *dbus.Message) any {
+	evnt := &ServiceResolverEvent{Resolver: resolver}
+
+	switch {
+	case msg.Interface != resolverIface:
+		return nil
+
+	case msg.Member == "Found" && msg.Signature == "iissssisqaayu":
+		addr, err := netip.ParseAddr(msg.Body[7].(string))
+		if err != nil {
+			evnt.Event = ResolverFailure
+			evnt.Err = fmt.Errorf("Avahi: invalid address: %w",
+				err)
+			return evnt
+		}
+
+		evnt.Event = ResolverFound
+		evnt.IfIdx = IfIndex(msg.Body[0].(int32))
+		evnt.Proto = Protocol(msg.Body[1].(int32))
+		evnt.InstanceName = msg.Body[2].(string)
+		evnt.SvcType = msg.Body[3].(string)
+		evnt.Domain = msg.Body[4].(string)
+		evnt.Hostname = msg.Body[5].(string)
+		evnt.Addr = addr
+		evnt.Port = msg.Body[8].(uint16)
+		evnt.Flags = LookupResultFlags(msg.Body[10].(uint32))
+
+		for _, txt := range msg.Body[9].([]any) {
+			evnt.TXT = append(evnt.TXT, string(txt.([]byte)))
+		}
+
+	case msg.Member == "Failure":
+		evnt.Event = ResolverFailure
+		evnt.Err = failureError(msg)
+
+	default:
+		return nil
+	}
+
+	return evnt
+}
+
+// failureError makes error from the Failure signal
+func failureError(msg *dbus.Message) error {
+	text := "unknown error"
+	if len(msg.Body) > 0 {
+		if s, ok := msg.Body[0].(string); ok {
+			text = s
+		}
+	}
+
+	return fmt.Errorf("Avahi: %s", text)
+}
@@ -0,0 +1,309 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Avahi client over D-Bus
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Avahi client test
+
+package avahidbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/internal/dbus"
+	"github.com/OpenPrinting/go-mfp/internal/dbus/dbustest"
+)
+
+// testSignal is the recorded Avahi signal
+type testSignal struct {
+	member string         // Signal name
+	sig    dbus.Signature // Signature
+	args   []any          // Arguments
+}
+
+// testObject is the recorded Avahi browser or resolver: its
+// path and signals it emits. If early is true, signals are emitted
+// before reply to the creation method, as older Avahi versions do.
+type testObject struct {
+	path    dbus.ObjectPath
+	early   bool
+	signals []testSignal
+}
+
+// testTXT is the TXT record of the recorded printer
+var testTXT = []any{
+	[]byte("txtvers=1"),
+	[]byte("ty=Kyocera ECOSYS M2040dn"),
+	[]byte("rp=ipp/print"),
+	[]byte("UUID=4509a320-00a0-008f-00b6-002507510eca"),
+}
+
+// testBrowsers contains recorded service browsers, by service type
+var testBrowsers = map[string]testObject{
+	"_ipp._tcp": {
+		path:  "/Client1/ServiceBrowser1",
+		early: true,
+		signals: []testSignal{
+			{"ItemNew", "iisssu", []any{
+				int32(2), int32(0), "Kyocera ECOSYS M2040dn",
+				"_ipp._tcp", "local", uint32(0)}},
+			{"ItemNew", "iisssu", []any{
+				int32(2), int32(1), "Kyocera ECOSYS M2040dn",
+				"_ipp._tcp", "local", uint32(0)}},
+			{"CacheExhausted", "", nil},
+			{"AllForNow", "", nil},
+		},
+	},
+
+	"_uscan._tcp": {
+		path: "/Client1/ServiceBrowser2",
+		signals: []testSignal{
+			{"Failure", "s", []any{"Too many objects"}},
+		},
+	},
+}
+
+// testResolvers contains recorded service resolvers, by instance
+// name and protocol
+var testResolvers = map[string]testObject{
+	"Kyocera ECOSYS M2040dn/ip4": {
+		path: "/Client1/ServiceResolver3",
+		signals: []testSignal{
+			{"Found", "iissssisqaayu", []any{
+				int32(2), int32(0), "Kyocera ECOSYS M2040dn",
+				"_ipp._tcp", "local", "KM7B6A91.local",
+				int32(0), "192.168.1.102", uint16(631),
+				testTXT, uint32(4)}},
+		},
+	},
+
+	"Kyocera ECOSYS M2040dn/ip6": {
+		path: "/Client1/ServiceResolver4",
+		signals: []testSignal{
+			{"Failure", "s", []any{"Timeout reached"}},
+		},
+	},
+}
+
+// testAvahi is the dbustest.Handler that replays the recorded
+// Avahi traffic
+func testAvahi(srv *dbustest.Server, call *dbustest.Call) {
+	if call.Interface != serverIface {
+		if call.Member == "Free" {
+			call.Reply("")
+		}
+		return
+	}
+
+	var obj testObject
+	var found bool
+
+	switch call.Member {
+	case "ServiceBrowserNew":
+		obj, found = testBrowsers[call.Body[2].(string)]
+
+	case "ServiceResolverNew":
+		key := fmt.Sprintf("%s/%s", call.Body[2],
+			Protocol(call.Body[5].(int32)))
+		obj, found = testResolvers[key]
+	}
+
+	if !found {
+		call.Error("org.freedesktop.Avahi.NotFoundError",
+			"Not found")
+		return
+	}
+
+	iface := browserIface
+	if call.Member == "ServiceResolverNew" {
+		iface = resolverIface
+	}
+
+	if !obj.early {
+		call.Reply("o", obj.path)
+	}
+
+	for _, s := range obj.signals {
+		srv.Emit(obj.path, iface, s.member, s.sig, s.args...)
+	}
+
+	if obj.early {
+		call.Reply("o", obj.path)
+	}
+}
+
+// TestClient tests the Client against the recorded Avahi traffic
+func TestClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	srv := dbustest.NewServer(testAvahi)
+	defer srv.Close()
+
+	// Avahi is not running yet
+	_, err := NewClient(ctx, srv.Addr())
+	if err != ErrNotRunning {
+		t.Fatalf("NewClient: expected ErrNotRunning, present %v", err)
+	}
+
+	srv.SetNameOwned(ServiceName, true)
+	clnt, err := NewClient(ctx, srv.Addr())
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+	defer clnt.Close()
+
+	// Browse for IPP printers. Signals are sent before reply.
+	ipp, err := clnt.NewServiceBrowser(ctx, IfIndexUnspec,
+		ProtocolUnspec, "_ipp._tcp", "local", LookupUseMulticast)
+	if err != nil {
+		t.Fatalf("NewServiceBrowser: %s", err)
+	}
+
+	expected := []BrowserEvent{
+		BrowserNew, BrowserNew, BrowserCacheExhausted, BrowserAllForNow,
+	}
+
+	var items []*ServiceBrowserEvent
+	for _, e := range expected {
+		evnt := testPoll(ctx, t, clnt).(*ServiceBrowserEvent)
+		if evnt.Event != e || evnt.Browser != ipp {
+			t.Fatalf("browser event: expected %s, present %s",
+				e, evnt.Event)
+		}
+
+		if e == BrowserNew {
+			items = append(items, evnt)
+		}
+	}
+
+	if items[0].IfIdx != 2 || items[0].Proto != ProtocolIP4 ||
+		items[0].InstanceName != "Kyocera ECOSYS M2040dn" ||
+		items[0].SvcType != "_ipp._tcp" || items[0].Domain != "local" {
+		t.Errorf("ItemNew: %#v", items[0])
+	}
+
+	// Resolve instances
+	res4, err := clnt.NewServiceResolver(ctx, items[0].IfIdx,
+		items[0].Proto, items[0].InstanceName, items[0].SvcType,
+		items[0].Domain, items[0].Proto, 0)
+	if err != nil {
+		t.Fatalf("NewServiceResolver: %s", err)
+	}
+
+	found := testPoll(ctx, t, clnt).(*ServiceResolverEvent)
+	expectedFound := &ServiceResolverEvent{
+		Resolver:     res4,
+		Event:        ResolverFound,
+		IfIdx:        2,
+		Proto:        ProtocolIP4,
+		InstanceName: "Kyocera ECOSYS M2040dn",
+		SvcType:      "_ipp._tcp",
+		Domain:       "local",
+		Hostname:     "KM7B6A91.local",
+		Addr:         netip.MustParseAddr("192.168.1.102"),
+		Port:         631,
+		TXT: []string{
+			"txtvers=1",
+			"ty=Kyocera ECOSYS M2040dn",
+			"rp=ipp/print",
+			"UUID=4509a320-00a0-008f-00b6-002507510eca",
+		},
+		Flags: LookupResultMulticast,
+	}
+
+	if !reflect.DeepEqual(found, expectedFound) {
+		t.Errorf("resolver event:\nexpected: %#v\npresent:  %#v",
+			expectedFound, found)
+	}
+
+	_, err = clnt.NewServiceResolver(ctx, items[1].IfIdx,
+		items[1].Proto, items[1].InstanceName, items[1].SvcType,
+		items[1].Domain, items[1].Proto, 0)
+	if err != nil {
+		t.Fatalf("NewServiceResolver: %s", err)
+	}
+
+	failure := testPoll(ctx, t, clnt).(*ServiceResolverEvent)
+	if failure.Event != ResolverFailure ||
+		failure.Err.Error() != "Avahi: Timeout reached" {
+		t.Errorf("resolver event: %s %v", failure.Event, failure.Err)
+	}
+
+	// Browse for eSCL scanners. Browser fails.
+	_, err = clnt.NewServiceBrowser(ctx, IfIndexUnspec,
+		ProtocolUnspec, "_uscan._tcp", "local", LookupUseMulticast)
+	if err != nil {
+		t.Fatalf("NewServiceBrowser: %s", err)
+	}
+
+	bfailure := testPoll(ctx, t, clnt).(*ServiceBrowserEvent)
+	if bfailure.Event != BrowserFailure ||
+		bfailure.Err.Error() != "Avahi: Too many objects" {
+		t.Errorf("browser event: %s %v", bfailure.Event, bfailure.Err)
+	}
+
+	// Creation errors
+	_, err = clnt.NewServiceBrowser(ctx, IfIndexUnspec,
+		ProtocolUnspec, "_printer._tcp", "local", LookupUseMulticast)
+
+	var dberr *dbus.Error
+	if !errors.As(err, &dberr) ||
+		err.Error() != "Avahi: ServiceBrowserNew: "+
+			"org.freedesktop.Avahi.NotFoundError: Not found" {
+		t.Errorf("NewServiceBrowser: %v", err)
+	}
+
+	// Signals of the freed objects are dropped
+	res4.Close()
+	srv.Emit("/Client1/ServiceResolver3", resolverIface, "Failure",
+		"s", "late")
+	srv.Emit("/Client1/ServiceBrowser1", browserIface, "AllForNow", "")
+
+	evnt := testPoll(ctx, t, clnt).(*ServiceBrowserEvent)
+	if evnt.Browser != ipp || evnt.Event != BrowserAllForNow {
+		t.Errorf("browser event: %s", evnt.Event)
+	}
+
+	// Free must be called. Free is sent without waiting for reply,
+	// so make a round-trip to the bus to be sure it is received.
+	_, err = clnt.conn.NameHasOwner(ctx, ServiceName)
+	if err != nil {
+		t.Fatalf("NameHasOwner: %s", err)
+	}
+
+	var freed []dbus.ObjectPath
+	for _, call := range srv.Calls() {
+		if call.Member == "Free" {
+			freed = append(freed, call.Path)
+		}
+	}
+
+	if !reflect.DeepEqual(freed,
+		[]dbus.ObjectPath{"/Client1/ServiceResolver3"}) {
+		t.Errorf("Free: %q", freed)
+	}
+
+	// Connection loss must be reported
+	srv.Disconnect()
+	_, err = clnt.Poll(ctx)
+	if err == nil {
+		t.Errorf("Poll: error expected after disconnect")
+	}
+}
+
+// testPoll polls the next event from the Client
+func testPoll(ctx context.Context, t *testing.T, clnt *Client) any {
+	evnt, err := clnt.Poll(ctx)
+	if err != nil {
+		t.Fatalf("Poll: %s", err)
+	}
+	return evnt
+}
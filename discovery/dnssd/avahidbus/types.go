@@ -0,0 +1,117 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Avahi client over D-Bus
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Avahi data types
+
+package avahidbus
+
+import "fmt"
+
+// IfIndex is the network interface index
+type IfIndex int32
+
+// IfIndexUnspec means any network interface
+const IfIndexUnspec IfIndex = -1
+
+// Protocol is the network protocol
+type Protocol int32
+
+// Protocol values, as defined by Avahi:
+const (
+	ProtocolIP4    Protocol = 0  // IPv4
+	ProtocolIP6    Protocol = 1  // IPv6
+	ProtocolUnspec Protocol = -1 // Any protocol
+)
+
+// String returns name of the Protocol
+func (proto Protocol) String() string {
+	switch proto {
+	case ProtocolIP4:
+		return "ip4"
+	case ProtocolIP6:
+		return "ip6"
+	case ProtocolUnspec:
+		return "unspec"
+	}
+
+	return fmt.Sprintf("unknown(%d)", int32(proto))
+}
+
+// LookupFlags are the lookup flags, passed to the browsers
+// and resolvers
+type LookupFlags uint32
+
+// LookupFlags bits, as defined by Avahi:
+const (
+	LookupUseWideArea  LookupFlags = 1 << iota // Wide-area DNS
+	LookupUseMulticast                         // Multicast DNS
+	LookupNoTXT                                // Don't resolve TXT
+	LookupNoAddress                            // Don't resolve address
+)
+
+// LookupResultFlags are the flags, returned with lookup results
+type LookupResultFlags uint32
+
+// LookupResultFlags bits, as defined by Avahi:
+const (
+	LookupResultCached    LookupResultFlags = 1 << iota // From cache
+	LookupResultWideArea                                // Wide-area DNS
+	LookupResultMulticast                               // Multicast DNS
+	LookupResultLocal                                   // Local service
+	LookupResultOurOwn                                  // Own service
+	LookupResultStatic                                  // Static service
+)
+
+// BrowserEvent is the type of the browser event
+type BrowserEvent int
+
+// BrowserEvent values:
+const (
+	BrowserNew            BrowserEvent = iota // New object found
+	BrowserRemove                             // Object removed
+	BrowserCacheExhausted                     // Cache entries reported
+	BrowserAllForNow                          // No more objects for now
+	BrowserFailure                            // Browsing failed
+)
+
+// String returns name of the BrowserEvent
+func (e BrowserEvent) String() string {
+	switch e {
+	case BrowserNew:
+		return "new"
+	case BrowserRemove:
+		return "remove"
+	case BrowserCacheExhausted:
+		return "cache-exhausted"
+	case BrowserAllForNow:
+		return "all-for-now"
+	case BrowserFailure:
+		return "failure"
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(e))
+}
+
+// ResolverEvent is the type of the resolver event
+type ResolverEvent int
+
+// ResolverEvent values:
+const (
+	ResolverFound   ResolverEvent = iota // Object resolved
+	ResolverFailure                      // Resolving failed
+)
+
+// String returns name of the ResolverEvent
+func (e ResolverEvent) String() string {
+	switch e {
+	case ResolverFound:
+		return "found"
+	case ResolverFailure:
+		return "failure"
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(e))
+}
@@ -11,6 +11,7 @@ package dnssd
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/netip"
 	"sync"
 
@@ -30,6 +31,16 @@ type backend struct {
 }
 
 // NewBackend creates a new [discovery.Backend] for DNS-SD discovery.
+//
+// If avahi-daemon is present on the D-Bus system bus, it is queried
+// over D-Bus directly. Otherwise, or if D-Bus browsing fails or
+// times out, libavahi-client is used.
+//
+// If avahi is not available at all (for example, when running
+// unprivileged in a container), it falls back to the unicast
+// DNS-SD in the domain (or in the first search domain from
+// /etc/resolv.conf, if domain is "" or "local"). The returned
+// backend implements [discovery.DegradedBackend] in this case.
 func NewBackend(ctx context.Context,
 	domain string, flags LookupFlags) (discovery.Backend, error) {
 
@@ -43,6 +54,27 @@ func NewBackend(ctx context.Context,
 		return nil, err
 	}
 
+	// Prefer Avahi over D-Bus
+	back, err := newDBusBackend(ctx, domain, flags)
+	if err == nil {
+		return back, nil
+	}
+
+	log.Debug(ctx, "avahi-dbus: %s, using libavahi", err)
+	back, err = newAvahiBackend(ctx, domain, flags)
+	if err == nil {
+		return back, nil
+	}
+
+	dialer := &net.Dialer{}
+	return newUnicastBackend(ctx, domain, err, dialer.DialContext), nil
+}
+
+// newAvahiBackend creates a new [discovery.Backend] for DNS-SD
+// discovery, that uses libavahi-client.
+func newAvahiBackend(ctx context.Context,
+	domain string, flags LookupFlags) (discovery.Backend, error) {
+
 	// Create Avahi client.
 	clnt, err := newAvahiClient(domain, flags)
 	if err != nil {
@@ -333,7 +365,10 @@ func (back *backend) onAddrBrowserEvent(
 			addr = avahi.DNSDecodeA(evnt.RData)
 		} else {
 			addr = avahi.DNSDecodeAAAA(evnt.RData)
-			addr = addr.WithZone(zone.Name(int(evnt.IfIdx)))
+			if addr.IsLinkLocalUnicast() {
+				// Only link-local addresses need zone
+				addr = addr.WithZone(zone.Name(int(evnt.IfIdx)))
+			}
 		}
 
 		if addr == (netip.Addr{}) {
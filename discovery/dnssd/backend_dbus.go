@@ -0,0 +1,471 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// DNS-SD service discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Discovery Backend, that talks to Avahi over D-Bus
+
+package dnssd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/OpenPrinting/go-avahi"
+	"github.com/OpenPrinting/go-mfp/discovery"
+	"github.com/OpenPrinting/go-mfp/discovery/dnssd/avahidbus"
+	"github.com/OpenPrinting/go-mfp/internal/zone"
+	"github.com/OpenPrinting/go-mfp/log"
+)
+
+// Parameters:
+const (
+	// dbusConnectTimeout limits the time of connecting to the
+	// D-Bus system bus and checking for the avahi-daemon presence.
+	dbusConnectTimeout = 2 * time.Second
+
+	// dbusBrowseTimeout limits the time, the initial browsing
+	// must complete (all service browsers must report AllForNow).
+	// Otherwise, the backend falls back to libavahi.
+	dbusBrowseTimeout = 5 * time.Second
+)
+
+// errDBusBrowseTimeout is the browsing timeout error
+var errDBusBrowseTimeout = errors.New("browsing timed out")
+
+// dbusBackend is the [discovery.Backend] for DNS-SD discovery,
+// that queries avahi-daemon over D-Bus.
+//
+// On failure, it falls back to the libavahi backend.
+type dbusBackend struct {
+	ctx       context.Context                             // For logging and backend.Close
+	cancel    context.CancelFunc                          // Context's cancel function
+	domain    string                                      // Lookup domain
+	flags     LookupFlags                                 // Lookup flags
+	clnt      *avahidbus.Client                           // Avahi connection
+	queue     *discovery.Eventqueue                       // Output queue
+	browsing  map[*avahidbus.ServiceBrowser]struct{}      // Until AllForNow
+	services  map[avahiServiceKey]*dbusService            // Table of services
+	resolvers map[*avahidbus.ServiceResolver]*dbusService // By resolver
+	fallback  discovery.Backend                           // Fallback backend
+	done      sync.WaitGroup                              // For backend.Close synchronization
+}
+
+// dbusService is the per-service-instance structure of the
+// dbusBackend.
+//
+// Unlike libavahi backend, the D-Bus backend relies on the Avahi
+// service resolver to resolve port, TXT record and the address of
+// the service all at once.
+type dbusService struct {
+	key      avahiServiceKey            // Identity
+	resolver *avahidbus.ServiceResolver // Service resolver
+	port     uint16                     // IP port
+	addr     netip.Addr                 // IP address
+	units    map[string]*unit           // Discovered print/fax/scan units
+}
+
+// newDBusBackend creates a new dbusBackend.
+// It fails if avahi-daemon is not running.
+func newDBusBackend(ctx context.Context,
+	domain string, flags LookupFlags) (discovery.Backend, error) {
+
+	// Connect to Avahi
+	connCtx, connCancel := context.WithTimeout(ctx, dbusConnectTimeout)
+	clnt, err := avahidbus.NewClient(connCtx, "")
+	connCancel()
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug(ctx, "avahi-dbus: connected")
+
+	// Create cancelable context
+	ctx, cancel := context.WithCancel(ctx)
+
+	back := &dbusBackend{
+		ctx:       ctx,
+		cancel:    cancel,
+		domain:    domain,
+		flags:     flags,
+		clnt:      clnt,
+		browsing:  make(map[*avahidbus.ServiceBrowser]struct{}),
+		services:  make(map[avahiServiceKey]*dbusService),
+		resolvers: make(map[*avahidbus.ServiceResolver]*dbusService),
+	}
+
+	return back, nil
+}
+
+// Name returns backend name.
+func (back *dbusBackend) Name() string {
+	return "dnssd"
+}
+
+// Start starts Backend operations.
+func (back *dbusBackend) Start(queue *discovery.Eventqueue) {
+	back.queue = queue
+
+	back.done.Add(1)
+	go back.proc()
+
+	log.Debug(back.ctx, "backend started (avahi-dbus)")
+}
+
+// Close closes the backend
+func (back *dbusBackend) Close() {
+	back.cancel()
+	back.done.Wait()
+
+	if back.clnt != nil {
+		back.clnt.Close()
+	}
+
+	if back.fallback != nil {
+		back.fallback.Close()
+	}
+}
+
+// proc runs the backend event loop on its separate goroutine.
+func (back *dbusBackend) proc() {
+	defer back.done.Done()
+
+	err := back.startServiceBrowsers()
+	deadline := time.Now().Add(dbusBrowseTimeout)
+
+	for err == nil {
+		// Until initial browsing completes, poll with deadline
+		ctx := back.ctx
+		var cancel context.CancelFunc = func() {}
+		if len(back.browsing) != 0 {
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+		}
+
+		var evnt any
+		evnt, err = back.clnt.Poll(ctx)
+		cancel()
+
+		switch {
+		case back.ctx.Err() != nil:
+			return
+
+		case err == context.DeadlineExceeded:
+			err = errDBusBrowseTimeout
+
+		case err == nil:
+			switch evnt := evnt.(type) {
+			case *avahidbus.ServiceBrowserEvent:
+				err = back.onServiceBrowserEvent(evnt)
+			case *avahidbus.ServiceResolverEvent:
+				back.onServiceResolverEvent(evnt)
+			}
+		}
+	}
+
+	if back.ctx.Err() == nil {
+		back.fallbackStart(err)
+	}
+}
+
+// fallbackStart closes the Avahi D-Bus connection, deletes all
+// discovered services and starts the libavahi backend instead.
+func (back *dbusBackend) fallbackStart(err error) {
+	log.Warning(back.ctx, "avahi-dbus: %s, falling back to libavahi", err)
+
+	for _, service := range back.services {
+		back.delService(service)
+	}
+
+	back.clnt.Close()
+	back.clnt = nil
+
+	fallback, err := newAvahiBackend(back.ctx, back.domain, back.flags)
+	if err != nil {
+		log.Error(back.ctx, "%s", err)
+		return
+	}
+
+	back.fallback = fallback
+	fallback.Start(back.queue)
+}
+
+// startServiceBrowsers starts service browsers for all service
+// types mentioned in the svcTypes.
+func (back *dbusBackend) startServiceBrowsers() error {
+	for _, svctype := range svcTypes {
+		browser, err := back.clnt.NewServiceBrowser(back.ctx,
+			avahidbus.IfIndexUnspec,
+			avahidbus.ProtocolUnspec,
+			svctype,
+			back.domain,
+			dbusLookupFlags(back.flags))
+
+		title := fmt.Sprintf("svc-browse: start %q", svctype)
+
+		if err != nil {
+			log.Error(back.ctx, "%s: %s", title, err)
+			return err
+		}
+
+		back.browsing[browser] = struct{}{}
+		log.Debug(back.ctx, "%s: OK", title)
+	}
+
+	return nil
+}
+
+// onServiceBrowserEvent handles avahidbus.ServiceBrowserEvent
+func (back *dbusBackend) onServiceBrowserEvent(
+	evnt *avahidbus.ServiceBrowserEvent) error {
+
+	switch evnt.Event {
+	case avahidbus.BrowserNew:
+		key := dbusServiceKeyFromServiceBrowserEvent(evnt)
+		title := fmt.Sprintf("svc-browse: found %s", key)
+
+		if back.services[key] != nil {
+			log.Debug(back.ctx, "%s (duplicate)", title)
+			return nil
+		}
+
+		log.Debug(back.ctx, "%s", title)
+		return back.addService(key)
+
+	case avahidbus.BrowserRemove:
+		key := dbusServiceKeyFromServiceBrowserEvent(evnt)
+		title := fmt.Sprintf("svc-browse: removed %s", key)
+
+		service := back.services[key]
+		if service != nil {
+			log.Debug(back.ctx, "%s", title)
+			back.delService(service)
+		} else {
+			log.Debug(back.ctx, "%s (not found)", title)
+		}
+
+	case avahidbus.BrowserAllForNow:
+		delete(back.browsing, evnt.Browser)
+		if len(back.browsing) == 0 {
+			log.Debug(back.ctx, "svc-browse: initial browsing done")
+		}
+
+	case avahidbus.BrowserFailure:
+		log.Warning(back.ctx, "svc-browse: failed: %s", evnt.Err)
+		return evnt.Err
+	}
+
+	return nil
+}
+
+// onServiceResolverEvent handles avahidbus.ServiceResolverEvent
+func (back *dbusBackend) onServiceResolverEvent(
+	evnt *avahidbus.ServiceResolverEvent) {
+
+	service := back.resolvers[evnt.Resolver]
+	if service == nil {
+		// Late event of the already deleted service
+		return
+	}
+
+	switch evnt.Event {
+	case avahidbus.ResolverFound:
+		title := fmt.Sprintf("svc-resolve: found %s", service.key)
+
+		log.Begin(back.ctx).
+			Debug("%s:", title).
+			Debug("  host: %s", evnt.Hostname).
+			Debug("  addr: %s", evnt.Addr).
+			Debug("  port: %d", evnt.Port).
+			Commit()
+
+		back.setServiceTxt(service, evnt.TXT)
+		service.SetPort(evnt.Port)
+		service.SetAddr(dbusFilterAddr(service.key, evnt.Addr))
+
+	case avahidbus.ResolverFailure:
+		title := fmt.Sprintf("svc-resolve: failed  %s", service.key)
+
+		// Note, typically it's not fatal, just answer
+		// doesn't want to come in time.
+		log.Warning(back.ctx, "%s: %s", title, evnt.Err)
+	}
+}
+
+// addService creates a new dbusService and starts its resolving
+func (back *dbusBackend) addService(key avahiServiceKey) error {
+	resolver, err := back.clnt.NewServiceResolver(back.ctx,
+		avahidbus.IfIndex(key.IfIdx),
+		avahidbus.Protocol(key.Proto),
+		key.InstanceName,
+		key.SvcType,
+		key.Domain,
+		avahidbus.Protocol(key.Proto),
+		dbusLookupFlags(back.flags))
+
+	title := fmt.Sprintf("svc-resolve: start %s", key)
+
+	if err != nil {
+		log.Error(back.ctx, "%s: %s", title, err)
+		return err
+	}
+
+	log.Debug(back.ctx, "%s: OK", title)
+
+	service := &dbusService{
+		key:      key,
+		resolver: resolver,
+		units:    make(map[string]*unit),
+	}
+
+	back.services[key] = service
+	back.resolvers[resolver] = service
+
+	return nil
+}
+
+// delService deletes the dbusService
+func (back *dbusBackend) delService(service *dbusService) {
+	service.resolver.Close()
+
+	for name, un := range service.units {
+		delete(service.units, name)
+		un.Delete()
+	}
+
+	delete(back.services, service.key)
+	delete(back.resolvers, service.resolver)
+}
+
+// setServiceTxt creates or updates service units from the
+// resolved TXT record.
+func (back *dbusBackend) setServiceTxt(service *dbusService,
+	txt []string) {
+
+	key := service.key
+	title := fmt.Sprintf("txt-resolve: %s", key)
+
+	if key.IsPrinter() {
+		txtPrinter, err := decodeTxtPrinter(key.SvcType,
+			key.InstanceName, txt)
+		if err != nil {
+			log.Debug(back.ctx, "%s: %s", title, err)
+			return
+		}
+
+		id := key.PrinterUnitID(txtPrinter)
+		un := service.units[id.Queue]
+		if un == nil {
+			un = newPrinterUnit(back.queue, id, txtPrinter)
+			service.AddUnit(id.Queue, un)
+		} else {
+			un.SetTxtPrinter(txtPrinter)
+		}
+	} else {
+		txtScanner, err := decodeTxtScanner(key.SvcType,
+			key.InstanceName, txt)
+		if err != nil {
+			log.Debug(back.ctx, "%s: %s", title, err)
+			return
+		}
+
+		unName := "scan"
+		un := service.units[unName]
+		if un == nil {
+			id := key.ScannerUnitID(txtScanner)
+			un = newScannerUnit(back.queue, id, txtScanner)
+			service.AddUnit(unName, un)
+		} else {
+			un.SetTxtScanner(txtScanner)
+		}
+	}
+}
+
+// AddUnit adds unit to the service
+func (service *dbusService) AddUnit(name string, un *unit) {
+	service.units[name] = un
+	un.SetPort(service.port)
+
+	if service.addr.IsValid() {
+		un.AddAddr(service.addr)
+	}
+}
+
+// SetPort sets service port
+func (service *dbusService) SetPort(port uint16) {
+	if service.port == port {
+		return // Nothing changed
+	}
+
+	service.port = port
+	for _, un := range service.units {
+		un.SetPort(port)
+	}
+}
+
+// SetAddr sets service address. Invalid address means
+// no address.
+func (service *dbusService) SetAddr(addr netip.Addr) {
+	if service.addr == addr {
+		return // Nothing changed
+	}
+
+	for _, un := range service.units {
+		if service.addr.IsValid() {
+			un.DelAddr(service.addr)
+		}
+		if addr.IsValid() {
+			un.AddAddr(addr)
+		}
+	}
+
+	service.addr = addr
+}
+
+// dbusFilterAddr filters the resolved service address, the same way
+// as avahiHostname.AddAddr does:
+//   - if service belongs to the loopback interface, only loopback
+//     addresses are allowed
+//   - link-local addresses get zone of the service interface
+//
+// It returns the invalid netip.Addr, if address is filtered out.
+func dbusFilterAddr(key avahiServiceKey, addr netip.Addr) netip.Addr {
+	switch {
+	case addr.IsLoopback():
+		if key.IfIdx != loopback {
+			return netip.Addr{}
+		}
+
+	case addr.Is6() && addr.IsLinkLocalUnicast():
+		addr = addr.WithZone(zone.Name(int(key.IfIdx)))
+	}
+
+	return addr
+}
+
+// dbusServiceKeyFromServiceBrowserEvent makes avahiServiceKey
+// from the avahidbus.ServiceBrowserEvent.
+//
+// Note, avahidbus and libavahi use the same numeric values for
+// interface indices and protocols.
+func dbusServiceKeyFromServiceBrowserEvent(
+	evnt *avahidbus.ServiceBrowserEvent) avahiServiceKey {
+
+	return avahiServiceKey{
+		IfIdx:        avahi.IfIndex(evnt.IfIdx),
+		Proto:        avahi.Protocol(evnt.Proto),
+		InstanceName: evnt.InstanceName,
+		SvcType:      evnt.SvcType,
+		Domain:       evnt.Domain,
+	}
+}
+
+// dbusLookupFlags maps LookupFlags to avahidbus.LookupFlags
+func dbusLookupFlags(flags LookupFlags) avahidbus.LookupFlags {
+	return avahidbus.LookupFlags(avahiLookupFlags(flags))
+}
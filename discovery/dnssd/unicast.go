@@ -0,0 +1,680 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// DNS-SD service discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Unicast DNS-SD fallback backend
+
+package dnssd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/util/generic"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Parameters:
+const (
+	// unicastQueryTimeout limits the time of the single
+	// DNS query.
+	unicastQueryTimeout = 3 * time.Second
+
+	// unicastBrowseInterval is the interval between the
+	// subsequent browsing rounds. Unlike mDNS, unicast DNS
+	// doesn't notify us about changes, so we need to poll.
+	unicastBrowseInterval = 60 * time.Second
+)
+
+// unicastResolvConf is the path to the resolver configuration.
+// It is variable, so tests may override it.
+var unicastResolvConf = "/etc/resolv.conf"
+
+// Capabilities, reported by unicastBackend.Degraded:
+const (
+	unicastCapMDNS    = "mDNS (avahi-daemon)"
+	unicastCapUnicast = "unicast DNS-SD"
+)
+
+// unicastDialFunc creates the DNS client socket.
+type unicastDialFunc func(ctx context.Context,
+	network, address string) (net.Conn, error)
+
+// unicastBackend is the [discovery.Backend] for DNS-SD discovery,
+// that uses unicast DNS queries (RFC 6763, wide-area DNS-SD).
+//
+// It is used as a fallback, when avahi-daemon is not available
+// and multicast DNS cannot be used. Only services, registered
+// in the unicast DNS domain, can be discovered this way.
+type unicastBackend struct {
+	ctx      context.Context                       // For logging and backend.Close
+	cancel   context.CancelFunc                    // Context's cancel function
+	domain   string                                // Browse domain
+	servers  []string                              // DNS servers, host:port
+	dial     unicastDialFunc                       // Socket factory
+	degraded []discovery.Degradation               // Degraded capabilities
+	queue    *discovery.Eventqueue                 // Output queue
+	services map[unicastServiceKey]*unicastService // Table of services
+	done     sync.WaitGroup                        // For backend.Close synchronization
+}
+
+// unicastServiceKey identifies the unicast DNS-SD service instance.
+type unicastServiceKey struct {
+	InstanceName string // Service instance name
+	SvcType      string // Service type
+	Domain       string // Service domain
+}
+
+// unicastService is the per-service-instance structure of the
+// unicastBackend.
+type unicastService struct {
+	key   unicastServiceKey       // Identity
+	txt   []string                // Last seen TXT record
+	port  uint16                  // IP port
+	addrs generic.Set[netip.Addr] // IP addresses
+	units map[string]*unit        // Discovered print/fax/scan units
+}
+
+// newUnicastBackend creates a new unicastBackend.
+//
+// avahiErr is the error that prevented usage of avahi. It is
+// reported via unicastBackend.Degraded. The backend never fails;
+// if unicast DNS-SD is not usable either, it just discovers nothing
+// and reports that there is no fallback.
+func newUnicastBackend(ctx context.Context, domain string,
+	avahiErr error, dial unicastDialFunc) *unicastBackend {
+
+	// Create cancelable context
+	ctx, cancel := context.WithCancel(ctx)
+
+	back := &unicastBackend{
+		ctx:      ctx,
+		cancel:   cancel,
+		dial:     dial,
+		services: make(map[unicastServiceKey]*unicastService),
+	}
+
+	// Load resolver configuration and choose the domain.
+	// The "local" domain is served by mDNS only, so it is
+	// replaced with the first search domain.
+	servers, search, err := unicastReadResolvConf(unicastResolvConf)
+	if err == nil && len(servers) == 0 {
+		err = errors.New("no DNS servers configured")
+	}
+
+	domain = strings.TrimSuffix(domain, ".")
+	if domain == "" || domain == "local" {
+		domain = ""
+		if len(search) != 0 {
+			domain = search[0]
+		}
+	}
+
+	if err == nil && domain == "" {
+		err = errors.New("no DNS-SD domain configured")
+	}
+
+	// Check that we are allowed to create DNS sockets
+	if err == nil {
+		var conn net.Conn
+		conn, err = dial(ctx, "udp", servers[0])
+		if err == nil {
+			conn.Close()
+		}
+	}
+
+	if err != nil {
+		back.degraded = []discovery.Degradation{
+			{
+				Backend:    "dnssd",
+				Capability: unicastCapMDNS,
+				Err:        avahiErr,
+			},
+			{
+				Backend:    "dnssd",
+				Capability: unicastCapUnicast,
+				Err:        err,
+			},
+		}
+
+		log.Warning(ctx, "%s", back.degraded[0])
+		log.Warning(ctx, "%s", back.degraded[1])
+		return back
+	}
+
+	back.domain = domain
+	back.servers = servers
+	back.degraded = []discovery.Degradation{
+		{
+			Backend:    "dnssd",
+			Capability: unicastCapMDNS,
+			Fallback:   fmt.Sprintf("%s in %q", unicastCapUnicast, domain),
+			Err:        avahiErr,
+		},
+	}
+
+	log.Warning(ctx, "%s", back.degraded[0])
+	return back
+}
+
+// Name returns backend name.
+func (back *unicastBackend) Name() string {
+	return "dnssd"
+}
+
+// Start starts Backend operations.
+func (back *unicastBackend) Start(queue *discovery.Eventqueue) {
+	back.queue = queue
+
+	if back.domain != "" {
+		back.done.Add(1)
+		go back.proc()
+	}
+
+	log.Debug(back.ctx, "backend started (unicast)")
+}
+
+// Close closes the backend
+func (back *unicastBackend) Close() {
+	back.cancel()
+	back.done.Wait()
+}
+
+// Degraded returns capabilities of the backend, that are
+// not available.
+func (back *unicastBackend) Degraded() []discovery.Degradation {
+	return back.degraded
+}
+
+// proc runs the backend event loop on its separate goroutine.
+func (back *unicastBackend) proc() {
+	defer back.done.Done()
+
+	for {
+		back.browse()
+
+		select {
+		case <-back.ctx.Done():
+			return
+		case <-time.After(unicastBrowseInterval):
+		}
+	}
+}
+
+// browse performs a single browsing round.
+func (back *unicastBackend) browse() {
+	seen := generic.NewSet[unicastServiceKey]()
+
+	for _, svctype := range back.svcTypes() {
+		name := svctype + "." + back.domain + "."
+		title := fmt.Sprintf("svc-browse: %q", name)
+
+		answers, err := back.query(name, dnsmessage.TypePTR)
+		if err != nil {
+			// Keep previously discovered services until
+			// the next successful round.
+			log.Debug(back.ctx, "%s: %s", title, err)
+			for key := range back.services {
+				if key.SvcType == svctype {
+					seen.Add(key)
+				}
+			}
+			continue
+		}
+
+		for _, rr := range answers {
+			ptr, ok := rr.Body.(*dnsmessage.PTRResource)
+			if !ok {
+				continue
+			}
+
+			instance, ok := strings.CutSuffix(ptr.PTR.String(),
+				"."+name)
+			if !ok {
+				log.Debug(back.ctx, "%s: %s: foreign name",
+					title, ptr.PTR)
+				continue
+			}
+
+			key := unicastServiceKey{
+				InstanceName: instance,
+				SvcType:      svctype,
+				Domain:       back.domain,
+			}
+
+			seen.Add(key)
+			back.resolve(key)
+		}
+	}
+
+	// Drop disappeared services
+	for key, service := range back.services {
+		if !seen.Contains(key) {
+			log.Debug(back.ctx, "svc-browse: removed %s", key)
+			back.delService(service)
+		}
+	}
+}
+
+// svcTypes returns service types to browse. Service types,
+// enumerated by the "_services._dns-sd._udp" PTR records
+// (RFC 6763, 9), are used, if available. Otherwise, all
+// service types we are interested in are browsed.
+func (back *unicastBackend) svcTypes() []string {
+	name := "_services._dns-sd._udp." + back.domain + "."
+	answers, err := back.query(name, dnsmessage.TypePTR)
+	if err != nil || len(answers) == 0 {
+		return svcTypes
+	}
+
+	var types []string
+	for _, rr := range answers {
+		ptr, ok := rr.Body.(*dnsmessage.PTRResource)
+		if !ok {
+			continue
+		}
+
+		svctype, ok := strings.CutSuffix(ptr.PTR.String(),
+			"."+back.domain+".")
+		if ok && slices.Contains(svcTypes, svctype) {
+			types = append(types, svctype)
+		}
+	}
+
+	return types
+}
+
+// resolve resolves the service instance and creates or updates
+// the unicastService.
+func (back *unicastBackend) resolve(key unicastServiceKey) {
+	fqdn := key.FQDN()
+	title := fmt.Sprintf("svc-resolve: %s", key)
+
+	// Query SRV
+	answers, err := back.query(fqdn, dnsmessage.TypeSRV)
+	if err != nil {
+		log.Debug(back.ctx, "%s: %s", title, err)
+		return
+	}
+
+	var srv *dnsmessage.SRVResource
+	for _, rr := range answers {
+		if s, ok := rr.Body.(*dnsmessage.SRVResource); ok {
+			srv = s
+			break
+		}
+	}
+
+	if srv == nil {
+		log.Debug(back.ctx, "%s: SRV record missed", title)
+		return
+	}
+
+	// Query TXT
+	answers, err = back.query(fqdn, dnsmessage.TypeTXT)
+	if err != nil {
+		log.Debug(back.ctx, "%s: %s", title, err)
+		return
+	}
+
+	var txt []string
+	for _, rr := range answers {
+		if t, ok := rr.Body.(*dnsmessage.TXTResource); ok {
+			txt = append(txt, t.TXT...)
+		}
+	}
+
+	// Query addresses
+	addrs := generic.NewSet[netip.Addr]()
+	host := srv.Target.String()
+	for _, qtype := range []dnsmessage.Type{
+		dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+
+		answers, err = back.query(host, qtype)
+		if err != nil {
+			log.Debug(back.ctx, "%s: %s", title, err)
+			continue
+		}
+
+		for _, rr := range answers {
+			switch body := rr.Body.(type) {
+			case *dnsmessage.AResource:
+				addrs.Add(netip.AddrFrom4(body.A))
+			case *dnsmessage.AAAAResource:
+				addrs.Add(netip.AddrFrom16(body.AAAA))
+			}
+		}
+	}
+
+	log.Begin(back.ctx).
+		Debug("%s:", title).
+		Debug("  host: %s", host).
+		Debug("  port: %d", srv.Port).
+		Commit()
+
+	// Create or update the service
+	service := back.services[key]
+	if service == nil {
+		service = &unicastService{
+			key:   key,
+			addrs: generic.NewSet[netip.Addr](),
+			units: make(map[string]*unit),
+		}
+		back.services[key] = service
+	}
+
+	service.SetPort(srv.Port)
+	service.SetAddrs(addrs)
+
+	if service.txt == nil || !slices.Equal(service.txt, txt) {
+		service.txt = txt
+		back.setServiceTxt(service, txt)
+	}
+}
+
+// delService deletes the unicastService
+func (back *unicastBackend) delService(service *unicastService) {
+	for name, un := range service.units {
+		delete(service.units, name)
+		un.Delete()
+	}
+
+	delete(back.services, service.key)
+}
+
+// setServiceTxt creates or updates service units from the
+// resolved TXT record.
+func (back *unicastBackend) setServiceTxt(service *unicastService,
+	txt []string) {
+
+	key := service.key
+	title := fmt.Sprintf("txt-resolve: %s", key)
+
+	if key.IsPrinter() {
+		txtPrinter, err := decodeTxtPrinter(key.SvcType,
+			key.InstanceName, txt)
+		if err != nil {
+			log.Debug(back.ctx, "%s: %s", title, err)
+			return
+		}
+
+		id := key.PrinterUnitID(txtPrinter)
+		un := service.units[id.Queue]
+		if un == nil {
+			un = newPrinterUnit(back.queue, id, txtPrinter)
+			service.AddUnit(id.Queue, un)
+		} else {
+			un.SetTxtPrinter(txtPrinter)
+		}
+	} else {
+		txtScanner, err := decodeTxtScanner(key.SvcType,
+			key.InstanceName, txt)
+		if err != nil {
+			log.Debug(back.ctx, "%s: %s", title, err)
+			return
+		}
+
+		unName := "scan"
+		un := service.units[unName]
+		if un == nil {
+			id := key.ScannerUnitID(txtScanner)
+			un = newScannerUnit(back.queue, id, txtScanner)
+			service.AddUnit(unName, un)
+		} else {
+			un.SetTxtScanner(txtScanner)
+		}
+	}
+}
+
+// query performs the DNS query and returns answers. Servers are
+// tried in order, until one of them responds.
+//
+// Nonexistent name is not an error; it returns no answers.
+func (back *unicastBackend) query(name string,
+	qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uint16(rand.Uint32())
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               id,
+			RecursionDesired: true,
+		},
+		Questions: []dnsmessage.Question{
+			{
+				Name:  qname,
+				Type:  qtype,
+				Class: dnsmessage.ClassINET,
+			},
+		},
+	}
+
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, server := range back.servers {
+		ctx, cancel := context.WithTimeout(back.ctx,
+			unicastQueryTimeout)
+		var answers []dnsmessage.Resource
+		answers, err = back.exchange(ctx, server, packed, id)
+		cancel()
+
+		if err == nil {
+			return answers, nil
+		}
+	}
+
+	return nil, err
+}
+
+// exchange sends the packed DNS query to the server and
+// waits for the response.
+func (back *unicastBackend) exchange(ctx context.Context, server string,
+	packed []byte, id uint16) ([]dnsmessage.Resource, error) {
+
+	conn, err := back.dial(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	_, err = conn.Write(packed)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 65536)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		var rsp dnsmessage.Message
+		err = rsp.Unpack(buf[:n])
+		if err != nil || rsp.ID != id || !rsp.Response {
+			continue // Not our response; wait for more
+		}
+
+		switch rsp.RCode {
+		case dnsmessage.RCodeSuccess:
+			return rsp.Answers, nil
+		case dnsmessage.RCodeNameError:
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("%s: %s", server, rsp.RCode)
+	}
+}
+
+// AddUnit adds unit to the service
+func (service *unicastService) AddUnit(name string, un *unit) {
+	service.units[name] = un
+	un.SetPort(service.port)
+
+	service.addrs.ForEach(func(addr netip.Addr) {
+		un.AddAddr(addr)
+	})
+}
+
+// SetPort sets service port
+func (service *unicastService) SetPort(port uint16) {
+	if service.port == port {
+		return // Nothing changed
+	}
+
+	service.port = port
+	for _, un := range service.units {
+		un.SetPort(port)
+	}
+}
+
+// SetAddrs sets service addresses.
+func (service *unicastService) SetAddrs(addrs generic.Set[netip.Addr]) {
+	service.addrs.ForEach(func(addr netip.Addr) {
+		if !addrs.Contains(addr) {
+			service.addrs.Del(addr)
+			for _, un := range service.units {
+				un.DelAddr(addr)
+			}
+		}
+	})
+
+	addrs.ForEach(func(addr netip.Addr) {
+		if !service.addrs.Contains(addr) {
+			service.addrs.Add(addr)
+			for _, un := range service.units {
+				un.AddAddr(addr)
+			}
+		}
+	})
+}
+
+// FQDN returns a Fully Qualified Domain Name of the service
+// instance, with the trailing dot.
+func (key unicastServiceKey) FQDN() string {
+	return key.InstanceName + "." + key.SvcType + "." + key.Domain + "."
+}
+
+// String returns string representation of the unicastServiceKey,
+// for debugging.
+func (key unicastServiceKey) String() string {
+	return fmt.Sprintf("%q (unicast)", key.FQDN())
+}
+
+// commonUnitID fills parts of discovery.UnitID, common for
+// printers, scanners and faxout devices
+func (key unicastServiceKey) commonUnitID() discovery.UnitID {
+	variant := "unicast"
+
+	switch key.SvcType {
+	case svcTypeIPP, svcTypeESCL:
+		variant += "-http"
+	case svcTypeIPPS, svcTypeESCLS:
+		variant += "-https"
+	}
+
+	return discovery.UnitID{
+		DNSSDName: key.InstanceName,
+		Realm:     discovery.RealmDNSSD,
+		Variant:   variant,
+		SvcProto:  svcTypeToDiscoveryServiceProto(key.SvcType),
+	}
+}
+
+// PrinterUnitID makes discovery.UnitID for printer
+func (key unicastServiceKey) PrinterUnitID(txt txtPrinter) discovery.UnitID {
+	id := key.commonUnitID()
+
+	id.UUID = txt.uuid
+	id.Queue = txt.params.Queue
+	id.SvcType = discovery.ServicePrinter
+	id.USBSerial = txt.usbSerial
+	id.USBHWID = txt.usbHWID
+
+	return id
+}
+
+// ScannerUnitID makes discovery.UnitID for scanner
+func (key unicastServiceKey) ScannerUnitID(txt txtScanner) discovery.UnitID {
+	id := key.commonUnitID()
+
+	id.UUID = txt.uuid
+	id.SvcType = discovery.ServiceScanner
+	id.USBSerial = txt.usbSerial
+	id.USBHWID = txt.usbHWID
+
+	return id
+}
+
+// IsPrinter reports if service type is printer
+func (key unicastServiceKey) IsPrinter() bool {
+	return svcTypeIsPrinter(key.SvcType)
+}
+
+// unicastReadResolvConf reads the resolver configuration file
+// and returns DNS servers (as host:port) and search domains.
+func unicastReadResolvConf(path string) (servers, search []string,
+	err error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "nameserver":
+			addr, err := netip.ParseAddr(fields[1])
+			if err == nil {
+				ap := netip.AddrPortFrom(addr, 53)
+				servers = append(servers, ap.String())
+			}
+
+		case "domain", "search":
+			// The last of these keywords wins
+			search = search[:0]
+			for _, d := range fields[1:] {
+				search = append(search,
+					strings.TrimSuffix(d, "."))
+			}
+		}
+	}
+
+	return servers, search, scanner.Err()
+}
+
+var _ = discovery.DegradedBackend(&unicastBackend{})
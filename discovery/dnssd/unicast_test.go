@@ -0,0 +1,276 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// DNS-SD service discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Unicast DNS-SD fallback backend tests
+
+package dnssd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testUnicastServer is the fake DNS server.
+type testUnicastServer struct {
+	conn    net.PacketConn
+	lock    sync.Mutex
+	records []dnsmessage.Resource
+}
+
+// newTestUnicastServer starts the fake DNS server on the loopback
+// address, that serves the "example.com" DNS-SD domain with a single
+// IPP printer.
+func newTestUnicastServer(t *testing.T) *testUnicastServer {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	name := dnsmessage.MustNewName
+	hdr := func(n string, typ dnsmessage.Type) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{
+			Name:  name(n),
+			Type:  typ,
+			Class: dnsmessage.ClassINET,
+			TTL:   60,
+		}
+	}
+
+	srv := &testUnicastServer{
+		conn: conn,
+		records: []dnsmessage.Resource{
+			{
+				Header: hdr("_services._dns-sd._udp.example.com.",
+					dnsmessage.TypePTR),
+				Body: &dnsmessage.PTRResource{
+					PTR: name("_ipp._tcp.example.com."),
+				},
+			},
+			{
+				Header: hdr("_ipp._tcp.example.com.",
+					dnsmessage.TypePTR),
+				Body: &dnsmessage.PTRResource{
+					PTR: name("Printer._ipp._tcp.example.com."),
+				},
+			},
+			{
+				Header: hdr("Printer._ipp._tcp.example.com.",
+					dnsmessage.TypeSRV),
+				Body: &dnsmessage.SRVResource{
+					Target: name("printer.example.com."),
+					Port:   631,
+				},
+			},
+			{
+				Header: hdr("Printer._ipp._tcp.example.com.",
+					dnsmessage.TypeTXT),
+				Body: &dnsmessage.TXTResource{
+					TXT: []string{"txtvers=1", "rp=ipp/print",
+						"ty=Test Printer"},
+				},
+			},
+			{
+				Header: hdr("printer.example.com.",
+					dnsmessage.TypeA),
+				Body: &dnsmessage.AResource{
+					A: [4]byte{192, 0, 2, 10},
+				},
+			},
+		},
+	}
+
+	go srv.serve()
+	return srv
+}
+
+// Addr returns the server address.
+func (srv *testUnicastServer) Addr() string {
+	return srv.conn.LocalAddr().String()
+}
+
+// Close closes the server.
+func (srv *testUnicastServer) Close() {
+	srv.conn.Close()
+}
+
+// SetRecords replaces the served records.
+func (srv *testUnicastServer) SetRecords(records []dnsmessage.Resource) {
+	srv.lock.Lock()
+	srv.records = records
+	srv.lock.Unlock()
+}
+
+// serve serves the DNS queries
+func (srv *testUnicastServer) serve() {
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := srv.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		var msg dnsmessage.Message
+		if msg.Unpack(buf[:n]) != nil || len(msg.Questions) != 1 {
+			continue
+		}
+
+		q := msg.Questions[0]
+		msg.Response = true
+		msg.RCode = dnsmessage.RCodeNameError
+
+		srv.lock.Lock()
+		for _, rr := range srv.records {
+			if rr.Header.Name == q.Name {
+				msg.RCode = dnsmessage.RCodeSuccess
+				if rr.Header.Type == q.Type {
+					msg.Answers = append(msg.Answers, rr)
+				}
+			}
+		}
+		srv.lock.Unlock()
+
+		packed, err := msg.Pack()
+		if err == nil {
+			srv.conn.WriteTo(packed, from)
+		}
+	}
+}
+
+// testUnicastResolvConf creates the resolv.conf file in the
+// temporary directory and points unicastResolvConf to it.
+func testUnicastResolvConf(t *testing.T, content string) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	saved := unicastResolvConf
+	unicastResolvConf = path
+	t.Cleanup(func() { unicastResolvConf = saved })
+}
+
+// TestUnicastBackend tests unicast DNS-SD discovery
+func TestUnicastBackend(t *testing.T) {
+	srv := newTestUnicastServer(t)
+	defer srv.Close()
+
+	testUnicastResolvConf(t, "nameserver 127.0.0.1\nsearch example.com\n")
+
+	// Redirect queries to the fake server
+	dial := func(ctx context.Context,
+		network, address string) (net.Conn, error) {
+		dialer := &net.Dialer{}
+		return dialer.DialContext(ctx, network, srv.Addr())
+	}
+
+	avahiErr := errors.New("avahi-daemon not running")
+	back := newUnicastBackend(context.Background(), "local",
+		avahiErr, dial)
+	defer back.Close()
+
+	degraded := back.Degraded()
+	if len(degraded) != 1 {
+		t.Fatalf("expected 1 degradation, present %v", degraded)
+	}
+
+	expected := `dnssd: mDNS (avahi-daemon) not available ` +
+		`(avahi-daemon not running), ` +
+		`using unicast DNS-SD in "example.com"`
+	if s := degraded[0].String(); s != expected {
+		t.Errorf("degradation:\nexpected: %s\npresent:  %s",
+			expected, s)
+	}
+
+	// Browse and check discovered units
+	back.queue = discovery.NewEventqueue()
+	back.browse()
+
+	key := unicastServiceKey{
+		InstanceName: "Printer",
+		SvcType:      svcTypeIPP,
+		Domain:       "example.com",
+	}
+
+	service := back.services[key]
+	if service == nil {
+		t.Fatalf("%s: not discovered", key)
+	}
+
+	un := service.units["ipp/print"]
+	if un == nil {
+		t.Fatalf("%s: print unit missed", key)
+	}
+
+	if un.id.DNSSDName != "Printer" || un.id.Variant != "unicast-http" {
+		t.Errorf("unexpected unit ID: %v", un.id)
+	}
+
+	addr := netip.MustParseAddr("192.0.2.10")
+	if !un.addrs.Contains(addr) {
+		t.Fatalf("%s: address missed", addr)
+	}
+
+	endpoint := un.endpoint(addr)
+	if endpoint != "ipp://192.0.2.10/ipp/print" {
+		t.Errorf("endpoint: expected %q, present %q",
+			"ipp://192.0.2.10/ipp/print", endpoint)
+	}
+
+	// Disappeared services must be removed
+	srv.SetRecords(nil)
+	back.browse()
+
+	if len(back.services) != 0 {
+		t.Errorf("services not removed: %v", back.services)
+	}
+}
+
+// TestUnicastBackendNoSockets tests the unicast backend, that is
+// not allowed to create sockets.
+func TestUnicastBackendNoSockets(t *testing.T) {
+	testUnicastResolvConf(t, "nameserver 127.0.0.1\nsearch example.com\n")
+
+	dial := func(ctx context.Context,
+		network, address string) (net.Conn, error) {
+		return nil, &net.OpError{
+			Op:  "dial",
+			Net: network,
+			Err: os.NewSyscallError("socket", syscall.EPERM),
+		}
+	}
+
+	back := newUnicastBackend(context.Background(), "",
+		errors.New("avahi-daemon not running"), dial)
+
+	back.Start(discovery.NewEventqueue())
+	defer back.Close()
+
+	caps := make(map[string]discovery.Degradation)
+	for _, d := range back.Degraded() {
+		caps[d.Capability] = d
+	}
+
+	if d := caps[unicastCapMDNS]; d.Fallback != "" {
+		t.Errorf("%s: unexpected fallback %q", unicastCapMDNS,
+			d.Fallback)
+	}
+
+	if d := caps[unicastCapUnicast]; !errors.Is(d.Err, os.ErrPermission) {
+		t.Errorf("%s: expected EPERM, present %v", unicastCapUnicast,
+			d.Err)
+	}
+}
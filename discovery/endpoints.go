@@ -8,7 +8,56 @@
 
 package discovery
 
-import "sort"
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"sort"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// endpointNormalize normalizes the endpoint URL, received from the
+// backend for the unit with the specified UnitID.Zone.
+//
+// The IPv4-mapped IPv6 literal address is converted into IPv4 form
+// (see [transport.NormalizeURL]), so endpoints, advertised with the
+// mapped and plain addresses, become equal.
+//
+// The link-local IPv6 literal address is useless for clients without
+// zone, so if endpoint uses such address without zone, the zone is
+// added. This way, zoned and unzoned forms of the same address on the
+// same interface become equal. The zone is percent-encoded, as
+// RFC 6874 requires.
+//
+// Endpoints that cannot be parsed or don't need normalization
+// are returned as is.
+func endpointNormalize(endpoint, zone string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+
+	if u2 := transport.NormalizeURL(u); u2 != u {
+		u = u2
+		endpoint = u.String()
+	}
+
+	addr, err := netip.ParseAddr(u.Hostname())
+	if zone == "" || err != nil || !addr.Is6() ||
+		!addr.IsLinkLocalUnicast() || addr.Zone() != "" {
+		return endpoint
+	}
+
+	host := addr.WithZone(zone).String()
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else {
+		u.Host = "[" + host + "]"
+	}
+
+	return u.String()
+}
 
 // endpointsCmp compares two endpoint strings for sorting and searching.
 func endpointsCmp(e1, e2 string) int {
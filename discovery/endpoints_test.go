@@ -0,0 +1,130 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Functions for management collections of endpoints test
+
+package discovery
+
+import (
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// TestEndpointNormalize tests endpointNormalize
+func TestEndpointNormalize(t *testing.T) {
+	type testData struct {
+		endpoint, zone, out string
+	}
+
+	tests := []testData{
+		// Link-local IPv6 without zone: zone is added
+		{
+			endpoint: "http://[fe80::1]:8080/eSCL",
+			zone:     "eth0",
+			out:      "http://[fe80::1%25eth0]:8080/eSCL",
+		},
+		{
+			endpoint: "ipp://[fe80::1]/ipp/print",
+			zone:     "eth0",
+			out:      "ipp://[fe80::1%25eth0]/ipp/print",
+		},
+
+		// Already zoned, global, IPv4 and DNS names: unchanged
+		{
+			endpoint: "http://[fe80::1%25eth0]:8080/eSCL",
+			zone:     "eth0",
+			out:      "http://[fe80::1%25eth0]:8080/eSCL",
+		},
+		{
+			endpoint: "http://[2001:db8::1]:8080/eSCL",
+			zone:     "eth0",
+			out:      "http://[2001:db8::1]:8080/eSCL",
+		},
+		{
+			endpoint: "http://192.168.0.1:8080/eSCL",
+			zone:     "eth0",
+			out:      "http://192.168.0.1:8080/eSCL",
+		},
+		{
+			endpoint: "http://printer.local:8080/eSCL",
+			zone:     "eth0",
+			out:      "http://printer.local:8080/eSCL",
+		},
+
+		// No zone known: unchanged
+		{
+			endpoint: "http://[fe80::1]:8080/eSCL",
+			zone:     "",
+			out:      "http://[fe80::1]:8080/eSCL",
+		},
+
+		// IPv4-mapped: converted to IPv4, with and without zone
+		{
+			endpoint: "http://[::ffff:192.168.0.1]:8080/eSCL",
+			zone:     "eth0",
+			out:      "http://192.168.0.1:8080/eSCL",
+		},
+		{
+			endpoint: "ipp://[::ffff:192.168.0.1]/ipp/print",
+			zone:     "",
+			out:      "ipp://192.168.0.1/ipp/print",
+		},
+
+		// Unparseable: unchanged
+		{
+			endpoint: "%%%",
+			zone:     "eth0",
+			out:      "%%%",
+		},
+	}
+
+	for _, test := range tests {
+		out := endpointNormalize(test.endpoint, test.zone)
+		if out != test.out {
+			t.Errorf("%q, %q:\nexpected: %q\npresent:  %q",
+				test.endpoint, test.zone, test.out, out)
+		}
+	}
+}
+
+// TestEndpointDedupMapped tests that endpoints, that differ only
+// by the IPv4-mapped and plain address form, are deduplicated
+func TestEndpointDedupMapped(t *testing.T) {
+	c := newCache(0, 0, 0)
+
+	uid := UnitID{
+		DNSSDName: "Test Printer",
+		UUID:      uuid.Random(),
+		SvcType:   ServicePrinter,
+		SvcProto:  ServiceIPP,
+		Zone:      "eth0",
+	}
+
+	err := c.AddUnit(&EventAddUnit{ID: uid})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	err = c.AddEndpoint(&EventAddEndpoint{ID: uid,
+		Endpoint: "ipp://192.168.0.1/ipp/print"})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	err = c.AddEndpoint(&EventAddEndpoint{ID: uid,
+		Endpoint: "ipp://[::ffff:192.168.0.1]/ipp/print"})
+	if err == nil {
+		t.Errorf("mapped endpoint not recognized as duplicate")
+	}
+
+	// And the mapped form deletes the plain one
+	err = c.DelEndpoint(&EventDelEndpoint{ID: uid,
+		Endpoint: "ipp://[::ffff:192.168.0.1]/ipp/print"})
+	if err != nil {
+		t.Errorf("DelEndpoint: %s", err)
+	}
+}
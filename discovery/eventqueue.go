@@ -25,6 +25,7 @@ import (
 type Eventqueue struct {
 	events    []Event       // Events in the queue
 	readychan chan struct{} // Signaled when more events is available
+	pushed    uint64        // Total count of pushed events
 	lock      sync.Mutex    // Access lock
 }
 
@@ -40,6 +41,7 @@ func NewEventqueue() *Eventqueue {
 func (q *Eventqueue) Push(e Event) {
 	q.lock.Lock()
 	q.events = append(q.events, e)
+	q.pushed++
 	q.lock.Unlock()
 
 	select {
@@ -50,9 +52,19 @@ func (q *Eventqueue) Push(e Event) {
 
 // Count returns the current queue length.
 func (q *Eventqueue) Count() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
 	return len(q.events)
 }
 
+// pushedCount returns the total count of events, pushed into
+// the queue since its creation.
+func (q *Eventqueue) pushedCount() uint64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.pushed
+}
+
 // pull returns next event out of the queue.
 //
 // If queue is empty, it will wait until more events is available
@@ -0,0 +1,518 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Human-readable formatting of discovered devices
+
+package discovery
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/abstract"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// Verbosity defines how much details [Format] writes.
+type Verbosity int
+
+// Verbosity levels. Each level includes everything of the
+// previous levels.
+const (
+	// One line per device: name, make and model, protocols
+	VerbositySummary Verbosity = iota
+
+	// Names with their provenance and endpoints with the
+	// discovery backend they came from and their status.
+	VerbosityEndpoints
+
+	// Device identification, addresses and units parameters.
+	VerbosityAttributes
+)
+
+// FormatOptions contains options for [Format].
+type FormatOptions struct {
+	Verbosity Verbosity // Output verbosity
+
+	// Raw, if set, causes Format to write records of the
+	// individual discovery backends, as they were received,
+	// instead of the merged device view.
+	Raw bool
+}
+
+// Format writes human-readable description of devices into
+// the io.Writer.
+//
+// Devices are written in the stable order, sorted by name.
+//
+// Note, the endpoint status reflects the discovery staging only
+// (see [ModeSnapshot]); endpoints are not probed by the discovery.
+func Format(w io.Writer, devices []Device, opt FormatOptions) error {
+	f := &formatter{w: w, v: opt.Verbosity}
+
+	sorted := formatSorted(devices)
+	if len(sorted) == 0 {
+		f.printf(0, "No devices found.")
+	}
+
+	for i, dev := range sorted {
+		if i != 0 && f.v > VerbositySummary {
+			f.printf(0, "")
+		}
+
+		if opt.Raw {
+			f.records(dev)
+		} else {
+			f.device(dev)
+		}
+	}
+
+	return f.err
+}
+
+// formatter writes formatted devices into the io.Writer.
+type formatter struct {
+	w   io.Writer // Destination
+	v   Verbosity // Verbosity
+	err error     // Sticky write error
+}
+
+// formatUnit is the unit, as seen by the formatter.
+//
+// Both merged units of the Device and per-backend records are
+// converted to formatUnit, so they share the same formatting code.
+type formatUnit struct {
+	svc       ServiceType  // Unit service type
+	proto     ServiceProto // Unit protocol
+	params    any          // PrinterParameters or ScannerParameters
+	endpoints []string     // Unit endpoints
+}
+
+// printf writes a line with the specified indentation level.
+func (f *formatter) printf(indent int, format string, args ...any) {
+	if f.err == nil {
+		line := strings.Repeat("  ", indent) +
+			fmt.Sprintf(format, args...) + "\n"
+		_, f.err = io.WriteString(f.w, line)
+	}
+}
+
+// device writes the merged Device.
+func (f *formatter) device(dev *Device) {
+	units := dev.formatUnits()
+	f.printf(0, "%q %q %s", dev.formatName(), dev.MakeModel,
+		formatProtocols(units))
+
+	if f.v < VerbosityEndpoints {
+		return
+	}
+
+	f.printf(1, "%-13s%q %s", "Name:", dev.DNSSDName,
+		formatProvenance(dev.records, dev.DNSSDName,
+			func(un *unit) string { return un.ID.DNSSDName }))
+	f.printf(1, "%-13s%q %s", "MakeModel:", dev.MakeModel,
+		formatProvenance(dev.records, dev.MakeModel,
+			func(un *unit) string { return un.MakeModel }))
+
+	if f.v >= VerbosityAttributes {
+		f.attr(1, "UUID:", formatUUID(dev.DNSSDUUID))
+		f.attr(1, "Location:", dev.Location)
+		f.attr(1, "PPD:", strings.TrimSpace(
+			dev.PPDManufacturer+" "+dev.PPDModel))
+		f.attr(1, "USB Serial:", dev.USBSerial)
+		f.attr(1, "USB HWID:", dev.USBHWID)
+		f.attr(1, "Print Admin:", dev.PrintAdminURL)
+		f.attr(1, "Scan Admin:", dev.ScanAdminURL)
+		f.attr(1, "Fax Admin:", dev.FaxoutAdminURL)
+		f.attr(1, "Icon:", dev.IconURL)
+
+		addrs := make([]string, len(dev.Addrs))
+		for i, addr := range dev.Addrs {
+			addrs[i] = addr.String()
+		}
+		f.attr(1, "Addresses:", strings.Join(addrs, ", "))
+	}
+
+	for _, un := range units {
+		f.unit(1, un, dev.records)
+	}
+}
+
+// records writes per-backend records of the Device.
+func (f *formatter) records(dev *Device) {
+	records := make([]*unit, len(dev.records))
+	for i := range dev.records {
+		records[i] = &dev.records[i]
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return formatRecordLess(records[i], records[j])
+	})
+
+	for i, rec := range records {
+		if i != 0 && f.v > VerbositySummary {
+			f.printf(0, "")
+		}
+
+		un := rec.formatUnit()
+		f.printf(0, "%s: %q %q %s", rec.ID.Realm, rec.ID.DNSSDName,
+			rec.MakeModel, formatProtocols([]formatUnit{un}))
+
+		if f.v >= VerbosityAttributes {
+			f.attr(1, "UUID:", formatUUID(rec.ID.UUID))
+			f.attr(1, "Queue:", rec.ID.Queue)
+			f.attr(1, "Zone:", rec.ID.Zone)
+			f.attr(1, "Location:", rec.Location)
+			f.attr(1, "PPD:", strings.TrimSpace(
+				rec.PPDManufacturer+" "+rec.PPDModel))
+			f.attr(1, "USB Serial:", rec.ID.USBSerial)
+			f.attr(1, "USB HWID:", rec.ID.USBHWID)
+			f.attr(1, "Admin:", rec.AdminURL)
+			f.attr(1, "Icon:", rec.IconURL)
+		}
+
+		if f.v >= VerbosityEndpoints {
+			f.unit(1, un, []unit{*rec})
+		}
+	}
+}
+
+// unit writes the unit. Records are used to annotate its
+// endpoints with provenance and status.
+func (f *formatter) unit(indent int, un formatUnit, records []unit) {
+	if f.v < VerbosityEndpoints {
+		return
+	}
+
+	f.printf(indent, "%s %s:", un.proto, un.svc)
+	indent++
+
+	if f.v >= VerbosityAttributes {
+		switch p := un.params.(type) {
+		case PrinterParameters:
+			f.attr(indent, "Auth:", p.Auth.String())
+			if p.Paper != PaperUnknown {
+				f.attr(indent, "Paper Size:", p.Paper.String())
+			}
+			if p.Media != 0 {
+				f.attr(indent, "Media Type:", p.Media.String())
+			}
+			f.attr(indent, "Flags:", p.Flags())
+			f.attr(indent, "PSProduct:", p.PSProduct)
+			f.attr(indent, "PDL:", strings.Join(p.PDL, ","))
+			f.attr(indent, "Priority:", fmt.Sprint(p.Priority))
+
+		case ScannerParameters:
+			if p.Duplex != nil {
+				f.attr(indent, "Duplex:", fmt.Sprint(*p.Duplex))
+			}
+			if p.Sources != 0 {
+				f.attr(indent, "Sources:", p.Sources.String())
+			}
+			f.attr(indent, "ColorModes:", formatColorModes(p))
+			f.attr(indent, "PDL:", strings.Join(p.PDL, ","))
+		}
+	}
+
+	for _, ep := range un.endpoints {
+		f.printf(indent, "%-13s%s %s", "Endpoint:", ep,
+			formatEndpointStatus(records, un, ep))
+	}
+}
+
+// attr writes attribute, if its value is not empty.
+func (f *formatter) attr(indent int, name, value string) {
+	if value != "" {
+		f.printf(indent, "%-13s%s", name, value)
+	}
+}
+
+// formatName returns the Device name, for formatting.
+func (dev *Device) formatName() string {
+	if dev.DNSSDName != "" {
+		return dev.DNSSDName
+	}
+	return dev.MakeModel
+}
+
+// formatUnits returns all units of the Device as formatUnit-s.
+func (dev *Device) formatUnits() []formatUnit {
+	var units []formatUnit
+
+	for _, un := range dev.PrintUnits {
+		units = append(units, formatUnit{
+			ServicePrinter, un.Proto, un.Params, un.Endpoints})
+	}
+
+	for _, un := range dev.ScanUnits {
+		units = append(units, formatUnit{
+			ServiceScanner, un.Proto, un.Params, un.Endpoints})
+	}
+
+	for _, un := range dev.FaxoutUnits {
+		units = append(units, formatUnit{
+			ServiceFaxout, un.Proto, un.Params, un.Endpoints})
+	}
+
+	return units
+}
+
+// formatUnit returns the unit as formatUnit.
+func (un *unit) formatUnit() formatUnit {
+	return formatUnit{un.ID.SvcType, un.ID.SvcProto, un.Params,
+		un.Endpoints}
+}
+
+// formatSorted returns pointers to devices, sorted in the
+// stable order by formatDeviceLess.
+func formatSorted(devices []Device) []*Device {
+	sorted := make([]*Device, len(devices))
+	for i := range devices {
+		sorted[i] = &devices[i]
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return formatDeviceLess(sorted[i], sorted[j])
+	})
+
+	return sorted
+}
+
+// formatDeviceLess defines the sort order of devices.
+func formatDeviceLess(dev1, dev2 *Device) bool {
+	switch {
+	case dev1.formatName() != dev2.formatName():
+		return dev1.formatName() < dev2.formatName()
+	case dev1.MakeModel != dev2.MakeModel:
+		return dev1.MakeModel < dev2.MakeModel
+	}
+
+	return dev1.DNSSDUUID.String() < dev2.DNSSDUUID.String()
+}
+
+// formatRecordLess defines the sort order of per-backend records.
+func formatRecordLess(rec1, rec2 *unit) bool {
+	id1, id2 := rec1.ID, rec2.ID
+	switch {
+	case id1.Realm != id2.Realm:
+		return id1.Realm < id2.Realm
+	case id1.SvcType != id2.SvcType:
+		return id1.SvcType < id2.SvcType
+	case id1.SvcProto != id2.SvcProto:
+		return id1.SvcProto < id2.SvcProto
+	}
+
+	return id1.Queue < id2.Queue
+}
+
+// formatProtocols formats unit protocols as
+// "print=IPP,WSD scan=ESCL".
+func formatProtocols(units []formatUnit) string {
+	var s []string
+
+	for _, svc := range []ServiceType{
+		ServicePrinter, ServiceScanner, ServiceFaxout} {
+
+		var protos []string
+		for _, un := range units {
+			proto := un.proto.String()
+			if un.svc == svc && !formatContains(protos, proto) {
+				protos = append(protos, proto)
+			}
+		}
+
+		if len(protos) != 0 {
+			s = append(s, formatServiceNames[svc]+"="+
+				strings.Join(protos, ","))
+		}
+	}
+
+	if len(s) == 0 {
+		return "-"
+	}
+
+	return strings.Join(s, " ")
+}
+
+// formatServiceNames contains short names of ServiceType,
+// used by formatProtocols
+var formatServiceNames = map[ServiceType]string{
+	ServicePrinter: "print",
+	ServiceScanner: "scan",
+	ServiceFaxout:  "faxout",
+}
+
+// formatProvenance returns annotation of the Device attribute with
+// the backends it came from, in the "(dnssd, wsd)" form.
+//
+// If backends disagree, the annotation shows which values were
+// overridden by the chosen one, according to the precedence rules
+// of the device merging:
+//
+//	(dnssd; overrides "Other Name" from wsd)
+func formatProvenance(records []unit, chosen string,
+	get func(*unit) string) string {
+
+	if chosen == "" {
+		return "(none)"
+	}
+
+	realms, overrides := formatProvenanceData(records, chosen, get)
+	s := formatRealms(realms)
+
+	if len(overrides) != 0 {
+		ss := make([]string, len(overrides))
+		for i, ovr := range overrides {
+			ss[i] = fmt.Sprintf("%q from %s",
+				ovr.value, formatRealms(ovr.realms))
+		}
+
+		s += "; overrides " + strings.Join(ss, ", ")
+	}
+
+	return "(" + s + ")"
+}
+
+// formatOverride is the value of the Device attribute, overridden
+// by the chosen value, and the backends it came from.
+type formatOverride struct {
+	value  string
+	realms []SearchRealm
+}
+
+// formatProvenanceData returns backends, the chosen value of the
+// Device attribute came from, and the overridden values, if any,
+// sorted by value.
+func formatProvenanceData(records []unit, chosen string,
+	get func(*unit) string) ([]SearchRealm, []formatOverride) {
+
+	sources := make(map[string][]SearchRealm)
+	var values []string
+
+	for i := range records {
+		rec := &records[i]
+		val := get(rec)
+		if val == "" {
+			continue
+		}
+
+		realms, found := sources[val]
+		if !found {
+			values = append(values, val)
+		}
+
+		if !formatContains(realms, rec.ID.Realm) {
+			realms = append(realms, rec.ID.Realm)
+			sort.Slice(realms, func(i, j int) bool {
+				return realms[i] < realms[j]
+			})
+		}
+
+		sources[val] = realms
+	}
+
+	if chosen == "" {
+		return nil, nil
+	}
+
+	sort.Strings(values)
+	var overrides []formatOverride
+	for _, val := range values {
+		if val != chosen {
+			overrides = append(overrides,
+				formatOverride{val, sources[val]})
+		}
+	}
+
+	return sources[chosen], overrides
+}
+
+// formatEndpointStatus returns annotation of the endpoint with
+// the backends it came from and its status, in the "(dnssd, stable)"
+// form.
+func formatEndpointStatus(records []unit, un formatUnit,
+	endpoint string) string {
+
+	realms, status := formatEndpointData(records, un, endpoint)
+	return "(" + formatRealms(realms) + ", " + status + ")"
+}
+
+// formatEndpointData returns backends, the endpoint came from,
+// and its status, "stable" or "staging".
+func formatEndpointData(records []unit, un formatUnit,
+	endpoint string) ([]SearchRealm, string) {
+
+	var realms []SearchRealm
+	status := "stable"
+
+	for i := range records {
+		rec := &records[i]
+		if rec.ID.SvcType != un.svc || rec.ID.SvcProto != un.proto ||
+			!endpointsContain(rec.Endpoints, endpoint) {
+			continue
+		}
+
+		if !formatContains(realms, rec.ID.Realm) {
+			realms = append(realms, rec.ID.Realm)
+		}
+
+		if endpointsContain(rec.Staging, endpoint) {
+			status = "staging"
+		}
+	}
+
+	sort.Slice(realms, func(i, j int) bool {
+		return realms[i] < realms[j]
+	})
+
+	return realms, status
+}
+
+// formatRealms formats list of realms as comma-separated string.
+func formatRealms(realms []SearchRealm) string {
+	s := make([]string, len(realms))
+	for i, realm := range realms {
+		s[i] = realm.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// formatColorModes formats scanner color modes as "color,mono,bin".
+func formatColorModes(p ScannerParameters) string {
+	var modes []string
+
+	if p.Colors.Contains(abstract.ColorModeColor) {
+		modes = append(modes, "color")
+	}
+	if p.Colors.Contains(abstract.ColorModeMono) {
+		modes = append(modes, "mono")
+	}
+	if p.Colors.Contains(abstract.ColorModeBinary) {
+		modes = append(modes, "bin")
+	}
+
+	return strings.Join(modes, ",")
+}
+
+// formatUUID formats UUID, returning "" for uuid.NilUUID.
+func formatUUID(u uuid.UUID) string {
+	if u == uuid.NilUUID {
+		return ""
+	}
+	return u.String()
+}
+
+// formatContains reports if slice contains the value.
+func formatContains[T comparable](slice []T, v T) bool {
+	for _, v2 := range slice {
+		if v == v2 {
+			return true
+		}
+	}
+	return false
+}
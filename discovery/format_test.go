@@ -0,0 +1,348 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Devices formatting tests
+
+package discovery
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/abstract"
+	"github.com/OpenPrinting/go-mfp/util/generic"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// testFormatUpdate, if set, causes TestFormat to rewrite golden files
+var testFormatUpdate = flag.Bool("update-golden", false,
+	"update golden files of the Format test")
+
+// testFormatDir is the directory with the Format golden files
+const testFormatDir = "testdata/format"
+
+// testFormatUnits returns units, used as the Format test fixture.
+//
+// The fixture includes the device, seen by the DNS-SD and WSD
+// under the different names, so the precedence annotation is
+// generated, the USB device and the scanner with endpoints still
+// on staging.
+func testFormatUnits() []unit {
+	kyoceraUUID := uuid.MustParse("4509a320-00a0-008f-00b6-002507510eca")
+	hpUUID := uuid.MustParse("00000000-0000-1000-8000-0018fe2a9b3c")
+	canonUUID := uuid.MustParse("6d4ff0ce-6b11-11d8-8020-f48139a1f2c8")
+
+	return []unit{
+		// Kyocera, DNS-SD, IPP printer, IP4 and IP6 variants
+		{
+			ID: UnitID{
+				DNSSDName: "Kyocera ECOSYS M2040dn",
+				UUID:      kyoceraUUID,
+				Realm:     RealmDNSSD,
+				Variant:   "ip4-ipp",
+				SvcType:   ServicePrinter,
+				SvcProto:  ServiceIPP,
+			},
+			MakeModel: "Kyocera ECOSYS M2040dn",
+			Location:  "2nd Floor Computer Lab",
+			AdminURL:  "http://192.168.0.5/",
+			Params: PrinterParameters{
+				Auth:     AuthNone,
+				Paper:    PaperA4,
+				Media:    MediaOther,
+				Duplex:   optional.New(true),
+				Copies:   optional.New(true),
+				PDL:      []string{"application/pdf", "image/pwg-raster"},
+				Priority: 0,
+			},
+			Endpoints: []string{"ipp://192.168.0.5:631/ipp/print"},
+		},
+		{
+			ID: UnitID{
+				DNSSDName: "Kyocera ECOSYS M2040dn",
+				UUID:      kyoceraUUID,
+				Realm:     RealmDNSSD,
+				Variant:   "ip6-ipp",
+				SvcType:   ServicePrinter,
+				SvcProto:  ServiceIPP,
+			},
+			MakeModel: "Kyocera ECOSYS M2040dn",
+			Location:  "2nd Floor Computer Lab",
+			AdminURL:  "http://192.168.0.5/",
+			Params: PrinterParameters{
+				Auth:     AuthNone,
+				Paper:    PaperA4,
+				Media:    MediaOther,
+				Duplex:   optional.New(true),
+				Copies:   optional.New(true),
+				PDL:      []string{"application/pdf", "image/pwg-raster"},
+				Priority: 0,
+			},
+			Endpoints: []string{"ipp://[fe80::217:c8ff:fe7b:6a91%252]:631/ipp/print"},
+		},
+
+		// Kyocera, DNS-SD, eSCL scanner
+		{
+			ID: UnitID{
+				DNSSDName: "Kyocera ECOSYS M2040dn",
+				UUID:      kyoceraUUID,
+				Realm:     RealmDNSSD,
+				SvcType:   ServiceScanner,
+				SvcProto:  ServiceESCL,
+			},
+			MakeModel: "Kyocera ECOSYS M2040dn",
+			AdminURL:  "http://192.168.0.5/scan",
+			Params: ScannerParameters{
+				Duplex:  optional.New(true),
+				Sources: ScanPlaten | ScanADF,
+				Colors: generic.MakeBitset(
+					abstract.ColorModeColor,
+					abstract.ColorModeMono),
+				PDL: []string{"application/pdf", "image/jpeg"},
+			},
+			Endpoints: []string{"http://192.168.0.5:9095/eSCL"},
+		},
+
+		// Kyocera, WSD printer and scanner, under the
+		// different name
+		{
+			ID: UnitID{
+				UUID:     kyoceraUUID,
+				Realm:    RealmWSD,
+				SvcType:  ServicePrinter,
+				SvcProto: ServiceWSD,
+			},
+			MakeModel: "Kyocera M2040dn",
+			Params: PrinterParameters{
+				Auth:  AuthNone,
+				Media: MediaOther,
+			},
+			Endpoints: []string{"http://192.168.0.5:5358/wsd/print"},
+		},
+		{
+			ID: UnitID{
+				UUID:     kyoceraUUID,
+				Realm:    RealmWSD,
+				SvcType:  ServiceScanner,
+				SvcProto: ServiceWSD,
+			},
+			MakeModel: "Kyocera M2040dn",
+			Params: ScannerParameters{
+				Sources: ScanPlaten,
+			},
+			Endpoints: []string{"http://192.168.0.5:5358/wsd/scan"},
+		},
+
+		// HP, USB printer
+		{
+			ID: UnitID{
+				UUID:      hpUUID,
+				Realm:     RealmUSB,
+				SvcType:   ServicePrinter,
+				SvcProto:  ServiceUSB,
+				USBSerial: "CN1234567X",
+				USBHWID:   "03f0:5817",
+			},
+			MakeModel:       "HP LaserJet MFP M28w",
+			PPDManufacturer: "HP",
+			PPDModel:        "LaserJet MFP M28w",
+			Params: PrinterParameters{
+				Auth:  AuthNone,
+				Media: MediaOther,
+			},
+			Endpoints: []string{"usb://HP/LaserJet%20MFP%20M28w?serial=CN1234567X"},
+		},
+
+		// Canon, DNS-SD eSCL scanner, with the new endpoint on staging
+		{
+			ID: UnitID{
+				DNSSDName: "Canon MF410 Series",
+				UUID:      canonUUID,
+				Realm:     RealmDNSSD,
+				SvcType:   ServiceScanner,
+				SvcProto:  ServiceESCL,
+			},
+			MakeModel: "Canon MF410 Series",
+			Params: ScannerParameters{
+				Sources: ScanPlaten,
+				Colors: generic.MakeBitset(
+					abstract.ColorModeColor),
+			},
+			Endpoints: []string{
+				"http://192.168.0.7/eSCL",
+				"https://192.168.0.7/eSCL",
+			},
+			Staging: []string{"https://192.168.0.7/eSCL"},
+		},
+	}
+}
+
+// TestFormat tests Format against the golden files
+func TestFormat(t *testing.T) {
+	var out output
+	devices := out.Generate(time.Now().Add(time.Hour), testFormatUnits())
+
+	type testData struct {
+		golden string
+		opt    FormatOptions
+	}
+
+	tests := []testData{
+		{"summary.txt", FormatOptions{Verbosity: VerbositySummary}},
+		{"endpoints.txt", FormatOptions{Verbosity: VerbosityEndpoints}},
+		{"attributes.txt", FormatOptions{Verbosity: VerbosityAttributes}},
+		{"raw.txt", FormatOptions{Verbosity: VerbosityEndpoints, Raw: true}},
+	}
+
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		err := Format(buf, devices, test.opt)
+		if err != nil {
+			t.Errorf("%s: %s", test.golden, err)
+			continue
+		}
+
+		file := filepath.Join(testFormatDir, test.golden)
+		if *testFormatUpdate {
+			err = os.WriteFile(file, buf.Bytes(), 0644)
+			if err != nil {
+				t.Errorf("%s", err)
+			}
+			continue
+		}
+
+		expected, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Errorf("%s: output mismatch\nexpected:\n%s\npresent:\n%s",
+				test.golden, expected, buf.Bytes())
+		}
+	}
+}
+
+// TestFormatNoDevices tests Format with no devices
+func TestFormatNoDevices(t *testing.T) {
+	buf := &bytes.Buffer{}
+	Format(buf, nil, FormatOptions{})
+
+	if s := buf.String(); s != "No devices found.\n" {
+		t.Errorf("expected %q, present %q", "No devices found.\n", s)
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	var out output
+	devices := out.Generate(time.Now().Add(time.Hour), testFormatUnits())
+
+	buf := &bytes.Buffer{}
+	err := FormatJSON(buf, devices)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	file := filepath.Join(testFormatDir, "devices.json")
+	if *testFormatUpdate {
+		err = os.WriteFile(file, buf.Bytes(), 0644)
+		if err != nil {
+			t.Errorf("%s", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("output mismatch\nexpected:\n%s\npresent:\n%s",
+			expected, buf.Bytes())
+	}
+
+	// No devices
+	buf.Reset()
+	FormatJSON(buf, nil)
+
+	expected = []byte("{\n  \"format_version\": 1,\n  \"devices\": []\n}\n")
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("no devices: expected %q, present %q",
+			expected, buf.Bytes())
+	}
+}
+
+// TestFormatList tests FormatTable, FormatURIs, FormatCUPSCommands
+// and FormatAirscanConf against the golden files
+func TestFormatList(t *testing.T) {
+	var out output
+	devices := out.Generate(time.Now().Add(time.Hour), testFormatUnits())
+
+	type testData struct {
+		golden string
+		format func(io.Writer, []Device) error
+	}
+
+	tests := []testData{
+		{"table.txt", FormatTable},
+		{"uris.txt", FormatURIs},
+		{"cups-commands.txt", FormatCUPSCommands},
+		{"airscan.conf", FormatAirscanConf},
+	}
+
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		err := test.format(buf, devices)
+		if err != nil {
+			t.Errorf("%s: %s", test.golden, err)
+			continue
+		}
+
+		file := filepath.Join(testFormatDir, test.golden)
+		if *testFormatUpdate {
+			err = os.WriteFile(file, buf.Bytes(), 0644)
+			if err != nil {
+				t.Errorf("%s", err)
+			}
+			continue
+		}
+
+		expected, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Errorf("%s: output mismatch\nexpected:\n%s\npresent:\n%s",
+				test.golden, expected, buf.Bytes())
+		}
+	}
+}
+
+// TestFormatQueueName tests formatQueueName
+func TestFormatQueueName(t *testing.T) {
+	tests := map[string]string{
+		"Kyocera ECOSYS M2040dn":   "Kyocera_ECOSYS_M2040dn",
+		"HP LaserJet (M28w) #2":    "HP_LaserJet_M28w_2",
+		"  leading and trailing  ": "leading_and_trailing",
+		"///":                      "printer",
+	}
+
+	for in, expected := range tests {
+		if out := formatQueueName(in); out != expected {
+			t.Errorf("%q: expected %q, present %q", in, expected, out)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Machine-readable (JSON) formatting of discovered devices
+
+package discovery
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+)
+
+// JSONFormatVersion is the version of the output format, written
+// by the [FormatJSON]. It is incremented on breaking changes.
+//
+// The output format is described by the JSON Schema, returned by
+// the [JSONSchema].
+const JSONFormatVersion = 1
+
+// jsonOutput is the top-level object of the JSON output.
+type jsonOutput struct {
+	FormatVersion int          `json:"format_version"`
+	Devices       []jsonDevice `json:"devices"`
+}
+
+// jsonDevice is the Device, as represented in the JSON output.
+type jsonDevice struct {
+	LocalID             string         `json:"local_id"`
+	IdentityConflict    bool           `json:"identity_conflict"`
+	Name                string         `json:"name"`
+	NameProvenance      jsonProvenance `json:"name_provenance"`
+	MakeModel           string         `json:"make_model"`
+	MakeModelProvenance jsonProvenance `json:"make_model_provenance"`
+	UUID                string         `json:"uuid"`
+	Location            string         `json:"location"`
+	PPDManufacturer     string         `json:"ppd_manufacturer"`
+	PPDModel            string         `json:"ppd_model"`
+	USBSerial           string         `json:"usb_serial"`
+	USBHWID             string         `json:"usb_hwid"`
+	PrintAdminURL       string         `json:"print_admin_url"`
+	ScanAdminURL        string         `json:"scan_admin_url"`
+	FaxoutAdminURL      string         `json:"faxout_admin_url"`
+	IconURL             string         `json:"icon_url"`
+	Addrs               []string       `json:"addrs"`
+	Late                bool           `json:"late"`
+	Units               []jsonUnit     `json:"units"`
+}
+
+// jsonProvenance describes where the Device attribute came from.
+type jsonProvenance struct {
+	Sources   []string       `json:"sources"`
+	Overrides []jsonOverride `json:"overrides"`
+}
+
+// jsonOverride is the attribute value, overridden by the chosen one.
+type jsonOverride struct {
+	Value   string   `json:"value"`
+	Sources []string `json:"sources"`
+}
+
+// jsonUnit is the device unit, as represented in the JSON output.
+// Depending on the service type, either Printer or Scanner is set.
+type jsonUnit struct {
+	Service   string         `json:"service"`
+	Protocol  string         `json:"protocol"`
+	Printer   *jsonPrinter   `json:"printer"`
+	Scanner   *jsonScanner   `json:"scanner"`
+	Endpoints []jsonEndpoint `json:"endpoints"`
+}
+
+// jsonPrinter is the PrinterParameters, as represented in the
+// JSON output.
+type jsonPrinter struct {
+	Auth      []string `json:"auth"`
+	Paper     string   `json:"paper"`
+	Media     []string `json:"media"`
+	Flags     []string `json:"flags"`
+	PSProduct string   `json:"ps_product"`
+	PDL       []string `json:"pdl"`
+	Queue     string   `json:"queue"`
+	Priority  int      `json:"priority"`
+}
+
+// jsonScanner is the ScannerParameters, as represented in the
+// JSON output.
+type jsonScanner struct {
+	Duplex     *bool    `json:"duplex"`
+	Sources    []string `json:"sources"`
+	ColorModes []string `json:"color_modes"`
+	PDL        []string `json:"pdl"`
+}
+
+// jsonEndpoint is the unit endpoint with its provenance and status.
+type jsonEndpoint struct {
+	URL     string   `json:"url"`
+	Sources []string `json:"sources"`
+	Status  string   `json:"status"`
+}
+
+// FormatJSON writes machine-readable description of devices into
+// the io.Writer, in the JSON format.
+//
+// Devices are written in the same order, as by the [Format].
+func FormatJSON(w io.Writer, devices []Device) error {
+	sorted := make([]*Device, len(devices))
+	for i := range devices {
+		sorted[i] = &devices[i]
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return formatDeviceLess(sorted[i], sorted[j])
+	})
+
+	out := jsonOutput{
+		FormatVersion: JSONFormatVersion,
+		Devices:       make([]jsonDevice, len(sorted)),
+	}
+
+	for i, dev := range sorted {
+		out.Devices[i] = dev.jsonDevice()
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// jsonDevice returns the Device, as represented in the JSON output.
+func (dev *Device) jsonDevice() jsonDevice {
+	jdev := jsonDevice{
+		LocalID:          dev.LocalID,
+		IdentityConflict: dev.IdentityConflict,
+		Name:             dev.DNSSDName,
+		NameProvenance: jsonProvenanceOf(dev.records, dev.DNSSDName,
+			func(un *unit) string { return un.ID.DNSSDName }),
+		MakeModel: dev.MakeModel,
+		MakeModelProvenance: jsonProvenanceOf(dev.records,
+			dev.MakeModel,
+			func(un *unit) string { return un.MakeModel }),
+		UUID:            formatUUID(dev.DNSSDUUID),
+		Location:        dev.Location,
+		PPDManufacturer: dev.PPDManufacturer,
+		PPDModel:        dev.PPDModel,
+		USBSerial:       dev.USBSerial,
+		USBHWID:         dev.USBHWID,
+		PrintAdminURL:   dev.PrintAdminURL,
+		ScanAdminURL:    dev.ScanAdminURL,
+		FaxoutAdminURL:  dev.FaxoutAdminURL,
+		IconURL:         dev.IconURL,
+		Addrs:           make([]string, len(dev.Addrs)),
+		Late:            dev.Late,
+		Units:           []jsonUnit{},
+	}
+
+	for i, addr := range dev.Addrs {
+		jdev.Addrs[i] = addr.String()
+	}
+
+	for _, un := range dev.formatUnits() {
+		jdev.Units = append(jdev.Units, jsonUnitOf(un, dev.records))
+	}
+
+	return jdev
+}
+
+// jsonProvenanceOf returns provenance of the Device attribute.
+func jsonProvenanceOf(records []unit, chosen string,
+	get func(*unit) string) jsonProvenance {
+
+	realms, overrides := formatProvenanceData(records, chosen, get)
+
+	prov := jsonProvenance{
+		Sources:   jsonRealms(realms),
+		Overrides: make([]jsonOverride, len(overrides)),
+	}
+
+	for i, ovr := range overrides {
+		prov.Overrides[i] = jsonOverride{ovr.value,
+			jsonRealms(ovr.realms)}
+	}
+
+	return prov
+}
+
+// jsonUnitOf returns the unit, as represented in the JSON output.
+func jsonUnitOf(un formatUnit, records []unit) jsonUnit {
+	junit := jsonUnit{
+		Service:   un.svc.String(),
+		Protocol:  un.proto.String(),
+		Endpoints: make([]jsonEndpoint, len(un.endpoints)),
+	}
+
+	switch p := un.params.(type) {
+	case PrinterParameters:
+		junit.Printer = &jsonPrinter{
+			Auth:      jsonList(p.Auth.String()),
+			Paper:     p.Paper.String(),
+			Media:     jsonList(p.Media.String()),
+			Flags:     jsonList(p.Flags()),
+			PSProduct: p.PSProduct,
+			PDL:       jsonStrings(p.PDL),
+			Queue:     p.Queue,
+			Priority:  p.Priority,
+		}
+
+	case ScannerParameters:
+		junit.Scanner = &jsonScanner{
+			Duplex:     p.Duplex,
+			Sources:    jsonList(p.Sources.String()),
+			ColorModes: jsonList(formatColorModes(p)),
+			PDL:        jsonStrings(p.PDL),
+		}
+	}
+
+	for i, ep := range un.endpoints {
+		realms, status := formatEndpointData(records, un, ep)
+		junit.Endpoints[i] = jsonEndpoint{ep, jsonRealms(realms),
+			status}
+	}
+
+	return junit
+}
+
+// jsonRealms converts list of realms into list of strings.
+func jsonRealms(realms []SearchRealm) []string {
+	s := make([]string, len(realms))
+	for i, realm := range realms {
+		s[i] = realm.String()
+	}
+	return s
+}
+
+// jsonList splits comma-separated list, as returned by the String
+// methods of the bitmask types, into list of strings.
+func jsonList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+// jsonStrings returns non-nil copy of the slice of strings,
+// so it is encoded as [] rather than null.
+func jsonStrings(s []string) []string {
+	return append([]string{}, s...)
+}
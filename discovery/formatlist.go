@@ -0,0 +1,222 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Table, URI list and configuration output formats
+
+package discovery
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// FormatTable writes devices as the table with the header, one
+// line per device, with the following columns: name, make and
+// model, protocols and IP addresses.
+//
+// Devices are written in the same order as by [Format].
+func FormatTable(w io.Writer, devices []Device) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "NAME\tMAKE AND MODEL\tPROTOCOLS\tADDRESSES\n")
+	for _, dev := range formatSorted(devices) {
+		addrs := make([]string, len(dev.Addrs))
+		for i, addr := range dev.Addrs {
+			addrs[i] = addr.String()
+		}
+
+		s := strings.Join(addrs, ",")
+		if s == "" {
+			s = "-"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+			formatDash(dev.formatName()), formatDash(dev.MakeModel),
+			formatProtocols(dev.formatUnits()), s)
+	}
+
+	return tw.Flush()
+}
+
+// FormatURIs writes endpoint URIs of all units of all devices,
+// one URI per line.
+//
+// Devices are written in the same order as by [Format]; within
+// the device, URIs are ordered by unit type and protocol.
+func FormatURIs(w io.Writer, devices []Device) error {
+	var seen []string
+	for _, dev := range formatSorted(devices) {
+		for _, un := range dev.formatUnits() {
+			for _, ep := range un.endpoints {
+				if !formatContains(seen, ep) {
+					seen = append(seen, ep)
+				}
+			}
+		}
+	}
+
+	for _, ep := range seen {
+		if _, err := fmt.Fprintln(w, ep); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FormatCUPSCommands writes the lpadmin(8) commands that add IPP
+// printers to CUPS as driverless ("everywhere") queues.
+//
+// Queue names are made from device names. For printers without
+// IPP endpoint, that need a driver, the comment is written instead.
+func FormatCUPSCommands(w io.Writer, devices []Device) error {
+	for _, dev := range formatSorted(devices) {
+		if len(dev.PrintUnits) == 0 {
+			continue
+		}
+
+		name := dev.formatName()
+		uri := formatPreferredEndpoint(dev, ServicePrinter, ServiceIPP)
+
+		var err error
+		if uri != "" {
+			_, err = fmt.Fprintf(w, "lpadmin -p %s -E -v %s -m everywhere\n",
+				formatShellQuote(formatQueueName(name)),
+				formatShellQuote(uri))
+		} else {
+			_, err = fmt.Fprintf(w, "# %q: no IPP endpoint, "+
+				"driver required\n", name)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FormatAirscanConf writes the [devices] section of the
+// sane-airscan(5) configuration file for ESCL and WSD scanners.
+//
+// When the device supports both protocols, the WSD scanner
+// is written with the " (WSD)" suffix to keep names unique.
+func FormatAirscanConf(w io.Writer, devices []Device) error {
+	if _, err := fmt.Fprintln(w, "[devices]"); err != nil {
+		return err
+	}
+
+	for _, dev := range formatSorted(devices) {
+		name := strings.ReplaceAll(dev.formatName(), `"`, "'")
+
+		escl := formatPreferredEndpoint(dev, ServiceScanner, ServiceESCL)
+		wsd := formatPreferredEndpoint(dev, ServiceScanner, ServiceWSD)
+
+		var err error
+		if escl != "" {
+			_, err = fmt.Fprintf(w, "%q = %s, eSCL\n", name, escl)
+		}
+
+		if wsd != "" && err == nil {
+			wsdName := name
+			if escl != "" {
+				wsdName += " (WSD)"
+			}
+			_, err = fmt.Fprintf(w, "%q = %s, WSD\n", wsdName, wsd)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatPreferredEndpoint returns the preferred endpoint of the
+// first Device unit of the specified type and protocol, or "" if none.
+//
+// Stable endpoints are preferred over endpoints still on staging.
+// Then secure (https, ipps) endpoints are preferred, as well as IPv4
+// over IPv6, because IPv6 endpoints often use link-local addresses,
+// usable only on the particular host.
+func formatPreferredEndpoint(dev *Device,
+	svc ServiceType, proto ServiceProto) string {
+
+	for _, un := range dev.formatUnits() {
+		if un.svc != svc || un.proto != proto || len(un.endpoints) == 0 {
+			continue
+		}
+
+		best, bestScore := "", -1
+		for _, ep := range un.endpoints {
+			score := 0
+			if _, st := formatEndpointData(dev.records, un,
+				ep); st == "stable" {
+				score += 4
+			}
+			if strings.HasPrefix(ep, "https:") ||
+				strings.HasPrefix(ep, "ipps:") {
+				score += 2
+			}
+			if !strings.Contains(ep, "://[") {
+				score++
+			}
+
+			if score > bestScore {
+				best, bestScore = ep, score
+			}
+		}
+
+		return best
+	}
+
+	return ""
+}
+
+// formatQueueName makes CUPS queue name from the device name.
+//
+// Letters, digits and '-' are preserved; sequences of all other
+// characters are replaced with the single '_'.
+func formatQueueName(name string) string {
+	var b strings.Builder
+	sep := false
+
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z',
+			c >= '0' && c <= '9', c == '-':
+			if sep && b.Len() != 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(c)
+			sep = false
+		default:
+			sep = true
+		}
+	}
+
+	if b.Len() == 0 {
+		return "printer"
+	}
+
+	return b.String()
+}
+
+// formatShellQuote quotes string for the POSIX shell.
+func formatShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// formatDash returns s, or "-", if s is empty.
+func formatDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,307 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Stable local identity of discovered devices
+
+package discovery
+
+import (
+	"net/netip"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// Identity is the stable local identity of the discovered device.
+//
+// Devices change their IP addresses (DHCP) and sometimes even
+// their UUIDs (i.e., after firmware reset). Identity is assigned
+// to the device when it is seen for the first time, and then
+// re-associated with the device when it reappears with the
+// matching strong identifier (UUID, MAC address or serial number),
+// even if weaker ones have changed.
+//
+// So Identity.LocalID may be used as a stable key for the
+// per-device user preferences.
+type Identity struct {
+	LocalID   string           // Stable local ID, see Device.LocalID
+	UUIDs     []uuid.UUID      // All UUIDs seen
+	Serials   []string         // All serial numbers seen
+	MACAddrs  []string         // All MAC addresses seen
+	Names     []string         // All DNS-SD names seen
+	FirstSeen time.Time        // When device was seen the first time
+	LastSeen  time.Time        // When device was seen the last time
+	History   []IdentityRecord // Changes of addresses and UUIDs
+}
+
+// IdentityRecord is the entry of the [Identity] history.
+//
+// A new record is added each time the device reappears with
+// the different UUID or set of addresses. So the record describes
+// the device state, beginning with the record Time.
+type IdentityRecord struct {
+	Time  time.Time    // When change was detected
+	UUID  uuid.UUID    // Device UUID, uuid.NilUUID if n/a
+	Addrs []netip.Addr // Device addresses
+}
+
+// clone returns a deep copy of the Identity.
+func (ident *Identity) clone() *Identity {
+	clone := *ident
+	clone.UUIDs = slices.Clone(ident.UUIDs)
+	clone.Serials = slices.Clone(ident.Serials)
+	clone.MACAddrs = slices.Clone(ident.MACAddrs)
+	clone.Names = slices.Clone(ident.Names)
+	clone.History = make([]IdentityRecord, len(ident.History))
+	for i, rec := range ident.History {
+		rec.Addrs = slices.Clone(rec.Addrs)
+		clone.History[i] = rec
+	}
+
+	return &clone
+}
+
+// identityStore assigns identities to the discovered devices.
+//
+// Identities are kept after devices disappear, so the same
+// LocalID is assigned when device reappears.
+//
+// Note, identityStore API is not reentrant and requires external
+// locking.
+type identityStore struct {
+	idents []*Identity // All known identities
+}
+
+// Export returns copy of all known identities.
+func (store *identityStore) Export() []Identity {
+	idents := make([]Identity, len(store.idents))
+	for i, ident := range store.idents {
+		idents[i] = *ident.clone()
+	}
+	return idents
+}
+
+// Import replaces all known identities, i.e., with the previously
+// saved ones.
+func (store *identityStore) Import(idents []Identity) {
+	store.idents = make([]*Identity, len(idents))
+	for i := range idents {
+		store.idents[i] = idents[i].clone()
+	}
+}
+
+// Assign assigns identities to the live devices and sets
+// Device.LocalID and Device.IdentityConflict.
+//
+// Devices are matched against the known identities by the
+// strong identifiers in the order of their strength: UUID, MAC
+// address and serial number. Devices without strong identifiers
+// are matched by DNS-SD name and then by addresses.
+//
+// If the same MAC address or serial number is claimed by the
+// multiple live devices, they are not merged. Each device keeps its
+// own identity and marked with the IdentityConflict flag, and
+// the conflicting identifier is not used for matching.
+func (store *identityStore) Assign(devices []Device, now time.Time) {
+	// Process devices in the stable order, so new identities
+	// are assigned deterministically
+	order := make([]*Device, len(devices))
+	for i := range devices {
+		order[i] = &devices[i]
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return identityDeviceLess(order[i], order[j])
+	})
+
+	// Find conflicting identifiers
+	macs := make(map[string]int)
+	serials := make(map[string]int)
+	for _, dev := range order {
+		if dev.MACAddr != "" {
+			macs[dev.MACAddr]++
+		}
+		if dev.USBSerial != "" {
+			serials[dev.USBSerial]++
+		}
+	}
+
+	// Assign identities
+	claimed := make(map[*Identity]struct{})
+	for _, dev := range order {
+		dev.IdentityConflict = macs[dev.MACAddr] > 1 ||
+			serials[dev.USBSerial] > 1
+
+		ident := store.lookup(dev, claimed,
+			macs[dev.MACAddr] > 1, serials[dev.USBSerial] > 1)
+
+		if ident == nil {
+			ident = &Identity{
+				LocalID:   uuid.Random().String(),
+				FirstSeen: now,
+			}
+			store.idents = append(store.idents, ident)
+		}
+
+		claimed[ident] = struct{}{}
+		store.update(ident, dev, now)
+	}
+}
+
+// lookup returns the known identity of the device, not claimed
+// yet by other live device, or nil if not found.
+//
+// Conflicting MAC address and serial number are not used for lookup.
+func (store *identityStore) lookup(dev *Device,
+	claimed map[*Identity]struct{},
+	macConflict, serialConflict bool) *Identity {
+
+	match := func(matches func(*Identity) bool) *Identity {
+		// If several identities match, prefer the most
+		// recently seen.
+		var found *Identity
+		for _, ident := range store.idents {
+			if _, busy := claimed[ident]; busy || !matches(ident) {
+				continue
+			}
+
+			if found == nil || ident.LastSeen.After(found.LastSeen) {
+				found = ident
+			}
+		}
+		return found
+	}
+
+	strong := false
+
+	if dev.DNSSDUUID != uuid.NilUUID {
+		strong = true
+		ident := match(func(ident *Identity) bool {
+			return slices.Contains(ident.UUIDs, dev.DNSSDUUID)
+		})
+		if ident != nil {
+			return ident
+		}
+	}
+
+	if dev.MACAddr != "" && !macConflict {
+		strong = true
+		ident := match(func(ident *Identity) bool {
+			return slices.Contains(ident.MACAddrs, dev.MACAddr)
+		})
+		if ident != nil {
+			return ident
+		}
+	}
+
+	if dev.USBSerial != "" && !serialConflict {
+		strong = true
+		ident := match(func(ident *Identity) bool {
+			return slices.Contains(ident.Serials, dev.USBSerial)
+		})
+		if ident != nil {
+			return ident
+		}
+	}
+
+	// Weak identifiers are used only for devices without the
+	// strong ones, and only match identities without the strong
+	// identifiers.
+	if strong || dev.IdentityConflict {
+		return nil
+	}
+
+	weak := func(ident *Identity) bool {
+		return len(ident.UUIDs) == 0 && len(ident.MACAddrs) == 0 &&
+			len(ident.Serials) == 0
+	}
+
+	if dev.DNSSDName != "" {
+		ident := match(func(ident *Identity) bool {
+			return weak(ident) &&
+				slices.Contains(ident.Names, dev.DNSSDName)
+		})
+		if ident != nil {
+			return ident
+		}
+	}
+
+	return match(func(ident *Identity) bool {
+		if !weak(ident) || len(ident.History) == 0 {
+			return false
+		}
+		last := ident.History[len(ident.History)-1]
+		return addrsOverlap(last.Addrs, dev.Addrs)
+	})
+}
+
+// update updates identity from the device, seen at the specified
+// time, and assigns Device.LocalID.
+func (store *identityStore) update(ident *Identity, dev *Device,
+	now time.Time) {
+
+	if dev.DNSSDUUID != uuid.NilUUID &&
+		!slices.Contains(ident.UUIDs, dev.DNSSDUUID) {
+		ident.UUIDs = append(ident.UUIDs, dev.DNSSDUUID)
+	}
+
+	if dev.MACAddr != "" && !slices.Contains(ident.MACAddrs, dev.MACAddr) {
+		ident.MACAddrs = append(ident.MACAddrs, dev.MACAddr)
+	}
+
+	if dev.USBSerial != "" &&
+		!slices.Contains(ident.Serials, dev.USBSerial) {
+		ident.Serials = append(ident.Serials, dev.USBSerial)
+	}
+
+	if dev.DNSSDName != "" && !slices.Contains(ident.Names, dev.DNSSDName) {
+		ident.Names = append(ident.Names, dev.DNSSDName)
+	}
+
+	// Record changes of UUID and addresses
+	changed := true
+	if l := len(ident.History); l != 0 {
+		last := ident.History[l-1]
+		changed = last.UUID != dev.DNSSDUUID ||
+			!slices.Equal(last.Addrs, dev.Addrs)
+	}
+
+	if changed {
+		rec := IdentityRecord{
+			Time:  now,
+			UUID:  dev.DNSSDUUID,
+			Addrs: slices.Clone(dev.Addrs),
+		}
+		ident.History = append(ident.History, rec)
+	}
+
+	ident.LastSeen = now
+	dev.LocalID = ident.LocalID
+}
+
+// identityDeviceLess defines the order in which devices are
+// processed by the identityStore.Assign.
+func identityDeviceLess(dev1, dev2 *Device) bool {
+	if c := slices.Compare(dev1.DNSSDUUID[:], dev2.DNSSDUUID[:]); c != 0 {
+		return c < 0
+	}
+
+	switch {
+	case dev1.MACAddr != dev2.MACAddr:
+		return dev1.MACAddr < dev2.MACAddr
+	case dev1.USBSerial != dev2.USBSerial:
+		return dev1.USBSerial < dev2.USBSerial
+	case dev1.DNSSDName != dev2.DNSSDName:
+		return dev1.DNSSDName < dev2.DNSSDName
+	}
+
+	return slices.CompareFunc(dev1.Addrs, dev2.Addrs,
+		func(a1, a2 netip.Addr) int {
+			return a1.Compare(a2)
+		}) < 0
+}
@@ -0,0 +1,251 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Stable local identity tests
+
+package discovery
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// testIdentityAddUnit adds the print unit with the single endpoint
+// to the cache.
+func testIdentityAddUnit(t *testing.T, c *cache, uid UnitID,
+	endpoint string) {
+
+	t.Helper()
+
+	err := c.AddUnit(&EventAddUnit{ID: uid})
+	if err == nil {
+		err = c.SetPrinterParameters(&EventPrinterParameters{
+			ID:        uid,
+			MakeModel: "Test Printer",
+		})
+	}
+	if err == nil {
+		err = c.AddEndpoint(&EventAddEndpoint{ID: uid,
+			Endpoint: endpoint})
+	}
+
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+}
+
+// testIdentitySnapshot takes the cache snapshot and checks that
+// it contains expected count of devices.
+func testIdentitySnapshot(t *testing.T, c *cache, count int) []Device {
+	t.Helper()
+
+	devices := c.Snapshot()
+	if len(devices) != count {
+		t.Fatalf("expected %d devices, present %d", count, len(devices))
+	}
+
+	for _, dev := range devices {
+		if dev.LocalID == "" {
+			t.Fatalf("LocalID not assigned")
+		}
+	}
+
+	return devices
+}
+
+// TestIdentityAddrChange tests re-association of the device,
+// that has changed its address (i.e., by DHCP).
+func TestIdentityAddrChange(t *testing.T) {
+	c := newCache(0, 0, 0)
+
+	uid := UnitID{
+		DNSSDName: "Test Printer",
+		UUID:      uuid.Random(),
+		SvcType:   ServicePrinter,
+		SvcProto:  ServiceIPP,
+	}
+
+	testIdentityAddUnit(t, c, uid, "http://192.168.0.10:631/ipp/print")
+	localID := testIdentitySnapshot(t, c, 1)[0].LocalID
+
+	// Device disappears and reappears with the new address
+	c.DelUnit(&EventDelUnit{ID: uid})
+	testIdentitySnapshot(t, c, 0)
+
+	testIdentityAddUnit(t, c, uid, "http://192.168.0.20:631/ipp/print")
+	dev := testIdentitySnapshot(t, c, 1)[0]
+
+	if dev.LocalID != localID {
+		t.Errorf("LocalID changed: %s->%s", localID, dev.LocalID)
+	}
+
+	if dev.IdentityConflict {
+		t.Errorf("unexpected IdentityConflict")
+	}
+
+	// Check history
+	idents := c.Identities()
+	if len(idents) != 1 {
+		t.Fatalf("expected 1 identity, present %d", len(idents))
+	}
+
+	history := idents[0].History
+	expected := [][]netip.Addr{
+		{netip.MustParseAddr("192.168.0.10")},
+		{netip.MustParseAddr("192.168.0.20")},
+	}
+
+	if len(history) != len(expected) {
+		t.Fatalf("expected %d history records, present %d",
+			len(expected), len(history))
+	}
+
+	for i := range history {
+		if !slices.Equal(history[i].Addrs, expected[i]) {
+			t.Errorf("history[%d]: addrs expected %v, present %v",
+				i, expected[i], history[i].Addrs)
+		}
+	}
+
+	if history[1].Time.Before(history[0].Time) {
+		t.Errorf("history timestamps out of order")
+	}
+
+	// Identities must survive Client restart
+	c2 := newCache(0, 0, 0)
+	c2.SetIdentities(idents)
+	testIdentityAddUnit(t, c2, uid, "http://192.168.0.30:631/ipp/print")
+	dev = testIdentitySnapshot(t, c2, 1)[0]
+
+	if dev.LocalID != localID {
+		t.Errorf("LocalID not restored: %s->%s", localID, dev.LocalID)
+	}
+}
+
+// TestIdentityUUIDChange tests re-association of the device,
+// that has changed its UUID but kept the serial number.
+func TestIdentityUUIDChange(t *testing.T) {
+	c := newCache(0, 0, 0)
+
+	uid1 := UnitID{
+		DNSSDName: "Test Printer",
+		UUID:      uuid.Random(),
+		SvcType:   ServicePrinter,
+		SvcProto:  ServiceIPP,
+		USBSerial: "CN1234567X",
+	}
+
+	uid2 := uid1
+	uid2.UUID = uuid.Random()
+
+	testIdentityAddUnit(t, c, uid1, "http://192.168.0.10:631/ipp/print")
+	localID := testIdentitySnapshot(t, c, 1)[0].LocalID
+
+	// Firmware reset: device reappears with the new UUID
+	c.DelUnit(&EventDelUnit{ID: uid1})
+	testIdentityAddUnit(t, c, uid2, "http://192.168.0.10:631/ipp/print")
+	dev := testIdentitySnapshot(t, c, 1)[0]
+
+	if dev.LocalID != localID {
+		t.Errorf("LocalID changed: %s->%s", localID, dev.LocalID)
+	}
+
+	// Check history
+	idents := c.Identities()
+	if len(idents) != 1 {
+		t.Fatalf("expected 1 identity, present %d", len(idents))
+	}
+
+	ident := idents[0]
+	if !slices.Equal(ident.UUIDs, []uuid.UUID{uid1.UUID, uid2.UUID}) {
+		t.Errorf("UUIDs: expected %v, present %v",
+			[]uuid.UUID{uid1.UUID, uid2.UUID}, ident.UUIDs)
+	}
+
+	if len(ident.History) != 2 ||
+		ident.History[0].UUID != uid1.UUID ||
+		ident.History[1].UUID != uid2.UUID {
+		t.Errorf("UUID change not recorded in history: %v",
+			ident.History)
+	}
+
+	// The old UUID must not be confused by the different
+	// device without serial number.
+	uid3 := uid1
+	uid3.DNSSDName = "Other Printer"
+	uid3.UUID = uuid.Random()
+	uid3.USBSerial = ""
+
+	testIdentityAddUnit(t, c, uid3, "http://192.168.0.11:631/ipp/print")
+	devices := testIdentitySnapshot(t, c, 2)
+
+	if devices[0].LocalID == devices[1].LocalID {
+		t.Errorf("different devices share LocalID %s",
+			devices[0].LocalID)
+	}
+}
+
+// TestIdentityConflict tests two live devices, that claim the
+// same serial number.
+func TestIdentityConflict(t *testing.T) {
+	c := newCache(0, 0, 0)
+
+	uid1 := UnitID{
+		DNSSDName: "Test Printer 1",
+		UUID:      uuid.Random(),
+		SvcType:   ServicePrinter,
+		SvcProto:  ServiceIPP,
+		USBSerial: "CN1234567X",
+	}
+
+	uid2 := uid1
+	uid2.DNSSDName = "Test Printer 2"
+	uid2.UUID = uuid.Random()
+
+	testIdentityAddUnit(t, c, uid1, "http://192.168.0.10:631/ipp/print")
+	testIdentityAddUnit(t, c, uid2, "http://192.168.0.11:631/ipp/print")
+
+	devices := testIdentitySnapshot(t, c, 2)
+	ids := make(map[uuid.UUID]string)
+
+	for _, dev := range devices {
+		if !dev.IdentityConflict {
+			t.Errorf("%s: IdentityConflict not set", dev.DNSSDName)
+		}
+		ids[dev.DNSSDUUID] = dev.LocalID
+	}
+
+	if ids[uid1.UUID] == ids[uid2.UUID] {
+		t.Errorf("conflicting devices merged into %s", ids[uid1.UUID])
+	}
+
+	if n := len(c.Identities()); n != 2 {
+		t.Errorf("expected 2 identities, present %d", n)
+	}
+
+	// LocalIDs must be stable
+	for _, dev := range testIdentitySnapshot(t, c, 2) {
+		if ids[dev.DNSSDUUID] != dev.LocalID {
+			t.Errorf("%s: LocalID changed: %s->%s", dev.DNSSDName,
+				ids[dev.DNSSDUUID], dev.LocalID)
+		}
+	}
+
+	// When one device disappears, conflict is resolved
+	c.DelUnit(&EventDelUnit{ID: uid2})
+	dev := testIdentitySnapshot(t, c, 1)[0]
+
+	if dev.IdentityConflict {
+		t.Errorf("IdentityConflict not cleared")
+	}
+
+	if dev.LocalID != ids[uid1.UUID] {
+		t.Errorf("LocalID changed: %s->%s", ids[uid1.UUID], dev.LocalID)
+	}
+}
@@ -0,0 +1,41 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device search query language
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Package documentation
+
+/*
+Package query implements a small query language for filtering
+discovered devices.
+
+Query consist of terms, combined with the AND, OR and NOT boolean
+operators and parentheses. NOT has the highest precedence, then
+AND, then OR. Operators are case-insensitive:
+
+	make:HP AND (protocol:escl OR protocol:wsd) AND NOT installed
+
+Each term has the field:value form. The following fields are
+supported:
+
+	make       device manufacturer
+	model      device model (matches both MakeModel and PPD model)
+	name       DNS-SD name of the device
+	uuid       device UUID
+	protocol   any of device protocols (ipp, escl, lpd, appsocket,
+	           wsd, usb)
+	address    any of device IP addresses; value is either
+	           address or CIDR prefix (e.g., 192.168.1.0/24)
+	installed  device is installed
+	verified   device is verified
+
+String values are compared case-insensitively. The '*' within the
+value matches any sequence of characters, so "model:*LaserJet*" is
+a substring match. Values with spaces or parentheses need to be
+quoted: name:"Kyocera ECOSYS M2040dn".
+
+Boolean fields (installed and verified) may be used either alone,
+or with the explicit value: installed:false.
+*/
+package query
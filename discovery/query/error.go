@@ -0,0 +1,42 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device search query language
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Query errors
+
+package query
+
+import "fmt"
+
+// Error is the query parse error. It points at the offending token.
+type Error struct {
+	Query string // The query
+	Pos   int    // Byte offset of the offending token
+	Token string // The offending token, "" at the end of query
+	Msg   string // Error message
+}
+
+// newError creates a new Error
+func newError(q string, tok token, format string, args ...any) *Error {
+	return &Error{
+		Query: q,
+		Pos:   tok.pos,
+		Token: tok.String(),
+		Msg:   fmt.Sprintf(format, args...),
+	}
+}
+
+// Error returns the error message. It implements the error interface.
+//
+// Position is reported as 1-based column.
+func (e *Error) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("query: column %d: %s at end of query",
+			e.Pos+1, e.Msg)
+	}
+
+	return fmt.Sprintf("query: column %d: %s: %s",
+		e.Pos+1, e.Token, e.Msg)
+}
@@ -0,0 +1,251 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device search query language
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Query fields
+
+package query
+
+import (
+	"errors"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// field describes the query field
+type field struct {
+	// optional is true, if value may be omitted
+	optional bool
+
+	// compile compiles field:value term into the predicate.
+	// The hasValue is false, if value is omitted.
+	compile func(value string, hasValue bool) (predicate, error)
+}
+
+// fields contains all known fields, indexed by name
+var fields = map[string]*field{
+	"make":      {compile: stringField(fieldMake)},
+	"model":     {compile: stringField(fieldModel)},
+	"name":      {compile: stringField(fieldName)},
+	"uuid":      {compile: uuidField},
+	"protocol":  {compile: protocolField},
+	"address":   {compile: addressField},
+	"installed": {optional: true, compile: boolField(fieldInstalled)},
+	"verified":  {optional: true, compile: boolField(fieldVerified)},
+}
+
+// protocols contains known protocols, indexed by lower-case name
+var protocols = map[string]discovery.ServiceProto{}
+
+func init() {
+	for _, proto := range []discovery.ServiceProto{
+		discovery.ServiceIPP,
+		discovery.ServiceESCL,
+		discovery.ServiceLPD,
+		discovery.ServiceAppSocket,
+		discovery.ServiceWSD,
+		discovery.ServiceUSB,
+	} {
+		protocols[strings.ToLower(proto.String())] = proto
+	}
+}
+
+// fieldMake returns the device manufacturer. If PPD manufacturer
+// is not known, the first word of the MakeModel is used.
+func fieldMake(dev *Device) []string {
+	if dev.PPDManufacturer != "" {
+		return []string{dev.PPDManufacturer}
+	}
+
+	mfg, _, _ := strings.Cut(dev.MakeModel, " ")
+	return []string{mfg}
+}
+
+// fieldModel returns the device model, both MakeModel
+// and PPD model.
+func fieldModel(dev *Device) []string {
+	return []string{dev.MakeModel, dev.PPDModel}
+}
+
+// fieldName returns the device DNS-SD name.
+func fieldName(dev *Device) []string {
+	return []string{dev.DNSSDName}
+}
+
+// fieldInstalled returns the device Installed flag
+func fieldInstalled(dev *Device) bool {
+	return dev.Installed
+}
+
+// fieldVerified returns the device Verified flag
+func fieldVerified(dev *Device) bool {
+	return dev.Verified
+}
+
+// stringField returns compile function for the string field.
+// The get function returns the field values, and the term
+// matches if any of them matches.
+func stringField(get func(dev *Device) []string) func(
+	string, bool) (predicate, error) {
+
+	return func(value string, _ bool) (predicate, error) {
+		match := stringMatcher(value)
+		return func(dev *Device) bool {
+			for _, s := range get(dev) {
+				if s != "" && match(s) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+}
+
+// uuidField compiles the uuid:value term.
+func uuidField(value string, _ bool) (predicate, error) {
+	value = strings.TrimPrefix(strings.ToLower(value), "urn:uuid:")
+	match := stringMatcher(value)
+
+	return func(dev *Device) bool {
+		return dev.DNSSDUUID != uuid.NilUUID &&
+			match(dev.DNSSDUUID.String())
+	}, nil
+}
+
+// protocolField compiles the protocol:value term.
+func protocolField(value string, _ bool) (predicate, error) {
+	proto, found := protocols[strings.ToLower(value)]
+	if !found {
+		return nil, errors.New("unknown protocol")
+	}
+
+	return func(dev *Device) bool {
+		for _, un := range dev.PrintUnits {
+			if un.Proto == proto {
+				return true
+			}
+		}
+		for _, un := range dev.ScanUnits {
+			if un.Proto == proto {
+				return true
+			}
+		}
+		for _, un := range dev.FaxoutUnits {
+			if un.Proto == proto {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// addressField compiles the address:value term.
+// The value is either IP address or CIDR prefix.
+func addressField(value string, _ bool) (predicate, error) {
+	var prefix netip.Prefix
+
+	if strings.Contains(value, "/") {
+		var err error
+		prefix, err = netip.ParsePrefix(value)
+		if err != nil {
+			return nil, errors.New("invalid CIDR prefix")
+		}
+		prefix = prefix.Masked()
+	} else {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, errors.New("invalid IP address")
+		}
+		addr = transport.NormalizeAddr(addr)
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	return func(dev *Device) bool {
+		for _, addr := range dev.Addrs {
+			if prefix.Contains(transport.NormalizeAddr(addr)) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// boolField returns compile function for the boolean field.
+// Omitted value means true.
+func boolField(get func(dev *Device) bool) func(
+	string, bool) (predicate, error) {
+
+	return func(value string, hasValue bool) (predicate, error) {
+		want := true
+		if hasValue {
+			var err error
+			want, err = parseBool(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return func(dev *Device) bool {
+			return get(dev) == want
+		}, nil
+	}
+}
+
+// parseBool parses the boolean value
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		err = errors.New("invalid boolean value")
+	}
+
+	return v, err
+}
+
+// stringMatcher returns function that matches strings against the
+// pattern. Match is case-insensitive and the '*' character within
+// the pattern matches any sequence of characters.
+func stringMatcher(pattern string) func(s string) bool {
+	pattern = strings.ToLower(pattern)
+	parts := strings.Split(pattern, "*")
+
+	if len(parts) == 1 {
+		return func(s string) bool {
+			return strings.ToLower(s) == pattern
+		}
+	}
+
+	first, last := parts[0], parts[len(parts)-1]
+	middle := parts[1 : len(parts)-1]
+
+	return func(s string) bool {
+		s = strings.ToLower(s)
+		if !strings.HasPrefix(s, first) {
+			return false
+		}
+		s = s[len(first):]
+
+		for _, part := range middle {
+			i := strings.Index(s, part)
+			if i < 0 {
+				return false
+			}
+			s = s[i+len(part):]
+		}
+
+		return strings.HasSuffix(s, last)
+	}
+}
@@ -0,0 +1,133 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device search query language
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Query lexer
+
+package query
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenType is the type of the query token
+type tokenType int
+
+// Token types:
+const (
+	tokenEOF    tokenType = iota // End of query
+	tokenLParen                  // (
+	tokenRParen                  // )
+	tokenWord                    // Unquoted word
+	tokenString                  // Quoted string
+)
+
+// token is the single query token
+type token struct {
+	typ  tokenType // Token type
+	text string    // Token text (unquoted for strings)
+	pos  int       // Byte offset within the query
+}
+
+// keyword returns the upper-case token text, if token is the
+// AND, OR or NOT keyword, or "" otherwise.
+func (tok token) keyword() string {
+	if tok.typ == tokenWord {
+		switch kw := strings.ToUpper(tok.text); kw {
+		case "AND", "OR", "NOT":
+			return kw
+		}
+	}
+
+	return ""
+}
+
+// String returns token text for diagnostics.
+func (tok token) String() string {
+	switch tok.typ {
+	case tokenEOF:
+		return ""
+	case tokenString:
+		return `"` + tok.text + `"`
+	}
+	return tok.text
+}
+
+// tokenize splits query into tokens. The returned slice always
+// ends with the tokenEOF token.
+func tokenize(q string) ([]token, error) {
+	var toks []token
+
+	for pos := 0; pos < len(q); {
+		c, sz := utf8.DecodeRuneInString(q[pos:])
+
+		switch {
+		case unicode.IsSpace(c):
+			pos += sz
+
+		case c == '(':
+			toks = append(toks, token{tokenLParen, "(", pos})
+			pos += sz
+
+		case c == ')':
+			toks = append(toks, token{tokenRParen, ")", pos})
+			pos += sz
+
+		case c == '"':
+			text, end, ok := lexString(q, pos)
+			if !ok {
+				return nil, newError(q, token{tokenWord,
+					q[pos:], pos}, "unterminated string")
+			}
+
+			toks = append(toks, token{tokenString, text, pos})
+			pos = end
+
+		default:
+			end := pos
+			for end < len(q) {
+				c, sz := utf8.DecodeRuneInString(q[end:])
+				if unicode.IsSpace(c) || strings.ContainsRune(`()"`, c) {
+					break
+				}
+				end += sz
+			}
+
+			toks = append(toks, token{tokenWord, q[pos:end], pos})
+			pos = end
+		}
+	}
+
+	toks = append(toks, token{tokenEOF, "", len(q)})
+	return toks, nil
+}
+
+// lexString decodes quoted string, starting at the q[pos].
+// Within the string, backslash escapes the next character.
+//
+// It returns unquoted string, position after the closing
+// quote and true on success.
+func lexString(q string, pos int) (string, int, bool) {
+	var buf strings.Builder
+
+	for i := pos + 1; i < len(q); i++ {
+		switch c := q[i]; c {
+		case '"':
+			return buf.String(), i + 1, true
+		case '\\':
+			if i+1 < len(q) {
+				i++
+				c = q[i]
+			}
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	return "", len(q), false
+}
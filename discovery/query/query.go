@@ -0,0 +1,214 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device search query language
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Query compiler
+
+package query
+
+import (
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+)
+
+// Device is the device record, the query is applied to.
+//
+// It extends the [discovery.Device] with information, which is
+// not known to the discovery itself, and is supplied by the caller.
+type Device struct {
+	discovery.Device
+	Installed bool // Device is installed in the system
+	Verified  bool // Device is verified (i.e., probed and working)
+}
+
+// predicate is the compiled query or its part.
+type predicate func(dev *Device) bool
+
+// Compile compiles the query into the predicate function, that
+// reports if [Device] matches the query.
+//
+// Empty query matches all devices.
+//
+// On a syntax error, the returned error is the *[Error], which
+// points at the offending token.
+func Compile(q string) (func(Device) bool, error) {
+	toks, err := tokenize(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if toks[0].typ == tokenEOF {
+		return func(Device) bool { return true }, nil
+	}
+
+	p := &parser{query: q, toks: toks}
+	pred, err := p.parseOr()
+	if err == nil && p.peek().typ != tokenEOF {
+		err = p.unexpected(p.peek())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func(dev Device) bool { return pred(&dev) }, nil
+}
+
+// parser parses the query.
+//
+// The grammar is the following:
+//
+//	or      := and { OR and }
+//	and     := not { AND not }
+//	not     := NOT not | primary
+//	primary := "(" or ")" | field [":" value]
+type parser struct {
+	query string  // The query
+	toks  []token // Query tokens
+	next  int     // Index of the next token
+}
+
+// peek returns the next token
+func (p *parser) peek() token {
+	return p.toks[p.next]
+}
+
+// get returns the next token and advances to the subsequent one.
+// At the end of query it returns the tokenEOF.
+func (p *parser) get() token {
+	tok := p.toks[p.next]
+	if tok.typ != tokenEOF {
+		p.next++
+	}
+	return tok
+}
+
+// unexpected returns the "unexpected token" error
+func (p *parser) unexpected(tok token) error {
+	switch {
+	case tok.typ == tokenEOF:
+		return newError(p.query, tok, "expression expected")
+	case tok.typ == tokenRParen:
+		return newError(p.query, tok, "unbalanced ')'")
+	case tok.keyword() != "":
+		return newError(p.query, tok, "unexpected operator")
+	}
+
+	return newError(p.query, tok, "AND or OR expected")
+}
+
+// parseOr parses the OR expression
+func (p *parser) parseOr() (predicate, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek().keyword() == "OR" {
+		var right predicate
+
+		p.get()
+		right, err = p.parseAnd()
+		if err == nil {
+			l := left
+			left = func(dev *Device) bool {
+				return l(dev) || right(dev)
+			}
+		}
+	}
+
+	return left, err
+}
+
+// parseAnd parses the AND expression
+func (p *parser) parseAnd() (predicate, error) {
+	left, err := p.parseNot()
+	for err == nil && p.peek().keyword() == "AND" {
+		var right predicate
+
+		p.get()
+		right, err = p.parseNot()
+		if err == nil {
+			l := left
+			left = func(dev *Device) bool {
+				return l(dev) && right(dev)
+			}
+		}
+	}
+
+	return left, err
+}
+
+// parseNot parses the NOT expression
+func (p *parser) parseNot() (predicate, error) {
+	if p.peek().keyword() != "NOT" {
+		return p.parsePrimary()
+	}
+
+	p.get()
+	pred, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(dev *Device) bool { return !pred(dev) }, nil
+}
+
+// parsePrimary parses the parenthesized expression or the term
+func (p *parser) parsePrimary() (predicate, error) {
+	tok := p.get()
+
+	switch {
+	case tok.typ == tokenLParen:
+		pred, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		switch next := p.peek(); next.typ {
+		case tokenRParen:
+			p.get()
+		case tokenEOF:
+			return nil, newError(p.query, tok, "unbalanced '('")
+		default:
+			return nil, newError(p.query, next, "')' expected")
+		}
+
+		return pred, nil
+
+	case tok.typ == tokenWord && tok.keyword() == "":
+		return p.parseTerm(tok)
+	}
+
+	return nil, p.unexpected(tok)
+}
+
+// parseTerm parses the field:value term
+func (p *parser) parseTerm(tok token) (predicate, error) {
+	name, value, hasValue := strings.Cut(tok.text, ":")
+	fld := fields[strings.ToLower(name)]
+	if fld == nil {
+		return nil, newError(p.query, tok, "unknown field %q", name)
+	}
+
+	// Value may be quoted: field:"value with spaces"
+	valtok := tok
+	if hasValue && value == "" {
+		if next := p.peek(); next.typ == tokenString {
+			valtok = p.get()
+			value = next.text
+		} else {
+			return nil, newError(p.query, tok, "missed value")
+		}
+	}
+
+	if !hasValue && !fld.optional {
+		return nil, newError(p.query, tok, "field:value expected")
+	}
+
+	pred, err := fld.compile(value, hasValue)
+	if err != nil {
+		return nil, newError(p.query, valtok, "%s", err)
+	}
+
+	return pred, nil
+}
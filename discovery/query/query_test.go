@@ -0,0 +1,265 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device search query language
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Query tests
+
+package query
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// testDevices contains devices for tests
+var testDevices = map[string]Device{
+	"hp": {
+		Device: discovery.Device{
+			MakeModel:       "HP LaserJet MFP M426fdn",
+			PPDManufacturer: "HP",
+			PPDModel:        "LaserJet MFP M426fdn",
+			DNSSDName:       "HP LaserJet MFP M426fdn (8A1B2C)",
+			DNSSDUUID: uuid.MustParse(
+				"564e4333-4230-4a35-3932-a0d3c1000000"),
+			Addrs: []netip.Addr{
+				netip.MustParseAddr("192.168.1.10"),
+				netip.MustParseAddr("fe80::a2d3:c1ff:fe00:1"),
+			},
+			PrintUnits: []discovery.PrintUnit{
+				{Proto: discovery.ServiceIPP},
+			},
+			ScanUnits: []discovery.ScanUnit{
+				{Proto: discovery.ServiceESCL},
+			},
+		},
+		Installed: true,
+	},
+
+	"kyocera": {
+		Device: discovery.Device{
+			MakeModel: "Kyocera ECOSYS M2040dn",
+			DNSSDName: "Kyocera ECOSYS M2040dn",
+			Addrs: []netip.Addr{
+				netip.MustParseAddr("10.0.0.5"),
+			},
+			PrintUnits: []discovery.PrintUnit{
+				{Proto: discovery.ServiceWSD},
+			},
+			ScanUnits: []discovery.ScanUnit{
+				{Proto: discovery.ServiceWSD},
+			},
+		},
+		Verified: true,
+	},
+
+	"usb": {
+		Device: discovery.Device{
+			MakeModel:       "Canon MF4400",
+			PPDManufacturer: "Canon",
+			PPDModel:        "MF4400",
+			PrintUnits: []discovery.PrintUnit{
+				{Proto: discovery.ServiceUSB},
+			},
+		},
+	},
+}
+
+// TestCompile tests query compilation and matching
+func TestCompile(t *testing.T) {
+	type testData struct {
+		query   string   // The query
+		matches []string // Names of matching devices, sorted
+	}
+
+	tests := []testData{
+		// Empty query
+		{"", []string{"hp", "kyocera", "usb"}},
+		{"  ", []string{"hp", "kyocera", "usb"}},
+
+		// Simple fields
+		{"make:HP", []string{"hp"}},
+		{"make:kyocera", []string{"kyocera"}},
+		{"MAKE:canon", []string{"usb"}},
+		{"model:MF4400", []string{"usb"}},
+		{"model:*laserjet*", []string{"hp"}},
+		{"model:HP*", []string{"hp"}},
+		{"model:*dn", []string{"hp", "kyocera"}},
+		{"model:*", []string{"hp", "kyocera", "usb"}},
+		{"model:Laser", nil},
+		{"uuid:564e4333-4230-4a35-3932-a0d3c1000000", []string{"hp"}},
+		{"uuid:urn:uuid:564E4333-*", []string{"hp"}},
+		{"protocol:escl", []string{"hp"}},
+		{"protocol:WSD", []string{"kyocera"}},
+		{"installed", []string{"hp"}},
+		{"installed:no", []string{"kyocera", "usb"}},
+		{"verified:true", []string{"kyocera"}},
+
+		// Quoting
+		{`name:"Kyocera ECOSYS M2040dn"`, []string{"kyocera"}},
+		{`name: "kyocera ecosys m2040dn"`, []string{"kyocera"}},
+		{`name:"HP LaserJet MFP M426fdn (*)"`, []string{"hp"}},
+		{`name:"say \"hello\""`, nil},
+
+		// Addresses
+		{"address:192.168.1.10", []string{"hp"}},
+		{"address:192.168.1.11", nil},
+		{"address:192.168.0.0/16", []string{"hp"}},
+		{"address:10.0.0.0/8", []string{"kyocera"}},
+		{"address:0.0.0.0/0", []string{"hp", "kyocera"}},
+		{"address:fe80::/10", []string{"hp"}},
+		{"address:fe80::a2d3:c1ff:fe00:1", []string{"hp"}},
+		{"address:::ffff:192.168.1.10", []string{"hp"}},
+		{`address:"::ffff:10.0.0.5"`, []string{"kyocera"}},
+
+		// Boolean operators and precedence
+		{"make:HP OR make:Canon", []string{"hp", "usb"}},
+		{"make:HP and installed", []string{"hp"}},
+		{"NOT installed", []string{"kyocera", "usb"}},
+		{"NOT NOT installed", []string{"hp"}},
+		{"make:HP OR make:Kyocera AND installed", []string{"hp"}},
+		{"(make:HP OR make:Kyocera) AND installed", []string{"hp"}},
+		{"(make:HP OR make:Kyocera) AND NOT installed",
+			[]string{"kyocera"}},
+		{"NOT make:HP AND NOT make:Canon", []string{"kyocera"}},
+		{"NOT (make:HP OR make:Canon)", []string{"kyocera"}},
+		{"make:HP AND (protocol:escl OR protocol:wsd) AND NOT installed",
+			nil},
+		{"(protocol:escl OR protocol:wsd) AND NOT installed",
+			[]string{"kyocera"}},
+		{"((protocol:usb))", []string{"usb"}},
+	}
+
+	for _, test := range tests {
+		match, err := Compile(test.query)
+		if err != nil {
+			t.Errorf("%q: %s", test.query, err)
+			continue
+		}
+
+		var matches []string
+		for _, name := range []string{"hp", "kyocera", "usb"} {
+			if match(testDevices[name]) {
+				matches = append(matches, name)
+			}
+		}
+
+		if !testEqual(matches, test.matches) {
+			t.Errorf("%q:\nexpected: %v\npresent:  %v",
+				test.query, test.matches, matches)
+		}
+	}
+}
+
+// TestCompileErrors tests query syntax errors
+func TestCompileErrors(t *testing.T) {
+	type testData struct {
+		query string // The query
+		pos   int    // Expected error position
+		err   string // Expected error message
+	}
+
+	tests := []testData{
+		{
+			query: "make:HP AND protocl:escl",
+			pos:   12,
+			err:   `query: column 13: protocl:escl: unknown field "protocl"`,
+		},
+		{
+			query: "make:HP model:X",
+			pos:   8,
+			err:   `query: column 9: model:X: AND or OR expected`,
+		},
+		{
+			query: "make:HP AND",
+			pos:   11,
+			err:   `query: column 12: expression expected at end of query`,
+		},
+		{
+			query: "(make:HP OR make:Canon",
+			pos:   0,
+			err:   `query: column 1: (: unbalanced '('`,
+		},
+		{
+			query: "make:HP)",
+			pos:   7,
+			err:   `query: column 8: ): unbalanced ')'`,
+		},
+		{
+			query: "make:HP OR OR installed",
+			pos:   11,
+			err:   `query: column 12: OR: unexpected operator`,
+		},
+		{
+			query: `name:"unterminated`,
+			pos:   5,
+			err:   `query: column 6: "unterminated: unterminated string`,
+		},
+		{
+			query: `name: AND`,
+			pos:   0,
+			err:   `query: column 1: name:: missed value`,
+		},
+		{
+			query: `make`,
+			pos:   0,
+			err:   `query: column 1: make: field:value expected`,
+		},
+		{
+			query: `installed AND address:300.1.1.1`,
+			pos:   14,
+			err:   `query: column 15: address:300.1.1.1: invalid IP address`,
+		},
+		{
+			query: `address:"10.0.0.0/33"`,
+			pos:   8,
+			err:   `query: column 9: "10.0.0.0/33": invalid CIDR prefix`,
+		},
+		{
+			query: `protocol:smb`,
+			pos:   0,
+			err:   `query: column 1: protocol:smb: unknown protocol`,
+		},
+		{
+			query: `verified:maybe`,
+			pos:   0,
+			err:   `query: column 1: verified:maybe: invalid boolean value`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := Compile(test.query)
+
+		var qerr *Error
+		if !errors.As(err, &qerr) {
+			t.Errorf("%q: expected *Error, present %v",
+				test.query, err)
+			continue
+		}
+
+		if qerr.Pos != test.pos || err.Error() != test.err {
+			t.Errorf("%q:\nexpected: %d %s\npresent:  %d %s",
+				test.query, test.pos, test.err, qerr.Pos, err)
+		}
+	}
+}
+
+// testEqual compares two slices of strings
+func testEqual(s1, s2 []string) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,262 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Two-phase discovery results
+
+package discovery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/util/generic"
+)
+
+// DiscoverOptions contains parameters of the [Client.Discover].
+type DiscoverOptions struct {
+	// Mode is the discovery mode for the [Results.Final].
+	// ModeSnapshot is treated as ModeNormal here.
+	Mode Mode
+
+	// FirstWaveTime is the deadline for the [Results.FirstWave],
+	// counted from the Discover call. If zero, the [FirstWaveTime]
+	// constant is used.
+	FirstWaveTime time.Duration
+}
+
+// Results represents results of the [Client.Discover], available
+// in two phases:
+//   - The first wave, "good enough" for interactive UIs, that
+//     resolves quickly.
+//   - The final, complete set of discovered devices.
+//
+// Devices, discovered after the first wave, are reported via the
+// [Results.Late] channel as they arrive, and marked with the
+// Device.Late flag in the [Results.Final] output.
+type Results struct {
+	clnt     *Client             // Discovery client
+	mode     Mode                // Mode for Final
+	deadline time.Time           // First wave deadline
+	fast     []FastBackend       // Fast backends
+	first    []Device            // First wave, nil if not resolved
+	firstIDs generic.Set[UnitID] // Units of the first wave devices
+	late     chan Device         // Late arrivals
+	lock     sync.Mutex          // Access lock
+}
+
+// Discover starts the two-phase discovery and returns its [Results].
+//
+// The first wave resolves as soon as all fast backends (see
+// [FastBackend]) report or opts.FirstWaveTime expires, whichever
+// comes first, while slow backends continue to work.
+//
+// The provided [context.Context] limits the lifetime of the
+// [Results.Late] channel. Cancel it when Results are not needed
+// anymore.
+func (clnt *Client) Discover(ctx context.Context,
+	opts DiscoverOptions) *Results {
+
+	firstWaveTime := opts.FirstWaveTime
+	if firstWaveTime == 0 {
+		firstWaveTime = FirstWaveTime
+	}
+
+	mode := opts.Mode
+	if mode == ModeSnapshot {
+		mode = ModeNormal
+	}
+
+	rs := &Results{
+		clnt:     clnt,
+		mode:     mode,
+		deadline: time.Now().Add(firstWaveTime),
+		late:     make(chan Device),
+	}
+
+	clnt.lock.Lock()
+	for bk := range clnt.backends {
+		if fast, ok := bk.(FastBackend); ok {
+			rs.fast = append(rs.fast, fast)
+		}
+	}
+	clnt.lock.Unlock()
+
+	go rs.proc(ctx)
+
+	return rs
+}
+
+// FirstWave returns the first wave of discovered devices.
+//
+// It waits until all fast backends report and their events are
+// processed, or the first wave deadline expires. Subsequent calls
+// return the same result.
+//
+// Error is only returned if ctx or the [Client] context expires
+// before the first wave is resolved.
+func (rs *Results) FirstWave(ctx context.Context) ([]Device, error) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+
+	if rs.first != nil {
+		return rs.first, nil
+	}
+
+	timer := time.NewTimer(time.Until(rs.deadline))
+	defer timer.Stop()
+
+	// Wait for fast backends
+	expired := false
+	for _, fast := range rs.fast {
+		if expired {
+			break
+		}
+
+		select {
+		case <-fast.Ready():
+		case <-timer.C:
+			expired = true
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-rs.clnt.ctx.Done():
+			return nil, rs.clnt.ctx.Err()
+		}
+	}
+
+	// Wait until their events are handled and take the snapshot
+	clnt := rs.clnt
+	pushed := clnt.queue.pushedCount()
+
+	clnt.lock.Lock()
+	for !expired && clnt.handled < pushed {
+		progress := clnt.progress
+
+		clnt.lock.Unlock()
+		select {
+		case <-progress:
+		case <-timer.C:
+			expired = true
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-clnt.ctx.Done():
+			return nil, clnt.ctx.Err()
+		}
+		clnt.lock.Lock()
+	}
+
+	devices := clnt.cache.Snapshot()
+	clnt.lock.Unlock()
+
+	rs.firstIDs = generic.NewSet[UnitID]()
+	for _, dev := range devices {
+		for _, id := range dev.ids {
+			rs.firstIDs.Add(id)
+		}
+	}
+
+	rs.first = devices
+	return rs.first, nil
+}
+
+// Final returns the complete set of discovered devices, waiting
+// according to the [DiscoverOptions.Mode], like [Client.GetDevices]
+// does.
+//
+// Devices, not present in the first wave, are marked as Late.
+func (rs *Results) Final(ctx context.Context) ([]Device, error) {
+	_, err := rs.FirstWave(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := rs.clnt.GetDevices(ctx, rs.mode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cached output of the Client is shared, so don't modify it
+	// in place.
+	devices = append([]Device(nil), devices...)
+	for i := range devices {
+		devices[i].Late = rs.isLate(&devices[i])
+	}
+
+	return devices, nil
+}
+
+// Late returns the channel of devices, discovered after the first
+// wave. Each device is reported once, as soon as it appears.
+//
+// The channel is closed when the [context.Context] of the
+// [Client.Discover] is canceled or the [Client] is closed.
+func (rs *Results) Late() <-chan Device {
+	return rs.late
+}
+
+// proc reports late arrivals on its separate goroutine.
+func (rs *Results) proc(ctx context.Context) {
+	defer close(rs.late)
+
+	_, err := rs.FirstWave(ctx)
+	if err != nil {
+		return
+	}
+
+	seen := rs.firstIDs.Clone()
+	clnt := rs.clnt
+
+	for {
+		clnt.lock.Lock()
+		updated := clnt.updated
+		devices := clnt.cache.Snapshot()
+		clnt.lock.Unlock()
+
+		for _, dev := range devices {
+			if !rs.isNew(&dev, seen) {
+				continue
+			}
+
+			for _, id := range dev.ids {
+				seen.Add(id)
+			}
+
+			dev.Late = true
+			select {
+			case rs.late <- dev:
+			case <-ctx.Done():
+				return
+			case <-clnt.ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-updated:
+		case <-ctx.Done():
+			return
+		case <-clnt.ctx.Done():
+			return
+		}
+	}
+}
+
+// isLate reports if device was discovered after the first wave.
+func (rs *Results) isLate(dev *Device) bool {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	return rs.isNew(dev, rs.firstIDs)
+}
+
+// isNew reports if none of the device units is in the set.
+func (rs *Results) isNew(dev *Device, ids generic.Set[UnitID]) bool {
+	for _, id := range dev.ids {
+		if ids.Contains(id) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Two-phase discovery results tests
+
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// testDelayedBackend is the Backend that reports its events
+// after the specified delay.
+type testDelayedBackend struct {
+	*MockBackend
+	delay time.Duration
+	ready chan struct{}
+}
+
+// testFastBackend is the testDelayedBackend, that implements
+// the FastBackend interface.
+type testFastBackend struct {
+	*testDelayedBackend
+}
+
+// newTestDelayedBackend creates a new testDelayedBackend
+func newTestDelayedBackend(name string,
+	delay time.Duration) *testDelayedBackend {
+	return &testDelayedBackend{
+		MockBackend: NewMockBackend(name),
+		delay:       delay,
+		ready:       make(chan struct{}),
+	}
+}
+
+// Start starts Backend operations.
+func (bk *testDelayedBackend) Start(q *Eventqueue) {
+	go func() {
+		time.Sleep(bk.delay)
+		bk.MockBackend.Start(q)
+		close(bk.ready)
+	}()
+}
+
+// Ready returns a channel that is closed when the Backend
+// has pushed all its events.
+func (bk testFastBackend) Ready() <-chan struct{} {
+	return bk.ready
+}
+
+// addPrinter adds events for the new printer into the backend
+func (bk *testDelayedBackend) addPrinter(name, endpoint string) {
+	uid := UnitID{
+		DNSSDName: name,
+		UUID:      uuid.Random(),
+		SvcType:   ServicePrinter,
+		SvcProto:  ServiceIPP,
+	}
+
+	bk.AddEvent(&EventAddUnit{ID: uid})
+	bk.AddEvent(&EventPrinterParameters{ID: uid, MakeModel: name})
+	bk.AddEvent(&EventAddEndpoint{ID: uid, Endpoint: endpoint})
+}
+
+// TestResults tests the two-phase discovery
+func TestResults(t *testing.T) {
+	ctx := context.Background()
+	clnt := NewClientTm(ctx, 700*time.Millisecond, 100*time.Millisecond)
+	defer clnt.Close()
+
+	fast := newTestDelayedBackend("fast", 50*time.Millisecond)
+	fast.addPrinter("Fast Printer", "ipp://192.168.1.100/ipp/print")
+
+	slow := newTestDelayedBackend("slow", 300*time.Millisecond)
+	slow.addPrinter("Slow Printer", "ipp://192.168.1.101/ipp/print")
+
+	clnt.AddBackend(testFastBackend{fast})
+	clnt.AddBackend(slow)
+
+	start := time.Now()
+	rs := clnt.Discover(ctx, DiscoverOptions{FirstWaveTime: time.Second})
+
+	// First wave must resolve as soon as fast backend reports
+	first, err := rs.FirstWave(ctx)
+	if err != nil {
+		t.Fatalf("FirstWave: %s", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("FirstWave took %s", elapsed)
+	}
+
+	if len(first) != 1 || first[0].MakeModel != "Fast Printer" {
+		t.Errorf("FirstWave: expected [Fast Printer], present %v",
+			first)
+	}
+
+	// Late arrival must be reported
+	select {
+	case dev := <-rs.Late():
+		if dev.MakeModel != "Slow Printer" || !dev.Late {
+			t.Errorf("Late: unexpected device %v", dev)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Late: device not reported")
+	}
+
+	// Final set must be complete, with the late arrival marked
+	final, err := rs.Final(ctx)
+	if err != nil {
+		t.Fatalf("Final: %s", err)
+	}
+
+	if len(final) != 2 {
+		t.Fatalf("Final: expected 2 devices, present %d", len(final))
+	}
+
+	for _, dev := range final {
+		expected := dev.MakeModel == "Slow Printer"
+		if dev.Late != expected {
+			t.Errorf("Final: %s: Late is %v, expected %v",
+				dev.MakeModel, dev.Late, expected)
+		}
+	}
+}
+
+// TestResultsDeadline tests that the first wave resolves at the
+// deadline, if the fast backend doesn't report in time.
+func TestResultsDeadline(t *testing.T) {
+	ctx := context.Background()
+	clnt := NewClientTm(ctx, 100*time.Millisecond, 100*time.Millisecond)
+	defer clnt.Close()
+
+	stuck := newTestDelayedBackend("stuck", time.Hour)
+	clnt.AddBackend(testFastBackend{stuck})
+
+	start := time.Now()
+	rs := clnt.Discover(ctx, DiscoverOptions{
+		FirstWaveTime: 200 * time.Millisecond,
+	})
+
+	first, err := rs.FirstWave(ctx)
+	if err != nil {
+		t.Fatalf("FirstWave: %s", err)
+	}
+
+	elapsed := time.Since(start)
+	if elapsed < 200*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("FirstWave took %s, expected ~200ms", elapsed)
+	}
+
+	if len(first) != 0 {
+		t.Errorf("FirstWave: expected no devices, present %v", first)
+	}
+
+	// Canceled context must be respected
+	ctx2, cancel := context.WithCancel(ctx)
+	cancel()
+
+	rs = clnt.Discover(ctx2, DiscoverOptions{})
+	_, err = rs.FirstWave(ctx2)
+	if err != context.Canceled {
+		t.Errorf("FirstWave: expected %v, present %v",
+			context.Canceled, err)
+	}
+}
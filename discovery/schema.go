@@ -0,0 +1,29 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// JSON Schema of the machine-readable output
+
+package discovery
+
+import (
+	_ "embed"
+)
+
+// jsonSchema is the JSON Schema (draft 2020-12) of the [FormatJSON]
+// output.
+//
+//go:embed schema.json
+var jsonSchema []byte
+
+// JSONSchema returns the JSON Schema (draft 2020-12), that describes
+// output of the [FormatJSON].
+//
+// The schema is the contract for the consumers of the JSON output.
+// Breaking changes of the output format are indicated by increment
+// of the [JSONFormatVersion].
+func JSONSchema() []byte {
+	return append([]byte{}, jsonSchema...)
+}
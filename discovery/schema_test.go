@@ -0,0 +1,304 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// JSON Schema tests
+
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testSchema is the minimal JSON Schema validator, sufficient
+// for our schema. It supports type, properties, required,
+// additionalProperties (false only), items, enum, anyOf and
+// local $ref ("#/$defs/name").
+type testSchema struct {
+	root map[string]any
+}
+
+// newTestSchema parses the JSON Schema.
+func newTestSchema(data []byte) (*testSchema, error) {
+	var root map[string]any
+	err := json.Unmarshal(data, &root)
+	if err != nil {
+		return nil, err
+	}
+	return &testSchema{root}, nil
+}
+
+// resolve resolves the $ref, if any.
+func (s *testSchema) resolve(node map[string]any) (map[string]any, error) {
+	ref, ok := node["$ref"].(string)
+	if !ok {
+		return node, nil
+	}
+
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+
+	defs, _ := s.root["$defs"].(map[string]any)
+	def, ok := defs[name].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("undefined $ref %q", ref)
+	}
+
+	return def, nil
+}
+
+// validate validates the value against the schema node.
+func (s *testSchema) validate(path string, node map[string]any,
+	v any) error {
+
+	node, err := s.resolve(node)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if variants, ok := node["anyOf"].([]any); ok {
+		for _, variant := range variants {
+			sub, _ := variant.(map[string]any)
+			if s.validate(path, sub, v) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: no anyOf variant matches", path)
+	}
+
+	if t, ok := node["type"]; ok {
+		var types []string
+		switch t := t.(type) {
+		case string:
+			types = []string{t}
+		case []any:
+			for _, tt := range t {
+				types = append(types, tt.(string))
+			}
+		}
+
+		match := false
+		for _, t := range types {
+			match = match || testSchemaTypeOK(t, v)
+		}
+
+		if !match {
+			return fmt.Errorf("%s: %v expected, %T present",
+				path, types, v)
+		}
+	}
+
+	if enum, ok := node["enum"].([]any); ok {
+		match := false
+		for _, e := range enum {
+			match = match || reflect.DeepEqual(e, v)
+		}
+
+		if !match {
+			return fmt.Errorf("%s: %v not in %v", path, v, enum)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		props, _ := node["properties"].(map[string]any)
+
+		required, _ := node["required"].([]any)
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing %q", path, name)
+			}
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			prop, ok := props[name].(map[string]any)
+			if !ok {
+				if node["additionalProperties"] == false {
+					return fmt.Errorf("%s: unexpected %q",
+						path, name)
+				}
+				continue
+			}
+
+			err := s.validate(path+"."+name, prop, v[name])
+			if err != nil {
+				return err
+			}
+		}
+
+	case []any:
+		items, ok := node["items"].(map[string]any)
+		if ok {
+			for i, item := range v {
+				err := s.validate(fmt.Sprintf("%s[%d]", path, i),
+					items, item)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// testSchemaTypeOK reports if JSON value matches the JSON Schema type.
+func testSchemaTypeOK(t string, v any) bool {
+	switch t {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return false
+}
+
+// TestJSONSchemaGolden validates all JSON golden outputs
+// against the schema.
+func TestJSONSchemaGolden(t *testing.T) {
+	schema, err := newTestSchema(JSONSchema())
+	if err != nil {
+		t.Fatalf("JSONSchema: %s", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(testFormatDir, "*.json"))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(files) == 0 {
+		t.Fatalf("no JSON golden files found")
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("%s", err)
+			continue
+		}
+
+		var v any
+		err = json.Unmarshal(data, &v)
+		if err != nil {
+			t.Errorf("%s: %s", file, err)
+			continue
+		}
+
+		err = schema.validate("$", schema.root, v)
+		if err != nil {
+			t.Errorf("%s: %s", file, err)
+		}
+	}
+}
+
+// TestJSONSchemaFields checks that the schema and the output
+// structures describe exactly the same set of fields, so new
+// output fields cannot be added without the schema update.
+func TestJSONSchemaFields(t *testing.T) {
+	schema, err := newTestSchema(JSONSchema())
+	if err != nil {
+		t.Fatalf("JSONSchema: %s", err)
+	}
+
+	var check func(path string, node map[string]any, typ reflect.Type)
+	check = func(path string, node map[string]any, typ reflect.Type) {
+		node, err := schema.resolve(node)
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			return
+		}
+
+		if variants, ok := node["anyOf"].([]any); ok {
+			for _, variant := range variants {
+				sub := variant.(map[string]any)
+				if sub["type"] != "null" {
+					check(path, sub, typ)
+				}
+			}
+			return
+		}
+
+		switch typ.Kind() {
+		case reflect.Pointer:
+			check(path, node, typ.Elem())
+
+		case reflect.Slice:
+			items, _ := node["items"].(map[string]any)
+			if items == nil {
+				t.Errorf("%s: items missed in schema", path)
+				return
+			}
+			check(path+"[]", items, typ.Elem())
+
+		case reflect.Struct:
+			props, _ := node["properties"].(map[string]any)
+			required, _ := node["required"].([]any)
+
+			if node["additionalProperties"] != false {
+				t.Errorf("%s: additionalProperties must be false",
+					path)
+			}
+
+			fields := make(map[string]struct{})
+			for i := 0; i < typ.NumField(); i++ {
+				fld := typ.Field(i)
+				name, _, _ := strings.Cut(fld.Tag.Get("json"), ",")
+				fields[name] = struct{}{}
+
+				prop, ok := props[name].(map[string]any)
+				if !ok {
+					t.Errorf("%s.%s: missed in schema", path, name)
+					continue
+				}
+
+				check(path+"."+name, prop, fld.Type)
+			}
+
+			for name := range props {
+				if _, ok := fields[name]; !ok {
+					t.Errorf("%s.%s: not in output", path, name)
+				}
+			}
+
+			if len(required) != len(props) {
+				t.Errorf("%s: all properties must be required",
+					path)
+			}
+		}
+	}
+
+	check("$", schema.root, reflect.TypeOf(jsonOutput{}))
+}
@@ -44,7 +44,7 @@ const (
 	ServiceLPD                           // LPD printer
 	ServiceAppSocket                     // AppSocket (JetDirect) printer
 	ServiceWSD                           // WSD printer or scanner
-	ServiceUSB                           // USB printer
+	ServiceUSB                           // USB printer or scanner
 )
 
 // String returns ServiceProto name, for debugging
@@ -0,0 +1,347 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// HTTP status listener for long-running discovery
+
+package discovery
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// DefaultStatusAddr is the default listen address of the
+// [StatusServer]. Status is never exposed beyond the localhost,
+// unless explicitly requested.
+const DefaultStatusAddr = "127.0.0.1:0"
+
+// DefaultStatusAuditLimit is the default count of the most recent
+// records, returned by the /audit endpoint of the [StatusServer].
+const DefaultStatusAuditLimit = 100
+
+// StatusMethods lists HTTP methods, accepted by the [StatusServer].
+var StatusMethods = []string{"GET", "HEAD"}
+
+// Health values, as reported by the [StatusServer]:
+const (
+	HealthOK       = "ok"       // Fully functional
+	HealthDegraded = "degraded" // Works, using fallbacks
+	HealthFailed   = "failed"   // Some capabilities lost
+)
+
+// StatusOptions contains the [StatusServer] options.
+type StatusOptions struct {
+	// Addr is the listen address. If "", DefaultStatusAddr
+	// is used.
+	Addr string
+
+	// Token, if not "", is the bearer token, required to
+	// access the status ("Authorization: Bearer <token>").
+	Token string
+}
+
+// StatusServer is the read-only HTTP status listener of the
+// long-running discovery [Client] (i.e., feeding a GUI or the
+// proxy's auto-mapping).
+//
+// It serves the following endpoints:
+//   - /healthz - overall and per-backend health. Responds with
+//     the http.StatusServiceUnavailable status, if some backend
+//     lost some of its capabilities without fallback (see
+//     [DegradedBackend]).
+//   - /devices - the current snapshot of discovered devices,
+//     in the format of the [FormatJSON].
+//   - /audit   - the most recent changes of device identities
+//     (see [Identity]), newest first. The "limit" query
+//     parameter overrides the [DefaultStatusAuditLimit].
+//
+// All responses are JSON.
+type StatusServer struct {
+	clnt     *Client           // Discovery client
+	token    string            // Bearer token, "" if none
+	srvr     *transport.Server // HTTP server
+	listener net.Listener      // Server's listener
+	ctx      context.Context   // For logging
+}
+
+// statusHealth is the /healthz response.
+type statusHealth struct {
+	Status   string                `json:"status"`
+	Backends []statusBackendHealth `json:"backends"`
+}
+
+// statusBackendHealth is the per-backend health.
+type statusBackendHealth struct {
+	Name     string              `json:"name"`
+	Status   string              `json:"status"`
+	Degraded []statusDegradation `json:"degraded"`
+}
+
+// statusDegradation is the Degradation, as represented
+// in the /healthz response.
+type statusDegradation struct {
+	Capability string `json:"capability"`
+	Fallback   string `json:"fallback"`
+	Error      string `json:"error"`
+}
+
+// statusAudit is the /audit response.
+type statusAudit struct {
+	Records []statusAuditRecord `json:"records"`
+}
+
+// statusAuditRecord is the IdentityRecord, as represented
+// in the /audit response.
+type statusAuditRecord struct {
+	LocalID string    `json:"local_id"`
+	Time    time.Time `json:"time"`
+	UUID    string    `json:"uuid"`
+	Addrs   []string  `json:"addrs"`
+}
+
+// NewStatusServer creates a new [StatusServer] and starts serving
+// the status of the [Client].
+//
+// The provided [context.Context] is used for logging.
+func NewStatusServer(ctx context.Context, clnt *Client,
+	opts StatusOptions) (*StatusServer, error) {
+
+	addr := opts.Addr
+	if addr == "" {
+		addr = DefaultStatusAddr
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := &StatusServer{
+		clnt:     clnt,
+		token:    opts.Token,
+		listener: listener,
+		ctx:      ctx,
+	}
+
+	ss.srvr = transport.NewServer(ctx, nil, ss)
+	go ss.srvr.Serve(listener)
+
+	log.Debug(ctx, "status: listening at %s", listener.Addr())
+
+	return ss, nil
+}
+
+// Addr returns the address, the StatusServer is listening at.
+func (ss *StatusServer) Addr() net.Addr {
+	return ss.listener.Addr()
+}
+
+// Close closes the StatusServer.
+func (ss *StatusServer) Close() {
+	ss.srvr.Close()
+}
+
+// ServeHTTP handles incoming HTTP requests.
+// It implements [http.Handler] interface.
+func (ss *StatusServer) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
+	query := transport.NewServerQuery(w, rq)
+	defer query.Finish()
+
+	if !ss.authorized(rq) {
+		query.ResponseHeader().Set("WWW-Authenticate", "Bearer")
+		query.Reject(http.StatusUnauthorized, nil)
+		return
+	}
+
+	switch query.RequestMethod() {
+	case "GET", "HEAD":
+	default:
+		query.RejectMethod(StatusMethods...)
+		return
+	}
+
+	query.NoCache()
+
+	switch query.RequestURL().Path {
+	case "/healthz":
+		ss.serveHealth(query)
+	case "/devices":
+		ss.serveDevices(query)
+	case "/audit":
+		ss.serveAudit(query)
+	default:
+		query.Reject(http.StatusNotFound, nil)
+	}
+}
+
+// authorized checks the bearer token of the request.
+func (ss *StatusServer) authorized(rq *http.Request) bool {
+	if ss.token == "" {
+		return true
+	}
+
+	auth := rq.Header.Get("Authorization")
+	scheme, token, _ := strings.Cut(auth, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token),
+		[]byte(ss.token)) == 1
+}
+
+// serveHealth handles the /healthz requests.
+func (ss *StatusServer) serveHealth(query *transport.ServerQuery) {
+	health := ss.clnt.health()
+
+	status := http.StatusOK
+	if health.Status == HealthFailed {
+		status = http.StatusServiceUnavailable
+	}
+
+	ss.sendJSON(query, status, health)
+}
+
+// serveDevices handles the /devices requests.
+func (ss *StatusServer) serveDevices(query *transport.ServerQuery) {
+	devices, _ := ss.clnt.GetDevices(query.RequestContext(), ModeSnapshot)
+
+	query.ResponseHeader().Set("Content-Type", "application/json")
+	query.WriteHeader(http.StatusOK)
+	FormatJSON(query, devices)
+}
+
+// serveAudit handles the /audit requests.
+func (ss *StatusServer) serveAudit(query *transport.ServerQuery) {
+	limit := DefaultStatusAuditLimit
+	if s := query.RequestURL().Query().Get("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			err = errors.New("invalid limit")
+			query.Reject(http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	audit := statusAudit{Records: []statusAuditRecord{}}
+	for _, ident := range ss.clnt.Identities() {
+		for _, rec := range ident.History {
+			jrec := statusAuditRecord{
+				LocalID: ident.LocalID,
+				Time:    rec.Time,
+				UUID:    formatUUID(rec.UUID),
+				Addrs:   make([]string, len(rec.Addrs)),
+			}
+
+			for i, addr := range rec.Addrs {
+				jrec.Addrs[i] = addr.String()
+			}
+
+			audit.Records = append(audit.Records, jrec)
+		}
+	}
+
+	sort.SliceStable(audit.Records, func(i, j int) bool {
+		return audit.Records[i].Time.After(audit.Records[j].Time)
+	})
+
+	if len(audit.Records) > limit {
+		audit.Records = audit.Records[:limit]
+	}
+
+	ss.sendJSON(query, http.StatusOK, audit)
+}
+
+// sendJSON sends the JSON response.
+func (ss *StatusServer) sendJSON(query *transport.ServerQuery,
+	status int, v any) {
+
+	query.ResponseHeader().Set("Content-Type", "application/json")
+	query.WriteHeader(status)
+
+	enc := json.NewEncoder(query)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(v)
+	if err != nil {
+		log.Debug(ss.ctx, "status: %s", err)
+	}
+}
+
+// health returns the overall and per-backend health of the Client.
+//
+// Backend is degraded, if it reports degradations (see
+// [DegradedBackend]), and failed, if some of them have no fallback.
+func (clnt *Client) health() statusHealth {
+	health := statusHealth{
+		Status:   HealthOK,
+		Backends: []statusBackendHealth{},
+	}
+
+	clnt.lock.Lock()
+	names := make([]string, 0, len(clnt.backends))
+	for bk := range clnt.backends {
+		names = append(names, bk.Name())
+	}
+	clnt.lock.Unlock()
+
+	sort.Strings(names)
+	degraded := clnt.Degraded()
+
+	for _, name := range names {
+		bh := statusBackendHealth{
+			Name:     name,
+			Status:   HealthOK,
+			Degraded: []statusDegradation{},
+		}
+
+		for _, d := range degraded {
+			if d.Backend != name {
+				continue
+			}
+
+			jd := statusDegradation{
+				Capability: d.Capability,
+				Fallback:   d.Fallback,
+			}
+
+			if d.Err != nil {
+				jd.Error = d.Err.Error()
+			}
+
+			bh.Degraded = append(bh.Degraded, jd)
+
+			switch {
+			case d.Fallback == "":
+				bh.Status = HealthFailed
+			case bh.Status == HealthOK:
+				bh.Status = HealthDegraded
+			}
+		}
+
+		switch {
+		case bh.Status == HealthFailed:
+			health.Status = HealthFailed
+		case bh.Status == HealthDegraded && health.Status == HealthOK:
+			health.Status = HealthDegraded
+		}
+
+		health.Backends = append(health.Backends, bh)
+	}
+
+	return health
+}
@@ -0,0 +1,187 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// HTTP status listener tests
+
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// TestStatusServer tests StatusServer
+func TestStatusServer(t *testing.T) {
+	ctx := context.Background()
+	clnt := NewClientTm(ctx, 100*time.Millisecond, 100*time.Millisecond)
+	defer clnt.Close()
+
+	// Backend with the single printer
+	usb := NewMockBackend("usb")
+	uid := UnitID{
+		DNSSDName: "Test Printer",
+		UUID:      uuid.Random(),
+		SvcType:   ServicePrinter,
+		SvcProto:  ServiceIPP,
+	}
+
+	usb.AddEvent(&EventAddUnit{ID: uid})
+	usb.AddEvent(&EventPrinterParameters{
+		ID:        uid,
+		MakeModel: "Test Make Model",
+	})
+	usb.AddEvent(&EventAddEndpoint{
+		ID:       uid,
+		Endpoint: "ipp://192.168.1.100/ipp/print",
+	})
+
+	// Backend that will degrade
+	dnssd := &testDegradedBackend{MockBackend: NewMockBackend("dnssd")}
+
+	clnt.AddBackend(usb)
+	clnt.AddBackend(dnssd)
+	clnt.flush()
+
+	ss, err := NewStatusServer(ctx, clnt, StatusOptions{Token: "secret"})
+	if err != nil {
+		t.Fatalf("NewStatusServer: %s", err)
+	}
+	defer ss.Close()
+
+	base := "http://" + ss.Addr().String()
+
+	get := func(path, token string, v any) int {
+		rq, _ := http.NewRequest("GET", base+path, nil)
+		if token != "" {
+			rq.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rsp, err := http.DefaultClient.Do(rq)
+		if err != nil {
+			t.Fatalf("GET %s: %s", path, err)
+		}
+		defer rsp.Body.Close()
+
+		if v != nil && rsp.StatusCode/100 != 4 {
+			err = json.NewDecoder(rsp.Body).Decode(v)
+			if err != nil {
+				t.Errorf("GET %s: %s", path, err)
+			}
+		}
+
+		return rsp.StatusCode
+	}
+
+	// Token is required
+	if status := get("/healthz", "", nil); status != http.StatusUnauthorized {
+		t.Errorf("no token: status %d", status)
+	}
+
+	if status := get("/healthz", "wrong", nil); status != http.StatusUnauthorized {
+		t.Errorf("wrong token: status %d", status)
+	}
+
+	// Healthy backends
+	var health statusHealth
+	if status := get("/healthz", "secret", &health); status != http.StatusOK {
+		t.Errorf("/healthz: status %d", status)
+	}
+
+	if health.Status != HealthOK || len(health.Backends) != 2 ||
+		health.Backends[0].Name != "dnssd" ||
+		health.Backends[1].Name != "usb" {
+		t.Errorf("/healthz: %+v", health)
+	}
+
+	// Devices
+	var devices jsonOutput
+	if status := get("/devices", "secret", &devices); status != http.StatusOK {
+		t.Errorf("/devices: status %d", status)
+	}
+
+	if len(devices.Devices) != 1 ||
+		devices.Devices[0].MakeModel != "Test Make Model" {
+		t.Fatalf("/devices: %+v", devices)
+	}
+
+	// Audit
+	var audit statusAudit
+	if status := get("/audit", "secret", &audit); status != http.StatusOK {
+		t.Errorf("/audit: status %d", status)
+	}
+
+	if len(audit.Records) == 0 ||
+		audit.Records[0].LocalID != devices.Devices[0].LocalID {
+		t.Errorf("/audit: %+v", audit)
+	}
+
+	if status := get("/audit?limit=0", "secret", nil); status != http.StatusBadRequest {
+		t.Errorf("/audit?limit=0: status %d", status)
+	}
+
+	// Degraded backend with fallback doesn't fail health
+	dnssd.degraded = []Degradation{
+		{
+			Backend:    "dnssd",
+			Capability: "mDNS",
+			Fallback:   "unicast DNS-SD",
+		},
+	}
+
+	health = statusHealth{}
+	if status := get("/healthz", "secret", &health); status != http.StatusOK {
+		t.Errorf("/healthz: status %d", status)
+	}
+
+	if health.Status != HealthDegraded ||
+		health.Backends[0].Status != HealthDegraded ||
+		health.Backends[1].Status != HealthOK {
+		t.Errorf("/healthz: %+v", health)
+	}
+
+	// Degraded backend without fallback makes it unhealthy
+	dnssd.degraded = append(dnssd.degraded, Degradation{
+		Backend:    "dnssd",
+		Capability: "unicast DNS-SD",
+		Err:        context.DeadlineExceeded,
+	})
+
+	health = statusHealth{}
+	status := get("/healthz", "secret", &health)
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("/healthz: status %d", status)
+	}
+
+	if health.Status != HealthFailed ||
+		health.Backends[0].Status != HealthFailed ||
+		len(health.Backends[0].Degraded) != 2 ||
+		health.Backends[0].Degraded[1].Error !=
+			context.DeadlineExceeded.Error() {
+		t.Errorf("/healthz: %+v", health)
+	}
+
+	// Unknown path and method
+	if status := get("/unknown", "secret", nil); status != http.StatusNotFound {
+		t.Errorf("/unknown: status %d", status)
+	}
+
+	rq, _ := http.NewRequest("POST", base+"/healthz", nil)
+	rq.Header.Set("Authorization", "Bearer secret")
+	rsp, err := http.DefaultClient.Do(rq)
+	if err != nil {
+		t.Fatalf("POST: %s", err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %d", rsp.StatusCode)
+	}
+}
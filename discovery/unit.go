@@ -49,12 +49,14 @@ type unit struct {
 	PPDModel        string       // Model name
 	Params          any          // PrinterParameters or ScannerParameters
 	Endpoints       []string     // Unit endpoints
+	Staging         []string     // Endpoints still on staging
 	Addrs           []netip.Addr // Addresses that unit use
 }
 
 // Merge merges two units
 func (un *unit) Merge(un2 unit) {
 	un.Endpoints = endpointsMerge(un.Endpoints, un2.Endpoints)
+	un.Staging = endpointsMerge(un.Staging, un2.Staging)
 	un.Addrs = addrsMerge(un.Addrs, un2.Addrs)
 }
 
@@ -116,6 +118,7 @@ func (un unit) Export() any {
 //	SvcType    - service type, printer/scanner/faxout
 //	SvcProto   - service protocol, i.e., IPP, LPD, eSCL etc
 //	Serial     - device serial number, if appropriate (i.e., for USB)
+//	MACAddr    - device MAC address, if known to backend
 type UnitID struct {
 	DNSSDName string       // DNS-SD name, "" if not available
 	UUID      uuid.UUID    // uuid.NilUUID if not available
@@ -127,6 +130,7 @@ type UnitID struct {
 	SvcProto  ServiceProto // Service protocol
 	USBSerial string       // "" if not avaliable
 	USBHWID   string       // "" if not avaliable
+	MACAddr   string       // "" if not avaliable
 }
 
 // SameDevice reports if two [UnitID]s belong to the same device.
@@ -201,5 +205,10 @@ func (id UnitID) MarshalLog() []byte {
 		lines = append(lines, line)
 	}
 
+	if id.MACAddr != "" {
+		line = fmt.Sprintf("MAC:       %s", id.MACAddr)
+		lines = append(lines, line)
+	}
+
 	return []byte(strings.Join(lines, "\n"))
 }
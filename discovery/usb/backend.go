@@ -0,0 +1,94 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// USB device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// USB backend
+
+package usb
+
+import (
+	"context"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// backend is the [discovery.Backend] for USB device discovery.
+type backend struct {
+	ctx       context.Context       // For logging
+	queue     *discovery.Eventqueue // Event queue
+	sysfs     string                // Sysfs root, normally "/sys"
+	ippusbDir string                // ipp-usb state directory
+	ready     chan struct{}         // Closed when devices are enumerated
+}
+
+var _ = discovery.FastBackend(&backend{})
+
+// NewBackend creates a new [discovery.Backend] for USB device discovery.
+//
+// Devices are enumerated via sysfs, without libusb. On platforms
+// other than Linux, this backend discovers nothing.
+func NewBackend(ctx context.Context) (discovery.Backend, error) {
+	return newBackend(ctx, "/sys", transport.IPPUSBStateDir), nil
+}
+
+// newBackend creates a new USB backend with the specified sysfs
+// root and ipp-usb state directory.
+func newBackend(ctx context.Context, sysfs, ippusbDir string) *backend {
+	// Set log prefix
+	ctx = log.WithPrefix(ctx, "usb")
+
+	back := &backend{
+		ctx:       ctx,
+		sysfs:     sysfs,
+		ippusbDir: ippusbDir,
+		ready:     make(chan struct{}),
+	}
+
+	return back
+}
+
+// Ready returns a channel that is closed when all devices are
+// enumerated and reported.
+func (back *backend) Ready() <-chan struct{} {
+	return back.ready
+}
+
+// Name returns backend name.
+func (back *backend) Name() string {
+	return "usb"
+}
+
+// Start starts Backend operations.
+func (back *backend) Start(queue *discovery.Eventqueue) {
+	back.queue = queue
+	defer close(back.ready)
+
+	log.Debug(back.ctx, "backend started")
+
+	devices, err := sysfsEnumerate(back.sysfs)
+	if err != nil {
+		log.Error(back.ctx, "%s", err)
+		return
+	}
+
+	for _, dev := range devices {
+		dev.ippusb = transport.DetectIPPUSB(back.ippusbDir,
+			dev.vid, dev.pid, dev.serial)
+
+		log.Debug(back.ctx, "found: %s %s (%s) serial=%q ippusb=%v",
+			dev.sysname, dev.HWID(), dev.MakeModel(), dev.serial,
+			dev.ippusb != nil)
+
+		for _, evnt := range dev.Events() {
+			queue.Push(evnt)
+		}
+	}
+}
+
+// Close closes the backend
+func (back *backend) Close() {
+}
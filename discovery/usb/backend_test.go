@@ -0,0 +1,116 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// USB device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// USB backend test
+
+//go:build linux
+
+package usb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+)
+
+// TestBackend tests USB discovery against the fixture sysfs tree
+func TestBackend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clnt := discovery.NewClient(ctx)
+	defer clnt.Close()
+
+	clnt.AddBackend(newBackend(ctx, "testdata/sys", "testdata/ipp-usb/dev"))
+
+	// The first wave is resolved when backend reports that all
+	// devices are enumerated and their events are handled. The
+	// FirstWaveTime is only the safety limit.
+	rs := clnt.Discover(ctx, discovery.DiscoverOptions{
+		FirstWaveTime: 10 * time.Second,
+	})
+
+	devices, err := rs.FirstWave(ctx)
+	if err != nil {
+		t.Fatalf("FirstWave: %s", err)
+	}
+
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 devices, present %d", len(devices))
+	}
+
+	type result struct {
+		makeModel  string
+		serial     string
+		hwid       string
+		printProto []discovery.ServiceProto
+		printEndpt []string
+		scanProto  []discovery.ServiceProto
+	}
+
+	expected := map[string]result{
+		"Canon MF4400 Series": {
+			makeModel: "Canon MF4400 Series",
+			serial:    "A1B2C3",
+			hwid:      "04a9:27e8",
+			printProto: []discovery.ServiceProto{
+				discovery.ServiceIPP,
+				discovery.ServiceUSB,
+			},
+			printEndpt: []string{
+				"ipp://localhost:60000/ipp/print",
+				"usb://Canon/MF4400%20Series?serial=A1B2C3",
+			},
+			scanProto: []discovery.ServiceProto{
+				discovery.ServiceUSB,
+			},
+		},
+
+		"HP LaserJet 1020": {
+			makeModel: "HP LaserJet 1020",
+			serial:    "FN0AB12",
+			hwid:      "03f0:2b17",
+			printProto: []discovery.ServiceProto{
+				discovery.ServiceUSB,
+			},
+			printEndpt: []string{
+				"usb://HP/HP%20LaserJet%201020?serial=FN0AB12",
+			},
+		},
+	}
+
+	for _, dev := range devices {
+		present := result{
+			makeModel: dev.MakeModel,
+			serial:    dev.USBSerial,
+			hwid:      dev.USBHWID,
+		}
+
+		for _, un := range dev.PrintUnits {
+			present.printProto = append(present.printProto, un.Proto)
+			present.printEndpt = append(present.printEndpt,
+				un.Endpoints...)
+		}
+
+		for _, un := range dev.ScanUnits {
+			present.scanProto = append(present.scanProto, un.Proto)
+		}
+
+		exp, found := expected[dev.MakeModel]
+		if !found {
+			t.Errorf("unexpected device %q", dev.MakeModel)
+			continue
+		}
+
+		if !reflect.DeepEqual(exp, present) {
+			t.Errorf("%s:\nexpected: %#v\npresent:  %#v",
+				dev.MakeModel, exp, present)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// USB device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Discovered USB devices
+
+package usb
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// USB interface classes, we are interested in:
+const (
+	usbClassStillImage     = 0x06 // Still image (PTP/scanner)
+	usbClassPrinter        = 0x07 // Printer
+	usbClassVendorSpecific = 0xff // Vendor-specific
+)
+
+// usbNameSpace is the namespace for generating name-based UUIDs
+// of the USB devices.
+var usbNameSpace = uuid.MustParse("2d0b2c1f-5e46-4b4e-8f63-3b1f2c7c6a55")
+
+// device represents a discovered USB device.
+type device struct {
+	sysname      string   // Sysfs name, i.e., "1-1.2"
+	vid, pid     uint16   // Vendor and product IDs
+	manufacturer string   // Manufacturer name
+	product      string   // Product name
+	serial       string   // Serial number, "" if none
+	printer      bool     // Device has printer interface
+	scanner      bool     // Device has scanner interface
+	ippusb       *url.URL // ipp-usb bridge URL, nil if none
+}
+
+// HWID returns the device hardware ID, in the "vvvv:pppp" form.
+func (dev *device) HWID() string {
+	return fmt.Sprintf("%4.4x:%4.4x", dev.vid, dev.pid)
+}
+
+// MakeModel returns the device make and model.
+func (dev *device) MakeModel() string {
+	if strings.HasPrefix(dev.product, dev.manufacturer) {
+		return dev.product
+	}
+	return dev.manufacturer + " " + dev.product
+}
+
+// UUID returns the device UUID.
+//
+// USB devices don't have UUIDs, so we generate the name-based UUID
+// out of the device identity. As the same device (identified by the
+// same combination of vendor/product IDs and the serial number) may
+// be attached to different ports, port is not taken into account,
+// unless device doesn't have the serial number.
+func (dev *device) UUID() uuid.UUID {
+	name := dev.HWID() + ":" + dev.serial
+	if dev.serial == "" {
+		name += ":" + dev.sysname
+	}
+
+	return uuid.SHA1(usbNameSpace, name)
+}
+
+// URI returns the device URI in the CUPS-compatible form:
+//
+//	usb://Manufacturer/Product?serial=XXX
+func (dev *device) URI() string {
+	u := url.URL{
+		Scheme: "usb",
+		Host:   dev.manufacturer,
+		Path:   "/" + dev.product,
+	}
+
+	if dev.serial != "" {
+		u.RawQuery = "serial=" + url.QueryEscape(dev.serial)
+	}
+
+	return u.String()
+}
+
+// Events returns discovery events for the device.
+//
+// The device is reported as a set of units with the same UUID,
+// so the discovery system merges them into the single device:
+//   - USB printer unit, if device has printer interface
+//   - USB scanner unit, if device has scanner interface
+//   - IPP printer unit, if device is bridged by ipp-usb
+func (dev *device) Events() []discovery.Event {
+	var events []discovery.Event
+
+	id := discovery.UnitID{
+		UUID:      dev.UUID(),
+		Realm:     discovery.RealmUSB,
+		USBSerial: dev.serial,
+		USBHWID:   dev.HWID(),
+	}
+
+	mkmodel := dev.MakeModel()
+
+	printerParams := func(id discovery.UnitID) discovery.Event {
+		return &discovery.EventPrinterParameters{
+			ID:              id,
+			MakeModel:       mkmodel,
+			PPDManufacturer: dev.manufacturer,
+			PPDModel:        dev.product,
+			Printer: discovery.PrinterParameters{
+				PSProduct: "(" + dev.product + ")",
+			},
+		}
+	}
+
+	if dev.printer {
+		id.SvcType = discovery.ServicePrinter
+		id.SvcProto = discovery.ServiceUSB
+
+		events = append(events,
+			&discovery.EventAddUnit{ID: id},
+			printerParams(id),
+			&discovery.EventAddEndpoint{ID: id, Endpoint: dev.URI()},
+		)
+	}
+
+	if dev.scanner {
+		id.SvcType = discovery.ServiceScanner
+		id.SvcProto = discovery.ServiceUSB
+
+		events = append(events,
+			&discovery.EventAddUnit{ID: id},
+			&discovery.EventScannerParameters{
+				ID:        id,
+				MakeModel: mkmodel,
+			},
+			&discovery.EventAddEndpoint{ID: id, Endpoint: dev.URI()},
+		)
+	}
+
+	if dev.ippusb != nil {
+		id.SvcType = discovery.ServicePrinter
+		id.SvcProto = discovery.ServiceIPP
+
+		u := *dev.ippusb
+		u.Scheme = "ipp"
+		u.Path = "/ipp/print"
+
+		events = append(events,
+			&discovery.EventAddUnit{ID: id},
+			printerParams(id),
+			&discovery.EventAddEndpoint{ID: id, Endpoint: u.String()},
+		)
+	}
+
+	return events
+}
@@ -0,0 +1,139 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// USB device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Sysfs-based USB devices enumeration -- the Linux version
+
+package usb
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sysfsEnumerate enumerates USB printers and scanners, using
+// the sysfs, mounted at the root directory (normally, "/sys").
+//
+// Each USB device appears in the /sys/bus/usb/devices directory
+// as an entry named by its bus path (i.e., "1-1.2"), and each of
+// its interfaces as an entry named "device:config.interface"
+// (i.e., "1-1.2:1.0").
+//
+// The device is considered as a printer, if it has the printer
+// class interface (07), and as scanner, if it has either the still
+// image class interface (06), or the vendor-specific interface
+// (ff) in combination with the printer interface, which is the
+// typical layout of the USB MFPs.
+func sysfsEnumerate(root string) ([]*device, error) {
+	dir := filepath.Join(root, "bus", "usb", "devices")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort entries into devices and interfaces
+	var devnames []string
+	ifaces := make(map[string][]string)
+
+	for _, ent := range entries {
+		name := ent.Name()
+		if devname, _, ok := strings.Cut(name, ":"); ok {
+			ifaces[devname] = append(ifaces[devname], name)
+		} else {
+			devnames = append(devnames, name)
+		}
+	}
+
+	sort.Strings(devnames)
+
+	// Decode devices
+	var devices []*device
+	for _, devname := range devnames {
+		dev := sysfsDevice(dir, devname, ifaces[devname])
+		if dev != nil {
+			devices = append(devices, dev)
+		}
+	}
+
+	return devices, nil
+}
+
+// sysfsDevice decodes the USB device. It returns nil, if
+// device cannot be decoded or if it is not printer or scanner.
+func sysfsDevice(dir, devname string, ifaces []string) *device {
+	path := filepath.Join(dir, devname)
+
+	vid, err := sysfsReadHex(filepath.Join(path, "idVendor"))
+	if err != nil {
+		return nil
+	}
+
+	pid, err := sysfsReadHex(filepath.Join(path, "idProduct"))
+	if err != nil {
+		return nil
+	}
+
+	dev := &device{
+		sysname:      devname,
+		vid:          uint16(vid),
+		pid:          uint16(pid),
+		manufacturer: sysfsReadString(filepath.Join(path, "manufacturer")),
+		product:      sysfsReadString(filepath.Join(path, "product")),
+		serial:       sysfsReadString(filepath.Join(path, "serial")),
+	}
+
+	// Classify device by its interfaces
+	var vendorSpecific bool
+	for _, iface := range ifaces {
+		class, err := sysfsReadHex(
+			filepath.Join(dir, iface, "bInterfaceClass"))
+		if err != nil {
+			continue
+		}
+
+		switch class {
+		case usbClassPrinter:
+			dev.printer = true
+		case usbClassStillImage:
+			dev.scanner = true
+		case usbClassVendorSpecific:
+			vendorSpecific = true
+		}
+	}
+
+	if vendorSpecific && dev.printer {
+		dev.scanner = true
+	}
+
+	if !dev.printer && !dev.scanner {
+		return nil
+	}
+
+	return dev
+}
+
+// sysfsReadString reads the sysfs attribute as string.
+// It returns "" if attribute is not available.
+func sysfsReadString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// sysfsReadHex reads the sysfs attribute as hexadecimal number.
+func sysfsReadHex(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 16, 16)
+}
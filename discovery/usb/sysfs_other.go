@@ -0,0 +1,19 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// USB device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Sysfs-based USB devices enumeration -- the portable fallback
+
+//go:build !linux
+
+package usb
+
+// sysfsEnumerate enumerates USB printers and scanners.
+//
+// Sysfs is Linux-specific, so the portable version always
+// returns the empty list.
+func sysfsEnumerate(root string) ([]*device, error) {
+	return nil, nil
+}
@@ -10,33 +10,85 @@ package wsdd
 
 import (
 	"context"
+	"errors"
+	"net"
 	"net/netip"
+	"sync"
+	"sync/atomic"
 
 	"github.com/OpenPrinting/go-mfp/discovery"
 	"github.com/OpenPrinting/go-mfp/log"
 	"github.com/OpenPrinting/go-mfp/proto/wsd"
 )
 
+// Degraded capabilities:
+const (
+	capMulticastRecv  = "multicast reception"
+	capMulticastProbe = "multicast probes"
+	capUDP            = "UDP sockets"
+)
+
+// Fallbacks of the degraded capabilities:
+const (
+	fallbackProbes   = "probes and directed probes"
+	fallbackDirected = "directed probes to known addresses"
+)
+
 // backend is the [discovery.Backend] for WSD device discovery.
 type backend struct {
-	ctx   context.Context       // For logging and backend.Close
-	queue *discovery.Eventqueue // Event queue
-	links *links                // Per-local address links
-	units *units                // Discovered units
-	mex   *mexGetter            // Metadata getter
-	res   *urlResolver          // URL resolver
+	ctx    context.Context       // For logging and backend.Close
+	queue  *discovery.Eventqueue // Event queue
+	links  *links                // Per-local address links
+	units  *units                // Discovered units
+	mex    *mexGetter            // Metadata getter
+	res    *urlResolver          // URL resolver
+	listen listenUDPFunc         // UDP socket factory
+
+	// Capabilities, not available to the backend
+	degraded     []discovery.Degradation
+	degradedLock sync.Mutex
+
+	// Counters of dropped hostile or malformed messages
+	droppedLimit  atomic.Uint64 // Dropped due to wsd.LimitError
+	droppedStrict atomic.Uint64 // Dropped due to wsd.StrictError
 }
 
+// backend implements discovery.DegradedBackend
+var _ = discovery.DegradedBackend(&backend{})
+
+// listenUDPFunc is the factory of UDP sockets. By default,
+// net.ListenUDP is used. Tests replace it to simulate failures
+// of socket operations.
+type listenUDPFunc func(network string,
+	laddr *net.UDPAddr) (*net.UDPConn, error)
+
 // NewBackend creates a new [discovery.Backend] for WSD device discovery.
+//
+// If backend lacks privileges to receive WS-Discovery multicasts
+// (i.e., in containers, where binding the UDP port 3702 or joining
+// multicast groups is not allowed), it doesn't fail, but falls back
+// to receiving only responses to its own probes, and to directed
+// probes to the known device addresses. Degraded capabilities are
+// reported via the [discovery.DegradedBackend] interface.
 func NewBackend(ctx context.Context) (discovery.Backend, error) {
+	return newBackend(ctx, net.ListenUDP)
+}
+
+// newBackend creates a new backend with the specified
+// UDP socket factory.
+func newBackend(ctx context.Context, listen listenUDPFunc) (*backend, error) {
 	// Set log prefix
 	ctx = log.WithPrefix(ctx, "wsdd")
 
 	// Create backend structure
 	back := &backend{
-		ctx: ctx,
+		ctx:    ctx,
+		listen: listen,
 	}
 
+	// Probe capabilities
+	back.probeUDP()
+
 	// Create links
 	var err error
 	back.links, err = newLinks(back)
@@ -72,6 +124,67 @@ func (back *backend) Close() {
 	back.res.Close()
 }
 
+// Degraded returns capabilities, currently not available to the
+// backend. It implements the [discovery.DegradedBackend] interface.
+func (back *backend) Degraded() []discovery.Degradation {
+	back.degradedLock.Lock()
+	defer back.degradedLock.Unlock()
+
+	if back.degraded == nil {
+		return nil
+	}
+
+	return append([]discovery.Degradation(nil), back.degraded...)
+}
+
+// degrade marks capability as degraded. The same capability
+// is reported only once.
+func (back *backend) degrade(capability, fallback string, err error) {
+	back.degradedLock.Lock()
+	defer back.degradedLock.Unlock()
+
+	for _, d := range back.degraded {
+		if d.Capability == capability {
+			return
+		}
+	}
+
+	d := discovery.Degradation{
+		Backend:    back.Name(),
+		Capability: capability,
+		Fallback:   fallback,
+		Err:        err,
+	}
+
+	back.degraded = append(back.degraded, d)
+	back.warning("%s", d)
+}
+
+// isDegraded reports if capability is degraded.
+func (back *backend) isDegraded(capability string) bool {
+	back.degradedLock.Lock()
+	defer back.degradedLock.Unlock()
+
+	for _, d := range back.degraded {
+		if d.Capability == capability {
+			return true
+		}
+	}
+
+	return false
+}
+
+// probeUDP checks that backend can create UDP sockets at all.
+func (back *backend) probeUDP() {
+	conn, err := back.listen("udp", &net.UDPAddr{})
+	if err != nil {
+		back.degrade(capUDP, "", err)
+		return
+	}
+
+	conn.Close()
+}
+
 // input handles received UDP messages.
 func (back *backend) input(data []byte, from, to netip.AddrPort, ifidx int) {
 	// Silently drop looped packets
@@ -82,9 +195,9 @@ func (back *backend) input(data []byte, from, to netip.AddrPort, ifidx int) {
 	// Decode the message
 	back.debug("%d bytes received from %s%%%d", len(data), from, ifidx)
 
-	msg, err := wsd.DecodeMsg(data)
+	msg, err := wsd.DecodeMsg(data, nil)
 	if err != nil {
-		back.warning("%s", err)
+		back.inputError(err)
 		return
 	}
 
@@ -103,6 +216,30 @@ func (back *backend) input(data []byte, from, to netip.AddrPort, ifidx int) {
 	}
 }
 
+// inputError handles the received message decoding error.
+//
+// As WS-Discovery messages come via unauthenticated multicast,
+// messages that violate the decoding limits may be just a hostile
+// noise. They are counted and dropped, without flooding the log
+// with warnings.
+func (back *backend) inputError(err error) {
+	var limitErr *wsd.LimitError
+	var strictErr *wsd.StrictError
+
+	switch {
+	case errors.As(err, &limitErr):
+		cnt := back.droppedLimit.Add(1)
+		back.debug("%s (dropped: %d)", err, cnt)
+
+	case errors.As(err, &strictErr):
+		cnt := back.droppedStrict.Add(1)
+		back.debug("%s (dropped: %d)", err, cnt)
+
+	default:
+		back.warning("%s", err)
+	}
+}
+
 // Debug writes a LevelDebug message on behalf of the backend.
 func (back *backend) debug(format string, args ...any) {
 	log.Debug(back.ctx, format, args...)
@@ -0,0 +1,164 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// WSD device discovery
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// WSDD backend tests
+
+package wsdd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/discovery"
+	"github.com/OpenPrinting/go-mfp/internal/netstate"
+	"github.com/OpenPrinting/go-mfp/proto/wsd"
+)
+
+// testListenUDP returns the listenUDPFunc, that fails with EPERM,
+// if fail returns true for the requested address.
+func testListenUDP(fail func(laddr *net.UDPAddr) bool) listenUDPFunc {
+	return func(network string, laddr *net.UDPAddr) (*net.UDPConn, error) {
+		if fail(laddr) {
+			return nil, &net.OpError{
+				Op:   "listen",
+				Net:  network,
+				Addr: laddr,
+				Err:  os.NewSyscallError("bind", syscall.EPERM),
+			}
+		}
+
+		return net.ListenUDP(network, laddr)
+	}
+}
+
+// testDegradedCapabilities returns names of degraded capabilities
+func testDegradedCapabilities(back *backend) map[string]error {
+	caps := make(map[string]error)
+	for _, d := range back.Degraded() {
+		caps[d.Capability] = d.Err
+	}
+	return caps
+}
+
+// TestBackendNoMulticast tests the backend, that is not allowed
+// to receive multicasts.
+func TestBackendNoMulticast(t *testing.T) {
+	listen := testListenUDP(func(laddr *net.UDPAddr) bool {
+		return laddr.IP.IsMulticast()
+	})
+
+	back, err := newBackend(context.Background(), listen)
+	if err != nil {
+		t.Fatalf("newBackend: %s", err)
+	}
+
+	back.Start(discovery.NewEventqueue())
+	defer back.Close()
+
+	caps := testDegradedCapabilities(back)
+	if len(caps) != 1 {
+		t.Errorf("expected 1 degraded capability, present %v", caps)
+	}
+
+	if err := caps[capMulticastRecv]; !errors.Is(err, os.ErrPermission) {
+		t.Errorf("%s: expected EPERM, present %v", capMulticastRecv, err)
+	}
+
+	// Directed probes must be sent to the known addresses
+	device, err := net.ListenUDP("udp4",
+		&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer device.Close()
+
+	back.units.addrs.Add(netip.MustParseAddr("127.0.0.1"))
+
+	lo := netstate.AddrFromIPNet(net.IPNet{
+		IP:   net.IPv4(127, 0, 0, 1),
+		Mask: net.CIDRMask(8, 32),
+	}, netstate.MakeNetIf(1, "lo", netstate.NetIfMulticast))
+
+	l := &link{
+		parent: back.links,
+		addr:   lo,
+		dest: netip.AddrPortFrom(wsddMulticastIP4.Addr(),
+			uint16(device.LocalAddr().(*net.UDPAddr).Port)),
+	}
+
+	l.conn, err = newUconn(lo, 0, listen)
+	if err != nil {
+		t.Fatalf("newUconn: %s", err)
+	}
+	defer l.conn.Close()
+
+	l.updateProbeMsg()
+	l.sendProbes()
+
+	device.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var buf [65536]byte
+	n, err := device.Read(buf[:])
+	if err != nil {
+		t.Fatalf("directed probe not received: %s", err)
+	}
+
+	msg, err := wsd.DecodeMsg(buf[:n], nil)
+	if err != nil {
+		t.Fatalf("directed probe: %s", err)
+	}
+
+	if msg.Header.Action != wsd.ActProbe {
+		t.Errorf("directed probe: expected %s, present %s",
+			wsd.ActProbe, msg.Header.Action)
+	}
+}
+
+// TestBackendNoSockets tests the backend, that is not allowed
+// to create UDP sockets at all.
+func TestBackendNoSockets(t *testing.T) {
+	listen := testListenUDP(func(*net.UDPAddr) bool {
+		return true
+	})
+
+	back, err := newBackend(context.Background(), listen)
+	if err != nil {
+		t.Fatalf("newBackend: %s", err)
+	}
+
+	back.Start(discovery.NewEventqueue())
+	defer back.Close()
+
+	caps := testDegradedCapabilities(back)
+	for _, capability := range []string{capUDP, capMulticastRecv} {
+		if err := caps[capability]; !errors.Is(err, os.ErrPermission) {
+			t.Errorf("%s: expected EPERM, present %v",
+				capability, err)
+		}
+	}
+}
+
+// TestBackendPrivileged tests the backend, that has all
+// the necessary privileges.
+func TestBackendPrivileged(t *testing.T) {
+	back, err := newBackend(context.Background(), net.ListenUDP)
+	if err != nil {
+		t.Fatalf("newBackend: %s", err)
+	}
+
+	back.Start(discovery.NewEventqueue())
+	defer back.Close()
+
+	if _, found := testDegradedCapabilities(back)[capUDP]; found {
+		t.Errorf("%s: unexpectedly degraded", capUDP)
+	}
+}
@@ -10,7 +10,9 @@ package wsdd
 
 import (
 	"context"
+	"errors"
 	"net/netip"
+	"os"
 	"sync"
 
 	"github.com/OpenPrinting/go-mfp/internal/netstate"
@@ -40,17 +42,20 @@ type links struct {
 }
 
 // newLinks creates a new links structure
+//
+// If multicast sockets cannot be created (i.e., due to the lack
+// of privileges), multicast reception is marked as degraded.
+// In this case, only responses to own probes are received.
 func newLinks(back *backend) (*links, error) {
 	// Create multicast sockets
-	mconn4, err := newMconn(wsddMulticastIP4)
+	mconn4, err := newMconn(wsddMulticastIP4, back.listen)
 	if err != nil {
-		return nil, err
+		back.degrade(capMulticastRecv, fallbackProbes, err)
 	}
 
-	mconn6, err := newMconn(wsddMulticastIP6)
+	mconn6, err := newMconn(wsddMulticastIP6, back.listen)
 	if err != nil {
-		mconn4.Close()
-		return nil, err
+		back.degrade(capMulticastRecv, fallbackProbes, err)
 	}
 
 	// Create links structure
@@ -74,9 +79,12 @@ func (lt *links) Start() {
 	go lt.procNetmon()
 
 	// Start links.procMconn, one per connection
-	lt.doneMconn.Add(2)
-	go lt.procMconn(lt.mconn4)
-	go lt.procMconn(lt.mconn6)
+	for _, mc := range []*mconn{lt.mconn4, lt.mconn6} {
+		if mc != nil {
+			lt.doneMconn.Add(1)
+			go lt.procMconn(mc)
+		}
+	}
 }
 
 // Close closes links table and all links it owns.
@@ -86,8 +94,11 @@ func (lt *links) Close() {
 	lt.doneNetmon.Wait()
 
 	// Stop multicasts reception
-	lt.mconn4.Close()
-	lt.mconn6.Close()
+	for _, mc := range []*mconn{lt.mconn4, lt.mconn6} {
+		if mc != nil {
+			mc.Close()
+		}
+	}
 	lt.doneMconn.Wait()
 
 	// Close each individual link
@@ -250,9 +261,13 @@ func (l *link) procProber() {
 			// Open connection on demand
 			if l.conn == nil {
 				var err error
-				l.conn, err = newUconn(l.addr, 0)
+				l.conn, err = newUconn(l.addr, 0,
+					back.listen)
 				if err != nil {
 					back.debug("%s", err)
+					if errors.Is(err, os.ErrPermission) {
+						back.degrade(capUDP, "", err)
+					}
 				}
 
 				if l.conn != nil {
@@ -268,15 +283,64 @@ func (l *link) procProber() {
 
 		case schedSend:
 			if l.conn != nil {
-				l.conn.WriteToUDPAddrPort(l.probeMsg, l.dest)
-				back.debug("%s message sent to %s%%%s",
-					wsd.ActProbe, l.dest,
-					l.addr.Interface().Name())
+				l.sendProbes()
 			}
 		}
 	}
 }
 
+// sendProbes sends Probe to the multicast group.
+//
+// If multicast capabilities are degraded, Probe is also sent
+// directly to the known addresses of devices.
+func (l *link) sendProbes() {
+	back := l.parent.back
+	ifname := l.addr.Interface().Name()
+
+	_, err := l.conn.WriteToUDPAddrPort(l.probeMsg, l.dest)
+	if err != nil {
+		back.degrade(capMulticastProbe, fallbackDirected, err)
+	} else {
+		back.debug("%s message sent to %s%%%s",
+			wsd.ActProbe, l.dest, ifname)
+	}
+
+	if !back.isDegraded(capMulticastRecv) &&
+		!back.isDegraded(capMulticastProbe) {
+		return
+	}
+
+	for _, addr := range back.units.KnownAddrs() {
+		if !l.reachable(addr) {
+			continue
+		}
+
+		dest := netip.AddrPortFrom(addr, l.dest.Port())
+		_, err = l.conn.WriteToUDPAddrPort(l.probeMsg, dest)
+		if err != nil {
+			back.debug("%s message to %s%%%s: %s",
+				wsd.ActProbe, dest, ifname, err)
+		} else {
+			back.debug("%s message sent to %s%%%s",
+				wsd.ActProbe, dest, ifname)
+		}
+	}
+}
+
+// reachable reports if address may be reached via the link.
+// Link-local IPv6 addresses are reachable only via the link
+// of the same zone.
+func (l *link) reachable(addr netip.Addr) bool {
+	switch {
+	case addr.Is4() != l.addr.Addr().Is4():
+		return false
+	case addr.Is6() && addr.IsLinkLocalUnicast():
+		return addr.Zone() == l.addr.Interface().Name()
+	}
+
+	return true
+}
+
 // procReader runs on its own goroutine and receives messages from the l.conn.
 func (l *link) procReader() {
 	defer l.doneReader.Done()
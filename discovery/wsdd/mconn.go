@@ -26,8 +26,9 @@ type mconn struct {
 	closed       atomic.Bool    // Connection is closed
 }
 
-// newMconn creates a new multicast connection
-func newMconn(group netip.AddrPort) (*mconn, error) {
+// newMconn creates a new multicast connection, using the
+// listen function to create the UDP socket.
+func newMconn(group netip.AddrPort, listen listenUDPFunc) (*mconn, error) {
 	// Address must be multicast
 	if !group.Addr().IsMulticast() {
 		err := fmt.Errorf("%s not multicast", group.Addr())
@@ -55,7 +56,7 @@ func newMconn(group netip.AddrPort) (*mconn, error) {
 		network = "udp6"
 	}
 
-	conn, err := net.ListenUDP(network, addr)
+	conn, err := listen(network, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -34,6 +34,7 @@ type mexGetter struct {
 	back  *backend                    // Parent backend
 	http  http.Client                 // HTTP client
 	cache map[mexCacheID]*mexCacheEnt // Cached metadata
+	meta  *wsd.MetadataCache          // Metadata by target and version
 	lock  sync.Mutex                  // Access lock
 }
 
@@ -45,6 +46,7 @@ func newMexGetter(back *backend) *mexGetter {
 			Timeout: 5 * time.Second,
 		},
 		cache: make(map[mexCacheID]*mexCacheEnt),
+		meta:  wsd.NewMetadataCache(),
 	}
 
 	return mg
@@ -64,6 +66,13 @@ func (mg *mexGetter) Get(ctx context.Context,
 	ifidx int, target wsd.AnyURI,
 	xaddr *url.URL, ver uint64) []mexData {
 
+	// If target's metadata of this version is already known,
+	// don't query the device again.
+	if meta, found := mg.meta.Lookup(target, ver); found {
+		mg.back.debug("%s: metadata version %d cached", target, ver)
+		return []mexData{{meta, xaddr}}
+	}
+
 	// Create mexCacheID
 	id := mexCacheID{ifidx, target, xaddr.String()}
 
@@ -104,6 +113,14 @@ func (mg *mexGetter) Get(ctx context.Context,
 
 	// Update the cache entry
 	mg.cacheUpdate(id, ent, metadata)
+	if len(metadata) > 0 {
+		diff, updated := mg.meta.Update(target, ver,
+			metadata[0].Metadata)
+		if updated {
+			mg.back.debug("%s: metadata version %d: %s",
+				target, ver, diff)
+		}
+	}
 
 	// Return whatever we have
 	return ent.metadata
@@ -113,6 +130,9 @@ func (mg *mexGetter) Get(ctx context.Context,
 //
 // It returns new or existing cache entry and 'true' as a seconf
 // returned value, if existent cache entry was found for this if.
+//
+// Completed entry of the different MetadataVersion is considered
+// stale and replaced with the new one.
 func (mg *mexGetter) cacheLookup(id mexCacheID,
 	ver uint64) (*mexCacheEnt, bool) {
 
@@ -120,7 +140,7 @@ func (mg *mexGetter) cacheLookup(id mexCacheID,
 	defer mg.lock.Unlock()
 
 	ent := mg.cache[id]
-	if ent != nil {
+	if ent != nil && (ent.ver == ver || !ent.isDone()) {
 		return ent, true
 	}
 
@@ -149,6 +169,21 @@ func (mg *mexGetter) cacheUpdate(id mexCacheID,
 	ent.done()
 }
 
+// Evict purges all cached metadata of the target.
+//
+// It is called when target sends the Bye message.
+func (mg *mexGetter) Evict(target wsd.AnyURI) {
+	mg.lock.Lock()
+	for id := range mg.cache {
+		if id.target == target {
+			delete(mg.cache, id)
+		}
+	}
+	mg.lock.Unlock()
+
+	mg.meta.Evict(target)
+}
+
 // fetch fetches the metadata
 func (mg *mexGetter) fetch(ctx context.Context,
 	xaddrs []*url.URL, target wsd.AnyURI) []mexData {
@@ -239,7 +274,7 @@ func (mg *mexGetter) fetchHTTP(ctx context.Context,
 	mg.back.debug("POST %s: %s", xaddr, rsp.Status)
 
 	// Decode response
-	msg, err = wsd.DecodeMsg(data)
+	msg, err = wsd.DecodeMsg(data, nil)
 	if err != nil {
 		mg.back.warning("POST %s: %s", xaddr, err)
 		return
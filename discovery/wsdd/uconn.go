@@ -25,8 +25,10 @@ type uconn struct {
 	closed       atomic.Bool   // Connection is closed
 }
 
-// newUconn creates a new unicast connection
-func newUconn(local netstate.Addr, port uint16) (*uconn, error) {
+// newUconn creates a new unicast connection, using the
+// listen function to create the UDP socket.
+func newUconn(local netstate.Addr, port uint16,
+	listen listenUDPFunc) (*uconn, error) {
 	// Address must be unicast
 	if local.Addr().IsMulticast() {
 		err := fmt.Errorf("%s not unicast", local.Addr())
@@ -46,7 +48,7 @@ func newUconn(local netstate.Addr, port uint16) (*uconn, error) {
 		network = "udp6"
 	}
 
-	conn, err := net.ListenUDP(network, addr)
+	conn, err := listen(network, addr)
 	if err != nil {
 		return nil, err
 	}
@@ -10,6 +10,7 @@ package wsdd
 
 import (
 	"context"
+	"net/netip"
 	"net/url"
 	"strings"
 	"sync"
@@ -45,6 +46,7 @@ import (
 type units struct {
 	back  *backend                   // Parent backend
 	table map[discovery.UnitID]*unit // Discovered units
+	addrs generic.Set[netip.Addr]    // Known addresses of devices
 	lock  sync.Mutex                 // units.table lock
 }
 
@@ -54,6 +56,7 @@ func newUnits(back *backend) *units {
 	ut := &units{
 		back:  back,
 		table: make(map[discovery.UnitID]*unit),
+		addrs: generic.NewSet[netip.Addr](),
 	}
 
 	return ut
@@ -80,10 +83,29 @@ func (ut *units) InputFromUDP(msg wsd.Msg) {
 	}
 }
 
+// KnownAddrs returns known addresses of devices, i.e., source
+// addresses of the received Hello and ProbeMatches messages.
+func (ut *units) KnownAddrs() []netip.Addr {
+	ut.lock.Lock()
+	defer ut.lock.Unlock()
+
+	addrs := make([]netip.Addr, 0, ut.addrs.Count())
+	ut.addrs.ForEach(func(addr netip.Addr) {
+		addrs = append(addrs, addr)
+	})
+
+	return addrs
+}
+
 // handleBye handles received [wsd.Bye] message.
 //
 // Called under units.lock.
 func (ut *units) handleBye(msg wsd.Msg) {
+	bye := msg.Body.(wsd.Bye)
+	target := bye.EndpointReference.Address
+
+	ut.back.debug("Bye received from %s", target)
+	ut.back.mex.Evict(target)
 }
 
 // handleAnnounce is the common handler for WSD announce messages
@@ -100,6 +122,11 @@ func (ut *units) handleAnnounces(msg wsd.Msg) {
 	logmsg := log.Begin(ut.back.ctx)
 	defer logmsg.Commit()
 
+	// Remember device address for the directed probes
+	if from := msg.From.Addr(); from.IsValid() {
+		ut.addrs.Add(from)
+	}
+
 	// Parse and dispatch XAddrs. Log the event.
 	for _, ann := range anns {
 		target := ann.EndpointReference.Address
@@ -190,6 +217,9 @@ type unit struct {
 	types         wsd.Types                  // WSD service types
 	xaddrsSeen    *generic.LockedSet[string] // Known XAddrs
 	endpointsSeen *generic.LockedSet[string] // Known endpoints
+	metaVer       uint64                     // Last seen MetadataVersion
+	meta          *wsd.Metadata              // Last seen Metadata
+	metaLock      sync.Mutex                 // Access lock for meta
 	paramsSent    atomic.Bool                // EventXXXParameters reported
 	closing       atomic.Bool                // unit.close in progress
 	closewait     sync.WaitGroup             // for unit.close
@@ -231,8 +261,13 @@ func (un *unit) handleXaddrs(ifidx int, target wsd.AnyURI,
 
 	back := un.parent.back
 
+	// If MetadataVersion has changed, metadata needs to be
+	// refreshed even for already known XAddrs.
+	refresh := un.metaVer != ver
+	un.metaVer = ver
+
 	for _, xaddr := range xaddrs {
-		if !un.xaddrsSeen.TestAndAdd(xaddr.String()) {
+		if !un.xaddrsSeen.TestAndAdd(xaddr.String()) && !refresh {
 			continue
 		}
 
@@ -254,7 +289,20 @@ func (un *unit) handleMetadata(metadata []mexData) {
 		mdl := meta.ThisModel.ModelName.NeutralLang().String
 		adm := meta.ThisModel.PresentationURL
 
-		endpoints := un.extractMetadataEndpoints(meta)
+		diff, updated := un.updateMetadata(meta.Metadata)
+		if updated {
+			if len(diff.ThisModel) != 0 {
+				// Model description has changed; report
+				// updated parameters
+				un.paramsSent.Store(false)
+			}
+
+			if diff.EndpointsChanged() {
+				un.delStaleEndpoints(diff, meta.Metadata)
+			}
+		}
+
+		endpoints := un.extractMetadataEndpoints(meta.Metadata)
 
 		logmsg := log.Begin(un.ctx)
 		logmsg.Debug("Got %s metadata (from %s)",
@@ -294,8 +342,65 @@ func (un *unit) handleMetadata(metadata []mexData) {
 	}
 }
 
+// updateMetadata saves the Metadata as the last seen and returns
+// difference with the previously seen Metadata. If Metadata is seen
+// for the first time, the second returned value is false.
+func (un *unit) updateMetadata(meta wsd.Metadata) (wsd.MetadataDiff, bool) {
+	un.metaLock.Lock()
+	defer un.metaLock.Unlock()
+
+	prev := un.meta
+	un.meta = &meta
+
+	if prev == nil {
+		return wsd.MetadataDiff{}, false
+	}
+
+	return wsd.DiffMetadata(*prev, meta), true
+}
+
+// delStaleEndpoints sends EventDelEndpoint for endpoints of the
+// removed or changed hosted services, that are not present in
+// the new metadata anymore.
+func (un *unit) delStaleEndpoints(diff wsd.MetadataDiff, meta wsd.Metadata) {
+	t := un.wsdType()
+
+	stale := []wsd.ServiceMetadata{}
+	stale = append(stale, diff.Removed...)
+	for _, svcdiff := range diff.Changed {
+		stale = append(stale, svcdiff.Old)
+	}
+
+	current := generic.NewSet[string]()
+	for _, endpoint := range un.extractMetadataEndpoints(meta) {
+		current.Add(endpoint)
+	}
+
+	for _, svc := range stale {
+		if !svc.Types.Contains(t) {
+			continue
+		}
+
+		for _, ref := range svc.EndpointReference {
+			endpoint := string(ref.Address)
+			if current.Contains(endpoint) {
+				continue
+			}
+
+			u := urlParse(endpoint)
+			if u == nil {
+				continue
+			}
+
+			u = urlWithZone(u, un.id.Zone)
+			un.xaddrsSeen.Del(u.String())
+			un.delEndpoint(u)
+		}
+	}
+}
+
 // extractMetadataEndpoints extract and returns service endpoint URLs
-func (un *unit) extractMetadataEndpoints(meta mexData) []string {
+func (un *unit) extractMetadataEndpoints(meta wsd.Metadata) []string {
 	t := un.wsdType()
 
 	var endpoints []string
@@ -383,3 +488,20 @@ func (un *unit) sendEndpoint(u *url.URL) {
 
 	un.parent.back.queue.Push(evnt)
 }
+
+// delEndpoint sends EventDelEndpoint to the discovery system.
+func (un *unit) delEndpoint(u *url.URL) {
+	s := u.String()
+	if !un.endpointsSeen.TestAndDel(s) {
+		return
+	}
+
+	log.Debug(un.ctx, "%s: endpoint removed: %s", un.id.SvcType, s)
+
+	evnt := &discovery.EventDelEndpoint{
+		ID:       un.id,
+		Endpoint: s,
+	}
+
+	un.parent.back.queue.Push(evnt)
+}
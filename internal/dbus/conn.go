@@ -0,0 +1,504 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Minimal D-Bus client
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// D-Bus connection
+
+package dbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Well-known names of the message bus itself
+const (
+	BusName      = "org.freedesktop.DBus"
+	BusPath      = ObjectPath("/org/freedesktop/DBus")
+	BusInterface = "org.freedesktop.DBus"
+)
+
+// SystemBusDefaultAddress is the default address of the system
+// message bus, used when DBUS_SYSTEM_BUS_ADDRESS is not set.
+const SystemBusDefaultAddress = "unix:path=/var/run/dbus/system_bus_socket"
+
+// Conn is the connection to the D-Bus message bus.
+//
+// Method calls may be performed from multiple goroutines
+// simultaneously. Received signals are queued until
+// consumed by the [Conn.Signal].
+type Conn struct {
+	conn     net.Conn                 // Underlying connection
+	name     string                   // Our unique name
+	wlock    sync.Mutex               // Write lock
+	lock     sync.Mutex               // Access lock
+	serial   uint32                   // Last used serial
+	calls    map[uint32]chan *Message // Pending calls, by serial
+	signals  []*Message               // Received signals
+	sigReady chan struct{}            // Signals or error available
+	err      error                    // Connection error, if any
+	done     chan struct{}            // Closed when reader exits
+}
+
+// SystemBusAddress returns address of the system message bus.
+func SystemBusAddress() string {
+	if addr := os.Getenv("DBUS_SYSTEM_BUS_ADDRESS"); addr != "" {
+		return addr
+	}
+	return SystemBusDefaultAddress
+}
+
+// Dial connects to the message bus at the specified address,
+// authenticates and registers on the bus.
+//
+// The address may contain multiple semicolon-separated entries,
+// they are tried in order. Only the unix transport is supported.
+func Dial(ctx context.Context, addr string) (*Conn, error) {
+	var err error = fmt.Errorf("D-Bus: %q: no usable address", addr)
+
+	for _, entry := range strings.Split(addr, ";") {
+		network, path, err2 := parseAddress(entry)
+		if err2 != nil {
+			err = err2
+			continue
+		}
+
+		var d net.Dialer
+		var nc net.Conn
+		nc, err = d.DialContext(ctx, network, path)
+		if err != nil {
+			continue
+		}
+
+		var conn *Conn
+		conn, err = newConn(ctx, nc)
+		if err == nil {
+			return conn, nil
+		}
+	}
+
+	return nil, err
+}
+
+// parseAddress parses the single entry of the D-Bus server address
+// and returns network and address for net.Dial.
+func parseAddress(entry string) (network, addr string, err error) {
+	transport, params, _ := strings.Cut(entry, ":")
+	if transport != "unix" {
+		err = fmt.Errorf("D-Bus: %q: unsupported transport", entry)
+		return
+	}
+
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(param, "=")
+		value, err = url.PathUnescape(value)
+		if err != nil {
+			err = fmt.Errorf("D-Bus: %q: %w", entry, err)
+			return
+		}
+
+		switch key {
+		case "path":
+			return "unix", value, nil
+		case "abstract":
+			return "unix", "@" + value, nil
+		}
+	}
+
+	err = fmt.Errorf("D-Bus: %q: missed path", entry)
+	return
+}
+
+// newConn performs authentication over the established connection
+// and returns the new Conn.
+func newConn(ctx context.Context, nc net.Conn) (*Conn, error) {
+	// Interrupt the handshake if context is canceled
+	stop := context.AfterFunc(ctx, func() { nc.Close() })
+
+	rd := bufio.NewReader(nc)
+	err := authExternal(nc, rd)
+
+	if !stop() {
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	conn := &Conn{
+		conn:     nc,
+		calls:    make(map[uint32]chan *Message),
+		sigReady: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	go conn.reader(rd)
+
+	// Register on the bus
+	reply, err := conn.Call(ctx, BusName, BusPath, BusInterface,
+		"Hello", "")
+	if err == nil {
+		var ok bool
+		if len(reply) > 0 {
+			conn.name, ok = reply[0].(string)
+		}
+		if !ok {
+			err = errors.New("D-Bus: Hello: invalid reply")
+		}
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// authExternal performs the EXTERNAL authentication.
+func authExternal(nc net.Conn, rd *bufio.Reader) error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	_, err := nc.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n"))
+	if err != nil {
+		return err
+	}
+
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("D-Bus: auth: %w", err)
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("D-Bus: auth: %q", line)
+	}
+
+	_, err = nc.Write([]byte("BEGIN\r\n"))
+	return err
+}
+
+// Name returns the unique connection name, assigned by the bus.
+func (conn *Conn) Name() string {
+	return conn.name
+}
+
+// Close closes the connection.
+func (conn *Conn) Close() error {
+	conn.setError(ErrClosed)
+	err := conn.conn.Close()
+	<-conn.done
+	return err
+}
+
+// Done returns a channel that is closed when the connection
+// is closed or fails.
+func (conn *Conn) Done() <-chan struct{} {
+	return conn.done
+}
+
+// Err returns the connection error, nil if connection is alive.
+func (conn *Conn) Err() error {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	return conn.err
+}
+
+// Call calls the method and waits for reply.
+//
+// Arguments are marshaled according to the signature sig.
+// On success, the reply body is returned. Error replies are
+// returned as [*Error].
+func (conn *Conn) Call(ctx context.Context, dest string,
+	path ObjectPath, iface, member string, sig Signature,
+	args ...any) ([]any, error) {
+
+	msg := &Message{
+		Type:        TypeMethodCall,
+		Path:        path,
+		Interface:   iface,
+		Member:      member,
+		Destination: dest,
+		Signature:   sig,
+		Body:        args,
+	}
+
+	// Register the pending call
+	reply := make(chan *Message, 1)
+
+	conn.lock.Lock()
+	if conn.err != nil {
+		err := conn.err
+		conn.lock.Unlock()
+		return nil, err
+	}
+
+	msg.Serial = conn.nextSerial()
+	conn.calls[msg.Serial] = reply
+	conn.lock.Unlock()
+
+	defer func() {
+		conn.lock.Lock()
+		delete(conn.calls, msg.Serial)
+		conn.lock.Unlock()
+	}()
+
+	// Send the request
+	err := conn.send(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for reply
+	var rsp *Message
+	select {
+	case rsp = <-reply:
+	case <-conn.done:
+		// Reply may be received just before connection failure
+		select {
+		case rsp = <-reply:
+		default:
+			return nil, conn.Err()
+		}
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if rsp.Type == TypeError {
+		return nil, errorFromMessage(rsp)
+	}
+
+	return rsp.Body, nil
+}
+
+// CallNoReply calls the method without waiting for reply.
+// The remote side is asked not to send reply at all.
+func (conn *Conn) CallNoReply(dest string, path ObjectPath,
+	iface, member string, sig Signature, args ...any) error {
+
+	msg := &Message{
+		Type:        TypeMethodCall,
+		Flags:       FlagNoReplyExpected,
+		Path:        path,
+		Interface:   iface,
+		Member:      member,
+		Destination: dest,
+		Signature:   sig,
+		Body:        args,
+	}
+
+	conn.lock.Lock()
+	err := conn.err
+	msg.Serial = conn.nextSerial()
+	conn.lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return conn.send(msg)
+}
+
+// AddMatch adds the match rule for signals delivery.
+func (conn *Conn) AddMatch(ctx context.Context, rule string) error {
+	_, err := conn.Call(ctx, BusName, BusPath, BusInterface,
+		"AddMatch", "s", rule)
+	return err
+}
+
+// NameHasOwner reports whether the name has owner on the bus.
+func (conn *Conn) NameHasOwner(ctx context.Context,
+	name string) (bool, error) {
+
+	reply, err := conn.Call(ctx, BusName, BusPath, BusInterface,
+		"NameHasOwner", "s", name)
+	if err != nil {
+		return false, err
+	}
+
+	var owned, ok bool
+	if len(reply) > 0 {
+		owned, ok = reply[0].(bool)
+	}
+
+	if !ok {
+		return false, errors.New("D-Bus: NameHasOwner: invalid reply")
+	}
+
+	return owned, nil
+}
+
+// Signal returns the next received signal.
+//
+// It blocks until signal is available, connection fails or
+// context is canceled.
+func (conn *Conn) Signal(ctx context.Context) (*Message, error) {
+	for {
+		conn.lock.Lock()
+		if len(conn.signals) > 0 {
+			msg := conn.signals[0]
+			conn.signals[0] = nil
+			conn.signals = conn.signals[1:]
+			conn.lock.Unlock()
+			return msg, nil
+		}
+
+		err := conn.err
+		conn.lock.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-conn.sigReady:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// send sends the message
+func (conn *Conn) send(msg *Message) error {
+	data, err := msg.Encode()
+	if err != nil {
+		return err
+	}
+
+	conn.wlock.Lock()
+	_, err = conn.conn.Write(data)
+	conn.wlock.Unlock()
+
+	if err != nil {
+		conn.setError(err)
+	}
+
+	return err
+}
+
+// reader runs on its own goroutine and receives incoming messages.
+func (conn *Conn) reader(rd *bufio.Reader) {
+	defer close(conn.done)
+
+	for {
+		msg, err := ReadMessage(rd)
+		if err != nil {
+			conn.setError(err)
+			return
+		}
+
+		switch msg.Type {
+		case TypeMethodReturn, TypeError:
+			conn.lock.Lock()
+			reply := conn.calls[msg.ReplySerial]
+			delete(conn.calls, msg.ReplySerial)
+			conn.lock.Unlock()
+
+			if reply != nil {
+				reply <- msg
+			}
+
+		case TypeSignal:
+			conn.lock.Lock()
+			conn.signals = append(conn.signals, msg)
+			conn.lock.Unlock()
+			conn.wakeup()
+
+		case TypeMethodCall:
+			// We don't export any objects, but Peer interface
+			// must be answered, and other calls need error reply
+			// unless no reply is expected.
+			if msg.Flags&FlagNoReplyExpected == 0 {
+				conn.answer(msg)
+			}
+		}
+	}
+}
+
+// answer answers the incoming method call.
+func (conn *Conn) answer(call *Message) {
+	rsp := &Message{
+		Type:        TypeMethodReturn,
+		ReplySerial: call.Serial,
+		Destination: call.Sender,
+	}
+
+	switch {
+	case call.Interface == "org.freedesktop.DBus.Peer" &&
+		call.Member == "Ping":
+
+	case call.Interface == "org.freedesktop.DBus.Peer" &&
+		call.Member == "GetMachineId":
+		rsp.Signature = "s"
+		rsp.Body = []any{machineID()}
+
+	default:
+		rsp.Type = TypeError
+		rsp.ErrorName = "org.freedesktop.DBus.Error.UnknownMethod"
+		rsp.Signature = "s"
+		rsp.Body = []any{fmt.Sprintf("Unknown method %s.%s",
+			call.Interface, call.Member)}
+	}
+
+	conn.lock.Lock()
+	rsp.Serial = conn.nextSerial()
+	conn.lock.Unlock()
+
+	conn.send(rsp)
+}
+
+// nextSerial returns the next message serial.
+// Must be called under the conn.lock.
+func (conn *Conn) nextSerial() uint32 {
+	conn.serial++
+	if conn.serial == 0 {
+		// Serial wrapped around, zero is not allowed
+		conn.serial++
+	}
+	return conn.serial
+}
+
+// setError sets the connection error, if not set yet, and wakes up
+// the Signal waiters.
+func (conn *Conn) setError(err error) {
+	conn.lock.Lock()
+	if conn.err == nil {
+		conn.err = err
+	}
+	conn.lock.Unlock()
+	conn.wakeup()
+}
+
+// wakeup wakes up the Signal waiter
+func (conn *Conn) wakeup() {
+	select {
+	case conn.sigReady <- struct{}{}:
+	default:
+	}
+}
+
+// machineID returns the local machine ID
+func machineID() string {
+	for _, file := range []string{
+		"/etc/machine-id", "/var/lib/dbus/machine-id",
+	} {
+		data, err := os.ReadFile(file)
+		if err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,372 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Scripted D-Bus server for tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The fake message bus
+
+package dbustest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/OpenPrinting/go-mfp/internal/dbus"
+)
+
+// Handler handles method calls, not handled by the [Server] itself.
+//
+// It must answer the call with the [Call.Reply] or [Call.Error].
+// If it returns without answering, the UnknownMethod error is
+// sent. Handler may emit signals with [Server.Emit] before
+// or after the answer; messages are delivered in order.
+type Handler func(srv *Server, call *Call)
+
+// Server is the fake D-Bus message bus, listening on the unix
+// socket in the temporary directory.
+//
+// The Server itself implements Hello, AddMatch, RemoveMatch and
+// NameHasOwner methods of the bus. All other calls are passed
+// to the Handler. Signals are delivered to all connected clients,
+// regardless of the match rules.
+type Server struct {
+	handler Handler              // Handler for method calls
+	dir     string               // Temporary directory
+	ln      net.Listener         // Listening socket
+	lock    sync.Mutex           // Access lock
+	serial  uint32               // Last used serial
+	clients map[*client]struct{} // Connected clients
+	names   map[string]struct{}  // Owned names
+	calls   []*dbus.Message      // Received method calls
+	nextID  int                  // Next unique name index
+	done    sync.WaitGroup       // For Server.Close synchronization
+	matches map[string][]string  // Match rules, by client name
+}
+
+// client represents the connected client
+type client struct {
+	conn  net.Conn   // Client connection
+	name  string     // Unique name
+	wlock sync.Mutex // Write lock
+}
+
+// Call represents the incoming method call, passed to the Handler.
+type Call struct {
+	*dbus.Message         // The call message
+	srv           *Server // The server
+	clnt          *client // Calling client
+	answered      bool    // Call is answered
+}
+
+// NewServer creates a new Server and starts serving.
+// It panics on errors, like [net/http/httptest.NewServer].
+func NewServer(handler Handler) *Server {
+	dir, err := os.MkdirTemp("", "dbustest")
+	if err != nil {
+		panic(fmt.Sprintf("dbustest: %s", err))
+	}
+
+	ln, err := net.Listen("unix", filepath.Join(dir, "bus"))
+	if err != nil {
+		os.RemoveAll(dir)
+		panic(fmt.Sprintf("dbustest: %s", err))
+	}
+
+	srv := &Server{
+		handler: handler,
+		dir:     dir,
+		ln:      ln,
+		clients: make(map[*client]struct{}),
+		names:   make(map[string]struct{}),
+		matches: make(map[string][]string),
+		nextID:  1,
+	}
+
+	srv.done.Add(1)
+	go srv.accept()
+
+	return srv
+}
+
+// Addr returns the D-Bus address of the Server
+func (srv *Server) Addr() string {
+	return "unix:path=" + srv.ln.Addr().String()
+}
+
+// Close closes the Server and disconnects all clients.
+func (srv *Server) Close() {
+	srv.ln.Close()
+
+	srv.lock.Lock()
+	for clnt := range srv.clients {
+		clnt.conn.Close()
+	}
+	srv.lock.Unlock()
+
+	srv.done.Wait()
+	os.RemoveAll(srv.dir)
+}
+
+// Disconnect closes connections of all clients, simulating
+// the bus failure. The Server continues to accept new connections.
+func (srv *Server) Disconnect() {
+	srv.lock.Lock()
+	for clnt := range srv.clients {
+		clnt.conn.Close()
+	}
+	srv.lock.Unlock()
+}
+
+// SetNameOwned sets or clears ownership of the well-known name,
+// as reported by the NameHasOwner method.
+func (srv *Server) SetNameOwned(name string, owned bool) {
+	srv.lock.Lock()
+	if owned {
+		srv.names[name] = struct{}{}
+	} else {
+		delete(srv.names, name)
+	}
+	srv.lock.Unlock()
+}
+
+// Calls returns all method calls received so far, including
+// calls, handled by the Server itself.
+func (srv *Server) Calls() []*dbus.Message {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	return append([]*dbus.Message(nil), srv.calls...)
+}
+
+// Matches returns match rules, added by all clients.
+func (srv *Server) Matches() []string {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	var rules []string
+	for _, r := range srv.matches {
+		rules = append(rules, r...)
+	}
+	return rules
+}
+
+// Emit sends signal to all connected clients.
+func (srv *Server) Emit(path dbus.ObjectPath, iface, member string,
+	sig dbus.Signature, args ...any) {
+
+	msg := &dbus.Message{
+		Type:      dbus.TypeSignal,
+		Path:      path,
+		Interface: iface,
+		Member:    member,
+		Sender:    ":1.0",
+		Signature: sig,
+		Body:      args,
+	}
+
+	srv.lock.Lock()
+	clients := make([]*client, 0, len(srv.clients))
+	for clnt := range srv.clients {
+		clients = append(clients, clnt)
+	}
+	srv.lock.Unlock()
+
+	for _, clnt := range clients {
+		srv.send(clnt, msg)
+	}
+}
+
+// Reply answers the call with the method return.
+// If the caller doesn't expect reply, nothing is sent.
+func (call *Call) Reply(sig dbus.Signature, args ...any) {
+	call.answered = true
+	if call.Flags&dbus.FlagNoReplyExpected != 0 {
+		return
+	}
+
+	call.srv.send(call.clnt, &dbus.Message{
+		Type:        dbus.TypeMethodReturn,
+		ReplySerial: call.Serial,
+		Destination: call.clnt.name,
+		Sender:      dbus.BusName,
+		Signature:   sig,
+		Body:        args,
+	})
+}
+
+// Error answers the call with the error.
+// If the caller doesn't expect reply, nothing is sent.
+func (call *Call) Error(name, text string) {
+	call.answered = true
+	if call.Flags&dbus.FlagNoReplyExpected != 0 {
+		return
+	}
+
+	call.srv.send(call.clnt, &dbus.Message{
+		Type:        dbus.TypeError,
+		ReplySerial: call.Serial,
+		ErrorName:   name,
+		Destination: call.clnt.name,
+		Sender:      dbus.BusName,
+		Signature:   "s",
+		Body:        []any{text},
+	})
+}
+
+// arg returns the i-th argument of the call, nil if missed
+func (call *Call) arg(i int) any {
+	if i < len(call.Body) {
+		return call.Body[i]
+	}
+	return nil
+}
+
+// accept accepts incoming connections
+func (srv *Server) accept() {
+	defer srv.done.Done()
+
+	for {
+		conn, err := srv.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		srv.lock.Lock()
+		clnt := &client{
+			conn: conn,
+			name: fmt.Sprintf(":1.%d", srv.nextID),
+		}
+		srv.nextID++
+		srv.clients[clnt] = struct{}{}
+		srv.lock.Unlock()
+
+		srv.done.Add(1)
+		go srv.serve(clnt)
+	}
+}
+
+// serve serves the client connection
+func (srv *Server) serve(clnt *client) {
+	defer srv.done.Done()
+	defer func() {
+		srv.lock.Lock()
+		delete(srv.clients, clnt)
+		delete(srv.matches, clnt.name)
+		srv.lock.Unlock()
+		clnt.conn.Close()
+	}()
+
+	rd := bufio.NewReader(clnt.conn)
+	if !srv.auth(clnt, rd) {
+		return
+	}
+
+	for {
+		msg, err := dbus.ReadMessage(rd)
+		if err != nil {
+			return
+		}
+
+		if msg.Type != dbus.TypeMethodCall {
+			continue
+		}
+
+		msg.Sender = clnt.name
+		srv.lock.Lock()
+		srv.calls = append(srv.calls, msg)
+		srv.lock.Unlock()
+
+		call := &Call{Message: msg, srv: srv, clnt: clnt}
+		if msg.Destination == dbus.BusName {
+			srv.busMethod(call)
+		} else if srv.handler != nil {
+			srv.handler(srv, call)
+		}
+
+		if !call.answered {
+			call.Error("org.freedesktop.DBus.Error.UnknownMethod",
+				fmt.Sprintf("Unknown method %s.%s",
+					msg.Interface, msg.Member))
+		}
+	}
+}
+
+// auth performs the server side of authentication
+func (srv *Server) auth(clnt *client, rd *bufio.Reader) bool {
+	// The leading NUL byte
+	b, err := rd.ReadByte()
+	if err != nil || b != 0 {
+		return false
+	}
+
+	for {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return false
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		var answer string
+
+		switch {
+		case strings.HasPrefix(line, "AUTH EXTERNAL"):
+			answer = "OK 0123456789abcdef0123456789abcdef\r\n"
+		case line == "BEGIN":
+			return true
+		default:
+			answer = "REJECTED EXTERNAL\r\n"
+		}
+
+		_, err = clnt.conn.Write([]byte(answer))
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// busMethod handles methods of the bus itself
+func (srv *Server) busMethod(call *Call) {
+	switch call.Member {
+	case "Hello":
+		call.Reply("s", call.clnt.name)
+
+	case "AddMatch", "RemoveMatch":
+		rule, _ := call.arg(0).(string)
+		srv.lock.Lock()
+		if call.Member == "AddMatch" {
+			srv.matches[call.clnt.name] = append(
+				srv.matches[call.clnt.name], rule)
+		}
+		srv.lock.Unlock()
+		call.Reply("")
+
+	case "NameHasOwner":
+		name, _ := call.arg(0).(string)
+		srv.lock.Lock()
+		_, owned := srv.names[name]
+		srv.lock.Unlock()
+		call.Reply("b", owned)
+	}
+}
+
+// send sends message to the client
+func (srv *Server) send(clnt *client, msg *dbus.Message) {
+	srv.lock.Lock()
+	srv.serial++
+	msg.Serial = srv.serial
+	srv.lock.Unlock()
+
+	data, err := msg.Encode()
+	if err != nil {
+		panic(fmt.Sprintf("dbustest: %s: %s", msg, err))
+	}
+
+	clnt.wlock.Lock()
+	clnt.conn.Write(data)
+	clnt.wlock.Unlock()
+}
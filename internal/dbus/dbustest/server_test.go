@@ -0,0 +1,177 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Scripted D-Bus server for tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// The fake message bus test
+
+package dbustest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/internal/dbus"
+)
+
+// TestServer tests the Server against the dbus.Conn client
+func TestServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	srv := NewServer(func(srv *Server, call *Call) {
+		switch call.Member {
+		case "Echo":
+			srv.Emit("/test", "test.Iface", "Before", "")
+			call.Reply(call.Signature, call.Body...)
+			srv.Emit("/test", "test.Iface", "After", "s", "done")
+
+		case "Fail":
+			call.Error("test.Error.Failed", "failed")
+		}
+	})
+	defer srv.Close()
+
+	srv.SetNameOwned("test.Service", true)
+
+	conn, err := dbus.Dial(ctx, "unix:path=/nonexistent;"+srv.Addr())
+	if err != nil {
+		t.Fatalf("Dial: %s", err)
+	}
+	defer conn.Close()
+
+	if conn.Name() != ":1.1" {
+		t.Errorf("Name: %q", conn.Name())
+	}
+
+	// Bus methods
+	for _, name := range []string{"test.Service", "test.Missed"} {
+		owned, err := conn.NameHasOwner(ctx, name)
+		switch {
+		case err != nil:
+			t.Errorf("NameHasOwner: %s", err)
+		case owned != (name == "test.Service"):
+			t.Errorf("NameHasOwner(%q): %v", name, owned)
+		}
+	}
+
+	rule := "type='signal',interface='test.Iface'"
+	err = conn.AddMatch(ctx, rule)
+	if err != nil {
+		t.Errorf("AddMatch: %s", err)
+	}
+
+	if rules := srv.Matches(); !reflect.DeepEqual(rules, []string{rule}) {
+		t.Errorf("Matches: %q", rules)
+	}
+
+	// Method call with reply and signals around
+	reply, err := conn.Call(ctx, "test.Service", "/test", "test.Iface",
+		"Echo", "sai", "hello", []any{int32(1), int32(2)})
+
+	expected := []any{"hello", []any{int32(1), int32(2)}}
+	switch {
+	case err != nil:
+		t.Errorf("Echo: %s", err)
+	case !reflect.DeepEqual(reply, expected):
+		t.Errorf("Echo: %#v", reply)
+	}
+
+	for _, member := range []string{"Before", "After"} {
+		sig, err := conn.Signal(ctx)
+		switch {
+		case err != nil:
+			t.Errorf("Signal: %s", err)
+		case sig.Member != member:
+			t.Errorf("Signal: expected %s, present %s",
+				member, sig.Member)
+		}
+	}
+
+	// Error replies
+	_, err = conn.Call(ctx, "test.Service", "/test", "test.Iface",
+		"Fail", "")
+
+	var dberr *dbus.Error
+	switch {
+	case !errors.As(err, &dberr):
+		t.Errorf("Fail: expected *dbus.Error, present %v", err)
+	case dberr.Error() != "test.Error.Failed: failed":
+		t.Errorf("Fail: %s", dberr)
+	}
+
+	_, err = conn.Call(ctx, "test.Service", "/test", "test.Iface",
+		"Unknown", "")
+	if !errors.As(err, &dberr) ||
+		dberr.Name != "org.freedesktop.DBus.Error.UnknownMethod" {
+		t.Errorf("Unknown: %v", err)
+	}
+
+	// Signal waiting respects the context
+	ctx2, cancel2 := context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = conn.Signal(ctx2)
+	cancel2()
+
+	if err != context.DeadlineExceeded {
+		t.Errorf("Signal: expected timeout, present %v", err)
+	}
+
+	// Bus disconnect must be reported
+	srv.Disconnect()
+
+	select {
+	case <-conn.Done():
+	case <-ctx.Done():
+		t.Fatalf("disconnect not detected")
+	}
+
+	if _, err = conn.Signal(ctx); err == nil {
+		t.Errorf("Signal: error expected after disconnect")
+	}
+
+	_, err = conn.Call(ctx, "test.Service", "/test", "test.Iface",
+		"Echo", "")
+	if err == nil {
+		t.Errorf("Call: error expected after disconnect")
+	}
+
+	// Calls log must contain all the calls
+	var members []string
+	for _, call := range srv.Calls() {
+		members = append(members, call.Member)
+	}
+
+	expectedMembers := []string{"Hello", "NameHasOwner", "NameHasOwner",
+		"AddMatch", "Echo", "Fail", "Unknown"}
+	if !reflect.DeepEqual(members, expectedMembers) {
+		t.Errorf("Calls:\nexpected: %q\npresent:  %q",
+			expectedMembers, members)
+	}
+}
+
+// TestDialErrors tests dbus.Dial errors
+func TestDialErrors(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		addr string
+		err  string
+	}{
+		{"tcp:host=localhost,port=1",
+			`D-Bus: "tcp:host=localhost,port=1": unsupported transport`},
+		{"unix:guid=1234",
+			`D-Bus: "unix:guid=1234": missed path`},
+	}
+
+	for _, test := range tests {
+		_, err := dbus.Dial(ctx, test.addr)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%q:\nerror expected: %s\nerror present:  %v",
+				test.addr, test.err, err)
+		}
+	}
+}
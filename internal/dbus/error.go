@@ -0,0 +1,38 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Minimal D-Bus client
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// D-Bus errors
+
+package dbus
+
+import "errors"
+
+// ErrClosed is returned when operation is attempted on the
+// closed connection
+var ErrClosed = errors.New("D-Bus: connection closed")
+
+// Error represents the error reply, returned by the remote side
+type Error struct {
+	Name    string // Error name (e.g., org.freedesktop.DBus.Error.Failed)
+	Message string // Error message, may be empty
+}
+
+// Error returns the error string. It implements the error interface.
+func (err *Error) Error() string {
+	if err.Message == "" {
+		return err.Name
+	}
+	return err.Name + ": " + err.Message
+}
+
+// errorFromMessage makes Error from the TypeError message
+func errorFromMessage(msg *Message) *Error {
+	err := &Error{Name: msg.ErrorName}
+	if len(msg.Body) > 0 {
+		err.Message, _ = msg.Body[0].(string)
+	}
+	return err
+}
@@ -0,0 +1,590 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Minimal D-Bus client
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Values marshaling
+
+package dbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// ErrMalformed is returned when received data cannot be decoded
+var ErrMalformed = errors.New("D-Bus: malformed data")
+
+// arrayMaxLen is the maximum length of marshaled array, in bytes,
+// as defined by the D-Bus specification.
+const arrayMaxLen = 64 * 1024 * 1024
+
+// byteOrder combines binary.ByteOrder and binary.AppendByteOrder
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// encoder marshals D-Bus values.
+//
+// Note, alignment is computed relative to the beginning of the buf,
+// so each encoder must start at the 8-byte boundary of the message.
+type encoder struct {
+	buf   []byte    // Output buffer
+	order byteOrder // Byte order
+	depth int       // Current nesting depth
+}
+
+// decoder unmarshals D-Bus values.
+//
+// Like encoder, it must start at the 8-byte boundary of the message.
+type decoder struct {
+	buf   []byte           // Input buffer
+	off   int              // Current offset
+	order binary.ByteOrder // Byte order
+	depth int              // Current nesting depth
+}
+
+// encodeValues marshals values according to the signature,
+// that may contain zero or more single complete types.
+func (enc *encoder) encodeValues(sig string, values []any) error {
+	i := 0
+	for ; sig != ""; i++ {
+		first, rest, err := sigNext(sig)
+		if err != nil {
+			return err
+		}
+
+		if i >= len(values) {
+			return fmt.Errorf("D-Bus: missed value for %q", first)
+		}
+
+		err = enc.encode(first, values[i])
+		if err != nil {
+			return err
+		}
+
+		sig = rest
+	}
+
+	if i != len(values) {
+		return errors.New("D-Bus: too many values")
+	}
+
+	return nil
+}
+
+// align pads the buffer with zero bytes up to the n-byte boundary
+func (enc *encoder) align(n int) {
+	for len(enc.buf)%n != 0 {
+		enc.buf = append(enc.buf, 0)
+	}
+}
+
+// putUint16 appends aligned uint16 value
+func (enc *encoder) putUint16(v uint16) {
+	enc.align(2)
+	enc.buf = enc.order.AppendUint16(enc.buf, v)
+}
+
+// putUint32 appends aligned uint32 value
+func (enc *encoder) putUint32(v uint32) {
+	enc.align(4)
+	enc.buf = enc.order.AppendUint32(enc.buf, v)
+}
+
+// putUint64 appends aligned uint64 value
+func (enc *encoder) putUint64(v uint64) {
+	enc.align(8)
+	enc.buf = enc.order.AppendUint64(enc.buf, v)
+}
+
+// encode marshals the single value of the single complete type
+func (enc *encoder) encode(sig string, v any) error {
+	if enc.depth > sigMaxDepth {
+		return ErrSignature
+	}
+
+	ok := true
+
+	switch sig[0] {
+	case 'y':
+		var b byte
+		b, ok = v.(byte)
+		enc.buf = append(enc.buf, b)
+
+	case 'b':
+		var b bool
+		b, ok = v.(bool)
+		if b {
+			enc.putUint32(1)
+		} else {
+			enc.putUint32(0)
+		}
+
+	case 'n':
+		var n int16
+		n, ok = v.(int16)
+		enc.putUint16(uint16(n))
+
+	case 'q':
+		var n uint16
+		n, ok = v.(uint16)
+		enc.putUint16(n)
+
+	case 'i':
+		var n int32
+		n, ok = v.(int32)
+		enc.putUint32(uint32(n))
+
+	case 'u':
+		var n uint32
+		n, ok = v.(uint32)
+		enc.putUint32(n)
+
+	case 'x':
+		var n int64
+		n, ok = v.(int64)
+		enc.putUint64(uint64(n))
+
+	case 't':
+		var n uint64
+		n, ok = v.(uint64)
+		enc.putUint64(n)
+
+	case 'd':
+		var f float64
+		f, ok = v.(float64)
+		enc.putUint64(math.Float64bits(f))
+
+	case 's':
+		var s string
+		s, ok = v.(string)
+		if ok {
+			return enc.encodeString(s)
+		}
+
+	case 'o':
+		var s ObjectPath
+		s, ok = v.(ObjectPath)
+		if ok {
+			return enc.encodeString(string(s))
+		}
+
+	case 'g':
+		var s Signature
+		s, ok = v.(Signature)
+		if ok {
+			return enc.encodeSignature(s)
+		}
+
+	case 'v':
+		var variant Variant
+		variant, ok = v.(Variant)
+		if ok {
+			return enc.encodeVariant(variant)
+		}
+
+	case 'a':
+		return enc.encodeArray(sig, v)
+
+	case '(', '{':
+		var fields []any
+		fields, ok = v.([]any)
+		if ok {
+			enc.align(8)
+			enc.depth++
+			err := enc.encodeValues(sig[1:len(sig)-1], fields)
+			enc.depth--
+			return err
+		}
+
+	default:
+		return ErrSignature
+	}
+
+	if !ok {
+		return fmt.Errorf("D-Bus: can't encode %T as %q", v, sig)
+	}
+
+	return nil
+}
+
+// encodeString marshals the string value
+func (enc *encoder) encodeString(s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("D-Bus: invalid UTF-8 string %q", s)
+	}
+
+	enc.putUint32(uint32(len(s)))
+	enc.buf = append(enc.buf, s...)
+	enc.buf = append(enc.buf, 0)
+	return nil
+}
+
+// encodeSignature marshals the signature value
+func (enc *encoder) encodeSignature(s Signature) error {
+	if len(s) > 255 {
+		return fmt.Errorf("%w: too long", ErrSignature)
+	}
+
+	err := sigValidate(string(s))
+	if err != nil {
+		return err
+	}
+
+	enc.buf = append(enc.buf, byte(len(s)))
+	enc.buf = append(enc.buf, s...)
+	enc.buf = append(enc.buf, 0)
+	return nil
+}
+
+// encodeVariant marshals the variant value
+func (enc *encoder) encodeVariant(v Variant) error {
+	first, rest, err := sigNext(string(v.Sig))
+	if err != nil || rest != "" {
+		return fmt.Errorf("%w: %q", ErrSignature, v.Sig)
+	}
+
+	err = enc.encodeSignature(v.Sig)
+	if err == nil {
+		enc.depth++
+		err = enc.encode(first, v.Value)
+		enc.depth--
+	}
+
+	return err
+}
+
+// encodeArray marshals the array value
+func (enc *encoder) encodeArray(sig string, v any) error {
+	elsig := sig[1:]
+
+	// Write length placeholder and pad to the element alignment.
+	// Note, padding is not included into the array length.
+	enc.putUint32(0)
+	lenOff := len(enc.buf) - 4
+	enc.align(sigAlign(elsig))
+	start := len(enc.buf)
+
+	switch v := v.(type) {
+	case []byte:
+		if elsig != "y" {
+			return fmt.Errorf("D-Bus: can't encode %T as %q", v, sig)
+		}
+		enc.buf = append(enc.buf, v...)
+
+	case []any:
+		enc.depth++
+		for _, elem := range v {
+			err := enc.encode(elsig, elem)
+			if err != nil {
+				return err
+			}
+		}
+		enc.depth--
+
+	default:
+		return fmt.Errorf("D-Bus: can't encode %T as %q", v, sig)
+	}
+
+	length := len(enc.buf) - start
+	if length > arrayMaxLen {
+		return errors.New("D-Bus: array too long")
+	}
+
+	enc.order.PutUint32(enc.buf[lenOff:], uint32(length))
+	return nil
+}
+
+// decodeValues unmarshals values according to the signature,
+// that may contain zero or more single complete types.
+func (dec *decoder) decodeValues(sig string) ([]any, error) {
+	var values []any
+
+	for sig != "" {
+		first, rest, err := sigNext(sig)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := dec.decode(first)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+		sig = rest
+	}
+
+	return values, nil
+}
+
+// align skips padding up to the n-byte boundary
+func (dec *decoder) align(n int) error {
+	off := (dec.off + n - 1) / n * n
+	if off > len(dec.buf) {
+		return ErrMalformed
+	}
+	dec.off = off
+	return nil
+}
+
+// next returns the next n bytes of the input
+func (dec *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(dec.buf)-dec.off < n {
+		return nil, ErrMalformed
+	}
+
+	data := dec.buf[dec.off : dec.off+n]
+	dec.off += n
+	return data, nil
+}
+
+// getUint16 returns the next aligned uint16 value
+func (dec *decoder) getUint16() (uint16, error) {
+	err := dec.align(2)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := dec.next(2)
+	if err != nil {
+		return 0, err
+	}
+
+	return dec.order.Uint16(data), nil
+}
+
+// getUint32 returns the next aligned uint32 value
+func (dec *decoder) getUint32() (uint32, error) {
+	err := dec.align(4)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := dec.next(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return dec.order.Uint32(data), nil
+}
+
+// getUint64 returns the next aligned uint64 value
+func (dec *decoder) getUint64() (uint64, error) {
+	err := dec.align(8)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := dec.next(8)
+	if err != nil {
+		return 0, err
+	}
+
+	return dec.order.Uint64(data), nil
+}
+
+// decode unmarshals the single value of the single complete type
+func (dec *decoder) decode(sig string) (any, error) {
+	if dec.depth > sigMaxDepth {
+		return nil, ErrMalformed
+	}
+
+	switch sig[0] {
+	case 'y':
+		data, err := dec.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return data[0], nil
+
+	case 'b':
+		v, err := dec.getUint32()
+		switch {
+		case err != nil:
+			return nil, err
+		case v > 1:
+			return nil, ErrMalformed
+		}
+		return v == 1, nil
+
+	case 'n':
+		v, err := dec.getUint16()
+		return int16(v), err
+
+	case 'q':
+		v, err := dec.getUint16()
+		return v, err
+
+	case 'i':
+		v, err := dec.getUint32()
+		return int32(v), err
+
+	case 'u':
+		v, err := dec.getUint32()
+		return v, err
+
+	case 'x':
+		v, err := dec.getUint64()
+		return int64(v), err
+
+	case 't':
+		v, err := dec.getUint64()
+		return v, err
+
+	case 'd':
+		v, err := dec.getUint64()
+		return math.Float64frombits(v), err
+
+	case 's':
+		return dec.decodeString()
+
+	case 'o':
+		s, err := dec.decodeString()
+		return ObjectPath(s), err
+
+	case 'g':
+		return dec.decodeSignature()
+
+	case 'v':
+		return dec.decodeVariant()
+
+	case 'a':
+		return dec.decodeArray(sig)
+
+	case '(', '{':
+		err := dec.align(8)
+		if err != nil {
+			return nil, err
+		}
+
+		dec.depth++
+		fields, err := dec.decodeValues(sig[1 : len(sig)-1])
+		dec.depth--
+
+		return fields, err
+	}
+
+	return nil, ErrSignature
+}
+
+// decodeString unmarshals the string value
+func (dec *decoder) decodeString() (string, error) {
+	n, err := dec.getUint32()
+	if err != nil {
+		return "", err
+	}
+
+	if n > arrayMaxLen {
+		return "", ErrMalformed
+	}
+
+	data, err := dec.next(int(n) + 1)
+	if err != nil {
+		return "", err
+	}
+
+	s := string(data[:n])
+	if data[n] != 0 || !utf8.ValidString(s) {
+		return "", ErrMalformed
+	}
+
+	return s, nil
+}
+
+// decodeSignature unmarshals the signature value
+func (dec *decoder) decodeSignature() (Signature, error) {
+	data, err := dec.next(1)
+	if err != nil {
+		return "", err
+	}
+
+	n := int(data[0])
+	data, err = dec.next(n + 1)
+	if err != nil {
+		return "", err
+	}
+
+	s := string(data[:n])
+	if data[n] != 0 || sigValidate(s) != nil {
+		return "", ErrMalformed
+	}
+
+	return Signature(s), nil
+}
+
+// decodeVariant unmarshals the variant value
+func (dec *decoder) decodeVariant() (Variant, error) {
+	sig, err := dec.decodeSignature()
+	if err != nil {
+		return Variant{}, err
+	}
+
+	first, rest, err := sigNext(string(sig))
+	if err != nil || rest != "" {
+		return Variant{}, ErrMalformed
+	}
+
+	dec.depth++
+	v, err := dec.decode(first)
+	dec.depth--
+
+	return Variant{Sig: sig, Value: v}, err
+}
+
+// decodeArray unmarshals the array value
+func (dec *decoder) decodeArray(sig string) (any, error) {
+	n, err := dec.getUint32()
+	if err != nil {
+		return nil, err
+	}
+
+	if n > arrayMaxLen {
+		return nil, ErrMalformed
+	}
+
+	elsig := sig[1:]
+	err = dec.align(sigAlign(elsig))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := dec.next(int(n))
+	if err != nil {
+		return nil, err
+	}
+
+	if elsig == "y" {
+		return append([]byte{}, data...), nil
+	}
+
+	// Decode elements. Note, we decode in the context of the
+	// entire buffer, to keep alignment right.
+	end := dec.off
+	dec.off -= int(n)
+
+	sub := decoder{
+		buf:   dec.buf[:end],
+		off:   dec.off,
+		order: dec.order,
+		depth: dec.depth + 1,
+	}
+
+	values := []any{}
+	for sub.off < end {
+		v, err := sub.decode(elsig)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	dec.off = end
+	return values, nil
+}
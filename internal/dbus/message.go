@@ -0,0 +1,288 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Minimal D-Bus client
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// D-Bus messages
+
+package dbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// MessageType is the D-Bus message type
+type MessageType byte
+
+// Message types:
+const (
+	TypeInvalid      MessageType = iota // Invalid message
+	TypeMethodCall                      // Method call
+	TypeMethodReturn                    // Method reply with returned data
+	TypeError                           // Error reply
+	TypeSignal                          // Signal emission
+)
+
+// MessageFlags are the D-Bus message flags
+type MessageFlags byte
+
+// Message flags:
+const (
+	FlagNoReplyExpected MessageFlags = 1 << iota // Don't send reply
+	FlagNoAutoStart                              // Don't start service
+)
+
+// Header field codes
+const (
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldErrorName   = 4
+	fieldReplySerial = 5
+	fieldDestination = 6
+	fieldSender      = 7
+	fieldSignature   = 8
+	fieldUnixFDs     = 9
+)
+
+// messageMaxLen is the maximum length of the message,
+// as defined by the D-Bus specification.
+const messageMaxLen = 128 * 1024 * 1024
+
+// Message represents a D-Bus message
+type Message struct {
+	Type        MessageType  // Message type
+	Flags       MessageFlags // Message flags
+	Serial      uint32       // Message serial number
+	Path        ObjectPath   // Object path
+	Interface   string       // Interface name
+	Member      string       // Method or signal name
+	ErrorName   string       // Error name, for TypeError
+	ReplySerial uint32       // Serial of the message this is reply to
+	Destination string       // Destination connection name
+	Sender      string       // Sender connection name
+	Signature   Signature    // Body signature
+	Body        []any        // Message body
+}
+
+// String returns short description of the message, for logging
+// and debugging.
+func (msg *Message) String() string {
+	switch msg.Type {
+	case TypeMethodCall:
+		return fmt.Sprintf("call %s %s.%s(%s) #%d",
+			msg.Path, msg.Interface, msg.Member, msg.Signature,
+			msg.Serial)
+	case TypeMethodReturn:
+		return fmt.Sprintf("return (%s) #%d", msg.Signature,
+			msg.ReplySerial)
+	case TypeError:
+		return fmt.Sprintf("error %s #%d", msg.ErrorName,
+			msg.ReplySerial)
+	case TypeSignal:
+		return fmt.Sprintf("signal %s %s.%s(%s)",
+			msg.Path, msg.Interface, msg.Member, msg.Signature)
+	}
+
+	return fmt.Sprintf("message type %d", msg.Type)
+}
+
+// Encode encodes the message into the wire representation.
+// Little-endian byte order is always used.
+func (msg *Message) Encode() ([]byte, error) {
+	// Encode body first, we need its length
+	body := encoder{order: binary.LittleEndian}
+	err := body.encodeValues(string(msg.Signature), msg.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare header fields
+	var fields []any
+	addField := func(code byte, sig Signature, v any) {
+		fields = append(fields,
+			[]any{code, Variant{Sig: sig, Value: v}})
+	}
+
+	if msg.Path != "" {
+		addField(fieldPath, "o", msg.Path)
+	}
+	if msg.Interface != "" {
+		addField(fieldInterface, "s", msg.Interface)
+	}
+	if msg.Member != "" {
+		addField(fieldMember, "s", msg.Member)
+	}
+	if msg.ErrorName != "" {
+		addField(fieldErrorName, "s", msg.ErrorName)
+	}
+	if msg.ReplySerial != 0 {
+		addField(fieldReplySerial, "u", msg.ReplySerial)
+	}
+	if msg.Destination != "" {
+		addField(fieldDestination, "s", msg.Destination)
+	}
+	if msg.Sender != "" {
+		addField(fieldSender, "s", msg.Sender)
+	}
+	if msg.Signature != "" {
+		addField(fieldSignature, "g", msg.Signature)
+	}
+
+	// Encode header
+	hdr := encoder{order: binary.LittleEndian}
+	err = hdr.encodeValues("yyyyuua(yv)", []any{
+		byte('l'), byte(msg.Type), byte(msg.Flags), byte(1),
+		uint32(len(body.buf)), msg.Serial, fields,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	hdr.align(8)
+
+	data := append(hdr.buf, body.buf...)
+	if len(data) > messageMaxLen {
+		return nil, fmt.Errorf("D-Bus: message too long")
+	}
+
+	return data, nil
+}
+
+// ReadMessage reads and decodes the next message from the stream.
+func ReadMessage(r io.Reader) (*Message, error) {
+	// Read the fixed part of the header
+	var fixed [16]byte
+	_, err := io.ReadFull(r, fixed[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder
+	switch fixed[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%w: invalid byte order", ErrMalformed)
+	}
+
+	if fixed[3] != 1 {
+		return nil, fmt.Errorf("%w: unsupported protocol version %d",
+			ErrMalformed, fixed[3])
+	}
+
+	bodyLen := uint64(order.Uint32(fixed[4:]))
+	fieldsLen := uint64(order.Uint32(fixed[12:]))
+	hdrLen := (16 + fieldsLen + 7) &^ 7
+
+	if hdrLen+bodyLen > messageMaxLen {
+		return nil, fmt.Errorf("%w: message too long", ErrMalformed)
+	}
+
+	// Read the rest of the message
+	data := make([]byte, hdrLen+bodyLen)
+	copy(data, fixed[:])
+	_, err = io.ReadFull(r, data[16:])
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return decodeMessage(data, order, int(hdrLen))
+}
+
+// decodeMessage decodes the message, read by ReadMessage
+func decodeMessage(data []byte, order binary.ByteOrder, hdrLen int) (
+	*Message, error) {
+
+	hdr := decoder{buf: data[:hdrLen], order: order}
+	values, err := hdr.decodeValues("yyyyuua(yv)")
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		Type:   MessageType(values[1].(byte)),
+		Flags:  MessageFlags(values[2].(byte)),
+		Serial: values[5].(uint32),
+	}
+
+	// Decode header fields. Unknown fields are ignored.
+	for _, field := range values[6].([]any) {
+		field := field.([]any)
+		code := field[0].(byte)
+		v := field[1].(Variant).Value
+
+		ok := true
+		switch code {
+		case fieldPath:
+			msg.Path, ok = v.(ObjectPath)
+		case fieldInterface:
+			msg.Interface, ok = v.(string)
+		case fieldMember:
+			msg.Member, ok = v.(string)
+		case fieldErrorName:
+			msg.ErrorName, ok = v.(string)
+		case fieldReplySerial:
+			msg.ReplySerial, ok = v.(uint32)
+		case fieldDestination:
+			msg.Destination, ok = v.(string)
+		case fieldSender:
+			msg.Sender, ok = v.(string)
+		case fieldSignature:
+			msg.Signature, ok = v.(Signature)
+		case fieldUnixFDs:
+			return nil, fmt.Errorf("%w: unix FDs not supported",
+				ErrMalformed)
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid header field %d",
+				ErrMalformed, code)
+		}
+	}
+
+	// Validate the message
+	switch {
+	case msg.Serial == 0:
+		err = fmt.Errorf("%w: zero serial", ErrMalformed)
+	case msg.Type == TypeMethodCall && (msg.Path == "" || msg.Member == ""):
+		err = fmt.Errorf("%w: missed path or member", ErrMalformed)
+	case msg.Type == TypeMethodReturn && msg.ReplySerial == 0:
+		err = fmt.Errorf("%w: missed reply serial", ErrMalformed)
+	case msg.Type == TypeError &&
+		(msg.ReplySerial == 0 || msg.ErrorName == ""):
+		err = fmt.Errorf("%w: missed reply serial or error name",
+			ErrMalformed)
+	case msg.Type == TypeSignal &&
+		(msg.Path == "" || msg.Interface == "" || msg.Member == ""):
+		err = fmt.Errorf("%w: missed path, interface or member",
+			ErrMalformed)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the body. Body starts at the 8-byte boundary, so we
+	// can decode it as a separate buffer.
+	body := decoder{buf: data[hdrLen:], order: order}
+	msg.Body, err = body.decodeValues(string(msg.Signature))
+	if err == nil && body.off != len(body.buf) {
+		err = fmt.Errorf("%w: garbage after body", ErrMalformed)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,236 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Minimal D-Bus client
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// D-Bus messages test
+
+package dbus
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// TestMessageEncodeDecode tests Message encoding and decoding
+func TestMessageEncodeDecode(t *testing.T) {
+	tests := []*Message{
+		{
+			Type:        TypeMethodCall,
+			Serial:      1,
+			Path:        "/",
+			Interface:   "org.freedesktop.Avahi.Server",
+			Member:      "ServiceBrowserNew",
+			Destination: "org.freedesktop.Avahi",
+			Signature:   "iissu",
+			Body: []any{
+				int32(-1), int32(-1), "_ipp._tcp", "local",
+				uint32(0),
+			},
+		},
+
+		{
+			Type:        TypeMethodReturn,
+			Flags:       FlagNoReplyExpected,
+			Serial:      2,
+			ReplySerial: 1,
+			Sender:      ":1.2",
+			Signature:   "o",
+			Body:        []any{ObjectPath("/Client1/ServiceBrowser1")},
+		},
+
+		{
+			Type:        TypeError,
+			Serial:      3,
+			ReplySerial: 1,
+			ErrorName:   "org.freedesktop.Avahi.TimeoutError",
+			Signature:   "s",
+			Body:        []any{"Timeout reached"},
+		},
+
+		{
+			Type:      TypeSignal,
+			Serial:    4,
+			Path:      "/Client1/ServiceResolver1",
+			Interface: "org.freedesktop.Avahi.ServiceResolver",
+			Member:    "Found",
+			Signature: "iissssisqaayu",
+			Body: []any{
+				int32(2), int32(0), "Kyocera ECOSYS M2040dn",
+				"_ipp._tcp", "local", "KM7B6A91.local",
+				int32(0), "192.168.0.22", uint16(631),
+				[]any{
+					[]byte("txtvers=1"),
+					[]byte("rp=ipp/print"),
+					[]byte{},
+				},
+				uint32(4),
+			},
+		},
+
+		{
+			Type:      TypeSignal,
+			Serial:    5,
+			Path:      "/test",
+			Interface: "test.Types",
+			Member:    "All",
+			Signature: "ybnqiuxtdsogv(ys)a{sv}aiab",
+			Body: []any{
+				byte(1), true, int16(-2), uint16(3),
+				int32(-4), uint32(5), int64(-6), uint64(7),
+				8.5, "nine", ObjectPath("/ten"),
+				Signature("a{sv}"),
+				Variant{Sig: "(ii)", Value: []any{
+					int32(1), int32(2)}},
+				[]any{byte(11), "twelve"},
+				[]any{
+					[]any{"a", Variant{Sig: "t",
+						Value: uint64(13)}},
+					[]any{"b", Variant{Sig: "s",
+						Value: "fourteen"}},
+				},
+				[]any{},
+				[]any{false, true},
+			},
+		},
+	}
+
+	for _, msg := range tests {
+		data, err := msg.Encode()
+		if err != nil {
+			t.Errorf("%s: Encode: %s", msg, err)
+			continue
+		}
+
+		if len(data)%8 != 0 && len(msg.Body) == 0 {
+			t.Errorf("%s: header is not padded", msg)
+		}
+
+		msg2, err := ReadMessage(bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("%s: ReadMessage: %s", msg, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(msg, msg2) {
+			t.Errorf("%s: decoded message mismatch:\n"+
+				"expected: %#v\npresent:  %#v", msg, msg, msg2)
+		}
+	}
+}
+
+// TestMessageBigEndian tests decoding of the big-endian message
+func TestMessageBigEndian(t *testing.T) {
+	data := []byte{
+		'B', byte(TypeMethodReturn), 0, 1, // Endianness, type, flags, version
+		0, 0, 0, 8, // Body length
+		0, 0, 0, 7, // Serial
+		0, 0, 0, 15, // Header fields array length
+		5, 1, 'u', 0, 0, 0, 0, 3, // REPLY_SERIAL = 3
+		8, 1, 'g', 0, 1, 'u', 0, // SIGNATURE = "u"
+		0,          // Padding
+		0, 0, 1, 0, // Body: uint32(256)
+		0, 0, 0, 0, // Garbage
+	}
+
+	_, err := ReadMessage(bytes.NewReader(data))
+	if !errors.Is(err, ErrMalformed) {
+		t.Errorf("garbage after body: expected ErrMalformed, got %v", err)
+	}
+
+	data[7] = 4
+	msg, err := ReadMessage(bytes.NewReader(data[:len(data)-4]))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	switch {
+	case msg.Serial != 7 || msg.ReplySerial != 3:
+		t.Errorf("serials: %d, %d", msg.Serial, msg.ReplySerial)
+	case !reflect.DeepEqual(msg.Body, []any{uint32(256)}):
+		t.Errorf("body: %#v", msg.Body)
+	}
+}
+
+// TestMessageErrors tests encoding and decoding errors
+func TestMessageErrors(t *testing.T) {
+	type testData struct {
+		sig  Signature
+		body []any
+		err  string
+	}
+
+	tests := []testData{
+		{sig: "i", body: []any{uint32(1)},
+			err: `D-Bus: can't encode uint32 as "i"`},
+		{sig: "s", body: []any{1},
+			err: `D-Bus: can't encode int as "s"`},
+		{sig: "ii", body: []any{int32(1)},
+			err: `D-Bus: missed value for "i"`},
+		{sig: "i", body: []any{int32(1), int32(2)},
+			err: `D-Bus: too many values`},
+		{sig: "a{vs}", body: []any{[]any{}},
+			err: `D-Bus: invalid signature`},
+		{sig: "(", body: []any{[]any{}},
+			err: `D-Bus: invalid signature`},
+		{sig: "()", body: []any{[]any{}},
+			err: `D-Bus: invalid signature`},
+		{sig: "v", body: []any{Variant{Sig: "ii"}},
+			err: `D-Bus: invalid signature: "ii"`},
+		{sig: "s", body: []any{"\xff"},
+			err: `D-Bus: invalid UTF-8 string "\xff"`},
+	}
+
+	for _, test := range tests {
+		msg := &Message{
+			Type:      TypeSignal,
+			Serial:    1,
+			Path:      "/",
+			Interface: "test.Errors",
+			Member:    "Error",
+			Signature: test.sig,
+			Body:      test.body,
+		}
+
+		_, err := msg.Encode()
+		errstr := ""
+		if err != nil {
+			errstr = err.Error()
+		}
+
+		if errstr != test.err {
+			t.Errorf("%q %#v:\nerror expected: %s\nerror present:  %s",
+				test.sig, test.body, test.err, errstr)
+		}
+	}
+
+	// Truncated messages
+	msg := &Message{
+		Type:      TypeSignal,
+		Serial:    1,
+		Path:      "/",
+		Interface: "test.Errors",
+		Member:    "Truncated",
+		Signature: "s",
+		Body:      []any{"hello"},
+	}
+
+	data, _ := msg.Encode()
+	for i := 0; i < len(data); i++ {
+		_, err := ReadMessage(bytes.NewReader(data[:i]))
+		if err == nil {
+			t.Errorf("truncated at %d: error not detected", i)
+		}
+	}
+
+	// Missed required header fields
+	msg.Member = ""
+	data, _ = msg.Encode()
+	_, err := ReadMessage(bytes.NewReader(data))
+	if !errors.Is(err, ErrMalformed) {
+		t.Errorf("missed member: expected ErrMalformed, got %v", err)
+	}
+}
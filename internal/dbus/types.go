@@ -0,0 +1,142 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Minimal D-Bus client
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// D-Bus data types
+
+package dbus
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ObjectPath is the D-Bus object path (type code 'o')
+type ObjectPath string
+
+// Signature is the D-Bus type signature (type code 'g')
+type Signature string
+
+// Variant is the D-Bus variant value (type code 'v')
+type Variant struct {
+	Sig   Signature // Signature of the value
+	Value any       // The value
+}
+
+// D-Bus values are represented by the following Go types:
+//
+//	y    byte
+//	b    bool
+//	n    int16
+//	q    uint16
+//	i    int32
+//	u    uint32
+//	x    int64
+//	t    uint64
+//	d    float64
+//	s    string
+//	o    ObjectPath
+//	g    Signature
+//	v    Variant
+//	ay   []byte
+//	a?   []any (other arrays)
+//	(?)  []any (structures)
+//	{??} []any (dictionary entries, key and value)
+
+// ErrSignature is returned when type signature is malformed
+var ErrSignature = errors.New("D-Bus: invalid signature")
+
+// sigMaxDepth is the maximum nesting depth of containers
+// in the type signature
+const sigMaxDepth = 32
+
+// sigNext splits the type signature into the first single complete
+// type and the rest of the signature.
+func sigNext(sig string) (first, rest string, err error) {
+	n, err := sigLen(sig, 0)
+	if err != nil {
+		return "", "", err
+	}
+	return sig[:n], sig[n:], nil
+}
+
+// sigLen returns length of the first single complete type
+// of the signature.
+func sigLen(sig string, depth int) (int, error) {
+	if sig == "" || depth > sigMaxDepth {
+		return 0, ErrSignature
+	}
+
+	switch sig[0] {
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd',
+		's', 'o', 'g', 'v':
+		return 1, nil
+
+	case 'a':
+		n, err := sigLen(sig[1:], depth+1)
+		return n + 1, err
+
+	case '(':
+		i := 1
+		for i < len(sig) && sig[i] != ')' {
+			n, err := sigLen(sig[i:], depth+1)
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+
+		if i == 1 || i >= len(sig) {
+			return 0, ErrSignature
+		}
+		return i + 1, nil
+
+	case '{':
+		// Key must be basic type, value is any single type
+		if len(sig) < 2 || !strings.ContainsRune("ybnqiuxtdsog", rune(sig[1])) {
+			return 0, ErrSignature
+		}
+
+		n, err := sigLen(sig[2:], depth+1)
+		if err != nil {
+			return 0, err
+		}
+
+		i := 2 + n
+		if i >= len(sig) || sig[i] != '}' {
+			return 0, ErrSignature
+		}
+		return i + 1, nil
+	}
+
+	return 0, ErrSignature
+}
+
+// sigValidate validates the signature, that may contain
+// zero or more single complete types.
+func sigValidate(sig string) error {
+	for sig != "" {
+		_, rest, err := sigNext(sig)
+		if err != nil {
+			return fmt.Errorf("%w: %q", err, sig)
+		}
+		sig = rest
+	}
+	return nil
+}
+
+// sigAlign returns alignment of the type with the given signature.
+func sigAlign(sig string) int {
+	switch sig[0] {
+	case 'y', 'g', 'v':
+		return 1
+	case 'n', 'q':
+		return 2
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 4
+}
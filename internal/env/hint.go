@@ -0,0 +1,39 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Execution environment
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Hints on network client errors
+
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+	"golang.org/x/term"
+)
+
+// ErrorHint appends the actionable hint to the error, returned by
+// the network client, if the error is the [transport.ClientError]
+// and the stderr is a terminal.
+//
+// Otherwise, err is returned as is. The returned error wraps the
+// original one, so [errors.As] and [errors.Is] still work.
+func ErrorHint(err error) error {
+	var clientErr *transport.ClientError
+	if !errors.As(err, &clientErr) ||
+		!term.IsTerminal(int(os.Stderr.Fd())) {
+		return err
+	}
+
+	hint := clientErr.Hint()
+	if hint == "" {
+		return err
+	}
+
+	return fmt.Errorf("%w\nhint: %s", err, hint)
+}
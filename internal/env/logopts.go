@@ -0,0 +1,57 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Execution environment
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Common logging options
+
+package env
+
+import (
+	"github.com/OpenPrinting/go-mfp/argv"
+	"github.com/OpenPrinting/go-mfp/log"
+)
+
+// Common logging options. They are persistent, so being declared
+// once at the top-level command, they are accepted both before
+// and after the sub-command name.
+var (
+	// OptDebug is the -d/--debug option
+	OptDebug = argv.Option{
+		Name:       "-d",
+		Aliases:    []string{"--debug"},
+		Help:       "Enable debug output",
+		Persistent: true,
+	}
+
+	// OptVerbose is the -v/--verbose option
+	OptVerbose = argv.Option{
+		Name:       "-v",
+		Aliases:    []string{"--verbose"},
+		Help:       "Enable verbose debug output",
+		Persistent: true,
+	}
+)
+
+// LogLevel returns the log.Level, requested by the [OptDebug] and
+// [OptVerbose] options.
+//
+// If the Invocation has the sub-command, the sub-command's
+// Invocation is consulted, so options, specified after the
+// sub-command name, are taken into account.
+func LogLevel(inv *argv.Invocation) log.Level {
+	if sub, err := inv.SubInvocation(); err == nil && sub != nil {
+		return LogLevel(sub)
+	}
+
+	level := log.LevelInfo
+	if inv.Flag("-d") {
+		level = log.LevelDebug
+	}
+	if inv.Flag("-v") {
+		level = log.LevelTrace
+	}
+
+	return level
+}
@@ -0,0 +1,164 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Logging facilities
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Asynchronous Backend
+
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncBackend is the [Backend] that writes logs to the underlying
+// Backend asynchronously, from a separate goroutine, using the
+// bounded queue of records.
+//
+// If the queue is full, new records are dropped and counted, so
+// slow backend (i.e., file on a slow disk) cannot block the
+// caller. Records that contain [LevelFatal] lines are never
+// dropped: Send waits until they are written, because the
+// program is about to exit.
+type AsyncBackend struct {
+	backend Backend          // Underlying backend
+	queue   chan asyncRecord // Queue of records
+	done    chan struct{}    // Closed when goroutine exits
+	dropped atomic.Uint64    // Count of dropped records
+	closed  bool             // Backend is closed
+	lock    sync.RWMutex     // Protects closed against queue close
+}
+
+// asyncRecord is the record, queued by the AsyncBackend
+type asyncRecord struct {
+	stamp  Stamp         // Record time stamp
+	levels []Level       // Line levels
+	lines  [][]byte      // Lines
+	fields []Field       // Record fields
+	flush  chan struct{} // Non-nil for flush requests
+}
+
+// NewAsyncBackend returns a new [AsyncBackend] on the top of
+// the existent Backend, with the queue of the specified size.
+func NewAsyncBackend(b Backend, size int) *AsyncBackend {
+	bk := &AsyncBackend{
+		backend: b,
+		queue:   make(chan asyncRecord, size),
+		done:    make(chan struct{}),
+	}
+
+	go bk.proc()
+	return bk
+}
+
+// Send implements the [Backend.Send] interface.
+func (bk *AsyncBackend) Send(levels []Level, lines [][]byte) {
+	bk.SendStamp(NewStamp(), levels, lines, nil)
+}
+
+// SendFields implements the [FieldsBackend.SendFields] interface.
+func (bk *AsyncBackend) SendFields(levels []Level, lines [][]byte,
+	fields []Field) {
+	bk.SendStamp(NewStamp(), levels, lines, fields)
+}
+
+// SendStamp implements the [StampBackend.SendStamp] interface.
+//
+// Stamp and fields are passed to the underlying Backend, if it
+// implements the [StampBackend] or [FieldsBackend] interface, so
+// records are stamped with the time they were sent, not written.
+func (bk *AsyncBackend) SendStamp(stamp Stamp, levels []Level,
+	lines [][]byte, fields []Field) {
+
+	// Make a copy. Caller may reuse buffers after return.
+	rec := asyncRecord{
+		stamp:  stamp,
+		levels: make([]Level, len(levels)),
+		lines:  make([][]byte, len(lines)),
+		fields: fields,
+	}
+
+	copy(rec.levels, levels)
+	fatal := false
+	for i := range lines {
+		rec.lines[i] = append([]byte(nil), lines[i]...)
+		if levels[i] == LevelFatal {
+			fatal = true
+		}
+	}
+
+	bk.lock.RLock()
+	defer bk.lock.RUnlock()
+
+	if bk.closed {
+		return
+	}
+
+	if fatal {
+		rec.flush = make(chan struct{})
+		bk.queue <- rec
+		<-rec.flush
+		return
+	}
+
+	select {
+	case bk.queue <- rec:
+	default:
+		bk.dropped.Add(1)
+	}
+}
+
+// Dropped returns count of records, dropped because of
+// the queue overflow.
+func (bk *AsyncBackend) Dropped() uint64 {
+	return bk.dropped.Load()
+}
+
+// Flush waits until all queued records are written.
+func (bk *AsyncBackend) Flush() {
+	bk.lock.RLock()
+	defer bk.lock.RUnlock()
+
+	if bk.closed {
+		return
+	}
+
+	rec := asyncRecord{flush: make(chan struct{})}
+	bk.queue <- rec
+	<-rec.flush
+}
+
+// Close writes all queued records and stops the AsyncBackend.
+// Records, sent after Close, are silently discarded.
+func (bk *AsyncBackend) Close() {
+	bk.lock.Lock()
+	if bk.closed {
+		bk.lock.Unlock()
+		return
+	}
+
+	bk.closed = true
+	close(bk.queue)
+	bk.lock.Unlock()
+
+	<-bk.done
+}
+
+// proc writes queued records to the underlying Backend.
+// It runs in its own goroutine.
+func (bk *AsyncBackend) proc() {
+	defer close(bk.done)
+
+	for rec := range bk.queue {
+		if len(rec.lines) != 0 {
+			backendSend(bk.backend, rec.stamp,
+				rec.levels, rec.lines, rec.fields)
+		}
+
+		if rec.flush != nil {
+			close(rec.flush)
+		}
+	}
+}
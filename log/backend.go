@@ -10,8 +10,8 @@ package log
 
 // Standard backends:
 var (
-	// Console writes output to console.
-	Console Backend = &backendConsole{}
+	// Console writes output to console, without time stamps.
+	Console Backend = NewConsoleBackend(TimeNone)
 
 	// Console writes output to stderr.
 	Stderr Backend = &backendStderr{}
@@ -39,3 +39,24 @@ type Backend interface {
 	// it should avoid rotation in the middle of some record.
 	Send(levels []Level, lines [][]byte)
 }
+
+// Field is the named value, attached to the log Record
+// (i.e., the trace ID, see [WithTraceContext]).
+type Field struct {
+	Name  string // Field name
+	Value string // Field value
+}
+
+// FieldsBackend is the optional interface, implemented by the
+// Backends that can represent [Field]s, attached to the Record,
+// i.e., by the structured (JSON) Backends.
+//
+// Backends that don't implement this interface receive
+// only the lines, so the text output is not cluttered.
+type FieldsBackend interface {
+	Backend
+
+	// SendFields is like Backend.Send, but also receives
+	// fields of the record. Fields may be nil.
+	SendFields(levels []Level, lines [][]byte, fields []Field)
+}
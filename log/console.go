@@ -19,11 +19,30 @@ import (
 // backendConsole is the Backend that writes logs to console
 type backendConsole struct {
 	color int32      // No: -1, Yes: +1, Unknown: 0
+	tf    TimeFormat // Time stamps format
 	mutex sync.Mutex // Send lock
 }
 
+// NewConsoleBackend returns a Backend that writes logs to console,
+// like [Console], but prefixes lines with time stamps of the
+// specified format, followed by the request sequence number, if
+// available (see [WithRequestSeq]).
+//
+// The [TimeRelative] format is useful when sharing logs from
+// machines with the wrong clock.
+func NewConsoleBackend(tf TimeFormat) Backend {
+	return &backendConsole{tf: tf}
+}
+
 // Line implements [Backend.Send] method
 func (bk *backendConsole) Send(levels []Level, lines [][]byte) {
+	bk.SendStamp(NewStamp(), levels, lines, nil)
+}
+
+// SendStamp implements the [StampBackend.SendStamp] interface.
+func (bk *backendConsole) SendStamp(stamp Stamp, levels []Level,
+	lines [][]byte, fields []Field) {
+
 	// Color auto-detection
 	if atomic.LoadInt32(&bk.color) == 0 {
 		isatty := term.IsTerminal(int(os.Stdout.Fd()))
@@ -33,6 +52,8 @@ func (bk *backendConsole) Send(levels []Level, lines [][]byte) {
 	}
 
 	// Build the entire message in the buffer
+	prefix := stampPrefix(bk.tf, stamp, fields)
+
 	buf := bufAlloc()
 	defer bufFree(buf)
 
@@ -58,6 +79,10 @@ func (bk *backendConsole) Send(levels []Level, lines [][]byte) {
 		}
 
 		buf.Write([]byte(beg))
+		if prefix != "" {
+			buf.WriteString(prefix)
+			buf.WriteByte(' ')
+		}
 		buf.Write(line)
 		buf.Write([]byte(end + "\n"))
 	}
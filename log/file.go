@@ -16,7 +16,6 @@ import (
 	"path/filepath"
 	"strconv"
 	"sync"
-	"time"
 )
 
 // backendFile is the Backend that writes log to file.
@@ -25,6 +24,7 @@ type backendFile struct {
 	path    string     // Path to file
 	maxsize int        // Maximum file size before rotation
 	backups int        // Maximum number of created backups
+	tf      TimeFormat // Time stamps format
 	file    *os.File   // Output file
 }
 
@@ -51,18 +51,36 @@ type backendFile struct {
 // Setting maxsize to 0 disables rotation and setting backups
 // to 0 disables creation of the backup files.
 //
+// Lines are prefixed with the wall-clock time and the request
+// sequence number, if available (see [WithRequestSeq]).
+//
 // Note, file Backend ignores any I/O errors when writing to
 // log files, as it has no method to report them.
 func NewFileBackend(path string, maxsize, backups int) Backend {
+	return NewFileBackendFormat(path, maxsize, backups, TimeWall)
+}
+
+// NewFileBackendFormat is like [NewFileBackend], but allows to
+// specify format of the time stamps.
+func NewFileBackendFormat(path string, maxsize, backups int,
+	tf TimeFormat) Backend {
 	return &backendFile{
 		path:    path,
 		maxsize: maxsize,
 		backups: backups,
+		tf:      tf,
 	}
 }
 
 // Send implements the [Backend.Send] interface.
 func (bk *backendFile) Send(levels []Level, lines [][]byte) {
+	bk.SendStamp(NewStamp(), levels, lines, nil)
+}
+
+// SendStamp implements the [StampBackend.SendStamp] interface.
+func (bk *backendFile) SendStamp(stamp Stamp, levels []Level,
+	lines [][]byte, fields []Field) {
+
 	// Lock the Backend
 	bk.mutex.Lock()
 	defer bk.mutex.Unlock()
@@ -89,26 +107,18 @@ func (bk *backendFile) Send(levels []Level, lines [][]byte) {
 	// Rotate now
 	bk.rotate()
 
-	// Format time prefix
-	now := time.Now()
-
-	year, month, day := now.Date()
-	hour, min, sec := now.Clock()
-
-	prefix := fmt.Sprintf("%2.2d-%2.2d-%4.4d %2.2d:%2.2d:%2.2d:",
-		day, month, year,
-		hour, min, sec)
-
 	// Write log lines
+	prefix := stampPrefix(bk.tf, stamp, fields)
+
 	buf := bufAlloc()
 	defer bufFree(buf)
 
 	for _, line := range lines {
 		buf.WriteString(prefix)
-		if len(line) > 0 {
+		if prefix != "" && len(line) > 0 {
 			buf.WriteByte(' ')
-			buf.Write(line)
 		}
+		buf.Write(line)
 		buf.WriteByte('\n')
 	}
 
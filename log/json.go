@@ -0,0 +1,101 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Logging facilities
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// JSON Backend
+
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// backendJSON is the Backend that writes logs as JSON objects
+type backendJSON struct {
+	w     io.Writer  // Destination
+	mutex sync.Mutex // Send lock
+}
+
+// jsonLevelNames contains names of levels, used by the JSON Backend
+var jsonLevelNames = map[Level]string{
+	LevelTrace:   "trace",
+	LevelDebug:   "debug",
+	LevelInfo:    "info",
+	LevelWarning: "warning",
+	LevelError:   "error",
+	LevelFatal:   "fatal",
+}
+
+// NewJSONBackend returns a Backend that writes logs to the
+// [io.Writer] as JSON objects, one object per line.
+//
+// Each object contains the "time", "elapsed", "level" and "msg"
+// members, plus the fields, attached to the Record (see [FieldsBackend]),
+// i.e.:
+//
+//	{"time":"...","elapsed":12.345,"level":"debug","msg":"...","trace_id":"...","span_id":"..."}
+//
+// The "elapsed" member is the [Stamp.Elapsed] in seconds.
+//
+// Each record is written by a single Write call, so multi-line
+// records are never intermixed. Write errors are ignored.
+func NewJSONBackend(w io.Writer) Backend {
+	return &backendJSON{w: w}
+}
+
+// Send implements the [Backend.Send] interface.
+func (bk *backendJSON) Send(levels []Level, lines [][]byte) {
+	bk.SendFields(levels, lines, nil)
+}
+
+// SendFields implements the [FieldsBackend.SendFields] interface.
+func (bk *backendJSON) SendFields(levels []Level, lines [][]byte,
+	fields []Field) {
+	bk.SendStamp(NewStamp(), levels, lines, fields)
+}
+
+// SendStamp implements the [StampBackend.SendStamp] interface.
+func (bk *backendJSON) SendStamp(stamp Stamp, levels []Level,
+	lines [][]byte, fields []Field) {
+
+	buf := bufAlloc()
+	defer bufFree(buf)
+
+	now, _ := json.Marshal(stamp.Time.Format(time.RFC3339Nano))
+	elapsed, _ := json.Marshal(stamp.Elapsed.Seconds())
+
+	for i, line := range lines {
+		msg, _ := json.Marshal(string(line))
+		lvl, _ := json.Marshal(jsonLevelNames[levels[i]])
+
+		buf.WriteString(`{"time":`)
+		buf.Write(now)
+		buf.WriteString(`,"elapsed":`)
+		buf.Write(elapsed)
+		buf.WriteString(`,"level":`)
+		buf.Write(lvl)
+		buf.WriteString(`,"msg":`)
+		buf.Write(msg)
+
+		for _, fld := range fields {
+			name, _ := json.Marshal(fld.Name)
+			value, _ := json.Marshal(fld.Value)
+
+			buf.WriteByte(',')
+			buf.Write(name)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+
+		buf.WriteString("}\n")
+	}
+
+	bk.mutex.Lock()
+	bk.w.Write(buf.Bytes())
+	bk.mutex.Unlock()
+}
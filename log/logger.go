@@ -10,6 +10,8 @@ package log
 
 import (
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Standard loggers:
@@ -37,6 +39,8 @@ var (
 type Logger struct {
 	out     []loggerDest // Attached destinations
 	outLock sync.Mutex   // Destinations modification lock
+	start   time.Time    // Creation time, for Stamp.Elapsed
+	elapsed atomic.Int64 // Last Stamp.Elapsed
 }
 
 // loggerDest represents logging destination
@@ -54,6 +58,7 @@ func NewLogger(lvl Level, b Backend) *Logger {
 				backend: b,
 			},
 		},
+		start: time.Now(),
 	}
 }
 
@@ -132,7 +137,9 @@ func (lgr *Logger) Object(prefix string, level Level, indent int, obj Marshaler)
 }
 
 // send writes some lines to the Logger.
-func (lgr *Logger) send(prefix string, levels []Level, lines [][]byte) *Logger {
+func (lgr *Logger) send(prefix string, fields []Field,
+	levels []Level, lines [][]byte) *Logger {
+
 	// Prepend prefix
 	if prefix != "" {
 		prefixed := make([][]byte, len(lines))
@@ -143,6 +150,8 @@ func (lgr *Logger) send(prefix string, levels []Level, lines [][]byte) *Logger {
 	}
 
 	// Send message to all destinations
+	stamp := lgr.stamp()
+
 	lgr.outLock.Lock()
 	out := lgr.out
 	lgr.outLock.Unlock()
@@ -163,9 +172,33 @@ func (lgr *Logger) send(prefix string, levels []Level, lines [][]byte) *Logger {
 
 		// Send to destination
 		if len(filteredLines) > 0 {
-			dest.backend.Send(filteredLevels, filteredLines)
+			backendSend(dest.backend, stamp,
+				filteredLevels, filteredLines, fields)
 		}
 	}
 
 	return lgr
 }
+
+// stamp returns the Stamp for the new record.
+//
+// Stamp.Elapsed is computed from the monotonic clock and never
+// decreases, even if records are created concurrently.
+func (lgr *Logger) stamp() Stamp {
+	now := time.Now()
+	elapsed := int64(now.Sub(lgr.start))
+
+	for {
+		last := lgr.elapsed.Load()
+		if elapsed <= last {
+			elapsed = last
+			break
+		}
+
+		if lgr.elapsed.CompareAndSwap(last, elapsed) {
+			break
+		}
+	}
+
+	return Stamp{Time: now, Elapsed: time.Duration(elapsed)}
+}
@@ -0,0 +1,65 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Logging facilities
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Logger with multiple sinks
+
+package log
+
+import "time"
+
+// SinkSpec specifies a single logging sink for [NewLoggerMulti].
+type SinkSpec struct {
+	// Level is the minimal level of lines, admitted to the sink.
+	Level Level
+
+	// Backend is the sink's logging destination.
+	Backend Backend
+
+	// Buffer, if not zero, makes the sink asynchronous, with the
+	// queue of Buffer records. See [NewAsyncBackend] for details.
+	Buffer int
+}
+
+// NewLoggerMulti returns a new logger, attached to multiple sinks,
+// each with its own level threshold.
+//
+// Records are formatted once and dispatched to every sink whose
+// threshold admits them. Multi-line Records are delivered to each
+// sink atomically.
+//
+// Sinks with non-zero Buffer are wrapped with [NewAsyncBackend],
+// so slow sinks cannot block others beyond the bounded buffer.
+// Use [Logger.Close] to flush them.
+func NewLoggerMulti(sinks ...SinkSpec) *Logger {
+	lgr := &Logger{start: time.Now()}
+	for _, sink := range sinks {
+		b := sink.Backend
+		if sink.Buffer > 0 {
+			b = NewAsyncBackend(b, sink.Buffer)
+		}
+
+		lgr.out = append(lgr.out, loggerDest{level: sink.Level, backend: b})
+	}
+
+	return lgr
+}
+
+// Close flushes and closes all asynchronous backends, attached
+// to the Logger.
+//
+// Other backends are not affected. Lines, written to the Logger
+// after Close, are not delivered to the closed backends.
+func (lgr *Logger) Close() {
+	lgr.outLock.Lock()
+	out := lgr.out
+	lgr.outLock.Unlock()
+
+	for _, dest := range out {
+		if async, ok := dest.backend.(*AsyncBackend); ok {
+			async.Close()
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Logging facilities
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Logger with multiple sinks test
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testSlowBackend is the Backend that blocks in Send until released
+type testSlowBackend struct {
+	entered chan struct{} // Signaled when Send is entered
+	release chan struct{} // Closed to unblock Send
+	lock    sync.Mutex    // Access lock
+	records int           // Count of received records
+}
+
+// newTestSlowBackend creates a new testSlowBackend
+func newTestSlowBackend() *testSlowBackend {
+	return &testSlowBackend{
+		entered: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+}
+
+// Send implements the [Backend.Send] interface.
+func (bk *testSlowBackend) Send(levels []Level, lines [][]byte) {
+	select {
+	case bk.entered <- struct{}{}:
+	default:
+	}
+
+	<-bk.release
+
+	bk.lock.Lock()
+	bk.records++
+	bk.lock.Unlock()
+}
+
+// TestLoggerMultiLevels tests per-sink level filtering
+func TestLoggerMultiLevels(t *testing.T) {
+	var console, file bytes.Buffer
+
+	lgr := NewLoggerMulti(
+		SinkSpec{Level: LevelInfo, Backend: NewWriterBackend(&console)},
+		SinkSpec{Level: LevelDebug, Backend: NewWriterBackend(&file),
+			Buffer: 16},
+	)
+
+	lgr.Trace("", "trace")
+	lgr.Debug("", "debug")
+	lgr.Info("", "info")
+	lgr.Error("", "error")
+	lgr.Close()
+
+	expected := "info\nerror\n"
+	if console.String() != expected {
+		t.Errorf("console sink:\nexpected: %q\npresent:  %q",
+			expected, console.String())
+	}
+
+	expected = "debug\ninfo\nerror\n"
+	if file.String() != expected {
+		t.Errorf("file sink:\nexpected: %q\npresent:  %q",
+			expected, file.String())
+	}
+
+	// Lines written after Close are not delivered to the
+	// closed asynchronous sink.
+	lgr.Info("", "late")
+	if strings.Contains(file.String(), "late") {
+		t.Errorf("file sink: write after Close delivered")
+	}
+}
+
+// TestLoggerMultiDrop tests dropping of records by the slow sink
+func TestLoggerMultiDrop(t *testing.T) {
+	const total = 10
+	const buffer = 2
+
+	var console bytes.Buffer
+	slow := newTestSlowBackend()
+	async := NewAsyncBackend(slow, buffer)
+
+	lgr := NewLogger(LevelAll, NewWriterBackend(&console))
+	lgr.Attach(LevelAll, async)
+
+	// The first record blocks the slow sink. Then the queue
+	// fills up and the rest records are dropped.
+	lgr.Info("", "record 0")
+	<-slow.entered
+
+	for i := 1; i < total; i++ {
+		lgr.Info("", "record %d", i)
+	}
+
+	expected := uint64(total - 1 - buffer)
+	if async.Dropped() != expected {
+		t.Errorf("dropped: expected %d, present %d",
+			expected, async.Dropped())
+	}
+
+	// Console must not be affected
+	if n := strings.Count(console.String(), "\n"); n != total {
+		t.Errorf("console sink: %d records expected, %d present",
+			total, n)
+	}
+
+	close(slow.release)
+	async.Close()
+
+	if slow.records != 1+buffer {
+		t.Errorf("slow sink: %d records expected, %d present",
+			1+buffer, slow.records)
+	}
+}
+
+// TestLoggerMultiFatal tests that Fatal records are never dropped
+func TestLoggerMultiFatal(t *testing.T) {
+	slow := newTestSlowBackend()
+	async := NewAsyncBackend(slow, 1)
+	defer async.Close()
+
+	async.Send([]Level{LevelInfo}, [][]byte{[]byte("info")})
+	<-slow.entered
+	async.Send([]Level{LevelInfo}, [][]byte{[]byte("queued")})
+
+	done := make(chan struct{})
+	go func() {
+		async.Send([]Level{LevelFatal}, [][]byte{[]byte("fatal")})
+		close(done)
+	}()
+
+	close(slow.release)
+	<-done
+
+	if async.Dropped() != 0 {
+		t.Errorf("%d records dropped", async.Dropped())
+	}
+
+	slow.lock.Lock()
+	records := slow.records
+	slow.lock.Unlock()
+
+	if records != 3 {
+		t.Errorf("3 records expected, %d present", records)
+	}
+}
+
+// TestLoggerMultiAtomic tests that multi-line Records are
+// delivered atomically to all sinks.
+func TestLoggerMultiAtomic(t *testing.T) {
+	const writers = 8
+	const records = 50
+	const lines = 5
+
+	var buf1, buf2 bytes.Buffer
+
+	lgr := NewLoggerMulti(
+		SinkSpec{Level: LevelAll, Backend: NewWriterBackend(&buf1)},
+		SinkSpec{Level: LevelAll, Backend: NewWriterBackend(&buf2),
+			Buffer: writers * records},
+	)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for r := 0; r < records; r++ {
+				rec := lgr.Begin("")
+				for l := 0; l < lines; l++ {
+					rec.Info("%d-%d line %d", w, r, l)
+				}
+				rec.Commit()
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	lgr.Close()
+
+	for i, buf := range []*bytes.Buffer{&buf1, &buf2} {
+		out := strings.Split(strings.TrimSuffix(buf.String(), "\n"),
+			"\n")
+
+		if len(out) != writers*records*lines {
+			t.Errorf("sink %d: %d lines expected, %d present",
+				i, writers*records*lines, len(out))
+			continue
+		}
+
+		for n := 0; n < len(out); n += lines {
+			id, _, _ := strings.Cut(out[n], " ")
+			for l := 0; l < lines; l++ {
+				expected := fmt.Sprintf("%s line %d", id, l)
+				if out[n+l] != expected {
+					t.Errorf("sink %d: record %s split",
+						i, id)
+					break
+				}
+			}
+		}
+	}
+}
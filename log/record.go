@@ -24,6 +24,7 @@ import (
 type Record struct {
 	parent *Logger    // Parent logger
 	prefix string     // Log prefix
+	fields []Field    // Record fields
 	lines  [][]byte   // Collected lines
 	levels []Level    // Corresponding levels
 	mutex  sync.Mutex // Access lock
@@ -48,7 +49,7 @@ func (rec *Record) Flush() *Record {
 	rec.levels = rec.levels[:0]
 	rec.mutex.Unlock()
 
-	rec.parent.send(rec.prefix, levels, lines)
+	rec.parent.send(rec.prefix, rec.fields, levels, lines)
 	return rec
 }
 
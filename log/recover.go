@@ -0,0 +1,158 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Logging facilities
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Panic recovery
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// RecoverOptions configures the [Recover] behavior.
+type RecoverOptions struct {
+	// AllGoroutines, if set, causes stacks of all goroutines
+	// to be logged, not only the stack of the panicked one.
+	AllGoroutines bool
+
+	// CrashDir, if not empty, is the directory where crash
+	// dump files are written. Each panic creates a new file,
+	// named crash-YYYYMMDD-HHMMSS.uuuuuu-PID.txt, that contains
+	// the same text as logged.
+	CrashDir string
+
+	// Repanic, if set, causes Recover to re-panic with the same
+	// value after the panic is logged. Otherwise, Recover
+	// terminates the program with os.Exit(1).
+	//
+	// It only has effect if onPanic callback is nil.
+	Repanic bool
+}
+
+// recoverOptions contains the current RecoverOptions.
+var recoverOptions atomic.Pointer[RecoverOptions]
+
+// SetRecoverOptions sets the [RecoverOptions], used by [Recover].
+// It is safe to call it concurrently with Recover.
+func SetRecoverOptions(opts RecoverOptions) {
+	recoverOptions.Store(&opts)
+}
+
+// Recover recovers panic and writes it to the [Logger] associated
+// with the Context, as a single Fatal-level [Record], including
+// the panic value and the call stack. It is designed to be used
+// at the goroutine entry points:
+//
+//	defer log.Recover(ctx, nil)
+//
+// Note, Recover must be deferred directly, as shown above. If it
+// is called from another deferred function, it will not recover
+// anything.
+//
+// If onPanic is not nil, it is called after the panic is logged,
+// and goroutine continues as if the deferred function returned
+// normally. Otherwise, Recover exits the program or re-panics, as
+// specified by the [RecoverOptions] (see [SetRecoverOptions]).
+//
+// If there is no panic, Recover does nothing.
+func Recover(ctx context.Context, onPanic func()) {
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	opts := RecoverOptions{}
+	if p := recoverOptions.Load(); p != nil {
+		opts = *p
+	}
+
+	// Format panic message
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "panic: %v\n", v)
+	if opts.AllGoroutines {
+		buf.Write(recoverAllStacks())
+	} else {
+		buf.Write(debug.Stack())
+	}
+
+	// Write log record and crash dump file
+	rec := Begin(ctx)
+	rec.text(LevelFatal, 0, buf.Bytes())
+
+	if opts.CrashDir != "" {
+		file, err := recoverCrashDump(opts.CrashDir, buf.Bytes())
+		if err != nil {
+			rec.format(LevelFatal, "crash dump: %s", err)
+		} else {
+			rec.format(LevelFatal, "crash dump saved to %s", file)
+		}
+	}
+
+	rec.Commit()
+
+	// Finish panic handling
+	switch {
+	case onPanic != nil:
+		onPanic()
+	case opts.Repanic:
+		panic(v)
+	default:
+		CtxLogger(ctx).Close()
+		os.Exit(1)
+	}
+}
+
+// recoverAllStacks returns stacks of all goroutines.
+func recoverAllStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// recoverCrashDump writes the crash dump file into the dir.
+// It returns the file name.
+func recoverCrashDump(dir string, data []byte) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("crash-%s-%d.txt",
+		time.Now().Format("20060102-150405.000000"), os.Getpid())
+	file := filepath.Join(dir, name)
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	fp, err := os.OpenFile(file, flags, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = fp.Write(data)
+	err2 := fp.Close()
+	if err == nil {
+		err = err2
+	}
+
+	if err != nil {
+		os.Remove(file)
+		return "", err
+	}
+
+	return file, nil
+}
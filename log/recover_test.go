@@ -0,0 +1,213 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Logging facilities
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Panic recovery test
+
+package log
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testRecordsBackend is the Backend that collects records,
+// one string per Send call
+type testRecordsBackend struct {
+	lock    sync.Mutex // Access lock
+	records []string   // Collected records
+}
+
+// Send implements the [Backend.Send] interface.
+func (bk *testRecordsBackend) Send(levels []Level, lines [][]byte) {
+	var buf strings.Builder
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	bk.lock.Lock()
+	bk.records = append(bk.records, buf.String())
+	bk.lock.Unlock()
+}
+
+// lookup returns all records that contain the substring
+func (bk *testRecordsBackend) lookup(substr string) []string {
+	bk.lock.Lock()
+	defer bk.lock.Unlock()
+
+	var found []string
+	for _, rec := range bk.records {
+		if strings.Contains(rec, substr) {
+			found = append(found, rec)
+		}
+	}
+
+	return found
+}
+
+// testRecoverNoise writes log messages until done is closed.
+// It signals started after the first message.
+func testRecoverNoise(ctx context.Context, started *sync.WaitGroup,
+	done chan struct{}) {
+
+	Info(ctx, "noise")
+	started.Done()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			Info(ctx, "noise")
+		}
+	}
+}
+
+// TestRecover tests Recover in the HTTP handler
+func TestRecover(t *testing.T) {
+	defer SetRecoverOptions(RecoverOptions{})
+
+	dir := filepath.Join(t.TempDir(), "crash")
+	SetRecoverOptions(RecoverOptions{
+		AllGoroutines: true,
+		CrashDir:      dir,
+	})
+
+	bk := &testRecordsBackend{}
+	ctx := NewContext(context.Background(), NewLogger(LevelAll, bk))
+
+	// Log concurrently, while handler panics
+	done := make(chan struct{})
+	var started, wait sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		started.Add(1)
+		wait.Add(1)
+		go func() {
+			testRecoverNoise(ctx, &started, done)
+			wait.Done()
+		}()
+	}
+
+	started.Wait()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter,
+		rq *http.Request) {
+		defer Recover(ctx, func() {
+			http.Error(w, "internal error",
+				http.StatusInternalServerError)
+		})
+
+		panic("boom")
+	})
+
+	rq := httptest.NewRequest("GET", "/", nil)
+	rsp := httptest.NewRecorder()
+	handler.ServeHTTP(rsp, rq)
+
+	close(done)
+	wait.Wait()
+
+	if rsp.Code != http.StatusInternalServerError {
+		t.Errorf("onPanic not called: HTTP %d", rsp.Code)
+	}
+
+	// Panic must be logged as a single record
+	records := bk.lookup("panic: boom")
+	if len(records) != 1 {
+		t.Fatalf("expected 1 panic record, present %d", len(records))
+	}
+
+	rec := records[0]
+	if !strings.HasPrefix(rec, "panic: boom\n") {
+		t.Errorf("panic record starts with %q",
+			strings.SplitN(rec, "\n", 2)[0])
+	}
+
+	for _, s := range []string{"TestRecover", "testRecoverNoise",
+		"crash dump saved to " + dir} {
+		if !strings.Contains(rec, s) {
+			t.Errorf("panic record: %q missed", s)
+		}
+	}
+
+	if strings.Contains(rec, "noise\n") {
+		t.Errorf("panic record intermixed with other records")
+	}
+
+	// Check the crash dump file
+	files, err := filepath.Glob(filepath.Join(dir, "crash-*.txt"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("crash dump: %d files, %v", len(files), err)
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("crash dump: %s", err)
+	}
+
+	if !strings.HasPrefix(string(data), "panic: boom\n") ||
+		!strings.Contains(string(data), "testRecoverNoise") {
+		t.Errorf("crash dump: unexpected content:\n%s", data)
+	}
+
+	if !strings.Contains(rec, "crash dump saved to "+files[0]) {
+		t.Errorf("panic record doesn't refer %s", files[0])
+	}
+}
+
+// TestRecoverRepanic tests Recover with RecoverOptions.Repanic
+func TestRecoverRepanic(t *testing.T) {
+	defer SetRecoverOptions(RecoverOptions{})
+
+	// CrashDir is not a directory, so crash dump fails
+	notdir := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(notdir, nil, 0644)
+
+	SetRecoverOptions(RecoverOptions{
+		Repanic:  true,
+		CrashDir: notdir,
+	})
+
+	bk := &testRecordsBackend{}
+	ctx := NewContext(context.Background(), NewLogger(LevelAll, bk))
+
+	var v any
+	func() {
+		defer func() { v = recover() }()
+		defer Recover(ctx, nil)
+		panic("again")
+	}()
+
+	if v != "again" {
+		t.Errorf("Repanic: %v", v)
+	}
+
+	records := bk.lookup("panic: again")
+	switch {
+	case len(records) != 1:
+		t.Errorf("expected 1 panic record, present %d", len(records))
+	case !strings.Contains(records[0], "crash dump: "):
+		t.Errorf("crash dump error missed:\n%s", records[0])
+	case strings.Contains(records[0], "testRecoverNoise"):
+		t.Errorf("unexpected stacks of other goroutines")
+	}
+
+	// Without panic, Recover does nothing
+	called := false
+	func() {
+		defer Recover(ctx, func() { called = true })
+	}()
+
+	if called {
+		t.Errorf("onPanic called without panic")
+	}
+}
@@ -0,0 +1,63 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Logging facilities
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Request sequence number for log correlation
+
+package log
+
+import (
+	"context"
+	"fmt"
+)
+
+// FieldRequestSeq is the name of the log field, generated from
+// the request sequence number (see [WithRequestSeq]).
+const FieldRequestSeq = "request_seq"
+
+// contextKeyRequestSeq specifies a request sequence number,
+// associated with the Context.
+var contextKeyRequestSeq = contextKey{"log-request-seq"}
+
+// contextValueRequestSeq wraps request sequence number for
+// context.WithValue
+type contextValueRequestSeq struct{ seq uint64 }
+
+// WithRequestSeq returns a new [context.Context] with the associated
+// request sequence number.
+//
+// Log records, created with this Context, automatically include
+// the [FieldRequestSeq] field, formatted with [RequestSeqString],
+// so they can be joined with the protocol trace files, named
+// after the same number.
+func WithRequestSeq(parent context.Context, seq uint64) context.Context {
+	return context.WithValue(parent, contextKeyRequestSeq,
+		contextValueRequestSeq{seq})
+}
+
+// CtxRequestSeq returns a request sequence number, associated with
+// the [context.Context]. If no number is available, it returns
+// false as a second value.
+//
+// Note, [context.Context] parameter may be safely passed as nil.
+func CtxRequestSeq(ctx context.Context) (uint64, bool) {
+	if ctx != nil {
+		v := ctx.Value(contextKeyRequestSeq)
+		if v != nil {
+			ctxv, ok := v.(contextValueRequestSeq)
+			if ok {
+				return ctxv.seq, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// RequestSeqString formats the request sequence number as
+// it appears in logs and trace file names.
+func RequestSeqString(seq uint64) string {
+	return fmt.Sprintf("%8.8d", seq)
+}
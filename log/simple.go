@@ -21,7 +21,7 @@ import (
 // If Logger is not available, [DefaultLogger] will be used.
 // The [context.Context] parameter may be safely passed as nil.
 func Trace(ctx context.Context, format string, v ...any) {
-	CtxLogger(ctx).Trace(CtxPrefix(ctx), format, v...)
+	Begin(ctx).Trace(format, v...).Commit()
 }
 
 // Debug writes a Debug-level message to the [Logger] associated
@@ -30,7 +30,7 @@ func Trace(ctx context.Context, format string, v ...any) {
 // If Logger is not available, [DefaultLogger] will be used.
 // The [context.Context] parameter may be safely passed as nil.
 func Debug(ctx context.Context, format string, v ...any) {
-	CtxLogger(ctx).Debug(CtxPrefix(ctx), format, v...)
+	Begin(ctx).Debug(format, v...).Commit()
 }
 
 // Info writes a Info-level message to the [Logger] associated
@@ -39,7 +39,7 @@ func Debug(ctx context.Context, format string, v ...any) {
 // If Logger is not available, [DefaultLogger] will be used.
 // The [context.Context] parameter may be safely passed as nil.
 func Info(ctx context.Context, format string, v ...any) {
-	CtxLogger(ctx).Info(CtxPrefix(ctx), format, v...)
+	Begin(ctx).Info(format, v...).Commit()
 }
 
 // Warning writes a Warning-level message to the [Logger] associated
@@ -48,7 +48,7 @@ func Info(ctx context.Context, format string, v ...any) {
 // If Logger is not available, [DefaultLogger] will be used.
 // The [context.Context] parameter may be safely passed as nil.
 func Warning(ctx context.Context, format string, v ...any) {
-	CtxLogger(ctx).Warning(CtxPrefix(ctx), format, v...)
+	Begin(ctx).Warning(format, v...).Commit()
 }
 
 // Error writes a Error-level message to the [Logger] associated
@@ -57,7 +57,7 @@ func Warning(ctx context.Context, format string, v ...any) {
 // If Logger is not available, [DefaultLogger] will be used.
 // The [context.Context] parameter may be safely passed as nil.
 func Error(ctx context.Context, format string, v ...any) {
-	CtxLogger(ctx).Error(CtxPrefix(ctx), format, v...)
+	Begin(ctx).Error(format, v...).Commit()
 }
 
 // Fatal writes a Fatal-level message to the [Logger] associated
@@ -68,7 +68,7 @@ func Error(ctx context.Context, format string, v ...any) {
 // If Logger is not available, [DefaultLogger] will be used.
 // The [context.Context] parameter may be safely passed as nil.
 func Fatal(ctx context.Context, format string, v ...any) {
-	CtxLogger(ctx).Fatal(CtxPrefix(ctx), format, v...)
+	Begin(ctx).Fatal(format, v...)
 }
 
 // Dump writes the hex dump to the [Logger] associated
@@ -77,11 +77,13 @@ func Fatal(ctx context.Context, format string, v ...any) {
 // If Logger is not available, [DefaultLogger] will be used.
 // The [context.Context] parameter may be safely passed as nil.
 func Dump(ctx context.Context, level Level, data []byte) {
-	CtxLogger(ctx).Dump(CtxPrefix(ctx), level, data)
+	Begin(ctx).Dump(level, data).Commit()
 }
 
 // Panic writes panic message to log, including the call stack,
-// and terminates the program
+// and terminates the program.
+//
+// For the goroutine entry points, see also [Recover].
 func Panic(ctx context.Context, v any) {
 	var buf bytes.Buffer
 	fmt.Fprintf(&buf, "panic: %v\n", v)
@@ -91,9 +93,14 @@ func Panic(ctx context.Context, v any) {
 
 // Begin initiates creation of a new multi-line log [Record].
 //
+// If Context has the associated [TraceContext], the Record
+// includes the trace and span IDs as fields.
+//
 // See [Logger.Begin] for details.
 func Begin(ctx context.Context) *Record {
-	return CtxLogger(ctx).Begin(CtxPrefix(ctx))
+	rec := CtxLogger(ctx).Begin(CtxPrefix(ctx))
+	rec.fields = ctxFields(ctx)
+	return rec
 }
 
 // Object writes any object that implements [Marshaler]
@@ -103,6 +110,6 @@ func Begin(ctx context.Context) *Record {
 // The [context.Context] parameter may be safely passed as nil.
 func Object(ctx context.Context, level Level, indent int,
 	obj Marshaler) context.Context {
-	CtxLogger(ctx).Object(CtxPrefix(ctx), level, indent, obj)
+	Begin(ctx).Object(level, indent, obj).Commit()
 	return ctx
 }
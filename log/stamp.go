@@ -0,0 +1,113 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Logging facilities
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Record time stamps
+
+package log
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stamp is the time stamp of the log Record.
+//
+// It contains both the wall-clock time and the monotonic delta
+// since the [Logger] creation. The delta is not affected by the
+// wall clock adjustments, and never decreases between Records,
+// written to the same Logger.
+type Stamp struct {
+	Time    time.Time     // Wall-clock time
+	Elapsed time.Duration // Monotonic time since Logger creation
+}
+
+// TimeFormat specifies how text Backends format the record
+// time stamps.
+type TimeFormat int
+
+// TimeFormat values:
+const (
+	TimeNone     TimeFormat = iota // No time stamps
+	TimeWall                       // Wall-clock time
+	TimeRelative                   // "+12.345s" since Logger creation
+)
+
+// StampBackend is the optional interface, implemented by the
+// Backends that can write the record [Stamp].
+//
+// Backends that don't implement this interface stamp records
+// by themselves, if they need it.
+type StampBackend interface {
+	Backend
+
+	// SendStamp is like FieldsBackend.SendFields, but also
+	// receives the record Stamp.
+	SendStamp(stamp Stamp, levels []Level, lines [][]byte,
+		fields []Field)
+}
+
+// NewStamp returns the Stamp for the current time, for Backends,
+// used without the [Logger].
+func NewStamp() Stamp {
+	return Stamp{Time: time.Now()}
+}
+
+// Format formats the Stamp according to the [TimeFormat].
+//
+// It returns "" for the TimeNone.
+func (stamp Stamp) Format(tf TimeFormat) string {
+	switch tf {
+	case TimeWall:
+		year, month, day := stamp.Time.Date()
+		hour, min, sec := stamp.Time.Clock()
+
+		return fmt.Sprintf("%2.2d-%2.2d-%4.4d %2.2d:%2.2d:%2.2d",
+			day, month, year,
+			hour, min, sec)
+
+	case TimeRelative:
+		ms := stamp.Elapsed.Milliseconds()
+		return fmt.Sprintf("+%d.%3.3ds", ms/1000, ms%1000)
+	}
+
+	return ""
+}
+
+// stampPrefix returns the text prefix for lines of the record
+// with the specified Stamp and fields: the formatted Stamp,
+// followed by the request sequence number, if available (see
+// [WithRequestSeq]).
+//
+// It returns "" for the TimeNone.
+func stampPrefix(tf TimeFormat, stamp Stamp, fields []Field) string {
+	if tf == TimeNone {
+		return ""
+	}
+
+	prefix := stamp.Format(tf)
+	for _, fld := range fields {
+		if fld.Name == FieldRequestSeq {
+			prefix += " #" + fld.Value
+		}
+	}
+
+	return prefix + ":"
+}
+
+// backendSend sends the record to the Backend, using the most
+// capable interface the Backend implements.
+func backendSend(b Backend, stamp Stamp, levels []Level, lines [][]byte,
+	fields []Field) {
+
+	switch b := b.(type) {
+	case StampBackend:
+		b.SendStamp(stamp, levels, lines, fields)
+	case FieldsBackend:
+		b.SendFields(levels, lines, fields)
+	default:
+		b.Send(levels, lines)
+	}
+}
@@ -0,0 +1,167 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Logging facilities
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Record time stamps test
+
+package log
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStampBackend is the Backend that records received Stamps
+type testStampBackend struct {
+	lock   sync.Mutex // Access lock
+	stamps []Stamp    // Received stamps
+}
+
+// Send implements the [Backend.Send] interface.
+func (bk *testStampBackend) Send(levels []Level, lines [][]byte) {
+	panic("StampBackend.SendStamp expected")
+}
+
+// SendStamp implements the [StampBackend.SendStamp] interface.
+func (bk *testStampBackend) SendStamp(stamp Stamp, levels []Level,
+	lines [][]byte, fields []Field) {
+
+	bk.lock.Lock()
+	bk.stamps = append(bk.stamps, stamp)
+	bk.lock.Unlock()
+}
+
+// TestStampMonotonic tests that Stamp.Elapsed never decreases
+// across records
+func TestStampMonotonic(t *testing.T) {
+	bk := &testStampBackend{}
+	lgr := NewLogger(LevelAll, bk)
+
+	const writers, records = 8, 500
+
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < records; j++ {
+				lgr.Info("", "record %d", j)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(bk.stamps) != writers*records {
+		t.Fatalf("%d records received, expected %d",
+			len(bk.stamps), writers*records)
+	}
+
+	// Records, written concurrently, may be delivered in the
+	// different order, but Elapsed must be sane.
+	limit := time.Since(lgr.start)
+	for _, stamp := range bk.stamps {
+		if stamp.Elapsed < 0 || stamp.Elapsed > limit {
+			t.Fatalf("Elapsed out of range: %s", stamp.Elapsed)
+		}
+	}
+
+	// Sequential records: Elapsed never regress
+	bk.stamps = nil
+	for i := 0; i < records; i++ {
+		lgr.Info("", "record %d", i)
+	}
+
+	for i := 1; i < len(bk.stamps); i++ {
+		prev, next := bk.stamps[i-1], bk.stamps[i]
+		if next.Elapsed < prev.Elapsed {
+			t.Fatalf("Elapsed regressed: %s -> %s",
+				prev.Elapsed, next.Elapsed)
+		}
+	}
+
+	// Async sink must keep the original stamps
+	async := NewAsyncBackend(&testStampBackend{}, 16)
+	lgr = NewLoggerMulti(SinkSpec{Level: LevelAll, Backend: bk},
+		SinkSpec{Level: LevelAll, Backend: async})
+
+	bk.stamps = nil
+	lgr.Info("", "record")
+	lgr.Close()
+
+	received := async.backend.(*testStampBackend).stamps
+	if len(received) != 1 || received[0] != bk.stamps[0] {
+		t.Errorf("AsyncBackend: stamps mismatch: %v vs %v",
+			received, bk.stamps)
+	}
+}
+
+// TestStampFormat tests Stamp.Format
+func TestStampFormat(t *testing.T) {
+	tm := time.Date(2025, time.March, 7, 9, 5, 3, 0, time.UTC)
+
+	type testData struct {
+		elapsed  time.Duration
+		tf       TimeFormat
+		expected string
+	}
+
+	tests := []testData{
+		{0, TimeRelative, "+0.000s"},
+		{12345 * time.Millisecond, TimeRelative, "+12.345s"},
+		{12345678 * time.Microsecond, TimeRelative, "+12.345s"},
+		{5 * time.Millisecond, TimeRelative, "+0.005s"},
+		{time.Hour + 500*time.Millisecond, TimeRelative, "+3600.500s"},
+		{time.Second, TimeWall, "07-03-2025 09:05:03"},
+		{time.Second, TimeNone, ""},
+	}
+
+	for _, test := range tests {
+		stamp := Stamp{Time: tm, Elapsed: test.elapsed}
+		s := stamp.Format(test.tf)
+		if s != test.expected {
+			t.Errorf("Format(%s, %d): expected %q, present %q",
+				test.elapsed, test.tf, test.expected, s)
+		}
+	}
+}
+
+// TestStampFileRelative tests file Backend with the relative
+// time stamps and request sequence numbers
+func TestStampFileRelative(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	lgr := NewLogger(LevelAll,
+		NewFileBackendFormat(path, 0, 0, TimeRelative))
+
+	ctx := NewContext(context.Background(), lgr)
+	Info(ctx, "no request")
+	Info(WithRequestSeq(ctx, 12), "request")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("2 lines expected, present:\n%s", data)
+	}
+
+	for i, suffix := range []string{
+		"s: no request",
+		"s #00000012: request",
+	} {
+		line := lines[i]
+		if !strings.HasPrefix(line, "+") ||
+			!strings.HasSuffix(line, suffix) {
+			t.Errorf("line %d: unexpected %q", i, line)
+		}
+	}
+}
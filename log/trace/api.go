@@ -40,7 +40,7 @@ func OnRequest(query *transport.ServerQuery,
 	if writer != nil {
 		cc := body
 		if body != nil {
-			body, cc = transport.TeeReadCloser2(body)
+			body, cc = traceBody(body)
 		}
 
 		writer.OnRequest(query, msg, cc)
@@ -74,7 +74,7 @@ func OnResponse(query *transport.ServerQuery,
 	if writer != nil {
 		cc := body
 		if body != nil {
-			body, cc = transport.TeeReadCloser2(body)
+			body, cc = traceBody(body)
 		}
 
 		writer.OnResponse(query, msg, cc)
@@ -82,3 +82,13 @@ func OnResponse(query *transport.ServerQuery,
 
 	return body
 }
+
+// traceBody splits the message body into the primary stream and
+// the copy for the tracer.
+//
+// Failure of the tracer doesn't affect the primary stream. Closing
+// the primary stream closes the copy.
+func traceBody(body io.ReadCloser) (primary, copy io.ReadCloser) {
+	rpipe, wpipe := io.Pipe()
+	return transport.BroadcastReadCloser(body, wpipe), rpipe
+}
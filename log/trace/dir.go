@@ -0,0 +1,228 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Protocol tracer
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Trace writer -- the directory mode
+
+package trace
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/util/generic"
+)
+
+// IndexFileName is the name of the index file, written by the
+// [NewDirWriter] into the trace directory.
+const IndexFileName = "index.jsonl"
+
+// IndexRecord is the line of the index file (see [IndexFileName]).
+// It describes the single exchange (the request/response pair).
+//
+// Records are appended in order of exchanges completion, not in
+// order of their start.
+type IndexRecord struct {
+	Seq      string      `json:"seq"`                // Request sequence number
+	Time     time.Time   `json:"time"`               // Request time
+	Protocol string      `json:"protocol,omitempty"` // I.e., "IPP"
+	Method   string      `json:"method,omitempty"`   // HTTP method
+	URL      string      `json:"url,omitempty"`      // Request URL
+	Request  string      `json:"request,omitempty"`  // Request name
+	Response string      `json:"response,omitempty"` // Response name
+	Status   int         `json:"status,omitempty"`   // HTTP status
+	Complete bool        `json:"complete"`           // Response completed
+	Files    []IndexFile `json:"files"`              // Exchange files
+
+	pending int // Count of pending body writes
+}
+
+// IndexFile describes the single file of the [IndexRecord].
+type IndexFile struct {
+	Name string    `json:"name"` // Path, relative to the trace directory
+	Size int       `json:"size"` // File size
+	Time time.Time `json:"time"` // When file was written
+}
+
+// hookRename is os.Rename, replaced by tests to simulate crashes.
+var hookRename = os.Rename
+
+// dirWriter writes trace files into the directory.
+//
+// It is not synchronized; the Writer serializes calls.
+type dirWriter struct {
+	path      string                  // Directory path
+	durable   bool                    // Sync bulk data files
+	index     *os.File                // The index file
+	exchanges map[string]*IndexRecord // Incomplete exchanges
+	subdirs   generic.Set[string]     // Already created subdirectories
+}
+
+// newDirWriter creates a new dirWriter.
+func newDirWriter(dir string, durable bool) (*dirWriter, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	const flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC | os.O_APPEND
+	index, err := os.OpenFile(filepath.Join(dir, IndexFileName),
+		flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	dw := &dirWriter{
+		path:      dir,
+		durable:   durable,
+		index:     index,
+		exchanges: make(map[string]*IndexRecord),
+		subdirs:   generic.NewSet[string](),
+	}
+
+	return dw, nil
+}
+
+// close writes incomplete exchanges into the index and closes
+// the dirWriter.
+func (dw *dirWriter) close() error {
+	seqs := make([]string, 0, len(dw.exchanges))
+	for seq := range dw.exchanges {
+		seqs = append(seqs, seq)
+	}
+	sort.Strings(seqs)
+
+	var err error
+	for _, seq := range seqs {
+		err2 := dw.writeIndex(dw.exchanges[seq])
+		if err == nil {
+			err = err2
+		}
+	}
+
+	clear(dw.exchanges)
+
+	err2 := dw.index.Close()
+	if err == nil {
+		err = err2
+	}
+
+	return err
+}
+
+// send writes the file and adds it into the index.
+//
+// The file is written under the temporary dot-prefixed name and
+// then renamed into place. Files, except bulk data, are synced
+// before rename; the bulk data is synced if dw.durable is set.
+func (dw *dirWriter) send(name string, data []byte, bulk bool) error {
+	// Create subdirectory, if needed
+	dir, base := path.Split(name)
+	dir = path.Clean(dir)
+	if dir != "." && !dw.subdirs.Contains(dir) {
+		err := os.MkdirAll(filepath.Join(dw.path, dir), 0755)
+		if err == nil {
+			err = syncDir(dw.path)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		dw.subdirs.Add(dir)
+	}
+
+	// Write the file
+	sync := !bulk || dw.durable
+	final := filepath.Join(dw.path, filepath.FromSlash(name))
+	tmp := filepath.Join(filepath.Dir(final), "."+base+".tmp")
+
+	err := dirWriteFile(tmp, data, sync)
+	if err == nil {
+		err = hookRename(tmp, final)
+	}
+
+	if err == nil && sync {
+		err = syncDir(filepath.Dir(final))
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Update the index
+	seq, _, _ := strings.Cut(name, "/")
+	return dw.update(seq, func(rec *IndexRecord) {
+		rec.Files = append(rec.Files, IndexFile{
+			Name: name,
+			Size: len(data),
+			Time: time.Now(),
+		})
+	})
+}
+
+// update updates the IndexRecord of the exchange. When exchange is
+// complete, the record is written into the index.
+func (dw *dirWriter) update(seq string, fn func(*IndexRecord)) error {
+	rec := dw.exchanges[seq]
+	if rec == nil {
+		rec = &IndexRecord{Seq: seq, Files: []IndexFile{}}
+		dw.exchanges[seq] = rec
+	}
+
+	fn(rec)
+
+	if !rec.Complete || rec.pending != 0 {
+		return nil
+	}
+
+	delete(dw.exchanges, seq)
+	return dw.writeIndex(rec)
+}
+
+// writeIndex appends the IndexRecord to the index file.
+//
+// The record is written by the single write and synced, so it
+// appears in the index atomically.
+func (dw *dirWriter) writeIndex(rec *IndexRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	_, err = dw.index.Write(append(line, '\n'))
+	if err == nil {
+		err = dw.index.Sync()
+	}
+
+	return err
+}
+
+// dirWriteFile writes the file and optionally syncs it to disk.
+func dirWriteFile(name string, data []byte, sync bool) error {
+	const flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	fp, err := os.OpenFile(name, flags, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = fp.Write(data)
+	if err == nil && sync {
+		err = fp.Sync()
+	}
+
+	err2 := fp.Close()
+	if err == nil {
+		err = err2
+	}
+
+	return err
+}
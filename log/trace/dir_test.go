@@ -0,0 +1,220 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Protocol tracer
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Trace writer test -- the directory mode
+
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/log"
+)
+
+// testDirWriter creates the directory mode Writer
+func testDirWriter(t *testing.T) (*Writer, string) {
+	name := filepath.Join(t.TempDir(), "trace")
+
+	logger := log.NewLogger(log.LevelError, log.Console)
+	ctx := log.NewContext(context.Background(), logger)
+
+	writer, err := NewDirWriter(ctx, name, false)
+	if err != nil {
+		t.Fatalf("NewDirWriter: %s", err)
+	}
+
+	return writer, name
+}
+
+// testDirIndex reads the index file
+func testDirIndex(t *testing.T, name string) []IndexRecord {
+	fp, err := os.Open(filepath.Join(name, IndexFileName))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer fp.Close()
+
+	var records []IndexRecord
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		var rec IndexRecord
+		err := json.Unmarshal(scanner.Bytes(), &rec)
+		if err != nil {
+			t.Fatalf("%s: %q: %s", IndexFileName, scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+
+	return records
+}
+
+// testDirExchange writes the exchange files the same way as
+// Writer.OnRequest and Writer.OnResponse do
+func testDirExchange(writer *Writer, seq string, body []byte) {
+	writer.onExchange(seq, func(rec *IndexRecord) {
+		rec.Protocol = "IPP"
+		rec.Method = "POST"
+		rec.URL = "/ipp/print"
+		rec.Request = "Print-Job"
+	})
+
+	writer.Send(seq+"/req-Print-Job.http", []byte("POST /ipp/print\r\n"))
+	writer.Send(seq+"/req-Print-Job.ipp", []byte("operation Print-Job"))
+	writer.sendBody(seq, seq+"/req-Print-Job", bytes.NewReader(body))
+
+	writer.onExchange(seq, func(rec *IndexRecord) {
+		rec.Response = "successful-ok"
+	})
+
+	writer.Send(seq+"/rsp-successful-ok.ipp", []byte("status successful-ok"))
+	writer.Send(seq+"/rsp-successful-ok.http", []byte("HTTP/1.1 200 OK\r\n"))
+
+	writer.onExchange(seq, func(rec *IndexRecord) {
+		rec.Status = 200
+		rec.Complete = true
+	})
+}
+
+// TestDirIndex tests the index of concurrent exchanges
+func TestDirIndex(t *testing.T) {
+	writer, name := testDirWriter(t)
+
+	const count = 32
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			seq := fmt.Sprintf("%8.8d", i+1)
+			body := bytes.Repeat([]byte{byte(i)}, 1000+i)
+			testDirExchange(writer, seq, body)
+			wg.Done()
+		}(i)
+	}
+
+	wg.Wait()
+	writer.Close()
+
+	records := testDirIndex(t, name)
+	if len(records) != count {
+		t.Fatalf("%s: expected %d records, present %d",
+			IndexFileName, count, len(records))
+	}
+
+	seen := make(map[string]bool)
+	for _, rec := range records {
+		if seen[rec.Seq] {
+			t.Errorf("%s: duplicated record", rec.Seq)
+		}
+		seen[rec.Seq] = true
+
+		if !rec.Complete || rec.Status != 200 ||
+			rec.Request != "Print-Job" ||
+			rec.Response != "successful-ok" {
+			t.Errorf("%s: bad record: %+v", rec.Seq, rec)
+		}
+
+		if len(rec.Files) != 5 {
+			t.Errorf("%s: expected 5 files, present %d",
+				rec.Seq, len(rec.Files))
+		}
+
+		// Files must belong to the exchange and match
+		// sizes on disk
+		for _, file := range rec.Files {
+			if !strings.HasPrefix(file.Name, rec.Seq+"/") {
+				t.Errorf("%s: foreign file %s", rec.Seq, file.Name)
+			}
+
+			fi, err := os.Stat(filepath.Join(name, file.Name))
+			switch {
+			case err != nil:
+				t.Errorf("%s: %s", rec.Seq, err)
+			case fi.Size() != int64(file.Size):
+				t.Errorf("%s: %s: size %d in index, %d on disk",
+					rec.Seq, file.Name, file.Size, fi.Size())
+			}
+		}
+	}
+}
+
+// TestDirCrash tests that if writer crashes before rename,
+// no partial files are visible under the final names.
+func TestDirCrash(t *testing.T) {
+	writer, name := testDirWriter(t)
+
+	errCrash := errors.New("simulated crash")
+	hookRename = func(oldpath, newpath string) error {
+		if strings.HasSuffix(newpath, ".bin") {
+			return errCrash
+		}
+		return os.Rename(oldpath, newpath)
+	}
+	defer func() { hookRename = os.Rename }()
+
+	testDirExchange(writer, "00000001", []byte("document data"))
+	writer.Close()
+
+	// Only the index and complete files must be visible
+	var visible []string
+	filepath.WalkDir(name, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() &&
+			!strings.HasPrefix(d.Name(), ".") {
+			rel, _ := filepath.Rel(name, path)
+			visible = append(visible, filepath.ToSlash(rel))
+		}
+		return err
+	})
+
+	for _, file := range visible {
+		if strings.HasSuffix(file, ".bin") {
+			t.Errorf("%s: partial file visible", file)
+		}
+	}
+
+	// The lost file must not be in the index
+	for _, rec := range testDirIndex(t, name) {
+		for _, file := range rec.Files {
+			if strings.HasSuffix(file.Name, ".bin") {
+				t.Errorf("%s: lost file indexed", file.Name)
+			}
+		}
+	}
+
+	if writer.err == nil {
+		t.Errorf("crash not reported")
+	}
+}
+
+// TestDirIncomplete tests that incomplete exchanges are written
+// into the index on Close
+func TestDirIncomplete(t *testing.T) {
+	writer, name := testDirWriter(t)
+
+	writer.onExchange("00000001", func(rec *IndexRecord) {
+		rec.Request = "Print-Job"
+	})
+	writer.Send("00000001/aborted", []byte("aborted on shutdown\n"))
+	writer.Close()
+
+	records := testDirIndex(t, name)
+	switch {
+	case len(records) != 1:
+		t.Errorf("expected 1 record, present %d", len(records))
+	case records[0].Complete || len(records[0].Files) != 1:
+		t.Errorf("bad record: %+v", records[0])
+	}
+}
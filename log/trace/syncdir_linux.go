@@ -0,0 +1,30 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Protocol tracer
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Directory sync -- Linux version
+
+//go:build linux
+
+package trace
+
+import "os"
+
+// syncDir syncs the directory to disk, so renames and newly
+// created entries survive the crash.
+func syncDir(dir string) error {
+	fp, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = fp.Sync()
+	err2 := fp.Close()
+	if err == nil {
+		err = err2
+	}
+
+	return err
+}
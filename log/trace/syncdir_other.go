@@ -0,0 +1,19 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Protocol tracer
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Directory sync -- the portable fallback
+
+//go:build !linux
+
+package trace
+
+// syncDir syncs the directory to disk.
+//
+// Not all systems support syncing of directories, so it does
+// nothing here.
+func syncDir(dir string) error {
+	return nil
+}
@@ -21,12 +21,16 @@ import (
 	"github.com/OpenPrinting/go-mfp/transport"
 )
 
-// Writer writes a protocol trace
+// Writer writes a protocol trace.
+//
+// The trace is written either into the .tar archive (see [NewWriter])
+// or into the directory (see [NewDirWriter]).
 type Writer struct {
 	ctx      context.Context // Logging context
 	name     string          // file name
 	fp       *os.File        // Underlying file
 	tar      *tar.Writer     // TAR writer
+	dir      *dirWriter      // Directory writer, nil for .tar
 	lock     sync.Mutex      // Access lock
 	err      error           // First error
 	donewait sync.WaitGroup  // Wait for async activities
@@ -59,6 +63,45 @@ func NewWriter(ctx context.Context, name string) (*Writer, error) {
 	return writer, nil
 }
 
+// NewDirWriter creates a new trace writer, that writes trace
+// files into the directory instead of the .tar archive.
+//
+// Each file first is written under the temporary dot-prefixed
+// name and then renamed into place, so the file, visible under
+// its final name, is always complete. The protocol message files
+// are synced to disk, the bulk data (i.e., printed documents) is
+// synced only if durable is true.
+//
+// For each exchange (the request/response pair), the line is
+// appended to the name/index.jsonl file, that describes the
+// exchange and lists its files. See [IndexRecord] for details.
+//
+// The name.log file is created the same way as by [NewWriter].
+func NewDirWriter(ctx context.Context, name string, durable bool) (
+	*Writer, error) {
+
+	nameLog := name + ".log"
+
+	// Create name/
+	dir, err := newDirWriter(name, durable)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create name.log
+	os.Remove(nameLog)
+	backend := log.NewFileBackend(nameLog, 0, 0)
+	log.CtxLogger(ctx).Attach(log.LevelTrace, backend)
+
+	writer := &Writer{
+		ctx:  ctx,
+		name: name,
+		dir:  dir,
+	}
+
+	return writer, nil
+}
+
 // Close closes the Writer
 func (writer *Writer) Close() {
 	writer.donewait.Wait()
@@ -66,6 +109,14 @@ func (writer *Writer) Close() {
 	writer.lock.Lock()
 	defer writer.lock.Unlock()
 
+	if writer.dir != nil {
+		err := writer.dir.close()
+		if err != nil {
+			writer.setError(err)
+		}
+		return
+	}
+
 	err := writer.tar.Close()
 	if err != nil {
 		writer.setError(err)
@@ -83,22 +134,22 @@ func (writer *Writer) Close() {
 func (writer *Writer) OnRequest(query *transport.ServerQuery,
 	msg Message, body io.Reader) {
 
-	name := fmt.Sprintf("%8.8d/req-%s", query.ID(), msg.Name())
+	seq := querySeq(query)
+	name := fmt.Sprintf("%s/req-%s", seq, msg.Name())
+
+	writer.onExchange(seq, func(rec *IndexRecord) {
+		rec.Time = time.Now()
+		rec.Protocol = msg.Protocol()
+		rec.Method = query.RequestMethod()
+		rec.URL = query.RequestURL().String()
+		rec.Request = msg.Name()
+	})
 
 	writer.Send(name+".http", query.DumpRequest())
 	writer.Send(name+"."+msg.Ext(), msg.MarshalTrace())
 
 	if body != nil {
-		writer.donewait.Add(1)
-		go func() {
-			data, _ := io.ReadAll(body)
-
-			if len(data) != 0 {
-				writer.Send(name+"."+magic(data), data)
-			}
-
-			writer.donewait.Done()
-		}()
+		writer.sendBody(seq, name, body)
 	}
 }
 
@@ -109,25 +160,26 @@ func (writer *Writer) OnRequest(query *transport.ServerQuery,
 func (writer *Writer) OnResponse(query *transport.ServerQuery,
 	msg Message, body io.Reader) {
 
-	name := fmt.Sprintf("%8.8d/rsp-%s", query.ID(), msg.Name())
-	writer.Send(name+"."+msg.Ext(), msg.MarshalTrace())
+	seq := querySeq(query)
+	name := fmt.Sprintf("%s/rsp-%s", seq, msg.Name())
 
-	if body != nil {
-		writer.donewait.Add(1)
-		go func() {
-			data, _ := io.ReadAll(body)
+	writer.onExchange(seq, func(rec *IndexRecord) {
+		rec.Response = msg.Name()
+	})
 
-			if len(data) != 0 {
-				writer.Send(name+"."+magic(data), data)
-			}
+	writer.Send(name+"."+msg.Ext(), msg.MarshalTrace())
 
-			writer.donewait.Done()
-		}()
+	if body != nil {
+		writer.sendBody(seq, name, body)
 	}
 
 	onCompletion := func(query *transport.ServerQuery) {
 		dump := query.DumpResponse()
 		writer.Send(name+".http", dump)
+		writer.onExchange(seq, func(rec *IndexRecord) {
+			rec.Status = query.ResponseStatus()
+			rec.Complete = true
+		})
 	}
 
 	if query.IsFinished() {
@@ -137,11 +189,74 @@ func (writer *Writer) OnResponse(query *transport.ServerQuery,
 	}
 }
 
+// sendBody asynchronously reads the message body and writes
+// it as the bulk data file.
+func (writer *Writer) sendBody(seq, name string, body io.Reader) {
+	writer.onExchange(seq, func(rec *IndexRecord) {
+		rec.pending++
+	})
+
+	writer.donewait.Add(1)
+	go func() {
+		data, _ := io.ReadAll(body)
+
+		if len(data) != 0 {
+			writer.send(name+"."+magic(data), data, true)
+		}
+
+		writer.onExchange(seq, func(rec *IndexRecord) {
+			rec.pending--
+		})
+
+		writer.donewait.Done()
+	}()
+}
+
+// onExchange calls the callback to update the index record of
+// the exchange, identified by the sequence number. When exchange
+// is complete, the record is written to the index.
+//
+// In the .tar mode the index is not maintained and callback
+// is not called.
+func (writer *Writer) onExchange(seq string, update func(*IndexRecord)) {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+
+	if writer.dir != nil {
+		err := writer.dir.update(seq, update)
+		if err != nil {
+			writer.setError(err)
+		}
+	}
+}
+
+// querySeq returns the request sequence number of the query,
+// formatted for use in file names.
+//
+// It is the same number that log records of the query contain
+// (see [log.WithRequestSeq]), so trace files can be joined with
+// the log.
+func querySeq(query *transport.ServerQuery) string {
+	seq, ok := log.CtxRequestSeq(query.RequestContext())
+	if !ok {
+		seq = query.ID()
+	}
+
+	return log.RequestSeqString(seq)
+}
+
 // Send writes a new record (a file) into the writer archive.
 //
 // If data is nil, nothing is written, but if data is the empty
 // slice, the empty file is written.
 func (writer *Writer) Send(name string, data []byte) {
+	writer.send(name, data, false)
+}
+
+// send writes a new record (a file). The bulk flag marks the bulk
+// data (i.e., printed documents), that is not synced to disk in
+// the directory mode, unless requested.
+func (writer *Writer) send(name string, data []byte, bulk bool) {
 	writer.lock.Lock()
 	defer writer.lock.Unlock()
 
@@ -151,6 +266,16 @@ func (writer *Writer) Send(name string, data []byte) {
 
 	log.Debug(writer.ctx, "%s: %d bytes saved", name, len(data))
 
+	if writer.dir != nil {
+		if writer.err == nil {
+			err := writer.dir.send(name, data, bulk)
+			if err != nil {
+				writer.setError(err)
+			}
+		}
+		return
+	}
+
 	hdr := tar.Header{
 		Typeflag: tar.TypeReg,
 		Name:     name,
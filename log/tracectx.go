@@ -0,0 +1,145 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Logging facilities
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Trace context (trace and span IDs) for log correlation
+
+package log
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// TraceID is the OpenTelemetry-compatible 16-byte trace identifier.
+type TraceID [16]byte
+
+// SpanID is the OpenTelemetry-compatible 8-byte span identifier.
+type SpanID [8]byte
+
+// TraceContext identifies the current operation within the
+// distributed trace.
+//
+// It doesn't record or export anything. It only provides the
+// consistent IDs for logs and W3C traceparent headers.
+type TraceContext struct {
+	TraceID TraceID // Trace ID
+	SpanID  SpanID  // Span ID of the current operation
+	Flags   byte    // W3C trace flags
+}
+
+// Names of log fields, generated from the TraceContext:
+const (
+	FieldTraceID = "trace_id"
+	FieldSpanID  = "span_id"
+)
+
+// contextKeyTrace specifies a TraceContext, associated with the Context.
+var contextKeyTrace = contextKey{"log-trace"}
+
+// contextValueTrace wraps TraceContext for context.WithValue
+type contextValueTrace struct{ tc TraceContext }
+
+// String returns TraceID as a lower-case hex string.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsZero reports whether TraceID is all-zeroes (i.e., invalid).
+func (id TraceID) IsZero() bool {
+	return id == TraceID{}
+}
+
+// String returns SpanID as a lower-case hex string.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// IsZero reports whether SpanID is all-zeroes (i.e., invalid).
+func (id SpanID) IsZero() bool {
+	return id == SpanID{}
+}
+
+// NewTraceContext returns a new TraceContext, with the
+// random trace and span IDs, that starts the new trace.
+func NewTraceContext() TraceContext {
+	var tc TraceContext
+	for tc.TraceID.IsZero() {
+		rand.Read(tc.TraceID[:])
+	}
+
+	tc.SpanID = newSpanID()
+	return tc
+}
+
+// Child returns a TraceContext for the child operation:
+// the same trace, but the new span ID.
+func (tc TraceContext) Child() TraceContext {
+	tc.SpanID = newSpanID()
+	return tc
+}
+
+// IsValid reports whether TraceContext is valid (i.e., both
+// trace and span IDs are not zero).
+func (tc TraceContext) IsValid() bool {
+	return !tc.TraceID.IsZero() && !tc.SpanID.IsZero()
+}
+
+// newSpanID generates a new random SpanID.
+func newSpanID() SpanID {
+	var id SpanID
+	for id.IsZero() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// WithTraceContext returns a new [context.Context] with the associated
+// [TraceContext].
+//
+// Log records, created with this Context, automatically include
+// the [FieldTraceID] and [FieldSpanID] fields.
+func WithTraceContext(parent context.Context,
+	tc TraceContext) context.Context {
+	return context.WithValue(parent, contextKeyTrace, contextValueTrace{tc})
+}
+
+// CtxTraceContext returns a [TraceContext] associated with the
+// [context.Context]. If no TraceContext is available, it returns
+// false as a second value.
+//
+// Note, [context.Context] parameter may be safely passed as nil.
+func CtxTraceContext(ctx context.Context) (TraceContext, bool) {
+	if ctx != nil {
+		v := ctx.Value(contextKeyTrace)
+		if v != nil {
+			ctxv, ok := v.(contextValueTrace)
+			if ok {
+				return ctxv.tc, true
+			}
+		}
+	}
+
+	return TraceContext{}, false
+}
+
+// ctxFields returns log fields, associated with the Context.
+func ctxFields(ctx context.Context) []Field {
+	var fields []Field
+
+	if tc, ok := CtxTraceContext(ctx); ok {
+		fields = append(fields,
+			Field{Name: FieldTraceID, Value: tc.TraceID.String()},
+			Field{Name: FieldSpanID, Value: tc.SpanID.String()})
+	}
+
+	if seq, ok := CtxRequestSeq(ctx); ok {
+		fields = append(fields,
+			Field{Name: FieldRequestSeq, Value: RequestSeqString(seq)})
+	}
+
+	return fields
+}
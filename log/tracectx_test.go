@@ -0,0 +1,116 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Logging facilities
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Trace context test
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestTraceContext tests TraceContext generation
+func TestTraceContext(t *testing.T) {
+	tc := NewTraceContext()
+	if !tc.IsValid() {
+		t.Errorf("NewTraceContext: invalid %+v", tc)
+	}
+
+	child := tc.Child()
+	if child.TraceID != tc.TraceID {
+		t.Errorf("Child: trace ID changed")
+	}
+	if child.SpanID == tc.SpanID || child.SpanID.IsZero() {
+		t.Errorf("Child: span ID not regenerated")
+	}
+
+	if s := tc.TraceID.String(); len(s) != 32 || strings.ToLower(s) != s {
+		t.Errorf("TraceID.String: %q", s)
+	}
+
+	if s := tc.SpanID.String(); len(s) != 16 {
+		t.Errorf("SpanID.String: %q", s)
+	}
+
+	ctx := WithTraceContext(context.Background(), tc)
+	tc2, ok := CtxTraceContext(ctx)
+	if !ok || tc2 != tc {
+		t.Errorf("CtxTraceContext: %+v %v", tc2, ok)
+	}
+
+	_, ok = CtxTraceContext(context.Background())
+	if ok {
+		t.Errorf("CtxTraceContext: found in alien Context")
+	}
+}
+
+// TestTraceContextJSON tests appearance of the trace fields
+// in the JSON log output.
+func TestTraceContextJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lgr := NewLogger(LevelAll, NewJSONBackend(buf))
+	ctx := NewContext(context.Background(), lgr)
+
+	// Without TraceContext
+	Debug(ctx, "no trace")
+
+	// With TraceContext, including multi-line Record
+	tc := NewTraceContext()
+	ctx = WithTraceContext(ctx, tc)
+
+	Info(ctx, "traced")
+	Begin(ctx).Debug("line 1").Warning("line 2").Commit()
+
+	type record struct {
+		Level   string `json:"level"`
+		Msg     string `json:"msg"`
+		TraceID string `json:"trace_id"`
+		SpanID  string `json:"span_id"`
+	}
+
+	var records []record
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec record
+		err := json.Unmarshal([]byte(line), &rec)
+		if err != nil {
+			t.Fatalf("%q: %s", line, err)
+		}
+		records = append(records, rec)
+	}
+
+	expected := []record{
+		{"debug", "no trace", "", ""},
+		{"info", "traced", tc.TraceID.String(), tc.SpanID.String()},
+		{"debug", "line 1", tc.TraceID.String(), tc.SpanID.String()},
+		{"warning", "line 2", tc.TraceID.String(), tc.SpanID.String()},
+	}
+
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, present %d:\n%s",
+			len(expected), len(records), buf)
+	}
+
+	for i := range expected {
+		if records[i] != expected[i] {
+			t.Errorf("record %d:\nexpected: %+v\npresent:  %+v",
+				i, expected[i], records[i])
+		}
+	}
+
+	// Text Backends are not affected
+	buf.Reset()
+	lgr = NewLogger(LevelAll, NewWriterBackend(buf))
+	ctx = WithTraceContext(NewContext(context.Background(), lgr), tc)
+	Info(ctx, "traced")
+
+	if s := buf.String(); s != "traced\n" {
+		t.Errorf("text backend: %q", s)
+	}
+}
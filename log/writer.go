@@ -0,0 +1,44 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Logging facilities
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// io.Writer Backend
+
+package log
+
+import (
+	"io"
+	"sync"
+)
+
+// backendWriter is the Backend that writes logs to io.Writer
+type backendWriter struct {
+	w     io.Writer  // Destination
+	mutex sync.Mutex // Send lock
+}
+
+// NewWriterBackend returns a Backend that writes logs to
+// the [io.Writer], line by line, without any decorations.
+//
+// Each record is written by a single Write call, so multi-line
+// records are never intermixed. Write errors are ignored.
+func NewWriterBackend(w io.Writer) Backend {
+	return &backendWriter{w: w}
+}
+
+// Send implements the [Backend.Send] interface.
+func (bk *backendWriter) Send(levels []Level, lines [][]byte) {
+	buf := bufAlloc()
+	defer bufFree(buf)
+
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	bk.mutex.Lock()
+	bk.w.Write(buf.Bytes())
+	bk.mutex.Unlock()
+}
@@ -0,0 +1,116 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Capturing model from the real device
+
+package modeling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// CaptureOptions define the device endpoints, queried by [Capture].
+//
+// Multiple endpoints of the same protocol are assumed to be aliases
+// of the same device; the first one that responds is used.
+type CaptureOptions struct {
+	IPP  []string // IPP printer endpoints
+	ESCL []string // eSCL scanner endpoints
+	WSD  []string // WS-Scan scanner endpoints
+	USB  string   // USB device serial number, "" if none
+}
+
+// Capture creates a new [Model] and fills it with the printer and
+// scanner capabilities, downloaded from the real device.
+//
+// On success, caller must [Model.Close] the returned Model after use.
+func Capture(ctx context.Context, opt CaptureOptions) (*Model, error) {
+	if opt.IPP == nil && opt.ESCL == nil && opt.WSD == nil &&
+		opt.USB == "" {
+		return nil, errors.New("no device endpoints to capture from")
+	}
+
+	model, err := NewModel()
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.IPP != nil {
+		err = model.DownloadIPPPrinterAttrs(ctx, opt.IPP)
+		if err != nil {
+			err = fmt.Errorf(
+				"Can't get IPP Printer Attributes: %s", err)
+		}
+	}
+
+	if err == nil && opt.ESCL != nil {
+		err = model.DownloadESCLScannerCapabilities(ctx, opt.ESCL)
+		if err != nil {
+			err = fmt.Errorf(
+				"Can't get eSCL ScannerCapabilities: %s", err)
+		}
+	}
+
+	if err == nil && opt.WSD != nil {
+		err = model.DownloadWSDScannerCapabilities(ctx, opt.WSD)
+		if err != nil {
+			err = fmt.Errorf(
+				"Can't get WSD ScannerCapabilities: %s", err)
+		}
+	}
+
+	if err == nil && opt.USB != "" {
+		err = model.DownloadUSBDeviceDescriptor(ctx, opt.USB)
+		if err != nil {
+			err = fmt.Errorf(
+				"Can't get USB DeviceDescriptor: %s", err)
+		}
+	}
+
+	captureLogPools(ctx, opt)
+
+	if err != nil {
+		model.Close()
+		return nil, err
+	}
+
+	return model, nil
+}
+
+// captureLogPools writes statistics of the [transport.SharedPool]-s,
+// used to talk to the device, into the log.
+func captureLogPools(ctx context.Context, opt CaptureOptions) {
+	seen := make(map[*transport.SharedPool]struct{})
+
+	var endpoints []string
+	endpoints = append(endpoints, opt.IPP...)
+	endpoints = append(endpoints, opt.ESCL...)
+	endpoints = append(endpoints, opt.WSD...)
+
+	for _, ep := range endpoints {
+		u, err := transport.ParseAddr(ep, "ipp://localhost")
+		if err != nil {
+			continue
+		}
+
+		pool := transport.PoolFor(u)
+		if _, found := seen[pool]; found {
+			continue
+		}
+		seen[pool] = struct{}{}
+
+		stats := pool.Stats()
+		log.Debug(ctx, "%s: %d requests, %d connections "+
+			"(%d reused), %d TLS handshakes, %d resumed",
+			pool.Key(), stats.Requests, stats.Conns,
+			stats.Reused(), stats.TLSHandshakes, stats.TLSResumed)
+	}
+}
@@ -0,0 +1,100 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Capturing model from the real device test
+
+package modeling
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/internal/assert"
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestCaptureSharedPool tests that Capture reuses connections
+// to the device across the IPP and eSCL fetch phases.
+func TestCaptureSharedPool(t *testing.T) {
+	kyocera := testutils.Kyocera.ECOSYS.M2040dn
+
+	// Fake device, serving IPP and eSCL on the same port
+	var handshakes atomic.Int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipp/print", func(w http.ResponseWriter,
+		rq *http.Request) {
+
+		var msg goipp.Message
+		err := msg.Decode(rq.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var rsp goipp.Message
+		assert.NoError(rsp.DecodeBytes(kyocera.IPP.PrinterAttributes))
+		rsp.RequestID = msg.RequestID
+
+		w.Header().Set("Content-Type", goipp.ContentType)
+		rsp.Encode(w)
+	})
+
+	mux.HandleFunc("/eSCL/ScannerCapabilities", func(w http.ResponseWriter,
+		rq *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write(kyocera.ESCL.ScannerCapabilities)
+	})
+
+	srv := httptest.NewUnstartedServer(mux)
+	srv.TLS = &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (
+			*tls.Config, error) {
+			handshakes.Add(1)
+			return nil, nil
+		},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "https://")
+	opt := CaptureOptions{
+		IPP:  []string{"ipps://" + host + "/ipp/print"},
+		ESCL: []string{"https://" + host + "/eSCL"},
+	}
+
+	// Capture the model
+	model, err := Capture(context.Background(), opt)
+	if err != nil {
+		t.Fatalf("Capture: %s", err)
+	}
+	defer model.Close()
+
+	if model.GetIPPPrinterAttrs() == nil {
+		t.Errorf("Capture: missed IPP printer attributes")
+	}
+
+	if model.GetESCLScanCaps() == nil {
+		t.Errorf("Capture: missed eSCL scanner capabilities")
+	}
+
+	// Check connections reuse
+	if n := handshakes.Load(); n != 1 {
+		t.Errorf("TLS handshakes: expected 1, present %d", n)
+	}
+
+	stats := transport.PoolFor(transport.MustParseURL(srv.URL)).Stats()
+	if stats.Conns != 1 || stats.Reused() == 0 {
+		t.Errorf("PoolStats: %+v", stats)
+	}
+}
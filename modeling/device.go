@@ -0,0 +1,250 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Device configuration part of Model
+
+package modeling
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/cpython"
+)
+
+// Protocol names, used as keys in the [DeviceConfig] maps.
+const (
+	ProtoIPP  = "ipp"
+	ProtoESCL = "escl"
+	ProtoWSD  = "wsd"
+)
+
+// deviceDefaultPaths contains default base paths of the
+// protocol services.
+var deviceDefaultPaths = map[string]string{
+	ProtoIPP:  "/ipp/print",
+	ProtoESCL: "/eSCL",
+	ProtoWSD:  "/WSScan",
+}
+
+// DeviceConfig defines the device-level configuration of the
+// modeled device: which protocols are enabled and where their
+// services live.
+//
+// Protocols are identified by name (see ProtoIPP, ProtoESCL
+// and ProtoWSD). Protocols, missed in the maps, use the defaults:
+// protocol is enabled, if model has its capabilities, and its
+// service is mounted at the default path.
+type DeviceConfig struct {
+	Protocols map[string]bool   // Protocols enabled/disabled
+	Paths     map[string]string // Per-protocol base paths
+	Ports     map[string]int    // Per-protocol TCP port hints
+	TLS       bool              // Use TLS
+}
+
+// SetDeviceConfig sets the [DeviceConfig].
+func (model *Model) SetDeviceConfig(cfg *DeviceConfig) {
+	model.deviceConfig = cfg
+}
+
+// GetDeviceConfig returns the [DeviceConfig], previously set
+// with [Model.SetDeviceConfig] or loaded from the model file.
+//
+// It returns nil, if model has no device configuration.
+func (model *Model) GetDeviceConfig() *DeviceConfig {
+	return model.deviceConfig
+}
+
+// ProtocolEnabled reports if protocol is enabled by the
+// [DeviceConfig]. Protocols are enabled by default.
+//
+// Note, enabled protocol still needs the capabilities,
+// defined by the model, to be actually served.
+func (model *Model) ProtocolEnabled(proto string) bool {
+	if cfg := model.deviceConfig; cfg != nil {
+		if enabled, found := cfg.Protocols[proto]; found {
+			return enabled
+		}
+	}
+
+	return true
+}
+
+// ProtocolPath returns the base path of the protocol service.
+func (model *Model) ProtocolPath(proto string) string {
+	if cfg := model.deviceConfig; cfg != nil {
+		if path, found := cfg.Paths[proto]; found {
+			return path
+		}
+	}
+
+	return deviceDefaultPaths[proto]
+}
+
+// ProtocolPort returns the TCP port hint for the protocol,
+// or 0, if port is not specified.
+func (model *Model) ProtocolPort(proto string) int {
+	if cfg := model.deviceConfig; cfg != nil {
+		return cfg.Ports[proto]
+	}
+
+	return 0
+}
+
+// deviceExport exports DeviceConfig as Python object.
+func deviceExport(py *cpython.Python, cfg *DeviceConfig) *cpython.Object {
+	var kwargs []cpython.KWArg
+
+	if cfg.Protocols != nil {
+		kwargs = append(kwargs,
+			cpython.KWArg{Name: "Protocols", Value: cfg.Protocols})
+	}
+
+	if cfg.Paths != nil {
+		kwargs = append(kwargs,
+			cpython.KWArg{Name: "Paths", Value: cfg.Paths})
+	}
+
+	if cfg.Ports != nil {
+		kwargs = append(kwargs,
+			cpython.KWArg{Name: "Ports", Value: cfg.Ports})
+	}
+
+	kwargs = append(kwargs, cpython.KWArg{Name: "TLS", Value: cfg.TLS})
+
+	return py.Eval("device.DeviceConfig").CallKWArgs(kwargs)
+}
+
+// deviceLoad decodes the device configuration part of model. The model
+// file assumed to be preloaded into the Model's Python interpreter
+// (model.py).
+func (model *Model) deviceLoad() error {
+	obj := model.py.Eval("device.config")
+
+	if err := obj.Err(); err != nil {
+		err = fmt.Errorf("device.config: %w", err)
+		return err
+	}
+
+	if obj.IsNone() {
+		return nil
+	}
+
+	cfg, err := deviceImport(obj)
+	if err != nil {
+		err = fmt.Errorf("device.config: %w", err)
+		return err
+	}
+
+	model.deviceConfig = cfg
+	return nil
+}
+
+// deviceImport imports DeviceConfig from the Python object.
+func deviceImport(obj *cpython.Object) (*DeviceConfig, error) {
+	cfg := &DeviceConfig{}
+
+	err := deviceImportMap(obj, "Protocols", &cfg.Protocols,
+		(*cpython.Object).Bool)
+
+	if err == nil {
+		err = deviceImportMap(obj, "Paths", &cfg.Paths,
+			(*cpython.Object).Str)
+	}
+
+	if err == nil {
+		err = deviceImportMap(obj, "Ports", &cfg.Ports,
+			func(obj *cpython.Object) (int, error) {
+				v, err := obj.Int()
+				return int(v), err
+			})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	tls := obj.Get("TLS")
+	switch {
+	case tls.NotFound():
+	case tls.Err() != nil:
+		return nil, errImportWrap("TLS", tls.Err())
+	default:
+		cfg.TLS, err = tls.Bool()
+		if err != nil {
+			return nil, errImportWrap("TLS", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// deviceImportMap imports the DeviceConfig map, represented
+// by the Python dict attribute of the specified name, using
+// the supplied function to decode map values.
+//
+// Map keys must be known protocol names.
+func deviceImportMap[T any](obj *cpython.Object, name string,
+	out *map[string]T, decode func(*cpython.Object) (T, error)) error {
+
+	dict := obj.Get(name)
+	switch {
+	case dict.NotFound():
+		return nil
+	case dict.Err() != nil:
+		return errImportWrap(name, dict.Err())
+	case dict.IsNone():
+		return nil
+	case !dict.IsDict():
+		return errImportWrap(name,
+			fmt.Errorf("%s is not dict", dict.TypeName()))
+	}
+
+	keys, err := dict.Keys()
+	if err != nil {
+		return errImportWrap(name, err)
+	}
+
+	m := make(map[string]T, len(keys))
+	for _, keyobj := range keys {
+		key, err := keyobj.Str()
+		if err != nil {
+			return errImportWrap(name, err)
+		}
+
+		if _, known := deviceDefaultPaths[key]; !known {
+			err = fmt.Errorf("unknown protocol %q (known are: %s)",
+				key, deviceKnownProtocols())
+			return errImportWrap(name, err)
+		}
+
+		item := dict.GetItem(keyobj)
+		if err = item.Err(); err != nil {
+			return errImportWrap(name+"["+key+"]", err)
+		}
+
+		m[key], err = decode(item)
+		if err != nil {
+			return errImportWrap(name+"["+key+"]", err)
+		}
+	}
+
+	*out = m
+	return nil
+}
+
+// deviceKnownProtocols returns comma-separated list of known
+// protocol names, for diagnostics messages.
+func deviceKnownProtocols() string {
+	protos := make([]string, 0, len(deviceDefaultPaths))
+	for proto := range deviceDefaultPaths {
+		protos = append(protos, proto)
+	}
+
+	sort.Strings(protos)
+	return strings.Join(protos, ", ")
+}
@@ -0,0 +1,20 @@
+# MFP - Miulti-Function Printers and scanners toolkit
+# Printer and scanner modeling.
+#
+# Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+# See LICENSE for license terms and conditions
+#
+# Device configuration definitions
+
+from helpers import collection
+
+# Device configuration types
+class DeviceConfig(collection): pass
+
+# config is the model-settable variable that defines the
+# device configuration: enabled protocols, their base paths,
+# TCP port hints and TLS usage.
+#
+# If None, all protocols with the defined capabilities are
+# enabled at their default paths.
+config = None
@@ -0,0 +1,192 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Device configuration test
+
+package modeling
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/internal/assert"
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/modeling/defaults"
+	"github.com/OpenPrinting/go-mfp/proto/escl"
+)
+
+// TestDeviceConfig tests DeviceConfig loading and Model.Write/Model.Read
+// round trip.
+func TestDeviceConfig(t *testing.T) {
+	const src = `
+escl.scanner = None
+device.config = device.DeviceConfig(
+    Protocols = {"ipp": False, "escl": True},
+    Paths = {"escl": "/scan/eSCL", "wsd": "/wsd/scan"},
+    Ports = {"escl": 8080},
+    TLS = True,
+)
+`
+
+	model, err := NewModel()
+	assert.NoError(err)
+	defer model.Close()
+	testNeedPython(t, model)
+
+	err = model.Read("test", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Model.Read: %s", err)
+	}
+
+	expected := &DeviceConfig{
+		Protocols: map[string]bool{ProtoIPP: false, ProtoESCL: true},
+		Paths: map[string]string{
+			ProtoESCL: "/scan/eSCL",
+			ProtoWSD:  "/wsd/scan",
+		},
+		Ports: map[string]int{ProtoESCL: 8080},
+		TLS:   true,
+	}
+
+	diff := testutils.Diff(expected, model.GetDeviceConfig())
+	if diff != "" {
+		t.Errorf("Model.Read:\n%s", diff)
+	}
+
+	// Check accessors, including defaults
+	if model.ProtocolEnabled(ProtoIPP) {
+		t.Errorf("ProtocolEnabled(%q): expected false", ProtoIPP)
+	}
+
+	if !model.ProtocolEnabled(ProtoWSD) {
+		t.Errorf("ProtocolEnabled(%q): expected true", ProtoWSD)
+	}
+
+	if path := model.ProtocolPath(ProtoIPP); path != "/ipp/print" {
+		t.Errorf("ProtocolPath(%q): %q", ProtoIPP, path)
+	}
+
+	if port := model.ProtocolPort(ProtoESCL); port != 8080 {
+		t.Errorf("ProtocolPort(%q): %d", ProtoESCL, port)
+	}
+
+	// Roll over Model.Write/Model.Read
+	buf := &bytes.Buffer{}
+	err = model.Write(buf)
+	if err != nil {
+		t.Fatalf("Model.Write: %s", err)
+	}
+
+	model2, err := NewModel()
+	assert.NoError(err)
+	defer model2.Close()
+
+	err = model2.Read("test", buf)
+	if err != nil {
+		t.Fatalf("Model.Read: %s", err)
+	}
+
+	diff = testutils.Diff(expected, model2.GetDeviceConfig())
+	if diff != "" {
+		t.Errorf("Model.Write/Model.Read:\n%s", diff)
+	}
+
+	// Unknown protocol must be rejected
+	model3, err := NewModel()
+	assert.NoError(err)
+	defer model3.Close()
+
+	err = model3.Read("test", strings.NewReader(
+		`device.config = device.DeviceConfig(Protocols = {"fax": True})`))
+	if err == nil {
+		t.Errorf("Model.Read: unknown protocol not detected")
+	}
+}
+
+// TestDeviceConfigValidate tests validation of the DeviceConfig
+func TestDeviceConfigValidate(t *testing.T) {
+	model, err := NewModel()
+	assert.NoError(err)
+	defer model.Close()
+
+	caps := defaults.ScannerCapabilities()
+	model.SetESCLScanCaps(escl.FromAbstractScannerCapabilities(
+		escl.DefaultVersion, caps))
+
+	type testData struct {
+		name string        // Test name
+		cfg  *DeviceConfig // Device configuration
+		err  string        // Expected error, "" if none
+	}
+
+	tests := []testData{
+		{
+			name: "no config",
+		},
+		{
+			name: "custom eSCL path",
+			cfg: &DeviceConfig{
+				Paths: map[string]string{ProtoESCL: "/scan"},
+			},
+		},
+		{
+			name: "WSD without scanner caps",
+			cfg: &DeviceConfig{
+				Protocols: map[string]bool{ProtoWSD: true},
+			},
+			err: "error: device: wsd enabled, but model has no wsd capabilities",
+		},
+		{
+			name: "all disabled",
+			cfg: &DeviceConfig{
+				Protocols: map[string]bool{ProtoESCL: false},
+			},
+			err: "error: device: all protocols are disabled",
+		},
+		{
+			name: "invalid path",
+			cfg: &DeviceConfig{
+				Paths: map[string]string{ProtoESCL: "eSCL"},
+			},
+			err: `error: device: escl: invalid path "eSCL"`,
+		},
+		{
+			name: "duplicated path",
+			cfg: &DeviceConfig{
+				Paths: map[string]string{ProtoIPP: "/eSCL"},
+			},
+			err: `error: device: ipp and escl share the same path "/eSCL"`,
+		},
+		{
+			name: "invalid port",
+			cfg: &DeviceConfig{
+				Ports: map[string]int{ProtoESCL: 70000},
+			},
+			err: "error: device: escl: invalid port 70000",
+		},
+	}
+
+	for _, test := range tests {
+		model.SetDeviceConfig(test.cfg)
+		issues := model.Validate()
+
+		var errs []string
+		for _, issue := range issues {
+			if issue.Severity == IssueError {
+				errs = append(errs, issue.String())
+			}
+		}
+
+		switch {
+		case test.err == "" && len(errs) != 0:
+			t.Errorf("%s: unexpected errors: %q", test.name, errs)
+		case test.err != "" && (len(errs) != 1 || errs[0] != test.err):
+			t.Errorf("%s:\nexpected: %q\npresent:  %q",
+				test.name, test.err, errs)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Models comparison
+
+package modeling
+
+import (
+	"bytes"
+
+	diff "github.com/thepudds/patience-diff"
+)
+
+// Diff compares two models and returns their difference in the
+// unified diff format, or "" if models are equal.
+//
+// Models are compared in their canonical form, as written by the
+// [Model.Write], so differences in formatting and comments in the
+// source files are ignored. Scripting hooks are not compared.
+//
+// oldName and newName are used as file names in the diff header.
+func Diff(oldName string, old *Model, newName string, new *Model) (
+	string, error) {
+
+	var oldBuf, newBuf bytes.Buffer
+
+	err := old.Write(&oldBuf)
+	if err == nil {
+		err = new.Write(&newBuf)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	d := diff.Diff(oldName, oldBuf.Bytes(), newName, newBuf.Bytes())
+	return string(d), nil
+}
@@ -6,4 +6,16 @@
 //
 // Package documentation
 
+// Package modeling implements modeling of printers and scanners.
+//
+// Models are represented as Python scripts and the embedded Python
+// interpreter is used to load and save models and to run the model
+// hooks.
+//
+// When built with the nocpython tag (see the cpython package),
+// Model still can be created and holds Go-native data, set either
+// directly or downloaded from the real device. Operations that need
+// Python (i.e., [Model.Write], [Model.Read], [Model.Save] and
+// [Model.Load]) return [cpython.ErrNotAvailable] and the model
+// hooks are not available.
 package modeling
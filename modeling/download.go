@@ -43,7 +43,7 @@ func (model *Model) DownloadIPPPrinterAttrs(ctx context.Context,
 			continue
 		}
 
-		clnt := ipp.NewClient(u, nil)
+		clnt := ipp.NewClient(u, transport.PoolFor(u).Transport())
 		clnt.SetDecoderOptions(
 			&ipp.DecoderOptions{KeepTrying: true},
 		)
@@ -94,7 +94,7 @@ func (model *Model) DownloadESCLScannerCapabilities(ctx context.Context,
 			continue
 		}
 
-		clnt := escl.NewClient(u, nil)
+		clnt := escl.NewClient(u, transport.PoolFor(u).Transport())
 		caps, _, err2 := clnt.GetScannerCapabilities(ctx)
 
 		if err2 != nil {
@@ -135,7 +135,7 @@ func (model *Model) DownloadWSDScannerCapabilities(ctx context.Context,
 			continue
 		}
 
-		clnt := wsscan.NewClient(u, nil)
+		clnt := wsscan.NewClient(u, transport.PoolFor(u).Transport())
 		caps, err2 := clnt.GetScannerElements(
 			ctx,
 			wsscan.ScannerElemDescription,
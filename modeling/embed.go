@@ -33,3 +33,9 @@ var embedPyWSD string
 
 //go:embed usb.py
 var embedPyUSB string
+
+//go:embed device.py
+var embedPyDevice string
+
+//go:embed l10n.py
+var embedPyL10n string
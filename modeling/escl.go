@@ -162,7 +162,7 @@ func (model *Model) NewESCLServer(
 	options := escl.AbstractServerOptions{
 		Version:  caps.Version,
 		Scanner:  scanner,
-		BasePath: "/eSCL",
+		BasePath: model.ProtocolPath(ProtoESCL),
 		Hooks:    hooks,
 	}
 
@@ -180,6 +180,20 @@ func (model *Model) esclOnScannerCapabilitiesResponse(
 	caps2 := model.GetESCLScanCaps()
 	if caps2 == nil {
 		query.Reject(http.StatusServiceUnavailable, nil)
+		return nil
+	}
+
+	// Choose AdminURI according to the Accept-Language
+	if l10n := model.l10n; l10n != nil {
+		langs := l10nAcceptLanguage(
+			query.RequestHeader().Get("Accept-Language"))
+		_, uri, ok := l10n.AdminURI.Lookup(
+			l10n.DefaultLanguage(), langs...)
+		if ok {
+			caps3 := *caps2
+			caps3.AdminURI = optional.New(uri)
+			caps2 = &caps3
+		}
 	}
 
 	return caps2
@@ -227,6 +241,8 @@ func (model *Model) esclOnScanJobsRequest(
 
 	// Call the hook
 	err = model.esclOnScanJobsRequestScriptlet.Call(q, rq).Err()
+	model.hookDone()
+
 	if err != nil {
 		query.Reject(http.StatusServiceUnavailable, err)
 		return nil
@@ -307,6 +323,8 @@ func (model *Model) esclOnNextDocumentResponse(
 
 	// Call the hook
 	err = model.esclOnNextDocumentResponseScriptlet.Call(q, flt).Err()
+	model.hookDone()
+
 	if err != nil {
 		query.Reject(http.StatusServiceUnavailable, err)
 		return nil
@@ -0,0 +1,146 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Model instantiation (identity regeneration)
+
+package modeling
+
+import (
+	"math/rand"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+	"github.com/OpenPrinting/goipp"
+)
+
+// Instantiate regenerates the device identity (UUID and serial
+// number) of the Model.
+//
+// It allows to make multiple distinct simulated devices out of
+// the single captured model. The new identity is derived from the
+// seed, so the same seed always produces the same identity.
+//
+// The following is updated:
+//   - IPP: printer-uuid (added, if missed) and the serial number
+//     in the printer-device-id (SN, SER, SERN or SERIALNUMBER key)
+//   - eSCL: UUID (added, if missed) and SerialNumber (if present)
+//   - USB: iSerialNumber (if present)
+//
+// The same UUID and serial number are used for all protocols, so
+// the instantiated model still looks as a single device.
+func (model *Model) Instantiate(seed int64) error {
+	rnd := rand.New(rand.NewSource(seed))
+
+	u, err := uuid.RandomFrom(rnd)
+	if err != nil {
+		return err
+	}
+
+	serial := instantiateSerial(rnd)
+
+	// Update IPP printer attributes
+	if model.ippPrinterAttrs != nil {
+		attrs := model.ippPrinterAttrs.RawAttrs().All().Clone()
+		attrs = instantiateIPPAttrs(attrs, u, serial)
+
+		opt := &ipp.DecoderOptions{
+			KeepTrying: true,
+		}
+
+		pa, err := ipp.DecodePrinterAttributes(attrs, opt)
+		if err != nil {
+			return err
+		}
+
+		model.ippPrinterAttrs = pa
+	}
+
+	// Update eSCL scanner capabilities
+	if model.esclScanCaps != nil {
+		caps := *model.esclScanCaps
+		caps.UUID = optional.New(u)
+		if caps.SerialNumber != nil {
+			caps.SerialNumber = optional.New(serial)
+		}
+
+		model.esclScanCaps = &caps
+	}
+
+	// Update USB device descriptor
+	if model.usbDevice != nil && model.usbDevice.ISerialNumber != "" {
+		desc := *model.usbDevice
+		desc.ISerialNumber = serial
+		model.usbDevice = &desc
+	}
+
+	return nil
+}
+
+// instantiateSerial generates the serial number.
+func instantiateSerial(rnd *rand.Rand) string {
+	const chars = "0123456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+	buf := make([]byte, 12)
+	for i := range buf {
+		buf[i] = chars[rnd.Intn(len(chars))]
+	}
+
+	return string(buf)
+}
+
+// instantiateIPPAttrs replaces identity in the IPP printer attributes.
+func instantiateIPPAttrs(attrs goipp.Attributes,
+	u uuid.UUID, serial string) goipp.Attributes {
+
+	uuidAttr := goipp.MakeAttribute("printer-uuid",
+		goipp.TagURI, goipp.String(u.URN()))
+
+	found := false
+	for i := range attrs {
+		attr := &attrs[i]
+		switch attr.Name {
+		case "printer-uuid":
+			*attr = uuidAttr
+			found = true
+
+		case "printer-device-id":
+			for j, v := range attr.Values {
+				if s, ok := v.V.(goipp.String); ok {
+					s = goipp.String(instantiateDeviceID(
+						string(s), serial))
+					attr.Values[j].V = s
+				}
+			}
+		}
+	}
+
+	if !found {
+		attrs = append(attrs, uuidAttr)
+	}
+
+	return attrs
+}
+
+// instantiateDeviceID replaces serial number in the IEEE 1284
+// device ID string.
+func instantiateDeviceID(id, serial string) string {
+	fields := strings.Split(id, ";")
+	for i, f := range fields {
+		key, _, ok := strings.Cut(f, ":")
+		if !ok {
+			continue
+		}
+
+		switch strings.ToUpper(strings.TrimSpace(key)) {
+		case "SN", "SER", "SERN", "SERIALNUMBER":
+			fields[i] = key + ":" + serial
+		}
+	}
+
+	return strings.Join(fields, ";")
+}
@@ -0,0 +1,45 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Model instantiation test
+
+package modeling
+
+import "testing"
+
+// TestInstantiateDeviceID tests instantiateDeviceID
+func TestInstantiateDeviceID(t *testing.T) {
+	type testData struct {
+		id, expected string
+	}
+
+	tests := []testData{
+		{
+			id:       "MFG:Kyocera;MDL:ECOSYS M2040dn;SER:VCF9192281;",
+			expected: "MFG:Kyocera;MDL:ECOSYS M2040dn;SER:NEWSERIAL;",
+		},
+		{
+			id:       "MFG:HP;SN:CN123;CMD:PCL;",
+			expected: "MFG:HP;SN:NEWSERIAL;CMD:PCL;",
+		},
+		{
+			id:       "MFG:Xerox;SerialNumber:X1",
+			expected: "MFG:Xerox;SerialNumber:NEWSERIAL",
+		},
+		{
+			id:       "MFG:Canon;MDL:G3010;",
+			expected: "MFG:Canon;MDL:G3010;",
+		},
+	}
+
+	for _, test := range tests {
+		id := instantiateDeviceID(test.id, "NEWSERIAL")
+		if id != test.expected {
+			t.Errorf("%q:\nexpected: %q\npresent:  %q",
+				test.id, test.expected, id)
+		}
+	}
+}
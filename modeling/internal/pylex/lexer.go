@@ -0,0 +1,320 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Lexer for the Python subset, used by model files
+
+// Package pylex implements lexer for the Python subset, used
+// by the MFP model files. It is shared by readers, that parse
+// model files without the embedded Python interpreter.
+package pylex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenType is the type of the lexical token
+type TokenType int
+
+// Token types:
+const (
+	EOF     TokenType = iota // End of file
+	Newline                  // End of the logical line
+	Ident                    // Identifier, including True/False/None
+	Number                   // Integer number
+	String                   // String literal, already unquoted
+	Punct                    // Punctuation or operator
+)
+
+// Token is the lexical token
+type Token struct {
+	Type TokenType // Token type
+	Text string    // Token text
+	Line int       // Line number, 1-based
+	Col  int       // Column number, 0-based
+}
+
+// String returns the token representation for the diagnostics
+func (tok Token) String() string {
+	switch tok.Type {
+	case EOF:
+		return "end of file"
+	case Newline:
+		return "end of line"
+	case String:
+		return strconv.Quote(tok.Text)
+	}
+	return fmt.Sprintf("%q", tok.Text)
+}
+
+// Lexer splits model file into tokens.
+//
+// Inside the brackets, newlines are ignored, as Python does.
+// Indentation is not tracked; instead, each token reports its
+// column, so the parser can recognize top-level statements.
+type Lexer struct {
+	src   string // Source text
+	pos   int    // Current position
+	line  int    // Current line, 1-based
+	bol   int    // Position of the beginning of the current line
+	depth int    // Brackets nesting depth
+}
+
+// NewLexer creates a new Lexer
+func NewLexer(src string) *Lexer {
+	return &Lexer{src: src, line: 1}
+}
+
+// Next returns the next token
+func (lx *Lexer) Next() (tok Token, err error) {
+	newline := false
+
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+
+		switch {
+		case c == '\n':
+			lx.pos++
+			lx.line++
+			lx.bol = lx.pos
+			if lx.depth == 0 {
+				newline = true
+			}
+
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			lx.pos++
+
+		case c == '#':
+			for lx.pos < len(lx.src) && lx.src[lx.pos] != '\n' {
+				lx.pos++
+			}
+
+		case c == '\\' && strings.HasPrefix(lx.src[lx.pos+1:], "\n"):
+			// Explicit line continuation
+			lx.pos += 2
+			lx.line++
+			lx.bol = lx.pos
+
+		default:
+			if newline {
+				return lx.token(Newline, ""), nil
+			}
+			return lx.scan()
+		}
+	}
+
+	if newline {
+		return lx.token(Newline, ""), nil
+	}
+
+	return lx.token(EOF, ""), nil
+}
+
+// scan scans the non-space token at the current position
+func (lx *Lexer) scan() (tok Token, err error) {
+	start := lx.pos
+	tok = lx.token(Punct, "")
+	c, sz := utf8.DecodeRuneInString(lx.src[lx.pos:])
+
+	switch {
+	case lx.stringPrefix() >= 0:
+		tok.Type = String
+		tok.Text, err = lx.scanString()
+
+	case c == '_' || unicode.IsLetter(c):
+		for lx.pos < len(lx.src) {
+			c, sz = utf8.DecodeRuneInString(lx.src[lx.pos:])
+			if c != '_' && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+				break
+			}
+			lx.pos += sz
+		}
+		tok.Type = Ident
+		tok.Text = lx.src[start:lx.pos]
+
+	case c >= '0' && c <= '9':
+		for lx.pos < len(lx.src) {
+			c := lx.src[lx.pos]
+			if c != '_' && !(c >= '0' && c <= '9') &&
+				!(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') {
+				break
+			}
+			lx.pos++
+		}
+		tok.Type = Number
+		tok.Text = lx.src[start:lx.pos]
+
+	case strings.HasPrefix(lx.src[lx.pos:], "**"):
+		lx.pos += 2
+		tok.Text = "**"
+
+	default:
+		switch c {
+		case '(', '[', '{':
+			lx.depth++
+		case ')', ']', '}':
+			if lx.depth > 0 {
+				lx.depth--
+			}
+		}
+
+		lx.pos += sz
+		tok.Text = lx.src[start:lx.pos]
+	}
+
+	return
+}
+
+// stringPrefix checks if string literal starts at the current
+// position. If so, it returns length of the string prefix
+// (like r or b), otherwise -1.
+func (lx *Lexer) stringPrefix() int {
+	for i := 0; i < 3 && lx.pos+i < len(lx.src); i++ {
+		switch lx.src[lx.pos+i] {
+		case '\'', '"':
+			return i
+		case 'r', 'R', 'b', 'B', 'u', 'U':
+		default:
+			return -1
+		}
+	}
+	return -1
+}
+
+// scanString scans the string literal
+func (lx *Lexer) scanString() (string, error) {
+	line := lx.line
+	prefix := strings.ToLower(lx.src[lx.pos : lx.pos+lx.stringPrefix()])
+	raw := strings.ContainsRune(prefix, 'r')
+	bytes := strings.ContainsRune(prefix, 'b')
+	lx.pos += len(prefix)
+
+	quote := lx.src[lx.pos : lx.pos+1]
+	if strings.HasPrefix(lx.src[lx.pos:], quote+quote+quote) {
+		quote += quote + quote
+	}
+	lx.pos += len(quote)
+
+	var buf strings.Builder
+	for {
+		if lx.pos >= len(lx.src) {
+			return "", fmt.Errorf("%d: unterminated string", line)
+		}
+
+		if strings.HasPrefix(lx.src[lx.pos:], quote) {
+			lx.pos += len(quote)
+			return buf.String(), nil
+		}
+
+		c := lx.src[lx.pos]
+		switch {
+		case c == '\n' && len(quote) == 1:
+			return "", fmt.Errorf("%d: unterminated string", line)
+
+		case c == '\n':
+			buf.WriteByte(c)
+			lx.pos++
+			lx.line++
+			lx.bol = lx.pos
+
+		case c == '\\' && raw:
+			buf.WriteString(lx.src[lx.pos : lx.pos+2])
+			lx.pos += 2
+
+		case c == '\\':
+			err := lx.scanEscape(&buf, bytes)
+			if err != nil {
+				return "", fmt.Errorf("%d: %w", lx.line, err)
+			}
+
+		default:
+			buf.WriteByte(c)
+			lx.pos++
+		}
+	}
+}
+
+// scanEscape scans the escape sequence within the string literal.
+//
+// In the str literals, \xHH, \uHHHH and octal escapes define the
+// Unicode code point, which is written as UTF-8. In the bytes
+// literals, \xHH and octal escapes define the byte.
+func (lx *Lexer) scanEscape(buf *strings.Builder, bytes bool) error {
+	lx.pos++
+	if lx.pos >= len(lx.src) {
+		return fmt.Errorf("unterminated string")
+	}
+
+	c := lx.src[lx.pos]
+	lx.pos++
+
+	simple := map[byte]byte{
+		'\\': '\\', '\'': '\'', '"': '"',
+		'a': '\a', 'b': '\b', 'f': '\f',
+		'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+	}
+
+	if s, ok := simple[c]; ok {
+		buf.WriteByte(s)
+		return nil
+	}
+
+	var digits, base int
+	switch {
+	case c == '\n':
+		lx.line++
+		lx.bol = lx.pos
+		return nil
+	case c == 'x':
+		digits, base = 2, 16
+	case c == 'u' && !bytes:
+		digits, base = 4, 16
+	case c == 'U' && !bytes:
+		digits, base = 8, 16
+	case c >= '0' && c <= '7':
+		lx.pos--
+		digits, base = 3, 8
+		for i := 0; i < 3; i++ {
+			if lx.pos+i >= len(lx.src) ||
+				lx.src[lx.pos+i] < '0' || lx.src[lx.pos+i] > '7' {
+				digits = i
+				break
+			}
+		}
+	default:
+		// Python keeps unknown escapes as is
+		buf.WriteByte('\\')
+		buf.WriteByte(c)
+		return nil
+	}
+
+	if lx.pos+digits > len(lx.src) {
+		return fmt.Errorf("truncated \\%c escape", c)
+	}
+
+	v, err := strconv.ParseUint(lx.src[lx.pos:lx.pos+digits], base, 32)
+	if err != nil {
+		return fmt.Errorf("invalid \\%c escape", c)
+	}
+
+	lx.pos += digits
+
+	if bytes {
+		buf.WriteByte(byte(v))
+	} else {
+		buf.WriteRune(rune(v))
+	}
+
+	return nil
+}
+
+// token creates a new token at the current position
+func (lx *Lexer) token(typ TokenType, text string) Token {
+	return Token{Type: typ, Text: text, Line: lx.line, Col: lx.pos - lx.bol}
+}
@@ -37,6 +37,13 @@ func (model *Model) NewIPPServer() *ipp.Printer {
 	options := ipp.PrinterOptions{
 		UseRawPrinterAttributes: true,
 	}
+
+	if model.l10n != nil {
+		l := &l10nIPP{l10n: model.l10n}
+		options.Hooks.OnIPPRequest = l.OnIPPRequest
+		options.Hooks.OnIPPResponse = l.OnIPPResponse
+	}
+
 	return ipp.NewPrinter(attrs, options)
 }
 
@@ -0,0 +1,545 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// IPP printer attributes reader
+
+// Package ippread reads IPP printer attributes from the MFP model
+// files without the embedded Python interpreter.
+//
+// The model file is the Python script, and the full-featured
+// reader, [modeling.Model.Read], executes it with the cpython.
+// This package understands only the canonical literal form of
+// the ipp.printer assignment, as written by [modeling.Model.Write]:
+//
+//	ipp.printer = ipp.COLLECTION(
+//	    charset_configured = ipp.CHARSET('utf-8'),
+//	    media_supported = [
+//	        ipp.KEYWORD('iso_a4_210x297mm'),
+//	        ipp.KEYWORD('na_letter_8.5x11in'),
+//	    ],
+//	    **ipp.ATTR('print_wfds', ipp.TEXT('T')),
+//	)
+//
+// The rest of the model file is skipped. It is enough for tools,
+// that only need to inspect the printer capabilities, and it
+// avoids dependency on the cpython.
+package ippread
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/modeling/internal/pylex"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/goipp"
+)
+
+// Load loads IPP printer attributes from the model file.
+//
+// It returns nil, nil, if model doesn't contain IPP printer
+// attributes.
+func Load(file string) (*ipp.PrinterAttributes, error) {
+	fp, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fp.Close()
+
+	return Read(file, fp)
+}
+
+// Read reads IPP printer attributes from the [io.Reader].
+// The filename parameter required for the diagnostics messages.
+//
+// It returns nil, nil, if model doesn't contain IPP printer
+// attributes.
+func Read(filename string, r io.Reader) (*ipp.PrinterAttributes, error) {
+	attrs, err := ReadAttrs(filename, r)
+	if attrs == nil || err != nil {
+		return nil, err
+	}
+
+	opt := &ipp.DecoderOptions{
+		KeepTrying: true,
+	}
+
+	return ipp.DecodePrinterAttributes(attrs, opt)
+}
+
+// ReadAttrs reads raw IPP printer attributes from the [io.Reader].
+// The filename parameter required for the diagnostics messages.
+//
+// It returns nil, nil, if model doesn't contain IPP printer
+// attributes.
+func ReadAttrs(filename string, r io.Reader) (goipp.Attributes, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{lx: pylex.NewLexer(string(data))}
+	attrs, err := p.parse()
+	if err != nil {
+		err = fmt.Errorf("%s:%w", filename, err)
+	}
+
+	return attrs, err
+}
+
+// parser parses the model file
+type parser struct {
+	lx  *pylex.Lexer // Underlying lexer
+	tok pylex.Token  // Current token
+}
+
+// parse parses the model file and returns IPP printer attributes
+func (p *parser) parse() (attrs goipp.Attributes, err error) {
+	err = p.advance()
+
+	for err == nil && p.tok.Type != pylex.EOF {
+		switch {
+		case p.tok.Type == pylex.Newline:
+			err = p.advance()
+
+		case p.tok.Col == 0 && p.tok.Type == pylex.Ident:
+			attrs, err = p.parseStatement(attrs)
+
+		default:
+			err = p.skipStatement()
+		}
+	}
+
+	return
+}
+
+// parseStatement parses the top-level statement. If statement
+// assigns ipp.printer (or legacy ipp.attrs), its value is returned.
+// Otherwise, the statement is skipped and attrs returned unchanged.
+func (p *parser) parseStatement(attrs goipp.Attributes) (
+	goipp.Attributes, error) {
+
+	tok := p.tok
+	name, err := p.parseDottedName()
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "ipp.printer" && name != "ipp.attrs" {
+		return attrs, p.skipStatement()
+	}
+
+	if p.tok.Text != "=" {
+		return nil, fmt.Errorf("%d: %s: only literal assignment "+
+			"is supported", tok.Line, name)
+	}
+
+	err = p.advance()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.Type == pylex.Ident && p.tok.Text == "None" {
+		return nil, p.advance()
+	}
+
+	tok = p.tok
+	name, err = p.parseDottedName()
+	switch {
+	case err != nil:
+		return nil, err
+	case name != "ipp.COLLECTION":
+		return nil, fmt.Errorf("%d: %s: ipp.COLLECTION expected",
+			tok.Line, tok)
+	}
+
+	attrs, err = p.parseCollection()
+	if err == nil && p.tok.Type != pylex.Newline && p.tok.Type != pylex.EOF {
+		err = p.unexpected()
+	}
+
+	return attrs, err
+}
+
+// parseCollection parses the ipp.COLLECTION arguments.
+// The ipp.COLLECTION name is already consumed.
+func (p *parser) parseCollection() (attrs goipp.Attributes, err error) {
+	err = p.expect("(")
+	if err != nil {
+		return
+	}
+
+	attrs = goipp.Attributes{}
+	for err == nil && p.tok.Text != ")" {
+		var attr goipp.Attribute
+
+		switch {
+		case p.tok.Text == "**":
+			attr, err = p.parseRawAttr()
+
+		case p.tok.Type == pylex.Ident:
+			attr.Name = strings.ReplaceAll(p.tok.Text, "_", "-")
+			err = p.advance()
+			if err == nil {
+				err = p.expect("=")
+			}
+			if err == nil {
+				attr.Values, err = p.parseValues(attr.Name)
+			}
+
+		default:
+			err = p.unexpected()
+		}
+
+		if err == nil {
+			attrs.Add(attr)
+			err = p.expectListSeparator(")")
+		}
+	}
+
+	if err == nil {
+		err = p.expect(")")
+	}
+
+	return
+}
+
+// parseRawAttr parses the **ipp.ATTR('name', value) argument of
+// the ipp.COLLECTION
+func (p *parser) parseRawAttr() (attr goipp.Attribute, err error) {
+	err = p.advance()
+	if err != nil {
+		return
+	}
+
+	tok := p.tok
+	name, err := p.parseDottedName()
+	switch {
+	case err != nil:
+		return
+	case name != "ipp.ATTR":
+		err = fmt.Errorf("%d: %s: ipp.ATTR expected", tok.Line, tok)
+		return
+	}
+
+	err = p.expect("(")
+	if err == nil {
+		attr.Name, err = p.parseString()
+	}
+	if err == nil {
+		err = p.expect(",")
+	}
+	if err == nil {
+		attr.Values, err = p.parseValues(attr.Name)
+	}
+	if err == nil {
+		err = p.expectListSeparator(")")
+	}
+	if err == nil {
+		err = p.expect(")")
+	}
+
+	return
+}
+
+// parseValues parses the attribute values: either single value
+// or list of values.
+func (p *parser) parseValues(attrname string) (goipp.Values, error) {
+	if p.tok.Text != "[" {
+		tag, val, err := p.parseValue(attrname)
+		if err != nil {
+			return nil, err
+		}
+		return goipp.Values{{T: tag, V: val}}, nil
+	}
+
+	err := p.advance()
+	vals := goipp.Values{}
+
+	for err == nil && p.tok.Text != "]" {
+		var tag goipp.Tag
+		var val goipp.Value
+
+		tag, val, err = p.parseValue(attrname)
+		if err == nil {
+			vals.Add(tag, val)
+			err = p.expectListSeparator("]")
+		}
+	}
+
+	if err == nil {
+		err = p.expect("]")
+	}
+
+	return vals, err
+}
+
+// parseValue parses a single IPP value, like ipp.KEYWORD('auto')
+func (p *parser) parseValue(attrname string) (
+	tag goipp.Tag, val goipp.Value, err error) {
+
+	tok := p.tok
+	name, err := p.parseDottedName()
+	if err != nil {
+		return
+	}
+
+	// ipp.COLLECTION(...)
+	if name == "ipp.COLLECTION" {
+		var attrs goipp.Attributes
+		attrs, err = p.parseCollection()
+		return goipp.TagBeginCollection, goipp.Collection(attrs), err
+	}
+
+	// ipp.OP.NAME
+	if opname, ok := strings.CutPrefix(name, "ipp.OP."); ok {
+		op, ok := ippOpByName[opname]
+		if !ok {
+			err = fmt.Errorf("%d: %s: unknown operation",
+				tok.Line, name)
+		}
+		return goipp.TagEnum, goipp.Integer(op), err
+	}
+
+	// ipp.TAG(args...)
+	tag = ippTagByName[name]
+	if tag == goipp.TagZero {
+		err = fmt.Errorf("%d: %s: unknown IPP value type", tok.Line, name)
+		return
+	}
+
+	err = p.expect("(")
+	if err != nil {
+		return
+	}
+
+	switch tag.Type() {
+	case goipp.TypeVoid:
+		val = goipp.Void{}
+
+	case goipp.TypeInteger:
+		var v int64
+		v, err = p.parseInt()
+		val = goipp.Integer(v)
+
+	case goipp.TypeBoolean:
+		var v bool
+		v, err = p.parseBool()
+		val = goipp.Boolean(v)
+
+	case goipp.TypeString, goipp.TypeBinary:
+		var v string
+		v, err = p.parseString()
+		val = goipp.String(v)
+
+	case goipp.TypeDateTime:
+		var v string
+		var t time.Time
+		v, err = p.parseString()
+		if err == nil {
+			t, err = time.Parse(time.RFC3339, v)
+		}
+		val = goipp.Time{Time: t}
+
+	case goipp.TypeResolution:
+		val, err = p.parseResolution()
+
+	case goipp.TypeRange:
+		var lower, upper int64
+		lower, err = p.parseInt()
+		if err == nil {
+			err = p.expect(",")
+		}
+		if err == nil {
+			upper, err = p.parseInt()
+		}
+		val = goipp.Range{Lower: int(lower), Upper: int(upper)}
+
+	case goipp.TypeTextWithLang:
+		var text, lang string
+		text, err = p.parseString()
+		if err == nil {
+			err = p.expect(",")
+		}
+		if err == nil {
+			lang, err = p.parseString()
+		}
+		val = goipp.TextWithLang{Text: text, Lang: lang}
+
+	default:
+		err = fmt.Errorf("%d: %s: unsupported IPP value type",
+			tok.Line, name)
+	}
+
+	if err == nil {
+		err = p.expectListSeparator(")")
+	}
+	if err == nil {
+		err = p.expect(")")
+	}
+
+	return
+}
+
+// parseResolution parses arguments of the ipp.RESOLUTION
+func (p *parser) parseResolution() (res goipp.Resolution, err error) {
+	var x, y int64
+	var units string
+
+	x, err = p.parseInt()
+	if err == nil {
+		err = p.expect(",")
+	}
+	if err == nil {
+		y, err = p.parseInt()
+	}
+	if err == nil {
+		err = p.expect(",")
+	}
+
+	tok := p.tok
+	if err == nil {
+		units, err = p.parseString()
+	}
+
+	if err != nil {
+		return
+	}
+
+	res = goipp.Resolution{Xres: int(x), Yres: int(y)}
+	switch units {
+	case "dpi":
+		res.Units = goipp.UnitsDpi
+	case "dpcm":
+		res.Units = goipp.UnitsDpcm
+	default:
+		err = fmt.Errorf("%d: %s: invalid resolution units",
+			tok.Line, tok)
+	}
+
+	return
+}
+
+// parseDottedName parses the dotted name, like ipp.COLLECTION
+func (p *parser) parseDottedName() (string, error) {
+	if p.tok.Type != pylex.Ident {
+		return "", p.unexpected()
+	}
+
+	name := p.tok.Text
+	err := p.advance()
+
+	for err == nil && p.tok.Text == "." {
+		err = p.advance()
+		if err == nil && p.tok.Type != pylex.Ident {
+			err = p.unexpected()
+		}
+		if err == nil {
+			name += "." + p.tok.Text
+			err = p.advance()
+		}
+	}
+
+	return name, err
+}
+
+// parseInt parses the integer number, possibly negative
+func (p *parser) parseInt() (int64, error) {
+	neg := p.tok.Text == "-"
+	if neg {
+		if err := p.advance(); err != nil {
+			return 0, err
+		}
+	}
+
+	if p.tok.Type != pylex.Number {
+		return 0, p.unexpected()
+	}
+
+	v, err := strconv.ParseInt(p.tok.Text, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%d: %s: invalid number",
+			p.tok.Line, p.tok)
+	}
+
+	if neg {
+		v = -v
+	}
+
+	return v, p.advance()
+}
+
+// parseBool parses the True or False
+func (p *parser) parseBool() (bool, error) {
+	var v bool
+
+	switch {
+	case p.tok.Type == pylex.Ident && p.tok.Text == "True":
+		v = true
+	case p.tok.Type == pylex.Ident && p.tok.Text == "False":
+	default:
+		return false, p.unexpected()
+	}
+
+	return v, p.advance()
+}
+
+// parseString parses the string. Adjacent string literals are
+// concatenated, as Python does.
+func (p *parser) parseString() (string, error) {
+	if p.tok.Type != pylex.String {
+		return "", p.unexpected()
+	}
+
+	var s string
+	var err error
+	for err == nil && p.tok.Type == pylex.String {
+		s += p.tok.Text
+		err = p.advance()
+	}
+
+	return s, err
+}
+
+// skipStatement skips the rest of the current statement
+func (p *parser) skipStatement() (err error) {
+	for err == nil && p.tok.Type != pylex.Newline && p.tok.Type != pylex.EOF {
+		err = p.advance()
+	}
+	return
+}
+
+// expect consumes the expected punctuation token
+func (p *parser) expect(text string) error {
+	if p.tok.Type != pylex.Punct || p.tok.Text != text {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+// expectListSeparator consumes the comma between list items.
+// The comma may be omitted before the closing bracket.
+func (p *parser) expectListSeparator(closing string) error {
+	if p.tok.Text == closing {
+		return nil
+	}
+	return p.expect(",")
+}
+
+// advance reads the next token
+func (p *parser) advance() (err error) {
+	p.tok, err = p.lx.Next()
+	return
+}
+
+// unexpected returns the "unexpected token" error
+func (p *parser) unexpected() error {
+	return fmt.Errorf("%d: unexpected %s", p.tok.Line, p.tok)
+}
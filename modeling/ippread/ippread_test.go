@@ -0,0 +1,251 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// IPP printer attributes reader test
+
+package ippread
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestReadAttrs tests ReadAttrs
+func TestReadAttrs(t *testing.T) {
+	const src = `# Model file
+def hook(rq):
+    ipp.printer = None
+
+escl.scanner = escl.ScannerCapabilities(
+    Version = '2.0',
+)
+
+ipp.printer = ipp.COLLECTION(
+    operations_supported = [ipp.OP.PRINT_JOB, ipp.OP.CUPS_GET_DEFAULT],
+    copies_supported = ipp.RANGE(1, 999),
+    finishings_default = ipp.ENUM(3),
+    color_supported = ipp.BOOLEAN(False),
+    printer_resolution_supported = [
+        ipp.RESOLUTION(300, 300, 'dpi'),
+        ipp.RESOLUTION(118, 118, 'dpcm'),
+    ],
+    printer_info = ipp.TEXTLANG('Office "printer"', 'en'),
+    printer_firmware_version = ipp.STRING('\x02\x00'),
+    printer_location = ipp.TEXT('Room ' "42"),
+    printer_current_time = ipp.DATE('2024-01-02T03:04:05Z'),
+    printer_alert = ipp.NOVALUE(),
+    media_col_ready = ipp.COLLECTION(
+        media_size = ipp.COLLECTION(
+            x_dimension = ipp.INTEGER(21000),
+            y_dimension = ipp.INTEGER(-1),
+        ),
+    ),
+    **ipp.ATTR('print_wfds', ipp.TEXT('T')),
+)
+`
+
+	attrs, err := ReadAttrs("test.py", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("ReadAttrs: %s", err)
+	}
+
+	var expected goipp.Attributes
+	expected.Add(goipp.MakeAttr("operations-supported", goipp.TagEnum,
+		goipp.Integer(goipp.OpPrintJob),
+		goipp.Integer(goipp.OpCupsGetDefault)))
+	expected.Add(goipp.MakeAttribute("copies-supported",
+		goipp.TagRange, goipp.Range{Lower: 1, Upper: 999}))
+	expected.Add(goipp.MakeAttribute("finishings-default",
+		goipp.TagEnum, goipp.Integer(3)))
+	expected.Add(goipp.MakeAttribute("color-supported",
+		goipp.TagBoolean, goipp.Boolean(false)))
+	expected.Add(goipp.MakeAttr("printer-resolution-supported",
+		goipp.TagResolution,
+		goipp.Resolution{Xres: 300, Yres: 300, Units: goipp.UnitsDpi},
+		goipp.Resolution{Xres: 118, Yres: 118, Units: goipp.UnitsDpcm}))
+	expected.Add(goipp.MakeAttribute("printer-info", goipp.TagTextLang,
+		goipp.TextWithLang{Text: `Office "printer"`, Lang: "en"}))
+	expected.Add(goipp.MakeAttribute("printer-firmware-version",
+		goipp.TagString, goipp.String("\x02\x00")))
+	expected.Add(goipp.MakeAttribute("printer-location",
+		goipp.TagText, goipp.String("Room 42")))
+	expected.Add(goipp.MakeAttribute("printer-current-time",
+		goipp.TagDateTime, goipp.Time{Time: time.Date(2024, 1, 2,
+			3, 4, 5, 0, time.UTC)}))
+	expected.Add(goipp.MakeAttribute("printer-alert",
+		goipp.TagNoValue, goipp.Void{}))
+	expected.Add(goipp.MakeAttribute("media-col-ready",
+		goipp.TagBeginCollection, goipp.Collection{
+			goipp.MakeAttribute("media-size",
+				goipp.TagBeginCollection, goipp.Collection{
+					goipp.MakeAttribute("x-dimension",
+						goipp.TagInteger, goipp.Integer(21000)),
+					goipp.MakeAttribute("y-dimension",
+						goipp.TagInteger, goipp.Integer(-1)),
+				}),
+		}))
+	expected.Add(goipp.MakeAttribute("print_wfds",
+		goipp.TagText, goipp.String("T")))
+
+	if !expected.Equal(attrs) {
+		t.Errorf("ReadAttrs:\nexpected: %v\npresent:  %v",
+			expected, attrs)
+	}
+}
+
+// TestReadAttrsMissed tests ReadAttrs on model without IPP part
+func TestReadAttrsMissed(t *testing.T) {
+	srcs := []string{
+		"",
+		"usb.device = usb.DeviceDescriptor(\n    BCDUSB = 0x0200,\n)\n",
+		"ipp.printer = None\n",
+	}
+
+	for _, src := range srcs {
+		attrs, err := ReadAttrs("test.py", strings.NewReader(src))
+		if attrs != nil || err != nil {
+			t.Errorf("ReadAttrs(%q): expected nil, nil, present %v, %v",
+				src, attrs, err)
+		}
+	}
+}
+
+// TestReadAttrsErrors tests ReadAttrs error handling
+func TestReadAttrsErrors(t *testing.T) {
+	type testData struct {
+		src string
+		err string
+	}
+
+	tests := []testData{
+		{
+			src: "ipp.printer = make_printer()\n",
+			err: "test.py:1: \"make_printer\": ipp.COLLECTION expected",
+		},
+		{
+			src: "ipp.printer['copies-default'] = ipp.INTEGER(1)\n",
+			err: "test.py:1: ipp.printer: only literal assignment " +
+				"is supported",
+		},
+		{
+			src: "ipp.printer = ipp.COLLECTION(\n" +
+				"    copies_default = ipp.FLOAT(1.0),\n)\n",
+			err: "test.py:2: ipp.FLOAT: unknown IPP value type",
+		},
+		{
+			src: "ipp.printer = ipp.COLLECTION(\n" +
+				"    operations_supported = ipp.OP.FLY,\n)\n",
+			err: "test.py:2: ipp.OP.FLY: unknown operation",
+		},
+		{
+			src: "ipp.printer = ipp.COLLECTION(\n" +
+				"    printer_name = ipp.NAME('unterminated),\n)\n",
+			err: "test.py:2: unterminated string",
+		},
+		{
+			src: "ipp.printer = ipp.COLLECTION(\n" +
+				"    printer_resolution_default = " +
+				"ipp.RESOLUTION(300, 300, 'dpm'),\n)\n",
+			err: "test.py:2: \"dpm\": invalid resolution units",
+		},
+		{
+			src: "ipp.printer = ipp.COLLECTION(\n" +
+				"    copies_default = ipp.INTEGER(1)\n" +
+				"    copies_supported = ipp.RANGE(1, 999),\n)\n",
+			err: "test.py:3: unexpected \"copies_supported\"",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := ReadAttrs("test.py", strings.NewReader(test.src))
+		errstr := ""
+		if err != nil {
+			errstr = err.Error()
+		}
+
+		if errstr != test.err {
+			t.Errorf("ReadAttrs(%q):\nerror expected: %s\nerror present:  %s",
+				test.src, test.err, errstr)
+		}
+	}
+}
+
+// TestLoadExamples tests Load on example models
+func TestLoadExamples(t *testing.T) {
+	files, err := filepath.Glob("../examples/*.py")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("example models not found: %v", err)
+	}
+
+	for _, file := range files {
+		prn, err := Load(file)
+		if err != nil {
+			t.Errorf("Load: %s", err)
+			continue
+		}
+
+		if prn == nil || prn.PrinterMakeAndModel == nil {
+			t.Errorf("%s: printer-make-and-model missed", file)
+		}
+	}
+}
+
+// TestOpNames verifies that all operations, defined in the
+// modeling/ipp.py, are known.
+func TestOpNames(t *testing.T) {
+	fp, err := os.Open("../ipp.py")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	defer fp.Close()
+
+	re := regexp.MustCompile(`^    ([A-Z_]+) = (0x[0-9a-fA-F]+)$`)
+	inOP := false
+	count := 0
+
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "class OP("):
+			inOP = true
+			continue
+		case strings.HasPrefix(line, "class "):
+			inOP = false
+		}
+
+		m := re.FindStringSubmatch(line)
+		if !inOP || m == nil {
+			continue
+		}
+
+		code, _ := strconv.ParseInt(m[2], 0, 32)
+		op, found := ippOpByName[m[1]]
+		switch {
+		case !found:
+			t.Errorf("ipp.OP.%s: unknown", m[1])
+		case int64(op) != code:
+			t.Errorf("ipp.OP.%s: expected 0x%x, present 0x%x",
+				m[1], code, int(op))
+		}
+
+		count++
+	}
+
+	if count == 0 {
+		t.Errorf("ipp.OP: no operations found in ipp.py")
+	}
+}
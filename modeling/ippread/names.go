@@ -0,0 +1,88 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Python names of IPP tags and operations
+
+package ippread
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/OpenPrinting/goipp"
+)
+
+// ippTagByName maps Python name of the IPP value type into the
+// goipp.Tag. It must be kept in sync with modeling/ipp.py.
+var ippTagByName = map[string]goipp.Tag{
+	// Special values
+	"ipp.UNSUPPORTED_VALUE": goipp.TagUnsupportedValue,
+	"ipp.DEFAULT":           goipp.TagDefault,
+	"ipp.UNKNOWN":           goipp.TagUnknown,
+	"ipp.NOVALUE":           goipp.TagNoValue,
+	"ipp.NOTSETTABLE":       goipp.TagNotSettable,
+	"ipp.DELETEATTR":        goipp.TagDeleteAttr,
+	"ipp.ADMINDEFINE":       goipp.TagAdminDefine,
+
+	// Values
+	"ipp.INTEGER":    goipp.TagInteger,
+	"ipp.BOOLEAN":    goipp.TagBoolean,
+	"ipp.ENUM":       goipp.TagEnum,
+	"ipp.STRING":     goipp.TagString,
+	"ipp.DATE":       goipp.TagDateTime,
+	"ipp.RESOLUTION": goipp.TagResolution,
+	"ipp.RANGE":      goipp.TagRange,
+	"ipp.TEXTLANG":   goipp.TagTextLang,
+	"ipp.NAMELANG":   goipp.TagNameLang,
+	"ipp.TEXT":       goipp.TagText,
+	"ipp.NAME":       goipp.TagName,
+	"ipp.KEYWORD":    goipp.TagKeyword,
+	"ipp.URI":        goipp.TagURI,
+	"ipp.URISCHEME":  goipp.TagURIScheme,
+	"ipp.CHARSET":    goipp.TagCharset,
+	"ipp.LANGUAGE":   goipp.TagLanguage,
+	"ipp.MIMETYPE":   goipp.TagMimeType,
+}
+
+// ippOpByName maps Python name of the IPP operation (the ipp.OP
+// member name, like PRINT_JOB) into the goipp.Op.
+var ippOpByName = map[string]goipp.Op{}
+
+// init populates the ippOpByName map.
+//
+// Python names are derived from the goipp Go names, so
+// goipp.OpCupsGetDefault becomes CUPS_GET_DEFAULT.
+func init() {
+	ranges := [][2]goipp.Op{{0x0000, 0x00ff}, {0x4000, 0x40ff}}
+	for _, rng := range ranges {
+		for op := rng[0]; op <= rng[1]; op++ {
+			goname, ok := strings.CutPrefix(op.GoString(), "goipp.Op")
+			if !ok || strings.HasPrefix(goname, "(") {
+				continue
+			}
+
+			// Words start at upper-case letter, that follows
+			// the lower-case one or starts the next word after
+			// an acronym, so PrintURI becomes PRINT_URI. The
+			// plural 's' belongs to acronym: CupsGetPPDs becomes
+			// CUPS_GET_PPDS.
+			var name strings.Builder
+			runes := []rune(goname)
+			for i, c := range runes {
+				if i > 0 && unicode.IsUpper(c) &&
+					(!unicode.IsUpper(runes[i-1]) ||
+						i+1 < len(runes) &&
+							unicode.IsLower(runes[i+1]) &&
+							runes[i+1] != 's') {
+					name.WriteByte('_')
+				}
+				name.WriteRune(unicode.ToUpper(c))
+			}
+
+			ippOpByName[name.String()] = op
+		}
+	}
+}
@@ -0,0 +1,489 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Localization of device strings
+
+package modeling
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/OpenPrinting/go-mfp/cpython"
+	"github.com/OpenPrinting/go-mfp/proto/wsscan"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// L10nDefaultLanguage is the default language of the localized
+// strings, used when [Localization.Default] is not set.
+const L10nDefaultLanguage = "en"
+
+// Localized contains per-language variants of the localizable string,
+// keyed by the language tag (i.e., "en", "en-US", "de").
+//
+// The empty key ("") denotes the language-neutral variant.
+type Localized map[string]string
+
+// Localization contains localized strings of the modeled device.
+//
+// The strings are served according to the client's language
+// preferences:
+//   - IPP: attributes-natural-language of the request
+//   - eSCL: the Accept-Language HTTP header
+//   - WS-Scan: all variants are served, each with its xml:lang
+//     attribute, and client chooses by itself
+//
+// See [Localized.Lookup] for the language fallback rules.
+type Localization struct {
+	Default         string    // Default language, "en" if not set
+	PrinterInfo     Localized // IPP printer-info
+	PrinterLocation Localized // IPP printer-location
+	AdminURI        Localized // eSCL AdminURI (admin pages)
+	FriendlyName    Localized // WS-Scan ScannerName
+	ScannerInfo     Localized // WS-Scan ScannerInfo
+}
+
+// SetLocalization sets the [Localization].
+func (model *Model) SetLocalization(l10n *Localization) {
+	model.l10n = l10n
+}
+
+// GetLocalization returns the [Localization], previously set with
+// [Model.SetLocalization] or loaded from the model file.
+//
+// It returns nil, if model has no localized strings.
+func (model *Model) GetLocalization() *Localization {
+	return model.l10n
+}
+
+// DefaultLanguage returns the default language of the Localization.
+func (l10n *Localization) DefaultLanguage() string {
+	if l10n.Default != "" {
+		return l10n.Default
+	}
+	return L10nDefaultLanguage
+}
+
+// Lookup returns the variant of the string that best matches
+// the languages, listed in order of preference, and its language.
+//
+// Languages are matched according to the following rules, in order:
+//   - for each requested language, the exact match (case-insensitive)
+//   - for each requested language, the match by the primary language
+//     subtag (i.e., "de-AT" matches "de" and vice versa)
+//   - the same two steps for the default language, def
+//   - the language-neutral ("") variant
+//   - the variant with the lexicographically smallest language tag,
+//     so the choice is deterministic.
+//
+// It returns false only if Localized is empty.
+func (loc Localized) Lookup(def string, langs ...string) (
+	lang, text string, ok bool) {
+
+	if len(loc) == 0 {
+		return "", "", false
+	}
+
+	tags := make([]string, 0, len(loc))
+	for tag := range loc {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	match := func(want string) (string, bool) {
+		if want == "" {
+			return "", false
+		}
+
+		// Exact match
+		for _, tag := range tags {
+			if strings.EqualFold(tag, want) {
+				return tag, true
+			}
+		}
+
+		// Match by primary subtag
+		primary := l10nPrimary(want)
+		for _, tag := range tags {
+			if tag != "" && strings.EqualFold(l10nPrimary(tag), primary) {
+				return tag, true
+			}
+		}
+
+		return "", false
+	}
+
+	for _, want := range append(langs[:len(langs):len(langs)], def) {
+		if tag, found := match(want); found {
+			return tag, loc[tag], true
+		}
+	}
+
+	if text, found := loc[""]; found {
+		return "", text, true
+	}
+
+	return tags[0], loc[tags[0]], true
+}
+
+// TextWithLangList converts Localized into the [wsscan.TextWithLangList].
+// The default language variant comes first, then the language-neutral
+// variant, then others, sorted by language tag.
+func (loc Localized) TextWithLangList(def string) wsscan.TextWithLangList {
+	tags := make([]string, 0, len(loc))
+	for tag := range loc {
+		tags = append(tags, tag)
+	}
+
+	rank := func(tag string) int {
+		switch {
+		case strings.EqualFold(tag, def):
+			return 0
+		case tag == "":
+			return 1
+		}
+		return 2
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		ri, rj := rank(tags[i]), rank(tags[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return tags[i] < tags[j]
+	})
+
+	list := make(wsscan.TextWithLangList, 0, len(tags))
+	for _, tag := range tags {
+		elm := wsscan.TextWithLangElement{Text: loc[tag]}
+		if tag != "" {
+			elm.Lang = optional.New(tag)
+		}
+		list = append(list, elm)
+	}
+
+	return list
+}
+
+// l10nPrimary returns the primary language subtag of the language tag.
+func l10nPrimary(tag string) string {
+	primary, _, _ := strings.Cut(tag, "-")
+	return primary
+}
+
+// l10nAcceptLanguage parses the Accept-Language HTTP header
+// and returns the list of languages in order of preference.
+//
+// Languages with q=0 and the wildcard ("*") are skipped.
+func l10nAcceptLanguage(hdr string) []string {
+	type item struct {
+		lang string
+		q    float64
+	}
+
+	var items []item
+	for _, field := range strings.Split(hdr, ",") {
+		lang, params, _ := strings.Cut(field, ";")
+		lang = strings.TrimSpace(lang)
+		q := 1.0
+
+		for _, param := range strings.Split(params, ";") {
+			name, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if name == "q" {
+				if v, err := strconv.ParseFloat(val, 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if lang != "" && lang != "*" && q > 0 {
+			items = append(items, item{lang, q})
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].q > items[j].q
+	})
+
+	langs := make([]string, len(items))
+	for i := range items {
+		langs[i] = items[i].lang
+	}
+
+	return langs
+}
+
+// l10nIPP applies Localization to the IPP responses.
+type l10nIPP struct {
+	l10n  *Localization // Localized strings
+	langs sync.Map      // Requested languages, by *transport.ServerQuery
+}
+
+// OnIPPRequest implements the [ipp.ServerHooks.OnIPPRequest] hook.
+// It saves the request's attributes-natural-language.
+func (l *l10nIPP) OnIPPRequest(query *transport.ServerQuery,
+	msg *goipp.Message) *goipp.Message {
+
+	lang := ""
+	for _, attr := range msg.Operation {
+		if attr.Name == "attributes-natural-language" &&
+			len(attr.Values) != 0 {
+			lang = attr.Values[0].V.String()
+			break
+		}
+	}
+
+	l.langs.Store(query, lang)
+	query.OnCompletion(func(query *transport.ServerQuery) {
+		l.langs.Delete(query)
+	})
+
+	return nil
+}
+
+// OnIPPResponse implements the [ipp.ServerHooks.OnIPPResponse] hook.
+// It replaces localizable printer attributes, if present in the
+// response, with the variant in the requested language.
+//
+// Variant in the language, other that the response
+// attributes-natural-language, is sent as textWithLanguage.
+func (l *l10nIPP) OnIPPResponse(query *transport.ServerQuery,
+	rsp *goipp.Message) *goipp.Message {
+
+	lang := ""
+	if v, found := l.langs.Load(query); found {
+		lang = v.(string)
+	}
+
+	rspLang := ""
+	for _, grp := range rsp.AttrGroups() {
+		if grp.Tag != goipp.TagOperationGroup {
+			continue
+		}
+		for _, attr := range grp.Attrs {
+			if attr.Name == "attributes-natural-language" &&
+				len(attr.Values) != 0 {
+				rspLang = attr.Values[0].V.String()
+				break
+			}
+		}
+	}
+
+	// Note, if rsp.Groups is set, it takes precedence over
+	// the named per-group fields when response is encoded,
+	// so both need to be updated.
+	if printer := l.localize(rsp.Printer, lang, rspLang); printer != nil {
+		rsp.Printer = printer
+	}
+
+	var groups goipp.Groups
+	for i, grp := range rsp.Groups {
+		if grp.Tag != goipp.TagPrinterGroup {
+			continue
+		}
+
+		printer := l.localize(grp.Attrs, lang, rspLang)
+		if printer == nil {
+			continue
+		}
+
+		if groups == nil {
+			groups = append(goipp.Groups(nil), rsp.Groups...)
+		}
+		groups[i].Attrs = printer
+	}
+
+	if groups != nil {
+		rsp.Groups = groups
+	}
+
+	return nil
+}
+
+// localize returns copy of the printer attributes with localizable
+// attributes replaced by the variant in the requested language.
+// If there is nothing to localize, it returns nil.
+//
+// Note, response attributes may share memory with the
+// printer attributes, so we never modify them in place.
+func (l *l10nIPP) localize(attrs goipp.Attributes,
+	lang, rspLang string) goipp.Attributes {
+
+	localizable := map[string]Localized{
+		"printer-info":     l.l10n.PrinterInfo,
+		"printer-location": l.l10n.PrinterLocation,
+	}
+
+	var printer goipp.Attributes
+	for i, attr := range attrs {
+		tag, text, ok := localizable[attr.Name].Lookup(
+			l.l10n.DefaultLanguage(), lang)
+		if !ok {
+			continue
+		}
+
+		if printer == nil {
+			printer = append(goipp.Attributes(nil), attrs...)
+		}
+
+		if tag == "" || strings.EqualFold(tag, rspLang) {
+			printer[i].Values = goipp.Values{
+				{T: goipp.TagText, V: goipp.String(text)}}
+		} else {
+			printer[i].Values = goipp.Values{
+				{T: goipp.TagTextLang,
+					V: goipp.TextWithLang{Lang: tag, Text: text}}}
+		}
+	}
+
+	return printer
+}
+
+// l10nExport exports Localization as Python object.
+func l10nExport(py *cpython.Python, l10n *Localization) *cpython.Object {
+	var kwargs []cpython.KWArg
+
+	if l10n.Default != "" {
+		kwargs = append(kwargs,
+			cpython.KWArg{Name: "Default", Value: l10n.Default})
+	}
+
+	for _, field := range l10n.fields() {
+		if *field.loc != nil {
+			kwargs = append(kwargs, cpython.KWArg{
+				Name:  field.name,
+				Value: map[string]string(*field.loc),
+			})
+		}
+	}
+
+	return py.Eval("l10n.Localization").CallKWArgs(kwargs)
+}
+
+// l10nLoad decodes the localization part of model. The model file
+// assumed to be preloaded into the Model's Python interpreter
+// (model.py).
+func (model *Model) l10nLoad() error {
+	obj := model.py.Eval("l10n.strings")
+
+	if err := obj.Err(); err != nil {
+		err = fmt.Errorf("l10n.strings: %w", err)
+		return err
+	}
+
+	if obj.IsNone() {
+		return nil
+	}
+
+	l10n, err := l10nImport(obj)
+	if err != nil {
+		err = fmt.Errorf("l10n.strings: %w", err)
+		return err
+	}
+
+	model.l10n = l10n
+	return nil
+}
+
+// l10nImport imports Localization from the Python object.
+func l10nImport(obj *cpython.Object) (*Localization, error) {
+	l10n := &Localization{}
+
+	def := obj.Get("Default")
+	switch {
+	case def.NotFound():
+	case def.Err() != nil:
+		return nil, errImportWrap("Default", def.Err())
+	case def.IsNone():
+	default:
+		var err error
+		l10n.Default, err = def.Str()
+		if err != nil {
+			return nil, errImportWrap("Default", err)
+		}
+	}
+
+	for _, field := range l10n.fields() {
+		err := l10nImportLocalized(obj, field.name, field.loc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return l10n, nil
+}
+
+// l10nImportLocalized imports the Localized string, represented
+// by the Python dict attribute of the specified name.
+func l10nImportLocalized(obj *cpython.Object, name string,
+	out *Localized) error {
+
+	dict := obj.Get(name)
+	switch {
+	case dict.NotFound():
+		return nil
+	case dict.Err() != nil:
+		return errImportWrap(name, dict.Err())
+	case dict.IsNone():
+		return nil
+	case !dict.IsDict():
+		return errImportWrap(name,
+			fmt.Errorf("%s is not dict", dict.TypeName()))
+	}
+
+	keys, err := dict.Keys()
+	if err != nil {
+		return errImportWrap(name, err)
+	}
+
+	loc := make(Localized, len(keys))
+	for _, keyobj := range keys {
+		key, err := keyobj.Str()
+		if err != nil {
+			return errImportWrap(name, err)
+		}
+
+		item := dict.GetItem(keyobj)
+		if err = item.Err(); err != nil {
+			return errImportWrap(name+"["+key+"]", err)
+		}
+
+		if !item.IsUnicode() {
+			err = fmt.Errorf("%s is not str", item.TypeName())
+			return errImportWrap(name+"["+key+"]", err)
+		}
+
+		loc[key], err = item.Unicode()
+		if err != nil {
+			return errImportWrap(name+"["+key+"]", err)
+		}
+	}
+
+	*out = loc
+	return nil
+}
+
+// l10nField is the named Localized field of the Localization.
+type l10nField struct {
+	name string
+	loc  *Localized
+}
+
+// fields returns Localized fields of the Localization, by name.
+func (l10n *Localization) fields() []l10nField {
+	return []l10nField{
+		{"PrinterInfo", &l10n.PrinterInfo},
+		{"PrinterLocation", &l10n.PrinterLocation},
+		{"AdminURI", &l10n.AdminURI},
+		{"FriendlyName", &l10n.FriendlyName},
+		{"ScannerInfo", &l10n.ScannerInfo},
+	}
+}
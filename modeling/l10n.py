@@ -0,0 +1,23 @@
+# MFP - Miulti-Function Printers and scanners toolkit
+# Printer and scanner modeling.
+#
+# Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+# See LICENSE for license terms and conditions
+#
+# Localization definitions
+
+from helpers import collection
+
+# Localization types
+class Localization(collection): pass
+
+# strings is the model-settable variable that defines the localized
+# device strings. Each string is the dict, keyed by language tag:
+#
+#   l10n.strings = l10n.Localization(
+#       Default = "en",
+#       PrinterInfo = {"en": "Office printer", "de": "Bürodrucker"},
+#   )
+#
+# If None, strings are not localized.
+strings = None
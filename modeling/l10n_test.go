@@ -0,0 +1,387 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Localization test
+
+package modeling
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/abstract"
+	"github.com/OpenPrinting/go-mfp/internal/assert"
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/modeling/defaults"
+	"github.com/OpenPrinting/go-mfp/proto/escl"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestLocalizedLookup tests the language fallback rules
+// of Localized.Lookup
+func TestLocalizedLookup(t *testing.T) {
+	type testData struct {
+		loc   Localized // Localized string
+		def   string    // Default language
+		langs []string  // Requested languages
+		lang  string    // Expected language
+		text  string    // Expected text
+		ok    bool      // Expected ok
+	}
+
+	loc := Localized{
+		"en":    "Office",
+		"de":    "Büro",
+		"pt-BR": "Escritório",
+	}
+
+	tests := []testData{
+		// Empty Localized
+		{loc: nil, def: "en", langs: []string{"en"}},
+
+		// Exact match, case-insensitive
+		{loc: loc, def: "en", langs: []string{"de"},
+			lang: "de", text: "Büro", ok: true},
+		{loc: loc, def: "en", langs: []string{"PT-br"},
+			lang: "pt-BR", text: "Escritório", ok: true},
+
+		// Match by primary subtag
+		{loc: loc, def: "en", langs: []string{"de-AT"},
+			lang: "de", text: "Büro", ok: true},
+		{loc: loc, def: "en", langs: []string{"pt"},
+			lang: "pt-BR", text: "Escritório", ok: true},
+
+		// Order of preference
+		{loc: loc, def: "en", langs: []string{"fr", "de"},
+			lang: "de", text: "Büro", ok: true},
+
+		// Fallback to default language
+		{loc: loc, def: "de", langs: []string{"fr"},
+			lang: "de", text: "Büro", ok: true},
+		{loc: loc, def: "en", langs: nil,
+			lang: "en", text: "Office", ok: true},
+
+		// Fallback to language-neutral variant
+		{loc: Localized{"": "Neutral", "de": "Büro"},
+			def: "en", langs: []string{"fr"},
+			lang: "", text: "Neutral", ok: true},
+
+		// Fallback to the smallest language tag
+		{loc: Localized{"ru": "Офис", "de": "Büro"},
+			def: "en", langs: []string{"fr"},
+			lang: "de", text: "Büro", ok: true},
+	}
+
+	for _, test := range tests {
+		lang, text, ok := test.loc.Lookup(test.def, test.langs...)
+		if lang != test.lang || text != test.text || ok != test.ok {
+			t.Errorf("%v, def=%q, langs=%q:\n"+
+				"expected: %q %q %v\n"+
+				"present:  %q %q %v",
+				test.loc, test.def, test.langs,
+				test.lang, test.text, test.ok,
+				lang, text, ok)
+		}
+	}
+}
+
+// TestL10nAcceptLanguage tests l10nAcceptLanguage
+func TestL10nAcceptLanguage(t *testing.T) {
+	type testData struct {
+		hdr   string   // Accept-Language header
+		langs []string // Expected languages
+	}
+
+	tests := []testData{
+		{"", []string{}},
+		{"de", []string{"de"}},
+		{"fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5",
+			[]string{"fr-CH", "fr", "en", "de"}},
+		{"en;q=0.5, de", []string{"de", "en"}},
+		{"en;q=0, de", []string{"de"}},
+	}
+
+	for _, test := range tests {
+		langs := l10nAcceptLanguage(test.hdr)
+		if !reflect.DeepEqual(langs, test.langs) {
+			t.Errorf("%q:\nexpected: %q\npresent:  %q",
+				test.hdr, test.langs, langs)
+		}
+	}
+}
+
+// TestLocalization tests Localization loading and Model.Write/Model.Read
+// round trip.
+func TestLocalization(t *testing.T) {
+	const src = `
+l10n.strings = l10n.Localization(
+    Default = "de",
+    PrinterInfo = {"en": "Office printer", "de": "Bürodrucker"},
+    FriendlyName = {"en": "Office scanner", "de": "Büroscanner"},
+)
+`
+
+	model, err := NewModel()
+	assert.NoError(err)
+	defer model.Close()
+	testNeedPython(t, model)
+
+	err = model.Read("test", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Model.Read: %s", err)
+	}
+
+	expected := &Localization{
+		Default:      "de",
+		PrinterInfo:  Localized{"en": "Office printer", "de": "Bürodrucker"},
+		FriendlyName: Localized{"en": "Office scanner", "de": "Büroscanner"},
+	}
+
+	diff := testutils.Diff(expected, model.GetLocalization())
+	if diff != "" {
+		t.Errorf("Model.Read:\n%s", diff)
+	}
+
+	// Roll over Model.Write/Model.Read
+	buf := &bytes.Buffer{}
+	err = model.Write(buf)
+	if err != nil {
+		t.Fatalf("Model.Write: %s", err)
+	}
+
+	model2, err := NewModel()
+	assert.NoError(err)
+	defer model2.Close()
+
+	err = model2.Read("test", buf)
+	if err != nil {
+		t.Fatalf("Model.Read: %s", err)
+	}
+
+	diff = testutils.Diff(expected, model2.GetLocalization())
+	if diff != "" {
+		t.Errorf("Model.Write/Model.Read:\n%s", diff)
+	}
+
+	// Non-string values must be rejected
+	model3, err := NewModel()
+	assert.NoError(err)
+	defer model3.Close()
+
+	err = model3.Read("test", strings.NewReader(
+		`l10n.strings = l10n.Localization(PrinterInfo = {"en": 5})`))
+	if err == nil {
+		t.Errorf("Model.Read: non-string value not detected")
+	}
+}
+
+// TestLocalizationServe requests the same device in two languages
+// and verifies that localized strings are served appropriately.
+func TestLocalizationServe(t *testing.T) {
+	model, err := NewModel()
+	assert.NoError(err)
+	defer model.Close()
+
+	// Setup the model
+	var msg goipp.Message
+	err = msg.DecodeBytes(testutils.Kyocera.ECOSYS.M2040dn.
+		IPP.PrinterAttributes)
+	assert.NoError(err)
+
+	pa, err := ipp.DecodePrinterAttributes(msg.Printer, nil)
+	assert.NoError(err)
+
+	model.SetIPPPrinterAttrs(pa)
+
+	abscaps := defaults.ScannerCapabilities()
+	model.SetESCLScanCaps(escl.FromAbstractScannerCapabilities(
+		escl.DefaultVersion, abscaps))
+
+	model.SetLocalization(&Localization{
+		PrinterInfo: Localized{
+			"en": "Office printer",
+			"de": "Bürodrucker",
+		},
+		PrinterLocation: Localized{
+			"": "Room 101",
+		},
+		AdminURI: Localized{
+			"en": "http://printer.local/en/admin",
+			"de": "http://printer.local/de/admin",
+		},
+	})
+
+	// Test IPP
+	ippsrv := httptest.NewServer(model.NewIPPServer())
+	defer ippsrv.Close()
+
+	getIPP := func(lang string) map[string]goipp.Values {
+		rq := goipp.NewRequest(goipp.DefaultVersion,
+			goipp.OpGetPrinterAttributes, 1)
+		rq.Operation.Add(goipp.MakeAttribute("attributes-charset",
+			goipp.TagCharset, goipp.String("utf-8")))
+		rq.Operation.Add(goipp.MakeAttribute(
+			"attributes-natural-language",
+			goipp.TagLanguage, goipp.String(lang)))
+		rq.Operation.Add(goipp.MakeAttribute("printer-uri",
+			goipp.TagURI, goipp.String(ippsrv.URL+"/ipp/print")))
+		rq.Operation.Add(goipp.MakeAttr("requested-attributes",
+			goipp.TagKeyword, goipp.String("printer-info"),
+			goipp.String("printer-location")))
+
+		data, _ := rq.EncodeBytes()
+		rsp, err := http.Post(ippsrv.URL+"/ipp/print",
+			goipp.ContentType, bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("IPP: %s", err)
+		}
+		defer rsp.Body.Close()
+
+		var msg goipp.Message
+		err = msg.Decode(rsp.Body)
+		if err != nil {
+			t.Fatalf("IPP: %s", err)
+		}
+
+		attrs := make(map[string]goipp.Values)
+		for _, attr := range msg.Printer {
+			attrs[attr.Name] = attr.Values
+		}
+
+		return attrs
+	}
+
+	type ippTestData struct {
+		lang     string      // Requested language
+		info     goipp.Value // Expected printer-info
+		location goipp.Value // Expected printer-location
+	}
+
+	ippTests := []ippTestData{
+		{
+			lang:     "en-us",
+			info:     goipp.TextWithLang{Lang: "en", Text: "Office printer"},
+			location: goipp.String("Room 101"),
+		},
+		{
+			lang:     "de",
+			info:     goipp.TextWithLang{Lang: "de", Text: "Bürodrucker"},
+			location: goipp.String("Room 101"),
+		},
+		{
+			lang:     "fr",
+			info:     goipp.TextWithLang{Lang: "en", Text: "Office printer"},
+			location: goipp.String("Room 101"),
+		},
+	}
+
+	for _, test := range ippTests {
+		attrs := getIPP(test.lang)
+
+		info := attrs["printer-info"]
+		if len(info) != 1 || !goipp.ValueEqual(info[0].V, test.info) {
+			t.Errorf("IPP %s: printer-info: expected %s, present %s",
+				test.lang, test.info, info)
+		}
+
+		location := attrs["printer-location"]
+		if len(location) != 1 || !goipp.ValueEqual(location[0].V, test.location) {
+			t.Errorf("IPP %s: printer-location: expected %s, present %s",
+				test.lang, test.location, location)
+		}
+	}
+
+	// Test eSCL
+	scanner := &abstract.VirtualScanner{ScanCaps: abscaps}
+	esclsrv := httptest.NewServer(model.NewESCLServer(scanner))
+	defer esclsrv.Close()
+
+	getESCL := func(lang string) string {
+		rq, _ := http.NewRequest("GET",
+			esclsrv.URL+"/eSCL/ScannerCapabilities", nil)
+		rq.Header.Set("Accept-Language", lang)
+
+		rsp, err := http.DefaultClient.Do(rq)
+		if err != nil {
+			t.Fatalf("eSCL: %s", err)
+		}
+		defer rsp.Body.Close()
+
+		xml, err := xmldoc.Decode(escl.NsMap, rsp.Body)
+		if err != nil {
+			t.Fatalf("eSCL: %s", err)
+		}
+
+		caps, err := escl.DecodeScannerCapabilities(xml)
+		if err != nil {
+			t.Fatalf("eSCL: %s", err)
+		}
+
+		return optional.Get(caps.AdminURI)
+	}
+
+	esclTests := map[string]string{
+		"en":                "http://printer.local/en/admin",
+		"de-DE, en;q=0.5":   "http://printer.local/de/admin",
+		"fr, de;q=0.8":      "http://printer.local/de/admin",
+		"fr":                "http://printer.local/en/admin",
+		"de;q=0, en;q=0.10": "http://printer.local/en/admin",
+	}
+
+	for lang, expected := range esclTests {
+		present := getESCL(lang)
+		if present != expected {
+			t.Errorf("eSCL %q: AdminURI: expected %s, present %s",
+				lang, expected, present)
+		}
+	}
+}
+
+// TestLocalizationWSD tests localized WS-Scan ScannerDescription
+func TestLocalizationWSD(t *testing.T) {
+	model, err := NewModel()
+	assert.NoError(err)
+	defer model.Close()
+
+	model.SetLocalization(&Localization{
+		Default: "de",
+		FriendlyName: Localized{
+			"en": "Office scanner",
+			"de": "Büroscanner",
+		},
+		ScannerInfo: Localized{
+			"": "Scanner",
+		},
+	})
+
+	desc := model.wsdDescription()
+	if desc == nil {
+		t.Fatalf("wsdDescription: nil")
+	}
+
+	names := []string{}
+	for _, name := range desc.ScannerName {
+		names = append(names, optional.Get(name.Lang)+"="+name.Text)
+	}
+
+	// Default language must come first
+	expected := "de=Büroscanner,en=Office scanner"
+	if present := strings.Join(names, ","); present != expected {
+		t.Errorf("ScannerName:\nexpected: %s\npresent:  %s",
+			expected, present)
+	}
+
+	if len(desc.ScannerInfo) != 1 || desc.ScannerInfo[0].Lang != nil {
+		t.Errorf("ScannerInfo: %v", desc.ScannerInfo)
+	}
+}
@@ -0,0 +1,530 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Decoding of literals into the protocol structures
+
+package lite
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/internal/assert"
+	"github.com/OpenPrinting/go-mfp/proto/escl"
+	"github.com/OpenPrinting/go-mfp/proto/usb"
+	"github.com/OpenPrinting/go-mfp/proto/wsscan"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// errDecode represents the error that may occur during decoding
+// literal into the Go structure.
+type errDecode struct {
+	path []string // Path over attribute names
+	err  error    // Underlying error
+}
+
+// errDecodeWrap wraps error into the errDecode.
+// name is the name of the attribute the error is related to.
+func errDecodeWrap(name string, err error) error {
+	if e, ok := err.(errDecode); ok {
+		return errDecode{
+			path: append([]string{name}, e.path...),
+			err:  e.err,
+		}
+	}
+
+	return errDecode{
+		path: []string{name},
+		err:  err,
+	}
+}
+
+// Error implements the error interface for errDecode
+func (e errDecode) Error() string {
+	buf := strings.Builder{}
+	for _, p := range e.path {
+		if buf.Len() > 0 && !strings.HasPrefix(p, "[") {
+			buf.WriteByte('.')
+		}
+		buf.WriteString(p)
+	}
+
+	buf.Write([]byte(": "))
+	buf.WriteString(e.err.Error())
+
+	return buf.String()
+}
+
+// Unwrap "unwraps" the error.
+func (e errDecode) Unwrap() error {
+	return e.err
+}
+
+// errConvert returns a conversion error for conversion
+// from the literal to the Go value
+func errConvert(from *node, to reflect.Value) error {
+	return fmt.Errorf("%d: can't convert %s to %s",
+		from.line, from, to.Type())
+}
+
+// decodeStruct decodes literal into the Go structure, that
+// expected to be the protocol object.
+//
+// module is the Python module of the protocol (escl, wsd or usb).
+// Keyword arguments are matched against the struct fields,
+// ignoring case, as the model keywords only differ from the
+// Go field names by case (Uuid vs UUID).
+//
+// p MUST be pointer to struct or pointer to pointer to struct.
+func decodeStruct(n *node, module string, p any) error {
+	err := decodeStructInt(n, module, p)
+	if err != nil {
+		name := reflect.TypeOf(p).Elem().String()
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			name = name[i+1:]
+		}
+		return errDecodeWrap(name, err)
+	}
+
+	return nil
+}
+
+// decodeStructInt is the internal function behind decodeStruct.
+func decodeStructInt(n *node, module string, p any) error {
+	// Validate argument
+	t := reflect.TypeOf(p)
+
+	msg := fmt.Sprintf("%s: invalid type", t)
+	assert.MustMsg(t.Kind() == reflect.Pointer, msg)
+	assert.MustMsg(p != nil, "nil pointer dereference")
+
+	t = t.Elem()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	assert.MustMsg(t.Kind() == reflect.Struct, msg)
+
+	// Structures are represented as module.Type(Field = value, ...)
+	if n.kind != nodeCall || len(n.items) != 0 ||
+		!strings.HasPrefix(n.str, module+".") {
+		return errConvert(n, reflect.New(t).Elem())
+	}
+
+	// Create a new instance of the target structure
+	v := reflect.New(t).Elem()
+
+	// Decode structure, argument by argument
+	flds := reflect.VisibleFields(t)
+	for _, arg := range n.named {
+		var fld *reflect.StructField
+		for i := range flds {
+			if flds[i].IsExported() &&
+				strings.EqualFold(flds[i].Name, arg.name) {
+				fld = &flds[i]
+				break
+			}
+		}
+
+		if fld == nil {
+			err := fmt.Errorf("%d: unknown keyword", arg.val.line)
+			return errDecodeWrap(arg.name, err)
+		}
+
+		if arg.val.kind == nodeNone {
+			continue
+		}
+
+		fldval := v.FieldByIndex(fld.Index)
+		err := decodeValue(arg.val, module, fldval)
+		if err != nil {
+			return errDecodeWrap(fld.Name, err)
+		}
+	}
+
+	// Save output
+	out := reflect.ValueOf(p).Elem()
+	if out.Type().Kind() == reflect.Pointer {
+		out.Set(v.Addr())
+	} else {
+		out.Set(v)
+	}
+
+	return nil
+}
+
+// decodeSlice decodes slice of values from the list literal.
+func decodeSlice(n *node, module string, v reflect.Value) error {
+	if n.kind != nodeList {
+		return errConvert(n, v)
+	}
+
+	// Allocate output memory
+	v.Set(reflect.MakeSlice(v.Type(), len(n.items), len(n.items)))
+
+	// Decode item by item
+	for i, item := range n.items {
+		err := decodeValue(item, module, v.Index(i))
+		if err != nil {
+			return errDecodeWrap(fmt.Sprintf("[%d]", i), err)
+		}
+	}
+
+	return nil
+}
+
+// decodeValue decodes a value from the literal.
+func decodeValue(n *node, module string, v reflect.Value) error {
+	// If we are decoding pointer to value, create a new
+	// value instance and shift to it.
+	if v.Kind() == reflect.Pointer {
+		v2 := reflect.New(v.Type().Elem())
+		v.Set(v2)
+		v = v2.Elem()
+	}
+
+	// Handle known types
+	switch v.Interface().(type) {
+
+	// escl types
+	case escl.ADFOption:
+		return decodeEnum(n, v, escl.DecodeADFOption)
+	case escl.ADFState:
+		return decodeEnum(n, v, escl.DecodeADFState)
+	case escl.BinaryRendering:
+		return decodeEnum(n, v, escl.DecodeBinaryRendering)
+	case escl.CCDChannel:
+		return decodeEnum(n, v, escl.DecodeCCDChannel)
+	case escl.ColorMode:
+		return decodeEnum(n, v, escl.DecodeColorMode)
+	case escl.ColorSpace:
+		return decodeEnum(n, v, escl.DecodeColorSpace)
+	case escl.ContentType:
+		return decodeEnum(n, v, escl.DecodeContentType)
+	case escl.FeedDirection:
+		return decodeEnum(n, v, escl.DecodeFeedDirection)
+	case escl.ImagePosition:
+		return decodeEnum(n, v, escl.DecodeImagePosition)
+	case escl.InputSource:
+		return decodeEnum(n, v, escl.DecodeInputSource)
+	case escl.Intent:
+		return decodeEnum(n, v, escl.DecodeIntent)
+	case escl.JobState:
+		return decodeEnum(n, v, escl.DecodeJobState)
+	case escl.Units:
+		return decodeEnum(n, v, escl.DecodeUnits)
+
+	case escl.JobStateReason:
+		s, err := decodeKeyword(n, v)
+		if err == nil {
+			v.Set(reflect.ValueOf(escl.JobStateReason(s)))
+		}
+		return err
+
+	case escl.Version:
+		s, err := decodeString(n, v)
+		if err != nil {
+			return err
+		}
+
+		ver, err := escl.DecodeVersion(s)
+		if err == nil {
+			v.Set(reflect.ValueOf(ver))
+		}
+		return err
+
+	// wsscan types
+	case wsscan.ColorEntry:
+		return decodeEnum(n, v, wsscan.DecodeColorEntry)
+	case wsscan.ContentTypeValue:
+		return decodeEnum(n, v, wsscan.DecodeContentTypeValue)
+	case wsscan.FilmScanMode:
+		return decodeEnum(n, v, wsscan.DecodeFilmScanMode)
+	case wsscan.InputSourceValue:
+		return decodeEnum(n, v, wsscan.DecodeInputSourceValue)
+	case wsscan.JobElemName:
+		return decodeEnum(n, v, wsscan.DecodeJobElemName)
+	case wsscan.JobStateReason:
+		return decodeEnum(n, v, wsscan.DecodeJobStateReason)
+	case wsscan.JobState:
+		return decodeEnum(n, v, wsscan.DecodeJobState)
+	case wsscan.RotationValue:
+		return decodeEnum(n, v, wsscan.DecodeRotationValue)
+	case wsscan.ScannerElemName:
+		return decodeEnum(n, v, wsscan.DecodeScannerElemName)
+	case wsscan.ScannerStateReason:
+		return decodeEnum(n, v, wsscan.DecodeScannerStateReason)
+	case wsscan.ScannerState:
+		return decodeEnum(n, v, wsscan.DecodeScannerState)
+	case wsscan.Severity:
+		return decodeEnum(n, v, wsscan.DecodeSeverity)
+
+	case wsscan.TextWithLangElement:
+		return decodeTextWithLangElement(n, v)
+
+	case wsscan.TextWithLangList:
+		return decodeTextWithLangList(n, v)
+
+	// USB types
+	case usb.Version:
+		s, err := decodeString(n, v)
+		if err != nil {
+			return err
+		}
+
+		ver, err := usb.ParseVersion(s)
+		if err == nil {
+			v.Set(reflect.ValueOf(ver))
+		}
+		return err
+
+	case usb.EndpointType:
+		s, err := decodeKeyword(n, v)
+		if err != nil {
+			return err
+		}
+
+		switch s {
+		case "IN":
+			v.Set(reflect.ValueOf(usb.EndpointIn))
+		case "OUT":
+			v.Set(reflect.ValueOf(usb.EndpointOut))
+		default:
+			err = errConvert(n, v)
+		}
+		return err
+
+	// other types
+	case uuid.UUID:
+		if n.kind != nodeCall || n.str != "UUID" ||
+			len(n.items) != 1 || len(n.named) != 0 {
+			return errConvert(n, v)
+		}
+
+		s, err := decodeString(n.items[0], v)
+		if err != nil {
+			return err
+		}
+
+		u, err := uuid.Parse(s)
+		if err == nil {
+			v.Set(reflect.ValueOf(u))
+		}
+		return err
+	}
+
+	// Handle interface types with pointer receiver
+	switch p := v.Addr().Interface().(type) {
+	case wsscan.WithOptions:
+		return decodeValWithOptions(n, p)
+	}
+
+	// Switch by reflect.Kind
+	switch v.Kind() {
+	case reflect.Struct:
+		return decodeStructInt(n, module, v.Addr().Interface())
+
+	case reflect.Slice:
+		return decodeSlice(n, module, v)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		if n.kind != nodeInt {
+			return errConvert(n, v)
+		}
+		v.Set(reflect.ValueOf(int(n.num)).Convert(v.Type()))
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		if n.kind != nodeInt || n.num < 0 {
+			return errConvert(n, v)
+		}
+		v.Set(reflect.ValueOf(int(n.num)).Convert(v.Type()))
+		return nil
+
+	case reflect.String:
+		s, err := decodeKeyword(n, v)
+		if err == nil {
+			v.Set(reflect.ValueOf(s).Convert(v.Type()))
+		}
+		return err
+	}
+
+	return errConvert(n, v)
+}
+
+// decodeString decodes the string literal.
+func decodeString(n *node, v reflect.Value) (string, error) {
+	if n.kind != nodeString {
+		return "", errConvert(n, v)
+	}
+	return n.str, nil
+}
+
+// decodeKeyword decodes the string literal or keyword. Keywords
+// are represented as module.Name and decoded as "Name".
+func decodeKeyword(n *node, v reflect.Value) (string, error) {
+	switch n.kind {
+	case nodeString:
+		return n.str, nil
+	case nodeName:
+		if i := strings.LastIndexByte(n.str, '.'); i >= 0 {
+			return n.str[i+1:], nil
+		}
+	}
+
+	return "", errConvert(n, v)
+}
+
+// decodeEnum decodes enum-alike value from the string or keyword,
+// using the supplied parse function.
+//
+// The parse function assumed to return the zero value of the
+// target type if string cannot be decoded.
+func decodeEnum[T comparable](n *node, v reflect.Value,
+	parse func(string) T) error {
+
+	var zero T
+
+	s, err := decodeKeyword(n, v)
+	if err != nil {
+		return err
+	}
+
+	val := parse(s)
+	if val == zero {
+		return fmt.Errorf("%d: %s: invalid %s",
+			n.line, s, reflect.TypeOf(zero))
+	}
+
+	v.Set(reflect.ValueOf(val))
+	return nil
+}
+
+// decodeTextWithLangElement decodes wsscan.TextWithLangElement value.
+//
+// The literal can be either string or wsd.WithLang('text', lang='en').
+func decodeTextWithLangElement(n *node, v reflect.Value) error {
+	var elem wsscan.TextWithLangElement
+
+	switch {
+	case n.kind == nodeString:
+		elem.Text = n.str
+
+	case n.kind == nodeCall && n.str == "wsd.WithLang" &&
+		len(n.items) == 1:
+		text, err := decodeString(n.items[0], v)
+		if err != nil {
+			return err
+		}
+
+		elem.Text = text
+
+		for _, arg := range n.named {
+			switch {
+			case arg.name != "lang":
+				return fmt.Errorf("%d: %s: unknown keyword",
+					arg.val.line, arg.name)
+			case arg.val.kind == nodeNone:
+			default:
+				lang, err := decodeString(arg.val, v)
+				if err != nil {
+					return err
+				}
+				elem.Lang = optional.New(lang)
+			}
+		}
+
+	default:
+		return errConvert(n, v)
+	}
+
+	v.Set(reflect.ValueOf(elem))
+	return nil
+}
+
+// decodeTextWithLangList decodes wsscan.TextWithLangList value.
+func decodeTextWithLangList(n *node, v reflect.Value) error {
+	if n.kind != nodeList {
+		// wsscan.TextWithLangList containing a single
+		// element can be represented by a single value
+		// (not by list of values).
+		var elem wsscan.TextWithLangElement
+		err := decodeTextWithLangElement(n,
+			reflect.ValueOf(&elem).Elem())
+		if err != nil {
+			return err
+		}
+
+		v.Set(reflect.ValueOf(wsscan.TextWithLangList{elem}))
+		return nil
+	}
+
+	return decodeSlice(n, "wsd", v)
+}
+
+// decodeValWithOptions decodes wsscan.ValWithOptions value.
+//
+// The literal can be either the plain value or
+// wsd.WithOptions(value, MustHonor='true', ...). Python writes
+// the later without the module name.
+func decodeValWithOptions(n *node, v wsscan.WithOptions) error {
+	val := n
+	if n.kind == nodeCall &&
+		(n.str == "wsd.WithOptions" || n.str == "WithOptions") {
+		if len(n.items) != 1 {
+			return errConvert(n, reflect.ValueOf(v).Elem())
+		}
+		val = n.items[0]
+	}
+
+	// Obtain reflect.Type and reflect.Value for underlying value
+	t2 := reflect.TypeOf(v.GetValue())
+	v2 := reflect.New(t2).Elem()
+
+	// Decode the value
+	err := decodeValue(val, "wsd", v2)
+	if err != nil {
+		return err
+	}
+
+	// Save the value
+	if !v.SetValue(v2.Interface()) {
+		return errConvert(n, reflect.ValueOf(v).Elem())
+	}
+
+	if val == n {
+		return nil
+	}
+
+	// Decode options
+	setters := map[string]func(optional.Val[wsscan.BooleanElement]){
+		"MustHonor":   v.SetMustHonor,
+		"Override":    v.SetOverride,
+		"UsedDefault": v.SetUsedDefault,
+	}
+
+	for _, arg := range n.named {
+		set := setters[arg.name]
+		if set == nil {
+			err = fmt.Errorf("%d: unknown keyword", arg.val.line)
+			return errDecodeWrap(arg.name, err)
+		}
+
+		if arg.val.kind == nodeNone {
+			continue
+		}
+
+		s, err := decodeKeyword(arg.val, reflect.ValueOf(""))
+		if err != nil {
+			return errDecodeWrap(arg.name, err)
+		}
+
+		set(optional.New(wsscan.BooleanElement(s)))
+	}
+
+	return nil
+}
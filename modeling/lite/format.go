@@ -0,0 +1,150 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Model file format versioning and migrations
+
+package lite
+
+import (
+	"fmt"
+)
+
+// FormatVersion is the current version of the model file format.
+//
+// It is written at the top of the saved model files as the
+// MODEL_FORMAT assignment. Files without this assignment
+// are treated as version 1.
+const FormatVersion = 1
+
+// FormatVersionName is the name of the model file variable,
+// that contains the format version.
+const FormatVersionName = "MODEL_FORMAT"
+
+// CheckFormatVersion verifies that model file of the specified
+// format version can be loaded.
+func CheckFormatVersion(version int) error {
+	return checkFormatVersion(version, FormatVersion)
+}
+
+// checkFormatVersion verifies that the model file format version
+// is not newer than the supported version.
+func checkFormatVersion(version, supported int) error {
+	switch {
+	case version < 1:
+		return fmt.Errorf("%s: invalid version %d",
+			FormatVersionName, version)
+
+	case version > supported:
+		return fmt.Errorf("model format version %d is newer "+
+			"than supported version %d", version, supported)
+	}
+
+	return nil
+}
+
+// Migration upgrades the model data, loaded from the file of the
+// previous format version, to the next version.
+//
+// Migrations are applied by both model readers, this package and
+// the full-featured [modeling.Model.Load], to the loaded data before
+// it is returned or imported into the model, so each format change
+// is handled at the single place.
+type Migration func(md *ModelData) error
+
+// Migrations is the registry of the model format migrations, indexed
+// by the format version they upgrade from. Migration for the version
+// N converts the model data from the version N to the version N+1.
+type Migrations map[int]Migration
+
+// migrations contains the model format migrations.
+//
+// When the FormatVersion is incremented, the migration from the
+// previous version must be added here.
+var migrations = Migrations{}
+
+// Migrate upgrades the model data, loaded from the file of the
+// specified format version, to the current [FormatVersion].
+func Migrate(md *ModelData, version int) error {
+	return migrations.Migrate(md, version, FormatVersion)
+}
+
+// formatMigrate upgrades the model data, loaded by the Read.
+// Tests substitute it to exercise the chain of migrations.
+var formatMigrate = Migrate
+
+// Migrate upgrades the model data, loaded from the file of the
+// specified format version, to the target version, using the
+// migrations from the registry.
+func (migrations Migrations) Migrate(md *ModelData,
+	version, target int) error {
+
+	err := checkFormatVersion(version, target)
+	if err != nil {
+		return err
+	}
+
+	for ; version < target; version++ {
+		step := migrations[version]
+		if step == nil {
+			return fmt.Errorf("model format version %d: "+
+				"migration missed", version)
+		}
+
+		err = step(md)
+		if err != nil {
+			return fmt.Errorf("model format version %d: "+
+				"migration: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+// literalModel is the model file, parsed but not decoded yet.
+type literalModel struct {
+	version int          // Format version
+	stmts   []*statement // Top-level assignments
+}
+
+// statement is the top-level assignment in the model file.
+type statement struct {
+	name string // Assigned name, like escl.scanner
+	val  *node  // Assigned value
+}
+
+// decode decodes the model into the protocol structures.
+//
+// The device configuration (device.config) and localized strings
+// (l10n.strings) are only verified to be literal.
+func (lm *literalModel) decode() (*ModelData, error) {
+	md := &ModelData{}
+
+	for _, stmt := range lm.stmts {
+		val := stmt.val
+		if val.kind == nodeNone {
+			continue
+		}
+
+		var err error
+		switch stmt.name {
+		case "escl.scanner", "escl.caps":
+			err = decodeStruct(val, "escl", &md.ESCLScanCaps)
+		case "wsd.scanner", "wsd.caps":
+			err = decodeStruct(val, "wsd", &md.WSDScanCaps)
+		case "usb.device":
+			err = decodeStruct(val, "usb", &md.USBDevice)
+		case "device.config", "l10n.strings":
+			err = checkLiteralDicts(val)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%d: %s: %w",
+				val.line, stmt.name, err)
+		}
+	}
+
+	return md, nil
+}
@@ -0,0 +1,558 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Read-only model reader
+
+// Package lite reads the MFP model files without the embedded
+// Python interpreter.
+//
+// The model file is the Python script, and the full-featured
+// reader, [modeling.Model.Load], executes it with the cpython.
+// However, models, saved by [modeling.Model.Save], are plain
+// literal assignments:
+//
+//	escl.scanner = escl.ScannerCapabilities(
+//	    Version = '2.62',
+//	    Uuid = UUID('4509a320-00a0-008f-00b6-002507510eca'),
+//	    ...
+//	)
+//
+// This package understands this literal subset (strings, numbers,
+// lists, dicts, keywords and constructor calls) and reconstructs
+// the protocol structures without executing Python. It is enough
+// for tools, that only need to inspect the device capabilities.
+//
+// Files, containing anything outside of the literal subset (hooks,
+// expressions, imports and so on) are refused with the error, that
+// wraps [ErrNotLiteral]. Such files require the full loader.
+//
+// Saved models start with the MODEL_FORMAT assignment, that
+// specifies the file format version ([FormatVersion]). Data, loaded
+// from files of the older versions, is upgraded in memory by the
+// registered migrations (see [Migration]), and files of the newer
+// versions are refused.
+package lite
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/OpenPrinting/go-mfp/modeling/internal/pylex"
+	"github.com/OpenPrinting/go-mfp/modeling/ippread"
+	"github.com/OpenPrinting/go-mfp/proto/escl"
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/proto/usb"
+	"github.com/OpenPrinting/go-mfp/proto/wsscan"
+)
+
+// ErrNotLiteral is returned, when model file contains anything
+// outside of the literal subset, understood by this package.
+var ErrNotLiteral = errors.New(
+	"not a literal model, use the full loader (modeling.Model.Load)")
+
+// ModelData contains protocol structures, read from the model file.
+//
+// Parts, missed in the model, are nil.
+type ModelData struct {
+	IPPPrinterAttrs *ipp.PrinterAttributes             // IPP printer
+	ESCLScanCaps    *escl.ScannerCapabilities          // eSCL scanner
+	WSDScanCaps     *wsscan.GetScannerElementsResponse // WSD scanner
+	USBDevice       *usb.DeviceDescriptor              // USB device
+}
+
+// ParseModelFile parses the model file.
+func ParseModelFile(path string) (*ModelData, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fp.Close()
+
+	return Read(path, fp)
+}
+
+// Read reads the model from the [io.Reader].
+// The filename parameter required for the diagnostics messages.
+//
+// The device configuration (device.config) and localized strings
+// (l10n.strings) parts of the model are verified to be literal,
+// but not returned.
+func Read(filename string, r io.Reader) (*ModelData, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{lx: pylex.NewLexer(string(data))}
+	lm, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", filename, err)
+	}
+
+	err = CheckFormatVersion(lm.version)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	md, err := lm.decode()
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", filename, err)
+	}
+
+	// IPP printer attributes have their own reader
+	md.IPPPrinterAttrs, err = ippread.Read(filename, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// Upgrade the model data of the older format versions
+	err = formatMigrate(md, lm.version)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return md, nil
+}
+
+// nodeKind is the kind of the parsed literal
+type nodeKind int
+
+// Node kinds:
+const (
+	nodeNone   nodeKind = iota // None
+	nodeBool                   // True or False
+	nodeInt                    // Integer number
+	nodeString                 // String
+	nodeList                   // List of nodes
+	nodeDict                   // Dict with string keys
+	nodeName                   // Dotted name, like escl.RGB24
+	nodeCall                   // Call, like escl.Platen(...)
+)
+
+// node is the parsed literal
+type node struct {
+	kind  nodeKind // Node kind
+	line  int      // Line number, for diagnostics
+	b     bool     // nodeBool value
+	num   int64    // nodeInt value
+	str   string   // nodeString value; nodeName/nodeCall name
+	items []*node  // nodeList items, nodeCall positional args
+	named []named  // nodeDict items, nodeCall keyword args
+}
+
+// named is the named node: keyword argument or dict item
+type named struct {
+	name string // Argument name or dict key
+	val  *node  // The value
+}
+
+// String returns the node kind name for diagnostics
+func (n *node) String() string {
+	switch n.kind {
+	case nodeNone:
+		return "None"
+	case nodeBool:
+		return "bool"
+	case nodeInt:
+		return "int"
+	case nodeString:
+		return "str"
+	case nodeList:
+		return "list"
+	case nodeDict:
+		return "dict"
+	}
+
+	return n.str
+}
+
+// parser parses the model file
+type parser struct {
+	lx  *pylex.Lexer // Underlying lexer
+	tok pylex.Token  // Current token
+}
+
+// parse parses the model file
+func (p *parser) parse() (*literalModel, error) {
+	lm := &literalModel{version: 1}
+	err := p.advance()
+
+	for err == nil && p.tok.Type != pylex.EOF {
+		switch {
+		case p.tok.Type == pylex.Newline:
+			err = p.advance()
+
+		case p.tok.Col == 0 && p.tok.Type == pylex.Ident:
+			err = p.parseStatement(lm)
+
+		default:
+			err = p.notLiteral()
+		}
+	}
+
+	return lm, err
+}
+
+// parseStatement parses the top-level assignment
+func (p *parser) parseStatement(lm *literalModel) error {
+	tok := p.tok
+	name, err := p.parseDottedName()
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case "ipp.printer", "ipp.attrs":
+		// Handled by ippread
+		for err == nil && p.tok.Type != pylex.Newline &&
+			p.tok.Type != pylex.EOF {
+			err = p.advance()
+		}
+		return err
+
+	case FormatVersionName, "escl.scanner", "escl.caps",
+		"wsd.scanner", "wsd.caps", "usb.device",
+		"device.config", "l10n.strings":
+
+	default:
+		return fmt.Errorf("%d: %s: %w", tok.Line, name, ErrNotLiteral)
+	}
+
+	if p.tok.Type != pylex.Punct || p.tok.Text != "=" {
+		return p.notLiteral()
+	}
+
+	err = p.advance()
+	if err != nil {
+		return err
+	}
+
+	val, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	if p.tok.Type != pylex.Newline && p.tok.Type != pylex.EOF {
+		return p.notLiteral()
+	}
+
+	if name == FormatVersionName {
+		switch {
+		case val.kind != nodeInt:
+			return fmt.Errorf("%d: %s: unexpected %s",
+				val.line, name, val)
+		case val.num < 1:
+			return fmt.Errorf("%d: %s: invalid version %d",
+				val.line, name, val.num)
+		}
+
+		lm.version = int(val.num)
+		return nil
+	}
+
+	lm.stmts = append(lm.stmts, &statement{name: name, val: val})
+	return nil
+}
+
+// parseValue parses the literal value
+func (p *parser) parseValue() (*node, error) {
+	n := &node{line: p.tok.Line}
+	var err error
+
+	switch {
+	case p.tok.Type == pylex.String:
+		n.kind = nodeString
+		for err == nil && p.tok.Type == pylex.String {
+			n.str += p.tok.Text
+			err = p.advance()
+		}
+
+	case p.tok.Type == pylex.Number,
+		p.tok.Type == pylex.Punct && p.tok.Text == "-":
+		n.kind = nodeInt
+		n.num, err = p.parseInt()
+
+	case p.tok.Type == pylex.Punct && p.tok.Text == "[":
+		n.kind = nodeList
+		n.items, err = p.parseList()
+
+	case p.tok.Type == pylex.Punct && p.tok.Text == "{":
+		n.kind = nodeDict
+		n.named, err = p.parseDict()
+
+	case p.tok.Type == pylex.Ident:
+		tok := p.tok
+		err = p.advance()
+		if err == nil {
+			n, err = p.parseValueAfterIdent(tok)
+		}
+
+	default:
+		err = p.notLiteral()
+	}
+
+	return n, err
+}
+
+// parseList parses the list of values
+func (p *parser) parseList() ([]*node, error) {
+	err := p.advance()
+	items := []*node{}
+
+	for err == nil && p.tok.Text != "]" {
+		var item *node
+		item, err = p.parseValue()
+		if err == nil {
+			items = append(items, item)
+			err = p.expectListSeparator("]")
+		}
+	}
+
+	if err == nil {
+		err = p.advance()
+	}
+
+	return items, err
+}
+
+// parseDict parses the dict. Keys must be strings.
+func (p *parser) parseDict() ([]named, error) {
+	err := p.advance()
+	items := []named{}
+
+	for err == nil && p.tok.Text != "}" {
+		var key string
+		var val *node
+
+		if p.tok.Type != pylex.String {
+			return nil, p.notLiteral()
+		}
+
+		key, err = p.parseString()
+		if err == nil {
+			err = p.expect(":")
+		}
+		if err == nil {
+			val, err = p.parseValue()
+		}
+		if err == nil {
+			items = append(items, named{key, val})
+			err = p.expectListSeparator("}")
+		}
+	}
+
+	if err == nil {
+		err = p.advance()
+	}
+
+	return items, err
+}
+
+// parseArgs parses the call arguments. Positional arguments must
+// precede the keyword arguments.
+func (p *parser) parseArgs() (args []*node, kwargs []named, err error) {
+	err = p.advance()
+
+	for err == nil && p.tok.Text != ")" {
+		var val *node
+
+		if p.tok.Type == pylex.Ident {
+			// Look ahead for keyword argument
+			tok := p.tok
+			err = p.advance()
+			if err == nil && p.tok.Text == "=" {
+				err = p.advance()
+				if err == nil {
+					val, err = p.parseValue()
+				}
+				if err == nil {
+					kwargs = append(kwargs, named{tok.Text, val})
+				}
+			} else if err == nil {
+				// Not a keyword argument, so the
+				// identifier starts the value
+				val, err = p.parseValueAfterIdent(tok)
+				if err == nil && kwargs != nil {
+					err = p.notLiteral()
+				}
+				if err == nil {
+					args = append(args, val)
+				}
+			}
+		} else {
+			val, err = p.parseValue()
+			if err == nil && kwargs != nil {
+				err = p.notLiteral()
+			}
+			if err == nil {
+				args = append(args, val)
+			}
+		}
+
+		if err == nil {
+			err = p.expectListSeparator(")")
+		}
+	}
+
+	if err == nil {
+		err = p.advance()
+	}
+
+	return
+}
+
+// parseValueAfterIdent parses the value, which starts from the
+// identifier token, already consumed.
+func (p *parser) parseValueAfterIdent(tok pylex.Token) (*node, error) {
+	n := &node{line: tok.Line, kind: nodeName, str: tok.Text}
+	var err error
+
+	switch tok.Text {
+	case "None":
+		n.kind = nodeNone
+		return n, nil
+	case "True", "False":
+		n.kind = nodeBool
+		n.b = tok.Text == "True"
+		return n, nil
+	}
+
+	for err == nil && p.tok.Text == "." {
+		err = p.advance()
+		if err == nil && p.tok.Type != pylex.Ident {
+			err = p.notLiteral()
+		}
+		if err == nil {
+			n.str += "." + p.tok.Text
+			err = p.advance()
+		}
+	}
+
+	if err == nil && p.tok.Type == pylex.Punct && p.tok.Text == "(" {
+		n.kind = nodeCall
+		n.items, n.named, err = p.parseArgs()
+	}
+
+	return n, err
+}
+
+// parseDottedName parses the dotted name, like escl.RGB24
+func (p *parser) parseDottedName() (string, error) {
+	tok := p.tok
+	err := p.advance()
+	if err != nil {
+		return "", err
+	}
+
+	n, err := p.parseValueAfterIdent(tok)
+	if err == nil && n.kind != nodeName {
+		err = fmt.Errorf("%d: %s: %w", tok.Line, tok, ErrNotLiteral)
+	}
+
+	return n.str, err
+}
+
+// parseInt parses the integer number, possibly negative
+func (p *parser) parseInt() (int64, error) {
+	neg := p.tok.Text == "-"
+	if neg {
+		if err := p.advance(); err != nil {
+			return 0, err
+		}
+	}
+
+	if p.tok.Type != pylex.Number {
+		return 0, p.notLiteral()
+	}
+
+	v, err := strconv.ParseInt(p.tok.Text, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%d: %s: invalid number",
+			p.tok.Line, p.tok)
+	}
+
+	if neg {
+		v = -v
+	}
+
+	return v, p.advance()
+}
+
+// parseString parses the string. Adjacent string literals are
+// concatenated, as Python does.
+func (p *parser) parseString() (string, error) {
+	var s string
+	var err error
+	for err == nil && p.tok.Type == pylex.String {
+		s += p.tok.Text
+		err = p.advance()
+	}
+
+	return s, err
+}
+
+// expect consumes the expected punctuation token
+func (p *parser) expect(text string) error {
+	if p.tok.Type != pylex.Punct || p.tok.Text != text {
+		return p.notLiteral()
+	}
+	return p.advance()
+}
+
+// expectListSeparator consumes the comma between list items.
+// The comma may be omitted before the closing bracket.
+func (p *parser) expectListSeparator(closing string) error {
+	if p.tok.Text == closing {
+		return nil
+	}
+	return p.expect(",")
+}
+
+// advance reads the next token
+func (p *parser) advance() (err error) {
+	p.tok, err = p.lx.Next()
+	return
+}
+
+// notLiteral returns the error for the token outside of the
+// literal subset.
+func (p *parser) notLiteral() error {
+	return fmt.Errorf("%d: unexpected %s: %w", p.tok.Line, p.tok,
+		ErrNotLiteral)
+}
+
+// checkLiteralDicts verifies that value is the constructor call with
+// the keyword arguments, that are strings, numbers, booleans or dicts
+// of them, as used by the device.config and l10n.strings.
+func checkLiteralDicts(n *node) error {
+	if n.kind != nodeCall || len(n.items) != 0 {
+		return fmt.Errorf("%s: unexpected value", n)
+	}
+
+	for _, arg := range n.named {
+		switch arg.val.kind {
+		case nodeNone, nodeBool, nodeInt, nodeString:
+		case nodeDict:
+			for _, item := range arg.val.named {
+				switch item.val.kind {
+				case nodeBool, nodeInt, nodeString:
+				default:
+					return fmt.Errorf("%s[%q]: unexpected %s",
+						arg.name, item.name, item.val)
+				}
+			}
+		default:
+			return fmt.Errorf("%s: unexpected %s", arg.name, arg.val)
+		}
+	}
+
+	return nil
+}
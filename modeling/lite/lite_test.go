@@ -0,0 +1,378 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Read-only model reader test
+
+package lite
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/proto/escl"
+	"github.com/OpenPrinting/go-mfp/proto/wsscan"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// TestParseModelFile tests ParseModelFile on the example model
+func TestParseModelFile(t *testing.T) {
+	file := filepath.Join("..", "examples", "Kyocera-ECOSYS-M2040dn.py")
+
+	md, err := ParseModelFile(file)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	switch {
+	case md.IPPPrinterAttrs == nil:
+		t.Errorf("missed IPP printer attributes")
+	case md.ESCLScanCaps == nil:
+		t.Errorf("missed eSCL scanner capabilities")
+	case md.USBDevice == nil:
+		t.Errorf("missed USB device descriptor")
+	case md.WSDScanCaps != nil:
+		t.Errorf("unexpected WSD scanner capabilities")
+	}
+
+	if t.Failed() {
+		return
+	}
+
+	caps := md.ESCLScanCaps
+	if caps.Version != escl.MakeVersion(2, 62) {
+		t.Errorf("eSCL Version: %s", caps.Version)
+	}
+
+	if caps.UUID == nil ||
+		(*caps.UUID).String() != "4509a320-00a0-008f-00b6-002507510eca" {
+		t.Errorf("eSCL Uuid: %v", caps.UUID)
+	}
+
+	if caps.Platen == nil || caps.Platen.PlatenInputCaps == nil {
+		t.Fatalf("eSCL Platen: missed")
+	}
+
+	intents := caps.Platen.PlatenInputCaps.SupportedIntents
+	if len(intents) == 0 || intents[0] != escl.Document {
+		t.Errorf("eSCL SupportedIntents: %v", intents)
+	}
+}
+
+// TestRead tests Read on the literals with special representation
+func TestRead(t *testing.T) {
+	src := "" +
+		"wsd.scanner = wsd.GetScannerElementsResponse(\n" +
+		"    ScannerElements = [\n" +
+		"        wsd.ScannerElemData(\n" +
+		"            Name = wsd.ScannerDescription,\n" +
+		"            Valid = 'true',\n" +
+		"            ScannerDescription = wsd.ScannerDescription(\n" +
+		"                ScannerInfo = 'Info',\n" +
+		"                ScannerName = [\n" +
+		"                    wsd.WithLang('Name', lang='en'),\n" +
+		"                    wsd.WithLang('Name', lang='de'),\n" +
+		"                ],\n" +
+		"            ),\n" +
+		"        ),\n" +
+		"        wsd.ScannerElemData(\n" +
+		"            Name = wsd.DefaultScanTicket,\n" +
+		"            Valid = 'true',\n" +
+		"            DefaultScanTicket = wsd.ScanTicket(\n" +
+		"                JobDescription = wsd.JobDescription(\n" +
+		"                    JobName = 'job',\n" +
+		"                    JobOriginatingUserName = 'user',\n" +
+		"                ),\n" +
+		"                DocumentParameters = wsd.DocumentParameters(\n" +
+		"                    CompressionQualityFactor = " +
+		"WithOptions(20, MustHonor='true'),\n" +
+		"                ),\n" +
+		"            ),\n" +
+		"        ),\n" +
+		"    ],\n" +
+		")\n" +
+		"\n" +
+		"usb.device = None\n" +
+		"\n" +
+		"device.config = device.DeviceConfig(\n" +
+		"    Protocols = {'wsd': False},\n" +
+		"    Ports = {'ipp': 631},\n" +
+		"    TLS = True,\n" +
+		")\n"
+
+	md, err := Read("test", strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if md.IPPPrinterAttrs != nil || md.ESCLScanCaps != nil ||
+		md.USBDevice != nil {
+		t.Errorf("unexpected model parts")
+	}
+
+	elems := md.WSDScanCaps.ScannerElements
+	if len(elems) != 2 {
+		t.Fatalf("ScannerElements: expected 2, present %d", len(elems))
+	}
+
+	desc := elems[0].ScannerDescription
+	if desc == nil {
+		t.Fatalf("ScannerDescription: missed")
+	}
+
+	info := desc.ScannerInfo
+	if len(info) != 1 || info[0].Text != "Info" || info[0].Lang != nil {
+		t.Errorf("ScannerInfo: %#v", info)
+	}
+
+	name := desc.ScannerName
+	if len(name) != 2 || name[1].Lang == nil || *name[1].Lang != "de" {
+		t.Errorf("ScannerName: %#v", name)
+	}
+
+	ticket := elems[1].DefaultScanTicket
+	if ticket == nil || ticket.DocumentParameters == nil {
+		t.Fatalf("DefaultScanTicket: missed")
+	}
+
+	cqf := ticket.DocumentParameters.CompressionQualityFactor
+	switch {
+	case cqf == nil:
+		t.Errorf("CompressionQualityFactor: missed")
+	case cqf.Val != 20:
+		t.Errorf("CompressionQualityFactor: %d", cqf.Val)
+	case cqf.MustHonor == nil || *cqf.MustHonor != wsscan.BooleanElement("true"):
+		t.Errorf("CompressionQualityFactor: MustHonor missed")
+	}
+}
+
+// TestReadErrors tests Read errors
+func TestReadErrors(t *testing.T) {
+	type testData struct {
+		src        string // Model source
+		err        string // Expected error
+		notLiteral bool   // Error must wrap ErrNotLiteral
+	}
+
+	tests := []testData{
+		{
+			src: "escl.scanner = None\n" +
+				"\n" +
+				"def escl_onScanJobsRequest (q, rq):\n" +
+				"    pass\n",
+			err:        "test:3: def: " + ErrNotLiteral.Error(),
+			notLiteral: true,
+		},
+
+		{
+			src:        "from uuid import UUID\n",
+			err:        "test:1: from: " + ErrNotLiteral.Error(),
+			notLiteral: true,
+		},
+
+		{
+			src:        "usb.device = 1 + 2\n",
+			err:        `test:1: unexpected "+": ` + ErrNotLiteral.Error(),
+			notLiteral: true,
+		},
+
+		{
+			src: "usb.device = make_device()\n",
+			err: "test:1: usb.device: DeviceDescriptor: " +
+				"1: can't convert make_device to usb.DeviceDescriptor",
+			notLiteral: false,
+		},
+
+		{
+			src: "usb.device = usb.DeviceDescriptor(\n" +
+				"    BCDUSB = 'x',\n" +
+				")\n",
+			err: "test:1: usb.device: DeviceDescriptor.BCDUSB: " +
+				`"x": invalid USB version`,
+		},
+
+		{
+			src: "usb.device = usb.DeviceDescriptor(\n" +
+				"    Unknown = 1,\n" +
+				")\n",
+			err: "test:1: usb.device: DeviceDescriptor.Unknown: " +
+				"2: unknown keyword",
+		},
+
+		{
+			src: "escl.scanner = escl.ScannerCapabilities(\n" +
+				"    Platen = escl.Platen(\n" +
+				"        PlatenInputCaps = escl.InputSourceCaps(\n" +
+				"            SupportedIntents = [escl.Unknown],\n" +
+				"        ),\n" +
+				"    ),\n" +
+				")\n",
+			err: "test:1: escl.scanner: ScannerCapabilities.Platen." +
+				"PlatenInputCaps.SupportedIntents[0]: " +
+				"4: Unknown: invalid escl.Intent",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := Read("test", strings.NewReader(test.src))
+
+		errstr := ""
+		if err != nil {
+			errstr = err.Error()
+		}
+
+		if errstr != test.err {
+			t.Errorf("%q:\nerror expected: %s\nerror present:  %s",
+				test.src, test.err, errstr)
+		}
+
+		if errors.Is(err, ErrNotLiteral) != test.notLiteral {
+			t.Errorf("%q: ErrNotLiteral expected: %v",
+				test.src, test.notLiteral)
+		}
+	}
+}
+
+// TestReadFormatVersion tests Read with the MODEL_FORMAT version
+func TestReadFormatVersion(t *testing.T) {
+	src := "MODEL_FORMAT = 1\n" +
+		"usb.device = None\n"
+
+	_, err := Read("test", strings.NewReader(src))
+	if err != nil {
+		t.Errorf("%s", err)
+	}
+
+	src = "MODEL_FORMAT = 2\n" +
+		"usb.device = None\n"
+
+	_, err = Read("test", strings.NewReader(src))
+	errstr := ""
+	if err != nil {
+		errstr = err.Error()
+	}
+
+	expected := "test: model format version 2 is newer " +
+		"than supported version 1"
+	if errstr != expected {
+		t.Errorf("too new version:\nerror expected: %s\n"+
+			"error present:  %s", expected, errstr)
+	}
+
+	src = "MODEL_FORMAT = '1'\n"
+	_, err = Read("test", strings.NewReader(src))
+	errstr = ""
+	if err != nil {
+		errstr = err.Error()
+	}
+
+	expected = "test:1: MODEL_FORMAT: unexpected str"
+	if errstr != expected {
+		t.Errorf("invalid version:\nerror expected: %s\n"+
+			"error present:  %s", expected, errstr)
+	}
+}
+
+// TestMigrate tests loading of the version 1 model file through
+// the chain of migrations
+func TestMigrate(t *testing.T) {
+	// The example model has no MODEL_FORMAT, i.e., it is version 1
+	file := filepath.Join("..", "examples", "Kyocera-ECOSYS-M2040dn.py")
+
+	// Each step marks the MakeAndModel, so the order of steps
+	// can be verified. The second step also drops the USB device
+	// descriptor.
+	var steps []int
+	testMigrations := Migrations{
+		1: func(md *ModelData) error {
+			steps = append(steps, 1)
+			if md.ESCLScanCaps == nil {
+				return errors.New("eSCL scanner capabilities missed")
+			}
+
+			caps := md.ESCLScanCaps
+			caps.MakeAndModel = optional.New(
+				optional.Get(caps.MakeAndModel) + " v2")
+			return nil
+		},
+
+		2: func(md *ModelData) error {
+			steps = append(steps, 2)
+			caps := md.ESCLScanCaps
+			caps.MakeAndModel = optional.New(
+				optional.Get(caps.MakeAndModel) + " v3")
+			md.USBDevice = nil
+			return nil
+		},
+	}
+
+	save := formatMigrate
+	defer func() { formatMigrate = save }()
+
+	formatMigrate = func(md *ModelData, version int) error {
+		return testMigrations.Migrate(md, version, 3)
+	}
+
+	md, err := ParseModelFile(file)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if diff := testutils.Diff([]int{1, 2}, steps); diff != "" {
+		t.Errorf("migration steps:\n%s", diff)
+	}
+
+	model := optional.Get(md.ESCLScanCaps.MakeAndModel)
+	if model != "Kyocera ECOSYS M2040dn v2 v3" {
+		t.Errorf("MakeAndModel: %q", model)
+	}
+
+	if md.USBDevice != nil {
+		t.Errorf("USB device descriptor: not migrated")
+	}
+
+	// Missed migration
+	errstr := ""
+	err = Migrations{1: testMigrations[1]}.Migrate(md, 1, 3)
+	if err != nil {
+		errstr = err.Error()
+	}
+
+	expected := "model format version 2: migration missed"
+	if errstr != expected {
+		t.Errorf("missed migration:\nerror expected: %s\n"+
+			"error present:  %s", expected, errstr)
+	}
+
+	// Failed migration
+	errstr = ""
+	err = Migrations{1: testMigrations[1]}.Migrate(&ModelData{}, 1, 2)
+	if err != nil {
+		errstr = err.Error()
+	}
+
+	expected = "model format version 1: migration: " +
+		"eSCL scanner capabilities missed"
+	if errstr != expected {
+		t.Errorf("failed migration:\nerror expected: %s\n"+
+			"error present:  %s", expected, errstr)
+	}
+
+	// Too new version
+	errstr = ""
+	err = testMigrations.Migrate(md, 4, 3)
+	if err != nil {
+		errstr = err.Error()
+	}
+
+	expected = "model format version 4 is newer than supported version 3"
+	if errstr != expected {
+		t.Errorf("too new version:\nerror expected: %s\n"+
+			"error present:  %s", expected, errstr)
+	}
+}
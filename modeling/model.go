@@ -9,12 +9,16 @@
 package modeling
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/OpenPrinting/go-mfp/cpython"
 	"github.com/OpenPrinting/go-mfp/internal/assert"
+	"github.com/OpenPrinting/go-mfp/modeling/lite"
 	"github.com/OpenPrinting/go-mfp/proto/escl"
 	"github.com/OpenPrinting/go-mfp/proto/ipp"
 	"github.com/OpenPrinting/go-mfp/proto/usb"
@@ -23,7 +27,7 @@ import (
 
 // Model defines the whole characteristics of the MFP device being
 // modeled, including the IPP printer attributes, eSCL and WSD
-// scanner capabilities, scripting hooks, used to modify device
+// scanner capabilities, device configuration, scripting hooks, used to modify device
 // behavior and the Python interpreter instance, used to execute
 // these hooks.
 type Model struct {
@@ -37,6 +41,12 @@ type Model struct {
 	// USB stuff
 	usbDevice *usb.DeviceDescriptor
 
+	// Device configuration
+	deviceConfig *DeviceConfig
+
+	// Localized strings
+	l10n *Localization
+
 	// Modules
 	modHelpers *cpython.Object // helpers.py
 	modQuery   *cpython.Object // query.py
@@ -44,6 +54,8 @@ type Model struct {
 	modEscl    *cpython.Object // escl.py
 	modWSScan  *cpython.Object // wsd.py
 	modUSB     *cpython.Object // usb.py
+	modDevice  *cpython.Object // device.py
+	modL10n    *cpython.Object // l10n.py
 
 	// Important Python class constructors
 	clsHTTPMessage     *cpython.Object // query.HTTPMessage
@@ -56,13 +68,24 @@ type Model struct {
 
 	// eSCL state
 	esclScanSettings escl.ScanSettings
+
+	// Run Python GC after each hook call
+	gcAfterHooks bool
 }
 
 // NewModel creates a new Model with empty printer/scanner parameters.
 // Use [Model.Close] to release resources owned by the Model.
+//
+// If Python is not available ([cpython.ErrNotAvailable]), the Model
+// is created without the Python interpreter. See package documentation
+// for details.
 func NewModel() (*Model, error) {
 	// Create Python interpreter
 	py, err := cpython.NewPython()
+	if errors.Is(err, cpython.ErrNotAvailable{}) {
+		return &Model{}, nil
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +136,16 @@ func NewModel() (*Model, error) {
 		return nil, err
 	}
 
+	model.modDevice = py.Load(embedPyDevice, "device", "device.py")
+	if err := model.modDevice.Err(); err != nil {
+		return nil, err
+	}
+
+	model.modL10n = py.Load(embedPyL10n, "l10n", "l10n.py")
+	if err := model.modL10n.Err(); err != nil {
+		return nil, err
+	}
+
 	// Load commonly used class constructors
 	model.clsQuery = py.Eval("query.Query")
 	if err := model.clsQuery.Err(); err != nil {
@@ -140,8 +173,10 @@ func NewModel() (*Model, error) {
 // Close closes the Model and releases all resources associated
 // with it.
 func (model *Model) Close() {
-	model.py.Close()
-	model.py = nil
+	if model.py != nil {
+		model.py.Close()
+		model.py = nil
+	}
 }
 
 // Reset resets the Modal into its initial state.
@@ -151,14 +186,21 @@ func (model *Model) Reset() error {
 		return err
 	}
 
-	model.py.Close()
+	if model.py != nil {
+		model.py.Close()
+	}
+
 	*model = *model2
 	return nil
 }
 
 // Write writes model into the [io.Writer]
 func (model *Model) Write(w io.Writer) (err error) {
-	var ipp, escl, wsd, usb string
+	if model.py == nil {
+		return cpython.ErrNotAvailable{}
+	}
+
+	var ipp, escl, wsd, usb, device, l10n string
 
 	// Format parts
 	if model.ippPrinterAttrs != nil {
@@ -193,6 +235,22 @@ func (model *Model) Write(w io.Writer) (err error) {
 		}
 	}
 
+	if model.deviceConfig != nil {
+		obj := deviceExport(model.py, model.deviceConfig)
+		device, err = formatPython(obj)
+		if err != nil {
+			return
+		}
+	}
+
+	if model.l10n != nil {
+		obj := l10nExport(model.py, model.l10n)
+		l10n, err = formatPython(obj)
+		if err != nil {
+			return
+		}
+	}
+
 	// Expand callback
 	expand := func(name string) string {
 		switch name {
@@ -204,6 +262,12 @@ func (model *Model) Write(w io.Writer) (err error) {
 			return wsd
 		case "USB":
 			return usb
+		case "DEVICE":
+			return device
+		case "L10N":
+			return l10n
+		case "FORMAT":
+			return strconv.Itoa(lite.FormatVersion)
 		}
 
 		return ""
@@ -227,6 +291,10 @@ func (model *Model) Write(w io.Writer) (err error) {
 			skip = model.wsdScanCaps == nil
 		case strings.HasPrefix(t, "#-usb"):
 			skip = model.usbDevice == nil
+		case strings.HasPrefix(t, "#-device"):
+			skip = model.deviceConfig == nil
+		case strings.HasPrefix(t, "#-l10n"):
+			skip = model.l10n == nil
 		case strings.HasPrefix(t, "#-"):
 			skip = false
 		default:
@@ -246,6 +314,10 @@ func (model *Model) Write(w io.Writer) (err error) {
 // Read reads model from the [io.Reader]
 // The filename parameter required for the diagnostics messages.
 func (model *Model) Read(filename string, r io.Reader) error {
+	if model.py == nil {
+		return cpython.ErrNotAvailable{}
+	}
+
 	data, err := io.ReadAll(r)
 	if err != nil {
 		return err
@@ -256,6 +328,11 @@ func (model *Model) Read(filename string, r io.Reader) error {
 		return err
 	}
 
+	version, err := model.formatVersion()
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
 	err = model.ippLoad()
 	if err != nil {
 		return err
@@ -276,9 +353,98 @@ func (model *Model) Read(filename string, r io.Reader) error {
 		return err
 	}
 
+	err = model.migrate(version)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	err = model.deviceLoad()
+	if err != nil {
+		return err
+	}
+
+	err = model.l10nLoad()
+	if err != nil {
+		return err
+	}
+
+	// Model loading creates a lot of temporary Python objects.
+	// Collect them now, at the safe point.
+	_, err = model.py.GC()
+	return err
+}
+
+// formatVersion returns the model file format version and verifies
+// that it is supported.
+//
+// Files without the version are treated as version 1.
+func (model *Model) formatVersion() (int, error) {
+	obj := model.py.GetGlobal(lite.FormatVersionName)
+	switch {
+	case obj.NotFound():
+		return 1, nil
+	case obj.Err() != nil:
+		return 0, fmt.Errorf("%s: %w", lite.FormatVersionName, obj.Err())
+	}
+
+	version, err := obj.Int()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", lite.FormatVersionName, err)
+	}
+
+	err = lite.CheckFormatVersion(int(version))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(version), nil
+}
+
+// formatMigrate upgrades the model data, loaded by the Model.Read.
+// Tests substitute it to exercise the chain of migrations.
+var formatMigrate = lite.Migrate
+
+// migrate upgrades the protocol structures, loaded from the model
+// file of the specified format version, to the current format
+// version. Migrations are shared with the lite reader (see
+// [lite.Migration]).
+func (model *Model) migrate(version int) error {
+	md := &lite.ModelData{
+		IPPPrinterAttrs: model.ippPrinterAttrs,
+		ESCLScanCaps:    model.esclScanCaps,
+		WSDScanCaps:     model.wsdScanCaps,
+		USBDevice:       model.usbDevice,
+	}
+
+	err := formatMigrate(md, version)
+	if err != nil {
+		return err
+	}
+
+	model.ippPrinterAttrs = md.IPPPrinterAttrs
+	model.esclScanCaps = md.ESCLScanCaps
+	model.wsdScanCaps = md.WSDScanCaps
+	model.usbDevice = md.USBDevice
+
 	return nil
 }
 
+// SetGCAfterHooks enables or disables running the Python garbage
+// collection after each call of the model hooks.
+//
+// It makes garbage collection pauses deterministic, at the cost
+// of some per-request overhead.
+func (model *Model) SetGCAfterHooks(enable bool) {
+	model.gcAfterHooks = enable
+}
+
+// hookDone must be called after each call of the model hook.
+func (model *Model) hookDone() {
+	if model.gcAfterHooks && model.py != nil {
+		model.py.GC()
+	}
+}
+
 // Save writes model into the disk file.
 func (model *Model) Save(file string) error {
 	// Open the file
@@ -10,6 +10,9 @@
 # This is the generated MFP model file.
 # You probably need to edit it appropriately before use.
 
+# Model file format version
+MODEL_FORMAT = $FORMAT
+
 #-ipp
 # IPP printer attributes:
 ipp.printer = $IPP
@@ -36,3 +39,10 @@ wsd.scanner = $WSD
 # USB device descriptor
 usb.device = $USB
 
+#-device
+# Device configuration
+device.config = $DEVICE
+
+#-l10n
+# Localized strings
+l10n.strings = $L10N
@@ -1,3 +1,5 @@
+//go:build !nocpython
+
 // MFP - Miulti-Function Printers and scanners toolkit
 // Printer and scanner modeling.
 //
@@ -10,12 +12,17 @@ package modeling
 
 import (
 	"bytes"
+	"fmt"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/OpenPrinting/go-mfp/cpython"
 	"github.com/OpenPrinting/go-mfp/internal/assert"
 	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/modeling/ippread"
+	"github.com/OpenPrinting/go-mfp/modeling/lite"
 	"github.com/OpenPrinting/go-mfp/proto/escl"
 	"github.com/OpenPrinting/go-mfp/proto/ipp"
 	"github.com/OpenPrinting/go-mfp/proto/usb"
@@ -779,3 +786,268 @@ func TestWSDValWithOptinsDecodeErrors(t *testing.T) {
 		}
 	}
 }
+
+// TestIPPReadParity verifies that the cpython-less ippread package
+// reads IPP printer attributes from the model exactly as the Model does.
+func TestIPPReadParity(t *testing.T) {
+	file := filepath.Join("examples", "Kyocera-ECOSYS-M2040dn.py")
+
+	model, err := NewModel()
+	assert.NoError(err)
+
+	defer model.Close()
+
+	err = model.Load(file)
+	if err != nil {
+		t.Fatalf("Model.Load: %s", err)
+	}
+
+	prn, err := ippread.Load(file)
+	if err != nil {
+		t.Fatalf("ippread.Load: %s", err)
+	}
+
+	expected := model.GetIPPPrinterAttrs().RawAttrs().All()
+	present := prn.RawAttrs().All()
+
+	if !expected.Equal(present) {
+		t.Errorf("ippread.Load: attributes mismatch")
+	}
+}
+
+// TestLiteRoundTrip verifies that the cpython-less lite package
+// reads the model, saved by the Model, exactly as the Model does.
+func TestLiteRoundTrip(t *testing.T) {
+	// Prepare the rich model
+	var msg goipp.Message
+	err := msg.DecodeBytes(testutils.Kyocera.ECOSYS.M2040dn.
+		IPP.PrinterAttributes)
+	assert.NoError(err)
+
+	pa, err := ipp.DecodePrinterAttributes(msg.Printer, nil)
+	assert.NoError(err)
+
+	xml, err := xmldoc.Decode(escl.NsMap, bytes.NewReader(testutils.
+		Kyocera.ECOSYS.M2040dn.ESCL.ScannerCapabilities))
+	assert.NoError(err)
+
+	esclCaps, err := escl.DecodeScannerCapabilities(xml)
+	assert.NoError(err)
+
+	xml, err = xmldoc.Decode(wsscan.NsMap, bytes.NewReader(testutils.
+		Kyocera.ECOSYS.M2040dn.WSD.GetScannerElementsResponse))
+	assert.NoError(err)
+
+	wsdMsg, err := wsscan.DecodeMessage(xml)
+	assert.NoError(err)
+
+	wsdCaps := wsdMsg.Body.(*wsscan.GetScannerElementsResponse)
+
+	// Add localized text and value with options, which have
+	// special representation in the model
+	for _, elem := range wsdCaps.ScannerElements {
+		if desc := elem.ScannerDescription; desc != nil {
+			desc.ScannerInfo = wsscan.TextWithLangList{
+				{Text: "Scanner", Lang: optional.New("en")},
+				{Text: "Scanner", Lang: optional.New("de")},
+			}
+		}
+
+		if ticket := elem.DefaultScanTicket; ticket != nil {
+			params := ticket.DocumentParameters
+			if params != nil && params.CompressionQualityFactor != nil {
+				params.CompressionQualityFactor.MustHonor =
+					optional.New(wsscan.BooleanElement("true"))
+			}
+		}
+	}
+
+	model, err := NewModel()
+	assert.NoError(err)
+
+	defer model.Close()
+
+	model.SetIPPPrinterAttrs(pa)
+	model.SetESCLScanCaps(esclCaps)
+	model.SetWSDScanCaps(wsdCaps)
+	model.SetUSBDeviceDescriptor(
+		&testutils.Kyocera.ECOSYS.M2040dn.USB.DeviceDescriptor)
+	model.SetDeviceConfig(&DeviceConfig{
+		Protocols: map[string]bool{ProtoWSD: false},
+		Paths:     map[string]string{ProtoIPP: "/ipp/printer"},
+		TLS:       true,
+	})
+	model.SetLocalization(&Localization{
+		Default:     "en",
+		PrinterInfo: Localized{"en": "Printer", "de": "Drucker"},
+	})
+
+	// Save with the Model, parse with the lite
+	file := filepath.Join(t.TempDir(), "model.py")
+	err = model.Save(file)
+	if err != nil {
+		t.Fatalf("Model.Save: %s", err)
+	}
+
+	md, err := lite.ParseModelFile(file)
+	if err != nil {
+		t.Fatalf("lite.ParseModelFile: %s", err)
+	}
+
+	// Compare
+	if md.IPPPrinterAttrs == nil {
+		t.Errorf("lite: missed IPP printer attributes")
+	} else {
+		attrs := pa.RawAttrs().All()
+		attrs2 := md.IPPPrinterAttrs.RawAttrs().All()
+		if !attrs.Equal(attrs2) {
+			diff := testutils.IPPDiffAttributes("expected", attrs,
+				"present", attrs2)
+			t.Errorf("lite: IPP printer attributes:\n%s", diff)
+		}
+	}
+
+	if diff := testutils.Diff(esclCaps, md.ESCLScanCaps); diff != "" {
+		t.Errorf("lite: eSCL scanner capabilities:\n%s", diff)
+	}
+
+	if diff := testutils.Diff(wsdCaps, md.WSDScanCaps); diff != "" {
+		t.Errorf("lite: WSD scanner capabilities:\n%s", diff)
+	}
+
+	if diff := testutils.Diff(model.GetUSBDeviceDescriptor(),
+		md.USBDevice); diff != "" {
+		t.Errorf("lite: USB device descriptor:\n%s", diff)
+	}
+}
+
+// TestModelFormatVersion tests MODEL_FORMAT handling by the Model
+func TestModelFormatVersion(t *testing.T) {
+	model, err := NewModel()
+	assert.NoError(err)
+
+	defer model.Close()
+
+	// Saved model contains the current version
+	buf := &bytes.Buffer{}
+	model.SetDeviceConfig(&DeviceConfig{TLS: true})
+	err = model.Write(buf)
+	if err != nil {
+		t.Fatalf("Model.Write: %s", err)
+	}
+
+	expected := fmt.Sprintf("\nMODEL_FORMAT = %d\n", lite.FormatVersion)
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("Model.Write: %q missed", strings.TrimSpace(expected))
+	}
+
+	err = model.Reset()
+	assert.NoError(err)
+
+	err = model.Read("test", buf)
+	if err != nil {
+		t.Errorf("Model.Read: %s", err)
+	}
+
+	// Too new version is rejected
+	err = model.Reset()
+	assert.NoError(err)
+
+	src := fmt.Sprintf("MODEL_FORMAT = %d\n", lite.FormatVersion+1)
+	err = model.Read("test", strings.NewReader(src))
+
+	errstr := ""
+	if err != nil {
+		errstr = err.Error()
+	}
+
+	expected = fmt.Sprintf("test: model format version %d is newer "+
+		"than supported version %d",
+		lite.FormatVersion+1, lite.FormatVersion)
+
+	if errstr != expected {
+		t.Errorf("too new version:\nerror expected: %s\n"+
+			"error present:  %s", expected, errstr)
+	}
+}
+
+// TestModelMigrate tests loading of the version 1 model file
+// through the chain of migrations
+func TestModelMigrate(t *testing.T) {
+	// The example model has no MODEL_FORMAT, i.e., it is version 1
+	file := filepath.Join("examples", "Kyocera-ECOSYS-M2040dn.py")
+
+	// Each step marks the MakeAndModel, so the order of steps
+	// can be verified. The second step also drops the USB device
+	// descriptor.
+	var steps []int
+	testMigrations := lite.Migrations{
+		1: func(md *lite.ModelData) error {
+			steps = append(steps, 1)
+			caps := md.ESCLScanCaps
+			caps.MakeAndModel = optional.New(
+				optional.Get(caps.MakeAndModel) + " v2")
+			return nil
+		},
+
+		2: func(md *lite.ModelData) error {
+			steps = append(steps, 2)
+			caps := md.ESCLScanCaps
+			caps.MakeAndModel = optional.New(
+				optional.Get(caps.MakeAndModel) + " v3")
+			md.USBDevice = nil
+			return nil
+		},
+	}
+
+	save := formatMigrate
+	defer func() { formatMigrate = save }()
+
+	formatMigrate = func(md *lite.ModelData, version int) error {
+		return testMigrations.Migrate(md, version, 3)
+	}
+
+	model, err := NewModel()
+	assert.NoError(err)
+
+	defer model.Close()
+
+	err = model.Load(file)
+	if err != nil {
+		t.Fatalf("Model.Load: %s", err)
+	}
+
+	if diff := testutils.Diff([]int{1, 2}, steps); diff != "" {
+		t.Errorf("migration steps:\n%s", diff)
+	}
+
+	makeModel := optional.Get(model.GetESCLScanCaps().MakeAndModel)
+	if makeModel != "Kyocera ECOSYS M2040dn v2 v3" {
+		t.Errorf("MakeAndModel: %q", makeModel)
+	}
+
+	if model.GetUSBDeviceDescriptor() != nil {
+		t.Errorf("USB device descriptor: not migrated")
+	}
+
+	// Migration error is returned by the Model.Read
+	err = model.Reset()
+	assert.NoError(err)
+
+	formatMigrate = func(md *lite.ModelData, version int) error {
+		return lite.Migrations{}.Migrate(md, version, 2)
+	}
+
+	err = model.Load(file)
+
+	errstr := ""
+	if err != nil {
+		errstr = err.Error()
+	}
+
+	expected := file + ": model format version 1: migration missed"
+	if errstr != expected {
+		t.Errorf("missed migration:\nerror expected: %s\n"+
+			"error present:  %s", expected, errstr)
+	}
+}
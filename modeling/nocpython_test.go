@@ -0,0 +1,65 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Model without Python test
+
+package modeling
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/cpython"
+	"github.com/OpenPrinting/go-mfp/modeling/defaults"
+	"github.com/OpenPrinting/go-mfp/proto/escl"
+)
+
+// testNeedPython skips the test, if model has no Python interpreter,
+// i.e., when built with the nocpython tag.
+func testNeedPython(t *testing.T, model *Model) {
+	t.Helper()
+	if model.py == nil {
+		t.Skip("Python is not available")
+	}
+}
+
+// TestModelWithoutPython tests the Model, created without Python
+// interpreter. Under the normal build, it is skipped.
+func TestModelWithoutPython(t *testing.T) {
+	model, err := NewModel()
+	if err != nil {
+		t.Fatalf("NewModel: %s", err)
+	}
+	defer model.Close()
+
+	if model.py != nil {
+		t.Skip("Python is available")
+	}
+
+	// Go-native data must work
+	caps := escl.FromAbstractScannerCapabilities(escl.DefaultVersion,
+		defaults.ScannerCapabilities())
+	model.SetESCLScanCaps(caps)
+
+	if model.GetESCLScanCaps() != caps {
+		t.Errorf("GetESCLScanCaps: value not saved")
+	}
+
+	// Python export and import must fail
+	err = model.Write(&bytes.Buffer{})
+	if !errors.Is(err, cpython.ErrNotAvailable{}) {
+		t.Errorf("Model.Write: expected %v, present %v",
+			cpython.ErrNotAvailable{}, err)
+	}
+
+	err = model.Read("test", strings.NewReader(""))
+	if !errors.Is(err, cpython.ErrNotAvailable{}) {
+		t.Errorf("Model.Read: expected %v, present %v",
+			cpython.ErrNotAvailable{}, err)
+	}
+}
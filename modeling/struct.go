@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/OpenPrinting/go-mfp/cpython"
 	"github.com/OpenPrinting/go-mfp/internal/assert"
@@ -160,6 +161,8 @@ func structExportValue(py *cpython.Python,
 			return py.Eval("usb.IN")
 		case usb.EndpointOut:
 			return py.Eval("usb.OUT")
+		case usb.EndpointInOut:
+			return py.Eval("usb.INOUT")
 		}
 
 	// fmt.Stringer becomes Python string
@@ -348,6 +351,10 @@ func structImportValueInt(obj *cpython.Object,
 		return structDecodeEnum(obj, v, escl.DecodeIntent)
 	case escl.JobState:
 		return structDecodeEnum(obj, v, escl.DecodeJobState)
+	case escl.ScannerState:
+		return structDecodeEnum(obj, v, escl.DecodeScannerState)
+	case escl.SupportedEdge:
+		return structDecodeEnum(obj, v, escl.DecodeSupportedEdge)
 	case escl.Units:
 		return structDecodeEnum(obj, v, escl.DecodeUnits)
 
@@ -368,6 +375,8 @@ func structImportValueInt(obj *cpython.Object,
 	// wsscan types
 	case wsscan.ColorEntry:
 		return structDecodeEnum(obj, v, wsscan.DecodeColorEntry)
+	case wsscan.Component:
+		return structDecodeEnum(obj, v, wsscan.DecodeComponent)
 	case wsscan.ContentTypeValue:
 		return structDecodeEnum(obj, v, wsscan.DecodeContentTypeValue)
 	case wsscan.FilmScanMode:
@@ -422,6 +431,8 @@ func structImportValueInt(obj *cpython.Object,
 			v.Set(reflect.ValueOf(usb.EndpointIn))
 		case "OUT":
 			v.Set(reflect.ValueOf(usb.EndpointOut))
+		case "INOUT":
+			v.Set(reflect.ValueOf(usb.EndpointInOut))
 		default:
 			err = errPy2Go(obj, v)
 		}
@@ -429,6 +440,20 @@ func structImportValueInt(obj *cpython.Object,
 		return err
 
 	// other types
+	case time.Duration:
+		// time.Duration is exported as fmt.Stringer
+		s, err := obj.Str()
+		if err != nil {
+			return err
+		}
+
+		d, err := time.ParseDuration(s)
+		if err == nil {
+			v.Set(reflect.ValueOf(d))
+		}
+
+		return err
+
 	case uuid.UUID:
 		s, err := obj.Str()
 		if err != nil {
@@ -457,6 +482,13 @@ func structImportValueInt(obj *cpython.Object,
 	case reflect.Slice:
 		return structImportSlice(obj, kwmap, v)
 
+	case reflect.Bool:
+		b, err := obj.Bool()
+		if err == nil {
+			v.SetBool(b)
+		}
+		return err
+
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
 		i, err := obj.Int()
 		if err == nil {
@@ -0,0 +1,75 @@
+//go:build !nocpython
+
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Export/import symmetry of the protocol structures
+
+package modeling
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/internal/assert"
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/proto/escl"
+	"github.com/OpenPrinting/go-mfp/proto/usb"
+	"github.com/OpenPrinting/go-mfp/proto/wsscan"
+)
+
+// structRegistry lists protocol structures, exchanged with Python.
+//
+// Each entry is tested for export/import symmetry by the
+// TestStructSymmetry. New types get coverage by adding a line here
+// (and, if needed, the value generator to the structGenTypes).
+var structRegistry = []struct {
+	kwmap  map[string]string // Keywords map
+	sample any               // Sample value of the type
+}{
+	// eSCL
+	{keywordMapESCL, escl.ScannerCapabilities{}},
+	{keywordMapESCL, escl.ScanSettings{}},
+	{keywordMapESCL, escl.ScannerStatus{}},
+
+	// WS-Scan
+	{keywordMapWSD, wsscan.GetScannerElementsResponse{}},
+
+	// USB
+	{keywordMapUSB, usb.DeviceDescriptor{}},
+}
+
+// TestStructSymmetry tests that every registered protocol structure
+// survives the structExport/structImport round trip unchanged.
+func TestStructSymmetry(t *testing.T) {
+	model, err := NewModel()
+	assert.NoError(err)
+	defer model.Close()
+
+	for _, ent := range structRegistry {
+		name := reflect.TypeOf(ent.sample).String()
+		in := newStructGen().New(ent.sample)
+
+		obj := structExport(model.py, ent.kwmap, in)
+		if err := obj.Err(); err != nil {
+			t.Errorf("%s: structExport: %s", name, err)
+			continue
+		}
+
+		out := reflect.New(reflect.TypeOf(in))
+		err := structImport(obj, ent.kwmap, out.Interface())
+		if err != nil {
+			t.Errorf("%s: structImport: %s", name, err)
+			continue
+		}
+
+		path := structDiffPath(name, reflect.ValueOf(in), out.Elem())
+		if path != "" {
+			t.Errorf("%s: mismatch at %s:\n%s", name, path,
+				testutils.Diff(in, out.Elem().Interface()))
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Deterministic generator of the populated protocol structures
+
+package modeling
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/proto/escl"
+	"github.com/OpenPrinting/go-mfp/proto/usb"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// structGen generates populated instances of the protocol
+// structures for tests.
+//
+// Every exported field is filled, slices get two elements and
+// pointers are allocated, so every field and every element type
+// is covered. Generated values are deterministic: the same type
+// always produces the same value.
+//
+// Types, which valid values cannot be guessed (i.e., string-based
+// enums), are generated by the per-type functions from the
+// structGenTypes table.
+//
+// Recursive types are populated one level deep: pointers and
+// slices, that would recurse into the type being generated,
+// are left nil.
+type structGen struct {
+	seed   int                   // Seed for the next leaf value
+	active map[reflect.Type]bool // Structures being generated
+}
+
+// structGenTypes contains per-type generators of valid values.
+// The seed parameter allows to vary values of the slice elements.
+var structGenTypes = map[reflect.Type]func(seed int) any{}
+
+// init registers per-type generators
+func init() {
+	structGenRegister(func(seed int) uuid.UUID {
+		var u uuid.UUID
+		for i := range u {
+			u[i] = byte(seed + i)
+		}
+		return u
+	})
+
+	structGenRegister(func(seed int) escl.Version {
+		return escl.MakeVersion(2, seed%10)
+	})
+
+	structGenRegister(func(seed int) usb.EndpointType {
+		return usb.EndpointType(seed % 3)
+	})
+}
+
+// structGenSkip lists types, not modeled at the Python side.
+// Fields of these types are left zero.
+var structGenSkip = map[reflect.Type]bool{
+	// Vendor extensions, represented as raw XML
+	reflect.TypeOf(xmldoc.Element{}): true,
+}
+
+// structGenRegister registers per-type generator.
+func structGenRegister[T any](gen func(seed int) T) {
+	var zero T
+	structGenTypes[reflect.TypeOf(zero)] = func(seed int) any {
+		return gen(seed)
+	}
+}
+
+// newStructGen creates a new structGen.
+func newStructGen() *structGen {
+	return &structGen{seed: 1, active: make(map[reflect.Type]bool)}
+}
+
+// New returns the pointer to the populated instance of the
+// same type as sample.
+func (gen *structGen) New(sample any) any {
+	t := reflect.TypeOf(sample)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	v := reflect.New(t)
+	gen.fill(v.Elem())
+	return v.Interface()
+}
+
+// fill fills the value.
+func (gen *structGen) fill(v reflect.Value) {
+	if fn := structGenTypes[v.Type()]; fn != nil {
+		v.Set(reflect.ValueOf(fn(gen.next())))
+		return
+	}
+
+	if structGenSkip[v.Type()] {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		gen.active[v.Type()] = true
+		for _, fld := range reflect.VisibleFields(v.Type()) {
+			if fld.IsExported() && len(fld.Index) == 1 {
+				gen.fill(v.Field(fld.Index[0]))
+			}
+		}
+		delete(gen.active, v.Type())
+
+	case reflect.Pointer:
+		if gen.active[v.Type().Elem()] {
+			return
+		}
+
+		p := reflect.New(v.Type().Elem())
+		gen.fill(p.Elem())
+		v.Set(p)
+
+	case reflect.Slice:
+		if gen.active[v.Type().Elem()] ||
+			structGenSkip[v.Type().Elem()] {
+			return
+		}
+
+		s := reflect.MakeSlice(v.Type(), 2, 2)
+		gen.fill(s.Index(0))
+		gen.fill(s.Index(1))
+		v.Set(s)
+
+	case reflect.Bool:
+		v.SetBool(gen.next()%2 == 1)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		v.SetInt(int64(gen.enum(v.Type())))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		v.SetUint(uint64(gen.enum(v.Type())))
+
+	case reflect.String:
+		s := fmt.Sprintf("%s-%d", v.Type().Name(), gen.next())
+		v.SetString(s)
+
+	case reflect.Interface:
+		// Interfaces can't be guessed; leave them nil.
+		// Types that need them must be registered in
+		// the structGenTypes.
+
+	default:
+		panic(fmt.Sprintf("structGen: %s: unsupported kind %s",
+			v.Type(), v.Kind()))
+	}
+}
+
+// enum returns the next integer value for the type.
+//
+// If type implements fmt.Stringer, it is considered enum, and
+// the valid value, that doesn't render as the zero value or as
+// the unknown value, is chosen.
+func (gen *structGen) enum(t reflect.Type) int {
+	seed := gen.next()
+
+	if !t.Implements(reflect.TypeOf((*fmt.Stringer)(nil)).Elem()) {
+		return seed % 100
+	}
+
+	str := func(n int) string {
+		v := reflect.New(t).Elem()
+		if v.CanInt() {
+			v.SetInt(int64(n))
+		} else {
+			v.SetUint(uint64(n))
+		}
+		return v.Interface().(fmt.Stringer).String()
+	}
+
+	zero := str(0)
+	valid := func(n int) bool {
+		s := str(n)
+		return s != "" && s != zero &&
+			!strings.Contains(s, "(") &&
+			!strings.HasPrefix(strings.ToLower(s), "unknown")
+	}
+
+	// Try values, starting from seed, then from 1
+	for _, start := range []int{seed % 8, 1} {
+		for n := start; n < start+8; n++ {
+			if n != 0 && valid(n) {
+				return n
+			}
+		}
+	}
+
+	panic(fmt.Sprintf("structGen: %s: can't guess enum value", t))
+}
+
+// next returns the next seed.
+func (gen *structGen) next() int {
+	seed := gen.seed
+	gen.seed++
+	return seed
+}
+
+// structDiffPath returns path to the first field, that differs
+// between v1 and v2, or "" if values are deeply equal.
+func structDiffPath(path string, v1, v2 reflect.Value) string {
+	if v1.IsValid() != v2.IsValid() {
+		return path
+	}
+
+	if !v1.IsValid() {
+		return ""
+	}
+
+	if v1.Type() != v2.Type() {
+		return path + " (type)"
+	}
+
+	switch v1.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v1.NumField(); i++ {
+			if !v1.Type().Field(i).IsExported() {
+				continue
+			}
+
+			name := path + "." + v1.Type().Field(i).Name
+			if p := structDiffPath(name, v1.Field(i), v2.Field(i)); p != "" {
+				return p
+			}
+		}
+		return ""
+
+	case reflect.Pointer, reflect.Interface:
+		if v1.IsNil() != v2.IsNil() {
+			return path
+		}
+		if v1.IsNil() {
+			return ""
+		}
+		return structDiffPath(path, v1.Elem(), v2.Elem())
+
+	case reflect.Slice:
+		if v1.Len() != v2.Len() || v1.IsNil() != v2.IsNil() {
+			return path
+		}
+
+		for i := 0; i < v1.Len(); i++ {
+			elem := fmt.Sprintf("%s[%d]", path, i)
+			if p := structDiffPath(elem, v1.Index(i), v2.Index(i)); p != "" {
+				return p
+			}
+		}
+		return ""
+	}
+
+	if !reflect.DeepEqual(v1.Interface(), v2.Interface()) {
+		return path
+	}
+
+	return ""
+}
@@ -18,6 +18,7 @@ class EndpointDescriptor(collection): pass
 # Keywords
 class IN(keyword): pass
 class OUT(keyword): pass
+class INOUT(keyword): pass
 
 # device is the model-settable variable that defines the
 # USB device parameters.
@@ -0,0 +1,197 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Printer and scanner modeling.
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Model validation
+
+package modeling
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/abstract"
+)
+
+// IssueSeverity is the severity of the [ModelIssue].
+type IssueSeverity int
+
+// IssueSeverity values:
+const (
+	IssueWarning IssueSeverity = iota // Model is usable, but suspicious
+	IssueError                        // Model is not usable
+)
+
+// String returns the IssueSeverity name.
+func (sev IssueSeverity) String() string {
+	switch sev {
+	case IssueWarning:
+		return "warning"
+	case IssueError:
+		return "error"
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(sev))
+}
+
+// ModelIssue is the problem, found by the [Model.Validate].
+type ModelIssue struct {
+	Severity IssueSeverity // Issue severity
+	Message  string        // Issue description
+}
+
+// String returns the ModelIssue string representation.
+func (issue ModelIssue) String() string {
+	return issue.Severity.String() + ": " + issue.Message
+}
+
+// ModelIssues is the list of [ModelIssue]s.
+type ModelIssues []ModelIssue
+
+// HasErrors reports if ModelIssues contain at least one
+// issue of the [IssueError] severity.
+func (issues ModelIssues) HasErrors() bool {
+	for _, issue := range issues {
+		if issue.Severity == IssueError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the Model for consistency and returns
+// the list of found issues, or nil if Model looks good.
+func (model *Model) Validate() ModelIssues {
+	var issues ModelIssues
+
+	add := func(sev IssueSeverity, format string, args ...any) {
+		issues = append(issues, ModelIssue{
+			Severity: sev,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if model.ippPrinterAttrs == nil && model.esclScanCaps == nil &&
+		model.wsdScanCaps == nil && model.usbDevice == nil {
+		add(IssueError, "model has no printer or scanner capabilities")
+		return issues
+	}
+
+	// Validate IPP printer attributes
+	if attrs := model.ippPrinterAttrs; attrs != nil {
+		for _, err := range attrs.Errors() {
+			add(IssueWarning, "ipp: %s", err)
+		}
+
+		if attrs.PrinterUUID == nil {
+			add(IssueWarning, "ipp: printer-uuid missed")
+		}
+
+		if len(attrs.DocumentFormatSupported) == 0 {
+			add(IssueError, "ipp: document-format-supported missed")
+		}
+	}
+
+	// Validate scanner capabilities
+	if model.esclScanCaps != nil {
+		caps := model.esclScanCaps.ToAbstract()
+		validateScanCaps(caps, "escl", add)
+
+		if model.esclScanCaps.UUID == nil {
+			add(IssueWarning, "escl: scanner UUID missed")
+		}
+	}
+
+	if model.wsdScanCaps != nil {
+		caps := model.wsdScanCaps.ToAbstract()
+		validateScanCaps(caps, "wsd", add)
+	}
+
+	// Validate device configuration
+	if model.deviceConfig != nil {
+		model.validateDeviceConfig(add)
+	}
+
+	return issues
+}
+
+// validateDeviceConfig validates the [DeviceConfig] for consistency
+// with the model capabilities.
+func (model *Model) validateDeviceConfig(
+	add func(IssueSeverity, string, ...any)) {
+
+	cfg := model.deviceConfig
+
+	// Protocol, explicitly enabled, requires its capabilities
+	present := map[string]bool{
+		ProtoIPP:  model.ippPrinterAttrs != nil,
+		ProtoESCL: model.esclScanCaps != nil,
+		ProtoWSD:  model.wsdScanCaps != nil,
+	}
+
+	served := 0
+	for _, proto := range []string{ProtoIPP, ProtoESCL, ProtoWSD} {
+		enabled, explicit := cfg.Protocols[proto]
+		switch {
+		case explicit && enabled && !present[proto]:
+			add(IssueError,
+				"device: %s enabled, but model has no %s capabilities",
+				proto, proto)
+		case enabled || !explicit:
+			if present[proto] {
+				served++
+			}
+		}
+	}
+
+	if served == 0 && model.usbDevice == nil {
+		add(IssueError, "device: all protocols are disabled")
+	}
+
+	// Check paths
+	paths := make(map[string]string)
+	for _, proto := range []string{ProtoIPP, ProtoESCL, ProtoWSD} {
+		if !model.ProtocolEnabled(proto) {
+			continue
+		}
+
+		path := model.ProtocolPath(proto)
+		if !strings.HasPrefix(path, "/") {
+			add(IssueError, "device: %s: invalid path %q", proto, path)
+			continue
+		}
+
+		if other, dup := paths[path]; dup {
+			add(IssueError, "device: %s and %s share the same path %q",
+				other, proto, path)
+		}
+
+		paths[path] = proto
+	}
+
+	// Check ports
+	for _, proto := range []string{ProtoIPP, ProtoESCL, ProtoWSD} {
+		port, found := cfg.Ports[proto]
+		if found && (port < 1 || port > 65535) {
+			add(IssueError, "device: %s: invalid port %d", proto, port)
+		}
+	}
+}
+
+// validateScanCaps validates scanner capabilities, converted into
+// the [abstract.ScannerCapabilities]. proto is the protocol name,
+// used as issue prefix.
+func validateScanCaps(caps *abstract.ScannerCapabilities,
+	proto string, add func(IssueSeverity, string, ...any)) {
+
+	if caps.Platen == nil && caps.ADFSimplex == nil &&
+		caps.ADFDuplex == nil {
+		add(IssueError, "%s: scanner has no input sources", proto)
+	}
+
+	if len(caps.DocumentFormats) == 0 {
+		add(IssueError, "%s: scanner has no document formats", proto)
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/OpenPrinting/go-mfp/abstract"
 	"github.com/OpenPrinting/go-mfp/proto/wsscan"
+	"github.com/OpenPrinting/go-mfp/util/optional"
 )
 
 // SetWSDScanCaps sets the WS-Scan scanner capabilities.
@@ -51,13 +52,59 @@ func (model *Model) NewWSDServer(
 	// Setup options
 	options := wsscan.AbstractServerOptions{
 		Scanner:  scanner,
-		BasePath: "/WSScan",
+		BasePath: model.ProtocolPath(ProtoWSD),
+	}
+
+	if desc := model.wsdDescription(); desc != nil {
+		options.Description = desc
 	}
 
 	// Create the WS-Scan server
 	return wsscan.NewAbstractServer(options)
 }
 
+// wsdDescription returns the localized ScannerDescription, or nil
+// if model has no localized WS-Scan strings.
+//
+// Localized strings replace the ScannerName and ScannerInfo of the
+// ScannerDescription, defined by the model capabilities, if any.
+func (model *Model) wsdDescription() optional.Val[wsscan.ScannerDescription] {
+	l10n := model.l10n
+	if l10n == nil || (len(l10n.FriendlyName) == 0 &&
+		len(l10n.ScannerInfo) == 0) {
+		return nil
+	}
+
+	var desc wsscan.ScannerDescription
+	if caps := model.wsdScanCaps; caps != nil {
+		found := false
+		for _, elm := range caps.ScannerElements {
+			if elm.ScannerDescription != nil {
+				desc = *elm.ScannerDescription
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			desc.ScannerName = wsscan.TextWithLangList{
+				{Text: caps.ToAbstract().MakeAndModel},
+			}
+		}
+	}
+
+	def := l10n.DefaultLanguage()
+	if len(l10n.FriendlyName) != 0 {
+		desc.ScannerName = l10n.FriendlyName.TextWithLangList(def)
+	}
+
+	if len(l10n.ScannerInfo) != 0 {
+		desc.ScannerInfo = l10n.ScannerInfo.TextWithLangList(def)
+	}
+
+	return optional.New(desc)
+}
+
 // wsdLoad decodes WS-Scan part of model. The model file assumed to
 // be preloaded into the Model's Python interpreter (model.py).
 func (model *Model) wsdLoad() error {
@@ -13,8 +13,10 @@ import (
 	"io"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/OpenPrinting/go-mfp/abstract"
 	"github.com/OpenPrinting/go-mfp/log/trace"
@@ -29,14 +31,41 @@ import (
 // [AbstractServer] keeps on its history.
 const AbstractServerHistorySize = 10
 
+// AbstractServerJobTimeout is the default inactivity timeout
+// of the [AbstractServer] scan jobs.
+const AbstractServerJobTimeout = time.Minute
+
+// abstractServerRetryAfter is the Retry-After hint, sent by the
+// AbstractServer, when it rejects a new job, because scanner is
+// busy with the current one.
+const abstractServerRetryAfter = 5 * time.Second
+
 // AbstractServer implements eSCL server on a top of [abstract.Scanner].
+//
+// Like the typical hardware scanner, it processes only one scan job
+// at a time and doesn't queue jobs. While job is active, requests
+// to start a new job are rejected with the HTTP 503 status.
 type AbstractServer struct {
-	options  AbstractServerOptions         // Server options
-	caps     *abstract.ScannerCapabilities // Scanner capabilities
-	status   ScannerStatus                 // Scanner status
-	document abstract.Document             // Document being server
-	joburi   string                        // Current JobURI, "" if none
-	lock     sync.Mutex                    // Access lock
+	options   AbstractServerOptions         // Server options
+	caps      *abstract.ScannerCapabilities // Scanner capabilities
+	status    ScannerStatus                 // Scanner status
+	document  abstract.Document             // Document being server
+	joburi    string                        // Current JobURI, "" if none
+	jobActive time.Time                     // Last access to the job
+	jobTimer  abstractServerTimer           // Job inactivity timer
+	transfers int                           // Images being sent
+	lock      sync.Mutex                    // Access lock
+
+	// Clock and timers, replaceable for testing
+	now      func() time.Time
+	newTimer func(time.Duration, func()) abstractServerTimer
+}
+
+// abstractServerTimer is the timer, used by the AbstractServer.
+// It is implemented by the [time.Timer].
+type abstractServerTimer interface {
+	Reset(d time.Duration) bool
+	Stop() bool
 }
 
 // AbstractServerOptions allows to specify options that can
@@ -53,6 +82,17 @@ type AbstractServerOptions struct {
 	// typical hardware eSCL scanner, the URL should be something like
 	// "/eSCL".
 	BasePath string
+
+	// JobTimeout is the inactivity timeout of the scan job.
+	//
+	// If client doesn't access the active job for this time,
+	// the job is considered abandoned (i.e., client has died),
+	// so it is canceled and scanner becomes available for the
+	// next job.
+	//
+	// If zero, AbstractServerJobTimeout is used. Negative value
+	// disables the timeout.
+	JobTimeout time.Duration
 }
 
 // NewAbstractServer returns a new [AbstractServer].
@@ -63,6 +103,11 @@ func NewAbstractServer(options AbstractServerOptions) *AbstractServer {
 		options.Version = DefaultVersion
 	}
 
+	// Use AbstractServerJobTimeout, if options.JobTimeout is not set
+	if options.JobTimeout == 0 {
+		options.JobTimeout = AbstractServerJobTimeout
+	}
+
 	// Canonicalize the base path
 	options.BasePath = transport.CleanURLPath(options.BasePath + "/")
 
@@ -70,6 +115,10 @@ func NewAbstractServer(options AbstractServerOptions) *AbstractServer {
 	srv := &AbstractServer{
 		options: options,
 		caps:    options.Scanner.Capabilities(),
+		now:     time.Now,
+		newTimer: func(d time.Duration, f func()) abstractServerTimer {
+			return time.AfterFunc(d, f)
+		},
 	}
 
 	srv.status = ScannerStatus{
@@ -282,6 +331,8 @@ func (srv *AbstractServer) postScanJobs(query *transport.ServerQuery) {
 	// Check if previous request already in progress
 	if srv.document != nil {
 		err := errors.New("Device is busy with the previous request")
+		retry := int(abstractServerRetryAfter / time.Second)
+		query.ResponseHeader().Set("Retry-After", strconv.Itoa(retry))
 		query.Reject(http.StatusServiceUnavailable, err)
 		return
 	}
@@ -299,6 +350,7 @@ func (srv *AbstractServer) postScanJobs(query *transport.ServerQuery) {
 
 	// Update server status
 	srv.document = document
+	srv.jobActive = srv.now()
 	srv.status.State = ScannerProcessing
 
 	jobuuid := uuid.Random().URN()
@@ -313,6 +365,10 @@ func (srv *AbstractServer) postScanJobs(query *transport.ServerQuery) {
 	srv.joburi = joburi
 	srv.status.PushJobInfo(info, AbstractServerHistorySize)
 
+	if timeout := srv.options.JobTimeout; timeout > 0 {
+		srv.jobTimer = srv.newTimer(timeout, srv.expire)
+	}
+
 	// Call OnScanJobsResponse hook
 	if srv.options.Hooks.OnScanJobsResponse != nil {
 		joburi2 := srv.options.Hooks.OnScanJobsResponse(query,
@@ -364,9 +420,14 @@ func (srv *AbstractServer) getJobURINextDocument(
 	var err error
 
 	if srv.document != nil && srv.joburi == joburi {
+		srv.touch()
 		file, err = srv.document.Next()
 	}
 
+	if err == nil && file != nil {
+		srv.transfers++
+	}
+
 	srv.lock.Unlock()
 
 	// Handle possible error conditions
@@ -386,6 +447,16 @@ func (srv *AbstractServer) getJobURINextDocument(
 		return
 	}
 
+	// Job inactivity timer restarts when image is sent
+	defer func() {
+		srv.lock.Lock()
+		srv.transfers--
+		if srv.document != nil && srv.joburi == joburi {
+			srv.touch()
+		}
+		srv.lock.Unlock()
+	}()
+
 	// Call OnNextDocumentResponse hook
 	body := io.NopCloser(file)
 	if srv.options.Hooks.OnNextDocumentResponse != nil {
@@ -468,6 +539,54 @@ func (srv *AbstractServer) finish(state JobState, reason JobStateReason) {
 	srv.lock.Lock()
 	defer srv.lock.Unlock()
 
+	if srv.document != nil {
+		srv.finishLocked(state, reason)
+	}
+}
+
+// touch marks the current job as accessed by the client and
+// restarts the job inactivity timer.
+//
+// It must be called under the srv.lock.
+func (srv *AbstractServer) touch() {
+	srv.jobActive = srv.now()
+	if srv.jobTimer != nil {
+		srv.jobTimer.Reset(srv.options.JobTimeout)
+	}
+}
+
+// expire cancels the current job, if it is abandoned by the client
+// (not accessed for the options.JobTimeout). It frees the scanner
+// for the next job.
+//
+// It is called by the job inactivity timer. The timer may fire
+// while image is being sent, or race with the timer restart; these
+// cases are detected here and ignored (when transfer is finished,
+// the timer is restarted).
+func (srv *AbstractServer) expire() {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	timeout := srv.options.JobTimeout
+	if srv.document == nil || srv.transfers != 0 || timeout < 0 {
+		return
+	}
+
+	if srv.now().Sub(srv.jobActive) >= timeout {
+		srv.finishLocked(JobCanceled, AbortedBySystem)
+	}
+}
+
+// finishLocked is the internal function behind the finish.
+// It must be called under the srv.lock.
+func (srv *AbstractServer) finishLocked(state JobState,
+	reason JobStateReason) {
+
+	if srv.jobTimer != nil {
+		srv.jobTimer.Stop()
+		srv.jobTimer = nil
+	}
+
 	srv.document.Close()
 	srv.document = nil
 	srv.joburi = ""
@@ -0,0 +1,437 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// eSCL core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// eSCL server on a top of abstract.Scanner test
+
+package escl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/abstract"
+	"github.com/OpenPrinting/go-mfp/internal/assert"
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/transport/testutil"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// testAbstractServer is the AbstractServer with the virtual scanner,
+// connected to the Client via the loopback transport.
+type testAbstractServer struct {
+	srv    *AbstractServer   // The server
+	server *transport.Server // HTTP server
+	clnt   *Client           // The client
+	rq     ScanSettings      // Scan request
+	clock  time.Time         // Fake server clock
+	timers []*testTimer      // Fake server timers
+	lock   sync.Mutex        // Clock access lock
+}
+
+// testTimer is the fake timer, driven by the testAbstractServer clock.
+type testTimer struct {
+	ts       *testAbstractServer // Owner
+	deadline time.Time           // Expiration time
+	active   bool                // Timer is active
+	f        func()              // Callback
+}
+
+// newTestAbstractServer creates a new testAbstractServer.
+func newTestAbstractServer(timeout time.Duration) *testAbstractServer {
+	xml, err := xmldoc.Decode(
+		NsMap,
+		bytes.NewReader(testutils.
+			Kyocera.ECOSYS.M2040dn.ESCL.ScannerCapabilities))
+	assert.NoError(err)
+
+	caps, err := DecodeScannerCapabilities(xml)
+	assert.NoError(err)
+
+	s := &abstract.VirtualScanner{
+		ScanCaps: caps.ToAbstract(),
+		Resolution: abstract.Resolution{
+			XResolution: 300,
+			YResolution: 300,
+		},
+		PlatenImage: testutils.Images.PNG100x75rgb8,
+	}
+
+	tr, loopback := transport.NewLoopback()
+	base := transport.MustParseURL("http://localhost/eSCL")
+
+	ts := &testAbstractServer{
+		clock: time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC),
+		rq: ScanSettings{
+			Version:     caps.Version,
+			InputSource: optional.New(InputPlaten),
+			XResolution: optional.New(300),
+			YResolution: optional.New(300),
+		},
+	}
+
+	ts.srv = NewAbstractServer(AbstractServerOptions{
+		Version:    caps.Version,
+		Scanner:    s,
+		BasePath:   base.Path,
+		JobTimeout: timeout,
+	})
+	ts.srv.now = ts.now
+	ts.srv.newTimer = ts.newTimer
+
+	ts.server = transport.NewServer(context.Background(), nil, ts.srv)
+	go ts.server.Serve(loopback)
+
+	ts.clnt = NewClient(base, tr)
+	ts.clnt.SetRetryPolicy(NoRetryPolicy)
+
+	return ts
+}
+
+// Close closes the testAbstractServer
+func (ts *testAbstractServer) Close() {
+	ts.server.Close()
+}
+
+// now returns the fake server time
+func (ts *testAbstractServer) now() time.Time {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	return ts.clock
+}
+
+// newTimer creates a new fake timer
+func (ts *testAbstractServer) newTimer(d time.Duration,
+	f func()) abstractServerTimer {
+
+	tm := &testTimer{ts: ts, f: f}
+	tm.Reset(d)
+
+	ts.lock.Lock()
+	ts.timers = append(ts.timers, tm)
+	ts.lock.Unlock()
+
+	return tm
+}
+
+// advance advances the fake server time and synchronously
+// fires the expired timers.
+func (ts *testAbstractServer) advance(d time.Duration) {
+	var fire []func()
+
+	ts.lock.Lock()
+	ts.clock = ts.clock.Add(d)
+	for _, tm := range ts.timers {
+		if tm.active && !tm.deadline.After(ts.clock) {
+			tm.active = false
+			fire = append(fire, tm.f)
+		}
+	}
+	ts.lock.Unlock()
+
+	for _, f := range fire {
+		f()
+	}
+}
+
+// busy reports if the server holds the active job
+func (ts *testAbstractServer) busy() bool {
+	ts.srv.lock.Lock()
+	defer ts.srv.lock.Unlock()
+	return ts.srv.document != nil
+}
+
+// Reset restarts the fake timer
+func (tm *testTimer) Reset(d time.Duration) bool {
+	tm.ts.lock.Lock()
+	defer tm.ts.lock.Unlock()
+
+	active := tm.active
+	tm.deadline = tm.ts.clock.Add(d)
+	tm.active = true
+	return active
+}
+
+// Stop stops the fake timer
+func (tm *testTimer) Stop() bool {
+	tm.ts.lock.Lock()
+	defer tm.ts.lock.Unlock()
+
+	active := tm.active
+	tm.active = false
+	return active
+}
+
+// TestAbstractServerConcurrentJobs tests that AbstractServer
+// accepts only one job at a time
+func TestAbstractServerConcurrentJobs(t *testing.T) {
+	ts := newTestAbstractServer(0)
+	defer ts.Close()
+
+	ctx := context.Background()
+
+	// Start two jobs concurrently
+	type result struct {
+		joburl  string
+		details *HTTPDetails
+		err     error
+	}
+
+	var results [2]result
+	var wait sync.WaitGroup
+
+	for i := range results {
+		wait.Add(1)
+		go func(r *result) {
+			r.joburl, r.details, r.err = ts.clnt.Scan(ctx, ts.rq)
+			wait.Done()
+		}(&results[i])
+	}
+
+	wait.Wait()
+
+	// Exactly one must succeed
+	ok, busy := results[0], results[1]
+	if ok.err != nil {
+		ok, busy = busy, ok
+	}
+
+	if ok.err != nil {
+		t.Fatalf("Scan: both jobs failed: %s", ok.err)
+	}
+
+	if !errors.Is(busy.err, ErrScannerBusy) {
+		t.Fatalf("Scan: ErrScannerBusy expected, present: %v",
+			busy.err)
+	}
+
+	if errors.Is(busy.err, ErrConflict) {
+		t.Errorf("Scan: unexpected ErrConflict")
+	}
+
+	var statusErr *StatusError
+	if !errors.As(busy.err, &statusErr) {
+		t.Fatalf("Scan: StatusError expected")
+	}
+
+	if statusErr.RetryAfter != abstractServerRetryAfter {
+		t.Errorf("Scan: RetryAfter expected %s, present %s",
+			abstractServerRetryAfter, statusErr.RetryAfter)
+	}
+
+	// When the first job is done, scanner accepts the next one
+	for {
+		doc, _, err := ts.clnt.NextDocument(ctx, ok.joburl)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatalf("NextDocument: %s", err)
+		}
+
+		doc.Close()
+	}
+
+	joburl, _, err := ts.clnt.Scan(ctx, ts.rq)
+	if err != nil {
+		t.Fatalf("Scan: %s", err)
+	}
+
+	ts.clnt.Cancel(ctx, joburl)
+}
+
+// TestAbstractServerJobTimeout tests cancellation of the
+// abandoned jobs
+func TestAbstractServerJobTimeout(t *testing.T) {
+	ts := newTestAbstractServer(0)
+	defer ts.Close()
+
+	ctx := context.Background()
+
+	// Create the job, then abandon it
+	joburl, _, err := ts.clnt.Scan(ctx, ts.rq)
+	if err != nil {
+		t.Fatalf("Scan: %s", err)
+	}
+
+	// Before timeout, scanner is busy
+	ts.advance(AbstractServerJobTimeout - time.Second)
+
+	_, _, err = ts.clnt.Scan(ctx, ts.rq)
+	if !errors.Is(err, ErrScannerBusy) {
+		t.Fatalf("Scan: ErrScannerBusy expected, present: %v", err)
+	}
+
+	status, _, err := ts.clnt.GetScannerStatus(ctx)
+	if err != nil {
+		t.Fatalf("GetScannerStatus: %s", err)
+	}
+
+	if status.State != ScannerProcessing {
+		t.Errorf("ScannerStatus: expected %s, present %s",
+			ScannerProcessing, status.State)
+	}
+
+	// After timeout, job is canceled by the timer,
+	// without waiting for the next request
+	ts.advance(time.Second)
+
+	if ts.busy() {
+		t.Fatalf("Job not canceled after timeout")
+	}
+
+	status, _, err = ts.clnt.GetScannerStatus(ctx)
+	if err != nil {
+		t.Fatalf("GetScannerStatus: %s", err)
+	}
+
+	if status.State != ScannerIdle {
+		t.Errorf("ScannerStatus: expected %s, present %s",
+			ScannerIdle, status.State)
+	}
+
+	if len(status.Jobs) == 0 {
+		t.Fatalf("ScannerStatus: job missed")
+	}
+
+	info := status.Jobs[0]
+	if info.JobState != JobCanceled ||
+		len(info.JobStateReasons) != 1 ||
+		info.JobStateReasons[0] != AbortedBySystem {
+		t.Errorf("JobInfo: %s %v", info.JobState, info.JobStateReasons)
+	}
+
+	_, _, err = ts.clnt.NextDocument(ctx, joburl)
+	if err != io.EOF {
+		t.Errorf("NextDocument: io.EOF expected, present: %v", err)
+	}
+
+	// Scanner accepts the next job. Access to the job
+	// restarts the inactivity timer.
+	joburl, _, err = ts.clnt.Scan(ctx, ts.rq)
+	if err != nil {
+		t.Fatalf("Scan: %s", err)
+	}
+
+	ts.advance(AbstractServerJobTimeout - time.Second)
+
+	doc, _, err := ts.clnt.NextDocument(ctx, joburl)
+	if err != nil {
+		t.Fatalf("NextDocument: %s", err)
+	}
+	doc.Close()
+
+	ts.advance(AbstractServerJobTimeout - time.Second)
+
+	_, _, err = ts.clnt.Scan(ctx, ts.rq)
+	if !errors.Is(err, ErrScannerBusy) {
+		t.Errorf("Scan: ErrScannerBusy expected, present: %v", err)
+	}
+
+	ts.clnt.Cancel(ctx, joburl)
+
+	// Negative timeout disables expiration
+	ts2 := newTestAbstractServer(-1)
+	defer ts2.Close()
+
+	_, _, err = ts2.clnt.Scan(ctx, ts2.rq)
+	if err != nil {
+		t.Fatalf("Scan: %s", err)
+	}
+
+	ts2.advance(24 * time.Hour)
+
+	if !ts2.busy() {
+		t.Errorf("JobTimeout < 0: job unexpectedly canceled")
+	}
+
+	_, _, err = ts2.clnt.Scan(ctx, ts2.rq)
+	if !errors.Is(err, ErrScannerBusy) {
+		t.Errorf("JobTimeout < 0: ErrScannerBusy expected, "+
+			"present: %v", err)
+	}
+}
+
+// TestClientStatusError tests mapping of the HTTP errors into
+// ErrScannerBusy and ErrConflict
+func TestClientStatusError(t *testing.T) {
+	type testData struct {
+		status int           // HTTP status
+		busy   bool          // Must match ErrScannerBusy
+		confl  bool          // Must match ErrConflict
+		retry  time.Duration // Expected RetryAfter
+	}
+
+	tests := []testData{
+		{
+			status: http.StatusServiceUnavailable,
+			busy:   true,
+			retry:  3 * time.Second,
+		},
+		{
+			status: http.StatusConflict,
+			confl:  true,
+			retry:  3 * time.Second,
+		},
+		{
+			status: http.StatusBadRequest,
+			retry:  3 * time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		scanner := newTestBusyScanner(0)
+		scanner.SetHook(testutil.FailN(1, test.status, "3"))
+
+		clnt, _ := testRetryClient(scanner.URL)
+		clnt.SetRetryPolicy(NoRetryPolicy)
+
+		_, _, err := clnt.Scan(context.Background(), ScanSettings{})
+		scanner.Close()
+
+		if errors.Is(err, ErrScannerBusy) != test.busy ||
+			errors.Is(err, ErrConflict) != test.confl {
+			t.Errorf("HTTP %d: unexpected error: %v",
+				test.status, err)
+		}
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			t.Errorf("HTTP %d: StatusError expected", test.status)
+			continue
+		}
+
+		if statusErr.StatusCode != test.status ||
+			statusErr.RetryAfter != test.retry {
+			t.Errorf("HTTP %d: StatusError: %#v",
+				test.status, statusErr)
+		}
+	}
+
+	// BusyError matches ErrScannerBusy and keeps the last hint
+	scanner := newTestBusyScanner(1000, "1", "1", "1", "1", "7")
+	defer scanner.Close()
+
+	clnt, _ := testRetryClient(scanner.URL)
+	_, _, err := clnt.Scan(context.Background(), ScanSettings{})
+
+	var busy *BusyError
+	if !errors.As(err, &busy) || !errors.Is(err, ErrScannerBusy) {
+		t.Fatalf("BusyError expected, present: %v", err)
+	}
+
+	if busy.RetryAfter != 7*time.Second {
+		t.Errorf("BusyError: RetryAfter: %s", busy.RetryAfter)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/OpenPrinting/go-mfp/log"
 	"github.com/OpenPrinting/go-mfp/transport"
@@ -25,24 +26,46 @@ import (
 )
 
 // Client implements a low-level eSCL client.
+//
+// Network failures are returned as [transport.ClientError],
+// which allows to classify them with [transport.ClassifyError].
 type Client struct {
-	url        *url.URL          // Destination URL (http://...)
-	httpClient *transport.Client // HTTP Client
+	url         *url.URL          // Destination URL (http://...)
+	httpClient  *transport.Client // HTTP Client
+	retryPolicy RetryPolicy       // Retry policy for busy scanner
+
+	// Clock, replaceable for testing
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
 }
 
 // NewClient creates a new eSCL client.
 //
 // If tr is nil, [transport.NewTransport] will be used to create
 // a new transport.
+//
+// To share connections with clients of other protocols, talking
+// to the same device, use the [transport.SharedPool] transport:
+//
+//	clnt := NewClient(u, transport.PoolFor(u).Transport())
 func NewClient(u *url.URL, tr *transport.Transport) *Client {
 	c := &Client{
-		url:        transport.URLClone(u),
-		httpClient: transport.NewClient(tr),
+		url:         transport.URLClone(u),
+		httpClient:  transport.NewClient(tr),
+		retryPolicy: DefaultRetryPolicy,
+		now:         time.Now,
+		sleep:       retrySleep,
 	}
 
 	return c
 }
 
+// SetRetryPolicy sets the [RetryPolicy] for requests, rejected
+// by the busy scanner. By default, [DefaultRetryPolicy] is used.
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retryPolicy = policy
+}
+
 // GetScannerCapabilities requests the [ScannerCapabilities] from
 // the eSCL scanner.
 func (c *Client) GetScannerCapabilities(ctx context.Context) (
@@ -75,6 +98,12 @@ func (c *Client) GetScannerStatus(ctx context.Context) (
 // used for the subsequent call to [Client.NextDocument] and
 // [Client.Cancel].
 //
+// If scanner is busy, the request is retried according to the
+// Client's [RetryPolicy], and [BusyError] is returned if all
+// attempts are exhausted. Both [BusyError] and, with retries
+// disabled, [StatusError] match the [ErrScannerBusy]. If scanner
+// rejects the request, the returned error matches [ErrConflict].
+//
 // Please notice that this function normalized the JobUri received
 // from the server. If you need the raw, unmodified JobUri, use
 // [HTTPDetails.Header.Get]("Location") using the provided
@@ -83,7 +112,9 @@ func (c *Client) Scan(ctx context.Context, rq ScanSettings) (
 	joburl string, details *HTTPDetails, err error) {
 
 	// Send the request
-	details, err = c.post(ctx, "POST", "ScanJobs", rq.ToXML())
+	details, err = c.retry(ctx, func() (*HTTPDetails, error) {
+		return c.post(ctx, "POST", "ScanJobs", rq.ToXML())
+	})
 	if err != nil {
 		return
 	}
@@ -123,10 +154,16 @@ func (c *Client) Scan(ctx context.Context, rq ScanSettings) (
 // If all scanned documents are consumed, it returns [io.EOF] error,
 // but please note that false positives are possible if there were
 // no preceding [Client.Scan] request or joburl is invalud.
+//
+// If scanner is busy, the request is retried the same way
+// as by [Client.Scan].
 func (c *Client) NextDocument(ctx context.Context, joburl string) (
 	doc io.ReadCloser, details *HTTPDetails, err error) {
 
-	doc, details, err = c.get(ctx, "GET", joburl+"/NextDocument")
+	details, err = c.retry(ctx, func() (d *HTTPDetails, e error) {
+		doc, d, e = c.get(ctx, "GET", joburl+"/NextDocument")
+		return
+	})
 	if details != nil && details.StatusCode == http.StatusNotFound {
 		err = io.EOF
 	}
@@ -201,6 +238,7 @@ func (c *Client) get(ctx context.Context, method, subpath string) (
 
 	httpRsp, err := c.httpClient.Do(httpRq)
 	if err != nil {
+		err = transport.WrapError(err, u)
 		return
 	}
 
@@ -208,7 +246,7 @@ func (c *Client) get(ctx context.Context, method, subpath string) (
 	details = newHTTPDetails(httpRsp)
 
 	if httpRsp.StatusCode/100 != http.StatusOK/100 {
-		err = fmt.Errorf("HTTP: %s", httpRsp.Status)
+		err = c.statusError(httpRsp)
 		httpRsp.Body.Close()
 		return
 	}
@@ -241,6 +279,7 @@ func (c *Client) post(ctx context.Context, method, subpath string,
 
 	httpRsp, err := c.httpClient.Do(httpRq)
 	if err != nil {
+		err = transport.WrapError(err, u)
 		return
 	}
 
@@ -250,7 +289,7 @@ func (c *Client) post(ctx context.Context, method, subpath string,
 	details = newHTTPDetails(httpRsp)
 
 	if httpRsp.StatusCode/100 != http.StatusOK/100 {
-		err = fmt.Errorf("HTTP: %s", httpRsp.Status)
+		err = c.statusError(httpRsp)
 		return
 	}
 
@@ -11,13 +11,16 @@ package escl
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"net"
 	"testing"
 
 	"github.com/OpenPrinting/go-mfp/abstract"
 	"github.com/OpenPrinting/go-mfp/internal/assert"
 	"github.com/OpenPrinting/go-mfp/internal/testutils"
 	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/transport/testutil"
 	"github.com/OpenPrinting/go-mfp/util/optional"
 	"github.com/OpenPrinting/go-mfp/util/xmldoc"
 )
@@ -140,3 +143,116 @@ func TestClient(t *testing.T) {
 		return
 	}
 }
+
+// TestClientErrorClass tests that network failures are
+// returned as transport.ClientError
+func TestClientErrorClass(t *testing.T) {
+	// Obtain the local port, where nobody listens
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %s", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	u := transport.MustParseURL("http://" + addr + "/eSCL")
+	clnt := NewClient(u, nil)
+
+	_, _, err = clnt.GetScannerStatus(context.Background())
+
+	var clientErr *transport.ClientError
+	if !errors.As(err, &clientErr) {
+		t.Fatalf("transport.ClientError expected, present: %v", err)
+	}
+
+	if clientErr.Class != transport.ErrClassConnectionRefused {
+		t.Errorf("ErrClass: expected %s, present %s",
+			transport.ErrClassConnectionRefused, clientErr.Class)
+	}
+}
+
+// TestClientFakeScanner tests the Client against the fake scanner
+// over HTTP, HTTPS and automatically detected TLS.
+func TestClientFakeScanner(t *testing.T) {
+	caps := testutils.Kyocera.ECOSYS.M2040dn.ESCL.ScannerCapabilities
+	pages := []testutil.FakePage{
+		{Data: []byte("page 1")},
+		{ContentType: "application/pdf", Data: []byte("page 2")},
+	}
+
+	for _, opts := range []testutil.Options{
+		{},
+		{TLS: true},
+		{AutoTLS: true},
+	} {
+		scanner := testutil.NewFakeESCLScanner(caps, opts)
+		scanner.SetPages(pages...)
+
+		base := scanner.BaseURL()
+		if opts.AutoTLS {
+			base = scanner.TLSURL + testutil.FakeESCLBasePath
+		}
+
+		ctx := context.Background()
+		clnt := NewClient(transport.MustParseURL(base), nil)
+
+		_, _, err := clnt.GetScannerCapabilities(ctx)
+		if err != nil {
+			t.Errorf("%+v: GetScannerCapabilities: %s", opts, err)
+		}
+
+		rq := ScanSettings{InputSource: optional.New(InputFeeder)}
+		job, _, err := clnt.Scan(ctx, rq)
+		if err != nil {
+			t.Fatalf("%+v: Scan: %s", opts, err)
+		}
+
+		for _, page := range pages {
+			doc, _, err := clnt.NextDocument(ctx, job)
+			if err != nil {
+				t.Fatalf("%+v: NextDocument: %s", opts, err)
+			}
+
+			data, _ := io.ReadAll(doc)
+			doc.Close()
+
+			if !bytes.Equal(data, page.Data) {
+				t.Errorf("%+v: NextDocument: expected %q, present %q",
+					opts, page.Data, data)
+			}
+		}
+
+		_, _, err = clnt.NextDocument(ctx, job)
+		if err != io.EOF {
+			t.Errorf("%+v: NextDocument: io.EOF expected, present %v",
+				opts, err)
+		}
+
+		clnt.Cancel(ctx, job)
+		if scanner.Jobs() != 0 {
+			t.Errorf("%+v: job not deleted", opts)
+		}
+
+		scanner.Log().Expect(t,
+			"GET /eSCL/ScannerCapabilities",
+			"POST /eSCL/ScanJobs",
+			"GET /eSCL/ScanJobs/1/NextDocument",
+			"GET /eSCL/ScanJobs/1/NextDocument",
+			"GET /eSCL/ScanJobs/1/NextDocument",
+			"DELETE /eSCL/ScanJobs/1")
+
+		// ScanSettings must be received as sent
+		posted := scanner.Log().Requests()[1]
+		xml, err := xmldoc.Decode(NsMap, bytes.NewReader(posted.Body))
+		assert.NoError(err)
+
+		received, err := DecodeScanSettings(xml)
+		if err != nil {
+			t.Errorf("%+v: ScanSettings: %s", opts, err)
+		} else if optional.Get(received.InputSource) != InputFeeder {
+			t.Errorf("%+v: ScanSettings: InputSource mismatch", opts)
+		}
+
+		scanner.Close()
+	}
+}
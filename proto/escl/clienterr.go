@@ -0,0 +1,66 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// eSCL core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Client errors
+
+package escl
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Errors, returned by the [Client], can be tested against these
+// values with [errors.Is]:
+//
+//   - ErrScannerBusy means that scanner is busy with another job
+//     (HTTP 503). It is worth to retry later.
+//   - ErrConflict means that scanner rejected the request
+//     (HTTP 409), typically due to unsupported scan settings.
+//     Retrying the same request is pointless.
+var (
+	ErrScannerBusy = errors.New("eSCL: scanner busy")
+	ErrConflict    = errors.New("eSCL: conflict")
+)
+
+// StatusError is returned by the [Client], when the eSCL server
+// responds with the unsuccessful HTTP status.
+type StatusError struct {
+	Status     string        // e.g. "503 Service Unavailable"
+	StatusCode int           // HTTP status code
+	RetryAfter time.Duration // Retry-After hint, 0 if missed
+}
+
+// Error returns the error message.
+// It implements the error interface.
+func (e *StatusError) Error() string {
+	return "HTTP: " + e.Status
+}
+
+// Is reports whether StatusError matches the target error.
+// It allows to use [errors.Is] with [ErrScannerBusy] and
+// [ErrConflict].
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrScannerBusy:
+		return e.StatusCode == http.StatusServiceUnavailable
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	}
+
+	return false
+}
+
+// statusError creates the StatusError from the HTTP response.
+func (c *Client) statusError(rsp *http.Response) error {
+	d, _ := retryAfter(rsp.Header.Get("Retry-After"), c.now())
+	return &StatusError{
+		Status:     rsp.Status,
+		StatusCode: rsp.StatusCode,
+		RetryAfter: d,
+	}
+}
@@ -9,8 +9,10 @@
 package escl
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 
 	"github.com/OpenPrinting/go-mfp/internal/assert"
@@ -35,6 +37,17 @@ type Proxy struct {
 	hooks     ServerHooks        // eSCL server hooks
 }
 
+// ProxyMethods lists HTTP methods, accepted by the [Proxy].
+//
+// Requests, known to eSCL, are handled by the Proxy with the
+// appropriate URL translation. Other requests with these methods
+// (for example, OPTIONS, used by some clients to test the scanner
+// reachability) are forwarded as is. Requests with other methods
+// are rejected with the http.StatusMethodNotAllowed status.
+var ProxyMethods = []string{
+	"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS",
+}
+
 // NewProxy creates the new [Proxy].
 //
 // The `clnt` is the client side of the proxy. If nil is passed,
@@ -52,9 +65,25 @@ func NewProxy(localPath string, remoteURL *url.URL) *Proxy {
 		clnt:      NewClient(remoteURL, nil),
 		urlxlat:   transport.NewURLXlat(localURL, remoteURL),
 	}
+
+	// Busy scanner status is passed to the proxy's client as is,
+	// so it can apply its own retry policy.
+	proxy.clnt.SetRetryPolicy(NoRetryPolicy)
+
 	return proxy
 }
 
+// SetTransport sets the [transport.Transport], used by the client
+// side of the proxy to reach the remote scanner. It allows, for
+// example, to choose the per-scanner [transport.TLSPolicy].
+//
+// Don't use this function when proxy is already active (i.e.,
+// concurrently with the [Proxy.ServeHTTP]).
+func (proxy *Proxy) SetTransport(tr *transport.Transport) {
+	proxy.clnt = NewClient(proxy.remoteURL, tr)
+	proxy.clnt.SetRetryPolicy(NoRetryPolicy)
+}
+
 // Sniff installs the sniffer callback.
 //
 // Don't use this function when proxy is already active (i.e., concurrently
@@ -124,12 +153,35 @@ func (proxy *Proxy) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
 		}
 	}
 
-	if action != nil {
+	switch {
+	case action != nil:
 		action(query)
-	} else {
-		query.Reject(http.StatusNotFound, nil)
+
+	case slices.Contains(ProxyMethods, method):
+		// Requests not known to eSCL are forwarded as is
+		proxy.forward(query)
+
+	default:
+		query.RejectMethod(ProxyMethods...)
 	}
+}
+
+// forward forwards the request to the scanner as is, without
+// eSCL-specific processing.
+func (proxy *Proxy) forward(query *transport.ServerQuery) {
+	// Guess Proxy's local (server) URL out of request.
+	s := query.RequestScheme() + "://" + query.RequestHost()
+	local, err := transport.ParseURL(s)
+	if err != nil {
+		err = fmt.Errorf("%q: can't parse local URL", s)
+		query.Reject(http.StatusBadRequest, err)
+		return
+	}
+
+	local.Path = proxy.localPath
+	urlxlat := transport.NewURLXlat(local, proxy.remoteURL)
 
+	transport.Forward(query, proxy.clnt.httpClient, urlxlat)
 }
 
 // getScannerCapabilities handles GET /{root}/ScannerCapabilities request
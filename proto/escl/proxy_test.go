@@ -0,0 +1,87 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// eSCL core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// eSCL Proxy test
+
+package escl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// TestProxyMethods tests how Proxy handles HTTP methods
+func TestProxyMethods(t *testing.T) {
+	// Create fake scanner. It returns request method in the
+	// X-Method response header.
+	target := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			w.Header().Set("X-Method", rq.Method)
+			w.WriteHeader(http.StatusOK)
+		}))
+	defer target.Close()
+
+	proxy := NewProxy("/eSCL",
+		transport.MustParseURL(target.URL+"/eSCL"))
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+
+	type testData struct {
+		method    string // Request method
+		path      string // Request path
+		status    int    // Expected status
+		forwarded bool   // Request must reach the target as is
+	}
+
+	tests := []testData{
+		{"GET", "/eSCL/icon.png", http.StatusOK, true},
+		{"HEAD", "/eSCL/icon.png", http.StatusOK, true},
+		{"OPTIONS", "/eSCL/", http.StatusOK, true},
+		{"PUT", "/eSCL/ScanJobs/1", http.StatusOK, true},
+		{"POST", "/eSCL/Unknown", http.StatusOK, true},
+		{"PATCH", "/eSCL/", http.StatusMethodNotAllowed, false},
+		{"TRACE", "/eSCL/", http.StatusMethodNotAllowed, false},
+	}
+
+	allowExpected := strings.Join(ProxyMethods, ", ")
+
+	for _, test := range tests {
+		rq, _ := http.NewRequest(test.method, srv.URL+test.path, nil)
+		rsp, err := http.DefaultClient.Do(rq)
+		if err != nil {
+			t.Errorf("%s %s: %s", test.method, test.path, err)
+			continue
+		}
+		rsp.Body.Close()
+
+		if rsp.StatusCode != test.status {
+			t.Errorf("%s %s: status mismatch:\n"+
+				"expected: %d\npresent:  %d",
+				test.method, test.path, test.status,
+				rsp.StatusCode)
+		}
+
+		method := rsp.Header.Get("X-Method")
+		switch {
+		case test.forwarded && method != test.method:
+			t.Errorf("%s %s: not forwarded",
+				test.method, test.path)
+		case !test.forwarded && method != "":
+			t.Errorf("%s %s: unexpectedly forwarded",
+				test.method, test.path)
+		}
+
+		allow := rsp.Header.Get("Allow")
+		if !test.forwarded && allow != allowExpected {
+			t.Errorf("%s %s: Allow mismatch: %q",
+				test.method, test.path, allow)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// eSCL core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Client retry policy for busy scanner
+
+package escl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/log"
+)
+
+// RetryPolicy defines how [Client] retries requests, rejected by
+// the busy scanner with the HTTP 503 (Service Unavailable) status.
+//
+// Scanners return this status, for example, when another job is
+// active or when lamp is warming up, typically with the Retry-After
+// header that hints when to retry.
+//
+// Only ScanJobs POST ([Client.Scan]) and NextDocument GET
+// ([Client.NextDocument]) requests are retried. Other HTTP
+// errors, including 4xx, are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including
+	// the first one. Values less that 2 disable retries.
+	MaxAttempts int
+
+	// DefaultWait is the wait time, used when Retry-After
+	// is missed or cannot be parsed.
+	DefaultWait time.Duration
+
+	// MaxWait is the upper bound for a single wait. Longer
+	// Retry-After values are truncated to MaxWait.
+	MaxWait time.Duration
+}
+
+// DefaultRetryPolicy is the default RetryPolicy, used by the
+// [Client] unless changed with [Client.SetRetryPolicy].
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	DefaultWait: 2 * time.Second,
+	MaxWait:     30 * time.Second,
+}
+
+// NoRetryPolicy is the RetryPolicy that disables retries.
+var NoRetryPolicy = RetryPolicy{}
+
+// BusyError is returned by the [Client], when scanner remains busy
+// (responds with the HTTP 503 status) after all attempts, allowed
+// by the [RetryPolicy], are exhausted.
+//
+// BusyError matches the [ErrScannerBusy] with [errors.Is].
+type BusyError struct {
+	Attempts   int           // Count of attempts made
+	Waited     time.Duration // Total time spent waiting between attempts
+	RetryAfter time.Duration // Last Retry-After hint, 0 if missed
+}
+
+// Error returns the error message.
+// It implements the error interface.
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("eSCL: scanner busy (%d attempts, waited %s)",
+		e.Attempts, e.Waited)
+}
+
+// Is reports whether BusyError matches the target error.
+func (e *BusyError) Is(target error) bool {
+	return target == ErrScannerBusy
+}
+
+// wait returns how long to wait before the next attempt, based
+// on the Retry-After response header.
+func (policy RetryPolicy) wait(hdr http.Header, now time.Time) time.Duration {
+	wait := policy.DefaultWait
+	if d, ok := retryAfter(hdr.Get("Retry-After"), now); ok {
+		wait = d
+	}
+
+	if policy.MaxWait > 0 && wait > policy.MaxWait {
+		wait = policy.MaxWait
+	}
+
+	return wait
+}
+
+// retryAfter parses the Retry-After header value, which may be
+// either delay in seconds or HTTP-date. The HTTP-date is converted
+// into delay, relative to now.
+func retryAfter(s string, now time.Time) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(s); err == nil {
+		d := t.Sub(now)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
+
+// retry performs request with retries, according to the Client's
+// RetryPolicy. The do callback performs a single attempt.
+func (c *Client) retry(ctx context.Context,
+	do func() (*HTTPDetails, error)) (details *HTTPDetails, err error) {
+
+	policy := c.retryPolicy
+	var waited time.Duration
+
+	for attempt := 1; ; attempt++ {
+		details, err = do()
+		if details == nil ||
+			details.StatusCode != http.StatusServiceUnavailable ||
+			policy.MaxAttempts < 2 {
+			return
+		}
+
+		if attempt >= policy.MaxAttempts {
+			var hint time.Duration
+			if e, ok := err.(*StatusError); ok {
+				hint = e.RetryAfter
+			}
+
+			err = &BusyError{
+				Attempts:   attempt,
+				Waited:     waited,
+				RetryAfter: hint,
+			}
+			return
+		}
+
+		wait := policy.wait(details.Header, c.now())
+		log.Debug(ctx, "eSCL: scanner busy, retry in %s", wait)
+
+		err = c.sleep(ctx, wait)
+		if err != nil {
+			return
+		}
+
+		waited += wait
+	}
+}
+
+// retrySleep waits for the specified duration or until
+// context is canceled.
+func retrySleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,227 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// eSCL core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Client retry policy test
+
+package escl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/transport/testutil"
+)
+
+// testRetryNow is the fake "current time" for retry tests
+var testRetryNow = time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestBusyScanner creates the fake scanner, that responds with
+// the HTTP 503 status to the first `busy` requests.
+func newTestBusyScanner(busy int,
+	retryAfter ...string) *testutil.FakeESCLScanner {
+
+	scanner := testutil.NewFakeESCLScanner(nil, testutil.Options{})
+	scanner.SetPages(testutil.FakePage{Data: []byte("image")})
+	scanner.SetHook(testutil.FailN(busy,
+		http.StatusServiceUnavailable, retryAfter...))
+
+	return scanner
+}
+
+// testRetryClient creates the Client with the fake clock.
+// Waits are recorded into the returned slice.
+func testRetryClient(u string) (*Client, *[]time.Duration) {
+	clnt := NewClient(transport.MustParseURL(u+"/eSCL"), nil)
+	waits := &[]time.Duration{}
+
+	clnt.now = func() time.Time { return testRetryNow }
+	clnt.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return ctx.Err()
+	}
+
+	return clnt, waits
+}
+
+// TestClientRetry tests retries of the busy scanner requests
+func TestClientRetry(t *testing.T) {
+	ctx := context.Background()
+	date := testRetryNow.Add(7 * time.Second).Format(http.TimeFormat)
+
+	// Scanner is busy for the first two attempts
+	scanner := newTestBusyScanner(2, "3", date)
+	defer scanner.Close()
+
+	clnt, waits := testRetryClient(scanner.URL)
+	joburl, _, err := clnt.Scan(ctx, ScanSettings{})
+	if err != nil {
+		t.Fatalf("Scan: %s", err)
+	}
+
+	if joburl != "/eSCL/ScanJobs/1" {
+		t.Errorf("Scan: JobUri mismatch: %q", joburl)
+	}
+
+	expected := []time.Duration{3 * time.Second, 7 * time.Second}
+	if !reflect.DeepEqual(*waits, expected) {
+		t.Errorf("Scan: waits mismatch:\nexpected: %v\npresent:  %v",
+			expected, *waits)
+	}
+
+	// NextDocument is retried the same way. Missed Retry-After
+	// means DefaultWait, too long one is truncated to MaxWait.
+	scanner.Log().Reset()
+	scanner.SetHook(testutil.FailN(2, http.StatusServiceUnavailable,
+		"", "3600"))
+	*waits = nil
+
+	doc, _, err := clnt.NextDocument(ctx, joburl)
+	if err != nil {
+		t.Fatalf("NextDocument: %s", err)
+	}
+	doc.Close()
+
+	expected = []time.Duration{
+		DefaultRetryPolicy.DefaultWait,
+		DefaultRetryPolicy.MaxWait,
+	}
+	if !reflect.DeepEqual(*waits, expected) {
+		t.Errorf("NextDocument: waits mismatch:\n"+
+			"expected: %v\npresent:  %v", expected, *waits)
+	}
+}
+
+// TestClientRetryBusy tests BusyError when scanner never frees up
+func TestClientRetryBusy(t *testing.T) {
+	scanner := newTestBusyScanner(1000, "1", "1", "1", "1", "1", "1")
+	defer scanner.Close()
+
+	clnt, waits := testRetryClient(scanner.URL)
+	_, details, err := clnt.Scan(context.Background(), ScanSettings{})
+
+	var busy *BusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("Scan: BusyError expected, present: %v", err)
+	}
+
+	attempts := DefaultRetryPolicy.MaxAttempts
+	waited := time.Duration(attempts-1) * time.Second
+
+	if busy.Attempts != attempts || busy.Waited != waited {
+		t.Errorf("BusyError mismatch:\n"+
+			"expected: %d attempts, %s waited\n"+
+			"present:  %d attempts, %s waited",
+			attempts, waited, busy.Attempts, busy.Waited)
+	}
+
+	if scanner.Log().Len() != attempts || len(*waits) != attempts-1 {
+		t.Errorf("%d requests, %d waits",
+			scanner.Log().Len(), len(*waits))
+	}
+
+	if details == nil ||
+		details.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("HTTPDetails of the last response expected")
+	}
+
+	// With retries disabled, the first 503 is returned as is
+	scanner.Log().Reset()
+	clnt.SetRetryPolicy(NoRetryPolicy)
+	_, _, err = clnt.Scan(context.Background(), ScanSettings{})
+
+	if err == nil || errors.As(err, &busy) || scanner.Log().Len() != 1 {
+		t.Errorf("NoRetryPolicy: %d requests, err: %v",
+			scanner.Log().Len(), err)
+	}
+}
+
+// TestClientRetry4xx tests that 4xx responses are not retried
+func TestClientRetry4xx(t *testing.T) {
+	scanner := testutil.NewFakeESCLScanner(nil, testutil.Options{})
+	scanner.SetHook(testutil.FailN(1, http.StatusConflict, "1"))
+	defer scanner.Close()
+
+	clnt, waits := testRetryClient(scanner.URL)
+	_, details, err := clnt.Scan(context.Background(), ScanSettings{})
+
+	if err == nil {
+		t.Errorf("Scan: error expected")
+	}
+
+	if details == nil || details.StatusCode != http.StatusConflict {
+		t.Errorf("Scan: HTTP 409 expected")
+	}
+
+	if scanner.Log().Len() != 1 || len(*waits) != 0 {
+		t.Errorf("4xx retried: %d requests, %d waits",
+			scanner.Log().Len(), len(*waits))
+	}
+}
+
+// TestClientRetryContext tests that wait is interrupted by
+// the context cancellation.
+func TestClientRetryContext(t *testing.T) {
+	scanner := newTestBusyScanner(1000, "3600")
+	defer scanner.Close()
+
+	clnt := NewClient(transport.MustParseURL(scanner.URL+"/eSCL"), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := clnt.Scan(ctx, ScanSettings{})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Scan: context.DeadlineExceeded expected, "+
+			"present: %v", err)
+	}
+
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Scan: wait not interrupted by context")
+	}
+}
+
+// TestRetryAfter tests Retry-After parsing
+func TestRetryAfter(t *testing.T) {
+	type testData struct {
+		in   string
+		wait time.Duration
+		ok   bool
+	}
+
+	tests := []testData{
+		{"", 0, false},
+		{"120", 120 * time.Second, true},
+		{" 5 ", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{
+			in:   testRetryNow.Add(time.Minute).Format(http.TimeFormat),
+			wait: time.Minute,
+			ok:   true,
+		},
+		{
+			in:   testRetryNow.Add(-time.Minute).Format(http.TimeFormat),
+			wait: 0,
+			ok:   true,
+		},
+	}
+
+	for _, test := range tests {
+		wait, ok := retryAfter(test.in, testRetryNow)
+		if wait != test.wait || ok != test.ok {
+			t.Errorf("%q:\nexpected: %s %v\npresent:  %s %v",
+				test.in, test.wait, test.ok, wait, ok)
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/OpenPrinting/go-mfp/proto/ipp/iana"
 	"github.com/OpenPrinting/go-mfp/util/generic"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
 	"github.com/OpenPrinting/goipp"
 )
 
@@ -129,6 +130,9 @@ func attrFieldCompatible(fld reflect.StructField, def *iana.DefAttr) bool {
 	case reflect.TypeOf(time.Time{}):
 		return def.HasTag(goipp.TagDateTime)
 
+	case reflect.TypeOf(uuid.UUID{}):
+		return def.HasTag(goipp.TagURI)
+
 	case reflect.TypeOf(""):
 		for _, tag := range def.Tags {
 			t := tag.Type()
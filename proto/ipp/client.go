@@ -31,12 +31,20 @@ type Client struct {
 	HTTPClient *transport.Client // HTTP Client
 	RequestID  uint32            // RequestID of the next request
 	decoderOpt *DecoderOptions   // Options for message decoder
+	version    goipp.Version     // Pinned IPP version, 0 if none
+	strict     Strictness        // Enforcement of RFC 8011 limits
+	limits     ResponseLimits    // Limits of the received responses
 }
 
 // NewClient creates a new IPP client.
 //
 // If tr is nil, [transport.NewTransport] will be used to create
 // a new transport.
+//
+// To share connections with clients of other protocols, talking
+// to the same device, use the [transport.SharedPool] transport:
+//
+//	clnt := NewClient(u, transport.PoolFor(u).Transport())
 func NewClient(u *url.URL, tr *transport.Transport) *Client {
 	c := &Client{
 		URL:        u,
@@ -52,6 +60,28 @@ func (c *Client) SetDecoderOptions(opt *DecoderOptions) {
 	c.decoderOpt = opt
 }
 
+// SetStrictness sets enforcement of the RFC 8011 limits of the
+// attribute values for the subsequent requests (see [CheckLimits]).
+//
+// Violations are detected before the request is sent, so the
+// request fails with the [LimitError] naming the attribute, instead
+// of being opaquely rejected by printer. Truncated values are
+// logged as warnings.
+//
+// The default is [StrictnessOff].
+func (c *Client) SetStrictness(strict Strictness) {
+	c.strict = strict
+}
+
+// SetResponseLimits sets limits of the received IPP responses.
+// Responses that exceed them fail with the [ErrResponseTooLarge].
+//
+// Zero fields of limits are replaced with the corresponding
+// fields of the [DefaultResponseLimits].
+func (c *Client) SetResponseLimits(limits ResponseLimits) {
+	c.limits = limits
+}
+
 // requestid generates a next RequestID
 func (c *Client) requestid() uint32 {
 	// IPP doesn't allow RequestID to be zero, so roll
@@ -64,10 +94,20 @@ func (c *Client) requestid() uint32 {
 	return id
 }
 
+// SetVersion pins the IPP version, used for requests, disabling
+// the version negotiation. Zero version restores negotiation.
+//
+// By default, requests are sent with the [DefaultVersion]. If printer
+// rejects it, the request is retried once with the [FallbackVersion],
+// and the working version is remembered for the subsequent requests.
+func (c *Client) SetVersion(ver goipp.Version) {
+	c.version = ver
+}
+
 // Do sends the [Request] and waits for [Response].
 //
 // The following Request fields are filled automatically:
-//   - Version, if zero, will be set to goipp.DefaultVersion
+//   - Version, if zero, will be negotiated (see [Client.SetVersion])
 //   - RequestID will be set to next Client's RequestID in sequence
 //
 // It automatically closes Response Body. This is convenient
@@ -88,25 +128,99 @@ func (c *Client) Do(ctx context.Context, rq Request, rsp Response) error {
 // DoWithBody sends the Request and waits for Response.
 //
 // The following Request fields are filled automatically:
-//   - Version, if zero, will be set to goipp.DefaultVersion
+//   - Version, if zero, will be negotiated (see [Client.SetVersion])
 //   - RequestID will be set to next Client's RequestID in sequence
 //
+// Requests with body are never retried, so the version is only
+// negotiated by requests without body.
+//
 // On success, caller MUST close Response body after use.
+//
+// Network failures are returned as [transport.ClientError],
+// which allows to classify them with [transport.ClassifyError].
 func (c *Client) DoWithBody(ctx context.Context,
 	rq Request, rsp Response) error {
 
 	// Encode IPP message
-	buf := &bytes.Buffer{}
 	msg := rq.Encode()
+	body := rq.Header().Body
 
-	if msg.Version == 0 {
-		msg.Version = goipp.DefaultVersion
+	// Enforce RFC 8011 limits before anything is sent
+	warnings, err := CheckMessageLimits(msg, c.strict)
+	for _, w := range warnings {
+		log.Warning(ctx, "IPP: %s", w)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	negotiate := msg.Version == 0 && c.version == 0
+	switch {
+	case msg.Version != 0:
+	case c.version != 0:
+		msg.Version = c.version
+	default:
+		msg.Version = clientVersionCacheGet(c.URL.String())
+		if msg.Version == 0 {
+			msg.Version = DefaultVersion
+		}
 	}
 
 	if msg.RequestID == 0 {
 		msg.RequestID = c.requestid()
 	}
 
+	// Call server. Retry with the lower version, if needed.
+	httpRsp, rspMsg, err := c.roundTrip(ctx, msg, body)
+	if negotiate && body == nil &&
+		clientVersionRetry(msg.Version, rspMsg, err) {
+
+		log.Debug(ctx, "IPP: version %s not supported, retrying with %s",
+			msg.Version, FallbackVersion)
+
+		if err == nil {
+			httpRsp.Body.Close()
+		}
+
+		msg.Version = FallbackVersion
+		msg.RequestID = c.requestid()
+
+		httpRsp, rspMsg, err = c.roundTrip(ctx, msg, nil)
+		if err == nil && goipp.Status(rspMsg.Code) !=
+			goipp.StatusErrorVersionNotSupported {
+			clientVersionCacheSet(c.URL.String(), FallbackVersion)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Decode Response
+	err = rsp.Decode(rspMsg, c.decoderOpt)
+	if err != nil {
+		log.Debug(ctx, "HTTP POST %s - %s", c.URL, err)
+		httpRsp.Body.Close()
+		return err
+	}
+
+	// Save IPPMessage, remainder of body and return
+	rsp.Header().IPPMessage = rspMsg
+	rsp.Header().Body = httpRsp.Body
+
+	return nil
+}
+
+// roundTrip sends the IPP request message, followed by the optional
+// body, and returns the HTTP response and the decoded IPP response
+// message.
+//
+// On success, caller MUST close HTTP response body after use.
+func (c *Client) roundTrip(ctx context.Context, msg *goipp.Message,
+	body io.Reader) (*http.Response, *goipp.Message, error) {
+
+	buf := &bytes.Buffer{}
 	msg.Encode(buf)
 
 	// Log the IPP request
@@ -116,7 +230,6 @@ func (c *Client) DoWithBody(ctx context.Context,
 	log.Debug(ctx, "IPP request:\n%s", f.Bytes())
 
 	// Attach Request body, if any
-	body := rq.Header().Body
 	if body == nil {
 		body = buf
 	} else {
@@ -126,7 +239,7 @@ func (c *Client) DoWithBody(ctx context.Context,
 	// Create HTTP request
 	httpRq, err := transport.NewRequest(ctx, "POST", c.URL, body)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	httpRq.Header.Set("Content-Type", "application/ipp")
@@ -136,7 +249,7 @@ func (c *Client) DoWithBody(ctx context.Context,
 	if strings.ToLower(httpRq.URL.Scheme) == "unix" {
 		usr, err := user.Current()
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 
 		auth := fmt.Sprintf("PeerCred %s", usr.Username)
@@ -144,9 +257,10 @@ func (c *Client) DoWithBody(ctx context.Context,
 	}
 
 	// Call server
+	var guard *rspGuard
 	httpRsp, err := c.HTTPClient.Do(httpRq)
 	if err != nil {
-		return err
+		return nil, nil, transport.WrapError(err, c.URL)
 	}
 
 	if httpRsp.StatusCode != http.StatusOK {
@@ -155,8 +269,15 @@ func (c *Client) DoWithBody(ctx context.Context,
 	}
 
 	// Decode IPP message
-	msg.Reset()
-	err = msg.Decode(httpRsp.Body)
+	guard = newRspGuard(httpRsp.Body,
+		c.limits.withDefaults(DefaultResponseLimits))
+
+	msg = &goipp.Message{}
+	err = msg.Decode(guard)
+	if guard.err != nil {
+		err = guard.err
+	}
+
 	if err != nil {
 		goto ERROR
 	}
@@ -167,22 +288,12 @@ func (c *Client) DoWithBody(ctx context.Context,
 	f.FmtResponse(msg)
 	log.Debug(ctx, "IPP response:\n%s", f.Bytes())
 
-	// Decode Response
-	err = rsp.Decode(msg, c.decoderOpt)
-	if err != nil {
-		goto ERROR
-	}
-
-	// Save IPPMessage, remainder of body and return
-	rsp.Header().IPPMessage = msg
-	rsp.Header().Body = httpRsp.Body
-
-	return nil
+	return httpRsp, msg, nil
 
 ERROR:
 	log.Debug(ctx, "HTTP %s %s - %s", httpRq.Method, httpRq.URL, err)
 	httpRsp.Body.Close()
-	return err
+	return nil, nil, err
 }
 
 // GetPrinterAttributes returns printer attributes.
@@ -0,0 +1,160 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// IPP client tests
+
+package ipp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// testVersionPrinter is the IPP printer that supports IPP versions
+// up to the specified maximum, and records versions of the received
+// requests.
+type testVersionPrinter struct {
+	*httptest.Server
+	versions []goipp.Version
+	lock     sync.Mutex
+}
+
+// newTestVersionPrinter creates a new testVersionPrinter
+func newTestVersionPrinter(maxVersion goipp.Version) *testVersionPrinter {
+	prn := &testVersionPrinter{}
+
+	attrs := &PrinterAttributes{}
+	attrs.PrinterName = optional.New("test")
+
+	options := PrinterOptions{
+		ServerOptions: ServerOptions{
+			MaxVersion: maxVersion,
+			Hooks: ServerHooks{
+				OnIPPRequest: func(_ *transport.ServerQuery,
+					msg *goipp.Message) *goipp.Message {
+					prn.lock.Lock()
+					prn.versions = append(prn.versions,
+						msg.Version)
+					prn.lock.Unlock()
+					return nil
+				},
+			},
+		},
+	}
+
+	prn.Server = httptest.NewServer(NewPrinter(attrs, options))
+	return prn
+}
+
+// received returns versions of requests, received since the last call.
+func (prn *testVersionPrinter) received() []goipp.Version {
+	prn.lock.Lock()
+	defer prn.lock.Unlock()
+
+	versions := prn.versions
+	prn.versions = nil
+	return versions
+}
+
+// TestClientVersionNegotiation tests IPP version negotiation
+func TestClientVersionNegotiation(t *testing.T) {
+	defer clientVersionCachePurge()
+
+	ctx := context.Background()
+	v11 := goipp.MakeVersion(1, 1)
+	v20 := goipp.MakeVersion(2, 0)
+
+	prn := newTestVersionPrinter(v11)
+	defer prn.Close()
+
+	// The first request must trigger the downgrade
+	c := NewClient(transport.MustParseURL(prn.URL), nil)
+	_, err := c.GetPrinterAttributes(ctx, nil, "")
+	if err != nil {
+		t.Fatalf("GetPrinterAttributes: %s", err)
+	}
+
+	expected := []goipp.Version{DefaultVersion, v11}
+	if received := prn.received(); !slices.Equal(received, expected) {
+		t.Errorf("1st request: versions expected %s, present %s",
+			expected, received)
+	}
+
+	// The second request, even by the new Client, must reuse
+	// the cached version
+	c = NewClient(transport.MustParseURL(prn.URL), nil)
+	_, err = c.GetPrinterAttributes(ctx, nil, "")
+	if err != nil {
+		t.Fatalf("GetPrinterAttributes: %s", err)
+	}
+
+	expected = []goipp.Version{v11}
+	if received := prn.received(); !slices.Equal(received, expected) {
+		t.Errorf("2nd request: versions expected %s, present %s",
+			expected, received)
+	}
+
+	// Pinned version must be used as is, without retry
+	c.SetVersion(v20)
+	_, err = c.GetPrinterAttributes(ctx, nil, "")
+	if err == nil {
+		t.Errorf("pinned version: expected error, present nil")
+	}
+
+	expected = []goipp.Version{v20}
+	if received := prn.received(); !slices.Equal(received, expected) {
+		t.Errorf("pinned version: versions expected %s, present %s",
+			expected, received)
+	}
+}
+
+// TestServerVersionNotSupported tests the server-error-version-not-supported
+// response of the Server
+func TestServerVersionNotSupported(t *testing.T) {
+	prn := newTestVersionPrinter(goipp.MakeVersion(1, 1))
+	defer prn.Close()
+
+	rq := goipp.NewRequest(goipp.MakeVersion(2, 0),
+		goipp.OpGetPrinterAttributes, 1)
+	rq.Operation.Add(goipp.MakeAttribute("attributes-charset",
+		goipp.TagCharset, goipp.String(DefaultCharset)))
+	rq.Operation.Add(goipp.MakeAttribute("attributes-natural-language",
+		goipp.TagLanguage, goipp.String(DefaultNaturalLanguage)))
+
+	data, _ := rq.EncodeBytes()
+	httpRsp, err := http.Post(prn.URL, "application/ipp",
+		bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("HTTP POST: %s", err)
+	}
+	defer httpRsp.Body.Close()
+
+	rsp := &goipp.Message{}
+	err = rsp.Decode(httpRsp.Body)
+	if err != nil {
+		t.Fatalf("IPP response: %s", err)
+	}
+
+	status := goipp.Status(rsp.Code)
+	if status != goipp.StatusErrorVersionNotSupported {
+		t.Errorf("Status: expected %s, present %s",
+			goipp.StatusErrorVersionNotSupported, status)
+	}
+
+	if rsp.Version != goipp.MakeVersion(1, 1) {
+		t.Errorf("Version: expected %s, present %s",
+			goipp.MakeVersion(1, 1), rsp.Version)
+	}
+}
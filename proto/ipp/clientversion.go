@@ -0,0 +1,100 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// IPP version negotiation
+
+package ipp
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"syscall"
+
+	"github.com/OpenPrinting/goipp"
+)
+
+// FallbackVersion is the IPP version, [Client] falls back to, when
+// printer rejects the request with the higher version.
+const FallbackVersion goipp.Version = 0x0101
+
+// clientVersionCacheSize is the maximum number of entries in the
+// clientVersionCache.
+const clientVersionCacheSize = 64
+
+// clientVersionCache remembers the working IPP version for printers
+// that don't support the DefaultVersion, indexed by the printer URL.
+//
+// It is shared between all Clients, so the negotiation is performed
+// only once per printer.
+var clientVersionCache = struct {
+	versions map[string]goipp.Version
+	lock     sync.Mutex
+}{
+	versions: make(map[string]goipp.Version),
+}
+
+// clientVersionCacheGet returns the cached IPP version for the
+// printer URL or zero, if printer is not in the cache.
+func clientVersionCacheGet(url string) goipp.Version {
+	clientVersionCache.lock.Lock()
+	defer clientVersionCache.lock.Unlock()
+
+	return clientVersionCache.versions[url]
+}
+
+// clientVersionCacheSet saves the working IPP version for the
+// printer URL.
+func clientVersionCacheSet(url string, ver goipp.Version) {
+	clientVersionCache.lock.Lock()
+	defer clientVersionCache.lock.Unlock()
+
+	// The cache is small and only contains exceptions, so if
+	// it overflows, simply start from scratch.
+	if len(clientVersionCache.versions) >= clientVersionCacheSize {
+		clear(clientVersionCache.versions)
+	}
+
+	clientVersionCache.versions[url] = ver
+}
+
+// clientVersionCachePurge purges the clientVersionCache.
+//
+// This is the testing interface.
+func clientVersionCachePurge() {
+	clientVersionCache.lock.Lock()
+	clear(clientVersionCache.versions)
+	clientVersionCache.lock.Unlock()
+}
+
+// clientVersionRetry reports if request, sent with the IPP version
+// ver, needs to be retried with the FallbackVersion, given its
+// outcome.
+//
+// Retry is needed, if printer has responded with the
+// server-error-version-not-supported status, or, which is a common
+// behavior of some old firmware, silently closed the connection
+// after receiving the IPP/2.x request.
+func clientVersionRetry(ver goipp.Version, msg *goipp.Message,
+	err error) bool {
+
+	if ver <= FallbackVersion {
+		return false
+	}
+
+	switch {
+	case err == nil:
+		return goipp.Status(msg.Code) ==
+			goipp.StatusErrorVersionNotSupported
+
+	case ver.Major() >= 2:
+		return errors.Is(err, io.EOF) ||
+			errors.Is(err, io.ErrUnexpectedEOF) ||
+			errors.Is(err, syscall.ECONNRESET)
+	}
+
+	return false
+}
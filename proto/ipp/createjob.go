@@ -58,11 +58,13 @@ func (rq *CreateJobRequest) Encode() *goipp.Message {
 			Tag:   goipp.TagOperationGroup,
 			Attrs: enc.Encode(rq),
 		},
+	}
 
-		{
+	if rq.JobTemplate != nil {
+		groups.Add(goipp.Group{
 			Tag:   goipp.TagJobGroup,
 			Attrs: enc.Encode(rq.JobTemplate),
-		},
+		})
 	}
 
 	msg := goipp.NewMessageWithGroups(rq.Version, goipp.Code(rq.GetOp()),
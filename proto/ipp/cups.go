@@ -148,6 +148,172 @@ type (
 		// Operational attributes
 		PrinterURI optional.Val[string] `ipp:"printer-uri"`
 	}
+
+	// CUPSAddModifyPrinterRequest operation (0x4003) adds a new
+	// printer or modifies the existing one.
+	//
+	// Only attributes, present in the Printer, are sent, and CUPS
+	// leaves the missed attributes of the existing printer unchanged.
+	CUPSAddModifyPrinterRequest struct {
+		ObjectRawAttrs
+		RequestHeader
+		OperationGroup
+
+		// Operational attributes
+		PrinterURI string `ipp:"printer-uri"`
+
+		// Other attributes.
+		Printer *CUPSPrinterSettings
+	}
+
+	// CUPSAddModifyPrinterResponse is the CUPS-Add-Modify-Printer
+	// Response.
+	CUPSAddModifyPrinterResponse struct {
+		ObjectRawAttrs
+		ResponseHeader
+		OperationGroup
+	}
+
+	// CUPSGetClassesRequest operation (0x4005) returns the printer
+	// attributes for every printer class known to the system.
+	CUPSGetClassesRequest struct {
+		ObjectRawAttrs
+		RequestHeader
+		OperationGroup
+
+		// Operation attributes
+		FirstPrinterName    optional.Val[string] `ipp:"first-printer-name"`
+		Limit               optional.Val[int]    `ipp:"limit"`
+		PrinterLocation     optional.Val[string] `ipp:"printer-location"`
+		PrinterType         optional.Val[int]    `ipp:"printer-type"`
+		PrinterTypeMask     optional.Val[int]    `ipp:"printer-type-mask"`
+		RequestedAttributes []string             `ipp:"requested-attributes"`
+		RequestedUserName   optional.Val[string] `ipp:"requested-user-name,name"`
+	}
+
+	// CUPSGetClassesResponse is the CUPS-Get-Classes Response.
+	//
+	// Class members are reported by the "member-names" and
+	// "member-uris" attributes of each class.
+	CUPSGetClassesResponse struct {
+		ObjectRawAttrs
+		ResponseHeader
+		OperationGroup
+
+		// Other attributes.
+		Printer []*PrinterAttributes
+	}
+
+	// CUPSAddModifyClassRequest operation (0x4006) adds a new
+	// printer class or modifies the existing one.
+	//
+	// Like CUPSAddModifyPrinterRequest, only attributes, present
+	// in the Class, are sent. If MemberURIs is set, it replaces
+	// the whole list of the class members.
+	CUPSAddModifyClassRequest struct {
+		ObjectRawAttrs
+		RequestHeader
+		OperationGroup
+
+		// Operational attributes
+		PrinterURI string `ipp:"printer-uri"`
+
+		// Other attributes.
+		Class *CUPSClassSettings
+	}
+
+	// CUPSAddModifyClassResponse is the CUPS-Add-Modify-Class
+	// Response.
+	CUPSAddModifyClassResponse struct {
+		ObjectRawAttrs
+		ResponseHeader
+		OperationGroup
+	}
+
+	// CUPSDeleteClassRequest operation (0x4007) deletes the
+	// printer class.
+	CUPSDeleteClassRequest struct {
+		ObjectRawAttrs
+		RequestHeader
+		OperationGroup
+
+		// Operational attributes
+		PrinterURI string `ipp:"printer-uri"`
+	}
+
+	// CUPSDeleteClassResponse is the CUPS-Delete-Class Response.
+	CUPSDeleteClassResponse struct {
+		ObjectRawAttrs
+		ResponseHeader
+		OperationGroup
+	}
+
+	// CUPSGetDocumentRequest operation (0x4027) returns the document
+	// file of the job.
+	//
+	// CUPS keeps the document files of the completed jobs only if
+	// PreserveJobFiles is enabled in the cupsd.conf.
+	CUPSGetDocumentRequest struct {
+		ObjectRawAttrs
+		RequestHeader
+		OperationGroup
+
+		// Operational attributes
+		PrinterURI         string               `ipp:"printer-uri"`
+		JobID              int                  `ipp:"job-id"`
+		DocumentNumber     int                  `ipp:"document-number"`
+		RequestingUserName optional.Val[string] `ipp:"requesting-user-name"`
+	}
+
+	// CUPSGetDocumentResponse is the CUPS-Get-Document Response.
+	//
+	// If the document is found, goipp.StatusOk is returned with the
+	// document file represented by the ResponseHeader.Body.
+	//
+	// If the job, the document or the document file doesn't exist,
+	// goipp.StatusErrorNotFound is returned.
+	CUPSGetDocumentResponse struct {
+		ObjectRawAttrs
+		ResponseHeader
+		OperationGroup
+
+		// Operational attributes
+		DocumentFormat optional.Val[string] `ipp:"document-format"`
+		DocumentName   optional.Val[string] `ipp:"document-name"`
+		DocumentNumber optional.Val[int]    `ipp:"document-number"`
+	}
+
+	// CUPSPrinterSettings contains printer attributes, that can be
+	// set by the CUPS-Add-Modify-Printer request.
+	//
+	// All attributes are optional; unset attributes are not sent.
+	CUPSPrinterSettings struct {
+		ObjectRawAttrs
+		PrinterDescriptionGroup
+
+		DeviceURI          optional.Val[string]               `ipp:"device-uri"`
+		JobSheetsDefault   []KwJobSheets                      `ipp:"job-sheets-default"`
+		PrinterErrorPolicy optional.Val[KwPrinterErrorPolicy] `ipp:"printer-error-policy"`
+		PrinterInfo        optional.Val[string]               `ipp:"printer-info"`
+		PrinterIsShared    optional.Val[bool]                 `ipp:"printer-is-shared"`
+		PrinterLocation    optional.Val[string]               `ipp:"printer-location"`
+		PrinterOpPolicy    optional.Val[KwPrinterOpPolicy]    `ipp:"printer-op-policy"`
+	}
+
+	// CUPSClassSettings contains class attributes, that can be
+	// set by the CUPS-Add-Modify-Class request.
+	//
+	// All attributes are optional; unset attributes are not sent.
+	CUPSClassSettings struct {
+		ObjectRawAttrs
+		PrinterDescriptionGroup
+		CUPSPrinterClassAttributesGroup
+
+		MemberURIs      []string             `ipp:"member-uris"`
+		PrinterInfo     optional.Val[string] `ipp:"printer-info"`
+		PrinterIsShared optional.Val[bool]   `ipp:"printer-is-shared"`
+		PrinterLocation optional.Val[string] `ipp:"printer-location"`
+	}
 )
 
 // ----- CUPS-Get-Default methods -----
@@ -609,3 +775,441 @@ func (rsp *CUPSGetPPDResponse) Decode(
 
 	return nil
 }
+
+// ----- CUPS-Add-Modify-Printer methods -----
+
+// GetOp returns CUPSAddModifyPrinterRequest IPP Operation code.
+func (rq *CUPSAddModifyPrinterRequest) GetOp() goipp.Op {
+	return goipp.OpCupsAddModifyPrinter
+}
+
+// Encode encodes CUPSAddModifyPrinterRequest into the goipp.Message.
+func (rq *CUPSAddModifyPrinterRequest) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rq),
+		},
+	}
+
+	if rq.Printer != nil {
+		groups.Add(goipp.Group{
+			Tag:   goipp.TagPrinterGroup,
+			Attrs: enc.Encode(rq.Printer),
+		})
+	}
+
+	msg := goipp.NewMessageWithGroups(rq.Version, goipp.Code(rq.GetOp()),
+		rq.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes CUPSAddModifyPrinterRequest from goipp.Message.
+func (rq *CUPSAddModifyPrinterRequest) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rq.Version = msg.Version
+	rq.RequestID = msg.RequestID
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rq, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	if len(msg.Printer) != 0 {
+		rq.Printer = &CUPSPrinterSettings{}
+		err = dec.Decode(rq.Printer, msg.Printer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Encode encodes CUPSAddModifyPrinterResponse into goipp.Message.
+func (rsp *CUPSAddModifyPrinterResponse) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rsp),
+		},
+	}
+
+	msg := goipp.NewMessageWithGroups(rsp.Version, goipp.Code(rsp.Status),
+		rsp.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes CUPSAddModifyPrinterResponse from goipp.Message.
+func (rsp *CUPSAddModifyPrinterResponse) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rsp.Version = msg.Version
+	rsp.RequestID = msg.RequestID
+	rsp.Status = goipp.Status(msg.Code)
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rsp, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ----- CUPS-Get-Classes methods -----
+
+// GetOp returns CUPSGetClassesRequest IPP Operation code.
+func (rq *CUPSGetClassesRequest) GetOp() goipp.Op {
+	return goipp.OpCupsGetClasses
+}
+
+// Encode encodes CUPSGetClassesRequest into the goipp.Message.
+func (rq *CUPSGetClassesRequest) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rq),
+		},
+	}
+
+	msg := goipp.NewMessageWithGroups(rq.Version, goipp.Code(rq.GetOp()),
+		rq.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes CUPSGetClassesRequest from goipp.Message.
+func (rq *CUPSGetClassesRequest) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rq.Version = msg.Version
+	rq.RequestID = msg.RequestID
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rq, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Encode encodes CUPSGetClassesResponse into goipp.Message.
+func (rsp *CUPSGetClassesResponse) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rsp),
+		},
+	}
+
+	for _, prn := range rsp.Printer {
+		groups.Add(goipp.Group{
+			Tag:   goipp.TagPrinterGroup,
+			Attrs: enc.Encode(prn),
+		})
+	}
+
+	msg := goipp.NewMessageWithGroups(rsp.Version, goipp.Code(rsp.Status),
+		rsp.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes CUPSGetClassesResponse from goipp.Message.
+func (rsp *CUPSGetClassesResponse) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rsp.Version = msg.Version
+	rsp.RequestID = msg.RequestID
+	rsp.Status = goipp.Status(msg.Code)
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rsp, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	for _, grp := range msg.Groups {
+		if grp.Tag == goipp.TagPrinterGroup && len(grp.Attrs) > 0 {
+			prn, err := DecodePrinterAttributes(grp.Attrs, opt)
+			if err != nil {
+				return err
+			}
+
+			rsp.Printer = append(rsp.Printer, prn)
+		}
+	}
+
+	return nil
+}
+
+// ----- CUPS-Add-Modify-Class methods -----
+
+// GetOp returns CUPSAddModifyClassRequest IPP Operation code.
+func (rq *CUPSAddModifyClassRequest) GetOp() goipp.Op {
+	return goipp.OpCupsAddModifyClass
+}
+
+// Encode encodes CUPSAddModifyClassRequest into the goipp.Message.
+func (rq *CUPSAddModifyClassRequest) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rq),
+		},
+	}
+
+	if rq.Class != nil {
+		groups.Add(goipp.Group{
+			Tag:   goipp.TagPrinterGroup,
+			Attrs: enc.Encode(rq.Class),
+		})
+	}
+
+	msg := goipp.NewMessageWithGroups(rq.Version, goipp.Code(rq.GetOp()),
+		rq.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes CUPSAddModifyClassRequest from goipp.Message.
+func (rq *CUPSAddModifyClassRequest) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rq.Version = msg.Version
+	rq.RequestID = msg.RequestID
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rq, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	if len(msg.Printer) != 0 {
+		rq.Class = &CUPSClassSettings{}
+		err = dec.Decode(rq.Class, msg.Printer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Encode encodes CUPSAddModifyClassResponse into goipp.Message.
+func (rsp *CUPSAddModifyClassResponse) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rsp),
+		},
+	}
+
+	msg := goipp.NewMessageWithGroups(rsp.Version, goipp.Code(rsp.Status),
+		rsp.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes CUPSAddModifyClassResponse from goipp.Message.
+func (rsp *CUPSAddModifyClassResponse) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rsp.Version = msg.Version
+	rsp.RequestID = msg.RequestID
+	rsp.Status = goipp.Status(msg.Code)
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rsp, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ----- CUPS-Delete-Class methods -----
+
+// GetOp returns CUPSDeleteClassRequest IPP Operation code.
+func (rq *CUPSDeleteClassRequest) GetOp() goipp.Op {
+	return goipp.OpCupsDeleteClass
+}
+
+// Encode encodes CUPSDeleteClassRequest into the goipp.Message.
+func (rq *CUPSDeleteClassRequest) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rq),
+		},
+	}
+
+	msg := goipp.NewMessageWithGroups(rq.Version, goipp.Code(rq.GetOp()),
+		rq.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes CUPSDeleteClassRequest from goipp.Message.
+func (rq *CUPSDeleteClassRequest) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rq.Version = msg.Version
+	rq.RequestID = msg.RequestID
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rq, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Encode encodes CUPSDeleteClassResponse into goipp.Message.
+func (rsp *CUPSDeleteClassResponse) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rsp),
+		},
+	}
+
+	msg := goipp.NewMessageWithGroups(rsp.Version, goipp.Code(rsp.Status),
+		rsp.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes CUPSDeleteClassResponse from goipp.Message.
+func (rsp *CUPSDeleteClassResponse) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rsp.Version = msg.Version
+	rsp.RequestID = msg.RequestID
+	rsp.Status = goipp.Status(msg.Code)
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rsp, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ----- CUPS-Get-Document methods -----
+
+// GetOp returns CUPSGetDocumentRequest IPP Operation code.
+func (rq *CUPSGetDocumentRequest) GetOp() goipp.Op {
+	return goipp.OpCupsGetDocument
+}
+
+// Encode encodes CUPSGetDocumentRequest into the goipp.Message.
+func (rq *CUPSGetDocumentRequest) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rq),
+		},
+	}
+
+	msg := goipp.NewMessageWithGroups(rq.Version, goipp.Code(rq.GetOp()),
+		rq.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes CUPSGetDocumentRequest from goipp.Message.
+func (rq *CUPSGetDocumentRequest) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rq.Version = msg.Version
+	rq.RequestID = msg.RequestID
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rq, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Encode encodes CUPSGetDocumentResponse into goipp.Message.
+func (rsp *CUPSGetDocumentResponse) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rsp),
+		},
+	}
+
+	msg := goipp.NewMessageWithGroups(rsp.Version, goipp.Code(rsp.Status),
+		rsp.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes CUPSGetDocumentResponse from goipp.Message.
+func (rsp *CUPSGetDocumentResponse) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rsp.Version = msg.Version
+	rsp.RequestID = msg.RequestID
+	rsp.Status = goipp.Status(msg.Code)
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rsp, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
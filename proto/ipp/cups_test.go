@@ -14,6 +14,8 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/util/optional"
 	"github.com/OpenPrinting/goipp"
 )
 
@@ -23,12 +25,22 @@ var (
 	_ Request = &CUPSGetDevicesRequest{}
 	_ Request = &CUPSGetPPDsRequest{}
 	_ Request = &CUPSGetPPDRequest{}
+	_ Request = &CUPSAddModifyPrinterRequest{}
+	_ Request = &CUPSGetClassesRequest{}
+	_ Request = &CUPSAddModifyClassRequest{}
+	_ Request = &CUPSDeleteClassRequest{}
+	_ Request = &CUPSGetDocumentRequest{}
 
 	_ Response = &CUPSGetDefaultResponse{}
 	_ Response = &CUPSGetPrintersResponse{}
 	_ Response = &CUPSGetDevicesResponse{}
 	_ Response = &CUPSGetPPDsResponse{}
 	_ Response = &CUPSGetPPDResponse{}
+	_ Response = &CUPSAddModifyPrinterResponse{}
+	_ Response = &CUPSGetClassesResponse{}
+	_ Response = &CUPSAddModifyClassResponse{}
+	_ Response = &CUPSDeleteClassResponse{}
+	_ Response = &CUPSGetDocumentResponse{}
 )
 
 // TestCupsRequests tests CUPS requests
@@ -119,6 +131,112 @@ func TestCupsRequests(t *testing.T) {
 
 			err: `IPP decode ipp.CUPSGetDefaultRequest: "attributes-charset": can't use integer as charset`,
 		},
+
+		// ----- CUPSAddModifyPrinterRequest tests -----
+		//
+		// Only the explicitly set printer attributes are sent.
+		{
+			op: 0x4003,
+
+			rq: &CUPSAddModifyPrinterRequest{
+				RequestHeader: hdr,
+				PrinterURI:    "ipp://localhost/printers/Test",
+				Printer: &CUPSPrinterSettings{
+					PrinterErrorPolicy: optional.New(
+						KwPrinterErrorPolicyStopPrinter),
+					PrinterIsShared: optional.New(true),
+				},
+			},
+
+			msg: goipp.NewMessageWithGroups(
+				ippVersion,
+				goipp.Code(goipp.OpCupsAddModifyPrinter),
+				ippRequestID,
+				goipp.Groups{
+					{
+						Tag: goipp.TagOperationGroup,
+						Attrs: []goipp.Attribute{
+							goipp.MakeAttribute(
+								"attributes-charset",
+								goipp.TagCharset,
+								goipp.String(DefaultCharset)),
+							goipp.MakeAttribute(
+								"attributes-natural-language",
+								goipp.TagLanguage,
+								goipp.String(DefaultNaturalLanguage)),
+							goipp.MakeAttribute(
+								"printer-uri",
+								goipp.TagURI,
+								goipp.String("ipp://localhost/printers/Test")),
+						},
+					},
+					{
+						Tag: goipp.TagPrinterGroup,
+						Attrs: []goipp.Attribute{
+							goipp.MakeAttribute(
+								"printer-error-policy",
+								goipp.TagName,
+								goipp.String("stop-printer")),
+							goipp.MakeAttribute(
+								"printer-is-shared",
+								goipp.TagBoolean,
+								goipp.Boolean(true)),
+						},
+					},
+				},
+			),
+		},
+
+		// ----- CUPSAddModifyClassRequest tests -----
+		{
+			op: 0x4006,
+
+			rq: &CUPSAddModifyClassRequest{
+				RequestHeader: hdr,
+				PrinterURI:    "ipp://localhost/classes/office",
+				Class: &CUPSClassSettings{
+					MemberURIs: []string{
+						"ipp://localhost/printers/Kyocera",
+						"ipp://localhost/printers/Xerox",
+					},
+				},
+			},
+
+			msg: goipp.NewMessageWithGroups(
+				ippVersion,
+				goipp.Code(goipp.OpCupsAddModifyClass),
+				ippRequestID,
+				goipp.Groups{
+					{
+						Tag: goipp.TagOperationGroup,
+						Attrs: []goipp.Attribute{
+							goipp.MakeAttribute(
+								"attributes-charset",
+								goipp.TagCharset,
+								goipp.String(DefaultCharset)),
+							goipp.MakeAttribute(
+								"attributes-natural-language",
+								goipp.TagLanguage,
+								goipp.String(DefaultNaturalLanguage)),
+							goipp.MakeAttribute(
+								"printer-uri",
+								goipp.TagURI,
+								goipp.String("ipp://localhost/classes/office")),
+						},
+					},
+					{
+						Tag: goipp.TagPrinterGroup,
+						Attrs: []goipp.Attribute{
+							goipp.MakeAttr(
+								"member-uris",
+								goipp.TagURI,
+								goipp.String("ipp://localhost/printers/Kyocera"),
+								goipp.String("ipp://localhost/printers/Xerox")),
+						},
+					},
+				},
+			),
+		},
 	}
 
 	for _, test := range tests {
@@ -309,3 +427,91 @@ func TestCupsRequesponses(t *testing.T) {
 		}
 	}
 }
+
+// TestCUPSGetClassesResponse tests decoding of the CUPS-Get-Classes
+// response with class members
+func TestCUPSGetClassesResponse(t *testing.T) {
+	class := func(name string, members ...string) goipp.Group {
+		names := goipp.Attribute{Name: "member-names"}
+		uris := goipp.Attribute{Name: "member-uris"}
+		for _, member := range members {
+			names.Values.Add(goipp.TagName, goipp.String(member))
+			uris.Values.Add(goipp.TagURI, goipp.String(
+				"ipp://localhost/printers/"+member))
+		}
+
+		grp := goipp.Group{
+			Tag: goipp.TagPrinterGroup,
+			Attrs: goipp.Attributes{
+				goipp.MakeAttribute("printer-name",
+					goipp.TagName, goipp.String(name)),
+				goipp.MakeAttribute("printer-type",
+					goipp.TagEnum, goipp.Integer(EnPrinterClass)),
+			},
+		}
+
+		// Empty class has no member attributes
+		if len(members) != 0 {
+			grp.Attrs.Add(names)
+			grp.Attrs.Add(uris)
+		}
+
+		return grp
+	}
+
+	msg := goipp.NewMessageWithGroups(
+		goipp.DefaultVersion,
+		goipp.Code(goipp.StatusOk),
+		1,
+		goipp.Groups{
+			{
+				Tag: goipp.TagOperationGroup,
+				Attrs: goipp.Attributes{
+					goipp.MakeAttribute(
+						"attributes-charset",
+						goipp.TagCharset,
+						goipp.String(DefaultCharset)),
+					goipp.MakeAttribute(
+						"attributes-natural-language",
+						goipp.TagLanguage,
+						goipp.String(DefaultNaturalLanguage)),
+				},
+			},
+			class("office", "Kyocera", "Xerox"),
+			class("empty"),
+		},
+	)
+
+	rsp := &CUPSGetClassesResponse{}
+	err := rsp.Decode(msg, nil)
+	if err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	if len(rsp.Printer) != 2 {
+		t.Fatalf("expected 2 classes, present %d", len(rsp.Printer))
+	}
+
+	office := rsp.Printer[0]
+	if diff := testutils.Diff([]string{"Kyocera", "Xerox"},
+		office.MemberNames); diff != "" {
+		t.Errorf("member-names:\n%s", diff)
+	}
+
+	expected := []string{
+		"ipp://localhost/printers/Kyocera",
+		"ipp://localhost/printers/Xerox",
+	}
+	if diff := testutils.Diff(expected, office.MemberURIs); diff != "" {
+		t.Errorf("member-uris:\n%s", diff)
+	}
+
+	if optional.Get(office.PrinterType)&EnPrinterClass == 0 {
+		t.Errorf("printer-type: class bit not set")
+	}
+
+	if len(rsp.Printer[1].MemberNames) != 0 {
+		t.Errorf("empty class: unexpected members %v",
+			rsp.Printer[1].MemberNames)
+	}
+}
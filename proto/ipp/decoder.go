@@ -20,6 +20,7 @@ import (
 
 	"github.com/OpenPrinting/go-mfp/internal/assert"
 	"github.com/OpenPrinting/go-mfp/proto/ipp/iana"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
 	"github.com/OpenPrinting/goipp"
 )
 
@@ -165,6 +166,15 @@ func (dec *Decoder) validate(attr goipp.Attribute, n int,
 			dec.errPush(err)
 		}
 
+		// Keywords, violating RFC 8011 charset, are only
+		// recorded, as devices send them quite often.
+		if tv.T == goipp.TagKeyword && !ValidateKeyword(string(v)) {
+			err := fmt.Errorf("keyword %q contains invalid characters",
+				v)
+			err = dec.errWrapAtSmart(err, n, attr, def)
+			dec.errPush(err)
+		}
+
 	case goipp.TextWithLang:
 		l := len(v.Lang)
 		if l > 63 {
@@ -286,6 +296,24 @@ func (dec *Decoder) decDateTime(p unsafe.Pointer, vals goipp.Values) error {
 
 }
 
+// Decode: uuid.UUID
+//
+// Both urn:uuid:xxxxxxxx-... and bare xxxxxxxx-... forms are accepted.
+func (dec *Decoder) decUUID(p unsafe.Pointer, vals goipp.Values) error {
+	s, ok := vals[0].V.(goipp.String)
+	if !ok {
+		return dec.errConvert(vals[0], goipp.TypeString)
+	}
+
+	res, err := uuid.Parse(string(s))
+	if err != nil {
+		return dec.errWrap(fmt.Errorf("%q: %w", s, err))
+	}
+
+	*(*uuid.UUID)(p) = res
+	return nil
+}
+
 // Decode: bool
 func (dec *Decoder) decBool(p unsafe.Pointer, vals goipp.Values) error {
 	res, ok := vals[0].V.(goipp.Boolean)
@@ -317,6 +345,11 @@ func (dec *Decoder) decString(p unsafe.Pointer, vals goipp.Values) error {
 	case goipp.Binary:
 		*(*string)(p) = string(res)
 
+	case goipp.TextWithLang:
+		// nameWithLanguage, used with the "keyword | name"
+		// attributes. Language is dropped.
+		*(*string)(p) = res.Text
+
 	default:
 		return dec.errConvert(vals[0], goipp.TypeString)
 	}
@@ -34,8 +34,9 @@ var (
 
 	// DefaultRequestHeader is the default value for the
 	// RequestHeader structure.
+	//
+	// Version is left zero, so [Client] negotiates it.
 	DefaultRequestHeader = RequestHeader{
-		Version:                   goipp.DefaultVersion,
 		AttributesCharset:         DefaultCharset,
 		AttributesNaturalLanguage: DefaultNaturalLanguage,
 	}
@@ -0,0 +1,179 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer endpoint discovery
+
+package ipp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// DiscoverEndpointPaths lists the IPP resource paths, probed by the
+// [DiscoverEndpoint], in order of preference.
+var DiscoverEndpointPaths = []string{
+	"/ipp/print",   // Most of driverless (IPP Everywhere, AirPrint) devices
+	"/ipp/printer", // Some older HP, Samsung and Xerox devices
+	"/ipp",         // Some older Brother and Canon devices
+	"/ipp/lp",      // Some older Epson devices
+	"/printer",     // Some Lexmark devices
+	"/",            // Some Kyocera devices and print servers
+}
+
+// Endpoint discovery parameters:
+const (
+	// DiscoverEndpointTimeout limits time of each probe.
+	DiscoverEndpointTimeout = 3 * time.Second
+
+	// DiscoverEndpointConcurrency limits count of simultaneous
+	// probes.
+	DiscoverEndpointConcurrency = 3
+)
+
+// discoverEndpointAttrs are the attributes, requested by the
+// DiscoverEndpoint probes.
+var discoverEndpointAttrs = []string{
+	"printer-make-and-model",
+	"printer-uuid",
+}
+
+// EndpointCandidate is the IPP printer endpoint, found by
+// the [DiscoverEndpoint].
+type EndpointCandidate struct {
+	URL       *url.URL      // Printer URI
+	Aliases   []*url.URL    // Other URIs of the same printer
+	MakeModel string        // printer-make-and-model, "" if unknown
+	UUID      string        // printer-uuid, "" if unknown
+	Latency   time.Duration // Time to answer
+}
+
+// DiscoverEndpoint discovers IPP printer endpoints on the host,
+// when the resource path is not known.
+//
+// The host may be specified as hostname or IP address, optionally
+// with port, and defaults to the ipp scheme and 631 port. Any path
+// in the host string is ignored.
+//
+// It probes the [DiscoverEndpointPaths] with the Get-Printer-Attributes
+// request, that asks only for the printer-make-and-model and
+// printer-uuid attributes. At most [DiscoverEndpointConcurrency]
+// probes run simultaneously, each limited by the
+// [DiscoverEndpointTimeout].
+//
+// Candidates are returned in order of [DiscoverEndpointPaths].
+// Paths, that answered with the same printer-uuid, are collapsed
+// into the single candidate, with the most preferred path as URL
+// and the rest as Aliases.
+//
+// If clnt is nil, the new [transport.Client] with the default
+// transport is used. If no path answers, error is returned.
+func DiscoverEndpoint(ctx context.Context, clnt *transport.Client,
+	host string) ([]EndpointCandidate, error) {
+
+	base, err := transport.ParseAddr(host, "ipp://localhost:631/")
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", host, err)
+	}
+
+	if clnt == nil {
+		clnt = transport.NewClient(nil)
+	}
+
+	// Probe all paths
+	type result struct {
+		cand EndpointCandidate
+		err  error
+	}
+
+	results := make([]result, len(DiscoverEndpointPaths))
+	sem := make(chan struct{}, DiscoverEndpointConcurrency)
+	var wait sync.WaitGroup
+
+	for i, path := range DiscoverEndpointPaths {
+		u := *base
+		u.Path = path
+		u.RawPath = ""
+
+		wait.Add(1)
+		go func(i int, u *url.URL) {
+			defer wait.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i].cand, results[i].err =
+				discoverEndpointProbe(ctx, clnt, u)
+		}(i, &u)
+	}
+
+	wait.Wait()
+
+	// Collect answered candidates, collapse duplicates
+	var candidates []EndpointCandidate
+	byUUID := make(map[string]int)
+
+	for _, res := range results {
+		if res.err != nil {
+			continue
+		}
+
+		cand := res.cand
+		if cand.UUID != "" {
+			if i, found := byUUID[cand.UUID]; found {
+				candidates[i].Aliases = append(
+					candidates[i].Aliases, cand.URL)
+				continue
+			}
+			byUUID[cand.UUID] = len(candidates)
+		}
+
+		candidates = append(candidates, cand)
+	}
+
+	if len(candidates) == 0 {
+		// Report error of the most preferred path
+		return nil, fmt.Errorf("%s: IPP printer not found: %w",
+			host, results[0].err)
+	}
+
+	return candidates, nil
+}
+
+// discoverEndpointProbe probes the single printer URI.
+func discoverEndpointProbe(ctx context.Context, clnt *transport.Client,
+	u *url.URL) (EndpointCandidate, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, DiscoverEndpointTimeout)
+	defer cancel()
+
+	ippclnt := &Client{URL: u, HTTPClient: clnt}
+	ippclnt.SetDecoderOptions(&DecoderOptions{KeepTrying: true})
+
+	start := time.Now()
+	prn, err := ippclnt.GetPrinterAttributes(ctx, discoverEndpointAttrs, "")
+	if err != nil {
+		return EndpointCandidate{}, err
+	}
+
+	cand := EndpointCandidate{
+		URL:     u,
+		Latency: time.Since(start),
+	}
+
+	if prn != nil {
+		cand.MakeModel = optional.Get(prn.PrinterMakeAndModel)
+		cand.UUID = prn.PrinterUUIDString()
+	}
+
+	return cand, nil
+}
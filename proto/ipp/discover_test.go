@@ -0,0 +1,126 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer endpoint discovery test
+
+package ipp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestDiscoverEndpoint tests DiscoverEndpoint
+func TestDiscoverEndpoint(t *testing.T) {
+	// Fake device answers on two paths with the same printer-uuid
+	// and on the third path with another printer-uuid
+	newPrinter := func(makeModel, id string) *ServeMux {
+		mux := NewServeMux(ServerOptions{})
+		mux.Handle(goipp.OpGetPrinterAttributes,
+			func(ctx context.Context, msg *goipp.Message,
+				body io.Reader) (*goipp.Message, io.Reader, error) {
+
+				prn := &PrinterAttributes{}
+				prn.PrinterMakeAndModel = optional.New(makeModel)
+				prn.PrinterUUID = optional.New(uuid.MustParse(id))
+
+				rsp := &GetPrinterAttributesResponse{
+					ResponseHeader: ResponseHeader{
+						Version:   msg.Version,
+						RequestID: msg.RequestID,
+						Status:    goipp.StatusOk,
+					},
+					Printer: prn,
+				}
+
+				return rsp.Encode(), nil, nil
+			})
+		return mux
+	}
+
+	const (
+		uuid1 = "urn:uuid:4509a320-00a0-008f-00b6-002507510eca"
+		uuid2 = "urn:uuid:4509a320-00a0-008f-00b6-002507510ecb"
+	)
+
+	prn1 := newPrinter("Kyocera ECOSYS M2040dn", uuid1)
+	prn2 := newPrinter("Kyocera Fax", uuid2)
+
+	mux := http.NewServeMux()
+	mux.Handle("/ipp/print", prn1)
+	mux.Handle("/ipp", prn1)
+	mux.Handle("/printer", prn2)
+	mux.Handle("/", http.NotFoundHandler())
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	// Discover endpoints
+	candidates, err := DiscoverEndpoint(context.Background(), nil, host)
+	if err != nil {
+		t.Fatalf("DiscoverEndpoint: %s", err)
+	}
+
+	type result struct {
+		url, makeModel, uuid string
+		aliases              []string
+	}
+
+	expected := []result{
+		{
+			url:       "ipp://" + host + "/ipp/print",
+			makeModel: "Kyocera ECOSYS M2040dn",
+			uuid:      uuid1,
+			aliases:   []string{"ipp://" + host + "/ipp"},
+		},
+		{
+			url:       "ipp://" + host + "/printer",
+			makeModel: "Kyocera Fax",
+			uuid:      uuid2,
+		},
+	}
+
+	var present []result
+	for _, cand := range candidates {
+		res := result{
+			url:       cand.URL.String(),
+			makeModel: cand.MakeModel,
+			uuid:      cand.UUID,
+		}
+
+		for _, alias := range cand.Aliases {
+			res.aliases = append(res.aliases, alias.String())
+		}
+
+		present = append(present, res)
+	}
+
+	if !reflect.DeepEqual(present, expected) {
+		t.Errorf("DiscoverEndpoint:\nexpected: %+v\npresent:  %+v",
+			expected, present)
+	}
+
+	// Nothing answers
+	srv404 := httptest.NewServer(http.NotFoundHandler())
+	defer srv404.Close()
+
+	host = strings.TrimPrefix(srv404.URL, "http://")
+	_, err = DiscoverEndpoint(context.Background(), nil, host)
+	if err == nil {
+		t.Errorf("DiscoverEndpoint: error expected")
+	}
+}
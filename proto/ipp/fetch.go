@@ -0,0 +1,98 @@
+// MFP - Multi-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Fetching of printer's auxiliary resources
+
+package ipp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// FetchIcon fetches the printer icon from the URL, as returned
+// by the "printer-icons" printer attribute, and returns icon
+// image data and its MIME type.
+//
+// Printer icons rarely change, so request is performed with
+// caching enabled (see [transport.WithCaching]) and uses
+// c.HTTPClient.Cache, if it is set.
+func (c *Client) FetchIcon(ctx context.Context, u string) (
+	data []byte, mimeType string, err error) {
+
+	rsp, err := c.fetch(ctx, u)
+	if err != nil {
+		return
+	}
+
+	defer rsp.Body.Close()
+
+	data, err = io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	mimeType = rsp.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	return data, mimeType, nil
+}
+
+// FetchStrings fetches the printer localization strings file from
+// the URL, as returned by the "printer-strings-uri" printer attribute,
+// and returns its content.
+//
+// The file uses the Apple .strings format (see PWG 5100.13).
+//
+// Strings files rarely change, so request is performed with
+// caching enabled (see [transport.WithCaching]) and uses
+// c.HTTPClient.Cache, if it is set.
+func (c *Client) FetchStrings(ctx context.Context, u string) ([]byte, error) {
+	rsp, err := c.fetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+
+	return io.ReadAll(rsp.Body)
+}
+
+// fetch performs the cacheable HTTP GET request.
+//
+// On success, caller MUST close response body after use.
+func (c *Client) fetch(ctx context.Context, u string) (
+	*http.Response, error) {
+
+	parsed, err := transport.ParseURL(u)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", u, err)
+	}
+
+	ctx = transport.WithCaching(ctx)
+	rq, err := transport.NewRequest(ctx, "GET", parsed, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := c.HTTPClient.Do(rq)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		rsp.Body.Close()
+		return nil, fmt.Errorf("HTTP: %s", rsp.Status)
+	}
+
+	return rsp, nil
+}
@@ -0,0 +1,370 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Fuzz tests for the IPP codec
+
+package ipp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+	"github.com/OpenPrinting/goipp"
+)
+
+// fuzzMessage is the common interface of IPP requests and responses
+type fuzzMessage interface {
+	Encode() *goipp.Message
+	Decode(*goipp.Message, *DecoderOptions) error
+}
+
+// fuzzMessageTypes contains constructors of all message types,
+// tested by FuzzIPPDecode
+var fuzzMessageTypes = []func() fuzzMessage{
+	func() fuzzMessage { return &CancelJobRequest{} },
+	func() fuzzMessage { return &CancelJobResponse{} },
+	func() fuzzMessage { return &CreateJobRequest{} },
+	func() fuzzMessage { return &CreateJobResponse{} },
+	func() fuzzMessage { return &CUPSAddModifyPrinterRequest{} },
+	func() fuzzMessage { return &CUPSAddModifyPrinterResponse{} },
+	func() fuzzMessage { return &CUPSGetDefaultRequest{} },
+	func() fuzzMessage { return &CUPSGetDefaultResponse{} },
+	func() fuzzMessage { return &CUPSGetDevicesRequest{} },
+	func() fuzzMessage { return &CUPSGetDevicesResponse{} },
+	func() fuzzMessage { return &CUPSGetDocumentRequest{} },
+	func() fuzzMessage { return &CUPSGetDocumentResponse{} },
+	func() fuzzMessage { return &CUPSGetPPDRequest{} },
+	func() fuzzMessage { return &CUPSGetPPDResponse{} },
+	func() fuzzMessage { return &CUPSGetPPDsRequest{} },
+	func() fuzzMessage { return &CUPSGetPPDsResponse{} },
+	func() fuzzMessage { return &CUPSGetPrintersRequest{} },
+	func() fuzzMessage { return &CUPSGetPrintersResponse{} },
+	func() fuzzMessage { return &GetJobAttributesRequest{} },
+	func() fuzzMessage { return &GetJobAttributesResponse{} },
+	func() fuzzMessage { return &GetJobsRequest{} },
+	func() fuzzMessage { return &GetJobsResponse{} },
+	func() fuzzMessage { return &GetNextDocumentDataRequest{} },
+	func() fuzzMessage { return &GetNextDocumentDataResponse{} },
+	func() fuzzMessage { return &GetPrinterAttributesRequest{} },
+	func() fuzzMessage { return &GetPrinterAttributesResponse{} },
+	func() fuzzMessage { return &GetPrinterSupportedValuesRequest{} },
+	func() fuzzMessage { return &GetPrinterSupportedValuesResponse{} },
+	func() fuzzMessage { return &PrintJobRequest{} },
+	func() fuzzMessage { return &PrintJobResponse{} },
+	func() fuzzMessage { return &SendDocumentRequest{} },
+	func() fuzzMessage { return &SendDocumentResponse{} },
+	func() fuzzMessage { return &ValidateJobRequest{} },
+	func() fuzzMessage { return &ValidateJobResponse{} },
+}
+
+// fuzzObjectTypes contains types of Objects, tested by FuzzIPPEncode
+var fuzzObjectTypes = []reflect.Type{
+	reflect.TypeOf(PrinterAttributes{}),
+	reflect.TypeOf(JobGroupEntry{}),
+	reflect.TypeOf(DocumentStatus{}),
+	reflect.TypeOf(DeviceAttributes{}),
+	reflect.TypeOf(PPDAttributes{}),
+}
+
+// fuzzSeedMessages returns the seed corpus for FuzzIPPDecode:
+// messages, captured from the real devices, and test fixtures.
+func fuzzSeedMessages() [][]byte {
+	seeds := [][]byte{
+		testutils.Kyocera.ECOSYS.M2040dn.IPP.PrinterAttributes,
+		testutils.Xerox.B235.IPP.PrinterAttributes,
+	}
+
+	rsp := &GetPrinterAttributesResponse{
+		ResponseHeader: ResponseHeader{
+			Version:                   goipp.DefaultVersion,
+			Status:                    goipp.StatusOk,
+			AttributesCharset:         DefaultCharset,
+			AttributesNaturalLanguage: DefaultNaturalLanguage,
+		},
+		Printer: &testdataPrinterAttributes,
+	}
+
+	rq := &GetPrinterAttributesRequest{
+		RequestHeader:       DefaultRequestHeader,
+		PrinterURI:          "ipp://localhost/ipp/print",
+		RequestedAttributes: []string{GetPrinterAttributesAll},
+	}
+
+	msgs := []fuzzMessage{rsp, rq}
+
+	// Small messages of all types make mutations cheap
+	for _, mk := range fuzzMessageTypes {
+		msgs = append(msgs, mk())
+	}
+
+	for _, msg := range msgs {
+		data, err := msg.Encode().EncodeBytes()
+		if err != nil {
+			panic(err)
+		}
+		seeds = append(seeds, data)
+	}
+
+	return seeds
+}
+
+// FuzzIPPDecode feeds arbitrary bytes into the message decoders.
+//
+// Any successfully decoded message must re-encode and re-decode
+// to the equal value.
+func FuzzIPPDecode(f *testing.F) {
+	for _, seed := range fuzzSeedMessages() {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var msg goipp.Message
+		if msg.DecodeBytes(data) != nil {
+			return
+		}
+
+		for _, keepTrying := range []bool{false, true} {
+			opt := &DecoderOptions{KeepTrying: keepTrying}
+			for _, mk := range fuzzMessageTypes {
+				fuzzRoundTrip(t, mk, &msg, opt)
+			}
+		}
+	})
+}
+
+// fuzzRoundTrip decodes message of the type, created by mk,
+// and if decoding succeeds, checks the encode/decode round trip.
+func fuzzRoundTrip(t *testing.T, mk func() fuzzMessage,
+	msg *goipp.Message, opt *DecoderOptions) {
+
+	obj := mk()
+	if obj.Decode(msg, opt) != nil {
+		return
+	}
+
+	name := reflect.TypeOf(obj).Elem().Name()
+
+	// Re-encode, then pass through the wire encoding
+	enc1 := obj.Encode()
+	data, err := enc1.EncodeBytes()
+	if err != nil {
+		t.Fatalf("%s: encode: %s", name, err)
+	}
+
+	var msg2 goipp.Message
+	err = msg2.DecodeBytes(data)
+	if err != nil {
+		t.Fatalf("%s: goipp decode: %s", name, err)
+	}
+
+	// Re-decode and re-encode again
+	obj2 := mk()
+	err = obj2.Decode(&msg2, opt)
+	if err != nil {
+		t.Fatalf("%s: re-decode: %s", name, err)
+	}
+
+	enc2 := obj2.Encode()
+	if !fuzzStripEmptyGroups(enc1).Equal(*fuzzStripEmptyGroups(enc2)) {
+		buf1, buf2 := &bytes.Buffer{}, &bytes.Buffer{}
+		enc1.Print(buf1, true)
+		enc2.Print(buf2, true)
+		t.Fatalf("%s: round trip mismatch:\nfirst:\n%s\nsecond:\n%s",
+			name, buf1, buf2)
+	}
+}
+
+// fuzzStripEmptyGroups returns copy of the message with empty
+// groups removed.
+//
+// Decoders skip empty groups, while encoders may generate them
+// for objects that have no attributes to encode (for example,
+// when none of the received attributes is known to the decoder).
+// These groups carry no information, so they are ignored when
+// messages are compared.
+func fuzzStripEmptyGroups(msg *goipp.Message) *goipp.Message {
+	var groups goipp.Groups
+	for _, grp := range msg.AttrGroups() {
+		if len(grp.Attrs) > 0 {
+			groups.Add(grp)
+		}
+	}
+
+	return goipp.NewMessageWithGroups(msg.Version, msg.Code,
+		msg.RequestID, groups)
+}
+
+// FuzzIPPEncode builds randomized Objects via reflection and
+// checks that they survive the encode/decode round trip.
+func FuzzIPPEncode(f *testing.F) {
+	f.Add(int64(0), uint8(0))
+	f.Add(int64(1), uint8(1))
+	f.Add(int64(12345), uint8(2))
+
+	f.Fuzz(func(t *testing.T, seed int64, typ uint8) {
+		t.Parallel()
+
+		gen := fuzzGenerator{rand.New(rand.NewSource(seed))}
+		objType := fuzzObjectTypes[int(typ)%len(fuzzObjectTypes)]
+
+		obj := reflect.New(objType)
+		gen.fill(obj.Elem(), 0)
+
+		enc := ippEncoder{}
+		attrs := enc.Encode(obj.Interface().(Object))
+
+		obj2 := reflect.New(objType)
+		dec := NewDecoder(nil)
+		defer dec.Free()
+
+		err := dec.Decode(obj2.Interface().(Object), attrs)
+		if err != nil {
+			t.Fatalf("%s: decode: %s", objType.Name(), err)
+		}
+
+		attrs2 := enc.Encode(obj2.Interface().(Object))
+		if diff := testDiffAttrs(attrs, attrs2); diff != "" {
+			t.Fatalf("%s: round trip mismatch:\n%s",
+				objType.Name(), diff)
+		}
+	})
+}
+
+// fuzzGenerator generates random values for FuzzIPPEncode
+type fuzzGenerator struct {
+	rnd *rand.Rand
+}
+
+// fuzzMaxDepth limits nesting of the generated collections
+const fuzzMaxDepth = 3
+
+// fill fills the value with random data.
+//
+// Generated values stay within limits, required by the IPP
+// registrations (for example, strings are not empty and
+// integers are non-negative), so the encoded attributes are
+// decoded without errors.
+func (gen fuzzGenerator) fill(v reflect.Value, depth int) {
+	switch v.Type() {
+	case reflect.TypeOf(ObjectRawAttrs{}):
+		return
+
+	case reflect.TypeOf((*goipp.IntegerOrRange)(nil)).Elem():
+		if gen.rnd.Intn(2) == 0 {
+			v.Set(reflect.ValueOf(goipp.Integer(gen.int())))
+		} else {
+			v.Set(reflect.ValueOf(gen.rng()))
+		}
+		return
+
+	case reflect.TypeOf(goipp.Range{}):
+		v.Set(reflect.ValueOf(gen.rng()))
+		return
+
+	case reflect.TypeOf(goipp.Resolution{}):
+		v.Set(reflect.ValueOf(goipp.Resolution{
+			Xres:  gen.int(),
+			Yres:  gen.int(),
+			Units: goipp.UnitsDpi,
+		}))
+		return
+
+	case reflect.TypeOf(goipp.TextWithLang{}):
+		v.Set(reflect.ValueOf(goipp.TextWithLang{
+			Lang: "en-us",
+			Text: gen.str(),
+		}))
+		return
+
+	case reflect.TypeOf(goipp.Version(0)):
+		v.Set(reflect.ValueOf(goipp.MakeVersion(
+			uint8(gen.rnd.Intn(3)+1), uint8(gen.rnd.Intn(3)))))
+		return
+
+	case reflect.TypeOf(time.Time{}):
+		// IPP dateTime has 1/10 second precision
+		tm := time.Unix(gen.rnd.Int63n(1<<32), 0).UTC()
+		v.Set(reflect.ValueOf(tm))
+		return
+
+	case reflect.TypeOf(uuid.UUID{}):
+		u, _ := uuid.RandomFrom(gen.rnd)
+		v.Set(reflect.ValueOf(u))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(gen.rnd.Intn(2) == 0)
+
+	case reflect.Int:
+		v.SetInt(int64(gen.int()))
+
+	case reflect.Uint16:
+		v.SetUint(uint64(gen.rnd.Intn(65536)))
+
+	case reflect.String:
+		v.SetString(gen.str())
+
+	case reflect.Pointer:
+		// optional.Val[T] is the pointer
+		if depth < fuzzMaxDepth && gen.rnd.Intn(2) == 0 {
+			p := reflect.New(v.Type().Elem())
+			gen.fill(p.Elem(), depth+1)
+			v.Set(p)
+		}
+
+	case reflect.Slice:
+		if depth < fuzzMaxDepth {
+			n := gen.rnd.Intn(4)
+			s := reflect.MakeSlice(v.Type(), n, n)
+			for i := 0; i < n; i++ {
+				gen.fill(s.Index(i), depth+1)
+			}
+			v.Set(s)
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				gen.fill(v.Field(i), depth)
+			}
+		}
+	}
+}
+
+// int returns random positive integer, small enough to fit
+// into the most of attribute value ranges.
+func (gen fuzzGenerator) int() int {
+	return gen.rnd.Intn(1000) + 1
+}
+
+// rng returns random goipp.Range
+func (gen fuzzGenerator) rng() goipp.Range {
+	lower := gen.int()
+	return goipp.Range{Lower: lower, Upper: lower + gen.int()}
+}
+
+// str returns random non-empty ASCII string
+func (gen fuzzGenerator) str() string {
+	const chars = "abcdefghijklmnopqrstuvwxyz0123456789-"
+
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], gen.rnd.Uint64())
+
+	n := gen.rnd.Intn(len(buf)) + 1
+	for i := 0; i < n; i++ {
+		buf[i] = chars[int(buf[i])%len(chars)]
+	}
+
+	return string(buf[:n])
+}
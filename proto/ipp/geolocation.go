@@ -0,0 +1,178 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer geographic location (geo: URI)
+
+package ipp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// GeoEarthRadius is the mean Earth radius, in meters, used by
+// [GeoLocation.Distance].
+const GeoEarthRadius = 6371008.8
+
+// GeoLocation represents the printer geographic location, as
+// defined by the "printer-geo-location" attribute (RFC 8011,
+// 5.4.31) in the form of geo: URI (RFC 5870).
+//
+// Coordinates are in the WGS-84 reference system, the only one
+// supported by RFC 5870.
+type GeoLocation struct {
+	Lat         float64               // Latitude, degrees
+	Lon         float64               // Longitude, degrees
+	Alt         optional.Val[float64] // Altitude, meters
+	Uncertainty optional.Val[float64] // Uncertainty, meters
+}
+
+// ParseGeoLocation parses the geo: URI (RFC 5870).
+//
+// Syntax is:
+//
+//	geo:lat,lon[,alt][;crs=wgs84][;u=uncertainty][;param=value...]
+//
+// Unknown parameters are ignored. Coordinate reference systems
+// other than wgs84 are rejected.
+func ParseGeoLocation(s string) (GeoLocation, error) {
+	err := fmt.Errorf("%q: invalid geo URI", s)
+
+	// Check scheme
+	const scheme = "geo:"
+	if len(s) < len(scheme) || !strings.EqualFold(s[:len(scheme)], scheme) {
+		return GeoLocation{}, err
+	}
+
+	// Split coordinates and parameters
+	params := strings.Split(s[len(scheme):], ";")
+	coords := strings.Split(params[0], ",")
+	params = params[1:]
+
+	if len(coords) != 2 && len(coords) != 3 {
+		return GeoLocation{}, err
+	}
+
+	// Parse coordinates
+	var geo GeoLocation
+	var e error
+
+	geo.Lat, e = geoParseNum(coords[0])
+	if e != nil || geo.Lat < -90 || geo.Lat > 90 {
+		return GeoLocation{}, fmt.Errorf("%w: bad latitude", err)
+	}
+
+	geo.Lon, e = geoParseNum(coords[1])
+	if e != nil || geo.Lon < -180 || geo.Lon > 180 {
+		return GeoLocation{}, fmt.Errorf("%w: bad longitude", err)
+	}
+
+	if len(coords) == 3 {
+		alt, e := geoParseNum(coords[2])
+		if e != nil {
+			return GeoLocation{}, fmt.Errorf("%w: bad altitude", err)
+		}
+		geo.Alt = optional.New(alt)
+	}
+
+	// Parse parameters
+	for i, param := range params {
+		name, value, _ := strings.Cut(param, "=")
+		switch strings.ToLower(name) {
+		case "crs":
+			// crs, if present, must be the first parameter
+			if i != 0 || !strings.EqualFold(value, "wgs84") {
+				return GeoLocation{},
+					fmt.Errorf("%w: unsupported crs", err)
+			}
+
+		case "u":
+			u, e := geoParseNum(value)
+			if e != nil || u < 0 || geo.Uncertainty != nil {
+				return GeoLocation{},
+					fmt.Errorf("%w: bad uncertainty", err)
+			}
+			geo.Uncertainty = optional.New(u)
+
+		case "":
+			return GeoLocation{}, err
+		}
+	}
+
+	return geo, nil
+}
+
+// String formats GeoLocation as geo: URI (RFC 5870).
+func (geo GeoLocation) String() string {
+	s := "geo:" + geoFormatNum(geo.Lat) + "," + geoFormatNum(geo.Lon)
+	if geo.Alt != nil {
+		s += "," + geoFormatNum(*geo.Alt)
+	}
+	if geo.Uncertainty != nil {
+		s += ";u=" + geoFormatNum(*geo.Uncertainty)
+	}
+	return s
+}
+
+// Distance returns the great-circle distance between two locations,
+// in meters. Altitude and uncertainty are not taken into account.
+func (geo GeoLocation) Distance(geo2 GeoLocation) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	lat1, lat2 := rad(geo.Lat), rad(geo2.Lat)
+	dlat := lat2 - lat1
+	dlon := rad(geo2.Lon - geo.Lon)
+
+	// Haversine formula
+	h := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dlon/2)*math.Sin(dlon/2)
+
+	return 2 * GeoEarthRadius * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
+
+// GeoLocation returns the parsed "printer-geo-location" attribute.
+// If attribute is missed or "unknown", it returns false.
+func (attrs *PrinterAttributes) GeoLocation() (GeoLocation, bool) {
+	s := optional.Get(attrs.PrinterGeoLocation)
+	if s == "" || s == "unknown" {
+		return GeoLocation{}, false
+	}
+
+	geo, err := ParseGeoLocation(s)
+	if err != nil {
+		return GeoLocation{}, false
+	}
+
+	return geo, true
+}
+
+// geoParseNum parses number in the geo: URI.
+//
+// RFC 5870 allows only the decimal notation (digits, optionally
+// with the leading minus and the fraction), so exponents, "Inf",
+// "NaN" and friends, accepted by strconv.ParseFloat, are rejected.
+func geoParseNum(s string) (float64, error) {
+	digits := strings.TrimPrefix(s, "-")
+	intPart, frac, hasFrac := strings.Cut(digits, ".")
+
+	if intPart == "" || (hasFrac && frac == "") ||
+		strings.Trim(intPart, "0123456789") != "" ||
+		strings.Trim(frac, "0123456789") != "" {
+		return 0, errors.New("invalid number")
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
+
+// geoFormatNum formats number for the geo: URI.
+func geoFormatNum(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,166 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer geographic location tests
+
+package ipp
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// TestParseGeoLocation tests ParseGeoLocation and GeoLocation.String
+func TestParseGeoLocation(t *testing.T) {
+	type testData struct {
+		in       string
+		expected GeoLocation
+		out      string // Expected String(), "" if same as in
+		err      string
+	}
+
+	tests := []testData{
+		{
+			in:       "geo:13.4125,103.8667",
+			expected: GeoLocation{Lat: 13.4125, Lon: 103.8667},
+		},
+
+		{
+			in: "geo:48.2010,16.3695,183",
+			expected: GeoLocation{Lat: 48.201, Lon: 16.3695,
+				Alt: optional.New(183.0)},
+			out: "geo:48.201,16.3695,183",
+		},
+
+		{
+			in: "geo:-48.198634,-16.371648;crs=wgs84;u=40",
+			expected: GeoLocation{Lat: -48.198634, Lon: -16.371648,
+				Uncertainty: optional.New(40.0)},
+			out: "geo:-48.198634,-16.371648;u=40",
+		},
+
+		{
+			in: "GEO:90,0,-12.5;U=0.5;param=value",
+			expected: GeoLocation{Lat: 90, Lon: 0,
+				Alt:         optional.New(-12.5),
+				Uncertainty: optional.New(0.5)},
+			out: "geo:90,0,-12.5;u=0.5",
+		},
+
+		{in: "", err: `"": invalid geo URI`},
+		{in: "geo:1", err: `"geo:1": invalid geo URI`},
+		{in: "geo:1,2,3,4", err: `"geo:1,2,3,4": invalid geo URI`},
+		{in: "geo:91,0", err: `"geo:91,0": invalid geo URI: bad latitude`},
+		{in: "geo:0,-181", err: `"geo:0,-181": invalid geo URI: bad longitude`},
+		{in: "geo:1e1,0", err: `"geo:1e1,0": invalid geo URI: bad latitude`},
+		{in: "geo:1.,0", err: `"geo:1.,0": invalid geo URI: bad latitude`},
+		{in: "geo:0,NaN", err: `"geo:0,NaN": invalid geo URI: bad longitude`},
+		{in: "geo:0,0,x", err: `"geo:0,0,x": invalid geo URI: bad altitude`},
+		{in: "geo:0,0;u=-1", err: `"geo:0,0;u=-1": invalid geo URI: bad uncertainty`},
+		{in: "geo:0,0;crs=nad27", err: `"geo:0,0;crs=nad27": invalid geo URI: unsupported crs`},
+		{in: "geo:0,0;u=1;crs=wgs84", err: `"geo:0,0;u=1;crs=wgs84": invalid geo URI: unsupported crs`},
+		{in: "http://0,0", err: `"http://0,0": invalid geo URI`},
+	}
+
+	for _, test := range tests {
+		geo, err := ParseGeoLocation(test.in)
+
+		errstr := ""
+		if err != nil {
+			errstr = err.Error()
+		}
+
+		if errstr != test.err {
+			t.Errorf("%q: error expected %q, present %q",
+				test.in, test.err, errstr)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(geo, test.expected) {
+			t.Errorf("%q: expected %s, present %s",
+				test.in, test.expected, geo)
+		}
+
+		// Formatting round-trip
+		out := test.out
+		if out == "" {
+			out = test.in
+		}
+
+		if s := geo.String(); s != out {
+			t.Errorf("%q: String expected %q, present %q",
+				test.in, out, s)
+		}
+
+		geo2, err := ParseGeoLocation(geo.String())
+		if err != nil || !reflect.DeepEqual(geo, geo2) {
+			t.Errorf("%q: round-trip: %s (%v)", test.in, geo2, err)
+		}
+	}
+}
+
+// TestGeoLocationDistance tests GeoLocation.Distance
+func TestGeoLocationDistance(t *testing.T) {
+	type testData struct {
+		a, b     GeoLocation
+		expected float64 // Meters
+	}
+
+	// Length of 1 degree of the great circle
+	deg := GeoEarthRadius * math.Pi / 180
+
+	tests := []testData{
+		{
+			a:        GeoLocation{Lat: 0, Lon: 0},
+			b:        GeoLocation{Lat: 0, Lon: 1},
+			expected: deg,
+		},
+
+		{
+			a:        GeoLocation{Lat: 10, Lon: 20},
+			b:        GeoLocation{Lat: 11, Lon: 20},
+			expected: deg,
+		},
+
+		{
+			a:        GeoLocation{Lat: 0, Lon: 179.5},
+			b:        GeoLocation{Lat: 0, Lon: -179.5},
+			expected: deg,
+		},
+
+		{
+			a:        GeoLocation{Lat: 90, Lon: 0},
+			b:        GeoLocation{Lat: -90, Lon: 123},
+			expected: 180 * deg,
+		},
+
+		{
+			a:        GeoLocation{Lat: 55.7558, Lon: 37.6173},
+			b:        GeoLocation{Lat: 55.7558, Lon: 37.6173},
+			expected: 0,
+		},
+	}
+
+	for _, test := range tests {
+		d := test.a.Distance(test.b)
+		if math.Abs(d-test.expected) > 1e-6 {
+			t.Errorf("%s-%s: expected %f, present %f",
+				test.a, test.b, test.expected, d)
+		}
+
+		if d2 := test.b.Distance(test.a); d2 != d {
+			t.Errorf("%s-%s: not symmetric: %f vs %f",
+				test.a, test.b, d, d2)
+		}
+	}
+}
@@ -190,14 +190,14 @@ func buildGetPrinterAttrubutesFilter() *filterAttributes {
 	all.Del("media-col-database")
 
 	jobTemplate := generic.NewSet[string]()
-	all.ForEach(func(name string) {
-		if iana.PrinterDescription[name+"-default"] != nil {
+	for name := range iana.JobTemplate {
+		if all.Contains(name + "-default") {
 			jobTemplate.Add(name + "-default")
 		}
-		if iana.PrinterDescription[name+"-supported"] != nil {
+		if all.Contains(name + "-supported") {
 			jobTemplate.Add(name + "-supported")
 		}
-	})
+	}
 
 	printerDescription := all.Clone()
 	jobTemplate.ForEach(func(name string) {
@@ -0,0 +1,228 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Get-Printer-Supported-Values request
+
+package ipp
+
+import (
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// GetPrinterSupportedValuesRequest operation (0x0015) returns
+// the "xxx-supported" printer attributes (RFC3380).
+type GetPrinterSupportedValuesRequest struct {
+	ObjectRawAttrs
+	RequestHeader
+	OperationGroup
+
+	// Operation attributes
+	PrinterURI          string               `ipp:"printer-uri"`
+	RequestedAttributes []string             `ipp:"requested-attributes"`
+	DocumentFormat      optional.Val[string] `ipp:"document-format"`
+}
+
+// GetPrinterSupportedValuesResponse is the Get-Printer-Supported-Values
+// Response.
+type GetPrinterSupportedValuesResponse struct {
+	ObjectRawAttrs
+	ResponseHeader
+	OperationGroup
+
+	// Names of unsupported attributes
+	UnsupportedAttributes []string
+
+	// Returned printer attributes. Only "xxx-supported"
+	// attributes are returned.
+	Printer *PrinterAttributes
+}
+
+// GetOp returns GetPrinterSupportedValuesRequest IPP Operation code.
+func (rq *GetPrinterSupportedValuesRequest) GetOp() goipp.Op {
+	return goipp.OpGetPrinterSupportedValues
+}
+
+// Encode encodes GetPrinterSupportedValuesRequest into the goipp.Message.
+func (rq *GetPrinterSupportedValuesRequest) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rq),
+		},
+	}
+
+	msg := goipp.NewMessageWithGroups(rq.Version, goipp.Code(rq.GetOp()),
+		rq.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes GetPrinterSupportedValuesRequest from goipp.Message.
+func (rq *GetPrinterSupportedValuesRequest) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rq.Version = msg.Version
+	rq.RequestID = msg.RequestID
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rq, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Apply selects the "xxx-supported" attributes, requested by the
+// requested-attributes, out of attrs and returns the encoded response
+// message. If requested-attributes is empty or contains "all", all
+// "xxx-supported" attributes are returned.
+//
+// If useRawAttrs is true, the source attrs are taken from
+// attrs.RawAttrs().All(); otherwise they are produced by encoding attrs.
+func (rq *GetPrinterSupportedValuesRequest) Apply(
+	attrs *PrinterAttributes,
+	useRawAttrs bool,
+) *goipp.Message {
+
+	var encoded goipp.Attributes
+	if useRawAttrs {
+		encoded = attrs.RawAttrs().All()
+	} else {
+		enc := ippEncoder{}
+		encoded = enc.Encode(attrs)
+	}
+
+	all := len(rq.RequestedAttributes) == 0
+	requested := make(map[string]bool)
+	for _, name := range rq.RequestedAttributes {
+		if name == "all" {
+			all = true
+		} else {
+			requested[name] = false
+		}
+	}
+
+	var filtered goipp.Attributes
+	for _, attr := range encoded {
+		if !strings.HasSuffix(attr.Name, "-supported") {
+			continue
+		}
+
+		if _, found := requested[attr.Name]; found || all {
+			requested[attr.Name] = true
+			filtered.Add(attr)
+		}
+	}
+
+	var unsupported []string
+	for _, name := range rq.RequestedAttributes {
+		if found := requested[name]; name != "all" && !found {
+			unsupported = append(unsupported, name)
+		}
+	}
+
+	status := goipp.StatusOk
+	if len(unsupported) > 0 {
+		status = goipp.StatusOkIgnoredOrSubstituted
+	}
+
+	rsp := &GetPrinterSupportedValuesResponse{
+		ResponseHeader:        rq.ResponseHeader(status),
+		UnsupportedAttributes: unsupported,
+	}
+
+	return rsp.EncodeRaw(filtered)
+}
+
+// Encode encodes GetPrinterSupportedValuesResponse into goipp.Message.
+func (rsp *GetPrinterSupportedValuesResponse) Encode() *goipp.Message {
+	var attrs goipp.Attributes
+	if rsp.Printer != nil {
+		enc := ippEncoder{}
+		for _, attr := range enc.Encode(rsp.Printer) {
+			if strings.HasSuffix(attr.Name, "-supported") {
+				attrs.Add(attr)
+			}
+		}
+	}
+
+	return rsp.EncodeRaw(attrs)
+}
+
+// EncodeRaw is like [GetPrinterSupportedValuesResponse.Encode],
+// but it accepts printer attributes as parameter and ignores
+// the [GetPrinterSupportedValuesResponse.Printer] field.
+func (rsp *GetPrinterSupportedValuesResponse) EncodeRaw(
+	rawPrinterAttrs goipp.Attributes) *goipp.Message {
+
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rsp),
+		},
+	}
+
+	if len(rsp.UnsupportedAttributes) > 0 {
+		names := make(goipp.Values, 0, len(rsp.UnsupportedAttributes))
+		for _, name := range rsp.UnsupportedAttributes {
+			names.Add(goipp.TagKeyword, goipp.String(name))
+		}
+
+		attr := goipp.Attribute{
+			Name:   "requested-attributes",
+			Values: names,
+		}
+
+		groups.Add(goipp.Group{
+			Tag:   goipp.TagUnsupportedGroup,
+			Attrs: goipp.Attributes{attr},
+		})
+	}
+
+	groups.Add(goipp.Group{
+		Tag:   goipp.TagPrinterGroup,
+		Attrs: rawPrinterAttrs,
+	})
+
+	msg := goipp.NewMessageWithGroups(rsp.Version, goipp.Code(rsp.Status),
+		rsp.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes GetPrinterSupportedValuesResponse from goipp.Message.
+func (rsp *GetPrinterSupportedValuesResponse) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rsp.Version = msg.Version
+	rsp.RequestID = msg.RequestID
+	rsp.Status = goipp.Status(msg.Code)
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rsp, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	rsp.Printer, err = DecodePrinterAttributes(msg.Printer, opt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
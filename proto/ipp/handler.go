@@ -22,6 +22,47 @@ type Handler struct {
 		*goipp.Message, io.ReadCloser, error)
 }
 
+// HandlerFunc is the low-level IPP request handler. It receives the
+// decoded [goipp.Message] request and the request body (document data,
+// following the IPP message) and returns the response message and
+// optional response body.
+//
+// If HandlerFunc returns an *[ErrIPP] error, it is sent to the client
+// as the IPP error response. Other errors are reported at the HTTP
+// level.
+type HandlerFunc func(ctx context.Context, rq *goipp.Message,
+	body io.Reader) (*goipp.Message, io.Reader, error)
+
+// NewHandlerFunc creates a new IPP handler for the operation op from
+// the [HandlerFunc].
+//
+// Unlike handlers, created by the [NewHandler], the HandlerFunc
+// works with raw [goipp.Message] requests and responses, without
+// decoding them into the [Request] structures.
+func NewHandlerFunc(op goipp.Op, f HandlerFunc) *Handler {
+	callback := func(ctx context.Context,
+		rqMsg *goipp.Message, body io.Reader) (
+
+		*goipp.Message, io.ReadCloser, error) {
+
+		rsp, rspBody, err := f(ctx, rqMsg, body)
+		if rspBody == nil {
+			return rsp, nil, err
+		}
+
+		if rc, ok := rspBody.(io.ReadCloser); ok {
+			return rsp, rc, err
+		}
+
+		return rsp, io.NopCloser(rspBody), err
+	}
+
+	return &Handler{
+		Op:       op,
+		callback: callback,
+	}
+}
+
 // NewHandler creates a new IPP handler from the function that
 // consumes [Request] and returns the [goipp.Message] response:
 //
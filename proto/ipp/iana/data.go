@@ -8727,6 +8727,13 @@ var PrinterDescription = map[string]*DefAttr{
 		Max:   1023,
 		Tags:  []goipp.Tag{goipp.TagURI},
 	},
+	// Printer Description/printer-error-policy (CUPS)
+	"printer-error-policy": &DefAttr{
+		SetOf: false,
+		Min:   0,
+		Max:   127,
+		Tags:  []goipp.Tag{goipp.TagName},
+	},
 	// Printer Description/printer-fax-log-uri (PWG5100.15)
 	"printer-fax-log-uri": &DefAttr{
 		SetOf: false,
@@ -8897,6 +8904,13 @@ var PrinterDescription = map[string]*DefAttr{
 		Max:   127,
 		Tags:  []goipp.Tag{goipp.TagName},
 	},
+	// Printer Description/printer-op-policy (CUPS)
+	"printer-op-policy": &DefAttr{
+		SetOf: false,
+		Min:   0,
+		Max:   127,
+		Tags:  []goipp.Tag{goipp.TagName},
+	},
 	// Printer Description/printer-organization (PWG5100.13)
 	"printer-organization": &DefAttr{
 		SetOf: true,
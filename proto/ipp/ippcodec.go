@@ -21,6 +21,7 @@ import (
 	"github.com/OpenPrinting/go-mfp/internal/assert"
 	"github.com/OpenPrinting/go-mfp/proto/ipp/iana"
 	"github.com/OpenPrinting/go-mfp/util/generic"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
 	"github.com/OpenPrinting/goipp"
 )
 
@@ -34,10 +35,29 @@ type ippEncoder struct {
 // The obj parameter must be pointer to structure that implements
 // the Object interface. Its codec will be generated on demand.
 //
+// Raw attributes of the Object, not known to its structure (i.e.,
+// vendor extensions, preserved by decoder), are appended after the
+// typed ones, unless disabled by [ObjectRawAttrs.SetRawPassthrough].
+// Raw attributes that correspond to structure fields are never
+// appended, so typed fields always take precedence.
+//
 // This function will panic, if codec cannot be generated.
 func (enc *ippEncoder) Encode(obj Object) goipp.Attributes {
 	codec := ippCodecGet(obj)
-	return codec.encodeAttrs(enc, obj)
+	attrs := codec.encodeAttrs(enc, obj)
+
+	rawattrs := obj.RawAttrs()
+	if rawattrs.noPassthrough {
+		return attrs
+	}
+
+	for _, attr := range rawattrs.attrs {
+		if _, known := codec.stepsByName[attr.Name]; !known {
+			attrs = append(attrs, attr)
+		}
+	}
+
+	return attrs
 }
 
 // Encode: goipp.IntegerOrRange
@@ -91,6 +111,15 @@ func (enc *ippEncoder) encDateTime(p unsafe.Pointer) goipp.Values {
 	return out
 }
 
+// Encode: uuid.UUID
+//
+// UUID is always encoded in the urn:uuid: form, as RFC 8011 requires.
+func (enc *ippEncoder) encUUID(p unsafe.Pointer) goipp.Values {
+	in := *(*uuid.UUID)(p)
+	out := goipp.Values{{T: goipp.TagZero, V: goipp.String(in.URN())}}
+	return out
+}
+
 // Encode: bool
 func (enc *ippEncoder) encBool(p unsafe.Pointer) goipp.Values {
 	in := *(*bool)(p)
@@ -148,11 +177,13 @@ func (enc *ippEncoder) encSlice(
 		return nil
 	}
 
-	vals := make(goipp.Values, slice.Len())
-	for i := range vals {
+	// Note, the single-value encoder may return no values
+	// (i.e., for nil goipp.IntegerOrRange). Such elements
+	// are silently skipped.
+	vals := make(goipp.Values, 0, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
 		p2 := unsafe.Pointer(slice.Index(i).Addr().Pointer())
-		v := encode(enc, p2)
-		vals[i] = v[0]
+		vals = append(vals, encode(enc, p2)...)
 	}
 
 	return vals
@@ -483,6 +514,13 @@ func ippCodecGenerateStep(fld reflect.StructField,
 		return nil, err
 	}
 
+	// Attributes of the "keyword | name" syntax choose the
+	// encoding tag on per-value basis.
+	if fldKind == reflect.String &&
+		def.HasTag(goipp.TagKeyword) && def.HasTag(goipp.TagName) {
+		step.encode = ippCodecDualSyntaxEncoder(fldType, step.encode)
+	}
+
 	// Generate slice wrapper for slice fields.
 	if isSlice {
 		t := reflect.SliceOf(fldType)
@@ -520,6 +558,43 @@ func ippCodecGenerateStep(fld reflect.StructField,
 	return step, nil
 }
 
+// kwRegistered is implemented by the keyword types, used with
+// attributes of the "keyword | name" syntax, which know their
+// registered values.
+type kwRegistered interface {
+	IsRegistered() bool
+}
+
+// ippCodecDualSyntaxEncoder wraps the string encoder for attributes
+// of the "keyword | name" syntax (i.e., "media" or "job-hold-until").
+//
+// Like CUPS does, registered keywords are encoded as keyword and
+// other values as name. If type of the value implements kwRegistered
+// interface, it decides. Otherwise, any syntactically valid keyword
+// is considered registered.
+func ippCodecDualSyntaxEncoder(t reflect.Type, encode encodeFunc) encodeFunc {
+	isKwRegistered := t.Implements(reflect.TypeOf((*kwRegistered)(nil)).Elem())
+
+	return func(enc *ippEncoder, p unsafe.Pointer) goipp.Values {
+		vals := encode(enc, p)
+
+		var keyword bool
+		if isKwRegistered {
+			v := reflect.NewAt(t, p).Elem().Interface()
+			keyword = v.(kwRegistered).IsRegistered()
+		} else {
+			keyword = kwIsKeywordSyntax(*(*string)(p))
+		}
+
+		vals[0].T = goipp.TagName
+		if keyword {
+			vals[0].T = goipp.TagKeyword
+		}
+
+		return vals
+	}
+}
+
 // Encode structure into the goipp.Attributes
 func (codec *ippCodec) encodeAttrs(enc *ippEncoder,
 	in interface{}) (attrs goipp.Attributes) {
@@ -787,6 +862,12 @@ var ippCodecMethodsByType = map[reflect.Type]*ippCodecMethods{
 		encode:        (*ippEncoder).encDateTime,
 		decode:        (*Decoder).decDateTime,
 	},
+
+	reflect.TypeOf(uuid.UUID{}): &ippCodecMethods{
+		defaultIppTag: goipp.TagURI,
+		encode:        (*ippEncoder).encUUID,
+		decode:        (*Decoder).decUUID,
+	},
 }
 
 // ippCodecMethodsByKind maps reflect.Kind to the particular
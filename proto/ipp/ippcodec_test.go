@@ -11,11 +11,13 @@ package ipp
 import (
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/OpenPrinting/go-mfp/proto/ipp/iana"
 	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
 	"github.com/OpenPrinting/goipp"
 )
 
@@ -192,6 +194,8 @@ func TestIppCodecStandardTypes(t *testing.T) {
 		&CUPSGetDefaultResponse{},
 		&CUPSGetDevicesRequest{},
 		&CUPSGetDevicesResponse{},
+		&CUPSGetDocumentRequest{},
+		&CUPSGetDocumentResponse{},
 		&CUPSGetPPDRequest{},
 		&CUPSGetPPDResponse{},
 		&CUPSGetPPDsRequest{},
@@ -204,6 +208,8 @@ func TestIppCodecStandardTypes(t *testing.T) {
 		&JobTemplate{},
 		&PPDAttributes{},
 		&PrinterAttributes{},
+		&PrintJobRequest{},
+		&PrintJobResponse{},
 		&SendDocumentRequest{},
 		&SendDocumentResponse{},
 		&ValidateJobRequest{},
@@ -221,6 +227,8 @@ func TestIppCodecStandardTypes(t *testing.T) {
 		&GetNextDocumentDataResponse{},
 		&GetPrinterAttributesRequest{},
 		&GetPrinterAttributesResponse{},
+		&GetPrinterSupportedValuesRequest{},
+		&GetPrinterSupportedValuesResponse{},
 		&JobDescriptionAndStatus{},
 	}
 
@@ -1411,3 +1419,296 @@ var testdataPrinterAttributes = PrinterAttributes{
 		},
 	},
 }
+
+// TestIppDualSyntax tests handling of attributes of the
+// "keyword | name" syntax
+func TestIppDualSyntax(t *testing.T) {
+	// Decode: both tags must be accepted
+	type decodeTestData struct {
+		attr      goipp.Attribute
+		media     KwMedia
+		holdUntil KwJobHoldUntil
+	}
+
+	decodeTests := []decodeTestData{
+		{
+			attr: goipp.MakeAttribute("media", goipp.TagKeyword,
+				goipp.String("iso_a4_210x297mm")),
+			media: "iso_a4_210x297mm",
+		},
+		{
+			attr: goipp.MakeAttribute("media", goipp.TagName,
+				goipp.String("my-letterhead")),
+			media: "my-letterhead",
+		},
+		{
+			attr: goipp.MakeAttribute("media", goipp.TagNameLang,
+				goipp.TextWithLang{Lang: "en-US", Text: "My Paper"}),
+			media: "My Paper",
+		},
+		{
+			attr: goipp.MakeAttribute("job-hold-until",
+				goipp.TagKeyword, goipp.String("indefinite")),
+			holdUntil: KwJobHoldUntilIndefinite,
+		},
+		{
+			attr: goipp.MakeAttribute("job-hold-until",
+				goipp.TagName, goipp.String("lunch-break")),
+			holdUntil: "lunch-break",
+		},
+	}
+
+	for _, test := range decodeTests {
+		var jt JobTemplate
+		dec := NewDecoder(nil)
+		err := dec.Decode(&jt, goipp.Attributes{test.attr})
+		if err != nil {
+			t.Errorf("%s: decode error: %s", test.attr.Name, err)
+			continue
+		}
+
+		if errs := dec.Errors(); len(errs) != 0 {
+			t.Errorf("%s: decode warnings: %v", test.attr.Name, errs)
+		}
+
+		media := optional.Get(jt.Media)
+		holdUntil := optional.Get(jt.JobHoldUntil)
+		if media != test.media || holdUntil != test.holdUntil {
+			t.Errorf("%s %s: expected (%q,%q), present (%q,%q)",
+				test.attr.Name, test.attr.Values[0].T,
+				test.media, test.holdUntil, media, holdUntil)
+		}
+	}
+
+	// Encode: tag depends on value
+	type encodeTestData struct {
+		media     KwMedia
+		holdUntil KwJobHoldUntil
+		tag       goipp.Tag
+	}
+
+	encodeTests := []encodeTestData{
+		{media: KwMediaIsoA4, tag: goipp.TagKeyword},
+		{media: "custom_foo_100x150mm", tag: goipp.TagKeyword},
+		{media: "na_foo_8.5x14in", tag: goipp.TagKeyword},
+		{media: "my-letterhead", tag: goipp.TagName},
+		{media: "custom_foo_100xmm", tag: goipp.TagName},
+		{holdUntil: KwJobHoldUntilNight, tag: goipp.TagKeyword},
+		{holdUntil: "lunch-break", tag: goipp.TagName},
+	}
+
+	for _, test := range encodeTests {
+		var jt JobTemplate
+		name := "media"
+		if test.media != "" {
+			jt.Media = optional.New(test.media)
+		} else {
+			name = "job-hold-until"
+			jt.JobHoldUntil = optional.New(test.holdUntil)
+		}
+
+		enc := ippEncoder{}
+		attrs := enc.Encode(&jt)
+		if attrs[0].Name != name {
+			t.Errorf("%s: unexpected encoding: %v", name, attrs)
+			continue
+		}
+
+		tag := attrs[0].Values[0].T
+		if tag != test.tag {
+			t.Errorf("%s %q: expected %s, present %s",
+				name, attrs[0].Values[0].V, test.tag, tag)
+		}
+	}
+
+	// Plain strings use the keyword syntax check
+	var jt JobTemplate
+	jt.JobSheetsCol = optional.New(JobSheets{
+		MediaCol: MediaCol{
+			MediaType:   optional.New("stationery-letterhead"),
+			MediaSource: optional.New("Tray 1"),
+		},
+	})
+
+	enc := ippEncoder{}
+	attrs := enc.Encode(&jt)
+
+	var mediaCol goipp.Collection
+	for _, attr := range attrs {
+		if attr.Name != "job-sheets-col" {
+			continue
+		}
+
+		for _, member := range attr.Values[0].V.(goipp.Collection) {
+			if member.Name == "media-col" {
+				mediaCol = member.Values[0].V.(goipp.Collection)
+			}
+		}
+	}
+
+	if len(mediaCol) != 2 {
+		t.Errorf("media-col: unexpected encoding: %v", mediaCol)
+	}
+
+	for _, attr := range mediaCol {
+		expected := goipp.TagKeyword
+		if attr.Name == "media-source" {
+			expected = goipp.TagName
+		}
+
+		if tag := attr.Values[0].T; tag != expected {
+			t.Errorf("%s: expected %s, present %s",
+				attr.Name, expected, tag)
+		}
+	}
+}
+
+// TestIppUUID tests encoding and decoding of uuid.UUID attributes
+func TestIppUUID(t *testing.T) {
+	const s = "4509a320-00a0-008f-00b6-00559a327d32"
+	expected := uuid.MustParse(s)
+
+	// Both urn:uuid: and bare forms must be accepted
+	for _, in := range []string{"urn:uuid:" + s, s} {
+		attrs := goipp.Attributes{
+			goipp.MakeAttribute("printer-uuid",
+				goipp.TagURI, goipp.String(in)),
+		}
+
+		pa, err := DecodePrinterAttributes(attrs, nil)
+		if err != nil {
+			t.Errorf("%q: %s", in, err)
+			continue
+		}
+
+		if optional.Get(pa.PrinterUUID) != expected {
+			t.Errorf("%q: decoded as %s", in,
+				optional.Get(pa.PrinterUUID))
+		}
+
+		if pa.PrinterUUIDString() != "urn:uuid:"+s {
+			t.Errorf("%q: PrinterUUIDString returned %q",
+				in, pa.PrinterUUIDString())
+		}
+
+		// Round trip: always encoded as urn:uuid:
+		enc := ippEncoder{}
+		var out goipp.Attributes
+		for _, attr := range enc.Encode(pa) {
+			if attr.Name == "printer-uuid" {
+				out = append(out, attr)
+			}
+		}
+
+		if len(out) != 1 || out[0].Values[0].T != goipp.TagURI ||
+			out[0].Values[0].V.String() != "urn:uuid:"+s {
+			t.Errorf("%q: encoded as %v", in, out)
+			continue
+		}
+
+		pa2, err := DecodePrinterAttributes(out, nil)
+		if err != nil || !reflect.DeepEqual(pa2.PrinterUUID,
+			pa.PrinterUUID) {
+			t.Errorf("%q: round trip failed: %v", in, err)
+		}
+	}
+
+	// Malformed value in lenient mode: per-attribute error,
+	// other attributes are decoded.
+	attrs := goipp.Attributes{
+		goipp.MakeAttribute("printer-uuid",
+			goipp.TagURI, goipp.String("urn:uuid:not-a-uuid")),
+		goipp.MakeAttribute("device-uuid",
+			goipp.TagURI, goipp.String(s)),
+	}
+
+	dec := NewDecoder(&DecoderOptions{KeepTrying: true})
+	defer dec.Free()
+
+	var pa PrinterAttributes
+	err := dec.Decode(&pa, attrs)
+	if err != nil {
+		t.Errorf("KeepTrying: unexpected error: %s", err)
+	}
+
+	errs := dec.Errors()
+	if len(errs) != 1 ||
+		!strings.Contains(errs[0].Error(), `"printer-uuid"`) {
+		t.Errorf("KeepTrying: printer-uuid error expected, "+
+			"present: %v", errs)
+	}
+
+	if pa.PrinterUUID != nil || pa.PrinterUUIDString() != "" {
+		t.Errorf("KeepTrying: printer-uuid must be reset")
+	}
+
+	if optional.Get(pa.DeviceUUID) != expected {
+		t.Errorf("KeepTrying: device-uuid decoded as %s",
+			optional.Get(pa.DeviceUUID))
+	}
+
+	// Strict mode: decode fails
+	_, err = DecodePrinterAttributes(attrs, nil)
+	if err == nil {
+		t.Errorf("strict: error expected")
+	}
+}
+
+// TestIppDateTimeZone tests that dateTime values keep their UTC
+// offset through the decode/encode round trip
+func TestIppDateTimeZone(t *testing.T) {
+	zones := []*time.Location{
+		time.FixedZone("", 3*3600),
+		time.FixedZone("", -(5*3600 + 30*60)),
+		time.FixedZone("", 9*3600+30*60),
+		time.UTC,
+	}
+
+	for _, zone := range zones {
+		in := time.Date(2025, time.March, 30, 1, 30, 15,
+			700000000, zone)
+		_, offset := in.Zone()
+
+		// Pass the value through the wire format
+		msg := goipp.NewResponse(goipp.DefaultVersion, goipp.StatusOk, 1)
+		msg.Printer.Add(goipp.MakeAttribute("printer-current-time",
+			goipp.TagDateTime, goipp.Time{Time: in}))
+
+		data, err := msg.EncodeBytes()
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		msg = &goipp.Message{}
+		err = msg.DecodeBytes(data)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		pa, err := DecodePrinterAttributes(msg.Printer, nil)
+		if err != nil {
+			t.Errorf("%s: %s", in, err)
+			continue
+		}
+
+		out := optional.Get(pa.PrinterCurrentTime)
+		_, outOffset := out.Zone()
+		if !out.Equal(in) || outOffset != offset {
+			t.Errorf("decode: expected %s, present %s", in, out)
+		}
+
+		// Encode again and compare with the original
+		enc := ippEncoder{}
+		var attrs goipp.Attributes
+		for _, attr := range enc.Encode(pa) {
+			if attr.Name == "printer-current-time" {
+				attrs = append(attrs, attr)
+			}
+		}
+
+		if !attrs.Equal(msg.Printer) {
+			t.Errorf("encode: expected %s, present %s",
+				msg.Printer, attrs)
+		}
+	}
+}
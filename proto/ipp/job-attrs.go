@@ -15,6 +15,7 @@ import (
 	"github.com/OpenPrinting/go-mfp/proto/ipp/iana"
 	"github.com/OpenPrinting/go-mfp/util/generic"
 	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
 	"github.com/OpenPrinting/goipp"
 )
 
@@ -136,12 +137,13 @@ type JobDescriptionAttrs struct {
 type JobStatusAttrs struct {
 	JobStatusGroup
 
-	JobImpressionsCompleted optional.Val[int]    `ipp:"job-impressions-completed"`
-	JobMediaSheetsCompleted optional.Val[int]    `ipp:"job-media-sheets-completed"`
-	JobState                EnJobState           `ipp:"job-state"`
-	JobStateMessage         optional.Val[string] `ipp:"job-state-message"`
-	JobStateReasons         []KwJobStateReasons  `ipp:"job-state-reasons"`
-	NumberOfInterveningJobs optional.Val[int]    `ipp:"number-of-intervening-jobs"`
+	JobImpressionsCompleted optional.Val[int]       `ipp:"job-impressions-completed"`
+	JobMediaSheetsCompleted optional.Val[int]       `ipp:"job-media-sheets-completed"`
+	JobState                EnJobState              `ipp:"job-state"`
+	JobStateMessage         optional.Val[string]    `ipp:"job-state-message"`
+	JobStateReasons         []KwJobStateReasons     `ipp:"job-state-reasons"`
+	JobUUID                 optional.Val[uuid.UUID] `ipp:"job-uuid"`
+	NumberOfInterveningJobs optional.Val[int]       `ipp:"number-of-intervening-jobs"`
 }
 
 // JobDescriptionAndStatus holds job-description and job-status attributes
@@ -165,6 +167,12 @@ type JobGroupEntry struct {
 	JobTemplateAttrs
 }
 
+// JobUUIDString returns job-uuid as string, in the urn:uuid:
+// form, or "" if attribute is missed.
+func (attrs *JobStatusAttrs) JobUUIDString() string {
+	return uuidString(attrs.JobUUID)
+}
+
 // DecodeJobDescriptionAndStatus decodes [JobDescriptionAndStatus] from
 // [goipp.Attributes].
 func DecodeJobDescriptionAndStatus(attrs goipp.Attributes, opt *DecoderOptions) (
@@ -222,7 +230,7 @@ type JobTemplateAttrs struct {
 	JobRetainUntilInterval  optional.Val[int]                   `ipp:"job-retain-until-interval"`
 	JobRetainUntilTime      optional.Val[time.Time]             `ipp:"job-retain-until-time"`
 	JobSheetMessage         optional.Val[string]                `ipp:"job-sheet-message"`
-	JobSheetsCol            JobSheets                           `ipp:"job-sheets-col"`
+	JobSheetsCol            optional.Val[JobSheets]             `ipp:"job-sheets-col"`
 	PrintContentOptimize    optional.Val[string]                `ipp:"print-content-optimize"`
 
 	// PWG5100.11: IPP Job and Printer Extensions – Set 2 (JPS2)
@@ -233,11 +241,11 @@ type JobTemplateAttrs struct {
 
 	// PWG5100.13: IPP Driver Replacement Extensions v2.0 (NODRIVER)
 	// 6.2 Job and Document Template Attributes
-	JobErrorAction       optional.Val[string] `ipp:"job-error-action"`
-	MediaOverprint       MediaOverprint       `ipp:"media-overprint"`
-	PrintColorMode       optional.Val[string] `ipp:"print-color-mode"`
-	PrintRenderingIntent optional.Val[string] `ipp:"print-rendering-intent"`
-	PrintScaling         optional.Val[string] `ipp:"print-scaling"`
+	JobErrorAction       optional.Val[string]         `ipp:"job-error-action"`
+	MediaOverprint       optional.Val[MediaOverprint] `ipp:"media-overprint"`
+	PrintColorMode       optional.Val[string]         `ipp:"print-color-mode"`
+	PrintRenderingIntent optional.Val[string]         `ipp:"print-rendering-intent"`
+	PrintScaling         optional.Val[string]         `ipp:"print-scaling"`
 
 	// Wi-Fi Peer-to-Peer Services Print (P2Ps-Print)
 	// Technical Specification
@@ -388,7 +396,7 @@ type JobTemplateCapabilities struct {
 // JobTemplate
 type JobSheets struct {
 	JobSheets KwJobSheets `ipp:"job-sheets"`
-	Media     string      `ipp:"media"`
+	Media     KwMedia     `ipp:"media"`
 	MediaCol  MediaCol    `ipp:"media-col"`
 }
 
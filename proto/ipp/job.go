@@ -23,6 +23,7 @@ type job struct {
 	JobTemplateAttrs               // Job Template attributes (settings)
 	JobCreateOperation             // Job create-time operation attributes
 	SendDocumentActive  bool       // Send-Document in progress
+	documents           int        // Count of received documents
 	cancelPending       bool       // Cancel-Job accepted, not yet canceled
 	lock                sync.Mutex // Access lock
 }
@@ -43,6 +44,7 @@ func newJob(ops *JobCreateOperation, attrs *JobTemplate) *job {
 			JobMediaSheetsCompleted: optional.New(0),
 			JobState:                EnJobStatePendingHeld,
 			JobStateReasons:         []KwJobStateReasons{KwJobStateReasonsJobIncoming},
+			JobUUID:                 optional.New(uu),
 		},
 		JobTemplateAttrs:   attrs.JobTemplateAttrs,
 		JobCreateOperation: *ops,
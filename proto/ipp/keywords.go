@@ -71,6 +71,22 @@ const (
 	KwJobDelayOutputUntilWeekend KwJobDelayOutputUntil = "weekend"
 )
 
+// IsRegistered reports if value is the registered keyword.
+//
+// "job-delay-output-until" uses the "keyword | name" syntax, and
+// unregistered values are encoded as name.
+func (kw KwJobDelayOutputUntil) IsRegistered() bool {
+	switch kw {
+	case KwJobDelayOutputUntilDayTime, KwJobDelayOutputUntilEvening,
+		KwJobDelayOutputUntilIndefinite, KwJobDelayOutputUntilNight,
+		KwJobDelayOutputUntilNoDelayOutput,
+		KwJobDelayOutputUntilSecondShift,
+		KwJobDelayOutputUntilThirdShift, KwJobDelayOutputUntilWeekend:
+		return true
+	}
+	return false
+}
+
 // KwJobHoldUntil represents standard keyword values for
 // "job-hold-until" attribute.
 //
@@ -110,6 +126,21 @@ const (
 	KwJobHoldUntilThirdShift KwJobHoldUntil = "third-shift"
 )
 
+// IsRegistered reports if value is the registered keyword.
+//
+// "job-hold-until" uses the "keyword | name" syntax, and
+// unregistered values are encoded as name.
+func (kw KwJobHoldUntil) IsRegistered() bool {
+	switch kw {
+	case KwJobHoldUntilNoHold, KwJobHoldUntilIndefinite,
+		KwJobHoldUntilDayTime, KwJobHoldUntilEvening,
+		KwJobHoldUntilNight, KwJobHoldUntilWeekend,
+		KwJobHoldUntilSecondShift, KwJobHoldUntilThirdShift:
+		return true
+	}
+	return false
+}
+
 // KwJobSheets represents standard keyword values for
 // "job-sheets" attribute.
 //
@@ -130,7 +161,7 @@ const (
 
 	// KwJobSheetsJobStartSheet means that a ob Sheet is printed to
 	// indicate the start of the Job.
-	KwJobSheetsJobStartSheet KwJobSheets = "job-start-sheet "
+	KwJobSheetsJobStartSheet KwJobSheets = "job-start-sheet"
 
 	// KwJobSheetsJobEndSheet means that a ob Sheet is printed to
 	// indicate the end of the Job.
@@ -147,6 +178,20 @@ const (
 	KwJobSheetsFirstPrintStreamPage KwJobSheets = "first-print-stream-page"
 )
 
+// IsRegistered reports if value is the registered keyword.
+//
+// "job-sheets" uses the "keyword | name" syntax, and
+// unregistered values are encoded as name.
+func (kw KwJobSheets) IsRegistered() bool {
+	switch kw {
+	case KwJobSheetsNone, KwJobSheetsStandard,
+		KwJobSheetsJobStartSheet, KwJobSheetsJobEndSheet,
+		KwJobSheetsJobBothSheets, KwJobSheetsFirstPrintStreamPage:
+		return true
+	}
+	return false
+}
+
 // KwJobSpooling represents standard keyword values for
 // "job-spooling-supported" attribute.
 //
@@ -615,6 +660,30 @@ const (
 	KwRequestedAttributeJobURI KwRequestedAttribute = "job-uri"
 )
 
+// kwIsKeywordSyntax reports if string is syntactically valid keyword.
+//
+// Per RFC8011, 5.1.4, keyword consists of US-ASCII lowercase
+// letters, digits, hyphens, dots and underscores and must
+// begin with the lowercase letter.
+func kwIsKeywordSyntax(s string) bool {
+	if s == "" || len(s) > 255 || s[0] < 'a' || s[0] > 'z' {
+		return false
+	}
+
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z':
+		case '0' <= c && c <= '9':
+		case c == '-' || c == '.' || c == '_':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
 // kwRegisteredTypes lists all registered keyword types for IPP codec.
 var kwRegisteredTypes = map[reflect.Type]struct{}{
 	// Types, defined here
@@ -35,3 +35,60 @@ const (
 	// class, because this protocol uses a pseudo-network connection.
 	KwDeviceClassNetwork KwDeviceClass = "network"
 )
+
+// KwPrinterErrorPolicy represents known values of the CUPS'
+// "printer-error-policy" attribute, that defines what CUPS does
+// when the backend fails to send a job to the printer.
+//
+// Although these values are keywords by meaning, the attribute
+// uses the name syntax.
+//
+// See [CUPS Implementation of IPP] for details.
+//
+// [CUPS Implementation of IPP]: https://www.cups.org/doc/spec-ipp.html
+type KwPrinterErrorPolicy string
+
+const (
+	// KwPrinterErrorPolicyAbortJob means the job is aborted
+	// and deleted.
+	KwPrinterErrorPolicyAbortJob KwPrinterErrorPolicy = "abort-job"
+
+	// KwPrinterErrorPolicyRetryCurrentJob means the job is
+	// immediately retried.
+	KwPrinterErrorPolicyRetryCurrentJob KwPrinterErrorPolicy = "retry-current-job"
+
+	// KwPrinterErrorPolicyRetryJob means the job is retried later,
+	// after the configured job retry interval.
+	KwPrinterErrorPolicyRetryJob KwPrinterErrorPolicy = "retry-job"
+
+	// KwPrinterErrorPolicyStopPrinter means the printer is stopped
+	// and the job is kept queued.
+	KwPrinterErrorPolicyStopPrinter KwPrinterErrorPolicy = "stop-printer"
+)
+
+// IsKnown reports if value is the known "printer-error-policy" value.
+func (kw KwPrinterErrorPolicy) IsKnown() bool {
+	switch kw {
+	case KwPrinterErrorPolicyAbortJob, KwPrinterErrorPolicyRetryCurrentJob,
+		KwPrinterErrorPolicyRetryJob, KwPrinterErrorPolicyStopPrinter:
+		return true
+	}
+	return false
+}
+
+// KwPrinterOpPolicy represents the CUPS' "printer-op-policy" attribute,
+// the name of the operation policy, defined in the cupsd.conf file.
+//
+// CUPS allows arbitrary policy names, and only the policies that
+// come with the default configuration are defined here. The attribute
+// uses the name syntax.
+type KwPrinterOpPolicy string
+
+const (
+	// KwPrinterOpPolicyDefault is the default operation policy.
+	KwPrinterOpPolicyDefault KwPrinterOpPolicy = "default"
+
+	// KwPrinterOpPolicyAuthenticated is the policy that requires
+	// authentication for all job operations.
+	KwPrinterOpPolicyAuthenticated KwPrinterOpPolicy = "authenticated"
+)
@@ -8,6 +8,8 @@
 
 package ipp
 
+import "strings"
+
 // KwMedia represents standard media size. Used in many places
 type KwMedia string
 
@@ -235,6 +237,66 @@ func (kw KwMedia) Size() (wid, hei int) {
 	return -1, -1
 }
 
+// IsRegistered reports if media name is the registered keyword.
+//
+// The name is considered registered, if it is one of the standard
+// names, known to this package, or if it is syntactically valid
+// PWG5101.1 self-describing media name (class_name_WxHunit).
+//
+// "media" uses the "keyword | name" syntax, and unregistered
+// values (i.e., "my-letterhead") are encoded as name.
+func (kw KwMedia) IsRegistered() bool {
+	if _, ok := kwMediaByName[kw]; ok {
+		return true
+	}
+
+	parts := strings.Split(string(kw), "_")
+	if len(parts) < 3 {
+		return false
+	}
+
+	last := len(parts) - 1
+	for _, part := range parts[:last] {
+		if !kwIsKeywordSyntax(part) {
+			return false
+		}
+	}
+
+	return kwMediaIsDimensions(parts[last])
+}
+
+// kwMediaIsDimensions reports if s is the PWG5101.1 media
+// dimensions part of the self-describing media name, i.e.,
+// "210x297mm" or "8.5x11in".
+func kwMediaIsDimensions(s string) bool {
+	switch {
+	case strings.HasSuffix(s, "mm"), strings.HasSuffix(s, "in"):
+		s = s[:len(s)-2]
+	default:
+		return false
+	}
+
+	wid, hei, found := strings.Cut(s, "x")
+	return found && kwMediaIsNumber(wid) && kwMediaIsNumber(hei)
+}
+
+// kwMediaIsNumber reports if s is non-negative decimal number
+// with optional fractional part.
+func kwMediaIsNumber(s string) bool {
+	intpart, frac, found := strings.Cut(s, ".")
+	if intpart == "" || (found && frac == "") {
+		return false
+	}
+
+	for _, c := range intpart + frac {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
 // kwMediaSize represents media size, associated with the media name.
 type kwMediaSize struct {
 	wid, hei int // in 1/100 mm
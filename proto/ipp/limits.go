@@ -0,0 +1,218 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// RFC 8011 limits of attribute values
+
+package ipp
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/OpenPrinting/goipp"
+)
+
+// Maximal length of attribute values, in octets, per RFC 8011:
+const (
+	MaxKeywordLength = 255  // keyword, 5.1.4
+	MaxNameLength    = 255  // name, 5.1.3
+	MaxTextLength    = 1023 // text, 5.1.2
+)
+
+// Strictness specifies how the RFC 8011 limits of the outgoing
+// attribute values are enforced (see [CheckLimits]).
+type Strictness int
+
+// Strictness values:
+const (
+	// StrictnessOff disables checks
+	StrictnessOff Strictness = iota
+
+	// StrictnessReject rejects values that violate limits
+	StrictnessReject
+
+	// StrictnessTruncate truncates oversized text and name
+	// values and reports them as warnings. Other violations
+	// are rejected, as with StrictnessReject.
+	StrictnessTruncate
+)
+
+// String returns the Strictness name, for debugging.
+func (strict Strictness) String() string {
+	switch strict {
+	case StrictnessOff:
+		return "off"
+	case StrictnessReject:
+		return "reject"
+	case StrictnessTruncate:
+		return "truncate"
+	}
+
+	return fmt.Sprintf("Strictness(%d)", int(strict))
+}
+
+// LimitError describes the violation of the attribute value limits.
+type LimitError struct {
+	Attr       string // Attribute name; "col/member" for members
+	Constraint string // Violated constraint
+}
+
+// Error returns the error message. It implements the error interface.
+func (err *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Attr, err.Constraint)
+}
+
+// CheckMessageLimits checks values of all attributes of the message
+// against RFC 8011 limits, as [CheckLimits] does.
+func CheckMessageLimits(msg *goipp.Message, strict Strictness) (
+	warnings []error, err error) {
+
+	for _, grp := range msg.AttrGroups() {
+		var w []error
+		w, err = CheckLimits(grp.Attrs, strict)
+		warnings = append(warnings, w...)
+		if err != nil {
+			break
+		}
+	}
+
+	return
+}
+
+// CheckLimits checks values of the attributes against RFC 8011
+// limits: maximal length of the keyword, name and text values
+// and charset of the keyword values. Members of collections are
+// checked recursively.
+//
+// The first violation is returned as the [LimitError].
+//
+// With the [StrictnessTruncate], oversized text and name values
+// are truncated in place at the UTF-8 character boundary and
+// returned as warnings.
+func CheckLimits(attrs goipp.Attributes, strict Strictness) (
+	warnings []error, err error) {
+	return limitsCheckAttrs(attrs, "", strict)
+}
+
+// limitsCheckAttrs does the real work of CheckLimits.
+// The prefix is prepended to the attribute names.
+func limitsCheckAttrs(attrs goipp.Attributes, prefix string,
+	strict Strictness) (warnings []error, err error) {
+
+	if strict == StrictnessOff {
+		return nil, nil
+	}
+
+	for _, attr := range attrs {
+		name := prefix + attr.Name
+
+		for i := range attr.Values {
+			tv := &attr.Values[i]
+
+			if col, ok := tv.V.(goipp.Collection); ok {
+				var w []error
+				w, err = limitsCheckAttrs(goipp.Attributes(col),
+					name+"/", strict)
+				warnings = append(warnings, w...)
+				if err != nil {
+					return
+				}
+				continue
+			}
+
+			constraint, max := limitsCheckValue(tv.T, tv.V)
+			if constraint == "" {
+				continue
+			}
+
+			lerr := &LimitError{Attr: name, Constraint: constraint}
+			if strict != StrictnessTruncate || max == 0 {
+				return warnings, lerr
+			}
+
+			tv.V = limitsTruncate(tv.V, max)
+			lerr.Constraint += ", truncated"
+			warnings = append(warnings, lerr)
+		}
+	}
+
+	return
+}
+
+// limitsCheckValue checks a single value against RFC 8011 limits.
+//
+// It returns the violated constraint, or "" if value is OK. For
+// the oversized value that can be truncated, it also returns the
+// maximal length.
+func limitsCheckValue(tag goipp.Tag, v goipp.Value) (string, int) {
+	var s string
+	switch v := v.(type) {
+	case goipp.String:
+		s = string(v)
+	case goipp.TextWithLang:
+		s = v.Text
+	default:
+		return "", 0
+	}
+
+	switch tag {
+	case goipp.TagKeyword:
+		if len(s) > MaxKeywordLength {
+			return fmt.Sprintf("keyword length %d exceeds %d octets",
+				len(s), MaxKeywordLength), 0
+		}
+
+		if !ValidateKeyword(s) {
+			return fmt.Sprintf("keyword %q contains invalid characters",
+				s), 0
+		}
+
+	case goipp.TagName, goipp.TagNameLang:
+		if len(s) > MaxNameLength {
+			return fmt.Sprintf("name length %d exceeds %d octets",
+				len(s), MaxNameLength), MaxNameLength
+		}
+
+	case goipp.TagText, goipp.TagTextLang:
+		if len(s) > MaxTextLength {
+			return fmt.Sprintf("text length %d exceeds %d octets",
+				len(s), MaxTextLength), MaxTextLength
+		}
+	}
+
+	return "", 0
+}
+
+// limitsTruncate truncates the string value to at most max octets,
+// without splitting UTF-8 characters.
+func limitsTruncate(v goipp.Value, max int) goipp.Value {
+	truncate := func(s string) string {
+		if len(s) <= max {
+			return s
+		}
+
+		s = s[:max]
+		for len(s) > 0 {
+			r, sz := utf8.DecodeLastRuneInString(s)
+			if r != utf8.RuneError || sz != 1 {
+				break
+			}
+			s = s[:len(s)-1]
+		}
+
+		return s
+	}
+
+	switch v := v.(type) {
+	case goipp.String:
+		return goipp.String(truncate(string(v)))
+	case goipp.TextWithLang:
+		v.Text = truncate(v.Text)
+		return v
+	}
+
+	return v
+}
@@ -0,0 +1,271 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// RFC 8011 limits of attribute values test
+
+package ipp
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestCheckLimits tests CheckLimits
+func TestCheckLimits(t *testing.T) {
+	longName := strings.Repeat("n", 300)
+	longText := strings.Repeat("t", 1020) + "éé" // 1024 octets
+
+	type testData struct {
+		attr   goipp.Attribute        // Input attribute
+		strict Strictness             // Strictness
+		err    string                 // Expected error
+		warn   string                 // Expected warning
+		check  func(goipp.Value) bool // Checks the result value
+	}
+
+	tests := []testData{
+		// Keyword charset
+		{
+			attr: goipp.MakeAttribute("sides",
+				goipp.TagKeyword, goipp.String("one sided")),
+			strict: StrictnessReject,
+			err:    `sides: keyword "one sided" contains invalid characters`,
+		},
+
+		// Keywords are never truncated
+		{
+			attr: goipp.MakeAttribute("media",
+				goipp.TagKeyword, goipp.String(longName)),
+			strict: StrictnessTruncate,
+			err:    "media: keyword length 300 exceeds 255 octets",
+		},
+
+		// Name length
+		{
+			attr: goipp.MakeAttribute("job-name",
+				goipp.TagName, goipp.String(longName)),
+			strict: StrictnessReject,
+			err:    "job-name: name length 300 exceeds 255 octets",
+		},
+
+		{
+			attr: goipp.MakeAttribute("job-name",
+				goipp.TagName, goipp.String(longName)),
+			strict: StrictnessTruncate,
+			warn:   "job-name: name length 300 exceeds 255 octets, truncated",
+			check: func(v goipp.Value) bool {
+				return v.(goipp.String) == goipp.String(longName[:255])
+			},
+		},
+
+		// Text length; truncation doesn't split UTF-8 characters
+		{
+			attr: goipp.MakeAttribute("job-message-from-operator",
+				goipp.TagText, goipp.String(longText)),
+			strict: StrictnessReject,
+			err:    "job-message-from-operator: text length 1024 exceeds 1023 octets",
+		},
+
+		{
+			attr: goipp.MakeAttribute("job-message-from-operator",
+				goipp.TagTextLang, goipp.TextWithLang{
+					Lang: "fr", Text: longText}),
+			strict: StrictnessTruncate,
+			warn:   "job-message-from-operator: text length 1024 exceeds 1023 octets, truncated",
+			check: func(v goipp.Value) bool {
+				s := v.(goipp.TextWithLang).Text
+				return len(s) == 1022 && utf8.ValidString(s)
+			},
+		},
+
+		// Collection members
+		{
+			attr: goipp.MakeAttribute("media-col",
+				goipp.TagBeginCollection, goipp.Collection{
+					goipp.MakeAttribute("media-type",
+						goipp.TagKeyword,
+						goipp.String("Plain Paper")),
+				}),
+			strict: StrictnessReject,
+			err:    `media-col/media-type: keyword "Plain Paper" contains invalid characters`,
+		},
+
+		// Valid values and disabled checks
+		{
+			attr: goipp.MakeAttribute("sides",
+				goipp.TagKeyword, goipp.String("two-sided-long-edge")),
+			strict: StrictnessReject,
+		},
+
+		{
+			attr: goipp.MakeAttribute("job-name",
+				goipp.TagName, goipp.String(longName)),
+			strict: StrictnessOff,
+		},
+	}
+
+	for _, test := range tests {
+		attrs := goipp.Attributes{test.attr}
+		warnings, err := CheckLimits(attrs, test.strict)
+
+		errStr := ""
+		if err != nil {
+			errStr = err.Error()
+
+			var lerr *LimitError
+			if !errors.As(err, &lerr) {
+				t.Errorf("%s: LimitError expected", test.attr.Name)
+			}
+		}
+
+		if errStr != test.err {
+			t.Errorf("%s (%s): error mismatch:\n"+
+				"expected: %q\npresent:  %q",
+				test.attr.Name, test.strict, test.err, errStr)
+		}
+
+		warnStr := ""
+		if len(warnings) != 0 {
+			warnStr = warnings[0].Error()
+		}
+
+		if warnStr != test.warn || len(warnings) > 1 {
+			t.Errorf("%s (%s): warning mismatch:\n"+
+				"expected: %q\npresent:  %v",
+				test.attr.Name, test.strict, test.warn, warnings)
+		}
+
+		if test.check != nil && !test.check(attrs[0].Values[0].V) {
+			t.Errorf("%s (%s): unexpected value: %s",
+				test.attr.Name, test.strict, attrs[0].Values)
+		}
+	}
+}
+
+// TestClientStrictness tests enforcement of RFC 8011 limits
+// by the Client
+func TestClientStrictness(t *testing.T) {
+	var lock sync.Mutex
+	var received []*goipp.Message
+
+	options := PrinterOptions{
+		ServerOptions: ServerOptions{
+			Hooks: ServerHooks{
+				OnIPPRequest: func(_ *transport.ServerQuery,
+					msg *goipp.Message) *goipp.Message {
+					lock.Lock()
+					received = append(received, msg)
+					lock.Unlock()
+					return nil
+				},
+			},
+		},
+	}
+
+	attrs := &PrinterAttributes{}
+	srv := httptest.NewServer(NewPrinter(attrs, options))
+	defer srv.Close()
+
+	ctx := context.Background()
+	clnt := NewClient(transport.MustParseURL(srv.URL), nil)
+	clnt.SetStrictness(StrictnessReject)
+
+	// Invalid keyword: rejected before anything is sent
+	rq := &GetPrinterAttributesRequest{
+		RequestHeader:       DefaultRequestHeader,
+		PrinterURI:          srv.URL,
+		RequestedAttributes: []string{"printer name"},
+	}
+
+	err := clnt.Do(ctx, rq, &GetPrinterAttributesResponse{})
+	var lerr *LimitError
+	if !errors.As(err, &lerr) || lerr.Attr != "requested-attributes" {
+		t.Errorf("LimitError for requested-attributes expected, "+
+			"present: %v", err)
+	}
+
+	if len(received) != 0 {
+		t.Errorf("invalid request was sent")
+	}
+
+	// Oversized name: rejected in strict mode, truncated
+	// in the truncate mode
+	vrq := &ValidateJobRequest{RequestHeader: DefaultRequestHeader}
+	vrq.PrinterURI = srv.URL
+	vrq.JobName = optional.New(strings.Repeat("j", 300))
+
+	err = clnt.Do(ctx, vrq, &ValidateJobResponse{})
+	if !errors.As(err, &lerr) || lerr.Attr != "job-name" {
+		t.Errorf("LimitError for job-name expected, present: %v", err)
+	}
+
+	clnt.SetStrictness(StrictnessTruncate)
+	err = clnt.Do(ctx, vrq, &ValidateJobResponse{})
+	if err != nil {
+		t.Fatalf("ValidateJob: %s", err)
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("%d requests received, expected 1", len(received))
+	}
+
+	for _, attr := range received[0].Operation {
+		if attr.Name == "job-name" &&
+			len(attr.Values[0].V.(goipp.String)) != MaxNameLength {
+			t.Errorf("job-name not truncated: %d octets",
+				len(attr.Values[0].V.(goipp.String)))
+		}
+	}
+}
+
+// TestDecodeLimits tests that inbound violations of RFC 8011
+// limits are recorded, but don't fail decoding
+func TestDecodeLimits(t *testing.T) {
+	attrs := goipp.Attributes{
+		goipp.MakeAttribute("printer-name",
+			goipp.TagName, goipp.String(strings.Repeat("p", 300))),
+		goipp.MakeAttribute("sides-supported",
+			goipp.TagKeyword, goipp.String("one-sided")),
+	}
+	attrs[1].Values.Add(goipp.TagKeyword, goipp.String("Two Sided"))
+
+	pa := &PrinterAttributes{}
+	dec := NewDecoder(&DecoderOptions{KeepTrying: true})
+	err := dec.Decode(pa, attrs)
+	if err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	var recorded []string
+	for _, err := range dec.Errors() {
+		recorded = append(recorded, err.Error())
+	}
+
+	expected := []string{"length(300)", `"Two Sided" contains invalid`}
+	for _, exp := range expected {
+		found := false
+		for _, s := range recorded {
+			found = found || strings.Contains(s, exp)
+		}
+
+		if !found {
+			t.Errorf("%q: not recorded, present: %q", exp, recorded)
+		}
+	}
+
+	if len(pa.SidesSupported) != 2 {
+		t.Errorf("sides-supported: %v", pa.SidesSupported)
+	}
+}
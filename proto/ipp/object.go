@@ -70,6 +70,10 @@ func ObjectSetAttr(obj Object, attr goipp.Attribute) error {
 
 	// Update raw attributes
 	rawattrs := obj.RawAttrs()
+	if rawattrs.byName == nil {
+		rawattrs.byName = make(map[string]int)
+	}
+
 	i, found := rawattrs.byName[attr.Name]
 	if !found {
 		i = len(rawattrs.attrs)
@@ -86,9 +90,10 @@ func ObjectSetAttr(obj Object, attr goipp.Attribute) error {
 // It gives access to raw IPP attributes and implements [Object]
 // interface.
 type ObjectRawAttrs struct {
-	attrs  goipp.Attributes // Raw attributes
-	byName map[string]int   // Attribute indices by name
-	errors []error          // Possible decode errors
+	attrs         goipp.Attributes // Raw attributes
+	byName        map[string]int   // Attribute indices by name
+	errors        []error          // Possible decode errors
+	noPassthrough bool             // Don't encode raw attributes
 }
 
 // RawAttrs returns [ObjecRawtAttrs], which gives uniform
@@ -112,6 +117,19 @@ func (rawattrs *ObjectRawAttrs) Errors() []error {
 	return rawattrs.errors
 }
 
+// SetRawPassthrough enables or disables raw attributes passthrough
+// on encoding.
+//
+// When enabled (the default), raw attributes, not known to the
+// [Object] structure (for example, vendor extensions, preserved by
+// decoder), are encoded after the typed attributes. This makes
+// the decode-modify-encode cycle lossless.
+//
+// When disabled, only the typed attributes are encoded.
+func (rawattrs *ObjectRawAttrs) SetRawPassthrough(enable bool) {
+	rawattrs.noPassthrough = !enable
+}
+
 // save saves all raw IPP attributes and decode errors.
 func (rawattrs *ObjectRawAttrs) save(attrs goipp.Attributes, errors []error) {
 	rawattrs.attrs = make(goipp.Attributes, 0, len(attrs))
@@ -0,0 +1,148 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Tests for raw attributes passthrough
+
+package ipp
+
+import (
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// testRawAttrsFind returns the attribute by name and count of
+// its occurrences.
+func testRawAttrsFind(attrs goipp.Attributes, name string) (
+	attr goipp.Attribute, count int) {
+
+	for _, a := range attrs {
+		if a.Name == name {
+			attr = a
+			count++
+		}
+	}
+
+	return
+}
+
+// TestRawPassthroughRequest tests that vendor operation attributes
+// of the decoded request survive the decode-modify-encode cycle.
+func TestRawPassthroughRequest(t *testing.T) {
+	vendor := goipp.MakeAttribute("com-example-vendor-op",
+		goipp.TagKeyword, goipp.String("magic"))
+
+	msg := goipp.NewRequest(goipp.DefaultVersion,
+		goipp.OpGetPrinterAttributes, 1)
+	msg.Operation.Add(goipp.MakeAttribute("attributes-charset",
+		goipp.TagCharset, goipp.String("utf-8")))
+	msg.Operation.Add(goipp.MakeAttribute("attributes-natural-language",
+		goipp.TagLanguage, goipp.String("en-us")))
+	msg.Operation.Add(goipp.MakeAttribute("printer-uri",
+		goipp.TagURI, goipp.String("ipp://old/ipp/print")))
+	msg.Operation.Add(goipp.MakeAttribute("document-format",
+		goipp.TagMimeType, goipp.String("application/pdf")))
+	msg.Operation.Add(vendor)
+
+	rq := &GetPrinterAttributesRequest{}
+	err := rq.Decode(msg, nil)
+	if err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	// Modify typed fields and re-encode
+	rq.PrinterURI = "ipp://new/ipp/print"
+	rq.DocumentFormat = nil
+
+	out := rq.Encode()
+
+	attr, count := testRawAttrsFind(out.Operation, vendor.Name)
+	switch {
+	case count != 1:
+		t.Errorf("%s: expected once, present %d times",
+			vendor.Name, count)
+	case !attr.Equal(vendor):
+		t.Errorf("%s: expected %s, present %s",
+			vendor.Name, vendor.Values, attr.Values)
+	}
+
+	// Vendor attributes go after typed ones
+	if out.Operation[len(out.Operation)-1].Name != vendor.Name {
+		t.Errorf("%s: must be the last attribute", vendor.Name)
+	}
+
+	// Typed fields take precedence over raw attributes
+	attr, count = testRawAttrsFind(out.Operation, "printer-uri")
+	if count != 1 || attr.Values[0].V.String() != rq.PrinterURI {
+		t.Errorf("printer-uri: expected %q once, present %s (%d times)",
+			rq.PrinterURI, attr.Values, count)
+	}
+
+	// Cleared typed field must not be restored from raw attributes
+	if _, count = testRawAttrsFind(out.Operation,
+		"document-format"); count != 0 {
+		t.Errorf("document-format: cleared but encoded")
+	}
+
+	// Test escape hatch
+	rq.SetRawPassthrough(false)
+	out = rq.Encode()
+
+	if _, count = testRawAttrsFind(out.Operation, vendor.Name); count != 0 {
+		t.Errorf("%s: encoded with passthrough disabled", vendor.Name)
+	}
+}
+
+// TestRawPassthroughResponse tests that unknown printer attributes
+// of the decoded response survive the decode-modify-encode cycle.
+func TestRawPassthroughResponse(t *testing.T) {
+	vendor := goipp.MakeAttribute("com-example-unknown",
+		goipp.TagInteger, goipp.Integer(42))
+
+	msg := goipp.NewResponse(goipp.DefaultVersion, goipp.StatusOk, 1)
+	msg.Operation.Add(goipp.MakeAttribute("attributes-charset",
+		goipp.TagCharset, goipp.String("utf-8")))
+	msg.Operation.Add(goipp.MakeAttribute("attributes-natural-language",
+		goipp.TagLanguage, goipp.String("en-us")))
+	msg.Printer.Add(goipp.MakeAttribute("printer-name",
+		goipp.TagName, goipp.String("old")))
+	msg.Printer.Add(vendor)
+
+	rsp := &GetPrinterAttributesResponse{}
+	err := rsp.Decode(msg, nil)
+	if err != nil {
+		t.Fatalf("Decode: %s", err)
+	}
+
+	// Modify typed fields and re-encode
+	rsp.Printer.PrinterName = optional.New("new")
+	out := rsp.Encode()
+
+	attr, count := testRawAttrsFind(out.Printer, vendor.Name)
+	switch {
+	case count != 1:
+		t.Errorf("%s: expected once, present %d times",
+			vendor.Name, count)
+	case !attr.Equal(vendor):
+		t.Errorf("%s: expected %s, present %s",
+			vendor.Name, vendor.Values, attr.Values)
+	}
+
+	attr, count = testRawAttrsFind(out.Printer, "printer-name")
+	if count != 1 || attr.Values[0].V.String() != "new" {
+		t.Errorf("printer-name: expected \"new\" once, "+
+			"present %s (%d times)", attr.Values, count)
+	}
+
+	// Test escape hatch
+	rsp.Printer.SetRawPassthrough(false)
+	out = rsp.Encode()
+
+	if _, count = testRawAttrsFind(out.Printer, vendor.Name); count != 0 {
+		t.Errorf("%s: encoded with passthrough disabled", vendor.Name)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/go-mfp/util/uuid"
 	"github.com/OpenPrinting/goipp"
 )
 
@@ -49,6 +50,7 @@ type PrinterAttributes struct {
 
 	PrinterDescriptionGroup
 	PrinterStatusGroup
+	CUPSPrinterClassAttributesGroup
 
 	PrinterDescription
 	ScannerDescription
@@ -78,6 +80,7 @@ type PrinterDescription struct {
 	PagesPerMinuteColor               optional.Val[int]           `ipp:"pages-per-minute-color"`
 	PagesPerMinute                    optional.Val[int]           `ipp:"pages-per-minute"`
 	PdlOverrideSupported              optional.Val[KwPdlOverride] `ipp:"pdl-override-supported"`
+	PrinterCurrentTime                optional.Val[time.Time]     `ipp:"printer-current-time"`
 	PrinterDriverInstaller            optional.Val[string]        `ipp:"printer-driver-installer"`
 	PrinterDeviceID                   optional.Val[string]        `ipp:"printer-device-id"`
 	PrinterInfo                       optional.Val[string]        `ipp:"printer-info"`
@@ -167,7 +170,7 @@ type PrinterDescription struct {
 	// PWG5100.13: IPP Driver Replacement Extensions v2.0 (NODRIVER)
 	// 6.6 Printer Status Attributes
 	DeviceServiceCount           optional.Val[int]       `ipp:"device-service-count"`
-	DeviceUUID                   optional.Val[string]    `ipp:"device-uuid"`
+	DeviceUUID                   optional.Val[uuid.UUID] `ipp:"device-uuid"`
 	PrinterConfigChangeDateTime  optional.Val[time.Time] `ipp:"printer-config-change-date-time"`
 	PrinterConfigChangeTime      optional.Val[int]       `ipp:"printer-config-change-time"`
 	PrinterFirmwareName          []string                `ipp:"printer-firmware-name"`
@@ -179,7 +182,12 @@ type PrinterDescription struct {
 	PrinterSupplyDescription     []goipp.TextWithLang    `ipp:"printer-supply-description"`
 	PrinterSupplyInfoURI         optional.Val[string]    `ipp:"printer-supply-info-uri"`
 	PrinterSupply                []string                `ipp:"printer-supply"`
-	PrinterUUID                  optional.Val[string]    `ipp:"printer-uuid"`
+	PrinterUUID                  optional.Val[uuid.UUID] `ipp:"printer-uuid"`
+
+	// PWG5100.22: IPP System Service v1.0 (SYSTEM)
+	// Printer Status Attributes
+	PrinterImpressionsCompleted optional.Val[int] `ipp:"printer-impressions-completed"`
+	PrinterPagesCompleted       optional.Val[int] `ipp:"printer-pages-completed"`
 
 	// Wi-Fi Peer-to-Peer Services Print (P2Ps-Print)
 	// Technical Specification
@@ -189,20 +197,27 @@ type PrinterDescription struct {
 	PclmStripHeightSupported []int                `ipp:"pclm-strip-height-supported"`
 
 	// CUPS extensions
-	DeviceURI          string                      `ipp:"device-uri"`
-	MarkerChangeTime   optional.Val[int]           `ipp:"marker-change-time"`
-	MarkerColors       []string                    `ipp:"marker-colors"`
-	MarkerHighLevels   []int                       `ipp:"marker-high-levels"`
-	MarkerLevels       []int                       `ipp:"marker-levels"`
-	MarkerLowLevels    []int                       `ipp:"marker-low-levels"`
-	MarkerMessage      optional.Val[string]        `ipp:"marker-message"`
-	MarkerNames        []string                    `ipp:"marker-names"`
-	MarkerTypes        []string                    `ipp:"marker-types"`
-	PrinterID          optional.Val[int]           `ipp:"printer-id"`
-	PrinterIsShared    optional.Val[bool]          `ipp:"printer-is-shared"`
-	PrinterIsTemporary optional.Val[bool]          `ipp:"printer-is-temporary"`
-	PrinterType        optional.Val[EnPrinterType] `ipp:"printer-type"`
-	UrfSupported       []string                    `ipp:"urf-supported"`
+	DeviceURI          string                             `ipp:"device-uri"`
+	JobKLimit          optional.Val[int]                  `ipp:"job-k-limit"`
+	JobPageLimit       optional.Val[int]                  `ipp:"job-page-limit"`
+	JobQuotaPeriod     optional.Val[int]                  `ipp:"job-quota-period"`
+	MarkerChangeTime   optional.Val[int]                  `ipp:"marker-change-time"`
+	MarkerColors       []string                           `ipp:"marker-colors"`
+	MarkerHighLevels   []int                              `ipp:"marker-high-levels"`
+	MarkerLevels       []int                              `ipp:"marker-levels"`
+	MarkerLowLevels    []int                              `ipp:"marker-low-levels"`
+	MarkerMessage      optional.Val[string]               `ipp:"marker-message"`
+	MarkerNames        []string                           `ipp:"marker-names"`
+	MarkerTypes        []string                           `ipp:"marker-types"`
+	MemberNames        []string                           `ipp:"member-names"`
+	MemberURIs         []string                           `ipp:"member-uris"`
+	PrinterErrorPolicy optional.Val[KwPrinterErrorPolicy] `ipp:"printer-error-policy"`
+	PrinterID          optional.Val[int]                  `ipp:"printer-id"`
+	PrinterIsShared    optional.Val[bool]                 `ipp:"printer-is-shared"`
+	PrinterIsTemporary optional.Val[bool]                 `ipp:"printer-is-temporary"`
+	PrinterOpPolicy    optional.Val[KwPrinterOpPolicy]    `ipp:"printer-op-policy"`
+	PrinterType        optional.Val[EnPrinterType]        `ipp:"printer-type"`
+	UrfSupported       []string                           `ipp:"urf-supported"`
 }
 
 // PrinterJobSaveDisposition represents "job-save-disposition-default"
@@ -255,3 +270,37 @@ func (pa *PrinterAttributes) IsOperationSupported(op goipp.Op) bool {
 	}
 	return false
 }
+
+// ClockSkew returns the difference between the printer clock, as
+// reported by the printer-current-time attribute, and now. Positive
+// value means that the printer clock is ahead.
+//
+// The now parameter should be the time when the attributes were
+// received. It returns false, if printer-current-time is missed.
+func (pa *PrinterAttributes) ClockSkew(now time.Time) (time.Duration, bool) {
+	if pa.PrinterCurrentTime == nil {
+		return 0, false
+	}
+	return (*pa.PrinterCurrentTime).Sub(now), true
+}
+
+// PrinterUUIDString returns printer-uuid as string, in the
+// urn:uuid: form, or "" if attribute is missed.
+func (pa *PrinterAttributes) PrinterUUIDString() string {
+	return uuidString(pa.PrinterUUID)
+}
+
+// DeviceUUIDString returns device-uuid as string, in the
+// urn:uuid: form, or "" if attribute is missed.
+func (pa *PrinterAttributes) DeviceUUIDString() string {
+	return uuidString(pa.DeviceUUID)
+}
+
+// uuidString returns optional UUID as string in the urn:uuid:
+// form, or "" if UUID is missed.
+func uuidString(u optional.Val[uuid.UUID]) string {
+	if u == nil {
+		return ""
+	}
+	return (*u).URN()
+}
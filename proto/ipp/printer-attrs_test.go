@@ -10,8 +10,10 @@ package ipp
 
 import (
 	"testing"
+	"time"
 
 	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/util/optional"
 	"github.com/OpenPrinting/goipp"
 )
 
@@ -60,3 +62,33 @@ func TestKyoceraM2040dnPrinterAttributes(t *testing.T) {
 	_ = diff
 	//println(diff)
 }
+
+// TestPrinterAttributesClockSkew tests PrinterAttributes.ClockSkew
+func TestPrinterAttributesClockSkew(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	pa := &PrinterAttributes{}
+
+	// printer-current-time is missed
+	skew, ok := pa.ClockSkew(now)
+	if ok || skew != 0 {
+		t.Errorf("missed printer-current-time: %s %v", skew, ok)
+	}
+
+	// Printer clock is ahead, in the different time zone
+	zone := time.FixedZone("", 2*3600)
+	pa.PrinterCurrentTime = optional.New(
+		time.Date(2025, time.June, 1, 14, 7, 30, 0, zone))
+
+	skew, ok = pa.ClockSkew(now)
+	if !ok || skew != 7*time.Minute+30*time.Second {
+		t.Errorf("ClockSkew: expected 7m30s, present %s %v", skew, ok)
+	}
+
+	// Printer clock is behind
+	pa.PrinterCurrentTime = optional.New(now.Add(-time.Hour))
+
+	skew, ok = pa.ClockSkew(now)
+	if !ok || skew != -time.Hour {
+		t.Errorf("ClockSkew: expected -1h, present %s %v", skew, ok)
+	}
+}
@@ -0,0 +1,151 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer attributes comparison
+
+package ipp
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/util/generic"
+	"github.com/OpenPrinting/goipp"
+)
+
+// AttributeDiff describes difference of the single attribute
+// between two sets of printer attributes, A and B.
+type AttributeDiff struct {
+	Name  string       // Attribute name
+	A, B  goipp.Values // Attribute values, nil if attribute missed
+	OnlyA goipp.Values // Values, present at the A side only
+	OnlyB goipp.Values // Values, present at the B side only
+}
+
+// MissedA reports whether attribute is missed at the A side.
+func (diff AttributeDiff) MissedA() bool {
+	return diff.A == nil
+}
+
+// MissedB reports whether attribute is missed at the B side.
+func (diff AttributeDiff) MissedB() bool {
+	return diff.B == nil
+}
+
+// DiffPrinterAttributes compares two sets of [PrinterAttributes]
+// and returns differences, sorted by attribute name.
+//
+// Attributes values are compared as sets: order of values doesn't
+// matter, and collections are compared regardless of order of their
+// members. Attributes with the equal sets of values are not reported.
+//
+// If filter is not nil, only attributes it accepts are compared.
+// See [PrinterCapabilityAttribute] for the filter, that accepts
+// only capability-relevant attributes.
+func DiffPrinterAttributes(a, b *PrinterAttributes,
+	filter func(name string) bool) []AttributeDiff {
+
+	attrsA := diffAttrsByName(a, filter)
+	attrsB := diffAttrsByName(b, filter)
+
+	names := generic.NewSet[string]()
+	var diffs []AttributeDiff
+
+	for _, attrs := range []map[string]goipp.Values{attrsA, attrsB} {
+		for name := range attrs {
+			if !names.TestAndAdd(name) {
+				continue
+			}
+
+			diff := AttributeDiff{
+				Name:  name,
+				A:     attrsA[name],
+				B:     attrsB[name],
+				OnlyA: diffValuesSub(attrsA[name], attrsB[name]),
+				OnlyB: diffValuesSub(attrsB[name], attrsA[name]),
+			}
+
+			if diff.MissedA() || diff.MissedB() ||
+				len(diff.OnlyA) != 0 || len(diff.OnlyB) != 0 {
+				diffs = append(diffs, diff)
+			}
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Name < diffs[j].Name
+	})
+
+	return diffs
+}
+
+// PrinterCapabilityAttribute reports whether the printer attribute
+// describes the printer capabilities, and hence relevant for
+// comparison of two printers with the [DiffPrinterAttributes].
+//
+// These are the xxx-supported attributes, except ones that identify
+// the particular printer (like printer-uri-supported), and the media
+// database and readiness attributes.
+func PrinterCapabilityAttribute(name string) bool {
+	switch name {
+	case "printer-uri-supported",
+		"uri-authentication-supported",
+		"uri-security-supported",
+		"printer-xri-supported":
+		return false
+
+	case "media-col-database",
+		"media-col-ready",
+		"media-ready":
+		return true
+	}
+
+	return strings.HasSuffix(name, "-supported")
+}
+
+// diffAttrsByName returns printer attributes, indexed by name,
+// accepted by the filter.
+func diffAttrsByName(prn *PrinterAttributes,
+	filter func(name string) bool) map[string]goipp.Values {
+
+	attrs := make(map[string]goipp.Values)
+	if prn == nil {
+		return attrs
+	}
+
+	for _, attr := range prn.RawAttrs().All() {
+		if filter == nil || filter(attr.Name) {
+			vals := attr.Values
+			if vals == nil {
+				vals = goipp.Values{}
+			}
+			attrs[attr.Name] = vals
+		}
+	}
+
+	return attrs
+}
+
+// diffValuesSub returns values from vals, that are missed in sub.
+func diffValuesSub(vals, sub goipp.Values) goipp.Values {
+	var diff goipp.Values
+
+	for _, v := range vals {
+		found := false
+		for _, v2 := range sub {
+			if v.T == v2.T && goipp.ValueSimilar(v.V, v2.V) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			diff.Add(v.T, v.V)
+		}
+	}
+
+	return diff
+}
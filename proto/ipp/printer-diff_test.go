@@ -0,0 +1,123 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer attributes comparison test
+
+package ipp
+
+import (
+	"testing"
+
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestDiffPrinterAttributes tests DiffPrinterAttributes
+func TestDiffPrinterAttributes(t *testing.T) {
+	kw := func(name string, vals ...string) goipp.Attribute {
+		attr := goipp.Attribute{Name: name}
+		for _, v := range vals {
+			attr.Values.Add(goipp.TagKeyword, goipp.String(v))
+		}
+		return attr
+	}
+
+	var attrsA, attrsB goipp.Attributes
+
+	attrsA.Add(goipp.MakeAttribute("printer-uri-supported",
+		goipp.TagURI, goipp.String("ipp://a/ipp/print")))
+	attrsB.Add(goipp.MakeAttribute("printer-uri-supported",
+		goipp.TagURI, goipp.String("ipp://b/ipp/print")))
+
+	// Same values, different order
+	attrsA.Add(kw("sides-supported", "one-sided", "two-sided-long-edge"))
+	attrsB.Add(kw("sides-supported", "two-sided-long-edge", "one-sided"))
+
+	// Different values
+	attrsA.Add(kw("media-supported", "iso_a4_210x297mm", "iso_a3_297x420mm"))
+	attrsB.Add(kw("media-supported", "iso_a4_210x297mm", "na_legal_8.5x14in"))
+
+	// Present at one side only
+	attrsA.Add(kw("print-color-mode-supported", "monochrome", "color"))
+	attrsB.Add(kw("output-bin-supported", "face-down"))
+
+	// Non-capability
+	attrsA.Add(kw("sides-default", "one-sided"))
+	attrsB.Add(kw("sides-default", "two-sided-long-edge"))
+
+	prnA, err := DecodePrinterAttributes(attrsA, nil)
+	if err != nil {
+		t.Fatalf("DecodePrinterAttributes: %s", err)
+	}
+
+	prnB, err := DecodePrinterAttributes(attrsB, nil)
+	if err != nil {
+		t.Fatalf("DecodePrinterAttributes: %s", err)
+	}
+
+	// Test with capability filter
+	diffs := DiffPrinterAttributes(prnA, prnB, PrinterCapabilityAttribute)
+
+	type result struct {
+		name         string
+		onlyA, onlyB string
+		missA, missB bool
+	}
+
+	expected := []result{
+		{
+			name:  "media-supported",
+			onlyA: "iso_a3_297x420mm",
+			onlyB: "na_legal_8.5x14in",
+		},
+		{
+			name:  "output-bin-supported",
+			onlyB: "face-down",
+			missA: true,
+		},
+		{
+			name:  "print-color-mode-supported",
+			onlyA: "[monochrome,color]",
+			missB: true,
+		},
+	}
+
+	var present []result
+	for _, diff := range diffs {
+		res := result{
+			name:  diff.Name,
+			missA: diff.MissedA(),
+			missB: diff.MissedB(),
+		}
+
+		if diff.OnlyA != nil {
+			res.onlyA = diff.OnlyA.String()
+		}
+		if diff.OnlyB != nil {
+			res.onlyB = diff.OnlyB.String()
+		}
+
+		present = append(present, res)
+	}
+
+	if len(present) != len(expected) {
+		t.Fatalf("DiffPrinterAttributes:\nexpected: %+v\npresent:  %+v",
+			expected, present)
+	}
+
+	for i := range expected {
+		if present[i] != expected[i] {
+			t.Errorf("DiffPrinterAttributes:\nexpected: %+v\npresent:  %+v",
+				expected[i], present[i])
+		}
+	}
+
+	// Test without filter
+	diffs = DiffPrinterAttributes(prnA, prnB, nil)
+	if len(diffs) != 5 {
+		t.Errorf("DiffPrinterAttributes: unfiltered: "+
+			"expected 5 differences, present %d", len(diffs))
+	}
+}
@@ -15,13 +15,14 @@ import (
 
 	"github.com/OpenPrinting/go-mfp/abstract"
 	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/util/optional"
 	"github.com/OpenPrinting/goipp"
 )
 
 // Printer implements the IPP printer.
 type Printer struct {
 	options PrinterOptions     // Printer options
-	server  *Server            // Underlying IPP server
+	mux     *ServeMux          // Underlying IPP request router
 	attrs   *PrinterAttributes // Printer attributes
 	q       *queue             // Job queue
 	backend abstract.Printer   // Print backend
@@ -47,22 +48,24 @@ type PrinterOptions struct {
 // behavior is defined by the supplied [PrinterAttributes].
 func NewPrinter(attrs *PrinterAttributes, options PrinterOptions) *Printer {
 	// Create the Printer structure
-	server := NewServer(options.ServerOptions)
+	mux := NewServeMux(options.ServerOptions)
 	printer := &Printer{
 		options: options,
-		server:  server,
+		mux:     mux,
 		attrs:   attrs,
 		q:       newQueue(),
 	}
 
 	// Install request handlers
-	server.RegisterHandler(NewHandler(printer.handleGetPrinterAttributes))
-	server.RegisterHandler(NewHandler(printer.handleGetJobs))
-	server.RegisterHandler(NewHandler(printer.handleGetJobAttributes))
-	server.RegisterHandler(NewHandler(printer.handleValidateJob))
-	server.RegisterHandler(NewHandler(printer.handleCreateJob))
-	server.RegisterHandler(NewHandler(printer.handleSendDocument))
-	server.RegisterHandler(NewHandler(printer.handleCancelJob))
+	mux.RegisterHandler(NewHandler(printer.handleGetPrinterAttributes))
+	mux.RegisterHandler(NewHandler(printer.handleGetPrinterSupportedValues))
+	mux.RegisterHandler(NewHandler(printer.handleGetJobs))
+	mux.RegisterHandler(NewHandler(printer.handleGetJobAttributes))
+	mux.RegisterHandler(NewHandler(printer.handleValidateJob))
+	mux.RegisterHandler(NewHandler(printer.handlePrintJob))
+	mux.RegisterHandler(NewHandler(printer.handleCreateJob))
+	mux.RegisterHandler(NewHandler(printer.handleSendDocument))
+	mux.RegisterHandler(NewHandler(printer.handleCancelJob))
 
 	return printer
 }
@@ -76,7 +79,7 @@ func (printer *Printer) SetPrintBackend(backend abstract.Printer) {
 // ServeHTTP handles incoming HTTP request. It implements
 // [http.Handler] interface.
 func (printer *Printer) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
-	printer.server.ServeHTTP(w, rq)
+	printer.mux.ServeHTTP(w, rq)
 }
 
 // handleGetPrinterAttributes handles Get-Printer-Attributes request.
@@ -87,6 +90,15 @@ func (printer *Printer) handleGetPrinterAttributes(
 	return rq.Apply(printer.attrs, printer.options.UseRawPrinterAttributes), nil, nil
 }
 
+// handleGetPrinterSupportedValues handles Get-Printer-Supported-Values
+// request.
+func (printer *Printer) handleGetPrinterSupportedValues(
+	ctx context.Context,
+	rq *GetPrinterSupportedValuesRequest) (*goipp.Message, io.ReadCloser, error) {
+
+	return rq.Apply(printer.attrs, printer.options.UseRawPrinterAttributes), nil, nil
+}
+
 // handleGetJobs handles Get-Jobs request.
 func (printer *Printer) handleGetJobs(
 	ctx context.Context,
@@ -112,8 +124,129 @@ func (printer *Printer) handleValidateJob(
 	ctx context.Context,
 	rq *ValidateJobRequest) (*goipp.Message, io.ReadCloser, error) {
 
+	var jobAttrs goipp.Attributes
+	if rq.JobTemplate != nil {
+		jobAttrs = rq.JobTemplate.RawAttrs().All()
+	}
+
+	unsupported := printer.validateJobAttrs(jobAttrs)
+
+	// Document format is the Operation attribute, but is
+	// validated as well
+	if format := rq.DocumentFormat; format != nil {
+		attr := goipp.MakeAttribute("document-format",
+			goipp.TagMimeType, goipp.String(*format))
+		unsupported = append(unsupported,
+			printer.validateJobAttrs(goipp.Attributes{attr})...)
+	}
+
+	// Like ippeveprinter, reject the job with unsupported
+	// attributes, regardless of the "ipp-attribute-fidelity".
+	status := goipp.StatusOk
+	if len(unsupported) != 0 {
+		status = goipp.StatusErrorAttributesOrValues
+	}
+
 	rsp := ValidateJobResponse{
+		ResponseHeader:        rq.ResponseHeader(status),
+		UnsupportedAttributes: unsupported,
+	}
+
+	return rsp.Encode(), nil, nil
+}
+
+// validateJobAttrs validates Job attributes against the
+// corresponding "xxx-supported" Printer attributes.
+//
+// It returns attributes, not supported by the Printer:
+//   - attributes without the "xxx-supported" counterpart are
+//     returned with the out-of-band "unsupported" value
+//   - for other attributes, unsupported values are returned
+func (printer *Printer) validateJobAttrs(
+	attrs goipp.Attributes) goipp.Attributes {
+
+	var supported map[string]SupportedSpec
+	if printer.options.UseRawPrinterAttributes {
+		supported = supportedSpecs(printer.attrs.RawAttrs().All())
+	} else {
+		supported = printer.attrs.SupportedValues()
+	}
+
+	var unsupported goipp.Attributes
+	for _, attr := range attrs {
+		spec, found := supported[attr.Name]
+		if !found {
+			unsupported.Add(goipp.MakeAttribute(attr.Name,
+				goipp.TagUnsupportedValue, goipp.Void{}))
+			continue
+		}
+
+		var values goipp.Values
+		for _, v := range attr.Values {
+			if ok, _ := spec.Supports(v.V); !ok {
+				values.Add(v.T, v.V)
+			}
+		}
+
+		if len(values) != 0 {
+			unsupported.Add(goipp.Attribute{
+				Name: attr.Name, Values: values})
+		}
+	}
+
+	return unsupported
+}
+
+// handlePrintJob handles Print-Job request.
+func (printer *Printer) handlePrintJob(
+	ctx context.Context,
+	rq *PrintJobRequest) (*goipp.Message, io.ReadCloser, error) {
+
+	// Create new job. It becomes visible (and cancelable) before
+	// the document is consumed.
+	j := newJob(&rq.JobCreateOperation, rq.JobTemplate)
+	j.Lock()
+	j.SendDocumentActive = true
+	printer.q.Push(j)
+	j.Unlock()
+
+	// Consume the document body
+	jobName := rq.DocumentName
+	if jobName == nil {
+		jobName = rq.JobName
+	}
+
+	params := printerRequest(rq.DocumentFormat, jobName, rq.JobTemplate)
+	printer.printDocument(ctx, "Print-Job", params, rq.Body)
+
+	j.Lock()
+	defer j.Unlock()
+
+	j.SendDocumentActive = false
+	j.documents++
+	j.finishCancel()
+
+	// Print-Job carries the only document of the job
+	if j.JobState == EnJobStatePendingHeld {
+		j.JobState = EnJobStateCompleted
+		j.JobStateReasons = []KwJobStateReasons{
+			KwJobStateReasonsJobCompletedSuccessfully,
+		}
+	}
+
+	// Generate response
+	rsp := &PrintJobResponse{
 		ResponseHeader: rq.ResponseHeader(goipp.StatusOk),
+		Job: &JobDescriptionAndStatus{
+			JobDescriptionAttrs: JobDescriptionAttrs{
+				JobID:  j.JobID,
+				JobURI: j.JobURI,
+			},
+			JobStatusAttrs: JobStatusAttrs{
+				JobState:        j.JobState,
+				JobStateReasons: j.JobStateReasons,
+			},
+		},
 	}
 
 	return rsp.Encode(), nil, nil
@@ -201,56 +334,42 @@ func (printer *Printer) handleSendDocument(
 		return nil, nil, err
 	}
 
+	if j.documents > 0 &&
+		!optional.Get(printer.attrs.MultipleDocumentJobsSupported) {
+		err := NewErrIPPFromRequest(rq,
+			goipp.StatusErrorMultipleJobsNotSupported,
+			"multiple-document jobs not supported")
+		return nil, nil, err
+	}
+
 	// Consume the document body
 	j.SendDocumentActive = true
 	j.Unlock()
 
-	if printer.backend != nil {
-		// Build protocol-independent job parameters
-		params := abstract.PrinterRequest{}
-
-		if rq.DocumentFormat != nil {
-			params.Format = *rq.DocumentFormat
-		}
-		if rq.DocumentName != nil {
-			params.JobName = *rq.DocumentName
-		} else if j.JobDescriptionAttrs.JobName != nil {
-			params.JobName = *j.JobDescriptionAttrs.JobName
-		}
-		if rq.JobTemplate != nil {
-			if rq.JobTemplate.Copies != nil {
-				params.Copies = *rq.JobTemplate.Copies
-			}
-			if rq.JobTemplate.Sides != nil {
-				params.Sides = sidesToAbstract(*rq.JobTemplate.Sides)
-			}
-			if rq.JobTemplate.PrintColorMode != nil {
-				params.ColorMode = colorModeToAbstract(*rq.JobTemplate.PrintColorMode)
-			}
-			if rq.JobTemplate.Media != nil {
-				params.Media = mediaSizeToAbstract(*rq.JobTemplate.Media)
-			}
-		}
-
-		if err := printer.backend.PrintDocument(params, rq.Body); err != nil {
-			log.Error(ctx, "Send-Document: backend error: %s", err)
-		}
-	} else {
-		// No backend — drain the body so the connection stays clean
-		n, err := io.Copy(io.Discard, rq.Body)
-		if err != nil {
-			log.Error(ctx, "Send-Document: %s", err)
-		} else {
-			log.Debug(ctx, "Send-Document: %d bytes discarded (no backend)", n)
-		}
+	jobName := rq.DocumentName
+	if jobName == nil {
+		jobName = j.JobDescriptionAttrs.JobName
 	}
 
+	params := printerRequest(rq.DocumentFormat, jobName, rq.JobTemplate)
+	printer.printDocument(ctx, "Send-Document", params, rq.Body)
+
 	j.Lock()
 	j.SendDocumentActive = false
+	j.documents++
 	j.finishCancel()
 
+	// Job is completed after the last document
+	if rq.LastDocument && j.JobState == EnJobStatePendingHeld {
+		j.JobState = EnJobStateCompleted
+		j.JobStateReasons = []KwJobStateReasons{
+			KwJobStateReasonsJobCompletedSuccessfully,
+		}
+	}
+
 	// Generate response
 	rsp := &SendDocumentResponse{
+		ResponseHeader: rq.ResponseHeader(goipp.StatusOk),
 		Job: &JobDescriptionAndStatus{
 			JobDescriptionAttrs: JobDescriptionAttrs{
 				JobID:  j.JobID,
@@ -266,6 +385,62 @@ func (printer *Printer) handleSendDocument(
 	return rsp.Encode(), nil, nil
 }
 
+// printDocument passes the document body to the print backend.
+// If backend is not set, the body is discarded.
+//
+// op is the name of the IPP operation, used for logging.
+func (printer *Printer) printDocument(ctx context.Context, op string,
+	params abstract.PrinterRequest, body io.Reader) {
+
+	if printer.backend != nil {
+		err := printer.backend.PrintDocument(params, body)
+		if err != nil {
+			log.Error(ctx, "%s: backend error: %s", op, err)
+		}
+		return
+	}
+
+	// No backend — drain the body so the connection stays clean
+	n, err := io.Copy(io.Discard, body)
+	if err != nil {
+		log.Error(ctx, "%s: %s", op, err)
+	} else {
+		log.Debug(ctx, "%s: %d bytes discarded (no backend)", op, n)
+	}
+}
+
+// printerRequest builds the protocol-independent print job
+// parameters from the document format, job name and Job Template
+// attributes. Any of them may be missed.
+func printerRequest(format, jobName optional.Val[string],
+	tmpl *JobTemplate) abstract.PrinterRequest {
+
+	params := abstract.PrinterRequest{}
+
+	if format != nil {
+		params.Format = *format
+	}
+	if jobName != nil {
+		params.JobName = *jobName
+	}
+	if tmpl != nil {
+		if tmpl.Copies != nil {
+			params.Copies = *tmpl.Copies
+		}
+		if tmpl.Sides != nil {
+			params.Sides = sidesToAbstract(*tmpl.Sides)
+		}
+		if tmpl.PrintColorMode != nil {
+			params.ColorMode = colorModeToAbstract(*tmpl.PrintColorMode)
+		}
+		if tmpl.Media != nil {
+			params.Media = mediaSizeToAbstract(*tmpl.Media)
+		}
+	}
+
+	return params
+}
+
 // handleCancelJob handles Cancel-Job request.
 func (printer *Printer) handleCancelJob(
 	ctx context.Context,
@@ -0,0 +1,134 @@
+// MFP - Multi-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Print-Job request
+
+package ipp
+
+import (
+	"github.com/OpenPrinting/goipp"
+)
+
+// PrintJobRequest operation (0x0002) creates a new print Job
+// and submits the single document within the same request.
+// The document data is supplied as the request Body.
+type PrintJobRequest struct {
+	ObjectRawAttrs
+	RequestHeader
+
+	// Operation attributes
+	JobCreateOperation
+
+	// Job Template attributes (RFC8011 Group 2)
+	JobTemplate *JobTemplate
+}
+
+// PrintJobResponse is the Print-Job response.
+type PrintJobResponse struct {
+	ObjectRawAttrs
+	ResponseHeader
+	OperationGroup
+
+	// Unsupported attributes, if any
+	UnsupportedAttributes goipp.Attributes
+
+	// Job status
+	Job *JobDescriptionAndStatus
+}
+
+// GetOp returns PrintJobRequest IPP Operation code.
+func (rq *PrintJobRequest) GetOp() goipp.Op {
+	return goipp.OpPrintJob
+}
+
+// Encode encodes PrintJobRequest into the goipp.Message.
+func (rq *PrintJobRequest) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rq),
+		},
+	}
+
+	if rq.JobTemplate != nil {
+		groups.Add(goipp.Group{
+			Tag:   goipp.TagJobGroup,
+			Attrs: enc.Encode(rq.JobTemplate),
+		})
+	}
+
+	msg := goipp.NewMessageWithGroups(rq.Version, goipp.Code(rq.GetOp()),
+		rq.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes PrintJobRequest from goipp.Message.
+func (rq *PrintJobRequest) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rq.Version = msg.Version
+	rq.RequestID = msg.RequestID
+
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rq, msg.Operation)
+	if err != nil {
+		return err
+	}
+
+	rq.JobTemplate, err = DecodeJobTemplate(msg.Job, opt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Encode encodes PrintJobResponse into goipp.Message.
+func (rsp *PrintJobResponse) Encode() *goipp.Message {
+	enc := ippEncoder{}
+
+	groups := goipp.Groups{
+		{
+			Tag:   goipp.TagOperationGroup,
+			Attrs: enc.Encode(rsp),
+		},
+	}
+
+	if rsp.Job != nil {
+		groups = append(groups, goipp.Group{
+			Tag:   goipp.TagJobGroup,
+			Attrs: enc.Encode(rsp.Job),
+		})
+	}
+
+	msg := goipp.NewMessageWithGroups(rsp.Version, goipp.Code(rsp.Status),
+		rsp.RequestID, groups)
+
+	return msg
+}
+
+// Decode decodes PrintJobResponse from goipp.Message.
+func (rsp *PrintJobResponse) Decode(
+	msg *goipp.Message, opt *DecoderOptions) error {
+
+	rsp.Version = msg.Version
+	rsp.RequestID = msg.RequestID
+	rsp.Status = goipp.Status(msg.Code)
+	rsp.UnsupportedAttributes = msg.Unsupported
+
+	var err error
+	rsp.Job, err = DecodeJobDescriptionAndStatus(msg.Job, opt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -14,7 +14,6 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
 
 	"github.com/OpenPrinting/go-mfp/log"
@@ -34,8 +33,16 @@ type Proxy struct {
 	localPath string            // Path portion of the local URL
 	remoteURL *url.URL          // Remote URLs
 	clnt      *transport.Client // HTTP client part of proxy
+	limits    ResponseLimits    // Limits of the forwarded responses
 }
 
+// ProxyMethods lists HTTP methods, accepted by the [Proxy].
+//
+// POST requests must carry the IPP message (application/ipp).
+// GET and HEAD requests are forwarded as is. Other methods
+// are rejected with the http.StatusMethodNotAllowed status.
+var ProxyMethods = []string{"GET", "HEAD", "POST"}
+
 // proxyMsgXlat performs URL translation in the IPP requests
 // and responses.
 type proxyMsgXlat struct {
@@ -74,26 +81,44 @@ func NewProxy(localPath string, remoteURL *url.URL) *Proxy {
 	return proxy
 }
 
+// SetTransport sets the [transport.Transport], used by the client
+// side of the proxy to reach the remote printer. It allows, for
+// example, to choose the per-printer [transport.TLSPolicy].
+//
+// Don't use this function when proxy is already active (i.e.,
+// concurrently with the [Proxy.ServeHTTP]).
+func (proxy *Proxy) SetTransport(tr *transport.Transport) {
+	proxy.clnt = transport.NewClient(tr)
+}
+
+// SetResponseLimits sets limits of the IPP responses, forwarded
+// by the proxy. Responses that exceed them are rejected with the
+// http.StatusBadGateway status.
+//
+// Zero fields of limits are replaced with the corresponding
+// fields of the [DefaultProxyResponseLimits].
+//
+// Don't use this function when proxy is already active.
+func (proxy *Proxy) SetResponseLimits(limits ResponseLimits) {
+	proxy.limits = limits
+}
+
 // ServeHTTP handles incoming HTTP requests.
 // It implements [http.Handler] interface.
 func (proxy *Proxy) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
 	// Setup things
 	query := transport.NewServerQuery(w, rq)
-	ctx := query.RequestContext()
+	defer query.Finish()
 
-	// Validate the request
-	switch query.RequestMethod() {
-	case "POST":
-	case "GET":
-		query.Reject(http.StatusNotFound, nil)
-		return
-	default:
-		query.Reject(http.StatusBadRequest, nil)
-		return
-	}
+	ctx := query.RequestContext()
 
-	if query.RequestContentType() != "application/ipp" {
-		query.Reject(http.StatusBadRequest, nil)
+	// Sanitize request headers before anything is forwarded.
+	// When proxy is served by the transport.Server, this is
+	// already done, but Proxy may be used with other servers.
+	err := transport.HTTPSanitizeRequest(rq)
+	if err != nil {
+		log.Debug(ctx, "%s", err)
+		query.Reject(http.StatusBadRequest, err)
 		return
 	}
 
@@ -105,6 +130,24 @@ func (proxy *Proxy) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
 		return
 	}
 
+	// Dispatch the request. Non-IPP requests (printer web
+	// pages, icons and so on) are forwarded as is.
+	switch query.RequestMethod() {
+	case "POST":
+		if query.RequestContentType() != "application/ipp" {
+			query.Reject(http.StatusBadRequest, nil)
+			return
+		}
+
+	case "GET", "HEAD":
+		transport.Forward(query, proxy.clnt, xlat.urlxlat)
+		return
+
+	default:
+		query.RejectMethod(ProxyMethods...)
+		return
+	}
+
 	// Prepare outgoing request
 	out, err := proxy.doRequest(query, xlat)
 	if err != nil {
@@ -135,6 +178,8 @@ func (proxy *Proxy) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
 	if ct != "application/ipp" {
 		transport.HTTPRemoveHopByHopHeaders(rsp.Header)
 		transport.HTTPCopyHeaders(query.ResponseHeader(), rsp.Header)
+		transport.BodyMetaFromResponse(rsp).SetHeader(
+			query.ResponseHeader())
 		query.WriteHeader(rsp.StatusCode)
 		io.Copy(query, rsp.Body)
 		return
@@ -148,6 +193,21 @@ func (proxy *Proxy) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
 	}
 }
 
+// ProxyTranslateResponse translates URLs, embedded into the IPP
+// response message, the same way as [Proxy] does it in the
+// reverse (server->client) direction.
+//
+// It returns the translated copy of the message; the original
+// message is not modified. It is useful for tools that build
+// responses out of the recorded device responses.
+func ProxyTranslateResponse(msg *goipp.Message,
+	urlxlat *transport.URLXlat) *goipp.Message {
+
+	xlat := &proxyMsgXlat{urlxlat: urlxlat}
+	msg2, _ := xlat.Reverse(msg)
+	return msg2
+}
+
 // doRequest performs (client->server) part of the IPP request handling
 //
 // It returns modified request ready to be send to the server or error.
@@ -164,7 +224,7 @@ func (proxy *Proxy) doRequest(query *transport.ServerQuery,
 	var consumed transport.DiscardCounter
 
 	ops := goipp.DecoderOptions{EnableWorkarounds: true}
-	err := msg.DecodeEx(io.TeeReader(body, &consumed), ops)
+	err := msg.DecodeEx(transport.BroadcastReader(body, &consumed), ops)
 	if err != nil {
 		return nil, err
 	}
@@ -194,12 +254,14 @@ func (proxy *Proxy) doRequest(query *transport.ServerQuery,
 	body = io.NopCloser(io.MultiReader(bytes.NewReader(msg2bytes), body))
 
 	// Setup outgoing request
+	//
+	// If request length is known, the outgoing request length is
+	// adjusted to the size of the translated message. Otherwise,
+	// it will be sent chunked.
 	out := proxy.outreq(query, xlat, body)
-	out.ContentLength = query.RequestContentLength()
-	if out.ContentLength >= 0 {
-		out.ContentLength += int64(len(msg2bytes))
-		out.ContentLength -= consumed.Count
-	}
+	meta := transport.BodyMetaFromRequest(query.Request())
+	meta = meta.Replace(consumed.Count, int64(len(msg2bytes)))
+	out.ContentLength = meta.ContentLength()
 
 	return out, nil
 }
@@ -214,8 +276,15 @@ func (proxy *Proxy) doResponse(query *transport.ServerQuery,
 	var msg goipp.Message
 	var consumed transport.DiscardCounter
 
+	guard := newRspGuard(body,
+		proxy.limits.withDefaults(DefaultProxyResponseLimits))
+
 	ops := goipp.DecoderOptions{EnableWorkarounds: true}
-	err := msg.DecodeEx(io.TeeReader(body, &consumed), ops)
+	err := msg.DecodeEx(transport.BroadcastReader(guard, &consumed), ops)
+	if guard.err != nil {
+		err = guard.err
+	}
+
 	if err != nil {
 		return err
 	}
@@ -233,19 +302,14 @@ func (proxy *Proxy) doResponse(query *transport.ServerQuery,
 	body = io.NopCloser(io.MultiReader(bytes.NewReader(msg2bytes), body))
 
 	// Adjust rsp.ContentLength
-	if rsp.ContentLength >= 0 {
-		rsp.ContentLength += int64(len(msg2bytes))
-		rsp.ContentLength -= consumed.Count
-	}
+	meta := transport.BodyMetaFromResponse(rsp)
+	meta = meta.Replace(consumed.Count, int64(len(msg2bytes)))
+	rsp.ContentLength = meta.ContentLength()
 
 	// Copy response headers and status to the client
 	transport.HTTPRemoveHopByHopHeaders(rsp.Header)
 	transport.HTTPCopyHeaders(query.ResponseHeader(), rsp.Header)
-
-	if rsp.ContentLength >= 0 {
-		rsp.Header.Set("Content-Length",
-			strconv.FormatInt(rsp.ContentLength, 10))
-	}
+	meta.SetHeader(query.ResponseHeader())
 
 	query.WriteHeader(rsp.StatusCode)
 
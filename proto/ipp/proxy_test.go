@@ -0,0 +1,332 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// IPP Proxy test
+
+package ipp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestProxyMethods tests how Proxy handles HTTP methods
+func TestProxyMethods(t *testing.T) {
+	// Create fake printer. It returns request method in the
+	// X-Method response header.
+	target := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			w.Header().Set("X-Method", rq.Method)
+			w.WriteHeader(http.StatusOK)
+		}))
+	defer target.Close()
+
+	proxy := NewProxy("/ipp/print",
+		transport.MustParseURL(target.URL+"/ipp/print"))
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+
+	type testData struct {
+		method    string // Request method
+		status    int    // Expected status
+		forwarded bool   // Request must reach the target
+	}
+
+	tests := []testData{
+		{"GET", http.StatusOK, true},
+		{"HEAD", http.StatusOK, true},
+		{"PUT", http.StatusMethodNotAllowed, false},
+		{"DELETE", http.StatusMethodNotAllowed, false},
+		{"OPTIONS", http.StatusMethodNotAllowed, false},
+		{"PATCH", http.StatusMethodNotAllowed, false},
+	}
+
+	for _, test := range tests {
+		rq, _ := http.NewRequest(test.method, srv.URL+"/ipp/print", nil)
+		rsp, err := http.DefaultClient.Do(rq)
+		if err != nil {
+			t.Errorf("%s: %s", test.method, err)
+			continue
+		}
+		rsp.Body.Close()
+
+		if rsp.StatusCode != test.status {
+			t.Errorf("%s: status mismatch:\n"+
+				"expected: %d\npresent:  %d",
+				test.method, test.status, rsp.StatusCode)
+		}
+
+		method := rsp.Header.Get("X-Method")
+		switch {
+		case test.forwarded && method != test.method:
+			t.Errorf("%s: not forwarded", test.method)
+		case !test.forwarded && method != "":
+			t.Errorf("%s: unexpectedly forwarded", test.method)
+		}
+
+		allow := rsp.Header.Get("Allow")
+		if !test.forwarded && allow != "GET, HEAD, POST" {
+			t.Errorf("%s: Allow mismatch: %q", test.method, allow)
+		}
+	}
+}
+
+// TestProxyBodyLength tests that Proxy correctly handles chunked
+// and known-length IPP requests and responses with data attachment
+// and never sends bogus Content-Length.
+func TestProxyBodyLength(t *testing.T) {
+	data := strings.Repeat("0123456789", 10000)
+
+	// received is the request, received by the fake printer
+	type received struct {
+		chunked bool   // Request was chunked
+		data    string // Data, following the IPP message
+	}
+	ch := make(chan received, 1)
+
+	// Create fake printer. It echoes data, following the IPP
+	// request, after the IPP response. The response message
+	// contains URL, so it will be resized by the proxy.
+	//
+	// The X-Chunked request header requests the chunked response.
+	var target *httptest.Server
+	target = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			var msg goipp.Message
+			msg.Decode(rq.Body)
+			rqData, _ := io.ReadAll(rq.Body)
+			ch <- received{len(rq.TransferEncoding) != 0,
+				string(rqData)}
+
+			chunked := rq.Header.Get("X-Chunked") != ""
+
+			rsp := goipp.NewResponse(goipp.DefaultVersion,
+				goipp.StatusOk, msg.RequestID)
+			rsp.Operation.Add(goipp.MakeAttribute("job-uri",
+				goipp.TagURI, goipp.String(target.URL+
+					"/printers/long/remote/path/job/1")))
+			rspBytes, _ := rsp.EncodeBytes()
+			rspBytes = append(rspBytes, rqData...)
+
+			w.Header().Set("Content-Type", "application/ipp")
+			if !chunked {
+				w.Header().Set("Content-Length",
+					strconv.Itoa(len(rspBytes)))
+			}
+
+			for len(rspBytes) > 0 {
+				n := min(len(rspBytes), 4096)
+				w.Write(rspBytes[:n])
+				if chunked {
+					w.(http.Flusher).Flush()
+				}
+				rspBytes = rspBytes[n:]
+			}
+		}))
+	defer target.Close()
+
+	proxy := NewProxy("/ipp/print", transport.MustParseURL(
+		target.URL+"/printers/long/remote/path"))
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+
+	for _, chunked := range []bool{false, true} {
+		rq := goipp.NewRequest(goipp.DefaultVersion,
+			goipp.OpPrintJob, 1)
+		rq.Operation.Add(goipp.MakeAttribute("printer-uri",
+			goipp.TagURI, goipp.String(srv.URL+"/ipp/print")))
+		rqBytes, _ := rq.EncodeBytes()
+
+		// io.MultiReader hides length, so request will be chunked
+		var body io.Reader = bytes.NewReader(
+			append(rqBytes, []byte(data)...))
+		if chunked {
+			body = io.MultiReader(body)
+		}
+
+		httpRq, _ := http.NewRequest("POST", srv.URL+"/ipp/print", body)
+		httpRq.Header.Set("Content-Type", "application/ipp")
+		if chunked {
+			httpRq.Header.Set("X-Chunked", "true")
+		}
+
+		httpRsp, err := http.DefaultClient.Do(httpRq)
+		if err != nil {
+			t.Fatalf("chunked=%v: %s", chunked, err)
+		}
+
+		rspBytes, err := io.ReadAll(httpRsp.Body)
+		httpRsp.Body.Close()
+		if err != nil {
+			t.Errorf("chunked=%v: %s", chunked, err)
+		}
+
+		// Check the request, received by the printer
+		rcv := <-ch
+		if rcv.chunked != chunked {
+			t.Errorf("chunked=%v: request chunked=%v",
+				chunked, rcv.chunked)
+		}
+
+		if rcv.data != data {
+			t.Errorf("chunked=%v: request truncated: %d of %d bytes",
+				chunked, len(rcv.data), len(data))
+		}
+
+		// Check the response, received by the client
+		cl := httpRsp.Header.Get("Content-Length")
+		switch {
+		case chunked && cl != "":
+			t.Errorf("chunked=%v: bogus Content-Length: %s",
+				chunked, cl)
+
+		case !chunked && cl != strconv.Itoa(len(rspBytes)):
+			t.Errorf("chunked=%v: Content-Length %q, actual %d",
+				chunked, cl, len(rspBytes))
+		}
+
+		var msg goipp.Message
+		rd := bytes.NewReader(rspBytes)
+		if err = msg.Decode(rd); err != nil {
+			t.Errorf("chunked=%v: %s", chunked, err)
+			continue
+		}
+
+		rspData, _ := io.ReadAll(rd)
+		if string(rspData) != data {
+			t.Errorf("chunked=%v: response truncated: %d of %d bytes",
+				chunked, len(rspData), len(data))
+		}
+
+		jobURI := msg.Operation[len(msg.Operation)-1].Values[0].V
+		if jobURI.String() != srv.URL+"/ipp/print/job/1" {
+			t.Errorf("chunked=%v: job-uri not translated: %s",
+				chunked, jobURI)
+		}
+	}
+}
+
+// TestProxySanitize tests that Proxy, served by the transport.Server,
+// forwards sanitized headers and rejects ambiguous requests. Requests
+// are sent as raw bytes, so pathological headers reach the server.
+func TestProxySanitize(t *testing.T) {
+	// Create fake printer. It returns received Accept header
+	// in the X-Accept response header.
+	var reached atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			reached.Add(1)
+			w.Header().Set("X-Accept",
+				strings.Join(rq.Header.Values("Accept"), "|"))
+			w.WriteHeader(http.StatusOK)
+		}))
+	defer target.Close()
+
+	proxy := NewProxy("/ipp/print",
+		transport.MustParseURL(target.URL+"/ipp/print"))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	srvr := transport.NewServer(context.Background(), nil, proxy)
+	go srvr.Serve(l)
+	defer srvr.Close()
+
+	type testData struct {
+		name    string // Test name
+		rq      string // Raw request
+		status  int    // Expected status
+		accept  string // Expected X-Accept
+		reached bool   // Request must reach the target
+	}
+
+	tests := []testData{
+		{
+			name: "duplicate Accept",
+			rq: "GET /ipp/print HTTP/1.1\r\n" +
+				"Host: localhost\r\n" +
+				"Accept: text/html\r\n" +
+				"accept: image/png\r\n" +
+				"\r\n",
+			status:  http.StatusOK,
+			accept:  "text/html, image/png",
+			reached: true,
+		},
+
+		{
+			name: "conflicting Content-Type",
+			rq: "POST /ipp/print HTTP/1.1\r\n" +
+				"Host: localhost\r\n" +
+				"Content-Type: application/ipp\r\n" +
+				"Content-Type: text/plain\r\n" +
+				"Content-Length: 0\r\n" +
+				"\r\n",
+			status: http.StatusBadRequest,
+		},
+
+		{
+			name: "Content-Length with Transfer-Encoding",
+			rq: "POST /ipp/print HTTP/1.1\r\n" +
+				"Host: localhost\r\n" +
+				"Content-Type: application/ipp\r\n" +
+				"Content-Length: 8\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"0\r\n" +
+				"\r\n",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		before := reached.Load()
+
+		io.WriteString(conn, test.rq)
+		rsp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			conn.Close()
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		rsp.Body.Close()
+		conn.Close()
+
+		if rsp.StatusCode != test.status {
+			t.Errorf("%s: status expected %d, present %d",
+				test.name, test.status, rsp.StatusCode)
+		}
+
+		if accept := rsp.Header.Get("X-Accept"); accept != test.accept {
+			t.Errorf("%s: Accept expected %q, present %q",
+				test.name, test.accept, accept)
+		}
+
+		if (reached.Load() != before) != test.reached {
+			t.Errorf("%s: reached target: expected %v",
+				test.name, test.reached)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Size limits of the received IPP responses
+
+package ipp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/OpenPrinting/goipp"
+)
+
+// ResponseLimits limits size of the IPP responses, accepted by
+// the [Client] and forwarded by the [Proxy].
+//
+// Limits are enforced while message is being read, before it
+// is fully decoded, so misbehaving or malicious device cannot
+// force the unbounded memory consumption.
+//
+// Zero fields are replaced with the corresponding defaults.
+type ResponseLimits struct {
+	// MaxMessageSize limits the size of IPP message, in bytes.
+	// Document data, following the message, is not counted.
+	MaxMessageSize int64
+
+	// MaxAttributes limits the total count of attributes
+	// in the message. Members of collections are counted
+	// as separate attributes.
+	MaxAttributes int
+
+	// MaxValueSize limits the size of the single attribute
+	// value, in bytes.
+	MaxValueSize int
+}
+
+// DefaultResponseLimits are the default limits, used by [Client].
+//
+// They are generous enough for the largest real-world responses
+// (Get-Printer-Attributes of the multi-function devices with the
+// long media lists, Get-Jobs with long history and so on).
+var DefaultResponseLimits = ResponseLimits{
+	MaxMessageSize: 16 * 1024 * 1024,
+	MaxAttributes:  32768,
+	MaxValueSize:   32767,
+}
+
+// DefaultProxyResponseLimits are the default limits, used
+// by [Proxy] on the forwarding path.
+//
+// Proxy only translates messages and is not interested in
+// their content, so these limits are larger, than the
+// [DefaultResponseLimits].
+var DefaultProxyResponseLimits = ResponseLimits{
+	MaxMessageSize: 64 * 1024 * 1024,
+	MaxAttributes:  131072,
+	MaxValueSize:   65535,
+}
+
+// ResponseLimit identifies the particular limit of [ResponseLimits].
+type ResponseLimit int
+
+// ResponseLimit values:
+const (
+	ResponseLimitMessageSize ResponseLimit = iota // MaxMessageSize
+	ResponseLimitAttributes                       // MaxAttributes
+	ResponseLimitValueSize                        // MaxValueSize
+)
+
+// String returns the ResponseLimit name, for debugging.
+func (limit ResponseLimit) String() string {
+	switch limit {
+	case ResponseLimitMessageSize:
+		return "message size"
+	case ResponseLimitAttributes:
+		return "attributes count"
+	case ResponseLimitValueSize:
+		return "value size"
+	}
+
+	return fmt.Sprintf("ResponseLimit(%d)", int(limit))
+}
+
+// ErrResponseTooLarge is returned, when received IPP response
+// exceeds one of the [ResponseLimits].
+type ErrResponseTooLarge struct {
+	Limit ResponseLimit // Which limit is exceeded
+	Max   int64         // The limit value
+}
+
+// Error returns the error message. It implements the error interface.
+func (e *ErrResponseTooLarge) Error() string {
+	return fmt.Sprintf("IPP response too large: %s exceeds %d",
+		e.Limit, e.Max)
+}
+
+// withDefaults returns copy of ResponseLimits with zero fields
+// replaced by fields of dflt.
+func (limits ResponseLimits) withDefaults(
+	dflt ResponseLimits) ResponseLimits {
+
+	if limits.MaxMessageSize <= 0 {
+		limits.MaxMessageSize = dflt.MaxMessageSize
+	}
+	if limits.MaxAttributes <= 0 {
+		limits.MaxAttributes = dflt.MaxAttributes
+	}
+	if limits.MaxValueSize <= 0 {
+		limits.MaxValueSize = dflt.MaxValueSize
+	}
+
+	return limits
+}
+
+// rspGuardState is the state of the rspGuard parser
+type rspGuardState int
+
+// rspGuardState values:
+const (
+	rspGuardHeader   rspGuardState = iota // Version, code, request ID
+	rspGuardTag                           // Tag byte
+	rspGuardNameLen                       // Name length
+	rspGuardName                          // Name bytes
+	rspGuardValueLen                      // Value length
+	rspGuardValue                         // Value bytes
+	rspGuardDone                          // TagEnd seen
+)
+
+// rspGuard wraps the io.Reader of the IPP message and enforces
+// the ResponseLimits.
+//
+// It follows the IPP wire format incrementally, as bytes pass
+// through it, without any buffering, so the reader can be used
+// with the goipp decoder and the document data, following the
+// message, is not affected.
+type rspGuard struct {
+	in     io.Reader      // Underlying reader
+	limits ResponseLimits // Enforced limits
+	state  rspGuardState  // Parser state
+	tag    goipp.Tag      // Tag of the current attribute
+	need   int            // Bytes remaining in the current state
+	buf    [2]byte        // Length being accumulated
+	size   int64          // Message size so far
+	attrs  int            // Attributes count so far
+	err    error          // Sticky error
+}
+
+// newRspGuard creates a new rspGuard.
+func newRspGuard(in io.Reader, limits ResponseLimits) *rspGuard {
+	return &rspGuard{
+		in:     in,
+		limits: limits,
+		state:  rspGuardHeader,
+		need:   8,
+	}
+}
+
+// Read reads from the underlying reader.
+// It implements the io.Reader interface.
+func (guard *rspGuard) Read(p []byte) (int, error) {
+	if guard.err != nil {
+		return 0, guard.err
+	}
+
+	n, err := guard.in.Read(p)
+	if guard.state != rspGuardDone {
+		guard.err = guard.scan(p[:n])
+		if guard.err != nil {
+			return 0, guard.err
+		}
+	}
+
+	return n, err
+}
+
+// scan feeds the received bytes into the parser.
+func (guard *rspGuard) scan(data []byte) error {
+	for len(data) > 0 && guard.state != rspGuardDone {
+		// Account the message size
+		sz := 1
+		if guard.state != rspGuardTag {
+			sz = min(guard.need, len(data))
+		}
+
+		guard.size += int64(sz)
+		if guard.size > guard.limits.MaxMessageSize {
+			return rspTooLarge(ResponseLimitMessageSize,
+				guard.limits.MaxMessageSize)
+		}
+
+		chunk := data[:sz]
+		data = data[sz:]
+
+		switch guard.state {
+		case rspGuardHeader, rspGuardName, rspGuardValue:
+			guard.need -= sz
+			if guard.need == 0 {
+				guard.next()
+			}
+
+		case rspGuardTag:
+			guard.tag = goipp.Tag(chunk[0])
+			switch {
+			case guard.tag == goipp.TagEnd:
+				guard.state = rspGuardDone
+			case !guard.tag.IsDelimiter():
+				guard.state = rspGuardNameLen
+				guard.need = 2
+			}
+
+		case rspGuardNameLen, rspGuardValueLen:
+			copy(guard.buf[2-guard.need:], chunk)
+			guard.need -= sz
+			if guard.need == 0 {
+				err := guard.length()
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// length handles the just received name or value length.
+func (guard *rspGuard) length() error {
+	l := int(binary.BigEndian.Uint16(guard.buf[:]))
+
+	if guard.state == rspGuardNameLen {
+		// Additional values of the 1setOf attribute and
+		// collection members come with the empty name.
+		if l > 0 {
+			guard.attrs++
+		}
+
+		guard.state = rspGuardName
+	} else {
+		// Collection members are named by the value
+		// of the TagMemberName pseudo-attribute.
+		if guard.tag == goipp.TagMemberName {
+			guard.attrs++
+		}
+
+		if l > guard.limits.MaxValueSize {
+			return rspTooLarge(ResponseLimitValueSize,
+				int64(guard.limits.MaxValueSize))
+		}
+
+		guard.state = rspGuardValue
+	}
+
+	if guard.attrs > guard.limits.MaxAttributes {
+		return rspTooLarge(ResponseLimitAttributes,
+			int64(guard.limits.MaxAttributes))
+	}
+
+	guard.need = l
+	if l == 0 {
+		guard.next()
+	}
+
+	return nil
+}
+
+// next advances state after the header, name or value is done.
+func (guard *rspGuard) next() {
+	switch guard.state {
+	case rspGuardHeader, rspGuardValue:
+		guard.state = rspGuardTag
+	case rspGuardName:
+		guard.state = rspGuardValueLen
+		guard.need = 2
+	}
+}
+
+// rspTooLarge returns the ErrResponseTooLarge error.
+func rspTooLarge(limit ResponseLimit, max int64) error {
+	return &ErrResponseTooLarge{Limit: limit, Max: max}
+}
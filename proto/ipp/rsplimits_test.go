@@ -0,0 +1,286 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Size limits of the received IPP responses tests
+
+package ipp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/goipp"
+)
+
+// testRspLimitsMsg creates the synthetic IPP response with the
+// specified count of attributes with values of the specified size.
+// The collection with two members is appended at the end.
+func testRspLimitsMsg(attrs, size int) []byte {
+	msg := goipp.NewResponse(goipp.DefaultVersion, goipp.StatusOk, 1)
+	msg.Operation.Add(goipp.MakeAttribute("attributes-charset",
+		goipp.TagCharset, goipp.String("utf-8")))
+
+	val := goipp.String(strings.Repeat("x", size))
+	for i := 0; i < attrs; i++ {
+		msg.Printer.Add(goipp.MakeAttribute(fmt.Sprintf("attr-%d", i),
+			goipp.TagText, val))
+	}
+
+	var col goipp.Collection
+	col.Add(goipp.MakeAttribute("a", goipp.TagInteger, goipp.Integer(1)))
+	col.Add(goipp.MakeAttribute("b", goipp.TagInteger, goipp.Integer(2)))
+	msg.Printer.Add(goipp.MakeAttribute("col",
+		goipp.TagBeginCollection, col))
+
+	data, _ := msg.EncodeBytes()
+	return data
+}
+
+// TestResponseLimits tests enforcement of the ResponseLimits
+func TestResponseLimits(t *testing.T) {
+	// 10 attributes + attributes-charset + col with 2 members
+	data := testRspLimitsMsg(10, 100)
+	const attrs = 10 + 1 + 3
+
+	type testData struct {
+		name   string
+		limits ResponseLimits
+		err    error
+	}
+
+	tests := []testData{
+		{
+			name:   "defaults",
+			limits: DefaultResponseLimits,
+		},
+
+		{
+			name: "at limits",
+			limits: ResponseLimits{
+				MaxMessageSize: int64(len(data)),
+				MaxAttributes:  attrs,
+				MaxValueSize:   100,
+			},
+		},
+
+		{
+			name: "message size",
+			limits: ResponseLimits{
+				MaxMessageSize: int64(len(data)) - 1,
+				MaxAttributes:  attrs,
+				MaxValueSize:   100,
+			},
+			err: &ErrResponseTooLarge{
+				Limit: ResponseLimitMessageSize,
+				Max:   int64(len(data)) - 1,
+			},
+		},
+
+		{
+			name: "attributes count",
+			limits: ResponseLimits{
+				MaxMessageSize: int64(len(data)),
+				MaxAttributes:  attrs - 1,
+				MaxValueSize:   100,
+			},
+			err: &ErrResponseTooLarge{
+				Limit: ResponseLimitAttributes,
+				Max:   attrs - 1,
+			},
+		},
+
+		{
+			name: "value size",
+			limits: ResponseLimits{
+				MaxMessageSize: int64(len(data)),
+				MaxAttributes:  attrs,
+				MaxValueSize:   99,
+			},
+			err: &ErrResponseTooLarge{
+				Limit: ResponseLimitValueSize,
+				Max:   99,
+			},
+		},
+	}
+
+	// The document data, following the message, must be
+	// passed as is and not counted.
+	trailer := bytes.Repeat([]byte("data"), 1000)
+	input := append(append([]byte{}, data...), trailer...)
+
+	readers := []struct {
+		name string
+		new  func([]byte) io.Reader
+	}{
+		{"bulk", func(b []byte) io.Reader {
+			return bytes.NewReader(b)
+		}},
+		{"bytewise", func(b []byte) io.Reader {
+			return iotest.OneByteReader(bytes.NewReader(b))
+		}},
+	}
+
+	for _, test := range tests {
+		for _, rd := range readers {
+			in := rd.new(input)
+			guard := newRspGuard(in, test.limits)
+
+			var msg goipp.Message
+			err := msg.Decode(guard)
+			if guard.err != nil {
+				err = guard.err
+			}
+
+			name := test.name + "/" + rd.name
+
+			if test.err == nil {
+				if err != nil {
+					t.Errorf("%s: %s", name, err)
+					continue
+				}
+
+				rest, _ := io.ReadAll(guard)
+				if !bytes.Equal(rest, trailer) {
+					t.Errorf("%s: document data corrupted",
+						name)
+				}
+				continue
+			}
+
+			var tooLarge *ErrResponseTooLarge
+			switch {
+			case !errors.As(err, &tooLarge):
+				t.Errorf("%s: expected %q, present %v",
+					name, test.err, err)
+			case *tooLarge != *test.err.(*ErrResponseTooLarge):
+				t.Errorf("%s: expected %+v, present %+v",
+					name, test.err, tooLarge)
+			}
+		}
+	}
+}
+
+// TestResponseLimitsAllocs tests that the ResponseLimits
+// enforcement doesn't add allocations to the decoding of
+// the near-limit message
+func TestResponseLimitsAllocs(t *testing.T) {
+	data := testRspLimitsMsg(1000, 1000)
+	limits := ResponseLimits{
+		MaxMessageSize: int64(len(data)),
+		MaxAttributes:  1000 + 1 + 3,
+		MaxValueSize:   1000,
+	}
+
+	rd := bytes.NewReader(data)
+
+	plain := testing.AllocsPerRun(10, func() {
+		rd.Reset(data)
+		var msg goipp.Message
+		msg.Decode(rd)
+	})
+
+	guarded := testing.AllocsPerRun(10, func() {
+		rd.Reset(data)
+		guard := newRspGuard(rd, limits)
+		var msg goipp.Message
+		msg.Decode(guard)
+		if guard.err != nil {
+			t.Fatalf("%s", guard.err)
+		}
+	})
+
+	// Only the guard itself may be allocated
+	if guarded > plain+1 {
+		t.Errorf("allocations: plain %v, guarded %v", plain, guarded)
+	}
+}
+
+// TestClientResponseLimits tests ResponseLimits, enforced by Client
+func TestClientResponseLimits(t *testing.T) {
+	data := testRspLimitsMsg(100, 100)
+
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			io.Copy(io.Discard, rq.Body)
+			w.Header().Set("Content-Type", "application/ipp")
+			w.Write(data)
+		}))
+	defer srv.Close()
+
+	u := transport.MustParseURL(srv.URL)
+	clnt := NewClient(u, nil)
+	clnt.SetVersion(goipp.DefaultVersion)
+
+	// Within the defaults
+	_, err := clnt.GetPrinterAttributes(context.Background(), nil, "")
+	if err != nil {
+		t.Errorf("default limits: %s", err)
+	}
+
+	// Over the limit
+	clnt.SetResponseLimits(ResponseLimits{MaxAttributes: 50})
+	_, err = clnt.GetPrinterAttributes(context.Background(), nil, "")
+
+	var tooLarge *ErrResponseTooLarge
+	if !errors.As(err, &tooLarge) ||
+		tooLarge.Limit != ResponseLimitAttributes {
+		t.Errorf("attributes limit: expected %s, present %v",
+			ResponseLimitAttributes, err)
+	}
+}
+
+// TestProxyResponseLimits tests ResponseLimits, enforced by Proxy
+func TestProxyResponseLimits(t *testing.T) {
+	data := testRspLimitsMsg(100, 100)
+
+	target := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			io.Copy(io.Discard, rq.Body)
+			w.Header().Set("Content-Type", "application/ipp")
+			w.Write(data)
+		}))
+	defer target.Close()
+
+	proxy := NewProxy("/ipp/print",
+		transport.MustParseURL(target.URL+"/ipp/print"))
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+
+	rq := goipp.NewRequest(goipp.DefaultVersion,
+		goipp.OpGetPrinterAttributes, 1)
+	rqData, _ := rq.EncodeBytes()
+
+	post := func() int {
+		rsp, err := http.Post(srv.URL+"/ipp/print", "application/ipp",
+			bytes.NewReader(rqData))
+		if err != nil {
+			t.Fatalf("POST: %s", err)
+		}
+		io.Copy(io.Discard, rsp.Body)
+		rsp.Body.Close()
+		return rsp.StatusCode
+	}
+
+	// Within the defaults
+	if status := post(); status != http.StatusOK {
+		t.Errorf("default limits: status %d", status)
+	}
+
+	// Over the limit
+	proxy.SetResponseLimits(ResponseLimits{MaxValueSize: 50})
+	if status := post(); status != http.StatusBadGateway {
+		t.Errorf("value size limit: status %d", status)
+	}
+}
@@ -39,7 +39,7 @@ func (d *docBodyCloser) Close() error {
 // Scanner implements the IPP Scan Service as defined in PWG5100.17.
 type Scanner struct {
 	options ScannerOptions
-	server  *Server
+	mux     *ServeMux
 	attrs   *PrinterAttributes
 	q       *queue
 
@@ -76,22 +76,22 @@ func NewScanner(attrs *PrinterAttributes, options ScannerOptions) *Scanner {
 	attrs.ScannerDescription =
 		fromAbstractScannerDescription(options.Scanner.Capabilities())
 
-	server := NewServer(options.ServerOptions)
+	mux := NewServeMux(options.ServerOptions)
 	scanner := &Scanner{
 		options:     options,
-		server:      server,
+		mux:         mux,
 		attrs:       attrs,
 		q:           newQueue(),
 		activeDocCh: make(chan docResult, 1),
 	}
 
 	// Install scan-service handlers.
-	server.RegisterHandler(NewHandler(scanner.handleGetPrinterAttributes))
-	server.RegisterHandler(NewHandler(scanner.handleGetJobs))
-	server.RegisterHandler(NewHandler(scanner.handleGetJobAttributes))
-	server.RegisterHandler(NewHandler(scanner.handleCreateScanJob))
-	server.RegisterHandler(NewHandler(scanner.handleGetNextDocumentData))
-	server.RegisterHandler(NewHandler(scanner.handleCancelJob))
+	mux.RegisterHandler(NewHandler(scanner.handleGetPrinterAttributes))
+	mux.RegisterHandler(NewHandler(scanner.handleGetJobs))
+	mux.RegisterHandler(NewHandler(scanner.handleGetJobAttributes))
+	mux.RegisterHandler(NewHandler(scanner.handleCreateScanJob))
+	mux.RegisterHandler(NewHandler(scanner.handleGetNextDocumentData))
+	mux.RegisterHandler(NewHandler(scanner.handleCancelJob))
 
 	return scanner
 }
@@ -99,7 +99,7 @@ func NewScanner(attrs *PrinterAttributes, options ScannerOptions) *Scanner {
 // ServeHTTP handles incoming HTTP requests. It implements
 // [http.Handler] interface.
 func (scanner *Scanner) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
-	scanner.server.ServeHTTP(w, rq)
+	scanner.mux.ServeHTTP(w, rq)
 }
 
 // handleGetPrinterAttributes handles Get-Printer-Attributes request.
@@ -0,0 +1,59 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// IPP request router
+
+package ipp
+
+import (
+	"net/http"
+
+	"github.com/OpenPrinting/goipp"
+)
+
+// ServeMux is the IPP request router. It dispatches incoming IPP
+// requests by the operation code to the registered handlers.
+//
+// It is the building block for the IPP services, like virtual
+// printers and scanners. ServeMux works on a top of the [Server],
+// which gives it the following properties:
+//   - HTTP requests other that POST of application/ipp are rejected
+//     at the HTTP level
+//   - Requests and responses are automatically decoded and encoded
+//   - Unregistered operations are answered with the
+//     server-error-operation-not-supported IPP status
+//   - Request ID and attributes-charset are echoed in the response,
+//     if handler didn't set them
+//   - Handler panic is recovered and answered with the
+//     server-error-internal-error IPP status
+//
+// ServeMux implements the [http.Handler] interface.
+type ServeMux struct {
+	server *Server
+}
+
+// NewServeMux creates a new ServeMux.
+func NewServeMux(options ServerOptions) *ServeMux {
+	return &ServeMux{server: NewServer(options)}
+}
+
+// Handle registers the [HandlerFunc] for the IPP operation.
+// If handler for this operation already exists, it is replaced.
+func (mux *ServeMux) Handle(op goipp.Op, h HandlerFunc) {
+	mux.server.RegisterHandler(NewHandlerFunc(op, h))
+}
+
+// RegisterHandler adds the request [Handler], created by the
+// [NewHandler] or [NewHandlerFunc].
+func (mux *ServeMux) RegisterHandler(handler *Handler) {
+	mux.server.RegisterHandler(handler)
+}
+
+// ServeHTTP handles incoming HTTP request. It implements
+// [http.Handler] interface.
+func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
+	mux.server.ServeHTTP(w, rq)
+}
@@ -0,0 +1,160 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// IPP request router test
+
+package ipp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestServeMux tests ServeMux
+func TestServeMux(t *testing.T) {
+	mux := NewServeMux(ServerOptions{})
+
+	// Get-Printer-Attributes returns printer-name and doesn't
+	// set Request ID and charset: they must be echoed.
+	mux.Handle(goipp.OpGetPrinterAttributes,
+		func(ctx context.Context, rq *goipp.Message,
+			body io.Reader) (*goipp.Message, io.Reader, error) {
+
+			rsp := &goipp.Message{Code: goipp.Code(goipp.StatusOk)}
+			rsp.Printer.Add(goipp.MakeAttribute("printer-name",
+				goipp.TagName, goipp.String("Test")))
+			return rsp, nil, nil
+		})
+
+	// Print-Job echoes the document data in the response body
+	mux.Handle(goipp.OpPrintJob,
+		func(ctx context.Context, rq *goipp.Message,
+			body io.Reader) (*goipp.Message, io.Reader, error) {
+
+			data, _ := io.ReadAll(body)
+			rsp := goipp.NewResponse(rq.Version, goipp.StatusOk,
+				rq.RequestID)
+			return rsp, bytes.NewReader(data), nil
+		})
+
+	// Cancel-Job panics
+	mux.Handle(goipp.OpCancelJob,
+		func(ctx context.Context, rq *goipp.Message,
+			body io.Reader) (*goipp.Message, io.Reader, error) {
+			panic("test panic")
+		})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// send sends the IPP request and returns the response
+	send := func(op goipp.Op, data string) (*goipp.Message, string) {
+		rq := goipp.NewRequest(goipp.DefaultVersion, op, 42)
+		rq.Operation.Add(goipp.MakeAttribute("attributes-charset",
+			goipp.TagCharset, goipp.String("utf-8")))
+		rq.Operation.Add(goipp.MakeAttribute(
+			"attributes-natural-language",
+			goipp.TagLanguage, goipp.String("de-DE")))
+		rqBytes, _ := rq.EncodeBytes()
+
+		httpRsp, err := http.Post(srv.URL, goipp.ContentType,
+			bytes.NewReader(append(rqBytes, data...)))
+		if err != nil {
+			t.Fatalf("%s: %s", op, err)
+		}
+		defer httpRsp.Body.Close()
+
+		if httpRsp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: HTTP %s", op, httpRsp.Status)
+		}
+
+		rsp := &goipp.Message{}
+		err = rsp.Decode(httpRsp.Body)
+		if err != nil {
+			t.Fatalf("%s: %s", op, err)
+		}
+
+		rspData, _ := io.ReadAll(httpRsp.Body)
+		return rsp, string(rspData)
+	}
+
+	// Dispatch and echo
+	rsp, _ := send(goipp.OpGetPrinterAttributes, "")
+	if goipp.Status(rsp.Code) != goipp.StatusOk {
+		t.Errorf("Get-Printer-Attributes: status %s",
+			goipp.Status(rsp.Code))
+	}
+
+	if rsp.RequestID != 42 {
+		t.Errorf("Get-Printer-Attributes: request ID not echoed")
+	}
+
+	if len(rsp.Printer) != 1 || rsp.Printer[0].Name != "printer-name" {
+		t.Errorf("Get-Printer-Attributes: wrong handler called")
+	}
+
+	names := []string{}
+	for _, attr := range rsp.Operation {
+		names = append(names, attr.Name+"="+attr.Values.String())
+	}
+
+	expected := "attributes-charset=utf-8," +
+		"attributes-natural-language=de-DE"
+	if present := strings.Join(names, ","); present != expected {
+		t.Errorf("Get-Printer-Attributes: charset not echoed:\n"+
+			"expected: %s\npresent:  %s", expected, present)
+	}
+
+	// Request and response bodies
+	rsp, data := send(goipp.OpPrintJob, "document data")
+	if goipp.Status(rsp.Code) != goipp.StatusOk {
+		t.Errorf("Print-Job: status %s", goipp.Status(rsp.Code))
+	}
+
+	if data != "document data" {
+		t.Errorf("Print-Job: body mismatch: %q", data)
+	}
+
+	// Unsupported operation
+	rsp, _ = send(goipp.OpGetJobs, "")
+	if goipp.Status(rsp.Code) != goipp.StatusErrorOperationNotSupported {
+		t.Errorf("Get-Jobs: status %s", goipp.Status(rsp.Code))
+	}
+
+	if rsp.RequestID != 42 {
+		t.Errorf("Get-Jobs: request ID not echoed")
+	}
+
+	// Handler panic. Server must survive it.
+	rsp, _ = send(goipp.OpCancelJob, "")
+	if goipp.Status(rsp.Code) != goipp.StatusErrorInternal {
+		t.Errorf("Cancel-Job: status %s", goipp.Status(rsp.Code))
+	}
+
+	rsp, _ = send(goipp.OpGetPrinterAttributes, "")
+	if goipp.Status(rsp.Code) != goipp.StatusOk {
+		t.Errorf("After panic: status %s", goipp.Status(rsp.Code))
+	}
+
+	// Non-IPP POST
+	httpRsp, err := http.Post(srv.URL, "text/plain",
+		strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("text/plain: %s", err)
+	}
+	httpRsp.Body.Close()
+
+	if httpRsp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain: HTTP %s", httpRsp.Status)
+	}
+}
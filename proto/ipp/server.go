@@ -10,15 +10,18 @@ package ipp
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
 	"net/http/httputil"
+	"runtime/debug"
 
 	"github.com/OpenPrinting/go-mfp/log"
 	"github.com/OpenPrinting/go-mfp/log/trace"
 	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/generic"
 	"github.com/OpenPrinting/goipp"
 )
 
@@ -34,6 +37,12 @@ type ServerOptions struct {
 	// Hooks defines IPP server hooks. See [ServerHooks]
 	// for details.
 	Hooks ServerHooks
+
+	// MaxVersion is the highest IPP version, accepted by the
+	// server. Requests with the higher version are rejected
+	// with the server-error-version-not-supported status.
+	// If zero, [DefaultVersion] is used.
+	MaxVersion goipp.Version
 }
 
 // NewServer returns a new Sever.
@@ -125,12 +134,19 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
 		return
 	}
 
-	if msg.Version < goipp.MakeVersion(1, 0) ||
-		msg.Version > goipp.DefaultVersion {
+	maxVersion := s.options.MaxVersion
+	if maxVersion == 0 {
+		maxVersion = DefaultVersion
+	}
+
+	if msg.Version < MinVersion || msg.Version > maxVersion {
 		err := NewErrIPPFromMessage(msg,
 			goipp.StatusErrorVersionNotSupported,
 			"bad request version %s", msg.Version)
 
+		// Respond with the version, we actually support
+		err.Version = generic.Min(err.Version, maxVersion)
+
 		s.httpError(query, err)
 		return
 	}
@@ -147,12 +163,14 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
 	}
 
 	// Handle the message
-	rsp, rspBody, err := handler.handle(ctx, msg, body)
+	rsp, rspBody, err := s.handle(ctx, handler, msg, body)
 	if err != nil {
 		s.httpError(query, err)
 		return
 	}
 
+	serverEchoRequest(msg, rsp)
+
 	// Close the body. It will notify tracer that request is
 	// fully consumed, so tracer can finish writing it.
 	body.Close()
@@ -175,15 +193,34 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
 	log.Debug(ctx, "IPP response message:")
 	log.Debug(ctx, buf.String())
 
+	// Encode response
+	data, err := rsp.EncodeBytes()
+	if err != nil {
+		log.Error(ctx, "IPP error encoding response: %s", err)
+		if rspBody != nil {
+			rspBody.Close()
+		}
+		query.Reject(http.StatusInternalServerError, err)
+		return
+	}
+
+	// Response length is known, unless it has the data
+	// attachment, which is sent chunked.
+	meta := transport.BodyMetaLength(int64(len(data)))
+	if rspBody != nil {
+		meta = transport.BodyMetaChunked()
+	}
+
 	// Send response
 	query.ResponseHeader().Set("Content-Type", "application/ipp")
+	meta.SetHeader(query.ResponseHeader())
 	query.WriteHeader(http.StatusOK) // At HTTP level everything OK.
 
 	// Notify tracer, if present (must be after WriteHeader so
 	// DumpResponse can read the correct response status).
 	trace.OnResponse(query, goippResponse{rsp}, nil)
 
-	err = rsp.Encode(query)
+	_, err = query.Write(data)
 	if err != nil {
 		log.Error(ctx, "IPP error sending response: %s", err)
 	}
@@ -198,6 +235,83 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
 	query.Finish()
 }
 
+// handle calls the handler. Handler panic is recovered and
+// converted into the server-error-internal-error IPP error.
+func (s *Server) handle(ctx context.Context, handler *Handler,
+	msg *goipp.Message, body io.Reader) (
+	rsp *goipp.Message, rspBody io.ReadCloser, err error) {
+
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+
+		log.Error(ctx, "IPP handler panic: %v", p)
+		log.Debug(ctx, "%s", debug.Stack())
+
+		if rspBody != nil {
+			rspBody.Close()
+		}
+
+		rsp, rspBody = nil, nil
+		err = NewErrIPPFromMessage(msg, goipp.StatusErrorInternal,
+			"internal error")
+	}()
+
+	rsp, rspBody, err = handler.handle(ctx, msg, body)
+	if err == nil && rsp == nil {
+		err = NewErrIPPFromMessage(msg, goipp.StatusErrorInternal,
+			"no response")
+	}
+
+	return
+}
+
+// serverEchoRequest copies request ID, version and attributes-charset
+// from the request into the response, if handler didn't set them.
+func serverEchoRequest(rq, rsp *goipp.Message) {
+	if rsp.RequestID == 0 {
+		rsp.RequestID = rq.RequestID
+	}
+
+	if rsp.Version == 0 {
+		rsp.Version = generic.Min(rq.Version, MaxVersion)
+	}
+
+	if len(rsp.Operation) != 0 &&
+		rsp.Operation[0].Name == "attributes-charset" {
+		return
+	}
+
+	charset := goipp.MakeAttribute("attributes-charset",
+		goipp.TagCharset, goipp.String("utf-8"))
+	language := goipp.MakeAttribute("attributes-natural-language",
+		goipp.TagLanguage, goipp.String("en-US"))
+
+	// Response values take precedence over the request values
+	attrs := append(rq.Operation.Clone(), rsp.Operation...)
+	for _, attr := range attrs {
+		switch attr.Name {
+		case "attributes-charset":
+			charset = attr
+		case "attributes-natural-language":
+			language = attr
+		}
+	}
+
+	ops := goipp.Attributes{charset, language}
+	for _, attr := range rsp.Operation {
+		switch attr.Name {
+		case "attributes-charset", "attributes-natural-language":
+		default:
+			ops = append(ops, attr)
+		}
+	}
+
+	rsp.Operation = ops
+}
+
 // RegisterHandler adds the request [Handler].
 func (s *Server) RegisterHandler(handler *Handler) {
 	s.ops[handler.Op] = handler
@@ -233,10 +347,14 @@ func (s *Server) httpError(query *transport.ServerQuery, err error) {
 		log.Debug(ctx, buf.String())
 
 		// Finish the HTTP query
+		data, _ := rsp.EncodeBytes()
+		meta := transport.BodyMetaLength(int64(len(data)))
+
 		query.ResponseHeader().Set("Content-Type", "application/ipp")
+		meta.SetHeader(query.ResponseHeader())
 		query.WriteHeader(http.StatusOK) // At HTTP level everything OK.
 
-		rsp.Encode(query)
+		query.Write(data)
 
 		// Notify tracer, if present (must be after WriteHeader so
 		// DumpResponse can read the correct response status).
@@ -0,0 +1,287 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Introspection of the "xxx-supported" Printer attributes
+
+package ipp
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/OpenPrinting/goipp"
+)
+
+// SupportedKind classifies the "xxx-supported" Printer attribute.
+type SupportedKind int
+
+// SupportedKind values:
+const (
+	SupportedList       SupportedKind = iota // List of values
+	SupportedRange                           // Range(s) of integers
+	SupportedBoolean                         // Boolean capability
+	SupportedCollection                      // Collection schema
+)
+
+// String returns the SupportedKind name, for debugging.
+func (kind SupportedKind) String() string {
+	switch kind {
+	case SupportedList:
+		return "list"
+	case SupportedRange:
+		return "range"
+	case SupportedBoolean:
+		return "boolean"
+	case SupportedCollection:
+		return "collection"
+	}
+
+	return fmt.Sprintf("SupportedKind(%d)", int(kind))
+}
+
+// SupportedSpec describes values of the attribute, supported
+// by the Printer, as reported by its "xxx-supported" counterpart.
+type SupportedSpec struct {
+	// Kind of support.
+	Kind SupportedKind
+
+	// Values are the raw values of the "xxx-supported" attribute.
+	Values goipp.Values
+
+	// Ranges of supported integer values, for SupportedRange.
+	Ranges []goipp.Range
+
+	// Bool is the capability value, for SupportedBoolean.
+	Bool bool
+
+	// Members are names of the supported collection member
+	// attributes, for SupportedCollection.
+	Members []string
+}
+
+// SupportedValues returns all "xxx-supported" Printer attributes,
+// indexed by the name of the attribute they describe (i.e.,
+// "copies-supported" is returned under the "copies" key).
+//
+// Attributes are taken from the typed fields of the
+// PrinterAttributes and from the raw attributes, not covered
+// by the typed fields.
+//
+// It allows generic UI to render the option pickers without
+// knowledge of the particular attributes.
+func (pa *PrinterAttributes) SupportedValues() map[string]SupportedSpec {
+	enc := ippEncoder{}
+	attrs := enc.Encode(pa)
+	attrs = append(attrs, pa.RawAttrs().All()...)
+
+	return supportedSpecs(attrs)
+}
+
+// SupportsValue reports if value of the attribute is supported
+// by the Printer. If value is not supported, reason explains why.
+//
+// Value may be either [goipp.Value] or any Go value of the
+// string, integer or bool kind (including keyword types, like
+// [KwSides]).
+func (pa *PrinterAttributes) SupportsValue(attr string,
+	value any) (ok bool, reason string) {
+
+	spec, found := pa.SupportedValues()[attr]
+	if !found {
+		return false, fmt.Sprintf("%s: not supported", attr)
+	}
+
+	return spec.Supports(value)
+}
+
+// Supports reports if the value is supported, according to the
+// SupportedSpec. If value is not supported, reason explains why.
+//
+// See [PrinterAttributes.SupportsValue] for the possible value types.
+func (spec SupportedSpec) Supports(value any) (ok bool, reason string) {
+	v := supportedValue(value)
+	if v == nil {
+		return false, fmt.Sprintf("%T: unsupported value type", value)
+	}
+
+	switch spec.Kind {
+	case SupportedBoolean:
+		if !spec.Bool {
+			return false, "not supported"
+		}
+		return true, ""
+
+	case SupportedRange:
+		for _, s := range spec.Values {
+			if s.V.Type() != goipp.TypeRange &&
+				goipp.ValueEqual(v, s.V) {
+				return true, ""
+			}
+		}
+
+		i, isInt := v.(goipp.Integer)
+		if !isInt {
+			return false, fmt.Sprintf("%s: integer expected", v)
+		}
+
+		for _, rng := range spec.Ranges {
+			if rng.Lower <= int(i) && int(i) <= rng.Upper {
+				return true, ""
+			}
+		}
+
+		return false, fmt.Sprintf("%d: out of range %s", i,
+			supportedRangesString(spec.Ranges))
+
+	case SupportedCollection:
+		col, isCol := v.(goipp.Collection)
+		if !isCol {
+			return false, fmt.Sprintf("%s: collection expected", v)
+		}
+
+		for _, mbr := range col {
+			if !slices.Contains(spec.Members, mbr.Name) {
+				return false, fmt.Sprintf(
+					"%s: member not supported", mbr.Name)
+			}
+		}
+
+		return true, ""
+	}
+
+	for _, s := range spec.Values {
+		if goipp.ValueEqual(v, s.V) {
+			return true, ""
+		}
+	}
+
+	return false, fmt.Sprintf("%s: not in the supported values", v)
+}
+
+// supportedSpecs builds SupportedSpec for all "xxx-supported"
+// attributes. If attribute is present multiple times, the first
+// occurrence wins.
+func supportedSpecs(attrs goipp.Attributes) map[string]SupportedSpec {
+	specs := make(map[string]SupportedSpec)
+	for _, attr := range attrs {
+		name, ok := strings.CutSuffix(attr.Name, "-supported")
+		if !ok || name == "" || len(attr.Values) == 0 {
+			continue
+		}
+
+		if _, found := specs[name]; !found {
+			specs[name] = newSupportedSpec(name, attr.Values)
+		}
+	}
+
+	return specs
+}
+
+// newSupportedSpec classifies values of the "xxx-supported"
+// attribute and creates the SupportedSpec.
+func newSupportedSpec(name string, values goipp.Values) SupportedSpec {
+	spec := SupportedSpec{Kind: SupportedList, Values: values}
+
+	var bools, ranges, cols, strs int
+	for _, v := range values {
+		switch v.V.(type) {
+		case goipp.Boolean:
+			bools++
+		case goipp.Range:
+			ranges++
+		case goipp.Collection:
+			cols++
+		case goipp.String:
+			strs++
+		}
+	}
+
+	switch {
+	case bools == len(values):
+		// "page-ranges-supported" and similar
+		spec.Kind = SupportedBoolean
+		spec.Bool = bool(values[0].V.(goipp.Boolean))
+
+	case ranges != 0:
+		// "copies-supported" and similar. Integer values,
+		// if any, are the single-value ranges.
+		spec.Kind = SupportedRange
+		for _, v := range values {
+			switch s := v.V.(type) {
+			case goipp.Range:
+				spec.Ranges = append(spec.Ranges, s)
+			case goipp.Integer:
+				spec.Ranges = append(spec.Ranges,
+					goipp.Range{Lower: int(s), Upper: int(s)})
+			}
+		}
+
+	case name == "job-priority" && len(values) == 1:
+		// "job-priority-supported" is the maximum value
+		if s, ok := values[0].V.(goipp.Integer); ok {
+			spec.Kind = SupportedRange
+			spec.Ranges = []goipp.Range{{Lower: 1, Upper: int(s)}}
+		}
+
+	case strings.HasSuffix(name, "-col") && strs == len(values):
+		// "media-col-supported" and similar list names
+		// of the supported member attributes.
+		spec.Kind = SupportedCollection
+		for _, v := range values {
+			spec.Members = append(spec.Members, v.V.String())
+		}
+
+	case cols == len(values):
+		// "media-size-supported" and similar list collections
+		// of supported values. Only member names are checked.
+		spec.Kind = SupportedCollection
+		for _, v := range values {
+			for _, mbr := range v.V.(goipp.Collection) {
+				if !slices.Contains(spec.Members, mbr.Name) {
+					spec.Members = append(spec.Members,
+						mbr.Name)
+				}
+			}
+		}
+	}
+
+	return spec
+}
+
+// supportedValue converts value into goipp.Value.
+// It returns nil, if value type is not supported.
+func supportedValue(value any) goipp.Value {
+	if v, ok := value.(goipp.Value); ok {
+		return v
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return goipp.String(rv.String())
+	case reflect.Bool:
+		return goipp.Boolean(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return goipp.Integer(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return goipp.Integer(rv.Uint())
+	}
+
+	return nil
+}
+
+// supportedRangesString formats ranges for the error messages.
+func supportedRangesString(ranges []goipp.Range) string {
+	s := make([]string, len(ranges))
+	for i, rng := range ranges {
+		s[i] = fmt.Sprintf("%d-%d", rng.Lower, rng.Upper)
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,213 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Introspection of the "xxx-supported" Printer attributes tests
+
+package ipp
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// testSupportedPrinterAttributes returns the fixture PrinterAttributes
+func testSupportedPrinterAttributes() *PrinterAttributes {
+	pa := &PrinterAttributes{
+		PrinterDescription: PrinterDescription{
+			MediaColSupported: []string{"media-size", "media-type"},
+			MediaSizeSupported: []MediaSizeRange{
+				{
+					XDimension: goipp.Integer(21000),
+					YDimension: goipp.Integer(29700),
+				},
+			},
+		},
+		JobTemplateCapabilities: JobTemplateCapabilities{
+			CopiesSupported:      optional.New(goipp.Range{Lower: 1, Upper: 99}),
+			JobPrioritySupported: optional.New(100),
+			MediaSupported:       []KwMedia{"iso_a4_210x297mm"},
+			PageRangesSupported:  optional.New(true),
+			SidesSupported: []KwSides{
+				KwSidesOneSided,
+				KwSidesTwoSidedLongEdge,
+			},
+			JobAccountIDSupported: optional.New(false),
+		},
+	}
+
+	// Attribute, not covered by the typed fields
+	ObjectSetAttr(pa, goipp.MakeAttribute("x-vendor-mode-supported",
+		goipp.TagKeyword, goipp.String("fast")))
+
+	return pa
+}
+
+// TestSupportedValues tests PrinterAttributes.SupportedValues
+func TestSupportedValues(t *testing.T) {
+	specs := testSupportedPrinterAttributes().SupportedValues()
+
+	tests := []struct {
+		attr    string
+		kind    SupportedKind
+		ranges  []goipp.Range
+		members []string
+	}{
+		{attr: "sides", kind: SupportedList},
+		{attr: "media", kind: SupportedList},
+		{attr: "x-vendor-mode", kind: SupportedList},
+		{attr: "copies", kind: SupportedRange,
+			ranges: []goipp.Range{{Lower: 1, Upper: 99}}},
+		{attr: "job-priority", kind: SupportedRange,
+			ranges: []goipp.Range{{Lower: 1, Upper: 100}}},
+		{attr: "page-ranges", kind: SupportedBoolean},
+		{attr: "job-account-id", kind: SupportedBoolean},
+		{attr: "media-col", kind: SupportedCollection,
+			members: []string{"media-size", "media-type"}},
+		{attr: "media-size", kind: SupportedCollection,
+			members: []string{"x-dimension", "y-dimension"}},
+	}
+
+	for _, test := range tests {
+		spec, found := specs[test.attr]
+		switch {
+		case !found:
+			t.Errorf("%s: missed", test.attr)
+			continue
+
+		case spec.Kind != test.kind:
+			t.Errorf("%s: kind expected %s, present %s",
+				test.attr, test.kind, spec.Kind)
+		}
+
+		if !reflect.DeepEqual(spec.Ranges, test.ranges) {
+			t.Errorf("%s: ranges expected %v, present %v",
+				test.attr, test.ranges, spec.Ranges)
+		}
+
+		if !reflect.DeepEqual(spec.Members, test.members) {
+			t.Errorf("%s: members expected %v, present %v",
+				test.attr, test.members, spec.Members)
+		}
+	}
+
+	if !specs["page-ranges"].Bool || specs["job-account-id"].Bool {
+		t.Errorf("boolean capabilities decoded incorrectly")
+	}
+}
+
+// TestSupportsValue tests PrinterAttributes.SupportsValue
+func TestSupportsValue(t *testing.T) {
+	pa := testSupportedPrinterAttributes()
+
+	tests := []struct {
+		attr   string
+		value  any
+		ok     bool
+		reason string
+	}{
+		// List
+		{"sides", KwSidesTwoSidedLongEdge, true, ""},
+		{"sides", "two-sided-short-edge", false,
+			`"two-sided-short-edge": not in the supported values`},
+		{"x-vendor-mode", goipp.String("fast"), true, ""},
+
+		// Range
+		{"copies", 10, true, ""},
+		{"copies", 100, false, "100: out of range 1-99"},
+		{"copies", "ten", false, `"ten": integer expected`},
+		{"job-priority", 50, true, ""},
+
+		// Boolean
+		{"page-ranges", goipp.Range{Lower: 1, Upper: 5}, true, ""},
+		{"job-account-id", "account", false, "not supported"},
+
+		// Collection
+		{"media-col",
+			goipp.Collection{goipp.MakeAttribute("media-size",
+				goipp.TagBeginCollection, goipp.Collection{})},
+			true, ""},
+		{"media-col",
+			goipp.Collection{goipp.MakeAttribute("media-source",
+				goipp.TagKeyword, goipp.String("tray-1"))},
+			false, "media-source: member not supported"},
+
+		// Unknown attribute and value type
+		{"finishings", 3, false, "finishings: not supported"},
+		{"copies", 1.5, false, "float64: unsupported value type"},
+	}
+
+	for _, test := range tests {
+		ok, reason := pa.SupportsValue(test.attr, test.value)
+		if ok != test.ok || reason != test.reason {
+			t.Errorf("%s=%v: expected %v %q, present %v %q",
+				test.attr, test.value, test.ok, test.reason,
+				ok, reason)
+		}
+	}
+}
+
+// TestGetPrinterSupportedValues tests Get-Printer-Supported-Values
+// operation
+func TestGetPrinterSupportedValues(t *testing.T) {
+	pa := testSupportedPrinterAttributes()
+	pa.PrinterName = optional.New("Test Printer")
+
+	srv := httptest.NewServer(NewPrinter(pa, PrinterOptions{}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	clnt := NewClient(transport.MustParseURL(srv.URL), nil)
+
+	// All "xxx-supported" attributes
+	rq := &GetPrinterSupportedValuesRequest{
+		RequestHeader: DefaultRequestHeader,
+		PrinterURI:    srv.URL,
+	}
+
+	rsp := &GetPrinterSupportedValuesResponse{}
+	err := clnt.Do(ctx, rq, rsp)
+	if err != nil {
+		t.Fatalf("Get-Printer-Supported-Values: %s", err)
+	}
+
+	for _, attr := range rsp.Printer.RawAttrs().All() {
+		if !strings.HasSuffix(attr.Name, "-supported") {
+			t.Errorf("unexpected attribute: %s", attr.Name)
+		}
+	}
+
+	if !reflect.DeepEqual(rsp.Printer.SidesSupported, pa.SidesSupported) {
+		t.Errorf("sides-supported: expected %v, present %v",
+			pa.SidesSupported, rsp.Printer.SidesSupported)
+	}
+
+	// Requested attributes
+	rq.RequestedAttributes = []string{"copies-supported",
+		"finishings-supported"}
+
+	rsp = &GetPrinterSupportedValuesResponse{}
+	err = clnt.Do(ctx, rq, rsp)
+	if err != nil {
+		t.Fatalf("Get-Printer-Supported-Values: %s", err)
+	}
+
+	if rsp.Status != goipp.StatusOkIgnoredOrSubstituted {
+		t.Errorf("status: expected %s, present %s",
+			goipp.StatusOkIgnoredOrSubstituted, rsp.Status)
+	}
+
+	attrs := rsp.Printer.RawAttrs().All()
+	if len(attrs) != 1 || attrs[0].Name != "copies-supported" {
+		t.Errorf("expected copies-supported only, present %v", attrs)
+	}
+}
@@ -0,0 +1,339 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Input and output trays status
+
+package ipp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// Special values of the tray level and capacity, as defined by
+// RFC 3805 (Printer MIB v2).
+const (
+	TrayLevelOther         = -1 // Other, or no restriction
+	TrayLevelUnknown       = -2 // Unknown
+	TrayLevelSomeRemaining = -3 // At least one unit remains
+)
+
+// InputTray is the parsed "printer-input-tray" value
+// (PWG5100.13, 6.6.9).
+//
+// The value is the octetString, that contains semicolon-delimited
+// key=value pairs, like this:
+//
+//	type=sheetFeedAutoRemovableTray;mediafeed=0;mediaxfeed=0;
+//	maxcapacity=250;level=100;status=0;name=Tray 2;
+//
+// Numeric fields, missed in the value, are set to TrayLevelUnknown.
+type InputTray struct {
+	Name        string            // Tray name, "" if missed
+	Type        string            // I.e., "sheetFeedAutoRemovableTray"
+	DimUnit     string            // I.e., "micrometers"
+	MediaFeed   int               // Media size in feed direction
+	MediaXFeed  int               // Media size across feed direction
+	Unit        string            // Capacity unit, I.e., "sheets"
+	MaxCapacity int               // Maximal capacity, in Units
+	Level       int               // Current level, in Units
+	Status      int               // RFC 3805 prtSubUnitStatus
+	Percent     int               // Level, percents of MaxCapacity
+	Media       KwMedia           // Media, loaded into the tray
+	Extra       map[string]string // Unknown (vendor) keys, nil if none
+}
+
+// OutputTray is the parsed "printer-output-tray" value
+// (PWG5100.13, 6.6.10). See [InputTray] for the format description.
+type OutputTray struct {
+	Name          string            // Tray name, "" if missed
+	Type          string            // I.e., "unRemovableBin"
+	Unit          string            // Capacity unit, I.e., "sheets"
+	MaxCapacity   int               // Maximal capacity, in Units
+	Remaining     int               // Remaining capacity, in Units
+	Status        int               // RFC 3805 prtSubUnitStatus
+	StackingOrder string            // I.e., "firstToLast"
+	PageDelivery  string            // I.e., "faceDown"
+	Percent       int               // Fill level, percents of MaxCapacity
+	Extra         map[string]string // Unknown (vendor) keys, nil if none
+}
+
+// ParseInputTray parses the "printer-input-tray" value.
+//
+// Parsing is tolerant: unknown keys are saved into Extra, missed
+// and malformed numeric fields are set to TrayLevelUnknown.
+//
+// Percent is computed from Level and MaxCapacity. If the percentage
+// cannot be computed, it is either TrayLevelSomeRemaining, if the
+// tray is known to be not empty, or TrayLevelUnknown.
+func ParseInputTray(s string) InputTray {
+	kv := trayParse(s)
+
+	tray := InputTray{
+		Name:        kv.str("name"),
+		Type:        kv.str("type"),
+		DimUnit:     kv.str("dimunit"),
+		MediaFeed:   kv.num("mediafeed"),
+		MediaXFeed:  kv.num("mediaxfeed"),
+		Unit:        kv.str("unit"),
+		MaxCapacity: kv.num("maxcapacity"),
+		Level:       kv.num("level"),
+		Status:      max(kv.num("status"), 0),
+	}
+
+	tray.Percent = trayPercent(tray.Level, tray.MaxCapacity)
+	tray.Extra = kv.extra()
+
+	return tray
+}
+
+// ParseOutputTray parses the "printer-output-tray" value.
+// See [ParseInputTray] for details.
+//
+// For output trays, Percent is the fill level, computed from
+// Remaining and MaxCapacity.
+func ParseOutputTray(s string) OutputTray {
+	kv := trayParse(s)
+
+	tray := OutputTray{
+		Name:          kv.str("name"),
+		Type:          kv.str("type"),
+		Unit:          kv.str("unit"),
+		MaxCapacity:   kv.num("maxcapacity"),
+		Remaining:     kv.num("remaining"),
+		Status:        max(kv.num("status"), 0),
+		StackingOrder: kv.str("stackingorder"),
+		PageDelivery:  kv.str("pagedelivery"),
+	}
+
+	tray.Percent = TrayLevelUnknown
+	switch {
+	case tray.MaxCapacity > 0 && tray.Remaining >= 0:
+		used := max(tray.MaxCapacity-tray.Remaining, 0)
+		tray.Percent = trayPercent(used, tray.MaxCapacity)
+	case tray.Remaining == 0:
+		tray.Percent = 100
+	}
+
+	tray.Extra = kv.extra()
+
+	return tray
+}
+
+// String formats InputTray for humans, like "Tray 2: A4, 40% full".
+func (tray InputTray) String() string {
+	s := trayName(tray.Name, tray.Type) + ":"
+	if tray.Media != "" {
+		s += " " + trayMediaName(tray.Media) + ","
+	}
+
+	switch tray.Percent {
+	case TrayLevelUnknown:
+		s += " level unknown"
+	case TrayLevelSomeRemaining:
+		s += " not empty"
+	case 0:
+		s += " empty"
+	default:
+		s += fmt.Sprintf(" %d%% full", tray.Percent)
+	}
+
+	return s
+}
+
+// String formats OutputTray for humans, like "Top Tray: 10% full".
+func (tray OutputTray) String() string {
+	s := trayName(tray.Name, tray.Type) + ":"
+
+	switch {
+	case tray.Percent >= 0:
+		s += fmt.Sprintf(" %d%% full", tray.Percent)
+	case tray.Remaining == TrayLevelSomeRemaining:
+		s += " not full"
+	default:
+		s += " level unknown"
+	}
+
+	return s
+}
+
+// InputTrays returns the parsed "printer-input-tray" values.
+//
+// Media, loaded into the tray, is taken from the "media-ready"
+// attribute. As "media-ready" is not explicitly linked to trays,
+// the "media-col-ready" entry is searched, which "media-source"
+// matches the tray name, and the "media-ready" value at the same
+// position is used. If not found, Media remains empty.
+func (pa *PrinterAttributes) InputTrays() []InputTray {
+	if len(pa.PrinterInputTray) == 0 {
+		return nil
+	}
+
+	trays := make([]InputTray, len(pa.PrinterInputTray))
+	for i, s := range pa.PrinterInputTray {
+		trays[i] = ParseInputTray(s)
+		trays[i].Media = pa.trayMedia(trays[i].Name)
+	}
+
+	return trays
+}
+
+// OutputTrays returns the parsed "printer-output-tray" values.
+func (pa *PrinterAttributes) OutputTrays() []OutputTray {
+	if len(pa.PrinterOutputTray) == 0 {
+		return nil
+	}
+
+	trays := make([]OutputTray, len(pa.PrinterOutputTray))
+	for i, s := range pa.PrinterOutputTray {
+		trays[i] = ParseOutputTray(s)
+	}
+
+	return trays
+}
+
+// trayMedia returns media, loaded into the tray, or "" if unknown.
+func (pa *PrinterAttributes) trayMedia(name string) KwMedia {
+	if name == "" {
+		return ""
+	}
+
+	for i, col := range pa.MediaColReady {
+		src := optional.Get(col.MediaSource)
+		if src == "" || trayNormalize(src) != trayNormalize(name) {
+			continue
+		}
+
+		if sizeName := optional.Get(col.MediaSizeName); sizeName != "" {
+			return KwMedia(sizeName)
+		}
+
+		if len(pa.MediaReady) == len(pa.MediaColReady) {
+			return pa.MediaReady[i]
+		}
+	}
+
+	return ""
+}
+
+// trayKeys contains parsed key=value pairs of the tray value
+type trayKeys map[string]string
+
+// trayKnownKeys contains keys, defined by PWG5100.13
+var trayKnownKeys = map[string]struct{}{
+	"name": {}, "type": {}, "dimunit": {}, "mediafeed": {},
+	"mediaxfeed": {}, "unit": {}, "maxcapacity": {}, "level": {},
+	"remaining": {}, "status": {}, "stackingorder": {},
+	"pagedelivery": {},
+}
+
+// trayParse splits the tray value into key=value pairs.
+//
+// Keys are case-insensitive. Empty items and items without '='
+// are ignored. If key is repeated, the first value wins.
+// Trailing NUL bytes, seen in some real-world values, are ignored.
+func trayParse(s string) trayKeys {
+	kv := make(trayKeys)
+	s = strings.TrimRight(s, "\x00")
+
+	for _, item := range strings.Split(s, ";") {
+		key, val, found := strings.Cut(item, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !found || key == "" {
+			continue
+		}
+
+		if _, dup := kv[key]; !dup {
+			kv[key] = strings.TrimSpace(val)
+		}
+	}
+
+	return kv
+}
+
+// str returns string value of the key, "" if missed.
+func (kv trayKeys) str(key string) string {
+	return kv[key]
+}
+
+// num returns numerical value of the key. Missed and malformed
+// values are returned as TrayLevelUnknown.
+func (kv trayKeys) num(key string) int {
+	v, err := strconv.Atoi(kv[key])
+	if err != nil {
+		return TrayLevelUnknown
+	}
+	return v
+}
+
+// extra returns unknown keys, nil if none.
+func (kv trayKeys) extra() map[string]string {
+	var extra map[string]string
+	for key, val := range kv {
+		if _, known := trayKnownKeys[key]; !known {
+			if extra == nil {
+				extra = make(map[string]string)
+			}
+			extra[key] = val
+		}
+	}
+	return extra
+}
+
+// trayPercent converts level into percents of capacity.
+func trayPercent(level, capacity int) int {
+	switch {
+	case level == TrayLevelSomeRemaining:
+		return TrayLevelSomeRemaining
+	case level < 0:
+		return TrayLevelUnknown
+	case level == 0:
+		return 0
+	case capacity > 0:
+		return min(level*100/capacity, 100)
+	}
+
+	// Level is positive, but capacity unknown
+	return TrayLevelSomeRemaining
+}
+
+// trayName returns the tray name for humans
+func trayName(name, typ string) string {
+	switch {
+	case name != "":
+		return name
+	case typ != "":
+		return typ
+	}
+	return "unnamed"
+}
+
+// trayNormalize normalizes tray name or media-source keyword
+// for comparison: "Tray 1" and "tray-1" are the same.
+func trayNormalize(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return strings.NewReplacer(" ", "-", "_", "-").Replace(s)
+}
+
+// trayMediaName returns the short media name for humans, i.e.,
+// "A4" for "iso_a4_210x297mm" or "letter" for "na_letter_8.5x11in".
+func trayMediaName(kw KwMedia) string {
+	parts := strings.Split(string(kw), "_")
+	if len(parts) < 3 {
+		return string(kw)
+	}
+
+	name := strings.Join(parts[1:len(parts)-1], "_")
+	switch parts[0] {
+	case "iso", "jis", "prc", "roc":
+		name = strings.ToUpper(name)
+	}
+
+	return name
+}
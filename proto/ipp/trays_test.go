@@ -0,0 +1,292 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Input and output trays status test
+
+package ipp
+
+import (
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// TestParseInputTray tests ParseInputTray
+func TestParseInputTray(t *testing.T) {
+	type testData struct {
+		in   string    // Input string
+		tray InputTray // Expected output
+		str  string    // Expected InputTray.String()
+	}
+
+	tests := []testData{
+		// Kyocera ECOSYS M2040dn: no dimunit, repeated keys
+		{
+			in: "type=other;mediafeed=116929;mediaxfeed=82677;" +
+				"mediafeed=116929;mediaxfeed=82677;maxcapacity=-2;" +
+				"level=-2;status=19;name=Auto;",
+			tray: InputTray{
+				Name:        "Auto",
+				Type:        "other",
+				MediaFeed:   116929,
+				MediaXFeed:  82677,
+				MaxCapacity: TrayLevelUnknown,
+				Level:       TrayLevelUnknown,
+				Status:      19,
+				Percent:     TrayLevelUnknown,
+			},
+			str: "Auto: level unknown",
+		},
+		{
+			in: "type=sheetFeedAutoNonRemovableTray;mediafeed=116929;" +
+				"mediaxfeed=82677;maxcapacity=250;level=-3;" +
+				"status=19;name=Cassette 1;",
+			tray: InputTray{
+				Name:        "Cassette 1",
+				Type:        "sheetFeedAutoNonRemovableTray",
+				MediaFeed:   116929,
+				MediaXFeed:  82677,
+				MaxCapacity: 250,
+				Level:       TrayLevelSomeRemaining,
+				Status:      19,
+				Percent:     TrayLevelSomeRemaining,
+			},
+			str: "Cassette 1: not empty",
+		},
+
+		// Xerox B235: name first, dimunit and unit present
+		{
+			in: "name=tray-1;type=sheetFeedAutoRemovableTray;" +
+				"dimunit=micrometers;mediafeed=297000;" +
+				"mediaxfeed=210000;unit=sheets;maxcapacity=250;" +
+				"level=250;status=0;",
+			tray: InputTray{
+				Name:        "tray-1",
+				Type:        "sheetFeedAutoRemovableTray",
+				DimUnit:     "micrometers",
+				MediaFeed:   297000,
+				MediaXFeed:  210000,
+				Unit:        "sheets",
+				MaxCapacity: 250,
+				Level:       250,
+				Percent:     100,
+			},
+			str: "tray-1: 100% full",
+		},
+		{
+			in: "name=manual;type=sheetFeedManual;dimunit=micrometers;" +
+				"mediafeed=297000;mediaxfeed=210000;unit=sheets;" +
+				"maxcapacity=1;level=0;status=0;",
+			tray: InputTray{
+				Name:        "manual",
+				Type:        "sheetFeedManual",
+				DimUnit:     "micrometers",
+				MediaFeed:   297000,
+				MediaXFeed:  210000,
+				Unit:        "sheets",
+				MaxCapacity: 1,
+				Level:       0,
+				Percent:     0,
+			},
+			str: "manual: empty",
+		},
+
+		// HP: vendor key, no trailing semicolon
+		{
+			in: "type=sheetFeedAutoRemovableTray;mediafeed=0;" +
+				"mediaxfeed=0;maxcapacity=250;level=100;status=0;" +
+				"name=Tray 2;x-hp-media-type=plain",
+			tray: InputTray{
+				Name:        "Tray 2",
+				Type:        "sheetFeedAutoRemovableTray",
+				MaxCapacity: 250,
+				Level:       100,
+				Percent:     40,
+				Extra:       map[string]string{"x-hp-media-type": "plain"},
+			},
+			str: "Tray 2: 40% full",
+		},
+
+		// Level without maxcapacity
+		{
+			in: "type=sheetFeedManual;level=10;name=Bypass",
+			tray: InputTray{
+				Name:        "Bypass",
+				Type:        "sheetFeedManual",
+				MediaFeed:   TrayLevelUnknown,
+				MediaXFeed:  TrayLevelUnknown,
+				MaxCapacity: TrayLevelUnknown,
+				Level:       10,
+				Percent:     TrayLevelSomeRemaining,
+			},
+			str: "Bypass: not empty",
+		},
+
+		// Level above maxcapacity, mixed case keys, garbage
+		{
+			in: "Name=Big;MaxCapacity=100;Level=150;junk;=x;;\x00\x00",
+			tray: InputTray{
+				Name:        "Big",
+				MediaFeed:   TrayLevelUnknown,
+				MediaXFeed:  TrayLevelUnknown,
+				MaxCapacity: 100,
+				Level:       150,
+				Percent:     100,
+			},
+			str: "Big: 100% full",
+		},
+
+		// Empty string
+		{
+			in: "",
+			tray: InputTray{
+				MediaFeed:   TrayLevelUnknown,
+				MediaXFeed:  TrayLevelUnknown,
+				MaxCapacity: TrayLevelUnknown,
+				Level:       TrayLevelUnknown,
+				Percent:     TrayLevelUnknown,
+			},
+			str: "unnamed: level unknown",
+		},
+	}
+
+	for _, test := range tests {
+		tray := ParseInputTray(test.in)
+		if diff := testutils.Diff(test.tray, tray); diff != "" {
+			t.Errorf("%q:\n%s", test.in, diff)
+		}
+
+		if s := tray.String(); s != test.str {
+			t.Errorf("%q: String:\nexpected: %q\npresent:  %q",
+				test.in, test.str, s)
+		}
+	}
+}
+
+// TestParseOutputTray tests ParseOutputTray
+func TestParseOutputTray(t *testing.T) {
+	type testData struct {
+		in      string // Input string
+		percent int    // Expected Percent
+		str     string // Expected OutputTray.String()
+	}
+
+	tests := []testData{
+		// Kyocera ECOSYS M2040dn
+		{
+			in: "type=unRemovableBin;maxcapacity=150;remaining=-2;" +
+				"status=4;name=Top Tray;stackingorder=firstToLast;" +
+				"pagedelivery=faceDown;",
+			percent: TrayLevelUnknown,
+			str:     "Top Tray: level unknown",
+		},
+
+		// Xerox B235
+		{
+			in: "name=face-down;type=unRemovableBin;unit=sheets;" +
+				"maxcapacity=150;remaining=-3;status=0;" +
+				"stackingorder=firstToLast;pagedelivery=faceDown;",
+			percent: TrayLevelUnknown,
+			str:     "face-down: not full",
+		},
+
+		// Known fill level
+		{
+			in:      "name=Bin;maxcapacity=200;remaining=180",
+			percent: 10,
+			str:     "Bin: 10% full",
+		},
+
+		// Full, capacity unknown
+		{
+			in:      "name=Bin;remaining=0",
+			percent: 100,
+			str:     "Bin: 100% full",
+		},
+	}
+
+	for _, test := range tests {
+		tray := ParseOutputTray(test.in)
+		if tray.Percent != test.percent {
+			t.Errorf("%q: Percent: expected %d, present %d",
+				test.in, test.percent, tray.Percent)
+		}
+
+		if s := tray.String(); s != test.str {
+			t.Errorf("%q: String:\nexpected: %q\npresent:  %q",
+				test.in, test.str, s)
+		}
+	}
+}
+
+// TestInputTrays tests PrinterAttributes.InputTrays and media
+// correlation on the real printer attributes
+func TestInputTrays(t *testing.T) {
+	type testData struct {
+		name  string   // Printer name
+		data  []byte   // Printer attributes
+		trays []string // Expected trays, formatted
+	}
+
+	tests := []testData{
+		{
+			name: "Xerox B235",
+			data: testutils.Xerox.B235.IPP.PrinterAttributes,
+			trays: []string{
+				"auto: 100% full",
+				"envelope: empty",
+				"manual: empty",
+				"tray-1: A4, 100% full",
+			},
+		},
+		{
+			// Tray names don't match media-source
+			name: "Kyocera ECOSYS M2040dn",
+			data: testutils.Kyocera.ECOSYS.M2040dn.IPP.PrinterAttributes,
+			trays: []string{
+				"Auto: level unknown",
+				"MP Tray: empty",
+				"Cassette 1: not empty",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		msg := testutils.IPPMustParse(test.data)
+		rsp := &GetPrinterAttributesResponse{}
+		err := rsp.Decode(msg, nil)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		var trays []string
+		for _, tray := range rsp.Printer.InputTrays() {
+			trays = append(trays, tray.String())
+		}
+
+		if diff := testutils.Diff(test.trays, trays); diff != "" {
+			t.Errorf("%s:\n%s", test.name, diff)
+		}
+
+		if len(rsp.Printer.OutputTrays()) == 0 {
+			t.Errorf("%s: OutputTrays: no trays", test.name)
+		}
+	}
+
+	// Correlation by media-size-name
+	pa := &PrinterAttributes{}
+	pa.PrinterInputTray = []string{"name=Tray 2;maxcapacity=250;level=100"}
+	pa.MediaColReady = []MediaColEx{{}, {}}
+	pa.MediaColReady[1].MediaSource = optional.New("tray-2")
+	pa.MediaColReady[1].MediaSizeName = optional.New("na_letter_8.5x11in")
+
+	trays := pa.InputTrays()
+	if s := trays[0].String(); s != "Tray 2: letter, 40% full" {
+		t.Errorf("media-size-name: unexpected %q", s)
+	}
+}
@@ -57,6 +57,18 @@ func testDiffStruct(s1, s2 interface{}) string {
 		v1 := struct1.Field(i).Interface()
 		v2 := struct2.Field(i).Interface()
 
+		// Compare nested non-nil structures field by field,
+		// so their ObjectRawAttrs will be skipped as well
+		f1, f2 := struct1.Field(i), struct2.Field(i)
+		if fld.Type.Kind() == reflect.Pointer &&
+			fld.Type.Elem().Kind() == reflect.Struct &&
+			!f1.IsNil() && !f2.IsNil() {
+			if diff := testDiffStruct(v1, v2); diff != "" {
+				fmt.Fprintf(buf, "%s:\n%s", fld.Name, diff)
+			}
+			continue
+		}
+
 		if !reflect.DeepEqual(v1, v2) {
 			fmt.Fprintf(buf, "%s:\n  <<< %#v\n  >>> %#v\n",
 				fld.Name, v1, v2)
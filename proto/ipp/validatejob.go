@@ -50,11 +50,13 @@ func (rq *ValidateJobRequest) Encode() *goipp.Message {
 			Tag:   goipp.TagOperationGroup,
 			Attrs: enc.Encode(rq),
 		},
+	}
 
-		{
+	if rq.JobTemplate != nil {
+		groups.Add(goipp.Group{
 			Tag:   goipp.TagJobGroup,
 			Attrs: enc.Encode(rq.JobTemplate),
-		},
+		})
 	}
 
 	msg := goipp.NewMessageWithGroups(rq.Version, goipp.Code(rq.GetOp()),
@@ -119,5 +121,13 @@ func (rsp *ValidateJobResponse) Decode(
 	rsp.Status = goipp.Status(msg.Code)
 	rsp.UnsupportedAttributes = msg.Unsupported
 
+	dec := NewDecoder(opt)
+	defer dec.Free()
+
+	err := dec.Decode(rsp, msg.Operation)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
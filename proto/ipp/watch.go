@@ -0,0 +1,144 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer configuration and state watcher
+
+package ipp
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/log"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// watchAttrs are the attributes, requested by [Watch] on each poll.
+var watchAttrs = []string{
+	"printer-config-change-time",
+	"printer-state",
+	"printer-state-reasons",
+}
+
+// watchState is the printer state, as seen by [Watch].
+type watchState struct {
+	configChange optional.Val[int]
+	state        optional.Val[int]
+	reasons      []KwPrinterStateReasons
+}
+
+// Watch watches the printer configuration and state.
+//
+// At every interval it requests only the printer-config-change-time,
+// printer-state and printer-state-reasons attributes. When the
+// genuine change is detected, it refetches the full set of printer
+// attributes and calls onChange with them.
+//
+// Change of the printer-config-change-time is reported immediately.
+// Changes of the printer-state and printer-state-reasons are subject
+// to hysteresis: the new state is reported only if it is seen by two
+// subsequent polls, so a state flap shorter than one interval is
+// ignored.
+//
+// The onChange callback is also called once at the beginning, with
+// the initial printer attributes.
+//
+// Poll errors are logged and don't terminate the Watch. The Watch
+// runs until ctx is canceled and returns ctx.Err().
+func Watch(ctx context.Context, clnt *Client, interval time.Duration,
+	onChange func(*PrinterAttributes)) error {
+
+	var committed, pending *watchState
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Fetch the full set of attributes, if needed
+		if committed == nil {
+			attrs, err := watchGet(ctx, clnt, nil)
+			if err == nil {
+				committed = newWatchState(attrs)
+				pending = nil
+				onChange(attrs)
+			} else if ctx.Err() == nil {
+				log.Debug(ctx, "IPP: %s: watch: %s", clnt.URL, err)
+			}
+		}
+
+		// Wait for the next poll
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if committed == nil {
+			continue
+		}
+
+		// Poll the printer
+		attrs, err := watchGet(ctx, clnt, watchAttrs)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Debug(ctx, "IPP: %s: watch: %s", clnt.URL, err)
+			}
+			continue
+		}
+
+		current := newWatchState(attrs)
+
+		switch {
+		case !reflect.DeepEqual(current.configChange,
+			committed.configChange):
+			log.Debug(ctx, "IPP: %s: watch: configuration changed",
+				clnt.URL)
+			committed = nil
+
+		case current.sameState(committed):
+			// Flap, if any, is over
+			pending = nil
+
+		case pending != nil && current.sameState(pending):
+			log.Debug(ctx, "IPP: %s: watch: state changed", clnt.URL)
+			committed = nil
+
+		default:
+			// New state; wait for confirmation by the next poll
+			pending = current
+		}
+	}
+}
+
+// watchGet requests printer attributes for the Watch.
+func watchGet(ctx context.Context, clnt *Client,
+	attrs []string) (*PrinterAttributes, error) {
+
+	prn, err := clnt.GetPrinterAttributes(ctx, attrs, "")
+	if err == nil && prn == nil {
+		err = errors.New("printer attributes not received")
+	}
+
+	return prn, err
+}
+
+// newWatchState creates a new watchState from the printer attributes.
+func newWatchState(attrs *PrinterAttributes) *watchState {
+	return &watchState{
+		configChange: attrs.PrinterConfigChangeTime,
+		state:        attrs.PrinterState,
+		reasons:      attrs.PrinterStateReasons,
+	}
+}
+
+// sameState reports if two watchStates have the same printer-state
+// and printer-state-reasons.
+func (ws *watchState) sameState(ws2 *watchState) bool {
+	return reflect.DeepEqual(ws.state, ws2.state) &&
+		reflect.DeepEqual(ws.reasons, ws2.reasons)
+}
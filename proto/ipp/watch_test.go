@@ -0,0 +1,159 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// IPP - Internet Printing Protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Printer configuration and state watcher test
+
+package ipp
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// TestWatch tests Watch
+func TestWatch(t *testing.T) {
+	type printerState struct {
+		config  int    // printer-config-change-time
+		state   int    // printer-state
+		reasons string // printer-state-reasons
+	}
+
+	const (
+		idle       = 3
+		processing = 4
+		stopped    = 5
+	)
+
+	// The initial printer state, then the state, as seen by
+	// the subsequent polls
+	script := []printerState{
+		{1, idle, "none"},
+		{1, processing, "none"},           // Flap...
+		{1, idle, "none"},                 // ...ignored
+		{1, stopped, "media-jam-error"},   // Pending...
+		{1, stopped, "media-jam-error"},   // ...confirmed
+		{2, stopped, "media-jam-error"},   // Config change
+		{2, stopped, "media-jam-error"},   // No change
+		{2, stopped, "media-empty-error"}, // Flap...
+		{2, stopped, "media-jam-error"},   // ...ignored
+	}
+
+	var (
+		lock  sync.Mutex
+		polls int // Count of polls
+		fulls int // Count of full fetches
+		done  = make(chan struct{})
+	)
+
+	mux := NewServeMux(ServerOptions{})
+	mux.Handle(goipp.OpGetPrinterAttributes,
+		func(ctx context.Context, msg *goipp.Message,
+			body io.Reader) (*goipp.Message, io.Reader, error) {
+
+			var rq GetPrinterAttributesRequest
+			err := rq.Decode(msg, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if reflect.DeepEqual(rq.RequestedAttributes, watchAttrs) {
+				polls++
+				if polls == len(script) {
+					close(done)
+				}
+			} else {
+				fulls++
+			}
+
+			s := script[min(polls, len(script)-1)]
+
+			prn := &PrinterAttributes{}
+			prn.PrinterName = optional.New("Test")
+			prn.PrinterConfigChangeTime = optional.New(s.config)
+			prn.PrinterState = optional.New(s.state)
+			prn.PrinterStateReasons = []KwPrinterStateReasons{
+				KwPrinterStateReasons(s.reasons)}
+
+			rsp := &GetPrinterAttributesResponse{
+				ResponseHeader: ResponseHeader{
+					Version:   msg.Version,
+					RequestID: msg.RequestID,
+					Status:    goipp.StatusOk,
+				},
+				Printer: prn,
+			}
+
+			return rsp.Encode(), nil, nil
+		})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	clnt := NewClient(transport.MustParseURL(srv.URL), nil)
+
+	// Run the Watch
+	var changes []printerState
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error)
+
+	go func() {
+		result <- Watch(ctx, clnt, 5*time.Millisecond,
+			func(prn *PrinterAttributes) {
+				if optional.Get(prn.PrinterName) != "Test" {
+					t.Errorf("Watch: full attributes expected")
+				}
+
+				changes = append(changes, printerState{
+					*prn.PrinterConfigChangeTime,
+					*prn.PrinterState,
+					string(prn.PrinterStateReasons[0]),
+				})
+			})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Watch: timeout")
+	}
+
+	cancel()
+	err := <-result
+
+	if err != context.Canceled {
+		t.Errorf("Watch: %v", err)
+	}
+
+	// Check results
+	expected := []printerState{
+		{1, idle, "none"},
+		{1, stopped, "media-jam-error"},
+		{2, stopped, "media-jam-error"},
+	}
+
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Watch: changes mismatch:\n"+
+			"expected: %v\npresent:  %v", expected, changes)
+	}
+
+	if fulls != len(expected) {
+		t.Errorf("Watch: %d full fetches expected, %d present",
+			len(expected), fulls)
+	}
+}
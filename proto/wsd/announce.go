@@ -28,7 +28,9 @@ type Announce struct {
 }
 
 // decodeAnnounce decodes [announce] from the XML tree
-func decodeAnnounce(root xmldoc.Element) (ann Announce, err error) {
+func decodeAnnounce(root xmldoc.Element, opt *DecodeOptions) (
+	ann Announce, err error) {
+
 	defer func() { err = xmldoc.XMLErrWrap(root, err) }()
 
 	// Lookup message elements
@@ -40,9 +42,11 @@ func decodeAnnounce(root xmldoc.Element) (ann Announce, err error) {
 		Name: NsDiscovery + ":" + "XAddrs"}
 	metadataVersion := xmldoc.Lookup{
 		Name: NsDiscovery + ":" + "MetadataVersion", Required: true}
+	scopes := xmldoc.Lookup{
+		Name: NsDiscovery + ":" + "Scopes"}
 
 	missed := root.Lookup(&endpointReference, &types,
-		&xaddrs, &metadataVersion)
+		&xaddrs, &metadataVersion, &scopes)
 
 	if missed != nil {
 		err = xmldoc.XMLErrMissed(missed.Name)
@@ -50,15 +54,19 @@ func decodeAnnounce(root xmldoc.Element) (ann Announce, err error) {
 	}
 
 	// Decode elements
-	ann.EndpointReference, err = DecodeEndpointReference(
-		endpointReference.Elem)
+	err = decodeCheckScopes(scopes, opt)
+
+	if err == nil {
+		ann.EndpointReference, err = DecodeEndpointReference(
+			endpointReference.Elem)
+	}
 
 	if err == nil && types.Found {
-		ann.Types, err = DecodeTypes(types.Elem)
+		ann.Types, err = DecodeTypes(types.Elem, opt)
 	}
 
 	if err == nil && xaddrs.Found {
-		ann.XAddrs, err = DecodeXAddrs(xaddrs.Elem)
+		ann.XAddrs, err = DecodeXAddrs(xaddrs.Elem, opt)
 	}
 
 	if err == nil {
@@ -85,7 +85,7 @@ func TestAnnounce(t *testing.T) {
 				xml.EncodeString(NsMap))
 		}
 
-		ann, err := decodeAnnounce(xml)
+		ann, err := decodeAnnounce(xml, nil)
 		if err != nil {
 			t.Errorf("decodeAnnounce: %s", err)
 			continue
@@ -181,7 +181,7 @@ func TestAnnounceDecodeErrors(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		_, err := decodeAnnounce(test.xml)
+		_, err := decodeAnnounce(test.xml, nil)
 		estr := ""
 		if err != nil {
 			estr = err.Error()
@@ -10,6 +10,8 @@ package wsd
 
 import (
 	"errors"
+	"net/url"
+	"strings"
 
 	"github.com/OpenPrinting/go-mfp/util/uuid"
 	"github.com/OpenPrinting/go-mfp/util/xmldoc"
@@ -49,3 +51,44 @@ func (s AnyURI) UUID() uuid.UUID {
 
 	return uuid.SHA1(uuid.NameSpaceURL, string(s))
 }
+
+// Canonical returns the canonical form of AnyURI, suitable for
+// comparison of URIs that may be spelled differently by different
+// messages or different firmware versions of the same device.
+//
+// UUIDs in any recognized form are converted into the
+// urn:uuid:xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx form. For other URIs,
+// scheme, URN namespace identifier and host are converted to
+// lower case and default HTTP and HTTPS ports are removed.
+func (s AnyURI) Canonical() AnyURI {
+	str := strings.TrimSpace(string(s))
+
+	if u, err := uuid.Parse(str); err == nil {
+		return AnyURI(u.URN())
+	}
+
+	u, err := url.Parse(str)
+	if err != nil || u.Scheme == "" {
+		return AnyURI(str)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	if u.Scheme == "urn" {
+		nid, nss, _ := strings.Cut(u.Opaque, ":")
+		u.Opaque = strings.ToLower(nid) + ":" + nss
+	}
+
+	u.Host = strings.ToLower(u.Host)
+	switch {
+	case u.Scheme == "http" && u.Port() == "80",
+		u.Scheme == "https" && u.Port() == "443":
+		u.Host = strings.TrimSuffix(u.Host, ":"+u.Port())
+	}
+
+	if u.Host != "" && u.Path == "" {
+		u.Path = "/"
+	}
+
+	return AnyURI(u.String())
+}
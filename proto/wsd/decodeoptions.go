@@ -0,0 +1,135 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// WSD core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Message decoding options
+
+package wsd
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// DecodeOptions control decoding of the WSD messages.
+//
+// WS-Discovery messages come as unauthenticated multicast
+// datagrams, so decoder must be prepared for hostile input.
+// DecodeOptions allow to bound sizes of the message elements
+// and to choose between the strict and lenient decoding.
+type DecodeOptions struct {
+	// Strict, if set, requires the message header to contain
+	// all the WS-Addressing and WS-Discovery headers, required
+	// by the specification for the particular message:
+	//   - To, for all messages
+	//   - RelatesTo, for responses (ProbeMatches, ResolveMatches
+	//     and GetResponse)
+	//   - AppSequence, for Hello, Bye, ProbeMatches and
+	//     ResolveMatches.
+	//
+	// Otherwise, only Action and MessageID are required, which
+	// is more tolerant to the buggy devices.
+	Strict bool
+
+	// Limits. Zero value means no limit.
+	MaxTypes      int // Max number of tokens in Types
+	MaxXAddrs     int // Max number of entries in XAddrs
+	MaxScopeBytes int // Max length of Scopes, in bytes
+}
+
+// DefaultDecodeOptions are used when nil *DecodeOptions are
+// passed to the decoding functions.
+var DefaultDecodeOptions = DecodeOptions{
+	Strict:        false,
+	MaxTypes:      32,
+	MaxXAddrs:     32,
+	MaxScopeBytes: 4096,
+}
+
+// getDecodeOptions returns opt, or &DefaultDecodeOptions if opt is nil.
+func getDecodeOptions(opt *DecodeOptions) *DecodeOptions {
+	if opt == nil {
+		return &DefaultDecodeOptions
+	}
+	return opt
+}
+
+// LimitError is returned, when message violates one of the
+// [DecodeOptions] limits.
+type LimitError struct {
+	Name  string // Name of the violating element
+	Size  int    // Actual size (count of entries or bytes)
+	Limit int    // The limit
+}
+
+// Error returns the error string. It implements the error interface.
+//
+// Note, the element name is not included, as error is returned
+// wrapped into the [xmldoc.XMLErr] that already includes the
+// element path.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("size %d exceeds limit %d", e.Size, e.Limit)
+}
+
+// StrictError is returned in the [DecodeOptions.Strict] mode, when
+// message misses the header, required by the specification.
+type StrictError struct {
+	Name string // Name of the missed header
+}
+
+// Error returns the error string. It implements the error interface.
+func (e *StrictError) Error() string {
+	return fmt.Sprintf("%s: missed (required in strict mode)", e.Name)
+}
+
+// decodeCheckLimit returns *LimitError, if size exceeds the limit.
+func decodeCheckLimit(root xmldoc.Element, size, limit int) error {
+	if limit > 0 && size > limit {
+		return &LimitError{Name: root.Name, Size: size, Limit: limit}
+	}
+	return nil
+}
+
+// decodeFields splits the element text into the whitespace-separated
+// fields, like strings.Fields does.
+//
+// The text comes from the network, so fields are counted first,
+// one at a time, and *LimitError is returned as soon as the count
+// exceeds the limit, before the text is split.
+func decodeFields(root xmldoc.Element, limit int) ([]string, error) {
+	if limit > 0 {
+		count := 0
+		inField := false
+		for _, c := range root.Text {
+			space := unicode.IsSpace(c)
+			if !space && !inField {
+				count++
+				err := decodeCheckLimit(root, count, limit)
+				if err != nil {
+					return nil, err
+				}
+			}
+			inField = !space
+		}
+	}
+
+	return strings.Fields(root.Text), nil
+}
+
+// decodeCheckScopes checks the Scopes element size against the limit.
+// Scopes are not decoded, so only the size is checked.
+func decodeCheckScopes(scopes xmldoc.Lookup, opt *DecodeOptions) error {
+	if !scopes.Found {
+		return nil
+	}
+
+	err := decodeCheckLimit(scopes.Elem, len(scopes.Elem.Text),
+		opt.MaxScopeBytes)
+
+	return xmldoc.XMLErrWrap(scopes.Elem, err)
+}
@@ -0,0 +1,409 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// WSD core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Message decoding options test
+
+package wsd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// decodeOptionsTestHeader is the message header template for
+// DecodeOptions tests.
+//
+// Template parameters are: optional To, Action, optional RelatesTo
+// and optional AppSequence headers.
+const decodeOptionsTestHeader = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery" xmlns:devprof="http://schemas.xmlsoap.org/ws/2006/02/devprof">
+<s:Header>
+%s
+<a:Action>%s</a:Action>
+<a:MessageID>urn:uuid:0f5d604c-81ac-4abc-8010-51dbffad55f2</a:MessageID>
+%s
+%s
+</s:Header>
+<s:Body>
+%s
+</s:Body>
+</s:Envelope>
+`
+
+// Header pieces for DecodeOptions tests
+const (
+	decodeOptionsTestTo = `<a:To>urn:schemas-xmlsoap-org:ws:2005:04:discovery</a:To>`
+
+	decodeOptionsTestRelatesTo = `<a:RelatesTo>urn:uuid:193ccfa0-347d-41a1-9285-f500b6b96a15</a:RelatesTo>`
+
+	decodeOptionsTestAppSequence = `<d:AppSequence InstanceId="2" MessageNumber="14"/>`
+)
+
+// decodeOptionsTestHello makes the Hello message with the specified
+// Types, XAddrs and Scopes for DecodeOptions tests.
+func decodeOptionsTestHello(types, xaddrs, scopes string) []byte {
+	body := `<d:Hello>
+<a:EndpointReference>
+<a:Address>urn:uuid:37f86d35-e6ac-4241-964f-1d9ae46fb366</a:Address>
+</a:EndpointReference>
+<d:Types>` + types + `</d:Types>
+<d:Scopes>` + scopes + `</d:Scopes>
+<d:XAddrs>` + xaddrs + `</d:XAddrs>
+<d:MetadataVersion>2</d:MetadataVersion>
+</d:Hello>`
+
+	return []byte(fmt.Sprintf(decodeOptionsTestHeader,
+		decodeOptionsTestTo, ActHello.Encode(),
+		"", decodeOptionsTestAppSequence, body))
+}
+
+// decodeOptionsTestProbe makes the Probe message with the specified
+// Types and Scopes for DecodeOptions tests.
+func decodeOptionsTestProbe(types, scopes string) []byte {
+	body := `<d:Probe>
+<d:Types>` + types + `</d:Types>
+<d:Scopes>` + scopes + `</d:Scopes>
+</d:Probe>`
+
+	return []byte(fmt.Sprintf(decodeOptionsTestHeader,
+		decodeOptionsTestTo, ActProbe.Encode(),
+		"", "", body))
+}
+
+// decodeOptionsTestRepeat returns s, repeated n times, space-separated
+func decodeOptionsTestRepeat(s string, n int) string {
+	return strings.TrimSpace(strings.Repeat(s+" ", n))
+}
+
+// TestDecodeOptionsLimits tests DecodeOptions limits
+func TestDecodeOptionsLimits(t *testing.T) {
+	types := decodeOptionsTestRepeat("devprof:Device", 33)
+	xaddrs := decodeOptionsTestRepeat("http://127.0.0.1/", 33)
+	scopes := strings.Repeat("x", 4097)
+
+	type testData struct {
+		name string         // Test name
+		data []byte         // Message data
+		opt  *DecodeOptions // Decode options
+		estr string         // Expected error, "" if none
+	}
+
+	tests := []testData{
+		{
+			name: "Hello, within limits",
+			data: decodeOptionsTestHello("devprof:Device",
+				"http://127.0.0.1/", "ldap:///ou=engineering"),
+		},
+
+		{
+			name: "Hello, too many Types",
+			data: decodeOptionsTestHello(types,
+				"http://127.0.0.1/", ""),
+			estr: "/s:Envelope/d:Hello/d:Types: size 33 exceeds limit 32",
+		},
+
+		{
+			name: "Hello, too many XAddrs",
+			data: decodeOptionsTestHello("devprof:Device", xaddrs, ""),
+			estr: "/s:Envelope/d:Hello/d:XAddrs: size 33 exceeds limit 32",
+		},
+
+		{
+			name: "Hello, too long Scopes",
+			data: decodeOptionsTestHello("devprof:Device",
+				"http://127.0.0.1/", scopes),
+			estr: "/s:Envelope/d:Hello/d:Scopes: size 4097 exceeds limit 4096",
+		},
+
+		{
+			name: "Probe, too many Types",
+			data: decodeOptionsTestProbe(types, ""),
+			estr: "/s:Envelope/d:Probe/d:Types: size 33 exceeds limit 32",
+		},
+
+		{
+			name: "Probe, too long Scopes",
+			data: decodeOptionsTestProbe("devprof:Device", scopes),
+			estr: "/s:Envelope/d:Probe/d:Scopes: size 4097 exceeds limit 4096",
+		},
+
+		{
+			name: "Hello, custom limits",
+			data: decodeOptionsTestHello(
+				decodeOptionsTestRepeat("devprof:Device", 3),
+				"http://127.0.0.1/", ""),
+			opt:  &DecodeOptions{MaxTypes: 2},
+			estr: "/s:Envelope/d:Hello/d:Types: size 3 exceeds limit 2",
+		},
+
+		{
+			name: "Hello, no limits",
+			data: decodeOptionsTestHello(types, xaddrs, scopes),
+			opt:  &DecodeOptions{},
+		},
+	}
+
+	for _, test := range tests {
+		_, err := DecodeMsg(test.data, test.opt)
+
+		estr := ""
+		if err != nil {
+			estr = err.Error()
+		}
+
+		if estr != test.estr {
+			t.Errorf("%s:\nexpected: %q\npresent:  %q",
+				test.name, test.estr, estr)
+			continue
+		}
+
+		var limitErr *LimitError
+		if err != nil && !errors.As(err, &limitErr) {
+			t.Errorf("%s: *LimitError expected, present %T",
+				test.name, err)
+		}
+	}
+}
+
+// TestDecodeFields tests splitting of the limited element text
+func TestDecodeFields(t *testing.T) {
+	type testData struct {
+		text  string // Element text
+		limit int    // The limit
+		estr  string // Expected error, "" if none
+	}
+
+	tests := []testData{
+		{text: "", limit: 2},
+		{text: " \t\n ", limit: 2},
+		{text: "a b", limit: 2},
+		{text: "  a\u00a0b\n", limit: 2},
+		{text: "a b c", limit: 2, estr: "size 3 exceeds limit 2"},
+		{text: "a b c", limit: 0},
+		{
+			text:  decodeOptionsTestRepeat("x", 100000),
+			limit: 32,
+			estr:  "size 33 exceeds limit 32",
+		},
+	}
+
+	for _, test := range tests {
+		root := xmldoc.WithText("d:Types", test.text)
+		fields, err := decodeFields(root, test.limit)
+
+		estr := ""
+		if err != nil {
+			estr = err.Error()
+		}
+
+		if estr != test.estr {
+			t.Errorf("%.32q: error expected %q, present %q",
+				test.text, test.estr, estr)
+			continue
+		}
+
+		if err == nil {
+			expected := strings.Fields(test.text)
+			if !reflect.DeepEqual(fields, expected) {
+				t.Errorf("%.32q: expected %q, present %q",
+					test.text, expected, fields)
+			}
+		}
+	}
+
+	// Too long text must be rejected without splitting
+	root := xmldoc.WithText("d:Types",
+		decodeOptionsTestRepeat("x", 100000))
+	allocs := testing.AllocsPerRun(10, func() {
+		decodeFields(root, 32)
+	})
+
+	if allocs > 1 {
+		t.Errorf("too many allocations: %v", allocs)
+	}
+}
+
+// TestDecodeOptionsStrict tests DecodeOptions strict mode
+func TestDecodeOptionsStrict(t *testing.T) {
+	bodyHello := `<d:Hello>
+<a:EndpointReference>
+<a:Address>urn:uuid:37f86d35-e6ac-4241-964f-1d9ae46fb366</a:Address>
+</a:EndpointReference>
+<d:MetadataVersion>2</d:MetadataVersion>
+</d:Hello>`
+
+	bodyBye := `<d:Bye>
+<a:EndpointReference>
+<a:Address>urn:uuid:37f86d35-e6ac-4241-964f-1d9ae46fb366</a:Address>
+</a:EndpointReference>
+</d:Bye>`
+
+	bodyProbe := `<d:Probe><d:Types>devprof:Device</d:Types></d:Probe>`
+
+	bodyProbeMatches := `<d:ProbeMatches><d:ProbeMatch>
+<a:EndpointReference>
+<a:Address>urn:uuid:37f86d35-e6ac-4241-964f-1d9ae46fb366</a:Address>
+</a:EndpointReference>
+<d:MetadataVersion>2</d:MetadataVersion>
+</d:ProbeMatch></d:ProbeMatches>`
+
+	type testData struct {
+		name      string // Test name
+		to        string // To header or ""
+		action    Action // Action
+		relatesTo string // RelatesTo header or ""
+		appSeq    string // AppSequence header or ""
+		body      string // Message body
+		estr      string // Expected error in strict mode, "" if none
+	}
+
+	tests := []testData{
+		{
+			name:   "Hello, complete",
+			to:     decodeOptionsTestTo,
+			action: ActHello,
+			appSeq: decodeOptionsTestAppSequence,
+			body:   bodyHello,
+		},
+
+		{
+			name:   "Hello, To missed",
+			action: ActHello,
+			appSeq: decodeOptionsTestAppSequence,
+			body:   bodyHello,
+			estr:   "/s:Envelope/s:Header: a:To: missed (required in strict mode)",
+		},
+
+		{
+			name:   "Hello, AppSequence missed",
+			to:     decodeOptionsTestTo,
+			action: ActHello,
+			body:   bodyHello,
+			estr:   "/s:Envelope/s:Header: d:AppSequence: missed (required in strict mode)",
+		},
+
+		{
+			name:   "Bye, AppSequence missed",
+			to:     decodeOptionsTestTo,
+			action: ActBye,
+			body:   bodyBye,
+			estr:   "/s:Envelope/s:Header: d:AppSequence: missed (required in strict mode)",
+		},
+
+		{
+			name:   "Probe, complete",
+			to:     decodeOptionsTestTo,
+			action: ActProbe,
+			body:   bodyProbe,
+		},
+
+		{
+			name:      "ProbeMatches, complete",
+			to:        decodeOptionsTestTo,
+			action:    ActProbeMatches,
+			relatesTo: decodeOptionsTestRelatesTo,
+			appSeq:    decodeOptionsTestAppSequence,
+			body:      bodyProbeMatches,
+		},
+
+		{
+			name:   "ProbeMatches, RelatesTo missed",
+			to:     decodeOptionsTestTo,
+			action: ActProbeMatches,
+			appSeq: decodeOptionsTestAppSequence,
+			body:   bodyProbeMatches,
+			estr:   "/s:Envelope/s:Header: a:RelatesTo: missed (required in strict mode)",
+		},
+
+		{
+			name:      "ProbeMatches, AppSequence missed",
+			to:        decodeOptionsTestTo,
+			action:    ActProbeMatches,
+			relatesTo: decodeOptionsTestRelatesTo,
+			body:      bodyProbeMatches,
+			estr:      "/s:Envelope/s:Header: d:AppSequence: missed (required in strict mode)",
+		},
+	}
+
+	for _, test := range tests {
+		data := []byte(fmt.Sprintf(decodeOptionsTestHeader,
+			test.to, test.action.Encode(),
+			test.relatesTo, test.appSeq, test.body))
+
+		// Lenient mode must accept all messages
+		_, err := DecodeMsg(data, nil)
+		if err != nil {
+			t.Errorf("%s: lenient mode: %s", test.name, err)
+			continue
+		}
+
+		// Check strict mode
+		_, err = DecodeMsg(data, &DecodeOptions{Strict: true})
+
+		estr := ""
+		if err != nil {
+			estr = err.Error()
+		}
+
+		if estr != test.estr {
+			t.Errorf("%s: strict mode:\nexpected: %q\npresent:  %q",
+				test.name, test.estr, estr)
+			continue
+		}
+
+		var strictErr *StrictError
+		if err != nil && !errors.As(err, &strictErr) {
+			t.Errorf("%s: *StrictError expected, present %T",
+				test.name, err)
+		}
+	}
+}
+
+// FuzzDecodeMsg fuzzes DecodeMsg with default options
+func FuzzDecodeMsg(f *testing.F) {
+	f.Add(decodeOptionsTestHello("devprof:Device",
+		"http://127.0.0.1/", "ldap:///ou=engineering"))
+	f.Add(decodeOptionsTestHello(
+		decodeOptionsTestRepeat("devprof:Device", 1000),
+		"http://127.0.0.1/", ""))
+	f.Add(decodeOptionsTestHello("devprof:Device",
+		decodeOptionsTestRepeat("http://127.0.0.1/", 1000), ""))
+	f.Add(decodeOptionsTestHello("devprof:Device",
+		"http://127.0.0.1/", strings.Repeat("x", 65536)))
+	f.Add(decodeOptionsTestProbe(
+		decodeOptionsTestRepeat("devprof:Device", 1000),
+		strings.Repeat("x", 65536)))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := DecodeMsg(data, nil)
+		if err != nil {
+			return
+		}
+
+		// Bounds must be respected by the successfully
+		// decoded messages
+		body, ok := msg.Body.(AnnouncesBody)
+		if !ok {
+			return
+		}
+
+		for _, ann := range body.Announces() {
+			if len(ann.Types) > DefaultDecodeOptions.MaxTypes {
+				t.Errorf("Types: %d entries decoded",
+					len(ann.Types))
+			}
+			if len(ann.XAddrs) > DefaultDecodeOptions.MaxXAddrs {
+				t.Errorf("XAddrs: %d entries decoded",
+					len(ann.XAddrs))
+			}
+		}
+	})
+}
@@ -24,9 +24,15 @@ type Header struct {
 }
 
 // DecodeHeader decodes message header [Header] from the XML tree
-func DecodeHeader(root xmldoc.Element) (hdr Header, err error) {
+//
+// If opt is nil, [DefaultDecodeOptions] will be used.
+func DecodeHeader(root xmldoc.Element, opt *DecodeOptions) (
+	hdr Header, err error) {
+
 	defer func() { err = xmldoc.XMLErrWrap(root, err) }()
 
+	opt = getDecodeOptions(opt)
+
 	// Lookup header elements
 	action := xmldoc.Lookup{Name: NsAddressing + ":Action", Required: true}
 	messageID := xmldoc.Lookup{Name: NsAddressing + ":MessageID", Required: true}
@@ -79,9 +85,39 @@ func DecodeHeader(root xmldoc.Element) (hdr Header, err error) {
 		}
 	}
 
+	if err == nil && opt.Strict {
+		err = hdr.strictCheck()
+	}
+
 	return
 }
 
+// strictCheck checks that header contains all headers, required
+// by the specification for the particular message.
+//
+// It returns *StrictError, if some required header is missed.
+func (hdr Header) strictCheck() error {
+	if hdr.To == nil {
+		return &StrictError{Name: NsAddressing + ":To"}
+	}
+
+	switch hdr.Action {
+	case ActProbeMatches, ActResolveMatches, ActGetResponse:
+		if hdr.RelatesTo == nil {
+			return &StrictError{Name: NsAddressing + ":RelatesTo"}
+		}
+	}
+
+	switch hdr.Action {
+	case ActHello, ActBye, ActProbeMatches, ActResolveMatches:
+		if hdr.AppSequence == nil {
+			return &StrictError{Name: NsDiscovery + ":AppSequence"}
+		}
+	}
+
+	return nil
+}
+
 // ToXML generates XML tree for the message header
 func (hdr Header) ToXML() xmldoc.Element {
 	elm := xmldoc.Element{
@@ -109,7 +109,7 @@ func TestHeader(t *testing.T) {
 				xml.EncodeString(NsMap))
 		}
 
-		hdr, err := DecodeHeader(xml)
+		hdr, err := DecodeHeader(xml, nil)
 		if err != nil {
 			t.Errorf("DecodeHeader: %s", err)
 			continue
@@ -170,7 +170,7 @@ func TestHeaderErrors(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		_, err := DecodeHeader(test.xml)
+		_, err := DecodeHeader(test.xml, nil)
 		estr := ""
 		if err != nil {
 			estr = err.Error()
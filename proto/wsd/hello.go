@@ -22,8 +22,12 @@ type Hello struct {
 }
 
 // DecodeHello decodes [Hello] from the XML tree
-func DecodeHello(root xmldoc.Element) (hello Hello, err error) {
-	ann, err := decodeAnnounce(root)
+//
+// If opt is nil, [DefaultDecodeOptions] will be used.
+func DecodeHello(root xmldoc.Element, opt *DecodeOptions) (
+	hello Hello, err error) {
+
+	ann, err := decodeAnnounce(root, getDecodeOptions(opt))
 	if err == nil {
 		hello = Hello(ann)
 	}
@@ -84,7 +84,7 @@ func TestHello(t *testing.T) {
 				xml.EncodeString(NsMap))
 		}
 
-		hello, err := DecodeHello(xml)
+		hello, err := DecodeHello(xml, nil)
 		if err != nil {
 			t.Errorf("DecodeHello: %s", err)
 			continue
@@ -167,7 +167,7 @@ func TestHelloDecodeErrors(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		_, err := DecodeHello(test.xml)
+		_, err := DecodeHello(test.xml, nil)
 		estr := ""
 		if err != nil {
 			estr = err.Error()
@@ -0,0 +1,258 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// WSD core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Announcement of the hosted device
+
+package wsd
+
+import (
+	"context"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/OpenPrinting/go-mfp/internal/netstate"
+)
+
+// BuildXAddrs builds [XAddrs] of the hosted device (i.e., the virtual
+// device or the proxy), one URL per advertisable local address.
+//
+// Addresses of loopback interfaces, as well as loopback, unspecified
+// and multicast addresses are not advertisable and skipped. Duplicates
+// are removed. IPv4 URLs come first.
+//
+// If tls is true, https URLs are generated, otherwise http URLs.
+// Port is omitted, if it is zero or default for the scheme.
+//
+// Link-local IPv6 addresses are included in the zone-free form
+// (i.e., http://[fe80::1]/path, not http://[fe80::1%eth0]/path), as
+// real devices do: zone is meaningful only for the local host, and
+// clients on the same link resolve these addresses via their own
+// interface zone.
+func BuildXAddrs(addrs []netstate.Addr, port int, path string,
+	tls bool) XAddrs {
+
+	scheme, dfltPort := "http", 80
+	if tls {
+		scheme, dfltPort = "https", 443
+	}
+
+	if path == "" || path[0] != '/' {
+		path = "/" + path
+	}
+
+	// Select advertisable addresses
+	ips := make([]netip.Addr, 0, len(addrs))
+	for _, addr := range addrs {
+		if addr.Interface().Flags().All(netstate.NetIfLoopback) {
+			continue
+		}
+
+		ip := addr.Prefix.Addr().WithZone("")
+		if ip.IsLoopback() || ip.IsUnspecified() || ip.IsMulticast() {
+			continue
+		}
+
+		ips = append(ips, ip)
+	}
+
+	slices.SortFunc(ips, func(ip1, ip2 netip.Addr) int {
+		switch {
+		case ip1.Is4() && !ip2.Is4():
+			return -1
+		case !ip1.Is4() && ip2.Is4():
+			return 1
+		}
+		return ip1.Compare(ip2)
+	})
+
+	ips = slices.Compact(ips)
+
+	// Build URLs
+	xaddrs := make(XAddrs, 0, len(ips))
+	for _, ip := range ips {
+		host := ip.String()
+		if ip.Is6() {
+			host = "[" + host + "]"
+		}
+
+		if port != 0 && port != dfltPort {
+			host += ":" + strconv.Itoa(port)
+		}
+
+		u := url.URL{Scheme: scheme, Host: host, Path: path}
+		xaddrs = append(xaddrs, u.String())
+	}
+
+	return xaddrs
+}
+
+// Host maintains the WS-Discovery announcement of the hosted device
+// (i.e., the virtual device or the proxy) and keeps its XAddrs in
+// sync with the local addresses.
+//
+// When XAddrs changes, Host increments the MetadataVersion and calls
+// the hello callback, which is expected to multicast the [Hello]
+// message.
+//
+// Host answers the received [Probe] messages, paced by the
+// [ResponseScheduler] (see [Host.HandleProbe]).
+//
+// Host is safe for concurrent use.
+type Host struct {
+	port     int                        // Server port
+	path     string                     // Server path
+	tls      bool                       // Use https URLs
+	hello    func(Hello)                // Hello callback
+	announce Announce                   // Current announcement
+	addrs    map[netstate.Addr]struct{} // Known primary addresses
+	lock     sync.Mutex                 // Access lock
+}
+
+// NewHost creates a new [Host].
+//
+// The ann parameter specifies the initial announcement; its XAddrs
+// are ignored and built from the local addresses (see [BuildXAddrs])
+// with the port, path and tls parameters.
+//
+// The hello callback is called without Host lock held.
+func NewHost(ann Announce, port int, path string, tls bool,
+	hello func(Hello)) *Host {
+
+	ann.XAddrs = XAddrs{}
+
+	return &Host{
+		port:     port,
+		path:     path,
+		tls:      tls,
+		hello:    hello,
+		announce: ann,
+		addrs:    make(map[netstate.Addr]struct{}),
+	}
+}
+
+// Announce returns the current announcement.
+func (host *Host) Announce() Announce {
+	host.lock.Lock()
+	defer host.lock.Unlock()
+
+	ann := host.announce
+	ann.XAddrs = slices.Clone(ann.XAddrs)
+	return ann
+}
+
+// Run tracks changes of the local addresses via the
+// [netstate.Notifier] and updates the announcement.
+//
+// It returns when ctx is canceled.
+func (host *Host) Run(ctx context.Context, notifier *netstate.Notifier) {
+	for {
+		evnt, err := notifier.Get(ctx)
+		if err != nil {
+			return
+		}
+
+		host.handleEvent(evnt)
+	}
+}
+
+// SetAddrs replaces the set of local addresses and updates
+// the announcement. It reports whether XAddrs has changed.
+func (host *Host) SetAddrs(addrs []netstate.Addr) bool {
+	host.lock.Lock()
+	clear(host.addrs)
+	for _, addr := range addrs {
+		host.addrs[addr] = struct{}{}
+	}
+	host.lock.Unlock()
+
+	return host.refresh()
+}
+
+// HandleProbe handles the received [Probe] message. If the hosted
+// device matches the Probe, the [ProbeMatches] response is scheduled
+// with the sched (see [ResponseScheduler.Schedule]), and the reply
+// callback is called when it is time to send it.
+//
+// Device matches the Probe, if it implements all the Types the
+// Probe searches for. Probe with empty Types matches any device.
+//
+// The Probe is considered received via multicast, if msg.To is
+// the multicast address.
+//
+// HandleProbe reports whether the response has been scheduled.
+func (host *Host) HandleProbe(sched *ResponseScheduler, msg Msg,
+	reply func(ProbeMatches)) bool {
+
+	probe, ok := msg.Body.(Probe)
+	if !ok {
+		return false
+	}
+
+	ann := host.Announce()
+	for _, t := range probe.Types {
+		if !ann.Types.Contains(t) {
+			return false
+		}
+	}
+
+	pm := ProbeMatches{ProbeMatch: []ProbeMatch{ProbeMatch(ann)}}
+	multicast := msg.To.Addr().IsMulticast()
+
+	return sched.Schedule(msg.Header.MessageID, multicast, func() {
+		reply(pm)
+	})
+}
+
+// handleEvent handles the netstate.Event.
+func (host *Host) handleEvent(evnt netstate.Event) {
+	host.lock.Lock()
+	switch evnt := evnt.(type) {
+	case netstate.EventAddPrimaryAddress:
+		host.addrs[evnt.Addr] = struct{}{}
+	case netstate.EventDelPrimaryAddress:
+		delete(host.addrs, evnt.Addr)
+	default:
+		host.lock.Unlock()
+		return
+	}
+	host.lock.Unlock()
+
+	host.refresh()
+}
+
+// refresh rebuilds XAddrs from the known addresses. If XAddrs
+// has changed, it bumps MetadataVersion and calls the hello
+// callback.
+func (host *Host) refresh() bool {
+	host.lock.Lock()
+
+	addrs := make([]netstate.Addr, 0, len(host.addrs))
+	for addr := range host.addrs {
+		addrs = append(addrs, addr)
+	}
+
+	xaddrs := BuildXAddrs(addrs, host.port, host.path, host.tls)
+	if slices.Equal(xaddrs, host.announce.XAddrs) {
+		host.lock.Unlock()
+		return false
+	}
+
+	host.announce.XAddrs = xaddrs
+	host.announce.MetadataVersion++
+
+	hello := Hello(host.announce)
+	hello.XAddrs = slices.Clone(xaddrs)
+	host.lock.Unlock()
+
+	if host.hello != nil {
+		host.hello(hello)
+	}
+
+	return true
+}
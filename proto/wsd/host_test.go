@@ -0,0 +1,311 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// WSD core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Announcement of the hosted device test
+
+package wsd
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/internal/netstate"
+)
+
+// testHostAddr makes netstate.Addr from CIDR string
+func testHostAddr(cidr string, nif netstate.NetIf) netstate.Addr {
+	ip, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+
+	ipnet.IP = ip
+	return netstate.AddrFromIPNet(*ipnet, nif)
+}
+
+// Test interfaces
+var (
+	testHostLo  = netstate.MakeNetIf(1, "lo", netstate.NetIfLoopback)
+	testHostEth = netstate.MakeNetIf(2, "eth0",
+		netstate.NetIfBroadcast|netstate.NetIfMulticast)
+)
+
+// TestBuildXAddrs tests BuildXAddrs
+func TestBuildXAddrs(t *testing.T) {
+	type testData struct {
+		name     string
+		addrs    []netstate.Addr
+		port     int
+		path     string
+		tls      bool
+		expected XAddrs
+	}
+
+	mixed := []netstate.Addr{
+		testHostAddr("fe80::1234/64", testHostEth),
+		testHostAddr("2001:db8::10/64", testHostEth),
+		testHostAddr("192.168.1.10/24", testHostEth),
+		testHostAddr("10.0.0.5/8", testHostEth),
+		testHostAddr("127.0.0.1/8", testHostLo),
+		testHostAddr("::1/128", testHostLo),
+	}
+
+	tests := []testData{
+		{
+			name:     "empty",
+			port:     80,
+			path:     "/wsd",
+			expected: XAddrs{},
+		},
+
+		{
+			name:  "IPv4+IPv6, default port",
+			addrs: mixed,
+			port:  80,
+			path:  "/wsd",
+			expected: XAddrs{
+				"http://10.0.0.5/wsd",
+				"http://192.168.1.10/wsd",
+				"http://[2001:db8::10]/wsd",
+				"http://[fe80::1234]/wsd",
+			},
+		},
+
+		{
+			name:  "IPv4+IPv6, custom port, relative path",
+			addrs: mixed,
+			port:  8080,
+			path:  "wsd",
+			expected: XAddrs{
+				"http://10.0.0.5:8080/wsd",
+				"http://192.168.1.10:8080/wsd",
+				"http://[2001:db8::10]:8080/wsd",
+				"http://[fe80::1234]:8080/wsd",
+			},
+		},
+
+		{
+			name:  "TLS, default port",
+			addrs: mixed[1:3],
+			port:  443,
+			path:  "/",
+			tls:   true,
+			expected: XAddrs{
+				"https://192.168.1.10/",
+				"https://[2001:db8::10]/",
+			},
+		},
+
+		{
+			name:  "TLS, port 80 is not default",
+			addrs: mixed[2:3],
+			port:  80,
+			path:  "/",
+			tls:   true,
+			expected: XAddrs{
+				"https://192.168.1.10:80/",
+			},
+		},
+
+		{
+			name: "duplicates",
+			addrs: []netstate.Addr{
+				testHostAddr("192.168.1.10/24", testHostEth),
+				testHostAddr("192.168.1.10/16", testHostEth),
+			},
+			expected: XAddrs{
+				"http://192.168.1.10/",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		xaddrs := BuildXAddrs(test.addrs, test.port, test.path, test.tls)
+		if !reflect.DeepEqual(xaddrs, test.expected) {
+			t.Errorf("%s:\nexpected: %q\npresent:  %q",
+				test.name, test.expected, xaddrs)
+		}
+	}
+}
+
+// TestHost tests Host
+func TestHost(t *testing.T) {
+	var hellos []Hello
+
+	ann := Announce{
+		EndpointReference: EndpointReference{
+			Address: "urn:uuid:1fccdddc-380e-41df-8d38-b5df20bc47ef",
+		},
+		Types:           Types{Device},
+		XAddrs:          XAddrs{"http://ignored/"},
+		MetadataVersion: 5,
+	}
+
+	host := NewHost(ann, 8080, "/wsd", false, func(hello Hello) {
+		hellos = append(hellos, hello)
+	})
+
+	addr4 := testHostAddr("192.168.1.10/24", testHostEth)
+	addr6 := testHostAddr("fe80::1234/64", testHostEth)
+
+	// Initial addresses
+	if !host.SetAddrs([]netstate.Addr{addr4}) {
+		t.Errorf("SetAddrs: change not reported")
+	}
+
+	expected := XAddrs{"http://192.168.1.10:8080/wsd"}
+	if len(hellos) != 1 || hellos[0].MetadataVersion != 6 ||
+		!reflect.DeepEqual(hellos[0].XAddrs, expected) {
+		t.Errorf("SetAddrs: unexpected Hello %+v", hellos)
+	}
+
+	// Address added
+	host.handleEvent(netstate.EventAddPrimaryAddress{Addr: addr6})
+
+	expected = XAddrs{
+		"http://192.168.1.10:8080/wsd",
+		"http://[fe80::1234]:8080/wsd",
+	}
+
+	if len(hellos) != 2 || hellos[1].MetadataVersion != 7 ||
+		!reflect.DeepEqual(hellos[1].XAddrs, expected) {
+		t.Errorf("add: unexpected Hello %+v", hellos)
+	}
+
+	// Non-primary address events and loopback addresses don't
+	// affect XAddrs, so Hello is not sent.
+	host.handleEvent(netstate.EventAddAddress{Addr: addr6})
+	host.handleEvent(netstate.EventAddPrimaryAddress{
+		Addr: testHostAddr("127.0.0.1/8", testHostLo)})
+
+	if len(hellos) != 2 {
+		t.Errorf("no changes: unexpected Hello %+v", hellos[2:])
+	}
+
+	// Address removed
+	host.handleEvent(netstate.EventDelPrimaryAddress{Addr: addr4})
+
+	expected = XAddrs{"http://[fe80::1234]:8080/wsd"}
+	if len(hellos) != 3 || hellos[2].MetadataVersion != 8 ||
+		!reflect.DeepEqual(hellos[2].XAddrs, expected) {
+		t.Errorf("del: unexpected Hello %+v", hellos)
+	}
+
+	// Announce returns the current state
+	ann = host.Announce()
+	if ann.MetadataVersion != 8 || !reflect.DeepEqual(ann.XAddrs, expected) {
+		t.Errorf("Announce: %+v", ann)
+	}
+}
+
+// TestHostProbe tests answering Probe by the Host
+func TestHostProbe(t *testing.T) {
+	ann := Announce{
+		EndpointReference: EndpointReference{
+			Address: "urn:uuid:1fccdddc-380e-41df-8d38-b5df20bc47ef",
+		},
+		Types:           Types{Device, PrinterServiceType},
+		MetadataVersion: 5,
+	}
+
+	host := NewHost(ann, 8080, "/wsd", false, nil)
+	host.SetAddrs([]netstate.Addr{
+		testHostAddr("192.168.1.10/24", testHostEth),
+	})
+
+	clock := &testResponseClock{now: time.Unix(1000000, 0)}
+	sched := NewResponseScheduler(context.Background(),
+		NewResponseLimiter(100, 100))
+	clock.install(sched)
+	defer sched.Close()
+
+	var replies []ProbeMatches
+	var lock sync.Mutex
+	reply := func(pm ProbeMatches) {
+		lock.Lock()
+		replies = append(replies, pm)
+		lock.Unlock()
+	}
+
+	multicast := netip.MustParseAddrPort("239.255.255.250:3702")
+	directed := netip.MustParseAddrPort("192.168.1.10:3702")
+
+	probe := func(id AnyURI, to netip.AddrPort, types ...Type) Msg {
+		return Msg{
+			To: to,
+			Header: Header{
+				Action:    ActProbe,
+				MessageID: id,
+			},
+			Body: Probe{Types: types},
+		}
+	}
+
+	// Matching multicast Probe is answered after random delay
+	msg := probe("urn:uuid:1", multicast, PrinterServiceType)
+	if !host.HandleProbe(sched, msg, reply) {
+		t.Errorf("multicast Probe: response not scheduled")
+	}
+
+	sched.done.Wait()
+
+	expected := []ProbeMatches{{ProbeMatch: []ProbeMatch{{
+		EndpointReference: ann.EndpointReference,
+		Types:             ann.Types,
+		XAddrs:            XAddrs{"http://192.168.1.10:8080/wsd"},
+		MetadataVersion:   6,
+	}}}}
+
+	if !reflect.DeepEqual(replies, expected) {
+		t.Errorf("multicast Probe:\n"+
+			"expected: %+v\npresent:  %+v", expected, replies)
+	}
+
+	if len(clock.sleeps) != 1 {
+		t.Errorf("multicast Probe: expected 1 delay, present %v",
+			clock.sleeps)
+	}
+
+	// Repeated Probe is not answered again
+	if host.HandleProbe(sched, msg, reply) {
+		t.Errorf("repeated Probe: response scheduled")
+	}
+
+	// Probe for the missed type is not answered
+	msg = probe("urn:uuid:2", multicast, Device, ScannerServiceType)
+	if host.HandleProbe(sched, msg, reply) {
+		t.Errorf("non-matching Probe: response scheduled")
+	}
+
+	// Directed Probe without types is answered without delay
+	clock.sleeps = nil
+	msg = probe("urn:uuid:3", directed)
+	if !host.HandleProbe(sched, msg, reply) {
+		t.Errorf("directed Probe: response not scheduled")
+	}
+
+	sched.done.Wait()
+
+	if len(replies) != 2 {
+		t.Errorf("directed Probe: expected 2 replies, present %d",
+			len(replies))
+	}
+
+	if len(clock.sleeps) != 0 {
+		t.Errorf("directed Probe: response delayed: %v", clock.sleeps)
+	}
+
+	// Other messages are ignored
+	msg = Msg{Header: Header{MessageID: "urn:uuid:4"}, Body: Hello(ann)}
+	if host.HandleProbe(sched, msg, reply) {
+		t.Errorf("Hello: response scheduled")
+	}
+}
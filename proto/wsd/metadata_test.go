@@ -84,7 +84,7 @@ func TestMetagata(t *testing.T) {
 // TestKyoceraECOSYSM2040dnMetadata tests decoding metadate from
 // the real device.
 func TestKyoceraECOSYSM2040dnMetadata(t *testing.T) {
-	msg, err := DecodeMsg([]byte(sampleKyoceraECOSYSM2040dnMetadata))
+	msg, err := DecodeMsg([]byte(sampleKyoceraECOSYSM2040dnMetadata), nil)
 	if err != nil {
 		t.Errorf("%s", err)
 		return
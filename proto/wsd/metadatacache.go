@@ -0,0 +1,246 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// WSD core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Metadata cache
+
+package wsd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// MetadataCache caches device [Metadata], keyed by the device
+// EndpointReference address.
+//
+// Devices announce their MetadataVersion in Hello, ProbeMatches and
+// ResolveMatches messages, and this version is incremented when
+// metadata changes. So the cached copy remains valid as long as
+// announced version matches the version of the cached entry,
+// and the repeated Get requests can be avoided.
+//
+// MetadataCache is safe for concurrent use.
+type MetadataCache struct {
+	entries map[AnyURI]metadataCacheEnt // Cached entries
+	lock    sync.Mutex                  // Access lock
+}
+
+// metadataCacheEnt is the MetadataCache entry
+type metadataCacheEnt struct {
+	ver  uint64   // MetadataVersion
+	meta Metadata // Cached Metadata
+}
+
+// NewMetadataCache creates a new, empty, [MetadataCache].
+func NewMetadataCache() *MetadataCache {
+	return &MetadataCache{
+		entries: make(map[AnyURI]metadataCacheEnt),
+	}
+}
+
+// Lookup returns the cached [Metadata] of the device, identified
+// by its address, if cache contains entry of the same version.
+func (mc *MetadataCache) Lookup(addr AnyURI, ver uint64) (Metadata, bool) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	ent, found := mc.entries[addr]
+	if !found || ent.ver != ver {
+		return Metadata{}, false
+	}
+
+	return ent.meta, true
+}
+
+// Put adds [Metadata] of the specified version into the cache,
+// replacing the previously cached entry, if any.
+func (mc *MetadataCache) Put(addr AnyURI, ver uint64, meta Metadata) {
+	mc.lock.Lock()
+	mc.entries[addr] = metadataCacheEnt{ver, meta}
+	mc.lock.Unlock()
+}
+
+// Update is like [MetadataCache.Put], but also compares the new
+// [Metadata] with the previously cached entry of any version and
+// returns the difference.
+//
+// If there was no previously cached entry, the second returned
+// value is false.
+func (mc *MetadataCache) Update(addr AnyURI, ver uint64,
+	meta Metadata) (MetadataDiff, bool) {
+
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+
+	prev, found := mc.entries[addr]
+	mc.entries[addr] = metadataCacheEnt{ver, meta}
+
+	if !found {
+		return MetadataDiff{}, false
+	}
+
+	return DiffMetadata(prev.meta, meta), true
+}
+
+// Evict removes the device entry from the cache.
+//
+// It should be called when device sends the [Bye] message.
+func (mc *MetadataCache) Evict(addr AnyURI) {
+	mc.lock.Lock()
+	delete(mc.entries, addr)
+	mc.lock.Unlock()
+}
+
+// Len returns count of entries in the cache.
+func (mc *MetadataCache) Len() int {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	return len(mc.entries)
+}
+
+// GetCached returns the cached [Metadata], if announced version
+// matches the cached entry. Otherwise, it calls the get callback,
+// which is expected to perform the actual [Get] request, and
+// caches its result on success.
+func (mc *MetadataCache) GetCached(addr AnyURI, ver uint64,
+	get func() (Metadata, error)) (Metadata, error) {
+
+	if meta, found := mc.Lookup(addr, ver); found {
+		return meta, nil
+	}
+
+	meta, err := get()
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	mc.Put(addr, ver, meta)
+	return meta, nil
+}
+
+// Save writes the cache content into the [io.Writer] as XML.
+func (mc *MetadataCache) Save(w io.Writer) error {
+	ns := NsMap.Clone()
+	root := xmldoc.Element{Name: "MetadataCache"}
+
+	mc.lock.Lock()
+	for addr, ent := range mc.entries {
+		ent.meta.MarkUsedNamespace(ns)
+
+		elm := xmldoc.WithChildren("Entry",
+			xmldoc.WithText(NsAddressing+":Address",
+				string(addr)),
+			xmldoc.WithText(NsDiscovery+":MetadataVersion",
+				strconv.FormatUint(ent.ver, 10)),
+			ent.meta.ToXML(),
+		)
+
+		root.Children = append(root.Children, elm)
+	}
+	mc.lock.Unlock()
+
+	return root.EncodeIndent(w, ns, "  ")
+}
+
+// Load reads the cache content, previously written by
+// [MetadataCache.Save], from the [io.Reader]. Loaded entries
+// are merged with the existent content of the cache.
+func (mc *MetadataCache) Load(r io.Reader) error {
+	root, err := xmldoc.Decode(NsMap, r)
+	if err != nil {
+		return err
+	}
+
+	if root.Name != "MetadataCache" {
+		return xmldoc.XMLErrNew(root, "unexpected root element")
+	}
+
+	entries := make(map[AnyURI]metadataCacheEnt)
+	for _, elm := range root.Children {
+		if elm.Name != "Entry" {
+			continue
+		}
+
+		addr := xmldoc.Lookup{
+			Name: NsAddressing + ":Address", Required: true}
+		ver := xmldoc.Lookup{
+			Name: NsDiscovery + ":MetadataVersion", Required: true}
+		meta := xmldoc.Lookup{
+			Name: NsMex + ":Metadata", Required: true}
+
+		if missed := elm.Lookup(&addr, &ver, &meta); missed != nil {
+			err = xmldoc.XMLErrMissed(missed.Name)
+			return xmldoc.XMLErrWrap(elm, err)
+		}
+
+		var ent metadataCacheEnt
+		ent.ver, err = decodeUint64(ver.Elem)
+		if err == nil {
+			ent.meta, err = DecodeMetadata(meta.Elem)
+		}
+
+		if err != nil {
+			return xmldoc.XMLErrWrap(elm, err)
+		}
+
+		entries[AnyURI(addr.Elem.Text)] = ent
+	}
+
+	mc.lock.Lock()
+	for addr, ent := range entries {
+		mc.entries[addr] = ent
+	}
+	mc.lock.Unlock()
+
+	return nil
+}
+
+// SaveFile saves the cache content into the file. File is written
+// atomically, via the temporary file in the same directory.
+func (mc *MetadataCache) SaveFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path),
+		filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	err = mc.Save(tmp)
+	err2 := tmp.Close()
+	if err == nil {
+		err = err2
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+// LoadFile loads the cache content from the file, previously
+// written by the [MetadataCache.SaveFile]. Missed file is not
+// considered an error.
+func (mc *MetadataCache) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	return mc.Load(file)
+}
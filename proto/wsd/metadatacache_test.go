@@ -0,0 +1,188 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// WSD core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Metadata cache test
+
+package wsd
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testMetadataCacheMeta returns Metadata for MetadataCache tests
+func testMetadataCacheMeta(name string) Metadata {
+	return Metadata{
+		ThisDevice: ThisDeviceMetadata{
+			FriendlyName:    LocalizedStringList{{String: name}},
+			FirmwareVersion: "0.0.1",
+			SerialNumber:    "FP-8322017",
+		},
+		ThisModel: ThisModelMetadata{
+			Manufacturer: LocalizedStringList{{String: "I.Fyodorov"}},
+			ModelName:    LocalizedStringList{{String: name}},
+		},
+		Relationship: Relationship{
+			Hosted: []ServiceMetadata{
+				{
+					EndpointReference: []EndpointReference{
+						{"http://127.0.0.1/print"},
+					},
+					Types:     []Type{PrinterServiceType},
+					ServiceID: "uri:b827bd97-925c-4502-a7db-4918a0abfc11",
+				},
+			},
+		},
+	}
+}
+
+// TestMetadataCache tests MetadataCache lookups, refresh and eviction
+func TestMetadataCache(t *testing.T) {
+	const addr = AnyURI("urn:uuid:1b6d1e2a-9a2d-4c43-9b8e-5b7f7f3e9c01")
+
+	mc := NewMetadataCache()
+	calls := 0
+	meta := testMetadataCacheMeta("FP-0001")
+	get := func() (Metadata, error) {
+		calls++
+		return meta, nil
+	}
+
+	// Initial fetch and the cache hit on the same version
+	for i := 0; i < 3; i++ {
+		got, err := mc.GetCached(addr, 1, get)
+		if err != nil {
+			t.Fatalf("GetCached: %s", err)
+		}
+
+		if !reflect.DeepEqual(got, meta) {
+			t.Fatalf("GetCached: metadata mismatch")
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("same version: %d Get calls, expected 1", calls)
+	}
+
+	// Version bump must refresh
+	meta = testMetadataCacheMeta("FP-0002")
+	got, _ := mc.GetCached(addr, 2, get)
+	if calls != 2 || !reflect.DeepEqual(got, meta) {
+		t.Errorf("version bump: metadata not refreshed")
+	}
+
+	if _, found := mc.Lookup(addr, 1); found {
+		t.Errorf("version bump: stale entry still found")
+	}
+
+	// Failed Get must not be cached
+	failed := errors.New("failed")
+	_, err := mc.GetCached(addr, 3, func() (Metadata, error) {
+		return Metadata{}, failed
+	})
+	if err != failed {
+		t.Errorf("GetCached: expected %v, present %v", failed, err)
+	}
+
+	// Eviction on Bye
+	mc.Evict(addr)
+	if _, found := mc.Lookup(addr, 2); found || mc.Len() != 0 {
+		t.Errorf("Evict: entry still found")
+	}
+
+	mc.GetCached(addr, 2, get)
+	if calls != 3 {
+		t.Errorf("after Evict: %d Get calls, expected 3", calls)
+	}
+}
+
+// TestMetadataCachePersistence tests MetadataCache save/load round-trip
+func TestMetadataCachePersistence(t *testing.T) {
+	mc := NewMetadataCache()
+	mc.Put("urn:uuid:1b6d1e2a-9a2d-4c43-9b8e-5b7f7f3e9c01", 5,
+		testMetadataCacheMeta("FP-0001"))
+	mc.Put("urn:uuid:6499d366-62a5-4da9-8c18-5af6eea01f22", 1<<40,
+		testMetadataCacheMeta("FP-0002"))
+
+	// Stream round-trip
+	buf := &bytes.Buffer{}
+	err := mc.Save(buf)
+	if err != nil {
+		t.Fatalf("Save: %s", err)
+	}
+
+	mc2 := NewMetadataCache()
+	err = mc2.Load(buf)
+	if err != nil {
+		t.Fatalf("Load: %s", err)
+	}
+
+	if !reflect.DeepEqual(mc.entries, mc2.entries) {
+		t.Errorf("Save/Load mismatch:\nexpected: %#v\npresent:  %#v",
+			mc.entries, mc2.entries)
+	}
+
+	// File round-trip
+	path := filepath.Join(t.TempDir(), "wsd-metadata.xml")
+
+	mc3 := NewMetadataCache()
+	err = mc3.LoadFile(path)
+	if err != nil {
+		t.Errorf("LoadFile (missed file): %s", err)
+	}
+
+	err = mc.SaveFile(path)
+	if err != nil {
+		t.Fatalf("SaveFile: %s", err)
+	}
+
+	err = mc3.LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile: %s", err)
+	}
+
+	if !reflect.DeepEqual(mc.entries, mc3.entries) {
+		t.Errorf("SaveFile/LoadFile mismatch")
+	}
+
+	// Invalid input
+	err = mc3.Load(bytes.NewReader([]byte("<Something/>")))
+	if err == nil {
+		t.Errorf("Load: invalid input not detected")
+	}
+}
+
+// TestMetadataCacheUpdate tests MetadataCache.Update
+func TestMetadataCacheUpdate(t *testing.T) {
+	const addr = AnyURI("urn:uuid:1b6d1e2a-9a2d-4c43-9b8e-5b7f7f3e9c01")
+
+	mc := NewMetadataCache()
+	meta := testMetadataCacheMeta("FP-0001")
+
+	_, updated := mc.Update(addr, 1, meta)
+	if updated {
+		t.Errorf("Update: new entry reported as updated")
+	}
+
+	meta.ThisDevice.FirmwareVersion = "0.0.2"
+	diff, updated := mc.Update(addr, 2, meta)
+	if !updated {
+		t.Errorf("Update: existent entry not reported as updated")
+	}
+
+	expected := []string{"FirmwareVersion"}
+	if !reflect.DeepEqual(diff.ThisDevice, expected) ||
+		diff.EndpointsChanged() {
+		t.Errorf("Update: unexpected diff: %s", diff)
+	}
+
+	if _, found := mc.Lookup(addr, 2); !found {
+		t.Errorf("Update: entry not saved")
+	}
+}
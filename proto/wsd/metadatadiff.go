@@ -0,0 +1,218 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// WSD core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Metadata difference
+
+package wsd
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// MetadataDiff describes the difference between two versions
+// of the device [Metadata], as returned by the [DiffMetadata].
+type MetadataDiff struct {
+	// Names of the changed ThisDevice and ThisModel fields,
+	// for example, "FirmwareVersion".
+	ThisDevice []string
+	ThisModel  []string
+
+	// Hosted services, keyed by the canonical ServiceId
+	Added   []ServiceMetadata // Services that appeared
+	Removed []ServiceMetadata // Services that disappeared
+	Changed []ServiceDiff     // Services with XAddrs or Types changed
+}
+
+// ServiceDiff describes the difference between two versions
+// of the hosted service with the same ServiceId.
+type ServiceDiff struct {
+	ServiceID     AnyURI          // Canonical ServiceId
+	Old, New      ServiceMetadata // Old and new versions
+	XAddrsChanged bool            // Endpoint addresses changed
+	TypesChanged  bool            // Service types changed
+}
+
+// DiffMetadata compares two versions of the device [Metadata].
+//
+// Hosted services are matched by their canonical ServiceId (see
+// [AnyURI.Canonical]). Order of services, of their endpoints and
+// of their types is not significant.
+func DiffMetadata(old, new Metadata) MetadataDiff {
+	diff := MetadataDiff{
+		ThisDevice: diffMetadataFields(old.ThisDevice, new.ThisDevice),
+		ThisModel:  diffMetadataFields(old.ThisModel, new.ThisModel),
+	}
+
+	oldHosted := diffMetadataServices(old.Relationship.Hosted)
+	newHosted := diffMetadataServices(new.Relationship.Hosted)
+
+	// Removed and changed services, in the old order
+	seen := make(map[AnyURI]struct{})
+	for _, svc := range old.Relationship.Hosted {
+		id := svc.ServiceID.Canonical()
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		oldSvc := oldHosted[id]
+		newSvc := newHosted[id]
+
+		if newSvc == nil {
+			diff.Removed = append(diff.Removed, *oldSvc)
+			continue
+		}
+
+		svcdiff := ServiceDiff{
+			ServiceID: id,
+			Old:       *oldSvc,
+			New:       *newSvc,
+			XAddrsChanged: !reflect.DeepEqual(
+				diffMetadataAddrs(oldSvc), diffMetadataAddrs(newSvc)),
+			TypesChanged: !reflect.DeepEqual(
+				diffMetadataTypes(oldSvc), diffMetadataTypes(newSvc)),
+		}
+
+		if svcdiff.XAddrsChanged || svcdiff.TypesChanged {
+			diff.Changed = append(diff.Changed, svcdiff)
+		}
+	}
+
+	// Added services, in the new order
+	for _, svc := range new.Relationship.Hosted {
+		id := svc.ServiceID.Canonical()
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		diff.Added = append(diff.Added, *newHosted[id])
+	}
+
+	return diff
+}
+
+// IsEmpty reports if MetadataDiff contains no changes.
+func (diff MetadataDiff) IsEmpty() bool {
+	return len(diff.ThisDevice) == 0 && len(diff.ThisModel) == 0 &&
+		len(diff.Added) == 0 && len(diff.Removed) == 0 &&
+		len(diff.Changed) == 0
+}
+
+// EndpointsChanged reports if set of hosted services or their
+// endpoints has changed, so the state that depends on the service
+// endpoints (like proxied endpoints) needs to be rebuilt.
+func (diff MetadataDiff) EndpointsChanged() bool {
+	return len(diff.Added) != 0 || len(diff.Removed) != 0 ||
+		len(diff.Changed) != 0
+}
+
+// String returns the short text description of the MetadataDiff,
+// for logging.
+func (diff MetadataDiff) String() string {
+	if diff.IsEmpty() {
+		return "no changes"
+	}
+
+	var parts []string
+
+	for _, name := range diff.ThisDevice {
+		parts = append(parts, "ThisDevice."+name)
+	}
+
+	for _, name := range diff.ThisModel {
+		parts = append(parts, "ThisModel."+name)
+	}
+
+	for _, svc := range diff.Added {
+		parts = append(parts, "added "+string(svc.ServiceID))
+	}
+
+	for _, svc := range diff.Removed {
+		parts = append(parts, "removed "+string(svc.ServiceID))
+	}
+
+	for _, svcdiff := range diff.Changed {
+		var what []string
+		if svcdiff.XAddrsChanged {
+			what = append(what, "XAddrs")
+		}
+		if svcdiff.TypesChanged {
+			what = append(what, "Types")
+		}
+
+		parts = append(parts, "changed "+string(svcdiff.ServiceID)+
+			" ("+strings.Join(what, ",")+")")
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// diffMetadataFields compares two structures of the same type
+// and returns names of fields that differ.
+func diffMetadataFields[T any](old, new T) (names []string) {
+	v1 := reflect.ValueOf(old)
+	v2 := reflect.ValueOf(new)
+
+	for i := 0; i < v1.NumField(); i++ {
+		if !reflect.DeepEqual(v1.Field(i).Interface(),
+			v2.Field(i).Interface()) {
+			names = append(names, v1.Type().Field(i).Name)
+		}
+	}
+
+	return
+}
+
+// diffMetadataServices indexes hosted services by the canonical
+// ServiceId. If ServiceId is duplicated, the first service wins.
+func diffMetadataServices(
+	hosted []ServiceMetadata) map[AnyURI]*ServiceMetadata {
+
+	services := make(map[AnyURI]*ServiceMetadata, len(hosted))
+	for i := range hosted {
+		id := hosted[i].ServiceID.Canonical()
+		if services[id] == nil {
+			services[id] = &hosted[i]
+		}
+	}
+
+	return services
+}
+
+// diffMetadataAddrs returns sorted set of canonical endpoint
+// addresses of the service.
+func diffMetadataAddrs(svc *ServiceMetadata) []AnyURI {
+	addrs := make([]AnyURI, 0, len(svc.EndpointReference))
+	for _, ref := range svc.EndpointReference {
+		addrs = append(addrs, ref.Address.Canonical())
+	}
+
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i] < addrs[j] })
+	return diffMetadataUniq(addrs)
+}
+
+// diffMetadataTypes returns sorted set of service types.
+func diffMetadataTypes(svc *ServiceMetadata) []Type {
+	types := make([]Type, len(svc.Types))
+	copy(types, svc.Types)
+
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return diffMetadataUniq(types)
+}
+
+// diffMetadataUniq removes duplicates from the sorted slice.
+func diffMetadataUniq[T comparable](s []T) []T {
+	out := s[:0]
+	for _, v := range s {
+		if len(out) == 0 || v != out[len(out)-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,234 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// WSD core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Metadata difference test
+
+package wsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testMetadataDiffMeta returns Metadata for DiffMetadata tests
+func testMetadataDiffMeta() Metadata {
+	return Metadata{
+		ThisDevice: ThisDeviceMetadata{
+			FriendlyName:    LocalizedStringList{{String: "MFP"}},
+			FirmwareVersion: "1.0",
+			SerialNumber:    "SN-0001",
+		},
+		ThisModel: ThisModelMetadata{
+			Manufacturer: LocalizedStringList{{String: "Kyocera"}},
+			ModelName:    LocalizedStringList{{String: "M2040dn"}},
+		},
+		Relationship: Relationship{
+			Hosted: []ServiceMetadata{
+				{
+					EndpointReference: []EndpointReference{
+						{"http://192.168.0.10:5358/print"},
+					},
+					Types: []Type{PrinterServiceType},
+					ServiceID: "urn:uuid:" +
+						"4509a320-00a0-008f-00b6-002507510eca",
+				},
+				{
+					EndpointReference: []EndpointReference{
+						{"http://192.168.0.10:5358/scan"},
+					},
+					Types:     []Type{ScannerServiceType},
+					ServiceID: "http://192.168.0.10/ScanService",
+				},
+			},
+		},
+	}
+}
+
+// TestDiffMetadata tests DiffMetadata
+func TestDiffMetadata(t *testing.T) {
+	type testData struct {
+		name      string               // Test name
+		modify    func(meta *Metadata) // Modifies the new Metadata
+		device    []string             // Expected ThisDevice changes
+		model     []string             // Expected ThisModel changes
+		added     []AnyURI             // Expected added services
+		removed   []AnyURI             // Expected removed services
+		changed   []ServiceDiff        // Expected changes, Old/New zeroed
+		endpoints bool                 // Expected EndpointsChanged()
+	}
+
+	tests := []testData{
+		{
+			name:   "no changes",
+			modify: func(meta *Metadata) {},
+		},
+
+		{
+			name: "only FirmwareVersion",
+			modify: func(meta *Metadata) {
+				meta.ThisDevice.FirmwareVersion = "1.1"
+			},
+			device: []string{"FirmwareVersion"},
+		},
+
+		{
+			name: "scan XAddr moved",
+			modify: func(meta *Metadata) {
+				meta.Relationship.Hosted[1].EndpointReference =
+					[]EndpointReference{
+						{"http://192.168.0.10:5359/scan"},
+					}
+			},
+			changed: []ServiceDiff{
+				{
+					ServiceID:     "http://192.168.0.10/ScanService",
+					XAddrsChanged: true,
+				},
+			},
+			endpoints: true,
+		},
+
+		{
+			name: "ServiceId spelled differently",
+			modify: func(meta *Metadata) {
+				hosted := meta.Relationship.Hosted
+				hosted[0].ServiceID = "urn:uuid:" +
+					"4509A320-00A0-008F-00B6-002507510ECA"
+				hosted[1].ServiceID =
+					"HTTP://192.168.0.10:80/ScanService"
+				hosted[0], hosted[1] = hosted[1], hosted[0]
+			},
+		},
+
+		{
+			name: "Types changed",
+			modify: func(meta *Metadata) {
+				meta.Relationship.Hosted[1].Types = []Type{
+					ScannerServiceType, PrinterServiceType}
+			},
+			changed: []ServiceDiff{
+				{
+					ServiceID:    "http://192.168.0.10/ScanService",
+					TypesChanged: true,
+				},
+			},
+			endpoints: true,
+		},
+
+		{
+			name: "service replaced",
+			modify: func(meta *Metadata) {
+				meta.Relationship.Hosted[1].ServiceID =
+					"http://192.168.0.10/ScanService2"
+				meta.ThisModel.ModelName = LocalizedStringList{
+					{String: "M2040dn/L"}}
+			},
+			model:     []string{"ModelName"},
+			added:     []AnyURI{"http://192.168.0.10/ScanService2"},
+			removed:   []AnyURI{"http://192.168.0.10/ScanService"},
+			endpoints: true,
+		},
+	}
+
+	for _, test := range tests {
+		old := testMetadataDiffMeta()
+		new := testMetadataDiffMeta()
+		test.modify(&new)
+
+		diff := DiffMetadata(old, new)
+
+		var added, removed []AnyURI
+		for _, svc := range diff.Added {
+			added = append(added, svc.ServiceID)
+		}
+		for _, svc := range diff.Removed {
+			removed = append(removed, svc.ServiceID)
+		}
+
+		var changed []ServiceDiff
+		for _, svcdiff := range diff.Changed {
+			svcdiff.Old, svcdiff.New = ServiceMetadata{},
+				ServiceMetadata{}
+			changed = append(changed, svcdiff)
+		}
+
+		switch {
+		case !reflect.DeepEqual(diff.ThisDevice, test.device):
+			t.Errorf("%s: ThisDevice: expected %v, present %v",
+				test.name, test.device, diff.ThisDevice)
+
+		case !reflect.DeepEqual(diff.ThisModel, test.model):
+			t.Errorf("%s: ThisModel: expected %v, present %v",
+				test.name, test.model, diff.ThisModel)
+
+		case !reflect.DeepEqual(added, test.added):
+			t.Errorf("%s: Added: expected %v, present %v",
+				test.name, test.added, added)
+
+		case !reflect.DeepEqual(removed, test.removed):
+			t.Errorf("%s: Removed: expected %v, present %v",
+				test.name, test.removed, removed)
+
+		case !reflect.DeepEqual(changed, test.changed):
+			t.Errorf("%s: Changed: expected %v, present %v",
+				test.name, test.changed, changed)
+
+		case diff.EndpointsChanged() != test.endpoints:
+			t.Errorf("%s: EndpointsChanged: expected %v, present %v",
+				test.name, test.endpoints, diff.EndpointsChanged())
+
+		case diff.IsEmpty() != (diff.String() == "no changes"):
+			t.Errorf("%s: IsEmpty/String mismatch: %q",
+				test.name, diff)
+		}
+	}
+}
+
+// TestAnyURICanonical tests AnyURI.Canonical
+func TestAnyURICanonical(t *testing.T) {
+	type testData struct {
+		in, out AnyURI
+	}
+
+	tests := []testData{
+		{
+			in:  "urn:uuid:4509A320-00A0-008F-00B6-002507510ECA",
+			out: "urn:uuid:4509a320-00a0-008f-00b6-002507510eca",
+		},
+		{
+			in:  "uuid:4509a320-00a0-008f-00b6-002507510eca",
+			out: "urn:uuid:4509a320-00a0-008f-00b6-002507510eca",
+		},
+		{
+			in:  "HTTP://Printer.Local:80/ScanService",
+			out: "http://printer.local/ScanService",
+		},
+		{
+			in:  "https://printer.local:443",
+			out: "https://printer.local/",
+		},
+		{
+			in:  "http://printer.local:8080/x",
+			out: "http://printer.local:8080/x",
+		},
+		{
+			in:  "URN:Vendor:Scan",
+			out: "urn:vendor:Scan",
+		},
+		{
+			in:  " opaque-id ",
+			out: "opaque-id",
+		},
+	}
+
+	for _, test := range tests {
+		out := test.in.Canonical()
+		if out != test.out {
+			t.Errorf("%q: expected %q, present %q",
+				test.in, test.out, out)
+		}
+	}
+}
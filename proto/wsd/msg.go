@@ -30,16 +30,24 @@ type Msg struct {
 }
 
 // DecodeMsg decodes [msg] from the wire representation
-func DecodeMsg(data []byte) (m Msg, err error) {
+//
+// If opt is nil, [DefaultDecodeOptions] will be used.
+//
+// Violations of the [DecodeOptions] constraints are reported
+// as *[LimitError] or *[StrictError], wrapped into the error
+// that can be inspected with the [errors.As].
+func DecodeMsg(data []byte, opt *DecodeOptions) (m Msg, err error) {
 	root, err := xmldoc.Decode(NsMap, bytes.NewReader(data))
 	if err == nil {
-		m, err = msgFromXML(root)
+		m, err = msgFromXML(root, getDecodeOptions(opt))
 	}
 	return
 }
 
 // msgFromXML decodes [msg] from the XML tree
-func msgFromXML(root xmldoc.Element) (m Msg, err error) {
+func msgFromXML(root xmldoc.Element, opt *DecodeOptions) (
+	m Msg, err error) {
+
 	const (
 		rootName = NsSOAP + ":" + "Envelope"
 		hdrName  = NsSOAP + ":" + "Header"
@@ -65,7 +73,7 @@ func msgFromXML(root xmldoc.Element) (m Msg, err error) {
 	}
 
 	// Decode message header
-	m.Header, err = DecodeHeader(hdr.Elem)
+	m.Header, err = DecodeHeader(hdr.Elem, opt)
 	if err != nil {
 		return
 	}
@@ -87,17 +95,17 @@ func msgFromXML(root xmldoc.Element) (m Msg, err error) {
 	// Decode message body
 	switch m.Header.Action {
 	case ActHello:
-		m.Body, err = DecodeHello(elem)
+		m.Body, err = DecodeHello(elem, opt)
 	case ActBye:
 		m.Body, err = DecodeBye(elem)
 	case ActProbe:
-		m.Body, err = DecodeProbe(elem)
+		m.Body, err = DecodeProbe(elem, opt)
 	case ActProbeMatches:
-		m.Body, err = DecodeProbeMatches(elem)
+		m.Body, err = DecodeProbeMatches(elem, opt)
 	case ActResolve:
 		m.Body, err = DecodeResolve(elem)
 	case ActResolveMatches:
-		m.Body, err = DecodeResolveMatches(elem)
+		m.Body, err = DecodeResolveMatches(elem, opt)
 	case ActGet:
 		m.Body, err = DecodeGet(elem)
 	case ActGetResponse:
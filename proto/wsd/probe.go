@@ -23,20 +23,30 @@ type Probe struct {
 }
 
 // DecodeProbe decodes [Probe] from the XML tree
-func DecodeProbe(root xmldoc.Element) (probe Probe, err error) {
+//
+// If opt is nil, [DefaultDecodeOptions] will be used.
+func DecodeProbe(root xmldoc.Element, opt *DecodeOptions) (
+	probe Probe, err error) {
+
 	defer func() { err = xmldoc.XMLErrWrap(root, err) }()
 
+	opt = getDecodeOptions(opt)
+
 	// Lookup message elements
 	types := xmldoc.Lookup{Name: NsDiscovery + ":Types", Required: true}
+	scopes := xmldoc.Lookup{Name: NsDiscovery + ":Scopes"}
 
-	missed := root.Lookup(&types)
+	missed := root.Lookup(&types, &scopes)
 	if missed != nil {
 		err = xmldoc.XMLErrMissed(missed.Name)
 		return
 	}
 
 	// Decode elements
-	probe.Types, err = DecodeTypes(types.Elem)
+	err = decodeCheckScopes(scopes, opt)
+	if err == nil {
+		probe.Types, err = DecodeTypes(types.Elem, opt)
+	}
 
 	return
 }
@@ -52,7 +52,7 @@ func TestProbe(t *testing.T) {
 				xml.EncodeString(NsMap))
 		}
 
-		probe, err := DecodeProbe(xml)
+		probe, err := DecodeProbe(xml, nil)
 		if err != nil {
 			t.Errorf("DecodeProbe: %s", err)
 			continue
@@ -115,7 +115,7 @@ func TestProbeDecodeErrors(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		_, err := DecodeProbe(test.xml)
+		_, err := DecodeProbe(test.xml, nil)
 		estr := ""
 		if err != nil {
 			estr = err.Error()
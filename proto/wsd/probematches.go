@@ -33,14 +33,20 @@ type ProbeMatch struct {
 }
 
 // DecodeProbeMatches decodes [ProbeMatches] from the XML tree
-func DecodeProbeMatches(root xmldoc.Element) (pm ProbeMatches, err error) {
+//
+// If opt is nil, [DefaultDecodeOptions] will be used.
+func DecodeProbeMatches(root xmldoc.Element, opt *DecodeOptions) (
+	pm ProbeMatches, err error) {
+
 	defer func() { err = xmldoc.XMLErrWrap(root, err) }()
 
+	opt = getDecodeOptions(opt)
+
 	const name = NsDiscovery + ":ProbeMatch"
 	for _, chld := range root.Children {
 		if chld.Name == name {
 			var ann Announce
-			ann, err = decodeAnnounce(chld)
+			ann, err = decodeAnnounce(chld, opt)
 			if err != nil {
 				return
 			}
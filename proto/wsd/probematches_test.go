@@ -92,7 +92,7 @@ func TestProbeMatches(t *testing.T) {
 				xml.EncodeString(NsMap))
 		}
 
-		pm, err := DecodeProbeMatches(xml)
+		pm, err := DecodeProbeMatches(xml, nil)
 		if err != nil {
 			t.Errorf("DecodeProbeMatches: %s", err)
 			continue
@@ -157,7 +157,7 @@ func TestProbeMatchesDecodeErrors(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		_, err := DecodeProbeMatches(test.xml)
+		_, err := DecodeProbeMatches(test.xml, nil)
 		estr := ""
 		if err != nil {
 			estr = err.Error()
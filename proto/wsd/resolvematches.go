@@ -33,14 +33,20 @@ type ResolveMatch struct {
 }
 
 // DecodeResolveMatches decodes [ResolveMatches] from the XML tree
-func DecodeResolveMatches(root xmldoc.Element) (rm ResolveMatches, err error) {
+//
+// If opt is nil, [DefaultDecodeOptions] will be used.
+func DecodeResolveMatches(root xmldoc.Element, opt *DecodeOptions) (
+	rm ResolveMatches, err error) {
+
 	defer func() { err = xmldoc.XMLErrWrap(root, err) }()
 
+	opt = getDecodeOptions(opt)
+
 	const name = NsDiscovery + ":ResolveMatch"
 	for _, chld := range root.Children {
 		if chld.Name == name {
 			var ann Announce
-			ann, err = decodeAnnounce(chld)
+			ann, err = decodeAnnounce(chld, opt)
 			if err != nil {
 				return
 			}
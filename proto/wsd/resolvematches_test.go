@@ -93,7 +93,7 @@ func TestResolveMatches(t *testing.T) {
 				xml.EncodeString(NsMap))
 		}
 
-		rm, err := DecodeResolveMatches(xml)
+		rm, err := DecodeResolveMatches(xml, nil)
 		if err != nil {
 			t.Errorf("DecodeResolveMatches: %s", err)
 			continue
@@ -158,7 +158,7 @@ func TestResolveMatchesDecodeErrors(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		_, err := DecodeResolveMatches(test.xml)
+		_, err := DecodeResolveMatches(test.xml, nil)
 		estr := ""
 		if err != nil {
 			estr = err.Error()
@@ -0,0 +1,259 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// WSD core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Responses pacing
+
+package wsd
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// AppMaxDelay is the APP_MAX_DELAY WS-Discovery parameter: the
+// maximum random delay before answering the multicast message
+// (i.e., multicast Probe or Resolve).
+const AppMaxDelay = 500 * time.Millisecond
+
+// Rate limits of the DefaultResponseLimiter:
+const (
+	DefaultResponseRate  = 20 // Responses per second
+	DefaultResponseBurst = 40 // Responses in burst
+)
+
+// responseDupWindow is how long the ResponseScheduler remembers
+// MessageIDs of the answered messages. Senders repeat multicast
+// messages (see MULTICAST_UDP_REPEAT) within much shorter interval.
+const responseDupWindow = 10 * time.Second
+
+// DefaultResponseLimiter is the [ResponseLimiter], shared by all
+// [ResponseScheduler]s in the process by default, so the total rate
+// of responses doesn't grow with the number of the simulated devices.
+var DefaultResponseLimiter = NewResponseLimiter(DefaultResponseRate,
+	DefaultResponseBurst)
+
+// ResponseLimiter is the token bucket, that bounds the rate of
+// outgoing responses.
+//
+// ResponseLimiter is safe for concurrent use.
+type ResponseLimiter struct {
+	rate   float64    // Tokens per second
+	burst  float64    // Bucket capacity
+	tokens float64    // Available tokens; negative when in debt
+	last   time.Time  // Last refill time
+	lock   sync.Mutex // Access lock
+}
+
+// NewResponseLimiter creates a new [ResponseLimiter] that allows
+// rate responses per second in average, with bursts up to the
+// burst responses.
+func NewResponseLimiter(rate float64, burst int) *ResponseLimiter {
+	return &ResponseLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// reserve takes a token from the bucket at the specified time.
+//
+// Responses to multicast messages are dropped when bucket is empty:
+// other devices will answer, and the sender repeats the message
+// anyway. In this case reserve returns false.
+//
+// Directed responses are never dropped. Instead, the token is
+// borrowed from the future, and reserve returns the delay until
+// the token becomes available.
+func (lim *ResponseLimiter) reserve(now time.Time,
+	multicast bool) (time.Duration, bool) {
+
+	lim.lock.Lock()
+	defer lim.lock.Unlock()
+
+	// Refill the bucket
+	if !lim.last.IsZero() {
+		if elapsed := now.Sub(lim.last); elapsed > 0 {
+			lim.tokens += elapsed.Seconds() * lim.rate
+			if lim.tokens > lim.burst {
+				lim.tokens = lim.burst
+			}
+		}
+	}
+
+	if lim.last.Before(now) {
+		lim.last = now
+	}
+
+	// Take the token
+	switch {
+	case lim.tokens >= 1:
+		lim.tokens--
+		return 0, true
+
+	case multicast:
+		return 0, false
+	}
+
+	lim.tokens--
+	wait := -lim.tokens / lim.rate
+	return time.Duration(wait * float64(time.Second)), true
+}
+
+// ResponseScheduler schedules responses to the received messages
+// according to WS-Discovery rules:
+//   - responses to the multicast messages are delayed randomly
+//     within [0...AppMaxDelay], to avoid multicast storms
+//   - a message is answered only once, even if received multiple
+//     times, i.e., via multiple network interfaces or due to
+//     MULTICAST_UDP_REPEAT
+//   - rate of responses is limited by the [ResponseLimiter]
+//
+// ResponseScheduler is safe for concurrent use.
+type ResponseScheduler struct {
+	limiter  *ResponseLimiter     // Rate limiter
+	ctx      context.Context      // Canceled by Close
+	cancel   context.CancelFunc   // Cancels ctx
+	answered map[AnyURI]time.Time // Answered MessageIDs
+	lock     sync.Mutex           // Access lock
+	done     sync.WaitGroup       // Wait for pending responses
+
+	// Time source and delays; replaced by tests
+	now    func() time.Time
+	sleep  func(context.Context, time.Duration) error
+	jitter func() time.Duration
+}
+
+// NewResponseScheduler creates a new [ResponseScheduler].
+//
+// Pending responses are canceled, when ctx is canceled or
+// [ResponseScheduler.Close] is called (i.e., when socket is closed).
+//
+// If limiter is nil, [DefaultResponseLimiter] is used.
+func NewResponseScheduler(ctx context.Context,
+	limiter *ResponseLimiter) *ResponseScheduler {
+
+	if limiter == nil {
+		limiter = DefaultResponseLimiter
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &ResponseScheduler{
+		limiter:  limiter,
+		ctx:      ctx,
+		cancel:   cancel,
+		answered: make(map[AnyURI]time.Time),
+		now:      time.Now,
+		sleep:    responseSleep,
+		jitter:   responseJitter,
+	}
+}
+
+// Close cancels all pending responses and waits until
+// scheduled callbacks are finished.
+func (sched *ResponseScheduler) Close() {
+	sched.cancel()
+	sched.done.Wait()
+}
+
+// Schedule schedules the response to the message with the specified
+// MessageID. The send callback is called from the separate goroutine
+// when it is time to respond.
+//
+// If multicast is true, the message was received via multicast.
+// Response is delayed randomly and may be dropped by the rate
+// limiter. Otherwise, response is delayed only by the rate limiter
+// and never dropped.
+//
+// If message with the same MessageID was already answered, Schedule
+// returns false and send is never called.
+func (sched *ResponseScheduler) Schedule(msgID AnyURI, multicast bool,
+	send func()) bool {
+
+	if !sched.markAnswered(msgID) {
+		return false
+	}
+
+	sched.done.Add(1)
+	go func() {
+		defer sched.done.Done()
+
+		if multicast {
+			err := sched.sleep(sched.ctx, sched.jitter())
+			if err != nil {
+				return
+			}
+		}
+
+		delay, ok := sched.limiter.reserve(sched.now(), multicast)
+		if !ok {
+			return
+		}
+
+		if delay > 0 {
+			err := sched.sleep(sched.ctx, delay)
+			if err != nil {
+				return
+			}
+		}
+
+		if sched.ctx.Err() == nil {
+			send()
+		}
+	}()
+
+	return true
+}
+
+// markAnswered remembers msgID as answered. It returns false,
+// if it was already answered.
+//
+// Empty msgID is never considered as a duplicate.
+func (sched *ResponseScheduler) markAnswered(msgID AnyURI) bool {
+	if msgID == "" {
+		return true
+	}
+
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+
+	now := sched.now()
+
+	// Purge expired entries
+	for id, when := range sched.answered {
+		if now.Sub(when) >= responseDupWindow {
+			delete(sched.answered, id)
+		}
+	}
+
+	if _, found := sched.answered[msgID]; found {
+		return false
+	}
+
+	sched.answered[msgID] = now
+	return true
+}
+
+// responseJitter returns random delay within [0...AppMaxDelay]
+func responseJitter() time.Duration {
+	return time.Duration(rand.Int63n(int64(AppMaxDelay) + 1))
+}
+
+// responseSleep sleeps for the specified duration or until
+// context is canceled.
+func responseSleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,287 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// WSD core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Responses pacing test
+
+package wsd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testResponseClock is the fake clock for the ResponseScheduler
+// tests. Sleeps return immediately, and requested delays are
+// recorded.
+type testResponseClock struct {
+	lock   sync.Mutex      // Access lock
+	now    time.Time       // Current time
+	sleeps []time.Duration // Recorded sleeps
+}
+
+// install installs the testResponseClock into the ResponseScheduler
+func (clock *testResponseClock) install(sched *ResponseScheduler) {
+	sched.now = func() time.Time {
+		clock.lock.Lock()
+		defer clock.lock.Unlock()
+		return clock.now
+	}
+
+	sched.sleep = func(ctx context.Context, d time.Duration) error {
+		clock.lock.Lock()
+		clock.sleeps = append(clock.sleeps, d)
+		clock.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+// advance advances the testResponseClock
+func (clock *testResponseClock) advance(d time.Duration) {
+	clock.lock.Lock()
+	clock.now = clock.now.Add(d)
+	clock.lock.Unlock()
+}
+
+// TestResponseSchedulerJitter tests random delay of responses
+// to multicast messages
+func TestResponseSchedulerJitter(t *testing.T) {
+	const count = 200
+
+	clock := &testResponseClock{now: time.Unix(1000000, 0)}
+	sched := NewResponseScheduler(context.Background(),
+		NewResponseLimiter(count, count+1))
+	clock.install(sched)
+
+	sent := 0
+	var lock sync.Mutex
+
+	for i := 0; i < count; i++ {
+		id := AnyURI(fmt.Sprintf("urn:uuid:%d", i))
+		sched.Schedule(id, true, func() {
+			lock.Lock()
+			sent++
+			lock.Unlock()
+		})
+	}
+
+	sched.done.Wait()
+
+	if sent != count {
+		t.Errorf("%d responses sent, expected %d", sent, count)
+	}
+
+	if len(clock.sleeps) != count {
+		t.Fatalf("%d delays, expected %d", len(clock.sleeps), count)
+	}
+
+	distinct := make(map[time.Duration]struct{})
+	for _, d := range clock.sleeps {
+		if d < 0 || d > AppMaxDelay {
+			t.Errorf("delay %s out of [0...%s]", d, AppMaxDelay)
+		}
+		distinct[d] = struct{}{}
+	}
+
+	if len(distinct) < 2 {
+		t.Errorf("delays are not random: %v", clock.sleeps[0])
+	}
+
+	// Directed messages are answered without random delay
+	clock.sleeps = nil
+	sched.Schedule("urn:uuid:directed", false, func() {})
+	sched.done.Wait()
+
+	if len(clock.sleeps) != 0 {
+		t.Errorf("directed response delayed: %v", clock.sleeps)
+	}
+}
+
+// TestResponseSchedulerDuplicates tests suppression of the second
+// answer to the same MessageID
+func TestResponseSchedulerDuplicates(t *testing.T) {
+	clock := &testResponseClock{now: time.Unix(1000000, 0)}
+	sched := NewResponseScheduler(context.Background(),
+		NewResponseLimiter(100, 100))
+	clock.install(sched)
+
+	sent := 0
+	send := func() { sent++ }
+
+	const id = "urn:uuid:0f4f6b2c-5e2a-4d1e-9a51-5d3a3e7c0a01"
+
+	if !sched.Schedule(id, true, send) {
+		t.Errorf("first answer suppressed")
+	}
+
+	sched.done.Wait()
+
+	if sched.Schedule(id, false, send) {
+		t.Errorf("second answer not suppressed")
+	}
+
+	// Empty MessageID is never a duplicate
+	sched.Schedule("", true, send)
+	sched.done.Wait()
+	sched.Schedule("", true, send)
+	sched.done.Wait()
+
+	// MessageID expires after responseDupWindow
+	clock.advance(responseDupWindow)
+	if !sched.Schedule(id, true, send) {
+		t.Errorf("answer suppressed after responseDupWindow")
+	}
+
+	sched.done.Wait()
+
+	if sent != 4 {
+		t.Errorf("%d responses sent, expected 4", sent)
+	}
+}
+
+// TestResponseLimiter tests the ResponseLimiter
+func TestResponseLimiter(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	lim := NewResponseLimiter(10, 2)
+
+	// Burst is admitted without delay
+	for i := 0; i < 2; i++ {
+		delay, ok := lim.reserve(now, true)
+		if !ok || delay != 0 {
+			t.Fatalf("burst %d: %s %v", i, delay, ok)
+		}
+	}
+
+	// Multicast response is dropped when bucket is empty
+	if _, ok := lim.reserve(now, true); ok {
+		t.Errorf("multicast response not dropped")
+	}
+
+	// Directed responses are delayed, but not dropped
+	for i := 1; i <= 3; i++ {
+		delay, ok := lim.reserve(now, false)
+		expected := time.Duration(i) * 100 * time.Millisecond
+		if !ok || delay != expected {
+			t.Errorf("directed %d: expected %s, present %s %v",
+				i, expected, delay, ok)
+		}
+	}
+
+	// Borrowed tokens must be repaid before multicast is admitted
+	now = now.Add(300 * time.Millisecond)
+	if _, ok := lim.reserve(now, true); ok {
+		t.Errorf("multicast admitted while in debt")
+	}
+
+	now = now.Add(100 * time.Millisecond)
+	if _, ok := lim.reserve(now, true); !ok {
+		t.Errorf("multicast not admitted after refill")
+	}
+
+	// Refill never exceeds burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		lim.reserve(now, true)
+	}
+
+	if _, ok := lim.reserve(now, true); ok {
+		t.Errorf("bucket overfilled")
+	}
+}
+
+// TestResponseSchedulerLimiter tests that the rate limiter, shared
+// between ResponseSchedulers, delays but never drops directed
+// responses, and drops excessive responses to multicast messages.
+func TestResponseSchedulerLimiter(t *testing.T) {
+	const count = 10
+
+	clock := &testResponseClock{now: time.Unix(1000000, 0)}
+	lim := NewResponseLimiter(1, 1)
+
+	var scheds []*ResponseScheduler
+	for i := 0; i < 2; i++ {
+		sched := NewResponseScheduler(context.Background(), lim)
+		clock.install(sched)
+		scheds = append(scheds, sched)
+	}
+
+	var lock sync.Mutex
+	sentDirected, sentMulticast := 0, 0
+
+	for i := 0; i < count; i++ {
+		sched := scheds[i%2]
+		sched.Schedule(AnyURI(fmt.Sprintf("urn:uuid:d%d", i)), false,
+			func() {
+				lock.Lock()
+				sentDirected++
+				lock.Unlock()
+			})
+		sched.Schedule(AnyURI(fmt.Sprintf("urn:uuid:m%d", i)), true,
+			func() {
+				lock.Lock()
+				sentMulticast++
+				lock.Unlock()
+			})
+	}
+
+	for _, sched := range scheds {
+		sched.done.Wait()
+	}
+
+	if sentDirected != count {
+		t.Errorf("%d directed responses sent, expected %d",
+			sentDirected, count)
+	}
+
+	// Without clock advance, at most one multicast response
+	// may take the token, and responses over the burst are
+	// delayed by 1s, 2s and so on.
+	if sentMulticast > 1 {
+		t.Errorf("%d multicast responses sent, expected <= 1",
+			sentMulticast)
+	}
+
+	var maxDelay time.Duration
+	for _, d := range clock.sleeps {
+		if d > maxDelay {
+			maxDelay = d
+		}
+	}
+
+	expected := time.Duration(count-1+sentMulticast) * time.Second
+	if maxDelay != expected {
+		t.Errorf("max delay: expected %s, present %s", expected, maxDelay)
+	}
+}
+
+// TestResponseSchedulerClose tests cancellation of pending
+// responses by Close
+func TestResponseSchedulerClose(t *testing.T) {
+	sched := NewResponseScheduler(context.Background(),
+		NewResponseLimiter(100, 100))
+	sched.jitter = func() time.Duration { return time.Hour }
+
+	sent := false
+	sched.Schedule("urn:uuid:pending", true, func() { sent = true })
+
+	done := make(chan struct{})
+	go func() {
+		sched.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close: pending response not canceled")
+	}
+
+	if sent {
+		t.Errorf("canceled response was sent")
+	}
+}
@@ -29,8 +29,17 @@ const (
 )
 
 // DecodeTypes decodes [Types] from the XML tree
-func DecodeTypes(root xmldoc.Element) (types Types, err error) {
-	names := strings.Fields(root.Text)
+//
+// If opt is nil, [DefaultDecodeOptions] will be used.
+func DecodeTypes(root xmldoc.Element, opt *DecodeOptions) (
+	types Types, err error) {
+
+	opt = getDecodeOptions(opt)
+	names, err := decodeFields(root, opt.MaxTypes)
+	if err != nil {
+		err = xmldoc.XMLErrWrap(root, err)
+		return
+	}
 
 	for _, n := range names {
 		// Note, type names looks as follows: namespace:name
@@ -71,7 +71,7 @@ func TestTypes(t *testing.T) {
 				xml.EncodeString(NsMap))
 		}
 
-		types, err := DecodeTypes(xml)
+		types, err := DecodeTypes(xml, nil)
 		if err != nil {
 			t.Errorf("DecodeBye: %s", err)
 			continue
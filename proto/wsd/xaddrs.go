@@ -19,8 +19,18 @@ import (
 type XAddrs []string
 
 // DecodeXAddrs decodes [XAddrs] from the XML tree
-func DecodeXAddrs(root xmldoc.Element) (xaddrs XAddrs, err error) {
-	ss := strings.Fields(root.Text)
+//
+// If opt is nil, [DefaultDecodeOptions] will be used.
+func DecodeXAddrs(root xmldoc.Element, opt *DecodeOptions) (
+	xaddrs XAddrs, err error) {
+
+	opt = getDecodeOptions(opt)
+	ss, err := decodeFields(root, opt.MaxXAddrs)
+	if err != nil {
+		err = xmldoc.XMLErrWrap(root, err)
+		return
+	}
+
 	xaddrs = make(XAddrs, 0, len(ss))
 
 	for _, s := range ss {
@@ -93,7 +93,7 @@ func TestXAddrsDecode(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		xaddrs, err := DecodeXAddrs(test.xml)
+		xaddrs, err := DecodeXAddrs(test.xml, nil)
 		if err != nil {
 			t.Errorf("%s", err)
 			continue
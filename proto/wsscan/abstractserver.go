@@ -9,7 +9,6 @@
 package wsscan
 
 import (
-	"bytes"
 	"errors"
 	"io"
 	"net/http"
@@ -23,7 +22,6 @@ import (
 	"github.com/OpenPrinting/go-mfp/util/generic"
 	"github.com/OpenPrinting/go-mfp/util/optional"
 	"github.com/OpenPrinting/go-mfp/util/uuid"
-	"github.com/OpenPrinting/go-mfp/util/xmldoc"
 )
 
 var (
@@ -51,6 +49,12 @@ type AbstractServerOptions struct {
 	// BasePath is required so the server knows how to
 	// interpret incoming request paths.
 	BasePath string
+
+	// Description, if set, is returned as the ScannerDescription
+	// element instead of one, generated from the Scanner
+	// capabilities. It allows to serve localized (per xml:lang)
+	// scanner name, info and location.
+	Description optional.Val[ScannerDescription]
 }
 
 // NewAbstractServer returns a new [AbstractServer].
@@ -93,7 +97,7 @@ func (srv *AbstractServer) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
 		return
 	}
 
-	root, err := xmldoc.Decode(NsMap, bytes.NewReader(data))
+	root, err := decodeXML(data)
 	if err != nil {
 		query.Reject(http.StatusBadRequest, err)
 		return
@@ -101,6 +105,13 @@ func (srv *AbstractServer) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
 
 	msg, err := DecodeMessage(root)
 	if err != nil {
+		// If header was decoded, reply with the SOAP Fault,
+		// so client will know what is wrong with its request.
+		if msg.Header.Action != ActUnknown {
+			srv.sendSOAPResponse(query, msg, ToSOAPFault(err))
+			return
+		}
+
 		query.Reject(http.StatusBadRequest, err)
 		return
 	}
@@ -170,6 +181,9 @@ func (srv *AbstractServer) handleGetScannerElementsRequest(
 
 		case ScannerElemDescription:
 			desc := fromAbstractScannerDescription(srv.caps)
+			if srv.options.Description != nil {
+				desc = *srv.options.Description
+			}
 			elements = append(elements, ScannerElemData{
 				Name:               ScannerElemDescription,
 				Valid:              BooleanElement("true"),
@@ -292,23 +306,21 @@ func (srv *AbstractServer) handleCreateScanJobRequest(
 		return nil, errBusy
 	}
 
-	// Convert ScanTicket to abstract.ScannerRequest
-	absreq := req.ScanTicket.ToAbstract()
-
-	// Fill missing parameters with scanner defaults and validate against
-	// capabilities. FillRequest returns an error for unsupported params.
-	filled, err := srv.caps.FillRequest(&absreq)
-	if err != nil {
-		query.Reject(http.StatusConflict, err)
-		return nil, err
+	// Convert ScanTicket to abstract.ScannerRequest, fill missing
+	// parameters with scanner defaults and validate against
+	// capabilities. Unsupported parameters are substituted with
+	// defaults, unless ticket requires to honor them.
+	filled, fault := fillTicketRequest(srv.caps, req.ScanTicket)
+	if fault != nil {
+		return fault, nil
 	}
 
 	// Send filled request to the underlying abstract.Scanner
 	ctx := query.RequestContext()
 	document, err := srv.options.Scanner.Scan(ctx, *filled)
 	if err != nil {
-		query.Reject(http.StatusConflict, err)
-		return nil, err
+		return faultFromError(FaultReceiver,
+			FaultOperationFailed, err), nil
 	}
 
 	// Store document and update status
@@ -394,7 +406,7 @@ func (srv *AbstractServer) handleCancelJobRequest(
 
 	srv.lock.Lock()
 	job := srv.jobs.get(req.JobID)
-	active := job.state == JobStateProcessing
+	active := job != nil && job.state == JobStateProcessing
 	srv.lock.Unlock()
 
 	if !active {
@@ -500,8 +512,13 @@ func (srv *AbstractServer) sendSOAPResponse(
 		return
 	}
 
+	status := http.StatusOK
+	if fault, ok := body.(*Fault); ok {
+		status = fault.httpStatus()
+	}
+
 	query.ResponseHeader().Set("Content-Type", "application/soap+xml")
-	query.SendXML(http.StatusOK, NsMap, rsp.toXML())
+	query.SendXML(status, rsp.nsMap(), rsp.toXML())
 
 	// Notify tracer
 	trace.OnResponse(query, traceMessage{rsp}, nil)
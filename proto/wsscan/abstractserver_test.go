@@ -0,0 +1,321 @@
+// MFP - Multi-Function Printers and scanners toolkit
+// WS-Scan core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// WS-Scan client/server integration test
+
+package wsscan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/abstract"
+	"github.com/OpenPrinting/go-mfp/internal/assert"
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// TestAbstractServer runs the WS-Scan Client against the
+// AbstractServer, backed by the virtual scanner with the
+// real-world Kyocera capabilities.
+func TestAbstractServer(t *testing.T) {
+	// Decode Kyocera ScannerConfiguration
+	xml, err := xmldoc.Decode(NsMap, bytes.NewReader(testutils.Kyocera.
+		ECOSYS.M2040dn.WSD.GetScannerElementsResponse))
+	assert.NoError(err)
+
+	msg, err := DecodeMessage(xml)
+	assert.NoError(err)
+
+	caps := msg.Body.(*GetScannerElementsResponse).ToAbstract()
+
+	// Create loopback transport
+	tr, loopback := transport.NewLoopback()
+
+	// Start virtual scanner with two pages loaded into ADF
+	s := &abstract.VirtualScanner{
+		ScanCaps: caps,
+		Resolution: abstract.Resolution{
+			XResolution: 200,
+			YResolution: 200,
+		},
+		ADFImages: [][]byte{
+			testutils.Images.JPEG100x75rgb8,
+			testutils.Images.JPEG100x75gray8,
+		},
+	}
+
+	base := transport.MustParseURL("http://localhost/WSDScanner")
+	handler := NewAbstractServer(AbstractServerOptions{
+		Scanner:  s,
+		BasePath: base.Path,
+	})
+
+	server := transport.NewServer(context.Background(), nil, handler)
+	go server.Serve(loopback)
+	defer server.Close()
+
+	// Create a client
+	clnt := NewClient(base, tr)
+	ctx := context.Background()
+
+	// Test Client.GetScannerElements
+	elements, err := clnt.GetScannerElements(ctx,
+		ScannerElemConfiguration, ScannerElemStatus)
+	if err != nil {
+		t.Fatalf("Client.GetScannerElements: %s", err)
+	}
+
+	if len(elements.ScannerElements) != 2 {
+		t.Fatalf("Client.GetScannerElements: expected 2 elements, "+
+			"present %d", len(elements.ScannerElements))
+	}
+
+	confExpected := fromAbstractScannerConfiguration(caps)
+	confPresent := elements.ScannerElements[0].ScannerConfiguration
+	if confPresent == nil {
+		t.Fatalf("Client.GetScannerElements: missed ScannerConfiguration")
+	}
+
+	diff := testutils.Diff(confExpected, *confPresent)
+	if diff != "" {
+		t.Errorf("Client.GetScannerElements: ScannerConfiguration:\n%s",
+			diff)
+	}
+
+	// Test Client.CreateScanJob with duplex ticket. Missed
+	// parameters must be filled with the scanner defaults.
+	ticket := ScanTicket{
+		JobDescription: JobDescription{
+			JobName:                "test",
+			JobOriginatingUserName: "tester",
+		},
+		DocumentParameters: optional.New(DocumentParameters{
+			Format: optional.New(
+				ValWithOptions[FormatValue]{Val: JFIF}),
+			InputSource: optional.New(
+				ValWithOptions[InputSourceValue]{
+					Val: InputSourceADFDuplex}),
+			MediaSides: optional.New(MediaSides{
+				MediaFront: MediaSide{
+					Resolution: optional.New(Resolution{
+						Width:  ValWithOptions[int]{Val: 200},
+						Height: ValWithOptions[int]{Val: 200},
+					}),
+					ScanRegion: optional.New(ScanRegion{
+						ScanRegionWidth: ValWithOptions[int]{
+							Val: 2000},
+						ScanRegionHeight: ValWithOptions[int]{
+							Val: 2000},
+					}),
+				},
+			}),
+		}),
+	}
+
+	absreq := ticket.ToAbstract()
+	filled, err := caps.FillRequest(&absreq)
+	assert.NoError(err)
+
+	job, err := clnt.CreateScanJob(ctx,
+		&CreateScanJobRequest{ScanTicket: ticket})
+	if err != nil {
+		t.Fatalf("Client.CreateScanJob: %s", err)
+	}
+
+	finalExpected := optional.Get(
+		fromAbstractScannerRequest(filled).DocumentParameters)
+
+	diff = testutils.Diff(finalExpected, job.DocumentFinalParameters)
+	if diff != "" {
+		t.Errorf("Client.CreateScanJob: DocumentFinalParameters:\n%s",
+			diff)
+	}
+
+	final := job.DocumentFinalParameters
+	if final.MediaSides == nil ||
+		optional.Get(final.MediaSides).MediaFront.ColorProcessing == nil {
+		t.Errorf("Client.CreateScanJob: ColorProcessing not filled")
+	}
+
+	if final.CompressionQualityFactor == nil {
+		t.Errorf("Client.CreateScanJob: " +
+			"CompressionQualityFactor not filled")
+	}
+
+	// Scan the same request directly, to obtain expected images
+	doc, err := s.Scan(ctx, *filled)
+	assert.NoError(err)
+
+	var imagesExpected [][]byte
+	for {
+		file, err := doc.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(err)
+
+		data, err := io.ReadAll(file)
+		assert.NoError(err)
+		imagesExpected = append(imagesExpected, data)
+	}
+	doc.Close()
+
+	// Test Client.RetrieveImage
+	var imagesPresent [][]byte
+	for len(imagesPresent) <= len(imagesExpected) {
+		rsp, err := clnt.RetrieveImage(ctx, &RetrieveImageRequest{
+			DocumentDescription: DocumentDescription{
+				DocumentName: "page",
+			},
+			JobID:    job.JobID,
+			JobToken: job.JobToken,
+		})
+		if err != nil {
+			break
+		}
+
+		data, err := io.ReadAll(rsp.Image)
+		rsp.Image.Close()
+		if err != nil {
+			t.Fatalf("Client.RetrieveImage: %s", err)
+		}
+
+		imagesPresent = append(imagesPresent, data)
+	}
+
+	if len(imagesExpected) != 2 {
+		t.Errorf("VirtualScanner: expected 2 images, present %d",
+			len(imagesExpected))
+	}
+
+	if len(imagesPresent) != len(imagesExpected) {
+		t.Fatalf("Client.RetrieveImage: expected %d images, present %d",
+			len(imagesExpected), len(imagesPresent))
+	}
+
+	for i := range imagesExpected {
+		if !bytes.Equal(imagesExpected[i], imagesPresent[i]) {
+			t.Errorf("Client.RetrieveImage: image %d mismatch", i)
+		}
+	}
+
+	// Job must be moved to the history
+	history, err := clnt.GetJobHistory(ctx)
+	if err != nil {
+		t.Fatalf("Client.GetJobHistory: %s", err)
+	}
+
+	if len(history.JobHistory) != 1 ||
+		history.JobHistory[0].JobState != JobStateCompleted ||
+		history.JobHistory[0].ScansCompleted != 2 {
+		t.Errorf("Client.GetJobHistory: unexpected history: %+v",
+			history.JobHistory)
+	}
+
+	// Unsupported resolution without MustHonor is substituted
+	// with the scanner default
+	dp := optional.Get(ticket.DocumentParameters)
+	ms := optional.Get(dp.MediaSides)
+	ms.MediaFront.Resolution = optional.New(Resolution{
+		Width:  ValWithOptions[int]{Val: 1234},
+		Height: ValWithOptions[int]{Val: 1234},
+	})
+	dp.MediaSides = optional.New(ms)
+	ticket.DocumentParameters = optional.New(dp)
+
+	job, err = clnt.CreateScanJob(ctx,
+		&CreateScanJobRequest{ScanTicket: ticket})
+	if err != nil {
+		t.Fatalf("Client.CreateScanJob: %s", err)
+	}
+
+	final = job.DocumentFinalParameters
+	res := optional.Get(optional.Get(final.MediaSides).MediaFront.Resolution)
+	if res.Width.Val == 1234 || res.Height.Val == 1234 {
+		t.Errorf("Client.CreateScanJob: resolution not substituted")
+	}
+
+	// Test Client.CancelJob
+	_, err = clnt.CancelJob(ctx, job.JobID)
+	if err != nil {
+		t.Fatalf("Client.CancelJob: %s", err)
+	}
+
+	active, err := clnt.GetActiveJobs(ctx)
+	if err != nil {
+		t.Fatalf("Client.GetActiveJobs: %s", err)
+	}
+
+	if len(active.ActiveJobs.JobSummary) != 0 {
+		t.Errorf("Client.GetActiveJobs: canceled job still active")
+	}
+
+	_, err = clnt.CancelJob(ctx, job.JobID)
+	if err == nil {
+		t.Errorf("Client.CancelJob: error expected for canceled job")
+	}
+
+	// Unsupported resolution with MustHonor causes SOAP fault
+	ms.MediaFront.Resolution = optional.New(Resolution{
+		MustHonor: optional.New(BooleanElement("true")),
+		Width:     ValWithOptions[int]{Val: 1234},
+		Height:    ValWithOptions[int]{Val: 1234},
+	})
+	dp.MediaSides = optional.New(ms)
+	ticket.DocumentParameters = optional.New(dp)
+
+	_, err = clnt.CreateScanJob(ctx,
+		&CreateScanJobRequest{ScanTicket: ticket})
+
+	var fault *Fault
+	switch {
+	case err == nil:
+		t.Errorf("Client.CreateScanJob: fault expected")
+	case !errors.As(err, &fault):
+		t.Errorf("Client.CreateScanJob: fault expected, present %s", err)
+	case fault.Code != FaultSender ||
+		fault.Subcode != FaultClientErrorInvalidResolution:
+		t.Errorf("Client.CreateScanJob: unexpected fault %s", fault)
+	}
+}
+
+// TestFault tests Fault encoding and decoding
+func TestFault(t *testing.T) {
+	fault := &Fault{
+		Code:    FaultSender,
+		Subcode: FaultClientErrorInvalidResolution,
+		Reason:  "resolution not supported",
+	}
+
+	msg := Message{
+		Header: Header{
+			Action:    ActFault,
+			MessageID: "urn:uuid:1cf1a5e1-4d2b-4a8e-9c0a-6b1e9f0c2a11",
+		},
+		Body: fault,
+	}
+
+	xml, err := xmldoc.Decode(NsMap, bytes.NewReader(msg.Encode()))
+	if err != nil {
+		t.Fatalf("xmldoc.Decode: %s", err)
+	}
+
+	msg2, err := DecodeMessage(xml)
+	if err != nil {
+		t.Fatalf("DecodeMessage: %s", err)
+	}
+
+	diff := testutils.Diff(msg, msg2)
+	if diff != "" {
+		t.Errorf("Fault round-trip:\n%s", diff)
+	}
+}
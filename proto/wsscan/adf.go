@@ -59,7 +59,7 @@ func decodeADF(root xmldoc.Element) (ADF, error) {
 
 	missed := root.Lookup(&adfBack, &adfFront, &adfSupportsDuplex)
 	if missed != nil {
-		return a, xmldoc.XMLErrMissed(missed.Name)
+		return a, errMissed(missed.Name)
 	}
 
 	if adfBack.Found {
@@ -64,7 +64,7 @@ func decodeADFSide(root xmldoc.Element) (
 		&adfResolutions,
 	)
 	if missed != nil {
-		return s, xmldoc.XMLErrMissed(missed.Name)
+		return s, errMissed(missed.Name)
 	}
 
 	for _, child := range adfColor.Elem.Children {
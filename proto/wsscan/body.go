@@ -19,6 +19,7 @@ import "github.com/OpenPrinting/go-mfp/util/xmldoc"
 //   - [CreateScanJobResponse]
 //   - [RetrieveImageRequest]
 //   - [RetrieveImageResponse]
+//   - [Fault]
 type Body interface {
 	// Action returns the [Action] associated with this body.
 	Action() Action
@@ -49,7 +49,7 @@ func decodeCancelJobRequest(root xmldoc.Element) (CancelJobRequest, error) {
 	}
 
 	if missed := root.Lookup(&jobID); missed != nil {
-		return r, xmldoc.XMLErrMissed(missed.Name)
+		return r, errMissed(missed.Name)
 	}
 
 	var err error
@@ -57,7 +57,13 @@ func decodeCancelJobRequest(root xmldoc.Element) (CancelJobRequest, error) {
 		return r, fmt.Errorf("JobId: %w", err)
 	}
 	if r.JobID < 1 {
-		return r, fmt.Errorf("JobId: must be at least 1, got %d", r.JobID)
+		return r, &InvalidValueError{
+			Path:       jobID.Elem.Name,
+			Value:      jobID.Elem.Text,
+			Constraint: "at least 1",
+			text: fmt.Sprintf("JobId: must be at least 1, got %d",
+				r.JobID),
+		}
 	}
 
 	return r, nil
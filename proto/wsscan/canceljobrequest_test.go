@@ -60,7 +60,11 @@ func TestCancelJobRequest_MissingJobId(t *testing.T) {
 	elm := xmldoc.Element{Name: NsWSCN + ":CancelJobRequest"}
 	_, err := decodeCancelJobRequest(elm)
 	if err == nil {
-		t.Error("expected error for missing JobId, got nil")
+		t.Fatal("expected error for missing JobId, got nil")
+	}
+
+	if _, ok := AsMissingElementError(err); !ok {
+		t.Errorf("expected MissingElementError, got %T", err)
 	}
 }
 
@@ -70,6 +74,17 @@ func TestCancelJobRequest_ZeroJobId(t *testing.T) {
 	elm := orig.toXML(NsWSCN + ":CancelJobRequest")
 	_, err := decodeCancelJobRequest(elm)
 	if err == nil {
-		t.Error("expected error for JobId=0, got nil")
+		t.Fatal("expected error for JobId=0, got nil")
+	}
+
+	e, ok := AsInvalidValueError(err)
+	if !ok {
+		t.Fatalf("expected InvalidValueError, got %T", err)
+	}
+	if e.Value != "0" || e.Constraint != "at least 1" {
+		t.Errorf("unexpected error fields: %+v", e)
+	}
+	if err.Error() != "JobId: must be at least 1, got 0" {
+		t.Errorf("unexpected error text: %s", err)
 	}
 }
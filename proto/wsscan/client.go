@@ -13,15 +13,13 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"mime"
-	"mime/multipart"
 	"net/http"
 	"net/url"
 
+	"github.com/OpenPrinting/go-mfp/proto/wsscan/mtom"
 	"github.com/OpenPrinting/go-mfp/transport"
 	"github.com/OpenPrinting/go-mfp/util/optional"
 	"github.com/OpenPrinting/go-mfp/util/uuid"
-	"github.com/OpenPrinting/go-mfp/util/xmldoc"
 )
 
 // Client implements a low-level WS-Scan client.
@@ -34,6 +32,11 @@ type Client struct {
 //
 // If tr is nil, [transport.NewTransport] will be used to create
 // a new transport.
+//
+// To share connections with clients of other protocols, talking
+// to the same device, use the [transport.SharedPool] transport:
+//
+//	clnt := NewClient(u, transport.PoolFor(u).Transport())
 func NewClient(u *url.URL, tr *transport.Transport) *Client {
 	return &Client{
 		url:        transport.URLClone(u),
@@ -188,41 +191,15 @@ func (c *Client) RetrieveImage(
 		return nil, err
 	}
 
-	mediaType, params, err := mime.ParseMediaType(
-		httpRsp.Header.Get("Content-Type"))
+	// Parse the MTOM response. The image is streamed directly
+	// from the HTTP response body.
+	root, attachments, err := mtom.ParseResponse(
+		httpRsp.Header.Get("Content-Type"), httpRsp.Body, NsMap)
 	if err != nil {
 		httpRsp.Body.Close()
-		return nil, fmt.Errorf("wsscan: invalid Content-Type: %w", err)
+		return nil, fmt.Errorf("wsscan: %w", err)
 	}
-	if mediaType != "multipart/related" {
-		httpRsp.Body.Close()
-		return nil, fmt.Errorf("wsscan: expected multipart/related, got %q",
-			mediaType)
-	}
-	boundary := params["boundary"]
-	if boundary == "" {
-		httpRsp.Body.Close()
-		return nil, fmt.Errorf("wsscan: missing multipart boundary")
-	}
-
-	mr := multipart.NewReader(httpRsp.Body, boundary)
 
-	// Part 1: SOAP envelope
-	soapPart, err := mr.NextPart()
-	if err != nil {
-		httpRsp.Body.Close()
-		return nil, fmt.Errorf("wsscan: reading SOAP part: %w", err)
-	}
-	soapData, err := io.ReadAll(soapPart)
-	if err != nil {
-		httpRsp.Body.Close()
-		return nil, fmt.Errorf("wsscan: reading SOAP part: %w", err)
-	}
-	root, err := xmldoc.Decode(NsMap, bytes.NewReader(soapData))
-	if err != nil {
-		httpRsp.Body.Close()
-		return nil, fmt.Errorf("wsscan: decoding SOAP envelope: %w", err)
-	}
 	msg, err := DecodeMessage(root)
 	if err != nil {
 		httpRsp.Body.Close()
@@ -234,17 +211,18 @@ func (c *Client) RetrieveImage(
 		return nil, fmt.Errorf("wsscan: unexpected response type %T", msg.Body)
 	}
 
-	// Part 2: image data — streamed; closing Image closes httpRsp.Body
-	imagePart, err := mr.NextPart()
+	// Resolve the xop:Include reference. Closing Image closes
+	// httpRsp.Body
+	image, err := attachments(rsp.ScanData.ContentID)
 	if err != nil {
 		httpRsp.Body.Close()
-		return nil, fmt.Errorf("wsscan: reading image part: %w", err)
+		return nil, fmt.Errorf("wsscan: %w", err)
 	}
-	rsp.ContentType = imagePart.Header.Get("Content-Type")
+	rsp.ContentType = image.ContentType
 	rsp.Image = struct {
 		io.Reader
 		io.Closer
-	}{imagePart, httpRsp.Body}
+	}{image, httpRsp.Body}
 
 	return rsp, nil
 }
@@ -280,14 +258,43 @@ func (c *Client) doRequest(
 	}
 
 	if httpRsp.StatusCode/100 != http.StatusOK/100 {
-		httpRsp.Body.Close()
-		return nil, fmt.Errorf("HTTP %d: %s",
-			httpRsp.StatusCode, httpRsp.Status)
+		defer httpRsp.Body.Close()
+		return nil, c.decodeFault(httpRsp)
 	}
 
 	return httpRsp, nil
 }
 
+// decodeFault decodes the failed HTTP response.
+//
+// If response contains the SOAP Fault, it is returned as [*Fault].
+// Otherwise, the generic HTTP error is returned.
+func (c *Client) decodeFault(httpRsp *http.Response) error {
+	httpErr := fmt.Errorf("HTTP %d: %s",
+		httpRsp.StatusCode, httpRsp.Status)
+
+	data, err := io.ReadAll(io.LimitReader(httpRsp.Body, faultMaxSize))
+	if err != nil {
+		return httpErr
+	}
+
+	root, err := decodeXML(data)
+	if err != nil {
+		return httpErr
+	}
+
+	msg, err := DecodeMessage(root)
+	if err != nil {
+		return httpErr
+	}
+
+	if fault, ok := msg.Body.(*Fault); ok {
+		return fault
+	}
+
+	return httpErr
+}
+
 // sendSOAP wraps body in a SOAP envelope, POSTs it to the server,
 // and returns the decoded response [Message].
 func (c *Client) sendSOAP(ctx context.Context, body Body) (Message, error) {
@@ -302,7 +309,7 @@ func (c *Client) sendSOAP(ctx context.Context, body Body) (Message, error) {
 		return Message{}, err
 	}
 
-	root, err := xmldoc.Decode(NsMap, bytes.NewReader(rspData))
+	root, err := decodeXML(rspData)
 	if err != nil {
 		return Message{}, err
 	}
@@ -82,7 +82,7 @@ func decodeConditionHistoryEntry(root xmldoc.Element) (
 		&time,
 	)
 	if missed != nil {
-		return che, xmldoc.XMLErrMissed(missed.Name)
+		return che, errMissed(missed.Name)
 	}
 
 	if che.ClearTime, err = decodeTime(clearTime.Elem); err != nil {
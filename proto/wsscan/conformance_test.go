@@ -0,0 +1,296 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// WS-Scan core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Conformance tests with the WS-Scan specification examples
+
+package wsscan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// testConformanceDir is the directory with the conformance test vectors
+const testConformanceDir = "testdata/conformance"
+
+// testConformanceVector is the conformance test vector.
+//
+// Each vector is the XML document in the testConformanceDir,
+// with the body element (CreateScanJobRequest, ScanTicket
+// and so on) at its root.
+//
+// To add a new vector, drop the document into the testdata
+// directory and add its entry into testConformanceVectors.
+type testConformanceVector struct {
+	// File name, relative to testConformanceDir
+	file string
+
+	// Returns selected fields of decoded value, with the
+	// expected values
+	expect func(v any) []testConformanceField
+
+	// Known gaps in the element coverage: items of the
+	// original document, dropped on decode/encode round trip,
+	// in the path=value or path/@attr=value form.
+	gaps []string
+}
+
+// testConformanceField is the field of the decoded value, compared
+// with the expected value.
+type testConformanceField struct {
+	name              string
+	present, expected any
+}
+
+// testConformanceBody is the common interface of decoded values
+type testConformanceBody interface {
+	toXML(name string) xmldoc.Element
+}
+
+// testConformanceDecoders contains decoders, indexed by name of
+// the root element
+var testConformanceDecoders = map[string]func(xmldoc.Element) (
+	testConformanceBody, error){
+
+	NsWSCN + ":CreateScanJobRequest": testConformanceDecoder(
+		decodeCreateScanJobRequest),
+	NsWSCN + ":GetScannerElementsResponse": testConformanceDecoder(
+		decodeGetScannerElementsResponse),
+	NsWSCN + ":ScanTicket": testConformanceDecoder(
+		decodeScanTicket),
+}
+
+// testConformanceDecoder converts typed decode function into
+// the testConformanceDecoders entry.
+func testConformanceDecoder[T testConformanceBody](
+	decode func(xmldoc.Element) (T, error)) func(xmldoc.Element) (
+	testConformanceBody, error) {
+
+	return func(root xmldoc.Element) (testConformanceBody, error) {
+		return decode(root)
+	}
+}
+
+// testConformanceVectors contains the conformance test vectors
+var testConformanceVectors = []testConformanceVector{
+	{
+		file: "CreateScanJobRequest.xml",
+		expect: func(v any) []testConformanceField {
+			csjr := v.(CreateScanJobRequest)
+			jd := csjr.ScanTicket.JobDescription
+			dp := optional.Get(csjr.ScanTicket.DocumentParameters)
+			front := optional.Get(dp.MediaSides).MediaFront
+			return []testConformanceField{
+				{"ScanIdentifier",
+					optional.Get(csjr.ScanIdentifier),
+					"a52a9d10-7c5a-4c1b-9ae3-3c6fd8eb1c9c"},
+				{"DestinationToken",
+					optional.Get(csjr.DestinationToken),
+					"Client_Token_1"},
+				{"JobName", jd.JobName, "Scanning job"},
+				{"JobOriginatingUserName",
+					jd.JobOriginatingUserName, `Contoso\user`},
+				{"Format", optional.Get(dp.Format).Val, JFIF},
+				{"Format.MustHonor",
+					optional.Get(optional.Get(dp.Format).MustHonor),
+					BooleanElement("true")},
+				{"ImagesToTransfer",
+					optional.Get(dp.ImagesToTransfer).Val, 1},
+				{"InputSource",
+					optional.Get(dp.InputSource).Val, InputSourcePlaten},
+				{"ColorProcessing",
+					optional.Get(front.ColorProcessing).Val, RGB24},
+				{"Resolution.Width",
+					optional.Get(front.Resolution).Width.Val, 300},
+			}
+		},
+	},
+	{
+		file: "ScanTicket.xml",
+		expect: func(v any) []testConformanceField {
+			st := v.(ScanTicket)
+			dp := optional.Get(st.DocumentParameters)
+			sides := optional.Get(dp.MediaSides)
+			return []testConformanceField{
+				{"JobName", st.JobDescription.JobName, "Duplex scan"},
+				{"Format", optional.Get(dp.Format).Val, PDFA},
+				{"InputSource",
+					optional.Get(dp.InputSource).Val, InputSourceADFDuplex},
+				{"MediaBack", sides.MediaBack != nil, true},
+				{"MediaBack.ColorProcessing",
+					optional.Get(optional.Get(sides.MediaBack).
+						ColorProcessing).Val, Grayscale8},
+			}
+		},
+	},
+	{
+		file: "GetScannerElementsResponse.xml",
+		expect: func(v any) []testConformanceField {
+			rsp := v.(GetScannerElementsResponse)
+			elems := rsp.ScannerElements
+			desc := optional.Get(elems[0].ScannerDescription)
+			conf := optional.Get(elems[1].ScannerConfiguration)
+			stat := optional.Get(elems[2].ScannerStatus)
+			return []testConformanceField{
+				{"len(ScannerElements)", len(elems), 3},
+				{"ScannerName", desc.ScannerName[0].Text,
+					"Contoso ScanMaster 2000"},
+				{"FormatsSupported",
+					conf.DeviceSettings.FormatsSupported,
+					[]FormatValue{JFIF, PDFA, TIFFSingleUncompressed}},
+				{"ADFSupportsDuplex",
+					optional.Get(conf.ADF).ADFSupportsDuplex,
+					BooleanElement("true")},
+				{"ScannerState", stat.ScannerState, Idle},
+				{"ScannerCurrentTime", stat.ScannerCurrentTime,
+					time.Date(2006, 6, 14, 14, 42, 5, 0, time.UTC)},
+				{"len(ConditionHistory)",
+					len(stat.ConditionHistory), 1},
+			}
+		},
+	},
+}
+
+// TestConformance runs the conformance tests
+func TestConformance(t *testing.T) {
+	for _, vec := range testConformanceVectors {
+		t.Run(vec.file, func(t *testing.T) {
+			vec.run(t)
+		})
+	}
+}
+
+// run runs the single conformance test
+func (vec testConformanceVector) run(t *testing.T) {
+	// Load and decode the document
+	data, err := os.ReadFile(filepath.Join(testConformanceDir, vec.file))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	orig, err := xmldoc.Decode(NsMap, strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("xmldoc.Decode: %s", err)
+	}
+
+	decode := testConformanceDecoders[orig.Name]
+	if decode == nil {
+		t.Fatalf("%s: unknown root element", orig.Name)
+	}
+
+	body, err := decode(orig)
+	if err != nil {
+		t.Fatalf("decode: %s", err)
+	}
+
+	// Check selected fields
+	for _, f := range vec.expect(body) {
+		if !reflect.DeepEqual(f.present, f.expected) {
+			t.Errorf("%s:\nexpected: %#v\npresent:  %#v",
+				f.name, f.expected, f.present)
+		}
+	}
+
+	// Re-encode and compare with the original
+	encoded := body.toXML(orig.Name)
+	if orig.Similar(encoded) {
+		if len(vec.gaps) != 0 {
+			t.Errorf("known gaps are not seen anymore; " +
+				"please update the test vector")
+		}
+		return
+	}
+
+	dropped, added := testConformanceDiff(orig, encoded)
+
+	gaps := make(map[string]struct{}, len(vec.gaps))
+	for _, gap := range vec.gaps {
+		gaps[gap] = struct{}{}
+	}
+
+	for _, item := range dropped {
+		if _, known := gaps[item]; known {
+			delete(gaps, item)
+			t.Logf("known gap: %s", item)
+		} else {
+			t.Errorf("dropped: %s", item)
+		}
+	}
+
+	for _, item := range added {
+		t.Errorf("added: %s", item)
+	}
+
+	for gap := range gaps {
+		t.Errorf("known gap is not seen anymore: %s", gap)
+	}
+
+	if t.Failed() {
+		t.Logf("original:\n%s", orig.EncodeCanonicalString(NsMap))
+		t.Logf("re-encoded:\n%s", encoded.EncodeCanonicalString(NsMap))
+	}
+}
+
+// testConformanceDiff compares two XML trees and returns items,
+// present only in the first and only in the second tree.
+//
+// Items are elements, in the path=text form, and attributes,
+// in the path/@name=value form.
+func testConformanceDiff(xml1, xml2 xmldoc.Element) (only1, only2 []string) {
+	items1 := testConformanceItems(xml1)
+	items2 := testConformanceItems(xml2)
+
+	for item, cnt := range items1 {
+		for i := items2[item]; i < cnt; i++ {
+			only1 = append(only1, item)
+		}
+	}
+
+	for item, cnt := range items2 {
+		for i := items1[item]; i < cnt; i++ {
+			only2 = append(only2, item)
+		}
+	}
+
+	sort.Strings(only1)
+	sort.Strings(only2)
+
+	return
+}
+
+// testConformanceItems returns items of the XML tree with their
+// counts. See testConformanceDiff for details.
+func testConformanceItems(root xmldoc.Element) map[string]int {
+	items := make(map[string]int)
+	testConformanceItemsRecursive(items, "", root)
+	return items
+}
+
+// testConformanceItemsRecursive adds items of the XML tree into
+// the map, recursively.
+func testConformanceItemsRecursive(items map[string]int,
+	parent string, elem xmldoc.Element) {
+
+	path := parent + "/" + elem.Name
+
+	items[fmt.Sprintf("%s=%s", path, elem.Text)]++
+	for _, attr := range elem.Attrs {
+		items[fmt.Sprintf("%s/@%s=%s", path, attr.Name, attr.Value)]++
+	}
+
+	for _, child := range elem.Children {
+		testConformanceItemsRecursive(items, path, child)
+	}
+}
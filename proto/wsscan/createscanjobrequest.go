@@ -80,7 +80,7 @@ func decodeCreateScanJobRequest(root xmldoc.Element) (
 		&destinationToken,
 		&scanIdentifier,
 	); missed != nil {
-		return csjr, xmldoc.XMLErrMissed(missed.Name)
+		return csjr, errMissed(missed.Name)
 	}
 
 	if csjr.ScanTicket, err = decodeScanTicket(scanTicket.Elem); err != nil {
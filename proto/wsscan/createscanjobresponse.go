@@ -74,7 +74,7 @@ func decodeCreateScanJobResponse(root xmldoc.Element) (
 		&documentFinalParameters, &imageInformation,
 		&jobID, &jobToken,
 	); missed != nil {
-		return r, xmldoc.XMLErrMissed(missed.Name)
+		return r, errMissed(missed.Name)
 	}
 
 	var err error
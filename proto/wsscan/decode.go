@@ -27,9 +27,20 @@ func decodeInt(root xmldoc.Element) (v int, err error) {
 
 	switch {
 	case err != nil:
-		err = fmt.Errorf("invalid int: %q", root.Text)
+		err = &InvalidValueError{
+			Path:       root.Name,
+			Value:      root.Text,
+			Constraint: "int",
+			text:       fmt.Sprintf("invalid int: %q", root.Text),
+		}
 	case v64 < math.MinInt32 || v64 > math.MaxInt32:
-		err = fmt.Errorf("int out of range: %d", v64)
+		err = &InvalidValueError{
+			Path:  root.Name,
+			Value: root.Text,
+			Constraint: fmt.Sprintf("%d...%d",
+				math.MinInt32, math.MaxInt32),
+			text: fmt.Sprintf("int out of range: %d", v64),
+		}
 	}
 
 	if err != nil {
@@ -47,9 +58,19 @@ func decodeNonNegativeInt(root xmldoc.Element) (v int, err error) {
 
 	switch {
 	case err != nil:
-		err = fmt.Errorf("invalid int: %q", root.Text)
+		err = &InvalidValueError{
+			Path:       root.Name,
+			Value:      root.Text,
+			Constraint: "int",
+			text:       fmt.Sprintf("invalid int: %q", root.Text),
+		}
 	case v64 < 0 || v64 > math.MaxInt32:
-		err = fmt.Errorf("int out of range: %d", v64)
+		err = &InvalidValueError{
+			Path:       root.Name,
+			Value:      root.Text,
+			Constraint: fmt.Sprintf("0...%d", math.MaxInt32),
+			text:       fmt.Sprintf("int out of range: %d", v64),
+		}
 	}
 
 	if err != nil {
@@ -69,7 +90,12 @@ func decodeBool(root xmldoc.Element) (v bool, err error) {
 		return false, nil
 	}
 
-	err = fmt.Errorf("invalid bool: %q", root.Text)
+	err = &InvalidValueError{
+		Path:       root.Name,
+		Value:      root.Text,
+		Constraint: "bool",
+		text:       fmt.Sprintf("invalid bool: %q", root.Text),
+	}
 	err = xmldoc.XMLErrWrap(root, err)
 
 	return
@@ -102,7 +128,12 @@ func decodeNMTOKEN(root xmldoc.Element) (v string, err error) {
 	}
 
 ERROR:
-	err = fmt.Errorf("invalid xs:NMTOKEN: %q", root.Text)
+	err = &InvalidValueError{
+		Path:       root.Name,
+		Value:      root.Text,
+		Constraint: "xs:NMTOKEN",
+		text:       fmt.Sprintf("invalid xs:NMTOKEN: %q", root.Text),
+	}
 	err = xmldoc.XMLErrWrap(root, err)
 
 	return
@@ -125,7 +156,12 @@ func decodeEnum[T ~int](root xmldoc.Element,
 		typeName = typeName[i+1:]
 	}
 
-	err = fmt.Errorf("invalid %s: %q", typeName, root.Text)
+	err = &InvalidValueError{
+		Path:       root.Name,
+		Value:      root.Text,
+		Constraint: typeName,
+		text:       fmt.Sprintf("invalid %s: %q", typeName, root.Text),
+	}
 	err = xmldoc.XMLErrWrap(root, err)
 
 	return
@@ -148,7 +184,12 @@ func decodeOptional[T any](root xmldoc.Element,
 func decodeTime(root xmldoc.Element) (v time.Time, err error) {
 	v, err = time.Parse(time.RFC3339, root.Text)
 	if err != nil {
-		err = fmt.Errorf("invalid time: %q", root.Text)
+		err = &InvalidValueError{
+			Path:       root.Name,
+			Value:      root.Text,
+			Constraint: "RFC 3339 time",
+			text:       fmt.Sprintf("invalid time: %q", root.Text),
+		}
 		err = xmldoc.XMLErrWrap(root, err)
 	}
 	return
@@ -0,0 +1,166 @@
+// MFP - Multi-Function Printers and scanners toolkit
+// WS-Scan core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Typed decode errors
+
+package wsscan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// MissingElementError is returned by decoders, when the required
+// XML element is missed.
+//
+// Decoders return it wrapped, so use [errors.As] or
+// [AsMissingElementError] to extract it.
+type MissingElementError struct {
+	Path string // Name or path of the missed element
+}
+
+// InvalidValueError is returned by decoders, when value of the
+// XML element cannot be parsed or violates a constraint.
+//
+// Decoders return it wrapped, so use [errors.As] or
+// [AsInvalidValueError] to extract it.
+type InvalidValueError struct {
+	Path       string // Name or path of the element
+	Value      string // The invalid value, as string
+	Constraint string // Violated constraint, human-readable
+
+	text string // Error text, if differs from the default
+}
+
+// InvalidAttributeError is returned by decoders, when value of the
+// XML attribute is invalid.
+//
+// Decoders return it wrapped, so use [errors.As] or
+// [AsInvalidAttributeError] to extract it.
+type InvalidAttributeError struct {
+	Path  string // Name or path of the element
+	Attr  string // Attribute name
+	Value string // The invalid value
+
+	text string // Error text, if differs from the default
+}
+
+// Error returns the error string. It implements the error interface.
+//
+// Path is not included, as decoders report it via [xmldoc.XMLErr].
+func (e *MissingElementError) Error() string {
+	return "missed"
+}
+
+// Error returns the error string. It implements the error interface.
+func (e *InvalidValueError) Error() string {
+	if e.text != "" {
+		return e.text
+	}
+	return fmt.Sprintf("invalid value %q: %s", e.Value, e.Constraint)
+}
+
+// Error returns the error string. It implements the error interface.
+func (e *InvalidAttributeError) Error() string {
+	if e.text != "" {
+		return e.text
+	}
+	return fmt.Sprintf("@%s: invalid value %q", e.Attr, e.Value)
+}
+
+// AsMissingElementError extracts [MissingElementError] from the
+// chain of wrapped errors.
+func AsMissingElementError(err error) (*MissingElementError, bool) {
+	var e *MissingElementError
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+// AsInvalidValueError extracts [InvalidValueError] from the
+// chain of wrapped errors.
+func AsInvalidValueError(err error) (*InvalidValueError, bool) {
+	var e *InvalidValueError
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+// AsInvalidAttributeError extracts [InvalidAttributeError] from the
+// chain of wrapped errors.
+func AsInvalidAttributeError(err error) (*InvalidAttributeError, bool) {
+	var e *InvalidAttributeError
+	ok := errors.As(err, &e)
+	return e, ok
+}
+
+// ToSOAPFault maps the error, returned by decoders or by request
+// handlers, into the SOAP [Fault]:
+//   - [Fault] is returned as is
+//   - [MissingElementError] and [InvalidAttributeError] become
+//     the InvalidArgs fault
+//   - [InvalidValueError] becomes the specific validation fault
+//     (ClientErrorInvalidResolution, ClientErrorInvalidRegionArea,
+//     ClientErrorFormatNotSupported), if element is known, or
+//     the InvalidArgs fault otherwise
+//   - other errors become the OperationFailed fault.
+//
+// It returns nil, if err is nil.
+func ToSOAPFault(err error) *Fault {
+	var fault *Fault
+	if err == nil || errors.As(err, &fault) {
+		return fault
+	}
+
+	if _, ok := AsMissingElementError(err); ok {
+		return faultFromError(FaultSender, FaultInvalidArgs, err)
+	}
+
+	if _, ok := AsInvalidAttributeError(err); ok {
+		return faultFromError(FaultSender, FaultInvalidArgs, err)
+	}
+
+	if e, ok := AsInvalidValueError(err); ok {
+		return faultFromError(FaultSender,
+			decodeErrFaultSubcode(e.Path), err)
+	}
+
+	return faultFromError(FaultReceiver, FaultOperationFailed, err)
+}
+
+// decodeErrFaultSubcode returns the fault subcode for the
+// invalid value of the element with the specified path.
+func decodeErrFaultSubcode(path string) string {
+	switch {
+	case strings.Contains(path, "Resolution"):
+		return FaultClientErrorInvalidResolution
+	case strings.Contains(path, "ScanRegion"):
+		return FaultClientErrorInvalidRegionArea
+	case strings.HasSuffix(path, ":Format"):
+		return FaultClientErrorFormatNotSupported
+	}
+
+	return FaultInvalidArgs
+}
+
+// errMissed creates the [MissingElementError], wrapped into the
+// [xmldoc.XMLErr]. It is the drop-in replacement for the
+// [xmldoc.XMLErrMissed].
+func errMissed(name string) error {
+	return xmldoc.XMLErrWrapName(name, &MissingElementError{Path: name})
+}
+
+// errInvalidAttr creates the [InvalidAttributeError] for the attribute
+// of the element. The error text is taken from err.
+func errInvalidAttr(root xmldoc.Element, attr xmldoc.Attr, err error) error {
+	return &InvalidAttributeError{
+		Path:  root.Name,
+		Attr:  attr.Name,
+		Value: attr.Value,
+		text:  err.Error(),
+	}
+}
@@ -0,0 +1,102 @@
+// MFP - Multi-Function Printers and scanners toolkit
+// WS-Scan core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Typed decode errors tests
+
+package wsscan
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// TestDecodeErrChain tests extraction of the typed errors
+// through the chain of wrapped errors.
+func TestDecodeErrChain(t *testing.T) {
+	// Out of range value, wrapped multiple times
+	_, err := decodeInt(xmldoc.WithText(NsWSCN+":Width", "2147483648"))
+	err = xmldoc.XMLErrWrapName(NsWSCN+":Resolution", err)
+	err = fmt.Errorf("DocumentParameters: %w", err)
+
+	e, ok := AsInvalidValueError(err)
+	switch {
+	case !ok:
+		t.Fatalf("InvalidValueError not found in %q", err)
+	case e.Path != NsWSCN+":Width" || e.Value != "2147483648":
+		t.Errorf("unexpected error fields: %+v", e)
+	}
+
+	expected := "DocumentParameters: /wscn:Resolution/wscn:Width: " +
+		"int out of range: 2147483648"
+	if err.Error() != expected {
+		t.Errorf("error text:\nexpected: %s\npresent:  %s",
+			expected, err)
+	}
+
+	// Missed element
+	err = fmt.Errorf("ScanTicket: %w", errMissed(NsWSCN+":JobName"))
+	if _, ok := AsMissingElementError(err); !ok {
+		t.Errorf("MissingElementError not found in %q", err)
+	}
+
+	if _, ok := AsInvalidValueError(err); ok {
+		t.Errorf("unexpected InvalidValueError in %q", err)
+	}
+
+	if err.Error() != "ScanTicket: /wscn:JobName: missed" {
+		t.Errorf("unexpected error text: %s", err)
+	}
+}
+
+// TestToSOAPFault tests ToSOAPFault
+func TestToSOAPFault(t *testing.T) {
+	badValue := func(name, text string) error {
+		_, err := decodeInt(xmldoc.WithText(name, text))
+		return fmt.Errorf("wrapped: %w", err)
+	}
+
+	tests := []struct {
+		err     error
+		code    FaultCode
+		subcode string
+	}{
+		{errMissed(NsWSCN + ":ScalingWidth"),
+			FaultSender, FaultInvalidArgs},
+		{badValue(NsWSCN+":ScalingWidth", "bad"),
+			FaultSender, FaultInvalidArgs},
+		{badValue(NsWSCN+":XResolution", "bad"),
+			FaultSender, FaultClientErrorInvalidResolution},
+		{badValue(NsWSCN+":ScanRegionWidth", "bad"),
+			FaultSender, FaultClientErrorInvalidRegionArea},
+		{&InvalidAttributeError{Attr: "wscn:MustHonor"},
+			FaultSender, FaultInvalidArgs},
+		{errors.New("I/O error"),
+			FaultReceiver, FaultOperationFailed},
+		{&Fault{Code: FaultSender, Subcode: FaultClientErrorJobIDNotFound},
+			FaultSender, FaultClientErrorJobIDNotFound},
+	}
+
+	for _, test := range tests {
+		fault := ToSOAPFault(test.err)
+		if fault == nil {
+			t.Errorf("%q: nil fault", test.err)
+			continue
+		}
+
+		if fault.Code != test.code || fault.Subcode != test.subcode {
+			t.Errorf("%q: expected %s/%s, present %s/%s",
+				test.err, test.code, test.subcode,
+				fault.Code, fault.Subcode)
+		}
+	}
+
+	if ToSOAPFault(nil) != nil {
+		t.Errorf("nil error: non-nil fault")
+	}
+}
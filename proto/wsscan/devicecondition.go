@@ -70,7 +70,7 @@ func decodeDeviceCondition(root xmldoc.Element) (
 		&time,
 	)
 	if missed != nil {
-		return dc, xmldoc.XMLErrMissed(missed.Name)
+		return dc, errMissed(missed.Name)
 	}
 
 	if dc.Component, err = decodeComponent(component.Elem); err != nil {
@@ -132,7 +132,7 @@ func decodeDeviceSettings(root xmldoc.Element) (ds DeviceSettings, err error) {
 		&scalingRangeSupported,
 	)
 	if missed != nil {
-		return ds, xmldoc.XMLErrMissed(missed.Name)
+		return ds, errMissed(missed.Name)
 	}
 
 	if ds.AutoExposureSupported, err = decodeBooleanElement(
@@ -44,7 +44,7 @@ func decodeDocument(root xmldoc.Element) (
 
 	missed := root.Lookup(&documentDescription)
 	if missed != nil && missed.Required {
-		return d, xmldoc.XMLErrMissed(missed.Name)
+		return d, errMissed(missed.Name)
 	}
 
 	// Decode DocumentDescription
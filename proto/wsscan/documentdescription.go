@@ -48,7 +48,7 @@ func decodeDocumentDescription(root xmldoc.Element) (
 
 	missed := root.Lookup(&documentName)
 	if missed != nil && missed.Required {
-		return dd, xmldoc.XMLErrMissed(missed.Name)
+		return dd, errMissed(missed.Name)
 	}
 
 	// Decode DocumentName (required)
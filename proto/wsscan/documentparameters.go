@@ -11,6 +11,7 @@ package wsscan
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/OpenPrinting/go-mfp/util/optional"
 	"github.com/OpenPrinting/go-mfp/util/xmldoc"
@@ -30,6 +31,11 @@ type DocumentParameters struct {
 	MediaSides               optional.Val[MediaSides]
 	Rotation                 optional.Val[ValWithOptions[RotationValue]]
 	Scaling                  optional.Val[Scaling]
+
+	// Extensions contains vendor extension elements, not
+	// recognized by the decoder. They are re-emitted verbatim
+	// at the end of the XML output.
+	Extensions []xmldoc.Element
 }
 
 // toXML generates XML tree for the DocumentParameters.
@@ -102,6 +108,8 @@ func (dp DocumentParameters) toXML(name string) xmldoc.Element {
 			NsWSCN+":Scaling"))
 	}
 
+	children = append(children, dp.Extensions...)
+
 	return xmldoc.Element{
 		Name:     name,
 		Children: children,
@@ -285,5 +293,42 @@ func decodeDocumentParameters(root xmldoc.Element) (
 		dp.Scaling = optional.New(scl)
 	}
 
-	return dp, nil
+	// Preserve vendor extensions
+	dp.Extensions, err = decodeExtensions(root,
+		&compressionQualityFactor,
+		&contentType,
+		&exposure,
+		&filmScanMode,
+		&format,
+		&imagesToTransfer,
+		&inputSize,
+		&inputSource,
+		&mediaSides,
+		&rotation,
+		&scaling,
+	)
+
+	return dp, err
+}
+
+// Canonicalize returns the canonical form of the DocumentParameters,
+// suitable for comparison.
+//
+// Unless opt.Extensions is set, vendor extension elements are
+// removed. opt may be nil, which implies defaults.
+func (dp DocumentParameters) Canonicalize(
+	opt *CompareOptions) DocumentParameters {
+
+	dp.Extensions = extensionsCanonical(dp.Extensions, opt)
+	return dp
+}
+
+// Equal reports whether two DocumentParameters are equal.
+//
+// Vendor extension elements are compared only if opt.Extensions
+// is set. opt may be nil, which implies defaults.
+func (dp DocumentParameters) Equal(dp2 DocumentParameters,
+	opt *CompareOptions) bool {
+
+	return reflect.DeepEqual(dp.Canonicalize(opt), dp2.Canonicalize(opt))
 }
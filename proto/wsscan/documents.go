@@ -53,7 +53,7 @@ func decodeDocuments(root xmldoc.Element) (
 
 	missed := root.Lookup(&documentFinalParameters)
 	if missed != nil && missed.Required {
-		return ds, xmldoc.XMLErrMissed(missed.Name)
+		return ds, errMissed(missed.Name)
 	}
 
 	// Decode DocumentFinalParameters
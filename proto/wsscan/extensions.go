@@ -0,0 +1,291 @@
+// MFP - Multi-Function Printers and scanners toolkit
+// WS-Scan core protocol
+//
+// Copyright (C) 2024 and up by Yogesh Singla (yogeshsingla481@gmail.com)
+// See LICENSE for license terms and conditions
+//
+// Vendor extension elements
+
+package wsscan
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// Limits of the vendor extension elements, preserved per
+// parent element (i.e., per ScanTicket). Elements that exceed
+// these limits cause decoding error.
+const (
+	// ExtensionsMaxCount limits count of extension elements.
+	ExtensionsMaxCount = 32
+
+	// ExtensionsMaxSize limits total size of extension elements,
+	// including their children, in bytes of names, attribute
+	// values and text.
+	ExtensionsMaxSize = 16 * 1024
+)
+
+// CompareOptions control comparison of structures, that may
+// contain vendor extension elements (see [ScanTicket.Equal]).
+type CompareOptions struct {
+	// Extensions, if set, includes vendor extension elements
+	// into the comparison. By default they are ignored.
+	Extensions bool
+}
+
+// nsXML is the URL of the reserved "xml" namespace
+const nsXML = "http://www.w3.org/XML/1998/namespace"
+
+// decodeExtensions returns children of the root element, not
+// recognized by the decoder, as vendor extension elements.
+//
+// Known children are specified by the same Lookups, the decoder
+// uses. Extension elements are returned in their original order.
+//
+// Elements in the namespaces, which cannot be re-emitted (i.e.,
+// in the unknown namespaces, if the XML tree was not decoded with
+// [decodeXML]), are skipped.
+func decodeExtensions(root xmldoc.Element, known ...*xmldoc.Lookup) (
+	[]xmldoc.Element, error) {
+
+	var ext []xmldoc.Element
+	size := 0
+
+	for _, child := range root.Children {
+		if extensionKnown(child.Name, known) ||
+			!extensionEmittable(child) {
+			continue
+		}
+
+		ext = append(ext, child)
+		size += extensionSize(child)
+
+		switch {
+		case len(ext) > ExtensionsMaxCount:
+			err := fmt.Errorf("too many extension elements (max %d)",
+				ExtensionsMaxCount)
+			return nil, xmldoc.XMLErrWrap(child, err)
+
+		case size > ExtensionsMaxSize:
+			err := fmt.Errorf("extension elements too large (max %d bytes)",
+				ExtensionsMaxSize)
+			return nil, xmldoc.XMLErrWrap(child, err)
+		}
+	}
+
+	return ext, nil
+}
+
+// extensionKnown reports if element name is known by one
+// of Lookups.
+func extensionKnown(name string, known []*xmldoc.Lookup) bool {
+	for _, l := range known {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// extensionEmittable reports if element can be re-emitted as
+// a well-formed XML. Elements and attributes in the unknown
+// namespaces are decoded by xmldoc with the "-" prefix, and
+// their namespace cannot be restored.
+func extensionEmittable(elem xmldoc.Element) bool {
+	iter := elem.Iterate()
+	for iter.Next() {
+		e := iter.Elem()
+		if extensionUnresolved(e.Name) {
+			return false
+		}
+
+		for _, attr := range e.Attrs {
+			if extensionUnresolved(attr.Name) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// extensionUnresolved reports if name has the "-" namespace prefix,
+// which xmldoc uses for the unknown namespaces.
+func extensionUnresolved(name string) bool {
+	return strings.HasPrefix(name, "-:")
+}
+
+// extensionSize returns size of the extension element, for
+// the limits check.
+func extensionSize(elem xmldoc.Element) int {
+	size := 0
+
+	iter := elem.Iterate()
+	for iter.Next() {
+		e := iter.Elem()
+		size += len(e.Name) + len(e.Text)
+		for _, attr := range e.Attrs {
+			size += len(attr.Name) + len(attr.Value)
+		}
+	}
+
+	return size
+}
+
+// extensionsCanonical returns extension elements, prepared
+// for comparison according to the CompareOptions.
+//
+// Unless opt.Extensions is set, it returns nil, so extensions
+// don't affect comparison.
+func extensionsCanonical(ext []xmldoc.Element,
+	opt *CompareOptions) []xmldoc.Element {
+
+	if opt == nil || !opt.Extensions || len(ext) == 0 {
+		return nil
+	}
+
+	out := make([]xmldoc.Element, len(ext))
+	for i := range ext {
+		out[i] = ext[i].Canonical()
+	}
+
+	return out
+}
+
+// decodeXML decodes the WS-Scan XML document.
+//
+// Unlike plain xmldoc.Decode with [NsMap], it preserves namespaces,
+// not known to NsMap (i.e., vendor namespaces). They retain their
+// original prefixes, if possible, or are assigned the synthetic ones
+// (vnd0, vnd1, ...), and declared by the xmlns attributes at the
+// elements that use them, so vendor extension elements remain
+// self-contained and can be re-emitted verbatim.
+func decodeXML(data []byte) (xmldoc.Element, error) {
+	ns := NsMap.Clone()
+
+	// Collect unknown namespaces. Syntax errors are ignored
+	// here; they will be reported by xmldoc.Decode.
+	//
+	// Original prefixes are collected from all xmlns declarations
+	// seen so far; scoping is ignored, as it is just a hint.
+	prefixes := make(map[string]string)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		names := []xml.Name{start.Name}
+		for _, attr := range start.Attr {
+			switch {
+			case attr.Name.Space == NsXML:
+				if _, found := prefixes[attr.Value]; !found {
+					prefixes[attr.Value] = attr.Name.Local
+				}
+			case attr.Name.Local != NsXML:
+				names = append(names, attr.Name)
+			}
+		}
+
+		for _, name := range names {
+			_, known := ns.ByURL(name.Space)
+			if name.Space == "" || name.Space == nsXML || known {
+				continue
+			}
+
+			prefix := prefixes[name.Space]
+			for i := len(ns) - len(NsMap); ; i++ {
+				_, used := ns.ByPrefix(prefix)
+				if prefix != "" && !used {
+					break
+				}
+				prefix = fmt.Sprintf("vnd%d", i)
+			}
+
+			ns.Append(name.Space, prefix)
+		}
+	}
+
+	root, err := xmldoc.Decode(ns, bytes.NewReader(data))
+	if err != nil {
+		return root, err
+	}
+
+	if vendor := ns[len(NsMap):]; len(vendor) != 0 {
+		root = extensionDeclareNS(root, vendor, nil)
+	}
+
+	return root, nil
+}
+
+// extensionDeclareNS adds xmlns declarations of the vendor
+// namespaces to the elements that use them, unless already
+// declared by the ancestors, and returns the updated element.
+func extensionDeclareNS(elem xmldoc.Element, vendor xmldoc.Namespace,
+	declared map[string]struct{}) xmldoc.Element {
+
+	var attrs []xmldoc.Attr
+
+	use := func(name string) {
+		for _, ent := range vendor {
+			if strings.HasPrefix(name, ent.Prefix+":") {
+				if _, found := declared[ent.Prefix]; !found {
+					attrs = append(attrs, xmldoc.Attr{
+						Name:  NsXML + ":" + ent.Prefix,
+						Value: ent.URL,
+					})
+
+					declared = extensionDeclared(declared,
+						ent.Prefix)
+				}
+				return
+			}
+		}
+	}
+
+	use(elem.Name)
+	for _, attr := range elem.Attrs {
+		use(attr.Name)
+	}
+
+	if attrs != nil {
+		elem.Attrs = append(attrs, elem.Attrs...)
+	}
+
+	if elem.Children != nil {
+		children := make([]xmldoc.Element, len(elem.Children))
+		for i := range elem.Children {
+			children[i] = extensionDeclareNS(elem.Children[i],
+				vendor, declared)
+		}
+		elem.Children = children
+	}
+
+	return elem
+}
+
+// extensionDeclared returns copy of the set of declared prefixes,
+// extended with the new prefix. Set is copied, so siblings are
+// not affected by the declarations, made by each other.
+func extensionDeclared(declared map[string]struct{},
+	prefix string) map[string]struct{} {
+
+	out := make(map[string]struct{}, len(declared)+1)
+	for p := range declared {
+		out[p] = struct{}{}
+	}
+	out[prefix] = struct{}{}
+
+	return out
+}
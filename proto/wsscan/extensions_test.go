@@ -0,0 +1,177 @@
+// MFP - Multi-Function Printers and scanners toolkit
+// WS-Scan core protocol
+//
+// Copyright (C) 2024 and up by Yogesh Singla (yogeshsingla481@gmail.com)
+// See LICENSE for license terms and conditions
+//
+// Vendor extension elements tests
+
+package wsscan
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// testExtensionsTicket is the ScanTicket with vendor extensions
+const testExtensionsTicket = `<?xml version="1.0" encoding="UTF-8"?>
+<wscn:ScanTicket
+  xmlns:wscn="http://schemas.microsoft.com/windows/2006/08/wdp/scan"
+  xmlns:brother="http://schemas.brother.com/wsd/scan">
+  <wscn:JobDescription>
+    <wscn:JobName>Scan</wscn:JobName>
+    <wscn:JobOriginatingUserName>user</wscn:JobOriginatingUserName>
+  </wscn:JobDescription>
+  <wscn:DocumentParameters>
+    <wscn:Format>jfif</wscn:Format>
+    <brother:SpecialMode brother:level="2">
+      <brother:Option>on</brother:Option>
+    </brother:SpecialMode>
+  </wscn:DocumentParameters>
+  <brother:Hint>fast</brother:Hint>
+</wscn:ScanTicket>
+`
+
+// testExtensionsDecode decodes ScanTicket from the XML text
+func testExtensionsDecode(t *testing.T, text string) ScanTicket {
+	root, err := decodeXML([]byte(text))
+	if err != nil {
+		t.Fatalf("decodeXML: %s", err)
+	}
+
+	st, err := decodeScanTicket(root)
+	if err != nil {
+		t.Fatalf("decodeScanTicket: %s", err)
+	}
+
+	return st
+}
+
+// TestExtensionsRoundTrip tests that vendor extensions survive
+// decode->encode->decode cycle.
+func TestExtensionsRoundTrip(t *testing.T) {
+	st := testExtensionsDecode(t, testExtensionsTicket)
+
+	if len(st.Extensions) != 1 {
+		t.Fatalf("ScanTicket: expected 1 extension, present %d",
+			len(st.Extensions))
+	}
+
+	dp := st.DocumentParameters
+	if dp == nil || len((*dp).Extensions) != 1 {
+		t.Fatalf("DocumentParameters: extension not decoded")
+	}
+
+	text := st.toXML(NsWSCN + ":ScanTicket").EncodeString(NsMap)
+
+	// Vendor namespace must be declared in the output
+	if !strings.Contains(text, `"http://schemas.brother.com/wsd/scan"`) {
+		t.Errorf("vendor namespace missing:\n%s", text)
+	}
+
+	// Extensions go after the known elements
+	if strings.Index(text, "SpecialMode") < strings.Index(text, "Format") {
+		t.Errorf("extension is not at the end:\n%s", text)
+	}
+
+	st2 := testExtensionsDecode(t, text)
+	if !st.Equal(st2, &CompareOptions{Extensions: true}) {
+		t.Errorf("round trip failed:\n%s", text)
+	}
+}
+
+// TestExtensionsLimit tests limits of the extension elements
+func TestExtensionsLimit(t *testing.T) {
+	// ticket makes ScanTicket with the specified extensions
+	ticket := func(ext string) string {
+		return `<wscn:ScanTicket
+  xmlns:wscn="http://schemas.microsoft.com/windows/2006/08/wdp/scan"
+  xmlns:v="http://vendor.example.com/scan">
+  <wscn:JobDescription>
+    <wscn:JobName>Scan</wscn:JobName>
+    <wscn:JobOriginatingUserName>user</wscn:JobOriginatingUserName>
+  </wscn:JobDescription>` + ext + `
+</wscn:ScanTicket>`
+	}
+
+	// decode decodes the ScanTicket
+	decode := func(text string) (ScanTicket, error) {
+		root, err := decodeXML([]byte(text))
+		if err != nil {
+			t.Fatalf("decodeXML: %s", err)
+		}
+		return decodeScanTicket(root)
+	}
+
+	// Count limit
+	ext := strings.Repeat("<v:Ext/>", ExtensionsMaxCount)
+	st, err := decode(ticket(ext))
+	if err != nil || len(st.Extensions) != ExtensionsMaxCount {
+		t.Errorf("%d extensions: %v", ExtensionsMaxCount, err)
+	}
+
+	ext = strings.Repeat("<v:Ext/>", 1000)
+	_, err = decode(ticket(ext))
+	if err == nil {
+		t.Errorf("1000 extensions: error not returned")
+	}
+
+	// Size limit
+	ext = fmt.Sprintf("<v:Ext>%s</v:Ext>",
+		strings.Repeat("x", ExtensionsMaxSize))
+	_, err = decode(ticket(ext))
+	if err == nil {
+		t.Errorf("%d bytes extension: error not returned",
+			ExtensionsMaxSize)
+	}
+}
+
+// TestExtensionsUnresolved tests that elements of the unknown
+// namespaces, decoded without decodeXML, are not preserved.
+func TestExtensionsUnresolved(t *testing.T) {
+	root, err := xmldoc.Decode(NsMap,
+		strings.NewReader(testExtensionsTicket))
+	if err != nil {
+		t.Fatalf("xmldoc.Decode: %s", err)
+	}
+
+	st, err := decodeScanTicket(root)
+	if err != nil {
+		t.Fatalf("decodeScanTicket: %s", err)
+	}
+
+	if st.Extensions != nil {
+		t.Errorf("unresolved extensions preserved: %v", st.Extensions)
+	}
+}
+
+// TestExtensionsCompare tests that extensions are ignored by
+// comparison and validation, unless requested.
+func TestExtensionsCompare(t *testing.T) {
+	st := testExtensionsDecode(t, testExtensionsTicket)
+
+	plain := st
+	plain.Extensions = nil
+	dp := *plain.DocumentParameters
+	dp.Extensions = nil
+	plain.DocumentParameters = &dp
+
+	if !st.Equal(plain, nil) {
+		t.Errorf("Equal: extensions not ignored by default")
+	}
+
+	if st.Equal(plain, &CompareOptions{Extensions: true}) {
+		t.Errorf("Equal: extensions ignored with Extensions option")
+	}
+
+	// ToAbstract ignores extensions
+	if !reflect.DeepEqual(st.ToAbstract(), plain.ToAbstract()) {
+		t.Errorf("ToAbstract: extensions not ignored:\n"+
+			"with:    %#v\nwithout: %#v",
+			st.ToAbstract(), plain.ToAbstract())
+	}
+}
@@ -0,0 +1,187 @@
+// MFP - Multi-Function Printers and scanners toolkit
+// WS-Scan core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// SOAP Fault
+
+package wsscan
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// FaultCode is the SOAP 1.2 fault code.
+type FaultCode string
+
+// Fault codes:
+const (
+	// FaultSender means that request is invalid and must
+	// not be resent without change.
+	FaultSender FaultCode = "Sender"
+
+	// FaultReceiver means that request failed due to
+	// the server side problem.
+	FaultReceiver FaultCode = "Receiver"
+)
+
+// WS-Scan fault subcodes:
+const (
+	FaultClientErrorFormatNotSupported = "ClientErrorFormatNotSupported"
+	FaultClientErrorInvalidRegionArea  = "ClientErrorInvalidRegionArea"
+	FaultClientErrorInvalidResolution  = "ClientErrorInvalidResolution"
+	FaultClientErrorJobIDNotFound      = "ClientErrorJobIdNotFound"
+	FaultClientErrorNoImagesAvailable  = "ClientErrorNoImagesAvailable"
+	FaultServerErrorNotAcceptingJobs   = "ServerErrorNotAcceptingJobs"
+	FaultInvalidArgs                   = "InvalidArgs"
+	FaultOperationFailed               = "OperationFailed"
+)
+
+// faultMaxSize is the maximum size of the SOAP message with Fault,
+// accepted by the [Client].
+const faultMaxSize = 64 * 1024
+
+// Fault represents the SOAP 1.2 Fault, returned instead of the
+// normal response when request cannot be performed.
+//
+// Fault implements both the [Body] and the error interfaces.
+type Fault struct {
+	Code    FaultCode // Fault code
+	Subcode string    // WS-Scan subcode, without namespace prefix
+	Reason  string    // Human-readable explanation
+}
+
+// Action returns [Action] associated with the [Fault].
+func (f *Fault) Action() Action {
+	return ActFault
+}
+
+// Error returns the error string. It implements the error interface.
+func (f *Fault) Error() string {
+	s := "SOAP fault: " + string(f.Code)
+	if f.Subcode != "" {
+		s += "/" + f.Subcode
+	}
+	if f.Reason != "" {
+		s += ": " + f.Reason
+	}
+	return s
+}
+
+// httpStatus returns HTTP status, used to send the [Fault].
+func (f *Fault) httpStatus() int {
+	if f.Code == FaultSender {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// ToXML generates XML tree for the [Fault].
+func (f *Fault) ToXML() xmldoc.Element {
+	code := xmldoc.Element{
+		Name: NsSOAP + ":Code",
+		Children: []xmldoc.Element{
+			{
+				Name: NsSOAP + ":Value",
+				Text: NsSOAP + ":" + string(f.Code),
+			},
+		},
+	}
+
+	if f.Subcode != "" {
+		code.Children = append(code.Children, xmldoc.Element{
+			Name: NsSOAP + ":Subcode",
+			Children: []xmldoc.Element{
+				{
+					Name: NsSOAP + ":Value",
+					Text: NsWSCN + ":" + f.Subcode,
+				},
+			},
+		})
+	}
+
+	return xmldoc.Element{
+		Name: NsSOAP + ":Fault",
+		Children: []xmldoc.Element{
+			code,
+			{
+				Name: NsSOAP + ":Reason",
+				Children: []xmldoc.Element{
+					{
+						Name: NsSOAP + ":Text",
+						Attrs: []xmldoc.Attr{
+							{Name: "xml:lang", Value: "en"},
+						},
+						Text: f.Reason,
+					},
+				},
+			},
+		},
+	}
+}
+
+// decodeFault decodes [Fault] from the XML tree.
+//
+// Values of Code and Subcode are QNames, and their namespace
+// prefixes are chosen by the sender, so they are stripped here.
+func decodeFault(root xmldoc.Element) (f Fault, err error) {
+	defer func() { err = xmldoc.XMLErrWrap(root, err) }()
+
+	code := xmldoc.Lookup{Name: NsSOAP + ":Code", Required: true}
+	reason := xmldoc.Lookup{Name: NsSOAP + ":Reason"}
+
+	missed := root.Lookup(&code, &reason)
+	if missed != nil {
+		err = errMissed(missed.Name)
+		return
+	}
+
+	value := xmldoc.Lookup{Name: NsSOAP + ":Value", Required: true}
+	subcode := xmldoc.Lookup{Name: NsSOAP + ":Subcode"}
+
+	missed = code.Elem.Lookup(&value, &subcode)
+	if missed != nil {
+		err = xmldoc.XMLErrWrap(code.Elem,
+			errMissed(missed.Name))
+		return
+	}
+
+	f.Code = FaultCode(faultLocalName(value.Elem.Text))
+	switch f.Code {
+	case FaultSender, FaultReceiver:
+	default:
+		err = xmldoc.XMLErrWrap(code.Elem,
+			fmt.Errorf("invalid fault code: %q", value.Elem.Text))
+		return
+	}
+
+	if subcode.Found {
+		if elem, ok := subcode.Elem.ChildByName(
+			NsSOAP + ":Value"); ok {
+			f.Subcode = faultLocalName(elem.Text)
+		}
+	}
+
+	if reason.Found {
+		if elem, ok := reason.Elem.ChildByName(
+			NsSOAP + ":Text"); ok {
+			f.Reason = strings.TrimSpace(elem.Text)
+		}
+	}
+
+	return
+}
+
+// faultLocalName returns local part of the QName.
+func faultLocalName(qname string) string {
+	qname = strings.TrimSpace(qname)
+	if i := strings.IndexByte(qname, ':'); i >= 0 {
+		qname = qname[i+1:]
+	}
+	return qname
+}
@@ -88,7 +88,7 @@ func decodeFilm(root xmldoc.Element) (Film, error) {
 	missed := root.Lookup(&filmColor, &filmMaxSize, &filmMinSize,
 		&filmOptRes, &filmRes, &filmModes)
 	if missed != nil {
-		return f, xmldoc.XMLErrMissed(missed.Name)
+		return f, errMissed(missed.Name)
 	}
 
 	color, err := decodeColorEntry(filmColor.Elem)
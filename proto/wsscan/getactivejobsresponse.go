@@ -49,7 +49,7 @@ func decodeGetActiveJobsResponse(root xmldoc.Element) (
 	}
 
 	if missed := root.Lookup(&activeJobs); missed != nil {
-		return r, xmldoc.XMLErrMissed(missed.Name)
+		return r, errMissed(missed.Name)
 	}
 
 	var err error
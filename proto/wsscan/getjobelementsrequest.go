@@ -64,7 +64,7 @@ func decodeGetJobElementsRequest(root xmldoc.Element) (
 	}
 
 	if missed := root.Lookup(&jobID, &requestedElements); missed != nil {
-		return r, xmldoc.XMLErrMissed(missed.Name)
+		return r, errMissed(missed.Name)
 	}
 
 	var err error
@@ -59,7 +59,7 @@ func decodeGetJobElementsResponse(root xmldoc.Element) (
 	}
 
 	if missed := root.Lookup(&jobElements); missed != nil {
-		return r, xmldoc.XMLErrMissed(missed.Name)
+		return r, errMissed(missed.Name)
 	}
 
 	for _, child := range jobElements.Elem.Children {
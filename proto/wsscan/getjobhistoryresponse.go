@@ -54,7 +54,7 @@ func decodeGetJobHistoryResponse(root xmldoc.Element) (
 	}
 
 	if missed := root.Lookup(&jobHistory); missed != nil {
-		return r, xmldoc.XMLErrMissed(missed.Name)
+		return r, errMissed(missed.Name)
 	}
 
 	for _, child := range jobHistory.Elem.Children {
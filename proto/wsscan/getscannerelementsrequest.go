@@ -60,7 +60,7 @@ func decodeGetScannerElementsRequest(root xmldoc.Element) (
 
 	missed := root.Lookup(&requestedElements)
 	if missed != nil && missed.Required {
-		return gser, xmldoc.XMLErrMissed(missed.Name)
+		return gser, errMissed(missed.Name)
 	}
 
 	for _, child := range requestedElements.Elem.Children {
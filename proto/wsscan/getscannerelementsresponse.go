@@ -60,7 +60,7 @@ func decodeGetScannerElementsResponse(root xmldoc.Element) (
 	}
 
 	if missed := root.Lookup(&scannerElements); missed != nil {
-		return r, xmldoc.XMLErrMissed(missed.Name)
+		return r, errMissed(missed.Name)
 	}
 
 	for _, child := range scannerElements.Elem.Children {
@@ -52,7 +52,7 @@ func decodeHeader(root xmldoc.Element) (hdr Header, err error) {
 
 	missed := root.Lookup(&action, &messageID, &to, &replyTo, &relatesTo)
 	if missed != nil {
-		err = xmldoc.XMLErrMissed(missed.Name)
+		err = errMissed(missed.Name)
 		return
 	}
 
@@ -85,7 +85,7 @@ func decodeHeader(root xmldoc.Element) (hdr Header, err error) {
 			Required: true,
 		}
 		if missed := replyTo.Elem.Lookup(&address); missed != nil {
-			err = xmldoc.XMLErrMissed(missed.Name)
+			err = errMissed(missed.Name)
 			return
 		}
 		var tmp AnyURI
@@ -38,7 +38,7 @@ func decodeInputMediaSize(root xmldoc.Element) (InputMediaSize, error) {
 
 	missed := root.Lookup(&heightLookup, &widthLookup)
 	if missed != nil {
-		return ims, xmldoc.XMLErrMissed(missed.Name)
+		return ims, errMissed(missed.Name)
 	}
 
 	// Decode Height
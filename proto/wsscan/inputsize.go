@@ -45,7 +45,7 @@ func decodeInputSize(root xmldoc.Element) (InputSize, error) {
 
 	missed := root.Lookup(&documentSizeAutoDetectLookup, &inputMediaSizeLookup)
 	if missed != nil {
-		return is, xmldoc.XMLErrMissed(missed.Name)
+		return is, errMissed(missed.Name)
 	}
 
 	// Decode DocumentSizeAutoDetect if present
@@ -59,7 +59,7 @@ func decodeJob(root xmldoc.Element) (
 		&scanTicket,
 	)
 	if missed != nil && missed.Required {
-		return j, xmldoc.XMLErrMissed(missed.Name)
+		return j, errMissed(missed.Name)
 	}
 
 	// Decode Documents
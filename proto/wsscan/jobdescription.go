@@ -72,7 +72,7 @@ func decodeJobDescription(root xmldoc.Element) (jd JobDescription, err error) {
 		&jobInformation,
 	)
 	if missed != nil && missed.Required {
-		return jd, xmldoc.XMLErrMissed(missed.Name)
+		return jd, errMissed(missed.Name)
 	}
 
 	jd.JobName = jobName.Elem.Text
@@ -141,7 +141,7 @@ func decodeJobElemData(root xmldoc.Element) (JobElemData, error) {
 	validAttr := xmldoc.LookupAttr{Name: "Valid", Required: true}
 
 	if missed := root.LookupAttrs(&nameAttr, &validAttr); missed != nil {
-		return ed, xmldoc.XMLErrMissed(missed.Name)
+		return ed, errMissed(missed.Name)
 	}
 
 	ed.Name = DecodeJobElemName(nameAttr.Attr.Value)
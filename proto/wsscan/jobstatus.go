@@ -116,7 +116,7 @@ func decodeJobStatus(root xmldoc.Element) (js JobStatus, err error) {
 		&jobStateReasons,
 	)
 	if missed != nil && missed.Required {
-		return js, xmldoc.XMLErrMissed(missed.Name)
+		return js, errMissed(missed.Name)
 	}
 
 	if js.JobID, err = decodeNonNegativeInt(jobID.Elem); err != nil {
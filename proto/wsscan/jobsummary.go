@@ -106,7 +106,7 @@ func decodeJobSummary(root xmldoc.Element) (
 		&jobStateReasons,
 	)
 	if missed != nil && missed.Required {
-		return js, xmldoc.XMLErrMissed(missed.Name)
+		return js, errMissed(missed.Name)
 	}
 
 	// Decode JobID
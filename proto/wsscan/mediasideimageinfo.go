@@ -54,7 +54,7 @@ func decodeMediaSideImageInfo(root xmldoc.Element) (MediaSideImageInfo, error) {
 
 	if missed := root.Lookup(
 		&bytesPerLine, &numberOfLines, &pixelsPerLine); missed != nil {
-		return m, xmldoc.XMLErrMissed(missed.Name)
+		return m, errMissed(missed.Name)
 	}
 
 	var err error
@@ -47,7 +47,7 @@ func decodeMediaSides(root xmldoc.Element) (MediaSides, error) {
 
 	missed := root.Lookup(&mediaFront, &mediaBack)
 	if missed != nil {
-		return ms, xmldoc.XMLErrMissed(missed.Name)
+		return ms, errMissed(missed.Name)
 	}
 
 	// Decode MediaFront (required)
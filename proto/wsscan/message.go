@@ -11,15 +11,12 @@ package wsscan
 import (
 	"bytes"
 	"fmt"
-	"io"
-	"mime/multipart"
 	"net/http"
-	"net/textproto"
 
 	"github.com/OpenPrinting/go-mfp/log/trace"
+	"github.com/OpenPrinting/go-mfp/proto/wsscan/mtom"
 	"github.com/OpenPrinting/go-mfp/transport"
 	"github.com/OpenPrinting/go-mfp/util/generic"
-	"github.com/OpenPrinting/go-mfp/util/uuid"
 	"github.com/OpenPrinting/go-mfp/util/xmldoc"
 )
 
@@ -57,7 +54,7 @@ func decodeMessageXML(root xmldoc.Element) (msg Message, err error) {
 
 	missed := root.Lookup(&hdr, &body)
 	if missed != nil {
-		err = xmldoc.XMLErrMissed(missed.Name)
+		err = errMissed(missed.Name)
 		return
 	}
 
@@ -76,7 +73,7 @@ func decodeMessageXML(root xmldoc.Element) (msg Message, err error) {
 
 	child, ok := body.Elem.ChildByName(bodyChildName)
 	if !ok {
-		err = xmldoc.XMLErrMissed(bodyChildName)
+		err = errMissed(bodyChildName)
 		err = xmldoc.XMLErrWrap(body.Elem, err)
 		return
 	}
@@ -116,6 +113,9 @@ func decodeMessageXML(root xmldoc.Element) (msg Message, err error) {
 	case ActGetJobHistoryResponse:
 		v, e := decodeGetJobHistoryResponse(child)
 		msg.Body, err = &v, e
+	case ActFault:
+		v, e := decodeFault(child)
+		msg.Body, err = &v, e
 	default:
 		err = fmt.Errorf("unhandled action: %s", msg.Header.Action)
 	}
@@ -126,15 +126,26 @@ func decodeMessageXML(root xmldoc.Element) (msg Message, err error) {
 // Encode encodes the [Message] into its wire representation.
 func (msg Message) Encode() []byte {
 	buf := bytes.Buffer{}
-	ns := generic.CopySlice(NsMap)
-	msg.toXML().Encode(&buf, ns)
+	msg.toXML().Encode(&buf, msg.nsMap())
 	return buf.Bytes()
 }
 
 // Format formats the [Message] for logging.
 func (msg Message) Format() string {
+	return msg.toXML().EncodeIndentString(msg.nsMap(), "  ")
+}
+
+// nsMap returns the namespace map for encoding the [Message].
+//
+// The [Fault] refers WS-Scan namespace from the subcode value
+// rather than from the element names, so this namespace is
+// explicitly marked as used.
+func (msg Message) nsMap() xmldoc.Namespace {
 	ns := generic.CopySlice(NsMap)
-	return msg.toXML().EncodeIndentString(ns, "  ")
+	if _, ok := msg.Body.(*Fault); ok {
+		ns.MarkUsedPrefix(NsWSCN)
+	}
+	return ns
 }
 
 // toXML generates the XML tree for the SOAP envelope.
@@ -156,19 +167,6 @@ func (msg Message) toXML() xmldoc.Element {
 	}
 }
 
-// mtomContentType returns the Content-Type header value for the
-// MTOM/XOP multipart encoding using the given boundary and
-// envelope Content-ID.
-func mtomContentType(boundary, envelopeCID string) string {
-	return fmt.Sprintf(
-		`multipart/related;`+
-			` type="application/xop+xml";`+
-			` boundary="%s";`+
-			` start="<%s>";`+
-			` start-info="application/soap+xml"`,
-		boundary, envelopeCID)
-}
-
 // writeMTOM encodes the message as an MTOM/XOP multipart response,
 // with the SOAP envelope as the first part and the binary
 // attachment from [RetrieveImageResponse] as the second part.
@@ -178,35 +176,13 @@ func (msg Message) writeMTOM(query *transport.ServerQuery) error {
 	body := msg.Body.(*RetrieveImageResponse)
 
 	// Write HTTP header
-	boundary := uuid.Random().String()
-	envelopeCID := uuid.Random().String()
+	mw := mtom.NewWriter(query)
 
-	query.ResponseHeader().Set("Content-Type",
-		mtomContentType(boundary, envelopeCID))
+	query.ResponseHeader().Set("Content-Type", mw.ContentType())
 	query.WriteHeader(http.StatusOK)
 
-	// Encode the SOAP envelope
-	soapData := msg.Encode()
-
-	// Create multipart writer with the pre-determined boundary
-	mw := multipart.NewWriter(query)
-	if err := mw.SetBoundary(boundary); err != nil {
-		return err
-	}
-
 	// Part 1: SOAP envelope
-	soapPart, err := mw.CreatePart(textproto.MIMEHeader{
-		"Content-Type": {`application/xop+xml;` +
-			` charset=UTF-8;` +
-			` type="application/soap+xml"`},
-		"Content-Transfer-Encoding": {"binary"},
-		"Content-Id":                {"<" + envelopeCID + ">"},
-	})
-	if err != nil {
-		return err
-	}
-
-	if _, err = soapPart.Write(soapData); err != nil {
+	if err := mw.WriteRoot(msg.Encode()); err != nil {
 		return err
 	}
 
@@ -216,17 +192,11 @@ func (msg Message) writeMTOM(query *transport.ServerQuery) error {
 	defer image.Close()
 
 	// Part 2: Image data (streamed)
-	imagePart, err := mw.CreatePart(textproto.MIMEHeader{
-		"Content-Type":              {body.ContentType},
-		"Content-Transfer-Encoding": {"binary"},
-		"Content-Id":                {"<" + body.ScanData.ContentID + ">"},
-	})
+	err := mw.WriteAttachment(body.ScanData.ContentID,
+		body.ContentType, image)
 	if err != nil {
 		return err
 	}
-	if _, err = io.Copy(imagePart, image); err != nil {
-		return err
-	}
 
 	return mw.Close()
 }
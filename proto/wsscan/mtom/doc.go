@@ -0,0 +1,20 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// MTOM/XOP multipart messages
+//
+// Copyright (C) 2024 and up by go-mfp authors.
+// See LICENSE for license terms and conditions
+//
+// Package documentation
+
+// Package mtom implements streaming encoding and decoding of the
+// MTOM/XOP (SOAP Message Transmission Optimization Mechanism,
+// XML-binary Optimized Packaging) multipart/related messages.
+//
+// WS-Scan uses MTOM to return scanned images: the SOAP envelope
+// goes as the root part of the message, and the image goes as
+// the binary attachment, referenced from the envelope by the
+// xop:Include element.
+//
+// Both [ParseResponse] and [Writer] process attachments as
+// streams, so multi-megabyte images are never buffered in memory.
+package mtom
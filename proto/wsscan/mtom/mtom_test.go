@@ -0,0 +1,260 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// MTOM/XOP multipart messages
+//
+// Copyright (C) 2024 and up by go-mfp authors.
+// See LICENSE for license terms and conditions
+//
+// MTOM tests
+
+package mtom
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// testNS is the namespace map for tests
+var testNS = xmldoc.Namespace{
+	{URL: "http://www.w3.org/2003/05/soap-envelope", Prefix: "s"},
+	{URL: "http://www.w3.org/2004/08/xop/include", Prefix: "xop"},
+}
+
+// testSOAP is the SOAP envelope for tests
+const testSOAP = `<?xml version="1.0" encoding="UTF-8"?>` +
+	`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"` +
+	` xmlns:xop="http://www.w3.org/2004/08/xop/include">` +
+	`<s:Body><xop:Include href="cid:image%40example"/></s:Body>` +
+	`</s:Envelope>`
+
+// countingReader counts bytes, read from the underlying reader.
+type countingReader struct {
+	r     io.Reader
+	count atomic.Int64
+}
+
+// Read implements io.Reader interface for the countingReader.
+func (cr *countingReader) Read(buf []byte) (int, error) {
+	n, err := cr.r.Read(buf)
+	cr.count.Add(int64(n))
+	return n, err
+}
+
+// TestStreaming tests that multi-megabyte attachment passes
+// through the Writer and ParseResponse without being buffered.
+func TestStreaming(t *testing.T) {
+	const imageSize = 32 * 1024 * 1024
+
+	// Generate the message on the fly
+	pr, pw := io.Pipe()
+	mw := NewWriter(pw)
+	contentType := mw.ContentType()
+
+	sentSum := make(chan []byte, 1)
+	go func() {
+		hash := sha256.New()
+		image := io.TeeReader(
+			io.LimitReader(rand.New(rand.NewSource(1)), imageSize),
+			hash)
+
+		err := mw.WriteRoot([]byte(testSOAP))
+		if err == nil {
+			err = mw.WriteAttachment("image@example",
+				"image/jpeg", image)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+
+		sentSum <- hash.Sum(nil)
+		pw.CloseWithError(err)
+	}()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	// Parse the message
+	body := &countingReader{r: pr}
+	root, attachments, err := ParseResponse(contentType, body, testNS)
+	if err != nil {
+		t.Fatalf("ParseResponse: %s", err)
+	}
+
+	include := xmldoc.Lookup{Name: "s:Body"}
+	root.Lookup(&include)
+	href, _ := include.Elem.Children[0].AttrByName("href")
+
+	image, err := attachments(href.Value)
+	if err != nil {
+		t.Fatalf("attachments(%q): %s", href.Value, err)
+	}
+
+	if image.ContentType != "image/jpeg" {
+		t.Errorf("ContentType: expected %q, present %q",
+			"image/jpeg", image.ContentType)
+	}
+
+	// Only the head of the message must be consumed by now
+	if n := body.count.Load(); n > 1024*1024 {
+		t.Errorf("%d bytes consumed before reading image", n)
+	}
+
+	// Read the image
+	hash := sha256.New()
+	n, err := io.Copy(hash, image)
+	if err != nil {
+		t.Fatalf("reading image: %s", err)
+	}
+
+	runtime.ReadMemStats(&after)
+
+	if n != imageSize {
+		t.Errorf("image size: expected %d, present %d", imageSize, n)
+	}
+
+	if sum := <-sentSum; !bytes.Equal(sum, hash.Sum(nil)) {
+		t.Errorf("image checksum mismatch")
+	}
+
+	// Memory consumption must be bounded regardless of image size
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > imageSize/4 {
+		t.Errorf("%d bytes allocated while streaming %d bytes",
+			alloc, imageSize)
+	}
+}
+
+// TestParseResponse tests ParseResponse on the hand-made messages
+func TestParseResponse(t *testing.T) {
+	const boundary = "BOUNDARY"
+
+	type testData struct {
+		name        string // Test name
+		contentType string // Content-Type
+		parts       []string
+		cid         string // Attachment to request
+		data        string // Expected attachment data
+		err         string // Expected error
+	}
+
+	ct := `multipart/related; type="application/xop+xml"; ` +
+		`boundary="` + boundary + `"; start="<root>"`
+
+	root := "Content-Type: application/xop+xml\r\n" +
+		"Content-Id: <root>\r\n\r\n" + testSOAP
+
+	tests := []testData{
+		{
+			name:        "binary",
+			contentType: ct,
+			parts: []string{
+				root,
+				"Content-Id: <image@example>\r\n\r\n" +
+					"hello, world",
+			},
+			cid:  "cid:image%40example",
+			data: "hello, world",
+		},
+
+		{
+			name:        "skip unreferenced parts",
+			contentType: ct,
+			parts: []string{
+				root,
+				"Content-Id: <other>\r\n\r\nother",
+				"Content-Id: <image@example>\r\n\r\n" +
+					"hello, world",
+			},
+			cid:  "image@example",
+			data: "hello, world",
+		},
+
+		{
+			name:        "base64",
+			contentType: ct,
+			parts: []string{
+				root,
+				"Content-Id: <image@example>\r\n" +
+					"Content-Transfer-Encoding: base64\r\n" +
+					"\r\n" +
+					"aGVsbG8s\r\nIHdvcmxk",
+			},
+			cid:  "cid:image@example",
+			data: "hello, world",
+		},
+
+		{
+			name:        "missed attachment",
+			contentType: ct,
+			parts:       []string{root},
+			cid:         "cid:image@example",
+			err:         `mtom: attachment "image@example" not found`,
+		},
+
+		{
+			name:        "start mismatch",
+			contentType: strings.Replace(ct, "<root>", "<other>", 1),
+			parts:       []string{root},
+			err: `mtom: root part Content-ID "root" ` +
+				`doesn't match start "<other>"`,
+		},
+
+		{
+			name:        "not multipart",
+			contentType: "application/soap+xml",
+			err: `mtom: expected multipart/related, ` +
+				`got "application/soap+xml"`,
+		},
+
+		{
+			name:        "missed boundary",
+			contentType: "multipart/related",
+			err:         "mtom: missing multipart boundary",
+		},
+	}
+
+	for _, test := range tests {
+		msg := ""
+		for _, part := range test.parts {
+			msg += "--" + boundary + "\r\n" + part + "\r\n"
+		}
+		msg += "--" + boundary + "--\r\n"
+
+		_, attachments, err := ParseResponse(test.contentType,
+			strings.NewReader(msg), testNS)
+
+		var data []byte
+		if err == nil && test.cid != "" {
+			var att *Attachment
+			att, err = attachments(test.cid)
+			if err == nil {
+				data, err = io.ReadAll(att)
+			}
+		}
+
+		errstr := fmt.Sprint(err)
+		if err == nil {
+			errstr = ""
+		}
+
+		switch {
+		case errstr != test.err:
+			t.Errorf("%s: error mismatch:\n"+
+				"expected: %s\npresent:  %s",
+				test.name, test.err, errstr)
+
+		case string(data) != test.data:
+			t.Errorf("%s: data mismatch:\n"+
+				"expected: %q\npresent:  %q",
+				test.name, test.data, data)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// MTOM/XOP multipart messages
+//
+// Copyright (C) 2024 and up by go-mfp authors.
+// See LICENSE for license terms and conditions
+//
+// Streaming MTOM message parser
+
+package mtom
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// MaxRootSize limits size of the root (SOAP) part of the message.
+// Unlike attachments, the root part is read into memory.
+const MaxRootSize = 1024 * 1024
+
+// Attachment represents the binary attachment of the MTOM message.
+//
+// It reads the attachment content directly from the underlying
+// message body, without buffering.
+type Attachment struct {
+	io.Reader                        // Attachment content
+	ContentID   string               // Content-ID, without <>
+	ContentType string               // Content-Type
+	Header      textproto.MIMEHeader // All MIME headers of the part
+}
+
+// AttachmentFunc returns the [Attachment] by its Content-ID, as
+// referenced from the root part by the xop:Include href.
+//
+// Both "cid:xxx" URL and bare "xxx" forms are accepted.
+//
+// Attachments are read from the message body sequentially, so
+// they must be requested in the order of their appearance in
+// the message. Requesting an attachment skips all preceding parts,
+// including the not yet consumed content of the previously returned
+// attachment.
+type AttachmentFunc func(cid string) (*Attachment, error)
+
+// ParseResponse parses the MTOM message with the given Content-Type
+// header value. The root part is decoded as XML, using the ns
+// namespace map.
+//
+// The root part is read immediately. Attachments are read from body
+// on demand, via the returned [AttachmentFunc], so the body must not
+// be closed until attachments are consumed.
+func ParseResponse(contentType string, body io.Reader,
+	ns xmldoc.Namespace) (xmldoc.Element, AttachmentFunc, error) {
+
+	// Parse Content-Type
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return xmldoc.Element{},
+			nil, fmt.Errorf("mtom: invalid Content-Type: %w", err)
+	}
+
+	if mediaType != "multipart/related" {
+		return xmldoc.Element{}, nil,
+			fmt.Errorf("mtom: expected multipart/related, got %q",
+				mediaType)
+	}
+
+	boundary := params["boundary"]
+	if boundary == "" {
+		return xmldoc.Element{}, nil,
+			errors.New("mtom: missing multipart boundary")
+	}
+
+	// Read the root part. If start parameter is present,
+	// it must match the first part.
+	mr := multipart.NewReader(body, boundary)
+
+	rootPart, err := mr.NextPart()
+	if err != nil {
+		return xmldoc.Element{}, nil,
+			fmt.Errorf("mtom: reading root part: %w", err)
+	}
+
+	if start := params["start"]; start != "" {
+		cid := normalizeCID(rootPart.Header.Get("Content-Id"))
+		if cid != normalizeCID(start) {
+			return xmldoc.Element{}, nil,
+				fmt.Errorf("mtom: root part Content-ID %q "+
+					"doesn't match start %q", cid, start)
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(
+		partReader(rootPart), MaxRootSize+1))
+	if err != nil {
+		return xmldoc.Element{}, nil,
+			fmt.Errorf("mtom: reading root part: %w", err)
+	}
+
+	if len(data) > MaxRootSize {
+		return xmldoc.Element{}, nil,
+			errors.New("mtom: root part too large")
+	}
+
+	root, err := xmldoc.Decode(ns, bytes.NewReader(data))
+	if err != nil {
+		return xmldoc.Element{}, nil,
+			fmt.Errorf("mtom: decoding root part: %w", err)
+	}
+
+	// Create the attachments lookup function
+	seen := make(map[string]struct{})
+	attachments := func(cid string) (*Attachment, error) {
+		cid = normalizeCID(cid)
+		if _, found := seen[cid]; found {
+			return nil, fmt.Errorf(
+				"mtom: attachment %q already passed", cid)
+		}
+
+		for {
+			part, err := mr.NextPart()
+			switch {
+			case err == io.EOF:
+				return nil, fmt.Errorf(
+					"mtom: attachment %q not found", cid)
+			case err != nil:
+				return nil, fmt.Errorf(
+					"mtom: reading attachment %q: %w",
+					cid, err)
+			}
+
+			partCID := normalizeCID(part.Header.Get("Content-Id"))
+			seen[partCID] = struct{}{}
+
+			if partCID == cid {
+				att := &Attachment{
+					Reader:      partReader(part),
+					ContentID:   partCID,
+					ContentType: part.Header.Get("Content-Type"),
+					Header:      part.Header,
+				}
+				return att, nil
+			}
+		}
+	}
+
+	return root, attachments, nil
+}
+
+// partReader returns reader for the part content, taking
+// Content-Transfer-Encoding into account.
+//
+// Note, multipart.Reader handles quoted-printable by itself.
+func partReader(part *multipart.Part) io.Reader {
+	enc := part.Header.Get("Content-Transfer-Encoding")
+	if strings.EqualFold(strings.TrimSpace(enc), "base64") {
+		return base64.NewDecoder(base64.StdEncoding,
+			newlineStripper{part})
+	}
+
+	return part
+}
+
+// normalizeCID converts Content-ID from any of the
+// "<xxx>", "cid:xxx" or "xxx" forms into the bare "xxx" form.
+//
+// The cid: URLs are percent-encoded (RFC 2392), so they are
+// unescaped as well.
+func normalizeCID(cid string) string {
+	cid = strings.TrimSpace(cid)
+
+	if len(cid) > 4 && strings.EqualFold(cid[:4], "cid:") {
+		cid = cid[4:]
+		if s, err := url.PathUnescape(cid); err == nil {
+			cid = s
+		}
+	}
+
+	cid = strings.TrimPrefix(cid, "<")
+	cid = strings.TrimSuffix(cid, ">")
+
+	return cid
+}
+
+// newlineStripper removes CR and LF characters from the
+// base64-encoded stream, so it can be consumed by the
+// base64 decoder.
+type newlineStripper struct {
+	r io.Reader
+}
+
+// Read implements io.Reader interface for the newlineStripper.
+func (ns newlineStripper) Read(buf []byte) (int, error) {
+	for {
+		n, err := ns.r.Read(buf)
+		out := 0
+		for _, c := range buf[:n] {
+			if c != '\r' && c != '\n' {
+				buf[out] = c
+				out++
+			}
+		}
+
+		if out > 0 || err != nil {
+			return out, err
+		}
+	}
+}
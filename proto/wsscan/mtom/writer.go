@@ -0,0 +1,109 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// MTOM/XOP multipart messages
+//
+// Copyright (C) 2024 and up by go-mfp authors.
+// See LICENSE for license terms and conditions
+//
+// Streaming MTOM message writer
+
+package mtom
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+
+	"github.com/OpenPrinting/go-mfp/util/uuid"
+)
+
+// Writer writes the MTOM message.
+//
+// The root (SOAP) part must be written first, by the
+// [Writer.WriteRoot], followed by any number of attachments,
+// written by the [Writer.WriteAttachment]. [Writer.Close] finishes
+// the message.
+type Writer struct {
+	mw      *multipart.Writer // Underlying multipart writer
+	rootCID string            // Content-ID of the root part
+	root    bool              // Root part is written
+}
+
+// NewWriter creates a new [Writer] that writes the message into w.
+//
+// Multipart boundary and Content-ID of the root part are generated
+// randomly. Use [Writer.ContentType] to obtain the matching
+// Content-Type header value.
+func NewWriter(w io.Writer) *Writer {
+	mw := multipart.NewWriter(w)
+	mw.SetBoundary(uuid.Random().String())
+
+	return &Writer{
+		mw:      mw,
+		rootCID: uuid.Random().String(),
+	}
+}
+
+// ContentType returns the Content-Type header value of the message.
+func (w *Writer) ContentType() string {
+	return fmt.Sprintf(
+		`multipart/related;`+
+			` type="application/xop+xml";`+
+			` boundary="%s";`+
+			` start="<%s>";`+
+			` start-info="application/soap+xml"`,
+		w.mw.Boundary(), w.rootCID)
+}
+
+// WriteRoot writes the root part of the message, containing
+// the SOAP envelope.
+func (w *Writer) WriteRoot(soap []byte) error {
+	if w.root {
+		return errors.New("mtom: root part already written")
+	}
+
+	w.root = true
+
+	part, err := w.mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {`application/xop+xml;` +
+			` charset=UTF-8;` +
+			` type="application/soap+xml"`},
+		"Content-Transfer-Encoding": {"binary"},
+		"Content-Id":                {"<" + w.rootCID + ">"},
+	})
+
+	if err == nil {
+		_, err = part.Write(soap)
+	}
+
+	return err
+}
+
+// WriteAttachment writes the binary attachment with the given
+// Content-ID (without <> or cid: prefix) and Content-Type.
+// Attachment content is copied from r without buffering.
+func (w *Writer) WriteAttachment(cid, contentType string,
+	r io.Reader) error {
+
+	if !w.root {
+		return errors.New("mtom: root part must be written first")
+	}
+
+	part, err := w.mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"binary"},
+		"Content-Id":                {"<" + cid + ">"},
+	})
+
+	if err == nil {
+		_, err = io.Copy(part, r)
+	}
+
+	return err
+}
+
+// Close writes the trailing boundary of the message.
+func (w *Writer) Close() error {
+	return w.mw.Close()
+}
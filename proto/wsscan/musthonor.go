@@ -0,0 +1,216 @@
+// MFP - Multi-Function Printers and scanners toolkit
+// WS-Scan core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// MustHonor handling of the scan ticket
+
+package wsscan
+
+import (
+	"errors"
+
+	"github.com/OpenPrinting/go-mfp/abstract"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// fillTicketRequest fills the [abstract.ScannerRequest], converted
+// from the [ScanTicket], with the scanner defaults.
+//
+// Parameters, not supported by the scanner, are substituted with
+// defaults, unless the corresponding ticket element has the
+// MustHonor attribute set. In the later case, the [Fault] is
+// returned.
+func fillTicketRequest(caps *abstract.ScannerCapabilities,
+	ticket ScanTicket) (*abstract.ScannerRequest, *Fault) {
+
+	absreq := ticket.ToAbstract()
+
+	for {
+		filled, err := caps.FillRequest(&absreq)
+		if err == nil {
+			return filled, nil
+		}
+
+		var perr abstract.ErrParam
+		if !errors.As(err, &perr) {
+			return nil, faultFromError(FaultReceiver,
+				FaultOperationFailed, err)
+		}
+
+		if ticketMustHonor(ticket, perr.Name) ||
+			!scanRequestReset(&absreq, perr.Name) {
+			return nil, faultFromError(FaultSender,
+				paramFaultSubcode(perr.Name), err)
+		}
+	}
+}
+
+// ticketMustHonor reports if the [ScanTicket] element, that
+// corresponds to the [abstract.ScannerRequest] parameter, has
+// the MustHonor attribute set.
+func ticketMustHonor(ticket ScanTicket, param string) bool {
+	if ticket.DocumentParameters == nil {
+		return false
+	}
+
+	dp := optional.Get(ticket.DocumentParameters)
+
+	var front MediaSide
+	if dp.MediaSides != nil {
+		ms := optional.Get(dp.MediaSides)
+		if mustHonor(ms.MustHonor) {
+			return true
+		}
+		front = ms.MediaFront
+	}
+
+	switch param {
+	case "Input", "ADFMode":
+		return dp.InputSource != nil &&
+			mustHonor(optional.Get(dp.InputSource).MustHonor)
+
+	case "DocumentFormat":
+		return dp.Format != nil &&
+			mustHonor(optional.Get(dp.Format).MustHonor)
+
+	case "Intent":
+		return dp.ContentType != nil &&
+			mustHonor(optional.Get(dp.ContentType).MustHonor)
+
+	case "Compression":
+		return dp.CompressionQualityFactor != nil &&
+			mustHonor(optional.Get(
+				dp.CompressionQualityFactor).MustHonor)
+
+	case "Brightness", "Contrast", "Sharpen":
+		return dp.Exposure != nil &&
+			mustHonor(optional.Get(dp.Exposure).MustHonor)
+
+	case "ColorMode", "ColorDepth", "BinaryRendering":
+		return front.ColorProcessing != nil &&
+			mustHonor(optional.Get(front.ColorProcessing).MustHonor)
+
+	case "Resolution":
+		if front.Resolution == nil {
+			return false
+		}
+		res := optional.Get(front.Resolution)
+		return mustHonor(res.MustHonor) ||
+			mustHonor(res.Width.MustHonor) ||
+			mustHonor(res.Height.MustHonor)
+
+	case "Region":
+		if dp.InputSize != nil &&
+			mustHonor(optional.Get(dp.InputSize).MustHonor) {
+			return true
+		}
+
+		if front.ScanRegion == nil {
+			return false
+		}
+		sr := optional.Get(front.ScanRegion)
+		return mustHonor(sr.ScanRegionWidth.MustHonor) ||
+			mustHonor(sr.ScanRegionHeight.MustHonor)
+	}
+
+	return false
+}
+
+// scanRequestReset resets the [abstract.ScannerRequest] parameter
+// to its default (missed) value, so [abstract.ScannerCapabilities]
+// will substitute it with the scanner default.
+//
+// It returns false, if parameter cannot be reset or is already
+// missed.
+func scanRequestReset(req *abstract.ScannerRequest, param string) bool {
+	var reset bool
+
+	switch param {
+	case "Input":
+		reset = req.Input != abstract.InputUnset
+		req.Input = abstract.InputUnset
+		req.ADFMode = abstract.ADFModeUnset
+
+	case "ADFMode":
+		reset = req.ADFMode != abstract.ADFModeUnset
+		req.ADFMode = abstract.ADFModeUnset
+
+	case "DocumentFormat":
+		reset = req.DocumentFormat != ""
+		req.DocumentFormat = ""
+
+	case "Intent":
+		reset = req.Intent != abstract.IntentUnset
+		req.Intent = abstract.IntentUnset
+
+	case "ColorMode":
+		reset = req.ColorMode != abstract.ColorModeUnset
+		req.ColorMode = abstract.ColorModeUnset
+		req.ColorDepth = abstract.ColorDepthUnset
+		req.BinaryRendering = abstract.BinaryRenderingUnset
+
+	case "ColorDepth":
+		reset = req.ColorDepth != abstract.ColorDepthUnset
+		req.ColorDepth = abstract.ColorDepthUnset
+
+	case "BinaryRendering":
+		reset = req.BinaryRendering != abstract.BinaryRenderingUnset
+		req.BinaryRendering = abstract.BinaryRenderingUnset
+
+	case "Region":
+		reset = !req.Region.IsZero()
+		req.Region = abstract.Region{}
+
+	case "Resolution":
+		reset = !req.Resolution.IsZero()
+		req.Resolution = abstract.Resolution{}
+
+	case "Compression":
+		reset = req.Compression != nil
+		req.Compression = nil
+
+	case "Brightness":
+		reset = req.Brightness != nil
+		req.Brightness = nil
+
+	case "Contrast":
+		reset = req.Contrast != nil
+		req.Contrast = nil
+
+	case "Sharpen":
+		reset = req.Sharpen != nil
+		req.Sharpen = nil
+	}
+
+	return reset
+}
+
+// paramFaultSubcode returns the [Fault] subcode for the unsupported
+// [abstract.ScannerRequest] parameter.
+func paramFaultSubcode(param string) string {
+	switch param {
+	case "DocumentFormat":
+		return FaultClientErrorFormatNotSupported
+	case "Resolution":
+		return FaultClientErrorInvalidResolution
+	case "Region":
+		return FaultClientErrorInvalidRegionArea
+	}
+	return FaultInvalidArgs
+}
+
+// mustHonor reports if the MustHonor attribute is set to true.
+func mustHonor(attr optional.Val[BooleanElement]) bool {
+	return attr != nil && optional.Get(attr).Bool()
+}
+
+// faultFromError creates the [Fault] from the error.
+func faultFromError(code FaultCode, subcode string, err error) *Fault {
+	return &Fault{
+		Code:    code,
+		Subcode: subcode,
+		Reason:  err.Error(),
+	}
+}
@@ -80,7 +80,7 @@ func decodePlaten(root xmldoc.Element) (Platen, error) {
 		&platenResolutions,
 	)
 	if missed != nil {
-		return p, xmldoc.XMLErrMissed(missed.Name)
+		return p, errMissed(missed.Name)
 	}
 
 	// PlatenColor
@@ -52,7 +52,7 @@ func decodeRange(root xmldoc.Element) (re Range, err error) {
 
 	missed := root.Lookup(&minLookup, &maxLookup)
 	if missed != nil {
-		return re, xmldoc.XMLErrMissed(missed.Name)
+		return re, errMissed(missed.Name)
 	}
 
 	// Decode MinValue
@@ -49,7 +49,7 @@ func decodeResolution(root xmldoc.Element) (Resolution, error) {
 
 	missed := root.Lookup(&height, &width)
 	if missed != nil {
-		return r, xmldoc.XMLErrMissed(missed.Name)
+		return r, errMissed(missed.Name)
 	}
 
 	// Decode Height
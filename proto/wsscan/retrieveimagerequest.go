@@ -66,7 +66,7 @@ func decodeRetrieveImageRequest(root xmldoc.Element) (
 	if missed := root.Lookup(
 		&documentDescription, &jobID, &jobToken,
 	); missed != nil {
-		return r, xmldoc.XMLErrMissed(missed.Name)
+		return r, errMissed(missed.Name)
 	}
 
 	var err error
@@ -47,12 +47,12 @@ func decodeRetrieveImageResponse(root xmldoc.Element) (
 
 	scanData := xmldoc.Lookup{Name: NsWSCN + ":ScanData", Required: true}
 	if missed := root.Lookup(&scanData); missed != nil {
-		return r, xmldoc.XMLErrMissed(missed.Name)
+		return r, errMissed(missed.Name)
 	}
 
 	include := xmldoc.Lookup{Name: NsXOP + ":Include", Required: true}
 	if missed := scanData.Elem.Lookup(&include); missed != nil {
-		return r, xmldoc.XMLErrMissed(missed.Name)
+		return r, errMissed(missed.Name)
 	}
 
 	href, _ := include.Elem.AttrByName("href")
@@ -33,7 +33,7 @@ func decodeScaling(root xmldoc.Element) (Scaling, error) {
 	if attr, found := root.AttrByName(NsWSCN + ":MustHonor"); found {
 		boolVal := BooleanElement(attr.Value)
 		if err := boolVal.Validate(); err != nil {
-			return s, err
+			return s, errInvalidAttr(root, attr, err)
 		}
 		s.MustHonor = optional.New(boolVal)
 	}
@@ -50,7 +50,7 @@ func decodeScaling(root xmldoc.Element) (Scaling, error) {
 
 	missed := root.Lookup(&height, &width)
 	if missed != nil {
-		return s, xmldoc.XMLErrMissed(missed.Name)
+		return s, errMissed(missed.Name)
 	}
 
 	// Decode ScalingHeight
@@ -153,7 +153,16 @@ func TestScaling_MissingWidth(t *testing.T) {
 
 	_, err := decodeScaling(elm)
 	if err == nil {
-		t.Error("expected error for missing ScalingWidth, got nil")
+		t.Fatal("expected error for missing ScalingWidth, got nil")
+	}
+
+	e, ok := AsMissingElementError(err)
+	if !ok {
+		t.Fatalf("expected MissingElementError, got %T", err)
+	}
+	if e.Path != NsWSCN+":ScalingWidth" {
+		t.Errorf("expected Path %q, got %q", NsWSCN+":ScalingWidth",
+			e.Path)
 	}
 }
 
@@ -171,7 +180,15 @@ func TestScaling_InvalidMustHonor(t *testing.T) {
 
 	_, err := decodeScaling(elm)
 	if err == nil {
-		t.Error("expected error for invalid MustHonor attribute, got nil")
+		t.Fatal("expected error for invalid MustHonor attribute, got nil")
+	}
+
+	e, ok := AsInvalidAttributeError(err)
+	if !ok {
+		t.Fatalf("expected InvalidAttributeError, got %T", err)
+	}
+	if e.Attr != NsWSCN+":MustHonor" || e.Value != "invalid" {
+		t.Errorf("unexpected error fields: %+v", e)
 	}
 }
 
@@ -207,7 +224,15 @@ func TestScaling_InvalidValue(t *testing.T) {
 
 	_, err := decodeScaling(elm)
 	if err == nil {
-		t.Error("expected error for invalid ScalingHeight value, got nil")
+		t.Fatal("expected error for invalid ScalingHeight value, got nil")
+	}
+
+	e, ok := AsInvalidValueError(err)
+	if !ok {
+		t.Fatalf("expected InvalidValueError, got %T", err)
+	}
+	if e.Path != NsWSCN+":ScalingHeight" || e.Value != "not-a-number" {
+		t.Errorf("unexpected error fields: %+v", e)
 	}
 }
 
@@ -54,7 +54,7 @@ func decodeScalingRangeSupported(root xmldoc.Element) (
 
 	missed := root.Lookup(&widthLookup, &heightLookup)
 	if missed != nil {
-		return srs, xmldoc.XMLErrMissed(missed.Name)
+		return srs, errMissed(missed.Name)
 	}
 
 	width, err := decodeRange(widthLookup.Elem)
@@ -10,6 +10,7 @@ package wsscan
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/OpenPrinting/go-mfp/util/optional"
 	"github.com/OpenPrinting/go-mfp/util/xmldoc"
@@ -23,6 +24,11 @@ type ScannerConfiguration struct {
 	DeviceSettings DeviceSettings
 	Film           optional.Val[Film]
 	Platen         optional.Val[Platen]
+
+	// Extensions contains vendor extension elements, not
+	// recognized by the decoder. They are re-emitted verbatim
+	// at the end of the XML output.
+	Extensions []xmldoc.Element
 }
 
 // toXML creates an XML element for ScannerConfiguration.
@@ -47,6 +53,8 @@ func (sc ScannerConfiguration) toXML(name string) xmldoc.Element {
 			optional.Get(sc.Platen).toXML(NsWSCN+":Platen"))
 	}
 
+	elm.Children = append(elm.Children, sc.Extensions...)
+
 	return elm
 }
 
@@ -74,7 +82,7 @@ func decodeScannerConfiguration(root xmldoc.Element) (
 
 	missed := root.Lookup(&adf, &deviceSettings, &film, &platen)
 	if missed != nil {
-		return sc, xmldoc.XMLErrMissed(missed.Name)
+		return sc, errMissed(missed.Name)
 	}
 
 	if adf.Found {
@@ -107,5 +115,30 @@ func decodeScannerConfiguration(root xmldoc.Element) (
 		sc.Platen = optional.New(p)
 	}
 
-	return sc, nil
+	sc.Extensions, err = decodeExtensions(root,
+		&adf, &deviceSettings, &film, &platen)
+
+	return sc, err
+}
+
+// Canonicalize returns the canonical form of the ScannerConfiguration,
+// suitable for comparison.
+//
+// Unless opt.Extensions is set, vendor extension elements are
+// removed. opt may be nil, which implies defaults.
+func (sc ScannerConfiguration) Canonicalize(
+	opt *CompareOptions) ScannerConfiguration {
+
+	sc.Extensions = extensionsCanonical(sc.Extensions, opt)
+	return sc
+}
+
+// Equal reports whether two ScannerConfigurations are equal.
+//
+// Vendor extension elements are compared only if opt.Extensions
+// is set. opt may be nil, which implies defaults.
+func (sc ScannerConfiguration) Equal(sc2 ScannerConfiguration,
+	opt *CompareOptions) bool {
+
+	return reflect.DeepEqual(sc.Canonicalize(opt), sc2.Canonicalize(opt))
 }
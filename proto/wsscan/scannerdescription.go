@@ -84,7 +84,7 @@ func decodeScannerDescription(root xmldoc.Element) (
 	}
 
 	if len(sd.ScannerName) == 0 {
-		return sd, xmldoc.XMLErrMissed(nameN)
+		return sd, errMissed(nameN)
 	}
 
 	return sd, nil
@@ -152,7 +152,7 @@ func decodeScannerElemData(root xmldoc.Element) (ScannerElemData, error) {
 	validAttr := xmldoc.LookupAttr{Name: "Valid", Required: true}
 
 	if missed := root.LookupAttrs(&nameAttr, &validAttr); missed != nil {
-		return ed, xmldoc.XMLErrMissed(missed.Name)
+		return ed, errMissed(missed.Name)
 	}
 
 	ed.Name = DecodeScannerElemName(nameAttr.Attr.Value)
@@ -29,17 +29,16 @@ type ScannerStatus struct {
 func (ss ScannerStatus) toXML(name string) xmldoc.Element {
 	children := []xmldoc.Element{}
 
-	// ActiveConditions slice
-	if len(ss.ActiveConditions) > 0 {
-		acChildren := make([]xmldoc.Element, len(ss.ActiveConditions))
-		for i, v := range ss.ActiveConditions {
-			acChildren[i] = v.toXML(NsWSCN + ":DeviceCondition")
-		}
-		children = append(children, xmldoc.Element{
-			Name:     NsWSCN + ":ActiveConditions",
-			Children: acChildren,
-		})
+	// ActiveConditions slice. The element is required, even
+	// if empty.
+	acChildren := make([]xmldoc.Element, len(ss.ActiveConditions))
+	for i, v := range ss.ActiveConditions {
+		acChildren[i] = v.toXML(NsWSCN + ":DeviceCondition")
 	}
+	children = append(children, xmldoc.Element{
+		Name:     NsWSCN + ":ActiveConditions",
+		Children: acChildren,
+	})
 
 	// ConditionHistory (optional)
 	if len(ss.ConditionHistory) > 0 {
@@ -62,17 +61,16 @@ func (ss ScannerStatus) toXML(name string) xmldoc.Element {
 	// ScannerState
 	children = append(children, ss.ScannerState.toXML(NsWSCN+":ScannerState"))
 
-	// ScannerStateReasons slice
-	if len(ss.ScannerStateReasons) > 0 {
-		ssrChildren := make([]xmldoc.Element, len(ss.ScannerStateReasons))
-		for i, v := range ss.ScannerStateReasons {
-			ssrChildren[i] = v.toXML(NsWSCN + ":ScannerStateReason")
-		}
-		children = append(children, xmldoc.Element{
-			Name:     NsWSCN + ":ScannerStateReasons",
-			Children: ssrChildren,
-		})
+	// ScannerStateReasons slice. The element is required, even
+	// if empty.
+	ssrChildren := make([]xmldoc.Element, len(ss.ScannerStateReasons))
+	for i, v := range ss.ScannerStateReasons {
+		ssrChildren[i] = v.toXML(NsWSCN + ":ScannerStateReason")
 	}
+	children = append(children, xmldoc.Element{
+		Name:     NsWSCN + ":ScannerStateReasons",
+		Children: ssrChildren,
+	})
 
 	return xmldoc.Element{
 		Name:     name,
@@ -116,7 +114,7 @@ func decodeScannerStatus(root xmldoc.Element) (ss ScannerStatus, err error) {
 		&scannerStateReasons,
 	)
 	if missed != nil {
-		return ss, xmldoc.XMLErrMissed(missed.Name)
+		return ss, errMissed(missed.Name)
 	}
 
 	// Required fields
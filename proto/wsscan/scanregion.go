@@ -51,7 +51,7 @@ func decodeScanRegion(root xmldoc.Element) (ScanRegion, error) {
 
 	missed := root.Lookup(&height, &width, &xOffset, &yOffset)
 	if missed != nil {
-		return sr, xmldoc.XMLErrMissed(missed.Name)
+		return sr, errMissed(missed.Name)
 	}
 
 	// Decoder function for integer values
@@ -10,6 +10,7 @@ package wsscan
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/OpenPrinting/go-mfp/util/optional"
 	"github.com/OpenPrinting/go-mfp/util/xmldoc"
@@ -20,6 +21,11 @@ import (
 type ScanTicket struct {
 	DocumentParameters optional.Val[DocumentParameters]
 	JobDescription     JobDescription
+
+	// Extensions contains vendor extension elements, not
+	// recognized by the decoder. They are re-emitted verbatim
+	// at the end of the XML output.
+	Extensions []xmldoc.Element
 }
 
 // toXML generates XML tree for the ScanTicket.
@@ -37,6 +43,8 @@ func (st ScanTicket) toXML(name string) xmldoc.Element {
 	children = append(children, st.JobDescription.toXML(
 		NsWSCN+":JobDescription"))
 
+	children = append(children, st.Extensions...)
+
 	return xmldoc.Element{
 		Name:     name,
 		Children: children,
@@ -61,7 +69,7 @@ func decodeScanTicket(root xmldoc.Element) (st ScanTicket, err error) {
 		&jobDescription,
 	)
 	if missed != nil && missed.Required {
-		return st, xmldoc.XMLErrMissed(missed.Name)
+		return st, errMissed(missed.Name)
 	}
 
 	// Decode DocumentParameters if present
@@ -82,5 +90,32 @@ func decodeScanTicket(root xmldoc.Element) (st ScanTicket, err error) {
 		return st, fmt.Errorf("JobDescription: %w", err)
 	}
 
-	return st, nil
+	// Preserve vendor extensions
+	st.Extensions, err = decodeExtensions(root,
+		&documentParameters, &jobDescription)
+
+	return st, err
+}
+
+// Canonicalize returns the canonical form of the ScanTicket,
+// suitable for comparison.
+//
+// Unless opt.Extensions is set, vendor extension elements are
+// removed. opt may be nil, which implies defaults.
+func (st ScanTicket) Canonicalize(opt *CompareOptions) ScanTicket {
+	st.Extensions = extensionsCanonical(st.Extensions, opt)
+	if st.DocumentParameters != nil {
+		dp := optional.Get(st.DocumentParameters).Canonicalize(opt)
+		st.DocumentParameters = optional.New(dp)
+	}
+
+	return st
+}
+
+// Equal reports whether two ScanTickets are equal.
+//
+// Vendor extension elements are compared only if opt.Extensions
+// is set. opt may be nil, which implies defaults.
+func (st ScanTicket) Equal(st2 ScanTicket, opt *CompareOptions) bool {
+	return reflect.DeepEqual(st.Canonicalize(opt), st2.Canonicalize(opt))
 }
@@ -0,0 +1,244 @@
+// MFP - Multi-Function Printers and scanners toolkit
+// WS-Scan core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// ScanTicket templates for common tasks
+
+package wsscan
+
+import (
+	"errors"
+
+	"github.com/OpenPrinting/go-mfp/util/optional"
+)
+
+// Errors, returned by the [TicketFromOptions] for the internally
+// inconsistent [TicketOptions].
+var (
+	ErrTicketDuplexSource = errors.New(
+		"duplex scan requires ADFDuplex input source")
+	ErrTicketFilmSource = errors.New(
+		"film scan mode requires Film input source")
+	ErrTicketResolution = errors.New(
+		"resolution must not be negative")
+)
+
+// TicketMustHonor is the set of the [ScanTicket] elements, that
+// will be marked with the MustHonor attribute by the [TicketFromOptions].
+//
+// Elements, marked this way, the scanner must either honor or fail
+// the job. Other elements it is free to substitute with defaults.
+type TicketMustHonor int
+
+// TicketMustHonor bits:
+const (
+	MustHonorSource      TicketMustHonor = 1 << iota // InputSource
+	MustHonorFormat                                  // Format
+	MustHonorColor                                   // ColorProcessing
+	MustHonorResolution                              // Resolution
+	MustHonorContentType                             // ContentType
+)
+
+// TicketOptions contains parameters for the [TicketFromOptions].
+//
+// Zero values of the optional parameters mean "not specified";
+// corresponding elements are omitted from the ticket, and the
+// scanner uses its defaults.
+type TicketOptions struct {
+	JobName      string           // Job name
+	UserName     string           // Originating user name
+	Source       InputSourceValue // Input source (optional)
+	Duplex       bool             // Scan both sides of each page
+	Format       FormatValue      // Image format (optional)
+	Color        ColorEntry       // Color processing (optional)
+	ContentType  ContentTypeValue // Content type (optional)
+	Resolution   int              // Resolution, DPI (optional)
+	FilmScanMode FilmScanMode     // Film scan mode (optional)
+	MustHonor    TicketMustHonor  // Elements the scanner must honor
+}
+
+// Default JobDescription values
+const (
+	ticketDefaultJobName  = "Scan"
+	ticketDefaultUserName = "mfp"
+)
+
+// TicketFromOptions builds the [ScanTicket] from the [TicketOptions].
+//
+// It checks the options for internal consistency:
+//   - Duplex requires InputSourceADFDuplex Source
+//     ([ErrTicketDuplexSource])
+//   - FilmScanMode, other than NotApplicable, requires
+//     InputSourceFilm Source ([ErrTicketFilmSource])
+//   - Resolution must not be negative ([ErrTicketResolution])
+//
+// Whether the ticket is supported by the particular scanner,
+// it doesn't check; this is up to the scanner.
+func TicketFromOptions(opt TicketOptions) (ScanTicket, error) {
+	// Check options
+	switch {
+	case opt.Duplex && opt.Source != InputSourceADFDuplex:
+		return ScanTicket{}, ErrTicketDuplexSource
+
+	case opt.FilmScanMode != UnknownFilmScanMode &&
+		opt.FilmScanMode != NotApplicable &&
+		opt.Source != InputSourceFilm:
+		return ScanTicket{}, ErrTicketFilmSource
+
+	case opt.Resolution < 0:
+		return ScanTicket{}, ErrTicketResolution
+	}
+
+	// Build the ticket
+	ticket := ScanTicket{
+		JobDescription: JobDescription{
+			JobName:                opt.JobName,
+			JobOriginatingUserName: opt.UserName,
+		},
+	}
+
+	if ticket.JobDescription.JobName == "" {
+		ticket.JobDescription.JobName = ticketDefaultJobName
+	}
+
+	if ticket.JobDescription.JobOriginatingUserName == "" {
+		ticket.JobDescription.JobOriginatingUserName =
+			ticketDefaultUserName
+	}
+
+	dp := DocumentParameters{}
+
+	if opt.Source != UnknownInputSource {
+		dp.InputSource = optional.New(ValWithOptions[InputSourceValue]{
+			Val:       opt.Source,
+			MustHonor: opt.MustHonor.attr(MustHonorSource),
+		})
+	}
+
+	if opt.Format != UnknownFormatValue {
+		dp.Format = optional.New(ValWithOptions[FormatValue]{
+			Val:       opt.Format,
+			MustHonor: opt.MustHonor.attr(MustHonorFormat),
+		})
+	}
+
+	if opt.ContentType != UnknownContentTypeValue {
+		dp.ContentType = optional.New(ValWithOptions[ContentTypeValue]{
+			Val:       opt.ContentType,
+			MustHonor: opt.MustHonor.attr(MustHonorContentType),
+		})
+	}
+
+	if opt.FilmScanMode != UnknownFilmScanMode {
+		dp.FilmScanMode = optional.New(ValWithOptions[FilmScanMode]{
+			Val: opt.FilmScanMode,
+		})
+	}
+
+	// Build MediaSides. For duplex, both sides use the
+	// same parameters.
+	side := MediaSide{}
+
+	if opt.Color != UnknownColorEntry {
+		side.ColorProcessing = optional.New(ValWithOptions[ColorEntry]{
+			Val:       opt.Color,
+			MustHonor: opt.MustHonor.attr(MustHonorColor),
+		})
+	}
+
+	if opt.Resolution != 0 {
+		side.Resolution = optional.New(Resolution{
+			Width:     ValWithOptions[int]{Val: opt.Resolution},
+			Height:    ValWithOptions[int]{Val: opt.Resolution},
+			MustHonor: opt.MustHonor.attr(MustHonorResolution),
+		})
+	}
+
+	if side != (MediaSide{}) {
+		ms := MediaSides{MediaFront: side}
+		if opt.Duplex {
+			ms.MediaBack = optional.New(side)
+		}
+		dp.MediaSides = optional.New(ms)
+	}
+
+	ticket.DocumentParameters = optional.New(dp)
+
+	return ticket, nil
+}
+
+// TicketADFColorPDF returns the [ScanTicket] for the single-sided
+// color scan from the ADF into PDF/A at the specified resolution.
+//
+// The scanner must honor input source and format; color and
+// resolution it may substitute with the closest supported.
+// If dpi is 0, the scanner default resolution is used.
+func TicketADFColorPDF(dpi int) ScanTicket {
+	return ticketTemplate(TicketOptions{
+		Source:     InputSourceADF,
+		Format:     PDFA,
+		Color:      RGB24,
+		Resolution: dpi,
+		MustHonor:  MustHonorSource | MustHonorFormat,
+	})
+}
+
+// TicketPlatenGrayJPEG returns the [ScanTicket] for the grayscale
+// scan from the platen into JPEG at the specified resolution.
+//
+// The scanner must honor input source and format; color and
+// resolution it may substitute with the closest supported.
+// If dpi is 0, the scanner default resolution is used.
+func TicketPlatenGrayJPEG(dpi int) ScanTicket {
+	return ticketTemplate(TicketOptions{
+		Source:     InputSourcePlaten,
+		Format:     JFIF,
+		Color:      Grayscale8,
+		Resolution: dpi,
+		MustHonor:  MustHonorSource | MustHonorFormat,
+	})
+}
+
+// TicketDuplex returns the [ScanTicket] for the double-sided scan
+// from the ADF at the specified resolution and format.
+//
+// The scanner must honor input source and format; resolution it
+// may substitute with the closest supported. If dpi is 0, the
+// scanner default resolution is used.
+func TicketDuplex(dpi int, format FormatValue) ScanTicket {
+	return ticketTemplate(TicketOptions{
+		Source:     InputSourceADFDuplex,
+		Duplex:     true,
+		Format:     format,
+		Resolution: dpi,
+		MustHonor:  MustHonorSource | MustHonorFormat,
+	})
+}
+
+// ticketTemplate builds the ScanTicket for the template.
+// Templates' options are consistent by construction, so
+// the only possible error is the negative resolution,
+// which is treated as "not specified".
+func ticketTemplate(opt TicketOptions) ScanTicket {
+	if opt.Resolution < 0 {
+		opt.Resolution = 0
+	}
+
+	ticket, err := TicketFromOptions(opt)
+	if err != nil {
+		panic(err)
+	}
+
+	return ticket
+}
+
+// attr returns the MustHonor attribute value for the element.
+// Elements not in the set don't have the MustHonor attribute.
+func (mh TicketMustHonor) attr(elem TicketMustHonor) optional.Val[BooleanElement] {
+	if mh&elem != 0 {
+		return optional.New(BooleanElement("true"))
+	}
+	return nil
+}
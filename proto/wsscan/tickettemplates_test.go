@@ -0,0 +1,283 @@
+// MFP - Multi-Function Printers and scanners toolkit
+// WS-Scan core protocol
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// ScanTicket templates test
+
+package wsscan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/abstract"
+	"github.com/OpenPrinting/go-mfp/internal/testutils"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/go-mfp/util/xmldoc"
+)
+
+// testTicketCaps returns the scanner capabilities, made from the
+// representative ScannerConfiguration fixture: the conformance
+// GetScannerElementsResponse example.
+func testTicketCaps(t *testing.T) *abstract.ScannerCapabilities {
+	fp, err := os.Open(filepath.Join(testConformanceDir,
+		"GetScannerElementsResponse.xml"))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer fp.Close()
+
+	xml, err := xmldoc.Decode(NsMap, fp)
+	if err != nil {
+		t.Fatalf("xmldoc.Decode: %s", err)
+	}
+
+	rsp, err := decodeGetScannerElementsResponse(xml)
+	if err != nil {
+		t.Fatalf("decodeGetScannerElementsResponse: %s", err)
+	}
+
+	return rsp.ToAbstract()
+}
+
+// TestTicketTemplates tests that ticket templates validate against
+// the representative ScannerConfiguration.
+func TestTicketTemplates(t *testing.T) {
+	caps := testTicketCaps(t)
+
+	type testData struct {
+		name    string                           // Template name
+		ticket  ScanTicket                       // The ticket
+		input   abstract.Input                   // Expected input
+		adfMode abstract.ADFMode                 // Expected ADF mode
+		format  string                           // Expected document format
+		color   abstract.ColorMode               // Expected color mode
+		res     int                              // Expected resolution
+		check   func(dp DocumentParameters) bool // Extra check
+	}
+
+	tests := []testData{
+		{
+			name:    "TicketADFColorPDF",
+			ticket:  TicketADFColorPDF(300),
+			input:   abstract.InputADF,
+			adfMode: abstract.ADFModeSimplex,
+			format:  "application/pdf",
+			color:   abstract.ColorModeColor,
+			res:     300,
+		},
+		{
+			name:   "TicketPlatenGrayJPEG",
+			ticket: TicketPlatenGrayJPEG(200),
+			input:  abstract.InputPlaten,
+			format: "image/jpeg",
+			color:  abstract.ColorModeMono,
+			res:    200,
+		},
+		{
+			name:    "TicketDuplex",
+			ticket:  TicketDuplex(200, JFIF),
+			input:   abstract.InputADF,
+			adfMode: abstract.ADFModeDuplex,
+			format:  "image/jpeg",
+			res:     200,
+			check: func(dp DocumentParameters) bool {
+				return dp.MediaSides != nil &&
+					optional.Get(dp.MediaSides).MediaBack != nil
+			},
+		},
+	}
+
+	for _, test := range tests {
+		// Ticket must survive XML round trip
+		xml := test.ticket.toXML(NsWSCN + ":ScanTicket")
+		decoded, err := decodeScanTicket(xml)
+		if err != nil {
+			t.Errorf("%s: decode: %s", test.name, err)
+			continue
+		}
+
+		diff := testutils.Diff(test.ticket, decoded)
+		if diff != "" {
+			t.Errorf("%s: round trip:\n%s", test.name, diff)
+		}
+
+		// Validate against the scanner
+		req, fault := fillTicketRequest(caps, test.ticket)
+		if fault != nil {
+			t.Errorf("%s: validation failed: %s", test.name, fault)
+			continue
+		}
+
+		if req.Input != test.input {
+			t.Errorf("%s: Input: expected %s, present %s",
+				test.name, test.input, req.Input)
+		}
+
+		if req.ADFMode != test.adfMode {
+			t.Errorf("%s: ADFMode: expected %s, present %s",
+				test.name, test.adfMode, req.ADFMode)
+		}
+
+		if req.DocumentFormat != test.format {
+			t.Errorf("%s: DocumentFormat: expected %s, present %s",
+				test.name, test.format, req.DocumentFormat)
+		}
+
+		if test.color != abstract.ColorModeUnset &&
+			req.ColorMode != test.color {
+			t.Errorf("%s: ColorMode: expected %s, present %s",
+				test.name, test.color, req.ColorMode)
+		}
+
+		if req.Resolution.XResolution != test.res ||
+			req.Resolution.YResolution != test.res {
+			t.Errorf("%s: Resolution: expected %d, present %dx%d",
+				test.name, test.res, req.Resolution.XResolution,
+				req.Resolution.YResolution)
+		}
+
+		dp := optional.Get(test.ticket.DocumentParameters)
+		if test.check != nil && !test.check(dp) {
+			t.Errorf("%s: extra check failed", test.name)
+		}
+	}
+}
+
+// TestTicketTemplatesMustHonor tests that templates mark input
+// source and format as MustHonor, and other parameters not.
+func TestTicketTemplatesMustHonor(t *testing.T) {
+	caps := testTicketCaps(t)
+
+	// Unsupported format must be rejected
+	_, fault := fillTicketRequest(caps, TicketDuplex(200, XPS))
+	switch {
+	case fault == nil:
+		t.Errorf("unsupported format: fault expected")
+	case fault.Subcode != FaultClientErrorFormatNotSupported:
+		t.Errorf("unsupported format: unexpected fault %s", fault)
+	}
+
+	// Unsupported resolution must be substituted
+	req, fault := fillTicketRequest(caps, TicketADFColorPDF(1200))
+	switch {
+	case fault != nil:
+		t.Errorf("unsupported resolution: unexpected fault %s", fault)
+	case req.Resolution.XResolution == 1200:
+		t.Errorf("unsupported resolution: not substituted")
+	}
+}
+
+// TestTicketFromOptions tests TicketFromOptions
+func TestTicketFromOptions(t *testing.T) {
+	type testData struct {
+		name string        // Test name
+		opt  TicketOptions // Input options
+		err  error         // Expected error
+	}
+
+	tests := []testData{
+		{
+			name: "empty",
+			opt:  TicketOptions{},
+		},
+		{
+			name: "duplex",
+			opt: TicketOptions{
+				Source: InputSourceADFDuplex,
+				Duplex: true,
+			},
+		},
+		{
+			name: "duplex from platen",
+			opt: TicketOptions{
+				Source: InputSourcePlaten,
+				Duplex: true,
+			},
+			err: ErrTicketDuplexSource,
+		},
+		{
+			name: "duplex from simplex ADF",
+			opt: TicketOptions{
+				Source: InputSourceADF,
+				Duplex: true,
+			},
+			err: ErrTicketDuplexSource,
+		},
+		{
+			name: "duplex without source",
+			opt:  TicketOptions{Duplex: true},
+			err:  ErrTicketDuplexSource,
+		},
+		{
+			name: "film",
+			opt: TicketOptions{
+				Source:       InputSourceFilm,
+				FilmScanMode: ColorSlideFilm,
+			},
+		},
+		{
+			name: "film from ADF",
+			opt: TicketOptions{
+				Source:       InputSourceADF,
+				FilmScanMode: ColorNegativeFilm,
+			},
+			err: ErrTicketFilmSource,
+		},
+		{
+			name: "film not applicable",
+			opt: TicketOptions{
+				Source:       InputSourcePlaten,
+				FilmScanMode: NotApplicable,
+			},
+		},
+		{
+			name: "negative resolution",
+			opt:  TicketOptions{Resolution: -300},
+			err:  ErrTicketResolution,
+		},
+	}
+
+	for _, test := range tests {
+		ticket, err := TicketFromOptions(test.opt)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: error mismatch:\nexpected: %v\npresent:  %v",
+				test.name, test.err, err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		jd := ticket.JobDescription
+		if jd.JobName == "" || jd.JobOriginatingUserName == "" {
+			t.Errorf("%s: JobDescription not filled: %+v",
+				test.name, jd)
+		}
+	}
+
+	// MustHonor attributes
+	ticket, _ := TicketFromOptions(TicketOptions{
+		Source:     InputSourcePlaten,
+		Format:     PNG,
+		Resolution: 300,
+		MustHonor:  MustHonorResolution,
+	})
+
+	dp := optional.Get(ticket.DocumentParameters)
+	res := optional.Get(optional.Get(dp.MediaSides).MediaFront.Resolution)
+
+	if !mustHonor(res.MustHonor) {
+		t.Errorf("Resolution: MustHonor expected")
+	}
+
+	if optional.Get(dp.InputSource).MustHonor != nil ||
+		optional.Get(dp.Format).MustHonor != nil {
+		t.Errorf("InputSource, Format: unexpected MustHonor")
+	}
+}
@@ -44,7 +44,7 @@ func decodeValidateScanTicketRequest(root xmldoc.Element) (
 
 	missed := root.Lookup(&scanTicket)
 	if missed != nil && missed.Required {
-		return vstr, xmldoc.XMLErrMissed(missed.Name)
+		return vstr, errMissed(missed.Name)
 	}
 
 	// Decode ScanTicket (required)
@@ -155,6 +155,10 @@ func (t *ValWithOptions[T]) decodeValWithOptions(
 	// Decode the text value using the provided decoder
 	val, err := decoder(root.Text)
 	if err != nil {
+		// Decoder doesn't know the element name
+		if e, ok := AsInvalidValueError(err); ok && e.Path == "" {
+			e.Path = root.Name
+		}
 		return *t, err
 	}
 	t.Val = val
@@ -163,7 +167,7 @@ func (t *ValWithOptions[T]) decodeValWithOptions(
 	if attr, found := root.AttrByName(NsWSCN + ":MustHonor"); found {
 		boolVal := BooleanElement(attr.Value)
 		if err := boolVal.Validate(); err != nil {
-			return *t, err
+			return *t, errInvalidAttr(root, attr, err)
 		}
 		t.MustHonor = optional.New(boolVal)
 	}
@@ -172,7 +176,7 @@ func (t *ValWithOptions[T]) decodeValWithOptions(
 	if attr, found := root.AttrByName(NsWSCN + ":Override"); found {
 		boolVal := BooleanElement(attr.Value)
 		if err := boolVal.Validate(); err != nil {
-			return *t, err
+			return *t, errInvalidAttr(root, attr, err)
 		}
 		t.Override = optional.New(boolVal)
 	}
@@ -181,7 +185,7 @@ func (t *ValWithOptions[T]) decodeValWithOptions(
 	if attr, found := root.AttrByName(NsWSCN + ":UsedDefault"); found {
 		boolVal := BooleanElement(attr.Value)
 		if err := boolVal.Validate(); err != nil {
-			return *t, err
+			return *t, errInvalidAttr(root, attr, err)
 		}
 		t.UsedDefault = optional.New(boolVal)
 	}
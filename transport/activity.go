@@ -0,0 +1,168 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Connection activity deadlines
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+)
+
+// AutoTLSActivityTimeout is the default activity window of the
+// connections, accepted by the [AutoTLSListener].
+//
+// If connection has no successful Read or Write within this window,
+// it is closed, so the half-open sessions (for example, client that
+// stopped reading the response body) don't hold resources forever.
+const AutoTLSActivityTimeout = 5 * time.Minute
+
+// activityConn wraps net.Conn and closes it when no Read or Write
+// succeeds within the activity window.
+//
+// The activity deadline is implemented with a timer rather than
+// with net.Conn.SetDeadline, so it doesn't interfere with deadlines,
+// set by the http.Server.
+type activityConn struct {
+	net.Conn                    // Underlying connection
+	timeout  time.Duration      // Activity window
+	timer    *time.Timer        // Activity timer
+	onReap   func()             // Called when connection is reaped
+	lock     sync.Mutex         // Access lock
+	cancel   context.CancelFunc // Cancels the connection context
+	closed   bool               // Connection is closed or reaped
+}
+
+// newActivityConn wraps net.Conn into the activityConn.
+// The onReap callback is called when connection is closed due
+// to inactivity.
+func newActivityConn(c net.Conn, timeout time.Duration,
+	onReap func()) *activityConn {
+
+	ac := &activityConn{
+		Conn:    c,
+		timeout: timeout,
+		onReap:  onReap,
+	}
+
+	ac.timer = time.AfterFunc(timeout, ac.reap)
+	return ac
+}
+
+// activityConnContext returns the context for the connection.
+//
+// If connection (possibly wrapped into the *tls.Conn) is the
+// activityConn, the returned context is canceled when connection
+// is reaped. Requests to the upstream servers, made within the
+// request context, are canceled as well, which closes the upstream
+// connections.
+func activityConnContext(ctx context.Context, c net.Conn) context.Context {
+	if tlsconn, ok := c.(*tls.Conn); ok {
+		c = tlsconn.NetConn()
+	}
+
+	ac, ok := c.(*activityConn)
+	if !ok {
+		return ctx
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	ac.lock.Lock()
+	if ac.closed {
+		cancel()
+	} else {
+		ac.cancel = cancel
+	}
+	ac.lock.Unlock()
+
+	return ctx
+}
+
+// Read reads data from the connection.
+func (ac *activityConn) Read(b []byte) (int, error) {
+	n, err := ac.Conn.Read(b)
+	if n > 0 || err == nil {
+		ac.touch()
+	}
+	return n, err
+}
+
+// Write writes data to the connection.
+func (ac *activityConn) Write(b []byte) (int, error) {
+	n, err := ac.Conn.Write(b)
+	if n > 0 || err == nil {
+		ac.touch()
+	}
+	return n, err
+}
+
+// Close closes the connection.
+func (ac *activityConn) Close() error {
+	ac.timer.Stop()
+	ac.shutdown()
+	return ac.Conn.Close()
+}
+
+// SetLinger passes SetLinger to the underlying connection,
+// so AbortConn works with the activityConn.
+func (ac *activityConn) SetLinger(sec int) error {
+	if withSetLinger, ok := ac.Conn.(connWithSetLinger); ok {
+		return withSetLinger.SetLinger(sec)
+	}
+	return nil
+}
+
+// CloseWrite passes CloseWrite to the underlying connection,
+// so CloseWrite works with the activityConn.
+func (ac *activityConn) CloseWrite() error {
+	return CloseWrite(ac.Conn)
+}
+
+// touch pushes the activity deadline forward.
+func (ac *activityConn) touch() {
+	ac.lock.Lock()
+	if !ac.closed {
+		ac.timer.Reset(ac.timeout)
+	}
+	ac.lock.Unlock()
+}
+
+// reap closes the connection when activity deadline expires.
+func (ac *activityConn) reap() {
+	if !ac.shutdown() {
+		return
+	}
+
+	AbortConn(ac.Conn)
+
+	if ac.onReap != nil {
+		ac.onReap()
+	}
+}
+
+// shutdown marks connection as closed and cancels its context.
+// It returns false, if connection is already closed.
+func (ac *activityConn) shutdown() bool {
+	ac.lock.Lock()
+	defer ac.lock.Unlock()
+
+	if ac.closed {
+		return false
+	}
+
+	ac.closed = true
+	if ac.cancel != nil {
+		ac.cancel()
+		ac.cancel = nil
+	}
+
+	return true
+}
@@ -0,0 +1,160 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Connection activity deadlines test
+
+package transport
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestActivityReap tests that stalled client is reaped together
+// with the upstream connection.
+func TestActivityReap(t *testing.T) {
+	const window = 200 * time.Millisecond
+
+	// Upstream server sends endless response body
+	upstreamDone := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			defer close(upstreamDone)
+
+			w.WriteHeader(http.StatusOK)
+			chunk := make([]byte, 64*1024)
+			for {
+				_, err := w.Write(chunk)
+				if err != nil {
+					return
+				}
+			}
+		}))
+	defer upstream.Close()
+
+	// Proxy forwards requests to upstream
+	proxy := NewServer(context.Background(), nil, http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			rq, err := http.NewRequestWithContext(r.Context(),
+				"GET", upstream.URL, nil)
+			if err != nil {
+				t.Errorf("%s", err)
+				return
+			}
+
+			rsp, err := http.DefaultClient.Do(rq)
+			if err != nil {
+				t.Errorf("%s", err)
+				return
+			}
+			defer rsp.Body.Close()
+
+			w.WriteHeader(rsp.StatusCode)
+			io.Copy(w, rsp.Body)
+		}))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	plain, _ := NewAutoTLSListener(l)
+	defer plain.Close()
+
+	plain.SetActivityTimeout(window)
+	go proxy.Serve(plain)
+
+	// Send request, receive response headers and stall
+	clnt, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer clnt.Close()
+
+	clnt.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+
+	rsp, err := http.ReadResponse(bufio.NewReader(clnt), nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("HTTP status: %s", rsp.Status)
+	}
+
+	// Upstream connection must be closed
+	select {
+	case <-upstreamDone:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("upstream connection not closed")
+	}
+
+	// Client connection must be closed
+	clnt.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.Copy(io.Discard, clnt)
+
+	var neterr net.Error
+	if errors.As(err, &neterr) && neterr.Timeout() {
+		t.Errorf("client connection not closed")
+	}
+
+	// Check statistics
+	stats := plain.Stats()
+	if stats.ActivityReaps != 1 {
+		t.Errorf("AutoTLSStats.ActivityReaps: expected 1, present %d",
+			stats.ActivityReaps)
+	}
+}
+
+// TestActivityTouch tests that active connection is not reaped.
+func TestActivityTouch(t *testing.T) {
+	const window = 200 * time.Millisecond
+
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+
+	reaped := make(chan struct{})
+	ac := newActivityConn(c1, window, func() { close(reaped) })
+	ctx := activityConnContext(context.Background(), ac)
+
+	// Keep connection active for several windows
+	go io.Copy(io.Discard, c2)
+
+	deadline := time.Now().Add(3 * window)
+	for time.Now().Before(deadline) {
+		ac.Write([]byte("ping"))
+		time.Sleep(window / 4)
+	}
+
+	select {
+	case <-reaped:
+		t.Fatalf("active connection reaped")
+	default:
+	}
+
+	// Now stall
+	select {
+	case <-reaped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("stalled connection not reaped")
+	}
+
+	if ctx.Err() == nil {
+		t.Errorf("connection context not canceled")
+	}
+
+	_, err := ac.Write([]byte("ping"))
+	if err == nil {
+		t.Errorf("write to reaped connection succeeded")
+	}
+}
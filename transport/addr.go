@@ -0,0 +1,87 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// IPv4-mapped addresses normalization
+
+package transport
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+)
+
+// NormalizeAddr converts the IPv4-mapped IPv6 address
+// (i.e., "::ffff:192.0.2.1") into its IPv4 form ("192.0.2.1").
+// Other addresses are returned as is.
+//
+// The mapped form appears in addresses of connections, accepted
+// by the dual-stack sockets, in the addresses of the dual-stack
+// sockets themselves and occasionally in URLs, advertised by
+// devices. Addresses must be normalized before comparison,
+// otherwise the same host will appear different.
+func NormalizeAddr(addr netip.Addr) netip.Addr {
+	if addr.Is4In6() {
+		return addr.Unmap()
+	}
+	return addr
+}
+
+// NormalizeAddrPort is like [NormalizeAddr], but for
+// the [netip.AddrPort].
+func NormalizeAddrPort(addrport netip.AddrPort) netip.AddrPort {
+	if addrport.Addr().Is4In6() {
+		return netip.AddrPortFrom(addrport.Addr().Unmap(),
+			addrport.Port())
+	}
+	return addrport
+}
+
+// NormalizeURL converts the IPv4-mapped IPv6 literal address
+// in the URL host (i.e., "http://[::ffff:192.0.2.1]:80/") into its
+// IPv4 form ("http://192.0.2.1:80/"). See [NormalizeAddr] for details.
+//
+// If URL doesn't need normalization, it is returned as is.
+// Otherwise, the normalized copy is returned and the input URL
+// is not modified.
+func NormalizeURL(u *url.URL) *url.URL {
+	host := normalizeHost(u.Host)
+	if host == u.Host {
+		return u
+	}
+
+	u = URLClone(u)
+	u.Host = host
+
+	return u
+}
+
+// normalizeHost normalizes the host or host:port string, in the
+// form, used by the [url.URL.Host] and by [net.Dial].
+// See [NormalizeAddr] for details.
+func normalizeHost(host string) string {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		// No port. IPv6 literal comes in square brackets
+		// in URL and without them in address
+		hostname, port = host, ""
+		if l := len(host); l > 2 && host[0] == '[' && host[l-1] == ']' {
+			hostname = host[1 : l-1]
+		}
+	}
+
+	addr, err := netip.ParseAddr(hostname)
+	if err != nil || !addr.Is4In6() {
+		return host
+	}
+
+	hostname = addr.Unmap().String()
+	if port != "" {
+		return net.JoinHostPort(hostname, port)
+	}
+
+	return hostname
+}
@@ -0,0 +1,149 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// IPv4-mapped addresses normalization test
+
+package transport
+
+import (
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+// TestNormalizeAddr tests NormalizeAddr and NormalizeAddrPort
+func TestNormalizeAddr(t *testing.T) {
+	type testData struct {
+		in, out string
+	}
+
+	tests := []testData{
+		{"::ffff:192.168.1.5", "192.168.1.5"},
+		{"192.168.1.5", "192.168.1.5"},
+		{"::1", "::1"},
+		{"fe80::1%eth0", "fe80::1%eth0"},
+		{"2001:db8::1", "2001:db8::1"},
+	}
+
+	for _, test := range tests {
+		out := NormalizeAddr(netip.MustParseAddr(test.in))
+		if out.String() != test.out {
+			t.Errorf("NormalizeAddr(%q): expected %q, present %q",
+				test.in, test.out, out)
+		}
+
+		ap := netip.AddrPortFrom(netip.MustParseAddr(test.in), 631)
+		ap = NormalizeAddrPort(ap)
+		if ap.Addr().String() != test.out || ap.Port() != 631 {
+			t.Errorf("NormalizeAddrPort(%q): unexpected %s",
+				test.in, ap)
+		}
+	}
+
+	// Mapped and plain forms must compare equal after normalization
+	mapped := netip.MustParseAddr("::ffff:10.0.0.1")
+	plain := netip.MustParseAddr("10.0.0.1")
+
+	if mapped == plain {
+		t.Errorf("%s and %s: equal without normalization", mapped, plain)
+	}
+
+	if NormalizeAddr(mapped) != NormalizeAddr(plain) {
+		t.Errorf("%s and %s: not equal after normalization",
+			mapped, plain)
+	}
+}
+
+// TestNormalizeURL tests NormalizeURL
+func TestNormalizeURL(t *testing.T) {
+	type testData struct {
+		in, out string
+	}
+
+	tests := []testData{
+		{
+			in:  "http://[::ffff:192.168.1.5]:8080/eSCL",
+			out: "http://192.168.1.5:8080/eSCL",
+		},
+		{
+			in:  "ipp://[::ffff:192.168.1.5]/ipp/print",
+			out: "ipp://192.168.1.5/ipp/print",
+		},
+		{
+			in:  "http://192.168.1.5:8080/eSCL",
+			out: "http://192.168.1.5:8080/eSCL",
+		},
+		{
+			in:  "http://[fe80::1%25eth0]:8080/eSCL",
+			out: "http://[fe80::1%25eth0]:8080/eSCL",
+		},
+		{
+			in:  "http://printer.local/eSCL",
+			out: "http://printer.local/eSCL",
+		},
+		{
+			in:  "unix:/var/run/cups/cups.sock",
+			out: "unix:/var/run/cups/cups.sock",
+		},
+	}
+
+	for _, test := range tests {
+		u, err := url.Parse(test.in)
+		if err != nil {
+			t.Fatalf("%q: %s", test.in, err)
+		}
+
+		saved := u.String()
+		out := NormalizeURL(u)
+
+		if out.String() != test.out {
+			t.Errorf("NormalizeURL(%q): expected %q, present %q",
+				test.in, test.out, out)
+		}
+
+		if u.String() != saved {
+			t.Errorf("NormalizeURL(%q): input modified", test.in)
+		}
+
+		if test.in == test.out && out != u {
+			t.Errorf("NormalizeURL(%q): unneeded copy", test.in)
+		}
+	}
+
+	// Equality of the mapped and plain forms
+	u1 := NormalizeURL(MustParseURL("ipp://192.168.1.5/ipp/print"))
+	u2, _ := url.Parse("ipp://[::ffff:192.168.1.5]/ipp/print")
+	u2 = NormalizeURL(u2)
+
+	if u1.String() != u2.String() {
+		t.Errorf("NormalizeURL: %q != %q", u1, u2)
+	}
+}
+
+// TestNormalizeHost tests normalization of the dial address
+func TestNormalizeHost(t *testing.T) {
+	type testData struct {
+		in, out string
+	}
+
+	tests := []testData{
+		{"[::ffff:127.0.0.1]:631", "127.0.0.1:631"},
+		{"[::ffff:127.0.0.1]", "127.0.0.1"},
+		{"::ffff:127.0.0.1", "127.0.0.1"},
+		{"127.0.0.1:631", "127.0.0.1:631"},
+		{"[::1]:631", "[::1]:631"},
+		{"localhost:631", "localhost:631"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		out := normalizeHost(test.in)
+		if out != test.out {
+			t.Errorf("normalizeHost(%q): expected %q, present %q",
+				test.in, test.out, out)
+		}
+	}
+}
@@ -12,9 +12,70 @@ import (
 	"errors"
 	"net"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
+// AutoTLSDetectTimeout is the maximum time the AutoTLS listener
+// waits for the first bytes from the client, to detect if
+// connection is encrypted or plain.
+const AutoTLSDetectTimeout = 10 * time.Second
+
+// AutoTLSHookQueueSize is the maximum count of classification
+// events, queued for the [AutoTLSClassifyHook]. If hook doesn't
+// keep up, excessive events are dropped.
+const AutoTLSHookQueueSize = 64
+
+// autoTLSPeekedMax is the maximum count of peeked bytes, passed
+// to the AutoTLSClassifyHook.
+const autoTLSPeekedMax = 8
+
+// AutoTLSListener is the [net.Listener], returned by the
+// [NewAutoTLSListener].
+//
+// It allows to observe how incoming connections are classified.
+// Both plain and encrypted listeners share the same hook and
+// the same statistics.
+type AutoTLSListener interface {
+	net.Listener
+
+	// SetClassifyHook sets the hook, called after each
+	// detection. Use nil to remove the hook.
+	SetClassifyHook(hook AutoTLSClassifyHook)
+
+	// SetActivityTimeout sets the activity window for the
+	// subsequently accepted connections (see the
+	// [AutoTLSActivityTimeout]). Zero disables activity deadlines.
+	SetActivityTimeout(timeout time.Duration)
+
+	// Stats returns the classification statistics.
+	Stats() AutoTLSStats
+}
+
+// AutoTLSClassifyHook is called after each incoming connection
+// is classified by the [AutoTLSListener].
+//
+// The remote is the client address, encrypted reports the detected
+// connection type, peeked contains up to 8 first bytes, received
+// from the client, and err is the detection error, if any.
+//
+// Hook is called from the separate goroutine, so it cannot
+// block accepting of the incoming connections. If hook is too slow,
+// some events will be dropped (see [AutoTLSStats.HookDropped]).
+type AutoTLSClassifyHook func(remote net.Addr, encrypted bool,
+	peeked []byte, err error)
+
+// AutoTLSStats contains the [AutoTLSListener] statistics.
+type AutoTLSStats struct {
+	Plain          int64 // Connections, classified as plain
+	Encrypted      int64 // Connections, classified as encrypted
+	DetectErrors   int64 // Detection failed due to error
+	DetectTimeouts int64 // Detection failed due to timeout
+	HookDropped    int64 // Events, dropped due to hook queue overflow
+	ActivityReaps  int64 // Connections, closed due to inactivity
+}
+
 // autoTLSListener wraps net.Listener and provides additional
 // functionality by multiplexing incoming connections into
 // plain (non-TLS) and encrypted (with TLS) connections.
@@ -29,6 +90,25 @@ type autoTLSListener struct {
 	parent           net.Listener          // Parent listener
 	plain, encrypted autoTLSListenerQueue  // Queues of connections
 	pending          map[net.Conn]struct{} // Detect in progress
+	hook             AutoTLSClassifyHook   // Classification hook
+	hookQueue        chan autoTLSEvent     // Queue of hook events
+	hookDone         chan struct{}         // Closed to stop hook
+	activityTimeout  time.Duration         // Connection activity window
+	stats            struct {              // Statistics
+		plain, encrypted             atomic.Int64
+		detectErrors, detectTimeouts atomic.Int64
+		hookDropped                  atomic.Int64
+		activityReaps                atomic.Int64
+	}
+}
+
+// autoTLSEvent is the classification event, queued for
+// the AutoTLSClassifyHook.
+type autoTLSEvent struct {
+	remote    net.Addr // Client address
+	encrypted bool     // Connection is encrypted
+	peeked    []byte   // First bytes, received from client
+	err       error    // Detection error
 }
 
 // autoTLSListenerChild is the child listener for autoTLSListener.
@@ -67,7 +147,12 @@ var errAutoTLSListenerClosed = errors.New("listener closed")
 //
 // Closing of any of returned listeners closes the parent listener
 // and unblocks all goroutines waiting for incoming connections.
-func NewAutoTLSListener(parent net.Listener) (plain, encrypted net.Listener) {
+//
+// Returned listeners implement [AutoTLSListener] interface, which
+// allows to observe how incoming connections are classified.
+func NewAutoTLSListener(parent net.Listener) (
+	plain, encrypted AutoTLSListener) {
+
 	_, plain, encrypted = newAutoTLSListener(parent)
 	return
 }
@@ -79,11 +164,12 @@ func NewAutoTLSListener(parent net.Listener) (plain, encrypted net.Listener) {
 // This object provides some testing interfaces. It is not intended
 // for the regular use.
 func newAutoTLSListener(parent net.Listener) (
-	atl *autoTLSListener, plain, encrypted net.Listener) {
+	atl *autoTLSListener, plain, encrypted AutoTLSListener) {
 
 	atl = &autoTLSListener{
-		parent:  parent,
-		pending: make(map[net.Conn]struct{}),
+		parent:          parent,
+		pending:         make(map[net.Conn]struct{}),
+		activityTimeout: AutoTLSActivityTimeout,
 	}
 
 	atl.wait.L = &atl.lock
@@ -114,6 +200,10 @@ func (atl *autoTLSListener) accept(encrypted bool) (net.Conn, error) {
 		// May be we already have a queued connection?
 		c := queue.pull()
 		if c != nil {
+			if atl.activityTimeout > 0 {
+				c = newActivityConn(c, atl.activityTimeout,
+					atl.reaped)
+			}
 			return c, nil
 		}
 
@@ -146,9 +236,20 @@ func (atl *autoTLSListener) accept(encrypted bool) (net.Conn, error) {
 }
 
 // close closes the listener.
+//
+// It is idempotent and safe to call concurrently from any goroutine,
+// including goroutines blocked in accept. Only the first call closes
+// the parent listener; when any call returns, the listener is closed,
+// queued and pending connections are aborted and all accept calls
+// are unblocked.
 func (atl *autoTLSListener) close() {
 	atl.lock.Lock()
 
+	if atl.closed {
+		atl.lock.Unlock()
+		return
+	}
+
 	// Close the parent listener
 	atl.parent.Close()
 
@@ -157,13 +258,19 @@ func (atl *autoTLSListener) close() {
 	atl.closed = true
 
 	for c := range atl.pending {
-		connAbort(c)
+		AbortConn(c)
 		delete(atl.pending, c)
 	}
 
 	atl.plain.purge()
 	atl.encrypted.purge()
 
+	// Stop the hook goroutine
+	if atl.hookDone != nil {
+		close(atl.hookDone)
+		atl.hookDone = nil
+	}
+
 	// Notify possible Accept-waiters
 	atl.wait.Broadcast()
 
@@ -175,6 +282,7 @@ func (atl *autoTLSListener) close() {
 // the appropriate (plain/encrypted) queue.
 func (atl *autoTLSListener) acceptWait() error {
 	var withTLS bool
+	var peeked []byte
 
 	// Accept a connection. Detect TLS on it.
 	c, err := atl.parent.Accept()
@@ -195,12 +303,16 @@ func (atl *autoTLSListener) acceptWait() error {
 		atl.lock.Unlock()
 
 		if closed {
-			connAbort(c)
+			AbortConn(c)
 			return errAutoTLSListenerClosed
 		}
 
 		// Detect TLS
-		withTLS, err = atl.detectTLS(c)
+		c.SetReadDeadline(time.Now().Add(AutoTLSDetectTimeout))
+		withTLS, peeked, err = atl.detectTLS(c)
+		c.SetReadDeadline(time.Time{})
+
+		atl.classified(c.RemoteAddr(), withTLS, peeked, err)
 	}
 
 	// Delete connection from pending and push it into
@@ -224,17 +336,126 @@ func (atl *autoTLSListener) acceptWait() error {
 
 	// Drop the connection in a case of an error.
 	if c != nil && err != nil {
-		connAbort(c)
+		AbortConn(c)
 	}
 
 	return err
 }
 
+// classified updates statistics and queues the event for the
+// classification hook, when connection is classified.
+func (atl *autoTLSListener) classified(remote net.Addr, withTLS bool,
+	peeked []byte, err error) {
+
+	var neterr net.Error
+	switch {
+	case errors.As(err, &neterr) && neterr.Timeout():
+		atl.stats.detectTimeouts.Add(1)
+	case err != nil:
+		atl.stats.detectErrors.Add(1)
+	case withTLS:
+		atl.stats.encrypted.Add(1)
+	default:
+		atl.stats.plain.Add(1)
+	}
+
+	atl.lock.Lock()
+	defer atl.lock.Unlock()
+
+	if atl.hook == nil || atl.hookDone == nil {
+		return
+	}
+
+	if len(peeked) > autoTLSPeekedMax {
+		peeked = peeked[:autoTLSPeekedMax]
+	}
+
+	evnt := autoTLSEvent{
+		remote:    remote,
+		encrypted: withTLS,
+		peeked:    append([]byte(nil), peeked...),
+		err:       err,
+	}
+
+	select {
+	case atl.hookQueue <- evnt:
+	default:
+		atl.stats.hookDropped.Add(1)
+	}
+}
+
+// setClassifyHook sets the classification hook.
+//
+// The hook goroutine is started on demand and runs until
+// listener is closed.
+func (atl *autoTLSListener) setClassifyHook(hook AutoTLSClassifyHook) {
+	atl.lock.Lock()
+	defer atl.lock.Unlock()
+
+	atl.hook = hook
+	if hook != nil && atl.hookQueue == nil && !atl.closed {
+		atl.hookQueue = make(chan autoTLSEvent, AutoTLSHookQueueSize)
+		atl.hookDone = make(chan struct{})
+		go atl.hookProc(atl.hookQueue, atl.hookDone)
+	}
+}
+
+// hookProc runs in the separate goroutine and delivers queued
+// events to the classification hook.
+func (atl *autoTLSListener) hookProc(queue chan autoTLSEvent,
+	done chan struct{}) {
+
+	for {
+		select {
+		case evnt := <-queue:
+			atl.lock.Lock()
+			hook := atl.hook
+			atl.lock.Unlock()
+
+			if hook != nil {
+				hook(evnt.remote, evnt.encrypted,
+					evnt.peeked, evnt.err)
+			}
+
+		case <-done:
+			return
+		}
+	}
+}
+
+// setActivityTimeout sets the activity window for the
+// subsequently accepted connections.
+func (atl *autoTLSListener) setActivityTimeout(timeout time.Duration) {
+	atl.lock.Lock()
+	atl.activityTimeout = timeout
+	atl.lock.Unlock()
+}
+
+// reaped updates statistics when connection is closed due
+// to inactivity.
+func (atl *autoTLSListener) reaped() {
+	atl.stats.activityReaps.Add(1)
+}
+
+// getStats returns the classification statistics.
+func (atl *autoTLSListener) getStats() AutoTLSStats {
+	return AutoTLSStats{
+		Plain:          atl.stats.plain.Load(),
+		Encrypted:      atl.stats.encrypted.Load(),
+		DetectErrors:   atl.stats.detectErrors.Load(),
+		DetectTimeouts: atl.stats.detectTimeouts.Load(),
+		HookDropped:    atl.stats.hookDropped.Load(),
+		ActivityReaps:  atl.stats.activityReaps.Load(),
+	}
+}
+
 // detectTLS detects if connection is encrypted or plain.
+// On success, it returns the bytes, peeked from the connection.
 //
 // Detection requires few bytes of data to be fetched from the
 // connection, and it may fail, so the function may return error.
-func (atl *autoTLSListener) detectTLS(c net.Conn) (withTLS bool, err error) {
+func (atl *autoTLSListener) detectTLS(c net.Conn) (
+	withTLS bool, peeked []byte, err error) {
 	conn, ok := c.(autoTLSWithSyscallConn)
 	if ok {
 		rawconn, err := conn.SyscallConn()
@@ -246,16 +467,16 @@ func (atl *autoTLSListener) detectTLS(c net.Conn) (withTLS bool, err error) {
 	// FIXME - implement detectTLS on connections that
 	// don't provide a SyscallConn() method.
 
-	return false, nil
+	return false, nil, nil
 }
 
 // detectTLSRawConn detects TLS on a syscall.RawConn.
 func (atl *autoTLSListener) detectTLSRawConn(rawconn syscall.RawConn) (
-	withTLS bool, err error) {
+	withTLS bool, peeked []byte, err error) {
 
 	buf := make([]byte, 16)
 
-	rawconn.Read(func(fd uintptr) bool {
+	err2 := rawconn.Read(func(fd uintptr) bool {
 		var n int
 		n, _, err = syscall.Recvfrom(int(fd), buf,
 			syscall.MSG_PEEK)
@@ -272,11 +493,18 @@ func (atl *autoTLSListener) detectTLSRawConn(rawconn syscall.RawConn) (
 		return false
 	})
 
+	// rawconn.Read fails, for example, when read deadline
+	// expires before any data is received.
+	if err2 != nil {
+		err = err2
+	}
+
 	if err == nil {
 		withTLS = buf[0] == 0x16
+		peeked = buf
 	}
 
-	return withTLS, err
+	return withTLS, peeked, err
 }
 
 // testCounters returns counters of queued plain, encrypted and
@@ -300,7 +528,8 @@ func (l autoTLSListenerChild) Accept() (net.Conn, error) {
 	return l.accept(l.encrypted)
 }
 
-// Close closes the listener.
+// Close closes the listener. Closing either of the buddy listeners
+// closes both; repeated and concurrent calls are safe.
 func (l autoTLSListenerChild) Close() error {
 	l.close()
 	return nil
@@ -311,6 +540,25 @@ func (l autoTLSListenerChild) Addr() net.Addr {
 	return l.parent.Addr()
 }
 
+// SetClassifyHook sets the classification hook.
+// It is shared between plain and encrypted listeners.
+func (l autoTLSListenerChild) SetClassifyHook(hook AutoTLSClassifyHook) {
+	l.setClassifyHook(hook)
+}
+
+// SetActivityTimeout sets the activity window for the subsequently
+// accepted connections. It is shared between plain and encrypted
+// listeners.
+func (l autoTLSListenerChild) SetActivityTimeout(timeout time.Duration) {
+	l.setActivityTimeout(timeout)
+}
+
+// Stats returns the classification statistics.
+// They are shared between plain and encrypted listeners.
+func (l autoTLSListenerChild) Stats() AutoTLSStats {
+	return l.getStats()
+}
+
 // push adds connection to the queue.
 func (q *autoTLSListenerQueue) push(c net.Conn) {
 	q.connections = append(q.connections, c)
@@ -330,7 +578,7 @@ func (q *autoTLSListenerQueue) pull() (c net.Conn) {
 // purge removes and aborts all the queued connections.
 func (q *autoTLSListenerQueue) purge() {
 	for _, c := range q.connections {
-		connAbort(c)
+		AbortConn(c)
 	}
 	q.connections = q.connections[:0]
 }
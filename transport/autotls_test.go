@@ -17,6 +17,7 @@ import (
 	"crypto/x509/pkix"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"net"
 	"net/http"
@@ -308,7 +309,7 @@ func testAutoTLSAbortingClient(t *testing.T, tr *Transport, l net.Listener) {
 		// preventing client from sending anything
 
 		<-cancelable.Done()
-		connAbort(conn)
+		AbortConn(conn)
 
 		return nil, errors.New("canceled")
 	}
@@ -500,3 +501,186 @@ func testAutoTLSCertGenerate() *tls.Certificate {
 
 	return cert
 }
+
+// TestAutoTLSClassifyHook tests the AutoTLS classification hook
+func TestAutoTLSClassifyHook(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	p, e := NewAutoTLSListener(l)
+	defer p.Close()
+
+	// testEvent is the hook event
+	type testEvent struct {
+		remote    string
+		encrypted bool
+		peeked    string
+		err       error
+	}
+
+	events := make(chan testEvent, 2)
+	p.SetClassifyHook(func(remote net.Addr, encrypted bool,
+		peeked []byte, err error) {
+		events <- testEvent{remote.String(), encrypted,
+			string(peeked), err}
+	})
+
+	// Drive one plain and one TLS connection
+	tests := []struct {
+		data      string
+		listener  net.Listener
+		encrypted bool
+	}{
+		{"GET / HTTP/1.1\r\n\r\n", p, false},
+		{"\x16\x03\x01\x02\x00\x01\x00\x01\xfc\x03\x03", e, true},
+	}
+
+	for _, test := range tests {
+		clnt, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		defer clnt.Close()
+
+		clnt.Write([]byte(test.data))
+
+		conn, err := test.listener.Accept()
+		if err != nil {
+			t.Fatalf("Accept: %s", err)
+		}
+		conn.Close()
+
+		expected := testEvent{
+			remote:    clnt.LocalAddr().String(),
+			encrypted: test.encrypted,
+			peeked:    test.data[:autoTLSPeekedMax],
+		}
+
+		select {
+		case evnt := <-events:
+			if evnt != expected {
+				t.Errorf("hook event:\n"+
+					"expected: %+v\npresent:  %+v",
+					expected, evnt)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("hook not called")
+		}
+	}
+
+	// Check statistics
+	stats := e.Stats()
+	expected := AutoTLSStats{Plain: 1, Encrypted: 1}
+	if stats != expected {
+		t.Errorf("AutoTLSStats:\nexpected: %+v\npresent:  %+v",
+			expected, stats)
+	}
+}
+
+// TestAutoTLSSlowHook tests that slow classification hook doesn't
+// delay Accept
+func TestAutoTLSSlowHook(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	p, _ := NewAutoTLSListener(l)
+	defer p.Close()
+
+	// Hook blocks until the test ends
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	p.SetClassifyHook(func(net.Addr, bool, []byte, error) {
+		<-unblock
+	})
+
+	// Overflow the hook queue. Each Accept must not be delayed.
+	// Note, the first event is consumed by the hook goroutine.
+	count := AutoTLSHookQueueSize + 3
+	for i := 0; i < count; i++ {
+		clnt, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		defer clnt.Close()
+
+		clnt.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+
+		start := time.Now()
+		conn, err := p.Accept()
+		if err != nil {
+			t.Fatalf("Accept: %s", err)
+		}
+		conn.Close()
+
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("Accept delayed by the hook: %s", d)
+		}
+	}
+
+	stats := p.Stats()
+	if stats.Plain != int64(count) || stats.HookDropped == 0 {
+		t.Errorf("AutoTLSStats: %+v", stats)
+	}
+}
+
+// TestServerAutoTLSHTTP2 tests that Server.ServeAutoTLS serves
+// HTTPS requests of clients that negotiate HTTP/2, regardless of
+// the order in which the plain and encrypted serving goroutines
+// are started.
+func TestServerAutoTLSHTTP2(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter,
+		rq *http.Request) {
+		w.Write([]byte(rq.Proto))
+	})
+
+	for round := 0; round < 20; round++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		srvr := NewServer(context.Background(), nil, handler)
+		srvr.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{*testAutoTLSCert},
+		}
+
+		done := make(chan struct{})
+		go func() {
+			srvr.ServeAutoTLS(l)
+			close(done)
+		}()
+
+		clnt := NewClient(NewTransport(nil))
+		clnt.Timeout = 5 * time.Second
+
+		for _, scheme := range []string{"http", "https"} {
+			u := MustParseURL(scheme + "://" + l.Addr().String() + "/")
+			rsp, err := clnt.Get(u.String())
+			if err != nil {
+				t.Fatalf("round %d: GET %s: %s", round, u, err)
+			}
+
+			body, err := io.ReadAll(rsp.Body)
+			rsp.Body.Close()
+
+			expected := "HTTP/1.1"
+			if scheme == "https" {
+				expected = "HTTP/2.0"
+			}
+
+			if err != nil || string(body) != expected {
+				t.Fatalf("round %d: GET %s: expected %s, present %q %v",
+					round, u, expected, body, err)
+			}
+		}
+
+		clnt.CloseIdleConnections()
+		srvr.Close()
+		<-done
+	}
+}
@@ -0,0 +1,213 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Binding of outgoing connections to interface and local address
+
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// bindDialer is the template for dialers, used by dialBound.
+// It uses the same parameters as the [http.DefaultTransport].
+var bindDialer = net.Dialer{
+	Timeout:   30 * time.Second,
+	KeepAlive: 30 * time.Second,
+}
+
+// dialBinding specifies how outgoing connections are bound
+// to the local interface and address.
+type dialBinding struct {
+	ifname string     // Interface name, "" if not bound
+	addr   netip.Addr // Local address, invalid if not set
+}
+
+// dialBindingKey is the context.Context key for dialBinding.
+type dialBindingKey struct{}
+
+// bindIfKey is the context.Context key for WithBindInterface.
+type bindIfKey struct{}
+
+// SetLocalAddr sets the source address for outgoing connections.
+// If addr is not valid (i.e., zero netip.Addr), the source address
+// is chosen by the OS.
+//
+// It must be called before the Transport is used.
+//
+// Binding is only applied if Transport uses the default dialer (i.e.,
+// the template passed to the [NewTransport] was nil or didn't have the
+// DialContext callback).
+func (tr *Transport) SetLocalAddr(addr netip.Addr) {
+	tr.bind.addr = addr
+}
+
+// SetBindInterface binds outgoing connections to the network
+// interface, specified by name. Empty name removes the binding.
+//
+// When host has multiple routes to the device (i.e., via VPN and
+// via LAN), it allows to choose the one explicitly.
+//
+// On Linux it uses SO_BINDTODEVICE. If not available, the local
+// address of the interface is used as the source address.
+//
+// It returns an error if interface doesn't exist.
+//
+// It must be called before the Transport is used. See
+// [Transport.SetLocalAddr] for the limitations.
+func (tr *Transport) SetBindInterface(name string) error {
+	if name != "" {
+		if _, err := bindInterfaceByName(name); err != nil {
+			return err
+		}
+	}
+
+	tr.bind.ifname = name
+	return nil
+}
+
+// WithBindInterface returns the derived context that overrides
+// the interface binding (see [Transport.SetBindInterface]) for the
+// requests, performed with this context.
+//
+// It is intended for cases when caller knows which interface device
+// is reachable through, like discovery, that knows which interface
+// the device announcement was received from.
+//
+// Connections, established with the different bindings, are never
+// shared between requests.
+func WithBindInterface(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, bindIfKey{}, name)
+}
+
+// bindInterfaceFromContext returns interface binding, set by
+// WithBindInterface, if any.
+func bindInterfaceFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(bindIfKey{}).(string)
+	return name, ok
+}
+
+// bindInterfaceByName returns net.Interface by name.
+// Errors are wrapped to be descriptive.
+func bindInterfaceByName(name string) (*net.Interface, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("bind to interface %q: %w", name, err)
+	}
+	return ifi, nil
+}
+
+// dialBound is the default dialer. It applies dialBinding, passed
+// via context.
+func dialBound(ctx context.Context, network, addr string) (net.Conn, error) {
+	bind, _ := ctx.Value(dialBindingKey{}).(dialBinding)
+
+	d := bindDialer
+	if network == "unix" {
+		return d.DialContext(ctx, network, addr)
+	}
+
+	addr = normalizeHost(addr)
+
+	if bind.addr.IsValid() {
+		d.LocalAddr = net.TCPAddrFromAddrPort(
+			netip.AddrPortFrom(bind.addr, 0))
+	}
+
+	if bind.ifname == "" {
+		return d.DialContext(ctx, network, addr)
+	}
+
+	ifi, err := bindInterfaceByName(bind.ifname)
+	if err != nil {
+		return nil, err
+	}
+
+	return sysDialInterface(ctx, d, ifi, network, addr)
+}
+
+// bindDialInterfaceAddr dials, binding the connection to the
+// interface by choosing the local address of that interface.
+//
+// This is the portable fallback for the systems, where
+// interface binding is not supported.
+func bindDialInterfaceAddr(ctx context.Context, d net.Dialer,
+	ifi *net.Interface, network, addr string) (net.Conn, error) {
+
+	if d.LocalAddr == nil {
+		local, err := bindInterfaceAddr(ifi, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		d.LocalAddr = net.TCPAddrFromAddrPort(
+			netip.AddrPortFrom(local, 0))
+	}
+
+	return d.DialContext(ctx, network, addr)
+}
+
+// bindInterfaceAddr chooses local address of the interface,
+// suitable for connecting to the addr.
+func bindInterfaceAddr(ifi *net.Interface, addr string) (netip.Addr, error) {
+	ifaddrs, err := ifi.Addrs()
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("bind to interface %q: %w",
+			ifi.Name, err)
+	}
+
+	// If peer address is literal IP address, match address family
+	// and scope. Otherwise, prefer IPv4.
+	var peer netip.Addr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		peer, _ = netip.ParseAddr(host)
+		peer = NormalizeAddr(peer)
+	}
+
+	var best netip.Addr
+
+	for _, ifaddr := range ifaddrs {
+		ipn, ok := ifaddr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+
+		local, ok := netip.AddrFromSlice(ipn.IP)
+		if !ok {
+			continue
+		}
+		local = NormalizeAddr(local)
+
+		switch {
+		case !peer.IsValid():
+			if !best.IsValid() || local.Is4() && !best.Is4() {
+				best = local
+			}
+
+		case peer.Is4() != local.Is4():
+
+		case peer.IsLinkLocalUnicast() != local.IsLinkLocalUnicast():
+
+		case !best.IsValid():
+			best = local
+		}
+	}
+
+	if !best.IsValid() {
+		return best, fmt.Errorf("bind to interface %q: "+
+			"no suitable address to connect to %s", ifi.Name, addr)
+	}
+
+	if best.Is6() && best.IsLinkLocalUnicast() {
+		best = best.WithZone(ifi.Name)
+	}
+
+	return best, nil
+}
@@ -0,0 +1,50 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Binding of outgoing connections -- Linux version
+
+//go:build linux
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// sysDialInterface dials, binding the connection to the interface.
+//
+// It uses SO_BINDTODEVICE. If it is not permitted (kernels prior
+// to 5.7 require CAP_NET_RAW for that), it falls back to choosing
+// the local address of the interface.
+func sysDialInterface(ctx context.Context, d net.Dialer,
+	ifi *net.Interface, network, addr string) (net.Conn, error) {
+
+	bound := d
+	bound.Control = func(_, _ string, c syscall.RawConn) error {
+		var err error
+		err2 := c.Control(func(fd uintptr) {
+			err = syscall.SetsockoptString(int(fd),
+				syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE,
+				ifi.Name)
+		})
+
+		if err == nil {
+			err = err2
+		}
+
+		return err
+	}
+
+	conn, err := bound.DialContext(ctx, network, addr)
+	if err != nil && errors.Is(err, syscall.EPERM) {
+		return bindDialInterfaceAddr(ctx, d, ifi, network, addr)
+	}
+
+	return conn, err
+}
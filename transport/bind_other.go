@@ -0,0 +1,25 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Binding of outgoing connections -- the portable fallback
+
+//go:build !linux
+
+package transport
+
+import (
+	"context"
+	"net"
+)
+
+// sysDialInterface dials, binding the connection to the interface.
+//
+// It chooses the local address of the interface, as there is
+// no portable way to bind socket to the interface.
+func sysDialInterface(ctx context.Context, d net.Dialer,
+	ifi *net.Interface, network, addr string) (net.Conn, error) {
+	return bindDialInterfaceAddr(ctx, d, ifi, network, addr)
+}
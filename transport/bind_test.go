@@ -0,0 +1,166 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Binding of outgoing connections test
+
+package transport
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLoopbackInterface returns name of the loopback interface.
+func testLoopbackInterface(t *testing.T) string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("net.Interfaces: %s", err)
+	}
+
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagLoopback != 0 && ifi.Flags&net.FlagUp != 0 {
+			return ifi.Name
+		}
+	}
+
+	t.Skip("loopback interface not found")
+	return ""
+}
+
+// TestTransportBind tests Transport.SetBindInterface,
+// Transport.SetLocalAddr and WithBindInterface.
+func TestTransportBind(t *testing.T) {
+	lo := testLoopbackInterface(t)
+
+	// Server returns client address in the X-Remote-Addr header
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			w.Header().Set("X-Remote-Addr", rq.RemoteAddr)
+		}))
+	defer srv.Close()
+
+	get := func(tr *Transport, ctx context.Context) (*http.Response, error) {
+		rq, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+		rsp, err := tr.RoundTrip(rq)
+		if err == nil {
+			rsp.Body.Close()
+		}
+		return rsp, err
+	}
+
+	ctx := context.Background()
+
+	// Bind to the loopback interface
+	tr := NewTransport(nil)
+	err := tr.SetBindInterface(lo)
+	if err != nil {
+		t.Fatalf("SetBindInterface(%q): %s", lo, err)
+	}
+
+	_, err = get(tr, ctx)
+	if err != nil {
+		t.Errorf("SetBindInterface(%q): %s", lo, err)
+	}
+
+	// Bind to the local address
+	tr = NewTransport(nil)
+	tr.SetLocalAddr(netip.MustParseAddr("127.0.0.1"))
+
+	rsp, err := get(tr, ctx)
+	if err != nil {
+		t.Errorf("SetLocalAddr: %s", err)
+	} else {
+		remote := rsp.Header.Get("X-Remote-Addr")
+		if !strings.HasPrefix(remote, "127.0.0.1:") {
+			t.Errorf("SetLocalAddr: remote address %s", remote)
+		}
+	}
+
+	// Bogus interface must be rejected immediately
+	const bogus = "bogus-if0"
+
+	tr = NewTransport(nil)
+	err = tr.SetBindInterface(bogus)
+	if err == nil || !strings.Contains(err.Error(), bogus) {
+		t.Errorf("SetBindInterface(%q): error expected, got %v",
+			bogus, err)
+	}
+
+	// Bogus interface, passed via context, must fail fast
+	start := time.Now()
+	_, err = get(tr, WithBindInterface(ctx, bogus))
+	if err == nil || !strings.Contains(err.Error(), bogus) {
+		t.Errorf("WithBindInterface(%q): error expected, got %v",
+			bogus, err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WithBindInterface(%q): too slow failure: %s",
+			bogus, elapsed)
+	}
+
+	// Context overrides the Transport binding
+	tr = NewTransport(nil)
+	tr.SetBindInterface(lo)
+
+	_, err = get(tr, WithBindInterface(ctx, bogus))
+	if err == nil {
+		t.Errorf("WithBindInterface(%q): override ignored", bogus)
+	}
+
+	_, err = get(tr, WithBindInterface(ctx, ""))
+	if err != nil {
+		t.Errorf("WithBindInterface(%q): %s", "", err)
+	}
+}
+
+// TestBindInterfaceAddr tests the portable fallback of the
+// interface binding.
+func TestBindInterfaceAddr(t *testing.T) {
+	ifi, err := net.InterfaceByName(testLoopbackInterface(t))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	addr, err := bindInterfaceAddr(ifi, "127.0.0.1:631")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !addr.Is4() || !addr.IsLoopback() {
+		t.Errorf("bindInterfaceAddr: %s", addr)
+	}
+
+	// IPv4-mapped peer must choose the IPv4 local address
+	addr, err = bindInterfaceAddr(ifi, "[::ffff:127.0.0.1]:631")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !addr.Is4() || !addr.IsLoopback() {
+		t.Errorf("bindInterfaceAddr (mapped peer): %s", addr)
+	}
+
+	d := bindDialer
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer l.Close()
+
+	conn, err := bindDialInterfaceAddr(context.Background(), d, ifi,
+		"tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("bindDialInterfaceAddr: %s", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,121 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// HTTP message body length tracking
+
+package transport
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// BodyMeta describes the length of the HTTP message body.
+//
+// It is either known length, sent with the Content-Length header,
+// or unknown length, sent using the chunked Transfer-Encoding (or,
+// for HTTP/1.0 responses, until connection close).
+//
+// The zero BodyMeta is the known length of 0 bytes.
+type BodyMeta struct {
+	length  int64 // Body length, if known
+	chunked bool  // Body length is not known
+}
+
+// BodyMetaLength returns the BodyMeta of the known length.
+// Negative length means unknown length.
+func BodyMetaLength(length int64) BodyMeta {
+	if length < 0 {
+		return BodyMetaChunked()
+	}
+	return BodyMeta{length: length}
+}
+
+// BodyMetaChunked returns the BodyMeta of the unknown length.
+func BodyMetaChunked() BodyMeta {
+	return BodyMeta{chunked: true}
+}
+
+// BodyMetaFromRequest returns BodyMeta of the [http.Request] body.
+//
+// Requests with the Transfer-Encoding are considered chunked,
+// regardless of the ContentLength.
+func BodyMetaFromRequest(rq *http.Request) BodyMeta {
+	if len(rq.TransferEncoding) != 0 {
+		return BodyMetaChunked()
+	}
+	return BodyMetaLength(rq.ContentLength)
+}
+
+// BodyMetaFromResponse returns BodyMeta of the [http.Response] body.
+//
+// Responses with the Transfer-Encoding are considered chunked,
+// regardless of the ContentLength.
+func BodyMetaFromResponse(rsp *http.Response) BodyMeta {
+	if len(rsp.TransferEncoding) != 0 {
+		return BodyMetaChunked()
+	}
+	return BodyMetaLength(rsp.ContentLength)
+}
+
+// Chunked reports whether body length is unknown.
+func (meta BodyMeta) Chunked() bool {
+	return meta.chunked
+}
+
+// Length returns body length and true, if length is known,
+// or (0, false) otherwise.
+func (meta BodyMeta) Length() (int64, bool) {
+	return meta.length, !meta.chunked
+}
+
+// ContentLength returns body length in the form, suitable for
+// the [http.Request.ContentLength] and [http.Response.ContentLength]:
+// the length, if known, or -1 otherwise.
+func (meta BodyMeta) ContentLength() int64 {
+	if meta.chunked {
+		return -1
+	}
+	return meta.length
+}
+
+// Replace returns BodyMeta of the body, where the leading `consumed`
+// bytes are replaced with the `added` bytes (i.e., when proxy rewrites
+// the protocol message, followed by the unmodified data).
+//
+// Unknown length remains unknown. If `consumed` exceeds the known
+// length, the original length was a lie, and result is the unknown
+// length as well.
+func (meta BodyMeta) Replace(consumed, added int64) BodyMeta {
+	if meta.chunked || consumed > meta.length {
+		return BodyMetaChunked()
+	}
+
+	return BodyMetaLength(meta.length - consumed + added)
+}
+
+// SetHeader sets the Content-Length header, if length is known,
+// and removes it otherwise, so the [http.Server] will use the chunked
+// Transfer-Encoding for the response.
+//
+// The Transfer-Encoding header itself is never set, as it is
+// managed by the net/http library.
+func (meta BodyMeta) SetHeader(hdr http.Header) {
+	if meta.chunked {
+		hdr.Del("Content-Length")
+		return
+	}
+
+	hdr.Set("Content-Length", strconv.FormatInt(meta.length, 10))
+}
+
+// String returns string representation of the BodyMeta, for logging.
+func (meta BodyMeta) String() string {
+	if meta.chunked {
+		return "chunked"
+	}
+	return strconv.FormatInt(meta.length, 10) + " bytes"
+}
@@ -0,0 +1,95 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// HTTP message body length tracking test
+
+package transport
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestBodyMeta tests BodyMeta operations
+func TestBodyMeta(t *testing.T) {
+	type testData struct {
+		meta     BodyMeta // Input BodyMeta
+		consumed int64    // Replace parameters
+		added    int64    // Replace parameters
+		expected string   // Expected String() after Replace
+		header   string   // Expected Content-Length header
+	}
+
+	tests := []testData{
+		{
+			meta:     BodyMetaLength(100),
+			consumed: 20,
+			added:    30,
+			expected: "110 bytes",
+			header:   "110",
+		},
+		{
+			// Unknown stays unknown
+			meta:     BodyMetaChunked(),
+			consumed: 20,
+			added:    30,
+			expected: "chunked",
+		},
+		{
+			// Negative length means unknown
+			meta:     BodyMetaLength(-1),
+			expected: "chunked",
+		},
+		{
+			// Length was a lie
+			meta:     BodyMetaLength(10),
+			consumed: 20,
+			added:    30,
+			expected: "chunked",
+		},
+		{
+			meta:     BodyMeta{},
+			expected: "0 bytes",
+			header:   "0",
+		},
+	}
+
+	for _, test := range tests {
+		meta := test.meta.Replace(test.consumed, test.added)
+		if s := meta.String(); s != test.expected {
+			t.Errorf("%s.Replace(%d,%d):\nexpected: %s\npresent:  %s",
+				test.meta, test.consumed, test.added,
+				test.expected, s)
+		}
+
+		hdr := http.Header{"Content-Length": {"12345"}}
+		meta.SetHeader(hdr)
+		if s := hdr.Get("Content-Length"); s != test.header {
+			t.Errorf("%s.SetHeader:\nexpected: %q\npresent:  %q",
+				meta, test.header, s)
+		}
+
+		l, known := meta.Length()
+		if known == meta.Chunked() ||
+			(known && l != meta.ContentLength()) ||
+			(!known && meta.ContentLength() != -1) {
+			t.Errorf("%s: inconsistent: Length()=(%d,%v) "+
+				"ContentLength()=%d", meta, l, known,
+				meta.ContentLength())
+		}
+	}
+
+	// Transfer-Encoding overrides ContentLength
+	rq := &http.Request{ContentLength: 5, TransferEncoding: []string{"chunked"}}
+	if !BodyMetaFromRequest(rq).Chunked() {
+		t.Errorf("BodyMetaFromRequest: Transfer-Encoding ignored")
+	}
+
+	rsp := &http.Response{ContentLength: 5, TransferEncoding: []string{"chunked"}}
+	if !BodyMetaFromResponse(rsp).Chunked() {
+		t.Errorf("BodyMetaFromResponse: Transfer-Encoding ignored")
+	}
+}
@@ -0,0 +1,158 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Broadcast of the stream to multiple consumers
+
+package transport
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Broadcast is the [io.Reader] that reads from the source and copies
+// everything it reads to the multiple sinks.
+//
+// Its semantics is well-defined:
+//   - sinks receive bytes in the read order, exactly as the primary
+//     reader receives them
+//   - if sink fails, it is dropped and its error is recorded
+//     (see [Broadcast.SinkErr]). The primary stream and other sinks
+//     are not affected.
+//   - sinks are never closed implicitly. Use [Broadcast.Close]
+//     or [BroadcastReadCloser] for that.
+type Broadcast struct {
+	src    io.Reader   // Source stream
+	sinks  []io.Writer // Sinks
+	errs   []error     // Sink errors; failed sinks are dropped
+	srcErr error       // Non-EOF source error
+	closed bool        // Sinks are closed
+	lock   sync.Mutex  // Access lock
+}
+
+// BroadcastReader creates a new [Broadcast] that reads from src
+// and copies data to sinks.
+func BroadcastReader(src io.Reader, sinks ...io.Writer) *Broadcast {
+	return &Broadcast{
+		src:   src,
+		sinks: append([]io.Writer(nil), sinks...),
+		errs:  make([]error, len(sinks)),
+	}
+}
+
+// Read reads from the source and copies the data to the sinks.
+func (b *Broadcast) Read(buf []byte) (int, error) {
+	n, err := b.src.Read(buf)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if n > 0 {
+		for i, sink := range b.sinks {
+			if b.errs[i] != nil {
+				continue
+			}
+
+			nw, werr := sink.Write(buf[:n])
+			if werr == nil && nw != n {
+				werr = io.ErrShortWrite
+			}
+
+			b.errs[i] = werr
+		}
+	}
+
+	if err != nil && err != io.EOF && b.srcErr == nil {
+		b.srcErr = err
+	}
+
+	return n, err
+}
+
+// SinkErr returns the error of the i-th sink, in order of the
+// [BroadcastReader] parameters, or nil, if sink didn't fail.
+func (b *Broadcast) SinkErr(i int) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.errs[i]
+}
+
+// Err returns errors of all failed sinks, joined by [errors.Join],
+// or nil, if no sinks have failed.
+func (b *Broadcast) Err() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return errors.Join(b.errs...)
+}
+
+// Close closes all sinks that implement [io.Closer], including
+// dropped ones. It doesn't close the source.
+//
+// If source has failed, the [io.PipeWriter] sinks are closed
+// with that error, so their readers see the failure instead
+// of the normal EOF.
+//
+// Subsequent calls to Close have no effect.
+func (b *Broadcast) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		return nil
+	}
+
+	b.closed = true
+	for i, sink := range b.sinks {
+		var err error
+		switch sink := sink.(type) {
+		case *io.PipeWriter:
+			err = sink.CloseWithError(b.srcErr)
+		case io.Closer:
+			err = sink.Close()
+		}
+
+		if err != nil && b.errs[i] == nil {
+			b.errs[i] = err
+		}
+	}
+
+	return nil
+}
+
+// broadcastReadCloser is the io.ReadCloser, returned by
+// the BroadcastReadCloser
+type broadcastReadCloser struct {
+	*Broadcast
+	src  io.Closer // Source closer
+	once sync.Once // To close only once
+	err  error     // Source close error
+}
+
+// BroadcastReadCloser is like [BroadcastReader], but for the
+// [io.ReadCloser]s.
+//
+// Closing the returned [io.ReadCloser] closes the source first,
+// then the sinks, as [Broadcast.Close] does. The source Close
+// error is returned.
+func BroadcastReadCloser(src io.ReadCloser,
+	sinks ...io.Writer) io.ReadCloser {
+
+	return &broadcastReadCloser{
+		Broadcast: BroadcastReader(src, sinks...),
+		src:       src,
+	}
+}
+
+// Close closes the source and the sinks.
+func (brc *broadcastReadCloser) Close() error {
+	brc.once.Do(func() {
+		brc.err = brc.src.Close()
+		brc.Broadcast.Close()
+	})
+
+	return brc.err
+}
@@ -0,0 +1,163 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Broadcast tests
+
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// testBroadcastRandReader returns data in randomly sized chunks
+type testBroadcastRandReader struct {
+	data []byte
+	rnd  *rand.Rand
+}
+
+// Read implements io.Reader interface for testBroadcastRandReader
+func (r *testBroadcastRandReader) Read(buf []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+
+	n := 1 + r.rnd.Intn(len(buf))
+	n = copy(buf[:n], r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// testBroadcastFailingWriter fails after accepting limit bytes
+type testBroadcastFailingWriter struct {
+	bytes.Buffer
+	limit  int
+	closed int
+}
+
+// Write implements io.Writer interface for testBroadcastFailingWriter
+func (w *testBroadcastFailingWriter) Write(data []byte) (int, error) {
+	if w.Len()+len(data) > w.limit {
+		return 0, errors.New("sink failed")
+	}
+	return w.Buffer.Write(data)
+}
+
+// Close implements io.Closer interface for testBroadcastFailingWriter
+func (w *testBroadcastFailingWriter) Close() error {
+	w.closed++
+	return nil
+}
+
+// TestBroadcast tests byte fidelity and ordering with randomized
+// read sizes, and isolation of the failing sink.
+func TestBroadcast(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		data := make([]byte, 1+rnd.Intn(64*1024))
+		rnd.Read(data)
+
+		src := &testBroadcastRandReader{data: data, rnd: rnd}
+		sink1 := &bytes.Buffer{}
+		failing := &testBroadcastFailingWriter{limit: len(data) / 2}
+		sink2 := &bytes.Buffer{}
+
+		b := BroadcastReader(src, sink1, failing, sink2)
+
+		// Read in randomly sized chunks
+		var primary bytes.Buffer
+		buf := make([]byte, 4096)
+		for {
+			n, err := b.Read(buf[:1+rnd.Intn(len(buf))])
+			primary.Write(buf[:n])
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("Read: %s", err)
+			}
+		}
+
+		// Check byte fidelity
+		for name, got := range map[string][]byte{
+			"primary": primary.Bytes(),
+			"sink1":   sink1.Bytes(),
+			"sink2":   sink2.Bytes(),
+		} {
+			if !bytes.Equal(got, data) {
+				t.Fatalf("%s: data mismatch (%d bytes of %d)",
+					name, len(got), len(data))
+			}
+		}
+
+		// Failing sink must receive consistent prefix and
+		// record its error
+		if !bytes.HasPrefix(data, failing.Bytes()) {
+			t.Fatalf("failing sink: data is not the stream prefix")
+		}
+
+		if b.SinkErr(1) == nil || b.Err() == nil {
+			t.Fatalf("failing sink: error not recorded")
+		}
+
+		if b.SinkErr(0) != nil || b.SinkErr(2) != nil {
+			t.Fatalf("healthy sinks: unexpected error")
+		}
+
+		// Sinks are closed explicitly, and only once
+		if failing.closed != 0 {
+			t.Fatalf("sink closed implicitly")
+		}
+
+		b.Close()
+		b.Close()
+
+		if failing.closed != 1 {
+			t.Fatalf("sink closed %d times", failing.closed)
+		}
+	}
+}
+
+// TestBroadcastReadCloser tests BroadcastReadCloser and
+// propagation of the source error into pipes
+func TestBroadcastReadCloser(t *testing.T) {
+	srcErr := errors.New("source failed")
+	src := io.NopCloser(io.MultiReader(
+		bytes.NewReader([]byte("hello")),
+		&testBroadcastErrReader{srcErr}))
+
+	rpipe, wpipe := io.Pipe()
+	done := make(chan error)
+	go func() {
+		_, err := io.ReadAll(rpipe)
+		done <- err
+	}()
+
+	rc := BroadcastReadCloser(src, wpipe)
+	_, err := io.ReadAll(rc)
+	if err != srcErr {
+		t.Errorf("ReadAll: expected %v, present %v", srcErr, err)
+	}
+
+	rc.Close()
+
+	if err = <-done; err != srcErr {
+		t.Errorf("pipe: expected %v, present %v", srcErr, err)
+	}
+}
+
+// testBroadcastErrReader is the io.Reader that always fails
+type testBroadcastErrReader struct {
+	err error
+}
+
+// Read implements io.Reader interface for testBroadcastErrReader
+func (r *testBroadcastErrReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,574 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// HTTP client-side cache
+
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the default size limit of the [Cache], in bytes.
+const DefaultCacheSize = 32 * 1024 * 1024
+
+// cacheFileSuffix is the suffix of the cache entry files
+const cacheFileSuffix = ".cache"
+
+// Cache is the on-disk cache of HTTP responses, used by the [Client].
+//
+// It is intended for the immutable or rarely changed resources,
+// like printer icons, localization strings and PPD files, which
+// are fetched repeatedly by UIs.
+//
+// Only successful responses to the GET requests are cached, and
+// only if request [context.Context] explicitly allows caching
+// (see [WithCaching]). Cache honors the Cache-Control, Expires,
+// ETag and Last-Modified response headers:
+//   - fresh responses are returned from the cache without
+//     contacting the server
+//   - stale responses are revalidated with the conditional
+//     request, if server has provided validators (ETag or
+//     Last-Modified); otherwise, they are refetched
+//   - responses with neither validators nor explicit expiration
+//     time are not cached.
+//
+// Cache is size-capped; when size limit is exceeded, the least
+// recently used entries are evicted.
+//
+// Cache is safe for concurrent use, but the same cache directory
+// must not be shared between multiple Cache instances.
+type Cache struct {
+	dir     string                   // Cache directory
+	maxSize int64                    // Size limit
+	size    int64                    // Current size
+	lru     *list.List               // Of *cacheEntry, MRU first
+	entries map[string]*list.Element // Entries by key
+	lock    sync.Mutex               // Access lock
+}
+
+// cacheEntry is the in-memory index entry of the cached response.
+type cacheEntry struct {
+	key  string // Cache key (hex-encoded URL hash)
+	size int64  // Size of the entry file
+}
+
+// cacheMeta contains the cached response metadata. It is saved
+// as the first line of the cache entry file, JSON-encoded, and
+// followed by the response body.
+type cacheMeta struct {
+	URL    string      // Request URL
+	Stored time.Time   // When response was received
+	Header http.Header // Response header
+}
+
+// NewCache creates a new [Cache] in the specified directory.
+//
+// Directory is created, if missed. Entries already present in the
+// directory are reused.
+//
+// If maxSize is 0, [DefaultCacheSize] is used.
+func NewCache(dir string, maxSize int64) (*Cache, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultCacheSize
+	}
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+
+	err = cache.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return cache, nil
+}
+
+// DefaultCacheDir returns the default directory for the [Cache],
+// located under the user cache directory (see [os.UserCacheDir]).
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "go-mfp", "http"), nil
+}
+
+// load loads index of entries, already present in the cache
+// directory. Entries are ordered by their modification time,
+// which is updated on each access.
+func (cache *Cache) load() error {
+	files, err := os.ReadDir(cache.dir)
+	if err != nil {
+		return err
+	}
+
+	type loaded struct {
+		key   string
+		size  int64
+		mtime time.Time
+	}
+
+	var entries []loaded
+	for _, file := range files {
+		name := file.Name()
+		info, err := file.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		key, ok := strings.CutSuffix(name, cacheFileSuffix)
+		if !ok {
+			// Leftover of interrupted write; just drop it.
+			os.Remove(filepath.Join(cache.dir, name))
+			continue
+		}
+
+		entries = append(entries,
+			loaded{key, info.Size(), info.ModTime()})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].mtime.After(entries[j].mtime)
+	})
+
+	for _, ent := range entries {
+		cache.insert(ent.key, ent.size)
+	}
+
+	cache.evict()
+
+	return nil
+}
+
+// Size returns the current cache size, in bytes.
+func (cache *Cache) Size() int64 {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	return cache.size
+}
+
+// Purge removes all entries from the cache.
+func (cache *Cache) Purge() {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	for cache.lru.Len() > 0 {
+		cache.remove(cache.lru.Back())
+	}
+}
+
+// do performs the HTTP request using the cache.
+//
+// The send callback performs the actual HTTP request.
+func (cache *Cache) do(rq *http.Request,
+	send func(*http.Request) (*http.Response, error)) (
+	*http.Response, error) {
+
+	key := cacheKey(rq)
+	meta, body := cache.get(key)
+
+	// Handle cache miss
+	if meta == nil {
+		rsp, err := send(rq)
+		if err == nil {
+			rsp = cache.put(key, rq, rsp)
+		}
+		return rsp, err
+	}
+
+	// Return fresh response from the cache.
+	now := time.Now()
+	if cacheFresh(meta, now) {
+		return cacheResponse(rq, meta, body), nil
+	}
+
+	// Revalidate stale response, if possible
+	etag := meta.Header.Get("ETag")
+	lastModified := meta.Header.Get("Last-Modified")
+
+	if etag == "" && lastModified == "" {
+		rsp, err := send(rq)
+		if err == nil {
+			rsp = cache.put(key, rq, rsp)
+		}
+		return rsp, err
+	}
+
+	rq2 := rq.Clone(rq.Context())
+	if etag != "" {
+		rq2.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		rq2.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	rsp, err := send(rq2)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusNotModified {
+		return cache.put(key, rq, rsp), nil
+	}
+
+	// Not modified. Update metadata and return cached response.
+	io.Copy(io.Discard, rsp.Body)
+	rsp.Body.Close()
+
+	for _, name := range []string{"Cache-Control", "Date", "ETag",
+		"Expires", "Last-Modified"} {
+		if v := rsp.Header.Values(name); len(v) != 0 {
+			meta.Header[name] = v
+		}
+	}
+
+	meta.Stored = now
+	cache.store(key, meta, body)
+
+	return cacheResponse(rq, meta, body), nil
+}
+
+// get returns the cached response by the key.
+// It returns (nil, nil), if response is not cached.
+func (cache *Cache) get(key string) (*cacheMeta, []byte) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	elem := cache.entries[key]
+	if elem == nil {
+		return nil, nil
+	}
+
+	meta, body, err := cache.read(key)
+	if err != nil {
+		cache.remove(elem)
+		return nil, nil
+	}
+
+	// Mark entry as recently used
+	cache.lru.MoveToFront(elem)
+	now := time.Now()
+	os.Chtimes(cache.path(key), now, now)
+
+	return meta, body
+}
+
+// put saves the received response into the cache, if response
+// is cacheable, and returns the response to be returned to the
+// caller.
+//
+// As response body is consumed while saving, the original response
+// is returned with its body replaced.
+func (cache *Cache) put(key string, rq *http.Request,
+	rsp *http.Response) *http.Response {
+
+	if !cacheStorable(rsp) {
+		cache.drop(key)
+		return rsp
+	}
+
+	// Read response body. If it doesn't fit the cache,
+	// pass it to the caller as is.
+	body, err := io.ReadAll(io.LimitReader(rsp.Body, cache.maxSize+1))
+	if err != nil || int64(len(body)) > cache.maxSize {
+		rsp.Body = cacheBody{io.MultiReader(bytes.NewReader(body),
+			rsp.Body), rsp.Body}
+		return rsp
+	}
+
+	rsp.Body.Close()
+	rsp.Body = io.NopCloser(bytes.NewReader(body))
+
+	meta := &cacheMeta{
+		URL:    rq.URL.String(),
+		Stored: time.Now(),
+		Header: rsp.Header.Clone(),
+	}
+
+	HTTPRemoveHopByHopHeaders(meta.Header)
+	cache.store(key, meta, body)
+
+	return rsp
+}
+
+// store writes the cache entry and updates the index.
+func (cache *Cache) store(key string, meta *cacheMeta, body []byte) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	size, err := cache.write(key, meta, body)
+	if err != nil {
+		if elem := cache.entries[key]; elem != nil {
+			cache.remove(elem)
+		}
+		return
+	}
+
+	if elem := cache.entries[key]; elem != nil {
+		ent := elem.Value.(*cacheEntry)
+		cache.size += size - ent.size
+		ent.size = size
+		cache.lru.MoveToFront(elem)
+	} else {
+		cache.insert(key, size)
+		cache.lru.MoveToFront(cache.entries[key])
+	}
+
+	cache.evict()
+}
+
+// drop removes the entry by key, if it exists.
+func (cache *Cache) drop(key string) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if elem := cache.entries[key]; elem != nil {
+		cache.remove(elem)
+	}
+}
+
+// insert adds the entry to the back of the LRU list.
+// Must be called under the lock.
+func (cache *Cache) insert(key string, size int64) {
+	elem := cache.lru.PushBack(&cacheEntry{key: key, size: size})
+	cache.entries[key] = elem
+	cache.size += size
+}
+
+// remove removes the entry from the cache.
+// Must be called under the lock.
+func (cache *Cache) remove(elem *list.Element) {
+	ent := elem.Value.(*cacheEntry)
+	cache.lru.Remove(elem)
+	delete(cache.entries, ent.key)
+	cache.size -= ent.size
+	os.Remove(cache.path(ent.key))
+}
+
+// evict evicts least recently used entries, until cache size
+// fits the limit. Must be called under the lock.
+func (cache *Cache) evict() {
+	for cache.size > cache.maxSize && cache.lru.Len() > 0 {
+		cache.remove(cache.lru.Back())
+	}
+}
+
+// path returns path to the cache entry file.
+func (cache *Cache) path(key string) string {
+	return filepath.Join(cache.dir, key+cacheFileSuffix)
+}
+
+// read reads the cache entry file.
+func (cache *Cache) read(key string) (*cacheMeta, []byte, error) {
+	data, err := os.ReadFile(cache.path(key))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	line, body, ok := bytes.Cut(data, []byte("\n"))
+	if !ok {
+		return nil, nil, errors.New("cache: invalid entry")
+	}
+
+	meta := &cacheMeta{}
+	err = json.Unmarshal(line, meta)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if meta.Header == nil {
+		meta.Header = make(http.Header)
+	}
+
+	return meta, body, nil
+}
+
+// write writes the cache entry file and returns its size.
+// File is written atomically, via temporary file.
+func (cache *Cache) write(key string, meta *cacheMeta,
+	body []byte) (int64, error) {
+
+	line, err := json.Marshal(meta)
+	if err != nil {
+		return 0, err
+	}
+
+	fp, err := os.CreateTemp(cache.dir, key+"-*.tmp")
+	if err != nil {
+		return 0, err
+	}
+
+	w := bufio.NewWriter(fp)
+	w.Write(line)
+	w.WriteByte('\n')
+	w.Write(body)
+	err = w.Flush()
+
+	if err2 := fp.Close(); err == nil {
+		err = err2
+	}
+
+	if err == nil {
+		err = os.Rename(fp.Name(), cache.path(key))
+	}
+
+	if err != nil {
+		os.Remove(fp.Name())
+		return 0, err
+	}
+
+	return int64(len(line) + 1 + len(body)), nil
+}
+
+// cacheKey returns the cache key for the request.
+func cacheKey(rq *http.Request) string {
+	sum := sha256.Sum256([]byte(rq.URL.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheStorable reports if response may be stored in the cache.
+func cacheStorable(rsp *http.Response) bool {
+	if rsp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	cc := cacheControl(rsp.Header)
+	if _, found := cc["no-store"]; found {
+		return false
+	}
+
+	if rsp.Header.Get("ETag") != "" ||
+		rsp.Header.Get("Last-Modified") != "" {
+		return true
+	}
+
+	// Without validators, response is only useful,
+	// if it has explicit expiration time.
+	now := time.Now()
+	meta := &cacheMeta{Stored: now, Header: rsp.Header}
+	return cacheFresh(meta, now)
+}
+
+// cacheFresh reports if cached response is still fresh.
+//
+// Freshness lifetime is taken from the Cache-Control max-age
+// directive or from the Expires header. Heuristic freshness
+// is not used, so response without explicit expiration time
+// is always considered stale.
+func cacheFresh(meta *cacheMeta, now time.Time) bool {
+	cc := cacheControl(meta.Header)
+	if _, found := cc["no-cache"]; found {
+		return false
+	}
+
+	if s, found := cc["max-age"]; found {
+		age, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return false
+		}
+
+		return now.Sub(meta.Stored) < time.Duration(age)*time.Second
+	}
+
+	if s := meta.Header.Get("Expires"); s != "" {
+		expires, err := http.ParseTime(s)
+		return err == nil && now.Before(expires)
+	}
+
+	return false
+}
+
+// cacheControl parses the Cache-Control header into the map
+// of directives.
+func cacheControl(hdr http.Header) map[string]string {
+	directives := make(map[string]string)
+
+	for _, v := range hdr.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(d), "=")
+			name = strings.ToLower(name)
+			if name != "" {
+				directives[name] = strings.Trim(value, `"`)
+			}
+		}
+	}
+
+	return directives
+}
+
+// cacheResponse makes http.Response out of the cached data.
+func cacheResponse(rq *http.Request, meta *cacheMeta,
+	body []byte) *http.Response {
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        meta.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       rq,
+	}
+}
+
+// cacheBody is the response body, partially read while
+// attempting to save it into the cache.
+type cacheBody struct {
+	io.Reader
+	io.Closer
+}
+
+// Keys for context.WithValue and context.Value, used by cache.
+var contextKeyCaching = contextKey{"transport-caching"}
+
+// contextKey wraps Context key, so it cannot be constructed
+// outside of this package.
+type contextKey struct{ name string }
+
+// WithCaching returns a new [context.Context], that allows
+// HTTP requests, performed with this context, to use the
+// [Client] cache.
+//
+// Only requests that fetch immutable or rarely changed resources
+// should be performed with this context.
+func WithCaching(parent context.Context) context.Context {
+	return context.WithValue(parent, contextKeyCaching, true)
+}
+
+// cachingEnabled reports if caching is allowed by the Context.
+func cachingEnabled(ctx context.Context) bool {
+	v, _ := ctx.Value(contextKeyCaching).(bool)
+	return v
+}
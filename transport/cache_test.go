@@ -0,0 +1,304 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// HTTP client-side cache test
+
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testCacheServer is the HTTP server for cache tests.
+//
+// It serves resources with the configurable content and headers
+// and counts requests it has received.
+type testCacheServer struct {
+	*httptest.Server
+	lock      sync.Mutex
+	resources map[string]*testCacheResource
+}
+
+// testCacheResource is the resource, served by testCacheServer
+type testCacheResource struct {
+	body         string // Resource content
+	etag         string // ETag, "" if none
+	cacheControl string // Cache-Control, "" if none
+	full         int    // Count of full (200) responses
+	notModified  int    // Count of 304 responses
+}
+
+// newTestCacheServer creates a new testCacheServer
+func newTestCacheServer() *testCacheServer {
+	srv := &testCacheServer{
+		resources: make(map[string]*testCacheResource),
+	}
+	srv.Server = httptest.NewServer(srv)
+	return srv
+}
+
+// ServeHTTP serves HTTP requests
+func (srv *testCacheServer) ServeHTTP(w http.ResponseWriter,
+	rq *http.Request) {
+
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	res := srv.resources[rq.URL.Path]
+	if res == nil {
+		http.NotFound(w, rq)
+		return
+	}
+
+	if res.etag != "" {
+		w.Header().Set("ETag", res.etag)
+	}
+
+	if res.cacheControl != "" {
+		w.Header().Set("Cache-Control", res.cacheControl)
+	}
+
+	if res.etag != "" && rq.Header.Get("If-None-Match") == res.etag {
+		res.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	res.full++
+	io.WriteString(w, res.body)
+}
+
+// set sets the resource
+func (srv *testCacheServer) set(path string, res *testCacheResource) {
+	srv.lock.Lock()
+	srv.resources[path] = res
+	srv.lock.Unlock()
+}
+
+// counts returns the resource's request counters
+func (srv *testCacheServer) counts(path string) (full, notModified int) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+
+	res := srv.resources[path]
+	return res.full, res.notModified
+}
+
+// testCacheGet performs the GET request and returns response body
+func testCacheGet(t *testing.T, ctx context.Context,
+	clnt *Client, u string) string {
+
+	rq, err := NewRequest(ctx, "GET", MustParseURL(u), nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %s", err)
+	}
+
+	rsp, err := clnt.Do(rq)
+	if err != nil {
+		t.Fatalf("GET %s: %s", u, err)
+	}
+
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s", u, rsp.Status)
+	}
+
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: %s", u, err)
+	}
+
+	return string(body)
+}
+
+// TestCache tests cache hit/miss/revalidate flows
+func TestCache(t *testing.T) {
+	srv := newTestCacheServer()
+	defer srv.Close()
+
+	cache, err := NewCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewCache: %s", err)
+	}
+
+	clnt := NewClient(nil)
+	clnt.Cache = cache
+
+	ctx := WithCaching(context.Background())
+
+	srv.set("/icon.png", &testCacheResource{
+		body: "icon", etag: `"1"`, cacheControl: "max-age=3600"})
+	srv.set("/strings", &testCacheResource{
+		body: "strings", etag: `"1"`, cacheControl: "no-cache"})
+	srv.set("/volatile", &testCacheResource{
+		body: "volatile"})
+	srv.set("/nostore", &testCacheResource{
+		body: "nostore", etag: `"1"`, cacheControl: "no-store"})
+
+	tests := []struct {
+		path        string // Resource path
+		ctx         context.Context
+		body        string // Expected body
+		full        int    // Expected count of full fetches
+		notModified int    // Expected count of revalidations
+	}{
+		// Fresh resource: served from cache after first fetch
+		{"/icon.png", ctx, "icon", 1, 0},
+		{"/icon.png", ctx, "icon", 1, 0},
+
+		// Caching not allowed by context
+		{"/icon.png", context.Background(), "icon", 2, 0},
+
+		// Stale resource with validator: revalidated
+		{"/strings", ctx, "strings", 1, 0},
+		{"/strings", ctx, "strings", 1, 1},
+		{"/strings", ctx, "strings", 1, 2},
+
+		// No validators, no expiration: not cached
+		{"/volatile", ctx, "volatile", 1, 0},
+		{"/volatile", ctx, "volatile", 2, 0},
+
+		// Cache-Control: no-store
+		{"/nostore", ctx, "nostore", 1, 0},
+		{"/nostore", ctx, "nostore", 2, 0},
+	}
+
+	for i, test := range tests {
+		body := testCacheGet(t, test.ctx, clnt, srv.URL+test.path)
+		if body != test.body {
+			t.Errorf("%d: %s: body mismatch:\n"+
+				"expected: %q\npresent:  %q",
+				i, test.path, test.body, body)
+		}
+
+		full, notModified := srv.counts(test.path)
+		if full != test.full || notModified != test.notModified {
+			t.Errorf("%d: %s: requests (full/304):\n"+
+				"expected: %d/%d\npresent:  %d/%d",
+				i, test.path,
+				test.full, test.notModified,
+				full, notModified)
+		}
+	}
+
+	// Modified resource must be refetched
+	srv.set("/strings", &testCacheResource{
+		body: "strings2", etag: `"2"`, cacheControl: "no-cache"})
+
+	body := testCacheGet(t, ctx, clnt, srv.URL+"/strings")
+	if body != "strings2" {
+		t.Errorf("modified: expected %q, present %q", "strings2", body)
+	}
+
+	body = testCacheGet(t, ctx, clnt, srv.URL+"/strings")
+	full, notModified := srv.counts("/strings")
+	if body != "strings2" || full != 1 || notModified != 1 {
+		t.Errorf("modified: unexpected state: %q, %d/%d",
+			body, full, notModified)
+	}
+
+	// Cache must survive re-opening
+	cache2, err := NewCache(cache.dir, 0)
+	if err != nil {
+		t.Fatalf("NewCache: %s", err)
+	}
+
+	if cache2.Size() != cache.Size() {
+		t.Errorf("reopen: size mismatch: expected %d, present %d",
+			cache.Size(), cache2.Size())
+	}
+
+	clnt.Cache = cache2
+	testCacheGet(t, ctx, clnt, srv.URL+"/icon.png")
+	full, _ = srv.counts("/icon.png")
+	if full != 2 {
+		t.Errorf("reopen: cached resource refetched")
+	}
+}
+
+// TestCacheEviction tests LRU eviction at the size cap
+func TestCacheEviction(t *testing.T) {
+	srv := newTestCacheServer()
+	defer srv.Close()
+
+	ctx := WithCaching(context.Background())
+	body := strings.Repeat("x", 1000)
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		srv.set(path, &testCacheResource{
+			body: body, etag: `"1"`, cacheControl: "max-age=3600"})
+	}
+
+	// Measure the entry size
+	cache, err := NewCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewCache: %s", err)
+	}
+
+	clnt := NewClient(nil)
+	clnt.Cache = cache
+	testCacheGet(t, ctx, clnt, srv.URL+"/a")
+	size := cache.Size()
+	cache.Purge()
+
+	if cache.Size() != 0 {
+		t.Errorf("Purge: size %d after purge", cache.Size())
+	}
+
+	// Now create cache that fits only two entries
+	cache, err = NewCache(t.TempDir(), 2*size+size/2)
+	if err != nil {
+		t.Fatalf("NewCache: %s", err)
+	}
+
+	clnt.Cache = cache
+
+	testCacheGet(t, ctx, clnt, srv.URL+"/a") // miss
+	testCacheGet(t, ctx, clnt, srv.URL+"/b") // miss
+	testCacheGet(t, ctx, clnt, srv.URL+"/a") // hit, a is MRU now
+	testCacheGet(t, ctx, clnt, srv.URL+"/c") // miss, b is evicted
+	testCacheGet(t, ctx, clnt, srv.URL+"/a") // hit
+	testCacheGet(t, ctx, clnt, srv.URL+"/b") // miss, c is evicted
+
+	expected := map[string]int{"/a": 2, "/b": 2, "/c": 1}
+	for path, exp := range expected {
+		full, _ := srv.counts(path)
+		if full != exp {
+			t.Errorf("%s: expected %d fetches, present %d",
+				path, exp, full)
+		}
+	}
+
+	if cache.Size() > 2*size+size/2 {
+		t.Errorf("cache size %d exceeds limit %d",
+			cache.Size(), 2*size+size/2)
+	}
+
+	// Response that doesn't fit the cache must pass through
+	srv.set("/big", &testCacheResource{
+		body: strings.Repeat("y", int(3*size)), etag: `"1"`})
+
+	for i := 0; i < 2; i++ {
+		s := testCacheGet(t, ctx, clnt, srv.URL+"/big")
+		if len(s) != int(3*size) {
+			t.Errorf("big: expected %d bytes, present %d",
+				3*size, len(s))
+		}
+	}
+
+	full, _ := srv.counts("/big")
+	if full != 2 {
+		t.Errorf("big: expected %d fetches, present %d", 2, full)
+	}
+
+}
@@ -10,6 +10,7 @@ package transport
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/OpenPrinting/go-mfp/log"
 )
@@ -17,6 +18,18 @@ import (
 // Client wraps [http.Client]
 type Client struct {
 	http.Client
+
+	// Cache, if not nil, is used for GET requests, performed
+	// with the Context that explicitly allows caching.
+	// See [WithCaching] for details.
+	Cache *Cache
+
+	// TokenRefreshMargin specifies how long before expiration
+	// the bearer token is refreshed (see [Client.SetTokenSource]).
+	// If zero, the DefaultTokenRefreshMargin is used.
+	TokenRefreshMargin time.Duration
+
+	tokens *tokenCache // Bearer tokens, nil if not used
 }
 
 // NewClient creates a new [Client].
@@ -39,10 +52,49 @@ func NewClient(tr *Transport) *Client {
 	return clnt
 }
 
+// WrapTransport replaces the Client's [http.RoundTripper] with
+// the wrapper, returned by the wrap function.
+//
+// It is intended for tests (i.e., for recording and replay of
+// HTTP interactions, see the transport/vcr package) and must be
+// called before the Client is used.
+func (c *Client) WrapTransport(
+	wrap func(next http.RoundTripper) http.RoundTripper) {
+
+	next := c.Client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	c.Client.Transport = wrap(next)
+}
+
 // Do sends an HTTP request and returns an HTTP response.
+//
+// The request carries the W3C traceparent header, and its
+// trace and span IDs are available to logs via the request
+// Context. If request Context has the [log.TraceContext], the
+// child span is created. Otherwise, the traceparent header,
+// if set by caller, is used, or the new trace is started.
 func (c *Client) Do(rq *http.Request) (*http.Response, error) {
+	// Propagate the trace context
+	rq = traceparentRequest(rq)
+
 	// Execute the request
-	rsp, err := c.Client.Do(rq)
+	var rsp *http.Response
+	var err error
+
+	send := c.Client.Do
+	if c.tokens != nil {
+		send = c.sendWithToken
+	}
+
+	if c.Cache != nil && rq.Method == "GET" &&
+		cachingEnabled(rq.Context()) {
+		rsp, err = c.Cache.do(rq, send)
+	} else {
+		rsp, err = send(rq)
+	}
 
 	// Write log message
 	var status string
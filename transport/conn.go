@@ -8,18 +8,72 @@
 
 package transport
 
-import "net"
+import (
+	"errors"
+	"net"
+)
 
 // connWithSetLinger denotes net.Conn with SetLinger method.
 type connWithSetLinger interface {
 	SetLinger(sec int) error
 }
 
-// connAbort closes connection abortively.
-func connAbort(conn net.Conn) {
-	if withSetLinger, ok := conn.(connWithSetLinger); ok {
-		withSetLinger.SetLinger(0)
+// connWithCloseWrite denotes net.Conn with CloseWrite method.
+type connWithCloseWrite interface {
+	CloseWrite() error
+}
+
+// connWithNetConn denotes net.Conn that wraps another net.Conn,
+// like [tls.Conn].
+type connWithNetConn interface {
+	NetConn() net.Conn
+}
+
+// AbortConn closes connection abortively: the SO_LINGER option
+// is set to zero and the connection is closed, so the TCP peer
+// receives RST instead of FIN and pending data is discarded.
+//
+// If connection wraps another connection (like [tls.Conn]), linger
+// is set on the underlying connection. For connections without
+// linger control (non-TCP) it is the same as the plain Close.
+//
+// It is safe to call AbortConn on nil or already closed connection.
+func AbortConn(conn net.Conn) {
+	if conn == nil {
+		return
+	}
+
+	for c := conn; c != nil; {
+		if withSetLinger, ok := c.(connWithSetLinger); ok {
+			withSetLinger.SetLinger(0)
+			break
+		}
+
+		withNetConn, ok := c.(connWithNetConn)
+		if !ok {
+			break
+		}
+		c = withNetConn.NetConn()
 	}
 
 	conn.Close()
 }
+
+// CloseWrite shuts down the writing side of the connection
+// (half-close). The peer receives FIN, while connection
+// remains readable.
+//
+// It returns [errors.ErrUnsupported], if connection doesn't support
+// half-close. It is safe to call CloseWrite on nil connection
+// (nil is returned) and call it multiple times.
+func CloseWrite(conn net.Conn) error {
+	if conn == nil {
+		return nil
+	}
+
+	if withCloseWrite, ok := conn.(connWithCloseWrite); ok {
+		return withCloseWrite.CloseWrite()
+	}
+
+	return errors.ErrUnsupported
+}
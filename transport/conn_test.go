@@ -0,0 +1,146 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Low-level functions for connections test
+
+package transport
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testTCPPair returns a pair of connected local TCP connections
+func testTCPPair(t *testing.T) (local, peer net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer l.Close()
+
+	local, err = net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	peer, err = l.Accept()
+	if err != nil {
+		local.Close()
+		t.Fatalf("%s", err)
+	}
+
+	t.Cleanup(func() {
+		local.Close()
+		peer.Close()
+	})
+
+	return local, peer
+}
+
+// testPeerRead reads from the peer until error and returns it
+func testPeerRead(t *testing.T, peer net.Conn) error {
+	peer.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	buf := make([]byte, 1024)
+	for {
+		_, err := peer.Read(buf)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// TestAbortConn tests that AbortConn produces RST
+func TestAbortConn(t *testing.T) {
+	local, peer := testTCPPair(t)
+
+	AbortConn(local)
+	err := testPeerRead(t, peer)
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("AbortConn: expected ECONNRESET, present %v", err)
+	}
+
+	// Repeated call and nil connection must be safe
+	AbortConn(local)
+	AbortConn(nil)
+}
+
+// TestAbortConnWrapped tests AbortConn on the connection,
+// wrapped into the tls.Conn
+func TestAbortConnWrapped(t *testing.T) {
+	local, peer := testTCPPair(t)
+
+	AbortConn(tls.Client(local, &tls.Config{}))
+	err := testPeerRead(t, peer)
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("AbortConn: expected ECONNRESET, present %v", err)
+	}
+}
+
+// TestCloseConn tests that normal Close produces FIN, for comparison
+// with the AbortConn
+func TestCloseConn(t *testing.T) {
+	local, peer := testTCPPair(t)
+
+	local.Close()
+	err := testPeerRead(t, peer)
+	if err != io.EOF {
+		t.Errorf("Close: expected EOF, present %v", err)
+	}
+}
+
+// TestCloseWrite tests CloseWrite
+func TestCloseWrite(t *testing.T) {
+	local, peer := testTCPPair(t)
+
+	// Peer must see EOF after half-close
+	err := CloseWrite(local)
+	if err != nil {
+		t.Fatalf("CloseWrite: %s", err)
+	}
+
+	err = testPeerRead(t, peer)
+	if err != io.EOF {
+		t.Errorf("CloseWrite: expected EOF, present %v", err)
+	}
+
+	// Repeated call must be safe
+	CloseWrite(local)
+
+	// Connection must remain readable
+	_, err = peer.Write([]byte("hello"))
+	if err != nil {
+		t.Fatalf("Write: %s", err)
+	}
+
+	local.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(local, buf)
+	if err != nil || string(buf) != "hello" {
+		t.Errorf("Read after CloseWrite: %q %v", buf, err)
+	}
+
+	// nil and connections without half-close
+	err = CloseWrite(nil)
+	if err != nil {
+		t.Errorf("CloseWrite(nil): %s", err)
+	}
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	err = CloseWrite(c1)
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("CloseWrite(net.Pipe): expected ErrUnsupported, "+
+			"present %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// DNS resolver cache
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Default DNSCache parameters:
+const (
+	// DefaultDNSCacheFixedTTL is the default lifetime of the
+	// cache entries, when TTL of DNS records is not available
+	// (i.e., names resolved via /etc/hosts).
+	DefaultDNSCacheFixedTTL = 60 * time.Second
+
+	// DefaultDNSCacheNegativeTTL is the default lifetime of the
+	// negative (NXDOMAIN) cache entries.
+	DefaultDNSCacheNegativeTTL = 5 * time.Second
+)
+
+// DNSCache caches results of the host name resolution, performed
+// by the [Transport] when connecting to devices referenced by the
+// host name.
+//
+// Entries are kept according to TTL of the DNS records. For names,
+// resolved without DNS queries (i.e., via /etc/hosts), the fixed
+// TTL is used. Nonexistent names (NXDOMAIN) are cached for a short
+// period (negative caching). Other failures, like timeouts, are not
+// cached.
+//
+// DNSCache is safe for concurrent use and may be shared between
+// multiple Transports.
+type DNSCache struct {
+	resolver *net.Resolver            // Underlying resolver
+	opts     DNSCacheOptions          // Cache options
+	now      func() time.Time         // Current time, for testing
+	lock     sync.Mutex               // Access lock
+	entries  map[string]dnsCacheEntry // Cached entries, by host
+	hits     atomic.Int64             // Positive hits counter
+	misses   atomic.Int64             // Misses counter
+	negHits  atomic.Int64             // Negative hits counter
+}
+
+// DNSCacheOptions contains the [DNSCache] options.
+type DNSCacheOptions struct {
+	// Server, if not "", overrides DNS servers from the
+	// system configuration. It is host:port.
+	Server string
+
+	// FixedTTL is the entries lifetime, if TTL of DNS records
+	// is not available. If 0, DefaultDNSCacheFixedTTL is used.
+	FixedTTL time.Duration
+
+	// NegativeTTL is the lifetime of the negative entries.
+	// If 0, DefaultDNSCacheNegativeTTL is used.
+	NegativeTTL time.Duration
+}
+
+// DNSCacheStats contains the [DNSCache] statistics.
+type DNSCacheStats struct {
+	Hits         int64 // Lookups, answered from the cache
+	Misses       int64 // Lookups, answered by DNS
+	NegativeHits int64 // Lookups, answered from the negative cache
+}
+
+// dnsCacheEntry is the DNSCache entry
+type dnsCacheEntry struct {
+	addrs   []netip.Addr // Resolved addresses, nil for negative
+	err     error        // Lookup error for negative entries
+	expires time.Time    // Expiration time
+}
+
+// dnsCacheTTL collects minimal TTL of DNS answers, received
+// during the single lookup.
+type dnsCacheTTL struct {
+	lock sync.Mutex
+	ttl  time.Duration
+	seen bool
+}
+
+// dnsCacheTTLKey is the context.Context key for dnsCacheTTL
+type dnsCacheTTLKey struct{}
+
+// dnsCacheConn wraps the UDP connection to the DNS server
+// and inspects responses for TTL.
+type dnsCacheConn struct {
+	*net.UDPConn
+	ttl *dnsCacheTTL
+}
+
+// NewDNSCache creates a new [DNSCache].
+func NewDNSCache(opts DNSCacheOptions) *DNSCache {
+	if opts.FixedTTL <= 0 {
+		opts.FixedTTL = DefaultDNSCacheFixedTTL
+	}
+
+	if opts.NegativeTTL <= 0 {
+		opts.NegativeTTL = DefaultDNSCacheNegativeTTL
+	}
+
+	cache := &DNSCache{
+		opts:    opts,
+		now:     time.Now,
+		entries: make(map[string]dnsCacheEntry),
+	}
+
+	cache.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial:     cache.dial,
+	}
+
+	return cache
+}
+
+// SetDNSCache enables resolving of host names via the [DNSCache].
+// If cache is nil, caching is disabled.
+//
+// It must be called before the Transport is used. See
+// [Transport.SetLocalAddr] for the limitations.
+func (tr *Transport) SetDNSCache(cache *DNSCache) {
+	tr.dnsCache = cache
+}
+
+// Lookup resolves host name into addresses, using the cache.
+func (cache *DNSCache) Lookup(ctx context.Context,
+	host string) ([]netip.Addr, error) {
+
+	now := cache.now()
+
+	cache.lock.Lock()
+	ent, found := cache.entries[host]
+	cache.lock.Unlock()
+
+	if found && now.Before(ent.expires) {
+		if ent.err != nil {
+			cache.negHits.Add(1)
+			return nil, ent.err
+		}
+
+		cache.hits.Add(1)
+		return ent.addrs, nil
+	}
+
+	cache.misses.Add(1)
+
+	// Perform the lookup
+	ttl := &dnsCacheTTL{}
+	ctx = context.WithValue(ctx, dnsCacheTTLKey{}, ttl)
+
+	addrs, err := cache.resolver.LookupNetIP(ctx, "ip", host)
+
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		ent = dnsCacheEntry{
+			addrs:   addrs,
+			expires: now.Add(ttl.get(cache.opts.FixedTTL)),
+		}
+
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		ent = dnsCacheEntry{
+			err:     err,
+			expires: now.Add(cache.opts.NegativeTTL),
+		}
+
+	default:
+		return nil, err
+	}
+
+	cache.lock.Lock()
+	cache.entries[host] = ent
+	cache.lock.Unlock()
+
+	return addrs, err
+}
+
+// Flush drops all cached entries.
+func (cache *DNSCache) Flush() {
+	cache.lock.Lock()
+	clear(cache.entries)
+	cache.lock.Unlock()
+}
+
+// Stats returns the cache statistics.
+func (cache *DNSCache) Stats() DNSCacheStats {
+	return DNSCacheStats{
+		Hits:         cache.hits.Load(),
+		Misses:       cache.misses.Load(),
+		NegativeHits: cache.negHits.Load(),
+	}
+}
+
+// dial implements the net.Resolver.Dial callback.
+//
+// UDP connections are wrapped to inspect DNS responses for TTL.
+// TCP responses are not inspected; if TTL is not known, the fixed
+// TTL is used.
+func (cache *DNSCache) dial(ctx context.Context,
+	network, address string) (net.Conn, error) {
+
+	if cache.opts.Server != "" {
+		address = cache.opts.Server
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl, _ := ctx.Value(dnsCacheTTLKey{}).(*dnsCacheTTL)
+	if udp, ok := conn.(*net.UDPConn); ok && ttl != nil {
+		conn = &dnsCacheConn{UDPConn: udp, ttl: ttl}
+	}
+
+	return conn, nil
+}
+
+// Read reads the DNS response and updates TTL.
+func (conn *dnsCacheConn) Read(b []byte) (int, error) {
+	n, err := conn.UDPConn.Read(b)
+	if err == nil {
+		conn.ttl.inspect(b[:n])
+	}
+	return n, err
+}
+
+// inspect updates TTL from the DNS message.
+func (ttl *dnsCacheTTL) inspect(msg []byte) {
+	var p dnsmessage.Parser
+	_, err := p.Start(msg)
+	if err != nil {
+		return
+	}
+
+	err = p.SkipAllQuestions()
+	if err != nil {
+		return
+	}
+
+	ttl.lock.Lock()
+	defer ttl.lock.Unlock()
+
+	for {
+		hdr, err := p.AnswerHeader()
+		if err != nil {
+			return
+		}
+
+		switch hdr.Type {
+		case dnsmessage.TypeA, dnsmessage.TypeAAAA,
+			dnsmessage.TypeCNAME:
+			t := time.Duration(hdr.TTL) * time.Second
+			if !ttl.seen || t < ttl.ttl {
+				ttl.ttl = t
+				ttl.seen = true
+			}
+		}
+
+		err = p.SkipAnswer()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// get returns the collected TTL or dflt, if TTL is not known.
+func (ttl *dnsCacheTTL) get(dflt time.Duration) time.Duration {
+	ttl.lock.Lock()
+	defer ttl.lock.Unlock()
+
+	if ttl.seen {
+		return ttl.ttl
+	}
+	return dflt
+}
@@ -0,0 +1,223 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// DNS resolver cache test
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// testDNSServer is the minimal DNS responder. It answers
+// A queries for the "printer.test" and responds NXDOMAIN
+// for everything else.
+type testDNSServer struct {
+	conn    net.PacketConn
+	ttl     uint32       // TTL of answers
+	queries atomic.Int64 // Count of received queries
+}
+
+// newTestDNSServer starts the testDNSServer.
+func newTestDNSServer(t *testing.T, ttl uint32) *testDNSServer {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	srv := &testDNSServer{conn: conn, ttl: ttl}
+	go srv.serve()
+
+	t.Cleanup(func() { conn.Close() })
+	return srv
+}
+
+// serve serves the DNS queries
+func (srv *testDNSServer) serve() {
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := srv.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		var msg dnsmessage.Message
+		if msg.Unpack(buf[:n]) != nil || len(msg.Questions) != 1 {
+			continue
+		}
+
+		srv.queries.Add(1)
+
+		q := msg.Questions[0]
+		msg.Response = true
+		msg.RecursionAvailable = true
+
+		switch {
+		case !strings.EqualFold(q.Name.String(), "printer.test."):
+			msg.RCode = dnsmessage.RCodeNameError
+
+		case q.Type == dnsmessage.TypeA:
+			msg.Answers = []dnsmessage.Resource{
+				{
+					Header: dnsmessage.ResourceHeader{
+						Name:  q.Name,
+						Type:  dnsmessage.TypeA,
+						Class: dnsmessage.ClassINET,
+						TTL:   srv.ttl,
+					},
+					Body: &dnsmessage.AResource{
+						A: [4]byte{127, 0, 0, 1},
+					},
+				},
+			}
+		}
+
+		packed, err := msg.Pack()
+		if err == nil {
+			srv.conn.WriteTo(packed, from)
+		}
+	}
+}
+
+// TestDNSCache tests DNSCache
+func TestDNSCache(t *testing.T) {
+	srv := newTestDNSServer(t, 30)
+
+	cache := NewDNSCache(DNSCacheOptions{
+		Server:      srv.conn.LocalAddr().String(),
+		NegativeTTL: 5 * time.Second,
+	})
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	expected := []netip.Addr{netip.MustParseAddr("127.0.0.1")}
+
+	lookup := func(host string) ([]netip.Addr, error) {
+		addrs, err := cache.Lookup(ctx, host)
+		if err == nil && !slices.Equal(addrs, expected) {
+			t.Errorf("%s: expected %v, present %v",
+				host, expected, addrs)
+		}
+		return addrs, err
+	}
+
+	checkStats := func(stage string, expected DNSCacheStats) {
+		if stats := cache.Stats(); stats != expected {
+			t.Errorf("%s: stats expected %+v, present %+v",
+				stage, expected, stats)
+		}
+	}
+
+	// Positive caching with TTL from the DNS response
+	if _, err := lookup("printer.test"); err != nil {
+		t.Fatalf("Lookup: %s", err)
+	}
+
+	queries := srv.queries.Load()
+
+	lookup("printer.test")
+	checkStats("positive", DNSCacheStats{Hits: 1, Misses: 1})
+
+	if srv.queries.Load() != queries {
+		t.Errorf("positive: cached entry caused DNS query")
+	}
+
+	now = now.Add(29 * time.Second)
+	lookup("printer.test")
+	checkStats("before TTL", DNSCacheStats{Hits: 2, Misses: 1})
+
+	now = now.Add(2 * time.Second)
+	lookup("printer.test")
+	checkStats("after TTL", DNSCacheStats{Hits: 2, Misses: 2})
+
+	// Negative caching
+	_, err := lookup("missed.test")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Fatalf("missed.test: expected NXDOMAIN, present %v", err)
+	}
+
+	lookup("missed.test")
+	checkStats("negative", DNSCacheStats{
+		Hits: 2, Misses: 3, NegativeHits: 1})
+
+	now = now.Add(6 * time.Second)
+	lookup("missed.test")
+	checkStats("negative expired", DNSCacheStats{
+		Hits: 2, Misses: 4, NegativeHits: 1})
+
+	// Flush
+	cache.Flush()
+	lookup("printer.test")
+	checkStats("flush", DNSCacheStats{
+		Hits: 2, Misses: 5, NegativeHits: 1})
+}
+
+// TestDNSCacheTransport tests Transport with DNSCache
+func TestDNSCacheTransport(t *testing.T) {
+	srv := newTestDNSServer(t, 30)
+
+	httpSrv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			io.WriteString(w, rq.Host)
+		}))
+	defer httpSrv.Close()
+
+	port := httpSrv.Listener.Addr().(*net.TCPAddr).Port
+
+	cache := NewDNSCache(DNSCacheOptions{
+		Server: srv.conn.LocalAddr().String(),
+	})
+
+	tr := NewTransport(nil)
+	tr.SetDNSCache(cache)
+	tr.DisableKeepAlives = true
+
+	clnt := &http.Client{Transport: tr}
+
+	for i := 0; i < 2; i++ {
+		rsp, err := clnt.Get("http://printer.test:" +
+			strconv.Itoa(port) + "/")
+		if err != nil {
+			t.Fatalf("GET: %s", err)
+		}
+
+		body, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if !strings.HasPrefix(string(body), "printer.test:") {
+			t.Errorf("Host: expected printer.test, present %s", body)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("stats: %+v", stats)
+	}
+
+	// Nonexistent host
+	_, err := clnt.Get("http://missed.test/")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("missed.test: expected NXDOMAIN, present %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Classification of client errors
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// ErrClass classifies client-side failures of the network requests,
+// so they can be reported to the end user in the understandable form.
+type ErrClass int
+
+// ErrClass values:
+const (
+	ErrClassOther              ErrClass = iota // Unclassified error
+	ErrClassDNSFailure                         // Host name not resolved
+	ErrClassConnectionRefused                  // Nobody listens on port
+	ErrClassNetworkUnreachable                 // No route to host
+	ErrClassTLSVerification                    // Bad server certificate
+	ErrClassTLSHandshake                       // Other TLS failure
+	ErrClassTimeout                            // Request timed out
+	ErrClassCanceled                           // Request canceled
+)
+
+// String returns the ErrClass name, for logging and debugging.
+func (class ErrClass) String() string {
+	switch class {
+	case ErrClassOther:
+		return "other"
+	case ErrClassDNSFailure:
+		return "DNS failure"
+	case ErrClassConnectionRefused:
+		return "connection refused"
+	case ErrClassNetworkUnreachable:
+		return "network unreachable"
+	case ErrClassTLSVerification:
+		return "TLS verification"
+	case ErrClassTLSHandshake:
+		return "TLS handshake"
+	case ErrClassTimeout:
+		return "timeout"
+	case ErrClassCanceled:
+		return "canceled"
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(class))
+}
+
+// ClassifyError returns the [ErrClass] of the client error.
+//
+// It understands errors, returned by the [Client] and by the
+// standard library: the nested *url.Error, *net.OpError,
+// *net.DNSError, x509 and TLS errors, system errors and the
+// context errors. If err is the [ClientError], its Class
+// is returned as is.
+func ClassifyError(err error) ErrClass {
+	var clientErr *ClientError
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		return ErrClassOther
+
+	case errors.As(err, &clientErr):
+		return clientErr.Class
+
+	case errors.Is(err, context.Canceled):
+		return ErrClassCanceled
+
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrClassTimeout
+
+	case errors.As(err, &dnsErr):
+		return ErrClassDNSFailure
+
+	case errClassTLSVerification(err):
+		return ErrClassTLSVerification
+
+	case errClassTLSHandshake(err):
+		return ErrClassTLSHandshake
+
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrClassConnectionRefused
+
+	case errors.Is(err, syscall.ENOENT) &&
+		errors.As(err, &opErr) && opErr.Op == "dial":
+		// Missed UNIX socket: the server is not running
+		return ErrClassConnectionRefused
+
+	case errors.Is(err, syscall.ENETUNREACH),
+		errors.Is(err, syscall.EHOSTUNREACH),
+		errors.Is(err, syscall.EHOSTDOWN):
+		return ErrClassNetworkUnreachable
+
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrClassTimeout
+	}
+
+	return ErrClassOther
+}
+
+// errClassTLSVerification reports if err is the TLS certificate
+// verification error.
+func errClassTLSVerification(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	return errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
+
+// errClassTLSHandshake reports if err is the TLS handshake error.
+func errClassTLSHandshake(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var opErr *net.OpError
+
+	switch {
+	case errors.As(err, &recordErr):
+		// Server doesn't speak TLS on this port
+		return true
+
+	case errors.As(err, &opErr) && opErr.Op == "remote error":
+		// TLS alert, received from the server
+		return true
+	}
+
+	// Other crypto/tls errors are not typed, and only
+	// recognizable by the message prefix.
+	for next := err; next != nil; next = errors.Unwrap(next) {
+		err = next
+	}
+
+	return strings.HasPrefix(err.Error(), "tls: ")
+}
+
+// Hint returns the actionable message for the user, that explains
+// the failure of the request to the target URL, classified as class.
+//
+// For [ErrClassOther] and [ErrClassCanceled] it returns "", as
+// there is nothing to advise.
+func Hint(class ErrClass, target *url.URL) string {
+	host, port := "the device", -1
+	if target != nil {
+		port = URLPort(target)
+		switch {
+		case target.Scheme == "unix":
+			host = target.Path
+		case target.Hostname() != "":
+			host = target.Hostname()
+		}
+	}
+
+	if target != nil && target.Scheme == "unix" {
+		switch class {
+		case ErrClassConnectionRefused:
+			return fmt.Sprintf("nobody listens on %s; "+
+				"check that CUPS is running", host)
+		case ErrClassTimeout:
+			return fmt.Sprintf("%s did not respond in time; "+
+				"check that CUPS is not overloaded", host)
+		}
+	}
+
+	switch class {
+	case ErrClassDNSFailure:
+		return fmt.Sprintf("cannot resolve %q; "+
+			"check the device name or use its IP address", host)
+
+	case ErrClassConnectionRefused:
+		if port < 0 {
+			return fmt.Sprintf("%s refused the connection; "+
+				"check that the service is enabled", host)
+		}
+
+		return fmt.Sprintf("%s refused the connection on port %d; "+
+			"check that %s is enabled", host, port,
+			hintService(target))
+
+	case ErrClassNetworkUnreachable:
+		return fmt.Sprintf("%s is unreachable; check the network "+
+			"connection and the device address", host)
+
+	case ErrClassTLSVerification:
+		return fmt.Sprintf("the certificate of %s cannot be verified; "+
+			"printers often use self-signed certificates, "+
+			"check the device certificate or use the "+
+			"non-secure URL", host)
+
+	case ErrClassTLSHandshake:
+		return fmt.Sprintf("TLS handshake with %s failed; "+
+			"the device may not support TLS on this port, "+
+			"try the ipp:// or http:// URL", host)
+
+	case ErrClassTimeout:
+		return fmt.Sprintf("%s did not respond in time; check that "+
+			"it is powered on and not in the sleep mode", host)
+	}
+
+	return ""
+}
+
+// hintService returns the service name, expected at the
+// target URL, for hints.
+func hintService(target *url.URL) string {
+	switch target.Scheme {
+	case "ipp", "ipps":
+		return "IPP"
+	}
+
+	return "the web service"
+}
+
+// ClientError wraps the client-side error with its [ErrClass]
+// and the target URL of the failed request.
+//
+// Use [errors.As] to obtain it from the error, returned by
+// the protocol clients.
+type ClientError struct {
+	Class  ErrClass // Error class
+	Target *url.URL // Target URL
+	Err    error    // Underlying error
+}
+
+// WrapError wraps the client-side error into the [ClientError].
+//
+// It returns err as is, if it is nil, is already wrapped or
+// cannot be classified (see [ClassifyError]).
+func WrapError(err error, target *url.URL) error {
+	var clientErr *ClientError
+	if err == nil || errors.As(err, &clientErr) {
+		return err
+	}
+
+	class := ClassifyError(err)
+	if class == ErrClassOther {
+		return err
+	}
+
+	return &ClientError{Class: class, Target: target, Err: err}
+}
+
+// Error returns the error message. It is the message of the
+// underlying error.
+func (e *ClientError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ClientError) Unwrap() error {
+	return e.Err
+}
+
+// Hint returns the actionable message for the user.
+// See [Hint] for details.
+func (e *ClientError) Hint() string {
+	return Hint(e.Class, e.Target)
+}
@@ -0,0 +1,305 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Classification of client errors test
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// testTimeoutError is the net.Error that reports timeout
+type testTimeoutError struct{}
+
+func (testTimeoutError) Error() string   { return "i/o timeout" }
+func (testTimeoutError) Timeout() bool   { return true }
+func (testTimeoutError) Temporary() bool { return true }
+
+// testDialError returns the synthetic error, returned by the
+// http.Client when dial fails with the specified error
+func testDialError(network string, err error) error {
+	return &url.Error{
+		Op:  "Post",
+		URL: "ipp://192.168.1.10/ipp/print",
+		Err: &net.OpError{
+			Op:  "dial",
+			Net: network,
+			Err: &os.SyscallError{Syscall: "connect", Err: err},
+		},
+	}
+}
+
+// testHTTPError returns the synthetic error, returned by the
+// http.Client when request fails with the specified error
+func testHTTPError(err error) error {
+	return &url.Error{
+		Op:  "Get",
+		URL: "https://192.168.1.10/eSCL/ScannerStatus",
+		Err: err,
+	}
+}
+
+// TestClassifyError tests ClassifyError
+func TestClassifyError(t *testing.T) {
+	type testData struct {
+		name  string
+		err   error
+		class ErrClass
+	}
+
+	tests := []testData{
+		{"nil", nil, ErrClassOther},
+		{"HTTP status", errors.New("HTTP: 404 Not Found"), ErrClassOther},
+
+		{
+			name: "DNS",
+			err: testHTTPError(&net.OpError{
+				Op:  "dial",
+				Net: "tcp",
+				Err: &net.DNSError{
+					Err:        "no such host",
+					Name:       "printer.local",
+					IsNotFound: true,
+				},
+			}),
+			class: ErrClassDNSFailure,
+		},
+
+		{
+			name:  "ECONNREFUSED",
+			err:   testDialError("tcp", syscall.ECONNREFUSED),
+			class: ErrClassConnectionRefused,
+		},
+
+		{
+			name:  "UNIX socket missed",
+			err:   testDialError("unix", syscall.ENOENT),
+			class: ErrClassConnectionRefused,
+		},
+
+		{
+			name:  "ENETUNREACH",
+			err:   testDialError("tcp", syscall.ENETUNREACH),
+			class: ErrClassNetworkUnreachable,
+		},
+
+		{
+			name:  "EHOSTUNREACH",
+			err:   testDialError("tcp", syscall.EHOSTUNREACH),
+			class: ErrClassNetworkUnreachable,
+		},
+
+		{
+			name: "x509 unknown authority",
+			err: testHTTPError(&tls.CertificateVerificationError{
+				Err: x509.UnknownAuthorityError{},
+			}),
+			class: ErrClassTLSVerification,
+		},
+
+		{
+			name: "x509 hostname",
+			err: testHTTPError(x509.HostnameError{
+				Certificate: &x509.Certificate{},
+				Host:        "192.168.1.10",
+			}),
+			class: ErrClassTLSVerification,
+		},
+
+		{
+			name: "x509 expired",
+			err: testHTTPError(x509.CertificateInvalidError{
+				Reason: x509.Expired,
+			}),
+			class: ErrClassTLSVerification,
+		},
+
+		{
+			name: "TLS record header",
+			err: testHTTPError(tls.RecordHeaderError{
+				Msg: "first record does not look like a TLS handshake",
+			}),
+			class: ErrClassTLSHandshake,
+		},
+
+		{
+			name: "TLS alert",
+			err: testHTTPError(&net.OpError{
+				Op:  "remote error",
+				Err: errors.New("tls: handshake failure"),
+			}),
+			class: ErrClassTLSHandshake,
+		},
+
+		{
+			name: "TLS untyped",
+			err: testHTTPError(
+				errors.New("tls: no supported versions")),
+			class: ErrClassTLSHandshake,
+		},
+
+		{
+			name:  "context canceled",
+			err:   testHTTPError(context.Canceled),
+			class: ErrClassCanceled,
+		},
+
+		{
+			name:  "context deadline",
+			err:   testHTTPError(context.DeadlineExceeded),
+			class: ErrClassTimeout,
+		},
+
+		{
+			name: "I/O deadline",
+			err: testHTTPError(&net.OpError{
+				Op:  "read",
+				Net: "tcp",
+				Err: os.ErrDeadlineExceeded,
+			}),
+			class: ErrClassTimeout,
+		},
+
+		{
+			name:  "net.Error timeout",
+			err:   testHTTPError(testTimeoutError{}),
+			class: ErrClassTimeout,
+		},
+
+		{
+			name: "ClientError",
+			err: fmt.Errorf("scan: %w", &ClientError{
+				Class: ErrClassTimeout,
+				Err:   errors.New("something"),
+			}),
+			class: ErrClassTimeout,
+		},
+	}
+
+	for _, test := range tests {
+		class := ClassifyError(test.err)
+		if class != test.class {
+			t.Errorf("%s: expected %s, present %s",
+				test.name, test.class, class)
+		}
+	}
+}
+
+// TestHint tests Hint
+func TestHint(t *testing.T) {
+	type testData struct {
+		class  ErrClass
+		target string
+		hint   string
+	}
+
+	tests := []testData{
+		{
+			class:  ErrClassConnectionRefused,
+			target: "ipp://192.168.1.10/ipp/print",
+			hint: "192.168.1.10 refused the connection on port 631; " +
+				"check that IPP is enabled",
+		},
+
+		{
+			class:  ErrClassConnectionRefused,
+			target: "http://192.168.1.10:8080/eSCL",
+			hint: "192.168.1.10 refused the connection on port 8080; " +
+				"check that the web service is enabled",
+		},
+
+		{
+			class:  ErrClassConnectionRefused,
+			target: "unix:/var/run/cups/cups.sock",
+			hint: "nobody listens on /var/run/cups/cups.sock; " +
+				"check that CUPS is running",
+		},
+
+		{
+			class:  ErrClassDNSFailure,
+			target: "ipp://printer.local/ipp/print",
+			hint: `cannot resolve "printer.local"; ` +
+				"check the device name or use its IP address",
+		},
+
+		{
+			class:  ErrClassTimeout,
+			target: "ipp://[fe80::1%25eth0]/ipp/print",
+			hint: "fe80::1%eth0 did not respond in time; check that " +
+				"it is powered on and not in the sleep mode",
+		},
+
+		{
+			class:  ErrClassCanceled,
+			target: "ipp://192.168.1.10/ipp/print",
+			hint:   "",
+		},
+
+		{
+			class:  ErrClassOther,
+			target: "ipp://192.168.1.10/ipp/print",
+			hint:   "",
+		},
+	}
+
+	for _, test := range tests {
+		hint := Hint(test.class, MustParseURL(test.target))
+		if hint != test.hint {
+			t.Errorf("%s %s:\nexpected: %q\npresent:  %q",
+				test.class, test.target, test.hint, hint)
+		}
+	}
+}
+
+// TestWrapError tests WrapError
+func TestWrapError(t *testing.T) {
+	target := MustParseURL("ipp://192.168.1.10/ipp/print")
+
+	if WrapError(nil, target) != nil {
+		t.Errorf("WrapError(nil): nil expected")
+	}
+
+	other := errors.New("HTTP: 404 Not Found")
+	if WrapError(other, target) != other {
+		t.Errorf("WrapError: unclassified error must not be wrapped")
+	}
+
+	dialErr := testDialError("tcp", syscall.ECONNREFUSED)
+	err := WrapError(dialErr, target)
+
+	var clientErr *ClientError
+	if !errors.As(err, &clientErr) {
+		t.Fatalf("WrapError: ClientError expected")
+	}
+
+	if clientErr.Class != ErrClassConnectionRefused ||
+		clientErr.Target != target {
+		t.Errorf("WrapError: class or target mismatch")
+	}
+
+	if err.Error() != dialErr.Error() {
+		t.Errorf("WrapError: message changed:\n"+
+			"expected: %q\npresent:  %q", dialErr.Error(), err.Error())
+	}
+
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("WrapError: errors.Is broken")
+	}
+
+	if WrapError(err, target) != err {
+		t.Errorf("WrapError: double wrapping")
+	}
+}
@@ -0,0 +1,81 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Generic HTTP request forwarding
+
+package transport
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/OpenPrinting/go-mfp/log"
+)
+
+// Forward forwards the [ServerQuery] as is, using the [Client],
+// and sends the response back to the client.
+//
+// The request URL is translated in the forward direction and the
+// Location response header in the reverse direction, using the
+// urlxlat. Request and response bodies are not touched, so this
+// function is suitable for requests, that don't need protocol-specific
+// handling in the proxy (for example, printer web pages and icons,
+// OPTIONS and HEAD requests and so on).
+//
+// If request cannot be forwarded, it is rejected with the
+// http.StatusBadGateway status.
+func Forward(query *ServerQuery, clnt *Client, urlxlat *URLXlat) {
+	ctx := query.RequestContext()
+
+	// Create outgoing request
+	target := urlxlat.Forward(query.RequestFullURL())
+
+	rqMeta := BodyMetaFromRequest(query.Request())
+
+	var body io.ReadCloser
+	if rqMeta.ContentLength() != 0 {
+		body = query.RequestBody()
+	}
+
+	out, err := NewRequest(ctx, query.RequestMethod(), target, body)
+	if err != nil {
+		query.Reject(http.StatusBadGateway, err)
+		return
+	}
+
+	out.Header = query.RequestHeader().Clone()
+	HTTPRemoveHopByHopHeaders(out.Header)
+	out.ContentLength = rqMeta.ContentLength()
+
+	// Execute the request
+	log.Debug(ctx, "HTTP: forward %s request to: %s", out.Method, out.URL)
+
+	rsp, err := clnt.Do(out)
+	if err != nil {
+		log.Debug(ctx, "HTTP: %s", err)
+		query.Reject(http.StatusBadGateway, err)
+		return
+	}
+
+	defer rsp.Body.Close()
+
+	// Forward the response
+	HTTPRemoveHopByHopHeaders(rsp.Header)
+
+	if location := rsp.Header.Get("Location"); location != "" {
+		if u, err := ParseURL(location); err == nil {
+			rsp.Header.Set("Location", urlxlat.Reverse(u).String())
+		}
+	}
+
+	HTTPCopyHeaders(query.ResponseHeader(), rsp.Header)
+	BodyMetaFromResponse(rsp).SetHeader(query.ResponseHeader())
+
+	query.WriteHeader(rsp.StatusCode)
+	if query.RequestMethod() != "HEAD" {
+		io.Copy(query, rsp.Body)
+	}
+}
@@ -0,0 +1,237 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Generic HTTP request forwarding test
+
+package transport
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testForwardTarget is the fake target for the Forward tests.
+//
+// It responds with the request method and path in the
+// X-Method and X-Path headers and echoes the request body.
+// Requests to /redirect are answered with the Location header,
+// pointing to the target's own URL.
+func testForwardTarget() *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			w.Header().Set("X-Method", rq.Method)
+			w.Header().Set("X-Path", rq.URL.Path)
+			if rq.URL.Path == "/target/redirect" {
+				w.Header().Set("Location", srv.URL+"/target/moved")
+			}
+
+			body, _ := io.ReadAll(rq.Body)
+			if rq.Method == "HEAD" {
+				body = []byte("ignored")
+			}
+
+			w.Write(append([]byte(rq.Method+":"), body...))
+		}))
+	return srv
+}
+
+// TestForward tests Forward
+func TestForward(t *testing.T) {
+	target := testForwardTarget()
+	defer target.Close()
+
+	var proxy *httptest.Server
+	proxy = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			query := NewServerQuery(w, rq)
+			defer query.Finish()
+
+			local := MustParseURL(proxy.URL + "/local")
+			remote := MustParseURL(target.URL + "/target")
+			urlxlat := NewURLXlat(local, remote)
+
+			Forward(query, NewClient(nil), urlxlat)
+		}))
+	defer proxy.Close()
+
+	type testData struct {
+		method string // Request method
+		path   string // Request path, relative to proxy
+		body   string // Request body
+		rsp    string // Expected response body
+	}
+
+	tests := []testData{
+		{method: "GET", path: "/local/page", rsp: "GET:"},
+		{method: "HEAD", path: "/local/page", rsp: ""},
+		{method: "OPTIONS", path: "/local/page", rsp: "OPTIONS:"},
+		{method: "DELETE", path: "/local/job", rsp: "DELETE:"},
+		{method: "PUT", path: "/local/job", body: "data",
+			rsp: "PUT:data"},
+		{method: "POST", path: "/local/job", body: "data",
+			rsp: "POST:data"},
+	}
+
+	for _, test := range tests {
+		rq, _ := http.NewRequest(test.method, proxy.URL+test.path,
+			strings.NewReader(test.body))
+
+		rsp, err := http.DefaultClient.Do(rq)
+		if err != nil {
+			t.Errorf("%s %s: %s", test.method, test.path, err)
+			continue
+		}
+
+		body, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusOK {
+			t.Errorf("%s %s: status %d", test.method, test.path,
+				rsp.StatusCode)
+		}
+
+		if m := rsp.Header.Get("X-Method"); m != test.method {
+			t.Errorf("%s %s: method mismatch: %q",
+				test.method, test.path, m)
+		}
+
+		if string(body) != test.rsp {
+			t.Errorf("%s %s: body mismatch:\n"+
+				"expected: %q\npresent:  %q",
+				test.method, test.path, test.rsp, body)
+		}
+	}
+
+	// Test Location translation
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	rsp, err := client.Get(proxy.URL + "/local/redirect")
+	if err != nil {
+		t.Fatalf("GET /local/redirect: %s", err)
+	}
+	rsp.Body.Close()
+
+	location := rsp.Header.Get("Location")
+	expected := proxy.URL + "/local/moved"
+	if location != expected {
+		t.Errorf("Location mismatch:\nexpected: %q\npresent:  %q",
+			expected, location)
+	}
+}
+
+// TestRejectMethod tests ServerQuery.RejectMethod
+func TestRejectMethod(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			query := NewServerQuery(w, rq)
+			defer query.Finish()
+			query.RejectMethod("GET", "HEAD", "POST")
+		}))
+	defer srv.Close()
+
+	rq, _ := http.NewRequest("PUT", srv.URL, nil)
+	rsp, err := http.DefaultClient.Do(rq)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("status mismatch: %d", rsp.StatusCode)
+	}
+
+	allow := rsp.Header.Get("Allow")
+	if allow != "GET, HEAD, POST" {
+		t.Errorf("Allow mismatch: %q", allow)
+	}
+}
+
+// TestForwardChunked tests forwarding of the chunked requests
+// and responses.
+func TestForwardChunked(t *testing.T) {
+	data := strings.Repeat("0123456789", 10000)
+
+	// Target echoes request body in chunks
+	type received struct {
+		te   []string // Request Transfer-Encoding
+		cl   int64    // Request ContentLength
+		body string   // Request body
+	}
+	ch := make(chan received, 1)
+
+	target := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			body, _ := io.ReadAll(rq.Body)
+			ch <- received{rq.TransferEncoding, rq.ContentLength,
+				string(body)}
+
+			for len(body) > 0 {
+				n := min(len(body), 4096)
+				w.Write(body[:n])
+				w.(http.Flusher).Flush()
+				body = body[n:]
+			}
+		}))
+	defer target.Close()
+
+	var proxy *httptest.Server
+	proxy = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			query := NewServerQuery(w, rq)
+			defer query.Finish()
+
+			local := MustParseURL(proxy.URL)
+			remote := MustParseURL(target.URL)
+			Forward(query, NewClient(nil), NewURLXlat(local, remote))
+		}))
+	defer proxy.Close()
+
+	// Hide the body length, so request will be sent chunked
+	body := io.MultiReader(strings.NewReader(data))
+	rq, _ := http.NewRequest("POST", proxy.URL+"/echo", body)
+
+	rsp, err := http.DefaultClient.Do(rq)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	rspBody, _ := io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+
+	// Check the forwarded request
+	rcv := <-ch
+	if len(rcv.te) == 0 || rcv.cl != -1 {
+		t.Errorf("request: expected chunked, present TE=%v CL=%d",
+			rcv.te, rcv.cl)
+	}
+
+	if rcv.body != data {
+		t.Errorf("request: body truncated: %d of %d bytes",
+			len(rcv.body), len(data))
+	}
+
+	// Check the response
+	if cl := rsp.Header.Get("Content-Length"); cl != "" {
+		t.Errorf("response: bogus Content-Length: %s", cl)
+	}
+
+	if len(rsp.TransferEncoding) == 0 {
+		t.Errorf("response: not chunked")
+	}
+
+	if string(rspBody) != data {
+		t.Errorf("response: body truncated: %d of %d bytes",
+			len(rspBody), len(data))
+	}
+}
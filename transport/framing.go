@@ -0,0 +1,281 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Connection-level detection of ambiguous HTTP request framing
+
+package transport
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// framingMaxLine is the maximum length of the request line, header
+// line or chunk size line, tracked by the framingConn. On longer lines
+// tracking is stopped.
+const framingMaxLine = 64 * 1024
+
+// framingState is the state of the framingConn parser
+type framingState int
+
+const (
+	framingHead      framingState = iota // Reading request head
+	framingBody                          // Reading fixed-length body
+	framingChunkSize                     // Reading chunk size line
+	framingChunkData                     // Reading chunk data
+	framingChunkCRLF                     // Reading CRLF after chunk data
+	framingTrailer                       // Reading trailer
+	framingOff                           // Tracking stopped
+)
+
+// framingConn wraps plain-text (non-TLS) HTTP/1.x server [net.Conn]
+// and follows the request stream, looking for requests that carry
+// both Content-Length and Transfer-Encoding headers.
+//
+// This is required, because net/http server silently drops the
+// Content-Length header of such requests before calling the handler.
+//
+// The framingConn follows the request framing the same way as net/http
+// does (Transfer-Encoding wins over the Content-Length), so it stays
+// in sync with the server. Each request head, seen on the connection,
+// produces a verdict, consumed by the handler with framingConn.next.
+// net/http serves requests of the connection sequentially, so verdicts
+// and requests are matched by order.
+//
+// If request stream cannot be followed (HTTP/2 preface, CONNECT or
+// Upgrade request, too long lines, invalid body length), tracking
+// is stopped.
+type framingConn struct {
+	net.Conn
+	lock     sync.Mutex   // Access lock
+	state    framingState // Parser state
+	line     []byte       // Current line
+	first    bool         // Next head line is the request line
+	cl, te   bool         // Content-Length/Transfer-Encoding seen
+	length   int64        // Content-Length value, -1 if invalid
+	off      bool         // Stop tracking after the current head
+	remain   int64        // Remaining bytes of body or chunk
+	verdicts []bool       // Pending verdicts, true if ambiguous
+}
+
+// framingListener wraps [net.Listener] and returns accepted
+// connections wrapped into the framingConn.
+type framingListener struct {
+	net.Listener
+}
+
+// framingConnKey is the context key for the framingConn
+type framingConnKey struct{}
+
+// newFramingConn wraps net.Conn into the framingConn.
+func newFramingConn(c net.Conn) *framingConn {
+	return &framingConn{Conn: c, first: true}
+}
+
+// Accept waits for and returns the next connection.
+func (l framingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newFramingConn(c), nil
+}
+
+// framingConnContext returns the context for the connection.
+//
+// If connection is the framingConn, it is attached to the context,
+// and the underlying connection is returned, so the connection
+// may be examined by other hooks. Otherwise, ctx and c are returned
+// unchanged.
+func framingConnContext(ctx context.Context, c net.Conn) (
+	context.Context, net.Conn) {
+
+	if fc, ok := c.(*framingConn); ok {
+		ctx = context.WithValue(ctx, framingConnKey{}, fc)
+		c = fc.Conn
+	}
+
+	return ctx, c
+}
+
+// framingAmbiguous reports whether the request, received via the
+// framingConn, had ambiguous framing. It must be called exactly
+// once per each request, passed to the handler.
+func framingAmbiguous(ctx context.Context) bool {
+	fc, ok := ctx.Value(framingConnKey{}).(*framingConn)
+	return ok && fc.next()
+}
+
+// Read reads data from the connection.
+func (fc *framingConn) Read(b []byte) (int, error) {
+	n, err := fc.Conn.Read(b)
+	if n > 0 {
+		fc.lock.Lock()
+		fc.feed(b[:n])
+		fc.lock.Unlock()
+	}
+	return n, err
+}
+
+// next returns the next verdict.
+func (fc *framingConn) next() bool {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	if len(fc.verdicts) == 0 {
+		return false
+	}
+
+	v := fc.verdicts[0]
+	fc.verdicts = fc.verdicts[1:]
+	return v
+}
+
+// feed feeds data, received from the connection, into the parser.
+func (fc *framingConn) feed(data []byte) {
+	for len(data) > 0 && fc.state != framingOff {
+		switch fc.state {
+		case framingBody, framingChunkData:
+			n := int64(len(data))
+			if n > fc.remain {
+				n = fc.remain
+			}
+
+			fc.remain -= n
+			data = data[n:]
+
+			if fc.remain == 0 {
+				if fc.state == framingBody {
+					fc.state = framingHead
+				} else {
+					fc.state = framingChunkCRLF
+				}
+			}
+
+		default:
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				fc.line = append(fc.line, data...)
+				data = nil
+			} else {
+				fc.line = append(fc.line, data[:i]...)
+				data = data[i+1:]
+				fc.onLine(bytes.TrimSuffix(fc.line, []byte("\r")))
+				fc.line = fc.line[:0]
+			}
+
+			if len(fc.line) > framingMaxLine {
+				fc.state = framingOff
+			}
+		}
+	}
+
+	if fc.state == framingOff {
+		fc.line = nil
+	}
+}
+
+// onLine handles the complete line, received in the line-oriented
+// parser state.
+func (fc *framingConn) onLine(line []byte) {
+	switch fc.state {
+	case framingHead:
+		fc.onHeadLine(line)
+
+	case framingChunkSize:
+		if i := bytes.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+
+		size, err := strconv.ParseInt(
+			string(bytes.TrimSpace(line)), 16, 64)
+		switch {
+		case err != nil || size < 0:
+			fc.state = framingOff
+		case size == 0:
+			fc.state = framingTrailer
+		default:
+			fc.remain = size
+			fc.state = framingChunkData
+		}
+
+	case framingChunkCRLF:
+		if len(line) != 0 {
+			fc.state = framingOff
+		} else {
+			fc.state = framingChunkSize
+		}
+
+	case framingTrailer:
+		if len(line) == 0 {
+			fc.state = framingHead
+		}
+	}
+}
+
+// onHeadLine handles the line of the request head.
+func (fc *framingConn) onHeadLine(line []byte) {
+	// Request line. Empty lines before it are ignored.
+	if fc.first {
+		switch {
+		case len(line) == 0:
+		case bytes.HasPrefix(line, []byte("PRI * HTTP/2")):
+			fc.state = framingOff
+		default:
+			fc.first = false
+			fc.cl, fc.te, fc.length = false, false, 0
+			fc.off = bytes.HasPrefix(line, []byte("CONNECT "))
+		}
+		return
+	}
+
+	// Header line
+	if len(line) != 0 {
+		i := bytes.IndexByte(line, ':')
+		if i < 0 {
+			return
+		}
+
+		name := string(bytes.ToLower(line[:i]))
+		value := string(bytes.TrimSpace(line[i+1:]))
+
+		switch name {
+		case "content-length":
+			l, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || l < 0 || (fc.cl && l != fc.length) {
+				l = -1
+			}
+			fc.cl, fc.length = true, l
+
+		case "transfer-encoding":
+			fc.te = true
+
+		case "upgrade":
+			fc.off = true
+		}
+
+		return
+	}
+
+	// End of head
+	fc.verdicts = append(fc.verdicts, fc.cl && fc.te)
+	fc.first = true
+
+	switch {
+	case fc.off:
+		fc.state = framingOff
+	case fc.te:
+		fc.state = framingChunkSize
+	case fc.length < 0:
+		fc.state = framingOff
+	case fc.length > 0:
+		fc.remain = fc.length
+		fc.state = framingBody
+	}
+}
@@ -0,0 +1,296 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Listeners handoff to the re-executed process
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Environment variables, used by the listeners handoff protocol.
+//
+// This is similar to the systemd socket activation, but self-managed:
+//   - EnvListenFDs contains comma-separated list of inherited
+//     listening sockets, in the fd:name form.
+//   - EnvHandoffFDs contains the ready:go pair of file descriptors,
+//     used to coordinate the handoff. The new process writes into
+//     the ready pipe when it is ready to take the sockets. The old
+//     process closes the go pipe when it stopped accepting, and
+//     the new process owns the sockets since then.
+const (
+	EnvListenFDs  = "MFP_LISTEN_FDS"
+	EnvHandoffFDs = "MFP_HANDOFF_FDS"
+)
+
+// ListenerFile is the listening socket of the [Server], exported
+// as [os.File] for passing to the other process.
+type ListenerFile struct {
+	Name string   // Listener name (its local address)
+	File *os.File // The socket file
+}
+
+// listenerFiler is implemented by net.Listeners that can
+// export their underlying socket as os.File.
+type listenerFiler interface {
+	File() (*os.File, error)
+}
+
+// Listeners returns files of all listening sockets the [Server]
+// currently serves. Files are duplicates of the original sockets,
+// and the caller is responsible to close them.
+//
+// Listeners must implement the File() (*os.File, error) method,
+// as [net.TCPListener] and [net.UnixListener] do.
+func (srvr *Server) Listeners() ([]ListenerFile, error) {
+	srvr.lock.Lock()
+	listeners := append([]net.Listener(nil), srvr.listeners...)
+	srvr.lock.Unlock()
+
+	files := make([]ListenerFile, 0, len(listeners))
+	for _, l := range listeners {
+		filer, ok := l.(listenerFiler)
+		var file *os.File
+		var err error
+
+		if ok {
+			file, err = filer.File()
+		} else {
+			err = fmt.Errorf("%s: listener can't be exported",
+				l.Addr())
+		}
+
+		if err != nil {
+			for _, lf := range files {
+				lf.File.Close()
+			}
+			return nil, err
+		}
+
+		files = append(files, ListenerFile{l.Addr().String(), file})
+	}
+
+	return files, nil
+}
+
+// Handoff passes listening sockets of the [Server] to the new
+// process, started by cmd, and drains the Server.
+//
+// The handoff is coordinated:
+//   - the new process is started and Handoff waits until it
+//     reports readiness (see [HandoffReady])
+//   - the Server stops accepting new connections
+//   - the new process is notified that it owns the sockets
+//   - the Server gracefully shuts down, waiting for active
+//     connections to complete, as [http.Server.Shutdown] does.
+//
+// Pending connections remain queued in the shared sockets, so
+// no connections are lost or reset during the handoff.
+//
+// If the new process fails to start or exits before it is ready,
+// Handoff returns an error and the Server continues to serve.
+// On success, the caller owns the running cmd and typically
+// exits after Handoff returns.
+//
+// The provided [context.Context] limits the whole operation.
+func (srvr *Server) Handoff(ctx context.Context, cmd *exec.Cmd) error {
+	files, err := srvr.Listeners()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		for _, lf := range files {
+			lf.File.Close()
+		}
+	}()
+
+	if len(files) == 0 {
+		return errors.New("handoff: no listeners")
+	}
+
+	// Create coordination pipes
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+
+	goR, goW, err := os.Pipe()
+	if err != nil {
+		readyW.Close()
+		return err
+	}
+	defer goW.Close()
+
+	// Prepare the command. Files in the cmd.ExtraFiles become
+	// fd 3, 4, ... in the new process.
+	fd := 3 + len(cmd.ExtraFiles)
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+
+	var fds []string
+	for _, lf := range files {
+		cmd.ExtraFiles = append(cmd.ExtraFiles, lf.File)
+		fds = append(fds, fmt.Sprintf("%d:%s", fd, lf.Name))
+		fd++
+	}
+
+	cmd.ExtraFiles = append(cmd.ExtraFiles, readyW, goR)
+	cmd.Env = append(env,
+		EnvListenFDs+"="+strings.Join(fds, ","),
+		fmt.Sprintf("%s=%d:%d", EnvHandoffFDs, fd, fd+1))
+
+	// Start the new process. Close our copies of its pipe ends,
+	// so we will see EOF if it exits.
+	err = cmd.Start()
+	readyW.Close()
+	goR.Close()
+
+	if err != nil {
+		return fmt.Errorf("handoff: %w", err)
+	}
+
+	// Wait until it is ready
+	ready := make(chan error, 1)
+	go func() {
+		var buf [1]byte
+		_, err := readyR.Read(buf[:])
+		if err == io.EOF {
+			err = errors.New("process exited before ready")
+		}
+		ready <- err
+	}()
+
+	select {
+	case err = <-ready:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		cmd.Process.Kill()
+		return fmt.Errorf("handoff: %w", err)
+	}
+
+	// Stop accepting and pass the ownership
+	srvr.lock.Lock()
+	for _, l := range srvr.listeners {
+		l.Close()
+	}
+	srvr.lock.Unlock()
+
+	goW.Close()
+
+	// Drain active connections. Listeners are already closed,
+	// so ignore the errors of closing them again.
+	srvr.Shutdown(ctx)
+	return ctx.Err()
+}
+
+// InheritListeners returns listeners, inherited from the parent
+// process by the [Server.Handoff], indexed by name. If process
+// has no inherited listeners, it returns nil map and nil error.
+//
+// It clears the [EnvListenFDs] environment variable, so
+// listeners are not inherited further by the child processes.
+func InheritListeners() (map[string]net.Listener, error) {
+	env, ok := os.LookupEnv(EnvListenFDs)
+	if !ok {
+		return nil, nil
+	}
+
+	os.Unsetenv(EnvListenFDs)
+
+	listeners := make(map[string]net.Listener)
+	for _, ent := range strings.Split(env, ",") {
+		if ent == "" {
+			continue
+		}
+
+		fdstr, name, _ := strings.Cut(ent, ":")
+		fd, err := strconv.ParseUint(fdstr, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid entry %q",
+				EnvListenFDs, ent)
+		}
+
+		file := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(file)
+		file.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w",
+				EnvListenFDs, name, err)
+		}
+
+		listeners[name] = l
+	}
+
+	return listeners, nil
+}
+
+// HandoffReady completes the handoff at the new process side.
+//
+// It reports to the old process that the new process is ready
+// to take the inherited sockets and waits until the old process
+// stops accepting. After that, inherited listeners can be served.
+//
+// If process was not started by the [Server.Handoff], HandoffReady
+// does nothing.
+func HandoffReady(ctx context.Context) error {
+	env, ok := os.LookupEnv(EnvHandoffFDs)
+	if !ok {
+		return nil
+	}
+
+	os.Unsetenv(EnvHandoffFDs)
+
+	readystr, gostr, _ := strings.Cut(env, ":")
+	readyfd, err1 := strconv.ParseUint(readystr, 10, 32)
+	gofd, err2 := strconv.ParseUint(gostr, 10, 32)
+	if err1 != nil || err2 != nil {
+		return fmt.Errorf("%s: invalid value %q", EnvHandoffFDs, env)
+	}
+
+	readyW := os.NewFile(uintptr(readyfd), "handoff-ready")
+	goR := os.NewFile(uintptr(gofd), "handoff-go")
+	defer goR.Close()
+
+	_, err := readyW.Write([]byte{1})
+	readyW.Close()
+	if err != nil {
+		return fmt.Errorf("handoff: %w", err)
+	}
+
+	// Wait until old process closes the go pipe. If it dies
+	// instead, we own the sockets anyway.
+	done := make(chan struct{})
+	go func() {
+		var buf [1]byte
+		goR.Read(buf[:])
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return nil
+}
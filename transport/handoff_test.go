@@ -0,0 +1,165 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Listeners handoff tests
+
+package transport
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testHandoffHelperEnv, if set, makes TestHandoffHelper to act
+// as the new process side of the handoff
+const testHandoffHelperEnv = "MFP_TEST_HANDOFF_HELPER"
+
+// testHandoffHandler returns http.Handler that responds with
+// the specified name
+func testHandoffHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		w.Write([]byte(name))
+	})
+}
+
+// TestHandoffHelper is the new process side of the TestHandoff.
+// It does nothing, unless started by the TestHandoff.
+func TestHandoffHelper(t *testing.T) {
+	if os.Getenv(testHandoffHelperEnv) == "" {
+		t.Skip("not a handoff helper process")
+	}
+
+	listeners, err := InheritListeners()
+	if err != nil || len(listeners) == 0 {
+		t.Fatalf("InheritListeners: %v (%d listeners)",
+			err, len(listeners))
+	}
+
+	ctx := context.Background()
+	srvr := NewServer(ctx, nil, testHandoffHandler("child"))
+
+	err = HandoffReady(ctx)
+	if err != nil {
+		t.Fatalf("HandoffReady: %s", err)
+	}
+
+	for _, l := range listeners {
+		go srvr.Serve(l)
+	}
+
+	// The parent kills us when done
+	time.Sleep(10 * time.Second)
+}
+
+// TestHandoff tests listeners handoff to the new process while
+// client continuously issues requests.
+func TestHandoff(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("listeners handoff is not supported on Windows")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %s", err)
+	}
+
+	ctx := context.Background()
+	srvr := NewServer(ctx, nil, testHandoffHandler("parent"))
+	go srvr.Serve(l)
+
+	// Run the client
+	var lock sync.Mutex
+	var errs []error
+	seen := make(map[string]int)
+	done := make(chan struct{})
+	var wait sync.WaitGroup
+
+	url := "http://" + l.Addr().String() + "/"
+	clnt := &http.Client{Timeout: 5 * time.Second}
+
+	wait.Add(1)
+	go func() {
+		defer wait.Done()
+
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			var body []byte
+			rsp, err := clnt.Get(url)
+			if err == nil {
+				body, err = io.ReadAll(rsp.Body)
+				rsp.Body.Close()
+			}
+
+			lock.Lock()
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				seen[string(body)]++
+			}
+			lock.Unlock()
+		}
+	}()
+
+	// Let client to talk with the parent for a while, then
+	// hand off the listener
+	time.Sleep(100 * time.Millisecond)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHandoffHelper$")
+	cmd.Env = append(os.Environ(), testHandoffHelperEnv+"=1")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	hctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	err = srvr.Handoff(hctx, cmd)
+	cancel()
+
+	if err != nil {
+		close(done)
+		wait.Wait()
+		t.Fatalf("Handoff: %s", err)
+	}
+
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	// Let client to talk with the child for a while
+	time.Sleep(100 * time.Millisecond)
+	close(done)
+	wait.Wait()
+
+	// Check results
+	if len(errs) != 0 {
+		t.Errorf("%d requests failed; first error: %s",
+			len(errs), errs[0])
+	}
+
+	if seen["parent"] == 0 || seen["child"] == 0 {
+		t.Errorf("responses: parent: %d, child: %d",
+			seen["parent"], seen["child"])
+	}
+
+	// Parent must not accept anymore
+	files, err := srvr.Listeners()
+	if err != nil || len(files) != 0 {
+		t.Errorf("Listeners after Handoff: %v, %d files",
+			err, len(files))
+	}
+}
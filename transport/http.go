@@ -16,6 +16,12 @@ import (
 // HTTPRemoveHopByHopHeaders removes HTTP hop-by-hop headers,
 // per [RFC 7230, section 6.1].
 //
+// It is intended for headers, received from the one side of
+// the proxy and forwarded to another. The framing of the server's
+// own response (Content-Length vs chunked Transfer-Encoding) is
+// managed by the net/http library; use [BodyMeta.SetHeader] to
+// control it.
+//
 // [RFC 7230, section 6.1]: https://www.rfc-editor.org/rfc/rfc7230.html#section-6.1
 func HTTPRemoveHopByHopHeaders(hdr http.Header) {
 	// Per RFC 7230, section 6.1:
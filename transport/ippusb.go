@@ -0,0 +1,134 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Detection of ipp-usb bridged devices
+
+package transport
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// IPPUSBStateDir is the directory where the ipp-usb daemon keeps
+// its per-device state files.
+const IPPUSBStateDir = "/var/ipp-usb/dev"
+
+// DetectIPPUSB checks if the USB device, identified by its vendor
+// ID, product ID and serial number, is bridged by the [ipp-usb]
+// daemon, and returns the localhost HTTP URL of the bridge.
+//
+// The bridge URL is the root URL of the device; the IPP printer
+// normally lives at "ipp/print" and the eSCL scanner at "eSCL"
+// under this root.
+//
+// The dir parameter specifies the ipp-usb state directory,
+// normally [IPPUSBStateDir].
+//
+// The ipp-usb daemon allocates a persistent localhost port for each
+// device it has ever seen and saves it in the device state file.
+// DetectIPPUSB doesn't check that ipp-usb is actually running.
+//
+// If device is not bridged or state directory is not available,
+// it returns nil.
+//
+// [ipp-usb]: https://github.com/OpenPrinting/ipp-usb
+func DetectIPPUSB(dir string, vid, pid uint16, serial string) *url.URL {
+	// ipp-usb names state files after the device ident, which
+	// looks as follows (with all unsafe characters replaced
+	// with '-'):
+	//
+	//   VVVV-PPPP-Serial-Manufacturer-Product.state
+	prefix := fmt.Sprintf("%4.4x-%4.4x-%s-", vid, pid,
+		ippusbIdentSanitize(serial))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.state"))
+	if err != nil {
+		return nil
+	}
+
+	for _, file := range files {
+		if !strings.HasPrefix(filepath.Base(file), prefix) {
+			continue
+		}
+
+		port := ippusbStatePort(file)
+		if port > 0 {
+			return &url.URL{
+				Scheme: "http",
+				Host:   "localhost:" + strconv.Itoa(port),
+				Path:   "/",
+			}
+		}
+	}
+
+	return nil
+}
+
+// ippusbStatePort returns the HTTP port, saved in the ipp-usb
+// device state file, or 0 if port is not available.
+//
+// The state file uses the INI-like format, and the port is
+// saved in the [device] section as follows:
+//
+//	[device]
+//	http-port = 60000
+func ippusbStatePort(file string) int {
+	fp, err := os.Open(file)
+	if err != nil {
+		return 0
+	}
+	defer fp.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "", line[0] == ';', line[0] == '#':
+			continue
+
+		case line[0] == '[':
+			section = strings.Trim(line, "[]")
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+
+		if ok && section == "device" && name == "http-port" {
+			port, err := strconv.ParseUint(value, 10, 16)
+			if err == nil {
+				return int(port)
+			}
+		}
+	}
+
+	return 0
+}
+
+// ippusbIdentSanitize replaces characters, unsafe for the
+// ipp-usb device ident, with '-', the same way as ipp-usb does.
+func ippusbIdentSanitize(s string) string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case '0' <= c && c <= '9':
+		case 'a' <= c && c <= 'z':
+		case 'A' <= c && c <= 'Z':
+		case c == '-' || c == '_':
+		default:
+			c = '-'
+		}
+		return c
+	}, s)
+}
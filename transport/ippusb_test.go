@@ -0,0 +1,70 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Detection of ipp-usb bridged devices test
+
+package transport
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDetectIPPUSB tests DetectIPPUSB
+func TestDetectIPPUSB(t *testing.T) {
+	dir := t.TempDir()
+
+	states := map[string]string{
+		"04a9-27e8-A1B2-C3-Canon-MF4400.state": "" +
+			"; ipp-usb device state\n" +
+			"[device]\n" +
+			"dnssd-name = Canon MF4400\n" +
+			"http-port = 60001\n",
+
+		"03f0-2b17-NOPORT-HP-LaserJet.state": "" +
+			"[device]\n" +
+			"dnssd-name = HP LaserJet\n",
+	}
+
+	for name, data := range states {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	tests := []struct {
+		vid, pid uint16
+		serial   string
+		url      string
+	}{
+		{0x04a9, 0x27e8, "A1B2/C3", "http://localhost:60001/"},
+		{0x04a9, 0x27e8, "A1B", ""},
+		{0x04a9, 0x27e9, "A1B2/C3", ""},
+		{0x03f0, 0x2b17, "NOPORT", ""},
+	}
+
+	for _, test := range tests {
+		u := DetectIPPUSB(dir, test.vid, test.pid, test.serial)
+		s := ""
+		if u != nil {
+			s = u.String()
+		}
+
+		if s != test.url {
+			t.Errorf("%4.4x:%4.4x %q: expected %q, present %q",
+				test.vid, test.pid, test.serial, test.url, s)
+		}
+	}
+
+	// Missed directory must not be an error
+	u := DetectIPPUSB(filepath.Join(dir, "missed"), 0x04a9, 0x27e8,
+		"A1B2/C3")
+	if u != nil {
+		t.Errorf("missed directory: expected nil, present %s", u)
+	}
+}
@@ -0,0 +1,272 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Close and Shutdown stress tests
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLifecycleRounds is the number of rounds of each stress test
+const testLifecycleRounds = 20
+
+// testLifecycleWait calls fn and fails the test, if fn doesn't
+// return in a reasonable time (i.e., deadlocked).
+func testLifecycleWait(t *testing.T, what string, fn func()) {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("%s: deadlock", what)
+	}
+}
+
+// testLifecycleListen creates the loopback TCP listener
+func testLifecycleListen(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	return l
+}
+
+// TestAutoTLSCloseRace closes the AutoTLS listener concurrently
+// from multiple goroutines, while connections are being accepted
+// and classified.
+func TestAutoTLSCloseRace(t *testing.T) {
+	for round := 0; round < testLifecycleRounds; round++ {
+		l := testLifecycleListen(t)
+		addr := l.Addr().String()
+		plain, encrypted := NewAutoTLSListener(l)
+
+		var wg sync.WaitGroup
+
+		// Accepters
+		for _, child := range []net.Listener{plain, encrypted,
+			plain, encrypted} {
+
+			wg.Add(1)
+			go func(child net.Listener) {
+				defer wg.Done()
+				for {
+					c, err := child.Accept()
+					if err != nil {
+						return
+					}
+					c.Close()
+				}
+			}(child)
+		}
+
+		// Clients: some send plain data, some stay silent,
+		// so the close hits connections in every state.
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				c, err := net.Dial("tcp", addr)
+				if err != nil {
+					return
+				}
+				if i%2 == 0 {
+					c.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+				}
+				io.Copy(io.Discard, c)
+				c.Close()
+			}(i)
+		}
+
+		// Concurrent close, including the double close
+		// of the same child.
+		for _, child := range []net.Listener{plain, encrypted, plain} {
+			wg.Add(1)
+			go func(child net.Listener) {
+				defer wg.Done()
+				child.Close()
+			}(child)
+		}
+
+		testLifecycleWait(t, "AutoTLS close", wg.Wait)
+
+		// Accept after close must fail immediately
+		if _, err := plain.Accept(); err == nil {
+			t.Errorf("Accept after Close: expected error")
+		}
+
+		if err := encrypted.Close(); err != nil {
+			t.Errorf("Close after Close: %s", err)
+		}
+	}
+}
+
+// TestServerShutdownRace shuts the Server down during active
+// requests, concurrently with Close and repeated Shutdown, with
+// both Serve and ServeAutoTLS.
+func TestServerShutdownRace(t *testing.T) {
+	for round := 0; round < testLifecycleRounds; round++ {
+		started := make(chan struct{}, 1)
+		release := make(chan struct{})
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter,
+			rq *http.Request) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+
+			select {
+			case <-release:
+			case <-rq.Context().Done():
+			}
+
+			w.Write([]byte("ok"))
+		})
+
+		srvr := NewServer(context.Background(), nil, handler)
+		srvr.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{*testAutoTLSCert},
+		}
+
+		l1 := testLifecycleListen(t)
+		l2 := testLifecycleListen(t)
+
+		var serving sync.WaitGroup
+		serving.Add(2)
+		go func() {
+			srvr.Serve(l1)
+			serving.Done()
+		}()
+		go func() {
+			srvr.ServeAutoTLS(l2)
+			serving.Done()
+		}()
+
+		// Start requests and wait until one of them is active
+		var clients sync.WaitGroup
+		for _, l := range []net.Listener{l1, l2} {
+			clients.Add(1)
+			go func(addr string) {
+				defer clients.Done()
+				rsp, err := http.Get("http://" + addr + "/")
+				if err == nil {
+					io.Copy(io.Discard, rsp.Body)
+					rsp.Body.Close()
+				}
+			}(l.Addr().String())
+		}
+
+		<-started
+
+		// Shutdown during active request, concurrently with
+		// Close and with the second Shutdown. Shutdown doesn't
+		// return until request completes or Close aborts it.
+		var closing sync.WaitGroup
+		closing.Add(3)
+		go func() {
+			srvr.Shutdown(context.Background())
+			closing.Done()
+		}()
+		go func() {
+			srvr.Shutdown(context.Background())
+			closing.Done()
+		}()
+		go func() {
+			time.Sleep(time.Duration(round%3) * time.Millisecond)
+			if round%2 == 0 {
+				srvr.Close()
+			} else {
+				close(release)
+			}
+			closing.Done()
+		}()
+
+		testLifecycleWait(t, "Server shutdown", closing.Wait)
+		testLifecycleWait(t, "Server serve", serving.Wait)
+		testLifecycleWait(t, "clients", clients.Wait)
+
+		// Double Close after Shutdown is harmless
+		srvr.Close()
+		srvr.Close()
+
+		if round%2 == 0 {
+			close(release)
+		}
+	}
+}
+
+// TestClientCloseIdleRace calls Client.CloseIdleConnections while
+// requests are in flight. Requests must not fail.
+func TestClientCloseIdleRace(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter,
+		rq *http.Request) {
+		io.Copy(io.Discard, rq.Body)
+		w.Write([]byte("ok"))
+	})
+
+	srvr := NewServer(context.Background(), nil, handler)
+	l := testLifecycleListen(t)
+	go srvr.Serve(l)
+	defer srvr.Close()
+
+	u := MustParseURL("http://" + l.Addr().String() + "/")
+	clnt := NewClient(nil)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < testLifecycleRounds; j++ {
+				rq, _ := NewRequest(context.Background(),
+					"GET", u, nil)
+				rsp, err := clnt.Do(rq)
+				if err != nil {
+					errs <- err
+					return
+				}
+				io.Copy(io.Discard, rsp.Body)
+				rsp.Body.Close()
+			}
+		}()
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				clnt.CloseIdleConnections()
+				time.Sleep(100 * time.Microsecond)
+			}
+		}
+	}()
+
+	testLifecycleWait(t, "requests", wg.Wait)
+	close(stop)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("request failed: %s", err)
+	}
+}
@@ -0,0 +1,392 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Polling budget, shared between cooperating processes
+
+package transport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPollBudgetTTL is the default expiration time of the
+// [PollBudget] registrations, that are not refreshed by their owner.
+const DefaultPollBudgetTTL = 30 * time.Second
+
+// PollBudget coordinates polling of the same targets (printers,
+// CUPS servers and so on) between multiple cooperating processes.
+//
+// When several tools (say, mfp-cups watch, discovery monitor and
+// the mfp-proxy attribute poller) poll the same small device, the
+// combined load may be too heavy for it. PollBudget keeps the
+// combined polling rate of all registrants of the same target not
+// above the rate, desired by the most demanding of them, by widening
+// intervals of all registrants proportionally.
+//
+// Registrations are kept in the shared file, protected by the
+// advisory file lock. Each process periodically refreshes its
+// registrations; registrations of the crashed processes are not
+// refreshed and expire after TTL.
+//
+// PollBudget is safe for concurrent use.
+type PollBudget struct {
+	path   string                       // Registry file
+	ttl    time.Duration                // Registration TTL
+	now    func() time.Time             // Current time, for testing
+	ctx    context.Context              // For PollBudget.Close
+	cancel context.CancelFunc           // ctx's cancel function
+	lock   sync.Mutex                   // Access lock
+	regs   map[string]*PollRegistration // Own registrations, by ID
+	done   sync.WaitGroup               // For PollBudget.Close
+}
+
+// PollRegistration represents registration of the poller
+// in the [PollBudget].
+type PollRegistration struct {
+	budget   *PollBudget        // Owning PollBudget
+	id       string             // Registration ID
+	target   string             // Normalized target URL
+	desired  time.Duration      // Desired interval
+	interval time.Duration      // Adjusted interval
+	changes  chan time.Duration // Interval changes
+}
+
+// pollBudgetEntry is the registration, as stored in the file.
+type pollBudgetEntry struct {
+	ID        string        `json:"id"`
+	Target    string        `json:"target"`
+	Desired   time.Duration `json:"desired"`
+	Heartbeat time.Time     `json:"heartbeat"`
+}
+
+// NewPollBudget creates a new [PollBudget], that uses the registry
+// file at the specified path. If path is "", [DefaultPollBudgetPath]
+// is used.
+//
+// If ttl is 0, [DefaultPollBudgetTTL] is used. Cooperating processes
+// must use the same TTL.
+func NewPollBudget(path string, ttl time.Duration) (*PollBudget, error) {
+	if path == "" {
+		var err error
+		path, err = DefaultPollBudgetPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultPollBudgetTTL
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pb := &PollBudget{
+		path:   path,
+		ttl:    ttl,
+		now:    time.Now,
+		ctx:    ctx,
+		cancel: cancel,
+		regs:   make(map[string]*PollRegistration),
+	}
+
+	pb.done.Add(1)
+	go pb.proc()
+
+	return pb, nil
+}
+
+// DefaultPollBudgetPath returns the default path of the [PollBudget]
+// registry file, located under the user runtime directory
+// ($XDG_RUNTIME_DIR or, if not set, the per-user directory under
+// the [os.TempDir]).
+func DefaultPollBudgetPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(),
+			"go-mfp-"+strconv.Itoa(os.Getuid()))
+	}
+
+	return filepath.Join(dir, "go-mfp", "pollbudget.json"), nil
+}
+
+// Close closes the PollBudget and removes all its registrations.
+func (pb *PollBudget) Close() {
+	pb.cancel()
+	pb.done.Wait()
+
+	pb.lock.Lock()
+	defer pb.lock.Unlock()
+
+	pb.update(func(entries []pollBudgetEntry) []pollBudgetEntry {
+		filtered := entries[:0]
+		for _, ent := range entries {
+			if pb.regs[ent.ID] == nil {
+				filtered = append(filtered, ent)
+			}
+		}
+		return filtered
+	})
+
+	clear(pb.regs)
+}
+
+// Register registers the poller of the target with the desired
+// polling interval.
+//
+// The returned [PollRegistration] reports the adjusted interval,
+// which may be wider that desired, if target is polled by other
+// registrants, and delivers its subsequent changes.
+func (pb *PollBudget) Register(target *url.URL,
+	desired time.Duration) (*PollRegistration, error) {
+
+	if desired <= 0 {
+		return nil, errors.New("poll budget: interval must be positive")
+	}
+
+	var buf [8]byte
+	rand.Read(buf[:])
+
+	reg := &PollRegistration{
+		budget: pb,
+		id: fmt.Sprintf("%d-%s", os.Getpid(),
+			hex.EncodeToString(buf[:])),
+		target:   pollBudgetTarget(target),
+		desired:  desired,
+		interval: desired,
+		changes:  make(chan time.Duration, 1),
+	}
+
+	pb.lock.Lock()
+	defer pb.lock.Unlock()
+
+	pb.regs[reg.id] = reg
+	err := pb.refresh()
+	if err != nil {
+		delete(pb.regs, reg.id)
+		return nil, err
+	}
+
+	return reg, nil
+}
+
+// Interval returns the current adjusted polling interval.
+func (reg *PollRegistration) Interval() time.Duration {
+	pb := reg.budget
+	pb.lock.Lock()
+	defer pb.lock.Unlock()
+
+	return reg.interval
+}
+
+// Changes returns the channel, that delivers the new adjusted
+// interval each time it changes. Only the most recent change
+// is kept, if receiver is not fast enough.
+func (reg *PollRegistration) Changes() <-chan time.Duration {
+	return reg.changes
+}
+
+// Close removes the registration.
+func (reg *PollRegistration) Close() {
+	pb := reg.budget
+	pb.lock.Lock()
+	defer pb.lock.Unlock()
+
+	if pb.regs[reg.id] == nil {
+		return
+	}
+
+	delete(pb.regs, reg.id)
+	pb.update(func(entries []pollBudgetEntry) []pollBudgetEntry {
+		filtered := entries[:0]
+		for _, ent := range entries {
+			if ent.ID != reg.id {
+				filtered = append(filtered, ent)
+			}
+		}
+		return filtered
+	})
+}
+
+// proc periodically refreshes registrations on its own goroutine.
+func (pb *PollBudget) proc() {
+	defer pb.done.Done()
+
+	ticker := time.NewTicker(pb.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pb.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pb.lock.Lock()
+		pb.refresh()
+		pb.lock.Unlock()
+	}
+}
+
+// refresh updates heartbeats of own registrations in the
+// registry file and recomputes the adjusted intervals.
+//
+// It must be called under the pb.lock.
+func (pb *PollBudget) refresh() error {
+	now := pb.now()
+
+	entries, err := pb.update(func(
+		entries []pollBudgetEntry) []pollBudgetEntry {
+
+		// Drop own entries, they will be re-added
+		filtered := entries[:0]
+		for _, ent := range entries {
+			if pb.regs[ent.ID] == nil {
+				filtered = append(filtered, ent)
+			}
+		}
+
+		for _, reg := range pb.regs {
+			filtered = append(filtered, pollBudgetEntry{
+				ID:        reg.id,
+				Target:    reg.target,
+				Desired:   reg.desired,
+				Heartbeat: now,
+			})
+		}
+
+		return filtered
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// Recompute intervals. Combined rate of all registrants
+	// of the target must not exceed rate of the most demanding
+	// of them.
+	type targetStat struct {
+		count   int
+		minimal time.Duration
+	}
+
+	stats := make(map[string]targetStat)
+	for _, ent := range entries {
+		st := stats[ent.Target]
+		if st.count == 0 || ent.Desired < st.minimal {
+			st.minimal = ent.Desired
+		}
+		st.count++
+		stats[ent.Target] = st
+	}
+
+	for _, reg := range pb.regs {
+		st := stats[reg.target]
+		interval := max(reg.desired, st.minimal*time.Duration(st.count))
+		if interval != reg.interval {
+			reg.interval = interval
+			reg.notify(interval)
+		}
+	}
+
+	return nil
+}
+
+// notify sends the interval change into the reg.changes channel,
+// replacing the previous unconsumed change, if any.
+func (reg *PollRegistration) notify(interval time.Duration) {
+	select {
+	case <-reg.changes:
+	default:
+	}
+
+	reg.changes <- interval
+}
+
+// update performs the read-modify-write of the registry file
+// under the file lock. Expired entries are dropped before
+// calling the modify callback.
+//
+// It returns entries, as written.
+func (pb *PollBudget) update(modify func(
+	[]pollBudgetEntry) []pollBudgetEntry) ([]pollBudgetEntry, error) {
+
+	file, err := os.OpenFile(pb.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	err = pollBudgetLock(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer pollBudgetUnlock(file)
+
+	// Load entries. Broken file is treated as empty.
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []pollBudgetEntry
+	json.Unmarshal(data, &entries)
+
+	// Drop expired entries
+	now := pb.now()
+	alive := entries[:0]
+	for _, ent := range entries {
+		if now.Sub(ent.Heartbeat) < pb.ttl {
+			alive = append(alive, ent)
+		}
+	}
+
+	// Modify and write back
+	entries = modify(alive)
+
+	data, err = json.Marshal(entries)
+	if err == nil {
+		err = file.Truncate(0)
+	}
+	if err == nil {
+		_, err = file.WriteAt(data, 0)
+	}
+
+	return entries, err
+}
+
+// pollBudgetTarget normalizes the target URL: scheme and host are
+// lowercased, default port is made explicit, path is cleaned and
+// everything else is dropped.
+func pollBudgetTarget(target *url.URL) string {
+	u := URLClone(target)
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	URLForcePort(u)
+
+	p := path.Clean("/" + u.Path)
+	return u.Scheme + "://" + u.Host + strings.TrimSuffix(p, "/")
+}
@@ -0,0 +1,24 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Polling budget file locking -- the portable fallback
+
+//go:build !unix
+
+package transport
+
+import "os"
+
+// pollBudgetLock is the no-op on systems without flock(2).
+// Cooperation between processes is best-effort there.
+func pollBudgetLock(file *os.File) error {
+	return nil
+}
+
+// pollBudgetUnlock is the no-op counterpart of pollBudgetLock.
+func pollBudgetUnlock(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,146 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Polling budget test
+
+package transport
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testPollBudgetClock is the fake clock, shared between
+// PollBudget instances under test
+type testPollBudgetClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+// Now returns the current fake time.
+func (clock *testPollBudgetClock) Now() time.Time {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+	return clock.now
+}
+
+// Advance advances the fake time.
+func (clock *testPollBudgetClock) Advance(d time.Duration) {
+	clock.lock.Lock()
+	clock.now = clock.now.Add(d)
+	clock.lock.Unlock()
+}
+
+// TestPollBudget tests PollBudget
+func TestPollBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pollbudget.json")
+	clock := &testPollBudgetClock{now: time.Now()}
+
+	// Each PollBudget simulates a separate process
+	newBudget := func() *PollBudget {
+		pb, err := NewPollBudget(path, time.Hour)
+		if err != nil {
+			t.Fatalf("NewPollBudget: %s", err)
+		}
+		pb.now = clock.Now
+		return pb
+	}
+
+	pb1 := newBudget()
+	defer pb1.Close()
+
+	pb2 := newBudget()
+
+	// The first registrant gets what it wants
+	reg1, err := pb1.Register(MustParseURL("ipp://Printer.local/ipp/print"),
+		5*time.Second)
+	if err != nil {
+		t.Fatalf("Register: %s", err)
+	}
+
+	if i := reg1.Interval(); i != 5*time.Second {
+		t.Errorf("reg1: interval expected %s, present %s",
+			5*time.Second, i)
+	}
+
+	// The second registrant of the same target widens
+	// intervals of both
+	reg2, err := pb2.Register(MustParseURL("ipp://printer.local:631/ipp/print/"),
+		5*time.Second)
+	if err != nil {
+		t.Fatalf("Register: %s", err)
+	}
+
+	if i := reg2.Interval(); i != 10*time.Second {
+		t.Errorf("reg2: interval expected %s, present %s",
+			10*time.Second, i)
+	}
+
+	pb1.lock.Lock()
+	pb1.refresh()
+	pb1.lock.Unlock()
+
+	select {
+	case i := <-reg1.Changes():
+		if i != 10*time.Second {
+			t.Errorf("reg1: interval expected %s, present %s",
+				10*time.Second, i)
+		}
+	default:
+		t.Errorf("reg1: interval change not reported")
+	}
+
+	// Registrant of the other target is not affected
+	reg3, err := pb1.Register(MustParseURL("ipp://other.local/ipp/print"),
+		5*time.Second)
+	if err != nil {
+		t.Fatalf("Register: %s", err)
+	}
+
+	if i := reg3.Interval(); i != 5*time.Second {
+		t.Errorf("reg3: interval expected %s, present %s",
+			5*time.Second, i)
+	}
+
+	// Simulate crash of the second process: it stops refreshing,
+	// lock is released, but its registration remains in the file.
+	pb2.cancel()
+	pb2.done.Wait()
+
+	clock.Advance(2 * time.Hour)
+
+	pb1.lock.Lock()
+	pb1.refresh()
+	pb1.lock.Unlock()
+
+	select {
+	case i := <-reg1.Changes():
+		if i != 5*time.Second {
+			t.Errorf("reg1: interval expected %s, present %s",
+				5*time.Second, i)
+		}
+	default:
+		t.Errorf("reg1: stale registration not expired")
+	}
+
+	// Closed registration must be removed
+	reg3.Close()
+
+	entries, err := pb1.update(func(
+		entries []pollBudgetEntry) []pollBudgetEntry {
+		return entries
+	})
+
+	if err != nil {
+		t.Fatalf("update: %s", err)
+	}
+
+	if len(entries) != 1 || entries[0].ID != reg1.id {
+		t.Errorf("registry: expected only reg1, present %v", entries)
+	}
+}
@@ -0,0 +1,31 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Polling budget file locking, the UNIX way
+
+//go:build unix
+
+package transport
+
+import (
+	"os"
+	"syscall"
+)
+
+// pollBudgetLock acquires an exclusive advisory lock on the
+// registry file. If lock is held by another process, it waits
+// until lock is released.
+//
+// Lock is released automatically when process terminates,
+// so crashed processes never leave the file locked.
+func pollBudgetLock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+}
+
+// pollBudgetUnlock releases the lock, acquired by pollBudgetLock.
+func pollBudgetUnlock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,206 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Per-device shared connection pools
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// PoolMaxConnsPerDevice limits count of simultaneous connections
+// to the same device, established via the [SharedPool].
+//
+// Printers and scanners are small devices, and many of them
+// misbehave when too many connections are opened simultaneously.
+const PoolMaxConnsPerDevice = 4
+
+// SharedPool is the pool of HTTP connections to the single device,
+// identified by the scheme, host and port, and shared between all
+// protocol clients, that talk to this device.
+//
+// For example, capturing the device model involves IPP and eSCL
+// requests to the same device. With SharedPool, these requests
+// reuse the same connections and TLS sessions, instead of making
+// a new TLS handshake for each protocol client.
+//
+// SharedPool is safe for concurrent use.
+type SharedPool struct {
+	key   string     // Pool key, see PoolFor
+	tr    *Transport // Shared transport
+	stats struct {
+		requests      atomic.Int64
+		conns         atomic.Int64
+		tlsHandshakes atomic.Int64
+		tlsResumed    atomic.Int64
+	}
+}
+
+// PoolStats contains the [SharedPool] statistics.
+type PoolStats struct {
+	Requests      int64 // HTTP requests sent
+	Conns         int64 // New connections established
+	TLSHandshakes int64 // Full TLS handshakes performed
+	TLSResumed    int64 // TLS sessions resumed
+}
+
+// Reused returns count of requests, sent over the reused connections.
+func (stats PoolStats) Reused() int64 {
+	if stats.Requests > stats.Conns {
+		return stats.Requests - stats.Conns
+	}
+	return 0
+}
+
+// sharedPools contains all SharedPool-s, indexed by key.
+var sharedPools struct {
+	pools map[string]*SharedPool
+	lock  sync.Mutex
+}
+
+// PoolFor returns the [SharedPool] for the device, identified
+// by the URL. Pools are created on demand and live forever.
+//
+// URLs with the equivalent schemes ("ipp" and "http", "ipps" and
+// "https") and the same host and port share the same pool.
+// Path and query are ignored.
+func PoolFor(u *url.URL) *SharedPool {
+	key := poolKey(u)
+
+	sharedPools.lock.Lock()
+	defer sharedPools.lock.Unlock()
+
+	pool := sharedPools.pools[key]
+	if pool == nil {
+		if sharedPools.pools == nil {
+			sharedPools.pools = make(map[string]*SharedPool)
+		}
+
+		pool = newSharedPool(key)
+		sharedPools.pools[key] = pool
+	}
+
+	return pool
+}
+
+// poolKey returns the SharedPool key for the URL.
+func poolKey(u *url.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+
+	switch scheme {
+	case "ipp":
+		scheme = "http"
+		if port == "" {
+			port = "631"
+		}
+
+	case "ipps":
+		scheme = "https"
+		if port == "" {
+			port = "631"
+		}
+
+	case "http":
+		if port == "" {
+			port = "80"
+		}
+
+	case "https":
+		if port == "" {
+			port = "443"
+		}
+
+	case "unix":
+		return "unix://" + u.Path
+	}
+
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// newSharedPool creates a new SharedPool
+func newSharedPool(key string) *SharedPool {
+	pool := &SharedPool{key: key}
+
+	tr := NewTransport(nil)
+	tr.pool = pool
+	tr.MaxConnsPerHost = PoolMaxConnsPerDevice
+	tr.MaxIdleConnsPerHost = PoolMaxConnsPerDevice
+
+	dial := tr.templateDialContext
+	if dial == nil {
+		dial = dialBound
+	}
+
+	tr.templateDialContext = func(ctx context.Context,
+		network, addr string) (net.Conn, error) {
+
+		conn, err := dial(ctx, network, addr)
+		if err == nil {
+			pool.stats.conns.Add(1)
+		}
+		return conn, err
+	}
+
+	tr.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+
+	verify := tr.TLSClientConfig.VerifyConnection
+	tr.TLSClientConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if cs.DidResume {
+			pool.stats.tlsResumed.Add(1)
+		} else {
+			pool.stats.tlsHandshakes.Add(1)
+		}
+
+		if verify != nil {
+			return verify(cs)
+		}
+		return nil
+	}
+
+	pool.tr = tr
+	return pool
+}
+
+// Key returns the pool key, which identifies the device.
+func (pool *SharedPool) Key() string {
+	return pool.key
+}
+
+// Transport returns the shared [Transport] of the pool.
+//
+// It can be passed to the protocol clients constructors, like
+// ipp.NewClient or escl.NewClient, so these clients will share
+// connections to the device.
+//
+// The returned Transport is shared, so its configuration
+// must not be modified.
+func (pool *SharedPool) Transport() *Transport {
+	return pool.tr
+}
+
+// Stats returns the pool statistics.
+func (pool *SharedPool) Stats() PoolStats {
+	return PoolStats{
+		Requests:      pool.stats.requests.Load(),
+		Conns:         pool.stats.conns.Load(),
+		TLSHandshakes: pool.stats.tlsHandshakes.Load(),
+		TLSResumed:    pool.stats.tlsResumed.Load(),
+	}
+}
+
+// CloseIdleConnections closes all idle connections of the pool.
+func (pool *SharedPool) CloseIdleConnections() {
+	pool.tr.CloseIdleConnections()
+}
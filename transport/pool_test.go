@@ -0,0 +1,164 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Per-device shared connection pools tests
+
+package transport
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestPoolKey tests poolKey
+func TestPoolKey(t *testing.T) {
+	type testData struct {
+		u1, u2 string // URLs to compare
+		same   bool   // Expected: same pool
+	}
+
+	tests := []testData{
+		{"ipp://Host/ipp/print", "http://host:631/eSCL", true},
+		{"ipps://host/ipp/print", "https://host:631/eSCL", true},
+		{"ipps://host/ipp/print", "ipp://host/ipp/print", false},
+		{"http://host/", "http://host:80/WSDScanner", true},
+		{"https://host/", "https://host:443/", true},
+		{"https://host/", "https://host:8443/", false},
+		{"https://[::1]/", "https://[::1]:443/", true},
+		{"unix:/var/run/cups.sock", "unix:/var/run/cups.sock", true},
+		{"unix:/var/run/cups.sock", "unix:/tmp/cups.sock", false},
+	}
+
+	for _, test := range tests {
+		u1 := MustParseURL(test.u1)
+		u2 := MustParseURL(test.u2)
+		same := poolKey(u1) == poolKey(u2)
+
+		if same != test.same {
+			t.Errorf("poolKey(%q)=%q, poolKey(%q)=%q",
+				test.u1, poolKey(u1), test.u2, poolKey(u2))
+		}
+	}
+}
+
+// TestPoolSharing tests that clients of different protocols,
+// talking to the same device, share connections via the SharedPool.
+func TestPoolSharing(t *testing.T) {
+	// Fake device, serving IPP and eSCL on the same port
+	var handshakes atomic.Int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipp/print", func(w http.ResponseWriter,
+		rq *http.Request) {
+		io.Copy(io.Discard, rq.Body)
+		w.Header().Set("Content-Type", "application/ipp")
+		w.Write([]byte("ipp"))
+	})
+	mux.HandleFunc("/eSCL/ScannerCapabilities", func(w http.ResponseWriter,
+		rq *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte("escl"))
+	})
+
+	srv := httptest.NewUnstartedServer(mux)
+	srv.TLS = &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (
+			*tls.Config, error) {
+			handshakes.Add(1)
+			return nil, nil
+		},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	host := strings.TrimPrefix(srv.URL, "https://")
+	ippURL := MustParseURL("ipps://" + host + "/ipp/print")
+	esclURL := MustParseURL("https://" + host + "/eSCL/")
+
+	pool := PoolFor(ippURL)
+	if PoolFor(esclURL) != pool {
+		t.Fatalf("PoolFor: different pools for the same device")
+	}
+
+	// Each protocol client creates its own Client on the
+	// shared Transport, as ipp.NewClient and escl.NewClient do
+	ippClient := NewClient(PoolFor(ippURL).Transport())
+	esclClient := NewClient(PoolFor(esclURL).Transport())
+
+	do := func(clnt *Client, method string, u *url.URL, expected string) {
+		var body io.Reader
+		if method == "POST" {
+			body = strings.NewReader("request")
+		}
+
+		rq, err := http.NewRequest(method, u.String(), body)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		rsp, err := clnt.Do(rq)
+		if err != nil {
+			t.Fatalf("%s %s: %s", method, u, err)
+		}
+
+		data, err := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if err != nil || string(data) != expected {
+			t.Fatalf("%s %s: %q (%v)", method, u, data, err)
+		}
+	}
+
+	// Interleave IPP and eSCL phases
+	for i := 0; i < 3; i++ {
+		do(ippClient, "POST", ippURL, "ipp")
+	}
+
+	for i := 0; i < 3; i++ {
+		do(esclClient, "GET",
+			MustParseURL(esclURL.String()+"ScannerCapabilities"),
+			"escl")
+	}
+
+	do(ippClient, "POST", ippURL, "ipp")
+
+	// Check results
+	if n := handshakes.Load(); n != 1 {
+		t.Errorf("TLS handshakes: expected 1, present %d", n)
+	}
+
+	stats := pool.Stats()
+	expected := PoolStats{
+		Requests:      7,
+		Conns:         1,
+		TLSHandshakes: 1,
+	}
+
+	if stats != expected {
+		t.Errorf("PoolStats:\nexpected: %+v\npresent:  %+v",
+			expected, stats)
+	}
+
+	if reused := stats.Reused(); reused != 6 {
+		t.Errorf("PoolStats.Reused: expected 6, present %d", reused)
+	}
+
+	// After connections are closed, TLS session must be resumed
+	pool.CloseIdleConnections()
+	do(esclClient, "GET",
+		MustParseURL(esclURL.String()+"ScannerCapabilities"), "escl")
+
+	stats = pool.Stats()
+	if stats.Conns != 2 || stats.TLSResumed != 1 {
+		t.Errorf("PoolStats after reconnect: %+v", stats)
+	}
+}
@@ -51,11 +51,24 @@ type ServerQuery struct {
 }
 
 // NewServerQuery returns the new [ServerQuery].
+//
+// If request has the W3C traceparent header, the trace context
+// is attached to the request Context (see [log.WithTraceContext]).
+//
+// The query ID is attached to the request Context as the request
+// sequence number (see [log.WithRequestSeq]), unless Context already
+// has one, so log records can be joined with the protocol trace.
 func NewServerQuery(w http.ResponseWriter, rq *http.Request) *ServerQuery {
+	rq = traceparentServerRequest(rq)
+	id := nextQueryID.Add(1)
+	if _, ok := log.CtxRequestSeq(rq.Context()); !ok {
+		rq = rq.WithContext(log.WithRequestSeq(rq.Context(), id))
+	}
+
 	ctx := rq.Context()
 	query := &ServerQuery{
 		log:       log.Begin(ctx),
-		id:        nextQueryID.Add(1),
+		id:        id,
 		logprefix: "HTTP-SRVR",
 		rq:        rq,
 		w:         w,
@@ -298,6 +311,13 @@ func (query *ServerQuery) Reject(status int, err error) {
 	query.Finish()
 }
 
+// RejectMethod completes request with the http.StatusMethodNotAllowed
+// status and the Allow header, that lists the allowed methods.
+func (query *ServerQuery) RejectMethod(allowed ...string) {
+	query.ResponseHeader().Set("Allow", strings.Join(allowed, ", "))
+	query.Reject(http.StatusMethodNotAllowed, nil)
+}
+
 // Created completes request with the http.StatusCreated
 // status and Location: URL
 func (query *ServerQuery) Created(location string) {
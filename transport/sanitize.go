@@ -0,0 +1,213 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Sanitation of the incoming HTTP requests
+
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ErrHTTPFraming is returned by the [HTTPSanitizeRequest], if request
+// carries both Content-Length and Transfer-Encoding headers, so its
+// framing is ambiguous.
+var ErrHTTPFraming = errors.New(
+	"HTTP: both Content-Length and Transfer-Encoding present")
+
+// httpMergeableHeaders contains headers, defined as comma-separated
+// lists, so multiple header fields with the same name can be merged
+// into the single one, per [RFC 7230, section 3.2.2].
+//
+// [RFC 7230, section 3.2.2]: https://www.rfc-editor.org/rfc/rfc7230.html#section-3.2.2
+var httpMergeableHeaders = map[string]struct{}{
+	"Accept":            {},
+	"Accept-Charset":    {},
+	"Accept-Encoding":   {},
+	"Accept-Language":   {},
+	"Allow":             {},
+	"Cache-Control":     {},
+	"Connection":        {},
+	"Content-Encoding":  {},
+	"Content-Language":  {},
+	"Expect":            {},
+	"If-Match":          {},
+	"If-None-Match":     {},
+	"Pragma":            {},
+	"Te":                {},
+	"Trailer":           {},
+	"Transfer-Encoding": {},
+	"Tracestate":        {},
+	"Upgrade":           {},
+	"Via":               {},
+	"Warning":           {},
+}
+
+// httpSingletonHeaders contains headers, that may appear only
+// once. Identical duplicates are collapsed, conflicting duplicates
+// make request invalid.
+var httpSingletonHeaders = map[string]struct{}{
+	"Authorization":       {},
+	"Content-Length":      {},
+	"Content-Range":       {},
+	"Content-Type":        {},
+	"Host":                {},
+	"If-Modified-Since":   {},
+	"If-Unmodified-Since": {},
+	"Proxy-Authorization": {},
+	"Range":               {},
+	"Referer":             {},
+	"Traceparent":         {},
+	"User-Agent":          {},
+}
+
+// HTTPSanitizeRequest sanitizes the incoming [http.Request] before
+// it is processed or forwarded.
+//
+// It returns [ErrHTTPFraming], if request carries both Content-Length
+// and Transfer-Encoding headers, and otherwise sanitizes request
+// headers with the [HTTPSanitizeHeaders].
+//
+// Note, net/http server silently drops Content-Length of the chunked
+// requests. The [Server] detects such requests at the connection
+// level and rejects them before calling the handler.
+//
+// Requests, rejected by this function, should be answered with
+// the http.StatusBadRequest status.
+func HTTPSanitizeRequest(rq *http.Request) error {
+	if len(rq.TransferEncoding) != 0 {
+		for name := range rq.Header {
+			if strings.EqualFold(name, "Content-Length") {
+				return ErrHTTPFraming
+			}
+		}
+	}
+
+	return HTTPSanitizeHeaders(rq.Header)
+}
+
+// HTTPSanitizeHeaders sanitizes HTTP headers in place:
+//   - header names are canonicalized; headers with invalid names
+//     are dropped
+//   - values with control characters are dropped
+//   - duplicates of the comma-separated list headers (Accept,
+//     Cache-Control and so on) are merged into the single value
+//   - identical duplicates of headers, that may appear only once
+//     (Content-Type, Host and so on) are collapsed
+//
+// It returns error, if headers, that may appear only once, have
+// conflicting values. Other headers (like Cookie) are left as is.
+func HTTPSanitizeHeaders(hdr http.Header) error {
+	// Canonicalize names. Remember non-canonical names first,
+	// so we don't modify the map while iterating. Values of
+	// non-canonical names are appended in the sorted order of
+	// names, so the result is predictable.
+	var rename []string
+	for name := range hdr {
+		if !httpValidName(name) ||
+			name != http.CanonicalHeaderKey(name) {
+			rename = append(rename, name)
+		}
+	}
+
+	sort.Strings(rename)
+	for _, name := range rename {
+		vals := hdr[name]
+		delete(hdr, name)
+
+		if httpValidName(name) {
+			canon := http.CanonicalHeaderKey(name)
+			hdr[canon] = append(hdr[canon], vals...)
+		}
+	}
+
+	// Process values
+	for name, vals := range hdr {
+		valid := vals[:0]
+		for _, v := range vals {
+			if httpValidValue(v) {
+				valid = append(valid, v)
+			}
+		}
+		vals = valid
+
+		switch {
+		case len(vals) == 0:
+			delete(hdr, name)
+			continue
+
+		case len(vals) == 1:
+
+		case httpIsMergeable(name):
+			vals = []string{strings.Join(vals, ", ")}
+
+		case httpIsSingleton(name):
+			for _, v := range vals[1:] {
+				if v != vals[0] {
+					return fmt.Errorf("HTTP: conflicting %s headers",
+						name)
+				}
+			}
+			vals = vals[:1]
+		}
+
+		hdr[name] = vals
+	}
+
+	return nil
+}
+
+// httpIsMergeable reports whether header with the canonical name
+// is the comma-separated list, that can be merged.
+func httpIsMergeable(name string) bool {
+	_, ok := httpMergeableHeaders[name]
+	return ok
+}
+
+// httpIsSingleton reports whether header with the canonical name
+// may appear only once.
+func httpIsSingleton(name string) bool {
+	_, ok := httpSingletonHeaders[name]
+	return ok
+}
+
+// httpValidName reports whether s is the valid header name
+// (RFC 7230 token).
+func httpValidName(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z',
+			'0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// httpValidValue reports whether s is the valid header value,
+// i.e., it doesn't contain control characters other that HTAB.
+func httpValidValue(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < ' ' && c != '\t') || c == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,295 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Sanitation of the incoming HTTP requests test
+
+package transport
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHTTPSanitizeHeaders tests HTTPSanitizeHeaders
+func TestHTTPSanitizeHeaders(t *testing.T) {
+	type testData struct {
+		in       http.Header // Input headers
+		expected http.Header // Expected output
+		err      string      // Expected error
+	}
+
+	tests := []testData{
+		{
+			// Canonicalization and merging
+			in: http.Header{
+				"accept":        {"text/html"},
+				"Accept":        {"application/ipp"},
+				"cache-control": {"no-cache", "no-store"},
+			},
+			expected: http.Header{
+				"Accept":        {"application/ipp, text/html"},
+				"Cache-Control": {"no-cache, no-store"},
+			},
+		},
+
+		{
+			// Identical singletons are collapsed, other
+			// duplicates left as is
+			in: http.Header{
+				"Content-Type": {"application/ipp", "application/ipp"},
+				"Cookie":       {"a=1", "b=2"},
+			},
+			expected: http.Header{
+				"Content-Type": {"application/ipp"},
+				"Cookie":       {"a=1", "b=2"},
+			},
+		},
+
+		{
+			// Control characters and invalid names
+			in: http.Header{
+				"X-Good":    {"tab\tis ok"},
+				"X-Bad":     {"line\nbreak", "nul\x00"},
+				"X-Mixed":   {"del\x7f", "ok"},
+				"Bad Name":  {"value"},
+				"Bad:Name2": {"value"},
+			},
+			expected: http.Header{
+				"X-Good":  {"tab\tis ok"},
+				"X-Mixed": {"ok"},
+			},
+		},
+
+		{
+			// Conflicting singletons
+			in: http.Header{
+				"content-type": {"application/ipp"},
+				"Content-Type": {"text/plain"},
+			},
+			err: "HTTP: conflicting Content-Type headers",
+		},
+	}
+
+	for _, test := range tests {
+		hdr := test.in.Clone()
+		err := HTTPSanitizeHeaders(hdr)
+
+		errstr := ""
+		if err != nil {
+			errstr = err.Error()
+		}
+
+		switch {
+		case errstr != test.err:
+			t.Errorf("%v:\nerror expected: %q\nerror present:  %q",
+				test.in, test.err, errstr)
+
+		case err == nil && !reflect.DeepEqual(hdr, test.expected):
+			t.Errorf("%v:\nexpected: %v\npresent:  %v",
+				test.in, test.expected, hdr)
+		}
+	}
+}
+
+// TestHTTPSanitizeRequest tests HTTPSanitizeRequest
+func TestHTTPSanitizeRequest(t *testing.T) {
+	rq, _ := http.NewRequest("POST", "http://localhost/", nil)
+	rq.TransferEncoding = []string{"chunked"}
+	rq.Header.Set("Content-Length", "5")
+
+	err := HTTPSanitizeRequest(rq)
+	if err != ErrHTTPFraming {
+		t.Errorf("error expected: %v, present: %v", ErrHTTPFraming, err)
+	}
+
+	rq.Header.Del("Content-Length")
+	err = HTTPSanitizeRequest(rq)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+// TestServerSanitize tests request sanitation by the Server,
+// using raw requests, sent over the single connection.
+func TestServerSanitize(t *testing.T) {
+	// The handler echoes request headers and body length
+	handler := http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			body, _ := io.ReadAll(rq.Body)
+
+			var names []string
+			for name := range rq.Header {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				fmt.Fprintf(w, "%s: %s\n", name,
+					strings.Join(rq.Header[name], "|"))
+			}
+			fmt.Fprintf(w, "body: %d\n", len(body))
+		})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	srvr := NewServer(context.Background(), nil, handler)
+	go srvr.Serve(l)
+	defer srvr.Close()
+
+	// dial opens the new connection to the server
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		return conn, bufio.NewReader(conn)
+	}
+
+	type testData struct {
+		name     string // Test name
+		rq       string // Raw request
+		status   int    // Expected status
+		expected string // Expected body (if status is OK)
+	}
+
+	tests := []testData{
+		{
+			name: "duplicate mergeable headers",
+			rq: "GET / HTTP/1.1\r\n" +
+				"Host: localhost\r\n" +
+				"accept: text/html\r\n" +
+				"ACCEPT: application/ipp\r\n" +
+				"\r\n",
+			status: http.StatusOK,
+			expected: "Accept: text/html, application/ipp\n" +
+				"body: 0\n",
+		},
+
+		{
+			name: "chunked body",
+			rq: "POST / HTTP/1.1\r\n" +
+				"Host: localhost\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"5\r\nHello\r\n" +
+				"6;ext=1\r\n, Dave\r\n" +
+				"0\r\n" +
+				"\r\n",
+			status:   http.StatusOK,
+			expected: "body: 11\n",
+		},
+
+		{
+			name: "identical duplicate Content-Type",
+			rq: "POST / HTTP/1.1\r\n" +
+				"Host: localhost\r\n" +
+				"Content-Type: application/ipp\r\n" +
+				"Content-Type: application/ipp\r\n" +
+				"Content-Length: 4\r\n" +
+				"\r\n" +
+				"\r\n\r\n",
+			status: http.StatusOK,
+			expected: "Content-Length: 4\n" +
+				"Content-Type: application/ipp\n" +
+				"body: 4\n",
+		},
+
+		{
+			name: "conflicting Content-Type",
+			rq: "POST / HTTP/1.1\r\n" +
+				"Host: localhost\r\n" +
+				"Content-Type: application/ipp\r\n" +
+				"Content-Type: text/plain\r\n" +
+				"Content-Length: 0\r\n" +
+				"\r\n",
+			status: http.StatusBadRequest,
+		},
+
+		{
+			name: "Content-Length with Transfer-Encoding",
+			rq: "POST / HTTP/1.1\r\n" +
+				"Host: localhost\r\n" +
+				"Content-Length: 5\r\n" +
+				"Transfer-Encoding: chunked\r\n" +
+				"\r\n" +
+				"0\r\n" +
+				"\r\n",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	// Accepted requests share the same connection, so the
+	// connection-level tracking must follow request framing.
+	conn, rd := dial()
+	defer func() { conn.Close() }()
+
+	for _, test := range tests {
+		_, err := io.WriteString(conn, test.rq)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		rsp, err := http.ReadResponse(rd, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		body, _ := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if rsp.StatusCode != test.status {
+			t.Errorf("%s: status expected %d, present %d",
+				test.name, test.status, rsp.StatusCode)
+		}
+
+		if test.status == http.StatusOK && string(body) != test.expected {
+			t.Errorf("%s:\nexpected:\n%s\npresent:\n%s",
+				test.name, test.expected, body)
+		}
+
+		if test.status != http.StatusOK {
+			// Server must close the connection after
+			// the rejected request.
+			if !rsp.Close {
+				t.Errorf("%s: connection not closed", test.name)
+			}
+
+			conn.Close()
+			conn, rd = dial()
+		}
+	}
+
+	// Control characters are rejected by net/http before
+	// the handler is called.
+	io.WriteString(conn, "GET / HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"X-Bad: a\x01b\r\n"+
+		"\r\n")
+
+	rsp, err := http.ReadResponse(rd, nil)
+	if err != nil {
+		t.Fatalf("control characters: %s", err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusBadRequest {
+		t.Errorf("control characters: status expected %d, present %d",
+			http.StatusBadRequest, rsp.StatusCode)
+	}
+}
@@ -13,15 +13,28 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/OpenPrinting/go-mfp/log"
 )
 
 // Server wraps [http.Server]
+//
+// Shutdown and Close are inherited from the [http.Server]. They are
+// idempotent and safe to call concurrently with each other and with
+// Serve and ServeAutoTLS. Shutdown closes listeners first, then waits
+// for active requests to complete; Close aborts them. Serve and
+// ServeAutoTLS return after their listeners are closed and, for
+// ServeAutoTLS, after both plain and encrypted serving goroutines
+// have returned.
 type Server struct {
-	http.Server                 // Underlying http.Server
-	ctx         context.Context // Server context
-	handler     http.Handler    // Request handler
+	http.Server                     // Underlying http.Server
+	ctx         context.Context     // Server context
+	handler     http.Handler        // Request handler
+	listeners   []net.Listener      // Listeners being served
+	autoTLSHook AutoTLSClassifyHook // Hook for ServeAutoTLS
+	activity    time.Duration       // Activity window for ServeAutoTLS
+	lock        sync.Mutex          // Access lock
 }
 
 // NewServer creates a new [Server].
@@ -53,14 +66,27 @@ func NewServer(ctx context.Context,
 			BaseContext:                  template.BaseContext,
 			ConnContext:                  template.ConnContext,
 		},
-		ctx:     ctx,
-		handler: handler,
+		ctx:      ctx,
+		handler:  handler,
+		activity: AutoTLSActivityTimeout,
 	}
 
 	srvr.Server.BaseContext = func(net.Listener) context.Context {
 		return srvr.ctx
 	}
 
+	// Connection contexts of the ServeAutoTLS connections are
+	// canceled when connection is closed due to inactivity.
+	connContext := template.ConnContext
+	srvr.Server.ConnContext = func(ctx context.Context,
+		c net.Conn) context.Context {
+		ctx, c = framingConnContext(ctx, c)
+		if connContext != nil {
+			ctx = connContext(ctx, c)
+		}
+		return activityConnContext(ctx, c)
+	}
+
 	srvr.Handler = http.HandlerFunc(srvr.handlerFunc)
 
 	return srvr
@@ -69,17 +95,78 @@ func NewServer(ctx context.Context,
 // handlerFunc wraps the http.Server.Handler.
 func (srvr *Server) handlerFunc(w http.ResponseWriter, r *http.Request) {
 	// Catch panics to log
-	defer func() {
-		v := recover()
-		if v != nil {
-			log.Panic(srvr.ctx, v)
-		}
-	}()
+	defer log.Recover(srvr.ctx, nil)
+
+	// Sanitize the request. Ambiguous framing is detected at
+	// the connection level, as net/http hides it from the handler.
+	err := HTTPSanitizeRequest(r)
+	if framingAmbiguous(r.Context()) {
+		err = ErrHTTPFraming
+	}
+
+	if err != nil {
+		log.Debug(srvr.ctx, "%s %s: %s", r.Method, r.URL, err)
+		w.Header().Set("Connection", "close")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Call the handler
 	srvr.handler.ServeHTTP(w, r)
 }
 
+// Serve accepts incoming connections on the [net.Listener] l
+// and serves them, as [http.Server.Serve] does.
+//
+// Listeners being served are tracked for the [Server.Handoff].
+func (srvr *Server) Serve(l net.Listener) error {
+	srvr.addListener(l)
+	defer srvr.delListener(l)
+
+	return srvr.Server.Serve(framingListener{l})
+}
+
+// addListener adds listener to the set of served listeners.
+func (srvr *Server) addListener(l net.Listener) {
+	srvr.lock.Lock()
+	srvr.listeners = append(srvr.listeners, l)
+	srvr.lock.Unlock()
+}
+
+// delListener removes listener from the set of served listeners.
+func (srvr *Server) delListener(l net.Listener) {
+	srvr.lock.Lock()
+	for i := range srvr.listeners {
+		if srvr.listeners[i] == l {
+			copy(srvr.listeners[i:], srvr.listeners[i+1:])
+			srvr.listeners = srvr.listeners[:len(srvr.listeners)-1]
+			break
+		}
+	}
+	srvr.lock.Unlock()
+}
+
+// SetAutoTLSClassifyHook sets the [AutoTLSClassifyHook] for
+// listeners, subsequently served by the [Server.ServeAutoTLS].
+func (srvr *Server) SetAutoTLSClassifyHook(hook AutoTLSClassifyHook) {
+	srvr.lock.Lock()
+	srvr.autoTLSHook = hook
+	srvr.lock.Unlock()
+}
+
+// SetAutoTLSActivityTimeout sets the activity window for
+// connections, subsequently accepted by the [Server.ServeAutoTLS].
+//
+// Connection that has no successful Read or Write within this window
+// is closed, and requests, currently being served on this connection,
+// are canceled. Zero disables activity deadlines. The default is
+// [AutoTLSActivityTimeout].
+func (srvr *Server) SetAutoTLSActivityTimeout(timeout time.Duration) {
+	srvr.lock.Lock()
+	srvr.activity = timeout
+	srvr.lock.Unlock()
+}
+
 // ServeAutoTLS is similar to the [http.Server.Serve] and
 // [http.Server.ServeTLS].
 //
@@ -91,26 +178,54 @@ func (srvr *Server) handlerFunc(w http.ResponseWriter, r *http.Request) {
 // of error. Use Server.Shutdown or Server.Close to force
 // this function to exit.
 func (srvr *Server) ServeAutoTLS(l net.Listener) error {
+	srvr.addListener(l)
+	defer srvr.delListener(l)
+
 	plain, encrypted := NewAutoTLSListener(l)
 
+	srvr.lock.Lock()
+	if srvr.autoTLSHook != nil {
+		plain.SetClassifyHook(srvr.autoTLSHook)
+	}
+	plain.SetActivityTimeout(srvr.activity)
+	srvr.lock.Unlock()
+
 	errchan := make(chan error, 2)
 	var done sync.WaitGroup
 
-	done.Add(2)
+	// http.Server configures HTTP/2 only once, by the first of
+	// Serve and ServeTLS. If Serve comes first, HTTP/2 remains
+	// unconfigured, but ServeTLS still offers "h2" to clients,
+	// and connections of clients that accept it are dropped.
+	//
+	// So plain connections are served only after ServeTLS has
+	// completed its setup and started accepting connections.
+	started := &serverStartedListener{
+		Listener: encrypted,
+		started:  make(chan struct{}),
+	}
 
+	done.Add(1)
 	go func() {
-		err := srvr.Serve(plain)
+		err := srvr.Server.ServeTLS(started, "", "")
 		errchan <- err
 		done.Done()
 	}()
 
-	go func() {
-		err := srvr.ServeTLS(encrypted, "", "")
-		errchan <- err
-		done.Done()
-	}()
+	var err error
+	select {
+	case <-started.started:
+		done.Add(1)
+		go func() {
+			err := srvr.Server.Serve(framingListener{plain})
+			errchan <- err
+			done.Done()
+		}()
 
-	err := <-errchan
+		err = <-errchan
+
+	case err = <-errchan:
+	}
 
 	plain.Close()
 	encrypted.Close()
@@ -119,3 +234,17 @@ func (srvr *Server) ServeAutoTLS(l net.Listener) error {
 
 	return err
 }
+
+// serverStartedListener wraps net.Listener and closes the started
+// channel, when Accept is called for the first time.
+type serverStartedListener struct {
+	net.Listener               // Underlying listener
+	started      chan struct{} // Closed on first Accept
+	once         sync.Once     // Closes started once
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (l *serverStartedListener) Accept() (net.Conn, error) {
+	l.once.Do(func() { close(l.started) })
+	return l.Listener.Accept()
+}
@@ -0,0 +1,19 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Fake devices for integration tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Package documentation
+
+// Package testutil provides fake printer and scanner HTTP servers
+// for integration tests of the protocol clients.
+//
+// Fakes are built on the [transport.Server], served over the loopback
+// TCP connection, optionally with TLS or with automatic TLS detection
+// (see [Options]). Each fake records received requests into the
+// [RequestLog] for later assertions, and allows to inject failures
+// via the [Hook].
+//
+// All fakes are safe for concurrent use.
+package testutil
@@ -0,0 +1,176 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Fake devices for integration tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Fake eSCL scanner
+
+package testutil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeESCLBasePath is the base path of the [FakeESCLScanner] resources.
+const FakeESCLBasePath = "/eSCL"
+
+// fakeESCLStatus is the default ScannerStatus of the FakeESCLScanner
+const fakeESCLStatus = `<?xml version="1.0" encoding="UTF-8"?>
+<scan:ScannerStatus xmlns:scan="http://schemas.hp.com/imaging/escl/2011/05/03" xmlns:pwg="http://www.pwg.org/schemas/2010/12/sm">
+  <pwg:Version>2.0</pwg:Version>
+  <pwg:State>Idle</pwg:State>
+</scan:ScannerStatus>
+`
+
+// FakePage is the scanned page, returned by the [FakeESCLScanner].
+type FakePage struct {
+	ContentType string // Content-Type; "image/jpeg" if empty
+	Data        []byte // Page image
+}
+
+// FakeESCLScanner is the fake eSCL scanner.
+//
+// It serves the following resources under the [FakeESCLBasePath]:
+//   - GET ScannerCapabilities: the configured capabilities
+//   - GET ScannerStatus: the configured status
+//   - POST ScanJobs: creates a new job, "201 Created"
+//   - GET ScanJobs/N/NextDocument: next scripted page of the
+//     job, or "404 Not Found" when pages are exhausted
+//   - DELETE ScanJobs/N: deletes the job
+//
+// ScanSettings of the created jobs can be obtained from the
+// [RequestLog].
+//
+// Scanner doesn't interpret XML documents, so it doesn't depend
+// on the eSCL protocol implementation.
+type FakeESCLScanner struct {
+	*Server
+	caps   []byte                // ScannerCapabilities
+	status []byte                // ScannerStatus
+	pages  []FakePage            // Pages for new jobs
+	jobs   map[string][]FakePage // Pending pages by job ID
+	lastID int                   // Last job ID
+	lock   sync.Mutex            // Access lock
+}
+
+// NewFakeESCLScanner creates a new [FakeESCLScanner] with the
+// specified ScannerCapabilities XML document and starts it.
+func NewFakeESCLScanner(caps []byte, opts Options) *FakeESCLScanner {
+	scanner := &FakeESCLScanner{
+		caps:   caps,
+		status: []byte(fakeESCLStatus),
+		jobs:   make(map[string][]FakePage),
+	}
+
+	scanner.Server = NewServer(http.HandlerFunc(scanner.serveHTTP), opts)
+
+	return scanner
+}
+
+// BaseURL returns the eSCL base URL of the scanner.
+func (scanner *FakeESCLScanner) BaseURL() string {
+	return scanner.URL + FakeESCLBasePath
+}
+
+// SetStatus sets the ScannerStatus XML document.
+func (scanner *FakeESCLScanner) SetStatus(status []byte) {
+	scanner.lock.Lock()
+	scanner.status = status
+	scanner.lock.Unlock()
+}
+
+// SetPages scripts pages, returned by the subsequently created
+// jobs. Pages of the already created jobs are not affected.
+func (scanner *FakeESCLScanner) SetPages(pages ...FakePage) {
+	scanner.lock.Lock()
+	scanner.pages = pages
+	scanner.lock.Unlock()
+}
+
+// Jobs returns count of the pending (created and not deleted) jobs.
+func (scanner *FakeESCLScanner) Jobs() int {
+	scanner.lock.Lock()
+	defer scanner.lock.Unlock()
+
+	return len(scanner.jobs)
+}
+
+// serveHTTP serves HTTP requests.
+func (scanner *FakeESCLScanner) serveHTTP(w http.ResponseWriter,
+	rq *http.Request) {
+
+	path, ok := strings.CutPrefix(rq.URL.Path, FakeESCLBasePath+"/")
+	if !ok {
+		http.NotFound(w, rq)
+		return
+	}
+
+	scanner.lock.Lock()
+	defer scanner.lock.Unlock()
+
+	switch {
+	case rq.Method == "GET" && path == "ScannerCapabilities":
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write(scanner.caps)
+
+	case rq.Method == "GET" && path == "ScannerStatus":
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write(scanner.status)
+
+	case rq.Method == "POST" && path == "ScanJobs":
+		scanner.lastID++
+		id := strconv.Itoa(scanner.lastID)
+		scanner.jobs[id] = append([]FakePage(nil), scanner.pages...)
+
+		w.Header().Set("Location",
+			FakeESCLBasePath+"/ScanJobs/"+id)
+		w.WriteHeader(http.StatusCreated)
+
+	case strings.HasPrefix(path, "ScanJobs/"):
+		scanner.serveJob(w, rq, strings.TrimPrefix(path, "ScanJobs/"))
+
+	default:
+		http.NotFound(w, rq)
+	}
+}
+
+// serveJob serves requests to the job resources.
+// Called under the lock.
+func (scanner *FakeESCLScanner) serveJob(w http.ResponseWriter,
+	rq *http.Request, path string) {
+
+	id, resource, _ := strings.Cut(path, "/")
+	pages, found := scanner.jobs[id]
+
+	switch {
+	case !found:
+		http.NotFound(w, rq)
+
+	case rq.Method == "DELETE" && resource == "":
+		delete(scanner.jobs, id)
+
+	case rq.Method == "GET" && resource == "NextDocument":
+		if len(pages) == 0 {
+			http.NotFound(w, rq)
+			return
+		}
+
+		page := pages[0]
+		scanner.jobs[id] = pages[1:]
+
+		ct := page.ContentType
+		if ct == "" {
+			ct = "image/jpeg"
+		}
+
+		w.Header().Set("Content-Type", ct)
+		w.Write(page.Data)
+
+	default:
+		http.NotFound(w, rq)
+	}
+}
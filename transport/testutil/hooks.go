@@ -0,0 +1,100 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Fake devices for integration tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Failure injection hooks
+
+package testutil
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// Hook is the failure injection hook, called by the [Server] for
+// each request, after the request is recorded into the [RequestLog]
+// as entry, and before it is passed to the device.
+//
+// If Hook returns true, the request is considered handled by the
+// hook and the device doesn't see it.
+//
+// Hook may be called concurrently.
+type Hook func(w http.ResponseWriter, rq *http.Request, entry Request) bool
+
+// FailN returns the [Hook] that responds with the HTTP status to
+// the first n requests.
+//
+// The optional retryAfter values are sent as the Retry-After header
+// of the first len(retryAfter) failed responses, in order. Empty
+// values are skipped.
+func FailN(n, status int, retryAfter ...string) Hook {
+	var count int
+	var lock sync.Mutex
+
+	return func(w http.ResponseWriter, rq *http.Request, _ Request) bool {
+		lock.Lock()
+		i := count
+		if i < n {
+			count++
+		}
+		lock.Unlock()
+
+		if i >= n {
+			return false
+		}
+
+		if i < len(retryAfter) && retryAfter[i] != "" {
+			w.Header().Set("Retry-After", retryAfter[i])
+		}
+
+		w.WriteHeader(status)
+		return true
+	}
+}
+
+// DropN returns the [Hook] that aborts connection of the first
+// n requests without sending a response. Client sees it as the
+// connection reset.
+func DropN(n int) Hook {
+	var count int
+	var lock sync.Mutex
+
+	return func(w http.ResponseWriter, rq *http.Request, _ Request) bool {
+		lock.Lock()
+		drop := count < n
+		if drop {
+			count++
+		}
+		lock.Unlock()
+
+		if !drop {
+			return false
+		}
+
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err == nil {
+			transport.AbortConn(conn)
+		}
+
+		return true
+	}
+}
+
+// Match returns the [Hook] that calls hook only for requests,
+// accepted by the match function. Other requests are passed to the
+// device as is.
+//
+// For example, to fail only Print-Job requests:
+//
+//	Match(func(rq Request) bool {
+//		return rq.Op() == goipp.OpPrintJob
+//	}, FailN(1, http.StatusServiceUnavailable))
+func Match(match func(Request) bool, hook Hook) Hook {
+	return func(w http.ResponseWriter, rq *http.Request, entry Request) bool {
+		return match(entry) && hook(w, rq, entry)
+	}
+}
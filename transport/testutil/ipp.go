@@ -0,0 +1,211 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Fake devices for integration tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Fake IPP printer
+
+package testutil
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/goipp"
+)
+
+// FakeIPPPrinter is the fake IPP printer.
+//
+// By default, requests are served by the [ipp.Printer], created
+// from the supplied [ipp.PrinterAttributes]. Responses to the
+// particular operations may be scripted by [FakeIPPPrinter.Respond]
+// and [FakeIPPPrinter.RespondFunc], and non-IPP resources (i.e.,
+// PPD files) may be added by [FakeIPPPrinter.Handle].
+type FakeIPPPrinter struct {
+	*Server
+
+	// Attrs are the printer attributes. They may be modified
+	// by test before requests are sent.
+	Attrs *ipp.PrinterAttributes
+
+	// Printer is the underlying ipp.Printer, i.e., for
+	// the ipp.Printer.SetPrintBackend.
+	Printer *ipp.Printer
+
+	scripts   map[goipp.Op][]*goipp.Message // Scripted responses
+	funcs     map[goipp.Op]IPPResponseFunc  // Response functions
+	resources map[string]http.Handler       // Non-IPP resources
+	lock      sync.Mutex                    // Access lock
+}
+
+// IPPResponseFunc returns response to the IPP request.
+type IPPResponseFunc func(rq *goipp.Message) *goipp.Message
+
+// NewFakeIPPPrinter creates a new [FakeIPPPrinter] and starts it.
+// If attrs is nil, the empty attributes are used.
+func NewFakeIPPPrinter(attrs *ipp.PrinterAttributes,
+	opts Options) *FakeIPPPrinter {
+
+	if attrs == nil {
+		attrs = &ipp.PrinterAttributes{}
+	}
+
+	prn := &FakeIPPPrinter{
+		Attrs:     attrs,
+		Printer:   ipp.NewPrinter(attrs, ipp.PrinterOptions{}),
+		scripts:   make(map[goipp.Op][]*goipp.Message),
+		funcs:     make(map[goipp.Op]IPPResponseFunc),
+		resources: make(map[string]http.Handler),
+	}
+
+	prn.Server = NewServer(http.HandlerFunc(prn.serveHTTP), opts)
+
+	return prn
+}
+
+// Respond scripts responses to the requests with the specified
+// operation. Responses are consumed in order, one per request.
+// When scripted responses are exhausted, requests are served as
+// usual.
+//
+// Version and request ID are copied from the request, and the
+// attributes-charset and attributes-natural-language are added,
+// if not set in the response.
+func (prn *FakeIPPPrinter) Respond(op goipp.Op, responses ...*goipp.Message) {
+	prn.lock.Lock()
+	prn.scripts[op] = append(prn.scripts[op], responses...)
+	prn.lock.Unlock()
+}
+
+// RespondFunc installs the function that responds to requests
+// with the specified operation, when no scripted responses are
+// pending. Pass nil to remove the previously installed function.
+func (prn *FakeIPPPrinter) RespondFunc(op goipp.Op, f IPPResponseFunc) {
+	prn.lock.Lock()
+	if f != nil {
+		prn.funcs[op] = f
+	} else {
+		delete(prn.funcs, op)
+	}
+	prn.lock.Unlock()
+}
+
+// Handle registers the handler for the non-IPP resource with
+// the specified path, i.e., "/printers/NAME.ppd".
+func (prn *FakeIPPPrinter) Handle(path string, handler http.Handler) {
+	prn.lock.Lock()
+	prn.resources[path] = handler
+	prn.lock.Unlock()
+}
+
+// NewIPPResponse returns the new response message with the
+// specified status. Response attributes may be added to
+// the message by caller.
+func NewIPPResponse(status goipp.Status) *goipp.Message {
+	return goipp.NewResponse(0, status, 0)
+}
+
+// serveHTTP serves HTTP requests.
+func (prn *FakeIPPPrinter) serveHTTP(w http.ResponseWriter, rq *http.Request) {
+	prn.lock.Lock()
+	resource := prn.resources[rq.URL.Path]
+	prn.lock.Unlock()
+
+	ct, _, _ := mime.ParseMediaType(rq.Header.Get("Content-Type"))
+
+	switch {
+	case resource != nil:
+		resource.ServeHTTP(w, rq)
+		return
+
+	case rq.Method != "POST" || ct != goipp.ContentType:
+		http.NotFound(w, rq)
+		return
+	}
+
+	// Decode the request
+	data, err := io.ReadAll(rq.Body)
+	if err != nil {
+		return
+	}
+
+	var msg goipp.Message
+	err = msg.DecodeBytes(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Use scripted response, if any
+	rsp := prn.scripted(&msg)
+	if rsp == nil {
+		rq.Body = io.NopCloser(bytes.NewReader(data))
+		prn.Printer.ServeHTTP(w, rq)
+		return
+	}
+
+	ippEchoRequest(&msg, rsp)
+
+	w.Header().Set("Content-Type", goipp.ContentType)
+	rsp.Encode(w)
+}
+
+// scripted returns the scripted response to the request,
+// or nil if there is no scripted response.
+func (prn *FakeIPPPrinter) scripted(rq *goipp.Message) *goipp.Message {
+	op := goipp.Op(rq.Code)
+
+	prn.lock.Lock()
+	var rsp *goipp.Message
+	if script := prn.scripts[op]; len(script) != 0 {
+		rsp, prn.scripts[op] = script[0], script[1:]
+	}
+	f := prn.funcs[op]
+	prn.lock.Unlock()
+
+	switch {
+	case rsp != nil:
+		// Scripted message may be shared between requests
+		// by caller, so modify the copy.
+		clone := *rsp
+		clone.Operation = rsp.Operation.Clone()
+		return &clone
+
+	case f != nil:
+		return f(rq)
+	}
+
+	return nil
+}
+
+// ippEchoRequest copies request ID and version from the request into
+// the response and adds attributes-charset and
+// attributes-natural-language, if not set.
+func ippEchoRequest(rq, rsp *goipp.Message) {
+	if rsp.RequestID == 0 {
+		rsp.RequestID = rq.RequestID
+	}
+
+	if rsp.Version == 0 {
+		rsp.Version = rq.Version
+	}
+
+	if len(rsp.Operation) != 0 &&
+		rsp.Operation[0].Name == "attributes-charset" {
+		return
+	}
+
+	ops := goipp.Attributes{
+		goipp.MakeAttribute("attributes-charset",
+			goipp.TagCharset, goipp.String("utf-8")),
+		goipp.MakeAttribute("attributes-natural-language",
+			goipp.TagLanguage, goipp.String("en-US")),
+	}
+
+	rsp.Operation = append(ops, rsp.Operation...)
+}
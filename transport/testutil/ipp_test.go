@@ -0,0 +1,163 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Fake devices for integration tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Fake IPP printer test
+
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/proto/ipp"
+	"github.com/OpenPrinting/go-mfp/transport"
+	"github.com/OpenPrinting/go-mfp/util/optional"
+	"github.com/OpenPrinting/goipp"
+)
+
+// testIPPGetPrinterAttributes performs the Get-Printer-Attributes
+// request and returns the response message.
+func testIPPGetPrinterAttributes(t *testing.T, u string) *goipp.Message {
+	rq := goipp.NewRequest(goipp.DefaultVersion,
+		goipp.OpGetPrinterAttributes, 1)
+	rq.Operation.Add(goipp.MakeAttribute("attributes-charset",
+		goipp.TagCharset, goipp.String("utf-8")))
+	rq.Operation.Add(goipp.MakeAttribute("attributes-natural-language",
+		goipp.TagLanguage, goipp.String("en-US")))
+	rq.Operation.Add(goipp.MakeAttribute("printer-uri",
+		goipp.TagURI, goipp.String(u)))
+
+	data, _ := rq.EncodeBytes()
+	httpRq, err := transport.NewRequest(context.Background(), "POST",
+		transport.MustParseURL(u), bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	httpRq.Header.Set("Content-Type", goipp.ContentType)
+
+	clnt := &http.Client{Transport: transport.NewTransport(nil)}
+	httpRsp, err := clnt.Do(httpRq)
+	if err != nil {
+		t.Fatalf("Get-Printer-Attributes: %s", err)
+	}
+
+	defer httpRsp.Body.Close()
+
+	rsp := &goipp.Message{}
+	err = rsp.Decode(httpRsp.Body)
+	if err != nil {
+		t.Fatalf("Get-Printer-Attributes: %s", err)
+	}
+
+	if rsp.RequestID != rq.RequestID {
+		t.Errorf("Get-Printer-Attributes: request ID mismatch")
+	}
+
+	return rsp
+}
+
+// testIPPPrinterName returns printer-name from the response
+func testIPPPrinterName(rsp *goipp.Message) string {
+	for _, attr := range rsp.Printer {
+		if attr.Name == "printer-name" {
+			return attr.Values.String()
+		}
+	}
+
+	return ""
+}
+
+// TestFakeIPPPrinter tests the FakeIPPPrinter
+func TestFakeIPPPrinter(t *testing.T) {
+	attrs := &ipp.PrinterAttributes{}
+	attrs.PrinterName = optional.New("fake")
+
+	for _, opts := range []Options{{}, {TLS: true}} {
+		prn := NewFakeIPPPrinter(attrs, opts)
+
+		// Scripted responses are consumed in order, then
+		// requests are served by the ipp.Printer.
+		prn.Respond(goipp.OpGetPrinterAttributes,
+			NewIPPResponse(goipp.StatusErrorBusy),
+			NewIPPResponse(goipp.StatusErrorServiceUnavailable))
+
+		expected := []goipp.Status{
+			goipp.StatusErrorBusy,
+			goipp.StatusErrorServiceUnavailable,
+			goipp.StatusOk,
+		}
+
+		for i, status := range expected {
+			rsp := testIPPGetPrinterAttributes(t, prn.URL)
+			if goipp.Status(rsp.Code) != status {
+				t.Errorf("%+v: response %d: expected %s, present %s",
+					opts, i, status, goipp.Status(rsp.Code))
+			}
+		}
+
+		rsp := testIPPGetPrinterAttributes(t, prn.URL)
+		if testIPPPrinterName(rsp) != "fake" {
+			t.Errorf("%+v: printer attributes not served", opts)
+		}
+
+		// RespondFunc
+		prn.RespondFunc(goipp.OpGetPrinterAttributes,
+			func(rq *goipp.Message) *goipp.Message {
+				return NewIPPResponse(goipp.StatusErrorNotFound)
+			})
+
+		rsp = testIPPGetPrinterAttributes(t, prn.URL)
+		if goipp.Status(rsp.Code) != goipp.StatusErrorNotFound {
+			t.Errorf("%+v: RespondFunc: present %s",
+				opts, goipp.Status(rsp.Code))
+		}
+
+		prn.RespondFunc(goipp.OpGetPrinterAttributes, nil)
+		rsp = testIPPGetPrinterAttributes(t, prn.URL)
+		if goipp.Status(rsp.Code) != goipp.StatusOk {
+			t.Errorf("%+v: RespondFunc(nil): present %s",
+				opts, goipp.Status(rsp.Code))
+		}
+
+		// Non-IPP resources
+		prn.Handle("/printers/fake.ppd", http.HandlerFunc(
+			func(w http.ResponseWriter, rq *http.Request) {
+				w.Write([]byte("*PPD-Adobe: \"4.3\"\n"))
+			}))
+
+		status, _, _ := testServerGet(prn.URL + "/printers/fake.ppd")
+		if status != http.StatusOK {
+			t.Errorf("%+v: Handle: HTTP %d", opts, status)
+		}
+
+		status, _, _ = testServerGet(prn.URL + "/printers/missed.ppd")
+		if status != http.StatusNotFound {
+			t.Errorf("%+v: missed resource: HTTP %d", opts, status)
+		}
+
+		// Requests are recorded in order
+		prn.Log().Expect(t,
+			"Get-Printer-Attributes",
+			"Get-Printer-Attributes",
+			"Get-Printer-Attributes",
+			"Get-Printer-Attributes",
+			"Get-Printer-Attributes",
+			"Get-Printer-Attributes",
+			"GET /printers/fake.ppd",
+			"GET /printers/missed.ppd")
+
+		rq := prn.Log().ByOp(goipp.OpGetPrinterAttributes)[0]
+		if len(rq.IPP.Operation) != 3 {
+			t.Errorf("%+v: IPP request not recorded: %v",
+				opts, rq.IPP.Operation)
+		}
+
+		prn.Close()
+	}
+}
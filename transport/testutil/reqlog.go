@@ -0,0 +1,206 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Fake devices for integration tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Log of received requests
+
+package testutil
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/OpenPrinting/goipp"
+)
+
+// Request is the request, recorded by the [RequestLog].
+type Request struct {
+	Seq    int            // Sequence number, starting from 1
+	Method string         // HTTP method
+	Path   string         // URL path
+	Header http.Header    // HTTP request header
+	Body   []byte         // Request body
+	IPP    *goipp.Message // Decoded IPP message, nil if not IPP
+}
+
+// String returns the short description of the Request, used
+// for the [RequestLog] assertions:
+//   - for IPP requests, it is the operation name, i.e.,
+//     "Get-Printer-Attributes"
+//   - for other requests, it is the method and path, i.e.,
+//     "GET /eSCL/ScannerCapabilities"
+func (rq Request) String() string {
+	if rq.IPP != nil {
+		return goipp.Op(rq.IPP.Code).String()
+	}
+
+	return rq.Method + " " + rq.Path
+}
+
+// Op returns the IPP operation of the request, or 0 if
+// request is not IPP.
+func (rq Request) Op() goipp.Op {
+	if rq.IPP != nil {
+		return goipp.Op(rq.IPP.Code)
+	}
+
+	return 0
+}
+
+// RequestLog records requests, received by the fake device, in
+// the order of their arrival.
+//
+// RequestLog is safe for concurrent use.
+type RequestLog struct {
+	requests []Request // Recorded requests
+	seq      int       // Last sequence number
+	lock     sync.Mutex
+}
+
+// add records the request and returns the recorded entry.
+func (log *RequestLog) add(rq *http.Request, body []byte) Request {
+	entry := Request{
+		Method: rq.Method,
+		Path:   rq.URL.Path,
+		Header: rq.Header.Clone(),
+		Body:   body,
+	}
+
+	ct, _, _ := mime.ParseMediaType(rq.Header.Get("Content-Type"))
+	if ct == goipp.ContentType {
+		msg := &goipp.Message{}
+		if msg.DecodeBytes(body) == nil {
+			entry.IPP = msg
+		}
+	}
+
+	log.lock.Lock()
+	log.seq++
+	entry.Seq = log.seq
+	log.requests = append(log.requests, entry)
+	log.lock.Unlock()
+
+	return entry
+}
+
+// Requests returns copy of the recorded requests.
+func (log *RequestLog) Requests() []Request {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+
+	return append([]Request(nil), log.requests...)
+}
+
+// Len returns count of the recorded requests.
+func (log *RequestLog) Len() int {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+
+	return len(log.requests)
+}
+
+// Reset purges the recorded requests. Sequence numbering
+// continues.
+func (log *RequestLog) Reset() {
+	log.lock.Lock()
+	log.requests = nil
+	log.lock.Unlock()
+}
+
+// Strings returns [Request.String] of all recorded requests.
+func (log *RequestLog) Strings() []string {
+	requests := log.Requests()
+	s := make([]string, len(requests))
+	for i, rq := range requests {
+		s[i] = rq.String()
+	}
+
+	return s
+}
+
+// Filter returns the recorded requests, accepted by the
+// match function.
+func (log *RequestLog) Filter(match func(Request) bool) []Request {
+	var filtered []Request
+	for _, rq := range log.Requests() {
+		if match(rq) {
+			filtered = append(filtered, rq)
+		}
+	}
+
+	return filtered
+}
+
+// ByOp returns the recorded IPP requests with the specified
+// operation.
+func (log *RequestLog) ByOp(op goipp.Op) []Request {
+	return log.Filter(func(rq Request) bool {
+		return rq.Op() == op
+	})
+}
+
+// Expect asserts that recorded requests exactly match the expected
+// sequence, compared by their [Request.String].
+//
+// It returns true if assertion succeeded.
+func (log *RequestLog) Expect(t testing.TB, expected ...string) bool {
+	t.Helper()
+
+	present := log.Strings()
+	if !stringsEqual(present, expected) {
+		t.Errorf("requests mismatch:\n"+
+			"expected: %s\npresent:  %s",
+			strings.Join(expected, ", "), strings.Join(present, ", "))
+		return false
+	}
+
+	return true
+}
+
+// ExpectOrder asserts that the expected requests were received
+// in the specified order, possibly interleaved with other requests.
+//
+// Use it when some requests are sent concurrently, so their exact
+// order is not deterministic.
+//
+// It returns true if assertion succeeded.
+func (log *RequestLog) ExpectOrder(t testing.TB, expected ...string) bool {
+	t.Helper()
+
+	present := log.Strings()
+	next := 0
+	for _, s := range present {
+		if next < len(expected) && s == expected[next] {
+			next++
+		}
+	}
+
+	if next != len(expected) {
+		t.Errorf("request %q not received in order:\n"+
+			"expected: %s\npresent:  %s", expected[next],
+			strings.Join(expected, ", "), strings.Join(present, ", "))
+		return false
+	}
+
+	return true
+}
+
+// stringsEqual reports whether two slices of strings are equal.
+func stringsEqual(s1, s2 []string) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return false
+		}
+	}
+
+	return true
+}
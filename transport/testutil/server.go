@@ -0,0 +1,205 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Fake devices for integration tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Fake device HTTP server
+
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// Options configures the fake device server.
+type Options struct {
+	// TLS, if set, makes server to serve HTTPS instead of HTTP.
+	TLS bool
+
+	// AutoTLS, if set, makes server to serve both HTTP and HTTPS
+	// on the same port (see [transport.Server.ServeAutoTLS]).
+	// It takes precedence over TLS.
+	AutoTLS bool
+}
+
+// Server is the HTTP server of the fake device.
+//
+// It records all received requests into the [RequestLog], then
+// calls the failure injection [Hook], if any, and then passes
+// the request to the device handler.
+//
+// Server uses self-signed certificate for TLS, so clients must
+// not verify it. The default [transport.Transport] doesn't.
+type Server struct {
+	// URL is the base URL of the server, "http://127.0.0.1:port",
+	// or "https://127.0.0.1:port", if Options.TLS is set.
+	URL string
+
+	// TLSURL is the "https://127.0.0.1:port" URL of the same
+	// port, when Options.AutoTLS is set. Otherwise, it is "".
+	TLSURL string
+
+	srvr   *transport.Server // Underlying transport.Server
+	device http.Handler      // The device handler
+	log    RequestLog        // Received requests
+	hook   Hook              // Failure injection hook
+	done   sync.WaitGroup    // Wait for serving goroutine
+	lock   sync.Mutex        // Access lock
+}
+
+// NewServer creates a new [Server], serving requests by the
+// device handler, and starts it on the loopback interface.
+//
+// Fakes like [FakeIPPPrinter] and [FakeESCLScanner] embed the
+// Server; use NewServer directly for devices, not covered by
+// this package.
+func NewServer(device http.Handler, opts Options) *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	srv := &Server{device: device}
+	srv.srvr = transport.NewServer(context.Background(), nil,
+		http.HandlerFunc(srv.serveHTTP))
+
+	addr := l.Addr().String()
+
+	var serve func(net.Listener) error
+	switch {
+	case opts.AutoTLS:
+		srv.srvr.TLSConfig = serverTLSConfig()
+		srv.URL = "http://" + addr
+		srv.TLSURL = "https://" + addr
+		serve = srv.srvr.ServeAutoTLS
+
+	case opts.TLS:
+		srv.srvr.TLSConfig = serverTLSConfig()
+		srv.URL = "https://" + addr
+		serve = func(l net.Listener) error {
+			return srv.srvr.ServeTLS(l, "", "")
+		}
+
+	default:
+		srv.URL = "http://" + addr
+		serve = srv.srvr.Serve
+	}
+
+	srv.done.Add(1)
+	go func() {
+		serve(l)
+		srv.done.Done()
+	}()
+
+	return srv
+}
+
+// Close closes the Server and all its active connections.
+func (srv *Server) Close() {
+	srv.srvr.Close()
+	srv.done.Wait()
+}
+
+// Log returns the [RequestLog] of the Server.
+func (srv *Server) Log() *RequestLog {
+	return &srv.log
+}
+
+// SetHook sets the failure injection [Hook].
+// Pass nil to remove the previously set hook.
+func (srv *Server) SetHook(hook Hook) {
+	srv.lock.Lock()
+	srv.hook = hook
+	srv.lock.Unlock()
+}
+
+// serveHTTP serves the incoming HTTP request.
+func (srv *Server) serveHTTP(w http.ResponseWriter, rq *http.Request) {
+	// Record the request. The body is consumed here, so
+	// the device receives its copy.
+	body, err := io.ReadAll(rq.Body)
+	if err != nil {
+		// Request aborted by client
+		return
+	}
+
+	rq.Body = io.NopCloser(bytes.NewReader(body))
+	entry := srv.log.add(rq, body)
+
+	// Call the hook
+	srv.lock.Lock()
+	hook := srv.hook
+	srv.lock.Unlock()
+
+	if hook != nil && hook(w, rq, entry) {
+		return
+	}
+
+	srv.device.ServeHTTP(w, rq)
+}
+
+// serverCert is the self-signed certificate, shared by all servers.
+var (
+	serverCert     tls.Certificate
+	serverCertOnce sync.Once
+)
+
+// serverTLSConfig returns the server's [tls.Config].
+func serverTLSConfig() *tls.Config {
+	serverCertOnce.Do(func() {
+		serverCert = serverCertGenerate()
+	})
+
+	return &tls.Config{Certificates: []tls.Certificate{serverCert}}
+}
+
+// serverCertGenerate generates the self-signed certificate
+// for 127.0.0.1 and localhost.
+func serverCertGenerate() tls.Certificate {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	notBefore := time.Now().Add(-time.Hour)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Organization: []string{"Fake Device"},
+		},
+
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(24 * 365 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader,
+		&template, &template, pub, priv)
+	if err != nil {
+		panic(err)
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  priv,
+	}
+}
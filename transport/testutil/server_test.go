@@ -0,0 +1,212 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// Fake devices for integration tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Fake device HTTP server test
+
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/transport"
+)
+
+// testServerGet performs the GET request to the server
+func testServerGet(u string) (int, string, error) {
+	rq, err := transport.NewRequest(context.Background(), "GET",
+		transport.MustParseURL(u), nil)
+	if err != nil {
+		return 0, "", err
+	}
+
+	clnt := &http.Client{Transport: transport.NewTransport(nil)}
+	rsp, err := clnt.Do(rq)
+	if err != nil {
+		return 0, "", err
+	}
+
+	defer rsp.Body.Close()
+	data, err := io.ReadAll(rsp.Body)
+
+	return rsp.StatusCode, string(data), err
+}
+
+// testServerEcho is the device handler that responds with
+// the request path
+var testServerEcho = http.HandlerFunc(
+	func(w http.ResponseWriter, rq *http.Request) {
+		io.WriteString(w, rq.URL.Path)
+	})
+
+// TestServerModes tests Server with HTTP, HTTPS and AutoTLS
+func TestServerModes(t *testing.T) {
+	type testData struct {
+		opts Options
+		urls func(srv *Server) []string
+	}
+
+	tests := []testData{
+		{
+			opts: Options{},
+			urls: func(srv *Server) []string {
+				return []string{srv.URL}
+			},
+		},
+		{
+			opts: Options{TLS: true},
+			urls: func(srv *Server) []string {
+				return []string{srv.URL}
+			},
+		},
+		{
+			opts: Options{AutoTLS: true},
+			urls: func(srv *Server) []string {
+				return []string{srv.URL, srv.TLSURL}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		srv := NewServer(testServerEcho, test.opts)
+
+		for _, u := range test.urls(srv) {
+			status, body, err := testServerGet(u + "/path")
+			if err != nil {
+				t.Errorf("%s: %s", u, err)
+				continue
+			}
+
+			if status != http.StatusOK || body != "/path" {
+				t.Errorf("%s: unexpected response: %d %q",
+					u, status, body)
+			}
+		}
+
+		srv.Close()
+	}
+}
+
+// TestServerHooks tests failure injection hooks
+func TestServerHooks(t *testing.T) {
+	srv := NewServer(testServerEcho, Options{})
+	defer srv.Close()
+
+	// FailN
+	srv.SetHook(FailN(2, http.StatusServiceUnavailable, "5"))
+
+	expected := []int{http.StatusServiceUnavailable,
+		http.StatusServiceUnavailable, http.StatusOK}
+
+	for i, exp := range expected {
+		status, _, err := testServerGet(srv.URL + "/fail")
+		if err != nil || status != exp {
+			t.Errorf("FailN: request %d: expected %d, present %d %v",
+				i, exp, status, err)
+		}
+	}
+
+	// DropN
+	srv.SetHook(DropN(1))
+	_, _, err := testServerGet(srv.URL + "/drop")
+	if err == nil {
+		t.Errorf("DropN: error expected")
+	}
+
+	status, _, err := testServerGet(srv.URL + "/drop")
+	if err != nil || status != http.StatusOK {
+		t.Errorf("DropN: request not passed: %d %v", status, err)
+	}
+
+	// Match
+	srv.SetHook(Match(func(rq Request) bool {
+		return rq.Path == "/match"
+	}, FailN(100, http.StatusNotFound)))
+
+	for _, path := range []string{"/match", "/other"} {
+		status, _, _ := testServerGet(srv.URL + path)
+		exp := http.StatusOK
+		if path == "/match" {
+			exp = http.StatusNotFound
+		}
+
+		if status != exp {
+			t.Errorf("Match: %s: expected %d, present %d",
+				path, exp, status)
+		}
+	}
+
+	// Failed requests are recorded as well
+	srv.Log().Expect(t,
+		"GET /fail", "GET /fail", "GET /fail",
+		"GET /drop", "GET /drop",
+		"GET /match", "GET /other")
+}
+
+// TestServerConcurrent tests concurrent use of the Server and
+// the RequestLog
+func TestServerConcurrent(t *testing.T) {
+	srv := NewServer(testServerEcho, Options{})
+	defer srv.Close()
+
+	srv.SetHook(FailN(10, http.StatusServiceUnavailable))
+
+	const clients, perClient = 8, 20
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	failed := 0
+
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perClient; j++ {
+				u := fmt.Sprintf("%s/%d/%d", srv.URL, i, j)
+				status, _, _ := testServerGet(u)
+				if status == http.StatusServiceUnavailable {
+					lock.Lock()
+					failed++
+					lock.Unlock()
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	requests := srv.Log().Requests()
+	if len(requests) != clients*perClient {
+		t.Fatalf("%d requests recorded, expected %d",
+			len(requests), clients*perClient)
+	}
+
+	// Sequence numbers are assigned in order of arrival
+	for i, rq := range requests {
+		if rq.Seq != i+1 {
+			t.Fatalf("request %d: Seq %d", i, rq.Seq)
+		}
+	}
+
+	// Requests of each client are ordered
+	for i := 0; i < clients; i++ {
+		var expected []string
+		for j := 0; j < perClient; j++ {
+			expected = append(expected,
+				fmt.Sprintf("GET /%d/%d", i, j))
+		}
+		srv.Log().ExpectOrder(t, expected...)
+	}
+
+	// Hook failed exactly 10 requests
+	if failed != 10 {
+		t.Errorf("%d requests failed, expected 10", failed)
+	}
+}
@@ -0,0 +1,222 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// TLS policy presets
+
+package transport
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/OpenPrinting/go-mfp/log"
+)
+
+// TLSPolicy is the preset of the client-side TLS parameters:
+// minimum protocol version, cipher suites, curve preferences
+// and renegotiation support.
+//
+// Old printers often only speak TLS 1.0/1.1 with RSA key exchange,
+// which is disabled by default. TLSPolicyLegacyPrinter is the
+// explicit escape hatch for such printers, applied per client
+// (see [Transport.SetTLSPolicy]), without weakening security of
+// other connections.
+type TLSPolicy int
+
+// TLSPolicy values:
+const (
+	// TLSPolicyModern uses TLS 1.2+ with only ECDHE AEAD
+	// cipher suites and the Go default curves, and disables
+	// renegotiation. This is the default.
+	TLSPolicyModern TLSPolicy = iota
+
+	// TLSPolicyIntermediate uses TLS 1.2+ and additionally
+	// enables ECDHE CBC cipher suites, used by some printers,
+	// and allows single renegotiation, initiated by the server.
+	TLSPolicyIntermediate
+
+	// TLSPolicyLegacyPrinter enables TLS 1.0+ and the legacy
+	// cipher suites (RSA key exchange, CBC, 3DES), still shipped
+	// with Go. It prefers NIST curves and allows renegotiations,
+	// as some Broadcom-based printer stacks fail otherwise.
+	//
+	// Use of this policy is always logged, once per peer.
+	TLSPolicyLegacyPrinter
+)
+
+// tlsPolicyNames contains names of TLSPolicy values
+var tlsPolicyNames = map[TLSPolicy]string{
+	TLSPolicyModern:        "modern",
+	TLSPolicyIntermediate:  "intermediate",
+	TLSPolicyLegacyPrinter: "legacy-printer",
+}
+
+// tlsModernCipherSuites are the TLS 1.2 cipher suites, enabled
+// by the TLSPolicyModern. TLS 1.3 suites are not configurable.
+var tlsModernCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// tlsIntermediateCipherSuites are the additional cipher suites,
+// enabled by the TLSPolicyIntermediate.
+var tlsIntermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+}
+
+// tlsLegacyCipherSuites are the additional cipher suites,
+// enabled by the TLSPolicyLegacyPrinter.
+var tlsLegacyCipherSuites = []uint16{
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+	tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+}
+
+// String returns the TLSPolicy name.
+func (policy TLSPolicy) String() string {
+	if name, ok := tlsPolicyNames[policy]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown (%d)", int(policy))
+}
+
+// ParseTLSPolicy parses the [TLSPolicy] name, as returned
+// by the [TLSPolicy.String]. Parsing is case-insensitive.
+func ParseTLSPolicy(name string) (TLSPolicy, error) {
+	for policy, s := range tlsPolicyNames {
+		if strings.EqualFold(name, s) {
+			return policy, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%q: unknown TLS policy "+
+		"(known are: modern, intermediate, legacy-printer)", name)
+}
+
+// Apply returns a copy of the [tls.Config] with the TLSPolicy
+// parameters applied. Other fields of config are preserved.
+// The config may be nil.
+func (policy TLSPolicy) Apply(config *tls.Config) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+
+	switch policy {
+	case TLSPolicyIntermediate:
+		config.MinVersion = tls.VersionTLS12
+		config.CipherSuites = tlsCipherSuites(
+			tlsModernCipherSuites,
+			tlsIntermediateCipherSuites)
+		config.CurvePreferences = []tls.CurveID{
+			tls.X25519, tls.CurveP256, tls.CurveP384,
+		}
+		config.Renegotiation = tls.RenegotiateOnceAsClient
+
+	case TLSPolicyLegacyPrinter:
+		config.MinVersion = tls.VersionTLS10
+		config.CipherSuites = tlsCipherSuites(
+			tlsModernCipherSuites,
+			tlsIntermediateCipherSuites,
+			tlsLegacyCipherSuites)
+
+		// Some old stacks choke on X25519 key shares
+		config.CurvePreferences = []tls.CurveID{
+			tls.CurveP256, tls.CurveP384, tls.CurveP521,
+		}
+		config.Renegotiation = tls.RenegotiateFreelyAsClient
+
+		verify := config.VerifyConnection
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			tlsLegacyWarning(cs)
+			if verify != nil {
+				return verify(cs)
+			}
+			return nil
+		}
+
+	default:
+		config.MinVersion = tls.VersionTLS12
+		config.CipherSuites = tlsCipherSuites(tlsModernCipherSuites)
+		config.CurvePreferences = nil
+		config.Renegotiation = tls.RenegotiateNever
+	}
+
+	return config
+}
+
+// tlsCipherSuites returns concatenation of the cipher suites lists
+func tlsCipherSuites(lists ...[]uint16) []uint16 {
+	var suites []uint16
+	for _, list := range lists {
+		suites = append(suites, list...)
+	}
+	return suites
+}
+
+// SetTLSPolicy applies the [TLSPolicy] to the TLS configuration
+// of the Transport.
+//
+// It must be called before the Transport is used.
+func (tr *Transport) SetTLSPolicy(policy TLSPolicy) {
+	tr.TLSClientConfig = policy.Apply(tr.TLSClientConfig)
+}
+
+// tlsLegacyWarned contains peers, already warned about use
+// of the TLSPolicyLegacyPrinter.
+var tlsLegacyWarned struct {
+	peers map[string]struct{}
+	lock  sync.Mutex
+}
+
+// tlsLegacyWarningLog writes the TLSPolicyLegacyPrinter warning.
+// It is replaceable for testing.
+var tlsLegacyWarningLog = func(format string, v ...any) {
+	log.Warning(nil, format, v...)
+}
+
+// tlsLegacyWarning writes the one-time warning about use of
+// the TLSPolicyLegacyPrinter with the peer.
+func tlsLegacyWarning(cs tls.ConnectionState) {
+	peer := cs.ServerName
+	if peer == "" {
+		peer = "unknown peer"
+	}
+
+	tlsLegacyWarned.lock.Lock()
+	_, warned := tlsLegacyWarned.peers[peer]
+	if !warned {
+		if tlsLegacyWarned.peers == nil {
+			tlsLegacyWarned.peers = make(map[string]struct{})
+		}
+		tlsLegacyWarned.peers[peer] = struct{}{}
+	}
+	tlsLegacyWarned.lock.Unlock()
+
+	if !warned {
+		tlsLegacyWarningLog("TLS: %s: insecure %s policy in use "+
+			"(negotiated %s, %s)", peer, TLSPolicyLegacyPrinter,
+			tls.VersionName(cs.Version),
+			tls.CipherSuiteName(cs.CipherSuite))
+	}
+}
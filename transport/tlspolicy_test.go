@@ -0,0 +1,186 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// TLS policy presets tests
+
+package transport
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestTLSPolicyHandshake tests handshake with servers, that
+// emulate different generations of printers, using different
+// TLS policies.
+func TestTLSPolicyHandshake(t *testing.T) {
+	type testServer struct {
+		name   string      // Server name
+		config *tls.Config // Server TLS config
+	}
+
+	servers := []testServer{
+		{
+			name: "TLS 1.0, RSA key exchange",
+			config: &tls.Config{
+				MinVersion: tls.VersionTLS10,
+				MaxVersion: tls.VersionTLS10,
+				CipherSuites: []uint16{
+					tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+				},
+			},
+		},
+		{
+			name: "TLS 1.1, 3DES",
+			config: &tls.Config{
+				MinVersion: tls.VersionTLS11,
+				MaxVersion: tls.VersionTLS11,
+				CipherSuites: []uint16{
+					tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+				},
+			},
+		},
+		{
+			name: "TLS 1.2, RSA key exchange",
+			config: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				MaxVersion: tls.VersionTLS12,
+				CipherSuites: []uint16{
+					tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+				},
+			},
+		},
+		{
+			name: "TLS 1.2, ECDHE CBC, P-256 only",
+			config: &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				MaxVersion: tls.VersionTLS12,
+				CipherSuites: []uint16{
+					tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+				},
+				CurvePreferences: []tls.CurveID{tls.CurveP256},
+			},
+		},
+		{
+			name: "TLS 1.3",
+			config: &tls.Config{
+				MinVersion: tls.VersionTLS13,
+			},
+		},
+	}
+
+	// Expected results, per policy, per server
+	expected := map[TLSPolicy][]bool{
+		TLSPolicyModern:        {false, false, false, false, true},
+		TLSPolicyIntermediate:  {false, false, false, true, true},
+		TLSPolicyLegacyPrinter: {true, true, true, true, true},
+	}
+
+	// Catch warnings
+	var lock sync.Mutex
+	var warnings []string
+
+	savedLog := tlsLegacyWarningLog
+	defer func() { tlsLegacyWarningLog = savedLog }()
+
+	tlsLegacyWarned.lock.Lock()
+	tlsLegacyWarned.peers = nil
+	tlsLegacyWarned.lock.Unlock()
+
+	tlsLegacyWarningLog = func(format string, v ...any) {
+		lock.Lock()
+		warnings = append(warnings, fmt.Sprintf(format, v...))
+		lock.Unlock()
+	}
+
+	for i, srv := range servers {
+		s := httptest.NewUnstartedServer(http.HandlerFunc(
+			func(w http.ResponseWriter, rq *http.Request) {
+				w.Write([]byte("OK"))
+			}))
+		s.TLS = srv.config
+		s.Config.ErrorLog = stdlog.New(io.Discard, "", 0)
+		s.StartTLS()
+
+		for _, policy := range []TLSPolicy{
+			TLSPolicyModern,
+			TLSPolicyIntermediate,
+			TLSPolicyLegacyPrinter,
+		} {
+			tr := NewTransport(nil)
+			tr.SetTLSPolicy(policy)
+
+			clnt := NewClient(tr)
+			rq, _ := http.NewRequest("GET", s.URL, nil)
+			rsp, err := clnt.Do(rq)
+			if err == nil {
+				rsp.Body.Close()
+			}
+
+			tr.CloseIdleConnections()
+
+			if (err == nil) != expected[policy][i] {
+				t.Errorf("%s, %s policy: expected success=%v, "+
+					"present error: %v", srv.name, policy,
+					expected[policy][i], err)
+			}
+		}
+
+		s.Close()
+	}
+
+	// All servers listen on 127.0.0.1, so LegacyPrinter warning
+	// must be written exactly once
+	if len(warnings) != 1 {
+		t.Errorf("LegacyPrinter: expected 1 warning, present %d:\n%s",
+			len(warnings), strings.Join(warnings, "\n"))
+	} else if !strings.Contains(warnings[0], "127.0.0.1") {
+		t.Errorf("LegacyPrinter: warning must name the peer: %s",
+			warnings[0])
+	}
+}
+
+// TestTLSPolicyApply tests TLSPolicy.Apply
+func TestTLSPolicyApply(t *testing.T) {
+	base := &tls.Config{InsecureSkipVerify: true, ServerName: "test"}
+
+	for _, policy := range []TLSPolicy{
+		TLSPolicyModern,
+		TLSPolicyIntermediate,
+		TLSPolicyLegacyPrinter,
+	} {
+		config := policy.Apply(base)
+		if config == base {
+			t.Errorf("%s: config must be copied", policy)
+		}
+
+		if !config.InsecureSkipVerify || config.ServerName != "test" {
+			t.Errorf("%s: config fields not preserved", policy)
+		}
+
+		parsed, err := ParseTLSPolicy(strings.ToUpper(policy.String()))
+		if err != nil || parsed != policy {
+			t.Errorf("ParseTLSPolicy(%q): %v %v",
+				policy, parsed, err)
+		}
+	}
+
+	if base.MinVersion != 0 || base.VerifyConnection != nil {
+		t.Errorf("Apply must not modify the original config")
+	}
+
+	_, err := ParseTLSPolicy("insecure")
+	if err == nil {
+		t.Errorf("ParseTLSPolicy: error expected")
+	}
+}
@@ -0,0 +1,240 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Bearer token authentication
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/log"
+)
+
+// DefaultTokenRefreshMargin is the default value of the
+// Client.TokenRefreshMargin.
+const DefaultTokenRefreshMargin = 30 * time.Second
+
+// ErrNoToken is returned by the [Client], if [TokenSource] returns
+// the empty token without error.
+var ErrNoToken = errors.New("TokenSource: empty token")
+
+// TokenSource returns the bearer token for the [Client] requests
+// (i.e., the OAuth2 access token) and its expiration time.
+//
+// Zero expiry means that token doesn't expire by itself, and will
+// be refreshed only when server rejects it.
+//
+// TokenSource is called with the Context of the request that needs
+// a new token. It is never called concurrently by the same Client.
+// If that request is canceled, other requests, waiting for the same
+// token, don't fail with its error; instead, TokenSource is called
+// again with the Context of one of them.
+type TokenSource func(ctx context.Context) (token string,
+	expiry time.Time, err error)
+
+// tokenCache caches the bearer token, obtained from the TokenSource.
+//
+// Concurrent requests that need a new token share the single call
+// to the TokenSource.
+type tokenCache struct {
+	source  TokenSource   // Source of tokens
+	token   string        // Cached token, "" if none
+	expiry  time.Time     // Token expiration time
+	gen     uint64        // Token generation
+	pending chan struct{} // Closed when refresh is done, nil if none
+	err     error         // Error of the last refresh
+	lock    sync.Mutex    // Access lock
+}
+
+// SetTokenSource sets the [TokenSource] of the bearer tokens.
+// Pass nil to disable bearer token authentication.
+//
+// When TokenSource is set, each request, sent by the [Client.Do],
+// carries the "Authorization: Bearer" header with the token. Token
+// is cached until its expiration minus the Client.TokenRefreshMargin.
+//
+// If server responds with the "401 Unauthorized", token is refreshed
+// and request is retried once, if its body can be replayed (i.e.,
+// request has no body or has the http.Request.GetBody function).
+//
+// Requests with the Authorization header, explicitly set by caller,
+// are sent as is.
+//
+// Tokens never appear in the log messages.
+func (c *Client) SetTokenSource(source TokenSource) {
+	if source != nil {
+		c.tokens = &tokenCache{source: source}
+	} else {
+		c.tokens = nil
+	}
+}
+
+// sendWithToken sends the request, authenticated with the bearer
+// token, and retries it once on the "401 Unauthorized" response.
+func (c *Client) sendWithToken(rq *http.Request) (*http.Response, error) {
+	if rq.Header.Get("Authorization") != "" {
+		return c.Client.Do(rq)
+	}
+
+	ctx := rq.Context()
+	margin := c.TokenRefreshMargin
+	if margin == 0 {
+		margin = DefaultTokenRefreshMargin
+	}
+
+	// Send the request
+	token, gen, err := c.tokens.get(ctx, margin)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := c.Client.Do(tokenRequest(rq, token, rq.Body))
+	if err != nil || rsp.StatusCode != http.StatusUnauthorized {
+		return rsp, err
+	}
+
+	// Server has rejected the token. Drop it, so the next request
+	// will obtain a new one, and retry, if possible.
+	c.tokens.invalidate(gen)
+
+	var body io.ReadCloser
+	switch {
+	case rq.Body == nil || rq.Body == http.NoBody:
+		body = rq.Body
+	case rq.GetBody != nil:
+		body, err = rq.GetBody()
+		if err != nil {
+			return rsp, nil
+		}
+	default:
+		log.Debug(ctx, "HTTP-CLNT %s %s - token rejected, can't retry",
+			rq.Method, rq.URL)
+		return rsp, nil
+	}
+
+	token, _, err = c.tokens.get(ctx, margin)
+	if err != nil {
+		log.Debug(ctx, "HTTP-CLNT %s %s - token refresh: %s",
+			rq.Method, rq.URL, err)
+		if body != nil {
+			body.Close()
+		}
+		return rsp, nil
+	}
+
+	log.Debug(ctx, "HTTP-CLNT %s %s - token rejected, retrying",
+		rq.Method, rq.URL)
+
+	io.Copy(io.Discard, rsp.Body)
+	rsp.Body.Close()
+
+	return c.Client.Do(tokenRequest(rq, token, body))
+}
+
+// tokenRequest returns copy of the request with the bearer token
+// and the specified body.
+func tokenRequest(rq *http.Request, token string,
+	body io.ReadCloser) *http.Request {
+
+	rq2 := rq.Clone(rq.Context())
+	rq2.Body = body
+	rq2.Header.Set("Authorization", "Bearer "+token)
+	return rq2
+}
+
+// get returns the cached token and its generation. If token is
+// missed or expires within the margin, it is refreshed.
+func (tc *tokenCache) get(ctx context.Context, margin time.Duration) (
+	string, uint64, error) {
+
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	for {
+		if tc.token != "" && (tc.expiry.IsZero() ||
+			time.Now().Before(tc.expiry.Add(-margin))) {
+			return tc.token, tc.gen, nil
+		}
+
+		if pending := tc.pending; pending != nil {
+			// Wait for refresh, started by other request.
+			tc.lock.Unlock()
+
+			var err error
+			select {
+			case <-pending:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+
+			tc.lock.Lock()
+			if err != nil {
+				return "", 0, err
+			}
+		} else if err := tc.refresh(ctx); err != nil {
+			return "", 0, err
+		}
+
+		// Use the refreshed token, even if it expires within
+		// the margin. Retry, if it was already invalidated.
+		switch {
+		case tc.err != nil:
+			return "", 0, tc.err
+		case tc.token != "":
+			return tc.token, tc.gen, nil
+		}
+	}
+}
+
+// refresh obtains a new token from the TokenSource.
+// Called and returns under the lock, but releases lock while
+// TokenSource is running.
+//
+// If refresh fails due to cancellation of the ctx, the error
+// is returned to the caller, but not saved for the requests that
+// wait for this refresh, so they will retry with their own contexts.
+func (tc *tokenCache) refresh(ctx context.Context) error {
+	pending := make(chan struct{})
+	tc.pending = pending
+	tc.lock.Unlock()
+
+	token, expiry, err := tc.source(ctx)
+	if err == nil && token == "" {
+		err = ErrNoToken
+	}
+
+	tc.lock.Lock()
+	tc.token, tc.expiry, tc.err = "", time.Time{}, err
+	switch {
+	case err == nil:
+		tc.token, tc.expiry = token, expiry
+		tc.gen++
+	case ctx.Err() != nil:
+		tc.err = nil
+	}
+
+	tc.pending = nil
+	close(pending)
+
+	return err
+}
+
+// invalidate drops the token of the specified generation, so
+// the next get will refresh it. If token is already refreshed,
+// it does nothing.
+func (tc *tokenCache) invalidate(gen uint64) {
+	tc.lock.Lock()
+	if tc.gen == gen {
+		tc.token = ""
+	}
+	tc.lock.Unlock()
+}
@@ -0,0 +1,418 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Bearer token authentication test
+
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/log"
+)
+
+// testTokenServer is the fake token endpoint and the resource
+// server that validates tokens, issued by the endpoint.
+type testTokenServer struct {
+	ttl      time.Duration        // Lifetime of issued tokens
+	delay    time.Duration        // Delay of token issuing
+	tokens   map[string]time.Time // Valid tokens with expiry
+	issued   int                  // Count of issued tokens
+	accepted int                  // Count of accepted requests
+	rejected int                  // Count of rejected requests
+	bodies   []string             // Bodies of accepted requests
+	lock     sync.Mutex
+	tokenSrv *httptest.Server // Token endpoint
+	resSrv   *httptest.Server // Resource server
+}
+
+// newTestTokenServer creates a new testTokenServer.
+func newTestTokenServer(ttl, delay time.Duration) *testTokenServer {
+	srv := &testTokenServer{
+		ttl:    ttl,
+		delay:  delay,
+		tokens: make(map[string]time.Time),
+	}
+
+	srv.tokenSrv = httptest.NewServer(http.HandlerFunc(srv.serveToken))
+	srv.resSrv = httptest.NewServer(http.HandlerFunc(srv.serveResource))
+
+	return srv
+}
+
+// Close closes the testTokenServer.
+func (srv *testTokenServer) Close() {
+	srv.tokenSrv.Close()
+	srv.resSrv.Close()
+}
+
+// serveToken issues a new token. Response body is the token,
+// and the Expires header contains its expiration time.
+func (srv *testTokenServer) serveToken(w http.ResponseWriter,
+	rq *http.Request) {
+
+	time.Sleep(srv.delay)
+
+	srv.lock.Lock()
+	srv.issued++
+	token := fmt.Sprintf("secret-token-%d", srv.issued)
+	expiry := time.Now().Add(srv.ttl)
+	srv.tokens[token] = expiry
+	srv.lock.Unlock()
+
+	w.Header().Set("Expires", expiry.Format(time.RFC3339Nano))
+	w.Write([]byte(token))
+}
+
+// serveResource validates the bearer token.
+func (srv *testTokenServer) serveResource(w http.ResponseWriter,
+	rq *http.Request) {
+
+	body, _ := io.ReadAll(rq.Body)
+	token, _ := strings.CutPrefix(rq.Header.Get("Authorization"), "Bearer ")
+
+	srv.lock.Lock()
+	expiry, found := srv.tokens[token]
+	ok := found && time.Now().Before(expiry)
+	if ok {
+		srv.accepted++
+		srv.bodies = append(srv.bodies, string(body))
+	} else {
+		srv.rejected++
+	}
+	srv.lock.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+}
+
+// Revoke revokes all issued tokens.
+func (srv *testTokenServer) Revoke() {
+	srv.lock.Lock()
+	clear(srv.tokens)
+	srv.lock.Unlock()
+}
+
+// Counters returns count of issued tokens, accepted and rejected
+// requests.
+func (srv *testTokenServer) Counters() (issued, accepted, rejected int) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	return srv.issued, srv.accepted, srv.rejected
+}
+
+// Source is the TokenSource that fetches tokens from the endpoint.
+func (srv *testTokenServer) Source(ctx context.Context) (
+	string, time.Time, error) {
+
+	rsp, err := http.Get(srv.tokenSrv.URL)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	defer rsp.Body.Close()
+	token, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiry, err := time.Parse(time.RFC3339Nano, rsp.Header.Get("Expires"))
+	return string(token), expiry, err
+}
+
+// Do performs request to the resource server.
+func (srv *testTokenServer) Do(ctx context.Context, clnt *Client,
+	body string) (int, error) {
+
+	var rd io.Reader
+	method := "GET"
+	if body != "" {
+		method = "POST"
+		rd = strings.NewReader(body)
+	}
+
+	rq, err := http.NewRequestWithContext(ctx, method, srv.resSrv.URL, rd)
+	if err != nil {
+		return 0, err
+	}
+
+	rsp, err := clnt.Do(rq)
+	if err != nil {
+		return 0, err
+	}
+
+	io.Copy(io.Discard, rsp.Body)
+	rsp.Body.Close()
+
+	return rsp.StatusCode, nil
+}
+
+// TestTokenSource tests token caching and refresh on expiration.
+func TestTokenSource(t *testing.T) {
+	srv := newTestTokenServer(300*time.Millisecond, 0)
+	defer srv.Close()
+
+	clnt := NewClient(nil)
+	clnt.TokenRefreshMargin = 100 * time.Millisecond
+	clnt.SetTokenSource(srv.Source)
+
+	ctx := context.Background()
+
+	// Token must be cached
+	for i := 0; i < 5; i++ {
+		status, err := srv.Do(ctx, clnt, "")
+		if err != nil || status != http.StatusOK {
+			t.Fatalf("request %d: %d %v", i, status, err)
+		}
+	}
+
+	if issued, accepted, _ := srv.Counters(); issued != 1 || accepted != 5 {
+		t.Errorf("expected 1 token, 5 requests, present %d, %d",
+			issued, accepted)
+	}
+
+	// Token must be refreshed before expiration
+	time.Sleep(250 * time.Millisecond)
+
+	status, err := srv.Do(ctx, clnt, "")
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("after refresh: %d %v", status, err)
+	}
+
+	if issued, _, rejected := srv.Counters(); issued != 2 || rejected != 0 {
+		t.Errorf("expected 2 tokens, 0 rejects, present %d, %d",
+			issued, rejected)
+	}
+
+	// Errors of the TokenSource must be returned
+	errSource := errors.New("token endpoint is down")
+	clnt.SetTokenSource(func(context.Context) (string, time.Time, error) {
+		return "", time.Time{}, errSource
+	})
+
+	_, err = srv.Do(ctx, clnt, "")
+	if !errors.Is(err, errSource) {
+		t.Errorf("TokenSource error: expected %v, present %v",
+			errSource, err)
+	}
+}
+
+// TestTokenSourceConcurrent tests that concurrent requests share
+// the single token refresh.
+func TestTokenSourceConcurrent(t *testing.T) {
+	srv := newTestTokenServer(time.Hour, 100*time.Millisecond)
+	defer srv.Close()
+
+	clnt := NewClient(nil)
+	clnt.SetTokenSource(srv.Source)
+
+	ctx := context.Background()
+
+	run := func() {
+		const count = 20
+		var wait sync.WaitGroup
+
+		for i := 0; i < count; i++ {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+				status, err := srv.Do(ctx, clnt, "")
+				if err != nil || status != http.StatusOK {
+					t.Errorf("request: %d %v", status, err)
+				}
+			}()
+		}
+
+		wait.Wait()
+	}
+
+	// Initial token
+	run()
+	if issued, _, _ := srv.Counters(); issued != 1 {
+		t.Errorf("initial: expected 1 token, present %d", issued)
+	}
+
+	// Concurrent 401s must cause a single refresh
+	srv.Revoke()
+	run()
+
+	if issued, accepted, _ := srv.Counters(); issued != 2 || accepted != 40 {
+		t.Errorf("after revoke: expected 2 tokens, 40 requests, "+
+			"present %d, %d", issued, accepted)
+	}
+}
+
+// testTokenWaitCtx is the Context, that reports when request
+// starts waiting on it.
+type testTokenWaitCtx struct {
+	context.Context               // Underlying context
+	waiting         chan struct{} // Closed on the first Done call
+	once            sync.Once     // Closes waiting once
+}
+
+// Done returns the Done channel of the underlying context.
+func (ctx *testTokenWaitCtx) Done() <-chan struct{} {
+	ctx.once.Do(func() { close(ctx.waiting) })
+	return ctx.Context.Done()
+}
+
+// TestTokenSourceCanceled tests that cancellation of the request,
+// that refreshes the token, doesn't fail other requests, waiting
+// for the same refresh.
+func TestTokenSourceCanceled(t *testing.T) {
+	const secret = "secret-token"
+
+	// The first call hangs until its request is canceled
+	started := make(chan struct{})
+	calls := 0
+	source := func(ctx context.Context) (string, time.Time, error) {
+		calls++
+		if calls == 1 {
+			close(started)
+			<-ctx.Done()
+			return "", time.Time{}, ctx.Err()
+		}
+		return secret, time.Time{}, nil
+	}
+
+	tc := &tokenCache{source: source}
+
+	// Start the first request and wait until it calls TokenSource
+	ctx1, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errchan := make(chan error)
+	go func() {
+		_, _, err := tc.get(ctx1, 0)
+		errchan <- err
+	}()
+
+	<-started
+
+	// Start the second request and wait until it waits for
+	// the refresh, started by the first one
+	ctx2 := &testTokenWaitCtx{
+		Context: context.Background(),
+		waiting: make(chan struct{}),
+	}
+
+	type result struct {
+		token string
+		err   error
+	}
+
+	reschan := make(chan result)
+	go func() {
+		token, _, err := tc.get(ctx2, 0)
+		reschan <- result{token, err}
+	}()
+
+	<-ctx2.waiting
+
+	// Cancel the first request
+	cancel()
+
+	err := <-errchan
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled request: expected %v, present %v",
+			context.Canceled, err)
+	}
+
+	res := <-reschan
+	if res.err != nil || res.token != secret {
+		t.Errorf("waiting request: expected %q, present %q %v",
+			secret, res.token, res.err)
+	}
+
+	if calls != 2 {
+		t.Errorf("TokenSource: expected 2 calls, present %d", calls)
+	}
+}
+
+// TestTokenSourceRetry tests retry of the rejected requests.
+func TestTokenSourceRetry(t *testing.T) {
+	srv := newTestTokenServer(time.Hour, 0)
+	defer srv.Close()
+
+	clnt := NewClient(nil)
+	clnt.SetTokenSource(srv.Source)
+
+	ctx := context.Background()
+
+	_, err := srv.Do(ctx, clnt, "first")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// Request body must be replayed on retry
+	srv.Revoke()
+	status, err := srv.Do(ctx, clnt, "second")
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("retry: %d %v", status, err)
+	}
+
+	issued, accepted, rejected := srv.Counters()
+	if issued != 2 || accepted != 2 || rejected != 1 {
+		t.Errorf("expected 2 tokens, 2 accepted, 1 rejected, "+
+			"present %d, %d, %d", issued, accepted, rejected)
+	}
+
+	if s := strings.Join(srv.bodies, ","); s != "first,second" {
+		t.Errorf("bodies: expected %q, present %q", "first,second", s)
+	}
+
+	// Retry is performed only once
+	clnt.SetTokenSource(func(context.Context) (string, time.Time, error) {
+		return "invalid", time.Time{}, nil
+	})
+
+	status, err = srv.Do(ctx, clnt, "")
+	if err != nil || status != http.StatusUnauthorized {
+		t.Errorf("invalid token: expected 401, present %d %v",
+			status, err)
+	}
+
+	if _, _, rejected2 := srv.Counters(); rejected2 != rejected+2 {
+		t.Errorf("invalid token: expected %d rejects, present %d",
+			rejected+2, rejected2)
+	}
+}
+
+// TestTokenSourceRedaction tests that tokens don't appear in logs.
+func TestTokenSourceRedaction(t *testing.T) {
+	srv := newTestTokenServer(time.Hour, 0)
+	defer srv.Close()
+
+	buf := &bytes.Buffer{}
+	lgr := log.NewLogger(log.LevelAll, log.NewWriterBackend(buf))
+	ctx := log.NewContext(context.Background(), lgr)
+
+	clnt := NewClient(nil)
+	clnt.SetTokenSource(srv.Source)
+
+	srv.Do(ctx, clnt, "")
+	srv.Revoke()
+	srv.Do(ctx, clnt, "body")
+
+	if buf.Len() == 0 {
+		t.Fatalf("nothing logged")
+	}
+
+	if strings.Contains(buf.String(), "secret-token") {
+		t.Errorf("token leaked into log:\n%s", buf)
+	}
+}
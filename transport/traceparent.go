@@ -0,0 +1,144 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// W3C Trace Context (traceparent header)
+
+package transport
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/OpenPrinting/go-mfp/log"
+)
+
+// TraceparentHeader is the name of the W3C Trace Context HTTP header.
+const TraceparentHeader = "Traceparent"
+
+// traceparentLen is the length of the version 00 traceparent
+// header value:
+//
+//	version "-" trace-id "-" parent-id "-" trace-flags
+//	2       1   32       1   16        1   2
+const traceparentLen = 55
+
+// ErrTraceparent is returned when traceparent header is invalid
+var ErrTraceparent = errors.New("invalid traceparent")
+
+// TraceparentParse parses the W3C traceparent header value.
+//
+// As required by the W3C Trace Context specification, values with
+// the unknown future versions are accepted, if their beginning is
+// compatible with the version 00, and the all-zero IDs are rejected.
+func TraceparentParse(s string) (log.TraceContext, error) {
+	var tc log.TraceContext
+
+	// Check syntax
+	if len(s) < traceparentLen ||
+		s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return tc, fmt.Errorf("%w: %q", ErrTraceparent, s)
+	}
+
+	version, ok := traceparentHex(s[0:2])
+	switch {
+	case !ok || version[0] == 0xff:
+		return tc, fmt.Errorf("%w: bad version %q", ErrTraceparent, s)
+
+	case version[0] == 0 && len(s) != traceparentLen,
+		version[0] != 0 && len(s) > traceparentLen &&
+			s[traceparentLen] != '-':
+		return tc, fmt.Errorf("%w: %q", ErrTraceparent, s)
+	}
+
+	traceID, ok1 := traceparentHex(s[3:35])
+	spanID, ok2 := traceparentHex(s[36:52])
+	flags, ok3 := traceparentHex(s[53:55])
+	if !(ok1 && ok2 && ok3) {
+		return tc, fmt.Errorf("%w: %q", ErrTraceparent, s)
+	}
+
+	copy(tc.TraceID[:], traceID)
+	copy(tc.SpanID[:], spanID)
+	tc.Flags = flags[0]
+
+	if !tc.IsValid() {
+		return tc, fmt.Errorf("%w: zero ID: %q", ErrTraceparent, s)
+	}
+
+	return tc, nil
+}
+
+// TraceparentFormat formats the W3C traceparent header value.
+func TraceparentFormat(tc log.TraceContext) string {
+	return fmt.Sprintf("00-%s-%s-%2.2x", tc.TraceID, tc.SpanID, tc.Flags)
+}
+
+// traceparentHex decodes lower-case hex string.
+// Upper-case letters are not allowed by the specification.
+func traceparentHex(s string) ([]byte, bool) {
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'F' {
+			return nil, false
+		}
+	}
+
+	data, err := hex.DecodeString(s)
+	return data, err == nil
+}
+
+// traceparentRequest attaches the trace context to the outgoing
+// [http.Request] and sets its traceparent header.
+//
+// If request Context already has the [log.TraceContext], its child
+// span is used. Otherwise, the valid traceparent header of the
+// request is used as is. Otherwise, the new trace is started.
+//
+// It returns the shallow copy of the original request.
+func traceparentRequest(rq *http.Request) *http.Request {
+	ctx := rq.Context()
+
+	tc, found := log.CtxTraceContext(ctx)
+	if found {
+		tc = tc.Child()
+	} else {
+		hdr, err := TraceparentParse(rq.Header.Get(TraceparentHeader))
+		if err == nil {
+			return rq.WithContext(log.WithTraceContext(ctx, hdr))
+		}
+
+		tc = log.NewTraceContext()
+	}
+
+	rq = rq.WithContext(log.WithTraceContext(ctx, tc))
+
+	// Header is cloned, so the caller's request is not modified.
+	rq.Header = rq.Header.Clone()
+	if rq.Header == nil {
+		rq.Header = make(http.Header)
+	}
+	rq.Header.Set(TraceparentHeader, TraceparentFormat(tc))
+
+	return rq
+}
+
+// traceparentServerRequest attaches the trace context, received
+// with the incoming [http.Request], to its Context.
+//
+// The new span is created for the request processing, so the
+// outgoing requests, made on behalf of the incoming, will carry
+// the same trace ID and the child span IDs.
+//
+// If request has no valid traceparent header, it is returned as is.
+func traceparentServerRequest(rq *http.Request) *http.Request {
+	tc, err := TraceparentParse(rq.Header.Get(TraceparentHeader))
+	if err != nil {
+		return rq
+	}
+
+	return rq.WithContext(log.WithTraceContext(rq.Context(), tc.Child()))
+}
@@ -0,0 +1,211 @@
+// MFP       - Miulti-Function Printers and scanners toolkit
+// TRANSPORT - Transport protocol implementation
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// W3C Trace Context test
+
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/OpenPrinting/go-mfp/log"
+)
+
+// TestTraceparentParse tests TraceparentParse and TraceparentFormat
+func TestTraceparentParse(t *testing.T) {
+	type testData struct {
+		in  string // Input string
+		out string // Expected output, "" if error expected
+	}
+
+	const valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tests := []testData{
+		{in: valid, out: valid},
+		{
+			// Future version with extra data
+			in:  "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-xyz",
+			out: valid,
+		},
+		{
+			// Version 00 with extra data
+			in: valid + "-xyz",
+		},
+		{
+			// Invalid version
+			in: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		},
+		{
+			// Zero trace ID
+			in: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		},
+		{
+			// Zero span ID
+			in: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		},
+		{
+			// Upper case
+			in: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		},
+		{
+			// Bad delimiter
+			in: "00-4bf92f3577b34da6a3ce929d0e0e4736+00f067aa0ba902b7-01",
+		},
+		{in: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"},
+		{in: ""},
+	}
+
+	for _, test := range tests {
+		tc, err := TraceparentParse(test.in)
+		out := ""
+		if err == nil {
+			out = TraceparentFormat(tc)
+		}
+
+		if out != test.out {
+			t.Errorf("%q:\nexpected: %q\npresent:  %q (%v)",
+				test.in, test.out, out, err)
+		}
+	}
+}
+
+// testTraceparentTarget is the fake HTTP server that
+// sends received traceparent header into the channel.
+func testTraceparentTarget(ch chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			ch <- rq.Header.Get(TraceparentHeader)
+		}))
+}
+
+// TestTraceparentClient tests traceparent generation and
+// propagation by the Client
+func TestTraceparentClient(t *testing.T) {
+	ch := make(chan string, 1)
+	target := testTraceparentTarget(ch)
+	defer target.Close()
+
+	clnt := NewClient(nil)
+
+	// do executes the request and returns the received header.
+	// It also returns the trace IDs, logged by the Client.
+	do := func(ctx context.Context, hdr string) (log.TraceContext, string) {
+		buf := &bytes.Buffer{}
+		lgr := log.NewLogger(log.LevelAll, log.NewJSONBackend(buf))
+		ctx = log.NewContext(ctx, lgr)
+
+		rq, _ := NewRequest(ctx, "GET", MustParseURL(target.URL), nil)
+		if hdr != "" {
+			rq.Header.Set(TraceparentHeader, hdr)
+		}
+
+		rsp, err := clnt.Do(rq)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		rsp.Body.Close()
+
+		if rq.Header.Get(TraceparentHeader) != hdr {
+			t.Errorf("caller's request modified")
+		}
+
+		received := <-ch
+		tc, err := TraceparentParse(received)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		var rec struct {
+			TraceID string `json:"trace_id"`
+			SpanID  string `json:"span_id"`
+		}
+		json.Unmarshal(buf.Bytes(), &rec)
+
+		if rec.TraceID != tc.TraceID.String() ||
+			rec.SpanID != tc.SpanID.String() {
+			t.Errorf("log fields mismatch:\nheader: %s\nlog:    %s",
+				received, buf)
+		}
+
+		return tc, received
+	}
+
+	// Generated, when absent
+	tc1, _ := do(context.Background(), "")
+	tc2, _ := do(context.Background(), "")
+	if tc1.TraceID == tc2.TraceID {
+		t.Errorf("generated: trace IDs are not unique")
+	}
+
+	// Taken from the header, when set by caller
+	const hdr = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	_, received := do(context.Background(), hdr)
+	if received != hdr {
+		t.Errorf("header:\nexpected: %s\npresent:  %s", hdr, received)
+	}
+
+	// Child span, when present in the Context
+	parent := log.NewTraceContext()
+	ctx := log.WithTraceContext(context.Background(), parent)
+	tc, _ := do(ctx, hdr)
+
+	if tc.TraceID != parent.TraceID || tc.SpanID == parent.SpanID {
+		t.Errorf("child:\nparent: %s\npresent: %s",
+			TraceparentFormat(parent), TraceparentFormat(tc))
+	}
+}
+
+// TestTraceparentProxy tests propagation of the incoming
+// traceparent header to the outgoing request by the proxy.
+func TestTraceparentProxy(t *testing.T) {
+	ch := make(chan string, 1)
+	target := testTraceparentTarget(ch)
+	defer target.Close()
+
+	var proxy *httptest.Server
+	proxy = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			query := NewServerQuery(w, rq)
+			defer query.Finish()
+
+			local := MustParseURL(proxy.URL)
+			remote := MustParseURL(target.URL)
+			urlxlat := NewURLXlat(local, remote)
+
+			Forward(query, NewClient(nil), urlxlat)
+		}))
+	defer proxy.Close()
+
+	const hdr = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	incoming, _ := TraceparentParse(hdr)
+
+	rq, _ := http.NewRequest("GET", proxy.URL+"/page", nil)
+	rq.Header.Set(TraceparentHeader, hdr)
+
+	rsp, err := http.DefaultClient.Do(rq)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	rsp.Body.Close()
+
+	received := <-ch
+	outgoing, err := TraceparentParse(received)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if outgoing.TraceID != incoming.TraceID ||
+		outgoing.SpanID == incoming.SpanID ||
+		outgoing.Flags != incoming.Flags {
+		t.Errorf("propagation:\nincoming: %s\noutgoing: %s",
+			hdr, received)
+	}
+}
@@ -13,14 +13,13 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"strings"
 
 	"github.com/OpenPrinting/go-mfp/util/missed"
 )
 
-var defaultDiaaler net.Dialer
-
 // Transport wraps [http.Transport] and adds the following functionality:
 //
 //   - "ipp", "ipps" schemes support.
@@ -28,14 +27,22 @@ var defaultDiaaler net.Dialer
 type Transport struct {
 	*http.Transport
 	templateDialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+	pool                *SharedPool // Owning SharedPool, if any
+	bind                dialBinding // Binding of outgoing connections
+	dnsCache            *DNSCache   // DNS cache, if any
 }
 
 // NewTransport creates a new Transport. Provided [http.Transport]
 // is only used as a configuration template.
+//
+// If template is nil, the default one is used, with the
+// [TLSPolicyModern] TLS policy.
 func NewTransport(template *http.Transport) *Transport {
 	if template == nil {
 		template = http.DefaultTransport.(*http.Transport).Clone()
-		template.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		template.TLSClientConfig = TLSPolicyModern.Apply(
+			&tls.Config{InsecureSkipVerify: true})
+		template.DialContext = dialBound
 	}
 
 	tr := &Transport{
@@ -45,12 +52,23 @@ func NewTransport(template *http.Transport) *Transport {
 
 	tr.DialContext = tr.dialContext
 
+	// TLS is established by the dialTLSContext, because the
+	// http.Transport would derive the TLS server name from
+	// the hacked URL host (see RoundTrip).
+	if template.DialTLSContext == nil && template.DialTLS == nil {
+		tr.DialTLSContext = tr.dialTLSContext
+	}
+
 	return tr
 }
 
 // RoundTrip executes a single HTTP transaction, returning
 // a Response for the provided Request.
 func (tr *Transport) RoundTrip(rq *http.Request) (*http.Response, error) {
+	if tr.pool != nil {
+		tr.pool.stats.requests.Add(1)
+	}
+
 	oldURL := rq.URL
 	if oldURL == nil {
 		return tr.Transport.RoundTrip(rq)
@@ -103,8 +121,23 @@ func (tr *Transport) RoundTrip(rq *http.Request) (*http.Response, error) {
 		port = defaultPort
 	}
 
+	// Interface binding override, if any, is embedded into the
+	// protocol, so connections with different bindings will not
+	// be shared by the http.Transport.
+	if ifname, ok := bindInterfaceFromContext(rq.Context()); ok {
+		proto += "." + escapePath(ifname)
+	}
+
 	newURL.Host = net.JoinHostPort(proto+"+"+host, port)
 
+	// If rq.Host is not set, http.Transport takes the Host header
+	// from the URL. Make sure, it is derived from the original URL,
+	// not from the hacked one.
+	if rq.Host == "" {
+		defer func() { rq.Host = "" }()
+		requestAdjustHost(rq, oldURL)
+	}
+
 	// Replace Request URL with the hacked URL. Restore after use
 	defer func() { rq.URL = oldURL }()
 	rq.URL = newURL
@@ -119,9 +152,19 @@ func (tr *Transport) dialContext(ctx context.Context,
 
 	host, port, _ := net.SplitHostPort(addr)
 	network, host, _ := strings.Cut(host, "+")
+	network, ifname, override := strings.Cut(network, ".")
 
 	addr = net.JoinHostPort(host, port)
 
+	bind := tr.bind
+	if override {
+		bind.ifname = unescapePath(ifname)
+	}
+
+	if network != "unix" && bind != (dialBinding{}) {
+		ctx = context.WithValue(ctx, dialBindingKey{}, bind)
+	}
+
 	if network == "unix" {
 		addr, _ = missed.StringsCutSuffix(addr, ":"+port)
 		addr = unescapePath(addr)
@@ -129,10 +172,124 @@ func (tr *Transport) dialContext(ctx context.Context,
 
 	dial := tr.templateDialContext
 	if dial == nil {
-		dial = defaultDiaaler.DialContext
+		dial = dialBound
+	}
+
+	if network == "unix" || tr.dnsCache == nil {
+		return dial(ctx, network, addr)
+	}
+
+	return tr.dialCached(ctx, dial, network, host, port)
+}
+
+// dialCached resolves host name via the DNSCache and dials
+// resolved addresses in order, until success.
+func (tr *Transport) dialCached(ctx context.Context,
+	dial func(ctx context.Context, network, addr string) (net.Conn, error),
+	network, host, port string) (net.Conn, error) {
+
+	// IP literals are dialed directly
+	if _, err := netip.ParseAddr(host); err == nil {
+		return dial(ctx, network, net.JoinHostPort(host, port))
+	}
+
+	addrs, err := tr.dnsCache.Lookup(ctx, host)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := dial(ctx, network,
+			net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr == nil {
+		firstErr = &net.OpError{Op: "dial", Net: network,
+			Err: &net.AddrError{Err: "no suitable address", Addr: host}}
+	}
+
+	return nil, firstErr
+}
+
+// dialTLSContext implements DialTLSContext callback for underlying
+// http.Transport.
+//
+// It establishes connection with the dialContext and performs TLS
+// handshake, using the real host name, decoded from the supplied
+// address, as the TLS server name. The IPv6 zone is never sent in
+// the server name.
+func (tr *Transport) dialTLSContext(ctx context.Context,
+	network, addr string) (net.Conn, error) {
+
+	conn, err := tr.dialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{}
+	if tr.TLSClientConfig != nil {
+		config = tr.TLSClientConfig.Clone()
+	}
+
+	if config.ServerName == "" {
+		config.ServerName = dialServerName(addr)
+	}
+
+	// For IP literals, the server name is not sent in the SNI
+	// and not reported in the tls.ConnectionState. Fill it in,
+	// so the connection verification hooks can identify the peer.
+	if verify := config.VerifyConnection; verify != nil {
+		name := config.ServerName
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			if cs.ServerName == "" {
+				cs.ServerName = name
+			}
+			return verify(cs)
+		}
+	}
+
+	if tr.TLSHandshakeTimeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, tr.TLSHandshakeTimeout)
+		defer cancel()
+	}
+
+	tlsConn := tls.Client(conn, config)
+	err = tlsConn.HandshakeContext(ctx)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// dialServerName returns the TLS server name for the address,
+// as passed to the dialContext.
+//
+// The IPv6 zone, if any, is stripped, as it only makes sense
+// locally and must not be sent to the server.
+func dialServerName(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	_, host, _ = strings.Cut(host, "+")
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		host = ip.WithZone("").String()
 	}
 
-	return dial(ctx, network, addr)
+	return host
 }
 
 // escapePath encodes path so it becomes syntactically correct
@@ -10,11 +10,14 @@ package transport
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
 	"testing"
 )
 
@@ -161,6 +164,18 @@ func TestTransportDial(t *testing.T) {
 			network: "tcp",
 			addr:    "localhost:39205",
 		},
+
+		{
+			dest:    "https://[fe80::1%25eth0]/",
+			network: "tcp",
+			addr:    "[fe80::1%eth0]:443",
+		},
+
+		{
+			dest:    "ipps://[fe80::1%25eth0]/",
+			network: "tcp",
+			addr:    "[fe80::1%eth0]:631",
+		},
 	}
 
 	var network, addr string
@@ -195,6 +210,154 @@ func TestTransportDial(t *testing.T) {
 	}
 }
 
+// TestTransportZone tests requests to the zone-qualified
+// IPv6 literal addresses.
+func TestTransportZone(t *testing.T) {
+	// Find the loopback interface; it will be used as zone
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	zone := ""
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			zone = iface.Name
+			break
+		}
+	}
+
+	if zone == "" {
+		t.Skip("loopback interface not found")
+	}
+
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %s", err)
+	}
+
+	// Start the TLS server that records the received Host
+	// header and the TLS server name (SNI).
+	var host, sni string
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			host = rq.Host
+		}))
+	srv.Listener.Close()
+	srv.Listener = l
+	srv.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (
+			*tls.Config, error) {
+			sni = hello.ServerName
+			return nil, nil
+		},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+
+	// Request to [::1%zone] must reach the server
+	u := MustParseURL("https://[::1%25" + zone + "]:" + port + "/")
+	if u2, err := ParseURL(u.String()); err != nil ||
+		u2.Hostname() != "::1%"+zone || u2.String() != u.String() {
+		t.Errorf("%s: zone lost: %v %v", u, u2, err)
+	}
+
+	rq, err := NewRequest(context.Background(), "GET", u, nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	clnt := NewClient(nil)
+	rsp, err := clnt.Do(rq)
+	if err != nil {
+		t.Fatalf("%s: %s", u, err)
+	}
+	rsp.Body.Close()
+
+	if expected := "[::1]:" + port; host != expected {
+		t.Errorf("%s: Host expected %q, present %q", u, expected, host)
+	}
+
+	if sni != "" {
+		t.Errorf("%s: SNI expected %q, present %q", u, "", sni)
+	}
+
+	// Now route all connections to the server and check
+	// the Host header and SNI for different targets.
+	type testData struct {
+		dest string // Destination URL
+		host string // Expected Host header
+		sni  string // Expected TLS server name
+	}
+
+	tests := []testData{
+		{
+			dest: "https://printer.local/",
+			host: "printer.local",
+			sni:  "printer.local",
+		},
+
+		{
+			dest: "ipps://printer.local/",
+			host: "printer.local:631",
+			sni:  "printer.local",
+		},
+
+		{
+			dest: "https://[fe80::1%25eth0]/",
+			host: "[fe80::1]",
+			sni:  "",
+		},
+
+		{
+			dest: "ipps://[fe80::1%25eth0]:8631/",
+			host: "[fe80::1]:8631",
+			sni:  "",
+		},
+	}
+
+	template := (http.DefaultTransport.(*http.Transport)).Clone()
+	template.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	template.DialContext = func(ctx context.Context,
+		network, _ string) (net.Conn, error) {
+		return net.Dial(network, l.Addr().String())
+	}
+
+	clnt = NewClient(NewTransport(template))
+
+	for _, test := range tests {
+		host, sni = "-", "-"
+
+		// Use request without Host, so http.Transport will
+		// take it from the URL.
+		rq, err := http.NewRequest("GET", test.dest, nil)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		rq.Host = ""
+
+		rsp, err := clnt.Do(rq)
+		if err != nil {
+			t.Errorf("%s: %s", test.dest, err)
+			continue
+		}
+		rsp.Body.Close()
+
+		if host != test.host {
+			t.Errorf("%s: Host expected %q, present %q",
+				test.dest, test.host, host)
+		}
+
+		if sni != test.sni {
+			t.Errorf("%s: SNI expected %q, present %q",
+				test.dest, test.sni, sni)
+		}
+	}
+}
+
 func TestTransport(t *testing.T) {
 
 	//return
@@ -0,0 +1,18 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// UDP multicast helpers
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Package documentation
+
+// Package udp provides low-level UDP multicast facilities,
+// shared by the WS-Discovery and mDNS implementations.
+//
+// On Linux, IP_PKTINFO/IPV6_PKTINFO control messages are used
+// to select outgoing interface and source address per send, and
+// to obtain destination address and receiving interface of the
+// incoming packets. On other systems, the portable fallback
+// is used, which selects outgoing interface by the socket options
+// and doesn't report destination address and receiving interface.
+package udp
@@ -0,0 +1,358 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// UDP multicast helpers
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Multicast group
+
+package udp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/OpenPrinting/go-mfp/internal/netstate"
+)
+
+// MulticastGroup wraps the UDP socket, bound to the multicast
+// group port, and manages per-interface group membership.
+//
+// The same socket is used for reception of multicasts and for
+// sending packets to the group via the explicitly selected
+// network interface.
+type MulticastGroup struct {
+	conn     *net.UDPConn                // Underlying UDP connection
+	group    netip.AddrPort              // Multicast group
+	joined   map[netstate.NetIf]struct{} // Interfaces we have joined
+	lock     sync.Mutex                  // Access lock for joined
+	sendLock sync.Mutex                  // Send lock, for fallback
+	closed   atomic.Bool                 // Group is closed
+}
+
+// NewMulticastGroup creates a new MulticastGroup.
+//
+// The group address must be multicast. If group port is 0,
+// the random port will be allocated by the system. Use
+// [MulticastGroup.Group] to obtain the actual port.
+//
+// Initially, group is not joined on any interface. Use
+// [MulticastGroup.Join] or [MulticastGroup.JoinAll] for
+// that purpose.
+func NewMulticastGroup(group netip.AddrPort) (*MulticastGroup, error) {
+	// Address must be multicast
+	if !group.Addr().IsMulticast() {
+		err := fmt.Errorf("%s not multicast", group.Addr())
+		return nil, err
+	}
+
+	// Open UDP connection.
+	//
+	// Note, with the multicast address being given,
+	// net.ListenUDP creates UDP socket bound to the
+	// 0.0.0.0:port (or [::0]:port) address with
+	// SO_REUSEADDR option being set.
+	network := "udp4"
+	if group.Addr().Is6() {
+		network = "udp6"
+	}
+
+	addr := &net.UDPAddr{
+		IP:   net.IP(group.Addr().AsSlice()),
+		Port: int(group.Port()),
+	}
+
+	conn, err := net.ListenUDP(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill the actual port, if was not specified
+	if group.Port() == 0 {
+		local := conn.LocalAddr().(*net.UDPAddr)
+		group = netip.AddrPortFrom(group.Addr(), uint16(local.Port))
+	}
+
+	mg := &MulticastGroup{
+		conn:   conn,
+		group:  group,
+		joined: make(map[netstate.NetIf]struct{}),
+	}
+
+	// Do system-specific setup
+	err = mg.sysSetSockOpt()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return mg, nil
+}
+
+// Close closes the MulticastGroup.
+//
+// Pending [MulticastGroup.Recv] and [MulticastGroup.Run] will
+// return immediately.
+func (mg *MulticastGroup) Close() {
+	mg.closed.Store(true)
+	mg.conn.Close()
+}
+
+// IsClosed reports if MulticastGroup is closed.
+func (mg *MulticastGroup) IsClosed() bool {
+	return mg.closed.Load()
+}
+
+// Group returns the multicast group address and port.
+func (mg *MulticastGroup) Group() netip.AddrPort {
+	return mg.group
+}
+
+// Is6 reports if MulticastGroup uses IPv6 address family
+func (mg *MulticastGroup) Is6() bool {
+	return mg.group.Addr().Is6()
+}
+
+// Interfaces returns list of interfaces, where group is joined,
+// sorted by interface index.
+func (mg *MulticastGroup) Interfaces() []netstate.NetIf {
+	mg.lock.Lock()
+	ifaces := make([]netstate.NetIf, 0, len(mg.joined))
+	for nif := range mg.joined {
+		ifaces = append(ifaces, nif)
+	}
+	mg.lock.Unlock()
+
+	sort.Slice(ifaces, func(i, j int) bool {
+		return ifaces[i].Less(ifaces[j])
+	})
+
+	return ifaces
+}
+
+// Join joins the multicast group on the network interface.
+//
+// Joining already joined interface is not an error.
+func (mg *MulticastGroup) Join(nif netstate.NetIf) error {
+	mg.lock.Lock()
+	defer mg.lock.Unlock()
+
+	return mg.joinLocked(nif)
+}
+
+// Leave leaves the multicast group on the network interface.
+//
+// Leaving not joined interface is not an error.
+func (mg *MulticastGroup) Leave(nif netstate.NetIf) error {
+	mg.lock.Lock()
+	defer mg.lock.Unlock()
+
+	return mg.leaveLocked(nif)
+}
+
+// JoinAll makes the group membership to match the list of
+// interfaces: group is joined on all interfaces from the list
+// and left on all other interfaces, previously joined.
+//
+// It attempts to process all interfaces, even if some of them
+// fail, and returns the first error encountered.
+func (mg *MulticastGroup) JoinAll(ifaces []netstate.NetIf) error {
+	mg.lock.Lock()
+	defer mg.lock.Unlock()
+
+	var errs []error
+
+	want := make(map[netstate.NetIf]struct{}, len(ifaces))
+	for _, nif := range ifaces {
+		want[nif] = struct{}{}
+	}
+
+	for nif := range mg.joined {
+		if _, found := want[nif]; !found {
+			if err := mg.leaveLocked(nif); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	for _, nif := range ifaces {
+		if err := mg.joinLocked(nif); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// HandleEvent updates group membership in response to the
+// network state change:
+//   - on [netstate.EventAddInterface], group is joined on
+//     the added interface, if it is multicast-capable or loopback
+//   - on [netstate.EventDelInterface], group membership on
+//     the deleted interface is forgotten, so when interface
+//     reappears, it will be joined again
+//
+// Other events are ignored.
+func (mg *MulticastGroup) HandleEvent(evnt netstate.Event) error {
+	switch evnt := evnt.(type) {
+	case netstate.EventAddInterface:
+		flags := evnt.Interface.Flags()
+		if flags.Any(netstate.NetIfMulticast | netstate.NetIfLoopback) {
+			return mg.Join(evnt.Interface)
+		}
+
+	case netstate.EventDelInterface:
+		mg.lock.Lock()
+		if _, found := mg.joined[evnt.Interface]; found {
+			// Interface may already be gone, so
+			// errors are expected here and ignored.
+			mg.sysLeave(evnt.Interface)
+			delete(mg.joined, evnt.Interface)
+		}
+		mg.lock.Unlock()
+	}
+
+	return nil
+}
+
+// SendTo sends the packet to the multicast group via
+// the network interface, specified by its index.
+func (mg *MulticastGroup) SendTo(ifidx int, data []byte) error {
+	return mg.send(ifidx, netip.Addr{}, data)
+}
+
+// SendFrom sends the packet to the multicast group via the
+// network interface that owns the local address, using this
+// address as the packet's source address.
+//
+// The portable fallback implementation cannot control the
+// source address and only selects the outgoing interface.
+func (mg *MulticastGroup) SendFrom(local netstate.Addr, data []byte) error {
+	if local.Addr().Is6() != mg.Is6() {
+		return fmt.Errorf("%s: address family mismatch", local.Addr())
+	}
+
+	return mg.send(local.Interface().Index(), local.Addr(), data)
+}
+
+// send is the common part of SendTo and SendFrom.
+func (mg *MulticastGroup) send(ifidx int, src netip.Addr, data []byte) error {
+	if mg.IsClosed() {
+		return net.ErrClosed
+	}
+
+	err := mg.sysSend(ifidx, src, data)
+	if err != nil {
+		err = fmt.Errorf("%s: send(if=%d): %w", mg.group, ifidx, err)
+	}
+
+	return err
+}
+
+// Recv receives the next packet.
+//
+// If MulticastGroup is closed, it returns [net.ErrClosed].
+func (mg *MulticastGroup) Recv() (Packet, error) {
+	var buf [65536]byte
+	var oob [8192]byte
+
+	n, ooblen, _, from, err := mg.conn.ReadMsgUDPAddrPort(buf[:], oob[:])
+	if err != nil {
+		if mg.IsClosed() {
+			err = net.ErrClosed
+		}
+		return Packet{}, err
+	}
+
+	p := Packet{
+		Data: append([]byte(nil), buf[:n]...),
+		Src:  netip.AddrPortFrom(from.Addr().Unmap(), from.Port()),
+	}
+
+	p.Dst, p.IfIdx = sysParseCmsg(oob[:ooblen], mg.Is6())
+
+	return p, nil
+}
+
+// Run runs the receive loop, calling deliver for each received
+// packet, until MulticastGroup is closed.
+//
+// It returns nil when MulticastGroup is closed, or the error,
+// if reception fails for any other reason.
+func (mg *MulticastGroup) Run(deliver func(Packet)) error {
+	for {
+		p, err := mg.Recv()
+		switch {
+		case errors.Is(err, net.ErrClosed):
+			return nil
+		case err != nil:
+			return err
+		}
+
+		deliver(p)
+	}
+}
+
+// joinLocked is the Join implementation. It must be called under
+// the mg.lock.
+func (mg *MulticastGroup) joinLocked(nif netstate.NetIf) error {
+	if _, found := mg.joined[nif]; found {
+		return nil
+	}
+
+	err := mg.sysJoin(nif)
+	if err != nil {
+		return fmt.Errorf("%s: join(%s): %w", mg.group.Addr(),
+			nif.Name(), err)
+	}
+
+	mg.joined[nif] = struct{}{}
+	return nil
+}
+
+// leaveLocked is the Leave implementation. It must be called under
+// the mg.lock.
+func (mg *MulticastGroup) leaveLocked(nif netstate.NetIf) error {
+	if _, found := mg.joined[nif]; !found {
+		return nil
+	}
+
+	delete(mg.joined, nif)
+
+	err := mg.sysLeave(nif)
+	if err != nil {
+		return fmt.Errorf("%s: leave(%s): %w", mg.group.Addr(),
+			nif.Name(), err)
+	}
+
+	return nil
+}
+
+// control invokes f on the underlying connection's
+// file descriptor.
+func (mg *MulticastGroup) control(f func(fd uintptr) error) error {
+	rawconn, err := mg.conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var err2 error
+	err = rawconn.Control(func(fd uintptr) {
+		err2 = f(fd)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return err2
+}
@@ -0,0 +1,250 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// UDP multicast helpers
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Multicast group test
+
+package udp
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/OpenPrinting/go-mfp/internal/netstate"
+)
+
+// testLoopback returns the loopback interface or skips the test
+func testLoopback(t *testing.T) netstate.NetIf {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("net.Interfaces: %s", err)
+	}
+
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagLoopback != 0 && ifi.Flags&net.FlagUp != 0 {
+			return netstate.NetIfFromInterface(ifi)
+		}
+	}
+
+	t.Skip("loopback interface not found")
+	return netstate.NetIf{}
+}
+
+// testRecv receives a packet with timeout
+func testRecv(t *testing.T, mg *MulticastGroup) (Packet, bool) {
+	mg.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	defer mg.conn.SetReadDeadline(time.Time{})
+
+	p, err := mg.Recv()
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return Packet{}, false
+		}
+		t.Fatalf("Recv: %s", err)
+	}
+
+	return p, true
+}
+
+// TestMulticastGroupIP4 tests join, send and receive over
+// the IPv4 loopback
+func TestMulticastGroupIP4(t *testing.T) {
+	lo := testLoopback(t)
+
+	group := netip.MustParseAddrPort("239.255.255.77:0")
+	mg, err := NewMulticastGroup(group)
+	if err != nil {
+		t.Fatalf("NewMulticastGroup: %s", err)
+	}
+	defer mg.Close()
+
+	if mg.Group().Port() == 0 {
+		t.Fatalf("Group port not allocated")
+	}
+
+	err = mg.JoinAll([]netstate.NetIf{lo})
+	if err != nil {
+		t.Skipf("JoinAll: %s", err)
+	}
+
+	if ifaces := mg.Interfaces(); len(ifaces) != 1 || ifaces[0] != lo {
+		t.Errorf("Interfaces: expected [%s], present %v", lo, ifaces)
+	}
+
+	data := []byte("hello")
+	err = mg.SendTo(lo.Index(), data)
+	if err != nil {
+		t.Fatalf("SendTo: %s", err)
+	}
+
+	p, ok := testRecv(t, mg)
+	if !ok {
+		t.Fatalf("Recv: timeout")
+	}
+
+	if !bytes.Equal(p.Data, data) {
+		t.Errorf("Data: expected %q, present %q", data, p.Data)
+	}
+
+	if p.Src.Port() != mg.Group().Port() {
+		t.Errorf("Src: expected port %d, present %s",
+			mg.Group().Port(), p.Src)
+	}
+
+	if p.IfIdx != 0 && p.IfIdx != lo.Index() {
+		t.Errorf("IfIdx: expected %d, present %d", lo.Index(), p.IfIdx)
+	}
+
+	if p.Dst.IsValid() && p.Dst != group.Addr() {
+		t.Errorf("Dst: expected %s, present %s", group.Addr(), p.Dst)
+	}
+}
+
+// TestMulticastGroupRejoin tests group rejoin after interface events
+func TestMulticastGroupRejoin(t *testing.T) {
+	lo := testLoopback(t)
+
+	mg, err := NewMulticastGroup(
+		netip.MustParseAddrPort("239.255.255.78:0"))
+	if err != nil {
+		t.Fatalf("NewMulticastGroup: %s", err)
+	}
+	defer mg.Close()
+
+	err = mg.Join(lo)
+	if err != nil {
+		t.Skipf("Join: %s", err)
+	}
+
+	// Simulate interface removal. Group must be left.
+	mg.HandleEvent(netstate.EventDelInterface{Interface: lo})
+	if ifaces := mg.Interfaces(); len(ifaces) != 0 {
+		t.Errorf("EventDelInterface: group still joined on %v", ifaces)
+	}
+
+	mg.SendTo(lo.Index(), []byte("lost"))
+	if p, ok := testRecv(t, mg); ok {
+		t.Errorf("Packet received after leave: %s", p)
+	}
+
+	// Simulate interface reappearance. Group must be re-joined.
+	err = mg.HandleEvent(netstate.EventAddInterface{Interface: lo})
+	if err != nil {
+		t.Fatalf("EventAddInterface: %s", err)
+	}
+
+	data := []byte("again")
+	err = mg.SendTo(lo.Index(), data)
+	if err != nil {
+		t.Fatalf("SendTo: %s", err)
+	}
+
+	p, ok := testRecv(t, mg)
+	if !ok {
+		t.Fatalf("Recv after rejoin: timeout")
+	}
+
+	if !bytes.Equal(p.Data, data) {
+		t.Errorf("Data: expected %q, present %q", data, p.Data)
+	}
+}
+
+// TestMulticastGroupRun tests the receive loop and Close
+func TestMulticastGroupRun(t *testing.T) {
+	lo := testLoopback(t)
+
+	mg, err := NewMulticastGroup(
+		netip.MustParseAddrPort("239.255.255.79:0"))
+	if err != nil {
+		t.Fatalf("NewMulticastGroup: %s", err)
+	}
+
+	err = mg.Join(lo)
+	if err != nil {
+		mg.Close()
+		t.Skipf("Join: %s", err)
+	}
+
+	packets := make(chan Packet, 1)
+	done := make(chan error)
+	go func() {
+		done <- mg.Run(func(p Packet) { packets <- p })
+	}()
+
+	mg.SendTo(lo.Index(), []byte("run"))
+
+	select {
+	case p := <-packets:
+		if string(p.Data) != "run" {
+			t.Errorf("Data: expected %q, present %q", "run", p.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Run: timeout")
+	}
+
+	mg.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Run: %s", err)
+	}
+
+	if err := mg.SendTo(lo.Index(), nil); err != net.ErrClosed {
+		t.Errorf("SendTo after Close: expected %v, present %v",
+			net.ErrClosed, err)
+	}
+}
+
+// TestMulticastGroupNotMulticast tests NewMulticastGroup with
+// non-multicast address
+func TestMulticastGroupNotMulticast(t *testing.T) {
+	_, err := NewMulticastGroup(netip.MustParseAddrPort("127.0.0.1:0"))
+	if err == nil {
+		t.Errorf("NewMulticastGroup: error expected")
+	}
+}
+
+// TestMulticastGroupIP6 tests join, SendFrom and receive over
+// the IPv6 loopback
+func TestMulticastGroupIP6(t *testing.T) {
+	lo := testLoopback(t)
+
+	group := netip.MustParseAddrPort("[ff15::77]:0")
+	mg, err := NewMulticastGroup(group)
+	if err != nil {
+		t.Skipf("NewMulticastGroup: %s", err)
+	}
+	defer mg.Close()
+
+	err = mg.Join(lo)
+	if err != nil {
+		t.Skipf("Join: %s", err)
+	}
+
+	local := netstate.AddrFromIPNet(net.IPNet{
+		IP:   net.IPv6loopback,
+		Mask: net.CIDRMask(128, 128),
+	}, lo)
+
+	data := []byte("hello6")
+	err = mg.SendFrom(local, data)
+	if err != nil {
+		t.Skipf("SendFrom: %s", err)
+	}
+
+	p, ok := testRecv(t, mg)
+	if !ok {
+		t.Fatalf("Recv: timeout")
+	}
+
+	if !bytes.Equal(p.Data, data) {
+		t.Errorf("Data: expected %q, present %q", data, p.Data)
+	}
+
+	if p.IfIdx != 0 && p.IfIdx != lo.Index() {
+		t.Errorf("IfIdx: expected %d, present %d", lo.Index(), p.IfIdx)
+	}
+}
@@ -0,0 +1,33 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// UDP multicast helpers
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Received packet
+
+package udp
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Packet represents a received UDP packet.
+type Packet struct {
+	Data  []byte         // Packet payload
+	Src   netip.AddrPort // Source address and port
+	Dst   netip.Addr     // Destination address, if known
+	IfIdx int            // Receiving interface index, 0 if unknown
+}
+
+// String returns string representation of the Packet, for logging.
+func (p Packet) String() string {
+	dst := "?"
+	if p.Dst.IsValid() {
+		dst = p.Dst.String()
+	}
+
+	return fmt.Sprintf("%d bytes %s->%s (if=%d)",
+		len(p.Data), p.Src, dst, p.IfIdx)
+}
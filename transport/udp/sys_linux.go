@@ -0,0 +1,200 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// UDP multicast helpers
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// System-specific part -- the Linux version
+
+package udp
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+	"unsafe"
+
+	"github.com/OpenPrinting/go-mfp/internal/netstate"
+)
+
+// sysSetSockOpt sets system-specific socket options.
+//
+// It enables reception of the IP_PKTINFO/IPV6_PKTINFO control
+// messages and sets multicast hop limit to 255, as required by
+// the mDNS.
+func (mg *MulticastGroup) sysSetSockOpt() error {
+	return mg.control(func(fd uintptr) error {
+		if mg.Is6() {
+			err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6,
+				syscall.IPV6_RECVPKTINFO, 1)
+			if err != nil {
+				return fmt.Errorf(
+					"setsockopt(IPV6_RECVPKTINFO): %w", err)
+			}
+
+			err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6,
+				syscall.IPV6_MULTICAST_HOPS, 255)
+			if err != nil {
+				return fmt.Errorf(
+					"setsockopt(IPV6_MULTICAST_HOPS): %w", err)
+			}
+
+			return nil
+		}
+
+		err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP,
+			syscall.IP_PKTINFO, 1)
+		if err != nil {
+			return fmt.Errorf("setsockopt(IP_PKTINFO): %w", err)
+		}
+
+		err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP,
+			syscall.IP_MULTICAST_TTL, 255)
+		if err != nil {
+			return fmt.Errorf("setsockopt(IP_MULTICAST_TTL): %w", err)
+		}
+
+		return nil
+	})
+}
+
+// sysJoin joins the multicast group on the network interface.
+func (mg *MulticastGroup) sysJoin(nif netstate.NetIf) error {
+	if mg.Is6() {
+		return mg.sysMembershipIP6(nif, syscall.IPV6_JOIN_GROUP)
+	}
+	return mg.sysMembershipIP4(nif, syscall.IP_ADD_MEMBERSHIP)
+}
+
+// sysLeave leaves the multicast group on the network interface.
+func (mg *MulticastGroup) sysLeave(nif netstate.NetIf) error {
+	if mg.Is6() {
+		return mg.sysMembershipIP6(nif, syscall.IPV6_LEAVE_GROUP)
+	}
+	return mg.sysMembershipIP4(nif, syscall.IP_DROP_MEMBERSHIP)
+}
+
+// sysMembershipIP4 adds or drops IPv4 group membership.
+func (mg *MulticastGroup) sysMembershipIP4(nif netstate.NetIf, opt int) error {
+	mreq := syscall.IPMreqn{
+		Multiaddr: mg.group.Addr().As4(),
+		Ifindex:   int32(nif.Index()),
+	}
+
+	return mg.control(func(fd uintptr) error {
+		return syscall.SetsockoptIPMreqn(int(fd), syscall.IPPROTO_IP,
+			opt, &mreq)
+	})
+}
+
+// sysMembershipIP6 adds or drops IPv6 group membership.
+func (mg *MulticastGroup) sysMembershipIP6(nif netstate.NetIf, opt int) error {
+	mreq := syscall.IPv6Mreq{
+		Multiaddr: mg.group.Addr().As16(),
+		Interface: uint32(nif.Index()),
+	}
+
+	return mg.control(func(fd uintptr) error {
+		return syscall.SetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IPV6,
+			opt, &mreq)
+	})
+}
+
+// sysSend sends the packet to the multicast group via the
+// specified interface. If src is valid, it is used as the
+// packet's source address.
+//
+// The outgoing interface and source address are passed with the
+// IP_PKTINFO/IPV6_PKTINFO control message, so no socket state
+// is changed and no locking is required.
+func (mg *MulticastGroup) sysSend(ifidx int, src netip.Addr,
+	data []byte) error {
+
+	var oob []byte
+
+	if mg.Is6() {
+		pktinfo := syscall.Inet6Pktinfo{Ifindex: uint32(ifidx)}
+		if src.IsValid() {
+			pktinfo.Addr = src.As16()
+		}
+
+		oob = sysCmsg(syscall.IPPROTO_IPV6, syscall.IPV6_PKTINFO,
+			unsafe.Pointer(&pktinfo), syscall.SizeofInet6Pktinfo)
+	} else {
+		pktinfo := syscall.Inet4Pktinfo{Ifindex: int32(ifidx)}
+		if src.IsValid() {
+			pktinfo.Spec_dst = src.Unmap().As4()
+		}
+
+		oob = sysCmsg(syscall.IPPROTO_IP, syscall.IP_PKTINFO,
+			unsafe.Pointer(&pktinfo), syscall.SizeofInet4Pktinfo)
+	}
+
+	dst := &net.UDPAddr{
+		IP:   net.IP(mg.group.Addr().AsSlice()),
+		Port: int(mg.group.Port()),
+	}
+
+	_, _, err := mg.conn.WriteMsgUDP(data, oob, dst)
+	return err
+}
+
+// sysCmsg builds the socket control message.
+func sysCmsg(level, typ int, data unsafe.Pointer, size int) []byte {
+	oob := make([]byte, syscall.CmsgSpace(size))
+
+	hdr := (*syscall.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	hdr.Level = int32(level)
+	hdr.Type = int32(typ)
+	hdr.SetLen(syscall.CmsgLen(size))
+
+	copy(oob[syscall.CmsgLen(0):], unsafe.Slice((*byte)(data), size))
+
+	return oob
+}
+
+// sysParseCmsg parses control messages, received with the packet,
+// and returns packet's destination address and receiving interface.
+//
+// If control messages are missed or cannot be decoded, it returns
+// netip.Addr{} and 0.
+func sysParseCmsg(oob []byte, is6 bool) (dst netip.Addr, ifidx int) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return
+	}
+
+	for _, msg := range msgs {
+		hdr := msg.Header
+		switch {
+		case !is6 && hdr.Level == syscall.IPPROTO_IP &&
+			hdr.Type == syscall.IP_PKTINFO &&
+			len(msg.Data) >= syscall.SizeofInet4Pktinfo:
+
+			var pktinfo syscall.Inet4Pktinfo
+			p := (*[syscall.SizeofInet4Pktinfo]byte)(
+				unsafe.Pointer(&pktinfo))[:]
+
+			copy(p, msg.Data)
+
+			return netip.AddrFrom4(pktinfo.Addr),
+				int(pktinfo.Ifindex)
+
+		case is6 && hdr.Level == syscall.IPPROTO_IPV6 &&
+			hdr.Type == syscall.IPV6_PKTINFO &&
+			len(msg.Data) >= syscall.SizeofInet6Pktinfo:
+
+			var pktinfo syscall.Inet6Pktinfo
+			p := (*[syscall.SizeofInet6Pktinfo]byte)(
+				unsafe.Pointer(&pktinfo))[:]
+
+			copy(p, msg.Data)
+
+			return netip.AddrFrom16(pktinfo.Addr),
+				int(pktinfo.Ifindex)
+		}
+	}
+
+	return
+}
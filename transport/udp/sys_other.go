@@ -0,0 +1,163 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// UDP multicast helpers
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// System-specific part -- the portable fallback
+
+//go:build !linux
+
+package udp
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"syscall"
+
+	"github.com/OpenPrinting/go-mfp/internal/netstate"
+)
+
+// sysSetSockOpt sets system-specific socket options.
+//
+// The portable version only sets multicast hop limit to 255,
+// as required by the mDNS.
+func (mg *MulticastGroup) sysSetSockOpt() error {
+	return mg.control(func(fd uintptr) error {
+		if mg.Is6() {
+			return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6,
+				syscall.IPV6_MULTICAST_HOPS, 255)
+		}
+
+		return syscall.SetsockoptByte(int(fd), syscall.IPPROTO_IP,
+			syscall.IP_MULTICAST_TTL, 255)
+	})
+}
+
+// sysJoin joins the multicast group on the network interface.
+func (mg *MulticastGroup) sysJoin(nif netstate.NetIf) error {
+	if mg.Is6() {
+		return mg.sysMembershipIP6(nif, syscall.IPV6_JOIN_GROUP)
+	}
+	return mg.sysMembershipIP4(nif, syscall.IP_ADD_MEMBERSHIP)
+}
+
+// sysLeave leaves the multicast group on the network interface.
+func (mg *MulticastGroup) sysLeave(nif netstate.NetIf) error {
+	if mg.Is6() {
+		return mg.sysMembershipIP6(nif, syscall.IPV6_LEAVE_GROUP)
+	}
+	return mg.sysMembershipIP4(nif, syscall.IP_DROP_MEMBERSHIP)
+}
+
+// sysMembershipIP4 adds or drops IPv4 group membership.
+//
+// Portable API identifies interface by its IPv4 address.
+func (mg *MulticastGroup) sysMembershipIP4(nif netstate.NetIf, opt int) error {
+	local, err := sysIfAddrIP4(nif.Index())
+	if err != nil {
+		return err
+	}
+
+	mreq := syscall.IPMreq{
+		Multiaddr: mg.group.Addr().As4(),
+		Interface: local.As4(),
+	}
+
+	return mg.control(func(fd uintptr) error {
+		return syscall.SetsockoptIPMreq(int(fd), syscall.IPPROTO_IP,
+			opt, &mreq)
+	})
+}
+
+// sysMembershipIP6 adds or drops IPv6 group membership.
+func (mg *MulticastGroup) sysMembershipIP6(nif netstate.NetIf, opt int) error {
+	mreq := syscall.IPv6Mreq{
+		Multiaddr: mg.group.Addr().As16(),
+		Interface: uint32(nif.Index()),
+	}
+
+	return mg.control(func(fd uintptr) error {
+		return syscall.SetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IPV6,
+			opt, &mreq)
+	})
+}
+
+// sysSend sends the packet to the multicast group via the
+// specified interface.
+//
+// The portable version selects the outgoing interface with the
+// IP_MULTICAST_IF/IPV6_MULTICAST_IF socket option, so sends are
+// serialized. The source address is chosen by the system and
+// the src parameter is ignored.
+func (mg *MulticastGroup) sysSend(ifidx int, src netip.Addr,
+	data []byte) error {
+
+	mg.sendLock.Lock()
+	defer mg.sendLock.Unlock()
+
+	var err error
+	if mg.Is6() {
+		err = mg.control(func(fd uintptr) error {
+			return syscall.SetsockoptInt(int(fd),
+				syscall.IPPROTO_IPV6,
+				syscall.IPV6_MULTICAST_IF, ifidx)
+		})
+	} else {
+		var local netip.Addr
+		local, err = sysIfAddrIP4(ifidx)
+		if err == nil {
+			err = mg.control(func(fd uintptr) error {
+				return syscall.SetsockoptInet4Addr(int(fd),
+					syscall.IPPROTO_IP,
+					syscall.IP_MULTICAST_IF, local.As4())
+			})
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	dst := &net.UDPAddr{
+		IP:   net.IP(mg.group.Addr().AsSlice()),
+		Port: int(mg.group.Port()),
+	}
+
+	_, err = mg.conn.WriteToUDP(data, dst)
+	return err
+}
+
+// sysParseCmsg parses control messages, received with the packet.
+//
+// The portable version doesn't request control messages, so
+// it always returns netip.Addr{} and 0.
+func sysParseCmsg(oob []byte, is6 bool) (dst netip.Addr, ifidx int) {
+	return
+}
+
+// sysIfAddrIP4 returns the first IPv4 address of the interface.
+func sysIfAddrIP4(ifidx int) (netip.Addr, error) {
+	ifi, err := net.InterfaceByIndex(ifidx)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	for _, addr := range addrs {
+		if ipn, ok := addr.(*net.IPNet); ok {
+			if ip, ok := netip.AddrFromSlice(ipn.IP); ok {
+				if ip = ip.Unmap(); ip.Is4() {
+					return ip, nil
+				}
+			}
+		}
+	}
+
+	return netip.Addr{}, errors.New(ifi.Name + ": no IPv4 address")
+}
@@ -11,7 +11,6 @@ package transport
 import (
 	"errors"
 	"fmt"
-	"net"
 	"net/netip"
 	"net/url"
 	"path"
@@ -103,9 +102,12 @@ func ParseAddr(addr, template string) (*url.URL, error) {
 	// Try IP addr, IP addr with port, UNIX path. Rebuild
 	// URL string, if something of above does match.
 	if tmp := parseIPAddr(host); tmp != "" {
+		// Note, tmp is already suitable as URL.Host, with
+		// IPv6 literal in square brackets, so net.JoinHostPort
+		// cannot be used here.
 		host = tmp
 		if port != "" {
-			host = net.JoinHostPort(host, port)
+			host += ":" + port
 		}
 	} else if tmp := parseIPAddrPort(host); tmp != "" {
 		host = tmp
@@ -168,10 +170,14 @@ func parseSplit(s string) (scheme, host, path string, ok bool) {
 //   - IPv6 in square brackets ("[2001:db8::1]")
 //   - domain name ("example.com")
 //
+// IPv6 zone is accepted in the "fe80::1%eth0" form and, in square
+// brackets, in the RFC 6874 form ("[fe80::1%25eth0]").
+//
 // The returned string is "" on a error, or suitable as the URL.Host on success
 func parseIPAddr(addr string) string {
 	if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") &&
 		strings.IndexByte(addr, ':') >= 0 {
+		addr = urlUnescapeZone(addr)
 		addr = addr[1 : len(addr)-1]
 	}
 
@@ -184,7 +190,7 @@ func parseIPAddr(addr string) string {
 		return ""
 	}
 
-	host := ip.String()
+	host := NormalizeAddr(ip).String()
 	if strings.IndexByte(host, ':') >= 0 {
 		return "[" + host + "]"
 	}
@@ -199,12 +205,15 @@ func parseIPAddr(addr string) string {
 //   - IPv6 ("[2001:db8::1]:80")
 //   - domain with literal port ("example.com:80")
 //
+// IPv6 zone is accepted both in the "[fe80::1%eth0]:80" and in the
+// RFC 6874 ("[fe80::1%25eth0]:80") forms.
+//
 // The returned string is "" on a error, or suitable as the URL.Host on success
 func parseIPAddrPort(addr string) string {
 	// Try netip.ParseAddrPort, it handles literal addresses
-	ip, err := netip.ParseAddrPort(addr)
+	ip, err := netip.ParseAddrPort(urlUnescapeZone(addr))
 	if err == nil {
-		return ip.String()
+		return NormalizeAddrPort(ip).String()
 	}
 
 	// Try to split into host and port and parse separately
@@ -252,7 +261,17 @@ func parseIPAddrPort(addr string) string {
 //
 // Unlike [url.Parse], any unknown schemes are rejected.
 //
+// IPv6 literal addresses with zone are accepted both in the
+// [RFC 6874] form, with the percent-encoded zone delimiter
+// ("[fe80::1%25eth0]") and in the commonly used non-encoded
+// form ("[fe80::1%eth0]"). The parsed URL always uses the
+// encoded form, when converted back to string.
+//
+// IPv4-mapped IPv6 literal addresses ("[::ffff:192.0.2.1]") are
+// converted into the IPv4 form (see [NormalizeURL]).
+//
 // [RFC 8089]: https://www.rfc-editor.org/rfc/rfc8089.html
+// [RFC 6874]: https://www.rfc-editor.org/rfc/rfc6874.html
 func ParseURL(in string) (*url.URL, error) {
 	// Test some corner cases
 	if in == "" {
@@ -262,7 +281,16 @@ func ParseURL(in string) (*url.URL, error) {
 	// Parse the URL string
 	u, err := url.Parse(in)
 	if err != nil {
-		return nil, ErrURLInvalid
+		// Retry with the encoded IPv6 zone delimiter
+		fixed := urlEscapeZone(in)
+		if fixed == in {
+			return nil, ErrURLInvalid
+		}
+
+		u, err = url.Parse(fixed)
+		if err != nil {
+			return nil, ErrURLInvalid
+		}
 	}
 
 	// Do schema-specific checks and postprocessing
@@ -296,6 +324,7 @@ func ParseURL(in string) (*url.URL, error) {
 		return nil, ErrURLHostMissed
 	}
 
+	u.Host = normalizeHost(u.Host)
 	if port != "" && u.Port() == port {
 		u.Host, _ = missed.StringsCutSuffix(u.Host, ":"+port)
 	}
@@ -424,3 +453,60 @@ func DefaultPort(scheme string) int {
 
 	return -1
 }
+
+// urlEscapeZone percent-encodes the zone delimiter in the IPv6
+// literal host of the URL string ("[fe80::1%eth0]" becomes
+// "[fe80::1%25eth0]"), as RFC 6874 requires.
+//
+// If the URL string doesn't contain the non-encoded zone delimiter,
+// it is returned as is.
+func urlEscapeZone(in string) string {
+	beg := strings.Index(in, "://[")
+	if beg < 0 {
+		return in
+	}
+
+	beg += 4
+	end := strings.IndexByte(in[beg:], ']')
+	if end < 0 {
+		return in
+	}
+
+	end += beg
+	pct := strings.IndexByte(in[beg:end], '%')
+	if pct < 0 {
+		return in
+	}
+
+	pct += beg
+	if strings.HasPrefix(in[pct:end], "%25") {
+		return in
+	}
+
+	return in[:pct] + "%25" + in[pct+1:]
+}
+
+// urlUnescapeZone decodes the percent-encoded zone delimiter in
+// the IPv6 literal host in square brackets ("[fe80::1%25eth0]" or
+// "[fe80::1%25eth0]:80"), so the host can be parsed with the
+// [netip] functions, which don't understand the RFC 6874 form.
+//
+// If host doesn't contain the encoded zone delimiter, it is
+// returned as is.
+func urlUnescapeZone(host string) string {
+	if !strings.HasPrefix(host, "[") {
+		return host
+	}
+
+	end := strings.IndexByte(host, ']')
+	if end < 0 {
+		return host
+	}
+
+	pct := strings.Index(host[:end], "%25")
+	if pct < 0 {
+		return host
+	}
+
+	return host[:pct] + "%" + host[pct+3:]
+}
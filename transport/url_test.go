@@ -53,6 +53,27 @@ func TestParseURL(t *testing.T) {
 			out: "http://[fe80::aec5:1bff:fe1c:6fa7%252]/ipp/print",
 		},
 
+		// IPv4-mapped address is converted into IPv4
+		{
+			in:  "http://[::ffff:192.168.1.5]:80/ipp/print",
+			out: "http://192.168.1.5/ipp/print",
+		},
+
+		{
+			in:  "ipp://[::ffff:192.168.1.5]:8631/ipp/print",
+			out: "ipp://192.168.1.5:8631/ipp/print",
+		},
+
+		{
+			in:  "http://[fe80::aec5:1bff:fe1c:6fa7%eth0]:8080/ipp/print",
+			out: "http://[fe80::aec5:1bff:fe1c:6fa7%25eth0]:8080/ipp/print",
+		},
+
+		{
+			in:  "ipp://[fe80::aec5:1bff:fe1c:6fa7%eth0]:631/ipp/print",
+			out: "ipp://[fe80::aec5:1bff:fe1c:6fa7%25eth0]/ipp/print",
+		},
+
 		// IPP schemes
 		{
 			in:  "ipp://127.0.0.1/ipp/print",
@@ -229,6 +250,48 @@ func TestParseAddr(t *testing.T) {
 			out: "http://[::1]/",
 		},
 
+		{
+			in:  "::ffff:127.0.0.1",
+			out: "http://127.0.0.1/",
+		},
+
+		{
+			in:  "[::ffff:127.0.0.1]:8080",
+			out: "http://127.0.0.1:8080/",
+		},
+
+		// IPv6 addresses with zone
+		{
+			in:  "fe80::1%eth0",
+			out: "http://[fe80::1%25eth0]/",
+		},
+
+		{
+			in:  "[fe80::1%eth0]",
+			out: "http://[fe80::1%25eth0]/",
+		},
+
+		{
+			in:  "[fe80::1%25eth0]",
+			out: "http://[fe80::1%25eth0]/",
+		},
+
+		{
+			in:  "[fe80::1%eth0]:631",
+			out: "ipp://[fe80::1%25eth0]/",
+		},
+
+		{
+			in:  "[fe80::1%25eth0]:631/ipp/print",
+			out: "ipp://[fe80::1%25eth0]/ipp/print",
+		},
+
+		{
+			in:       "[fe80::1%25eth0]",
+			template: "ipps://localhost:8631/ipp/print",
+			out:      "ipps://[fe80::1%25eth0]:8631/ipp/print",
+		},
+
 		// IP4 and IP4 addresses with port
 		{
 			in:  "127.0.0.1:80",
@@ -518,6 +581,11 @@ func TestURLForcePort(t *testing.T) {
 			out: "http://[::1]:1234/",
 		},
 
+		{
+			in:  "http://[fe80::1%25eth0]/",
+			out: "http://[fe80::1%25eth0]:80/",
+		},
+
 		{
 			in:  "http://localhost/",
 			out: "http://localhost:80/",
@@ -0,0 +1,95 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// HTTP recording and replay for unit tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Cassette file format
+
+package vcr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// CassetteVersion is the current version of the cassette file format.
+const CassetteVersion = 1
+
+// Cassette contains the recorded HTTP interactions.
+//
+// It is stored as JSON. Bodies are stored base64-encoded, so binary
+// bodies (i.e., IPP messages) survive the round trip unchanged.
+type Cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is the single recorded request/response exchange.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is the recorded HTTP request.
+type Request struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Header   http.Header `json:"header,omitempty"`
+	BodyHash string      `json:"body_sha256"`
+	Body     []byte      `json:"body,omitempty"`
+}
+
+// Response is the recorded HTTP response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// LoadCassette loads the [Cassette] from file.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("vcr: %w", err)
+	}
+
+	cassette := &Cassette{}
+	err = json.Unmarshal(data, cassette)
+	if err != nil {
+		return nil, fmt.Errorf("vcr: %s: %w", path, err)
+	}
+
+	if cassette.Version != CassetteVersion {
+		return nil, fmt.Errorf("vcr: %s: unsupported version %d",
+			path, cassette.Version)
+	}
+
+	return cassette, nil
+}
+
+// Save saves the [Cassette] into file.
+func (cassette *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("vcr: %w", err)
+	}
+
+	data = append(data, '\n')
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return fmt.Errorf("vcr: %w", err)
+	}
+
+	return nil
+}
+
+// bodyHash returns the hex-encoded SHA-256 hash of the body.
+func bodyHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,28 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// HTTP recording and replay for unit tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Package documentation
+
+// Package vcr records HTTP exchanges into the replayable cassette
+// files and replays them in unit tests.
+//
+// The [Recorder] wraps the [http.RoundTripper]. In the [ModeRecord]
+// it passes requests to the underlying transport and records
+// requests and responses into the [Cassette]. In the [ModeReplay]
+// the network is not used; responses are taken from the cassette.
+//
+// Typical usage with the transport.Client:
+//
+//	rec, err := vcr.New("testdata/test.json", vcr.Options{
+//		Mode: vcr.ModeFromEnv(),
+//	})
+//	...
+//	clnt.WrapTransport(rec.Wrap)
+//	...
+//	err = rec.Save()
+//
+// This package is intended for tests only.
+package vcr
@@ -0,0 +1,413 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// HTTP recording and replay for unit tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Recorder
+
+package vcr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// ModeEnv is the environment variable that enables the [ModeRecord],
+// if set to non-empty value (see [ModeFromEnv]).
+const ModeEnv = "MFP_VCR_RECORD"
+
+// Redacted is the value, recorded instead of values of the
+// redacted headers.
+const Redacted = "REDACTED"
+
+// DefaultRedactHeaders lists headers, redacted by default.
+var DefaultRedactHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+// Mode defines the [Recorder] mode.
+type Mode int
+
+// Recorder modes:
+const (
+	ModeReplay Mode = iota // Replay from the cassette
+	ModeRecord             // Record into the cassette
+)
+
+// String returns the Mode name, for debugging.
+func (mode Mode) String() string {
+	switch mode {
+	case ModeReplay:
+		return "replay"
+	case ModeRecord:
+		return "record"
+	}
+
+	return "Mode(" + strconv.Itoa(int(mode)) + ")"
+}
+
+// ModeFromEnv returns [ModeRecord] if the [ModeEnv] environment
+// variable is set to non-empty value, [ModeReplay] otherwise.
+func ModeFromEnv() Mode {
+	if os.Getenv(ModeEnv) != "" {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// Options defines the [Recorder] options.
+type Options struct {
+	// Mode is the Recorder mode.
+	Mode Mode
+
+	// Lenient relaxes the replay matching: requests are matched
+	// by method and URL path only; URL host and query, as well
+	// as the request body are ignored.
+	//
+	// Strict matching (the default) requires the method, the
+	// full URL and the request body hash to match.
+	Lenient bool
+
+	// RecordHeaders, if not nil, lists headers to be recorded.
+	// Other headers are dropped. If nil, all headers are recorded.
+	RecordHeaders []string
+
+	// RedactHeaders lists headers, which values are replaced with
+	// [Redacted] at record time. If nil, DefaultRedactHeaders
+	// is used.
+	RedactHeaders []string
+}
+
+// Recorder records HTTP interactions into the [Cassette] or
+// replays them.
+//
+// Recorder is safe for concurrent use. In the replay mode,
+// requests are matched against the not yet replayed interactions
+// in the recording order, so repeated identical requests receive
+// their responses in sequence.
+type Recorder struct {
+	path     string     // Cassette file path
+	opt      Options    // Recorder options
+	cassette *Cassette  // The cassette
+	replayed []bool     // Replayed interactions
+	lock     sync.Mutex // Access lock
+}
+
+// New creates a new [Recorder] for the cassette file.
+//
+// In the [ModeReplay] the cassette is loaded immediately.
+// In the [ModeRecord] the new empty cassette is created and
+// saved by the [Recorder.Save].
+func New(path string, opt Options) (*Recorder, error) {
+	if opt.RedactHeaders == nil {
+		opt.RedactHeaders = DefaultRedactHeaders
+	}
+
+	rec := &Recorder{
+		path: path,
+		opt:  opt,
+	}
+
+	switch opt.Mode {
+	case ModeReplay:
+		cassette, err := LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+
+		rec.cassette = cassette
+		rec.replayed = make([]bool, len(cassette.Interactions))
+
+	case ModeRecord:
+		rec.cassette = &Cassette{Version: CassetteVersion}
+
+	default:
+		return nil, fmt.Errorf("vcr: invalid mode %s", opt.Mode)
+	}
+
+	return rec, nil
+}
+
+// Mode returns the Recorder [Mode].
+func (rec *Recorder) Mode() Mode {
+	return rec.opt.Mode
+}
+
+// Cassette returns the Recorder's [Cassette].
+func (rec *Recorder) Cassette() *Cassette {
+	return rec.cassette
+}
+
+// Save saves the recorded cassette. In the [ModeReplay] it
+// does nothing.
+func (rec *Recorder) Save() error {
+	if rec.opt.Mode != ModeRecord {
+		return nil
+	}
+
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	return rec.cassette.Save(rec.path)
+}
+
+// Wrap returns the [http.RoundTripper] that records or replays
+// requests. In the [ModeRecord] requests are passed to next.
+// In the [ModeReplay] next is not used.
+//
+// It fits the transport.Client.WrapTransport.
+func (rec *Recorder) Wrap(next http.RoundTripper) http.RoundTripper {
+	return &roundTripper{rec: rec, next: next}
+}
+
+// roundTripper is the http.RoundTripper, returned by the Recorder.Wrap.
+type roundTripper struct {
+	rec  *Recorder
+	next http.RoundTripper
+}
+
+// RoundTrip executes a single HTTP transaction.
+func (rt *roundTripper) RoundTrip(rq *http.Request) (*http.Response, error) {
+	body, err := readBody(rq.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	recRq := Request{
+		Method:   rq.Method,
+		URL:      rq.URL.String(),
+		Header:   rt.rec.header(rq.Header),
+		BodyHash: bodyHash(body),
+		Body:     body,
+	}
+
+	if rt.rec.opt.Mode == ModeReplay {
+		return rt.rec.replay(rq, recRq)
+	}
+
+	return rt.record(rq, recRq)
+}
+
+// record passes request to the next RoundTripper and records
+// the interaction.
+func (rt *roundTripper) record(rq *http.Request,
+	recRq Request) (*http.Response, error) {
+
+	rq = rq.Clone(rq.Context())
+	rq.Body = io.NopCloser(bytes.NewReader(recRq.Body))
+	rq.ContentLength = int64(len(recRq.Body))
+
+	rsp, err := rt.next.RoundTrip(rq)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := readBody(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp.Body = io.NopCloser(bytes.NewReader(body))
+
+	rt.rec.lock.Lock()
+	rt.rec.cassette.Interactions = append(rt.rec.cassette.Interactions,
+		Interaction{
+			Request: recRq,
+			Response: Response{
+				Status: rsp.StatusCode,
+				Header: rt.rec.header(rsp.Header),
+				Body:   body,
+			},
+		})
+	rt.rec.lock.Unlock()
+
+	return rsp, nil
+}
+
+// replay returns the recorded response for the request.
+func (rec *Recorder) replay(rq *http.Request,
+	recRq Request) (*http.Response, error) {
+
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	var nearest *Interaction
+	var nearestDiff []string
+
+	for i := range rec.cassette.Interactions {
+		if rec.replayed[i] {
+			continue
+		}
+
+		interaction := &rec.cassette.Interactions[i]
+		diff := rec.diff(&interaction.Request, &recRq)
+		if len(diff) == 0 {
+			rec.replayed[i] = true
+			return interaction.Response.httpResponse(rq), nil
+		}
+
+		if nearest == nil || len(diff) < len(nearestDiff) {
+			nearest, nearestDiff = interaction, diff
+		}
+	}
+
+	return nil, &ErrNoMatch{
+		Method:  recRq.Method,
+		URL:     recRq.URL,
+		Nearest: nearest,
+		Diff:    nearestDiff,
+	}
+}
+
+// diff returns the list of differences between the recorded
+// and the actual request, in the terms of matching rules.
+// Empty list means match.
+func (rec *Recorder) diff(recorded, actual *Request) []string {
+	var diff []string
+
+	if recorded.Method != actual.Method {
+		diff = append(diff, "method differs")
+	}
+
+	if rec.opt.Lenient {
+		if urlPath(recorded.URL) != urlPath(actual.URL) {
+			diff = append(diff, "URL path differs")
+		}
+	} else {
+		if recorded.URL != actual.URL {
+			diff = append(diff, "URL differs")
+		}
+
+		if recorded.BodyHash != actual.BodyHash {
+			diff = append(diff, "body hash differs")
+		}
+	}
+
+	return diff
+}
+
+// header returns the header to be recorded: filtered by the
+// Options.RecordHeaders and redacted.
+func (rec *Recorder) header(hdr http.Header) http.Header {
+	out := make(http.Header, len(hdr))
+
+	if rec.opt.RecordHeaders == nil {
+		for name, values := range hdr {
+			out[name] = append([]string(nil), values...)
+		}
+	} else {
+		for _, name := range rec.opt.RecordHeaders {
+			name = textproto.CanonicalMIMEHeaderKey(name)
+			if values, found := hdr[name]; found {
+				out[name] = append([]string(nil), values...)
+			}
+		}
+	}
+
+	for _, name := range rec.opt.RedactHeaders {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		for i := range out[name] {
+			out[name][i] = Redacted
+		}
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+
+	return out
+}
+
+// httpResponse makes http.Response out of the recorded Response.
+func (recRsp *Response) httpResponse(rq *http.Request) *http.Response {
+	hdr := recRsp.Header.Clone()
+	if hdr == nil {
+		hdr = make(http.Header)
+	}
+
+	status := strconv.Itoa(recRsp.Status) + " " +
+		http.StatusText(recRsp.Status)
+
+	return &http.Response{
+		Status:        status,
+		StatusCode:    recRsp.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        hdr,
+		Body:          io.NopCloser(bytes.NewReader(recRsp.Body)),
+		ContentLength: int64(len(recRsp.Body)),
+		Request:       rq,
+	}
+}
+
+// ErrNoMatch is returned in the [ModeReplay], when request doesn't
+// match any of the recorded (and not yet replayed) interactions.
+type ErrNoMatch struct {
+	Method  string       // Request method
+	URL     string       // Request URL
+	Nearest *Interaction // Nearest miss, nil if none
+	Diff    []string     // Differences from the nearest miss
+}
+
+// Error returns the error string. It implements the error interface.
+func (e *ErrNoMatch) Error() string {
+	s := fmt.Sprintf("vcr: no recorded interaction for %s %s",
+		e.Method, e.URL)
+
+	if e.Nearest == nil {
+		return s + ": cassette exhausted"
+	}
+
+	s += fmt.Sprintf("; nearest: %s %s",
+		e.Nearest.Request.Method, e.Nearest.Request.URL)
+
+	for i, d := range e.Diff {
+		if i == 0 {
+			s += " ("
+		} else {
+			s += ", "
+		}
+		s += d
+	}
+
+	return s + ")"
+}
+
+// readBody reads the entire body and closes it. Nil body is Ok.
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil || body == http.NoBody {
+		return nil, nil
+	}
+
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("vcr: %w", err)
+	}
+
+	return data, nil
+}
+
+// urlPath returns path of the URL string. If URL cannot be
+// parsed, the whole string is returned.
+func urlPath(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+
+	return u.EscapedPath()
+}
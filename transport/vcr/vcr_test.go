@@ -0,0 +1,346 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// HTTP recording and replay for unit tests
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Recorder tests
+
+package vcr
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OpenPrinting/goipp"
+)
+
+// testDo performs the request via the RoundTripper and returns
+// response status and body.
+func testDo(t *testing.T, rt http.RoundTripper, method, u string,
+	body []byte, hdr http.Header) (int, []byte, error) {
+
+	rq, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for name, values := range hdr {
+		rq.Header[name] = values
+	}
+
+	rsp, err := rt.RoundTrip(rq)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer rsp.Body.Close()
+	data, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	return rsp.StatusCode, data, nil
+}
+
+// testIPPMessage returns the encoded binary IPP message
+func testIPPMessage(id uint32) []byte {
+	msg := goipp.NewRequest(goipp.DefaultVersion,
+		goipp.OpGetPrinterAttributes, id)
+	msg.Operation.Add(goipp.MakeAttribute("attributes-charset",
+		goipp.TagCharset, goipp.String("utf-8")))
+	msg.Operation.Add(goipp.MakeAttribute("attributes-natural-language",
+		goipp.TagLanguage, goipp.String("en-US")))
+	msg.Operation.Add(goipp.MakeAttribute("printer-uri",
+		goipp.TagURI, goipp.String("ipp://printer.test/ipp/print")))
+
+	data, _ := msg.EncodeBytes()
+	return data
+}
+
+// TestRecordReplay tests round-trip of binary IPP bodies through
+// the cassette and header redaction.
+func TestRecordReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, rq *http.Request) {
+			body, _ := io.ReadAll(rq.Body)
+			w.Header().Set("Content-Type", goipp.ContentType)
+			w.Header().Set("Set-Cookie", "session=12345")
+			w.Header().Set("X-Echo", "yes")
+
+			// Echo the request body, reversed, so every byte
+			// value goes through the response path.
+			for i, j := 0, len(body)-1; i < j; i, j = i+1, j-1 {
+				body[i], body[j] = body[j], body[i]
+			}
+			w.Write(body)
+		}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	hdr := http.Header{
+		"Authorization": {"Bearer secret-token"},
+		"Content-Type":  {goipp.ContentType},
+	}
+
+	// Record
+	rec, err := New(path, Options{Mode: ModeRecord})
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+
+	rt := rec.Wrap(http.DefaultTransport)
+
+	binary := make([]byte, 256)
+	for i := range binary {
+		binary[i] = byte(i)
+	}
+
+	bodies := [][]byte{testIPPMessage(1), testIPPMessage(2), binary}
+	var recorded [][]byte
+
+	for _, body := range bodies {
+		status, data, err := testDo(t, rt, "POST", srv.URL+"/ipp",
+			body, hdr)
+		if err != nil || status != http.StatusOK {
+			t.Fatalf("record: %d %v", status, err)
+		}
+		recorded = append(recorded, data)
+	}
+
+	err = rec.Save()
+	if err != nil {
+		t.Fatalf("Save: %s", err)
+	}
+
+	// Check redaction
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("LoadCassette: %s", err)
+	}
+
+	if len(cassette.Interactions) != len(bodies) {
+		t.Fatalf("recorded %d interactions, expected %d",
+			len(cassette.Interactions), len(bodies))
+	}
+
+	interaction := cassette.Interactions[0]
+	if v := interaction.Request.Header.Get("Authorization"); v != Redacted {
+		t.Errorf("Authorization not redacted: %q", v)
+	}
+
+	if v := interaction.Response.Header.Get("Set-Cookie"); v != Redacted {
+		t.Errorf("Set-Cookie not redacted: %q", v)
+	}
+
+	if v := interaction.Response.Header.Get("X-Echo"); v != "yes" {
+		t.Errorf("X-Echo: expected %q, present %q", "yes", v)
+	}
+
+	// Replay, server is not used anymore
+	srv.Close()
+
+	rec, err = New(path, Options{})
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+
+	rt = rec.Wrap(nil)
+	for i, body := range bodies {
+		status, data, err := testDo(t, rt, "POST", srv.URL+"/ipp",
+			body, hdr)
+		if err != nil || status != http.StatusOK {
+			t.Fatalf("replay: %d %v", status, err)
+		}
+
+		if !bytes.Equal(data, recorded[i]) {
+			t.Errorf("replay #%d: body mismatch:\n"+
+				"expected: %x\npresent:  %x", i, recorded[i], data)
+		}
+	}
+
+	// Cassette exhausted
+	_, _, err = testDo(t, rt, "POST", srv.URL+"/ipp", bodies[0], hdr)
+	if err == nil || !strings.HasSuffix(err.Error(), "cassette exhausted") {
+		t.Errorf("exhausted: unexpected error %v", err)
+	}
+}
+
+// TestMatcher tests replay matching and near-miss diagnostics
+func TestMatcher(t *testing.T) {
+	body1 := testIPPMessage(1)
+	body2 := testIPPMessage(2)
+
+	cassette := &Cassette{
+		Version: CassetteVersion,
+		Interactions: []Interaction{
+			{
+				Request: Request{
+					Method:   "POST",
+					URL:      "http://printer.test/ipp/print",
+					BodyHash: bodyHash(body1),
+				},
+				Response: Response{Status: 200, Body: []byte("ipp")},
+			},
+			{
+				Request: Request{
+					Method:   "GET",
+					URL:      "http://printer.test/eSCL/ScannerStatus?x=1",
+					BodyHash: bodyHash(nil),
+				},
+				Response: Response{Status: 404},
+			},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	err := cassette.Save(path)
+	if err != nil {
+		t.Fatalf("Save: %s", err)
+	}
+
+	type testData struct {
+		name    string
+		lenient bool
+		method  string
+		url     string
+		body    []byte
+		status  int    // Expected status
+		err     string // Expected error
+	}
+
+	tests := []testData{
+		{
+			name:   "strict hit",
+			method: "POST",
+			url:    "http://printer.test/ipp/print",
+			body:   body1,
+			status: 200,
+		},
+
+		{
+			name:   "strict, body differs",
+			method: "POST",
+			url:    "http://printer.test/ipp/print",
+			body:   body2,
+			err: "vcr: no recorded interaction for " +
+				"POST http://printer.test/ipp/print; " +
+				"nearest: POST http://printer.test/ipp/print " +
+				"(body hash differs)",
+		},
+
+		{
+			name:   "strict, host differs",
+			method: "GET",
+			url:    "http://other.test/eSCL/ScannerStatus?x=1",
+			err: "vcr: no recorded interaction for " +
+				"GET http://other.test/eSCL/ScannerStatus?x=1; " +
+				"nearest: GET http://printer.test/eSCL/ScannerStatus?x=1 " +
+				"(URL differs)",
+		},
+
+		{
+			name:   "strict, method and URL differ",
+			method: "PUT",
+			url:    "http://printer.test/ipp/print",
+			body:   body2,
+			err: "vcr: no recorded interaction for " +
+				"PUT http://printer.test/ipp/print; " +
+				"nearest: POST http://printer.test/ipp/print " +
+				"(method differs, body hash differs)",
+		},
+
+		{
+			name:    "lenient hit, body and host differ",
+			lenient: true,
+			method:  "POST",
+			url:     "http://127.0.0.1:631/ipp/print",
+			body:    body2,
+			status:  200,
+		},
+
+		{
+			name:    "lenient hit, query differs",
+			lenient: true,
+			method:  "GET",
+			url:     "http://printer.test/eSCL/ScannerStatus",
+			status:  404,
+		},
+
+		{
+			name:    "lenient, path differs",
+			lenient: true,
+			method:  "GET",
+			url:     "http://printer.test/eSCL/ScannerCapabilities",
+			err: "vcr: no recorded interaction for " +
+				"GET http://printer.test/eSCL/ScannerCapabilities; " +
+				"nearest: GET http://printer.test/eSCL/ScannerStatus?x=1 " +
+				"(URL path differs)",
+		},
+	}
+
+	for _, test := range tests {
+		rec, err := New(path, Options{Lenient: test.lenient})
+		if err != nil {
+			t.Fatalf("New: %s", err)
+		}
+
+		status, _, err := testDo(t, rec.Wrap(nil), test.method,
+			test.url, test.body, nil)
+
+		errstr := ""
+		if err != nil {
+			errstr = err.Error()
+
+			var errNoMatch *ErrNoMatch
+			if !errors.As(err, &errNoMatch) {
+				t.Errorf("%s: not ErrNoMatch: %T", test.name, err)
+			}
+		}
+
+		if errstr != test.err {
+			t.Errorf("%s: error mismatch:\nexpected: %s\npresent:  %s",
+				test.name, test.err, errstr)
+		}
+
+		if status != test.status {
+			t.Errorf("%s: status expected %d, present %d",
+				test.name, test.status, status)
+		}
+	}
+}
+
+// TestRecordHeaders tests header selection and custom redaction
+func TestRecordHeaders(t *testing.T) {
+	rec, err := New("", Options{
+		Mode:          ModeRecord,
+		RecordHeaders: []string{"content-type", "x-auth-token"},
+		RedactHeaders: []string{"X-Auth-Token"},
+	})
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+
+	hdr := rec.header(http.Header{
+		"Content-Type":  {"application/ipp"},
+		"X-Auth-Token":  {"secret1", "secret2"},
+		"User-Agent":    {"test"},
+		"Authorization": {"Basic dXNlcjpwYXNz"},
+	})
+
+	expected := http.Header{
+		"Content-Type": {"application/ipp"},
+		"X-Auth-Token": {Redacted, Redacted},
+	}
+
+	if !reflect.DeepEqual(hdr, expected) {
+		t.Errorf("expected: %v\npresent:  %v", expected, hdr)
+	}
+}
@@ -0,0 +1,55 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// XML mini library
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Canonical form of XML tree
+
+package xmldoc
+
+import (
+	"sort"
+
+	"github.com/OpenPrinting/go-mfp/util/generic"
+)
+
+// Canonical returns the canonical form of the XML tree, suitable
+// for comparison of semantically equal documents as text.
+//
+// In the canonical form:
+//   - [Element.Line] is set to zero
+//   - attributes are sorted by name
+//   - children are sorted by name
+//
+// Sorting is stable, so attributes and children with the same
+// name retain their relative order, as in the [Element.Similar].
+func (root Element) Canonical() Element {
+	root.Line = 0
+	root.Attrs = generic.CopySlice(root.Attrs)
+	root.Children = generic.CopySlice(root.Children)
+
+	sort.SliceStable(root.Attrs, func(i, j int) bool {
+		return root.Attrs[i].Name < root.Attrs[j].Name
+	})
+
+	sort.SliceStable(root.Children, func(i, j int) bool {
+		return root.Children[i].Name < root.Children[j].Name
+	})
+
+	for i := range root.Children {
+		root.Children[i] = root.Children[i].Canonical()
+	}
+
+	return root
+}
+
+// EncodeCanonicalString writes canonical form of the XML tree
+// (see [Element.Canonical]) into the string, one element per line.
+//
+// Similar XML trees (see [Element.Similar]) have equal canonical
+// strings, so the canonical strings can be compared line-by-line,
+// to report the differences.
+func (root Element) EncodeCanonicalString(ns Namespace) string {
+	return root.Canonical().EncodeIndentString(ns, " ")
+}
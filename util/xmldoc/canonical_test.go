@@ -0,0 +1,78 @@
+// MFP - Miulti-Function Printers and scanners toolkit
+// XML mini library
+//
+// Copyright (C) 2024 and up by Alexander Pevzner (pzz@apevzner.com)
+// See LICENSE for license terms and conditions
+//
+// Element.Canonical test
+
+package xmldoc
+
+import (
+	"testing"
+)
+
+// TestCanonical tests Element.Canonical and Element.EncodeCanonicalString
+func TestCanonical(t *testing.T) {
+	in := Element{
+		Name: "root",
+		Line: 1,
+		Attrs: []Attr{
+			{"b", "2"},
+			{"a", "1"},
+		},
+		Children: []Element{
+			{Name: "z", Text: "z1", Line: 2},
+			{Name: "y", Text: "y", Line: 3},
+			{Name: "z", Text: "z2", Line: 4},
+		},
+	}
+
+	expected := Element{
+		Name: "root",
+		Attrs: []Attr{
+			{"a", "1"},
+			{"b", "2"},
+		},
+		Children: []Element{
+			{Name: "y", Text: "y"},
+			{Name: "z", Text: "z1"},
+			{Name: "z", Text: "z2"},
+		},
+	}
+
+	out := in.Canonical()
+	if !out.Equal(expected) || out.Line != 0 {
+		t.Errorf("Element.Canonical:\nexpected: %s\npresent:  %s",
+			expected.EncodeString(nil), out.EncodeString(nil))
+	}
+
+	// Input must not be modified
+	if in.Attrs[0].Name != "b" || in.Children[0].Name != "z" {
+		t.Errorf("Element.Canonical: input modified")
+	}
+
+	// Similar trees must have equal canonical strings
+	similar := Element{
+		Name: "root",
+		Attrs: []Attr{
+			{"a", "1"},
+			{"b", "2"},
+		},
+		Children: []Element{
+			{Name: "z", Text: "z1"},
+			{Name: "z", Text: "z2"},
+			{Name: "y", Text: "y"},
+		},
+	}
+
+	if !in.Similar(similar) {
+		t.Errorf("Element.Similar: trees expected to be similar")
+	}
+
+	s1 := in.EncodeCanonicalString(nil)
+	s2 := similar.EncodeCanonicalString(nil)
+	if s1 != s2 {
+		t.Errorf("Element.EncodeCanonicalString:\n%s\n%s", s1, s2)
+	}
+}